@@ -808,19 +808,22 @@ type callMsg struct {
 	ethereum.CallMsg
 }
 
-func (m callMsg) From() common.Address         { return m.CallMsg.From }
-func (m callMsg) Nonce() uint64                { return 0 }
-func (m callMsg) IsFake() bool                 { return true }
-func (m callMsg) To() *common.Address          { return m.CallMsg.To }
-func (m callMsg) GasPrice() *big.Int           { return m.CallMsg.GasPrice }
-func (m callMsg) GasFeeCap() *big.Int          { return m.CallMsg.GasFeeCap }
-func (m callMsg) GasTipCap() *big.Int          { return m.CallMsg.GasTipCap }
-func (m callMsg) Gas() uint64                  { return m.CallMsg.Gas }
-func (m callMsg) Value() *big.Int              { return m.CallMsg.Value }
-func (m callMsg) Data() []byte                 { return m.CallMsg.Data }
-func (m callMsg) AccessList() types.AccessList { return m.CallMsg.AccessList }
-func (m callMsg) Mint() *big.Int               { return nil }
-func (m callMsg) RollupDataGas() uint64        { return 0 }
+func (m callMsg) From() common.Address                                { return m.CallMsg.From }
+func (m callMsg) Nonce() uint64                                       { return 0 }
+func (m callMsg) IsFake() bool                                        { return true }
+func (m callMsg) To() *common.Address                                 { return m.CallMsg.To }
+func (m callMsg) GasPrice() *big.Int                                  { return m.CallMsg.GasPrice }
+func (m callMsg) GasFeeCap() *big.Int                                 { return m.CallMsg.GasFeeCap }
+func (m callMsg) GasTipCap() *big.Int                                 { return m.CallMsg.GasTipCap }
+func (m callMsg) Gas() uint64                                         { return m.CallMsg.Gas }
+func (m callMsg) Value() *big.Int                                     { return m.CallMsg.Value }
+func (m callMsg) Data() []byte                                        { return m.CallMsg.Data }
+func (m callMsg) AccessList() types.AccessList                        { return m.CallMsg.AccessList }
+func (m callMsg) Mint() *big.Int                                      { return nil }
+func (m callMsg) RollupDataGas() uint64                               { return 0 }
+func (m callMsg) BlobGasFeeCap() *big.Int                             { return nil }
+func (m callMsg) BlobHashes() []common.Hash                           { return nil }
+func (m callMsg) SetCodeAuthorizations() []types.SetCodeAuthorization { return nil }
 
 // filterBackend implements filters.Backend to support filtering for logs without
 // taking bloom-bits acceleration structures into account.
@@ -876,10 +879,22 @@ func (fb *filterBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.
 	return nullSubscription()
 }
 
+func (fb *filterBackend) SubscribeDropTxsEvent(ch chan<- core.DropTxsEvent) event.Subscription {
+	return nullSubscription()
+}
+
 func (fb *filterBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
 	return fb.bc.SubscribeChainEvent(ch)
 }
 
+func (fb *filterBackend) SubscribeChainSafeEvent(ch chan<- core.ChainSafeEvent) event.Subscription {
+	return fb.bc.SubscribeChainSafeEvent(ch)
+}
+
+func (fb *filterBackend) SubscribeChainFinalizedEvent(ch chan<- core.ChainFinalizedEvent) event.Subscription {
+	return fb.bc.SubscribeChainFinalizedEvent(ch)
+}
+
 func (fb *filterBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return fb.bc.SubscribeRemovedLogsEvent(ch)
 }
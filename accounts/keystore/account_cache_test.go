@@ -89,6 +89,58 @@ func TestWatchNewFile(t *testing.T) {
 	t.Errorf("got %s, want %s", spew.Sdump(list), spew.Sdump(wantAccounts))
 }
 
+// Tests that keys provisioned through a secret mount, which links key files to
+// an atomically swapped data directory, are picked up.
+func TestWatchSymlinkedFiles(t *testing.T) {
+	t.Parallel()
+
+	dir, ks := tmpKeyStore(t, false)
+
+	// Ensure the watcher is started before adding any files.
+	ks.Accounts()
+	time.Sleep(1000 * time.Millisecond)
+
+	provision := func(version string, account accounts.Account) {
+		data := filepath.Join(dir, "..data_"+version)
+		if err := os.Mkdir(data, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := cp.CopyFile(filepath.Join(data, "key"), account.URL.Path); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Symlink(filepath.Base(data), filepath.Join(dir, "..data_tmp")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	provision("1", cachetestAccounts[0])
+	if err := os.Symlink(filepath.Join("..data", "key"), filepath.Join(dir, "key")); err != nil {
+		t.Fatal(err)
+	}
+	want := []accounts.Account{{
+		Address: cachetestAccounts[0].Address,
+		URL:     accounts.URL{Scheme: KeyStoreScheme, Path: filepath.Join(dir, "key")},
+	}}
+	if err := waitForAccounts(want, ks); err != nil {
+		t.Fatal(err)
+	}
+	// needed so that the modTime of the new key differs
+	time.Sleep(1000 * time.Millisecond)
+
+	provision("2", cachetestAccounts[1])
+	want[0].Address = cachetestAccounts[1].Address
+	if err := waitForAccounts(want, ks); err != nil {
+		t.Fatal(err)
+	}
+	// Removing the link removes the account.
+	os.Remove(filepath.Join(dir, "key"))
+	if err := waitForAccounts([]accounts.Account{}, ks); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestWatchNoDir(t *testing.T) {
 	t.Parallel()
 
@@ -56,18 +56,25 @@ func (fc *fileCache) scan(keyDir string) (mapset.Set, mapset.Set, mapset.Set, er
 	var newLastMod time.Time
 	for _, fi := range files {
 		path := filepath.Join(keyDir, fi.Name())
+		info, err := fi.Info()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		// Follow symlinks, secret mounts link the provisioned files into place
+		if info.Mode()&os.ModeSymlink != 0 {
+			if info, err = os.Stat(path); err != nil {
+				log.Trace("Ignoring broken symlink on account scan", "path", path, "err", err)
+				continue
+			}
+		}
 		// Skip any non-key files from the folder
-		if nonKeyFile(fi) {
+		if nonKeyFile(fi.Name(), info) {
 			log.Trace("Ignoring file on account scan", "path", path)
 			continue
 		}
 		// Gather the set of all and fresly modified files
 		all.Add(path)
 
-		info, err := fi.Info()
-		if err != nil {
-			return nil, nil, nil, err
-		}
 		modified := info.ModTime()
 		if modified.After(fc.lastMod) {
 			mods.Add(path)
@@ -91,14 +98,15 @@ func (fc *fileCache) scan(keyDir string) (mapset.Set, mapset.Set, mapset.Set, er
 	return creates, deletes, updates, nil
 }
 
-// nonKeyFile ignores editor backups, hidden files and folders/symlinks.
-func nonKeyFile(fi os.DirEntry) bool {
+// nonKeyFile ignores editor backups, hidden files and folders. The info of
+// symlinks must be that of their target.
+func nonKeyFile(name string, info os.FileInfo) bool {
 	// Skip editor backups and UNIX-style hidden files.
-	if strings.HasSuffix(fi.Name(), "~") || strings.HasPrefix(fi.Name(), ".") {
+	if strings.HasSuffix(name, "~") || strings.HasPrefix(name, ".") {
 		return true
 	}
-	// Skip misc special files, directories (yes, symlinks too).
-	if fi.IsDir() || !fi.Type().IsRegular() {
+	// Skip misc special files and directories.
+	if info.IsDir() || !info.Mode().IsRegular() {
 		return true
 	}
 	return false
@@ -38,6 +38,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/trie"
 	"github.com/urfave/cli/v2"
 )
 
@@ -47,11 +48,13 @@ var (
 		Name:      "init",
 		Usage:     "Bootstrap and initialize a new genesis block",
 		ArgsUsage: "<genesisPath>",
-		Flags:     utils.DatabasePathFlags,
+		Flags: append([]cli.Flag{
+			utils.StateSchemeFlag,
+		}, utils.DatabasePathFlags...),
 		Description: `
 The init command initializes a new genesis block and definition for the network.
 This is a destructive action and changes the network in which you will be
-participating.
+participating. The state is stored with the scheme given by --state.scheme.
 
 It expects the genesis file as argument.`,
 	}
@@ -91,13 +94,23 @@ The dumpgenesis command dumps the genesis block configuration in JSON format to
 			utils.MetricsInfluxDBBucketFlag,
 			utils.MetricsInfluxDBOrganizationFlag,
 			utils.TxLookupLimitFlag,
+			utils.HistoryTransactionsFlag,
+			utils.TrustedImportFlag,
 		}, utils.DatabasePathFlags...),
 		Description: `
 The import command imports blocks from an RLP-encoded form. The form can be one file
 with several RLP-encoded blocks, or several files can be used.
 
 If only one file is used, import error will result in failure. If several files are used,
-processing will proceed even if an individual RLP-file import failure occurs.`,
+processing will proceed even if an individual RLP-file import failure occurs.
+
+With --trusted.checkpoints, the files must contain blocks and receipts written by
+'geth export --receipts'. The blocks are not executed: headers, transaction and
+receipt roots are verified, and the chain is checked against the block hashes and
+state roots of the checkpoints, a JSON list of {"number", "hash", "stateRoot"}
+objects. Blocks beyond the last checkpoint are not imported. The state of the
+imported head is not generated and must be synced from the network afterwards.
+Only use this mode for files produced by nodes you operate.`,
 	}
 	exportCommand = &cli.Command{
 		Action:    exportChain,
@@ -107,6 +120,7 @@ processing will proceed even if an individual RLP-file import failure occurs.`,
 		Flags: append([]cli.Flag{
 			utils.CacheFlag,
 			utils.SyncModeFlag,
+			utils.ExportReceiptsFlag,
 		}, utils.DatabasePathFlags...),
 		Description: `
 Requires a first argument of the file to write to.
@@ -191,6 +205,17 @@ func initGenesis(ctx *cli.Context) error {
 		if err != nil {
 			utils.Fatalf("Failed to open database: %v", err)
 		}
+		// Mark the full database with its state scheme before writing any state,
+		// the light one only stores the state by hash
+		if name == "chaindata" {
+			scheme, err := rawdb.ParseStateScheme(ctx.String(utils.StateSchemeFlag.Name), chaindb)
+			if err != nil {
+				utils.Fatalf("Failed to set state scheme: %v", err)
+			}
+			if rawdb.ReadStateScheme(chaindb) == "" {
+				rawdb.WriteStateScheme(chaindb, scheme)
+			}
+		}
 		_, hash, err := core.SetupGenesisBlock(chaindb, genesis)
 		if err != nil {
 			utils.Fatalf("Failed to write genesis block: %v", err)
@@ -248,14 +273,22 @@ func importChain(ctx *cli.Context) error {
 
 	var importErr error
 
+	importFile := func(fn string) error { return utils.ImportChain(chain, fn) }
+	if ctx.IsSet(utils.TrustedImportFlag.Name) {
+		checkpoints, err := utils.ReadImportCheckpoints(ctx.String(utils.TrustedImportFlag.Name))
+		if err != nil {
+			utils.Fatalf("%v", err)
+		}
+		importFile = func(fn string) error { return utils.ImportChainTrusted(chain, fn, checkpoints) }
+	}
 	if ctx.Args().Len() == 1 {
-		if err := utils.ImportChain(chain, ctx.Args().First()); err != nil {
+		if err := importFile(ctx.Args().First()); err != nil {
 			importErr = err
 			log.Error("Import error", "err", err)
 		}
 	} else {
 		for _, arg := range ctx.Args().Slice() {
-			if err := utils.ImportChain(chain, arg); err != nil {
+			if err := importFile(arg); err != nil {
 				importErr = err
 				log.Error("Import error", "file", arg, "err", err)
 			}
@@ -305,7 +338,21 @@ func exportChain(ctx *cli.Context) error {
 
 	var err error
 	fp := ctx.Args().First()
-	if ctx.Args().Len() < 3 {
+	if ctx.Bool(utils.ExportReceiptsFlag.Name) {
+		first, last := uint64(1), chain.CurrentBlock().NumberU64()
+		if ctx.Args().Len() >= 3 {
+			f, ferr := strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+			l, lerr := strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+			if ferr != nil || lerr != nil {
+				utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+			}
+			first, last = f, l
+		}
+		if head := chain.CurrentFastBlock(); last > head.NumberU64() {
+			utils.Fatalf("Export error: block number %d larger than head block %d\n", last, head.NumberU64())
+		}
+		err = utils.ExportChainReceipts(chain, fp, first, last)
+	} else if ctx.Args().Len() < 3 {
 		err = utils.ExportChain(chain, fp)
 	} else {
 		// This can be improved to allow for numbers larger than 9223372036854775807
@@ -433,7 +480,7 @@ func dump(ctx *cli.Context) error {
 	if err != nil {
 		return err
 	}
-	state, err := state.New(root, state.NewDatabase(db), nil)
+	state, err := state.New(root, state.NewDatabaseWithConfig(db, &trie.Config{Preimages: true, Scheme: rawdb.ReadStateScheme(db)}), nil)
 	if err != nil {
 		return err
 	}
@@ -33,7 +33,9 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/usbwallet"
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/internal/debug"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/internal/flags"
 	"github.com/ethereum/go-ethereum/log"
@@ -83,7 +85,15 @@ var tomlSettings = toml.Config{
 }
 
 type ethstatsConfig struct {
-	URL string `toml:",omitempty"`
+	URL   string `toml:",omitempty"`
+	Token string `toml:",omitempty"`
+}
+
+// logConfig contains the log levels. Unlike the command line flags, they are
+// re-applied when the configuration is reloaded.
+type logConfig struct {
+	Verbosity *int   `toml:",omitempty"`
+	Vmodule   string `toml:",omitempty"`
 }
 
 type gethConfig struct {
@@ -91,6 +101,7 @@ type gethConfig struct {
 	Node     node.Config
 	Ethstats ethstatsConfig
 	Metrics  metrics.Config
+	Log      logConfig
 }
 
 func loadConfig(file string, cfg *gethConfig) error {
@@ -118,6 +129,38 @@ func defaultNodeConfig() node.Config {
 	return cfg
 }
 
+// reloadConfig re-reads the config file and applies the settings which can be
+// changed at runtime: the log levels, the RPC concurrency limit and method deny
+// list, the gas price oracle parameters and the transaction pool limits and
+// denylist. Command line flags keep precedence over the file.
+func reloadConfig(ctx *cli.Context, stack *node.Node, backend *eth.Ethereum) error {
+	file := ctx.String(configFileFlag.Name)
+	if file == "" {
+		return errors.New("no config file to reload")
+	}
+	cfg := gethConfig{
+		Eth:     ethconfig.Defaults,
+		Node:    defaultNodeConfig(),
+		Metrics: metrics.DefaultConfig,
+	}
+	if err := loadConfig(file, &cfg); err != nil {
+		return err
+	}
+	utils.SetReloadableConfig(ctx, &cfg.Node, &cfg.Eth)
+
+	if err := debug.SetLogLevels(ctx, cfg.Log.Verbosity, cfg.Log.Vmodule); err != nil {
+		return err
+	}
+	stack.SetRPCConcurrencyLimit(cfg.Node.RPCConcurrencyLimit, cfg.Node.RPCEnginePriorityWeight)
+	stack.SetRPCDeniedMethods(cfg.Node.RPCDeniedMethods)
+	if backend != nil {
+		backend.TxPool().SetLimits(cfg.Eth.TxPool)
+		backend.APIBackend.SetGasPriceOracleConfig(cfg.Eth.GPO)
+	}
+	log.Info("Reloaded configuration", "file", file)
+	return nil
+}
+
 // makeConfigNode loads geth configuration and creates a blank node instance.
 func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	// Load defaults.
@@ -132,6 +175,9 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 		if err := loadConfig(file, &cfg); err != nil {
 			utils.Fatalf("%v", err)
 		}
+		if err := debug.SetLogLevels(ctx, cfg.Log.Verbosity, cfg.Log.Vmodule); err != nil {
+			utils.Fatalf("%v", err)
+		}
 	}
 
 	// Apply flags.
@@ -149,6 +195,9 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 	if ctx.IsSet(utils.EthStatsURLFlag.Name) {
 		cfg.Ethstats.URL = ctx.String(utils.EthStatsURLFlag.Name)
 	}
+	if ctx.IsSet(utils.EthStatsTokenFlag.Name) {
+		cfg.Ethstats.Token = ctx.String(utils.EthStatsTokenFlag.Name)
+	}
 	applyMetricConfig(ctx, &cfg)
 
 	return stack, cfg
@@ -163,6 +212,10 @@ func makeFullNode(ctx *cli.Context) (*node.Node, ethapi.Backend) {
 	if ctx.IsSet(utils.OverrideTerminalTotalDifficulty.Name) {
 		cfg.Eth.OverrideTerminalTotalDifficulty = flags.GlobalBig(ctx, utils.OverrideTerminalTotalDifficulty.Name)
 	}
+	// Load the native tracer plugins before any trace may run.
+	if ctx.IsSet(utils.TracerPluginsFlag.Name) {
+		utils.LoadTracerPlugins(ctx)
+	}
 	backend, eth := utils.RegisterEthService(stack, &cfg.Eth)
 	// Warn users to migrate if they have a legacy freezer format.
 	if eth != nil && !ctx.IsSet(utils.IgnoreLegacyReceiptsFlag.Name) {
@@ -182,13 +235,33 @@ func makeFullNode(ctx *cli.Context) (*node.Node, ethapi.Backend) {
 		}
 	}
 
+	// Serve the health endpoints of full nodes.
+	if eth != nil {
+		utils.RegisterHealthService(ctx, stack, eth)
+	}
+	// Track the local transactions if requested.
+	if eth != nil && ctx.Bool(utils.TxTrackerFlag.Name) {
+		utils.RegisterTxTrackerService(ctx, stack, eth)
+	}
+	// Prune the stale state in the background if requested.
+	if eth != nil && (ctx.Bool(utils.OnlinePruningFlag.Name) || ctx.IsSet(utils.StateRetentionFlag.Name)) {
+		utils.RegisterStatePrunerService(ctx, stack, eth)
+	}
+	// Check that blocks are posted to L1 if requested.
+	if eth != nil && ctx.IsSet(utils.RollupDACheckL1RPCFlag.Name) {
+		utils.RegisterDACheckService(ctx, stack, eth)
+	}
+	// Reload the runtime tunable settings on SIGHUP and admin_reloadConfig.
+	stack.SetConfigReloader(func() error {
+		return reloadConfig(ctx, stack, eth)
+	})
 	// Configure GraphQL if requested
 	if ctx.IsSet(utils.GraphQLEnabledFlag.Name) {
 		utils.RegisterGraphQLService(stack, backend, cfg.Node)
 	}
 	// Add the Ethereum Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
-		utils.RegisterEthStatsService(stack, backend, cfg.Ethstats.URL)
+		utils.RegisterEthStatsService(stack, backend, cfg.Ethstats.URL, cfg.Ethstats.Token)
 	}
 	return stack, backend
 }
@@ -305,6 +378,10 @@ func setAccountManagerBackends(stack *node.Node) error {
 	// we can have both, but it's very confusing for the user to see the same
 	// accounts in both externally and locally, plus very racey.
 	am.AddBackend(keystore.NewKeyStore(keydir, scryptN, scryptP))
+	for _, dir := range conf.KeyStoreMounts {
+		log.Info("Watching keystore mount", "dir", dir)
+		am.AddBackend(keystore.NewKeyStore(dir, scryptN, scryptP))
+	}
 	if conf.USB {
 		// Start a USB hub for Ledger hardware wallets
 		if ledgerhub, err := usbwallet.NewLedgerHub(); err != nil {
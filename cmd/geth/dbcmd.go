@@ -18,6 +18,7 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
@@ -33,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/console/prompt"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -71,6 +73,7 @@ Remove blockchain and state databases`,
 			dbMetadataCmd,
 			dbMigrateFreezerCmd,
 			dbCheckStateContentCmd,
+			dbMigrateStateCmd,
 		},
 	}
 	dbInspectCmd = &cli.Command{
@@ -92,6 +95,15 @@ Remove blockchain and state databases`,
 		Description: `This command iterates the entire database for 32-byte keys, looking for rlp-encoded trie nodes.
 For each trie node encountered, it checks that the key corresponds to the keccak256(value). If this is not true, this indicates
 a data corruption.`,
+	}
+	dbMigrateStateCmd = &cli.Command{
+		Action: migrateState,
+		Name:   "migrate-state",
+		Flags:  utils.GroupFlags(utils.NetworkFlags, utils.DatabasePathFlags),
+		Usage:  "Convert the head state into the path-based state scheme",
+		Description: `This command rewrites the state of the current head block with the path-based
+state scheme and deletes the legacy hash-based trie nodes. The states of all other blocks are
+no longer accessible afterwards.`,
 	}
 	dbStatCmd = &cli.Command{
 		Action: dbStats,
@@ -377,6 +389,20 @@ func dbStats(ctx *cli.Context) error {
 	return nil
 }
 
+func migrateState(ctx *cli.Context) error {
+	stack, _ := makeConfigNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack, false)
+	defer db.Close()
+
+	head := rawdb.ReadHeadBlock(db)
+	if head == nil {
+		return errors.New("failed to load head block")
+	}
+	return state.MigrateToPathScheme(db, head.Root())
+}
+
 func dbCompact(ctx *cli.Context) error {
 	stack, _ := makeConfigNode(ctx)
 	defer stack.Close()
@@ -517,7 +543,7 @@ func dbDumpTrie(ctx *cli.Context) error {
 			return err
 		}
 	}
-	theTrie, err := trie.New(common.Hash{}, stRoot, trie.NewDatabase(db))
+	theTrie, err := trie.New(common.Hash{}, stRoot, trie.NewDatabaseWithConfig(db, &trie.Config{Scheme: rawdb.ReadStateScheme(db)}))
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,137 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/eth/catalyst"
+	"github.com/ethereum/go-ethereum/log"
+	cli "github.com/urfave/cli/v2"
+)
+
+var (
+	replayEngineStopFlag = &cli.BoolFlag{
+		Name:  "stop",
+		Usage: "Stop at the first call whose result differs from the recording",
+	}
+	replayEngineCommand = &cli.Command{
+		Name:      "replay-engine",
+		Usage:     "Replay an engine API recording into the node",
+		ArgsUsage: "<recording>",
+		Action:    replayEngine,
+		Flags: utils.GroupFlags([]cli.Flag{
+			replayEngineStopFlag,
+		}, nodeFlags),
+		Description: `
+geth replay-engine <recording>
+feeds the engine API calls recorded with --authrpc.record into the node, in
+order and without networking, and reports the calls whose results differ from
+the recorded ones. The node is expected to be initialized with the genesis of
+the recording, e.g. a fresh datadir after geth init.
+`,
+	}
+)
+
+func replayEngine(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	file, err := os.Open(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stack, cfg := makeConfigNode(ctx)
+	defer stack.Close()
+
+	// Replay offline, the recording alone drives the chain
+	cfg.Eth.EngineRecord = ""
+	server := stack.Server()
+	server.MaxPeers, server.NoDiscovery, server.ListenAddr = 0, true, ""
+
+	_, backend := utils.RegisterEthService(stack, &cfg.Eth)
+	if backend == nil {
+		return errors.New("engine API replay requires a full node")
+	}
+	if backend.BlockChain().Config().TerminalTotalDifficulty == nil {
+		return errors.New("engine API replay requires a terminal total difficulty")
+	}
+	if err := stack.Start(); err != nil {
+		return err
+	}
+	var (
+		api        = catalyst.NewConsensusAPI(backend)
+		dec        = json.NewDecoder(file)
+		start      = time.Now()
+		calls      int
+		mismatches int
+	)
+	for {
+		var record catalyst.EngineRecord
+		if err := dec.Decode(&record); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("invalid record %d: %v", calls, err)
+		}
+		result, err := catalyst.ReplayEngineCall(api, &record)
+		if !replayedAsRecorded(&record, result, err) {
+			mismatches++
+			have, _ := json.Marshal(result)
+			log.Warn("Replayed engine API call mismatch", "index", calls, "method", record.Method, "recorded", record.Time,
+				"have", string(have), "haveErr", err, "want", string(record.Result), "wantErr", record.Error)
+			if ctx.Bool(replayEngineStopFlag.Name) {
+				return fmt.Errorf("call %d (%s) mismatched the recording", calls, record.Method)
+			}
+		}
+		calls++
+	}
+	head := backend.BlockChain().CurrentBlock()
+	log.Info("Replayed engine API recording", "calls", calls, "mismatches", mismatches,
+		"head", head.NumberU64(), "hash", head.Hash(), "elapsed", common.PrettyDuration(time.Since(start)))
+	if mismatches > 0 {
+		return fmt.Errorf("%d of %d calls mismatched the recording", mismatches, calls)
+	}
+	return nil
+}
+
+// replayedAsRecorded reports whether a replayed call returned the recorded
+// result, or failed with the recorded error.
+func replayedAsRecorded(record *catalyst.EngineRecord, result interface{}, err error) bool {
+	if err != nil || record.Error != "" {
+		return err != nil && err.Error() == record.Error
+	}
+	blob, err := json.Marshal(result)
+	if err != nil {
+		return false
+	}
+	var have, want interface{}
+	if json.Unmarshal(blob, &have) != nil || json.Unmarshal(record.Result, &want) != nil {
+		return false
+	}
+	return reflect.DeepEqual(have, want)
+}
@@ -65,6 +65,7 @@ var (
 		utils.BootnodesFlag,
 		utils.MinFreeDiskSpaceFlag,
 		utils.KeyStoreDirFlag,
+		utils.KeyStoreMountsFlag,
 		utils.ExternalSignerFlag,
 		utils.NoUSBFlag,
 		utils.USBFlag,
@@ -80,21 +81,44 @@ var (
 		utils.EthashDatasetsOnDiskFlag,
 		utils.EthashDatasetsLockMmapFlag,
 		utils.TxPoolLocalsFlag,
+		utils.TxPoolDenylistFlag,
 		utils.TxPoolNoLocalsFlag,
 		utils.TxPoolJournalFlag,
 		utils.TxPoolRejournalFlag,
+		utils.TxPoolRemoteJournalFlag,
+		utils.TxPoolRemoteJournalCapFlag,
+		utils.TxPoolQueuePolicyFlag,
+		utils.TxPoolBroadcastBudgetFlag,
+		utils.TxPoolAuditLogFlag,
+		utils.TxPoolBansFlag,
 		utils.TxPoolPriceLimitFlag,
 		utils.TxPoolPriceBumpFlag,
+		utils.TxPoolPriceBumpMinFlag,
+		utils.TxPoolPriceBumpSameFeeFlag,
 		utils.TxPoolAccountSlotsFlag,
 		utils.TxPoolGlobalSlotsFlag,
 		utils.TxPoolAccountQueueFlag,
 		utils.TxPoolGlobalQueueFlag,
 		utils.TxPoolLifetimeFlag,
+		utils.TxPoolBlobSlotsFlag,
+		utils.TxPoolP2PQuotaFlag,
+		utils.TxPoolRPCQuotaFlag,
+		utils.TxPoolLocalQuotaFlag,
+		utils.TxTrackerFlag,
+		utils.TxTrackerResubmitFlag,
+		utils.TxTrackerFeeBumpFlag,
+		utils.TxTrackerMaxFeeBumpsFlag,
+		utils.TxTrackerConfirmationsFlag,
 		utils.SyncModeFlag,
 		utils.ExitWhenSyncedFlag,
 		utils.GCModeFlag,
+		utils.StateSchemeFlag,
+		utils.StateHistoryFlag,
 		utils.SnapshotFlag,
 		utils.TxLookupLimitFlag,
+		utils.HistoryTransactionsFlag,
+		utils.StateDiffIndexFlag,
+		utils.WitnessRecordFlag,
 		utils.LightServeFlag,
 		utils.LightIngressFlag,
 		utils.LightEgressFlag,
@@ -108,20 +132,34 @@ var (
 		utils.EthRequiredBlocksFlag,
 		utils.LegacyWhitelistFlag,
 		utils.BloomFilterSizeFlag,
+		utils.OnlinePruningFlag,
+		utils.OnlinePruningIntervalFlag,
+		utils.StateRetentionFlag,
 		utils.CacheFlag,
 		utils.CacheDatabaseFlag,
 		utils.CacheTrieFlag,
 		utils.CacheTrieJournalFlag,
 		utils.CacheTrieRejournalFlag,
+		utils.CacheWarmupFlag,
 		utils.CacheGCFlag,
 		utils.CacheSnapshotFlag,
 		utils.CacheNoPrefetchFlag,
+		utils.TriePrefetchWorkersFlag,
+		utils.TriePrefetchConcurrencyFlag,
+		utils.TriePrefetchNoImportFlag,
+		utils.DBCompactionRateFlag,
+		utils.DBCompactionWindowFlag,
+		utils.DataDirReadOnlyFlag,
+		utils.DataDirRefreshFlag,
 		utils.CachePreimagesFlag,
 		utils.FDLimitFlag,
 		utils.ListenPortFlag,
 		utils.DiscoveryPortFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
+		utils.DialBackoffFlag,
+		utils.DialMaxDelayFlag,
+		utils.DialSubnetLimitFlag,
 		utils.MiningEnabledFlag,
 		utils.MinerThreadsFlag,
 		utils.MinerNotifyFlag,
@@ -131,7 +169,26 @@ var (
 		utils.MinerEtherbaseFlag,
 		utils.MinerExtraDataFlag,
 		utils.MinerRecommitIntervalFlag,
+		utils.MinerNewPayloadRecommitFlag,
+		utils.MinerNewPayloadTimeoutFlag,
+		utils.MinerDecisionLogFlag,
+		utils.MinerTxOrderingFlag,
 		utils.MinerNoVerifyFlag,
+		utils.MinerNoPrefetchFlag,
+		utils.RollupPromiseKeyFlag,
+		utils.RollupPromiseWindowFlag,
+		utils.RollupMaxReorgDepthFlag,
+		utils.RollupHistoricalRPCFlag,
+		utils.RollupHistoricalRPCTimeoutFlag,
+		utils.RollupHistoricalBlockFlag,
+		utils.RollupVerifyDepositsFlag,
+		utils.RollupMaxTxSizeFlag,
+		utils.RollupMaxCalldataFlag,
+		utils.RollupMaxInitCodeFlag,
+		utils.RollupDACheckL1RPCFlag,
+		utils.RollupDACheckInboxFlag,
+		utils.RollupDACheckBatcherFlag,
+		utils.RollupDACheckL1StartFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
@@ -143,8 +200,10 @@ var (
 		utils.DeveloperPeriodFlag,
 		utils.DeveloperGasLimitFlag,
 		utils.VMEnableDebugFlag,
+		utils.TracerPluginsFlag,
 		utils.NetworkIdFlag,
 		utils.EthStatsURLFlag,
+		utils.EthStatsTokenFlag,
 		utils.FakePoWFlag,
 		utils.NoCompactionFlag,
 		utils.GpoBlocksFlag,
@@ -164,19 +223,36 @@ var (
 		utils.AuthListenFlag,
 		utils.AuthPortFlag,
 		utils.AuthVirtualHostsFlag,
+		utils.AdminRPCEnabledFlag,
+		utils.AdminRPCListenAddrFlag,
+		utils.AdminRPCPortFlag,
+		utils.AdminRPCVirtualHostsFlag,
 		utils.JWTSecretFlag,
+		utils.AuthRecordFlag,
+		utils.AuthSlowCallFlag,
 		utils.HTTPVirtualHostsFlag,
+		utils.HealthMaxBlockAgeFlag,
+		utils.HealthMinPeersFlag,
+		utils.HealthMaxEngineSilenceFlag,
 		utils.GraphQLEnabledFlag,
 		utils.GraphQLCORSDomainFlag,
 		utils.GraphQLVirtualHostsFlag,
 		utils.HTTPApiFlag,
 		utils.HTTPPathPrefixFlag,
+		utils.HTTPACMEDomainsFlag,
+		utils.HTTPACMECacheDirFlag,
+		utils.HTTPACMEEmailFlag,
 		utils.WSEnabledFlag,
 		utils.WSListenAddrFlag,
 		utils.WSPortFlag,
 		utils.WSApiFlag,
 		utils.WSAllowedOriginsFlag,
 		utils.WSPathPrefixFlag,
+		utils.WSSubscriptionBufferFlag,
+		utils.WSSubscriptionOverflowFlag,
+		utils.WSMaxConnectionsFlag,
+		utils.WSMaxConnectionsPerIPFlag,
+		utils.WSIdleTimeoutFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
 		utils.InsecureUnlockAllowedFlag,
@@ -184,6 +260,25 @@ var (
 		utils.RPCGlobalEVMTimeoutFlag,
 		utils.RPCGlobalTxFeeCapFlag,
 		utils.AllowUnprotectedTxs,
+		utils.RPCConcurrencyLimitFlag,
+		utils.RPCDeniedMethodsFlag,
+		utils.RPCEnginePriorityWeightFlag,
+		utils.RPCSubscriptionFanoutFlag,
+		utils.RPCFilterCheckpointsFlag,
+		utils.RPCFilterTimeoutFlag,
+		utils.RPCFilterClientLimitFlag,
+		utils.RPCFilterBacklogFlag,
+		utils.RPCTraceConcurrencyFlag,
+		utils.RPCTraceClientConcurrencyFlag,
+		utils.RPCTraceGasPerMinuteFlag,
+		utils.RPCTraceClientGasPerMinuteFlag,
+		utils.RPCTraceQueueTimeoutFlag,
+		utils.RPCTraceBlockConcurrencyFlag,
+		utils.RPCTraceIndexFlag,
+		utils.RPCTraceJobsFlag,
+		utils.RPCAccountRangeRateFlag,
+		utils.RPCComparePeersFlag,
+		utils.RPCCompressionThresholdFlag,
 	}
 
 	metricsFlags = []cli.Flag{
@@ -240,6 +335,10 @@ func init() {
 		utils.ShowDeprecated,
 		// See snapshot.go
 		snapshotCommand,
+		// See txpoolcmd.go
+		txpoolCommand,
+		// See enginecmd.go
+		replayEngineCommand,
 	}
 	sort.Sort(cli.CommandsByName(app.Commands))
 
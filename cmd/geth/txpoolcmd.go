@@ -0,0 +1,72 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/core"
+	cli "github.com/urfave/cli/v2"
+)
+
+var (
+	txpoolCommand = &cli.Command{
+		Name:  "txpool",
+		Usage: "A set of commands operating on transaction pool data",
+		Subcommands: []*cli.Command{
+			{
+				Name:      "replay",
+				Usage:     "Reconstruct the transaction pool a block was built from",
+				ArgsUsage: "<auditlog> <block>",
+				Action:    replayTxPool,
+				Description: `
+geth txpool replay <auditlog> <block>
+replays a transaction pool audit log recorded with --txpool.auditlog and prints
+the pending and queued transactions the pool held right before it saw the given
+block, together with the transactions included in the block and the pending
+ones that were skipped.
+`,
+			},
+		},
+	}
+)
+
+func replayTxPool(ctx *cli.Context) error {
+	if ctx.NArg() != 2 {
+		return fmt.Errorf("required arguments: %v", ctx.Command.ArgsUsage)
+	}
+	number, err := strconv.ParseUint(ctx.Args().Get(1), 0, 64)
+	if err != nil {
+		return fmt.Errorf("invalid block number: %v", err)
+	}
+	file, err := os.Open(ctx.Args().First())
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	snapshot, err := core.ReplayTxPoolAudit(file, number)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(snapshot)
+}
@@ -86,6 +86,7 @@ func StartNode(ctx *cli.Context, stack *node.Node, isConsole bool) {
 		if minFreeDiskSpace > 0 {
 			go monitorFreeDiskSpace(sigc, stack.InstanceDir(), uint64(minFreeDiskSpace)*1024*1024)
 		}
+		go reloadOnHangup(stack)
 
 		shutdown := func() {
 			log.Info("Got interrupt, shutting down...")
@@ -117,6 +118,19 @@ func StartNode(ctx *cli.Context, stack *node.Node, isConsole bool) {
 	}()
 }
 
+// reloadOnHangup reloads the node configuration whenever SIGHUP is received.
+func reloadOnHangup(stack *node.Node) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		log.Info("Got SIGHUP, reloading configuration")
+		if err := stack.ReloadConfig(); err != nil {
+			log.Error("Failed to reload configuration", "err", err)
+		}
+	}
+}
+
 func monitorFreeDiskSpace(sigc chan os.Signal, path string, freeDiskSpaceCritical uint64) {
 	for {
 		freeSpace, err := getFreeDiskSpace(path)
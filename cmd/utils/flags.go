@@ -19,6 +19,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"fmt"
 	"math"
 	"math/big"
@@ -33,10 +34,12 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/fdlimit"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state/pruner"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth"
@@ -45,7 +48,9 @@ import (
 	"github.com/ethereum/go-ethereum/eth/ethconfig"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/txtracker"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/objectstore"
 	"github.com/ethereum/go-ethereum/ethdb/remotedb"
 	"github.com/ethereum/go-ethereum/ethstats"
 	"github.com/ethereum/go-ethereum/graphql"
@@ -64,6 +69,7 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
 	pcsclite "github.com/gballet/go-libpcsclite"
 	gopsutil "github.com/shirou/gopsutil/mem"
 	"github.com/urfave/cli/v2"
@@ -94,6 +100,55 @@ var (
 		Usage:    "Data directory for ancient chain segments (default = inside chaindata)",
 		Category: flags.EthCategory,
 	}
+	ColdFlag = &flags.DirectoryFlag{
+		Name:     "datadir.cold",
+		Usage:    "Data directory for state untouched for --datadir.cold.age, e.g. on a slower volume (default = no tiering)",
+		Category: flags.EthCategory,
+	}
+	ColdAgeFlag = &cli.DurationFlag{
+		Name:     "datadir.cold.age",
+		Usage:    "Time after which untouched trie nodes and snapshot entries are migrated to --datadir.cold",
+		Value:    ethconfig.Defaults.DatabaseColdAge,
+		Category: flags.EthCategory,
+	}
+	AncientRemoteFlag = &cli.StringFlag{
+		Name:     "datadir.ancient.remote",
+		Usage:    "Path-style URL of an S3-compatible bucket sharing the ancient chain segments (https://endpoint/bucket[/prefix])",
+		Category: flags.EthCategory,
+	}
+	AncientRemoteRegionFlag = &cli.StringFlag{
+		Name:     "datadir.ancient.remote.region",
+		Usage:    "Region of the remote ancient store",
+		Value:    ethconfig.Defaults.DatabaseFreezerRemoteRegion,
+		Category: flags.EthCategory,
+	}
+	AncientRemoteCacheFlag = &cli.IntFlag{
+		Name:     "datadir.ancient.remote.cache",
+		Usage:    "Disk space in MB to use for caching the segments downloaded from the remote ancient store",
+		Value:    ethconfig.Defaults.DatabaseFreezerRemoteCache,
+		Category: flags.EthCategory,
+	}
+	AncientRemoteUploadFlag = &cli.BoolFlag{
+		Name:     "datadir.ancient.remote.upload",
+		Usage:    "Upload the local ancient chain segments missing from the remote ancient store",
+		Category: flags.EthCategory,
+	}
+	AncientRemoteAnonFlag = &cli.BoolFlag{
+		Name:     "datadir.ancient.remote.anonymous",
+		Usage:    "Access the remote ancient store anonymously, without signing the requests (public buckets)",
+		Category: flags.EthCategory,
+	}
+	DataDirReadOnlyFlag = &cli.BoolFlag{
+		Name:     "datadir.readonly",
+		Usage:    "Share the data directory read-only with the node owning it, serving its chain over RPC without networking",
+		Category: flags.EthCategory,
+	}
+	DataDirRefreshFlag = &cli.DurationFlag{
+		Name:     "datadir.readonly.refresh",
+		Usage:    "Interval of following the chain of the node owning the data directory shared with --datadir.readonly",
+		Value:    ethconfig.Defaults.DatabaseRefresh,
+		Category: flags.EthCategory,
+	}
 	MinFreeDiskSpaceFlag = &flags.DirectoryFlag{
 		Name:     "datadir.minfreedisk",
 		Usage:    "Minimum free disk space in MB, once reached triggers auto shut down (default = --cache.gc converted to MB, 0 = disabled)",
@@ -104,6 +159,11 @@ var (
 		Usage:    "Directory for the keystore (default = inside the datadir)",
 		Category: flags.AccountCategory,
 	}
+	KeyStoreMountsFlag = &cli.StringFlag{
+		Name:     "keystore.mounts",
+		Usage:    "Comma separated directories with externally provisioned key files, watched for changes",
+		Category: flags.AccountCategory,
+	}
 	USBFlag = &cli.BoolFlag{
 		Name:     "usb",
 		Usage:    "Enable monitoring and management of USB hardware wallets",
@@ -229,6 +289,17 @@ var (
 		Value:    "full",
 		Category: flags.EthCategory,
 	}
+	StateSchemeFlag = &cli.StringFlag{
+		Name:     "state.scheme",
+		Usage:    `Scheme to use for storing the ethereum state ("hash" or "path", default = the one of the database, or "hash" for new ones)`,
+		Category: flags.EthCategory,
+	}
+	StateHistoryFlag = &cli.Uint64Flag{
+		Name:     "state.history",
+		Usage:    "Number of recent blocks the path scheme can roll the state back to (0 = none)",
+		Value:    ethconfig.Defaults.StateHistory,
+		Category: flags.EthCategory,
+	}
 	SnapshotFlag = &cli.BoolFlag{
 		Name:     "snapshot",
 		Usage:    `Enables snapshot-database mode (default = enable)`,
@@ -241,6 +312,21 @@ var (
 		Value:    ethconfig.Defaults.TxLookupLimit,
 		Category: flags.EthCategory,
 	}
+	HistoryTransactionsFlag = &cli.Uint64Flag{
+		Name:     "history.transactions",
+		Usage:    "Number of recent blocks to maintain transactions and logs index for (0 = entire chain), overrides --txlookuplimit",
+		Category: flags.EthCategory,
+	}
+	StateDiffIndexFlag = &cli.BoolFlag{
+		Name:     "statediff.index",
+		Usage:    "Store the state changes of every imported block, served by debug_getStateDiff without re-execution",
+		Category: flags.EthCategory,
+	}
+	WitnessRecordFlag = &cli.BoolFlag{
+		Name:     "witness.record",
+		Usage:    "Record the execution witness of every imported block, served by debug_executionWitness without re-execution",
+		Category: flags.EthCategory,
+	}
 	LightKDFFlag = &cli.BoolFlag{
 		Name:     "lightkdf",
 		Usage:    "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
@@ -262,6 +348,22 @@ var (
 		Value:    2048,
 		Category: flags.EthCategory,
 	}
+	OnlinePruningFlag = &cli.BoolFlag{
+		Name:     "pruning.online",
+		Usage:    "Prune the stale state in the background while the node is running",
+		Category: flags.EthCategory,
+	}
+	OnlinePruningIntervalFlag = &cli.DurationFlag{
+		Name:     "pruning.online.interval",
+		Usage:    "Time between the end of an online state pruning and the start of the next one",
+		Value:    24 * time.Hour,
+		Category: flags.EthCategory,
+	}
+	StateRetentionFlag = &cli.StringFlag{
+		Name:     "history.state.retention",
+		Usage:    `Keep the states of the recent blocks, as a number of blocks or a period (e.g. "90d", "720h"), and prune the older ones online (implies --gcmode=archive)`,
+		Category: flags.EthCategory,
+	}
 	OverrideGrayGlacierFlag = &cli.Uint64Flag{
 		Name:     "override.grayglacier",
 		Usage:    "Manually specify Gray Glacier fork-block, overriding the bundled setting",
@@ -379,6 +481,11 @@ var (
 		Usage:    "Comma separated accounts to treat as locals (no flush, priority inclusion)",
 		Category: flags.TxPoolCategory,
 	}
+	TxPoolDenylistFlag = &cli.StringFlag{
+		Name:     "txpool.denylist",
+		Usage:    "Comma separated accounts whose transactions, sent or received, are rejected",
+		Category: flags.TxPoolCategory,
+	}
 	TxPoolNoLocalsFlag = &cli.BoolFlag{
 		Name:     "txpool.nolocals",
 		Usage:    "Disables price exemptions for locally submitted transactions",
@@ -396,6 +503,37 @@ var (
 		Value:    core.DefaultTxPoolConfig.Rejournal,
 		Category: flags.TxPoolCategory,
 	}
+	TxPoolBansFlag = &cli.StringFlag{
+		Name:     "txpool.bans",
+		Usage:    "File the temporary transaction pool sender bans are saved to (not persisted if empty)",
+		Value:    core.DefaultTxPoolConfig.Bans,
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolRemoteJournalFlag = &cli.StringFlag{
+		Name:     "txpool.remotejournal",
+		Usage:    "Disk journal for pending remote transactions to survive node restarts (disabled if empty)",
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolRemoteJournalCapFlag = &cli.Uint64Flag{
+		Name:     "txpool.remotejournalcap",
+		Usage:    "Maximum number of remote transactions journaled, the best paying ones (0 = unlimited)",
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolQueuePolicyFlag = &cli.StringFlag{
+		Name:     "txpool.queuepolicy",
+		Usage:    "JSON file of sender classes with queue limits and lifetimes of their own",
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolBroadcastBudgetFlag = &cli.Uint64Flag{
+		Name:     "txpool.broadcastbudget",
+		Usage:    "Bytes per second of transactions broadcast directly to a peer, the excess is announced (0 = unlimited)",
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolAuditLogFlag = &cli.StringFlag{
+		Name:     "txpool.auditlog",
+		Usage:    "Append-only log of transaction pool decisions for sequencing audits (disabled if empty)",
+		Category: flags.TxPoolCategory,
+	}
 	TxPoolPriceLimitFlag = &cli.Uint64Flag{
 		Name:     "txpool.pricelimit",
 		Usage:    "Minimum gas price limit to enforce for acceptance into the pool",
@@ -408,6 +546,16 @@ var (
 		Value:    ethconfig.Defaults.TxPool.PriceBump,
 		Category: flags.TxPoolCategory,
 	}
+	TxPoolPriceBumpMinFlag = &cli.Uint64Flag{
+		Name:     "txpool.pricebump.min",
+		Usage:    "Minimum absolute price bump in wei to replace an already existing transaction",
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolPriceBumpSameFeeFlag = &cli.BoolFlag{
+		Name:     "txpool.pricebump.samefee",
+		Usage:    "Allow replacing a transaction by a different one of identical fees",
+		Category: flags.TxPoolCategory,
+	}
 	TxPoolAccountSlotsFlag = &cli.Uint64Flag{
 		Name:     "txpool.accountslots",
 		Usage:    "Minimum number of executable transaction slots guaranteed per account",
@@ -438,6 +586,54 @@ var (
 		Value:    ethconfig.Defaults.TxPool.Lifetime,
 		Category: flags.TxPoolCategory,
 	}
+	TxPoolP2PQuotaFlag = &cli.Uint64Flag{
+		Name:     "txpool.quota.p2p",
+		Usage:    "Maximum number of pooled transactions received from peers (0 = unlimited)",
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolRPCQuotaFlag = &cli.Uint64Flag{
+		Name:     "txpool.quota.rpc",
+		Usage:    "Maximum number of pooled transactions submitted through RPC (0 = unlimited)",
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolLocalQuotaFlag = &cli.Uint64Flag{
+		Name:     "txpool.quota.local",
+		Usage:    "Maximum number of pooled transactions submitted locally (0 = unlimited)",
+		Category: flags.TxPoolCategory,
+	}
+	TxPoolBlobSlotsFlag = &cli.Uint64Flag{
+		Name:     "txpool.blobslots",
+		Usage:    "Maximum number of blob transactions for all accounts",
+		Value:    ethconfig.Defaults.TxPool.BlobSlots,
+		Category: flags.TxPoolCategory,
+	}
+	TxTrackerFlag = &cli.BoolFlag{
+		Name:     "txtracker",
+		Usage:    "Track the local transactions submitted through RPC until their inclusion",
+		Category: flags.TxPoolCategory,
+	}
+	TxTrackerResubmitFlag = &cli.DurationFlag{
+		Name:     "txtracker.resubmit",
+		Usage:    "Delay after which tracked transactions not included are resubmitted (0 = never)",
+		Category: flags.TxPoolCategory,
+	}
+	TxTrackerFeeBumpFlag = &cli.Uint64Flag{
+		Name:     "txtracker.feebump",
+		Usage:    "Percentage the fees of resubmitted transactions of unlocked accounts are bumped by (0 = resubmit as is)",
+		Category: flags.TxPoolCategory,
+	}
+	TxTrackerMaxFeeBumpsFlag = &cli.Uint64Flag{
+		Name:     "txtracker.maxfeebumps",
+		Usage:    "Maximum number of fee bumps of a tracked transaction",
+		Value:    txtracker.DefaultConfig.MaxFeeBumps,
+		Category: flags.TxPoolCategory,
+	}
+	TxTrackerConfirmationsFlag = &cli.Uint64Flag{
+		Name:     "txtracker.confirmations",
+		Usage:    "Number of blocks on top of the inclusion after which transactions are no longer tracked",
+		Value:    txtracker.DefaultConfig.Confirmations,
+		Category: flags.TxPoolCategory,
+	}
 
 	// Performance tuning settings
 	CacheFlag = &cli.IntFlag{
@@ -470,6 +666,11 @@ var (
 		Value:    ethconfig.Defaults.TrieCleanCacheRejournal,
 		Category: flags.PerfCategory,
 	}
+	CacheWarmupFlag = &cli.IntFlag{
+		Name:     "cache.warmup",
+		Usage:    "Number of hottest contracts to track and pre-load into the caches on startup (0 = disabled)",
+		Category: flags.PerfCategory,
+	}
 	CacheGCFlag = &cli.IntFlag{
 		Name:     "cache.gc",
 		Usage:    "Percentage of cache memory allowance to use for trie pruning (default = 25% full mode, 0% archive mode)",
@@ -487,6 +688,32 @@ var (
 		Usage:    "Disable heuristic state prefetch during block import (less CPU and disk IO, more time waiting for data)",
 		Category: flags.PerfCategory,
 	}
+	TriePrefetchWorkersFlag = &cli.IntFlag{
+		Name:     "prefetch.workers",
+		Usage:    "Maximum number of tries the trie prefetcher loads in parallel (0 = unlimited)",
+		Category: flags.PerfCategory,
+	}
+	TriePrefetchConcurrencyFlag = &cli.IntFlag{
+		Name:     "prefetch.concurrency",
+		Usage:    "Number of parallel loaders the trie prefetcher uses for a single trie",
+		Value:    1,
+		Category: flags.PerfCategory,
+	}
+	TriePrefetchNoImportFlag = &cli.BoolFlag{
+		Name:     "prefetch.noimport",
+		Usage:    "Disable the trie prefetcher during block import",
+		Category: flags.PerfCategory,
+	}
+	DBCompactionRateFlag = &cli.IntFlag{
+		Name:     "db.compaction.ratelimit",
+		Usage:    "Maximum write rate of the database compactions in MiB/s outside the compaction window (0 = unlimited)",
+		Category: flags.PerfCategory,
+	}
+	DBCompactionWindowFlag = &cli.StringFlag{
+		Name:     "db.compaction.window",
+		Usage:    "Daily UTC time window (HH:MM-HH:MM) running a full, unthrottled database compaction",
+		Category: flags.PerfCategory,
+	}
 	CachePreimagesFlag = &cli.BoolFlag{
 		Name:     "cache.preimages",
 		Usage:    "Enable recording the SHA3/keccak preimages of trie keys",
@@ -549,11 +776,113 @@ var (
 		Value:    ethconfig.Defaults.Miner.Recommit,
 		Category: flags.MinerCategory,
 	}
+	MinerNewPayloadRecommitFlag = &cli.DurationFlag{
+		Name:     "miner.newpayload-recommit",
+		Usage:    "Time interval to rebuild payloads requested by the engine API (0 = build once)",
+		Value:    ethconfig.Defaults.Miner.NewPayloadRecommit,
+		Category: flags.MinerCategory,
+	}
+	MinerNewPayloadTimeoutFlag = &cli.DurationFlag{
+		Name:     "miner.newpayload-timeout",
+		Usage:    "Maximum time to keep improving payloads requested by the engine API",
+		Value:    ethconfig.Defaults.Miner.NewPayloadTimeout,
+		Category: flags.MinerCategory,
+	}
+	MinerDecisionLogFlag = &cli.Uint64Flag{
+		Name:     "miner.decisionlog",
+		Usage:    "Number of recent blocks to log builder decisions for, served by miner_getBuilderDecisions on the authenticated endpoint (0 = disabled)",
+		Category: flags.MinerCategory,
+	}
+	MinerTxOrderingFlag = &cli.StringFlag{
+		Name:     "miner.txordering",
+		Usage:    "Order pending transactions are included in blocks in (\"price\", \"fifo\" or a registered policy)",
+		Value:    miner.PriceOrdering,
+		Category: flags.MinerCategory,
+	}
 	MinerNoVerifyFlag = &cli.BoolFlag{
 		Name:     "miner.noverify",
 		Usage:    "Disable remote sealing verification",
 		Category: flags.MinerCategory,
 	}
+	MinerNoPrefetchFlag = &cli.BoolFlag{
+		Name:     "miner.noprefetch",
+		Usage:    "Disable the trie prefetcher during block building",
+		Category: flags.MinerCategory,
+	}
+
+	// Rollup settings
+	RollupPromiseKeyFlag = &cli.StringFlag{
+		Name:     "rollup.promisekey",
+		Usage:    "Sequencer key file used to sign transaction inclusion promises (promises disabled if unset)",
+		Category: flags.RollupCategory,
+	}
+	RollupPromiseWindowFlag = &cli.Uint64Flag{
+		Name:     "rollup.promisewindow",
+		Usage:    "Number of blocks within which a promised transaction is to be included",
+		Value:    ethconfig.Defaults.InclusionPromiseWindow,
+		Category: flags.RollupCategory,
+	}
+	RollupMaxReorgDepthFlag = &cli.Uint64Flag{
+		Name:     "rollup.maxreorgdepth",
+		Usage:    "Maximum number of blocks a forkchoice update may rewind without an admin_allowReorg override (0 = unlimited)",
+		Category: flags.RollupCategory,
+	}
+	RollupHistoricalRPCFlag = &cli.StringFlag{
+		Name:     "rollup.historicalrpc",
+		Usage:    "RPC endpoint of a legacy node serving the blocks preceding --rollup.historicalblock",
+		Category: flags.RollupCategory,
+	}
+	RollupHistoricalRPCTimeoutFlag = &cli.DurationFlag{
+		Name:     "rollup.historicalrpctimeout",
+		Usage:    "Timeout of requests proxied to the legacy node (0 = unlimited)",
+		Value:    ethconfig.Defaults.HistoricalRPCTimeout,
+		Category: flags.RollupCategory,
+	}
+	RollupHistoricalBlockFlag = &cli.Uint64Flag{
+		Name:     "rollup.historicalblock",
+		Usage:    "First block served locally, preceding ones are served by the legacy node",
+		Category: flags.RollupCategory,
+	}
+	RollupVerifyDepositsFlag = &cli.BoolFlag{
+		Name:     "rollup.verifydeposits",
+		Usage:    "Reject engine API payloads whose deposits don't match the ones derived by the rollup node (verifier mode)",
+		Category: flags.RollupCategory,
+	}
+	RollupMaxTxSizeFlag = &cli.Uint64Flag{
+		Name:     "rollup.maxtxsize",
+		Usage:    "Maximum encoded size of pooled and included transactions, tightening the chain config (0 = chain config only)",
+		Category: flags.RollupCategory,
+	}
+	RollupMaxCalldataFlag = &cli.Uint64Flag{
+		Name:     "rollup.maxcalldata",
+		Usage:    "Maximum input data of pooled and included transactions, tightening the chain config (0 = chain config only)",
+		Category: flags.RollupCategory,
+	}
+	RollupMaxInitCodeFlag = &cli.Uint64Flag{
+		Name:     "rollup.maxinitcode",
+		Usage:    "Maximum input data of pooled and included contract creations, tightening the chain config (0 = chain config only)",
+		Category: flags.RollupCategory,
+	}
+	RollupDACheckL1RPCFlag = &cli.StringFlag{
+		Name:     "rollup.dacheck.l1rpc",
+		Usage:    "L1 RPC endpoint used to check that local blocks are posted in batches (check disabled if unset)",
+		Category: flags.RollupCategory,
+	}
+	RollupDACheckInboxFlag = &cli.StringFlag{
+		Name:     "rollup.dacheck.inbox",
+		Usage:    "L1 address batches are posted to",
+		Category: flags.RollupCategory,
+	}
+	RollupDACheckBatcherFlag = &cli.StringFlag{
+		Name:     "rollup.dacheck.batcher",
+		Usage:    "Sender of batch transactions (any sender if unset)",
+		Category: flags.RollupCategory,
+	}
+	RollupDACheckL1StartFlag = &cli.Uint64Flag{
+		Name:     "rollup.dacheck.l1start",
+		Usage:    "L1 block to start scanning for batches from (0 = shortly behind the L1 head)",
+		Category: flags.RollupCategory,
+	}
 
 	// Account settings
 	UnlockedAccountFlag = &cli.StringFlag{
@@ -586,6 +915,11 @@ var (
 		Usage:    "Record information useful for VM and contract debugging",
 		Category: flags.VMCategory,
 	}
+	TracerPluginsFlag = &cli.StringFlag{
+		Name:     "tracer.plugins",
+		Usage:    "Comma separated paths of Go plugins exporting native tracers",
+		Category: flags.VMCategory,
+	}
 
 	// API options.
 	RPCGlobalGasCapFlag = &cli.Uint64Flag{
@@ -609,7 +943,7 @@ var (
 	// Authenticated RPC HTTP settings
 	AuthListenFlag = &cli.StringFlag{
 		Name:     "authrpc.addr",
-		Usage:    "Listening address for authenticated APIs",
+		Usage:    "Listening address for authenticated APIs (or unix:// socket path)",
 		Value:    node.DefaultConfig.AuthAddr,
 		Category: flags.APICategory,
 	}
@@ -619,6 +953,30 @@ var (
 		Value:    node.DefaultConfig.AuthPort,
 		Category: flags.APICategory,
 	}
+	// Admin RPC HTTP settings
+	AdminRPCEnabledFlag = &cli.BoolFlag{
+		Name:     "adminrpc",
+		Usage:    "Enable the admin HTTP-RPC server, serving only the admin, debug and txpool APIs",
+		Category: flags.APICategory,
+	}
+	AdminRPCListenAddrFlag = &cli.StringFlag{
+		Name:     "adminrpc.addr",
+		Usage:    "Admin HTTP-RPC server listening interface",
+		Value:    node.DefaultAdminHost,
+		Category: flags.APICategory,
+	}
+	AdminRPCPortFlag = &cli.IntFlag{
+		Name:     "adminrpc.port",
+		Usage:    "Admin HTTP-RPC server listening port",
+		Value:    node.DefaultAdminPort,
+		Category: flags.APICategory,
+	}
+	AdminRPCVirtualHostsFlag = &cli.StringFlag{
+		Name:     "adminrpc.vhosts",
+		Usage:    "Comma separated list of virtual hostnames from which to accept admin requests (server enforced). Accepts '*' wildcard.",
+		Value:    strings.Join(node.DefaultConfig.AdminVirtualHosts, ","),
+		Category: flags.APICategory,
+	}
 	AuthVirtualHostsFlag = &cli.StringFlag{
 		Name:     "authrpc.vhosts",
 		Usage:    "Comma separated list of virtual hostnames from which to accept requests (server enforced). Accepts '*' wildcard.",
@@ -627,7 +985,116 @@ var (
 	}
 	JWTSecretFlag = &cli.StringFlag{
 		Name:     "authrpc.jwtsecret",
-		Usage:    "Path to a JWT secret to use for authenticated RPC endpoints",
+		Usage:    "Path to a JWT secret to use for authenticated RPC endpoints, or a vault://, awskms:// or gcpkms:// secret reference",
+		Category: flags.APICategory,
+	}
+	AuthRecordFlag = &cli.StringFlag{
+		Name:     "authrpc.record",
+		Usage:    "File to append the engine API calls to, for replaying them with geth replay-engine",
+		Category: flags.APICategory,
+	}
+	AuthSlowCallFlag = &cli.DurationFlag{
+		Name:     "authrpc.slowcall",
+		Usage:    "Serving time above which engine API calls importing or building blocks are logged as slow (0 = never)",
+		Value:    ethconfig.Defaults.EngineSlowCall,
+		Category: flags.APICategory,
+	}
+	RPCConcurrencyLimitFlag = &cli.IntFlag{
+		Name:     "rpc.concurrency",
+		Usage:    "Maximum number of concurrently executing HTTP/WS RPC calls, authenticated calls are queued ahead of public ones (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	RPCDeniedMethodsFlag = &cli.StringFlag{
+		Name:     "rpc.denymethods",
+		Usage:    "Comma separated list of methods rejected by the public HTTP/WS RPC endpoints",
+		Category: flags.APICategory,
+	}
+	RPCEnginePriorityWeightFlag = &cli.IntFlag{
+		Name:     "rpc.engineweight",
+		Usage:    "Number of queued authenticated RPC calls dispatched per queued public call when the concurrency limit is reached",
+		Value:    rpc.DefaultPriorityWeights[rpc.PriorityEngine],
+		Category: flags.APICategory,
+	}
+	RPCSubscriptionFanoutFlag = &cli.BoolFlag{
+		Name:     "rpc.subscription.fanout",
+		Usage:    "Share one internal feed among newHeads and logs subscriptions with identical criteria (for nodes serving many clients)",
+		Category: flags.APICategory,
+	}
+	RPCFilterCheckpointsFlag = &cli.BoolFlag{
+		Name:     "rpc.filter.checkpoints",
+		Usage:    "Persist eth_newFilter log filters and their delivery position, serving eth_getFilterChanges without gaps across restarts",
+		Category: flags.APICategory,
+	}
+	RPCFilterTimeoutFlag = &cli.DurationFlag{
+		Name:     "rpc.filter.timeout",
+		Usage:    "Uninstall filters not polled via eth_getFilterChanges for this long",
+		Value:    ethconfig.Defaults.RPCFilterTimeout,
+		Category: flags.APICategory,
+	}
+	RPCFilterClientLimitFlag = &cli.IntFlag{
+		Name:     "rpc.filter.clientlimit",
+		Usage:    "Maximum number of filters installed per client IP, the least recently polled are uninstalled beyond (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	RPCFilterBacklogFlag = &cli.IntFlag{
+		Name:     "rpc.filter.backlog",
+		Usage:    "Maximum number of undelivered hashes or logs queued per filter, the filter is uninstalled beyond (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	RPCTraceConcurrencyFlag = &cli.IntFlag{
+		Name:     "rpc.trace.concurrency",
+		Usage:    "Maximum number of debug traces running at once (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	RPCTraceClientConcurrencyFlag = &cli.IntFlag{
+		Name:     "rpc.trace.clientconcurrency",
+		Usage:    "Maximum number of debug traces running at once per client IP (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	RPCTraceGasPerMinuteFlag = &cli.Uint64Flag{
+		Name:     "rpc.trace.gasperminute",
+		Usage:    "Maximum gas traced per minute (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	RPCTraceClientGasPerMinuteFlag = &cli.Uint64Flag{
+		Name:     "rpc.trace.clientgasperminute",
+		Usage:    "Maximum gas traced per minute per client IP (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	RPCTraceQueueTimeoutFlag = &cli.DurationFlag{
+		Name:     "rpc.trace.queuetimeout",
+		Usage:    "Maximum time a debug trace waits for a free slot (0 = until the request is cancelled)",
+		Category: flags.APICategory,
+	}
+	RPCTraceBlockConcurrencyFlag = &cli.IntFlag{
+		Name:     "rpc.trace.blockconcurrency",
+		Usage:    "Number of transactions of a block traced at once (0 = number of CPUs)",
+		Category: flags.APICategory,
+	}
+	RPCTraceIndexFlag = &cli.BoolFlag{
+		Name:     "rpc.trace.index",
+		Usage:    "Index the addresses in the call traces of the chain, speeding up trace_filter address queries",
+		Category: flags.APICategory,
+	}
+	RPCTraceJobsFlag = &cli.BoolFlag{
+		Name:     "rpc.tracejobs",
+		Usage:    "Enable the background trace jobs managed via the admin API (also enabled by trace jobs in the config file)",
+		Category: flags.APICategory,
+	}
+	RPCAccountRangeRateFlag = &cli.IntFlag{
+		Name:     "rpc.accountrange.rate",
+		Usage:    "Maximum number of accounts enumerated per second per client IP via debug_accountRange (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	RPCComparePeersFlag = &cli.StringFlag{
+		Name:     "rpc.comparepeers",
+		Usage:    "Comma separated list of http(s) and ws(s) endpoints of the nodes debug_compareState may compare the state with",
+		Category: flags.APICategory,
+	}
+	RPCCompressionThresholdFlag = &cli.IntFlag{
+		Name:     "rpc.compression.threshold",
+		Usage:    "Minimum size in bytes of compressed HTTP and WS RPC responses (-1 = disable compression)",
+		Value:    node.DefaultCompressionThreshold,
 		Category: flags.APICategory,
 	}
 
@@ -637,6 +1104,11 @@ var (
 		Usage:    "Reporting URL of a ethstats service (nodename:secret@host:port)",
 		Category: flags.MetricsCategory,
 	}
+	EthStatsTokenFlag = &cli.StringFlag{
+		Name:     "ethstats.token",
+		Usage:    "Bearer token to authorize with the ethstats service (requires TLS)",
+		Category: flags.MetricsCategory,
+	}
 	FakePoWFlag = &cli.BoolFlag{
 		Name:     "fakepow",
 		Usage:    "Disables proof-of-work verification",
@@ -647,6 +1119,16 @@ var (
 		Usage:    "Disables db compaction after import",
 		Category: flags.LoggingCategory,
 	}
+	TrustedImportFlag = &cli.StringFlag{
+		Name:     "trusted.checkpoints",
+		Usage:    "Import blocks with receipts WITHOUT re-execution, verified against the trusted checkpoints in the given JSON file",
+		Category: flags.MiscCategory,
+	}
+	ExportReceiptsFlag = &cli.BoolFlag{
+		Name:     "receipts",
+		Usage:    "Export blocks with their receipts, for import with --trusted.checkpoints",
+		Category: flags.MiscCategory,
+	}
 
 	IgnoreLegacyReceiptsFlag = &cli.BoolFlag{
 		Name:     "ignore-legacy-receipts",
@@ -672,7 +1154,7 @@ var (
 	}
 	HTTPListenAddrFlag = &cli.StringFlag{
 		Name:     "http.addr",
-		Usage:    "HTTP-RPC server listening interface",
+		Usage:    "HTTP-RPC server listening interface (or unix:// socket path)",
 		Value:    node.DefaultHTTPHost,
 		Category: flags.APICategory,
 	}
@@ -706,6 +1188,36 @@ var (
 		Value:    "",
 		Category: flags.APICategory,
 	}
+	HTTPACMEDomainsFlag = &cli.StringFlag{
+		Name:     "http.acme.domains",
+		Usage:    "Comma separated domains to serve HTTP and WebSocket RPC over TLS with certificates from Let's Encrypt (requires port 443)",
+		Category: flags.APICategory,
+	}
+	HTTPACMECacheDirFlag = &cli.StringFlag{
+		Name:     "http.acme.cachedir",
+		Usage:    "Directory for ACME certificates and account keys (default = inside the datadir)",
+		Category: flags.APICategory,
+	}
+	HTTPACMEEmailFlag = &cli.StringFlag{
+		Name:     "http.acme.email",
+		Usage:    "Contact email address of the ACME account",
+		Category: flags.APICategory,
+	}
+	HealthMaxBlockAgeFlag = &cli.DurationFlag{
+		Name:     "health.maxblockage",
+		Usage:    "Maximum head block age for the node to report ready on /readyz (0 = no limit)",
+		Category: flags.APICategory,
+	}
+	HealthMinPeersFlag = &cli.IntFlag{
+		Name:     "health.minpeers",
+		Usage:    "Minimum number of peers for the node to report ready on /readyz",
+		Category: flags.APICategory,
+	}
+	HealthMaxEngineSilenceFlag = &cli.DurationFlag{
+		Name:     "health.maxenginesilence",
+		Usage:    "Maximum time since the last engine API call for the node to report ready on /readyz (0 = no limit)",
+		Category: flags.APICategory,
+	}
 	GraphQLEnabledFlag = &cli.BoolFlag{
 		Name:     "graphql",
 		Usage:    "Enable GraphQL on the HTTP-RPC server. Note that GraphQL can only be started if an HTTP server is started as well.",
@@ -730,7 +1242,7 @@ var (
 	}
 	WSListenAddrFlag = &cli.StringFlag{
 		Name:     "ws.addr",
-		Usage:    "WS-RPC server listening interface",
+		Usage:    "WS-RPC server listening interface (or unix:// socket path)",
 		Value:    node.DefaultWSHost,
 		Category: flags.APICategory,
 	}
@@ -758,6 +1270,32 @@ var (
 		Value:    "",
 		Category: flags.APICategory,
 	}
+	WSSubscriptionBufferFlag = &cli.IntFlag{
+		Name:     "ws.subbuffer",
+		Usage:    "Number of notifications queued per WS subscription (0 = write directly to the connection)",
+		Category: flags.APICategory,
+	}
+	WSSubscriptionOverflowFlag = &cli.StringFlag{
+		Name:     "ws.suboverflow",
+		Usage:    "Policy applied to a full WS subscription queue (drop-oldest, drop-newest, disconnect)",
+		Value:    string(rpc.OverflowDropOldest),
+		Category: flags.APICategory,
+	}
+	WSMaxConnectionsFlag = &cli.IntFlag{
+		Name:     "ws.maxconns",
+		Usage:    "Maximum number of concurrent WS-RPC connections (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	WSMaxConnectionsPerIPFlag = &cli.IntFlag{
+		Name:     "ws.maxconnsperip",
+		Usage:    "Maximum number of concurrent WS-RPC connections per remote IP (0 = unlimited)",
+		Category: flags.APICategory,
+	}
+	WSIdleTimeoutFlag = &cli.DurationFlag{
+		Name:     "ws.idletimeout",
+		Usage:    "Close WS-RPC connections without requests or notifications for this long (0 = disabled)",
+		Category: flags.APICategory,
+	}
 	ExecFlag = &cli.StringFlag{
 		Name:     "exec",
 		Usage:    "Execute JavaScript statement",
@@ -787,6 +1325,23 @@ var (
 		Value:    node.DefaultConfig.P2P.MaxPendingPeers,
 		Category: flags.NetworkingCategory,
 	}
+	DialBackoffFlag = &cli.Float64Flag{
+		Name:     "dial.backoff",
+		Usage:    "Factor applied to the redial delay of a node after each failed dial (1 = no backoff)",
+		Value:    1,
+		Category: flags.NetworkingCategory,
+	}
+	DialMaxDelayFlag = &cli.DurationFlag{
+		Name:     "dial.maxdelay",
+		Usage:    "Maximum redial delay reached through dial backoff",
+		Value:    time.Hour,
+		Category: flags.NetworkingCategory,
+	}
+	DialSubnetLimitFlag = &cli.UintFlag{
+		Name:     "dial.subnetlimit",
+		Usage:    "Maximum number of dynamically dialed peers within the same /24 subnet (0 = unlimited)",
+		Category: flags.NetworkingCategory,
+	}
 	ListenPortFlag = &cli.IntFlag{
 		Name:     "port",
 		Usage:    "Network listening port",
@@ -801,7 +1356,7 @@ var (
 	}
 	NodeKeyFileFlag = &cli.StringFlag{
 		Name:     "nodekey",
-		Usage:    "P2P node key file",
+		Usage:    "P2P node key file, or a vault://, awskms:// or gcpkms:// secret reference",
 		Category: flags.NetworkingCategory,
 	}
 	NodeKeyHexFlag = &cli.StringFlag{
@@ -990,6 +1545,13 @@ var (
 	DatabasePathFlags = []cli.Flag{
 		DataDirFlag,
 		AncientFlag,
+		ColdFlag,
+		ColdAgeFlag,
+		AncientRemoteFlag,
+		AncientRemoteRegionFlag,
+		AncientRemoteCacheFlag,
+		AncientRemoteUploadFlag,
+		AncientRemoteAnonFlag,
 		RemoteDBFlag,
 	}
 )
@@ -1045,7 +1607,7 @@ func setNodeKey(ctx *cli.Context, cfg *p2p.Config) {
 	case file != "" && hex != "":
 		Fatalf("Options %q and %q are mutually exclusive", NodeKeyFileFlag.Name, NodeKeyHexFlag.Name)
 	case file != "":
-		if key, err = crypto.LoadECDSA(file); err != nil {
+		if key, err = node.LoadNodeKey(file); err != nil {
 			Fatalf("Option %q: %v", NodeKeyFileFlag.Name, err)
 		}
 		cfg.PrivateKey = key
@@ -1203,6 +1765,46 @@ func setHTTP(ctx *cli.Context, cfg *node.Config) {
 	if ctx.IsSet(AllowUnprotectedTxs.Name) {
 		cfg.AllowUnprotectedTxs = ctx.Bool(AllowUnprotectedTxs.Name)
 	}
+	if ctx.IsSet(RPCConcurrencyLimitFlag.Name) {
+		cfg.RPCConcurrencyLimit = ctx.Int(RPCConcurrencyLimitFlag.Name)
+	}
+	if ctx.IsSet(RPCDeniedMethodsFlag.Name) {
+		cfg.RPCDeniedMethods = SplitAndTrim(ctx.String(RPCDeniedMethodsFlag.Name))
+	}
+	if ctx.IsSet(RPCEnginePriorityWeightFlag.Name) {
+		cfg.RPCEnginePriorityWeight = ctx.Int(RPCEnginePriorityWeightFlag.Name)
+	}
+	if ctx.IsSet(RPCCompressionThresholdFlag.Name) {
+		cfg.RPCCompressionThreshold = ctx.Int(RPCCompressionThresholdFlag.Name)
+	}
+}
+
+// setAdminRPC creates the admin HTTP RPC listener interface string from the set
+// command line flags, returning empty if the admin endpoint is disabled.
+func setAdminRPC(ctx *cli.Context, cfg *node.Config) {
+	if ctx.Bool(AdminRPCEnabledFlag.Name) && cfg.AdminHost == "" {
+		cfg.AdminHost = ctx.String(AdminRPCListenAddrFlag.Name)
+	}
+	if ctx.IsSet(AdminRPCPortFlag.Name) {
+		cfg.AdminPort = ctx.Int(AdminRPCPortFlag.Name)
+	}
+	if ctx.IsSet(AdminRPCVirtualHostsFlag.Name) {
+		cfg.AdminVirtualHosts = SplitAndTrim(ctx.String(AdminRPCVirtualHostsFlag.Name))
+	}
+}
+
+// setACME configures TLS certificates of the HTTP and WebSocket servers from the
+// command line flags.
+func setACME(ctx *cli.Context, cfg *node.Config) {
+	if ctx.IsSet(HTTPACMEDomainsFlag.Name) {
+		cfg.ACMEDomains = SplitAndTrim(ctx.String(HTTPACMEDomainsFlag.Name))
+	}
+	if ctx.IsSet(HTTPACMECacheDirFlag.Name) {
+		cfg.ACMECacheDir = ctx.String(HTTPACMECacheDirFlag.Name)
+	}
+	if ctx.IsSet(HTTPACMEEmailFlag.Name) {
+		cfg.ACMEEmail = ctx.String(HTTPACMEEmailFlag.Name)
+	}
 }
 
 // setGraphQL creates the GraphQL listener interface string from the set
@@ -1240,6 +1842,24 @@ func setWS(ctx *cli.Context, cfg *node.Config) {
 	if ctx.IsSet(WSPathPrefixFlag.Name) {
 		cfg.WSPathPrefix = ctx.String(WSPathPrefixFlag.Name)
 	}
+
+	if ctx.IsSet(WSSubscriptionBufferFlag.Name) {
+		cfg.WSSubscriptionBuffer = ctx.Int(WSSubscriptionBufferFlag.Name)
+	}
+
+	if ctx.IsSet(WSSubscriptionOverflowFlag.Name) {
+		cfg.WSSubscriptionOverflow = ctx.String(WSSubscriptionOverflowFlag.Name)
+	}
+
+	if ctx.IsSet(WSMaxConnectionsFlag.Name) {
+		cfg.WSMaxConnections = ctx.Int(WSMaxConnectionsFlag.Name)
+	}
+	if ctx.IsSet(WSMaxConnectionsPerIPFlag.Name) {
+		cfg.WSMaxConnectionsPerIP = ctx.Int(WSMaxConnectionsPerIPFlag.Name)
+	}
+	if ctx.IsSet(WSIdleTimeoutFlag.Name) {
+		cfg.WSIdleTimeout = ctx.Duration(WSIdleTimeoutFlag.Name)
+	}
 }
 
 // setIPC creates an IPC path configuration from the set command line flags,
@@ -1422,6 +2042,16 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	if ctx.IsSet(MaxPendingPeersFlag.Name) {
 		cfg.MaxPendingPeers = ctx.Int(MaxPendingPeersFlag.Name)
 	}
+	if ctx.IsSet(DialBackoffFlag.Name) {
+		cfg.DialPolicy.RedialBackoff = ctx.Float64(DialBackoffFlag.Name)
+		cfg.DialPolicy.MaxRedialDelay = ctx.Duration(DialMaxDelayFlag.Name)
+	}
+	if ctx.IsSet(DialMaxDelayFlag.Name) {
+		cfg.DialPolicy.MaxRedialDelay = ctx.Duration(DialMaxDelayFlag.Name)
+	}
+	if ctx.IsSet(DialSubnetLimitFlag.Name) {
+		cfg.DialPolicy.SubnetLimit = ctx.Uint(DialSubnetLimitFlag.Name)
+	}
 	if ctx.IsSet(NoDiscoverFlag.Name) || lightClient {
 		cfg.NoDiscovery = true
 	}
@@ -1459,10 +2089,13 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	SetP2PConfig(ctx, &cfg.P2P)
 	setIPC(ctx, cfg)
 	setHTTP(ctx, cfg)
+	setAdminRPC(ctx, cfg)
+	setACME(ctx, cfg)
 	setGraphQL(ctx, cfg)
 	setWS(ctx, cfg)
 	setNodeUserIdent(ctx, cfg)
 	SetDataDir(ctx, cfg)
+	setReadOnlyDataDir(ctx, cfg)
 	setSmartCard(ctx, cfg)
 
 	if ctx.IsSet(JWTSecretFlag.Name) {
@@ -1476,6 +2109,9 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.IsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.String(KeyStoreDirFlag.Name)
 	}
+	if ctx.IsSet(KeyStoreMountsFlag.Name) {
+		cfg.KeyStoreMounts = SplitAndTrim(ctx.String(KeyStoreMountsFlag.Name))
+	}
 	if ctx.IsSet(DeveloperFlag.Name) {
 		cfg.UseLightweightKDF = true
 	}
@@ -1493,6 +2129,23 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	}
 }
 
+// setReadOnlyDataDir shares the data directory read-only with the node owning it
+// if requested, disabling the networking and the IPC endpoint, which would clash
+// with the ones of the owner.
+func setReadOnlyDataDir(ctx *cli.Context, cfg *node.Config) {
+	if !ctx.Bool(DataDirReadOnlyFlag.Name) {
+		return
+	}
+	cfg.ReadOnlyDataDir = true
+	cfg.P2P.MaxPeers = 0
+	cfg.P2P.ListenAddr = ""
+	cfg.P2P.NoDiscovery = true
+	cfg.P2P.DiscoveryV5 = false
+	if !ctx.IsSet(IPCPathFlag.Name) {
+		cfg.IPCPath = ""
+	}
+}
+
 func setSmartCard(ctx *cli.Context, cfg *node.Config) {
 	// Skip enabling smartcards if no path is set
 	path := ctx.String(SmartCardDaemonPathFlag.Name)
@@ -1562,6 +2215,22 @@ func setGPO(ctx *cli.Context, cfg *gasprice.Config, light bool) {
 	}
 }
 
+// SetReloadableConfig applies the command line flags of the settings which can
+// be reloaded at runtime, so they keep precedence over a reloaded config file.
+func SetReloadableConfig(ctx *cli.Context, nodeCfg *node.Config, ethCfg *ethconfig.Config) {
+	if ctx.IsSet(RPCConcurrencyLimitFlag.Name) {
+		nodeCfg.RPCConcurrencyLimit = ctx.Int(RPCConcurrencyLimitFlag.Name)
+	}
+	if ctx.IsSet(RPCDeniedMethodsFlag.Name) {
+		nodeCfg.RPCDeniedMethods = SplitAndTrim(ctx.String(RPCDeniedMethodsFlag.Name))
+	}
+	if ctx.IsSet(RPCEnginePriorityWeightFlag.Name) {
+		nodeCfg.RPCEnginePriorityWeight = ctx.Int(RPCEnginePriorityWeightFlag.Name)
+	}
+	setGPO(ctx, &ethCfg.GPO, false)
+	setTxPool(ctx, &ethCfg.TxPool)
+}
+
 func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.IsSet(TxPoolLocalsFlag.Name) {
 		locals := strings.Split(ctx.String(TxPoolLocalsFlag.Name), ",")
@@ -1573,6 +2242,15 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 			}
 		}
 	}
+	if ctx.IsSet(TxPoolDenylistFlag.Name) {
+		for _, account := range strings.Split(ctx.String(TxPoolDenylistFlag.Name), ",") {
+			if trimmed := strings.TrimSpace(account); !common.IsHexAddress(trimmed) {
+				Fatalf("Invalid account in --txpool.denylist: %s", trimmed)
+			} else {
+				cfg.Denylist = append(cfg.Denylist, common.HexToAddress(trimmed))
+			}
+		}
+	}
 	if ctx.IsSet(TxPoolNoLocalsFlag.Name) {
 		cfg.NoLocals = ctx.Bool(TxPoolNoLocalsFlag.Name)
 	}
@@ -1582,12 +2260,33 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.IsSet(TxPoolRejournalFlag.Name) {
 		cfg.Rejournal = ctx.Duration(TxPoolRejournalFlag.Name)
 	}
+	if ctx.IsSet(TxPoolRemoteJournalFlag.Name) {
+		cfg.RemoteJournal = ctx.String(TxPoolRemoteJournalFlag.Name)
+	}
+	if ctx.IsSet(TxPoolRemoteJournalCapFlag.Name) {
+		cfg.RemoteJournalCap = ctx.Uint64(TxPoolRemoteJournalCapFlag.Name)
+	}
+	if ctx.IsSet(TxPoolQueuePolicyFlag.Name) {
+		cfg.QueuePolicy = ctx.String(TxPoolQueuePolicyFlag.Name)
+	}
+	if ctx.IsSet(TxPoolAuditLogFlag.Name) {
+		cfg.AuditLog = ctx.String(TxPoolAuditLogFlag.Name)
+	}
+	if ctx.IsSet(TxPoolBansFlag.Name) {
+		cfg.Bans = ctx.String(TxPoolBansFlag.Name)
+	}
 	if ctx.IsSet(TxPoolPriceLimitFlag.Name) {
 		cfg.PriceLimit = ctx.Uint64(TxPoolPriceLimitFlag.Name)
 	}
 	if ctx.IsSet(TxPoolPriceBumpFlag.Name) {
 		cfg.PriceBump = ctx.Uint64(TxPoolPriceBumpFlag.Name)
 	}
+	if ctx.IsSet(TxPoolPriceBumpMinFlag.Name) {
+		cfg.PriceBumpMin = ctx.Uint64(TxPoolPriceBumpMinFlag.Name)
+	}
+	if ctx.IsSet(TxPoolPriceBumpSameFeeFlag.Name) {
+		cfg.PriceBumpSameFee = ctx.Bool(TxPoolPriceBumpSameFeeFlag.Name)
+	}
 	if ctx.IsSet(TxPoolAccountSlotsFlag.Name) {
 		cfg.AccountSlots = ctx.Uint64(TxPoolAccountSlotsFlag.Name)
 	}
@@ -1603,6 +2302,18 @@ func setTxPool(ctx *cli.Context, cfg *core.TxPoolConfig) {
 	if ctx.IsSet(TxPoolLifetimeFlag.Name) {
 		cfg.Lifetime = ctx.Duration(TxPoolLifetimeFlag.Name)
 	}
+	if ctx.IsSet(TxPoolP2PQuotaFlag.Name) {
+		cfg.Quotas.P2P = ctx.Uint64(TxPoolP2PQuotaFlag.Name)
+	}
+	if ctx.IsSet(TxPoolRPCQuotaFlag.Name) {
+		cfg.Quotas.RPC = ctx.Uint64(TxPoolRPCQuotaFlag.Name)
+	}
+	if ctx.IsSet(TxPoolLocalQuotaFlag.Name) {
+		cfg.Quotas.Local = ctx.Uint64(TxPoolLocalQuotaFlag.Name)
+	}
+	if ctx.IsSet(TxPoolBlobSlotsFlag.Name) {
+		cfg.BlobSlots = ctx.Uint64(TxPoolBlobSlotsFlag.Name)
+	}
 }
 
 func setEthash(ctx *cli.Context, cfg *ethconfig.Config) {
@@ -1649,9 +2360,27 @@ func setMiner(ctx *cli.Context, cfg *miner.Config) {
 	if ctx.IsSet(MinerRecommitIntervalFlag.Name) {
 		cfg.Recommit = ctx.Duration(MinerRecommitIntervalFlag.Name)
 	}
+	if ctx.IsSet(MinerNewPayloadRecommitFlag.Name) {
+		cfg.NewPayloadRecommit = ctx.Duration(MinerNewPayloadRecommitFlag.Name)
+	}
+	if ctx.IsSet(MinerNewPayloadTimeoutFlag.Name) {
+		cfg.NewPayloadTimeout = ctx.Duration(MinerNewPayloadTimeoutFlag.Name)
+	}
+	if ctx.IsSet(MinerDecisionLogFlag.Name) {
+		cfg.DecisionLogBlocks = ctx.Uint64(MinerDecisionLogFlag.Name)
+	}
+	if ctx.IsSet(MinerTxOrderingFlag.Name) {
+		cfg.TxOrdering = ctx.String(MinerTxOrderingFlag.Name)
+		if _, err := miner.LookupTxOrderingPolicy(cfg.TxOrdering); err != nil {
+			Fatalf("Invalid miner transaction ordering: %v", err)
+		}
+	}
 	if ctx.IsSet(MinerNoVerifyFlag.Name) {
 		cfg.Noverify = ctx.Bool(MinerNoVerifyFlag.Name)
 	}
+	if ctx.IsSet(MinerNoPrefetchFlag.Name) {
+		cfg.NoTriePrefetch = ctx.Bool(MinerNoPrefetchFlag.Name)
+	}
 	if ctx.IsSet(LegacyMinerGasTargetFlag.Name) {
 		log.Warn("The generic --miner.gastarget flag is deprecated and will be removed in the future!")
 	}
@@ -1732,8 +2461,14 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	CheckExclusive(ctx, MainnetFlag, DeveloperFlag, RopstenFlag, RinkebyFlag, GoerliFlag, SepoliaFlag, KilnFlag)
 	CheckExclusive(ctx, LightServeFlag, SyncModeFlag, "light")
 	CheckExclusive(ctx, DeveloperFlag, ExternalSignerFlag) // Can't use both ephemeral unlocked and external signer
+	if ctx.IsSet(HistoryTransactionsFlag.Name) {
+		ctx.Set(TxLookupLimitFlag.Name, strconv.FormatUint(ctx.Uint64(HistoryTransactionsFlag.Name), 10))
+	}
 	if ctx.String(GCModeFlag.Name) == "archive" && ctx.Uint64(TxLookupLimitFlag.Name) != 0 {
 		ctx.Set(TxLookupLimitFlag.Name, "0")
+		if ctx.IsSet(HistoryTransactionsFlag.Name) {
+			ctx.Set(HistoryTransactionsFlag.Name, "0")
+		}
 		log.Warn("Disable transaction unindexing for archive node")
 	}
 	if ctx.IsSet(LightServeFlag.Name) && ctx.Uint64(TxLookupLimitFlag.Name) != 0 {
@@ -1746,6 +2481,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	setEtherbase(ctx, ks, cfg)
 	setGPO(ctx, &cfg.GPO, ctx.String(SyncModeFlag.Name) == "light")
 	setTxPool(ctx, &cfg.TxPool)
+	if ctx.IsSet(TxPoolBroadcastBudgetFlag.Name) {
+		cfg.TxBroadcastBudget = ctx.Uint64(TxPoolBroadcastBudgetFlag.Name)
+	}
 	setEthash(ctx, cfg)
 	setMiner(ctx, &cfg.Miner)
 	setRequiredBlocks(ctx, cfg)
@@ -1784,6 +2522,48 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(AncientFlag.Name) {
 		cfg.DatabaseFreezer = ctx.String(AncientFlag.Name)
 	}
+	if ctx.IsSet(ColdFlag.Name) {
+		cfg.DatabaseCold = ctx.String(ColdFlag.Name)
+	}
+	if ctx.IsSet(ColdAgeFlag.Name) {
+		cfg.DatabaseColdAge = ctx.Duration(ColdAgeFlag.Name)
+	}
+	if ctx.IsSet(AncientRemoteFlag.Name) {
+		cfg.DatabaseFreezerRemote = ctx.String(AncientRemoteFlag.Name)
+	}
+	if ctx.IsSet(AncientRemoteRegionFlag.Name) {
+		cfg.DatabaseFreezerRemoteRegion = ctx.String(AncientRemoteRegionFlag.Name)
+	}
+	if ctx.IsSet(AncientRemoteCacheFlag.Name) {
+		cfg.DatabaseFreezerRemoteCache = ctx.Int(AncientRemoteCacheFlag.Name)
+	}
+	if ctx.IsSet(AncientRemoteUploadFlag.Name) {
+		cfg.DatabaseFreezerRemoteUpload = ctx.Bool(AncientRemoteUploadFlag.Name)
+	}
+	if ctx.IsSet(AncientRemoteAnonFlag.Name) {
+		cfg.DatabaseFreezerRemoteAnon = ctx.Bool(AncientRemoteAnonFlag.Name)
+	}
+	if ctx.IsSet(DBCompactionRateFlag.Name) {
+		cfg.DatabaseCompactionRate = ctx.Int(DBCompactionRateFlag.Name)
+	}
+	if ctx.IsSet(DBCompactionWindowFlag.Name) {
+		cfg.DatabaseCompactionWindow = ctx.String(DBCompactionWindowFlag.Name)
+	}
+	if ctx.IsSet(DataDirReadOnlyFlag.Name) {
+		cfg.DatabaseReadOnly = ctx.Bool(DataDirReadOnlyFlag.Name)
+	}
+	if ctx.IsSet(DataDirRefreshFlag.Name) {
+		cfg.DatabaseRefresh = ctx.Duration(DataDirRefreshFlag.Name)
+	}
+	if cfg.DatabaseReadOnly && (cfg.DatabaseCold != "" || cfg.DatabaseFreezerRemote != "") {
+		Fatalf("--%s can't be used with --%s or --%s", DataDirReadOnlyFlag.Name, ColdFlag.Name, AncientRemoteFlag.Name)
+	}
+	if cfg.DatabaseReadOnly && cfg.DatabaseRefresh <= 0 {
+		Fatalf("--%s must be positive", DataDirRefreshFlag.Name)
+	}
+	if cfg.DatabaseCold != "" && cfg.DatabaseFreezerRemote != "" {
+		Fatalf("--%s and --%s can't be used together", ColdFlag.Name, AncientRemoteFlag.Name)
+	}
 
 	if gcmode := ctx.String(GCModeFlag.Name); gcmode != "full" && gcmode != "archive" {
 		Fatalf("--%s must be either 'full' or 'archive'", GCModeFlag.Name)
@@ -1791,9 +2571,51 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(GCModeFlag.Name) {
 		cfg.NoPruning = ctx.String(GCModeFlag.Name) == "archive"
 	}
+	if ctx.IsSet(StateRetentionFlag.Name) {
+		if ctx.IsSet(GCModeFlag.Name) && !cfg.NoPruning {
+			Fatalf("--%s requires --%s=archive", StateRetentionFlag.Name, GCModeFlag.Name)
+		}
+		if _, _, err := ParseStateRetention(ctx.String(StateRetentionFlag.Name)); err != nil {
+			Fatalf("Invalid --%s: %v", StateRetentionFlag.Name, err)
+		}
+		// The recent states are persisted like on archive nodes, and the ones
+		// leaving the retention window get pruned online
+		cfg.NoPruning = true
+	}
+	if ctx.IsSet(StateSchemeFlag.Name) {
+		switch scheme := ctx.String(StateSchemeFlag.Name); scheme {
+		case rawdb.HashScheme:
+		case rawdb.PathScheme:
+			// The path scheme keeps a single state, which snap sync can't build
+			if cfg.NoPruning {
+				Fatalf("--%s=%s is not supported with archive nodes", StateSchemeFlag.Name, scheme)
+			}
+			if ctx.IsSet(SyncModeFlag.Name) && cfg.SyncMode == downloader.SnapSync {
+				Fatalf("--%s=%s is not supported with snap sync, use --%s=full", StateSchemeFlag.Name, scheme, SyncModeFlag.Name)
+			}
+			if ctx.Bool(OnlinePruningFlag.Name) {
+				Fatalf("--%s=%s doesn't need online pruning (--%s)", StateSchemeFlag.Name, scheme, OnlinePruningFlag.Name)
+			}
+		default:
+			Fatalf("--%s must be either %q or %q", StateSchemeFlag.Name, rawdb.HashScheme, rawdb.PathScheme)
+		}
+		cfg.StateScheme = ctx.String(StateSchemeFlag.Name)
+	}
+	if ctx.IsSet(StateHistoryFlag.Name) {
+		cfg.StateHistory = ctx.Uint64(StateHistoryFlag.Name)
+	}
 	if ctx.IsSet(CacheNoPrefetchFlag.Name) {
 		cfg.NoPrefetch = ctx.Bool(CacheNoPrefetchFlag.Name)
 	}
+	if ctx.IsSet(TriePrefetchWorkersFlag.Name) {
+		cfg.TriePrefetchWorkers = ctx.Int(TriePrefetchWorkersFlag.Name)
+	}
+	if ctx.IsSet(TriePrefetchConcurrencyFlag.Name) {
+		cfg.TriePrefetchConcurrency = ctx.Int(TriePrefetchConcurrencyFlag.Name)
+	}
+	if ctx.IsSet(TriePrefetchNoImportFlag.Name) {
+		cfg.NoImportTriePrefetch = ctx.Bool(TriePrefetchNoImportFlag.Name)
+	}
 	// Read the value from the flag no matter if it's set or not.
 	cfg.Preimages = ctx.Bool(CachePreimagesFlag.Name)
 	if cfg.NoPruning && !cfg.Preimages {
@@ -1803,6 +2625,15 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(TxLookupLimitFlag.Name) {
 		cfg.TxLookupLimit = ctx.Uint64(TxLookupLimitFlag.Name)
 	}
+	if ctx.IsSet(HistoryTransactionsFlag.Name) {
+		cfg.LogLookupLimit = ctx.Uint64(HistoryTransactionsFlag.Name)
+	}
+	if ctx.IsSet(StateDiffIndexFlag.Name) {
+		cfg.StateDiffIndex = ctx.Bool(StateDiffIndexFlag.Name)
+	}
+	if ctx.IsSet(WitnessRecordFlag.Name) {
+		cfg.RecordWitnesses = ctx.Bool(WitnessRecordFlag.Name)
+	}
 	if ctx.IsSet(CacheFlag.Name) || ctx.IsSet(CacheTrieFlag.Name) {
 		cfg.TrieCleanCache = ctx.Int(CacheFlag.Name) * ctx.Int(CacheTrieFlag.Name) / 100
 	}
@@ -1812,6 +2643,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(CacheTrieRejournalFlag.Name) {
 		cfg.TrieCleanCacheRejournal = ctx.Duration(CacheTrieRejournalFlag.Name)
 	}
+	if ctx.IsSet(CacheWarmupFlag.Name) {
+		cfg.CacheWarmupContracts = ctx.Int(CacheWarmupFlag.Name)
+	}
 	if ctx.IsSet(CacheFlag.Name) || ctx.IsSet(CacheGCFlag.Name) {
 		cfg.TrieDirtyCache = ctx.Int(CacheFlag.Name) * ctx.Int(CacheGCFlag.Name) / 100
 	}
@@ -1846,9 +2680,102 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *ethconfig.Config) {
 	if ctx.IsSet(RPCGlobalEVMTimeoutFlag.Name) {
 		cfg.RPCEVMTimeout = ctx.Duration(RPCGlobalEVMTimeoutFlag.Name)
 	}
+	if ctx.IsSet(RPCSubscriptionFanoutFlag.Name) {
+		cfg.RPCSubscriptionFanout = ctx.Bool(RPCSubscriptionFanoutFlag.Name)
+	}
+	if ctx.IsSet(RPCFilterCheckpointsFlag.Name) {
+		cfg.RPCFilterCheckpoints = ctx.Bool(RPCFilterCheckpointsFlag.Name)
+	}
+	if ctx.IsSet(RPCFilterTimeoutFlag.Name) {
+		cfg.RPCFilterTimeout = ctx.Duration(RPCFilterTimeoutFlag.Name)
+	}
+	if ctx.IsSet(RPCFilterClientLimitFlag.Name) {
+		cfg.RPCFilterClientLimit = ctx.Int(RPCFilterClientLimitFlag.Name)
+	}
+	if ctx.IsSet(RPCFilterBacklogFlag.Name) {
+		cfg.RPCFilterBacklog = ctx.Int(RPCFilterBacklogFlag.Name)
+	}
+	if ctx.IsSet(RPCTraceConcurrencyFlag.Name) {
+		cfg.RPCTraceConcurrency = ctx.Int(RPCTraceConcurrencyFlag.Name)
+	}
+	if ctx.IsSet(RPCTraceClientConcurrencyFlag.Name) {
+		cfg.RPCTraceClientConcurrency = ctx.Int(RPCTraceClientConcurrencyFlag.Name)
+	}
+	if ctx.IsSet(RPCTraceGasPerMinuteFlag.Name) {
+		cfg.RPCTraceGasPerMinute = ctx.Uint64(RPCTraceGasPerMinuteFlag.Name)
+	}
+	if ctx.IsSet(RPCTraceClientGasPerMinuteFlag.Name) {
+		cfg.RPCTraceClientGasPerMinute = ctx.Uint64(RPCTraceClientGasPerMinuteFlag.Name)
+	}
+	if ctx.IsSet(RPCTraceQueueTimeoutFlag.Name) {
+		cfg.RPCTraceQueueTimeout = ctx.Duration(RPCTraceQueueTimeoutFlag.Name)
+	}
+	if ctx.IsSet(RPCTraceBlockConcurrencyFlag.Name) {
+		cfg.RPCTraceBlockConcurrency = ctx.Int(RPCTraceBlockConcurrencyFlag.Name)
+	}
+	if ctx.IsSet(RPCTraceIndexFlag.Name) {
+		cfg.RPCTraceIndex = ctx.Bool(RPCTraceIndexFlag.Name)
+	}
+	if ctx.IsSet(RPCTraceJobsFlag.Name) {
+		cfg.RPCTraceJobs = ctx.Bool(RPCTraceJobsFlag.Name)
+	}
+	if ctx.IsSet(RPCAccountRangeRateFlag.Name) {
+		cfg.RPCAccountRangeRate = ctx.Int(RPCAccountRangeRateFlag.Name)
+	}
+	if ctx.IsSet(RPCComparePeersFlag.Name) {
+		cfg.RPCComparePeers = SplitAndTrim(ctx.String(RPCComparePeersFlag.Name))
+		for _, endpoint := range cfg.RPCComparePeers {
+			if err := eth.CheckComparePeer(endpoint); err != nil {
+				Fatalf("Option %q: %v", RPCComparePeersFlag.Name, err)
+			}
+		}
+	}
 	if ctx.IsSet(RPCGlobalTxFeeCapFlag.Name) {
 		cfg.RPCTxFeeCap = ctx.Float64(RPCGlobalTxFeeCapFlag.Name)
 	}
+	if ctx.IsSet(RollupPromiseKeyFlag.Name) {
+		key, err := crypto.LoadECDSA(ctx.String(RollupPromiseKeyFlag.Name))
+		if err != nil {
+			Fatalf("Option %q: %v", RollupPromiseKeyFlag.Name, err)
+		}
+		cfg.InclusionPromiseKey = key
+	}
+	if ctx.IsSet(RollupPromiseWindowFlag.Name) {
+		cfg.InclusionPromiseWindow = ctx.Uint64(RollupPromiseWindowFlag.Name)
+	}
+	if ctx.IsSet(RollupMaxReorgDepthFlag.Name) {
+		cfg.MaxReorgDepth = ctx.Uint64(RollupMaxReorgDepthFlag.Name)
+	}
+	if ctx.IsSet(RollupHistoricalRPCFlag.Name) {
+		cfg.HistoricalRPC = ctx.String(RollupHistoricalRPCFlag.Name)
+	}
+	if ctx.IsSet(RollupHistoricalRPCTimeoutFlag.Name) {
+		cfg.HistoricalRPCTimeout = ctx.Duration(RollupHistoricalRPCTimeoutFlag.Name)
+	}
+	if ctx.IsSet(RollupHistoricalBlockFlag.Name) {
+		cfg.HistoricalBlock = ctx.Uint64(RollupHistoricalBlockFlag.Name)
+	}
+	if ctx.IsSet(RollupVerifyDepositsFlag.Name) {
+		cfg.VerifyDeposits = ctx.Bool(RollupVerifyDepositsFlag.Name)
+	}
+	if ctx.IsSet(AuthRecordFlag.Name) {
+		cfg.EngineRecord = ctx.String(AuthRecordFlag.Name)
+	}
+	if ctx.IsSet(AuthSlowCallFlag.Name) {
+		cfg.EngineSlowCall = ctx.Duration(AuthSlowCallFlag.Name)
+	}
+	if ctx.IsSet(RollupMaxTxSizeFlag.Name) {
+		cfg.TxPool.Limits.MaxTxSize = ctx.Uint64(RollupMaxTxSizeFlag.Name)
+		cfg.Miner.TxLimits.MaxTxSize = cfg.TxPool.Limits.MaxTxSize
+	}
+	if ctx.IsSet(RollupMaxCalldataFlag.Name) {
+		cfg.TxPool.Limits.MaxCalldata = ctx.Uint64(RollupMaxCalldataFlag.Name)
+		cfg.Miner.TxLimits.MaxCalldata = cfg.TxPool.Limits.MaxCalldata
+	}
+	if ctx.IsSet(RollupMaxInitCodeFlag.Name) {
+		cfg.TxPool.Limits.MaxInitCode = ctx.Uint64(RollupMaxInitCodeFlag.Name)
+		cfg.Miner.TxLimits.MaxInitCode = cfg.TxPool.Limits.MaxInitCode
+	}
 	if ctx.IsSet(NoDiscoverFlag.Name) {
 		cfg.EthDiscoveryURLs, cfg.SnapDiscoveryURLs = []string{}, []string{}
 	} else if ctx.IsSet(DNSDiscoveryFlag.Name) {
@@ -1992,7 +2919,8 @@ func RegisterEthService(stack *node.Node, cfg *ethconfig.Config) (ethapi.Backend
 		if err != nil {
 			Fatalf("Failed to register the Ethereum service: %v", err)
 		}
-		stack.RegisterAPIs(tracers.APIs(backend.ApiBackend))
+		stack.RegisterAPIs(tracers.APIs(backend.ApiBackend, traceQuota(cfg), nil))
+		registerTraceJobs(stack, backend.ApiBackend, cfg)
 		if backend.BlockChain().Config().TerminalTotalDifficulty != nil {
 			if err := lescatalyst.Register(stack, backend); err != nil {
 				Fatalf("Failed to register the catalyst service: %v", err)
@@ -2015,18 +2943,185 @@ func RegisterEthService(stack *node.Node, cfg *ethconfig.Config) (ethapi.Backend
 			Fatalf("Failed to register the catalyst service: %v", err)
 		}
 	}
-	stack.RegisterAPIs(tracers.APIs(backend.APIBackend))
+	var index *tracers.TraceIndex
+	if cfg.RPCTraceIndex {
+		index = registerTraceIndexer(stack, backend.APIBackend)
+	}
+	stack.RegisterAPIs(tracers.APIs(backend.APIBackend, traceQuota(cfg), index))
+	registerTraceJobs(stack, backend.APIBackend, cfg)
 	return backend.APIBackend, backend
 }
 
+// traceQuota returns the limits on the tracing work of RPC clients.
+func traceQuota(cfg *ethconfig.Config) tracers.QuotaConfig {
+	return tracers.QuotaConfig{
+		Concurrency:        cfg.RPCTraceConcurrency,
+		ClientConcurrency:  cfg.RPCTraceClientConcurrency,
+		GasPerMinute:       cfg.RPCTraceGasPerMinute,
+		ClientGasPerMinute: cfg.RPCTraceClientGasPerMinute,
+		QueueTimeout:       cfg.RPCTraceQueueTimeout,
+		BlockConcurrency:   cfg.RPCTraceBlockConcurrency,
+	}
+}
+
+// LoadTracerPlugins registers the native tracers of the Go plugins given on the
+// command line.
+func LoadTracerPlugins(ctx *cli.Context) {
+	for _, path := range SplitAndTrim(ctx.String(TracerPluginsFlag.Name)) {
+		names, err := tracers.LoadPlugin(path)
+		if err != nil {
+			Fatalf("Failed to load tracer plugin %s: %v", path, err)
+		}
+		log.Info("Loaded tracer plugin", "path", path, "tracers", names)
+	}
+}
+
+// registerTraceJobs adds the background trace job runner to the stack if trace
+// jobs are enabled, scheduling the jobs of the configuration.
+func registerTraceJobs(stack *node.Node, backend tracers.Backend, cfg *ethconfig.Config) {
+	if !cfg.RPCTraceJobs && len(cfg.TraceJobs) == 0 {
+		return
+	}
+	jobs, err := tracers.NewJobManager(backend, stack.ResolvePath("tracejobs"))
+	if err != nil {
+		Fatalf("Failed to load trace jobs: %v", err)
+	}
+	specs := make([]tracers.JobSpec, len(cfg.TraceJobs))
+	for i, job := range cfg.TraceJobs {
+		specs[i] = tracers.JobSpec{
+			From:     hexutil.Uint64(job.From),
+			To:       hexutil.Uint64(job.To),
+			Output:   job.Output,
+			Throttle: job.Throttle,
+		}
+		if job.Tracer != "" {
+			tracer := job.Tracer
+			specs[i].Config = &tracers.TraceConfig{Tracer: &tracer}
+		}
+	}
+	if err := jobs.Schedule(specs); err != nil {
+		Fatalf("Failed to schedule trace jobs: %v", err)
+	}
+	stack.RegisterLifecycle(jobs)
+	stack.RegisterAPIs(jobs.APIs())
+}
+
+// registerTraceIndexer adds the indexer of the call traces of the chain to the
+// stack, returning the index it maintains.
+func registerTraceIndexer(stack *node.Node, backend tracers.TraceIndexBackend) *tracers.TraceIndex {
+	db, err := stack.OpenDatabase("traceindex", 16, 16, "eth/db/traceindex/", false)
+	if err != nil {
+		Fatalf("Failed to open the trace index: %v", err)
+	}
+	indexer, err := tracers.NewTraceIndexer(backend, db)
+	if err != nil {
+		Fatalf("Failed to load the trace index: %v", err)
+	}
+	stack.RegisterLifecycle(indexer)
+	return indexer.Index()
+}
+
 // RegisterEthStatsService configures the Ethereum Stats daemon and adds it to
 // the given node.
-func RegisterEthStatsService(stack *node.Node, backend ethapi.Backend, url string) {
-	if err := ethstats.New(stack, backend, backend.Engine(), url); err != nil {
+func RegisterEthStatsService(stack *node.Node, backend ethapi.Backend, url string, token string) {
+	if err := ethstats.New(stack, backend, backend.Engine(), url, token); err != nil {
 		Fatalf("Failed to register the Ethereum Stats service: %v", err)
 	}
 }
 
+// RegisterHealthService adds the health and readiness endpoints to the HTTP
+// server of the stack.
+func RegisterHealthService(ctx *cli.Context, stack *node.Node, backend *eth.Ethereum) {
+	eth.RegisterHealthHandlers(stack, backend, eth.HealthConfig{
+		MaxBlockAge:      ctx.Duration(HealthMaxBlockAgeFlag.Name),
+		MinPeers:         ctx.Int(HealthMinPeersFlag.Name),
+		MaxEngineSilence: ctx.Duration(HealthMaxEngineSilenceFlag.Name),
+	})
+}
+
+// RegisterTxTrackerService adds the tracker of the local transactions to the
+// stack.
+func RegisterTxTrackerService(ctx *cli.Context, stack *node.Node, backend *eth.Ethereum) {
+	config := txtracker.Config{
+		Resubmit:      ctx.Duration(TxTrackerResubmitFlag.Name),
+		FeeBump:       ctx.Uint64(TxTrackerFeeBumpFlag.Name),
+		MaxFeeBumps:   ctx.Uint64(TxTrackerMaxFeeBumpsFlag.Name),
+		Confirmations: ctx.Uint64(TxTrackerConfirmationsFlag.Name),
+	}
+	eth.RegisterTxTracker(stack, backend, config)
+}
+
+// RegisterStatePrunerService adds the online pruning of the stale state to the
+// stack.
+func RegisterStatePrunerService(ctx *cli.Context, stack *node.Node, backend *eth.Ethereum) {
+	config := pruner.OnlineConfig{
+		Interval:  ctx.Duration(OnlinePruningIntervalFlag.Name),
+		BloomSize: ctx.Uint64(BloomFilterSizeFlag.Name),
+	}
+	if ctx.IsSet(StateRetentionFlag.Name) {
+		blocks, period, err := ParseStateRetention(ctx.String(StateRetentionFlag.Name))
+		if err != nil {
+			Fatalf("Invalid --%s: %v", StateRetentionFlag.Name, err)
+		}
+		config.RetainBlocks, config.RetainPeriod = blocks, period
+	}
+	if err := eth.RegisterStatePruner(stack, backend, config); err != nil {
+		Fatalf("Failed to register the online state pruner: %v", err)
+	}
+}
+
+// ParseStateRetention parses a state retention window, given either as a number
+// of blocks, as a number of days suffixed with "d", or as a duration.
+func ParseStateRetention(value string) (uint64, time.Duration, error) {
+	if blocks, err := strconv.ParseUint(value, 10, 64); err == nil {
+		if blocks == 0 {
+			return 0, 0, errors.New("empty retention window")
+		}
+		return blocks, 0, nil
+	}
+	var (
+		period time.Duration
+		err    error
+	)
+	if days := strings.TrimSuffix(value, "d"); days != value {
+		var n uint64
+		if n, err = strconv.ParseUint(days, 10, 64); err == nil {
+			period = time.Duration(n) * 24 * time.Hour
+		}
+	} else {
+		period, err = time.ParseDuration(value)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid retention window %q", value)
+	}
+	if period <= 0 {
+		return 0, 0, errors.New("empty retention window")
+	}
+	return 0, period, nil
+}
+
+// RegisterDACheckService adds the service checking that local blocks are posted
+// to L1 in batches.
+func RegisterDACheckService(ctx *cli.Context, stack *node.Node, backend *eth.Ethereum) {
+	config := eth.DACheckConfig{
+		L1RPC:   ctx.String(RollupDACheckL1RPCFlag.Name),
+		L1Start: ctx.Uint64(RollupDACheckL1StartFlag.Name),
+	}
+	for _, flag := range []*cli.StringFlag{RollupDACheckInboxFlag, RollupDACheckBatcherFlag} {
+		if value := ctx.String(flag.Name); value != "" && !common.IsHexAddress(value) {
+			Fatalf("Option %q: invalid address %q", flag.Name, value)
+		}
+	}
+	if !ctx.IsSet(RollupDACheckInboxFlag.Name) {
+		Fatalf("Option %q is required to check posted batches", RollupDACheckInboxFlag.Name)
+	}
+	config.BatchInbox = common.HexToAddress(ctx.String(RollupDACheckInboxFlag.Name))
+	config.Batcher = common.HexToAddress(ctx.String(RollupDACheckBatcherFlag.Name))
+	if err := eth.RegisterDAChecker(stack, backend, config); err != nil {
+		Fatalf("Failed to register the DA checker: %v", err)
+	}
+}
+
 // RegisterGraphQLService is a utility function to construct a new service and register it against a node.
 func RegisterGraphQLService(stack *node.Node, backend ethapi.Backend, cfg node.Config) {
 	if err := graphql.New(stack, backend, cfg.GraphQLCors, cfg.GraphQLVirtualHosts); err != nil {
@@ -2125,6 +3220,19 @@ func MakeChainDatabase(ctx *cli.Context, stack *node.Node, readonly bool) ethdb.
 		chainDb, err = remotedb.New(ctx.String(RemoteDBFlag.Name))
 	case ctx.String(SyncModeFlag.Name) == "light":
 		chainDb, err = stack.OpenDatabase("lightchaindata", cache, handles, "", readonly)
+	case ctx.IsSet(ColdFlag.Name):
+		chainDb, err = stack.OpenTieredDatabaseWithFreezer("chaindata", cache, handles, ctx.String(AncientFlag.Name), ctx.String(ColdFlag.Name), ctx.Duration(ColdAgeFlag.Name), "", readonly)
+	case ctx.IsSet(AncientRemoteFlag.Name):
+		store, serr := objectstore.NewS3(ctx.String(AncientRemoteFlag.Name), ctx.String(AncientRemoteRegionFlag.Name), ctx.Bool(AncientRemoteAnonFlag.Name))
+		if serr != nil {
+			Fatalf("Could not open remote ancient store: %v", serr)
+		}
+		remote := rawdb.RemoteFreezerConfig{
+			Store:     store,
+			CacheSize: uint64(ctx.Int(AncientRemoteCacheFlag.Name)) * 1024 * 1024,
+			Upload:    ctx.Bool(AncientRemoteUploadFlag.Name),
+		}
+		chainDb, err = stack.OpenDatabaseWithRemoteFreezer("chaindata", cache, handles, ctx.String(AncientFlag.Name), remote, "", readonly)
 	default:
 		chainDb, err = stack.OpenDatabaseWithFreezer("chaindata", cache, handles, ctx.String(AncientFlag.Name), "", readonly)
 	}
@@ -2177,7 +3285,7 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 		TrieCleanLimit:      ethconfig.Defaults.TrieCleanCache,
 		TrieCleanNoPrefetch: ctx.Bool(CacheNoPrefetchFlag.Name),
 		TrieDirtyLimit:      ethconfig.Defaults.TrieDirtyCache,
-		TrieDirtyDisabled:   ctx.String(GCModeFlag.Name) == "archive",
+		TrieDirtyDisabled:   ctx.String(GCModeFlag.Name) == "archive" || ctx.IsSet(StateRetentionFlag.Name),
 		TrieTimeLimit:       ethconfig.Defaults.TrieTimeout,
 		SnapshotLimit:       ethconfig.Defaults.SnapshotCache,
 		Preimages:           ctx.Bool(CachePreimagesFlag.Name),
@@ -20,6 +20,7 @@ package utils
 import (
 	"reflect"
 	"testing"
+	"time"
 )
 
 func Test_SplitTagsFlag(t *testing.T) {
@@ -62,3 +63,37 @@ func Test_SplitTagsFlag(t *testing.T) {
 		})
 	}
 }
+
+func TestParseStateRetention(t *testing.T) {
+	tests := []struct {
+		value  string
+		blocks uint64
+		period time.Duration
+		fail   bool
+	}{
+		{value: "100000", blocks: 100000},
+		{value: "90d", period: 90 * 24 * time.Hour},
+		{value: "720h", period: 720 * time.Hour},
+		{value: "0", fail: true},
+		{value: "0d", fail: true},
+		{value: "-5h", fail: true},
+		{value: "d", fail: true},
+		{value: "ninety days", fail: true},
+	}
+	for _, tt := range tests {
+		blocks, period, err := ParseStateRetention(tt.value)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("%q: expected error", tt.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.value, err)
+			continue
+		}
+		if blocks != tt.blocks || period != tt.period {
+			t.Errorf("%q: have (%d, %v), want (%d, %v)", tt.value, blocks, period, tt.blocks, tt.period)
+		}
+	}
+}
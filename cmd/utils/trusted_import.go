@@ -0,0 +1,259 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// ImportCheckpoint is a block trusted by the operator. Blocks imported without
+// re-execution are accepted only up to the last checkpoint they lead to.
+type ImportCheckpoint struct {
+	Number    uint64      `json:"number"`
+	Hash      common.Hash `json:"hash"`
+	StateRoot common.Hash `json:"stateRoot"`
+}
+
+// receiptChainEntry is the RLP encoding of a block and its receipts in files
+// written by ExportChainReceipts.
+type receiptChainEntry struct {
+	Block    *types.Block
+	Receipts []*types.ReceiptForStorage
+}
+
+// ReadImportCheckpoints loads a JSON list of trusted checkpoints, sorted by
+// block number.
+func ReadImportCheckpoints(fn string) ([]ImportCheckpoint, error) {
+	blob, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+	var checkpoints []ImportCheckpoint
+	if err := json.Unmarshal(blob, &checkpoints); err != nil {
+		return nil, fmt.Errorf("invalid checkpoint file %s: %v", fn, err)
+	}
+	if len(checkpoints) == 0 {
+		return nil, fmt.Errorf("no checkpoints in %s", fn)
+	}
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].Number < checkpoints[j].Number })
+	return checkpoints, nil
+}
+
+// ExportChainReceipts exports the blocks first to last and their receipts into
+// the specified file, truncating any data already present in it. The file can
+// be imported with ImportChainTrusted.
+func ExportChainReceipts(blockchain *core.BlockChain, fn string, first uint64, last uint64) error {
+	log.Info("Exporting blockchain with receipts", "file", fn, "first", first, "last", last)
+
+	fh, err := os.OpenFile(fn, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var writer io.Writer = fh
+	if strings.HasSuffix(fn, ".gz") {
+		writer = gzip.NewWriter(writer)
+		defer writer.(*gzip.Writer).Close()
+	}
+	var parentHash common.Hash
+	for nr := first; nr <= last; nr++ {
+		block := blockchain.GetBlockByNumber(nr)
+		if block == nil {
+			return fmt.Errorf("export failed on #%d: not found", nr)
+		}
+		if nr > first && block.ParentHash() != parentHash {
+			return errors.New("export failed: chain reorg during export")
+		}
+		parentHash = block.Hash()
+
+		receipts := blockchain.GetReceiptsByHash(block.Hash())
+		if receipts == nil && len(block.Transactions()) > 0 {
+			return fmt.Errorf("export failed on #%d: receipts not found", nr)
+		}
+		entry := receiptChainEntry{Block: block, Receipts: make([]*types.ReceiptForStorage, len(receipts))}
+		for i, receipt := range receipts {
+			entry.Receipts[i] = (*types.ReceiptForStorage)(receipt)
+		}
+		if err := rlp.Encode(writer, &entry); err != nil {
+			return err
+		}
+	}
+	log.Info("Exported blockchain with receipts", "file", fn)
+	return nil
+}
+
+// ImportChainTrusted imports blocks and receipts exported by ExportChainReceipts
+// without executing them. Headers are verified by the consensus engine, bodies
+// and receipts against the roots in the headers, and the chain against the
+// trusted checkpoints. Only blocks up to the last checkpoint reached are kept,
+// the import is rolled back to it on any verification failure.
+//
+// The state of the imported blocks is not generated, it has to be synced from
+// the network afterwards.
+func ImportChainTrusted(chain *core.BlockChain, fn string, checkpoints []ImportCheckpoint) error {
+	log.Warn("################################################################")
+	log.Warn("# Trusted import: blocks are NOT re-executed. Only import files #")
+	log.Warn("# produced by nodes you operate, a wrong checkpoint or a        #")
+	log.Warn("# compromised file results in an invalid chain.                 #")
+	log.Warn("################################################################")
+
+	// Watch for Ctrl-C while the import is running.
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	fh, err := os.Open(fn)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+
+	var reader io.Reader = fh
+	if strings.HasSuffix(fn, ".gz") {
+		if reader, err = gzip.NewReader(reader); err != nil {
+			return err
+		}
+	}
+	var (
+		stream   = rlp.NewStream(reader, 0)
+		trusted  = make(map[uint64]ImportCheckpoint, len(checkpoints))
+		limit    = checkpoints[len(checkpoints)-1].Number
+		verified = chain.CurrentHeader().Number.Uint64() // highest block known to be valid
+		imported = verified
+		start    = time.Now()
+	)
+	for _, cp := range checkpoints {
+		trusted[cp.Number] = cp
+	}
+	// rollback drops all imported blocks above the last verified checkpoint.
+	rollback := func(cause error) error {
+		if imported > verified {
+			log.Warn("Rolling back unverified blocks", "from", imported, "to", verified)
+			if err := chain.SetHead(verified); err != nil {
+				return fmt.Errorf("%v (rollback failed: %v)", cause, err)
+			}
+		}
+		return cause
+	}
+	for done := false; !done; {
+		select {
+		case <-interrupt:
+			return rollback(errors.New("interrupted"))
+		default:
+		}
+		var (
+			blocks   types.Blocks
+			receipts []types.Receipts
+		)
+		for len(blocks) < importBatchSize {
+			var entry receiptChainEntry
+			if err := stream.Decode(&entry); err == io.EOF {
+				done = true
+				break
+			} else if err != nil {
+				return rollback(fmt.Errorf("at block %d: %v", imported+uint64(len(blocks))+1, err))
+			}
+			number := entry.Block.NumberU64()
+			if number > limit {
+				log.Warn("Stopping trusted import at the last checkpoint", "number", limit)
+				done = true
+				break
+			}
+			if number <= imported && chain.HasBlock(entry.Block.Hash(), number) {
+				continue
+			}
+			blockReceipts := make(types.Receipts, len(entry.Receipts))
+			for i, receipt := range entry.Receipts {
+				blockReceipts[i] = (*types.Receipt)(receipt)
+			}
+			if err := verifyTrustedBlock(chain, entry.Block, blockReceipts, trusted); err != nil {
+				return rollback(err)
+			}
+			blocks, receipts = append(blocks, entry.Block), append(receipts, blockReceipts)
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		headers := make([]*types.Header, len(blocks))
+		for i, block := range blocks {
+			headers[i] = block.Header()
+		}
+		if _, err := chain.InsertHeaderChain(headers, 1); err != nil {
+			return rollback(fmt.Errorf("invalid header: %v", err))
+		}
+		imported = blocks[len(blocks)-1].NumberU64()
+		if _, err := chain.InsertReceiptChain(blocks, receipts, 0); err != nil {
+			return rollback(fmt.Errorf("receipt chain import failed: %v", err))
+		}
+		for _, block := range blocks {
+			if _, ok := trusted[block.NumberU64()]; ok {
+				verified = block.NumberU64()
+			}
+		}
+		log.Info("Imported trusted blocks", "number", imported, "verified", verified, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	if err := rollback(nil); err != nil {
+		return err
+	}
+	log.Warn("Trusted import done, the state of the imported blocks must be synced from the network", "head", verified)
+	return nil
+}
+
+// verifyTrustedBlock checks a block body and its receipts against the roots in
+// the header, and the header against its checkpoint, if there is one.
+func verifyTrustedBlock(chain *core.BlockChain, block *types.Block, receipts types.Receipts, trusted map[uint64]ImportCheckpoint) error {
+	header := block.Header()
+	if hash := types.CalcUncleHash(block.Uncles()); hash != header.UncleHash {
+		return fmt.Errorf("block %d: uncle root hash mismatch: have %x, want %x", header.Number, hash, header.UncleHash)
+	}
+	if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != header.TxHash {
+		return fmt.Errorf("block %d: transaction root hash mismatch: have %x, want %x", header.Number, hash, header.TxHash)
+	}
+	if err := receipts.DeriveFields(chain.Config(), block.Hash(), block.NumberU64(), block.Transactions()); err != nil {
+		return fmt.Errorf("block %d: invalid receipts: %v", header.Number, err)
+	}
+	if hash := types.DeriveSha(receipts, trie.NewStackTrie(nil)); hash != header.ReceiptHash {
+		return fmt.Errorf("block %d: receipt root hash mismatch: have %x, want %x", header.Number, hash, header.ReceiptHash)
+	}
+	if cp, ok := trusted[block.NumberU64()]; ok {
+		if block.Hash() != cp.Hash {
+			return fmt.Errorf("block %d: checkpoint hash mismatch: have %x, want %x", header.Number, block.Hash(), cp.Hash)
+		}
+		if header.Root != cp.StateRoot {
+			return fmt.Errorf("block %d: checkpoint state root mismatch: have %x, want %x", header.Number, header.Root, cp.StateRoot)
+		}
+	}
+	return nil
+}
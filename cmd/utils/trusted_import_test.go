@@ -0,0 +1,100 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package utils
+
+import (
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func newTrustedImportChain(t *testing.T, genesis *core.Genesis) *core.BlockChain {
+	db := rawdb.NewMemoryDatabase()
+	genesis.MustCommit(db)
+	chain, err := core.NewBlockChain(db, nil, genesis.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chain
+}
+
+func TestImportChainTrusted(t *testing.T) {
+	var (
+		key, _  = crypto.GenerateKey()
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		signer  = types.LatestSigner(params.TestChainConfig)
+		genesis = &core.Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   core.GenesisAlloc{addr: {Balance: big.NewInt(params.Ether)}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		gendb     = rawdb.NewMemoryDatabase()
+		blocks, _ = core.GenerateChain(genesis.Config, genesis.MustCommit(gendb), ethash.NewFaker(), gendb, 10, func(i int, b *core.BlockGen) {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(addr), common.Address{0x01}, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, key)
+			b.AddTx(tx)
+		})
+	)
+	source := newTrustedImportChain(t, genesis)
+	defer source.Stop()
+	if _, err := source.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	fn := filepath.Join(t.TempDir(), "chain.rlp")
+	if err := ExportChainReceipts(source, fn, 1, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	// Blocks up to the last checkpoint are imported with their receipts.
+	chain := newTrustedImportChain(t, genesis)
+	defer chain.Stop()
+	checkpoints := []ImportCheckpoint{
+		{Number: 4, Hash: blocks[3].Hash(), StateRoot: blocks[3].Root()},
+		{Number: 8, Hash: blocks[7].Hash(), StateRoot: blocks[7].Root()},
+	}
+	if err := ImportChainTrusted(chain, fn, checkpoints); err != nil {
+		t.Fatal(err)
+	}
+	if head := chain.CurrentFastBlock(); head.Hash() != blocks[7].Hash() {
+		t.Fatalf("wrong head: have #%d, want #8", head.NumberU64())
+	}
+	if chain.CurrentHeader().Number.Uint64() != 8 {
+		t.Fatalf("wrong header head: have #%d, want #8", chain.CurrentHeader().Number)
+	}
+	if receipts := chain.GetReceiptsByHash(blocks[7].Hash()); len(receipts) != 1 || receipts[0].TxHash != blocks[7].Transactions()[0].Hash() {
+		t.Fatalf("wrong receipts of block #8: %v", receipts)
+	}
+
+	// A wrong checkpoint fails the import without keeping unverified blocks.
+	chain = newTrustedImportChain(t, genesis)
+	defer chain.Stop()
+	checkpoints[1].StateRoot = common.Hash{0x01}
+	if err := ImportChainTrusted(chain, fn, checkpoints); err == nil {
+		t.Fatal("import with a wrong checkpoint succeeded")
+	}
+	if head := chain.CurrentHeader().Number.Uint64(); head > 4 {
+		t.Fatalf("unverified blocks kept: header head #%d", head)
+	}
+}
@@ -30,13 +30,20 @@ import (
 // - gas limit check
 // - basefee check
 func VerifyEip1559Header(config *params.ChainConfig, parent, header *types.Header) error {
-	// Verify that the gas limit remains within allowed bounds
-	parentGasLimit := parent.GasLimit
-	if !config.IsLondon(parent.Number) {
-		parentGasLimit = parent.GasLimit * params.ElasticityMultiplier
-	}
-	if err := VerifyGaslimit(parentGasLimit, header.GasLimit); err != nil {
-		return err
+	// Verify that the gas limit remains within allowed bounds. Rollups set the
+	// gas limit through the system config on L1, which may change it at once.
+	if config.Optimism != nil {
+		if header.GasLimit < params.MinGasLimit {
+			return fmt.Errorf("invalid gas limit below %d", params.MinGasLimit)
+		}
+	} else {
+		parentGasLimit := parent.GasLimit
+		if !config.IsLondon(parent.Number) {
+			parentGasLimit = parent.GasLimit * params.ElasticityMultiplier
+		}
+		if err := VerifyGaslimit(parentGasLimit, header.GasLimit); err != nil {
+			return err
+		}
 	}
 	// Verify the header is not malformed
 	if header.BaseFee == nil {
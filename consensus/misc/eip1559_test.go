@@ -106,6 +106,43 @@ func TestBlockGasLimits(t *testing.T) {
 	}
 }
 
+// TestRollupBlockGasLimits tests that rollups may change the gas limit at once,
+// as long as it stays above the minimum.
+func TestRollupBlockGasLimits(t *testing.T) {
+	config := config()
+	config.Optimism = &params.OptimismConfig{}
+	initial := new(big.Int).SetUint64(params.InitialBaseFee)
+
+	for i, tc := range []struct {
+		gasLimit uint64
+		ok       bool
+	}{
+		{20000000, true},
+		{40000000, true},
+		{params.MinGasLimit, true},
+		{params.MinGasLimit - 1, false},
+	} {
+		parent := &types.Header{
+			GasUsed:  10000000,
+			GasLimit: 20000000,
+			BaseFee:  initial,
+			Number:   big.NewInt(5),
+		}
+		header := &types.Header{
+			GasLimit: tc.gasLimit,
+			BaseFee:  initial,
+			Number:   big.NewInt(6),
+		}
+		err := VerifyEip1559Header(config, parent, header)
+		if tc.ok && err != nil {
+			t.Errorf("test %d: Expected valid header: %s", i, err)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("test %d: Expected invalid header", i)
+		}
+	}
+}
+
 // TestCalcBaseFee assumes all blocks are 1559-blocks
 func TestCalcBaseFee(t *testing.T) {
 	tests := []struct {
@@ -0,0 +1,115 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package misc
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// VerifyEip4844Header verifies the header attributes which were added in EIP-4844,
+// - blob gas used check
+// - excess blob gas check
+func VerifyEip4844Header(config *params.ChainConfig, parent, header *types.Header) error {
+	if !config.IsBlobTx(header.Number) {
+		if header.BlobGasUsed != nil {
+			return fmt.Errorf("invalid blobGasUsed: have %d, expected nil", *header.BlobGasUsed)
+		}
+		if header.ExcessBlobGas != nil {
+			return fmt.Errorf("invalid excessBlobGas: have %d, expected nil", *header.ExcessBlobGas)
+		}
+		if header.WithdrawalsHash != nil {
+			return fmt.Errorf("invalid withdrawalsHash: have %x, expected nil", *header.WithdrawalsHash)
+		}
+		return nil
+	}
+	// Verify the header is not malformed
+	if header.WithdrawalsHash == nil {
+		return fmt.Errorf("header is missing withdrawalsHash")
+	}
+	if *header.WithdrawalsHash != types.EmptyRootHash {
+		return fmt.Errorf("invalid withdrawalsHash: have %x, expected %x", *header.WithdrawalsHash, types.EmptyRootHash)
+	}
+	if header.BlobGasUsed == nil {
+		return fmt.Errorf("header is missing blobGasUsed")
+	}
+	if header.ExcessBlobGas == nil {
+		return fmt.Errorf("header is missing excessBlobGas")
+	}
+	// Verify the blob gas used is within the limit and covers whole blobs
+	if max := config.BlobTx.MaxBlobGas(); *header.BlobGasUsed > max {
+		return fmt.Errorf("blob gas used %d exceeds maximum allowance %d", *header.BlobGasUsed, max)
+	}
+	if *header.BlobGasUsed%params.BlobTxBlobGasPerBlob != 0 {
+		return fmt.Errorf("blob gas used %d not a multiple of blob gas per blob %d", *header.BlobGasUsed, params.BlobTxBlobGasPerBlob)
+	}
+	// Verify the excess blob gas is correct based on the parent header
+	expectedExcessBlobGas := CalcExcessBlobGas(config, parent)
+	if *header.ExcessBlobGas != expectedExcessBlobGas {
+		return fmt.Errorf("invalid excessBlobGas: have %d, want %d, parentExcessBlobGas %v, parentBlobGasUsed %v",
+			*header.ExcessBlobGas, expectedExcessBlobGas, parent.ExcessBlobGas, parent.BlobGasUsed)
+	}
+	return nil
+}
+
+// CalcExcessBlobGas calculates the excess blob gas of the header after the given
+// parent. Parents before the blob transaction activation carry no excess blob gas.
+func CalcExcessBlobGas(config *params.ChainConfig, parent *types.Header) uint64 {
+	var parentExcessBlobGas, parentBlobGasUsed uint64
+	if parent.ExcessBlobGas != nil {
+		parentExcessBlobGas = *parent.ExcessBlobGas
+	}
+	if parent.BlobGasUsed != nil {
+		parentBlobGasUsed = *parent.BlobGasUsed
+	}
+	target := config.BlobTx.TargetBlobGas()
+	if parentExcessBlobGas+parentBlobGasUsed < target {
+		return 0
+	}
+	return parentExcessBlobGas + parentBlobGasUsed - target
+}
+
+// CalcBlobFee calculates the price of blob gas of a block from its excess blob
+// gas. The price starts at the protocol minimum, or at the minimum of the chain
+// config if higher, and grows exponentially with the excess blob gas.
+func CalcBlobFee(config *params.ChainConfig, excessBlobGas uint64) *big.Int {
+	minPrice := big.NewInt(params.BlobTxMinBlobGasprice)
+	if config.BlobTx != nil && config.BlobTx.MinBlobGasPrice != nil && config.BlobTx.MinBlobGasPrice.Cmp(minPrice) > 0 {
+		minPrice.Set(config.BlobTx.MinBlobGasPrice)
+	}
+	return fakeExponential(minPrice, new(big.Int).SetUint64(excessBlobGas), big.NewInt(params.BlobTxBlobGaspriceUpdateFraction))
+}
+
+// fakeExponential approximates factor * e ** (numerator / denominator) using
+// Taylor expansion.
+func fakeExponential(factor, numerator, denominator *big.Int) *big.Int {
+	var (
+		output = new(big.Int)
+		accum  = new(big.Int).Mul(factor, denominator)
+	)
+	for i := 1; accum.Sign() > 0; i++ {
+		output.Add(output, accum)
+
+		accum.Mul(accum, numerator)
+		accum.Div(accum, denominator)
+		accum.Div(accum, big.NewInt(int64(i)))
+	}
+	return output.Div(output, denominator)
+}
@@ -0,0 +1,180 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package misc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func blobConfig() *params.ChainConfig {
+	config := copyConfig(params.TestChainConfig)
+	config.BlobTx = &params.BlobTxConfig{Block: big.NewInt(5)}
+	return config
+}
+
+func TestCalcExcessBlobGas(t *testing.T) {
+	var (
+		config = blobConfig()
+		target = config.BlobTx.TargetBlobGas()
+		blob   = uint64(params.BlobTxBlobGasPerBlob)
+	)
+	tests := []struct {
+		excess uint64
+		used   uint64
+		want   uint64
+	}{
+		// The excess blob gas should not increase from zero if the used blob
+		// slots are below - or equal - to the target.
+		{0, 0, 0},
+		{0, target - blob, 0},
+		{0, target, 0},
+
+		// If the target blob gas is exceeded, the excess blob gas should increase
+		// by however much it was overshot
+		{0, target + blob, blob},
+		{blob, target + blob, 2 * blob},
+		{blob, target + 2*blob, 3 * blob},
+
+		// The excess blob gas should decrease by however much the target was
+		// under-shot, capped at zero.
+		{target, target, target},
+		{target, target - blob, target - blob},
+		{target, 0, 0},
+		{target - blob, 0, 0},
+	}
+	for i, tt := range tests {
+		parent := &types.Header{ExcessBlobGas: &tt.excess, BlobGasUsed: &tt.used}
+		if have := CalcExcessBlobGas(config, parent); have != tt.want {
+			t.Errorf("test %d: excess blob gas mismatch: have %d, want %d", i, have, tt.want)
+		}
+	}
+	// Parents before the activation carry no blob gas
+	if have := CalcExcessBlobGas(config, &types.Header{}); have != 0 {
+		t.Errorf("excess blob gas after legacy parent: have %d, want 0", have)
+	}
+}
+
+func TestCalcBlobFee(t *testing.T) {
+	tests := []struct {
+		excessBlobGas uint64
+		minPrice      int64
+		blobfee       int64
+	}{
+		{0, 0, 1},
+		{2314057, 0, 1},
+		{2314058, 0, 2},
+		{10 * 1024 * 1024, 0, 23},
+		{0, 10, 10},
+		{10 * 1024 * 1024, 10, 231},
+	}
+	for i, tt := range tests {
+		config := blobConfig()
+		if tt.minPrice != 0 {
+			config.BlobTx.MinBlobGasPrice = big.NewInt(tt.minPrice)
+		}
+		have := CalcBlobFee(config, tt.excessBlobGas)
+		if have.Int64() != tt.blobfee {
+			t.Errorf("test %d: blobfee mismatch: have %v want %v", i, have, tt.blobfee)
+		}
+	}
+}
+
+func TestFakeExponential(t *testing.T) {
+	tests := []struct {
+		factor      int64
+		numerator   int64
+		denominator int64
+		want        int64
+	}{
+		// When numerator == 0 the return value should always equal the value of factor
+		{1, 0, 1, 1},
+		{38493, 0, 1000, 38493},
+		{0, 1234, 2345, 0}, // should be 0
+		{1, 2, 1, 6},       // approximate 7.389
+		{1, 4, 2, 6},
+		{1, 3, 1, 16}, // approximate 20.09
+		{1, 6, 2, 18},
+		{1, 4, 1, 49}, // approximate 54.60
+		{1, 8, 2, 50},
+		{10, 8, 2, 542}, // approximate 540.598
+		{11, 8, 2, 596}, // approximate 600.58
+		{1, 5, 1, 136},  // approximate 148.4
+		{1, 5, 2, 11},   // approximate 12.18
+		{2, 5, 2, 23},   // approximate 24.36
+		{1, 50000000, 2225652, 5709098764},
+	}
+	for i, tt := range tests {
+		f, n, d := big.NewInt(tt.factor), big.NewInt(tt.numerator), big.NewInt(tt.denominator)
+		original := n.String()
+		have := fakeExponential(f, n, d)
+		if have.Int64() != tt.want {
+			t.Errorf("test %d: fake exponential mismatch: have %v want %v", i, have, tt.want)
+		}
+		if n.String() != original {
+			t.Errorf("test %d: numerator modified: have %v want %v", i, n, original)
+		}
+	}
+}
+
+// TestVerifyEip4844Header tests the blob gas checks of headers both across the
+// blob transaction activation and after it.
+func TestVerifyEip4844Header(t *testing.T) {
+	var (
+		config = blobConfig()
+		blob   = uint64(params.BlobTxBlobGasPerBlob)
+		target = config.BlobTx.TargetBlobGas()
+		max    = config.BlobTx.MaxBlobGas()
+	)
+	u64 := func(n uint64) *uint64 { return &n }
+	empty := &types.EmptyRootHash
+	tests := []struct {
+		parent *types.Header
+		header *types.Header
+		ok     bool
+	}{
+		// Before the activation the fields must be absent
+		{&types.Header{Number: big.NewInt(3)}, &types.Header{Number: big.NewInt(4)}, true},
+		{&types.Header{Number: big.NewInt(3)}, &types.Header{Number: big.NewInt(4), BlobGasUsed: u64(0), ExcessBlobGas: u64(0)}, false},
+		// At the activation the fields must be present and start from zero
+		{&types.Header{Number: big.NewInt(4)}, &types.Header{Number: big.NewInt(5)}, false},
+		{&types.Header{Number: big.NewInt(4)}, &types.Header{Number: big.NewInt(5), WithdrawalsHash: empty, BlobGasUsed: u64(blob), ExcessBlobGas: u64(0)}, true},
+		{&types.Header{Number: big.NewInt(4)}, &types.Header{Number: big.NewInt(5), WithdrawalsHash: empty, BlobGasUsed: u64(blob), ExcessBlobGas: u64(blob)}, false},
+		// The withdrawals hash must commit to an empty withdrawal list
+		{&types.Header{Number: big.NewInt(4)}, &types.Header{Number: big.NewInt(5), BlobGasUsed: u64(blob), ExcessBlobGas: u64(0)}, false},
+		{&types.Header{Number: big.NewInt(4)}, &types.Header{Number: big.NewInt(5), WithdrawalsHash: &common.Hash{}, BlobGasUsed: u64(blob), ExcessBlobGas: u64(0)}, false},
+		// After the activation the excess must follow the parent
+		{&types.Header{Number: big.NewInt(5), WithdrawalsHash: empty, BlobGasUsed: u64(max), ExcessBlobGas: u64(0)}, &types.Header{Number: big.NewInt(6), WithdrawalsHash: empty, BlobGasUsed: u64(0), ExcessBlobGas: u64(max - target)}, true},
+		{&types.Header{Number: big.NewInt(5), WithdrawalsHash: empty, BlobGasUsed: u64(max), ExcessBlobGas: u64(0)}, &types.Header{Number: big.NewInt(6), WithdrawalsHash: empty, BlobGasUsed: u64(0), ExcessBlobGas: u64(0)}, false},
+		// The blob gas used must cover whole blobs within the limit
+		{&types.Header{Number: big.NewInt(5), WithdrawalsHash: empty, BlobGasUsed: u64(0), ExcessBlobGas: u64(0)}, &types.Header{Number: big.NewInt(6), WithdrawalsHash: empty, BlobGasUsed: u64(blob - 1), ExcessBlobGas: u64(0)}, false},
+		{&types.Header{Number: big.NewInt(5), WithdrawalsHash: empty, BlobGasUsed: u64(0), ExcessBlobGas: u64(0)}, &types.Header{Number: big.NewInt(6), WithdrawalsHash: empty, BlobGasUsed: u64(max + blob), ExcessBlobGas: u64(0)}, false},
+	}
+	for i, tt := range tests {
+		err := VerifyEip4844Header(config, tt.parent, tt.header)
+		if tt.ok && err != nil {
+			t.Errorf("test %d: header rejected: %v", i, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("test %d: invalid header accepted", i)
+		}
+	}
+}
@@ -39,12 +39,20 @@ type PayloadAttributesV1 struct {
 	// NoTxPool is a field for rollups: if true, the no transactions are taken out of the tx-pool,
 	// only transactions from the above Transactions list will be included.
 	NoTxPool bool `json:"noTxPool,omitempty" gencodec:"optional"`
+	// GasLimit is a field for rollups: if set, it overrides the gas limit of the
+	// payload, as configured by the system config on L1.
+	GasLimit *uint64 `json:"gasLimit,omitempty" gencodec:"optional"`
+	// ExtraData is a field for rollups: if set, it overrides the extra-data of the
+	// payload.
+	ExtraData []byte `json:"extraData,omitempty" gencodec:"optional"`
 }
 
 // JSON type overrides for PayloadAttributesV1.
 type payloadAttributesMarshaling struct {
 	Timestamp    hexutil.Uint64
 	Transactions []hexutil.Bytes
+	GasLimit     *hexutil.Uint64
+	ExtraData    hexutil.Bytes
 }
 
 //go:generate go run github.com/fjl/gencodec -type ExecutableDataV1 -field-override executableDataMarshaling -out gen_ed.go
@@ -65,6 +73,8 @@ type ExecutableDataV1 struct {
 	BaseFeePerGas *big.Int       `json:"baseFeePerGas" gencodec:"required"`
 	BlockHash     common.Hash    `json:"blockHash"     gencodec:"required"`
 	Transactions  [][]byte       `json:"transactions"  gencodec:"required"`
+	BlobGasUsed   *uint64        `json:"blobGasUsed,omitempty"`
+	ExcessBlobGas *uint64        `json:"excessBlobGas,omitempty"`
 }
 
 // JSON type overrides for executableData.
@@ -77,6 +87,14 @@ type executableDataMarshaling struct {
 	ExtraData     hexutil.Bytes
 	LogsBloom     hexutil.Bytes
 	Transactions  []hexutil.Bytes
+	BlobGasUsed   *hexutil.Uint64
+	ExcessBlobGas *hexutil.Uint64
+}
+
+// ExecutionPayloadBodyV1 is the body of an execution payload, returned by
+// engine_getPayloadBodiesByHashV1 and engine_getPayloadBodiesByRangeV1.
+type ExecutionPayloadBodyV1 struct {
+	TransactionData []hexutil.Bytes `json:"transactions"`
 }
 
 type PayloadStatusV1 struct {
@@ -178,6 +196,14 @@ func ExecutableDataToBlock(params ExecutableDataV1) (*types.Block, error) {
 		BaseFee:     params.BaseFeePerGas,
 		Extra:       params.ExtraData,
 		MixDigest:   params.Random,
+
+		BlobGasUsed:   params.BlobGasUsed,
+		ExcessBlobGas: params.ExcessBlobGas,
+	}
+	if header.ExcessBlobGas != nil {
+		// The chain doesn't process withdrawals, the blob headers commit to an
+		// empty withdrawal list.
+		header.WithdrawalsHash = &types.EmptyRootHash
 	}
 	block := types.NewBlockWithHeader(header).WithBody(txs, nil /* uncles */)
 	if block.Hash() != params.BlockHash {
@@ -204,5 +230,7 @@ func BlockToExecutableData(block *types.Block) *ExecutableDataV1 {
 		Transactions:  encodeTransactions(block.Transactions()),
 		Random:        block.MixDigest(),
 		ExtraData:     block.Extra(),
+		BlobGasUsed:   block.BlobGasUsed(),
+		ExcessBlobGas: block.ExcessBlobGas(),
 	}
 }
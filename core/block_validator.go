@@ -65,6 +65,31 @@ func (v *BlockValidator) ValidateBody(block *types.Block) error {
 	if hash := types.DeriveSha(block.Transactions(), trie.NewStackTrie(nil)); hash != header.TxHash {
 		return fmt.Errorf("transaction root hash mismatch: have %x, want %x", hash, header.TxHash)
 	}
+	if v.config.IsBlobTx(header.Number) {
+		var blobGas uint64
+		for _, tx := range block.Transactions() {
+			blobGas += tx.BlobGas()
+		}
+		if max := v.config.BlobTx.MaxBlobGas(); blobGas > max {
+			return fmt.Errorf("%w: block blob gas %d, limit %d", ErrBlobGasLimit, blobGas, max)
+		}
+		if header.BlobGasUsed == nil || *header.BlobGasUsed != blobGas {
+			return fmt.Errorf("blob gas used mismatch: have %v, want %d", header.BlobGasUsed, blobGas)
+		}
+	}
+	// Enforce the size limits of the chain config, exempting the deposits which
+	// are forced in by the L1 and can't be refused
+	if v.config.IsTxLimits(header.Number) {
+		limits := ActiveTxLimits(v.config, header.Number, TxLimits{})
+		for i, tx := range block.Transactions() {
+			if tx.Type() == types.DepositTxType {
+				continue
+			}
+			if err := limits.Check(tx); err != nil {
+				return fmt.Errorf("%w: tx %d [%v]: %v", ErrBlockTxLimits, i, tx.Hash(), err)
+			}
+		}
+	}
 	if !v.bc.HasBlockAndState(block.ParentHash(), block.NumberU64()-1) {
 		if !v.bc.HasBlock(block.ParentHash(), block.NumberU64()-1) {
 			return consensus.ErrUnknownAncestor
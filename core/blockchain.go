@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/stateless"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -42,6 +43,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
 	lru "github.com/hashicorp/golang-lru"
 )
@@ -51,6 +53,7 @@ var (
 	headHeaderGauge         = metrics.NewRegisteredGauge("chain/head/header", nil)
 	headFastBlockGauge      = metrics.NewRegisteredGauge("chain/head/receipt", nil)
 	headFinalizedBlockGauge = metrics.NewRegisteredGauge("chain/head/finalized", nil)
+	headSafeBlockGauge      = metrics.NewRegisteredGauge("chain/head/safe", nil)
 
 	accountReadTimer   = metrics.NewRegisteredTimer("chain/account/reads", nil)
 	accountHashTimer   = metrics.NewRegisteredTimer("chain/account/hashes", nil)
@@ -90,6 +93,7 @@ const (
 	txLookupCacheLimit  = 1024
 	maxFutureBlocks     = 256
 	maxTimeFutureBlocks = 30
+	maxReloadBlocks     = 256 // Maximum number of new blocks announced by a head reload
 	TriesInMemory       = 128
 
 	// BlockChainVersion ensures that an incompatible database forces a resync from scratch.
@@ -130,6 +134,16 @@ type CacheConfig struct {
 	TrieTimeLimit       time.Duration // Time limit after which to flush the current in-memory trie to disk
 	SnapshotLimit       int           // Memory allowance (MB) to use for caching snapshot entries in memory
 	Preimages           bool          // Whether to store preimage of trie key to the disk
+	HeatMapJournal      string        // Disk journal of the hottest state, pre-loaded into the caches on startup
+	HeatMapContracts    int           // Number of hottest contracts to track in the heat map
+	StateDiffs          bool          // Whether to store the state changes of the imported blocks
+	Witnesses           bool          // Whether to store the execution witnesses of the imported blocks
+	LogLookupLimit      uint64        // Number of recent blocks to maintain the log index for (0 = entire chain)
+	StateScheme         string        // Scheme used to store the state, detected from the database if empty
+	StateHistory        uint64        // Number of recent states the path scheme can revert the persistent state to
+
+	TriePrefetch    state.PrefetcherConfig // Tuning of the trie prefetcher running during block import
+	TriePrefetchOff bool                   // Whether to disable the trie prefetcher during block import
 
 	SnapshotWait bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
 }
@@ -167,6 +181,12 @@ type BlockChain struct {
 	triegc *prque.Prque   // Priority queue mapping block numbers to tries to gc
 	gcproc time.Duration  // Accumulates canonical block processing for trie dumping
 
+	flushInterval  int64  // Block processing time after which a whole trie is flushed to disk (atomic)
+	flushLimit     int64  // Dirty trie cache size in bytes at which nodes are flushed to disk (atomic)
+	pendingProc    int64  // Mirror of gcproc for concurrent readers (atomic)
+	lastFlushTime  int64  // Unix time in nanoseconds of the last whole trie flush, 0 if none (atomic)
+	lastFlushBlock uint64 // Number of the block whose state was last flushed whole (atomic)
+
 	// txLookupLimit is the maximum number of blocks from head whose tx indices
 	// are reserved:
 	//  * 0:   means no limit and regenerate any missing indexes
@@ -179,6 +199,8 @@ type BlockChain struct {
 	chainFeed     event.Feed
 	chainSideFeed event.Feed
 	chainHeadFeed event.Feed
+	finalizedFeed event.Feed
+	safeFeed      event.Feed
 	logsFeed      event.Feed
 	blockProcFeed event.Feed
 	scope         event.SubscriptionScope
@@ -191,6 +213,10 @@ type BlockChain struct {
 	currentBlock          atomic.Value // Current head of the block chain
 	currentFastBlock      atomic.Value // Current head of the fast-sync chain (may be above the block chain!)
 	currentFinalizedBlock atomic.Value // Current finalized head
+	currentSafeBlock      atomic.Value // Current safe head, not persisted
+
+	maxReorgDepth  uint64 // Maximum number of blocks SetCanonical may rewind, 0 if unlimited (atomic)
+	reorgAllowance uint64 // Depth of the next deeper rewind permitted by the operator (atomic)
 
 	stateCache    state.Database // State database to reuse between imports (contains state cache)
 	bodyCache     *lru.Cache     // Cache for the most recent block bodies
@@ -199,6 +225,7 @@ type BlockChain struct {
 	blockCache    *lru.Cache     // Cache for the most recent entire blocks
 	txLookupCache *lru.Cache     // Cache for the most recent transaction lookup data.
 	futureBlocks  *lru.Cache     // future blocks are blocks added for later processing
+	heatMap       *heatMap       // Access counts of the hottest state, nil if not tracked
 
 	wg            sync.WaitGroup //
 	quit          chan struct{}  // shutdown signal, closed in Stop.
@@ -220,6 +247,23 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	if cacheConfig == nil {
 		cacheConfig = defaultCacheConfig
 	}
+	// Fail early if the chain relies on a fee currency this binary doesn't know.
+	if _, err := NewFeeCurrency(chainConfig); err != nil {
+		return nil, err
+	}
+	// The verkle state is converted using the preimages of the tries, and isn't
+	// snapshotted. The genesis can't be converted, it's committed as tries.
+	if chainConfig.Verkle != nil {
+		if block := chainConfig.Verkle.Block; block != nil && block.Sign() == 0 {
+			return nil, errors.New("verkle conversion can't happen at genesis")
+		}
+		if !cacheConfig.Preimages || cacheConfig.SnapshotLimit > 0 {
+			log.Warn("Enabling preimages and disabling snapshots for the verkle state")
+			config := *cacheConfig
+			config.Preimages, config.SnapshotLimit = true, 0
+			cacheConfig = &config
+		}
+	}
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	receiptsCache, _ := lru.New(receiptsCacheLimit)
@@ -232,10 +276,15 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		cacheConfig: cacheConfig,
 		db:          db,
 		triegc:      prque.New(nil),
+
+		flushInterval: int64(cacheConfig.TrieTimeLimit),
+		flushLimit:    int64(cacheConfig.TrieDirtyLimit) * 1024 * 1024,
 		stateCache: state.NewDatabaseWithConfig(db, &trie.Config{
 			Cache:     cacheConfig.TrieCleanLimit,
 			Journal:   cacheConfig.TrieCleanJournal,
 			Preimages: cacheConfig.Preimages,
+			Scheme:    cacheConfig.StateScheme,
+			History:   cacheConfig.StateHistory,
 		}),
 		quit:          make(chan struct{}),
 		chainmu:       syncx.NewClosableMutex(),
@@ -248,6 +297,13 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		engine:        engine,
 		vmConfig:      vmConfig,
 	}
+	// The path scheme keeps a single persistent state, archiving all is impossible
+	if cacheConfig.TrieDirtyDisabled && bc.stateCache.TrieDB().Scheme() == rawdb.PathScheme {
+		return nil, errors.New("archive mode is not supported by the path state scheme")
+	}
+	if chainConfig.Verkle != nil && bc.stateCache.TrieDB().Scheme() == rawdb.PathScheme {
+		return nil, errors.New("verkle state is not supported by the path state scheme")
+	}
 	bc.forker = NewForkChoice(bc, shouldPreserve)
 	bc.validator = NewBlockValidator(chainConfig, bc, engine)
 	bc.prefetcher = newStatePrefetcher(chainConfig, bc, engine)
@@ -267,6 +323,7 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	bc.currentBlock.Store(nilBlock)
 	bc.currentFastBlock.Store(nilBlock)
 	bc.currentFinalizedBlock.Store(nilBlock)
+	bc.currentSafeBlock.Store(nilBlock)
 
 	// Initialize the chain with ancient data if it isn't empty.
 	var txIndexBlock uint64
@@ -284,8 +341,15 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 		return nil, err
 	}
 
-	// Make sure the state associated with the block is available
+	// The genesis block of the path scheme is written without its state, commit
+	// it through the trie database of the chain
 	head := bc.CurrentBlock()
+	if triedb := bc.stateCache.TrieDB(); head.NumberU64() == 0 && triedb.Scheme() == rawdb.PathScheme && !bc.HasState(head.Root()) {
+		if err := CommitGenesisState(bc.db, bc.stateCache, head.Hash()); err != nil && !errors.Is(err, errGenesisNoAlloc) {
+			return nil, err
+		}
+	}
+	// Make sure the state associated with the block is available
 	if _, err := state.New(head.Root(), bc.stateCache, bc.snaps); err != nil {
 		// Head state is missing, before the state recovery, find out the
 		// disk layer point of snapshot(if it's enabled). Make sure the
@@ -378,7 +442,10 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			log.Warn("Enabling snapshot recovery", "chainhead", head.NumberU64(), "diskbase", *layer)
 			recover = true
 		}
-		bc.snaps, _ = snapshot.New(bc.db, bc.stateCache.TrieDB(), bc.cacheConfig.SnapshotLimit, head.Root(), !bc.cacheConfig.SnapshotWait, true, recover)
+		// Don't wait for generating the snapshot of a missing head state, e.g.
+		// a genesis awaiting snap sync, as it can't complete.
+		async := !bc.cacheConfig.SnapshotWait || !bc.HasState(head.Root())
+		bc.snaps, _ = snapshot.New(bc.db, bc.stateCache.TrieDB(), bc.cacheConfig.SnapshotLimit, head.Root(), async, true, recover)
 	}
 
 	// Start future block processor.
@@ -406,6 +473,20 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 			triedb.SaveCachePeriodically(bc.cacheConfig.TrieCleanJournal, bc.cacheConfig.TrieCleanRejournal, bc.quit)
 		}()
 	}
+	// If the hottest state from the previous run is known, pre-load it.
+	if bc.cacheConfig.HeatMapJournal != "" && bc.cacheConfig.HeatMapContracts > 0 {
+		heat, err := loadHeatMap(bc.cacheConfig.HeatMapJournal, bc.cacheConfig.HeatMapContracts)
+		if err != nil {
+			log.Warn("Failed to load state heat map", "err", err)
+		}
+		bc.heatMap = heat
+
+		bc.wg.Add(1)
+		go bc.warmCaches()
+	}
+	// The state of the head has been persisted on the last shutdown, or has
+	// just been repaired
+	atomic.StoreUint64(&bc.lastFlushBlock, bc.CurrentBlock().NumberU64())
 	return bc, nil
 }
 
@@ -493,6 +574,110 @@ func (bc *BlockChain) loadLastState() error {
 	return nil
 }
 
+// ReloadHead reloads the head markers from the database, moving the chain to the
+// head written by the node owning a shared database. A head whose state is not
+// committed to disk yet is skipped, keeping the current head until it is.
+func (bc *BlockChain) ReloadHead() error {
+	hash := rawdb.ReadHeadBlockHash(bc.db)
+	if hash == bc.CurrentBlock().Hash() {
+		return nil
+	}
+	head := bc.GetBlockByHash(hash)
+	if head == nil {
+		return fmt.Errorf("head block %x missing", hash)
+	}
+	if !bc.HasState(head.Root()) {
+		log.Debug("Skipping head without committed state", "number", head.Number(), "hash", hash)
+		return nil
+	}
+	if !bc.chainmu.TryLock() {
+		return errChainStopped
+	}
+	defer bc.chainmu.Unlock()
+
+	// The transactions may have moved in a reorg, the rest of the caches are
+	// keyed by hash and remain valid
+	bc.txLookupCache.Purge()
+
+	old := bc.CurrentBlock()
+	bc.currentBlock.Store(head)
+	headBlockGauge.Update(int64(head.NumberU64()))
+
+	header := head.Header()
+	if hash := rawdb.ReadHeadHeaderHash(bc.db); hash != (common.Hash{}) {
+		if h := bc.GetHeaderByHash(hash); h != nil {
+			header = h
+		}
+	}
+	bc.hc.SetCurrentHeader(header)
+
+	bc.currentFastBlock.Store(head)
+	if hash := rawdb.ReadHeadFastBlockHash(bc.db); hash != (common.Hash{}) {
+		if block := bc.GetBlockByHash(hash); block != nil {
+			bc.currentFastBlock.Store(block)
+		}
+	}
+	headFastBlockGauge.Update(int64(bc.CurrentFastBlock().NumberU64()))
+
+	if hash := rawdb.ReadFinalizedBlockHash(bc.db); hash != (common.Hash{}) {
+		if block := bc.GetBlockByHash(hash); block != nil {
+			bc.currentFinalizedBlock.Store(block)
+			headFinalizedBlockGauge.Update(int64(block.NumberU64()))
+		}
+	}
+	log.Debug("Reloaded chain head", "number", head.Number(), "hash", hash)
+	bc.sendReloadEvents(old, head)
+	bc.chainHeadFeed.Send(ChainHeadEvent{Block: head})
+	return nil
+}
+
+// sendReloadEvents announces the chain moving from the old head to the new one
+// on a database written by another process: the logs of the blocks leaving the
+// canonical chain are sent as removed, then the chain events and logs of the
+// blocks joining it. At most maxReloadBlocks new blocks are announced, if the
+// head moved further the older ones are skipped.
+func (bc *BlockChain) sendReloadEvents(oldHead, newHead *types.Block) {
+	var (
+		oldBlock, newBlock = oldHead, newHead
+		newChain           types.Blocks
+		deletedLogs        [][]*types.Log
+	)
+	for newBlock != nil && newBlock.NumberU64() > oldBlock.NumberU64() {
+		if len(newChain) == maxReloadBlocks {
+			break
+		}
+		newChain = append(newChain, newBlock)
+		newBlock = bc.GetBlock(newBlock.ParentHash(), newBlock.NumberU64()-1)
+	}
+	// Find the blocks of the old chain reorged out, unless the new chain was cut
+	// short before reaching back to them
+	if newBlock != nil && newBlock.NumberU64() <= oldBlock.NumberU64() {
+		for oldBlock != nil && newBlock != nil && oldBlock.Hash() != newBlock.Hash() {
+			if logs := bc.collectLogs(oldBlock.Hash(), true); len(logs) > 0 {
+				deletedLogs = append(deletedLogs, logs)
+			}
+			if oldBlock.NumberU64() == newBlock.NumberU64() {
+				if len(newChain) < maxReloadBlocks {
+					newChain = append(newChain, newBlock)
+				}
+				newBlock = bc.GetBlock(newBlock.ParentHash(), newBlock.NumberU64()-1)
+			}
+			oldBlock = bc.GetBlock(oldBlock.ParentHash(), oldBlock.NumberU64()-1)
+		}
+	}
+	if len(deletedLogs) > 0 {
+		bc.rmLogsFeed.Send(RemovedLogsEvent{mergeLogs(deletedLogs, true)})
+	}
+	for i := len(newChain) - 1; i >= 0; i-- {
+		block := newChain[i]
+		logs := bc.collectLogs(block.Hash(), false)
+		bc.chainFeed.Send(ChainEvent{Block: block, Hash: block.Hash(), Logs: logs})
+		if len(logs) > 0 {
+			bc.logsFeed.Send(logs)
+		}
+	}
+}
+
 // SetHead rewinds the local chain to a new head. Depending on whether the node
 // was fast synced or full synced and in which state, the method will try to
 // delete minimal data from disk whilst retaining chain consistency.
@@ -503,9 +688,122 @@ func (bc *BlockChain) SetHead(head uint64) error {
 
 // SetFinalized sets the finalized block.
 func (bc *BlockChain) SetFinalized(block *types.Block) {
+	prev := bc.CurrentFinalizedBlock()
 	bc.currentFinalizedBlock.Store(block)
 	rawdb.WriteFinalizedBlockHash(bc.db, block.Hash())
 	headFinalizedBlockGauge.Update(int64(block.NumberU64()))
+
+	if prev == nil || prev.Hash() != block.Hash() {
+		bc.finalizedFeed.Send(ChainFinalizedEvent{Block: block, Old: prev})
+	}
+}
+
+// SetSafe sets the safe block.
+func (bc *BlockChain) SetSafe(block *types.Block) {
+	prev := bc.CurrentSafeBlock()
+	bc.currentSafeBlock.Store(block)
+	headSafeBlockGauge.Update(int64(block.NumberU64()))
+
+	if prev == nil || prev.Hash() != block.Hash() {
+		bc.safeFeed.Send(ChainSafeEvent{Block: block, Old: prev})
+	}
+}
+
+// SetMaxReorgDepth limits the number of canonical blocks SetCanonical may
+// rewind, protecting against accidental rollbacks. Zero disables the limit.
+func (bc *BlockChain) SetMaxReorgDepth(depth uint64) {
+	atomic.StoreUint64(&bc.maxReorgDepth, depth)
+}
+
+// TrieFlushStatus is the usage of the dirty trie cache and the progress of its
+// flushing to disk.
+type TrieFlushStatus struct {
+	Dirty          common.StorageSize // Size of the dirty trie nodes in memory
+	Preimages      common.StorageSize // Size of the preimages in memory
+	DirtyLimit     common.StorageSize // Dirty size at which trie nodes are flushed
+	FlushInterval  time.Duration      // Block processing time after which a whole trie is flushed
+	LastFlush      time.Time          // Time of the last whole trie flush, zero if none yet
+	LastFlushBlock uint64             // Number of the block whose state was last flushed whole
+	ReplayBlocks   uint64             // Number of blocks to reprocess after a crash
+	ReplayTime     time.Duration      // Estimated processing time of the blocks to reprocess
+}
+
+// SetTrieFlushInterval configures after how much block processing time a whole
+// trie is flushed to disk, trading the reprocessing time after a crash against
+// the write amplification. It has no effect on archive nodes.
+func (bc *BlockChain) SetTrieFlushInterval(interval time.Duration) {
+	atomic.StoreInt64(&bc.flushInterval, int64(interval))
+}
+
+// SetTrieDirtyLimit configures the size of the dirty trie cache in megabytes at
+// which the oldest trie nodes are flushed to disk. It has no effect on archive
+// nodes.
+func (bc *BlockChain) SetTrieDirtyLimit(limit int) {
+	atomic.StoreInt64(&bc.flushLimit, int64(limit)*1024*1024)
+}
+
+// TrieFlushStatus returns the usage of the dirty trie cache and the progress of
+// its flushing to disk.
+func (bc *BlockChain) TrieFlushStatus() TrieFlushStatus {
+	dirty, preimages := bc.stateCache.TrieDB().Size()
+	status := TrieFlushStatus{
+		Dirty:          dirty,
+		Preimages:      preimages,
+		DirtyLimit:     common.StorageSize(atomic.LoadInt64(&bc.flushLimit)),
+		FlushInterval:  time.Duration(atomic.LoadInt64(&bc.flushInterval)),
+		LastFlushBlock: atomic.LoadUint64(&bc.lastFlushBlock),
+	}
+	if last := atomic.LoadInt64(&bc.lastFlushTime); last != 0 {
+		status.LastFlush = time.Unix(0, last)
+	}
+	if head := bc.CurrentBlock().NumberU64(); head > status.LastFlushBlock {
+		status.ReplayBlocks = head - status.LastFlushBlock
+	}
+	if !bc.cacheConfig.TrieDirtyDisabled {
+		status.ReplayTime = time.Duration(atomic.LoadInt64(&bc.pendingProc))
+	}
+	return status
+}
+
+// markFlushed records the flush of the state of the given block to disk.
+func (bc *BlockChain) markFlushed(number uint64) {
+	atomic.StoreUint64(&bc.lastFlushBlock, number)
+	atomic.StoreInt64(&bc.lastFlushTime, time.Now().UnixNano())
+}
+
+// AllowReorg permits the next rewind beyond the maximum reorg depth, as long as
+// it is no deeper than the given number of blocks.
+func (bc *BlockChain) AllowReorg(depth uint64) {
+	atomic.StoreUint64(&bc.reorgAllowance, depth)
+}
+
+// checkReorgDepth verifies that setting the given block as head doesn't rewind
+// more canonical blocks than permitted, consuming the operator allowance if
+// the rewind needs it.
+func (bc *BlockChain) checkReorgDepth(head *types.Block) error {
+	limit := atomic.LoadUint64(&bc.maxReorgDepth)
+	if limit == 0 {
+		return nil
+	}
+	current := bc.CurrentBlock()
+	if head.ParentHash() == current.Hash() {
+		return nil
+	}
+	ancestor := rawdb.FindCommonAncestor(bc.db, current.Header(), head.Header())
+	if ancestor == nil {
+		return fmt.Errorf("%w: no common ancestor with head %d [%x]", ErrReorgTooDeep, current.Number(), current.Hash())
+	}
+	depth := current.NumberU64() - ancestor.Number.Uint64()
+	if depth <= limit {
+		return nil
+	}
+	if allowance := atomic.LoadUint64(&bc.reorgAllowance); depth > allowance {
+		log.Error("Refusing deep reorg", "depth", depth, "limit", limit, "allowance", allowance, "head", current.Number(), "hash", current.Hash(), "new", head.Number(), "newhash", head.Hash())
+		return fmt.Errorf("%w: rewinding %d blocks, limit %d", ErrReorgTooDeep, depth, limit)
+	}
+	atomic.StoreUint64(&bc.reorgAllowance, 0)
+	log.Warn("Permitting deep reorg", "depth", depth, "limit", limit, "head", current.Number(), "hash", current.Hash(), "new", head.Number(), "newhash", head.Hash())
+	return nil
 }
 
 // setHeadBeyondRoot rewinds the local chain to a new head with the extra condition
@@ -550,6 +848,13 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, root common.Hash, repair bo
 					if root != (common.Hash{}) && !beyondRoot && newHeadBlock.Root() == root {
 						beyondRoot, rootNumber = true, newHeadBlock.NumberU64()
 					}
+					// The path scheme can revert the persistent state to the recent blocks
+					if triedb := bc.stateCache.TrieDB(); !bc.HasState(newHeadBlock.Root()) && triedb.Recoverable(newHeadBlock.Root()) {
+						if err := triedb.Recover(newHeadBlock.Root()); err != nil {
+							log.Crit("Failed to revert state", "number", newHeadBlock.NumberU64(), "root", newHeadBlock.Root(), "err", err)
+						}
+						log.Debug("Reverted state", "number", newHeadBlock.NumberU64(), "hash", newHeadBlock.Hash())
+					}
 					if _, err := state.New(newHeadBlock.Root(), bc.stateCache, bc.snaps); err != nil {
 						log.Trace("Block state missing, rewinding further", "number", newHeadBlock.NumberU64(), "hash", newHeadBlock.Hash())
 						if pivot == nil || newHeadBlock.NumberU64() > *pivot {
@@ -573,10 +878,20 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, root common.Hash, repair bo
 							// if the historical chain pruning is enabled. In that case the logic
 							// needs to be improved here.
 							if !bc.HasState(bc.genesisBlock.Root()) {
-								if err := CommitGenesisState(bc.db, bc.genesisBlock.Hash()); err != nil {
+								// The path scheme can't hold the genesis state along the current one
+								if triedb := bc.stateCache.TrieDB(); triedb.Scheme() == rawdb.PathScheme {
+									if err := triedb.Reset(); err != nil {
+										log.Crit("Failed to reset state", "err", err)
+									}
+								}
+								if err := CommitGenesisState(bc.db, bc.stateCache, bc.genesisBlock.Hash()); err == nil {
+									log.Debug("Recommitted genesis state to disk")
+								} else if errors.Is(err, errGenesisNoAlloc) {
+									// Genesis committed without state, it can only be snap synced
+									log.Warn("Genesis state missing, awaiting state sync", "root", bc.genesisBlock.Root())
+								} else {
 									log.Crit("Failed to commit genesis state", "err", err)
 								}
-								log.Debug("Recommitted genesis state to disk")
 							}
 						}
 						log.Debug("Rewound to block with state", "number", newHeadBlock.NumberU64(), "hash", newHeadBlock.Hash())
@@ -641,6 +956,10 @@ func (bc *BlockChain) setHeadBeyondRoot(head uint64, root common.Hash, repair bo
 			rawdb.DeleteBody(db, hash, num)
 			rawdb.DeleteReceipts(db, hash, num)
 		}
+		// The state diffs and witnesses are only kept in the active store
+		rawdb.DeleteStateDiff(db, hash, num)
+		rawdb.DeleteWitness(db, hash, num)
+
 		// Todo(rjl493456442) txlookup, bloombits, etc
 	}
 	// If SetHead was only called as a chain reparation method, try to skip
@@ -835,9 +1154,14 @@ func (bc *BlockChain) Stop() {
 	//  - HEAD:     So we don't need to reprocess any blocks in the general case
 	//  - HEAD-1:   So we don't do large reorgs if our HEAD becomes an uncle
 	//  - HEAD-127: So we have a hard limit on the number of blocks reexecuted
-	if !bc.cacheConfig.TrieDirtyDisabled {
-		triedb := bc.stateCache.TrieDB()
-
+	//
+	// The path scheme journals the states held in memory instead, restoring all
+	// of them on restart.
+	if triedb := bc.stateCache.TrieDB(); triedb.Scheme() == rawdb.PathScheme {
+		if err := triedb.Journal(bc.CurrentBlock().Root()); err != nil {
+			log.Error("Failed to journal state", "err", err)
+		}
+	} else if !bc.cacheConfig.TrieDirtyDisabled {
 		for _, offset := range []uint64{0, 1, TriesInMemory - 1} {
 			if number := bc.CurrentBlock().NumberU64(); number > offset {
 				recent := bc.GetBlockByNumber(number - offset)
@@ -867,6 +1191,11 @@ func (bc *BlockChain) Stop() {
 		triedb := bc.stateCache.TrieDB()
 		triedb.SaveCache(bc.cacheConfig.TrieCleanJournal)
 	}
+	if bc.heatMap != nil {
+		if err := bc.heatMap.save(bc.cacheConfig.HeatMapJournal); err != nil {
+			log.Error("Failed to save state heat map", "err", err)
+		}
+	}
 	log.Info("Blockchain stopped")
 }
 
@@ -1178,8 +1507,6 @@ func (bc *BlockChain) InsertReceiptChain(blockChain types.Blocks, receiptChain [
 	return 0, nil
 }
 
-var lastWrite uint64
-
 // writeBlockWithoutState writes only the block and its metadata to the database,
 // but does not write any state. This is used to construct competing side forks
 // up to the point where they exceed the canonical total difficulty.
@@ -1230,6 +1557,22 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	rawdb.WriteBlock(blockBatch, block)
 	rawdb.WriteReceipts(blockBatch, block.Hash(), block.NumberU64(), receipts)
 	rawdb.WritePreimages(blockBatch, state.Preimages())
+	for _, c := range state.ContractCreations() {
+		rawdb.WriteContractCreation(blockBatch, c.Address, block.NumberU64(), block.Hash(), c.TxHash, uint64(c.TxIndex), c.CodeHash)
+	}
+	for _, d := range state.ContractDestructions() {
+		rawdb.WriteContractDestruction(blockBatch, d.Address, block.NumberU64(), block.Hash(), d.TxHash, uint64(d.TxIndex), d.CodeHash)
+	}
+	if diff := state.StateDiff(); diff != nil {
+		rawdb.WriteStateDiff(blockBatch, block.Hash(), block.NumberU64(), diff)
+	}
+	if witness := state.Witness(); witness != nil {
+		blob, err := rlp.EncodeToBytes(witness)
+		if err != nil {
+			return err
+		}
+		rawdb.WriteWitness(blockBatch, block.Hash(), block.NumberU64(), blob)
+	}
 	if err := blockBatch.Write(); err != nil {
 		log.Crit("Failed to write block into disk", "err", err)
 	}
@@ -1240,9 +1583,17 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 	}
 	triedb := bc.stateCache.TrieDB()
 
+	// The path scheme maintains the states in memory and on disk by itself
+	if triedb.Scheme() == rawdb.PathScheme {
+		return nil
+	}
 	// If we're running an archive node, always flush
 	if bc.cacheConfig.TrieDirtyDisabled {
-		return triedb.Commit(root, false, nil)
+		if err := triedb.Commit(root, false, nil); err != nil {
+			return err
+		}
+		bc.markFlushed(block.NumberU64())
+		return nil
 	} else {
 		// Full but not archive node, do proper garbage collection
 		triedb.Reference(root, common.Hash{}) // metadata reference to keep trie alive
@@ -1252,7 +1603,7 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 			// If we exceeded our memory allowance, flush matured singleton nodes to disk
 			var (
 				nodes, imgs = triedb.Size()
-				limit       = common.StorageSize(bc.cacheConfig.TrieDirtyLimit) * 1024 * 1024
+				limit       = common.StorageSize(atomic.LoadInt64(&bc.flushLimit))
 			)
 			if nodes > limit || imgs > 4*1024*1024 {
 				triedb.Cap(limit - ethdb.IdealBatchSize)
@@ -1261,7 +1612,8 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 			chosen := current - TriesInMemory
 
 			// If we exceeded out time allowance, flush an entire trie to disk
-			if bc.gcproc > bc.cacheConfig.TrieTimeLimit {
+			flushInterval := time.Duration(atomic.LoadInt64(&bc.flushInterval))
+			if bc.gcproc > flushInterval {
 				// If the header is missing (canonical chain behind), we're reorging a low
 				// diff sidechain. Suspend committing until this operation is completed.
 				header := bc.GetHeaderByNumber(chosen)
@@ -1270,13 +1622,15 @@ func (bc *BlockChain) writeBlockWithState(block *types.Block, receipts []*types.
 				} else {
 					// If we're exceeding limits but haven't reached a large enough memory gap,
 					// warn the user that the system is becoming unstable.
-					if chosen < lastWrite+TriesInMemory && bc.gcproc >= 2*bc.cacheConfig.TrieTimeLimit {
-						log.Info("State in memory for too long, committing", "time", bc.gcproc, "allowance", bc.cacheConfig.TrieTimeLimit, "optimum", float64(chosen-lastWrite)/TriesInMemory)
+					lastWrite := atomic.LoadUint64(&bc.lastFlushBlock)
+					if chosen < lastWrite+TriesInMemory && bc.gcproc >= 2*flushInterval {
+						log.Info("State in memory for too long, committing", "time", bc.gcproc, "allowance", flushInterval, "optimum", float64(chosen-lastWrite)/TriesInMemory)
 					}
 					// Flush an entire trie and restart the counters
 					triedb.Commit(header.Root, true, nil)
-					lastWrite = chosen
+					bc.markFlushed(chosen)
 					bc.gcproc = 0
+					atomic.StoreInt64(&bc.pendingProc, 0)
 				}
 			}
 			// Garbage collect anything below our required write retention
@@ -1607,9 +1961,21 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 		if err != nil {
 			return it.index, err
 		}
+		if bc.cacheConfig.StateDiffs {
+			statedb.TrackStateDiff()
+		}
+		if bc.cacheConfig.Witnesses {
+			witness, err := stateless.NewWitness(block.Header(), bc)
+			if err != nil {
+				return it.index, err
+			}
+			statedb.StartWitness(witness)
+		}
 
 		// Enable prefetching to pull in trie node paths while processing transactions
-		statedb.StartPrefetcher("chain")
+		if !bc.cacheConfig.TriePrefetchOff {
+			statedb.StartPrefetcher("chain", &bc.cacheConfig.TriePrefetch)
+		}
 		activeState = statedb
 
 		// If we have a followup block, run that against the current state to pre-cache
@@ -1638,6 +2004,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 			atomic.StoreUint32(&followupInterrupt, 1)
 			return it.index, err
 		}
+		if bc.heatMap != nil {
+			bc.heatMap.record(statedb.AccessedContracts())
+		}
 
 		// Update the metrics touched during block processing
 		accountReadTimer.Update(statedb.AccountReads)                 // Account reads are complete, we can mark them
@@ -1708,6 +2077,7 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals, setHead bool)
 
 			// Only count canonical blocks for GC processing time
 			bc.gcproc += proctime
+			atomic.StoreInt64(&bc.pendingProc, int64(bc.gcproc))
 
 		case SideStatTy:
 			log.Debug("Inserted forked block", "number", block.Number(), "hash", block.Hash(),
@@ -2074,6 +2444,11 @@ func (bc *BlockChain) reorg(oldBlock, newBlock *types.Block) error {
 	for _, tx := range types.HashDifference(deletedTxs, addedTxs) {
 		rawdb.DeleteTxLookupEntry(indexesBatch, tx)
 	}
+	// Delete the state diffs and witnesses recorded for the dropped blocks
+	for _, block := range oldChain {
+		rawdb.DeleteStateDiff(indexesBatch, block.Hash(), block.NumberU64())
+		rawdb.DeleteWitness(indexesBatch, block.Hash(), block.NumberU64())
+	}
 
 	// Delete all hash markers that are not part of the new canonical chain.
 	// Because the reorg function does not handle new chain head, all hash
@@ -2143,6 +2518,10 @@ func (bc *BlockChain) SetCanonical(head *types.Block) (common.Hash, error) {
 	}
 	defer bc.chainmu.Unlock()
 
+	// Refuse rewinding more of the canonical chain than permitted.
+	if err := bc.checkReorgDepth(head); err != nil {
+		return common.Hash{}, err
+	}
 	// Re-execute the reorged chain in case the head state is missing.
 	if !bc.HasState(head.Root()) {
 		if latestValidHash, err := bc.recoverAncestors(head); err != nil {
@@ -2245,6 +2624,10 @@ func (bc *BlockChain) skipBlock(err error, it *insertIterator) bool {
 // The user can adjust the txlookuplimit value for each launch after fast
 // sync, Geth will automatically construct the missing indices and delete
 // the extra indices.
+//
+// If the log index is limited too, the bloom bits of the sections falling
+// out of the window are deleted along. Unlike transaction indices they are
+// not reconstructed if the limit is raised later.
 func (bc *BlockChain) maintainTxIndex(ancients uint64) {
 	defer bc.wg.Done()
 
@@ -2264,6 +2647,10 @@ func (bc *BlockChain) maintainTxIndex(ancients uint64) {
 	indexBlocks := func(tail *uint64, head uint64, done chan struct{}) {
 		defer func() { done <- struct{}{} }()
 
+		// Drop the bloom bits falling out of the log index window, if limited
+		if limit := bc.cacheConfig.LogLookupLimit; limit != 0 && head >= limit {
+			unindexBloomBits(bc.db, params.BloomBitsBlocks, head-limit+1, bc.quit)
+		}
 		// If the user just upgraded Geth to a new version which supports transaction
 		// index pruning, write the new tail and remove anything older.
 		if tail == nil {
@@ -24,9 +24,11 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/stateless"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 )
@@ -55,6 +57,12 @@ func (bc *BlockChain) CurrentFinalizedBlock() *types.Block {
 	return bc.currentFinalizedBlock.Load().(*types.Block)
 }
 
+// CurrentSafeBlock retrieves the current safe block of the canonical chain, as
+// last reported by the consensus client. It is nil until the first report.
+func (bc *BlockChain) CurrentSafeBlock() *types.Block {
+	return bc.currentSafeBlock.Load().(*types.Block)
+}
+
 // HasHeader checks if a block header is present in the database or not, caching
 // it if present.
 func (bc *BlockChain) HasHeader(hash common.Hash, number uint64) bool {
@@ -257,6 +265,69 @@ func (bc *BlockChain) GetTransactionLookup(hash common.Hash) *rawdb.LegacyTxLook
 	return lookup
 }
 
+// GetContractCreation retrieves the latest canonical deployment of the contract
+// at the given address. Only deployments executed by this node are recorded,
+// blocks imported by snap sync are not indexed.
+func (bc *BlockChain) GetContractCreation(address common.Address) *rawdb.ContractCreation {
+	creations := rawdb.ReadContractCreations(bc.db, address)
+	for i := len(creations) - 1; i >= 0; i-- {
+		if rawdb.ReadCanonicalHash(bc.db, creations[i].BlockNumber) == creations[i].BlockHash {
+			return &creations[i]
+		}
+	}
+	return nil
+}
+
+// GetContractHistory retrieves the canonical deployments and self-destructs of
+// the contract at the given address, ordered by block number. Like the creator
+// index, only blocks executed by this node are recorded.
+func (bc *BlockChain) GetContractHistory(address common.Address) ([]rawdb.ContractCreation, []rawdb.ContractDestruction) {
+	var (
+		creations    []rawdb.ContractCreation
+		destructions []rawdb.ContractDestruction
+	)
+	for _, c := range rawdb.ReadContractCreations(bc.db, address) {
+		if rawdb.ReadCanonicalHash(bc.db, c.BlockNumber) == c.BlockHash {
+			creations = append(creations, c)
+		}
+	}
+	for _, d := range rawdb.ReadContractDestructions(bc.db, address) {
+		if rawdb.ReadCanonicalHash(bc.db, d.BlockNumber) == d.BlockHash {
+			destructions = append(destructions, d)
+		}
+	}
+	return creations, destructions
+}
+
+// GetStateDiff retrieves the state changes of a block recorded on import, nil if
+// the block wasn't indexed.
+func (bc *BlockChain) GetStateDiff(hash common.Hash) []*types.AccountDiff {
+	number := bc.hc.GetBlockNumber(hash)
+	if number == nil {
+		return nil
+	}
+	return rawdb.ReadStateDiff(bc.db, hash, *number)
+}
+
+// GetWitness retrieves the execution witness of a block recorded on import, nil
+// if the block's witness wasn't recorded.
+func (bc *BlockChain) GetWitness(hash common.Hash) *stateless.Witness {
+	number := bc.hc.GetBlockNumber(hash)
+	if number == nil {
+		return nil
+	}
+	data := rawdb.ReadWitness(bc.db, hash, *number)
+	if len(data) == 0 {
+		return nil
+	}
+	witness := new(stateless.Witness)
+	if err := rlp.DecodeBytes(data, witness); err != nil {
+		log.Error("Invalid execution witness RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return witness
+}
+
 // GetTd retrieves a block's total difficulty in the canonical chain from the
 // database by hash and number, caching it if found.
 func (bc *BlockChain) GetTd(hash common.Hash, number uint64) *big.Int {
@@ -382,6 +453,16 @@ func (bc *BlockChain) SubscribeChainHeadEvent(ch chan<- ChainHeadEvent) event.Su
 	return bc.scope.Track(bc.chainHeadFeed.Subscribe(ch))
 }
 
+// SubscribeChainFinalizedEvent registers a subscription of ChainFinalizedEvent.
+func (bc *BlockChain) SubscribeChainFinalizedEvent(ch chan<- ChainFinalizedEvent) event.Subscription {
+	return bc.scope.Track(bc.finalizedFeed.Subscribe(ch))
+}
+
+// SubscribeChainSafeEvent registers a subscription of ChainSafeEvent.
+func (bc *BlockChain) SubscribeChainSafeEvent(ch chan<- ChainSafeEvent) event.Subscription {
+	return bc.scope.Track(bc.safeFeed.Subscribe(ch))
+}
+
 // SubscribeChainSideEvent registers a subscription of ChainSideEvent.
 func (bc *BlockChain) SubscribeChainSideEvent(ch chan<- ChainSideEvent) event.Subscription {
 	return bc.scope.Track(bc.chainSideFeed.Subscribe(ch))
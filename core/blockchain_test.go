@@ -22,6 +22,7 @@ import (
 	"math/big"
 	"math/rand"
 	"os"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -2928,6 +2929,445 @@ func TestDeleteCreateRevert(t *testing.T) {
 	}
 }
 
+// TestContractCreationIndex tests that contracts deployed by transactions and by
+// other contracts are indexed, while reverted deployments are not.
+func TestContractCreationIndex(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(100000000000000000)}},
+		}
+		// Init code deploying the single byte code 0x00.
+		childInit = []byte{
+			byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x00, byte(vm.MSTORE8),
+			byte(vm.PUSH1), 0x01, byte(vm.PUSH1), 0x00, byte(vm.RETURN),
+		}
+		// Init code creating a child with childInit, then deploying or reverting.
+		parentInit = func(end ...byte) []byte {
+			code := append([]byte{byte(vm.PUSH10)}, childInit...)
+			code = append(code,
+				byte(vm.PUSH1), 0x00, byte(vm.MSTORE),
+				byte(vm.PUSH1), byte(len(childInit)), byte(vm.PUSH1), byte(32-len(childInit)), byte(vm.PUSH1), 0x00, byte(vm.CREATE), byte(vm.POP),
+			)
+			return append(code, end...)
+		}
+		deploy = parentInit(childInit...)
+		revert = parentInit(byte(vm.PUSH1), 0x00, byte(vm.DUP1), byte(vm.REVERT))
+	)
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 2, func(i int, b *BlockGen) {
+		init := deploy
+		if i == 1 {
+			init = revert
+		}
+		tx, _ := types.SignTx(types.NewContractCreation(uint64(i), big.NewInt(0), 200000, b.header.BaseFee, init), types.HomesteadSigner{}, key)
+		b.AddTx(tx)
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+	chain, err := NewBlockChain(diskdb, nil, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	var (
+		parent         = crypto.CreateAddress(address, 0)
+		child          = crypto.CreateAddress(parent, 1)
+		revertedParent = crypto.CreateAddress(address, 1)
+		revertedChild  = crypto.CreateAddress(revertedParent, 1)
+	)
+	for _, addr := range []common.Address{parent, child} {
+		creation := chain.GetContractCreation(addr)
+		if creation == nil {
+			t.Fatalf("creation of %x not indexed", addr)
+		}
+		if creation.BlockHash != blocks[0].Hash() || creation.TxHash != blocks[0].Transactions()[0].Hash() || creation.TxIndex != 0 {
+			t.Errorf("wrong creation of %x: %+v", addr, creation)
+		}
+	}
+	for _, addr := range []common.Address{revertedParent, revertedChild, address} {
+		if creation := chain.GetContractCreation(addr); creation != nil {
+			t.Errorf("unexpected creation of %x: %+v", addr, creation)
+		}
+	}
+}
+
+func TestContractHistory(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		bb      = common.HexToAddress("0x000000000000000000000000000000000000bbbb")
+		aaCode  = []byte{byte(vm.PC), byte(vm.SELFDESTRUCT)}
+		// Init code deploying aaCode.
+		initCode = []byte{
+			byte(vm.PUSH2), byte(vm.PC), byte(vm.SELFDESTRUCT),
+			byte(vm.PUSH1), 0x0, byte(vm.MSTORE),
+			byte(vm.PUSH1), 0x2, byte(vm.PUSH1), byte(32 - 2), byte(vm.RETURN),
+		}
+		// The contract BB deploys AA with CREATE2, so it can be re-created.
+		bbCode = append(append([]byte{byte(vm.PUSH1) + byte(len(initCode)-1)}, initCode...),
+			byte(vm.PUSH1), 0x0, byte(vm.MSTORE),
+			byte(vm.PUSH1), 0x00, byte(vm.PUSH1), byte(len(initCode)), byte(vm.PUSH1), byte(32-len(initCode)), byte(vm.PUSH1), 0x00,
+			byte(vm.CREATE2),
+		)
+		initHash = crypto.Keccak256Hash(initCode)
+		aa       = crypto.CreateAddress2(bb, [32]byte{}, initHash[:])
+		gspec    = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address: {Balance: big.NewInt(100000000000000000)},
+				bb:      {Code: bbCode, Balance: big.NewInt(0)},
+			},
+		}
+	)
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+
+	// Block 1 creates and destructs AA, block 2 re-creates it, block 3 destructs it.
+	var nonce uint64
+	call := func(b *BlockGen, to common.Address) {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, to, big.NewInt(0), 100000, b.header.BaseFee, nil), types.HomesteadSigner{}, key)
+		b.AddTx(tx)
+		nonce++
+	}
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 3, func(i int, b *BlockGen) {
+		switch i {
+		case 0:
+			call(b, bb)
+			call(b, aa)
+		case 1:
+			call(b, bb)
+		case 2:
+			call(b, aa)
+		}
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+	chain, err := NewBlockChain(diskdb, nil, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	creations, destructions := chain.GetContractHistory(aa)
+	if len(creations) != 2 || len(destructions) != 2 {
+		t.Fatalf("wrong history: %d creations, %d destructions", len(creations), len(destructions))
+	}
+	codeHash := crypto.Keccak256Hash(aaCode)
+	for i, want := range []struct{ block, tx int }{{0, 0}, {1, 0}} {
+		if c := creations[i]; c.BlockHash != blocks[want.block].Hash() || c.TxIndex != uint64(want.tx) || c.CodeHash != codeHash {
+			t.Errorf("wrong creation %d: %+v", i, c)
+		}
+	}
+	for i, want := range []struct{ block, tx int }{{0, 1}, {2, 0}} {
+		if d := destructions[i]; d.BlockHash != blocks[want.block].Hash() || d.TxIndex != uint64(want.tx) || d.CodeHash != codeHash {
+			t.Errorf("wrong destruction %d: %+v", i, d)
+		}
+	}
+}
+
+// TestStateDiffIndex tests that the account and storage changes of the imported
+// blocks are indexed if enabled.
+func TestStateDiffIndex(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		cc      = common.HexToAddress("0x000000000000000000000000000000000000cccc")
+		dd      = common.HexToAddress("0x000000000000000000000000000000000000dddd")
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc: GenesisAlloc{
+				address: {Balance: big.NewInt(100000000000000000)},
+				// The contract CC sets slot 1 to 2 and slot 2 to 0.
+				cc: {
+					Code: []byte{
+						byte(vm.PUSH1), 0x02, byte(vm.PUSH1), 0x01, byte(vm.SSTORE),
+						byte(vm.PUSH1), 0x00, byte(vm.PUSH1), 0x02, byte(vm.SSTORE),
+					},
+					Storage: map[common.Hash]common.Hash{
+						common.HexToHash("01"): common.HexToHash("01"),
+						common.HexToHash("02"): common.HexToHash("01"),
+					},
+					Balance: big.NewInt(0),
+				},
+			},
+		}
+	)
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 1, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(0, cc, big.NewInt(0), 100000, b.header.BaseFee, nil), types.HomesteadSigner{}, key)
+		b.AddTx(tx)
+		tx, _ = types.SignTx(types.NewTransaction(1, dd, big.NewInt(1000), params.TxGas, b.header.BaseFee, nil), types.HomesteadSigner{}, key)
+		b.AddTx(tx)
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.StateDiffs = true
+	chain, err := NewBlockChain(diskdb, &cacheConfig, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	diff := chain.GetStateDiff(blocks[0].Hash())
+	if diff == nil {
+		t.Fatalf("state diff not indexed")
+	}
+	accounts := make(map[common.Address]*types.AccountDiff)
+	for _, account := range diff {
+		accounts[account.Address] = account
+	}
+	if sender := accounts[address]; sender == nil || sender.Prev.Nonce != 0 || sender.Post.Nonce != 2 || sender.Post.Balance.Cmp(sender.Prev.Balance) >= 0 {
+		t.Errorf("wrong sender diff: %+v", sender)
+	}
+	if recipient := accounts[dd]; recipient == nil || recipient.Prev != nil || recipient.Post.Balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("wrong recipient diff: %+v", recipient)
+	}
+	contract := accounts[cc]
+	if contract == nil {
+		t.Fatalf("contract diff missing")
+	}
+	want := []types.SlotDiff{
+		{Key: common.HexToHash("01"), Prev: common.HexToHash("01"), Post: common.HexToHash("02")},
+		{Key: common.HexToHash("02"), Prev: common.HexToHash("01"), Post: common.Hash{}},
+	}
+	if !reflect.DeepEqual(contract.Storage, want) {
+		t.Errorf("wrong storage diff: have %+v, want %+v", contract.Storage, want)
+	}
+	if contract.Prev.Root == contract.Post.Root {
+		t.Errorf("storage root unchanged")
+	}
+}
+
+// TestStateDiffDeletion tests that the state diffs and witnesses of the blocks
+// dropped by a reorg or a rewind are deleted along with them.
+func TestStateDiffDeletion(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		gspec   = &Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  GenesisAlloc{address: {Balance: big.NewInt(100000000000000000)}},
+		}
+		gendb    = rawdb.NewMemoryDatabase()
+		genesis  = gspec.MustCommit(gendb)
+		transfer = func(i int, b *BlockGen) {
+			tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), common.Address{0xdd}, big.NewInt(1000), params.TxGas, b.header.BaseFee, nil), types.HomesteadSigner{}, key)
+			b.AddTx(tx)
+		}
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, gendb, 4, transfer)
+	fork, _ := GenerateChain(params.TestChainConfig, blocks[1], engine, gendb, 3, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0xff})
+		transfer(i, b)
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.StateDiffs = true
+	cacheConfig.Witnesses = true
+	chain, err := NewBlockChain(diskdb, &cacheConfig, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	check := func(blocks []*types.Block, exist bool) {
+		t.Helper()
+		for _, block := range blocks {
+			if have := rawdb.ReadStateDiff(diskdb, block.Hash(), block.NumberU64()) != nil; have != exist {
+				t.Errorf("block #%d state diff presence mismatch: have %v, want %v", block.NumberU64(), have, exist)
+			}
+			if have := rawdb.ReadWitness(diskdb, block.Hash(), block.NumberU64()) != nil; have != exist {
+				t.Errorf("block #%d witness presence mismatch: have %v, want %v", block.NumberU64(), have, exist)
+			}
+		}
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	check(blocks, true)
+
+	// Reorg to the longer fork, dropping the last two blocks
+	if n, err := chain.InsertChain(fork); err != nil {
+		t.Fatalf("block %d: failed to insert fork: %v", n, err)
+	}
+	check(blocks[:2], true)
+	check(blocks[2:], false)
+	check(fork, true)
+
+	// Rewind into the common part of the chain
+	if err := chain.SetHead(1); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	check(blocks[:1], true)
+	check(blocks[1:2], false)
+	check(fork, false)
+}
+
+// TestExecutionWitness tests that the witness recorded on import is enough to
+// re-execute the block statelessly.
+func TestExecutionWitness(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		cc      = common.HexToAddress("0x000000000000000000000000000000000000cccc")
+		ee      = common.HexToAddress("0x000000000000000000000000000000000000eeee")
+		alloc   = GenesisAlloc{
+			address: {Balance: big.NewInt(100000000000000000)},
+			// The contract CC stores the hash of the block before its parent
+			// in slot 1, the code size of EE in slot 2 and copies slot 3 to 4.
+			cc: {
+				Code: []byte{
+					byte(vm.NUMBER), byte(vm.PUSH1), 0x02, byte(vm.SWAP1), byte(vm.SUB), byte(vm.BLOCKHASH),
+					byte(vm.PUSH1), 0x01, byte(vm.SSTORE),
+					byte(vm.PUSH2), 0xee, 0xee, byte(vm.EXTCODESIZE), byte(vm.PUSH1), 0x02, byte(vm.SSTORE),
+					byte(vm.PUSH1), 0x03, byte(vm.SLOAD), byte(vm.PUSH1), 0x04, byte(vm.SSTORE),
+				},
+				Storage: map[common.Hash]common.Hash{
+					common.HexToHash("03"): common.HexToHash("03"),
+				},
+				Balance: big.NewInt(0),
+			},
+			ee: {Code: []byte{byte(vm.STOP), byte(vm.STOP), byte(vm.STOP)}, Balance: big.NewInt(0)},
+		}
+	)
+	// Fill the state with unrelated accounts, so the witness is a strict subset
+	for i := 0; i < 64; i++ {
+		alloc[common.BigToAddress(big.NewInt(int64(0x10000+i)))] = GenesisAccount{Balance: big.NewInt(1)}
+	}
+	gspec := &Genesis{Config: params.TestChainConfig, Alloc: alloc}
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 2, func(i int, b *BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	// Calling the contract needs the ancestors for BLOCKHASH, served by a chain
+	genchain, err := NewBlockChain(db, nil, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create generator chain: %v", err)
+	}
+	defer genchain.Stop()
+	if n, err := genchain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into generator chain: %v", n, err)
+	}
+	last, _ := GenerateChain(params.TestChainConfig, blocks[1], engine, db, 1, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(0, cc, big.NewInt(0), 100000, b.header.BaseFee, nil), types.HomesteadSigner{}, key)
+		b.AddTxWithChain(genchain, tx)
+	})
+	blocks = append(blocks, last...)
+
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.Witnesses = true
+	chain, err := NewBlockChain(diskdb, &cacheConfig, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	block := blocks[2]
+	witness := chain.GetWitness(block.Hash())
+	if witness == nil {
+		t.Fatalf("witness not recorded")
+	}
+	if len(witness.Headers) != 2 {
+		t.Errorf("wrong number of witness headers: have %d, want 2", len(witness.Headers))
+	}
+	if len(witness.Codes) != 2 {
+		t.Errorf("wrong number of witness codes: have %d, want 2", len(witness.Codes))
+	}
+	if err := ExecuteStateless(params.TestChainConfig, engine, vm.Config{}, block, witness); err != nil {
+		t.Fatalf("stateless execution failed: %v", err)
+	}
+	// A witness missing the accessed codes must be rejected
+	witness.Codes = make(map[string]struct{})
+	if err := ExecuteStateless(params.TestChainConfig, engine, vm.Config{}, block, witness); err == nil {
+		t.Fatalf("stateless execution succeeded with an incomplete witness")
+	}
+}
+
+// TestExecutionWitnessMissingStorage tests that stateless execution fails if the
+// witness misses storage nodes which were only read, instead of reading the
+// slots as empty.
+func TestExecutionWitnessMissingStorage(t *testing.T) {
+	var (
+		engine  = ethash.NewFaker()
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		dd      = common.HexToAddress("0x000000000000000000000000000000000000dddd")
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{
+			address: {Balance: big.NewInt(100000000000000000)},
+			// The contract DD reads slot 3 without changing any state
+			dd: {
+				Code:    []byte{byte(vm.PUSH1), 0x03, byte(vm.SLOAD), byte(vm.POP), byte(vm.STOP)},
+				Storage: map[common.Hash]common.Hash{common.HexToHash("03"): common.HexToHash("03")},
+				Balance: big.NewInt(0),
+			},
+		}}
+		db      = rawdb.NewMemoryDatabase()
+		genesis = gspec.MustCommit(db)
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 1, func(i int, b *BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(0, dd, big.NewInt(0), 100000, b.header.BaseFee, nil), types.HomesteadSigner{}, key)
+		b.AddTx(tx)
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	cacheConfig := *defaultCacheConfig
+	cacheConfig.Witnesses = true
+	chain, err := NewBlockChain(diskdb, &cacheConfig, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	witness := chain.GetWitness(blocks[0].Hash())
+	if witness == nil {
+		t.Fatalf("witness not recorded")
+	}
+	if err := ExecuteStateless(params.TestChainConfig, engine, vm.Config{}, blocks[0], witness); err != nil {
+		t.Fatalf("stateless execution failed: %v", err)
+	}
+	// Prune the storage root of the contract from the witness
+	statedb, _ := chain.StateAt(genesis.Root())
+	root := statedb.StorageTrie(dd).Hash()
+	for blob := range witness.State {
+		if crypto.Keccak256Hash([]byte(blob)) == root {
+			delete(witness.State, blob)
+		}
+	}
+	if err := ExecuteStateless(params.TestChainConfig, engine, vm.Config{}, blocks[0], witness); err == nil {
+		t.Fatalf("stateless execution succeeded without the read storage")
+	}
+}
+
 // TestDeleteRecreateSlots tests a state-transition that contains both deletion
 // and recreation of contract state.
 // Contract A exists, has slots 1 and 2 set
@@ -3761,6 +4201,106 @@ func TestSetCanonical(t *testing.T) {
 	verify(canon[TriesInMemory-1])
 }
 
+// Tests that SetCanonical refuses rewinding more blocks than the maximum reorg
+// depth, unless permitted by the operator for a single rewind.
+func TestMaxReorgDepth(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig, BaseFee: big.NewInt(params.InitialBaseFee)}
+		genesis = gspec.MustCommit(db)
+		engine  = ethash.NewFaker()
+	)
+	canon, _ := GenerateChain(params.TestChainConfig, genesis, engine, db, 10, nil)
+	side, _ := GenerateChain(params.TestChainConfig, canon[4], engine, db, 10, func(i int, gen *BlockGen) {
+		gen.SetCoinbase(common.Address{0x01})
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, nil, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(canon); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	for _, block := range side {
+		if err := chain.InsertBlockWithoutSetHead(block); err != nil {
+			t.Fatalf("failed to insert side block: %v", err)
+		}
+	}
+	chain.SetMaxReorgDepth(3)
+
+	// Switching to the side chain rewinds 5 blocks, refused unless permitted
+	if _, err := chain.SetCanonical(side[len(side)-1]); !errors.Is(err, ErrReorgTooDeep) {
+		t.Fatalf("deep reorg error mismatch: have %v, want %v", err, ErrReorgTooDeep)
+	}
+	chain.AllowReorg(4)
+	if _, err := chain.SetCanonical(side[len(side)-1]); !errors.Is(err, ErrReorgTooDeep) {
+		t.Fatalf("insufficiently permitted reorg error mismatch: have %v, want %v", err, ErrReorgTooDeep)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != canon[len(canon)-1].Hash() {
+		t.Fatalf("head changed by refused reorg: have %d [%x]", head.Number(), head.Hash())
+	}
+	chain.AllowReorg(5)
+	if _, err := chain.SetCanonical(side[len(side)-1]); err != nil {
+		t.Fatalf("permitted reorg failed: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != side[len(side)-1].Hash() {
+		t.Fatalf("head mismatch after permitted reorg: have %d [%x]", head.Number(), head.Hash())
+	}
+	// The permission is consumed, shallow rewinds are always fine
+	if _, err := chain.SetCanonical(canon[len(canon)-1]); !errors.Is(err, ErrReorgTooDeep) {
+		t.Fatalf("reused permission error mismatch: have %v, want %v", err, ErrReorgTooDeep)
+	}
+	if _, err := chain.SetCanonical(side[len(side)-3]); err != nil {
+		t.Fatalf("failed to shallow rewind: %v", err)
+	}
+}
+
+// Tests that blocks with transactions exceeding the size limits of the chain
+// config are rejected on import once the limits are activated.
+func TestBlockTxLimits(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		config  = *params.TestChainConfig
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: &config, Alloc: GenesisAlloc{addr: {Balance: big.NewInt(params.Ether)}}}
+		genesis = gspec.MustCommit(db)
+		engine  = ethash.NewFaker()
+		signer  = types.LatestSigner(&config)
+	)
+	config.TxLimits = &params.TxLimitsConfig{MaxCalldata: 100, Block: big.NewInt(2)}
+
+	// Each block carries a transaction over the calldata limit
+	blocks, _ := GenerateChain(&config, genesis, engine, db, 2, func(i int, gen *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(addr), common.Address{0x01}, new(big.Int), 100000, gen.header.BaseFee, make([]byte, 200)), signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign tx: %v", err)
+		}
+		gen.AddTx(tx)
+	})
+	diskdb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(diskdb)
+
+	chain, err := NewBlockChain(diskdb, nil, &config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// The block before the activation is accepted, the one after rejected
+	if n, err := chain.InsertChain(blocks); n != 1 || !errors.Is(err, ErrBlockTxLimits) {
+		t.Fatalf("import mismatch: have %d, %v, want 1, %v", n, err, ErrBlockTxLimits)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != blocks[0].Hash() {
+		t.Fatalf("head mismatch: have %d [%x], want %d [%x]", head.Number(), head.Hash(), blocks[0].Number(), blocks[0].Hash())
+	}
+}
+
 // TestCanonicalHashMarker tests all the canonical hash markers are updated/deleted
 // correctly in case reorg is called.
 func TestCanonicalHashMarker(t *testing.T) {
@@ -3869,3 +4409,327 @@ func TestCanonicalHashMarker(t *testing.T) {
 		}
 	}
 }
+
+// Tests that a chain sharing the database of another one follows its head when
+// reloaded, skipping the heads whose state isn't committed yet.
+func TestReloadHead(t *testing.T) {
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		gspec = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   GenesisAlloc{},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		genesis = gspec.MustCommit(db)
+		engine  = ethash.NewFaker()
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, rawdb.NewMemoryDatabase(), 10, func(i int, gen *BlockGen) {})
+
+	archive := &CacheConfig{TrieCleanLimit: 256, TrieDirtyDisabled: true, TrieTimeLimit: 5 * time.Minute}
+	writer, err := NewBlockChain(db, archive, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create writer chain: %v", err)
+	}
+	defer writer.Stop()
+
+	if n, err := writer.InsertChain(blocks[:5]); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	reader, err := NewBlockChain(db, archive, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create reader chain: %v", err)
+	}
+	defer reader.Stop()
+
+	heads := make(chan ChainHeadEvent, 1)
+	sub := reader.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	if n, err := writer.InsertChain(blocks[5:]); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	if head := reader.CurrentBlock().NumberU64(); head != 5 {
+		t.Fatalf("reader head moved before reload: %d", head)
+	}
+	// A head without committed state must be skipped
+	root := rawdb.ReadTrieNode(db, blocks[9].Root())
+	rawdb.DeleteTrieNode(db, blocks[9].Root())
+	if err := reader.ReloadHead(); err != nil {
+		t.Fatalf("failed to reload head: %v", err)
+	}
+	if head := reader.CurrentBlock().NumberU64(); head != 5 {
+		t.Fatalf("head without state not skipped: have %d, want 5", head)
+	}
+	rawdb.WriteTrieNode(db, blocks[9].Root(), root)
+
+	if err := reader.ReloadHead(); err != nil {
+		t.Fatalf("failed to reload head: %v", err)
+	}
+	if head := reader.CurrentBlock(); head.Hash() != blocks[9].Hash() {
+		t.Fatalf("reloaded head mismatch: have %d, want %d", head.NumberU64(), blocks[9].NumberU64())
+	}
+	if head := reader.CurrentHeader(); head.Hash() != blocks[9].Hash() {
+		t.Fatalf("reloaded head header mismatch: have %d, want %d", head.Number, blocks[9].NumberU64())
+	}
+	select {
+	case ev := <-heads:
+		if ev.Block.Hash() != blocks[9].Hash() {
+			t.Fatalf("head event mismatch: have %d, want %d", ev.Block.NumberU64(), blocks[9].NumberU64())
+		}
+	default:
+		t.Fatal("no head event after reload")
+	}
+}
+
+// Tests that the trie flush interval and dirty limit can be tuned at runtime and
+// that the flushing progress is reported.
+func TestTrieFlushStatus(t *testing.T) {
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		gspec = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   GenesisAlloc{},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		genesis = gspec.MustCommit(db)
+		engine  = ethash.NewFaker()
+	)
+	blocks, _ := GenerateChain(params.TestChainConfig, genesis, engine, rawdb.NewMemoryDatabase(), 2*TriesInMemory, func(i int, gen *BlockGen) {})
+
+	chain, err := NewBlockChain(db, defaultCacheConfig, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	defer chain.Stop()
+
+	status := chain.TrieFlushStatus()
+	if status.FlushInterval != defaultCacheConfig.TrieTimeLimit {
+		t.Fatalf("flush interval mismatch: have %v, want %v", status.FlushInterval, defaultCacheConfig.TrieTimeLimit)
+	}
+	if status.DirtyLimit != common.StorageSize(defaultCacheConfig.TrieDirtyLimit*1024*1024) {
+		t.Fatalf("dirty limit mismatch: have %v, want %dMB", status.DirtyLimit, defaultCacheConfig.TrieDirtyLimit)
+	}
+	chain.SetTrieDirtyLimit(512)
+	if status := chain.TrieFlushStatus(); status.DirtyLimit != 512*1024*1024 {
+		t.Fatalf("updated dirty limit mismatch: have %v, want 512MB", status.DirtyLimit)
+	}
+	// Flush after every block, the last flushed state should trail the head
+	chain.SetTrieFlushInterval(0)
+	if n, err := chain.InsertChain(blocks[:TriesInMemory+10]); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	status = chain.TrieFlushStatus()
+	if status.LastFlushBlock != 10 || status.LastFlush.IsZero() {
+		t.Fatalf("flush mismatch: have block %d at %v, want block 10", status.LastFlushBlock, status.LastFlush)
+	}
+	if status.ReplayBlocks != TriesInMemory {
+		t.Fatalf("replay window mismatch: have %d, want %d", status.ReplayBlocks, TriesInMemory)
+	}
+	if blob := rawdb.ReadTrieNode(db, blocks[9].Root()); len(blob) == 0 {
+		t.Fatal("flushed state missing from disk")
+	}
+	// Stop flushing, the replay window should grow
+	chain.SetTrieFlushInterval(time.Hour)
+	if n, err := chain.InsertChain(blocks[TriesInMemory+10:]); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	status = chain.TrieFlushStatus()
+	if status.LastFlushBlock != 10 {
+		t.Fatalf("unexpected flush: have block %d, want block 10", status.LastFlushBlock)
+	}
+	if status.ReplayBlocks != 2*TriesInMemory-10 || status.ReplayTime == 0 {
+		t.Fatalf("replay window mismatch: have %d blocks in %v, want %d blocks", status.ReplayBlocks, status.ReplayTime, 2*TriesInMemory-10)
+	}
+}
+
+// Tests that a chain stored with the path scheme keeps the recent states
+// readable, restores them from the journal on restart and reverts the
+// persistent state when rewinding the head.
+func TestPathSchemeChain(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000000000)
+		gspec   = &Genesis{
+			Config:  params.TestChainConfig,
+			Alloc:   GenesisAlloc{address: {Balance: funds}},
+			BaseFee: big.NewInt(params.InitialBaseFee),
+		}
+		engine = ethash.NewFaker()
+		signer = types.LatestSigner(gspec.Config)
+	)
+	// Generate the chain with the hash scheme, touching new accounts in every block
+	gendb := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(gendb)
+	blocks, _ := GenerateChain(gspec.Config, genesis, engine, gendb, 2*TriesInMemory, func(i int, b *BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(b.TxNonce(address), common.Address{byte(i), 0x1}, big.NewInt(1), params.TxGas, b.header.BaseFee, nil), signer, key)
+		if err != nil {
+			t.Fatalf("failed to sign tx: %v", err)
+		}
+		b.AddTx(tx)
+	})
+	// Import it into a database using the path scheme
+	db := rawdb.NewMemoryDatabase()
+	rawdb.WriteStateScheme(db, rawdb.PathScheme)
+	if have := gspec.MustCommit(db); have.Hash() != genesis.Hash() {
+		t.Fatalf("genesis mismatch: have %x, want %x", have.Hash(), genesis.Hash())
+	}
+	config := &CacheConfig{
+		TrieCleanLimit: 256,
+		TrieDirtyLimit: 256,
+		TrieTimeLimit:  5 * time.Minute,
+		SnapshotLimit:  256,
+		SnapshotWait:   true,
+		StateHistory:   2 * TriesInMemory,
+	}
+	chain, err := NewBlockChain(db, config, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create tester chain: %v", err)
+	}
+	if scheme := chain.stateCache.TrieDB().Scheme(); scheme != rawdb.PathScheme {
+		t.Fatalf("state scheme mismatch: have %s, want %s", scheme, rawdb.PathScheme)
+	}
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	for _, block := range blocks[len(blocks)-TriesInMemory:] {
+		if !chain.HasState(block.Root()) {
+			t.Fatalf("block %d: state missing", block.NumberU64())
+		}
+	}
+	if chain.HasState(blocks[len(blocks)-TriesInMemory-2].Root()) {
+		t.Fatal("state older than the diff layers retained")
+	}
+	// Restart the chain, the states held in memory must survive
+	chain.Stop()
+
+	chain, err = NewBlockChain(db, config, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to recreate tester chain: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != blocks[len(blocks)-1].Hash() {
+		t.Fatalf("head mismatch after restart: have %d, want %d", head.NumberU64(), blocks[len(blocks)-1].NumberU64())
+	}
+	for _, block := range blocks[len(blocks)-TriesInMemory:] {
+		if !chain.HasState(block.Root()) {
+			t.Fatalf("block %d: state missing after restart", block.NumberU64())
+		}
+	}
+	// Rewind below the persistent state, it must be reverted through the histories
+	target := blocks[len(blocks)-TriesInMemory-20]
+	if err := chain.SetHead(target.NumberU64()); err != nil {
+		t.Fatalf("failed to rewind chain: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != target.Hash() {
+		t.Fatalf("head mismatch after rewind: have %d, want %d", head.NumberU64(), target.NumberU64())
+	}
+	statedb, err := chain.State()
+	if err != nil {
+		t.Fatalf("rewound state missing: %v", err)
+	}
+	if have, want := statedb.GetNonce(address), target.NumberU64(); have != want {
+		t.Fatalf("nonce mismatch after rewind: have %d, want %d", have, want)
+	}
+	// The chain must be importable again on top of the rewound state
+	if n, err := chain.InsertChain(blocks[target.NumberU64():]); err != nil {
+		t.Fatalf("block %d: failed to reimport: %v", n, err)
+	}
+	chain.Stop()
+}
+
+// Tests that reloading the head of a shared chain announces the blocks it moved
+// over, along with their logs, and the logs removed by a reorg.
+func TestReloadHeadEvents(t *testing.T) {
+	var (
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr    = crypto.PubkeyToAddress(key.PublicKey)
+		db      = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig, Alloc: GenesisAlloc{addr: {Balance: big.NewInt(10000000000000000)}}}
+		genesis = gspec.MustCommit(db)
+		signer  = types.LatestSigner(gspec.Config)
+		engine  = ethash.NewFaker()
+		gendb   = rawdb.NewMemoryDatabase()
+	)
+	gspec.MustCommit(gendb)
+	chain, _ := GenerateChain(gspec.Config, genesis, engine, gendb, 2, func(i int, gen *BlockGen) {
+		if i == 1 {
+			tx, err := types.SignTx(types.NewContractCreation(gen.TxNonce(addr), new(big.Int), 1000000, gen.header.BaseFee, logCode), signer, key)
+			if err != nil {
+				t.Fatalf("failed to create tx: %v", err)
+			}
+			gen.AddTx(tx)
+		}
+	})
+	fork, _ := GenerateChain(gspec.Config, genesis, engine, gendb, 3, func(i int, gen *BlockGen) { gen.SetCoinbase(common.Address{1}) })
+
+	archive := &CacheConfig{TrieCleanLimit: 256, TrieDirtyDisabled: true, TrieTimeLimit: 5 * time.Minute}
+	writer, err := NewBlockChain(db, archive, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create writer chain: %v", err)
+	}
+	defer writer.Stop()
+
+	reader, err := NewBlockChain(db, archive, gspec.Config, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create reader chain: %v", err)
+	}
+	defer reader.Stop()
+
+	var (
+		chainCh  = make(chan ChainEvent, 8)
+		logsCh   = make(chan []*types.Log, 8)
+		rmLogsCh = make(chan RemovedLogsEvent, 8)
+	)
+	defer reader.SubscribeChainEvent(chainCh).Unsubscribe()
+	defer reader.SubscribeLogsEvent(logsCh).Unsubscribe()
+	defer reader.SubscribeRemovedLogsEvent(rmLogsCh).Unsubscribe()
+
+	checkChain := func(blocks []*types.Block) {
+		t.Helper()
+		for _, block := range blocks {
+			select {
+			case ev := <-chainCh:
+				if ev.Hash != block.Hash() {
+					t.Fatalf("chain event mismatch: have %d %x, want %d %x", ev.Block.NumberU64(), ev.Hash, block.NumberU64(), block.Hash())
+				}
+			default:
+				t.Fatalf("missing chain event of block %d", block.NumberU64())
+			}
+		}
+		if len(chainCh) != 0 {
+			t.Fatalf("unexpected chain events: %d", len(chainCh))
+		}
+	}
+	// Extend the chain, the reader announces the blocks and their logs
+	if n, err := writer.InsertChain(chain); err != nil {
+		t.Fatalf("block %d: failed to insert into chain: %v", n, err)
+	}
+	if err := reader.ReloadHead(); err != nil {
+		t.Fatalf("failed to reload head: %v", err)
+	}
+	checkChain(chain)
+	select {
+	case logs := <-logsCh:
+		if len(logs) != 1 || logs[0].BlockHash != chain[1].Hash() {
+			t.Fatalf("logs mismatch: have %v", logs)
+		}
+	default:
+		t.Fatal("missing logs event")
+	}
+	// Reorg the chain, the reader announces the removed logs and the new blocks
+	if n, err := writer.InsertChain(fork); err != nil {
+		t.Fatalf("block %d: failed to insert fork into chain: %v", n, err)
+	}
+	if err := reader.ReloadHead(); err != nil {
+		t.Fatalf("failed to reload head: %v", err)
+	}
+	checkChain(fork)
+	select {
+	case ev := <-rmLogsCh:
+		if len(ev.Logs) != 1 || !ev.Logs[0].Removed || ev.Logs[0].BlockHash != chain[1].Hash() {
+			t.Fatalf("removed logs mismatch: have %v", ev.Logs)
+		}
+	default:
+		t.Fatal("missing removed logs event")
+	}
+}
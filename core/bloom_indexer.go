@@ -26,6 +26,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
 )
 
 const (
@@ -90,3 +91,31 @@ func (b *BloomIndexer) Commit() error {
 func (b *BloomIndexer) Prune(threshold uint64) error {
 	return nil
 }
+
+// unindexBloomBits deletes the bloom bits of all the sections ending before the
+// given block number, moving the log index tail up to the first retained section.
+// The tail is persisted before deleting anything, so that an interrupted run never
+// leaves partially deleted sections inside the advertised log index window.
+func unindexBloomBits(db ethdb.Database, size uint64, tail uint64, interrupt chan struct{}) {
+	var from uint64
+	if stored := rawdb.ReadLogIndexTail(db); stored != nil {
+		from = *stored / size
+	}
+	to := tail / size
+	if to <= from {
+		return
+	}
+	start := time.Now()
+	rawdb.WriteLogIndexTail(db, to*size)
+
+	for bit := uint(0); bit < types.BloomBitLength; bit++ {
+		select {
+		case <-interrupt:
+			log.Debug("Log unindexing interrupted", "from", from*size, "to", to*size)
+			return
+		default:
+		}
+		rawdb.DeleteBloombits(db, bit, from, to)
+	}
+	log.Info("Unindexed bloom bits", "sections", to-from, "tail", to*size, "elapsed", common.PrettyDuration(time.Since(start)))
+}
@@ -109,6 +109,9 @@ func (b *BlockGen) AddTxWithChain(bc *BlockChain, tx *types.Transaction) {
 	}
 	b.txs = append(b.txs, tx)
 	b.receipts = append(b.receipts, receipt)
+	if b.header.BlobGasUsed != nil {
+		*b.header.BlobGasUsed += tx.BlobGas()
+	}
 }
 
 // GetBalance returns the balance of the given address at the generated block.
@@ -248,6 +251,11 @@ func GenerateChain(config *params.ChainConfig, parent *types.Block, engine conse
 				}
 			}
 		}
+		if config.Verkle != nil && config.Verkle.Block != nil && config.Verkle.Block.Cmp(b.header.Number) == 0 {
+			if err := statedb.ConvertToVerkle(); err != nil {
+				panic(fmt.Sprintf("verkle conversion error: %v", err))
+			}
+		}
 		if config.DAOForkSupport && config.DAOForkBlock != nil && config.DAOForkBlock.Cmp(b.header.Number) == 0 {
 			misc.ApplyDAOHardFork(statedb)
 		}
@@ -312,6 +320,12 @@ func makeHeader(chain consensus.ChainReader, parent *types.Block, state *state.S
 			header.GasLimit = CalcGasLimit(parentGasLimit, parentGasLimit)
 		}
 	}
+	if chain.Config().IsBlobTx(header.Number) {
+		excessBlobGas := misc.CalcExcessBlobGas(chain.Config(), parent.Header())
+		header.ExcessBlobGas = &excessBlobGas
+		header.BlobGasUsed = new(uint64)
+		header.WithdrawalsHash = &types.EmptyRootHash
+	}
 	return header
 }
 
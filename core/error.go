@@ -32,6 +32,14 @@ var (
 	// ErrNoGenesis is returned when there is no Genesis Block.
 	ErrNoGenesis = errors.New("genesis not found in chain")
 
+	// ErrReorgTooDeep is returned if setting a new head would rewind more blocks
+	// of the canonical chain than the maximum reorg depth permits.
+	ErrReorgTooDeep = errors.New("reorg too deep")
+
+	// ErrBlockTxLimits is returned if a block to import contains a transaction
+	// exceeding the size limits of the chain config.
+	ErrBlockTxLimits = errors.New("block transaction exceeds size limits")
+
 	errSideChainReceipts = errors.New("side blocks can't be accepted as ancient chain data")
 )
 
@@ -96,4 +104,37 @@ var (
 
 	// ErrSenderNoEOA is returned if the sender of a transaction is a contract.
 	ErrSenderNoEOA = errors.New("sender not an eoa")
+
+	// ErrBlobFeeCapTooLow is returned if the transaction blob gas fee cap is
+	// less than the blob gas fee of the block.
+	ErrBlobFeeCapTooLow = errors.New("max fee per blob gas less than block blob gas fee")
+
+	// ErrMissingBlobHashes is returned if a blob transaction has no blobs.
+	ErrMissingBlobHashes = errors.New("blob transaction missing blob hashes")
+
+	// ErrBlobGasLimit is returned if the blobs of a transaction exceed the blob
+	// gas limit of a block.
+	ErrBlobGasLimit = errors.New("blob gas exceeds block limit")
+
+	// ErrBlobHashVersion is returned if a blob hash is not a versioned hash of
+	// a known version.
+	ErrBlobHashVersion = errors.New("blob hash version mismatch")
+
+	// ErrEmptyAuthList is returned if a set code transaction has no
+	// authorizations.
+	ErrEmptyAuthList = errors.New("set code transaction with empty auth list")
+
+	// ErrSetCodeTxCreate is returned if a set code transaction has no
+	// destination.
+	ErrSetCodeTxCreate = errors.New("set code transaction cannot be used to create contract")
+)
+
+// List of set code authorization errors. These make an authorization invalid
+// without failing the transaction carrying it.
+var (
+	ErrAuthorizationWrongChainID       = errors.New("authorization chain ID mismatch")
+	ErrAuthorizationNonceOverflow      = errors.New("authorization nonce overflow")
+	ErrAuthorizationInvalidSignature   = errors.New("authorization has invalid signature")
+	ErrAuthorizationDestinationHasCode = errors.New("authorization destination has code")
+	ErrAuthorizationNonceMismatch      = errors.New("authorization nonce does not match")
 )
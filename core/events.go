@@ -24,6 +24,16 @@ import (
 // NewTxsEvent is posted when a batch of transactions enter the transaction pool.
 type NewTxsEvent struct{ Txs []*types.Transaction }
 
+// DroppedTx is a transaction removed from the transaction pool without being
+// included, along with the reason it was dropped.
+type DroppedTx struct {
+	Tx     *types.Transaction
+	Reason string // One of the TxDrop reasons
+}
+
+// DropTxsEvent is posted when transactions are dropped from the transaction pool.
+type DropTxsEvent struct{ Txs []DroppedTx }
+
 // NewMinedBlockEvent is posted when a block has been imported.
 type NewMinedBlockEvent struct{ Block *types.Block }
 
@@ -41,3 +51,15 @@ type ChainSideEvent struct {
 }
 
 type ChainHeadEvent struct{ Block *types.Block }
+
+// ChainFinalizedEvent is posted when the finalized block changes.
+type ChainFinalizedEvent struct {
+	Block *types.Block
+	Old   *types.Block // Previously finalized block, nil if none
+}
+
+// ChainSafeEvent is posted when the safe block changes.
+type ChainSafeEvent struct {
+	Block *types.Block
+	Old   *types.Block // Previous safe block, nil if none
+}
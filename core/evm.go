@@ -38,9 +38,10 @@ type ChainContext interface {
 // NewEVMBlockContext creates a new context for use in the EVM.
 func NewEVMBlockContext(header *types.Header, chain ChainContext, author *common.Address) vm.BlockContext {
 	var (
-		beneficiary common.Address
-		baseFee     *big.Int
-		random      *common.Hash
+		beneficiary   common.Address
+		baseFee       *big.Int
+		random        *common.Hash
+		excessBlobGas *uint64
 	)
 
 	// If we don't have an explicit author (i.e. not mining), extract from the header
@@ -55,6 +56,10 @@ func NewEVMBlockContext(header *types.Header, chain ChainContext, author *common
 	if header.Difficulty.Cmp(common.Big0) == 0 {
 		random = &header.MixDigest
 	}
+	if header.ExcessBlobGas != nil {
+		excess := *header.ExcessBlobGas
+		excessBlobGas = &excess
+	}
 	return vm.BlockContext{
 		CanTransfer: CanTransfer,
 		Transfer:    Transfer,
@@ -66,15 +71,25 @@ func NewEVMBlockContext(header *types.Header, chain ChainContext, author *common
 		BaseFee:     baseFee,
 		GasLimit:    header.GasLimit,
 		Random:      random,
+
+		ExcessBlobGas: excessBlobGas,
 	}
 }
 
 // NewEVMTxContext creates a new transaction context for a single transaction.
 func NewEVMTxContext(msg Message) vm.TxContext {
-	return vm.TxContext{
+	ctx := vm.TxContext{
 		Origin:   msg.From(),
 		GasPrice: new(big.Int).Set(msg.GasPrice()),
 	}
+	if msg.Nonce() == types.DepositsNonce {
+		ctx.IsDeposit = true
+		ctx.IsSystemTx = msg.From() == L1InfoDepositerAddr
+		if mint := msg.Mint(); mint != nil {
+			ctx.Mint = new(big.Int).Set(mint)
+		}
+	}
+	return ctx
 }
 
 // GetHashFn returns a GetHashFunc which retrieves header hashes by number
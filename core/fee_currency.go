@@ -0,0 +1,121 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// FeeCurrencyMessage is the part of a transaction or message a fee currency
+// inspects to decide whether it pays the fees.
+type FeeCurrencyMessage interface {
+	To() *common.Address
+	Data() []byte
+	Nonce() uint64
+}
+
+// FeeCurrency is an alternative fee payment of a network, e.g. fees paid in an
+// ERC-20 token through a designated contract. For the transactions it handles,
+// it replaces the ether balance of the sender when fees are checked at pool
+// admission, bought before execution and refunded or paid out afterwards. The
+// transferred value is always paid in ether. All amounts are denominated in wei,
+// conversions are up to the implementation.
+type FeeCurrency interface {
+	// Handles reports whether the fees of the message are paid in the fee currency.
+	Handles(msg FeeCurrencyMessage) bool
+
+	// Balance returns the fee currency balance of the account.
+	Balance(db vm.StateDB, addr common.Address) *big.Int
+
+	// Debit charges the account with the given amount of fees.
+	Debit(db vm.StateDB, addr common.Address, amount *big.Int)
+
+	// Credit pays the given amount of fees to the account.
+	Credit(db vm.StateDB, addr common.Address, amount *big.Int)
+}
+
+// FeeCurrencyFactory creates the fee currency configured for a network.
+type FeeCurrencyFactory func(config *params.FeeCurrencyConfig) (FeeCurrency, error)
+
+var (
+	feeCurrencyLock      sync.RWMutex
+	feeCurrencyFactories = make(map[string]FeeCurrencyFactory)
+	feeCurrencies        = make(map[*params.FeeCurrencyConfig]FeeCurrency)
+)
+
+// RegisterFeeCurrency makes a fee currency available to chain configs under the
+// given name. It is meant to be called from init functions of network specific
+// packages and panics if the name is already taken.
+func RegisterFeeCurrency(name string, factory FeeCurrencyFactory) {
+	feeCurrencyLock.Lock()
+	defer feeCurrencyLock.Unlock()
+
+	if _, ok := feeCurrencyFactories[name]; ok {
+		panic(fmt.Sprintf("fee currency %q registered twice", name))
+	}
+	feeCurrencyFactories[name] = factory
+}
+
+// NewFeeCurrency returns the fee currency selected by the chain config, or nil
+// if there is none. Fee currencies are created once per configuration.
+func NewFeeCurrency(config *params.ChainConfig) (FeeCurrency, error) {
+	if config.FeeCurrency == nil {
+		return nil, nil
+	}
+	feeCurrencyLock.RLock()
+	fc, ok := feeCurrencies[config.FeeCurrency]
+	feeCurrencyLock.RUnlock()
+	if ok {
+		return fc, nil
+	}
+	feeCurrencyLock.Lock()
+	defer feeCurrencyLock.Unlock()
+
+	if fc, ok := feeCurrencies[config.FeeCurrency]; ok {
+		return fc, nil
+	}
+	factory, ok := feeCurrencyFactories[config.FeeCurrency.Name]
+	if !ok {
+		return nil, fmt.Errorf("unknown fee currency %q", config.FeeCurrency.Name)
+	}
+	fc, err := factory(config.FeeCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("fee currency %q: %w", config.FeeCurrency.Name, err)
+	}
+	feeCurrencies[config.FeeCurrency] = fc
+	return fc, nil
+}
+
+// activeFeeCurrency returns the fee currency handling the message in the given
+// block, or nil if its fees are paid in ether. Unknown fee currencies are
+// rejected when the chain is set up.
+func activeFeeCurrency(config *params.ChainConfig, number *big.Int, msg FeeCurrencyMessage) FeeCurrency {
+	if !config.IsFeeCurrency(number) {
+		return nil
+	}
+	fc, err := NewFeeCurrency(config)
+	if err != nil || fc == nil || !fc.Handles(msg) {
+		return nil
+	}
+	return fc
+}
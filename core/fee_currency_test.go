@@ -0,0 +1,151 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// tokenFeeCurrency is a fee currency keeping token balances in the storage of
+// the designated contract, handling all transactions with a non-zero nonce.
+type tokenFeeCurrency struct {
+	contract common.Address
+}
+
+func init() {
+	RegisterFeeCurrency("token", func(config *params.FeeCurrencyConfig) (FeeCurrency, error) {
+		return &tokenFeeCurrency{contract: config.Contract}, nil
+	})
+}
+
+func (fc *tokenFeeCurrency) Handles(msg FeeCurrencyMessage) bool {
+	return msg.Nonce() > 0
+}
+
+func (fc *tokenFeeCurrency) Balance(db vm.StateDB, addr common.Address) *big.Int {
+	return db.GetState(fc.contract, addr.Hash()).Big()
+}
+
+func (fc *tokenFeeCurrency) Debit(db vm.StateDB, addr common.Address, amount *big.Int) {
+	db.SetState(fc.contract, addr.Hash(), common.BigToHash(new(big.Int).Sub(fc.Balance(db, addr), amount)))
+}
+
+func (fc *tokenFeeCurrency) Credit(db vm.StateDB, addr common.Address, amount *big.Int) {
+	db.SetState(fc.contract, addr.Hash(), common.BigToHash(new(big.Int).Add(fc.Balance(db, addr), amount)))
+}
+
+func TestFeeCurrency(t *testing.T) {
+	var (
+		token    = common.HexToAddress("0x000000000000000000000000000000000000aaaa")
+		coinbase = common.HexToAddress("0x000000000000000000000000000000000000cccc")
+		key, _   = crypto.GenerateKey()
+		sender   = crypto.PubkeyToAddress(key.PublicKey)
+		config   = *params.TestChainConfig
+	)
+	config.FeeCurrency = &params.FeeCurrencyConfig{Name: "token", Contract: token, Block: big.NewInt(0)}
+	fc, err := NewFeeCurrency(&config)
+	if err != nil {
+		t.Fatalf("failed to create fee currency: %v", err)
+	}
+	// The first transaction pays its fees in ether, later ones in tokens.
+	gasPrice := big.NewInt(params.InitialBaseFee)
+	transfer := func(nonce uint64) *types.Transaction {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(100), params.TxGas, gasPrice, nil), types.HomesteadSigner{}, key)
+		return tx
+	}
+	etherFee := new(big.Int).Mul(gasPrice, big.NewInt(int64(params.TxGas)))
+
+	// The pool only checks the value against the ether balance of handled transactions.
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	statedb.SetNonce(sender, 1)
+	statedb.AddBalance(sender, big.NewInt(100))
+	pool := NewTxPool(testTxPoolConfig, &config, &testBlockChain{10000000, statedb, new(event.Feed)})
+	defer pool.Stop()
+
+	if err := pool.AddRemote(transfer(1)); !errors.Is(err, ErrInsufficientFunds) {
+		t.Fatalf("transaction without tokens admitted: %v", err)
+	}
+	fc.Credit(statedb, sender, etherFee)
+	if err := pool.addRemoteSync(transfer(1)); err != nil {
+		t.Fatalf("failed to admit transaction paying tokens: %v", err)
+	}
+	if pending, _ := pool.Stats(); pending != 1 {
+		t.Fatalf("pending transactions mismatch: have %d, want 1", pending)
+	}
+
+	// Block building and execution charge the fees in tokens.
+	gspec := &Genesis{
+		Config: &config,
+		Alloc: GenesisAlloc{
+			sender: {Balance: new(big.Int).Add(etherFee, big.NewInt(200))},
+			token: {
+				Code:    []byte{byte(vm.STOP)},
+				Balance: big.NewInt(0),
+				Storage: map[common.Hash]common.Hash{sender.Hash(): common.BigToHash(etherFee)},
+			},
+		},
+	}
+	db := rawdb.NewMemoryDatabase()
+	genesis := gspec.MustCommit(db)
+	blocks, _ := GenerateChain(&config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		b.SetCoinbase(coinbase)
+		b.AddTx(transfer(0))
+		b.AddTx(transfer(1))
+	})
+	chain, err := NewBlockChain(db, nil, &config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	state, _ := chain.State()
+	if have := state.GetBalance(sender); have.Sign() != 0 {
+		t.Errorf("sender ether balance mismatch: have %v, want 0", have)
+	}
+	if have := fc.Balance(state, sender); have.Sign() != 0 {
+		t.Errorf("sender token balance mismatch: have %v, want 0", have)
+	}
+	// Only the tip is paid to the coinbase, the base fee is burnt in both currencies.
+	tip := new(big.Int).Mul(new(big.Int).Sub(gasPrice, blocks[0].BaseFee()), big.NewInt(int64(params.TxGas)))
+	if have := state.GetBalance(coinbase); have.Cmp(new(big.Int).Add(tip, ethash.ConstantinopleBlockReward)) != 0 {
+		t.Errorf("coinbase ether balance mismatch: have %v, want %v", have, new(big.Int).Add(tip, ethash.ConstantinopleBlockReward))
+	}
+	if have := fc.Balance(state, coinbase); have.Cmp(tip) != 0 {
+		t.Errorf("coinbase token balance mismatch: have %v, want %v", have, tip)
+	}
+
+	// Chains configured with an unknown fee currency are rejected.
+	unknown := config
+	unknown.FeeCurrency = &params.FeeCurrencyConfig{Name: "unknown", Block: big.NewInt(0)}
+	if _, err := NewBlockChain(rawdb.NewMemoryDatabase(), nil, &unknown, ethash.NewFaker(), vm.Config{}, nil, nil); err == nil {
+		t.Fatal("chain with unknown fee currency created")
+	}
+}
@@ -42,7 +42,13 @@ import (
 //go:generate go run github.com/fjl/gencodec -type Genesis -field-override genesisSpecMarshaling -out gen_genesis.go
 //go:generate go run github.com/fjl/gencodec -type GenesisAccount -field-override genesisAccountMarshaling -out gen_genesis_account.go
 
-var errGenesisNoConfig = errors.New("genesis has no chain configuration")
+var (
+	errGenesisNoConfig = errors.New("genesis has no chain configuration")
+
+	// errGenesisNoAlloc is returned if the allocation of a genesis is unknown,
+	// so its state can't be recommitted.
+	errGenesisNoAlloc = errors.New("genesis allocation not found")
+)
 
 // Genesis specifies the header fields, state of a genesis block. It also defines hard
 // fork switch-over blocks through the chain configuration.
@@ -57,6 +63,11 @@ type Genesis struct {
 	Coinbase   common.Address      `json:"coinbase"`
 	Alloc      GenesisAlloc        `json:"alloc"      gencodec:"required"`
 
+	// StateHash is the state root of a genesis whose state is not part of the
+	// specification, such as the migrated state a rollup chain is started from.
+	// Its state is retrieved through snap sync instead, so Alloc must be empty.
+	StateHash *common.Hash `json:"stateHash,omitempty"`
+
 	// These fields are used for consensus tests. Please don't use them
 	// in actual genesis blocks.
 	Number     uint64      `json:"number"`
@@ -82,8 +93,8 @@ func (ga *GenesisAlloc) UnmarshalJSON(data []byte) error {
 
 // flush adds allocated genesis accounts into a fresh new statedb and
 // commit the state changes into the given database handler.
-func (ga *GenesisAlloc) flush(db ethdb.Database) (common.Hash, error) {
-	statedb, err := state.New(common.Hash{}, state.NewDatabase(db), nil)
+func (ga *GenesisAlloc) flush(db state.Database) (common.Hash, error) {
+	statedb, err := state.New(common.Hash{}, db, nil)
 	if err != nil {
 		return common.Hash{}, err
 	}
@@ -118,8 +129,8 @@ func (ga *GenesisAlloc) write(db ethdb.KeyValueWriter, hash common.Hash) error {
 }
 
 // CommitGenesisState loads the stored genesis state with the given block
-// hash and commits them into the given database handler.
-func CommitGenesisState(db ethdb.Database, hash common.Hash) error {
+// hash and commits them into the given state database.
+func CommitGenesisState(db ethdb.Database, statedb state.Database, hash common.Hash) error {
 	var alloc GenesisAlloc
 	blob := rawdb.ReadGenesisState(db, hash)
 	if len(blob) != 0 {
@@ -148,10 +159,10 @@ func CommitGenesisState(db ethdb.Database, hash common.Hash) error {
 		if genesis != nil {
 			alloc = genesis.Alloc
 		} else {
-			return errors.New("not found")
+			return errGenesisNoAlloc
 		}
 	}
-	_, err := alloc.flush(db)
+	_, err := alloc.flush(statedb)
 	return err
 }
 
@@ -266,9 +277,15 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 		return genesis.Config, block.Hash(), nil
 	}
 	// We have the genesis block in database(perhaps in ancient database)
-	// but the corresponding state is missing.
+	// but the corresponding state is missing. The path scheme only keeps the
+	// state of the head, the chain recommits the genesis state if rewound to it.
 	header := rawdb.ReadHeader(db, stored, 0)
-	if _, err := state.New(header.Root, state.NewDatabaseWithConfig(db, nil), nil); err != nil {
+	var missing bool
+	if rawdb.ReadStateScheme(db) != rawdb.PathScheme {
+		_, err := state.New(header.Root, state.NewDatabaseWithConfig(db, nil), nil)
+		missing = err != nil
+	}
+	if missing && !statelessGenesis(db, stored, genesis) {
 		if genesis == nil {
 			genesis = DefaultGenesisBlock()
 		}
@@ -326,6 +343,19 @@ func SetupGenesisBlockWithOverride(db ethdb.Database, genesis *Genesis, override
 	return newcfg, stored, nil
 }
 
+// statelessGenesis reports whether the stored genesis block was committed
+// without its state, which is then expected to be snap synced rather than
+// recommitted from the genesis specification.
+func statelessGenesis(db ethdb.Database, stored common.Hash, genesis *Genesis) bool {
+	if genesis != nil {
+		return genesis.StateHash != nil
+	}
+	if len(rawdb.ReadGenesisState(db, stored)) != 0 {
+		return false
+	}
+	return genesis.configOrDefault(stored) == params.AllEthashProtocolChanges
+}
+
 func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 	switch {
 	case g != nil:
@@ -348,14 +378,29 @@ func (g *Genesis) configOrDefault(ghash common.Hash) *params.ChainConfig {
 }
 
 // ToBlock creates the genesis block and writes state of a genesis specification
-// to the given database (or discards it if nil).
+// to the given database (or discards it if nil). The state of databases using
+// the path scheme is left to the chain to commit.
 func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 	if db == nil {
 		db = rawdb.NewMemoryDatabase()
 	}
-	root, err := g.Alloc.flush(db)
-	if err != nil {
-		panic(err)
+	var root common.Hash
+	if g.StateHash != nil {
+		if len(g.Alloc) > 0 {
+			panic(fmt.Errorf("genesis with state hash %x can't allocate accounts", *g.StateHash))
+		}
+		root = *g.StateHash
+	} else {
+		// The state of the path scheme is committed by the chain through its
+		// own trie database, only derive its root here
+		statedb := state.NewDatabase(db)
+		if rawdb.ReadStateScheme(db) == rawdb.PathScheme {
+			statedb = state.NewDatabase(rawdb.NewMemoryDatabase())
+		}
+		var err error
+		if root, err = g.Alloc.flush(statedb); err != nil {
+			panic(err)
+		}
 	}
 	head := &types.Header{
 		Number:     new(big.Int).SetUint64(g.Number),
@@ -384,6 +429,10 @@ func (g *Genesis) ToBlock(db ethdb.Database) *types.Block {
 			head.BaseFee = new(big.Int).SetUint64(params.InitialBaseFee)
 		}
 	}
+	if g.Config != nil && g.Config.IsBlobTx(common.Big0) {
+		head.BlobGasUsed, head.ExcessBlobGas = new(uint64), new(uint64)
+		head.WithdrawalsHash = &types.EmptyRootHash
+	}
 	return types.NewBlock(head, nil, nil, nil, trie.NewStackTrie(nil))
 }
 
@@ -404,8 +453,11 @@ func (g *Genesis) Commit(db ethdb.Database) (*types.Block, error) {
 	if config.Clique != nil && len(block.Extra()) < 32+crypto.SignatureLength {
 		return nil, errors.New("can't start clique chain without signers")
 	}
-	if err := g.Alloc.write(db, block.Hash()); err != nil {
-		return nil, err
+	// The state of a genesis with a state hash is snap synced, it has no allocation
+	if g.StateHash == nil {
+		if err := g.Alloc.write(db, block.Hash()); err != nil {
+			return nil, err
+		}
 	}
 	rawdb.WriteTd(db, block.Hash(), block.NumberU64(), block.Difficulty())
 	rawdb.WriteBlock(db, block)
@@ -243,3 +243,47 @@ func TestReadWriteGenesisAlloc(t *testing.T) {
 		}
 	}
 }
+
+func TestStatelessGenesis(t *testing.T) {
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		root    = common.HexToHash("0xdeadbeef")
+		genesis = &Genesis{Config: params.TestChainConfig, BaseFee: big.NewInt(params.InitialBaseFee), StateHash: &root}
+	)
+	block, err := genesis.Commit(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Root() != root {
+		t.Fatalf("genesis root mismatch: have %x, want %x", block.Root(), root)
+	}
+	if blob := rawdb.ReadGenesisState(db, block.Hash()); len(blob) != 0 {
+		t.Fatalf("genesis allocation stored: %s", blob)
+	}
+	// Restarting, with or without the specification, must not recommit the genesis
+	for _, spec := range []*Genesis{nil, genesis} {
+		_, hash, err := SetupGenesisBlock(db, spec)
+		if err != nil {
+			t.Fatalf("failed to set up genesis (spec %v): %v", spec != nil, err)
+		}
+		if hash != block.Hash() {
+			t.Fatalf("genesis hash mismatch: have %x, want %x", hash, block.Hash())
+		}
+	}
+	// The chain must start up awaiting the genesis state, also when rewound
+	chain, err := NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Stop()
+
+	if err := chain.SetHead(0); err != nil {
+		t.Fatal(err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != block.Hash() {
+		t.Fatalf("chain head mismatch: have %x, want %x", head.Hash(), block.Hash())
+	}
+	if chain.HasState(root) {
+		t.Fatal("genesis state unexpectedly present")
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// heatMapSlots is the number of hottest storage slots kept per contract.
+	heatMapSlots = 64
+
+	// heatMapHeaders is the number of recent headers loaded on warmup.
+	heatMapHeaders = 512
+)
+
+// heatMap counts the accesses of contracts and their storage slots during block
+// processing, so that the hottest state can be loaded into the caches when the
+// node is restarted.
+type heatMap struct {
+	limit     int // Number of hottest contracts to keep
+	lock      sync.Mutex
+	contracts map[common.Address]*contractHeat
+}
+
+// contractHeat is the access count of a contract and its storage slots.
+type contractHeat struct {
+	hits  uint64
+	slots map[common.Hash]uint64
+}
+
+// heatMapEntry is the persisted form of a contract in the heat map.
+type heatMapEntry struct {
+	Address common.Address `json:"address"`
+	Hits    uint64         `json:"hits"`
+	Slots   []common.Hash  `json:"slots"` // Hottest first
+}
+
+func newHeatMap(limit int) *heatMap {
+	return &heatMap{limit: limit, contracts: make(map[common.Address]*contractHeat)}
+}
+
+// record counts the accesses of a processed block.
+func (h *heatMap) record(accessed map[common.Address][]common.Hash) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for addr, slots := range accessed {
+		heat := h.contracts[addr]
+		if heat == nil {
+			heat = &contractHeat{slots: make(map[common.Hash]uint64)}
+			h.contracts[addr] = heat
+		}
+		heat.hits++
+		for _, slot := range slots {
+			heat.slots[slot]++
+		}
+		if len(heat.slots) > 2*heatMapSlots {
+			heat.slots = topSlots(heat.slots)
+		}
+	}
+	if len(h.contracts) > 2*h.limit {
+		h.prune()
+	}
+}
+
+// prune drops all but the hottest contracts and halves the remaining counts,
+// so that recent accesses outweigh old ones.
+func (h *heatMap) prune() {
+	entries := h.entries()
+	contracts := make(map[common.Address]*contractHeat, len(entries))
+	for _, entry := range entries {
+		heat := h.contracts[entry.Address]
+		heat.hits /= 2
+		for slot, hits := range heat.slots {
+			heat.slots[slot] = hits / 2
+		}
+		contracts[entry.Address] = heat
+	}
+	h.contracts = contracts
+}
+
+// entries returns the hottest contracts and their hottest slots, hottest first.
+func (h *heatMap) entries() []heatMapEntry {
+	entries := make([]heatMapEntry, 0, len(h.contracts))
+	for addr, heat := range h.contracts {
+		entry := heatMapEntry{Address: addr, Hits: heat.hits, Slots: make([]common.Hash, 0, len(heat.slots))}
+		for slot := range heat.slots {
+			entry.Slots = append(entry.Slots, slot)
+		}
+		sort.Slice(entry.Slots, func(i, j int) bool {
+			a, b := heat.slots[entry.Slots[i]], heat.slots[entry.Slots[j]]
+			if a != b {
+				return a > b
+			}
+			return bytes.Compare(entry.Slots[i][:], entry.Slots[j][:]) < 0
+		})
+		if len(entry.Slots) > heatMapSlots {
+			entry.Slots = entry.Slots[:heatMapSlots]
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Hits != entries[j].Hits {
+			return entries[i].Hits > entries[j].Hits
+		}
+		return bytes.Compare(entries[i].Address[:], entries[j].Address[:]) < 0
+	})
+	if len(entries) > h.limit {
+		entries = entries[:h.limit]
+	}
+	return entries
+}
+
+// topSlots returns the hottest storage slots of a contract.
+func topSlots(slots map[common.Hash]uint64) map[common.Hash]uint64 {
+	keys := make([]common.Hash, 0, len(slots))
+	for slot := range slots {
+		keys = append(keys, slot)
+	}
+	sort.Slice(keys, func(i, j int) bool { return slots[keys[i]] > slots[keys[j]] })
+
+	top := make(map[common.Hash]uint64, heatMapSlots)
+	for _, slot := range keys[:heatMapSlots] {
+		top[slot] = slots[slot]
+	}
+	return top
+}
+
+// save writes the heat map to the given file.
+func (h *heatMap) save(path string) error {
+	h.lock.Lock()
+	entries := h.entries()
+	h.lock.Unlock()
+
+	blob, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, blob, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadHeatMap reads a heat map saved by a previous run. A missing file yields
+// an empty heat map.
+func loadHeatMap(path string, limit int) (*heatMap, error) {
+	h := newHeatMap(limit)
+	blob, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	var entries []heatMapEntry
+	if err := json.Unmarshal(blob, &entries); err != nil {
+		return h, err
+	}
+	for i, entry := range entries {
+		if i >= limit {
+			break
+		}
+		heat := &contractHeat{hits: entry.Hits, slots: make(map[common.Hash]uint64, len(entry.Slots))}
+		for j, slot := range entry.Slots {
+			heat.slots[slot] = uint64(len(entry.Slots) - j) // preserve the order
+		}
+		h.contracts[entry.Address] = heat
+	}
+	return h, nil
+}
+
+// warmCaches loads the recent headers and the hottest contracts' code and storage
+// into the caches, aborting if the chain is stopped.
+func (bc *BlockChain) warmCaches() {
+	defer bc.wg.Done()
+
+	var (
+		start   = time.Now()
+		head    = bc.CurrentBlock()
+		headers int
+		slots   int
+	)
+	for number := head.NumberU64(); headers < heatMapHeaders; number-- {
+		if bc.GetHeaderByNumber(number) == nil {
+			break
+		}
+		headers++
+		if number == 0 {
+			break
+		}
+	}
+	bc.heatMap.lock.Lock()
+	entries := bc.heatMap.entries()
+	bc.heatMap.lock.Unlock()
+
+	statedb, err := bc.StateAt(head.Root())
+	if err != nil {
+		log.Warn("Failed to warm up state caches", "err", err)
+		return
+	}
+	for i, entry := range entries {
+		select {
+		case <-bc.quit:
+			log.Debug("Aborted cache warmup", "contracts", i, "slots", slots)
+			return
+		default:
+		}
+		statedb.GetCode(entry.Address)
+		for _, slot := range entry.Slots {
+			statedb.GetState(entry.Address, slot)
+		}
+		slots += len(entry.Slots)
+	}
+	log.Info("Warmed up caches", "headers", headers, "contracts", len(entries), "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+}
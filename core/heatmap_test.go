@@ -0,0 +1,65 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Tests that the heat map keeps the hottest contracts and slots across a
+// save and load cycle.
+func TestHeatMapPersistence(t *testing.T) {
+	var (
+		hot  = common.Address{1}
+		warm = common.Address{2}
+		cold = common.Address{3}
+	)
+	h := newHeatMap(2)
+	for i := 0; i < 3; i++ {
+		h.record(map[common.Address][]common.Hash{hot: {{1}, {2}}, warm: {{3}}})
+	}
+	h.record(map[common.Address][]common.Hash{hot: {{2}}, cold: {{4}}})
+	h.record(map[common.Address][]common.Hash{hot: {{2}}, warm: nil})
+
+	// Only the hottest contracts are kept, hottest slots first.
+	want := []heatMapEntry{
+		{Address: hot, Hits: 5, Slots: []common.Hash{{2}, {1}}},
+		{Address: warm, Hits: 4, Slots: []common.Hash{{3}}},
+	}
+	if have := h.entries(); !reflect.DeepEqual(have, want) {
+		t.Fatalf("heat map mismatch:\nhave %+v\nwant %+v", have, want)
+	}
+	path := filepath.Join(t.TempDir(), "heatmap.json")
+	if err := h.save(path); err != nil {
+		t.Fatalf("failed to save heat map: %v", err)
+	}
+	loaded, err := loadHeatMap(path, 2)
+	if err != nil {
+		t.Fatalf("failed to load heat map: %v", err)
+	}
+	if have := loaded.entries(); !reflect.DeepEqual(have[0].Slots, want[0].Slots) || have[0].Address != hot || have[1].Address != warm {
+		t.Fatalf("loaded heat map mismatch:\nhave %+v\nwant %+v", have, want)
+	}
+	// A missing heat map is empty.
+	if missing, err := loadHeatMap(filepath.Join(t.TempDir(), "missing"), 2); err != nil || len(missing.entries()) != 0 {
+		t.Fatalf("missing heat map: have %d entries, err %v", len(missing.entries()), err)
+	}
+}
@@ -18,6 +18,7 @@ package rawdb
 
 import (
 	"bytes"
+	"encoding/binary"
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -175,3 +176,146 @@ func DeleteBloombits(db ethdb.Database, bit uint, from uint64, to uint64) {
 		log.Crit("Failed to delete bloom bits", "err", it.Error())
 	}
 }
+
+// ContractCreation is the transaction deploying a contract.
+type ContractCreation struct {
+	BlockHash   common.Hash
+	BlockNumber uint64
+	TxHash      common.Hash
+	TxIndex     uint64
+	CodeHash    common.Hash // zero for entries stored without the code hash
+}
+
+// ContractDestruction is the transaction self-destructing a contract.
+type ContractDestruction ContractCreation
+
+// contractCreationRLP is the stored form of a contract creation or destruction,
+// the block is part of the key.
+type contractCreationRLP struct {
+	TxHash   common.Hash
+	TxIndex  uint64
+	CodeHash common.Hash `rlp:"optional"`
+}
+
+// readContractEvents iterates the creations or destructions of a contract.
+func readContractEvents(db ethdb.Iteratee, prefix []byte, address common.Address) []ContractCreation {
+	prefix = append(append([]byte{}, prefix...), address.Bytes()...)
+	it := db.NewIterator(prefix, nil)
+	defer it.Release()
+
+	var events []ContractCreation
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(prefix)+8+common.HashLength {
+			continue
+		}
+		var stored contractCreationRLP
+		if err := rlp.DecodeBytes(it.Value(), &stored); err != nil {
+			log.Error("Invalid contract creation RLP", "address", address, "err", err)
+			continue
+		}
+		events = append(events, ContractCreation{
+			BlockHash:   common.BytesToHash(key[len(prefix)+8:]),
+			BlockNumber: binary.BigEndian.Uint64(key[len(prefix) : len(prefix)+8]),
+			TxHash:      stored.TxHash,
+			TxIndex:     stored.TxIndex,
+			CodeHash:    stored.CodeHash,
+		})
+	}
+	return events
+}
+
+// writeContractEvent stores a creation or destruction of a contract.
+func writeContractEvent(db ethdb.KeyValueWriter, key []byte, txHash common.Hash, txIndex uint64, codeHash common.Hash) {
+	data, err := rlp.EncodeToBytes(contractCreationRLP{TxHash: txHash, TxIndex: txIndex, CodeHash: codeHash})
+	if err != nil {
+		log.Crit("Failed to RLP encode contract creation", "err", err)
+	}
+	if err := db.Put(key, data); err != nil {
+		log.Crit("Failed to store contract creation", "err", err)
+	}
+}
+
+// ReadContractCreations retrieves all recorded deployments of a contract at the
+// given address, including those of non-canonical blocks, ordered by block number.
+func ReadContractCreations(db ethdb.Iteratee, address common.Address) []ContractCreation {
+	return readContractEvents(db, contractCreationPrefix, address)
+}
+
+// WriteContractCreation stores the transaction of a block deploying a contract
+// with the given code.
+func WriteContractCreation(db ethdb.KeyValueWriter, address common.Address, number uint64, hash common.Hash, txHash common.Hash, txIndex uint64, codeHash common.Hash) {
+	writeContractEvent(db, contractCreationKey(address, number, hash), txHash, txIndex, codeHash)
+}
+
+// ReadContractDestructions retrieves all recorded self-destructs of a contract at
+// the given address, including those of non-canonical blocks, ordered by block
+// number.
+func ReadContractDestructions(db ethdb.Iteratee, address common.Address) []ContractDestruction {
+	events := readContractEvents(db, contractDestructPrefix, address)
+	destructions := make([]ContractDestruction, len(events))
+	for i, event := range events {
+		destructions[i] = ContractDestruction(event)
+	}
+	return destructions
+}
+
+// WriteContractDestruction stores the transaction of a block self-destructing a
+// contract with the given code.
+func WriteContractDestruction(db ethdb.KeyValueWriter, address common.Address, number uint64, hash common.Hash, txHash common.Hash, txIndex uint64, codeHash common.Hash) {
+	writeContractEvent(db, contractDestructKey(address, number, hash), txHash, txIndex, codeHash)
+}
+
+// ReadStateDiff retrieves the state changes of a block, nil if the block wasn't
+// indexed.
+func ReadStateDiff(db ethdb.KeyValueReader, hash common.Hash, number uint64) []*types.AccountDiff {
+	data, _ := db.Get(stateDiffKey(number, hash))
+	if len(data) == 0 {
+		return nil
+	}
+	diff := make([]*types.AccountDiff, 0)
+	if err := rlp.DecodeBytes(data, &diff); err != nil {
+		log.Error("Invalid state diff RLP", "hash", hash, "err", err)
+		return nil
+	}
+	return diff
+}
+
+// WriteStateDiff stores the state changes of a block.
+func WriteStateDiff(db ethdb.KeyValueWriter, hash common.Hash, number uint64, diff []*types.AccountDiff) {
+	data, err := rlp.EncodeToBytes(diff)
+	if err != nil {
+		log.Crit("Failed to RLP encode state diff", "err", err)
+	}
+	if err := db.Put(stateDiffKey(number, hash), data); err != nil {
+		log.Crit("Failed to store state diff", "err", err)
+	}
+}
+
+// DeleteStateDiff removes the state changes of a block.
+func DeleteStateDiff(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
+	if err := db.Delete(stateDiffKey(number, hash)); err != nil {
+		log.Crit("Failed to delete state diff", "err", err)
+	}
+}
+
+// ReadWitness retrieves the RLP encoded execution witness of a block, nil if it
+// wasn't recorded.
+func ReadWitness(db ethdb.KeyValueReader, hash common.Hash, number uint64) []byte {
+	data, _ := db.Get(witnessKey(number, hash))
+	return data
+}
+
+// WriteWitness stores the RLP encoded execution witness of a block.
+func WriteWitness(db ethdb.KeyValueWriter, hash common.Hash, number uint64, witness []byte) {
+	if err := db.Put(witnessKey(number, hash), witness); err != nil {
+		log.Crit("Failed to store execution witness", "err", err)
+	}
+}
+
+// DeleteWitness removes the execution witness of a block.
+func DeleteWitness(db ethdb.KeyValueWriter, hash common.Hash, number uint64) {
+	if err := db.Delete(witnessKey(number, hash)); err != nil {
+		log.Crit("Failed to delete execution witness", "err", err)
+	}
+}
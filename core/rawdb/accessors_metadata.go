@@ -17,6 +17,7 @@
 package rawdb
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"time"
 
@@ -186,3 +187,51 @@ func WriteTransitionStatus(db ethdb.KeyValueWriter, data []byte) {
 		log.Crit("Failed to store the eth2 transition status", "err", err)
 	}
 }
+
+// ReadLastOnlinePrune retrieves the unix time the last online state pruning
+// finished, zero if it never did.
+func ReadLastOnlinePrune(db ethdb.KeyValueReader) uint64 {
+	data, _ := db.Get(lastOnlinePruneKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteLastOnlinePrune stores the unix time the last online state pruning
+// finished.
+func WriteLastOnlinePrune(db ethdb.KeyValueWriter, timestamp uint64) {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], timestamp)
+	if err := db.Put(lastOnlinePruneKey, buf[:]); err != nil {
+		log.Crit("Failed to store last online pruning time", "err", err)
+	}
+}
+
+// ReadFilterCheckpoints retrieves all persisted log filter checkpoints, keyed
+// by filter id.
+func ReadFilterCheckpoints(db ethdb.Iteratee) map[string][]byte {
+	it := db.NewIterator(filterCheckpointPrefix, nil)
+	defer it.Release()
+
+	checkpoints := make(map[string][]byte)
+	for it.Next() {
+		id := string(it.Key()[len(filterCheckpointPrefix):])
+		checkpoints[id] = common.CopyBytes(it.Value())
+	}
+	return checkpoints
+}
+
+// WriteFilterCheckpoint stores the checkpoint of a log filter.
+func WriteFilterCheckpoint(db ethdb.KeyValueWriter, id string, data []byte) {
+	if err := db.Put(filterCheckpointKey(id), data); err != nil {
+		log.Crit("Failed to store log filter checkpoint", "err", err)
+	}
+}
+
+// DeleteFilterCheckpoint removes the checkpoint of a log filter.
+func DeleteFilterCheckpoint(db ethdb.KeyValueWriter, id string) {
+	if err := db.Delete(filterCheckpointKey(id)); err != nil {
+		log.Crit("Failed to delete log filter checkpoint", "err", err)
+	}
+}
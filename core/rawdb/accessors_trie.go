@@ -0,0 +1,265 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// The list of state schemes a database can be maintained with.
+const (
+	// HashScheme stores the trie nodes keyed by their hash, keeping every
+	// version of them until garbage collected or pruned.
+	HashScheme = "hash"
+
+	// PathScheme stores the trie nodes keyed by their path in the trie, keeping
+	// a single version of the state on disk.
+	PathScheme = "path"
+)
+
+// ReadStateScheme reports the state scheme of the persistent state, or an empty
+// string for new databases without any state yet.
+func ReadStateScheme(db ethdb.KeyValueReader) string {
+	if blob, _ := db.Get(stateSchemeKey); len(blob) > 0 {
+		return string(blob)
+	}
+	// Databases without the scheme marker, check the layout of their state
+	if ok, _ := db.Has(accountTrieNodeKey(nil)); ok {
+		return PathScheme
+	}
+	if ReadHeadHeaderHash(db) != (common.Hash{}) {
+		return HashScheme
+	}
+	return ""
+}
+
+// WriteStateScheme stores the scheme the state of the database is stored with.
+func WriteStateScheme(db ethdb.KeyValueWriter, scheme string) {
+	if err := db.Put(stateSchemeKey, []byte(scheme)); err != nil {
+		log.Crit("Failed to store state scheme", "err", err)
+	}
+}
+
+// ParseStateScheme checks the state scheme requested by the user against the
+// one of the database, returning the scheme to use. If none is requested, the
+// scheme of the database is used, defaulting to the hash scheme for new ones.
+func ParseStateScheme(provided string, disk ethdb.KeyValueReader) (string, error) {
+	if provided != "" && provided != HashScheme && provided != PathScheme {
+		return "", fmt.Errorf("unknown state scheme %q", provided)
+	}
+	stored := ReadStateScheme(disk)
+	switch {
+	case provided == "" && stored == "":
+		return HashScheme, nil
+	case provided == "":
+		return stored, nil
+	case stored != "" && stored != provided:
+		return "", fmt.Errorf("incompatible state scheme, stored: %s, provided: %s", stored, provided)
+	}
+	return provided, nil
+}
+
+// ReadAccountTrieNode retrieves the account trie node stored at the given path.
+func ReadAccountTrieNode(db ethdb.KeyValueReader, path []byte) []byte {
+	data, _ := db.Get(accountTrieNodeKey(path))
+	return data
+}
+
+// WriteAccountTrieNode writes the account trie node at the given path.
+func WriteAccountTrieNode(db ethdb.KeyValueWriter, path []byte, node []byte) {
+	if err := db.Put(accountTrieNodeKey(path), node); err != nil {
+		log.Crit("Failed to store account trie node", "err", err)
+	}
+}
+
+// DeleteAccountTrieNode deletes the account trie node at the given path.
+func DeleteAccountTrieNode(db ethdb.KeyValueWriter, path []byte) {
+	if err := db.Delete(accountTrieNodeKey(path)); err != nil {
+		log.Crit("Failed to delete account trie node", "err", err)
+	}
+}
+
+// ReadStorageTrieNode retrieves the node stored at the given path of the storage
+// trie of an account.
+func ReadStorageTrieNode(db ethdb.KeyValueReader, accountHash common.Hash, path []byte) []byte {
+	data, _ := db.Get(storageTrieNodeKey(accountHash, path))
+	return data
+}
+
+// WriteStorageTrieNode writes the node at the given path of the storage trie of
+// an account.
+func WriteStorageTrieNode(db ethdb.KeyValueWriter, accountHash common.Hash, path []byte, node []byte) {
+	if err := db.Put(storageTrieNodeKey(accountHash, path), node); err != nil {
+		log.Crit("Failed to store storage trie node", "err", err)
+	}
+}
+
+// DeleteStorageTrieNode deletes the node at the given path of the storage trie
+// of an account.
+func DeleteStorageTrieNode(db ethdb.KeyValueWriter, accountHash common.Hash, path []byte) {
+	if err := db.Delete(storageTrieNodeKey(accountHash, path)); err != nil {
+		log.Crit("Failed to delete storage trie node", "err", err)
+	}
+}
+
+// ReadVerkleNode retrieves the verkle tree node with the given commitment.
+func ReadVerkleNode(db ethdb.KeyValueReader, commitment common.Hash) []byte {
+	data, _ := db.Get(verkleNodeKey(commitment))
+	return data
+}
+
+// HasVerkleNode checks if the verkle tree node with the given commitment is
+// present in the database.
+func HasVerkleNode(db ethdb.KeyValueReader, commitment common.Hash) bool {
+	ok, _ := db.Has(verkleNodeKey(commitment))
+	return ok
+}
+
+// WriteVerkleNode writes the verkle tree node with the given commitment.
+func WriteVerkleNode(db ethdb.KeyValueWriter, commitment common.Hash, node []byte) {
+	if err := db.Put(verkleNodeKey(commitment), node); err != nil {
+		log.Crit("Failed to store verkle node", "err", err)
+	}
+}
+
+// IsTrieNodePathKey reports whether the given key is the one of a trie node
+// stored by path.
+func IsTrieNodePathKey(key []byte) bool {
+	switch {
+	case len(key) > 0 && key[0] == trieNodeAccountPrefix[0]:
+		return len(key) <= len(trieNodeAccountPrefix)+2*common.HashLength
+	case len(key) > common.HashLength && key[0] == trieNodeStoragePrefix[0]:
+		return len(key) <= len(trieNodeStoragePrefix)+3*common.HashLength
+	}
+	return false
+}
+
+// ReadTrieJournal retrieves the serialized in-memory trie node layers saved at
+// the last shutdown.
+func ReadTrieJournal(db ethdb.KeyValueReader) []byte {
+	data, _ := db.Get(trieJournalKey)
+	return data
+}
+
+// WriteTrieJournal stores the serialized in-memory trie node layers to survive
+// restarts.
+func WriteTrieJournal(db ethdb.KeyValueWriter, journal []byte) {
+	if err := db.Put(trieJournalKey, journal); err != nil {
+		log.Crit("Failed to store trie journal", "err", err)
+	}
+}
+
+// DeleteTrieJournal deletes the serialized in-memory trie node layers.
+func DeleteTrieJournal(db ethdb.KeyValueWriter) {
+	if err := db.Delete(trieJournalKey); err != nil {
+		log.Crit("Failed to remove trie journal", "err", err)
+	}
+}
+
+// ReadTrieHistoryHead retrieves the id of the latest trie history, zero if none
+// was ever written.
+func ReadTrieHistoryHead(db ethdb.KeyValueReader) uint64 {
+	data, _ := db.Get(trieHistoryHeadKey)
+	if len(data) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(data)
+}
+
+// WriteTrieHistoryHead stores the id of the latest trie history.
+func WriteTrieHistoryHead(db ethdb.KeyValueWriter, id uint64) {
+	if err := db.Put(trieHistoryHeadKey, encodeBlockNumber(id)); err != nil {
+		log.Crit("Failed to store trie history head", "err", err)
+	}
+}
+
+// ReadTrieHistory retrieves the trie history with the given id.
+func ReadTrieHistory(db ethdb.KeyValueReader, id uint64) []byte {
+	data, _ := db.Get(trieHistoryKey(id))
+	return data
+}
+
+// WriteTrieHistory stores the trie history with the given id.
+func WriteTrieHistory(db ethdb.KeyValueWriter, id uint64, history []byte) {
+	if err := db.Put(trieHistoryKey(id), history); err != nil {
+		log.Crit("Failed to store trie history", "err", err)
+	}
+}
+
+// DeleteTrieHistory deletes the trie history with the given id.
+func DeleteTrieHistory(db ethdb.KeyValueWriter, id uint64) {
+	if err := db.Delete(trieHistoryKey(id)); err != nil {
+		log.Crit("Failed to delete trie history", "err", err)
+	}
+}
+
+// ReadTrieHistoryID retrieves the id of the trie history reverting the state
+// to the given root.
+func ReadTrieHistoryID(db ethdb.KeyValueReader, root common.Hash) (uint64, bool) {
+	data, _ := db.Get(trieHistoryRootKey(root))
+	if len(data) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(data), true
+}
+
+// WriteTrieHistoryID stores the id of the trie history reverting the state to
+// the given root.
+func WriteTrieHistoryID(db ethdb.KeyValueWriter, root common.Hash, id uint64) {
+	if err := db.Put(trieHistoryRootKey(root), encodeBlockNumber(id)); err != nil {
+		log.Crit("Failed to store trie history id", "err", err)
+	}
+}
+
+// DeleteTrieHistoryID deletes the trie history id of the given root.
+func DeleteTrieHistoryID(db ethdb.KeyValueWriter, root common.Hash) {
+	if err := db.Delete(trieHistoryRootKey(root)); err != nil {
+		log.Crit("Failed to delete trie history id", "err", err)
+	}
+}
+
+// DeletePathTrieNodes deletes all the trie nodes stored by path.
+func DeletePathTrieNodes(db ethdb.KeyValueStore) error {
+	batch := db.NewBatch()
+	for _, prefix := range [][]byte{trieNodeAccountPrefix, trieNodeStoragePrefix} {
+		it := db.NewIterator(prefix, nil)
+		for it.Next() {
+			if !IsTrieNodePathKey(it.Key()) {
+				continue
+			}
+			batch.Delete(it.Key())
+			if batch.ValueSize() >= ethdb.IdealBatchSize {
+				if err := batch.Write(); err != nil {
+					it.Release()
+					return err
+				}
+				batch.Reset()
+			}
+		}
+		err := it.Error()
+		it.Release()
+		if err != nil {
+			return err
+		}
+	}
+	return batch.Write()
+}
@@ -0,0 +1,72 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// Tests that the requested state scheme is checked against the one the database
+// was created with, detecting legacy databases without the marker.
+func TestParseStateScheme(t *testing.T) {
+	fresh := NewMemoryDatabase()
+
+	legacy := NewMemoryDatabase()
+	WriteHeadHeaderHash(legacy, common.Hash{0x1})
+
+	path := NewMemoryDatabase()
+	WriteAccountTrieNode(path, nil, []byte{0x1})
+
+	marked := NewMemoryDatabase()
+	WriteStateScheme(marked, PathScheme)
+
+	tests := []struct {
+		provided string
+		db       ethdb.KeyValueReader
+		want     string
+		fail     bool
+	}{
+		{"", fresh, HashScheme, false},
+		{HashScheme, fresh, HashScheme, false},
+		{PathScheme, fresh, PathScheme, false},
+		{"verkle", fresh, "", true},
+		{"", legacy, HashScheme, false},
+		{PathScheme, legacy, "", true},
+		{"", path, PathScheme, false},
+		{HashScheme, path, "", true},
+		{"", marked, PathScheme, false},
+		{PathScheme, marked, PathScheme, false},
+		{HashScheme, marked, "", true},
+	}
+	for i, tt := range tests {
+		have, err := ParseStateScheme(tt.provided, tt.db)
+		if tt.fail {
+			if err == nil {
+				t.Errorf("test %d: scheme %q accepted, database scheme %q", i, tt.provided, ReadStateScheme(tt.db))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: failed to parse scheme %q: %v", i, tt.provided, err)
+		} else if have != tt.want {
+			t.Errorf("test %d: scheme mismatch: have %q, want %q", i, have, tt.want)
+		}
+	}
+}
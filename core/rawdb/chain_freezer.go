@@ -68,6 +68,21 @@ func newChainFreezer(datadir string, namespace string, readonly bool, maxTableSi
 	}, nil
 }
 
+// newSharedChainFreezer opens a chain freezer read-only without locking it, see
+// NewSharedFreezer. The chain freezer never runs on a shared freezer.
+func newSharedChainFreezer(datadir string, namespace string, maxTableSize uint32, tables map[string]bool) (*chainFreezer, error) {
+	freezer, err := NewSharedFreezer(datadir, namespace, maxTableSize, tables)
+	if err != nil {
+		return nil, err
+	}
+	return &chainFreezer{
+		Freezer:   freezer,
+		threshold: params.FullImmutabilityThreshold,
+		quit:      make(chan struct{}),
+		trigger:   make(chan chan struct{}),
+	}, nil
+}
+
 // Close closes the chain freezer instance and terminates the background thread.
 func (f *chainFreezer) Close() error {
 	err := f.Freezer.Close()
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sync/atomic"
 	"time"
 
@@ -28,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/ethdb/leveldb"
 	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/ethdb/tiered"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/olekukonko/tablewriter"
 )
@@ -58,18 +60,22 @@ func (frdb *freezerdb) Close() error {
 // a freeze cycle completes, without having to sleep for a minute to trigger the
 // automatic background run.
 func (frdb *freezerdb) Freeze(threshold uint64) error {
-	if frdb.AncientStore.(*chainFreezer).readonly {
+	chain, ok := frdb.AncientStore.(*chainFreezer)
+	if remote, isRemote := frdb.AncientStore.(*remoteChainFreezer); isRemote {
+		chain, ok = remote.chainFreezer, true
+	}
+	if !ok || chain.readonly {
 		return errReadOnly
 	}
 	// Set the freezer threshold to a temporary value
 	defer func(old uint64) {
-		atomic.StoreUint64(&frdb.AncientStore.(*chainFreezer).threshold, old)
-	}(atomic.LoadUint64(&frdb.AncientStore.(*chainFreezer).threshold))
-	atomic.StoreUint64(&frdb.AncientStore.(*chainFreezer).threshold, threshold)
+		atomic.StoreUint64(&chain.threshold, old)
+	}(atomic.LoadUint64(&chain.threshold))
+	atomic.StoreUint64(&chain.threshold, threshold)
 
 	// Trigger a freeze cycle and block until it's done
 	trigger := make(chan struct{}, 1)
-	frdb.AncientStore.(*chainFreezer).trigger <- trigger
+	chain.trigger <- trigger
 	<-trigger
 	return nil
 }
@@ -171,6 +177,24 @@ func NewDatabaseWithFreezer(db ethdb.KeyValueStore, freezer string, namespace st
 	if err != nil {
 		return nil, err
 	}
+	return newDatabaseWithFreezer(db, frdb, frdb)
+}
+
+// NewDatabaseWithRemoteFreezer creates a high level database on top of a given
+// key-value data store with a freezer moving immutable chain segments into cold
+// storage, complemented by the ancient chain segments in a remote store.
+func NewDatabaseWithRemoteFreezer(db ethdb.KeyValueStore, freezer string, remote RemoteFreezerConfig, namespace string, readonly bool) (ethdb.Database, error) {
+	frdb, err := newRemoteChainFreezer(freezer, remote, namespace, readonly, freezerTableSize, FreezerNoSnappy)
+	if err != nil {
+		return nil, err
+	}
+	return newDatabaseWithFreezer(db, frdb.chainFreezer, frdb)
+}
+
+// newDatabaseWithFreezer validates the given ancient store against the key-value
+// data store and starts the chain freezer moving immutable chain segments into
+// it.
+func newDatabaseWithFreezer(db ethdb.KeyValueStore, chain *chainFreezer, frdb ethdb.AncientStore) (ethdb.Database, error) {
 	// Since the freezer can be stored separately from the user's key-value database,
 	// there's a fairly high probability that the user requests invalid combinations
 	// of the freezer and database. Ensure that we don't shoot ourselves in the foot
@@ -236,11 +260,11 @@ func NewDatabaseWithFreezer(db ethdb.KeyValueStore, freezer string, namespace st
 		}
 	}
 	// Freezer is consistent with the key-value database, permit combining the two
-	if !frdb.readonly {
-		frdb.wg.Add(1)
+	if !chain.readonly {
+		chain.wg.Add(1)
 		go func() {
-			frdb.freeze(db)
-			frdb.wg.Done()
+			chain.freeze(db)
+			chain.wg.Done()
 		}()
 	}
 	return &freezerdb{
@@ -287,6 +311,61 @@ func NewLevelDBDatabaseWithFreezer(file string, cache int, handles int, freezer
 	return frdb, nil
 }
 
+// NewLevelDBDatabaseWithRemoteFreezer creates a persistent key-value database
+// with a freezer moving immutable chain segments into cold storage, complemented
+// by the ancient chain segments in a remote store.
+func NewLevelDBDatabaseWithRemoteFreezer(file string, cache int, handles int, freezer string, remote RemoteFreezerConfig, namespace string, readonly bool) (ethdb.Database, error) {
+	kvdb, err := leveldb.New(file, cache, handles, namespace, readonly)
+	if err != nil {
+		return nil, err
+	}
+	frdb, err := NewDatabaseWithRemoteFreezer(kvdb, freezer, remote, namespace, readonly)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
+const (
+	// tieredMigrateInterval is the interval between the sweeps of a tiered
+	// database for state to migrate to cold storage.
+	tieredMigrateInterval = time.Minute
+
+	// tieredSweepLimit is the number of entries visited per sweep, so that a
+	// pass over the hot database is spread over many sweeps.
+	tieredSweepLimit = 100000
+)
+
+// NewTieredLevelDBDatabaseWithFreezer creates a persistent key-value database
+// with a freezer, migrating the trie nodes and snapshot entries untouched for
+// the given age to a cold database on slower storage.
+func NewTieredLevelDBDatabaseWithFreezer(file string, cold string, age time.Duration, cache int, handles int, freezer string, namespace string, readonly bool) (ethdb.Database, error) {
+	hotdb, err := leveldb.New(file, cache, handles, namespace, readonly)
+	if err != nil {
+		return nil, err
+	}
+	colddb, err := leveldb.New(cold, 0, 0, namespace+"cold/", readonly)
+	if err != nil {
+		hotdb.Close()
+		return nil, err
+	}
+	kvdb := tiered.New(hotdb, colddb, tiered.Config{
+		Age:        age,
+		Interval:   tieredMigrateInterval,
+		SweepLimit: tieredSweepLimit,
+		Tiered:     isTieredStateKey,
+		ReadOnly:   readonly,
+	}, namespace)
+
+	frdb, err := NewDatabaseWithFreezer(kvdb, freezer, namespace, readonly)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	return frdb, nil
+}
+
 type counter uint64
 
 func (c counter) String() string {
@@ -317,9 +396,52 @@ func (s *stat) Count() string {
 	return s.count.String()
 }
 
+// DatabaseStat is the size and the number of entries of a category of data.
+type DatabaseStat struct {
+	Database string `json:"database"`
+	Category string `json:"category"`
+	Size     uint64 `json:"size"`
+	Count    uint64 `json:"count"`
+}
+
+// DatabaseStats is the breakdown of the database content into categories.
+type DatabaseStats struct {
+	Stats       []DatabaseStat `json:"stats"`
+	Total       uint64         `json:"total"`
+	Unaccounted DatabaseStat   `json:"unaccounted"`
+}
+
+// errInspectionAborted is returned if a database inspection is interrupted.
+var errInspectionAborted = errors.New("database inspection aborted")
+
 // InspectDatabase traverses the entire database and checks the size
 // of all different categories of data.
 func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
+	stats, err := CollectDatabaseStats(db, keyPrefix, keyStart, nil)
+	if err != nil {
+		return err
+	}
+	// Display the database statistic.
+	rows := make([][]string, 0, len(stats.Stats))
+	for _, stat := range stats.Stats {
+		rows = append(rows, []string{stat.Database, stat.Category, common.StorageSize(stat.Size).String(), counter(stat.Count).String()})
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"Database", "Category", "Size", "Items"})
+	table.SetFooter([]string{"", "Total", common.StorageSize(stats.Total).String(), " "})
+	table.AppendBulk(rows)
+	table.Render()
+
+	if stats.Unaccounted.Size > 0 {
+		log.Error("Database contains unaccounted data", "size", common.StorageSize(stats.Unaccounted.Size), "count", stats.Unaccounted.Count)
+	}
+	return nil
+}
+
+// CollectDatabaseStats traverses the entire database and gathers the size of
+// all different categories of data. The traversal is aborted if the interrupt
+// channel is closed.
+func CollectDatabaseStats(db ethdb.Database, keyPrefix, keyStart []byte, interrupt <-chan struct{}) (*DatabaseStats, error) {
 	it := db.NewIterator(keyPrefix, keyStart)
 	defer it.Release()
 
@@ -336,8 +458,13 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		numHashPairings stat
 		hashNumPairings stat
 		tries           stat
+		verkleNodes     stat
 		codes           stat
 		txLookups       stat
+		creations       stat
+		destructions    stat
+		stateDiffs      stat
+		witnesses       stat
 		accountSnaps    stat
 		storageSnaps    stat
 		preimages       stat
@@ -387,8 +514,18 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			tries.Add(size)
 		case bytes.HasPrefix(key, CodePrefix) && len(key) == len(CodePrefix)+common.HashLength:
 			codes.Add(size)
+		case bytes.HasPrefix(key, verkleNodePrefix) && len(key) == len(verkleNodePrefix)+common.HashLength:
+			verkleNodes.Add(size)
 		case bytes.HasPrefix(key, txLookupPrefix) && len(key) == (len(txLookupPrefix)+common.HashLength):
 			txLookups.Add(size)
+		case bytes.HasPrefix(key, contractCreationPrefix) && len(key) == (len(contractCreationPrefix)+common.AddressLength+8+common.HashLength):
+			creations.Add(size)
+		case bytes.HasPrefix(key, contractDestructPrefix) && len(key) == (len(contractDestructPrefix)+common.AddressLength+8+common.HashLength):
+			destructions.Add(size)
+		case bytes.HasPrefix(key, stateDiffPrefix) && len(key) == (len(stateDiffPrefix)+8+common.HashLength):
+			stateDiffs.Add(size)
+		case bytes.HasPrefix(key, witnessPrefix) && len(key) == (len(witnessPrefix)+8+common.HashLength):
+			witnesses.Add(size)
 		case bytes.HasPrefix(key, SnapshotAccountPrefix) && len(key) == (len(SnapshotAccountPrefix)+common.HashLength):
 			accountSnaps.Add(size)
 		case bytes.HasPrefix(key, SnapshotStoragePrefix) && len(key) == (len(SnapshotStoragePrefix)+2*common.HashLength):
@@ -399,6 +536,8 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			metadata.Add(size)
 		case bytes.HasPrefix(key, genesisPrefix) && len(key) == (len(genesisPrefix)+common.HashLength):
 			metadata.Add(size)
+		case bytes.HasPrefix(key, filterCheckpointPrefix):
+			metadata.Add(size)
 		case bytes.HasPrefix(key, bloomBitsPrefix) && len(key) == (len(bloomBitsPrefix)+10+common.HashLength):
 			bloomBits.Add(size)
 		case bytes.HasPrefix(key, BloomBitsIndexPrefix):
@@ -420,8 +559,9 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			for _, meta := range [][]byte{
 				databaseVersionKey, headHeaderKey, headBlockKey, headFastBlockKey, headFinalizedBlockKey,
 				lastPivotKey, fastTrieProgressKey, snapshotDisabledKey, SnapshotRootKey, snapshotJournalKey,
-				snapshotGeneratorKey, snapshotRecoveryKey, txIndexTailKey, fastTxLookupLimitKey,
+				snapshotGeneratorKey, snapshotRecoveryKey, txIndexTailKey, logIndexTailKey, fastTxLookupLimitKey,
 				uncleanShutdownKey, badBlockKey, transitionStatusKey, skeletonSyncStatusKey,
+				lastOnlinePruneKey,
 			} {
 				if bytes.Equal(key, meta) {
 					metadata.Add(size)
@@ -434,11 +574,21 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 			}
 		}
 		count++
-		if count%1000 == 0 && time.Since(logged) > 8*time.Second {
-			log.Info("Inspecting database", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
-			logged = time.Now()
+		if count%1000 == 0 {
+			select {
+			case <-interrupt:
+				return nil, errInspectionAborted
+			default:
+			}
+			if time.Since(logged) > 8*time.Second {
+				log.Info("Inspecting database", "count", count, "elapsed", common.PrettyDuration(time.Since(start)))
+				logged = time.Now()
+			}
 		}
 	}
+	if err := it.Error(); err != nil {
+		return nil, err
+	}
 	// Inspect append-only file store then.
 	ancientSizes := []*common.StorageSize{&ancientHeadersSize, &ancientBodiesSize, &ancientReceiptsSize, &ancientHashesSize, &ancientTdsSize}
 	for i, category := range []string{freezerHeaderTable, freezerBodiesTable, freezerReceiptTable, freezerHashTable, freezerDifficultyTable} {
@@ -448,45 +598,83 @@ func InspectDatabase(db ethdb.Database, keyPrefix, keyStart []byte) error {
 		}
 	}
 	// Get number of ancient rows inside the freezer
-	ancients := counter(0)
+	var ancients uint64
 	if count, err := db.Ancients(); err == nil {
-		ancients = counter(count)
+		ancients = count
 	}
-	// Display the database statistic.
-	stats := [][]string{
-		{"Key-Value store", "Headers", headers.Size(), headers.Count()},
-		{"Key-Value store", "Bodies", bodies.Size(), bodies.Count()},
-		{"Key-Value store", "Receipt lists", receipts.Size(), receipts.Count()},
-		{"Key-Value store", "Difficulties", tds.Size(), tds.Count()},
-		{"Key-Value store", "Block number->hash", numHashPairings.Size(), numHashPairings.Count()},
-		{"Key-Value store", "Block hash->number", hashNumPairings.Size(), hashNumPairings.Count()},
-		{"Key-Value store", "Transaction index", txLookups.Size(), txLookups.Count()},
-		{"Key-Value store", "Bloombit index", bloomBits.Size(), bloomBits.Count()},
-		{"Key-Value store", "Contract codes", codes.Size(), codes.Count()},
-		{"Key-Value store", "Trie nodes", tries.Size(), tries.Count()},
-		{"Key-Value store", "Trie preimages", preimages.Size(), preimages.Count()},
-		{"Key-Value store", "Account snapshot", accountSnaps.Size(), accountSnaps.Count()},
-		{"Key-Value store", "Storage snapshot", storageSnaps.Size(), storageSnaps.Count()},
-		{"Key-Value store", "Beacon sync headers", beaconHeaders.Size(), beaconHeaders.Count()},
-		{"Key-Value store", "Clique snapshots", cliqueSnaps.Size(), cliqueSnaps.Count()},
-		{"Key-Value store", "Singleton metadata", metadata.Size(), metadata.Count()},
-		{"Ancient store", "Headers", ancientHeadersSize.String(), ancients.String()},
-		{"Ancient store", "Bodies", ancientBodiesSize.String(), ancients.String()},
-		{"Ancient store", "Receipt lists", ancientReceiptsSize.String(), ancients.String()},
-		{"Ancient store", "Difficulties", ancientTdsSize.String(), ancients.String()},
-		{"Ancient store", "Block number->hash", ancientHashesSize.String(), ancients.String()},
-		{"Light client", "CHT trie nodes", chtTrieNodes.Size(), chtTrieNodes.Count()},
-		{"Light client", "Bloom trie nodes", bloomTrieNodes.Size(), bloomTrieNodes.Count()},
+	kv := func(category string, s stat) DatabaseStat {
+		return DatabaseStat{"Key-Value store", category, uint64(s.size), uint64(s.count)}
 	}
-	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"Database", "Category", "Size", "Items"})
-	table.SetFooter([]string{"", "Total", total.String(), " "})
-	table.AppendBulk(stats)
-	table.Render()
-
-	if unaccounted.size > 0 {
-		log.Error("Database contains unaccounted data", "size", unaccounted.size, "count", unaccounted.count)
+	ancient := func(category string, size common.StorageSize) DatabaseStat {
+		return DatabaseStat{"Ancient store", category, uint64(size), ancients}
 	}
+	light := func(category string, s stat) DatabaseStat {
+		return DatabaseStat{"Light client", category, uint64(s.size), uint64(s.count)}
+	}
+	return &DatabaseStats{
+		Stats: []DatabaseStat{
+			kv("Headers", headers),
+			kv("Bodies", bodies),
+			kv("Receipt lists", receipts),
+			kv("Difficulties", tds),
+			kv("Block number->hash", numHashPairings),
+			kv("Block hash->number", hashNumPairings),
+			kv("Transaction index", txLookups),
+			kv("Contract creations", creations),
+			kv("Contract destructions", destructions),
+			kv("State diffs", stateDiffs),
+			kv("Execution witnesses", witnesses),
+			kv("Bloombit index", bloomBits),
+			kv("Contract codes", codes),
+			kv("Trie nodes", tries),
+			kv("Verkle nodes", verkleNodes),
+			kv("Trie preimages", preimages),
+			kv("Account snapshot", accountSnaps),
+			kv("Storage snapshot", storageSnaps),
+			kv("Beacon sync headers", beaconHeaders),
+			kv("Clique snapshots", cliqueSnaps),
+			kv("Singleton metadata", metadata),
+			ancient("Headers", ancientHeadersSize),
+			ancient("Bodies", ancientBodiesSize),
+			ancient("Receipt lists", ancientReceiptsSize),
+			ancient("Difficulties", ancientTdsSize),
+			ancient("Block number->hash", ancientHashesSize),
+			light("CHT trie nodes", chtTrieNodes),
+			light("Bloom trie nodes", bloomTrieNodes),
+		},
+		Total:       uint64(total),
+		Unaccounted: kv("Unaccounted", unaccounted),
+	}, nil
+}
 
-	return nil
+// AncientStat is the state of an ancient table.
+type AncientStat struct {
+	Table string `json:"table"`
+	Items uint64 `json:"items"` // Number of items, the deleted tail included
+	Tail  uint64 `json:"tail"`  // Number of the first item available
+	Size  uint64 `json:"size"`
+	Files int    `json:"files"` // Number of data files of the local table
+}
+
+// ReadAncientStats retrieves the state of the chain ancient tables, without any
+// traversal of the data.
+func ReadAncientStats(db ethdb.Database) []AncientStat {
+	var (
+		items, _ = db.Ancients()
+		tail, _  = db.Tail()
+		dir, _   = db.AncientDatadir()
+		stats    []AncientStat
+	)
+	for _, table := range []string{freezerHeaderTable, freezerBodiesTable, freezerReceiptTable, freezerHashTable, freezerDifficultyTable} {
+		stat := AncientStat{Table: table, Items: items, Tail: tail}
+		if size, err := db.AncientSize(table); err == nil {
+			stat.Size = size
+		}
+		if dir != "" {
+			files, _ := filepath.Glob(filepath.Join(dir, table+".*.?dat"))
+			stat.Files = len(files)
+		}
+		stats = append(stats, stat)
+	}
+	return stats
 }
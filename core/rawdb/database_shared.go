@@ -0,0 +1,375 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/leveldb"
+)
+
+// SharedDatabase is a read-only view of a database with a freezer owned by
+// another process, which keeps writing to it. The view is a snapshot of the last
+// state flushed by the writer, moved forward by Refresh.
+//
+// The writes into the key-value store are silently discarded: the node sharing
+// the database rewrites markers on startup and maintains indexes in the
+// background, all of which are the business of the writer. Appending to the
+// freezer fails, as the callers rely on the appended data.
+type SharedDatabase struct {
+	file      string
+	freezer   string
+	namespace string
+	cache     int
+	handles   int
+
+	db     *sharedSnapshot // Current snapshot of the database
+	closed bool            // Whether the database was closed
+	lock   sync.RWMutex
+}
+
+// sharedSnapshot is a snapshot of the shared database, closed once it is
+// replaced and the last operation, iterator or snapshot reading it is done.
+type sharedSnapshot struct {
+	ethdb.Database
+	refs int64 // Pending readers, plus one while the snapshot is current
+}
+
+// NewSharedLevelDBDatabaseWithFreezer opens a persistent key-value database
+// with a freezer read-only, without locking them, while another process may be
+// writing to them.
+func NewSharedLevelDBDatabaseWithFreezer(file string, cache int, handles int, freezer string, namespace string) (*SharedDatabase, error) {
+	db := &SharedDatabase{
+		file:      file,
+		freezer:   freezer,
+		namespace: namespace,
+		cache:     cache,
+		handles:   handles,
+	}
+	snapshot, err := db.open()
+	if err != nil {
+		return nil, err
+	}
+	db.db = snapshot
+	return db, nil
+}
+
+// open opens a snapshot of the shared database.
+func (db *SharedDatabase) open() (*sharedSnapshot, error) {
+	// Open the key-value store first: the writer only deletes the chain segments
+	// from it after moving them into the freezer, so a freezer opened later is
+	// guaranteed to continue where the key-value store ends.
+	kvdb, err := leveldb.NewShared(db.file, db.cache, db.handles, db.namespace)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := newSharedChainFreezer(db.freezer, db.namespace, freezerTableSize, FreezerNoSnappy)
+	if err != nil {
+		kvdb.Close()
+		return nil, err
+	}
+	frdb, err := newDatabaseWithFreezer(kvdb, chain, chain)
+	if err != nil {
+		chain.Close()
+		kvdb.Close()
+		return nil, err
+	}
+	return &sharedSnapshot{Database: frdb, refs: 1}, nil
+}
+
+// Refresh replaces the snapshot of the database with a fresh one, following the
+// writer. The replaced snapshot remains open until the reads in progress on it,
+// such as iterations, are done.
+func (db *SharedDatabase) Refresh() error {
+	snapshot, err := db.open()
+	if err != nil {
+		return err
+	}
+	db.lock.Lock()
+	if db.closed {
+		db.lock.Unlock()
+		snapshot.Close()
+		return errors.New("database closed")
+	}
+	stale := db.db
+	db.db = snapshot
+	db.lock.Unlock()
+
+	return stale.release()
+}
+
+// acquire returns the current snapshot of the database, which must be released
+// once done reading it.
+func (db *SharedDatabase) acquire() *sharedSnapshot {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	atomic.AddInt64(&db.db.refs, 1)
+	return db.db
+}
+
+// release drops a reference to the snapshot, closing it if it was the last one.
+func (s *sharedSnapshot) release() error {
+	if atomic.AddInt64(&s.refs, -1) == 0 {
+		return s.Close()
+	}
+	return nil
+}
+
+// Has retrieves if a key is present in the key-value data store.
+func (db *SharedDatabase) Has(key []byte) (bool, error) {
+	snapshot := db.acquire()
+	defer snapshot.release()
+
+	return snapshot.Has(key)
+}
+
+// Get retrieves the given key if it's present in the key-value data store.
+func (db *SharedDatabase) Get(key []byte) ([]byte, error) {
+	snapshot := db.acquire()
+	defer snapshot.release()
+
+	return snapshot.Get(key)
+}
+
+// Put discards the insertion into the key-value data store.
+func (db *SharedDatabase) Put(key []byte, value []byte) error {
+	return nil
+}
+
+// Delete discards the removal from the key-value data store.
+func (db *SharedDatabase) Delete(key []byte) error {
+	return nil
+}
+
+// NewBatch creates a write-only batch, discarding its writes.
+func (db *SharedDatabase) NewBatch() ethdb.Batch {
+	return new(discardBatch)
+}
+
+// NewBatchWithSize creates a write-only batch, discarding its writes.
+func (db *SharedDatabase) NewBatchWithSize(size int) ethdb.Batch {
+	return new(discardBatch)
+}
+
+// NewIterator creates a binary-alphabetical iterator over a subset of database
+// content with a particular key prefix, starting at a particular initial key.
+func (db *SharedDatabase) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	snapshot := db.acquire()
+	return &sharedIterator{Iterator: snapshot.NewIterator(prefix, start), snapshot: snapshot}
+}
+
+// NewSnapshot creates a database snapshot based on the current state.
+func (db *SharedDatabase) NewSnapshot() (ethdb.Snapshot, error) {
+	snapshot := db.acquire()
+	snap, err := snapshot.NewSnapshot()
+	if err != nil {
+		snapshot.release()
+		return nil, err
+	}
+	return &pinnedSnapshot{Snapshot: snap, snapshot: snapshot}, nil
+}
+
+// Stat returns a particular internal stat of the database.
+func (db *SharedDatabase) Stat(property string) (string, error) {
+	snapshot := db.acquire()
+	defer snapshot.release()
+
+	return snapshot.Stat(property)
+}
+
+// Compact is a no-op, the database being compacted by its writer.
+func (db *SharedDatabase) Compact(start []byte, limit []byte) error {
+	return nil
+}
+
+// HasAncient returns an indicator whether the specified data exists in the
+// ancient store.
+func (db *SharedDatabase) HasAncient(kind string, number uint64) (bool, error) {
+	snapshot := db.acquire()
+	defer snapshot.release()
+
+	return snapshot.HasAncient(kind, number)
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (db *SharedDatabase) Ancient(kind string, number uint64) ([]byte, error) {
+	snapshot := db.acquire()
+	defer snapshot.release()
+
+	return snapshot.Ancient(kind, number)
+}
+
+// AncientRange retrieves multiple items in sequence, starting from the index
+// 'start'.
+func (db *SharedDatabase) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	snapshot := db.acquire()
+	defer snapshot.release()
+
+	return snapshot.AncientRange(kind, start, count, maxBytes)
+}
+
+// Ancients returns the ancient item numbers in the ancient store.
+func (db *SharedDatabase) Ancients() (uint64, error) {
+	snapshot := db.acquire()
+	defer snapshot.release()
+
+	return snapshot.Ancients()
+}
+
+// Tail returns the number of first stored item in the freezer.
+func (db *SharedDatabase) Tail() (uint64, error) {
+	snapshot := db.acquire()
+	defer snapshot.release()
+
+	return snapshot.Tail()
+}
+
+// AncientSize returns the ancient size of the specified category.
+func (db *SharedDatabase) AncientSize(kind string) (uint64, error) {
+	snapshot := db.acquire()
+	defer snapshot.release()
+
+	return snapshot.AncientSize(kind)
+}
+
+// ReadAncients runs the given read operation on a consistent snapshot of the
+// ancient store.
+func (db *SharedDatabase) ReadAncients(fn func(ethdb.AncientReaderOp) error) error {
+	snapshot := db.acquire()
+	defer snapshot.release()
+
+	return snapshot.ReadAncients(fn)
+}
+
+// ModifyAncients refuses to append to the ancient store.
+func (db *SharedDatabase) ModifyAncients(fn func(ethdb.AncientWriteOp) error) (int64, error) {
+	return 0, errReadOnly
+}
+
+// TruncateHead discards the truncation of the ancient store.
+func (db *SharedDatabase) TruncateHead(items uint64) error {
+	return nil
+}
+
+// TruncateTail discards the truncation of the ancient store.
+func (db *SharedDatabase) TruncateTail(items uint64) error {
+	return nil
+}
+
+// Sync is a no-op, there being nothing to flush.
+func (db *SharedDatabase) Sync() error {
+	return nil
+}
+
+// MigrateTable refuses to migrate the ancient store.
+func (db *SharedDatabase) MigrateTable(kind string, convert func([]byte) ([]byte, error)) error {
+	return errReadOnly
+}
+
+// AncientDatadir returns the root directory path of the ancient store.
+func (db *SharedDatabase) AncientDatadir() (string, error) {
+	snapshot := db.acquire()
+	defer snapshot.release()
+
+	return snapshot.AncientDatadir()
+}
+
+// Close closes the database. The snapshots still being read are closed once the
+// reads are done.
+func (db *SharedDatabase) Close() error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if db.closed {
+		return nil
+	}
+	db.closed = true
+	return db.db.release()
+}
+
+// sharedIterator is an iterator keeping the snapshot it reads open until it is
+// released.
+type sharedIterator struct {
+	ethdb.Iterator
+	snapshot *sharedSnapshot
+	released bool
+}
+
+// Release releases the iterator and its reference to the snapshot.
+func (it *sharedIterator) Release() {
+	it.Iterator.Release()
+	if !it.released {
+		it.released = true
+		it.snapshot.release()
+	}
+}
+
+// pinnedSnapshot is a database snapshot keeping the shared snapshot it was
+// taken from open until it is released.
+type pinnedSnapshot struct {
+	ethdb.Snapshot
+	snapshot *sharedSnapshot
+	once     sync.Once
+}
+
+// Release releases the snapshot and its reference to the shared snapshot.
+func (snap *pinnedSnapshot) Release() {
+	snap.Snapshot.Release()
+	snap.once.Do(func() { snap.snapshot.release() })
+}
+
+// discardBatch is a write-only batch discarding its writes, only tracking their
+// size for the flushing logic of the callers.
+type discardBatch struct {
+	size int
+}
+
+// Put discards the insertion.
+func (b *discardBatch) Put(key, value []byte) error {
+	b.size += len(key) + len(value)
+	return nil
+}
+
+// Delete discards the removal.
+func (b *discardBatch) Delete(key []byte) error {
+	b.size += len(key)
+	return nil
+}
+
+// ValueSize retrieves the amount of data queued up for writing.
+func (b *discardBatch) ValueSize() int {
+	return b.size
+}
+
+// Write is a no-op, the queued data being discarded.
+func (b *discardBatch) Write() error {
+	return nil
+}
+
+// Reset resets the batch for reuse.
+func (b *discardBatch) Reset() {
+	b.size = 0
+}
+
+// Replay is a no-op, the queued data being discarded.
+func (b *discardBatch) Replay(w ethdb.KeyValueWriter) error {
+	return nil
+}
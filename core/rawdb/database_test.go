@@ -15,3 +15,156 @@
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
 package rawdb
+
+import (
+	"math/big"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestTieredStateKeys(t *testing.T) {
+	hash := common.Hash{0x01}
+	tests := []struct {
+		key    []byte
+		tiered bool
+	}{
+		{hash.Bytes(), true},
+		{accountSnapshotKey(hash), true},
+		{storageSnapshotKey(hash, hash), true},
+		{storageSnapshotsKey(hash), false},
+		{codeKey(hash), false},
+		{headerKey(1, hash), false},
+		{headHeaderKey, false},
+	}
+	for i, tt := range tests {
+		if have := isTieredStateKey(tt.key); have != tt.tiered {
+			t.Errorf("test %d: tiered mismatch for %x: have %v, want %v", i, tt.key, have, tt.tiered)
+		}
+	}
+}
+
+// Tests that the database statistics account the entries into their categories
+// and that the traversal can be interrupted.
+func TestCollectDatabaseStats(t *testing.T) {
+	db := NewMemoryDatabase()
+	for i := uint64(0); i < 2000; i++ {
+		header := &types.Header{Number: new(big.Int).SetUint64(i), Extra: []byte("test")}
+		WriteHeader(db, header)
+	}
+	db.Put([]byte("unaccounted"), []byte{0x01})
+
+	stats, err := CollectDatabaseStats(db, nil, nil, nil)
+	if err != nil {
+		t.Fatal("inspection failed:", err)
+	}
+	for _, stat := range stats.Stats {
+		if stat.Database == "Key-Value store" && stat.Category == "Headers" {
+			if stat.Count != 2000 {
+				t.Fatalf("header count mismatch: have %d, want 2000", stat.Count)
+			}
+		}
+	}
+	if stats.Unaccounted.Count != 1 {
+		t.Fatalf("unaccounted count mismatch: have %d, want 1", stats.Unaccounted.Count)
+	}
+	interrupt := make(chan struct{})
+	close(interrupt)
+	if _, err := CollectDatabaseStats(db, nil, nil, interrupt); err != errInspectionAborted {
+		t.Fatalf("interrupted inspection error mismatch: have %v, want %v", err, errInspectionAborted)
+	}
+}
+
+// Tests that a database can be shared while its owner keeps it open, following
+// the owner on refresh and discarding its own writes.
+func TestSharedDatabase(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewLevelDBDatabaseWithFreezer(dir, 0, 0, filepath.Join(dir, "ancient"), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	WriteCanonicalHash(db, common.Hash{0x01}, 1)
+
+	shared, err := NewSharedLevelDBDatabaseWithFreezer(dir, 0, 0, filepath.Join(dir, "ancient"), "")
+	if err != nil {
+		t.Fatalf("failed to open shared database: %v", err)
+	}
+	defer shared.Close()
+
+	if hash := ReadCanonicalHash(shared, 1); hash != (common.Hash{0x01}) {
+		t.Fatalf("canonical hash mismatch: have %x, want %x", hash, common.Hash{0x01})
+	}
+	WriteCanonicalHash(shared, common.Hash{0x03}, 3)
+	if hash := ReadCanonicalHash(shared, 3); hash != (common.Hash{}) {
+		t.Fatalf("shared database kept a write: %x", hash)
+	}
+	// Write into the owned database, a refresh should pick it up
+	WriteCanonicalHash(db, common.Hash{0x02}, 2)
+	if hash := ReadCanonicalHash(shared, 2); hash != (common.Hash{}) {
+		t.Fatalf("shared database snapshot changed: %x", hash)
+	}
+	if err := shared.Refresh(); err != nil {
+		t.Fatalf("failed to refresh shared database: %v", err)
+	}
+	if hash := ReadCanonicalHash(shared, 2); hash != (common.Hash{0x02}) {
+		t.Fatalf("refreshed canonical hash mismatch: have %x, want %x", hash, common.Hash{0x02})
+	}
+	if hash := ReadCanonicalHash(db, 3); hash != (common.Hash{}) {
+		t.Fatalf("shared database wrote into the owned one: %x", hash)
+	}
+}
+
+// Tests that the snapshots replaced by refreshes stay open while they are still
+// being read, and are closed once the reads are done.
+func TestSharedDatabaseRefreshInUse(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewLevelDBDatabaseWithFreezer(dir, 0, 0, filepath.Join(dir, "ancient"), "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for i := uint64(0); i < 3; i++ {
+		WriteCanonicalHash(db, common.Hash{byte(i + 1)}, i)
+	}
+	shared, err := NewSharedLevelDBDatabaseWithFreezer(dir, 0, 0, filepath.Join(dir, "ancient"), "")
+	if err != nil {
+		t.Fatalf("failed to open shared database: %v", err)
+	}
+	defer shared.Close()
+
+	first := shared.db
+	it := shared.NewIterator(headerPrefix, nil)
+
+	// Refresh past the snapshot the iterator reads from
+	for i := 0; i < 2; i++ {
+		if err := shared.Refresh(); err != nil {
+			t.Fatalf("failed to refresh shared database: %v", err)
+		}
+	}
+	var count int
+	for it.Next() {
+		count++
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("iteration failed after refresh: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("iterated entry count mismatch: have %d, want %d", count, 3)
+	}
+	if refs := atomic.LoadInt64(&first.refs); refs != 1 {
+		t.Fatalf("replaced snapshot reference count mismatch: have %d, want %d", refs, 1)
+	}
+	it.Release()
+	if refs := atomic.LoadInt64(&first.refs); refs != 0 {
+		t.Fatalf("replaced snapshot not released: %d references left", refs)
+	}
+	if _, err := first.Get(headerHashKey(0)); err == nil {
+		t.Fatalf("replaced snapshot still open")
+	}
+}
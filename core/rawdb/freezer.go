@@ -87,11 +87,23 @@ type Freezer struct {
 // The 'tables' argument defines the data tables. If the value of a map
 // entry is true, snappy compression is disabled for the table.
 func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool) (*Freezer, error) {
+	return newFreezer(datadir, namespace, readonly, false, maxTableSize, tables)
+}
+
+// NewSharedFreezer opens a freezer read-only without locking it, while another
+// process may be appending to it. The freezer is a snapshot of the items fully
+// written to all the tables when opened.
+func NewSharedFreezer(datadir string, namespace string, maxTableSize uint32, tables map[string]bool) (*Freezer, error) {
+	return newFreezer(datadir, namespace, true, true, maxTableSize, tables)
+}
+
+// newFreezer creates a freezer instance, locking it unless shared.
+func newFreezer(datadir string, namespace string, readonly bool, shared bool, maxTableSize uint32, tables map[string]bool) (*Freezer, error) {
 	// Create the initial freezer object
 	var (
-		readMeter  = metrics.NewRegisteredMeter(namespace+"ancient/read", nil)
-		writeMeter = metrics.NewRegisteredMeter(namespace+"ancient/write", nil)
-		sizeGauge  = metrics.NewRegisteredGauge(namespace+"ancient/size", nil)
+		readMeter  = metrics.GetOrRegisterMeter(namespace+"ancient/read", nil)
+		writeMeter = metrics.GetOrRegisterMeter(namespace+"ancient/write", nil)
+		sizeGauge  = metrics.GetOrRegisterGauge(namespace+"ancient/size", nil)
 	)
 	// Ensure the datadir is not a symbolic link if it exists.
 	if info, err := os.Lstat(datadir); !os.IsNotExist(err) {
@@ -102,9 +114,14 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 	}
 	// Leveldb uses LOCK as the filelock filename. To prevent the
 	// name collision, we use FLOCK as the lock name.
-	lock, _, err := fileutil.Flock(filepath.Join(datadir, "FLOCK"))
-	if err != nil {
-		return nil, err
+	var (
+		lock fileutil.Releaser = sharedLock{}
+		err  error
+	)
+	if !shared {
+		if lock, _, err = fileutil.Flock(filepath.Join(datadir, "FLOCK")); err != nil {
+			return nil, err
+		}
 	}
 	// Open all the supported data tables
 	freezer := &Freezer{
@@ -116,7 +133,7 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 
 	// Create the tables.
 	for name, disableSnappy := range tables {
-		table, err := newTable(datadir, name, readMeter, writeMeter, sizeGauge, maxTableSize, disableSnappy, readonly)
+		table, err := openTable(datadir, name, readMeter, writeMeter, sizeGauge, maxTableSize, disableSnappy, readonly, shared)
 		if err != nil {
 			for _, table := range freezer.tables {
 				table.Close()
@@ -127,7 +144,11 @@ func NewFreezer(datadir string, namespace string, readonly bool, maxTableSize ui
 		freezer.tables[name] = table
 	}
 
-	if freezer.readonly {
+	if shared {
+		// The owner of a shared freezer may be appending to it, only expose
+		// the items written to all the tables.
+		freezer.share()
+	} else if freezer.readonly {
 		// In readonly mode only validate, don't truncate.
 		// validate also sets `freezer.frozen`.
 		err = freezer.validate()
@@ -348,6 +369,34 @@ func (f *Freezer) validate() error {
 	return nil
 }
 
+// share sets the length of a shared freezer to the shortest table length and
+// its tail to the highest table tail, without truncating any tables.
+func (f *Freezer) share() {
+	var (
+		head = uint64(math.MaxUint64)
+		tail = uint64(0)
+	)
+	for _, table := range f.tables {
+		if items := atomic.LoadUint64(&table.items); head > items {
+			head = items
+		}
+		if hidden := atomic.LoadUint64(&table.itemHidden); hidden > tail {
+			tail = hidden
+		}
+	}
+	if len(f.tables) == 0 {
+		head = 0
+	}
+	atomic.StoreUint64(&f.frozen, head)
+	atomic.StoreUint64(&f.tail, tail)
+}
+
+// sharedLock is the no-op instance lock of a shared freezer.
+type sharedLock struct{}
+
+// Release implements fileutil.Releaser.
+func (sharedLock) Release() error { return nil }
+
 // repair truncates all data tables to the same length.
 func (f *Freezer) repair() error {
 	var (
@@ -0,0 +1,668 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/objectstore"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/golang/snappy"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	// remoteSegmentItems is the number of items of a table packed into a single
+	// remote object.
+	remoteSegmentItems = 2048
+
+	// remoteSyncInterval is the interval between the refreshes of the remote
+	// manifest, along with the uploads and the local trimming they allow.
+	remoteSyncInterval = time.Minute
+
+	// remoteDecodedSegments is the number of decoded segments kept in memory.
+	remoteDecodedSegments = 16
+
+	// remoteManifestKey is the key of the object tracking the remote progress.
+	remoteManifestKey = "manifest.json"
+)
+
+// errRemoteChainMismatch is returned if the remote ancients belong to another
+// chain than the local ones.
+var errRemoteChainMismatch = errors.New("remote ancients belong to a different chain")
+
+// RemoteFreezerConfig are the parameters of the remote ancient store.
+type RemoteFreezerConfig struct {
+	Store     objectstore.Store // Object storage holding the remote ancients
+	CacheDir  string            // Directory caching the downloaded segments
+	CacheSize uint64            // Maximum size of the segment cache in bytes
+	Upload    bool              // Whether to upload the local ancients missing remotely
+}
+
+// remoteManifest is the object tracking the number of items available in each
+// remote table.
+type remoteManifest struct {
+	Items uint64 `json:"items"`
+}
+
+// remoteSegmentKey returns the object key of a remote table segment.
+func remoteSegmentKey(kind string, segment uint64) string {
+	return fmt.Sprintf("%s/%08d", kind, segment)
+}
+
+// encodeRemoteSegment packs the items of a table segment into a remote object:
+// the end offsets of the items as big endian uint32s followed by the items, all
+// snappy compressed unless disabled for the table.
+func encodeRemoteSegment(items [][]byte, noSnappy bool) []byte {
+	var (
+		blob   = make([]byte, 4*len(items))
+		offset uint32
+	)
+	for i, item := range items {
+		offset += uint32(len(item))
+		binary.BigEndian.PutUint32(blob[4*i:], offset)
+	}
+	for _, item := range items {
+		blob = append(blob, item...)
+	}
+	if noSnappy {
+		return blob
+	}
+	return snappy.Encode(nil, blob)
+}
+
+// decodeRemoteSegment unpacks the items of a table segment from a remote object.
+func decodeRemoteSegment(blob []byte, noSnappy bool) ([][]byte, error) {
+	if !noSnappy {
+		var err error
+		if blob, err = snappy.Decode(nil, blob); err != nil {
+			return nil, err
+		}
+	}
+	if len(blob) < 4*remoteSegmentItems {
+		return nil, errors.New("truncated remote segment")
+	}
+	var (
+		items = make([][]byte, remoteSegmentItems)
+		data  = blob[4*remoteSegmentItems:]
+		start uint32
+	)
+	for i := range items {
+		end := binary.BigEndian.Uint32(blob[4*i:])
+		if end < start || int(end) > len(data) {
+			return nil, errors.New("corrupted remote segment")
+		}
+		items[i] = data[start:end]
+		start = end
+	}
+	return items, nil
+}
+
+// remoteFreezer is a read-only view of the ancient tables stored in an object
+// storage. The tables are split into immutable segments of a fixed number of
+// items, which are cached on the local disk once downloaded.
+type remoteFreezer struct {
+	items uint64 // Number of items available in every remote table (atomic)
+
+	store   objectstore.Store
+	tables  map[string]bool // Remote tables and whether snappy is disabled for them
+	cache   *segmentCache   // Local disk cache of the downloaded segments
+	decoded *lru.Cache      // Memory cache of the decoded segments
+
+	fetchMeter metrics.Meter
+	hitMeter   metrics.Meter
+}
+
+// newRemoteFreezer creates a view of the ancient tables in the configured object
+// storage, loading the current remote progress.
+func newRemoteFreezer(config RemoteFreezerConfig, namespace string, tables map[string]bool) (*remoteFreezer, error) {
+	cache, err := newSegmentCache(config.CacheDir, config.CacheSize)
+	if err != nil {
+		return nil, err
+	}
+	decoded, _ := lru.New(remoteDecodedSegments)
+	f := &remoteFreezer{
+		store:      config.Store,
+		tables:     tables,
+		cache:      cache,
+		decoded:    decoded,
+		fetchMeter: metrics.NewRegisteredMeter(namespace+"ancient/remote/fetch", nil),
+		hitMeter:   metrics.NewRegisteredMeter(namespace+"ancient/remote/hit", nil),
+	}
+	if err := f.refresh(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// refresh reloads the number of items available remotely.
+func (f *remoteFreezer) refresh() error {
+	blob, err := f.store.Get(remoteManifestKey)
+	if errors.Is(err, objectstore.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var manifest remoteManifest
+	if err := json.Unmarshal(blob, &manifest); err != nil {
+		return err
+	}
+	atomic.StoreUint64(&f.items, manifest.Items)
+	return nil
+}
+
+// Ancients returns the number of items available in every remote table.
+func (f *remoteFreezer) Ancients() uint64 {
+	return atomic.LoadUint64(&f.items)
+}
+
+// segment retrieves the decoded items of a table segment, from the memory cache,
+// the disk cache or the object storage, in this order.
+func (f *remoteFreezer) segment(kind string, segment uint64) ([][]byte, error) {
+	noSnappy, ok := f.tables[kind]
+	if !ok {
+		return nil, errUnknownTable
+	}
+	key := remoteSegmentKey(kind, segment)
+	if items, ok := f.decoded.Get(key); ok {
+		return items.([][]byte), nil
+	}
+	if blob := f.cache.get(key); blob != nil {
+		items, err := decodeRemoteSegment(blob, noSnappy)
+		if err == nil {
+			f.hitMeter.Mark(1)
+			f.decoded.Add(key, items)
+			return items, nil
+		}
+		// The cached copy got damaged on disk, drop it and download it again
+		log.Warn("Dropping corrupted cached segment", "segment", key, "err", err)
+		f.cache.drop(key)
+	}
+	blob, err := f.store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	f.fetchMeter.Mark(int64(len(blob)))
+
+	items, err := decodeRemoteSegment(blob, noSnappy)
+	if err != nil {
+		return nil, fmt.Errorf("remote segment %s: %v", key, err)
+	}
+	f.cache.put(key, blob)
+	f.decoded.Add(key, items)
+	return items, nil
+}
+
+// ancient retrieves a single item of a remote table.
+func (f *remoteFreezer) ancient(kind string, number uint64) ([]byte, error) {
+	if number >= f.Ancients() {
+		return nil, errOutOfBounds
+	}
+	items, err := f.segment(kind, number/remoteSegmentItems)
+	if err != nil {
+		return nil, err
+	}
+	return common.CopyBytes(items[number%remoteSegmentItems]), nil
+}
+
+// ancientRange retrieves multiple items of a remote table in sequence, with the
+// same limits as Freezer.AncientRange.
+func (f *remoteFreezer) ancientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	if items := f.Ancients(); start >= items || count == 0 {
+		return nil, errOutOfBounds
+	} else if start+count > items {
+		count = items - start
+	}
+	var (
+		output [][]byte
+		size   uint64
+	)
+	for number := start; number < start+count; number++ {
+		item, err := f.ancient(kind, number)
+		if err != nil {
+			return nil, err
+		}
+		if len(output) > 0 && size+uint64(len(item)) > maxBytes {
+			break
+		}
+		output = append(output, item)
+		size += uint64(len(item))
+	}
+	return output, nil
+}
+
+// upload packs the items of the source freezer following the remote ones into
+// segments and uploads them, as long as full segments are available locally.
+func (f *remoteFreezer) upload(source *Freezer, quit chan struct{}) error {
+	for {
+		var (
+			next      = f.Ancients()
+			frozen, _ = source.Ancients()
+			tail, _   = source.Tail()
+		)
+		if next+remoteSegmentItems > frozen {
+			return nil
+		}
+		if next < tail {
+			return fmt.Errorf("local ancients trimmed beyond the remote ones: tail %d, remote %d", tail, next)
+		}
+		for kind, noSnappy := range f.tables {
+			items := make([][]byte, 0, remoteSegmentItems)
+			for number := next; number < next+remoteSegmentItems; number++ {
+				item, err := source.Ancient(kind, number)
+				if err != nil {
+					return err
+				}
+				items = append(items, item)
+			}
+			if err := f.store.Put(remoteSegmentKey(kind, next/remoteSegmentItems), encodeRemoteSegment(items, noSnappy)); err != nil {
+				return err
+			}
+		}
+		blob, _ := json.Marshal(&remoteManifest{Items: next + remoteSegmentItems})
+		if err := f.store.Put(remoteManifestKey, blob); err != nil {
+			return err
+		}
+		atomic.StoreUint64(&f.items, next+remoteSegmentItems)
+		log.Info("Uploaded ancient segment", "first", next, "items", remoteSegmentItems)
+
+		select {
+		case <-quit:
+			return nil
+		default:
+		}
+	}
+}
+
+// remoteChainFreezer is a chain freezer whose ancient items are complemented by
+// the ones in a remote store: the local tables are trimmed to the items missing
+// remotely, with the reads of the trimmed items served by the remote store.
+type remoteChainFreezer struct {
+	*chainFreezer
+	remote *remoteFreezer
+	upload bool
+
+	syncLock sync.Mutex // Serializes the synchronisations with the remote store
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newRemoteChainFreezer initializes the chain freezer complemented by the given
+// remote ancient store.
+func newRemoteChainFreezer(datadir string, config RemoteFreezerConfig, namespace string, readonly bool, maxTableSize uint32, tables map[string]bool) (*remoteChainFreezer, error) {
+	remote, err := newRemoteFreezer(config, namespace, tables)
+	if err != nil {
+		return nil, err
+	}
+	local, err := newChainFreezer(datadir, namespace, readonly, maxTableSize, tables)
+	if err != nil {
+		return nil, err
+	}
+	f := &remoteChainFreezer{
+		chainFreezer: local,
+		remote:       remote,
+		upload:       config.Upload,
+		quit:         make(chan struct{}),
+	}
+	if err := f.verify(remote.Ancients()); err != nil {
+		local.Close()
+		return nil, err
+	}
+	f.wg.Add(1)
+	go f.sync()
+	return f, nil
+}
+
+// Close terminates the remote synchronisation and closes the chain freezer.
+func (f *remoteChainFreezer) Close() error {
+	select {
+	case <-f.quit:
+	default:
+		close(f.quit)
+	}
+	f.wg.Wait()
+	return f.chainFreezer.Close()
+}
+
+// HasAncient returns an indicator whether the specified ancient data exists
+// locally or remotely.
+func (f *remoteChainFreezer) HasAncient(kind string, number uint64) (bool, error) {
+	if tail, _ := f.Freezer.Tail(); number < tail {
+		if _, ok := f.remote.tables[kind]; !ok {
+			return false, nil
+		}
+		return number < f.remote.Ancients(), nil
+	}
+	return f.Freezer.HasAncient(kind, number)
+}
+
+// Ancient retrieves an ancient binary blob, from the remote store if trimmed
+// from the local tables.
+func (f *remoteChainFreezer) Ancient(kind string, number uint64) ([]byte, error) {
+	if tail, _ := f.Freezer.Tail(); number < tail {
+		return f.remote.ancient(kind, number)
+	}
+	blob, err := f.Freezer.Ancient(kind, number)
+	if err == errOutOfBounds {
+		// The item might have been trimmed since checking the tail
+		if tail, _ := f.Freezer.Tail(); number < tail {
+			return f.remote.ancient(kind, number)
+		}
+	}
+	return blob, err
+}
+
+// AncientRange retrieves multiple items in sequence, starting from the index
+// 'start', from the remote store for the items trimmed from the local tables.
+func (f *remoteChainFreezer) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	tail, _ := f.Freezer.Tail()
+	if start >= tail {
+		return f.Freezer.AncientRange(kind, start, count, maxBytes)
+	}
+	remote := count
+	if start+remote > tail {
+		remote = tail - start
+	}
+	items, err := f.remote.ancientRange(kind, start, remote, maxBytes)
+	if err != nil || uint64(len(items)) < remote || remote == count {
+		return items, err
+	}
+	// The range continues into the local tables, fill it up within the limits
+	var size uint64
+	for _, item := range items {
+		size += uint64(len(item))
+	}
+	local, err := f.Freezer.AncientRange(kind, tail, count-remote, maxBytes-size)
+	if err != nil {
+		return items, nil
+	}
+	for _, item := range local {
+		if size+uint64(len(item)) > maxBytes {
+			break
+		}
+		items = append(items, item)
+		size += uint64(len(item))
+	}
+	return items, nil
+}
+
+// Tail returns the number of the first item available either locally or
+// remotely.
+func (f *remoteChainFreezer) Tail() (uint64, error) {
+	tail, err := f.Freezer.Tail()
+	if err != nil || f.remote.Ancients() >= tail {
+		return 0, err
+	}
+	return tail, nil
+}
+
+// ReadAncients runs the given read operation while ensuring that no writes take
+// place on the local tables.
+func (f *remoteChainFreezer) ReadAncients(fn func(ethdb.AncientReaderOp) error) error {
+	return f.Freezer.ReadAncients(func(ethdb.AncientReaderOp) error {
+		return fn(f)
+	})
+}
+
+// verify checks that the remote ancients below the given number belong to the
+// same chain as the local ones, by comparing the last hash stored at both.
+func (f *remoteChainFreezer) verify(number uint64) error {
+	var (
+		frozen, _ = f.Freezer.Ancients()
+		tail, _   = f.Freezer.Tail()
+	)
+	if number > frozen {
+		number = frozen
+	}
+	if number <= tail {
+		return nil
+	}
+	local, err := f.Freezer.Ancient(freezerHashTable, number-1)
+	if err != nil {
+		return err
+	}
+	remote, err := f.remote.ancient(freezerHashTable, number-1)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(local, remote) {
+		return fmt.Errorf("%w: #%d %#x (local) != %#x (remote)", errRemoteChainMismatch, number-1, local, remote)
+	}
+	return nil
+}
+
+// sync is a background thread that periodically synchronises the local tables
+// with the remote store.
+func (f *remoteChainFreezer) sync() {
+	defer f.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+		case <-f.quit:
+			return
+		}
+		if err := f.synchronise(); err != nil {
+			log.Error("Failed to synchronise remote ancients", "err", err)
+		}
+		timer.Reset(remoteSyncInterval)
+	}
+}
+
+// synchronise refreshes the remote progress, uploads the local items missing
+// remotely if requested, and trims the local tables of the items available
+// remotely.
+func (f *remoteChainFreezer) synchronise() error {
+	f.syncLock.Lock()
+	defer f.syncLock.Unlock()
+
+	if err := f.remote.refresh(); err != nil {
+		return err
+	}
+	if f.readonly {
+		return nil
+	}
+	if f.upload {
+		if err := f.remote.upload(f.Freezer, f.quit); err != nil {
+			return err
+		}
+	}
+	return f.trim()
+}
+
+// trim discards the local items available remotely.
+func (f *remoteChainFreezer) trim() error {
+	var (
+		target    = f.remote.Ancients()
+		frozen, _ = f.Freezer.Ancients()
+		tail, _   = f.Freezer.Tail()
+	)
+	if target > frozen {
+		target = frozen
+	}
+	if target <= tail {
+		return nil
+	}
+	if err := f.verify(target); err != nil {
+		return err
+	}
+	if err := f.Freezer.TruncateTail(target); err != nil {
+		return err
+	}
+	log.Info("Trimmed ancients available remotely", "tail", target)
+	return nil
+}
+
+// segmentCache is a size limited disk cache of the downloaded remote segments,
+// evicting the least recently used ones.
+type segmentCache struct {
+	dir   string
+	limit uint64
+
+	size    uint64
+	order   *list.List // Cached segments, most recently used first
+	entries map[string]*list.Element
+	lock    sync.Mutex
+}
+
+// segmentCacheEntry is a segment in the disk cache.
+type segmentCacheEntry struct {
+	name string
+	size uint64
+}
+
+// newSegmentCache opens the segment cache in the given directory, indexing the
+// segments cached by previous runs by their modification time.
+func newSegmentCache(dir string, limit uint64) (*segmentCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type cachedFile struct {
+		name string
+		size uint64
+		time time.Time
+	}
+	var cached []cachedFile
+	for _, file := range files {
+		info, err := file.Info()
+		if err != nil || !info.Mode().IsRegular() || strings.HasSuffix(file.Name(), ".tmp") {
+			continue
+		}
+		cached = append(cached, cachedFile{file.Name(), uint64(info.Size()), info.ModTime()})
+	}
+	sort.Slice(cached, func(i, j int) bool { return cached[i].time.After(cached[j].time) })
+
+	c := &segmentCache{
+		dir:     dir,
+		limit:   limit,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+	for _, file := range cached {
+		c.entries[file.name] = c.order.PushBack(&segmentCacheEntry{file.name, file.size})
+		c.size += file.size
+	}
+	c.evict()
+	return c, nil
+}
+
+// fileName converts a segment key into a file name in the cache directory.
+func (c *segmentCache) fileName(key string) string {
+	return strings.ReplaceAll(key, "/", "-")
+}
+
+// get retrieves a cached segment, or nil if it's not cached.
+func (c *segmentCache) get(key string) []byte {
+	name := c.fileName(key)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.entries[name]
+	if !ok {
+		return nil
+	}
+	blob, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		c.remove(elem)
+		return nil
+	}
+	c.order.MoveToFront(elem)
+	return blob
+}
+
+// put caches a segment, evicting the least recently used ones above the limit.
+func (c *segmentCache) put(key string, blob []byte) {
+	name := c.fileName(key)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.entries[name]; ok || uint64(len(blob)) > c.limit {
+		return
+	}
+	path := filepath.Join(c.dir, name)
+	if err := os.WriteFile(path+".tmp", blob, 0644); err != nil {
+		log.Warn("Failed to cache remote segment", "segment", key, "err", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		log.Warn("Failed to cache remote segment", "segment", key, "err", err)
+		return
+	}
+	c.entries[name] = c.order.PushFront(&segmentCacheEntry{name, uint64(len(blob))})
+	c.size += uint64(len(blob))
+	c.evict()
+}
+
+// drop deletes a segment from the cache.
+func (c *segmentCache) drop(key string) {
+	name := c.fileName(key)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elem, ok := c.entries[name]
+	if !ok {
+		return
+	}
+	if err := os.Remove(filepath.Join(c.dir, name)); err != nil && !os.IsNotExist(err) {
+		log.Warn("Failed to drop remote segment", "segment", key, "err", err)
+	}
+	c.remove(elem)
+}
+
+// evict drops the least recently used segments until the cache fits its limit.
+func (c *segmentCache) evict() {
+	for c.size > c.limit {
+		elem := c.order.Back()
+		if err := os.Remove(filepath.Join(c.dir, elem.Value.(*segmentCacheEntry).name)); err != nil && !os.IsNotExist(err) {
+			log.Warn("Failed to evict remote segment", "err", err)
+		}
+		c.remove(elem)
+	}
+}
+
+// remove drops a segment from the cache index.
+func (c *segmentCache) remove(elem *list.Element) {
+	entry := c.order.Remove(elem).(*segmentCacheEntry)
+	delete(c.entries, entry.name)
+	c.size -= entry.size
+}
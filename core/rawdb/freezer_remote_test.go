@@ -0,0 +1,192 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/objectstore"
+)
+
+var remoteTestTableDef = map[string]bool{freezerHashTable: true, "data": false}
+
+func newRemoteFreezerForTesting(t *testing.T, store objectstore.Store, upload bool) *remoteChainFreezer {
+	t.Helper()
+
+	config := RemoteFreezerConfig{
+		Store:     store,
+		CacheDir:  t.TempDir(),
+		CacheSize: 1024 * 1024,
+		Upload:    upload,
+	}
+	f, err := newRemoteChainFreezer(t.TempDir(), config, "", false, 2049, remoteTestTableDef)
+	if err != nil {
+		t.Fatal("can't open freezer", err)
+	}
+	return f
+}
+
+func appendRemoteTestItems(t *testing.T, f ethdb.AncientWriter, start, count uint64, salt int) {
+	t.Helper()
+
+	_, err := f.ModifyAncients(func(op ethdb.AncientWriteOp) error {
+		for i := start; i < start+count; i++ {
+			if err := op.AppendRaw(freezerHashTable, i, getChunk(32, int(i)+salt)); err != nil {
+				return err
+			}
+			if err := op.AppendRaw("data", i, getChunk(int(i%100)+1, int(i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal("ModifyAncients failed:", err)
+	}
+}
+
+// Tests that the full segments of local ancients are uploaded, trimmed locally
+// and transparently served from the remote store afterwards.
+func TestRemoteFreezerUploadAndTrim(t *testing.T) {
+	store := objectstore.NewMemory()
+	f := newRemoteFreezerForTesting(t, store, true)
+	defer f.Close()
+
+	items := uint64(2*remoteSegmentItems + 100)
+	appendRemoteTestItems(t, f, 0, items, 0)
+
+	if err := f.synchronise(); err != nil {
+		t.Fatal("synchronisation failed:", err)
+	}
+	if have, want := f.remote.Ancients(), uint64(2*remoteSegmentItems); have != want {
+		t.Fatalf("remote item count mismatch: have %d, want %d", have, want)
+	}
+	if tail, _ := f.Freezer.Tail(); tail != 2*remoteSegmentItems {
+		t.Fatalf("local tail mismatch: have %d, want %d", tail, 2*remoteSegmentItems)
+	}
+	if tail, _ := f.Tail(); tail != 0 {
+		t.Fatalf("combined tail mismatch: have %d, want 0", tail)
+	}
+	for i := uint64(0); i < items; i++ {
+		if ok, _ := f.HasAncient("data", i); !ok {
+			t.Fatalf("item %d missing", i)
+		}
+		blob, err := f.Ancient("data", i)
+		if err != nil {
+			t.Fatalf("item %d retrieval failed: %v", i, err)
+		}
+		if !bytes.Equal(blob, getChunk(int(i%100)+1, int(i))) {
+			t.Fatalf("item %d mismatch: %x", i, blob)
+		}
+	}
+	// Retrieve a range spanning the remote and the local items
+	start := uint64(2*remoteSegmentItems - 10)
+	blobs, err := f.AncientRange("data", start, 20, 1<<20)
+	if err != nil {
+		t.Fatal("range retrieval failed:", err)
+	}
+	if len(blobs) != 20 {
+		t.Fatalf("range length mismatch: have %d, want 20", len(blobs))
+	}
+	for i, blob := range blobs {
+		if !bytes.Equal(blob, getChunk(int((start+uint64(i))%100)+1, int(start)+i)) {
+			t.Fatalf("range item %d mismatch: %x", i, blob)
+		}
+	}
+	// Ensure a freezer of another chain refuses the remote ancients
+	other := objectstore.NewMemory()
+	g := newRemoteFreezerForTesting(t, other, true)
+	appendRemoteTestItems(t, g, 0, remoteSegmentItems, 0)
+	if err := g.synchronise(); err != nil {
+		t.Fatal("synchronisation failed:", err)
+	}
+	g.Close()
+
+	h := newRemoteFreezerForTesting(t, other, false)
+	defer h.Close()
+	appendRemoteTestItems(t, h, 0, remoteSegmentItems, 1)
+	if err := h.synchronise(); !errors.Is(err, errRemoteChainMismatch) {
+		t.Fatalf("synchronisation error mismatch: have %v, want %v", err, errRemoteChainMismatch)
+	}
+}
+
+// Tests that the segment cache stays within its limit, evicting the least
+// recently used segments.
+func TestSegmentCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := newSegmentCache(dir, 300)
+	if err != nil {
+		t.Fatal("can't open cache", err)
+	}
+	cache.put("a/0", make([]byte, 100))
+	cache.put("a/1", make([]byte, 100))
+	cache.put("a/2", make([]byte, 100))
+	cache.get("a/0")
+	cache.put("a/3", make([]byte, 100))
+
+	if cache.get("a/1") != nil {
+		t.Fatalf("least recently used segment not evicted")
+	}
+	for _, key := range []string{"a/0", "a/2", "a/3"} {
+		if cache.get(key) == nil {
+			t.Fatalf("segment %s evicted", key)
+		}
+	}
+	// Reopen the cache and ensure the segments are retained
+	if cache, err = newSegmentCache(dir, 300); err != nil {
+		t.Fatal("can't reopen cache", err)
+	}
+	if cache.size != 300 || len(cache.entries) != 3 {
+		t.Fatalf("reopened cache mismatch: size %d, entries %d", cache.size, len(cache.entries))
+	}
+}
+
+// Tests that a corrupted segment in the disk cache is dropped and downloaded
+// again instead of failing every retrieval.
+func TestRemoteFreezerCorruptedCache(t *testing.T) {
+	store := objectstore.NewMemory()
+	items := make([][]byte, remoteSegmentItems)
+	for i := range items {
+		items[i] = getChunk(10, i)
+	}
+	key := remoteSegmentKey("data", 0)
+	blob := encodeRemoteSegment(items, false)
+	if err := store.Put(key, blob); err != nil {
+		t.Fatal("can't store segment", err)
+	}
+	f, err := newRemoteFreezer(RemoteFreezerConfig{Store: store, CacheDir: t.TempDir(), CacheSize: 1024 * 1024}, "", remoteTestTableDef)
+	if err != nil {
+		t.Fatal("can't open remote freezer", err)
+	}
+	f.cache.put(key, []byte("garbage"))
+
+	have, err := f.segment("data", 0)
+	if err != nil {
+		t.Fatal("segment retrieval failed:", err)
+	}
+	for i := range items {
+		if !bytes.Equal(have[i], items[i]) {
+			t.Fatalf("item %d mismatch: have %x, want %x", i, have[i], items[i])
+		}
+	}
+	if cached := f.cache.get(key); !bytes.Equal(cached, blob) {
+		t.Fatalf("cached segment not replaced: have %x", cached)
+	}
+}
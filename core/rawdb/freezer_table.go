@@ -103,6 +103,7 @@ type freezerTable struct {
 
 	noCompression bool // if true, disables snappy compression. Note: does not work retroactively
 	readonly      bool
+	shared        bool   // if true, another process may be appending to the table
 	maxFileSize   uint32 // Max file size for data-files
 	name          string
 	path          string
@@ -132,6 +133,13 @@ func NewFreezerTable(path, name string, disableSnappy, readonly bool) (*freezerT
 // non-existent. Both files are truncated to the shortest common length to ensure
 // they don't go out of sync.
 func newTable(path string, name string, readMeter metrics.Meter, writeMeter metrics.Meter, sizeGauge metrics.Gauge, maxFilesize uint32, noCompression, readonly bool) (*freezerTable, error) {
+	return openTable(path, name, readMeter, writeMeter, sizeGauge, maxFilesize, noCompression, readonly, false)
+}
+
+// openTable opens a freezer table like newTable. A shared table is opened
+// readonly while another process may be appending to it, so the data past its
+// index is left alone instead of being rejected.
+func openTable(path string, name string, readMeter metrics.Meter, writeMeter metrics.Meter, sizeGauge metrics.Gauge, maxFilesize uint32, noCompression, readonly, shared bool) (*freezerTable, error) {
 	// Ensure the containing directory exists and open the indexEntry file
 	if err := os.MkdirAll(path, 0755); err != nil {
 		return nil, err
@@ -188,6 +196,7 @@ func newTable(path string, name string, readMeter metrics.Meter, writeMeter metr
 		logger:        log.New("database", path, "table", name),
 		noCompression: noCompression,
 		readonly:      readonly,
+		shared:        shared,
 		maxFileSize:   maxFilesize,
 	}
 	if err := tab.repair(); err != nil {
@@ -222,14 +231,15 @@ func (t *freezerTable) repair() error {
 		}
 	}
 	// Ensure the index is a multiple of indexEntrySize bytes
-	if overflow := stat.Size() % indexEntrySize; overflow != 0 {
+	if overflow := stat.Size() % indexEntrySize; overflow != 0 && !t.shared {
 		truncateFreezerFile(t.index, stat.Size()-overflow) // New file can't trigger this path
 	}
 	// Retrieve the file sizes and prepare for truncation
 	if stat, err = t.index.Stat(); err != nil {
 		return err
 	}
-	offsetsSize := stat.Size()
+	// A shared table ignores any partially written index entry instead
+	offsetsSize := stat.Size() - stat.Size()%indexEntrySize
 
 	// Open the head file
 	var (
@@ -279,6 +289,15 @@ func (t *freezerTable) repair() error {
 
 	// Keep truncating both files until they come in sync
 	contentExp = int64(lastIndex.offset)
+	if t.shared {
+		// The files of a shared table can't be truncated. Ignore the data past
+		// the last index, which may well be written concurrently by the owner of
+		// the table, but refuse an index pointing past the data.
+		if contentExp > contentSize {
+			return fmt.Errorf("freezer table %s indexes missing data: %d > %d", t.name, contentExp, contentSize)
+		}
+		contentSize = contentExp
+	}
 	for contentExp != contentSize {
 		// Truncate the head file to the last offset pointer
 		if contentExp < contentSize {
@@ -342,10 +361,10 @@ func (t *freezerTable) repair() error {
 	t.headId = lastIndex.filenum
 
 	// Delete the leftover files because of head deletion
-	t.releaseFilesAfter(t.headId, true)
+	t.releaseFilesAfter(t.headId, !t.shared)
 
 	// Delete the leftover files because of tail deletion
-	t.releaseFilesBefore(t.tailId, true)
+	t.releaseFilesBefore(t.tailId, !t.shared)
 
 	// Close opened files and preopen all files
 	if err := t.preopen(); err != nil {
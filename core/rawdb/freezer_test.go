@@ -283,6 +283,44 @@ func TestFreezerReadonlyValidate(t *testing.T) {
 	}
 }
 
+// Tests that a freezer can be opened shared while its owner keeps it open, only
+// exposing the items written to all the tables.
+func TestFreezerShared(t *testing.T) {
+	tables := map[string]bool{"a": true, "b": true}
+	dir := t.TempDir()
+
+	f, err := NewFreezer(dir, "", false, 2049, tables)
+	if err != nil {
+		t.Fatal("can't open freezer", err)
+	}
+	defer f.Close()
+
+	var item = make([]byte, 1024)
+	aBatch := f.tables["a"].newBatch()
+	require.NoError(t, aBatch.AppendRaw(0, item))
+	require.NoError(t, aBatch.AppendRaw(1, item))
+	require.NoError(t, aBatch.AppendRaw(2, item))
+	require.NoError(t, aBatch.commit())
+	bBatch := f.tables["b"].newBatch()
+	require.NoError(t, bBatch.AppendRaw(0, item))
+	require.NoError(t, bBatch.commit())
+
+	shared, err := NewSharedFreezer(dir, "", 2049, tables)
+	if err != nil {
+		t.Fatal("can't open shared freezer", err)
+	}
+	defer shared.Close()
+
+	checkAncientCount(t, shared, "b", 1)
+	if _, err := shared.ModifyAncients(func(op ethdb.AncientWriteOp) error { return nil }); err != errReadOnly {
+		t.Fatalf("shared freezer modification error mismatch: have %v, want %v", err, errReadOnly)
+	}
+	// The owner of the freezer must not have been disturbed
+	require.Equal(t, uint64(3), f.tables["a"].items)
+	_, err = f.tables["a"].Retrieve(2)
+	require.NoError(t, err)
+}
+
 func newFreezerForTesting(t *testing.T, tables map[string]bool) (*Freezer, string) {
 	t.Helper()
 
@@ -72,6 +72,9 @@ var (
 	// txIndexTailKey tracks the oldest block whose transactions have been indexed.
 	txIndexTailKey = []byte("TransactionIndexTail")
 
+	// logIndexTailKey tracks the oldest block whose bloom bits are still indexed.
+	logIndexTailKey = []byte("LogIndexTail")
+
 	// fastTxLookupLimitKey tracks the transaction lookup limit during fast sync.
 	fastTxLookupLimitKey = []byte("FastTransactionLookupLimit")
 
@@ -84,6 +87,18 @@ var (
 	// transitionStatusKey tracks the eth2 transition status.
 	transitionStatusKey = []byte("eth2-transition")
 
+	// lastOnlinePruneKey tracks the time the last online state pruning finished.
+	lastOnlinePruneKey = []byte("LastOnlinePrune")
+
+	// stateSchemeKey tracks the scheme the state of the database is stored with.
+	stateSchemeKey = []byte("StateScheme")
+
+	// trieJournalKey tracks the in-memory trie node layers across restarts.
+	trieJournalKey = []byte("TrieJournal")
+
+	// trieHistoryHeadKey tracks the id of the latest trie history.
+	trieHistoryHeadKey = []byte("TrieHistoryHead")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -93,17 +108,32 @@ var (
 	blockBodyPrefix     = []byte("b") // blockBodyPrefix + num (uint64 big endian) + hash -> block body
 	blockReceiptsPrefix = []byte("r") // blockReceiptsPrefix + num (uint64 big endian) + hash -> block receipts
 
-	txLookupPrefix        = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
-	bloomBitsPrefix       = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
-	SnapshotAccountPrefix = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
-	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
-	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
-	skeletonHeaderPrefix  = []byte("S") // skeletonHeaderPrefix + num (uint64 big endian) -> header
+	txLookupPrefix         = []byte("l") // txLookupPrefix + hash -> transaction/receipt lookup metadata
+	bloomBitsPrefix        = []byte("B") // bloomBitsPrefix + bit (uint16 big endian) + section (uint64 big endian) + hash -> bloom bits
+	SnapshotAccountPrefix  = []byte("a") // SnapshotAccountPrefix + account hash -> account trie value
+	SnapshotStoragePrefix  = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
+	CodePrefix             = []byte("c") // CodePrefix + code hash -> account code
+	skeletonHeaderPrefix   = []byte("S") // skeletonHeaderPrefix + num (uint64 big endian) -> header
+	contractCreationPrefix = []byte("C") // contractCreationPrefix + address + num (uint64 big endian) + hash -> contract creation
+	contractDestructPrefix = []byte("D") // contractDestructPrefix + address + num (uint64 big endian) + hash -> contract self-destruct
+	stateDiffPrefix        = []byte("d") // stateDiffPrefix + num (uint64 big endian) + hash -> block state diff
+	witnessPrefix          = []byte("w") // witnessPrefix + num (uint64 big endian) + hash -> block execution witness
+
+	// Path-based storage scheme of merkle patricia trie.
+	trieNodeAccountPrefix = []byte("A")             // trieNodeAccountPrefix + hexPath -> trie node
+	trieNodeStoragePrefix = []byte("O")             // trieNodeStoragePrefix + accountHash + hexPath -> trie node
+	trieHistoryPrefix     = []byte("trie-history-") // trieHistoryPrefix + id (uint64 big endian) -> trie history
+	trieHistoryRootPrefix = []byte("trie-root-")    // trieHistoryRootPrefix + state root -> trie history id
+
+	// Experimental verkle tree, nodes keyed by their commitment.
+	verkleNodePrefix = []byte("verkle-") // verkleNodePrefix + commitment -> verkle tree node
 
 	PreimagePrefix = []byte("secure-key-")       // PreimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-")  // config prefix for the db
 	genesisPrefix  = []byte("ethereum-genesis-") // genesis state prefix for the db
 
+	filterCheckpointPrefix = []byte("filter-checkpoint-") // filterCheckpointPrefix + filter id -> log filter checkpoint
+
 	// Chain index prefixes (use `i` + single byte to avoid mixing data types).
 	BloomBitsIndexPrefix = []byte("iB") // BloomBitsIndexPrefix is the data table of a chain indexer to track its progress
 
@@ -188,11 +218,33 @@ func blockReceiptsKey(number uint64, hash common.Hash) []byte {
 	return append(append(blockReceiptsPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
 }
 
+// stateDiffKey = stateDiffPrefix + num (uint64 big endian) + hash
+func stateDiffKey(number uint64, hash common.Hash) []byte {
+	return append(append(stateDiffPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
+// witnessKey = witnessPrefix + num (uint64 big endian) + hash
+func witnessKey(number uint64, hash common.Hash) []byte {
+	return append(append(witnessPrefix, encodeBlockNumber(number)...), hash.Bytes()...)
+}
+
 // txLookupKey = txLookupPrefix + hash
 func txLookupKey(hash common.Hash) []byte {
 	return append(txLookupPrefix, hash.Bytes()...)
 }
 
+// contractCreationKey = contractCreationPrefix + address + num (uint64 big endian) + hash
+func contractCreationKey(address common.Address, number uint64, hash common.Hash) []byte {
+	key := append(append(contractCreationPrefix, address.Bytes()...), encodeBlockNumber(number)...)
+	return append(key, hash.Bytes()...)
+}
+
+// contractDestructKey = contractDestructPrefix + address + num (uint64 big endian) + hash
+func contractDestructKey(address common.Address, number uint64, hash common.Hash) []byte {
+	key := append(append(contractDestructPrefix, address.Bytes()...), encodeBlockNumber(number)...)
+	return append(key, hash.Bytes()...)
+}
+
 // accountSnapshotKey = SnapshotAccountPrefix + hash
 func accountSnapshotKey(hash common.Hash) []byte {
 	return append(SnapshotAccountPrefix, hash.Bytes()...)
@@ -233,6 +285,31 @@ func codeKey(hash common.Hash) []byte {
 	return append(CodePrefix, hash.Bytes()...)
 }
 
+// accountTrieNodeKey = trieNodeAccountPrefix + nodePath.
+func accountTrieNodeKey(path []byte) []byte {
+	return append(trieNodeAccountPrefix, path...)
+}
+
+// storageTrieNodeKey = trieNodeStoragePrefix + accountHash + nodePath.
+func storageTrieNodeKey(accountHash common.Hash, path []byte) []byte {
+	return append(append(trieNodeStoragePrefix, accountHash.Bytes()...), path...)
+}
+
+// trieHistoryKey = trieHistoryPrefix + id (uint64 big endian)
+func trieHistoryKey(id uint64) []byte {
+	return append(trieHistoryPrefix, encodeBlockNumber(id)...)
+}
+
+// trieHistoryRootKey = trieHistoryRootPrefix + state root
+func trieHistoryRootKey(root common.Hash) []byte {
+	return append(trieHistoryRootPrefix, root.Bytes()...)
+}
+
+// verkleNodeKey = verkleNodePrefix + commitment
+func verkleNodeKey(commitment common.Hash) []byte {
+	return append(verkleNodePrefix, commitment.Bytes()...)
+}
+
 // IsCodeKey reports whether the given byte slice is the key of contract code,
 // if so return the raw code hash as well.
 func IsCodeKey(key []byte) (bool, []byte) {
@@ -242,6 +319,20 @@ func IsCodeKey(key []byte) (bool, []byte) {
 	return false, nil
 }
 
+// isTieredStateKey reports whether the given key is the one of a trie node or a
+// snapshot entry, the bulk of the state that may be kept on cold storage.
+func isTieredStateKey(key []byte) bool {
+	switch len(key) {
+	case common.HashLength:
+		return true
+	case len(SnapshotAccountPrefix) + common.HashLength:
+		return bytes.HasPrefix(key, SnapshotAccountPrefix)
+	case len(SnapshotStoragePrefix) + 2*common.HashLength:
+		return bytes.HasPrefix(key, SnapshotStoragePrefix)
+	}
+	return false
+}
+
 // configKey = configPrefix + hash
 func configKey(hash common.Hash) []byte {
 	return append(configPrefix, hash.Bytes()...)
@@ -251,3 +342,8 @@ func configKey(hash common.Hash) []byte {
 func genesisKey(hash common.Hash) []byte {
 	return append(genesisPrefix, hash.Bytes()...)
 }
+
+// filterCheckpointKey = filterCheckpointPrefix + filter id
+func filterCheckpointKey(id string) []byte {
+	return append(filterCheckpointPrefix, id...)
+}
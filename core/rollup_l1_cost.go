@@ -34,9 +34,18 @@ var (
 	DecimalsSlot  = common.BigToHash(big.NewInt(5))
 )
 
+// Further storage slots of the L1Block predeploy, describing the L1 origin of
+// the current L2 block. The number and timestamp are packed into one slot.
+var (
+	L1NumberTimestampSlot = common.BigToHash(big.NewInt(0))
+	L1BlockHashSlot       = common.BigToHash(big.NewInt(2))
+	L1SequenceNumberSlot  = common.BigToHash(big.NewInt(3))
+)
+
 var (
 	OVM_GasPriceOracleAddr = common.HexToAddress("0x420000000000000000000000000000000000000F")
 	L1BlockAddr            = common.HexToAddress("0x4200000000000000000000000000000000000015")
+	L1InfoDepositerAddr    = common.HexToAddress("0xDeaDDEaDDeAdDeAdDEAdDEaddeAddEAdDEAd0001")
 )
 
 // NewL1CostFunc returns a function used for calculating L1 fee cost.
@@ -26,6 +26,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/verkle"
 	lru "github.com/hashicorp/golang-lru"
 )
 
@@ -92,6 +93,10 @@ type Trie interface {
 	// and external (for account tries) references.
 	Commit(onleaf trie.LeafCallback) (common.Hash, int, error)
 
+	// CommitState is like Commit, but stages the trie nodes of the path scheme
+	// into the transition to the given state root instead of the trie's own.
+	CommitState(state common.Hash, onleaf trie.LeafCallback) (common.Hash, int, error)
+
 	// NodeIterator returns an iterator that returns nodes of the trie. Iteration
 	// starts at the key after the given start key.
 	NodeIterator(startKey []byte) trie.NodeIterator
@@ -108,7 +113,9 @@ type Trie interface {
 
 // NewDatabase creates a backing store for state. The returned database is safe for
 // concurrent use, but does not retain any recent trie nodes in memory. To keep some
-// historical state in memory, use the NewDatabaseWithConfig constructor.
+// historical state in memory, use the NewDatabaseWithConfig constructor. The hash
+// scheme is used without inspecting the database, the state of the path scheme
+// is only accessible through the trie database of the chain.
 func NewDatabase(db ethdb.Database) Database {
 	return NewDatabaseWithConfig(db, nil)
 }
@@ -131,8 +138,16 @@ type cachingDB struct {
 	codeCache     *fastcache.Cache
 }
 
-// OpenTrie opens the main account trie at a specific root hash.
+// OpenTrie opens the main account trie at a specific root hash, or the verkle
+// tree if the state was converted into one.
 func (db *cachingDB) OpenTrie(root common.Hash) (Trie, error) {
+	if verkle.IsRoot(db.db.DiskDB(), root) {
+		tr, err := verkle.New(root, db.db.DiskDB())
+		if err != nil {
+			return nil, err
+		}
+		return tr, nil
+	}
 	tr, err := trie.NewSecure(common.Hash{}, root, db.db)
 	if err != nil {
 		return nil, err
@@ -154,6 +169,8 @@ func (db *cachingDB) CopyTrie(t Trie) Trie {
 	switch t := t.(type) {
 	case *trie.SecureTrie:
 		return t.Copy()
+	case *verkle.Trie:
+		return t.Copy()
 	default:
 		panic(fmt.Errorf("unknown trie type %T", t))
 	}
@@ -51,7 +51,7 @@ type DumpCollector interface {
 type DumpAccount struct {
 	Balance   string                 `json:"balance"`
 	Nonce     uint64                 `json:"nonce"`
-	Root      hexutil.Bytes          `json:"root"`
+	Root      hexutil.Bytes          `json:"root,omitempty"`
 	CodeHash  hexutil.Bytes          `json:"codeHash"`
 	Code      hexutil.Bytes          `json:"code,omitempty"`
 	Storage   map[common.Hash]string `json:"storage,omitempty"`
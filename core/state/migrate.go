@@ -0,0 +1,163 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// MigrateToPathScheme converts the hash-based state of the given root into the
+// path-based layout and switches the database over to the path scheme. All the
+// legacy hash-keyed trie nodes are deleted afterwards, so the states of other
+// roots are no longer accessible once the migration completes.
+func MigrateToPathScheme(db ethdb.Database, root common.Hash) error {
+	switch rawdb.ReadStateScheme(db) {
+	case rawdb.PathScheme:
+		return errors.New("state is already stored with the path scheme")
+	case "":
+		// Pin the legacy scheme explicitly, so an interrupted migration is
+		// never mistaken for a fresh database.
+		rawdb.WriteStateScheme(db, rawdb.HashScheme)
+	}
+	triedb := trie.NewDatabaseWithConfig(db, &trie.Config{Scheme: rawdb.HashScheme})
+	accTrie, err := trie.NewSecure(common.Hash{}, root, triedb)
+	if err != nil {
+		return err
+	}
+	var (
+		start    = time.Now()
+		logged   = time.Now()
+		batch    = db.NewBatch()
+		accounts int
+		nodes    int
+	)
+	flush := func() error {
+		if batch.ValueSize() < ethdb.IdealBatchSize {
+			return nil
+		}
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		batch.Reset()
+		return nil
+	}
+	accIter := accTrie.NodeIterator(nil)
+	for accIter.Next(true) {
+		if accIter.Hash() != (common.Hash{}) {
+			rawdb.WriteAccountTrieNode(batch, accIter.Path(), accIter.NodeBlob())
+			nodes++
+		}
+		if !accIter.Leaf() {
+			continue
+		}
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(accIter.LeafBlob(), &acc); err != nil {
+			return err
+		}
+		accounts++
+
+		// Storage tries are keyed by their owner in the path scheme, so the
+		// tries shared by multiple accounts are written once per account.
+		if acc.Root != emptyRoot {
+			owner := common.BytesToHash(accIter.LeafKey())
+			storageTrie, err := trie.NewSecure(owner, acc.Root, triedb)
+			if err != nil {
+				return err
+			}
+			storageIter := storageTrie.NodeIterator(nil)
+			for storageIter.Next(true) {
+				if storageIter.Hash() != (common.Hash{}) {
+					rawdb.WriteStorageTrieNode(batch, owner, storageIter.Path(), storageIter.NodeBlob())
+					nodes++
+				}
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			if storageIter.Error() != nil {
+				return storageIter.Error()
+			}
+		}
+		// Contract codes stored with the legacy scheme share the key space with
+		// the hash-based trie nodes, move them over before the nodes are wiped.
+		if !bytes.Equal(acc.CodeHash, emptyCodeHash) {
+			hash := common.BytesToHash(acc.CodeHash)
+			if !rawdb.HasCodeWithPrefix(db, hash) {
+				code := rawdb.ReadCode(db, hash)
+				if len(code) == 0 {
+					return errors.New("missing contract code")
+				}
+				rawdb.WriteCode(batch, hash, code)
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Migrating state to path scheme", "accounts", accounts, "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if accIter.Error() != nil {
+		return accIter.Error()
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	batch.Reset()
+	rawdb.WriteStateScheme(db, rawdb.PathScheme)
+	log.Info("Migrated state to path scheme", "accounts", accounts, "nodes", nodes, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	// Wipe the legacy hash-based trie nodes, they are identified by their key
+	// being the hash of the value.
+	it := rawdb.NewKeyLengthIterator(db.NewIterator(nil, nil), common.HashLength)
+	defer it.Release()
+
+	var deleted int
+	for it.Next() {
+		key := it.Key()
+		if !bytes.Equal(key, crypto.Keccak256(it.Value())) {
+			continue
+		}
+		if err := batch.Delete(key); err != nil {
+			return err
+		}
+		deleted++
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	if err := it.Error(); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Deleted legacy trie nodes", "nodes", deleted, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
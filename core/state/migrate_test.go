@@ -0,0 +1,106 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Tests that the hash-based state is converted into the path-based one, with
+// all the accounts, storage slots and codes readable afterwards.
+func TestMigrateToPathScheme(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	sdb := NewDatabase(db)
+	state, _ := New(common.Hash{}, sdb, nil)
+
+	legacy := []byte{0x60, 0x01}
+	for i := byte(0); i < 64; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		state.AddBalance(addr, big.NewInt(int64(i)+1))
+		if i%4 == 0 {
+			state.SetCode(addr, []byte{i, 0x2})
+		}
+		if i%8 == 0 {
+			for j := byte(0); j < 16; j++ {
+				state.SetState(addr, common.Hash{j}, common.Hash{i, j})
+			}
+		}
+	}
+	legacyAddr := common.Address{0xff}
+	state.SetCode(legacyAddr, legacy)
+
+	root, err := state.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := sdb.TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	// Store one of the codes with the legacy scheme, sharing the key space with
+	// the trie nodes
+	hash := crypto.Keccak256Hash(legacy)
+	rawdb.DeleteCode(db, hash)
+	db.Put(hash.Bytes(), legacy)
+
+	if err := MigrateToPathScheme(db, root); err != nil {
+		t.Fatalf("failed to migrate state: %v", err)
+	}
+	if scheme := rawdb.ReadStateScheme(db); scheme != rawdb.PathScheme {
+		t.Fatalf("state scheme mismatch: have %s, want %s", scheme, rawdb.PathScheme)
+	}
+	if rawdb.HasTrieNode(db, root) {
+		t.Fatal("legacy trie node retained")
+	}
+	migrated, err := New(root, NewDatabaseWithConfig(db, &trie.Config{Scheme: rawdb.PathScheme}), nil)
+	if err != nil {
+		t.Fatalf("failed to open migrated state: %v", err)
+	}
+	for i := byte(0); i < 64; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		if have, want := migrated.GetBalance(addr), big.NewInt(int64(i)+1); have.Cmp(want) != 0 {
+			t.Fatalf("account %x: balance mismatch: have %v, want %v", addr, have, want)
+		}
+		if i%4 == 0 {
+			if have, want := migrated.GetCode(addr), []byte{i, 0x2}; !bytes.Equal(have, want) {
+				t.Fatalf("account %x: code mismatch: have %x, want %x", addr, have, want)
+			}
+		}
+		if i%8 == 0 {
+			for j := byte(0); j < 16; j++ {
+				if have, want := migrated.GetState(addr, common.Hash{j}), (common.Hash{i, j}); have != want {
+					t.Fatalf("account %x: slot %x mismatch: have %x, want %x", addr, j, have, want)
+				}
+			}
+		}
+	}
+	if have := migrated.GetCode(legacyAddr); !bytes.Equal(have, legacy) {
+		t.Fatalf("legacy code mismatch: have %x, want %x", have, legacy)
+	}
+	if err := migrated.Error(); err != nil {
+		t.Fatalf("failed to read migrated state: %v", err)
+	}
+	if err := MigrateToPathScheme(db, root); err == nil {
+		t.Fatal("migrated twice")
+	}
+}
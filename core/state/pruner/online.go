@@ -0,0 +1,576 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// targetCheckInterval is the interval at which the online pruner checks whether
+// a state usable as pruning target got persisted.
+var targetCheckInterval = 10 * time.Second
+
+const (
+	// sweepBatchDelay is the pause between two batches of deletions, leaving
+	// room for the chain to use the database.
+	sweepBatchDelay = 50 * time.Millisecond
+)
+
+// Stages of the online pruning.
+const (
+	StageIdle       = "idle"       // Waiting for the next pruning
+	StageWaiting    = "waiting"    // Waiting for a state to be persisted as pruning target
+	StageMarking    = "marking"    // Collecting the nodes of the target state
+	StageSweeping   = "sweeping"   // Deleting the nodes not belonging to the target state
+	StageCompacting = "compacting" // Compacting the database after the deletions
+)
+
+// errPruningAborted is returned if the pruning is interrupted by a shutdown.
+var errPruningAborted = errors.New("pruning aborted")
+
+// OnlineConfig contains the settings of the online pruner.
+type OnlineConfig struct {
+	Interval  time.Duration // Time between the end of a pruning and the start of the next one
+	BloomSize uint64        // Megabytes of memory allocated to the state bloom
+
+	// The states of the recent blocks kept by an archive node, either the given
+	// number of blocks or the blocks of the given period, whichever is longer.
+	// Only the latest state is kept if neither is set.
+	RetainBlocks uint64
+	RetainPeriod time.Duration
+}
+
+// Retention reports whether the pruner keeps the recent states of an archive
+// node rather than only the latest state.
+func (config OnlineConfig) Retention() bool {
+	return config.RetainBlocks > 0 || config.RetainPeriod > 0
+}
+
+// OnlineChain is the chain whose state is pruned by the online pruner.
+type OnlineChain interface {
+	CurrentHeader() *types.Header
+	GetHeaderByNumber(number uint64) *types.Header
+}
+
+// PruneStatus is the progress of the online pruner.
+type PruneStatus struct {
+	Stage        string          `json:"stage"`
+	Target       *common.Hash    `json:"target,omitempty"` // State root kept by the running pruning
+	TargetNumber *hexutil.Uint64 `json:"targetNumber,omitempty"`
+	RetainedFrom *hexutil.Uint64 `json:"retainedFrom,omitempty"` // Oldest block whose state is kept by the running pruning
+	Marked       hexutil.Uint64  `json:"marked"`                 // Entries of the target state marked to keep
+	Scanned      hexutil.Uint64  `json:"scanned"`                // Trie node entries checked in the database
+	Pruned       hexutil.Uint64  `json:"pruned"`                 // Trie node entries deleted
+	PrunedSize   hexutil.Uint64  `json:"prunedSize"`
+	Progress     float64         `json:"progress"`             // Fraction of the database swept
+	Started      hexutil.Uint64  `json:"started,omitempty"`    // Unix time the running pruning started
+	LastPruned   hexutil.Uint64  `json:"lastPruned,omitempty"` // Unix time the last pruning finished
+	Error        string          `json:"error,omitempty"`      // Error of the last pruning
+}
+
+// OnlinePruner prunes the stale state of a running node in the background.
+// Unlike the offline pruner it can't rely on the snapshot, whose layers get
+// flattened by the chain long before the target state could be iterated, so
+// it works on the tries directly:
+//
+//   - it reports every trie node written to disk from then on into the bloom
+//     filter, so that the nodes of the states built meanwhile are kept
+//   - it waits for a state processed after that point to be persisted, and
+//     picks it as the pruning target
+//   - it iterates the target state, putting all its nodes into the bloom
+//   - it iterates the states still held in memory by the chain, putting the
+//     nodes they don't share with the target into the bloom
+//   - it iterates the database, deleting the trie nodes not in the bloom in
+//     small batches
+//
+// Every state newer than the target is made of nodes of the target and of
+// nodes written after the start of the pruning, so none of them is lost. The
+// older states held in memory may still be committed by the chain, e.g. on
+// shutdown, so their nodes already flushed to disk are kept as well.
+// Contract codes are never deleted, as they are written outside of the trie
+// database.
+//
+// On archive nodes with a retention window, the states of all the blocks of the
+// window up to the target are marked: the oldest one in full, and each of the
+// following ones by the nodes it doesn't share with its predecessor.
+type OnlinePruner struct {
+	config OnlineConfig
+	db     ethdb.Database
+	triedb *trie.Database
+	chain  OnlineChain
+
+	bloom *stateBloom // Entries to keep in the running pruning, nil if none
+	lock  sync.Mutex  // Serializes the deletions with the node writes of the chain
+
+	status   PruneStatus
+	statusMu sync.RWMutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewOnlinePruner creates a pruner for the state of a running chain, whose
+// trie nodes are written to disk by the given trie database.
+func NewOnlinePruner(config OnlineConfig, db ethdb.Database, triedb *trie.Database, chain OnlineChain) *OnlinePruner {
+	if config.BloomSize < 256 {
+		log.Warn("Sanitizing bloomfilter size", "provided(MB)", config.BloomSize, "updated(MB)", 256)
+		config.BloomSize = 256
+	}
+	return &OnlinePruner{
+		config: config,
+		db:     db,
+		triedb: triedb,
+		chain:  chain,
+		status: PruneStatus{
+			Stage:      StageIdle,
+			LastPruned: hexutil.Uint64(rawdb.ReadLastOnlinePrune(db)),
+		},
+		quit: make(chan struct{}),
+	}
+}
+
+// Start implements node.Lifecycle, starting the background pruning.
+func (p *OnlinePruner) Start() error {
+	p.wg.Add(1)
+	go p.loop()
+	log.Info("Started online state pruner", "interval", p.config.Interval)
+	return nil
+}
+
+// Stop implements node.Lifecycle, aborting the running pruning.
+func (p *OnlinePruner) Stop() error {
+	close(p.quit)
+	p.wg.Wait()
+	return nil
+}
+
+// Status returns the progress of the online pruner.
+func (p *OnlinePruner) Status() *PruneStatus {
+	p.statusMu.RLock()
+	defer p.statusMu.RUnlock()
+
+	status := p.status
+	return &status
+}
+
+// APIs returns the RPC services of the online pruner.
+func (p *OnlinePruner) APIs() []rpc.API {
+	return []rpc.API{{
+		Namespace: "debug",
+		Service:   &PrunerAPI{p},
+	}}
+}
+
+// PrunerAPI offers the progress of the online pruner.
+type PrunerAPI struct {
+	p *OnlinePruner
+}
+
+// PruneStatus returns the progress of the online state pruning.
+func (api *PrunerAPI) PruneStatus() *PruneStatus {
+	return api.p.Status()
+}
+
+// loop runs a pruning whenever the configured interval elapsed since the
+// previous one.
+func (p *OnlinePruner) loop() {
+	defer p.wg.Done()
+
+	for {
+		var wait time.Duration
+		if last := p.Status().LastPruned; last != 0 {
+			wait = time.Until(time.Unix(int64(last), 0).Add(p.config.Interval))
+		}
+		if wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-p.quit:
+				return
+			}
+		}
+		err := p.prune()
+		if errors.Is(err, errPruningAborted) {
+			return
+		}
+		now := uint64(time.Now().Unix())
+		if err != nil {
+			log.Error("Online state pruning failed", "err", err)
+		} else {
+			rawdb.WriteLastOnlinePrune(p.db, now)
+		}
+		p.updateStatus(func(status *PruneStatus) {
+			// Failed prunings are retried after the interval too
+			*status = PruneStatus{Stage: StageIdle, LastPruned: hexutil.Uint64(now)}
+			if err != nil {
+				status.Error = err.Error()
+			}
+		})
+	}
+}
+
+// updateStatus applies a change to the progress of the pruner.
+func (p *OnlinePruner) updateStatus(update func(status *PruneStatus)) {
+	p.statusMu.Lock()
+	defer p.statusMu.Unlock()
+
+	update(&p.status)
+}
+
+// keep is the flush hook of the trie database, putting every node written to
+// disk during the pruning into the bloom filter.
+func (p *OnlinePruner) keep(hash common.Hash) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.bloom != nil {
+		p.bloom.Put(hash.Bytes(), nil)
+	}
+}
+
+// prune runs a pruning from start to finish.
+func (p *OnlinePruner) prune() error {
+	bloom, err := newStateBloomWithSize(p.config.BloomSize)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+
+	// Keep the nodes written from now on, and drop the bloom filter once done
+	p.lock.Lock()
+	p.bloom = bloom
+	p.lock.Unlock()
+	p.triedb.SetFlushHook(p.keep)
+
+	defer func() {
+		p.triedb.SetFlushHook(nil)
+
+		p.lock.Lock()
+		p.bloom = nil
+		p.lock.Unlock()
+	}()
+	head := p.chain.CurrentHeader().Number.Uint64()
+	p.updateStatus(func(status *PruneStatus) {
+		status.Stage, status.Started, status.Error = StageWaiting, hexutil.Uint64(start.Unix()), ""
+	})
+	target, err := p.waitTarget(head)
+	if err != nil {
+		return err
+	}
+	number := hexutil.Uint64(target.Number.Uint64())
+	p.updateStatus(func(status *PruneStatus) {
+		status.Stage, status.Target, status.TargetNumber = StageMarking, &target.Root, &number
+	})
+	log.Info("Marking state for online pruning", "number", target.Number, "root", target.Root)
+
+	var marked uint64
+	if p.config.Retention() {
+		first := p.retainedFrom(target)
+		from := hexutil.Uint64(first)
+		p.updateStatus(func(status *PruneStatus) {
+			status.RetainedFrom = &from
+		})
+		log.Info("Marking retained states for online pruning", "from", first, "to", target.Number)
+		marked, err = p.extractStates(first, target, bloom)
+	} else {
+		marked, err = extractState(p.db, target.Root, bloom, p.quit)
+	}
+	if err != nil {
+		return err
+	}
+	if err := extractGenesis(p.db, bloom); err != nil {
+		return err
+	}
+	// The states the chain still holds in memory may be older than the target
+	// and rely on nodes flushed to disk before the pruning started, keep them
+	inMemory, err := p.extractMemoryStates(target.Root, bloom)
+	marked += inMemory
+	if err != nil {
+		return err
+	}
+	p.updateStatus(func(status *PruneStatus) {
+		status.Stage, status.Marked = StageSweeping, hexutil.Uint64(marked)
+	})
+	log.Info("Sweeping stale state", "marked", marked, "elapsed", common.PrettyDuration(time.Since(start)))
+
+	count, err := p.sweep(bloom)
+	if err != nil {
+		return err
+	}
+	// Compact the database if a lot got deleted, range by range so that the
+	// chain isn't stalled for too long
+	if count >= rangeCompactionThreshold {
+		p.updateStatus(func(status *PruneStatus) {
+			status.Stage = StageCompacting
+		})
+		for b := 0x00; b <= 0xf0; b += 0x10 {
+			var (
+				start = []byte{byte(b)}
+				end   = []byte{byte(b + 0x10)}
+			)
+			if b == 0xf0 {
+				end = nil
+			}
+			select {
+			case <-p.quit:
+				return errPruningAborted
+			default:
+			}
+			if err := p.db.Compact(start, end); err != nil {
+				return fmt.Errorf("database compaction failed: %v", err)
+			}
+		}
+	}
+	log.Info("Online state pruning successful", "pruned", count, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// waitTarget waits until the state of a block newer than the given one is
+// persisted, and returns its header.
+func (p *OnlinePruner) waitTarget(after uint64) (*types.Header, error) {
+	ticker := time.NewTicker(targetCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		for number := p.chain.CurrentHeader().Number.Uint64(); number > after; number-- {
+			header := p.chain.GetHeaderByNumber(number)
+			if header == nil {
+				break // Reorg in progress, check again later
+			}
+			// The root is persisted after all the rest of the trie
+			if rawdb.HasTrieNode(p.db, header.Root) {
+				return header, nil
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-p.quit:
+			return nil, errPruningAborted
+		}
+	}
+}
+
+// extractMemoryStates puts into the bloom filter the trie nodes and contract
+// codes of the states referenced in memory by the chain, which aren't part of
+// the target state. Their nodes may have been partially flushed to disk, and
+// they may still be committed, e.g. on shutdown. States dereferenced while
+// being marked are no longer needed and skipped.
+func (p *OnlinePruner) extractMemoryStates(target common.Hash, bloom *stateBloom) (uint64, error) {
+	var count uint64
+	for _, root := range p.triedb.Roots() {
+		if root == target {
+			continue
+		}
+		marked, err := extractStateDiff(p.triedb, target, root, bloom, p.quit)
+		count += marked
+		if err != nil {
+			var missing *trie.MissingNodeError
+			if errors.As(err, &missing) && !p.referenced(root) {
+				continue
+			}
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// referenced returns whether the state root is still referenced in memory.
+func (p *OnlinePruner) referenced(root common.Hash) bool {
+	for _, r := range p.triedb.Roots() {
+		if r == root {
+			return true
+		}
+	}
+	return false
+}
+
+// sweep deletes all the trie nodes not in the bloom filter, and returns the
+// number of deleted nodes.
+func (p *OnlinePruner) sweep(bloom *stateBloom) (uint64, error) {
+	type staleNode struct {
+		key  []byte
+		size int
+	}
+	var (
+		count  uint64
+		size   uint64
+		stale  []staleNode
+		logged = time.Now()
+	)
+	// flush deletes the collected stale nodes. The nodes are checked again
+	// against the bloom filter with the chain writes blocked, as they may have
+	// been written again meanwhile.
+	flush := func() error {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+
+		batch := p.db.NewBatch()
+		for _, node := range stale {
+			if ok, _ := bloom.Contain(node.key); !ok {
+				batch.Delete(node.key)
+				count++
+				size += uint64(node.size)
+			}
+		}
+		stale = stale[:0]
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		p.updateStatus(func(status *PruneStatus) {
+			status.Pruned, status.PrunedSize = hexutil.Uint64(count), hexutil.Uint64(size)
+		})
+		return nil
+	}
+	var next []byte
+	for {
+		// Recreate the iterator after every batch to allow the underlying
+		// compactor to delete the entries
+		iter := p.db.NewIterator(nil, next)
+		for next = nil; iter.Next(); {
+			key := iter.Key()
+			if len(key) != common.HashLength {
+				continue
+			}
+			p.updateStatus(func(status *PruneStatus) {
+				status.Scanned++
+				status.Progress = float64(binary.BigEndian.Uint64(key[:8])) / math.MaxUint64
+			})
+			if ok, _ := bloom.Contain(key); ok {
+				continue
+			}
+			stale = append(stale, staleNode{key: common.CopyBytes(key), size: len(key) + len(iter.Value())})
+			if len(stale)*common.HashLength >= ethdb.IdealBatchSize {
+				next = stale[len(stale)-1].key
+				break
+			}
+		}
+		err := iter.Error()
+		iter.Release()
+		if err != nil {
+			return count, err
+		}
+		if err := flush(); err != nil {
+			return count, err
+		}
+		if next == nil {
+			return count, nil
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Pruning stale state", "nodes", count, "size", common.StorageSize(size))
+			logged = time.Now()
+		}
+		// Leave room for the chain between the batches
+		select {
+		case <-time.After(sweepBatchDelay):
+		case <-p.quit:
+			return count, errPruningAborted
+		}
+	}
+}
+
+// retainedFrom returns the number of the oldest block of the retention window
+// ending at the given head.
+func (p *OnlinePruner) retainedFrom(head *types.Header) uint64 {
+	number := head.Number.Uint64()
+
+	first := number
+	if n := p.config.RetainBlocks; n > 0 {
+		if n > number {
+			first = 0
+		} else {
+			first = number - n + 1
+		}
+	}
+	if period := uint64(p.config.RetainPeriod / time.Second); period > 0 {
+		// Binary search the oldest block within the period
+		var cutoff uint64
+		if head.Time > period {
+			cutoff = head.Time - period
+		}
+		n := sort.Search(int(number+1), func(i int) bool {
+			header := p.chain.GetHeaderByNumber(uint64(i))
+			return header == nil || header.Time >= cutoff
+		})
+		if uint64(n) < first {
+			first = uint64(n)
+		}
+	}
+	return first
+}
+
+// extractStates puts into the bloom filter the trie nodes and contract codes of
+// the states of all the blocks from first to the target. The blocks whose state
+// wasn't persisted, such as the ones processed before the node became an archive
+// node, are skipped.
+func (p *OnlinePruner) extractStates(first uint64, target *types.Header, bloom *stateBloom) (uint64, error) {
+	var (
+		count  uint64
+		parent common.Hash
+		logged = time.Now()
+	)
+	for number := first; number <= target.Number.Uint64(); number++ {
+		header := target
+		if number < target.Number.Uint64() {
+			if header = p.chain.GetHeaderByNumber(number); header == nil {
+				return count, fmt.Errorf("missing header #%d", number)
+			}
+		}
+		if header.Root == parent || !rawdb.HasTrieNode(p.db, header.Root) {
+			continue
+		}
+		var (
+			marked uint64
+			err    error
+		)
+		if parent == (common.Hash{}) {
+			marked, err = extractState(p.db, header.Root, bloom, p.quit)
+		} else {
+			marked, err = extractStateDiff(trie.NewDatabase(p.db), parent, header.Root, bloom, p.quit)
+		}
+		count += marked
+		if err != nil {
+			return count, err
+		}
+		parent = header.Root
+
+		p.updateStatus(func(status *PruneStatus) {
+			status.Marked = hexutil.Uint64(count)
+		})
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Marking retained states", "number", number, "marked", count)
+			logged = time.Now()
+		}
+	}
+	if parent != target.Root {
+		return count, fmt.Errorf("missing target state %x", target.Root)
+	}
+	return count, nil
+}
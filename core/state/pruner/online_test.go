@@ -0,0 +1,279 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pruner
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// newPrunerTestChain creates a chain with the given cache settings, along with
+// blocks changing its state, each one funding a new account and updating the
+// storage of a contract.
+func newPrunerTestChain(t *testing.T, cacheConfig *core.CacheConfig, n int) (*core.BlockChain, ethdb.Database, []*types.Block) {
+	var (
+		key, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		address  = crypto.PubkeyToAddress(key.PublicKey)
+		contract = common.HexToAddress("0xc0de")
+		gspec    = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc: core.GenesisAlloc{
+				address: {Balance: big.NewInt(params.Ether)},
+				// NUMBER NUMBER SSTORE, storing the block number in the slot of its value
+				contract: {Code: []byte{byte(vm.NUMBER), byte(vm.NUMBER), byte(vm.SSTORE)}, Balance: common.Big0},
+			},
+		}
+		engine = ethash.NewFaker()
+	)
+	gendb := rawdb.NewMemoryDatabase()
+	blocks, _ := core.GenerateChain(params.TestChainConfig, gspec.MustCommit(gendb), engine, gendb, n, func(i int, b *core.BlockGen) {
+		signer := types.HomesteadSigner{}
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(address), common.BigToAddress(big.NewInt(int64(0x10000+i))), big.NewInt(1), params.TxGas, b.BaseFee(), nil), signer, key)
+		b.AddTx(tx)
+		tx, _ = types.SignTx(types.NewTransaction(b.TxNonce(address), contract, common.Big0, 50000, b.BaseFee(), nil), signer, key)
+		b.AddTx(tx)
+	})
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, cacheConfig, params.TestChainConfig, engine, vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	return chain, db, blocks
+}
+
+// newTestOnlinePruner creates an online pruner with a small bloom filter and
+// starts a pruning, returning once it waits for its target.
+func newTestOnlinePruner(t *testing.T, config OnlineConfig, db ethdb.Database, chain *core.BlockChain) (*OnlinePruner, chan error) {
+	p := NewOnlinePruner(config, db, chain.StateCache().TrieDB(), chain)
+	p.config.BloomSize = 1
+
+	done := make(chan error, 1)
+	go func() { done <- p.prune() }()
+
+	// Wait for the pruning to keep the nodes written to disk
+	for p.Status().Stage != StageWaiting {
+		time.Sleep(time.Millisecond)
+	}
+	return p, done
+}
+
+// checkState iterates the full state of the given root, returning any error
+// hit on the way.
+func checkState(triedb *trie.Database, root common.Hash) error {
+	t, err := trie.NewSecure(common.Hash{}, root, triedb)
+	if err != nil {
+		return err
+	}
+	it := t.NodeIterator(nil)
+	for it.Next(true) {
+		if !it.Leaf() {
+			continue
+		}
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(it.LeafBlob(), &acc); err != nil {
+			return err
+		}
+		if acc.Root == emptyRoot {
+			continue
+		}
+		st, err := trie.NewSecure(common.BytesToHash(it.LeafKey()), acc.Root, triedb)
+		if err != nil {
+			return err
+		}
+		stIt := st.NodeIterator(nil)
+		for stIt.Next(true) {
+		}
+		if err := stIt.Error(); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// Tests that the online pruner keeps the states the chain holds in memory, even
+// if they are older than the pruning target and were partially flushed to disk
+// before the pruning started.
+func TestOnlinePrunerMemoryStates(t *testing.T) {
+	defer func(interval time.Duration) { targetCheckInterval = interval }(targetCheckInterval)
+	targetCheckInterval = 10 * time.Millisecond
+
+	chain, db, blocks := newPrunerTestChain(t, &core.CacheConfig{
+		TrieCleanLimit: 16,
+		TrieDirtyLimit: 256,
+		TrieTimeLimit:  5 * time.Minute,
+	}, 12)
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks[:10]); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", n, err)
+	}
+	// Flush the states to disk and forget the oldest ones, as if garbage
+	// collected by the chain
+	triedb := chain.StateCache().TrieDB()
+	if err := triedb.Cap(0); err != nil {
+		t.Fatalf("failed to flush states: %v", err)
+	}
+	for _, block := range blocks[:5] {
+		triedb.Dereference(block.Root())
+	}
+	p, done := newTestOnlinePruner(t, OnlineConfig{}, db, chain)
+
+	// Persist a newer state, to be picked as pruning target
+	if n, err := chain.InsertChain(blocks[10:]); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", n, err)
+	}
+	if err := triedb.Cap(0); err != nil {
+		t.Fatalf("failed to flush states: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("pruning failed: %v", err)
+	}
+	if status := p.Status(); status.TargetNumber == nil || uint64(*status.TargetNumber) != 12 || status.Pruned == 0 {
+		t.Fatalf("pruning status mismatch: %+v", status)
+	}
+	// The forgotten states are pruned, the ones still in memory are intact
+	for i, block := range blocks {
+		err := checkState(triedb, block.Root())
+		if i < 5 && err == nil {
+			t.Errorf("state #%d not pruned", block.NumberU64())
+		}
+		if i >= 5 && err != nil {
+			t.Errorf("state #%d damaged: %v", block.NumberU64(), err)
+		}
+	}
+	// Committing an older state kept in memory, as done on shutdown, leaves it
+	// complete on disk
+	if err := triedb.Commit(blocks[6].Root(), false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := checkState(trie.NewDatabase(db), blocks[6].Root()); err != nil {
+		t.Fatalf("committed state damaged: %v", err)
+	}
+}
+
+// Tests that the online pruner of an archive node keeps every state of the
+// retention window, and prunes the older ones.
+func TestOnlinePrunerRetention(t *testing.T) {
+	defer func(interval time.Duration) { targetCheckInterval = interval }(targetCheckInterval)
+	targetCheckInterval = 10 * time.Millisecond
+
+	chain, db, blocks := newPrunerTestChain(t, &core.CacheConfig{
+		TrieCleanLimit:    16,
+		TrieDirtyDisabled: true,
+		TrieTimeLimit:     5 * time.Minute,
+	}, 12)
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks[:10]); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", n, err)
+	}
+	p, done := newTestOnlinePruner(t, OnlineConfig{RetainBlocks: 4}, db, chain)
+
+	if n, err := chain.InsertChain(blocks[10:]); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", n, err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("pruning failed: %v", err)
+	}
+	status := p.Status()
+	if status.TargetNumber == nil || uint64(*status.TargetNumber) != 12 || status.RetainedFrom == nil || uint64(*status.RetainedFrom) != 9 {
+		t.Fatalf("retention window mismatch: %+v", status)
+	}
+	if status.Pruned == 0 {
+		t.Fatalf("nothing pruned")
+	}
+	triedb := trie.NewDatabase(db)
+	for _, block := range blocks {
+		err := checkState(triedb, block.Root())
+		if block.NumberU64() < 9 && err == nil {
+			t.Errorf("state #%d not pruned", block.NumberU64())
+		}
+		if block.NumberU64() >= 9 && err != nil {
+			t.Errorf("retained state #%d damaged: %v", block.NumberU64(), err)
+		}
+	}
+	if err := checkState(triedb, chain.Genesis().Root()); err != nil {
+		t.Errorf("genesis state damaged: %v", err)
+	}
+}
+
+// Tests that the states of a retention window are marked incrementally, every
+// state after the first one only adding the nodes it doesn't share with its
+// parent, and that sweeping the unmarked nodes leaves them all readable.
+func TestExtractStates(t *testing.T) {
+	chain, db, blocks := newPrunerTestChain(t, &core.CacheConfig{
+		TrieCleanLimit:    16,
+		TrieDirtyDisabled: true,
+		TrieTimeLimit:     5 * time.Minute,
+	}, 10)
+	defer chain.Stop()
+
+	if n, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("block %d: failed to insert: %v", n, err)
+	}
+	p := NewOnlinePruner(OnlineConfig{RetainBlocks: 4}, db, chain.StateCache().TrieDB(), chain)
+
+	head := chain.CurrentHeader()
+	first := p.retainedFrom(head)
+	if first != 7 {
+		t.Fatalf("retention window start mismatch: have %d, want 7", first)
+	}
+	bloom, err := newStateBloomWithSize(1)
+	if err != nil {
+		t.Fatalf("failed to create bloom: %v", err)
+	}
+	marked, err := p.extractStates(first, head, bloom)
+	if err != nil {
+		t.Fatalf("failed to mark retained states: %v", err)
+	}
+	// Marking the window must be cheaper than marking each state in full
+	full, err := extractState(db, head.Root, bloom, nil)
+	if err != nil {
+		t.Fatalf("failed to mark head state: %v", err)
+	}
+	if marked >= 4*full {
+		t.Fatalf("retained states not marked incrementally: marked %d, full state %d", marked, full)
+	}
+	if _, err := p.sweep(bloom); err != nil {
+		t.Fatalf("sweep failed: %v", err)
+	}
+	triedb := trie.NewDatabase(db)
+	for _, block := range blocks {
+		err := checkState(triedb, block.Root())
+		if block.NumberU64() < first && err == nil {
+			t.Errorf("state #%d not pruned", block.NumberU64())
+		}
+		if block.NumberU64() >= first && err != nil {
+			t.Errorf("retained state #%d damaged: %v", block.NumberU64(), err)
+		}
+	}
+}
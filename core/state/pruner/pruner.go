@@ -85,6 +85,9 @@ type Pruner struct {
 
 // NewPruner creates the pruner instance.
 func NewPruner(db ethdb.Database, datadir, trieCachePath string, bloomSize uint64) (*Pruner, error) {
+	if rawdb.ReadStateScheme(db) == rawdb.PathScheme {
+		return nil, errors.New("the path state scheme keeps a single state, pruning is not needed")
+	}
 	headBlock := rawdb.ReadHeadBlock(db)
 	if headBlock == nil {
 		return nil, errors.New("Failed to load head block")
@@ -410,47 +413,157 @@ func extractGenesis(db ethdb.Database, stateBloom *stateBloom) error {
 	if genesis == nil {
 		return errors.New("missing genesis block")
 	}
-	t, err := trie.NewSecure(common.Hash{}, genesis.Root(), trie.NewDatabase(db))
+	_, err := extractState(db, genesis.Root(), stateBloom, nil)
+	return err
+}
+
+// extractState iterates the state trie of the given root along with all its
+// storage tries, and puts the trie nodes and contract codes into the bloom
+// filter. The extraction is aborted once the interrupt channel is closed. The
+// number of entries put into the bloom filter is returned.
+func extractState(db ethdb.Database, root common.Hash, stateBloom *stateBloom, interrupt <-chan struct{}) (uint64, error) {
+	t, err := trie.NewSecure(common.Hash{}, root, trie.NewDatabase(db))
 	if err != nil {
-		return err
+		return 0, err
 	}
+	var (
+		count    uint64
+		storages = make(map[common.Hash]struct{})
+	)
 	accIter := t.NodeIterator(nil)
 	for accIter.Next(true) {
+		select {
+		case <-interrupt:
+			return count, errPruningAborted
+		default:
+		}
 		hash := accIter.Hash()
 
 		// Embedded nodes don't have hash.
 		if hash != (common.Hash{}) {
 			stateBloom.Put(hash.Bytes(), nil)
+			count++
 		}
 		// If it's a leaf node, yes we are touching an account,
 		// dig into the storage trie further.
 		if accIter.Leaf() {
 			var acc types.StateAccount
 			if err := rlp.DecodeBytes(accIter.LeafBlob(), &acc); err != nil {
-				return err
+				return count, err
 			}
-			if acc.Root != emptyRoot {
+			// Storage tries shared by multiple accounts are only iterated once
+			if _, ok := storages[acc.Root]; acc.Root != emptyRoot && !ok {
+				storages[acc.Root] = struct{}{}
+
 				storageTrie, err := trie.NewSecure(common.BytesToHash(accIter.LeafKey()), acc.Root, trie.NewDatabase(db))
 				if err != nil {
-					return err
+					return count, err
 				}
 				storageIter := storageTrie.NodeIterator(nil)
 				for storageIter.Next(true) {
+					select {
+					case <-interrupt:
+						return count, errPruningAborted
+					default:
+					}
 					hash := storageIter.Hash()
 					if hash != (common.Hash{}) {
 						stateBloom.Put(hash.Bytes(), nil)
+						count++
 					}
 				}
 				if storageIter.Error() != nil {
-					return storageIter.Error()
+					return count, storageIter.Error()
 				}
 			}
 			if !bytes.Equal(acc.CodeHash, emptyCode) {
 				stateBloom.Put(acc.CodeHash, nil)
+				count++
+			}
+		}
+	}
+	return count, accIter.Error()
+}
+
+// extractStateDiff puts into the bloom filter the trie nodes and contract codes
+// of the state of the given root which aren't part of the parent state. It's
+// meant to extend the bloom filter already holding the parent state to another
+// state, usually derived from it. The number of entries put into the bloom
+// filter is returned.
+func extractStateDiff(triedb *trie.Database, parent, root common.Hash, stateBloom *stateBloom, interrupt <-chan struct{}) (uint64, error) {
+	parentTrie, err := trie.New(common.Hash{}, parent, triedb)
+	if err != nil {
+		return 0, err
+	}
+	t, err := trie.New(common.Hash{}, root, triedb)
+	if err != nil {
+		return 0, err
+	}
+	var count uint64
+	accIter, _ := trie.NewDifferenceIterator(parentTrie.NodeIterator(nil), t.NodeIterator(nil))
+	for accIter.Next(true) {
+		select {
+		case <-interrupt:
+			return count, errPruningAborted
+		default:
+		}
+		// Embedded nodes don't have hash.
+		if hash := accIter.Hash(); hash != (common.Hash{}) {
+			stateBloom.Put(hash.Bytes(), nil)
+			count++
+		}
+		if !accIter.Leaf() {
+			continue
+		}
+		var acc types.StateAccount
+		if err := rlp.DecodeBytes(accIter.LeafBlob(), &acc); err != nil {
+			return count, err
+		}
+		// Only the changes of the storage trie have to be marked too
+		parentRoot := emptyRoot
+		blob, err := parentTrie.TryGet(accIter.LeafKey())
+		if err != nil {
+			return count, err
+		}
+		if len(blob) > 0 {
+			var parentAcc types.StateAccount
+			if err := rlp.DecodeBytes(blob, &parentAcc); err != nil {
+				return count, err
+			}
+			parentRoot = parentAcc.Root
+		}
+		if acc.Root != emptyRoot && acc.Root != parentRoot {
+			owner := common.BytesToHash(accIter.LeafKey())
+			parentStorage, err := trie.New(owner, parentRoot, triedb)
+			if err != nil {
+				return count, err
 			}
+			storage, err := trie.New(owner, acc.Root, triedb)
+			if err != nil {
+				return count, err
+			}
+			storageIter, _ := trie.NewDifferenceIterator(parentStorage.NodeIterator(nil), storage.NodeIterator(nil))
+			for storageIter.Next(true) {
+				select {
+				case <-interrupt:
+					return count, errPruningAborted
+				default:
+				}
+				if hash := storageIter.Hash(); hash != (common.Hash{}) {
+					stateBloom.Put(hash.Bytes(), nil)
+					count++
+				}
+			}
+			if storageIter.Error() != nil {
+				return count, storageIter.Error()
+			}
+		}
+		if !bytes.Equal(acc.CodeHash, emptyCode) {
+			stateBloom.Put(acc.CodeHash, nil)
+			count++
 		}
 	}
-	return accIter.Error()
+	return count, accIter.Error()
 }
 
 func bloomFilterName(datadir string, hash common.Hash) string {
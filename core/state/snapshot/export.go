@@ -0,0 +1,315 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// exportMagic identifies the portable state files.
+	exportMagic = "gethstate"
+
+	// ExportVersion is the version of the portable state file format written by
+	// Export and accepted by Import.
+	ExportVersion uint64 = 1
+
+	// exportMaxEntrySize is the maximum encoded size of a single entry of a
+	// portable state file, well above the largest contract code.
+	exportMaxEntrySize = 1 << 20
+)
+
+// Record kinds of the portable state file. The accounts follow each other in
+// ascending hash order, each one followed by the code it references, unless it
+// was already written for a previous account, and by its storage slots in
+// ascending hash order. The file is terminated by an end record.
+const (
+	exportAccount uint64 = iota + 1 // account hash -> slim account RLP
+	exportCode                      // code hash -> code
+	exportSlot                      // slot hash -> slot value RLP, of the preceding account
+	exportEnd                       // state root -> nothing
+)
+
+// exportHeader is the first entry of a portable state file.
+type exportHeader struct {
+	Magic   string
+	Version uint64
+	Root    common.Hash
+}
+
+// exportRecord is a single state entry of a portable state file.
+type exportRecord struct {
+	Kind uint64
+	Hash common.Hash
+	Data []byte
+}
+
+// Export writes the flat state at the given root into a portable, versioned
+// file containing every account, storage slot and contract code, which can be
+// verified against the root and loaded into an empty database with Import.
+func (t *Tree) Export(w io.Writer, root common.Hash) error {
+	if err := rlp.Encode(w, &exportHeader{Magic: exportMagic, Version: ExportVersion, Root: root}); err != nil {
+		return err
+	}
+	accIt, err := t.AccountIterator(root, common.Hash{})
+	if err != nil {
+		return err
+	}
+	defer accIt.Release()
+
+	var (
+		start    = time.Now()
+		logged   = time.Now()
+		codes    = make(map[common.Hash]struct{})
+		accounts uint64
+		slots    uint64
+	)
+	for accIt.Next() {
+		blob := accIt.Account()
+		if err := rlp.Encode(w, &exportRecord{Kind: exportAccount, Hash: accIt.Hash(), Data: blob}); err != nil {
+			return err
+		}
+		account, err := FullAccount(blob)
+		if err != nil {
+			return err
+		}
+		if codeHash := common.BytesToHash(account.CodeHash); codeHash != emptyCode {
+			if _, ok := codes[codeHash]; !ok {
+				code := rawdb.ReadCode(t.diskdb, codeHash)
+				if len(code) == 0 {
+					return fmt.Errorf("missing code %x of account %x", codeHash, accIt.Hash())
+				}
+				if err := rlp.Encode(w, &exportRecord{Kind: exportCode, Hash: codeHash, Data: code}); err != nil {
+					return err
+				}
+				codes[codeHash] = struct{}{}
+			}
+		}
+		if common.BytesToHash(account.Root) != emptyRoot {
+			stIt, err := t.StorageIterator(root, accIt.Hash(), common.Hash{})
+			if err != nil {
+				return err
+			}
+			for stIt.Next() {
+				if err := rlp.Encode(w, &exportRecord{Kind: exportSlot, Hash: stIt.Hash(), Data: stIt.Slot()}); err != nil {
+					stIt.Release()
+					return err
+				}
+				slots++
+			}
+			err = stIt.Error()
+			stIt.Release()
+			if err != nil {
+				return err
+			}
+		}
+		accounts++
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Exporting state", "at", accIt.Hash(), "accounts", accounts, "slots", slots, "codes", len(codes), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	if err := accIt.Error(); err != nil {
+		return err
+	}
+	if err := rlp.Encode(w, &exportRecord{Kind: exportEnd, Hash: root}); err != nil {
+		return err
+	}
+	log.Info("Exported state", "root", root, "accounts", accounts, "slots", slots, "codes", len(codes), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// Import loads a portable state file written by Export into the database,
+// rebuilding the tries and the snapshot, and returns the state root once the
+// imported state has been verified against it. A failed import may leave some
+// state behind, which is unreachable unless the same file is imported again.
+func Import(db ethdb.Database, r io.Reader) (common.Hash, error) {
+	// The tries are rebuilt by hash, which only the hash scheme can use
+	if rawdb.ReadStateScheme(db) == rawdb.PathScheme {
+		return common.Hash{}, errors.New("state import is not supported by the path state scheme")
+	}
+	stream := rlp.NewStream(r, 0)
+
+	var header exportHeader
+	if err := decodeExportEntry(stream, &header); err != nil {
+		return common.Hash{}, fmt.Errorf("invalid state file header: %v", err)
+	}
+	if header.Magic != exportMagic {
+		return common.Hash{}, errors.New("not a state file")
+	}
+	if header.Version != ExportVersion {
+		return common.Hash{}, fmt.Errorf("unsupported state file version %d, want %d", header.Version, ExportVersion)
+	}
+	var (
+		batch   = db.NewBatch()
+		accTrie = trie.NewStackTrie(batch)
+		stTrie  *trie.StackTrie
+
+		start    = time.Now()
+		logged   = time.Now()
+		accHash  common.Hash
+		account  *Account
+		lastSlot common.Hash
+		hasSlot  bool                         // Whether lastSlot was imported for the pending account
+		codes    = make(map[common.Hash]bool) // code hash -> whether it was imported
+		accounts uint64
+		slots    uint64
+	)
+	// finishAccount inserts the pending account into the account trie once its
+	// storage was fully imported.
+	finishAccount := func() error {
+		if account == nil {
+			return nil
+		}
+		if stTrie != nil {
+			root, err := stTrie.Commit()
+			if err != nil {
+				return err
+			}
+			if root != common.BytesToHash(account.Root) {
+				return fmt.Errorf("storage root mismatch of account %x: have %x, want %x", accHash, root, account.Root)
+			}
+			stTrie = nil
+		}
+		blob, err := rlp.EncodeToBytes(account)
+		if err != nil {
+			return err
+		}
+		if err := accTrie.TryUpdate(accHash[:], blob); err != nil {
+			return err
+		}
+		account = nil
+		return nil
+	}
+	for {
+		var record exportRecord
+		if err := decodeExportEntry(stream, &record); err != nil {
+			if err == io.EOF {
+				return common.Hash{}, errors.New("truncated state file")
+			}
+			return common.Hash{}, err
+		}
+		switch record.Kind {
+		case exportAccount:
+			if err := finishAccount(); err != nil {
+				return common.Hash{}, err
+			}
+			if accounts > 0 && bytes.Compare(record.Hash[:], accHash[:]) <= 0 {
+				return common.Hash{}, fmt.Errorf("account %x out of order", record.Hash)
+			}
+			full, err := FullAccount(record.Data)
+			if err != nil {
+				return common.Hash{}, fmt.Errorf("invalid account %x: %v", record.Hash, err)
+			}
+			accHash, account, lastSlot, hasSlot = record.Hash, &full, common.Hash{}, false
+			if common.BytesToHash(full.Root) != emptyRoot {
+				stTrie = trie.NewStackTrieWithOwner(batch, accHash)
+			}
+			if codeHash := common.BytesToHash(full.CodeHash); codeHash != emptyCode {
+				if _, ok := codes[codeHash]; !ok {
+					codes[codeHash] = false
+				}
+			}
+			rawdb.WriteAccountSnapshot(batch, accHash, record.Data)
+			accounts++
+
+		case exportCode:
+			if crypto.Keccak256Hash(record.Data) != record.Hash {
+				return common.Hash{}, fmt.Errorf("code hash mismatch %x", record.Hash)
+			}
+			rawdb.WriteCode(batch, record.Hash, record.Data)
+			codes[record.Hash] = true
+
+		case exportSlot:
+			if stTrie == nil {
+				return common.Hash{}, fmt.Errorf("unexpected storage slot %x", record.Hash)
+			}
+			if hasSlot && bytes.Compare(record.Hash[:], lastSlot[:]) <= 0 {
+				return common.Hash{}, fmt.Errorf("storage slot %x of account %x out of order", record.Hash, accHash)
+			}
+			if err := stTrie.TryUpdate(record.Hash[:], record.Data); err != nil {
+				return common.Hash{}, err
+			}
+			rawdb.WriteStorageSnapshot(batch, accHash, record.Hash, record.Data)
+			lastSlot, hasSlot = record.Hash, true
+			slots++
+
+		case exportEnd:
+			if err := finishAccount(); err != nil {
+				return common.Hash{}, err
+			}
+			root, err := accTrie.Commit()
+			if err != nil {
+				return common.Hash{}, err
+			}
+			if root != header.Root || record.Hash != header.Root {
+				return common.Hash{}, fmt.Errorf("state root mismatch: have %x, want %x", root, header.Root)
+			}
+			for hash, imported := range codes {
+				if !imported {
+					return common.Hash{}, fmt.Errorf("missing code %x", hash)
+				}
+			}
+			// The imported flat state is a complete disk layer of the snapshot
+			rawdb.WriteSnapshotRoot(batch, root)
+			journalProgress(batch, nil, nil)
+			if err := batch.Write(); err != nil {
+				return common.Hash{}, err
+			}
+			log.Info("Imported state", "root", root, "accounts", accounts, "slots", slots, "codes", len(codes), "elapsed", common.PrettyDuration(time.Since(start)))
+			return root, nil
+
+		default:
+			return common.Hash{}, fmt.Errorf("unknown state record kind %d", record.Kind)
+		}
+		if batch.ValueSize() > ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return common.Hash{}, err
+			}
+			batch.Reset()
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Importing state", "at", accHash, "accounts", accounts, "slots", slots, "codes", len(codes), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+}
+
+// decodeExportEntry decodes the next entry of a portable state file, rejecting
+// oversized entries before allocating them.
+func decodeExportEntry(stream *rlp.Stream, val interface{}) error {
+	_, size, err := stream.Kind()
+	if err != nil {
+		return err
+	}
+	if size > exportMaxEntrySize {
+		return fmt.Errorf("state file entry of %d bytes exceeds limit of %d", size, exportMaxEntrySize)
+	}
+	return stream.Decode(val)
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package snapshot
+
+import (
+	"bytes"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+// Tests that a state exported into a portable file can be imported into an
+// empty database, and that tampered files are rejected.
+func TestExportImport(t *testing.T) {
+	var (
+		helper   = newHelper()
+		code     = []byte{0x60, 0x01, 0x60, 0x02}
+		codeHash = crypto.Keccak256(code)
+	)
+	rawdb.WriteCode(helper.diskdb, common.BytesToHash(codeHash), code)
+
+	stRoot := helper.makeStorageTrie(common.Hash{}, hashData([]byte("acc-1")), []string{"key-1", "key-2", "key-3"}, []string{"val-1", "val-2", "val-3"}, true)
+	helper.addTrieAccount("acc-1", &Account{Balance: big.NewInt(1), Root: stRoot, CodeHash: codeHash})
+	helper.addTrieAccount("acc-2", &Account{Balance: big.NewInt(2), Root: emptyRoot.Bytes(), CodeHash: emptyCode.Bytes()})
+	helper.addTrieAccount("acc-3", &Account{Balance: big.NewInt(3), Root: emptyRoot.Bytes(), CodeHash: codeHash})
+
+	root, snap := helper.CommitAndGenerate()
+	select {
+	case <-snap.genPending:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("Snapshot generation failed")
+	}
+	defer func() {
+		stop := make(chan *generatorStats)
+		snap.genAbort <- stop
+		<-stop
+	}()
+	tree := &Tree{diskdb: helper.diskdb, triedb: helper.triedb, layers: map[common.Hash]snapshot{root: snap}}
+
+	var buf bytes.Buffer
+	if err := tree.Export(&buf, root); err != nil {
+		t.Fatalf("failed to export state: %v", err)
+	}
+	file := buf.Bytes()
+
+	db := rawdb.NewMemoryDatabase()
+	imported, err := Import(db, bytes.NewReader(file))
+	if err != nil {
+		t.Fatalf("failed to import state: %v", err)
+	}
+	if imported != root {
+		t.Fatalf("imported root mismatch: have %x, want %x", imported, root)
+	}
+	if have := rawdb.ReadSnapshotRoot(db); have != root {
+		t.Errorf("snapshot root mismatch: have %x, want %x", have, root)
+	}
+	if have := rawdb.ReadCode(db, common.BytesToHash(codeHash)); !bytes.Equal(have, code) {
+		t.Errorf("code mismatch: have %x, want %x", have, code)
+	}
+	// The imported tries must be complete
+	accTrie, err := trie.NewSecure(common.Hash{}, root, trie.NewDatabase(db))
+	if err != nil {
+		t.Fatalf("failed to open imported trie: %v", err)
+	}
+	it := trie.NewIterator(accTrie.NodeIterator(nil))
+	accounts := 0
+	for it.Next() {
+		var acc Account
+		if err := rlp.DecodeBytes(it.Value, &acc); err != nil {
+			t.Fatalf("invalid account: %v", err)
+		}
+		stTrie, err := trie.NewSecure(common.BytesToHash(it.Key), common.BytesToHash(acc.Root), trie.NewDatabase(db))
+		if err != nil {
+			t.Fatalf("failed to open imported storage trie: %v", err)
+		}
+		stIt := trie.NewIterator(stTrie.NodeIterator(nil))
+		for stIt.Next() {
+		}
+		if stIt.Err != nil {
+			t.Fatalf("imported storage trie incomplete: %v", stIt.Err)
+		}
+		accounts++
+	}
+	if it.Err != nil {
+		t.Fatalf("imported trie incomplete: %v", it.Err)
+	}
+	if accounts != 3 {
+		t.Errorf("imported accounts mismatch: have %d, want 3", accounts)
+	}
+	// Files altered or cut short must be rejected
+	tampered := common.CopyBytes(file)
+	tampered[bytes.Index(tampered, []byte("val-2"))] = 'V'
+	if _, err := Import(rawdb.NewMemoryDatabase(), bytes.NewReader(tampered)); err == nil {
+		t.Errorf("tampered state file imported")
+	}
+	if _, err := Import(rawdb.NewMemoryDatabase(), bytes.NewReader(file[:len(file)-40])); err == nil {
+		t.Errorf("truncated state file imported")
+	}
+}
+
+// Tests that malformed state files are rejected before being fully processed.
+func TestImportMalformed(t *testing.T) {
+	encode := func(entries ...interface{}) []byte {
+		var buf bytes.Buffer
+		for _, entry := range entries {
+			if err := rlp.Encode(&buf, entry); err != nil {
+				t.Fatalf("failed to encode entry: %v", err)
+			}
+		}
+		return buf.Bytes()
+	}
+	header := &exportHeader{Magic: exportMagic, Version: ExportVersion}
+
+	tests := []struct {
+		file []byte
+		err  string
+	}{
+		// Oversized entries must be refused without being read
+		{encode(header, &exportRecord{Kind: exportCode, Data: make([]byte, exportMaxEntrySize)}), "exceeds limit"},
+		// Duplicate slots must be refused, the zero hash included
+		{encode(header,
+			&exportRecord{Kind: exportAccount, Hash: common.Hash{0x01}, Data: SlimAccountRLP(0, big.NewInt(1), common.Hash{0x01}, emptyCode.Bytes())},
+			&exportRecord{Kind: exportSlot, Hash: common.Hash{}, Data: []byte{0x01}},
+			&exportRecord{Kind: exportSlot, Hash: common.Hash{}, Data: []byte{0x02}},
+		), "out of order"},
+	}
+	for i, tt := range tests {
+		_, err := Import(rawdb.NewMemoryDatabase(), bytes.NewReader(tt.file))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("test %d: error mismatch: have %v, want %q", i, err, tt.err)
+		}
+	}
+}
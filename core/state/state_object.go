@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie/verkle"
 )
 
 var emptyCodeHash = crypto.Keccak256(nil)
@@ -85,6 +86,11 @@ type stateObject struct {
 	dirtyStorage   Storage // Storage entries that have been modified in the current transaction execution
 	fakeStorage    Storage // Fake storage which constructed by caller for debugging purpose.
 
+	// Account and storage values before the state transitions, tracked only if
+	// the state diff is recorded. A nil origin means the account didn't exist.
+	origin      *types.StateAccount
+	originSlots Storage
+
 	// Cache flags.
 	// When an object is marked suicided it will be delete from the trie
 	// during the "update" phase of the state transition.
@@ -125,11 +131,14 @@ func (s *stateObject) EncodeRLP(w io.Writer) error {
 	return rlp.Encode(w, &s.data)
 }
 
-// setError remembers the first non-nil error it is called with.
+// setError remembers the first non-nil error it is called with, passing it on
+// to the state database so that failed storage accesses aren't mistaken for
+// empty slots.
 func (s *stateObject) setError(err error) {
 	if s.dbErr == nil {
 		s.dbErr = err
 	}
+	s.db.setError(err)
 }
 
 func (s *stateObject) markSuicided() {
@@ -149,6 +158,11 @@ func (s *stateObject) touch() {
 
 func (s *stateObject) getTrie(db Database) Trie {
 	if s.trie == nil {
+		// The storage of a verkle state is kept in the tree of the state
+		if tr, ok := s.db.trie.(*verkle.Trie); ok {
+			s.trie = tr.StorageTrie(s.address)
+			return s.trie
+		}
 		// Try fetching from prefetcher first
 		// We don't prefetch empty tries
 		if s.data.Root != emptyRoot && s.db.prefetcher != nil {
@@ -201,7 +215,7 @@ func (s *stateObject) GetCommittedState(db Database, key common.Hash) common.Has
 		enc []byte
 		err error
 	)
-	if s.db.snap != nil {
+	if s.db.snap != nil && s.db.witness == nil {
 		// If the object was destructed in *this* block (and potentially resurrected),
 		// the storage has been cleared out, and we should *not* consult the previous
 		// snapshot about any storage values. The only possible alternatives are:
@@ -218,7 +232,7 @@ func (s *stateObject) GetCommittedState(db Database, key common.Hash) common.Has
 		}
 	}
 	// If the snapshot is unavailable or reading from it fails, load from the database.
-	if s.db.snap == nil || err != nil {
+	if s.db.snap == nil || s.db.witness != nil || err != nil {
 		start := time.Now()
 		enc, err = s.getTrie(db).TryGet(key.Bytes())
 		if metrics.EnabledExpensive {
@@ -312,13 +326,24 @@ func (s *stateObject) updateTrie(db Database) Trie {
 	}
 	// Track the amount of time wasted on updating the storage trie
 	if metrics.EnabledExpensive {
-		defer func(start time.Time) { s.db.StorageUpdates += time.Since(start) }(time.Now())
+		defer func(start time.Time) {
+			s.db.storageLock.Lock()
+			s.db.StorageUpdates += time.Since(start)
+			s.db.storageLock.Unlock()
+		}(time.Now())
+	}
+	// The snapshot storage changes of the object, merged into the shared ones
+	// at the end as storage tries are updated in parallel
+	var (
+		storage          map[common.Hash][]byte
+		hasher           crypto.KeccakState
+		updated, deleted int
+	)
+	if s.db.snap != nil {
+		storage, hasher = make(map[common.Hash][]byte), crypto.NewKeccakState()
 	}
-	// The snapshot storage map for the object
-	var storage map[common.Hash][]byte
 	// Insert all the pending updates into the trie
 	tr := s.getTrie(db)
-	hasher := s.db.hasher
 
 	usedStorage := make([][]byte, 0, len(s.pendingStorage))
 	for key, value := range s.pendingStorage {
@@ -326,31 +351,44 @@ func (s *stateObject) updateTrie(db Database) Trie {
 		if value == s.originStorage[key] {
 			continue
 		}
+		if s.originSlots != nil {
+			if _, ok := s.originSlots[key]; !ok {
+				s.originSlots[key] = s.originStorage[key]
+			}
+		}
 		s.originStorage[key] = value
 
 		var v []byte
 		if (value == common.Hash{}) {
 			s.setError(tr.TryDelete(key[:]))
-			s.db.StorageDeleted += 1
+			deleted += 1
 		} else {
 			// Encoding []byte cannot fail, ok to ignore the error.
 			v, _ = rlp.EncodeToBytes(common.TrimLeftZeroes(value[:]))
 			s.setError(tr.TryUpdate(key[:], v))
-			s.db.StorageUpdated += 1
+			updated += 1
 		}
 		// If state snapshotting is active, cache the data til commit
-		if s.db.snap != nil {
-			if storage == nil {
-				// Retrieve the old storage map, if available, create a new one otherwise
-				if storage = s.db.snapStorage[s.addrHash]; storage == nil {
-					storage = make(map[common.Hash][]byte)
-					s.db.snapStorage[s.addrHash] = storage
-				}
-			}
+		if storage != nil {
 			storage[crypto.HashData(hasher, key[:])] = v // v will be nil if it's deleted
 		}
 		usedStorage = append(usedStorage, common.CopyBytes(key[:])) // Copy needed for closure
 	}
+	s.db.storageLock.Lock()
+	s.db.StorageUpdated += updated
+	s.db.StorageDeleted += deleted
+	if len(storage) > 0 {
+		// Merge into the old storage map, if available, use the new one otherwise
+		if old := s.db.snapStorage[s.addrHash]; old != nil {
+			for hash, v := range storage {
+				old[hash] = v
+			}
+		} else {
+			s.db.snapStorage[s.addrHash] = storage
+		}
+	}
+	s.db.storageLock.Unlock()
+
 	if s.db.prefetcher != nil {
 		s.db.prefetcher.used(s.addrHash, s.data.Root, usedStorage)
 	}
@@ -368,14 +406,18 @@ func (s *stateObject) updateRoot(db Database) {
 	}
 	// Track the amount of time wasted on hashing the storage trie
 	if metrics.EnabledExpensive {
-		defer func(start time.Time) { s.db.StorageHashes += time.Since(start) }(time.Now())
+		defer func(start time.Time) {
+			s.db.storageLock.Lock()
+			s.db.StorageHashes += time.Since(start)
+			s.db.storageLock.Unlock()
+		}(time.Now())
 	}
 	s.data.Root = s.trie.Hash()
 }
 
-// CommitTrie the storage trie of the object to db.
-// This updates the trie root.
-func (s *stateObject) CommitTrie(db Database) (int, error) {
+// CommitTrie the storage trie of the object to db, as part of the state with
+// the given root. This updates the trie root.
+func (s *stateObject) CommitTrie(db Database, state common.Hash) (int, error) {
 	// If nothing changed, don't bother with hashing anything
 	if s.updateTrie(db) == nil {
 		return 0, nil
@@ -385,9 +427,13 @@ func (s *stateObject) CommitTrie(db Database) (int, error) {
 	}
 	// Track the amount of time wasted on committing the storage trie
 	if metrics.EnabledExpensive {
-		defer func(start time.Time) { s.db.StorageCommits += time.Since(start) }(time.Now())
+		defer func(start time.Time) {
+			s.db.storageLock.Lock()
+			s.db.StorageCommits += time.Since(start)
+			s.db.storageLock.Unlock()
+		}(time.Now())
 	}
-	root, committed, err := s.trie.Commit(nil)
+	root, committed, err := s.trie.CommitState(state, nil)
 	if err == nil {
 		s.data.Root = root
 	}
@@ -432,7 +478,10 @@ func (s *stateObject) setBalance(amount *big.Int) {
 func (s *stateObject) deepCopy(db *StateDB) *stateObject {
 	stateObject := newObject(db, s.address, s.data)
 	if s.trie != nil {
-		stateObject.trie = db.db.CopyTrie(s.trie)
+		// Views into the tree of a verkle state are reopened from the copied tree
+		if _, ok := s.trie.(*verkle.StorageTrie); !ok {
+			stateObject.trie = db.db.CopyTrie(s.trie)
+		}
 	}
 	stateObject.code = s.code
 	stateObject.dirtyStorage = s.dirtyStorage.Copy()
@@ -441,6 +490,10 @@ func (s *stateObject) deepCopy(db *StateDB) *stateObject {
 	stateObject.suicided = s.suicided
 	stateObject.dirtyCode = s.dirtyCode
 	stateObject.deleted = s.deleted
+	stateObject.origin = s.origin
+	if s.originSlots != nil {
+		stateObject.originSlots = s.originSlots.Copy()
+	}
 	return stateObject
 }
 
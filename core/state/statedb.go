@@ -18,21 +18,27 @@
 package state
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"math/big"
+	"runtime"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
+	"github.com/ethereum/go-ethereum/core/stateless"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/verkle"
 )
 
 type revision struct {
@@ -77,6 +83,10 @@ type StateDB struct {
 	snapAccounts  map[common.Hash][]byte
 	snapStorage   map[common.Hash]map[common.Hash][]byte
 
+	// storageLock protects the fields updated while hashing and committing the
+	// storage tries in parallel: the snapshot storage and the storage metrics.
+	storageLock sync.Mutex
+
 	// This map holds 'live' objects, which will get modified while processing a state transition.
 	stateObjects        map[common.Address]*stateObject
 	stateObjectsPending map[common.Address]struct{} // State objects finalized but not yet written to the trie
@@ -97,8 +107,18 @@ type StateDB struct {
 	logs    map[common.Hash][]*types.Log
 	logSize uint
 
+	// Contracts deployed and self-destructed by the finalised transactions
+	creations    []ContractCreation
+	destructions []ContractCreation
+
 	preimages map[common.Hash][]byte
 
+	// Whether the original values of the modified state are tracked
+	diffs bool
+
+	// Witness recording the state accessed, nil if not recorded
+	witness *stateless.Witness
+
 	// Per-transaction access list
 	accessList *accessList
 
@@ -159,14 +179,15 @@ func New(root common.Hash, db Database, snaps *snapshot.Tree) (*StateDB, error)
 
 // StartPrefetcher initializes a new trie prefetcher to pull in nodes from the
 // state trie concurrently while the state is mutated so that when we reach the
-// commit phase, most of the needed data is already hot.
-func (s *StateDB) StartPrefetcher(namespace string) {
+// commit phase, most of the needed data is already hot. A nil config runs the
+// prefetcher with the default, unlimited settings.
+func (s *StateDB) StartPrefetcher(namespace string, config *PrefetcherConfig) {
 	if s.prefetcher != nil {
 		s.prefetcher.close()
 		s.prefetcher = nil
 	}
 	if s.snap != nil {
-		s.prefetcher = newTriePrefetcher(s.db, s.originalRoot, namespace)
+		s.prefetcher = newTriePrefetcher(s.db, s.originalRoot, namespace, config)
 	}
 }
 
@@ -200,6 +221,114 @@ func (s *StateDB) AddLog(log *types.Log) {
 	s.logSize++
 }
 
+// ContractCreation is a contract deployed or self-destructed by a transaction.
+type ContractCreation struct {
+	Address  common.Address
+	CodeHash common.Hash // code deployed, or code of the destructed contract
+	TxHash   common.Hash
+	TxIndex  int
+}
+
+// ContractCreations returns the contracts deployed by the finalised transactions,
+// including those deployed by contracts with CREATE and CREATE2.
+func (s *StateDB) ContractCreations() []ContractCreation {
+	return s.creations
+}
+
+// ContractDestructions returns the contracts self-destructed by the finalised
+// transactions.
+func (s *StateDB) ContractDestructions() []ContractCreation {
+	return s.destructions
+}
+
+// TrackStateDiff enables tracking the original values of the accounts and the
+// storage slots, to retrieve the changes with StateDiff. It must be called
+// before any account is accessed.
+func (s *StateDB) TrackStateDiff() {
+	s.diffs = true
+}
+
+// StateDiff returns the changes of the accounts and the storage slots since the
+// state was opened, ordered by address and slot, or nil if they aren't tracked.
+// The changes must have been written into the tries by IntermediateRoot. The
+// storage wiped by a self-destruct is only included for the modified slots.
+func (s *StateDB) StateDiff() []*types.AccountDiff {
+	if !s.diffs {
+		return nil
+	}
+	diffs := make([]*types.AccountDiff, 0)
+	for addr, obj := range s.stateObjects {
+		if obj.originSlots == nil {
+			continue
+		}
+		diff := &types.AccountDiff{Address: addr, Prev: obj.origin}
+		if !obj.deleted {
+			post := obj.data
+			post.Balance = new(big.Int).Set(post.Balance)
+			diff.Post = &post
+		}
+		for key, prev := range obj.originSlots {
+			var post common.Hash
+			if !obj.deleted {
+				post = obj.GetCommittedState(s.db, key)
+			}
+			if prev != post {
+				diff.Storage = append(diff.Storage, types.SlotDiff{Key: key, Prev: prev, Post: post})
+			}
+		}
+		if len(diff.Storage) == 0 && sameAccount(diff.Prev, diff.Post) {
+			continue
+		}
+		sort.Slice(diff.Storage, func(i, j int) bool {
+			return bytes.Compare(diff.Storage[i].Key[:], diff.Storage[j].Key[:]) < 0
+		})
+		diffs = append(diffs, diff)
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return bytes.Compare(diffs[i].Address[:], diffs[j].Address[:]) < 0
+	})
+	return diffs
+}
+
+// sameAccount reports whether two accounts, nil if absent, are identical.
+func sameAccount(a, b *types.StateAccount) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Nonce == b.Nonce && a.Balance.Cmp(b.Balance) == 0 && a.Root == b.Root && bytes.Equal(a.CodeHash, b.CodeHash)
+}
+
+// recordCreations collects the contracts deployed by the current transaction.
+// Deployments which were reverted are no longer in the journal.
+func (s *StateDB) recordCreations() {
+	var seen map[common.Address]bool
+	for _, entry := range s.journal.entries {
+		var addr common.Address
+		switch ch := entry.(type) {
+		case createObjectChange:
+			addr = *ch.account
+		case resetObjectChange:
+			addr = ch.prev.address
+		default:
+			continue
+		}
+		obj := s.stateObjects[addr]
+		if obj == nil || bytes.Equal(obj.CodeHash(), emptyCodeHash) || seen[addr] {
+			continue
+		}
+		if seen == nil {
+			seen = make(map[common.Address]bool)
+		}
+		seen[addr] = true
+		s.creations = append(s.creations, ContractCreation{
+			Address:  addr,
+			CodeHash: common.BytesToHash(obj.CodeHash()),
+			TxHash:   s.thash,
+			TxIndex:  s.txIndex,
+		})
+	}
+}
+
 func (s *StateDB) GetLogs(hash common.Hash, blockHash common.Hash) []*types.Log {
 	logs := s.logs[hash]
 	for _, l := range logs {
@@ -466,6 +595,12 @@ func (s *StateDB) updateStateObject(obj *stateObject) {
 	if err := s.trie.TryUpdateAccount(addr[:], &obj.data); err != nil {
 		s.setError(fmt.Errorf("updateStateObject (%x) error: %v", addr[:], err))
 	}
+	// The code of a verkle state is kept in the tree
+	if tr, ok := s.trie.(*verkle.Trie); ok && obj.dirtyCode {
+		if err := tr.UpdateCode(addr, obj.code); err != nil {
+			s.setError(fmt.Errorf("updateStateObject (%x) code error: %v", addr[:], err))
+		}
+	}
 
 	// If state snapshotting is active, cache the data til commit. Note, this
 	// update mechanism is not symmetric to the deletion, because whereas it is
@@ -510,7 +645,7 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 	}
 	// If no live objects are available, attempt to use snapshots
 	var data *types.StateAccount
-	if s.snap != nil {
+	if s.snap != nil && s.witness == nil {
 		start := time.Now()
 		acc, err := s.snap.Account(crypto.HashData(s.hasher, addr.Bytes()))
 		if metrics.EnabledExpensive {
@@ -556,6 +691,11 @@ func (s *StateDB) getDeletedStateObject(addr common.Address) *stateObject {
 	}
 	// Insert into the live set
 	obj := newObject(s, addr, *data)
+	if s.diffs {
+		origin := obj.data
+		origin.Balance = new(big.Int).Set(origin.Balance)
+		obj.origin, obj.originSlots = &origin, make(Storage)
+	}
 	s.setStateObject(obj)
 	return obj
 }
@@ -586,6 +726,13 @@ func (s *StateDB) createObject(addr common.Address) (newobj, prev *stateObject)
 		}
 	}
 	newobj = newObject(s, addr, types.StateAccount{})
+	if s.diffs {
+		if prev != nil {
+			newobj.origin, newobj.originSlots = prev.origin, prev.originSlots
+		} else {
+			newobj.originSlots = make(Storage)
+		}
+	}
 	if prev == nil {
 		s.journal.append(createObjectChange{account: &addr})
 	} else {
@@ -644,6 +791,23 @@ func (db *StateDB) ForEachStorage(addr common.Address, cb func(key, value common
 	return nil
 }
 
+// AccessedContracts returns the contracts accessed by the state along with the
+// storage slots loaded from each of them.
+func (s *StateDB) AccessedContracts() map[common.Address][]common.Hash {
+	contracts := make(map[common.Address][]common.Hash)
+	for addr, obj := range s.stateObjects {
+		if bytes.Equal(obj.CodeHash(), emptyCodeHash) {
+			continue
+		}
+		slots := make([]common.Hash, 0, len(obj.originStorage))
+		for slot := range obj.originStorage {
+			slots = append(slots, slot)
+		}
+		contracts[addr] = slots
+	}
+	return contracts
+}
+
 // Copy creates a deep, independent copy of the state.
 // Snapshots of the copied state cannot be applied to the copy.
 func (s *StateDB) Copy() *StateDB {
@@ -658,7 +822,11 @@ func (s *StateDB) Copy() *StateDB {
 		refund:              s.refund,
 		logs:                make(map[common.Hash][]*types.Log, len(s.logs)),
 		logSize:             s.logSize,
+		creations:           append([]ContractCreation(nil), s.creations...),
+		destructions:        append([]ContractCreation(nil), s.destructions...),
 		preimages:           make(map[common.Hash][]byte, len(s.preimages)),
+		diffs:               s.diffs,
+		witness:             s.witness,
 		journal:             newJournal(),
 		hasher:              crypto.NewKeccakState(),
 	}
@@ -778,6 +946,8 @@ func (s *StateDB) GetRefund() uint64 {
 // the journal as well as the refunds. Finalise, however, will not push any updates
 // into the tries just yet. Only IntermediateRoot or Commit will do that.
 func (s *StateDB) Finalise(deleteEmptyObjects bool) {
+	s.recordCreations()
+
 	addressesToPrefetch := make([][]byte, 0, len(s.journal.dirties))
 	for addr := range s.journal.dirties {
 		obj, exist := s.stateObjects[addr]
@@ -791,6 +961,14 @@ func (s *StateDB) Finalise(deleteEmptyObjects bool) {
 			continue
 		}
 		if obj.suicided || (deleteEmptyObjects && obj.empty()) {
+			if obj.suicided && !obj.deleted {
+				s.destructions = append(s.destructions, ContractCreation{
+					Address:  addr,
+					CodeHash: common.BytesToHash(obj.CodeHash()),
+					TxHash:   s.thash,
+					TxIndex:  s.txIndex,
+				})
+			}
 			obj.deleted = true
 
 			// If state snapshotting is active, also mark the destruction there.
@@ -845,12 +1023,17 @@ func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 	// the contract storage and account updates sequentially, that short circuits
 	// the account prefetcher. Instead, let's process all the storage updates
 	// first, giving the account prefeches just a few more milliseconds of time
-	// to pull useful data from disk.
+	// to pull useful data from disk. The storage tries are independent of each
+	// other, so hash them in parallel.
+	updated := make([]*stateObject, 0, len(s.stateObjectsPending))
 	for addr := range s.stateObjectsPending {
 		if obj := s.stateObjects[addr]; !obj.deleted {
-			obj.updateRoot(s.db)
+			updated = append(updated, obj)
 		}
 	}
+	forEachObject(updated, func(_ int, obj *stateObject) {
+		obj.updateRoot(s.db)
+	})
 	// Now we're about to start to write changes to the trie. The trie is so far
 	// _untouched_. We can check with the prefetcher, if it can give us a trie
 	// which has the same root, but also has some content loaded into it.
@@ -883,6 +1066,40 @@ func (s *StateDB) IntermediateRoot(deleteEmptyObjects bool) common.Hash {
 	return s.trie.Hash()
 }
 
+// forEachObject runs the given function for every state object, spreading them
+// over a pool of workers. The function is called with the index of the object
+// and must only modify the state shared between objects under the storage lock.
+func forEachObject(objs []*stateObject, fn func(i int, obj *stateObject)) {
+	workers := runtime.NumCPU()
+	if workers > len(objs) {
+		workers = len(objs)
+	}
+	if workers <= 1 {
+		for i, obj := range objs {
+			fn(i, obj)
+		}
+		return
+	}
+	var (
+		next int32 = -1
+		wg   sync.WaitGroup
+	)
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt32(&next, 1))
+				if i >= len(objs) {
+					return
+				}
+				fn(i, objs[i])
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 // Prepare sets the current transaction hash and index which are
 // used when the EVM emits new state logs.
 func (s *StateDB) Prepare(thash common.Hash, ti int) {
@@ -904,10 +1121,13 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 		return common.Hash{}, fmt.Errorf("commit aborted due to earlier error: %v", s.dbErr)
 	}
 	// Finalize any pending changes and merge everything into the tries
-	s.IntermediateRoot(deleteEmptyObjects)
+	state := s.IntermediateRoot(deleteEmptyObjects)
 
 	// Commit objects to the trie, measuring the elapsed time
-	var storageCommitted int
+	var (
+		storageCommitted int
+		committed        []*stateObject
+	)
 	codeWriter := s.db.TrieDB().DiskDB().NewBatch()
 	for addr := range s.stateObjectsDirty {
 		if obj := s.stateObjects[addr]; !obj.deleted {
@@ -916,13 +1136,23 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 				rawdb.WriteCode(codeWriter, common.BytesToHash(obj.CodeHash()), obj.code)
 				obj.dirtyCode = false
 			}
-			// Write any storage changes in the state object to its storage trie
-			committed, err := obj.CommitTrie(s.db)
-			if err != nil {
-				return common.Hash{}, err
-			}
-			storageCommitted += committed
+			committed = append(committed, obj)
+		}
+	}
+	// Write any storage changes in the state objects to their storage tries,
+	// committing the independent tries in parallel
+	var (
+		counts = make([]int, len(committed))
+		errs   = make([]error, len(committed))
+	)
+	forEachObject(committed, func(i int, obj *stateObject) {
+		counts[i], errs[i] = obj.CommitTrie(s.db, state)
+	})
+	for i, err := range errs {
+		if err != nil {
+			return common.Hash{}, err
 		}
+		storageCommitted += counts[i]
 	}
 	if len(s.stateObjectsDirty) > 0 {
 		s.stateObjectsDirty = make(map[common.Address]struct{})
@@ -952,6 +1182,11 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 	if err != nil {
 		return common.Hash{}, err
 	}
+	// Seal the committed trie nodes into the state transition, for the schemes
+	// tracking them
+	if err := s.db.TrieDB().Update(root, s.originalRoot); err != nil {
+		return common.Hash{}, err
+	}
 	if metrics.EnabledExpensive {
 		s.AccountCommits += time.Since(start)
 
@@ -144,6 +144,53 @@ func TestIntermediateLeaks(t *testing.T) {
 	}
 }
 
+// Tests that the storage tries hashed and committed in parallel result in the
+// same state as the ones hashed one by one.
+func TestParallelStorageRoots(t *testing.T) {
+	var (
+		diskdb      = rawdb.NewMemoryDatabase()
+		parallel, _ = New(common.Hash{}, NewDatabase(diskdb), nil)
+		serial, _   = New(common.Hash{}, NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	)
+	for i := byte(0); i < 64; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		for _, state := range []*StateDB{parallel, serial} {
+			state.SetNonce(addr, uint64(i))
+			for j := byte(0); j < 16; j++ {
+				state.SetState(addr, common.BytesToHash([]byte{i, j}), common.BytesToHash([]byte{j, i, 1}))
+			}
+		}
+		// Hash a single storage trie at a time in the serial state
+		serial.IntermediateRoot(false)
+	}
+	want := serial.IntermediateRoot(false)
+	if root := parallel.IntermediateRoot(false); root != want {
+		t.Fatalf("intermediate root mismatch: have %x, want %x", root, want)
+	}
+	root, err := parallel.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if root != want {
+		t.Fatalf("committed root mismatch: have %x, want %x", root, want)
+	}
+	if err := parallel.Database().TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to flush state: %v", err)
+	}
+	reopened, err := New(root, NewDatabase(diskdb), nil)
+	if err != nil {
+		t.Fatalf("failed to reopen state: %v", err)
+	}
+	for i := byte(0); i < 64; i++ {
+		addr := common.BytesToAddress([]byte{i})
+		for j := byte(0); j < 16; j++ {
+			if have, want := reopened.GetState(addr, common.BytesToHash([]byte{i, j})), common.BytesToHash([]byte{j, i, 1}); have != want {
+				t.Fatalf("storage mismatch at %x/%d: have %x, want %x", addr, j, have, want)
+			}
+		}
+	}
+}
+
 // TestCopy tests that copying a StateDB object indeed makes the original and
 // the copy independent of each other. This test is a regression test against
 // https://github.com/ethereum/go-ethereum/pull/15549.
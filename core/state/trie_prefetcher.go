@@ -29,6 +29,12 @@ var (
 	triePrefetchMetricsPrefix = "trie/prefetch/"
 )
 
+// PrefetcherConfig contains the tuning knobs of the trie prefetcher.
+type PrefetcherConfig struct {
+	Workers     int // Maximum number of tries prefetched in parallel (0 = unlimited)
+	Concurrency int // Number of parallel loaders of a single trie (0 = 1)
+}
+
 // triePrefetcher is an active prefetcher, which receives accounts or storage
 // items and does trie-loading of them. The goal is to get as much useful content
 // into the caches as possible.
@@ -40,33 +46,52 @@ type triePrefetcher struct {
 	fetches  map[string]Trie        // Partially or fully fetcher tries
 	fetchers map[string]*subfetcher // Subfetchers for each trie
 
+	workers     chan struct{} // Semaphore limiting the parallel subfetchers, nil if unlimited
+	concurrency int           // Number of parallel loaders of a single trie
+
+	deliveryHitMeter  metrics.Meter
 	deliveryMissMeter metrics.Meter
 	accountLoadMeter  metrics.Meter
 	accountDupMeter   metrics.Meter
 	accountSkipMeter  metrics.Meter
 	accountWasteMeter metrics.Meter
+	accountHitMeter   metrics.Meter
+	accountMissMeter  metrics.Meter
 	storageLoadMeter  metrics.Meter
 	storageDupMeter   metrics.Meter
 	storageSkipMeter  metrics.Meter
 	storageWasteMeter metrics.Meter
+	storageHitMeter   metrics.Meter
+	storageMissMeter  metrics.Meter
 }
 
-func newTriePrefetcher(db Database, root common.Hash, namespace string) *triePrefetcher {
+func newTriePrefetcher(db Database, root common.Hash, namespace string, config *PrefetcherConfig) *triePrefetcher {
 	prefix := triePrefetchMetricsPrefix + namespace
 	p := &triePrefetcher{
 		db:       db,
 		root:     root,
 		fetchers: make(map[string]*subfetcher), // Active prefetchers use the fetchers map
 
+		deliveryHitMeter:  metrics.GetOrRegisterMeter(prefix+"/deliveryhit", nil),
 		deliveryMissMeter: metrics.GetOrRegisterMeter(prefix+"/deliverymiss", nil),
 		accountLoadMeter:  metrics.GetOrRegisterMeter(prefix+"/account/load", nil),
 		accountDupMeter:   metrics.GetOrRegisterMeter(prefix+"/account/dup", nil),
 		accountSkipMeter:  metrics.GetOrRegisterMeter(prefix+"/account/skip", nil),
 		accountWasteMeter: metrics.GetOrRegisterMeter(prefix+"/account/waste", nil),
+		accountHitMeter:   metrics.GetOrRegisterMeter(prefix+"/account/hit", nil),
+		accountMissMeter:  metrics.GetOrRegisterMeter(prefix+"/account/miss", nil),
 		storageLoadMeter:  metrics.GetOrRegisterMeter(prefix+"/storage/load", nil),
 		storageDupMeter:   metrics.GetOrRegisterMeter(prefix+"/storage/dup", nil),
 		storageSkipMeter:  metrics.GetOrRegisterMeter(prefix+"/storage/skip", nil),
 		storageWasteMeter: metrics.GetOrRegisterMeter(prefix+"/storage/waste", nil),
+		storageHitMeter:   metrics.GetOrRegisterMeter(prefix+"/storage/hit", nil),
+		storageMissMeter:  metrics.GetOrRegisterMeter(prefix+"/storage/miss", nil),
+	}
+	if config != nil {
+		if config.Workers > 0 {
+			p.workers = make(chan struct{}, config.Workers)
+		}
+		p.concurrency = config.Concurrency
 	}
 	return p
 }
@@ -78,24 +103,34 @@ func (p *triePrefetcher) close() {
 		fetcher.abort() // safe to do multiple times
 
 		if metrics.Enabled {
+			// Split the used entries into the ones loaded ahead of time (hits)
+			// and the ones loaded on demand (misses), the remaining loaded
+			// entries were wasted.
+			loads := len(fetcher.seen)
+
+			var hits, misses int
+			for _, key := range fetcher.used {
+				if _, ok := fetcher.seen[string(key)]; ok {
+					delete(fetcher.seen, string(key))
+					hits++
+				} else {
+					misses++
+				}
+			}
 			if fetcher.root == p.root {
-				p.accountLoadMeter.Mark(int64(len(fetcher.seen)))
+				p.accountLoadMeter.Mark(int64(loads))
 				p.accountDupMeter.Mark(int64(fetcher.dups))
 				p.accountSkipMeter.Mark(int64(len(fetcher.tasks)))
-
-				for _, key := range fetcher.used {
-					delete(fetcher.seen, string(key))
-				}
 				p.accountWasteMeter.Mark(int64(len(fetcher.seen)))
+				p.accountHitMeter.Mark(int64(hits))
+				p.accountMissMeter.Mark(int64(misses))
 			} else {
-				p.storageLoadMeter.Mark(int64(len(fetcher.seen)))
+				p.storageLoadMeter.Mark(int64(loads))
 				p.storageDupMeter.Mark(int64(fetcher.dups))
 				p.storageSkipMeter.Mark(int64(len(fetcher.tasks)))
-
-				for _, key := range fetcher.used {
-					delete(fetcher.seen, string(key))
-				}
 				p.storageWasteMeter.Mark(int64(len(fetcher.seen)))
+				p.storageHitMeter.Mark(int64(hits))
+				p.storageMissMeter.Mark(int64(misses))
 			}
 		}
 	}
@@ -113,15 +148,20 @@ func (p *triePrefetcher) copy() *triePrefetcher {
 		root:    p.root,
 		fetches: make(map[string]Trie), // Active prefetchers use the fetches map
 
+		deliveryHitMeter:  p.deliveryHitMeter,
 		deliveryMissMeter: p.deliveryMissMeter,
 		accountLoadMeter:  p.accountLoadMeter,
 		accountDupMeter:   p.accountDupMeter,
 		accountSkipMeter:  p.accountSkipMeter,
 		accountWasteMeter: p.accountWasteMeter,
+		accountHitMeter:   p.accountHitMeter,
+		accountMissMeter:  p.accountMissMeter,
 		storageLoadMeter:  p.storageLoadMeter,
 		storageDupMeter:   p.storageDupMeter,
 		storageSkipMeter:  p.storageSkipMeter,
 		storageWasteMeter: p.storageWasteMeter,
+		storageHitMeter:   p.storageHitMeter,
+		storageMissMeter:  p.storageMissMeter,
 	}
 	// If the prefetcher is already a copy, duplicate the data
 	if p.fetches != nil {
@@ -147,7 +187,7 @@ func (p *triePrefetcher) prefetch(owner common.Hash, root common.Hash, keys [][]
 	id := p.trieID(owner, root)
 	fetcher := p.fetchers[id]
 	if fetcher == nil {
-		fetcher = newSubfetcher(p.db, owner, root)
+		fetcher = newSubfetcher(p.db, owner, root, p.workers, p.concurrency)
 		p.fetchers[id] = fetcher
 	}
 	fetcher.schedule(keys)
@@ -164,6 +204,7 @@ func (p *triePrefetcher) trie(owner common.Hash, root common.Hash) Trie {
 			p.deliveryMissMeter.Mark(1)
 			return nil
 		}
+		p.deliveryHitMeter.Mark(1)
 		return p.db.CopyTrie(trie)
 	}
 	// Otherwise the prefetcher is active, bail if no trie was prefetched for this root
@@ -181,6 +222,7 @@ func (p *triePrefetcher) trie(owner common.Hash, root common.Hash) Trie {
 		p.deliveryMissMeter.Mark(1)
 		return nil
 	}
+	p.deliveryHitMeter.Mark(1)
 	return trie
 }
 
@@ -207,6 +249,9 @@ type subfetcher struct {
 	root  common.Hash // Root hash of the trie to prefetch
 	trie  Trie        // Trie being populated with nodes
 
+	workers     chan struct{} // Semaphore limiting the parallel subfetchers, nil if unlimited
+	concurrency int           // Number of parallel loaders of the trie
+
 	tasks [][]byte   // Items queued up for retrieval
 	lock  sync.Mutex // Lock protecting the task queue
 
@@ -222,16 +267,18 @@ type subfetcher struct {
 
 // newSubfetcher creates a goroutine to prefetch state items belonging to a
 // particular root hash.
-func newSubfetcher(db Database, owner common.Hash, root common.Hash) *subfetcher {
+func newSubfetcher(db Database, owner common.Hash, root common.Hash, workers chan struct{}, concurrency int) *subfetcher {
 	sf := &subfetcher{
-		db:    db,
-		owner: owner,
-		root:  root,
-		wake:  make(chan struct{}, 1),
-		stop:  make(chan struct{}),
-		term:  make(chan struct{}),
-		copy:  make(chan chan Trie),
-		seen:  make(map[string]struct{}),
+		db:          db,
+		owner:       owner,
+		root:        root,
+		workers:     workers,
+		concurrency: concurrency,
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		term:        make(chan struct{}),
+		copy:        make(chan chan Trie),
+		seen:        make(map[string]struct{}),
 	}
 	go sf.loop()
 	return sf
@@ -312,6 +359,16 @@ func (sf *subfetcher) loop() {
 			sf.tasks = nil
 			sf.lock.Unlock()
 
+			// Wait for a free worker slot if the parallel fetches are limited
+			if !sf.acquire() {
+				sf.lock.Lock()
+				sf.tasks = append(sf.tasks, tasks...)
+				sf.lock.Unlock()
+				return
+			}
+			// Warm up the caches in parallel if multiple loaders are allowed
+			sf.spread(tasks)
+
 			// Prefetch any tasks until the loop is interrupted
 			for i, task := range tasks {
 				select {
@@ -320,6 +377,7 @@ func (sf *subfetcher) loop() {
 					sf.lock.Lock()
 					sf.tasks = append(sf.tasks, tasks[i:]...)
 					sf.lock.Unlock()
+					sf.release()
 					return
 
 				case ch := <-sf.copy:
@@ -336,6 +394,7 @@ func (sf *subfetcher) loop() {
 					}
 				}
 			}
+			sf.release()
 
 		case ch := <-sf.copy:
 			// Somebody wants a copy of the current trie, grant them
@@ -347,3 +406,58 @@ func (sf *subfetcher) loop() {
 		}
 	}
 }
+
+// acquire waits for a free worker slot if the number of parallel subfetchers is
+// limited, serving trie copy requests meanwhile. It returns false if termination
+// was requested before a slot was freed up.
+func (sf *subfetcher) acquire() bool {
+	if sf.workers == nil {
+		return true
+	}
+	for {
+		select {
+		case sf.workers <- struct{}{}:
+			return true
+
+		case ch := <-sf.copy:
+			ch <- sf.db.CopyTrie(sf.trie)
+
+		case <-sf.stop:
+			return false
+		}
+	}
+}
+
+// release frees up the worker slot held by the subfetcher, if any.
+func (sf *subfetcher) release() {
+	if sf.workers != nil {
+		<-sf.workers
+	}
+}
+
+// spread splits the batch of tasks into chunks and, except for the first one,
+// loads each of them through a separate copy of the trie in the background. The
+// tasks are still loaded into the prefetched trie in order, but by the time the
+// subfetcher reaches a chunk, its nodes are already hot in the database caches.
+func (sf *subfetcher) spread(tasks [][]byte) {
+	if sf.concurrency <= 1 || len(tasks) <= 1 {
+		return
+	}
+	chunk := (len(tasks) + sf.concurrency - 1) / sf.concurrency
+	for start := chunk; start < len(tasks); start += chunk {
+		end := start + chunk
+		if end > len(tasks) {
+			end = len(tasks)
+		}
+		go func(trie Trie, keys [][]byte) {
+			for _, key := range keys {
+				select {
+				case <-sf.stop:
+					return
+				default:
+					trie.TryGet(key)
+				}
+			}
+		}(sf.db.CopyTrie(sf.trie), tasks[start:end])
+	}
+}
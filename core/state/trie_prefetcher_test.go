@@ -23,6 +23,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 func filledStateDB() *StateDB {
@@ -45,7 +46,7 @@ func filledStateDB() *StateDB {
 
 func TestCopyAndClose(t *testing.T) {
 	db := filledStateDB()
-	prefetcher := newTriePrefetcher(db.db, db.originalRoot, "")
+	prefetcher := newTriePrefetcher(db.db, db.originalRoot, "", nil)
 	skey := common.HexToHash("aaa")
 	prefetcher.prefetch(common.Hash{}, db.originalRoot, [][]byte{skey.Bytes()})
 	prefetcher.prefetch(common.Hash{}, db.originalRoot, [][]byte{skey.Bytes()})
@@ -70,7 +71,7 @@ func TestCopyAndClose(t *testing.T) {
 
 func TestUseAfterClose(t *testing.T) {
 	db := filledStateDB()
-	prefetcher := newTriePrefetcher(db.db, db.originalRoot, "")
+	prefetcher := newTriePrefetcher(db.db, db.originalRoot, "", nil)
 	skey := common.HexToHash("aaa")
 	prefetcher.prefetch(common.Hash{}, db.originalRoot, [][]byte{skey.Bytes()})
 	a := prefetcher.trie(common.Hash{}, db.originalRoot)
@@ -86,7 +87,7 @@ func TestUseAfterClose(t *testing.T) {
 
 func TestCopyClose(t *testing.T) {
 	db := filledStateDB()
-	prefetcher := newTriePrefetcher(db.db, db.originalRoot, "")
+	prefetcher := newTriePrefetcher(db.db, db.originalRoot, "", nil)
 	skey := common.HexToHash("aaa")
 	prefetcher.prefetch(common.Hash{}, db.originalRoot, [][]byte{skey.Bytes()})
 	cpy := prefetcher.copy()
@@ -108,3 +109,38 @@ func TestCopyClose(t *testing.T) {
 		t.Fatal("Copy trie should not return nil")
 	}
 }
+
+func TestPrefetcherConfig(t *testing.T) {
+	state := filledStateDB()
+	root, _ := state.Commit(false)
+	if err := state.db.TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	addr := common.HexToAddress("0xaffeaffeaffeaffeaffeaffeaffeaffeaffeaffe")
+	owner := crypto.Keccak256Hash(addr.Bytes())
+	storageRoot := state.StorageTrie(addr).Hash()
+
+	// Prefetch the tries under a single worker slot with parallel loaders
+	prefetcher := newTriePrefetcher(state.db, root, "", &PrefetcherConfig{Workers: 1, Concurrency: 4})
+	var keys [][]byte
+	for i := 1; i < 100; i++ {
+		keys = append(keys, common.BigToHash(big.NewInt(int64(i))).Bytes())
+	}
+	prefetcher.prefetch(common.Hash{}, root, [][]byte{addr.Bytes()})
+	prefetcher.prefetch(owner, storageRoot, keys)
+
+	a := prefetcher.trie(common.Hash{}, root)
+	b := prefetcher.trie(owner, storageRoot)
+	prefetcher.close()
+	if a == nil || b == nil {
+		t.Fatal("Prefetching with limited workers should not return nil")
+	}
+	if a.Hash() != root || b.Hash() != storageRoot {
+		t.Fatalf("Invalid trie, hashes mismatch: have %v %v, want %v %v", a.Hash(), b.Hash(), root, storageRoot)
+	}
+	for _, key := range keys {
+		if val, err := b.TryGet(key); err != nil || len(val) == 0 {
+			t.Fatalf("Prefetched slot %x missing: %v", key, err)
+		}
+	}
+}
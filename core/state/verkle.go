@@ -0,0 +1,123 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/verkle"
+)
+
+// errVerkleDirtyState is returned when converting a state which was already
+// modified.
+var errVerkleDirtyState = errors.New("state modified before the verkle conversion")
+
+// ConvertToVerkle moves every account of the state, with its code and storage,
+// from the merkle patricia tries into a verkle tree, which the state is kept in
+// from then on. The keys are recovered from the preimages of the tries, which
+// must have been recorded. The whole state is loaded into memory until the next
+// commit, so this is only meant for experimental networks.
+//
+// The conversion must happen before any change to the state, and is a noop if
+// the state is already kept in a verkle tree.
+func (s *StateDB) ConvertToVerkle() error {
+	if _, ok := s.trie.(*verkle.Trie); ok {
+		return nil
+	}
+	if len(s.stateObjects) > 0 || len(s.journal.entries) > 0 {
+		return errVerkleDirtyState
+	}
+	tree, err := verkle.New(common.Hash{}, s.db.TrieDB().DiskDB())
+	if err != nil {
+		return err
+	}
+	var (
+		start    = time.Now()
+		accounts int
+		slots    int
+	)
+	it := trie.NewIterator(s.trie.NodeIterator(nil))
+	for it.Next() {
+		key := s.trie.GetKey(it.Key)
+		if key == nil {
+			return fmt.Errorf("missing preimage of account %x", it.Key)
+		}
+		var (
+			addr     = common.BytesToAddress(key)
+			addrHash = common.BytesToHash(it.Key)
+			acc      types.StateAccount
+		)
+		if err := rlp.DecodeBytes(it.Value, &acc); err != nil {
+			return fmt.Errorf("invalid account %x: %v", addr, err)
+		}
+		if err := tree.TryUpdateAccount(addr[:], &acc); err != nil {
+			return err
+		}
+		if codeHash := common.BytesToHash(acc.CodeHash); codeHash != common.BytesToHash(emptyCodeHash) {
+			code, err := s.db.ContractCode(addrHash, codeHash)
+			if err != nil {
+				return fmt.Errorf("can't load code hash %x: %v", codeHash, err)
+			}
+			if err := tree.UpdateCode(addr, code); err != nil {
+				return err
+			}
+		}
+		if acc.Root != emptyRoot {
+			storage, err := s.db.OpenStorageTrie(addrHash, acc.Root)
+			if err != nil {
+				return err
+			}
+			view := tree.StorageTrie(addr)
+			sit := trie.NewIterator(storage.NodeIterator(nil))
+			for sit.Next() {
+				slot := storage.GetKey(sit.Key)
+				if slot == nil {
+					return fmt.Errorf("missing preimage of slot %x of account %x", sit.Key, addr)
+				}
+				if err := view.TryUpdate(slot, sit.Value); err != nil {
+					return err
+				}
+				slots++
+			}
+			if sit.Err != nil {
+				return sit.Err
+			}
+		}
+		accounts++
+	}
+	if it.Err != nil {
+		return it.Err
+	}
+	// Switch over to the tree, the snapshot and the prefetcher only serve the
+	// merkle patricia tries
+	if s.prefetcher != nil {
+		s.prefetcher.close()
+		s.prefetcher = nil
+	}
+	s.trie = tree
+	s.snap, s.snapDestructs, s.snapAccounts, s.snapStorage = nil, nil, nil, nil
+
+	log.Info("Converted state into verkle tree", "accounts", accounts, "slots", slots, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/trie/verkle"
+)
+
+// Tests that a committed state is converted into a verkle tree, which is
+// reopened and updated like the tries were.
+func TestConvertToVerkle(t *testing.T) {
+	var (
+		db       = NewDatabase(rawdb.NewMemoryDatabase())
+		state, _ = New(common.Hash{}, db, nil)
+		addr     = common.Address{1}
+		code     = []byte{0x60, 0x42, 0x60, 0x00, 0x55, 0x00}
+	)
+	state.SetBalance(addr, big.NewInt(100))
+	state.SetNonce(addr, 2)
+	state.SetCode(addr, code)
+	state.SetState(addr, common.Hash{1}, common.Hash{2})
+	state.SetBalance(common.Address{2}, big.NewInt(200))
+
+	if err := state.ConvertToVerkle(); err != errVerkleDirtyState {
+		t.Fatalf("modified state conversion error mismatch: have %v, want %v", err, errVerkleDirtyState)
+	}
+	root, err := state.Commit(false)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := db.TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to commit tries: %v", err)
+	}
+	// Convert the state, updating it and its copy differently
+	state, _ = New(root, db, nil)
+	if err := state.ConvertToVerkle(); err != nil {
+		t.Fatalf("failed to convert state: %v", err)
+	}
+	cpy := state.Copy()
+	state.SetState(addr, common.Hash{1}, common.Hash{3})
+	cpy.AddBalance(addr, big.NewInt(1))
+
+	if root, err = state.Commit(false); err != nil {
+		t.Fatalf("failed to commit verkle state: %v", err)
+	}
+	if !verkle.IsRoot(db.TrieDB().DiskDB(), root) {
+		t.Fatalf("root %x is not a verkle root", root)
+	}
+	if cpy.IntermediateRoot(false) == root {
+		t.Fatal("copy shares the tree of the state")
+	}
+	// Reopen the tree and check the content
+	state, err = New(root, db, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen verkle state: %v", err)
+	}
+	if balance := state.GetBalance(addr); balance.Cmp(big.NewInt(100)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 100", balance)
+	}
+	if nonce := state.GetNonce(addr); nonce != 2 {
+		t.Errorf("nonce mismatch: have %d, want 2", nonce)
+	}
+	if have := state.GetCode(addr); !bytes.Equal(have, code) {
+		t.Errorf("code mismatch: have %x, want %x", have, code)
+	}
+	if have := state.GetState(addr, common.Hash{1}); have != (common.Hash{3}) {
+		t.Errorf("slot mismatch: have %x, want %x", have, common.Hash{3})
+	}
+	if balance := state.GetBalance(common.Address{2}); balance.Cmp(big.NewInt(200)) != 0 {
+		t.Errorf("balance mismatch: have %v, want 200", balance)
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/stateless"
+)
+
+// recordingTrie is implemented by the tries able to report the nodes they load
+// from the database.
+type recordingTrie interface {
+	SetRecorder(recorder func(hash common.Hash, blob []byte))
+}
+
+// witnessDatabase wraps a state database, recording into a witness every trie
+// node loaded by the tries it opens and every contract code it retrieves.
+type witnessDatabase struct {
+	Database
+	witness *stateless.Witness
+}
+
+// record reports the root node of a freshly opened trie, which is resolved
+// before a recorder can be installed, and the nodes it loads from then on.
+func (db *witnessDatabase) record(tr Trie, owner common.Hash, root common.Hash) Trie {
+	if root != emptyRoot && root != (common.Hash{}) {
+		if blob, err := db.TrieDB().NodeBlob(owner, nil, root); err == nil {
+			db.witness.AddNode(root, blob)
+		}
+	}
+	if rt, ok := tr.(recordingTrie); ok {
+		rt.SetRecorder(db.witness.AddNode)
+	}
+	return tr
+}
+
+// OpenTrie opens the main account trie, recording the nodes it accesses.
+func (db *witnessDatabase) OpenTrie(root common.Hash) (Trie, error) {
+	tr, err := db.Database.OpenTrie(root)
+	if err != nil {
+		return nil, err
+	}
+	return db.record(tr, common.Hash{}, root), nil
+}
+
+// OpenStorageTrie opens the storage trie of an account, recording the nodes it
+// accesses.
+func (db *witnessDatabase) OpenStorageTrie(addrHash, root common.Hash) (Trie, error) {
+	tr, err := db.Database.OpenStorageTrie(addrHash, root)
+	if err != nil {
+		return nil, err
+	}
+	return db.record(tr, addrHash, root), nil
+}
+
+// ContractCode retrieves a particular contract's code, recording it.
+func (db *witnessDatabase) ContractCode(addrHash, codeHash common.Hash) ([]byte, error) {
+	code, err := db.Database.ContractCode(addrHash, codeHash)
+	if err == nil {
+		db.witness.AddCode(code)
+	}
+	return code, err
+}
+
+// ContractCodeSize retrieves a particular contracts code's size. The whole code
+// is recorded, as a stateless execution can't tell the size without it.
+func (db *witnessDatabase) ContractCodeSize(addrHash, codeHash common.Hash) (int, error) {
+	code, err := db.ContractCode(addrHash, codeHash)
+	return len(code), err
+}
+
+// StartWitness starts recording into the witness the trie nodes and the codes
+// accessed, bypassing the snapshot so that every read goes through the tries.
+// It must be called before any account is accessed and before the prefetcher
+// is started.
+func (s *StateDB) StartWitness(witness *stateless.Witness) {
+	db := &witnessDatabase{Database: s.db, witness: witness}
+	s.trie = db.record(s.trie, common.Hash{}, s.originalRoot)
+	s.db, s.witness = db, witness
+}
+
+// Witness returns the witness being recorded, if any.
+func (s *StateDB) Witness() *stateless.Witness {
+	return s.witness
+}
@@ -36,12 +36,19 @@ import (
 // StateProcessor implements Processor.
 type StateProcessor struct {
 	config *params.ChainConfig // Chain configuration options
-	bc     *BlockChain         // Canonical block chain
+	bc     processorChain      // Canonical block chain
 	engine consensus.Engine    // Consensus engine used for block rewards
 }
 
+// processorChain is the chain access needed to process a block: the ancestor
+// headers for the BLOCKHASH opcode and the chain handed to the consensus engine.
+type processorChain interface {
+	ChainContext
+	consensus.ChainHeaderReader
+}
+
 // NewStateProcessor initialises a new StateProcessor.
-func NewStateProcessor(config *params.ChainConfig, bc *BlockChain, engine consensus.Engine) *StateProcessor {
+func NewStateProcessor(config *params.ChainConfig, bc processorChain, engine consensus.Engine) *StateProcessor {
 	return &StateProcessor{
 		config: config,
 		bc:     bc,
@@ -67,11 +74,24 @@ func (p *StateProcessor) Process(block *types.Block, statedb *state.StateDB, cfg
 		gp          = new(GasPool).AddGas(block.GasLimit())
 	)
 	// Mutate the block and state according to any hard-fork specs
+	if p.config.Verkle != nil && p.config.Verkle.Block != nil && p.config.Verkle.Block.Cmp(block.Number()) == 0 {
+		if err := statedb.ConvertToVerkle(); err != nil {
+			return nil, nil, 0, fmt.Errorf("could not convert state into verkle tree: %w", err)
+		}
+	}
 	if p.config.DAOForkSupport && p.config.DAOForkBlock != nil && p.config.DAOForkBlock.Cmp(block.Number()) == 0 {
 		misc.ApplyDAOHardFork(statedb)
 	}
 	blockContext := NewEVMBlockContext(header, p.bc, nil)
 	blockContext.L1CostFunc = NewL1CostFunc(p.config, statedb)
+	if witness := statedb.Witness(); witness != nil {
+		// Pull the headers proving the accessed block hashes into the witness
+		getHash := blockContext.GetHash
+		blockContext.GetHash = func(n uint64) common.Hash {
+			witness.AddBlockHash(n)
+			return getHash(n)
+		}
+	}
 	vmenv := vm.NewEVM(blockContext, vm.TxContext{}, statedb, p.config, cfg)
 	// Iterate over and process the individual transactions
 	for i, tx := range block.Transactions() {
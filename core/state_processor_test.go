@@ -17,7 +17,9 @@
 package core
 
 import (
+	"bytes"
 	"crypto/ecdsa"
+	"errors"
 	"math/big"
 	"testing"
 
@@ -32,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/trie"
+	"github.com/ethereum/go-ethereum/trie/verkle"
 	"golang.org/x/crypto/sha3"
 )
 
@@ -299,6 +302,204 @@ func TestStateProcessorErrors(t *testing.T) {
 	}
 }
 
+// Tests that set code transactions install delegations to the authorized
+// contracts, and that calls to delegating accounts run the delegated code.
+func TestStateProcessorSetCode(t *testing.T) {
+	var (
+		config = &params.ChainConfig{
+			ChainID:             big.NewInt(1),
+			HomesteadBlock:      big.NewInt(0),
+			EIP150Block:         big.NewInt(0),
+			EIP155Block:         big.NewInt(0),
+			EIP158Block:         big.NewInt(0),
+			ByzantiumBlock:      big.NewInt(0),
+			ConstantinopleBlock: big.NewInt(0),
+			PetersburgBlock:     big.NewInt(0),
+			IstanbulBlock:       big.NewInt(0),
+			MuirGlacierBlock:    big.NewInt(0),
+			BerlinBlock:         big.NewInt(0),
+			LondonBlock:         big.NewInt(0),
+			Ethash:              new(params.EthashConfig),
+			SetCodeTx:           &params.SetCodeTxConfig{Block: big.NewInt(0)},
+		}
+		signer    = types.LatestSigner(config)
+		key1, _   = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		key2, _   = crypto.HexToECDSA("0202020202020202020202020202020202020202020202020202002020202020")
+		sender    = crypto.PubkeyToAddress(key1.PublicKey)
+		authority = crypto.PubkeyToAddress(key2.PublicKey)
+		target    = common.HexToAddress("0xaaaa")
+
+		db    = rawdb.NewMemoryDatabase()
+		gspec = &Genesis{
+			Config: config,
+			Alloc: GenesisAlloc{
+				sender: {Balance: big.NewInt(1000000000000000000)},
+				// PUSH1 0x42 PUSH1 0x00 SSTORE STOP
+				target: {Code: common.FromHex("604260005500"), Balance: common.Big0},
+			},
+		}
+		genesis = gspec.MustCommit(db)
+	)
+	blockchain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer blockchain.Stop()
+
+	auth, err := types.SignSetCode(key2, types.SetCodeAuthorization{
+		ChainID: config.ChainID,
+		Address: target,
+	})
+	if err != nil {
+		t.Fatalf("failed to sign authorization: %v", err)
+	}
+	tx := types.MustSignNewTx(key1, signer, &types.SetCodeTx{
+		ChainID:   config.ChainID,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(params.InitialBaseFee),
+		Gas:       100000,
+		To:        authority,
+		AuthList:  []types.SetCodeAuthorization{auth},
+	})
+	blocks, _ := GenerateChain(config, genesis, ethash.NewFaker(), db, 1, func(i int, b *BlockGen) {
+		b.AddTx(tx)
+	})
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	statedb, err := blockchain.State()
+	if err != nil {
+		t.Fatalf("failed to retrieve state: %v", err)
+	}
+	if addr, ok := types.ParseDelegation(statedb.GetCode(authority)); !ok || addr != target {
+		t.Fatalf("delegation mismatch: have %x (%v), want %x", addr, ok, target)
+	}
+	if nonce := statedb.GetNonce(authority); nonce != 1 {
+		t.Fatalf("authority nonce mismatch: have %d, want 1", nonce)
+	}
+	if have, want := statedb.GetState(authority, common.Hash{}), common.BytesToHash([]byte{0x42}); have != want {
+		t.Fatalf("delegated code not executed: have %x, want %x", have, want)
+	}
+}
+
+// Tests that delegation designators have no meaning before EIP-7702 is enabled,
+// senders with such code not counting as EOAs.
+func TestStateProcessorSetCodeBeforeFork(t *testing.T) {
+	var (
+		config  = params.TestChainConfig
+		signer  = types.LatestSigner(config)
+		key, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		db      = rawdb.NewMemoryDatabase()
+		genesis = (&Genesis{
+			Config: config,
+			Alloc: GenesisAlloc{
+				sender: {Balance: big.NewInt(1000000000000000000), Code: types.AddressToDelegation(common.HexToAddress("0xaaaa"))},
+			},
+		}).MustCommit(db)
+	)
+	blockchain, _ := NewBlockChain(db, nil, config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer blockchain.Stop()
+
+	tx := types.MustSignNewTx(key, signer, &types.LegacyTx{Gas: params.TxGas, GasPrice: big.NewInt(params.InitialBaseFee), To: &common.Address{}})
+	block := GenerateBadBlock(genesis, ethash.NewFaker(), types.Transactions{tx}, config)
+	_, err := blockchain.InsertChain(types.Blocks{block})
+	if !errors.Is(err, ErrSenderNoEOA) {
+		t.Fatalf("delegating sender error mismatch: have %v, want %v", err, ErrSenderNoEOA)
+	}
+}
+
+// Tests that the state is converted into a verkle tree at the configured block,
+// the accounts, code and storage of the tries being carried over, and that the
+// blocks after the conversion are executed over the tree.
+func TestStateProcessorVerkle(t *testing.T) {
+	var (
+		config    = *params.TestChainConfig
+		signer    = types.LatestSigner(&config)
+		key, _    = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		sender    = crypto.PubkeyToAddress(key.PublicKey)
+		recipient = common.HexToAddress("0xbbbb")
+		target    = common.HexToAddress("0xaaaa")
+		deployed  = crypto.CreateAddress(sender, 2)
+
+		// PUSH1 0x42 PUSH1 0x00 SSTORE STOP
+		code = common.FromHex("604260005500")
+		// PUSH6 code PUSH1 0x00 MSTORE PUSH1 0x06 PUSH1 0x1a RETURN
+		initcode = common.FromHex("656042600055006000526006601af3")
+
+		db    = rawdb.NewMemoryDatabase()
+		gspec = &Genesis{
+			Config: &config,
+			Alloc: GenesisAlloc{
+				sender: {Balance: big.NewInt(1000000000000000000)},
+				target: {Code: code, Storage: map[common.Hash]common.Hash{{1}: {7}}, Balance: common.Big0},
+			},
+		}
+	)
+	config.Verkle = &params.VerkleConfig{Block: big.NewInt(2)}
+	genesis := gspec.MustCommit(db)
+
+	blockchain, err := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	// Generate the chain in a database of its own, for the import to convert
+	// the state too
+	gendb := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(gendb)
+
+	var nonce uint64
+	send := func(b *BlockGen, to *common.Address, data []byte) {
+		tx := types.MustSignNewTx(key, signer, &types.LegacyTx{Nonce: nonce, To: to, Value: big.NewInt(1000), Gas: 100000, GasPrice: big.NewInt(params.InitialBaseFee), Data: data})
+		b.AddTx(tx)
+		nonce++
+	}
+	blocks, _ := GenerateChain(&config, genesis, ethash.NewFaker(), gendb, 4, func(i int, b *BlockGen) {
+		switch i {
+		case 0:
+			send(b, &recipient, nil)
+		case 1:
+			send(b, &target, nil)
+		case 2:
+			send(b, nil, initcode)
+			send(b, &recipient, nil)
+		case 3:
+			send(b, &deployed, nil)
+		}
+	})
+	if _, err := blockchain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert chain: %v", err)
+	}
+	for i, block := range blocks {
+		if have, want := verkle.IsRoot(db, block.Root()), config.IsVerkle(block.Number()); have != want {
+			t.Errorf("block %d verkle root mismatch: have %v, want %v", block.Number(), have, want)
+		}
+		if i > 0 && block.Root() == blocks[i-1].Root() {
+			t.Errorf("block %d state unchanged", block.Number())
+		}
+	}
+	statedb, err := blockchain.State()
+	if err != nil {
+		t.Fatalf("failed to retrieve state: %v", err)
+	}
+	if balance := statedb.GetBalance(recipient); balance.Cmp(big.NewInt(2000)) != 0 {
+		t.Errorf("recipient balance mismatch: have %v, want 2000", balance)
+	}
+	if nonce := statedb.GetNonce(sender); nonce != 5 {
+		t.Errorf("sender nonce mismatch: have %d, want 5", nonce)
+	}
+	for _, addr := range []common.Address{target, deployed} {
+		if have := statedb.GetCode(addr); !bytes.Equal(have, code) {
+			t.Errorf("code of %x mismatch: have %x, want %x", addr, have, code)
+		}
+		if have, want := statedb.GetState(addr, common.Hash{}), common.BytesToHash([]byte{0x42}); have != want {
+			t.Errorf("slot of %x mismatch: have %x, want %x", addr, have, want)
+		}
+	}
+	if have, want := statedb.GetState(target, common.Hash{1}), (common.Hash{7}); have != want {
+		t.Errorf("converted slot mismatch: have %x, want %x", have, want)
+	}
+}
+
 // GenerateBadBlock constructs a "block" which contains the transactions. The transactions are not expected to be
 // valid, and no proper post-state can be made. But from the perspective of the blockchain, the block is sufficiently
 // valid to be considered for import:
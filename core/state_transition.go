@@ -23,6 +23,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	cmath "github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/consensus/misc"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -61,6 +62,9 @@ type StateTransition struct {
 	data       []byte
 	state      vm.StateDB
 	evm        *vm.EVM
+
+	// feeCurrency pays the fees of the message instead of ether, if set.
+	feeCurrency FeeCurrency
 }
 
 // Message represents a message sent to a contract.
@@ -78,6 +82,13 @@ type Message interface {
 	Mint() *big.Int
 	RollupDataGas() uint64
 
+	// BlobGasFeeCap and BlobHashes are nil if the message carries no blobs
+	BlobGasFeeCap() *big.Int
+	BlobHashes() []common.Hash
+
+	// SetCodeAuthorizations is nil if the message doesn't set account code
+	SetCodeAuthorizations() []types.SetCodeAuthorization
+
 	Nonce() uint64
 	IsFake() bool
 	Data() []byte
@@ -120,7 +131,7 @@ func (result *ExecutionResult) Revert() []byte {
 }
 
 // IntrinsicGas computes the 'intrinsic gas' for a message with the given data.
-func IntrinsicGas(data []byte, accessList types.AccessList, isContractCreation bool, isHomestead, isEIP2028 bool) (uint64, error) {
+func IntrinsicGas(data []byte, accessList types.AccessList, authList []types.SetCodeAuthorization, isContractCreation bool, isHomestead, isEIP2028 bool) (uint64, error) {
 	// Set the starting gas for the raw transaction
 	var gas uint64
 	if isContractCreation && isHomestead {
@@ -157,6 +168,9 @@ func IntrinsicGas(data []byte, accessList types.AccessList, isContractCreation b
 		gas += uint64(len(accessList)) * params.TxAccessListAddressGas
 		gas += uint64(accessList.StorageKeys()) * params.TxAccessListStorageKeyGas
 	}
+	if authList != nil {
+		gas += uint64(len(authList)) * params.CallNewAccountGas
+	}
 	return gas, nil
 }
 
@@ -205,6 +219,10 @@ func (st *StateTransition) buyGas() error {
 	if l1Cost != nil {
 		mgval = mgval.Add(mgval, l1Cost)
 	}
+	blobGas := new(big.Int).SetUint64(uint64(len(st.msg.BlobHashes())) * params.BlobTxBlobGasPerBlob)
+	if blobGas.Sign() > 0 {
+		mgval.Add(mgval, new(big.Int).Mul(blobGas, st.blobGasPrice()))
+	}
 	balanceCheck := mgval
 	if st.gasFeeCap != nil {
 		balanceCheck = new(big.Int).SetUint64(st.msg.Gas())
@@ -213,8 +231,24 @@ func (st *StateTransition) buyGas() error {
 		if l1Cost != nil {
 			balanceCheck.Add(balanceCheck, l1Cost)
 		}
+		if blobGas.Sign() > 0 {
+			balanceCheck.Add(balanceCheck, new(big.Int).Mul(blobGas, st.msg.BlobGasFeeCap()))
+		}
 	}
-	if have, want := st.state.GetBalance(st.msg.From()), balanceCheck; have.Cmp(want) < 0 {
+	// Fees paid in the fee currency of the network are checked against its
+	// balance, leaving only the transferred value to the ether balance.
+	st.feeCurrency = activeFeeCurrency(st.evm.ChainConfig(), st.evm.Context.BlockNumber, st.msg)
+	if st.feeCurrency != nil {
+		if have, want := st.state.GetBalance(st.msg.From()), st.value; have.Cmp(want) < 0 {
+			return fmt.Errorf("%w: address %v have %v want %v", ErrInsufficientFunds, st.msg.From().Hex(), have, want)
+		}
+		if st.gasFeeCap != nil {
+			balanceCheck = new(big.Int).Sub(balanceCheck, st.value)
+		}
+		if have, want := st.feeCurrency.Balance(st.state, st.msg.From()), balanceCheck; have.Cmp(want) < 0 {
+			return fmt.Errorf("%w: address %v have %v want %v in fee currency", ErrInsufficientFunds, st.msg.From().Hex(), have, want)
+		}
+	} else if have, want := st.state.GetBalance(st.msg.From()), balanceCheck; have.Cmp(want) < 0 {
 		return fmt.Errorf("%w: address %v have %v want %v", ErrInsufficientFunds, st.msg.From().Hex(), have, want)
 	}
 	if err := st.gp.SubGas(st.msg.Gas()); err != nil {
@@ -223,10 +257,24 @@ func (st *StateTransition) buyGas() error {
 	st.gas += st.msg.Gas()
 
 	st.initialGas = st.msg.Gas()
-	st.state.SubBalance(st.msg.From(), mgval)
+	if st.feeCurrency != nil {
+		st.feeCurrency.Debit(st.state, st.msg.From(), mgval)
+	} else {
+		st.state.SubBalance(st.msg.From(), mgval)
+	}
 	return nil
 }
 
+// payFee credits a fee to the account, in the fee currency if the message pays
+// its fees in it.
+func (st *StateTransition) payFee(addr common.Address, amount *big.Int) {
+	if st.feeCurrency != nil {
+		st.feeCurrency.Credit(st.state, addr, amount)
+	} else {
+		st.state.AddBalance(addr, amount)
+	}
+}
+
 func (st *StateTransition) preCheck() error {
 	if st.msg.Nonce() == types.DepositsNonce {
 		// No fee fields to check, no nonce to check, and no need to check if EOA (L1 already verified it for us)
@@ -249,8 +297,13 @@ func (st *StateTransition) preCheck() error {
 			return fmt.Errorf("%w: address %v, nonce: %d", ErrNonceMax,
 				st.msg.From().Hex(), stNonce)
 		}
-		// Make sure the sender is an EOA
-		if codeHash := st.state.GetCodeHash(st.msg.From()); codeHash != emptyCodeHash && codeHash != (common.Hash{}) {
+		// Make sure the sender is an EOA, accounts delegating their code
+		// still count as one once EIP-7702 is enabled
+		var delegated bool
+		if st.evm.ChainConfig().IsSetCodeTx(st.evm.Context.BlockNumber) {
+			_, delegated = types.ParseDelegation(st.state.GetCode(st.msg.From()))
+		}
+		if codeHash := st.state.GetCodeHash(st.msg.From()); codeHash != emptyCodeHash && codeHash != (common.Hash{}) && !delegated {
 			return fmt.Errorf("%w: address %v, codehash: %s", ErrSenderNoEOA,
 				st.msg.From().Hex(), codeHash)
 		}
@@ -279,9 +332,63 @@ func (st *StateTransition) preCheck() error {
 			}
 		}
 	}
+	// Check the blobs and that the blob gas fee cap covers the blob gas fee
+	if hashes := st.msg.BlobHashes(); hashes != nil {
+		if !st.evm.ChainConfig().IsBlobTx(st.evm.Context.BlockNumber) {
+			return fmt.Errorf("%w: address %v, blob transaction", ErrTxTypeNotSupported, st.msg.From().Hex())
+		}
+		if err := validateBlobHashes(st.evm.ChainConfig(), hashes); err != nil {
+			return fmt.Errorf("%w: address %v", err, st.msg.From().Hex())
+		}
+		// Skip the check if the fee cap is zero and the base fee was disabled (eth_call)
+		if !st.evm.Config.NoBaseFee || st.msg.BlobGasFeeCap().BitLen() > 0 {
+			if fee := st.blobGasPrice(); st.msg.BlobGasFeeCap().Cmp(fee) < 0 {
+				return fmt.Errorf("%w: address %v, maxFeePerBlobGas: %s blobGasFee: %s", ErrBlobFeeCapTooLow,
+					st.msg.From().Hex(), st.msg.BlobGasFeeCap(), fee)
+			}
+		}
+	}
+	// Check that set code transactions are enabled and carry authorizations
+	if auths := st.msg.SetCodeAuthorizations(); auths != nil {
+		if !st.evm.ChainConfig().IsSetCodeTx(st.evm.Context.BlockNumber) {
+			return fmt.Errorf("%w: address %v, set code transaction", ErrTxTypeNotSupported, st.msg.From().Hex())
+		}
+		if st.msg.To() == nil {
+			return fmt.Errorf("%w: address %v", ErrSetCodeTxCreate, st.msg.From().Hex())
+		}
+		if len(auths) == 0 {
+			return fmt.Errorf("%w: address %v", ErrEmptyAuthList, st.msg.From().Hex())
+		}
+	}
 	return st.buyGas()
 }
 
+// blobGasPrice returns the price of blob gas in the current block.
+func (st *StateTransition) blobGasPrice() *big.Int {
+	var excessBlobGas uint64
+	if st.evm.Context.ExcessBlobGas != nil {
+		excessBlobGas = *st.evm.Context.ExcessBlobGas
+	}
+	return misc.CalcBlobFee(st.evm.ChainConfig(), excessBlobGas)
+}
+
+// validateBlobHashes checks the number and versions of the blob hashes of a
+// blob transaction.
+func validateBlobHashes(config *params.ChainConfig, hashes []common.Hash) error {
+	if len(hashes) == 0 {
+		return ErrMissingBlobHashes
+	}
+	if max := config.BlobTx.MaxBlobGas(); uint64(len(hashes))*params.BlobTxBlobGasPerBlob > max {
+		return fmt.Errorf("%w: %d blobs, limit %d", ErrBlobGasLimit, len(hashes), max/params.BlobTxBlobGasPerBlob)
+	}
+	for i, hash := range hashes {
+		if hash[0] != params.BlobTxHashVersion {
+			return fmt.Errorf("%w: blob %d has version %d", ErrBlobHashVersion, i, hash[0])
+		}
+	}
+	return nil
+}
+
 // TransitionDb will transition the state by applying the current message and
 // returning the evm execution result with following fields.
 //
@@ -315,6 +422,13 @@ func (st *StateTransition) TransitionDb() (*ExecutionResult, error) {
 				ReturnData: nil,
 			}
 			err = nil
+
+			// The failed deposit never entered the EVM, report it to the tracer
+			// as a call without value transfer so that it still gets a frame.
+			if st.evm.Config.Debug {
+				st.evm.Config.Tracer.CaptureStart(st.evm, st.msg.From(), st.to(), st.msg.To() == nil, st.data, st.msg.Gas(), common.Big0)
+				st.evm.Config.Tracer.CaptureEnd(nil, st.msg.Gas(), 0, result.Err)
+			}
 		}
 	}
 	return result, err
@@ -339,7 +453,12 @@ func (st *StateTransition) innerTransitionDb() (*ExecutionResult, error) {
 	if st.evm.Config.Debug {
 		st.evm.Config.Tracer.CaptureTxStart(st.initialGas)
 		defer func() {
-			st.evm.Config.Tracer.CaptureTxEnd(st.gas)
+			// Deposits are charged their full gas limit, regardless of execution
+			if st.msg.Nonce() == types.DepositsNonce {
+				st.evm.Config.Tracer.CaptureTxEnd(0)
+			} else {
+				st.evm.Config.Tracer.CaptureTxEnd(st.gas)
+			}
 		}()
 	}
 
@@ -351,7 +470,7 @@ func (st *StateTransition) innerTransitionDb() (*ExecutionResult, error) {
 	)
 
 	// Check clauses 4-5, subtract intrinsic gas if everything is correct
-	gas, err := IntrinsicGas(st.data, st.msg.AccessList(), contractCreation, rules.IsHomestead, rules.IsIstanbul)
+	gas, err := IntrinsicGas(st.data, st.msg.AccessList(), st.msg.SetCodeAuthorizations(), contractCreation, rules.IsHomestead, rules.IsIstanbul)
 	if err != nil {
 		return nil, err
 	}
@@ -378,6 +497,20 @@ func (st *StateTransition) innerTransitionDb() (*ExecutionResult, error) {
 	} else {
 		// Increment the nonce for the next transaction
 		st.state.SetNonce(msg.From(), st.state.GetNonce(sender.Address())+1)
+
+		// Apply the authorizations, invalid ones are skipped without failing
+		// the transaction
+		if auths := msg.SetCodeAuthorizations(); auths != nil {
+			for _, auth := range auths {
+				st.applyAuthorization(&auth)
+			}
+		}
+		// Warm the delegation target of the destination, if any
+		if rules.IsSetCodeTx {
+			if addr, ok := types.ParseDelegation(st.state.GetCode(*msg.To())); ok {
+				st.state.AddAddressToAccessList(addr)
+			}
+		}
 		ret, st.gas, vmerr = st.evm.Call(sender, st.to(), st.data, st.gas, st.value)
 	}
 
@@ -408,13 +541,13 @@ func (st *StateTransition) innerTransitionDb() (*ExecutionResult, error) {
 	} else {
 		fee := new(big.Int).SetUint64(st.gasUsed())
 		fee.Mul(fee, effectiveTip)
-		st.state.AddBalance(st.evm.Context.Coinbase, fee)
+		st.payFee(st.evm.Context.Coinbase, fee)
 	}
 
 	if optimismConfig := st.evm.ChainConfig().Optimism; optimismConfig != nil {
-		st.state.AddBalance(optimismConfig.BaseFeeRecipient, new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.evm.Context.BaseFee))
+		st.payFee(optimismConfig.BaseFeeRecipient, new(big.Int).Mul(new(big.Int).SetUint64(st.gasUsed()), st.evm.Context.BaseFee))
 		if cost := st.evm.Context.L1CostFunc(st.evm.Context.BlockNumber.Uint64(), st.msg); cost != nil {
-			st.state.AddBalance(optimismConfig.L1FeeRecipient, cost)
+			st.payFee(optimismConfig.L1FeeRecipient, cost)
 		}
 	}
 
@@ -425,6 +558,55 @@ func (st *StateTransition) innerTransitionDb() (*ExecutionResult, error) {
 	}, nil
 }
 
+// validateAuthorization checks an authorization against the chain and the
+// state, returning the authorizing account if it may set its code.
+func (st *StateTransition) validateAuthorization(auth *types.SetCodeAuthorization) (common.Address, error) {
+	// Verify the chain ID is zero or matches the current chain
+	if auth.ChainID.Sign() != 0 && auth.ChainID.Cmp(st.evm.ChainConfig().ChainID) != 0 {
+		return common.Address{}, ErrAuthorizationWrongChainID
+	}
+	// Limit the nonce to 2^64-1 per EIP-2681
+	if auth.Nonce+1 < auth.Nonce {
+		return common.Address{}, ErrAuthorizationNonceOverflow
+	}
+	authority, err := auth.Authority()
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", ErrAuthorizationInvalidSignature, err)
+	}
+	// The authority is accessed even if the authorization ends up invalid
+	st.state.AddAddressToAccessList(authority)
+
+	// The authority must not be a contract, but may already delegate its code
+	code := st.state.GetCode(authority)
+	if _, ok := types.ParseDelegation(code); len(code) != 0 && !ok {
+		return common.Address{}, ErrAuthorizationDestinationHasCode
+	}
+	if have := st.state.GetNonce(authority); have != auth.Nonce {
+		return common.Address{}, ErrAuthorizationNonceMismatch
+	}
+	return authority, nil
+}
+
+// applyAuthorization sets the code of the authorizing account to a delegation
+// to the authorized address, or clears it if the authorized address is zero.
+func (st *StateTransition) applyAuthorization(auth *types.SetCodeAuthorization) error {
+	authority, err := st.validateAuthorization(auth)
+	if err != nil {
+		return err
+	}
+	// Existing accounts were charged for being created, refund the difference
+	if st.state.Exist(authority) {
+		st.state.AddRefund(params.CallNewAccountGas - params.TxAuthTupleGas)
+	}
+	st.state.SetNonce(authority, auth.Nonce+1)
+	if auth.Address == (common.Address{}) {
+		st.state.SetCode(authority, nil)
+		return nil
+	}
+	st.state.SetCode(authority, types.AddressToDelegation(auth.Address))
+	return nil
+}
+
 func (st *StateTransition) refundGas(refundQuotient uint64) {
 	// Apply refund counter, capped to a refund quotient
 	refund := st.gasUsed() / refundQuotient
@@ -435,7 +617,7 @@ func (st *StateTransition) refundGas(refundQuotient uint64) {
 
 	// Return ETH for remaining gas, exchanged at the original rate.
 	remaining := new(big.Int).Mul(new(big.Int).SetUint64(st.gas), st.gasPrice)
-	st.state.AddBalance(st.msg.From(), remaining)
+	st.payFee(st.msg.From(), remaining)
 
 	// Also return remaining gas to the block gas counter so it is
 	// available for the next transaction.
@@ -0,0 +1,100 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/stateless"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// ExecuteStateless re-executes a block on top of the state contained in its
+// witness alone, without access to any chain or state database, and verifies
+// the gas used, the receipts and the post state root against the block header.
+func ExecuteStateless(config *params.ChainConfig, engine consensus.Engine, cfg vm.Config, block *types.Block, witness *stateless.Witness) error {
+	if err := witness.Verify(block); err != nil {
+		return err
+	}
+	statedb, err := state.New(witness.Root(), state.NewDatabase(witness.MakeHashDB()), nil)
+	if err != nil {
+		return err
+	}
+	chain := &statelessChain{config: config, engine: engine, witness: witness}
+	receipts, _, usedGas, err := NewStateProcessor(config, chain, engine).Process(block, statedb, cfg)
+	if err != nil {
+		return err
+	}
+	if err := NewBlockValidator(config, nil, engine).ValidateState(block, statedb, receipts, usedGas); err != nil {
+		return err
+	}
+	// Nodes missing from the witness surface as database errors, make sure the
+	// execution didn't silently run on a partial state.
+	if err := statedb.Error(); err != nil {
+		return fmt.Errorf("incomplete witness: %v", err)
+	}
+	return nil
+}
+
+// statelessChain serves the ancestor headers of a stateless execution from its
+// witness.
+type statelessChain struct {
+	config  *params.ChainConfig
+	engine  consensus.Engine
+	witness *stateless.Witness
+}
+
+func (c *statelessChain) Config() *params.ChainConfig { return c.config }
+func (c *statelessChain) Engine() consensus.Engine    { return c.engine }
+func (c *statelessChain) CurrentHeader() *types.Header {
+	return c.witness.Headers[0]
+}
+
+func (c *statelessChain) GetHeader(hash common.Hash, number uint64) *types.Header {
+	for _, header := range c.witness.Headers {
+		if header.Number.Uint64() == number && header.Hash() == hash {
+			return header
+		}
+	}
+	return nil
+}
+
+func (c *statelessChain) GetHeaderByNumber(number uint64) *types.Header {
+	for _, header := range c.witness.Headers {
+		if header.Number.Uint64() == number {
+			return header
+		}
+	}
+	return nil
+}
+
+func (c *statelessChain) GetHeaderByHash(hash common.Hash) *types.Header {
+	for _, header := range c.witness.Headers {
+		if header.Hash() == hash {
+			return header
+		}
+	}
+	return nil
+}
+
+func (c *statelessChain) GetTd(hash common.Hash, number uint64) *big.Int { return nil }
@@ -0,0 +1,136 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package stateless
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// extWitness is a witness RLP encoding for transferring across clients.
+type extWitness struct {
+	Headers []*types.Header
+	Codes   [][]byte
+	State   [][]byte
+}
+
+// jsonWitness is the witness encoding served over RPC.
+type jsonWitness struct {
+	Headers []*types.Header `json:"headers"`
+	Codes   []hexutil.Bytes `json:"codes"`
+	State   []hexutil.Bytes `json:"state"`
+}
+
+// toExtWitness converts our internal witness representation to the consensus one,
+// with the codes and trie nodes sorted for a deterministic encoding.
+func (w *Witness) toExtWitness() *extWitness {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	ext := &extWitness{
+		Headers: w.Headers,
+		Codes:   make([][]byte, 0, len(w.Codes)),
+		State:   make([][]byte, 0, len(w.State)),
+	}
+	for code := range w.Codes {
+		ext.Codes = append(ext.Codes, []byte(code))
+	}
+	for node := range w.State {
+		ext.State = append(ext.State, []byte(node))
+	}
+	sortBlobs(ext.Codes)
+	sortBlobs(ext.State)
+	return ext
+}
+
+// fromExtWitness converts the consensus witness format into our internal one.
+func (w *Witness) fromExtWitness(ext *extWitness) {
+	w.Headers = ext.Headers
+	w.Codes = make(map[string]struct{}, len(ext.Codes))
+	for _, code := range ext.Codes {
+		w.Codes[string(code)] = struct{}{}
+	}
+	w.State = make(map[string]struct{}, len(ext.State))
+	for _, node := range ext.State {
+		w.State[string(node)] = struct{}{}
+	}
+}
+
+// EncodeRLP serializes a witness as RLP.
+func (w *Witness) EncodeRLP(wr io.Writer) error {
+	return rlp.Encode(wr, w.toExtWitness())
+}
+
+// DecodeRLP decodes a witness from RLP.
+func (w *Witness) DecodeRLP(s *rlp.Stream) error {
+	var ext extWitness
+	if err := s.Decode(&ext); err != nil {
+		return err
+	}
+	w.fromExtWitness(&ext)
+	return nil
+}
+
+// MarshalJSON serializes a witness as JSON, hex encoding the codes and nodes.
+func (w *Witness) MarshalJSON() ([]byte, error) {
+	ext := w.toExtWitness()
+	enc := jsonWitness{
+		Headers: ext.Headers,
+		Codes:   make([]hexutil.Bytes, len(ext.Codes)),
+		State:   make([]hexutil.Bytes, len(ext.State)),
+	}
+	for i, code := range ext.Codes {
+		enc.Codes[i] = code
+	}
+	for i, node := range ext.State {
+		enc.State[i] = node
+	}
+	return json.Marshal(&enc)
+}
+
+// UnmarshalJSON decodes a witness from JSON.
+func (w *Witness) UnmarshalJSON(input []byte) error {
+	var dec jsonWitness
+	if err := json.Unmarshal(input, &dec); err != nil {
+		return err
+	}
+	ext := &extWitness{
+		Headers: dec.Headers,
+		Codes:   make([][]byte, len(dec.Codes)),
+		State:   make([][]byte, len(dec.State)),
+	}
+	for i, code := range dec.Codes {
+		ext.Codes[i] = code
+	}
+	for i, node := range dec.State {
+		ext.State[i] = node
+	}
+	w.fromExtWitness(ext)
+	return nil
+}
+
+func sortBlobs(blobs [][]byte) {
+	sort.Slice(blobs, func(i, j int) bool {
+		return bytes.Compare(blobs[i], blobs[j]) < 0
+	})
+}
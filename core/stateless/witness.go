@@ -0,0 +1,150 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package stateless implements the execution witness of a block: the minimal set
+// of trie nodes, contract codes and ancestor headers needed to re-execute the
+// block without access to the state database.
+package stateless
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// HeaderReader is an interface to pull in headers in place of block hashes for
+// the witness.
+type HeaderReader interface {
+	// GetHeader retrieves a block header from the database by hash and number.
+	GetHeader(hash common.Hash, number uint64) *types.Header
+}
+
+// Witness encompasses the state required to apply a block and derive its post
+// state root.
+type Witness struct {
+	context *types.Header // Header to which this witness belongs to, nil if decoded
+
+	Headers []*types.Header     // Past headers in reverse order (0=parent, 1=parent's-parent, etc). First must be set.
+	Codes   map[string]struct{} // Set of bytecodes ran or accessed
+	State   map[string]struct{} // Set of trie nodes (account and storage together) resolved
+
+	chain HeaderReader // Chain reader to convert block hash ops to header proofs
+	lock  sync.Mutex   // Lock to allow concurrent trie node recording
+}
+
+// NewWitness creates an empty witness ready for recording the execution of the
+// block with the given header.
+func NewWitness(context *types.Header, chain HeaderReader) (*Witness, error) {
+	if context.Number.Sign() == 0 {
+		return nil, errors.New("cannot create witness for the genesis block")
+	}
+	parent := chain.GetHeader(context.ParentHash, context.Number.Uint64()-1)
+	if parent == nil {
+		return nil, errors.New("failed to retrieve parent header")
+	}
+	return &Witness{
+		context: context,
+		Headers: []*types.Header{parent},
+		Codes:   make(map[string]struct{}),
+		State:   make(map[string]struct{}),
+		chain:   chain,
+	}, nil
+}
+
+// AddBlockHash adds a "blockhash" to the witness with the designated offset from
+// chain head. Under the hood, this method actually pulls in enough headers from
+// the chain to cover the block being added.
+func (w *Witness) AddBlockHash(number uint64) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.chain == nil || number >= w.context.Number.Uint64() {
+		return
+	}
+	for int(w.context.Number.Uint64()-number) > len(w.Headers) {
+		tail := w.Headers[len(w.Headers)-1]
+		if tail.Number.Sign() == 0 {
+			return
+		}
+		header := w.chain.GetHeader(tail.ParentHash, tail.Number.Uint64()-1)
+		if header == nil {
+			return
+		}
+		w.Headers = append(w.Headers, header)
+	}
+}
+
+// AddCode adds a bytecode blob to the witness.
+func (w *Witness) AddCode(code []byte) {
+	if len(code) == 0 {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.Codes[string(code)] = struct{}{}
+}
+
+// AddNode adds a trie node blob to the witness. The hash is the one the node was
+// referenced by, which always equals the hash of the blob.
+func (w *Witness) AddNode(hash common.Hash, blob []byte) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.State[string(blob)] = struct{}{}
+}
+
+// Root returns the pre-state root from the first header.
+func (w *Witness) Root() common.Hash {
+	return w.Headers[0].Root
+}
+
+// MakeHashDB imports the codes and trie nodes of the witness into a new in-memory
+// database that can serve as the backing store of a stateless execution.
+func (w *Witness) MakeHashDB() ethdb.Database {
+	db := rawdb.NewMemoryDatabase()
+	for code := range w.Codes {
+		blob := []byte(code)
+		rawdb.WriteCode(db, crypto.Keccak256Hash(blob), blob)
+	}
+	for node := range w.State {
+		blob := []byte(node)
+		rawdb.WriteTrieNode(db, crypto.Keccak256Hash(blob), blob)
+	}
+	return db
+}
+
+// Verify checks that the witness headers form an unbroken chain ending in the
+// parent of the given block.
+func (w *Witness) Verify(block *types.Block) error {
+	if len(w.Headers) == 0 {
+		return errors.New("witness has no headers")
+	}
+	if w.Headers[0].Hash() != block.ParentHash() {
+		return errors.New("witness parent header mismatch")
+	}
+	for i := 1; i < len(w.Headers); i++ {
+		if w.Headers[i].Hash() != w.Headers[i-1].ParentHash {
+			return errors.New("witness header chain broken")
+		}
+	}
+	return nil
+}
@@ -43,13 +43,15 @@ func (*devNull) Close() error                      { return nil }
 // txJournal is a rotating log of transactions with the aim of storing locally
 // created transactions to allow non-executed ones to survive node restarts.
 type txJournal struct {
+	kind   string         // Kind of journaled transactions, local or remote
 	path   string         // Filesystem path to store the transactions at
 	writer io.WriteCloser // Output stream to write new transactions into
 }
 
 // newTxJournal creates a new transaction journal to
-func newTxJournal(path string) *txJournal {
+func newTxJournal(kind string, path string) *txJournal {
 	return &txJournal{
+		kind: kind,
 		path: path,
 	}
 }
@@ -111,7 +113,7 @@ func (journal *txJournal) load(add func([]*types.Transaction) []error) error {
 			batch = batch[:0]
 		}
 	}
-	log.Info("Loaded local transaction journal", "transactions", total, "dropped", dropped)
+	log.Info("Loaded "+journal.kind+" transaction journal", "transactions", total, "dropped", dropped)
 
 	return failure
 }
@@ -163,7 +165,7 @@ func (journal *txJournal) rotate(all map[common.Address]types.Transactions) erro
 		return err
 	}
 	journal.writer = sink
-	log.Info("Regenerated local transaction journal", "transactions", journaled, "accounts", len(all))
+	log.Info("Regenerated "+journal.kind+" transaction journal", "transactions", journaled, "accounts", len(all))
 
 	return nil
 }
@@ -0,0 +1,83 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	// ErrTxSizeLimit is returned if the encoded size of a transaction exceeds
+	// the configured limit.
+	ErrTxSizeLimit = errors.New("transaction size exceeds limit")
+
+	// ErrCalldataLimit is returned if the input data of a transaction exceeds
+	// the configured limit.
+	ErrCalldataLimit = errors.New("calldata exceeds limit")
+
+	// ErrInitCodeLimit is returned if the input data of a contract creation
+	// exceeds the configured limit.
+	ErrInitCodeLimit = errors.New("initcode exceeds limit")
+)
+
+// TxLimits bounds the size of the transactions admitted to the pool and included
+// in built blocks. Zero limits are not enforced.
+type TxLimits struct {
+	MaxTxSize   uint64 `toml:",omitempty"` // Maximum encoded size of a transaction
+	MaxCalldata uint64 `toml:",omitempty"` // Maximum input data of a transaction
+	MaxInitCode uint64 `toml:",omitempty"` // Maximum input data of a contract creation
+}
+
+// ActiveTxLimits returns the limits in effect for the block with the given
+// number: the ones of the chain config if activated, tightened by the local ones.
+func ActiveTxLimits(config *params.ChainConfig, num *big.Int, local TxLimits) TxLimits {
+	if !config.IsTxLimits(num) {
+		return local
+	}
+	return TxLimits{
+		MaxTxSize:   minLimit(config.TxLimits.MaxTxSize, local.MaxTxSize),
+		MaxCalldata: minLimit(config.TxLimits.MaxCalldata, local.MaxCalldata),
+		MaxInitCode: minLimit(config.TxLimits.MaxInitCode, local.MaxInitCode),
+	}
+}
+
+// Check returns an error if the transaction exceeds any of the limits.
+func (l TxLimits) Check(tx *types.Transaction) error {
+	if size := uint64(tx.Size()); l.MaxTxSize != 0 && size > l.MaxTxSize {
+		return fmt.Errorf("%w: size %d, limit %d", ErrTxSizeLimit, size, l.MaxTxSize)
+	}
+	if data := uint64(len(tx.Data())); l.MaxCalldata != 0 && data > l.MaxCalldata {
+		return fmt.Errorf("%w: length %d, limit %d", ErrCalldataLimit, data, l.MaxCalldata)
+	}
+	if data := uint64(len(tx.Data())); tx.To() == nil && l.MaxInitCode != 0 && data > l.MaxInitCode {
+		return fmt.Errorf("%w: length %d, limit %d", ErrInitCodeLimit, data, l.MaxInitCode)
+	}
+	return nil
+}
+
+// minLimit returns the tighter of two limits, zero meaning unlimited.
+func minLimit(a, b uint64) uint64 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestActiveTxLimits(t *testing.T) {
+	config := *params.TestChainConfig
+	config.TxLimits = &params.TxLimitsConfig{MaxTxSize: 1000, MaxCalldata: 500, Block: big.NewInt(10)}
+	local := TxLimits{MaxCalldata: 800, MaxInitCode: 300}
+
+	if have := ActiveTxLimits(&config, big.NewInt(9), local); have != local {
+		t.Errorf("limits before activation mismatch: have %+v, want %+v", have, local)
+	}
+	want := TxLimits{MaxTxSize: 1000, MaxCalldata: 500, MaxInitCode: 300}
+	if have := ActiveTxLimits(&config, big.NewInt(10), local); have != want {
+		t.Errorf("limits after activation mismatch: have %+v, want %+v", have, want)
+	}
+}
+
+func TestTxLimitsCheck(t *testing.T) {
+	limits := TxLimits{MaxCalldata: 200, MaxInitCode: 100}
+	tests := []struct {
+		tx   *types.Transaction
+		want error
+	}{
+		{types.NewTransaction(0, common.Address{}, common.Big0, 0, common.Big0, make([]byte, 200)), nil},
+		{types.NewTransaction(0, common.Address{}, common.Big0, 0, common.Big0, make([]byte, 201)), ErrCalldataLimit},
+		{types.NewContractCreation(0, common.Big0, 0, common.Big0, make([]byte, 100)), nil},
+		{types.NewContractCreation(0, common.Big0, 0, common.Big0, make([]byte, 101)), ErrInitCodeLimit},
+		{types.NewTransaction(0, common.Address{}, common.Big0, 0, common.Big0, nil), nil},
+	}
+	for i, test := range tests {
+		if err := limits.Check(test.tx); !errors.Is(err, test.want) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.want)
+		}
+	}
+	if err := (TxLimits{MaxTxSize: 50}).Check(tests[0].tx); !errors.Is(err, ErrTxSizeLimit) {
+		t.Errorf("size error mismatch: have %v, want %v", err, ErrTxSizeLimit)
+	}
+}
@@ -264,6 +264,8 @@ type txList struct {
 
 	costcap *big.Int // Price of the highest costing transaction (reset only if exceeds balance)
 	gascap  uint64   // Gas limit of the highest spending transaction (reset only if exceeds block limit)
+
+	costFn func(tx *types.Transaction) *big.Int // Ether cost of a transaction, defaults to its total cost
 }
 
 // newTxList create a new transaction list for maintaining nonce-indexable fast,
@@ -276,6 +278,14 @@ func newTxList(strict bool) *txList {
 	}
 }
 
+// cost returns the ether the transaction costs its sender.
+func (l *txList) cost(tx *types.Transaction) *big.Int {
+	if l.costFn != nil {
+		return l.costFn(tx)
+	}
+	return tx.Cost()
+}
+
 // Overlaps returns whether the transaction specified has the same nonce as one
 // already contained within the list.
 func (l *txList) Overlaps(tx *types.Transaction) bool {
@@ -287,33 +297,15 @@ func (l *txList) Overlaps(tx *types.Transaction) bool {
 //
 // If the new transaction is accepted into the list, the lists' cost and gas
 // thresholds are also potentially updated.
-func (l *txList) Add(tx *types.Transaction, priceBump uint64) (bool, *types.Transaction) {
+func (l *txList) Add(tx *types.Transaction, policy ReplacementPolicy) (bool, *types.Transaction) {
 	// If there's an older better transaction, abort
 	old := l.txs.Get(tx.Nonce())
-	if old != nil {
-		if old.GasFeeCapCmp(tx) >= 0 || old.GasTipCapCmp(tx) >= 0 {
-			return false, nil
-		}
-		// thresholdFeeCap = oldFC  * (100 + priceBump) / 100
-		a := big.NewInt(100 + int64(priceBump))
-		aFeeCap := new(big.Int).Mul(a, old.GasFeeCap())
-		aTip := a.Mul(a, old.GasTipCap())
-
-		// thresholdTip    = oldTip * (100 + priceBump) / 100
-		b := big.NewInt(100)
-		thresholdFeeCap := aFeeCap.Div(aFeeCap, b)
-		thresholdTip := aTip.Div(aTip, b)
-
-		// We have to ensure that both the new fee cap and tip are higher than the
-		// old ones as well as checking the percentage threshold to ensure that
-		// this is accurate for low (Wei-level) gas price replacements.
-		if tx.GasFeeCapIntCmp(thresholdFeeCap) < 0 || tx.GasTipCapIntCmp(thresholdTip) < 0 {
-			return false, nil
-		}
+	if old != nil && !policy.Replaces(old, tx) {
+		return false, nil
 	}
 	// Otherwise overwrite the old transaction with the current one
 	l.txs.Put(tx)
-	if cost := tx.Cost(); l.costcap.Cmp(cost) < 0 {
+	if cost := l.cost(tx); l.costcap.Cmp(cost) < 0 {
 		l.costcap = cost
 	}
 	if gas := tx.Gas(); l.gascap < gas {
@@ -348,7 +340,7 @@ func (l *txList) Filter(costLimit *big.Int, gasLimit uint64) (types.Transactions
 
 	// Filter out all the transactions above the account's funds
 	removed := l.txs.Filter(func(tx *types.Transaction) bool {
-		return tx.Gas() > gasLimit || tx.Cost().Cmp(costLimit) > 0
+		return tx.Gas() > gasLimit || l.cost(tx).Cmp(costLimit) > 0
 	})
 
 	if len(removed) == 0 {
@@ -428,9 +420,12 @@ func (l *txList) LastElement() *types.Transaction {
 // priceHeap is a heap.Interface implementation over transactions for retrieving
 // price-sorted transactions to discard when the pool fills up. If baseFee is set
 // then the heap is sorted based on the effective tip based on the given base fee.
-// If baseFee is nil then the sorting is based on gasFeeCap.
+// If baseFee is nil then the sorting is based on gasFeeCap. If l1Cost is set, the
+// L1 data cost of the transactions per unit of gas is deducted from the prices,
+// sorting them by the profit they make the sequencer.
 type priceHeap struct {
-	baseFee *big.Int // heap should always be re-sorted after baseFee is changed
+	baseFee *big.Int                          // heap should always be re-sorted after baseFee is changed
+	l1Cost  func(*types.Transaction) *big.Int // heap should always be re-sorted after l1Cost is changed
 	list    []*types.Transaction
 }
 
@@ -449,7 +444,12 @@ func (h *priceHeap) Less(i, j int) bool {
 }
 
 func (h *priceHeap) cmp(a, b *types.Transaction) int {
-	if h.baseFee != nil {
+	if h.l1Cost != nil {
+		// Compare the profits net of the L1 data cost on rollups
+		if c := h.profit(a).Cmp(h.profit(b)); c != 0 {
+			return c
+		}
+	} else if h.baseFee != nil {
 		// Compare effective tips if baseFee is specified
 		if c := a.EffectiveGasTipCmp(b, h.baseFee); c != 0 {
 			return c
@@ -463,6 +463,21 @@ func (h *priceHeap) cmp(a, b *types.Transaction) int {
 	return a.GasTipCapCmp(b)
 }
 
+// profit returns the price of a transaction net of its L1 data cost per unit of
+// gas: the effective tip if baseFee is set, the gasFeeCap otherwise.
+func (h *priceHeap) profit(tx *types.Transaction) *big.Int {
+	var price *big.Int
+	if h.baseFee != nil {
+		price = tx.EffectiveGasTipValue(h.baseFee)
+	} else {
+		price = tx.GasFeeCap()
+	}
+	if cost := h.l1Cost(tx); cost != nil && tx.Gas() > 0 {
+		price.Sub(price, new(big.Int).Div(cost, new(big.Int).SetUint64(tx.Gas())))
+	}
+	return price
+}
+
 func (h *priceHeap) Push(x interface{}) {
 	tx := x.(*types.Transaction)
 	h.list = append(h.list, tx)
@@ -636,6 +651,14 @@ func (l *txPricedList) Reheap() {
 	reheapTimer.Update(time.Since(start))
 }
 
+// SetL1Cost updates the function deriving the L1 data cost of the transactions,
+// deducted from their prices on rollups. It doesn't re-heap, the caller should
+// follow up with SetBaseFee or Reheap.
+func (l *txPricedList) SetL1Cost(l1Cost func(*types.Transaction) *big.Int) {
+	l.urgent.l1Cost = l1Cost
+	l.floating.l1Cost = l1Cost
+}
+
 // SetBaseFee updates the base fee and triggers a re-heap. Note that Removed is not
 // necessary to call right before SetBaseFee when processing a new block.
 func (l *txPricedList) SetBaseFee(baseFee *big.Int) {
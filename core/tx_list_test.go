@@ -38,7 +38,7 @@ func TestStrictTxListAdd(t *testing.T) {
 	// Insert the transactions in a random order
 	list := newTxList(true)
 	for _, v := range rand.Perm(len(txs)) {
-		list.Add(txs[v], DefaultTxPoolConfig.PriceBump)
+		list.Add(txs[v], DefaultTxPoolConfig.replacementPolicy())
 	}
 	// Verify internal state
 	if len(list.txs.items) != len(txs) {
@@ -65,8 +65,45 @@ func BenchmarkTxListAdd(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		list := newTxList(true)
 		for _, v := range rand.Perm(len(txs)) {
-			list.Add(txs[v], DefaultTxPoolConfig.PriceBump)
+			list.Add(txs[v], DefaultTxPoolConfig.replacementPolicy())
 			list.Filter(priceLimit, DefaultTxPoolConfig.PriceBump)
 		}
 	}
 }
+
+// Tests that on rollups the priced list evicts transactions by their profit net
+// of the L1 data cost, not their raw tip.
+func TestPricedListL1Cost(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+
+	var (
+		lean  = pricedTransaction(0, 100000, big.NewInt(2), key)
+		bulky = pricedDataTransaction(1, 100000, big.NewInt(3), key, 10000)
+	)
+	l1Cost := func(tx *types.Transaction) *big.Int {
+		return new(big.Int).SetUint64(tx.RollupDataGas() * 10)
+	}
+	for _, test := range []struct {
+		l1Cost func(*types.Transaction) *big.Int
+		evict  *types.Transaction
+	}{
+		{nil, lean},
+		{l1Cost, bulky},
+	} {
+		all := newTxLookup()
+		all.Add(lean, false)
+		all.Add(bulky, false)
+
+		priced := newTxPricedList(all)
+		priced.SetL1Cost(test.l1Cost)
+		priced.SetBaseFee(big.NewInt(0))
+
+		drop, ok := priced.Discard(1, false)
+		if !ok || len(drop) != 1 {
+			t.Fatalf("discard failed: ok %v, dropped %d", ok, len(drop))
+		}
+		if drop[0].Hash() != test.evict.Hash() {
+			t.Errorf("l1 cost %v: evicted tx with nonce %d, want %d", test.l1Cost != nil, drop[0].Nonce(), test.evict.Nonce())
+		}
+	}
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/prque"
 	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -52,6 +53,10 @@ const (
 	// more expensive to propagate; larger transactions also take more resources
 	// to validate whether they fit into the pool or not.
 	txMaxSize = 4 * txSlotSize // 128KB
+
+	// maxReinjectDepth is the depth of the deepest reorg whose transactions are
+	// reinjected into the pool.
+	maxReinjectDepth = 64
 )
 
 var (
@@ -86,6 +91,10 @@ var (
 	// than some meaningful limit a user might use. This is not a consensus error
 	// making the transaction invalid, rather a DOS protection.
 	ErrOversizedData = errors.New("oversized data")
+
+	// ErrBlobPoolOverflow is returned if the blob transaction limit of the pool
+	// is reached and no more blob transactions are accepted.
+	ErrBlobPoolOverflow = errors.New("txpool blob limit reached")
 )
 
 var (
@@ -126,6 +135,7 @@ var (
 	queuedGauge  = metrics.NewRegisteredGauge("txpool/queued", nil)
 	localGauge   = metrics.NewRegisteredGauge("txpool/local", nil)
 	slotsGauge   = metrics.NewRegisteredGauge("txpool/slots", nil)
+	blobsGauge   = metrics.NewRegisteredGauge("txpool/blobs", nil)
 
 	reheapTimer = metrics.NewRegisteredTimer("txpool/reheap", nil)
 )
@@ -157,15 +167,34 @@ type TxPoolConfig struct {
 	Journal   string           // Journal of local transactions to survive node restarts
 	Rejournal time.Duration    // Time interval to regenerate the local transaction journal
 
+	RemoteJournal    string `toml:",omitempty"` // Journal of pending remote transactions to survive node restarts (empty = disabled)
+	RemoteJournalCap uint64 `toml:",omitempty"` // Maximum number of remote transactions journaled, the best paying ones (0 = unlimited)
+
 	PriceLimit uint64 // Minimum gas price to enforce for acceptance into the pool
 	PriceBump  uint64 // Minimum price bump percentage to replace an already existing transaction (nonce)
 
+	PriceBumpMin     uint64 `toml:",omitempty"` // Minimum absolute price bump in wei to replace a transaction
+	PriceBumpSameFee bool   `toml:",omitempty"` // Whether a different payload may replace a transaction at identical fees
+
 	AccountSlots uint64 // Number of executable transaction slots guaranteed per account
 	GlobalSlots  uint64 // Maximum number of executable transaction slots for all accounts
 	AccountQueue uint64 // Maximum number of non-executable transaction slots permitted per account
 	GlobalQueue  uint64 // Maximum number of non-executable transaction slots for all accounts
 
 	Lifetime time.Duration // Maximum amount of time non-executable transaction are queued
+
+	QueuePolicy string `toml:",omitempty"` // File of sender classes with queue limits of their own (empty = none)
+
+	BlobSlots uint64 // Maximum number of blob transactions for all accounts
+
+	Quotas TxOriginQuotas // Maximum number of pooled transactions per origin
+
+	Denylist []common.Address `toml:",omitempty"` // Senders and recipients whose transactions are rejected
+
+	Limits TxLimits // Local size limits of transactions, tightening the ones of the chain config
+
+	AuditLog string // Append-only log of pool decisions for sequencing audits (empty = disabled)
+	Bans     string // File the temporary sender bans are saved to (empty = not persisted)
 }
 
 // DefaultTxPoolConfig contains the default configurations for the transaction
@@ -173,6 +202,7 @@ type TxPoolConfig struct {
 var DefaultTxPoolConfig = TxPoolConfig{
 	Journal:   "transactions.rlp",
 	Rejournal: time.Hour,
+	Bans:      "txpool-bans.json",
 
 	PriceLimit: 1,
 	PriceBump:  10,
@@ -183,6 +213,8 @@ var DefaultTxPoolConfig = TxPoolConfig{
 	GlobalQueue:  1024,
 
 	Lifetime: 3 * time.Hour,
+
+	BlobSlots: 256,
 }
 
 // sanitize checks the provided user configurations and changes anything that's
@@ -221,9 +253,22 @@ func (config *TxPoolConfig) sanitize() TxPoolConfig {
 		log.Warn("Sanitizing invalid txpool lifetime", "provided", conf.Lifetime, "updated", DefaultTxPoolConfig.Lifetime)
 		conf.Lifetime = DefaultTxPoolConfig.Lifetime
 	}
+	if conf.BlobSlots < 1 {
+		log.Warn("Sanitizing invalid txpool blob slots", "provided", conf.BlobSlots, "updated", DefaultTxPoolConfig.BlobSlots)
+		conf.BlobSlots = DefaultTxPoolConfig.BlobSlots
+	}
 	return conf
 }
 
+// replacementPolicy returns the price bump policy of the configuration.
+func (config *TxPoolConfig) replacementPolicy() ReplacementPolicy {
+	policy := PriceBumpPolicy{Percent: config.PriceBump, SameFee: config.PriceBumpSameFee}
+	if config.PriceBumpMin > 0 {
+		policy.Min = new(big.Int).SetUint64(config.PriceBumpMin)
+	}
+	return policy
+}
+
 // TxPool contains all currently known transactions. Transactions
 // enter the pool when they are received from the network or submitted
 // locally. They exit the pool when they are included in the blockchain.
@@ -237,6 +282,7 @@ type TxPool struct {
 	chain       blockChain
 	gasPrice    *big.Int
 	txFeed      event.Feed
+	dropFeed    event.Feed
 	scope       event.SubscriptionScope
 	signer      types.Signer
 	mu          sync.RWMutex
@@ -244,21 +290,50 @@ type TxPool struct {
 	istanbul bool // Fork indicator whether we are in the istanbul stage.
 	eip2718  bool // Fork indicator whether we are using EIP-2718 type transactions.
 	eip1559  bool // Fork indicator whether we are using EIP-1559 type transactions.
+	eip4844  bool // Fork indicator whether we are using EIP-4844 type transactions.
+	eip7702  bool // Fork indicator whether we are using EIP-7702 type transactions.
+
+	blobFee *big.Int // Blob gas price of the pending block, nil before EIP-4844
+
+	txLimits TxLimits // Size limits of transactions in effect for the pending block
+
+	replacement ReplacementPolicy // Rule for replacing transactions of the same nonce
+	customRBF   bool              // Whether the replacement policy was set explicitly
+
+	hooks    []TxValidationHook // Custom policies checked for incoming transactions
+	denylist AddressDenylist    // Configured senders and recipients, see SetLimits
+	bans     *txBans            // Senders temporarily banned by the operator
+
+	queueClasses []TxQueueClass                   // Classes of senders with queue limits of their own
+	classOf      map[common.Address]*TxQueueClass // Queue class of the senders in any class
 
 	currentState  *state.StateDB // Current state in the blockchain head
 	pendingNonces *txNoncer      // Pending state tracking virtual nonces
 	currentMaxGas uint64         // Current gas limit for transaction caps
 
-	l1CostFn func(message vm.RollupMessage) *big.Int // Current L1 fee cost function
-
-	locals  *accountSet // Set of local transaction to exempt from eviction rules
-	journal *txJournal  // Journal of local transaction to back up to disk
-
-	pending map[common.Address]*txList   // All currently processable transactions
-	queue   map[common.Address]*txList   // Queued but non-processable transactions
-	beats   map[common.Address]time.Time // Last heartbeat from each known account
-	all     *txLookup                    // All transactions to allow lookups
-	priced  *txPricedList                // All transactions sorted by price
+	l1CostFn    func(message vm.RollupMessage) *big.Int // Current L1 fee cost function
+	feeCurrency FeeCurrency                             // Alternative fee payment of the next block, if active
+
+	locals        *accountSet  // Set of local transaction to exempt from eviction rules
+	journal       *txJournal   // Journal of local transaction to back up to disk
+	remoteJournal *txJournal   // Journal of pending remote transactions to back up to disk
+	remoteJourMu  sync.Mutex   // Lock serialising the remote journal rotations, taken before mu
+	audit         *txPoolAudit // Log of pool decisions for sequencing audits
+
+	pending   map[common.Address]*txList   // All currently processable transactions
+	queue     map[common.Address]*txList   // Queued but non-processable transactions
+	senders   [2]senderIndex               // Sorted senders of the pending and queued transactions
+	beats     map[common.Address]time.Time // Last heartbeat from each known account
+	all       *txLookup                    // All transactions to allow lookups
+	priced    *txPricedList                // All transactions sorted by price
+	tags      *txTags                      // Caller supplied tags and drop reasons
+	drops     *txDrops                     // Reasons of recently dropped transactions
+	dropped   []DroppedTx                  // Drops not yet sent to subscribers
+	mined     map[common.Hash]struct{}     // Transactions included by the last head, not announced as drops
+	origins   map[common.Hash]minedOrigin  // Origins of recently included non-P2P transactions, for reinjection
+	latencies *txLatencies                 // Residency of transactions leaving the pool
+
+	conditionals map[common.Hash]*TxConditional // Inclusion conditions of conditional transactions
 
 	chainHeadCh     chan ChainHeadEvent
 	chainHeadSub    event.Subscription
@@ -293,6 +368,11 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		queue:           make(map[common.Address]*txList),
 		beats:           make(map[common.Address]time.Time),
 		all:             newTxLookup(),
+		tags:            newTxTags(),
+		drops:           newTxDrops(),
+		origins:         make(map[common.Hash]minedOrigin),
+		latencies:       newTxLatencies(),
+		conditionals:    make(map[common.Hash]*TxConditional),
 		chainHeadCh:     make(chan ChainHeadEvent, chainHeadChanSize),
 		reqResetCh:      make(chan *txpoolResetRequest),
 		reqPromoteCh:    make(chan *accountSet),
@@ -307,7 +387,23 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 		log.Info("Setting new local account", "address", addr)
 		pool.locals.add(addr)
 	}
+	if len(config.Denylist) > 0 {
+		log.Info("Denylisting transaction pool addresses", "count", len(config.Denylist))
+	}
+	pool.denylist = NewAddressDenylist(config.Denylist)
+	pool.hooks = append(pool.hooks, pool.denylist)
+	pool.bans = newTxBans(config.Bans)
+	pool.hooks = append(pool.hooks, pool.bans)
 	pool.priced = newTxPricedList(pool.all)
+	pool.replacement = config.replacementPolicy()
+	if config.AuditLog != "" {
+		audit, err := newTxPoolAudit(config.AuditLog)
+		if err != nil {
+			log.Warn("Failed to open transaction pool audit log", "err", err)
+		} else {
+			pool.audit = audit
+		}
+	}
 	pool.reset(nil, chain.CurrentBlock().Header())
 
 	// Start the reorg loop early so it can handle requests generated during journal loading.
@@ -316,7 +412,7 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 
 	// If local transactions and journaling is enabled, load from disk
 	if !config.NoLocals && config.Journal != "" {
-		pool.journal = newTxJournal(config.Journal)
+		pool.journal = newTxJournal("local", config.Journal)
 
 		if err := pool.journal.load(pool.AddLocals); err != nil {
 			log.Warn("Failed to load transaction journal", "err", err)
@@ -325,6 +421,26 @@ func NewTxPool(config TxPoolConfig, chainconfig *params.ChainConfig, chain block
 			log.Warn("Failed to rotate transaction journal", "err", err)
 		}
 	}
+	// Apply the queue classes of the policy file before any remote transactions
+	// are restored
+	if config.QueuePolicy != "" {
+		if classes, err := LoadTxQueueClasses(config.QueuePolicy); err != nil {
+			log.Warn("Failed to load transaction queue policy", "err", err)
+		} else if err := pool.SetQueueClasses(classes); err != nil {
+			log.Warn("Invalid transaction queue policy", "err", err)
+		} else {
+			log.Info("Loaded transaction queue policy", "classes", len(classes))
+		}
+	}
+	// If remote journaling is enabled, restore the remote transactions of the
+	// last shutdown, revalidating them against the current state
+	if config.RemoteJournal != "" {
+		pool.remoteJournal = newTxJournal("remote", config.RemoteJournal)
+
+		if err := pool.remoteJournal.load(pool.AddRemotes); err != nil {
+			log.Warn("Failed to load remote transaction journal", "err", err)
+		}
+	}
 
 	// Subscribe events from blockchain and start the main event loop.
 	pool.chainHeadSub = pool.chain.SubscribeChainHeadEvent(pool.chainHeadCh)
@@ -390,27 +506,68 @@ func (pool *TxPool) loop() {
 					continue
 				}
 				// Any non-locals old enough should be removed
-				if time.Since(pool.beats[addr]) > pool.config.Lifetime {
+				if time.Since(pool.beats[addr]) > pool.queueLifetime(addr) {
 					list := pool.queue[addr].Flatten()
 					for _, tx := range list {
+						pool.txDropped(tx, TxDropExpired)
 						pool.removeTx(tx.Hash(), true)
 					}
 					queuedEvictionMeter.Mark(int64(len(list)))
 				}
 			}
+			dropped := pool.takeDropped()
 			pool.mu.Unlock()
+			pool.sendDropped(dropped)
 
-		// Handle local transaction journal rotation
+		// Handle transaction journal rotation
 		case <-journal.C:
+			pool.mu.Lock()
 			if pool.journal != nil {
-				pool.mu.Lock()
 				if err := pool.journal.rotate(pool.local()); err != nil {
 					log.Warn("Failed to rotate local tx journal", "err", err)
 				}
-				pool.mu.Unlock()
 			}
+			pool.mu.Unlock()
+
+			if pool.remoteJournal != nil {
+				if err := pool.rotateRemoteJournal(); err != nil {
+					log.Warn("Failed to rotate remote tx journal", "err", err)
+				}
+			}
+		}
+	}
+}
+
+// FlushJournal regenerates the local and remote transaction journals from the
+// current contents of the pool.
+func (pool *TxPool) FlushJournal() error {
+	if pool.journal != nil {
+		pool.mu.Lock()
+		err := pool.journal.rotate(pool.local())
+		pool.mu.Unlock()
+
+		if err != nil {
+			return err
 		}
 	}
+	if pool.remoteJournal != nil {
+		return pool.rotateRemoteJournal()
+	}
+	return nil
+}
+
+// rotateRemoteJournal regenerates the remote transaction journal. The pending
+// remote transactions are gathered under the pool lock, but written outside of
+// it so that a large journal doesn't stall the pool.
+func (pool *TxPool) rotateRemoteJournal() error {
+	pool.remoteJourMu.Lock()
+	defer pool.remoteJourMu.Unlock()
+
+	pool.mu.RLock()
+	remotes := pool.remotes()
+	pool.mu.RUnlock()
+
+	return pool.remoteJournal.rotate(remotes)
 }
 
 // Stop terminates the transaction pool.
@@ -425,6 +582,18 @@ func (pool *TxPool) Stop() {
 	if pool.journal != nil {
 		pool.journal.close()
 	}
+	if pool.remoteJournal != nil {
+		// Remote transactions are only journaled on rotation, save the last ones
+		if err := pool.rotateRemoteJournal(); err != nil {
+			log.Warn("Failed to save remote tx journal", "err", err)
+		}
+		pool.remoteJournal.close()
+	}
+	if pool.audit != nil {
+		if err := pool.audit.close(); err != nil {
+			log.Warn("Failed to close transaction pool audit log", "err", err)
+		}
+	}
 	log.Info("Transaction pool stopped")
 }
 
@@ -434,6 +603,12 @@ func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscripti
 	return pool.scope.Track(pool.txFeed.Subscribe(ch))
 }
 
+// SubscribeDropTxsEvent registers a subscription of DropTxsEvent and starts
+// sending event to the given channel.
+func (pool *TxPool) SubscribeDropTxsEvent(ch chan<- DropTxsEvent) event.Subscription {
+	return pool.scope.Track(pool.dropFeed.Subscribe(ch))
+}
+
 // GasPrice returns the current gas price enforced by the transaction pool.
 func (pool *TxPool) GasPrice() *big.Int {
 	pool.mu.RLock()
@@ -446,8 +621,6 @@ func (pool *TxPool) GasPrice() *big.Int {
 // new transaction, and drops all transactions below this threshold.
 func (pool *TxPool) SetGasPrice(price *big.Int) {
 	pool.mu.Lock()
-	defer pool.mu.Unlock()
-
 	old := pool.gasPrice
 	pool.gasPrice = price
 	// if the min miner fee increased, remove transactions below the new threshold
@@ -455,14 +628,80 @@ func (pool *TxPool) SetGasPrice(price *big.Int) {
 		// pool.priced is sorted by GasFeeCap, so we have to iterate through pool.all instead
 		drop := pool.all.RemotesBelowTip(price)
 		for _, tx := range drop {
+			pool.txDropped(tx, TxDropUnderpriced)
 			pool.removeTx(tx.Hash(), false)
 		}
 		pool.priced.Removed(len(drop))
 	}
+	dropped := pool.takeDropped()
+	pool.mu.Unlock()
+	pool.sendDropped(dropped)
 
 	log.Info("Transaction pool price threshold updated", "price", price)
 }
 
+// SetLimits changes the price limit, price bump, slot limits, lifetime, origin
+// quotas and denylist of the pool to those of config, ignoring its other fields.
+// Transactions exceeding the new limits are dropped right away, while pooled
+// transactions of newly denylisted addresses are kept. The minimum gas price is
+// only reset if the price limit changed, leaving a price set through SetGasPrice
+// in place.
+func (pool *TxPool) SetLimits(config TxPoolConfig) {
+	config = (&config).sanitize()
+
+	pool.mu.Lock()
+	priceChanged := pool.config.PriceLimit != config.PriceLimit
+	pool.config.PriceLimit = config.PriceLimit
+	pool.config.PriceBump = config.PriceBump
+	pool.config.PriceBumpMin, pool.config.PriceBumpSameFee = config.PriceBumpMin, config.PriceBumpSameFee
+	if !pool.customRBF {
+		pool.replacement = pool.config.replacementPolicy()
+	}
+	pool.config.AccountSlots, pool.config.GlobalSlots = config.AccountSlots, config.GlobalSlots
+	pool.config.AccountQueue, pool.config.GlobalQueue = config.AccountQueue, config.GlobalQueue
+	pool.config.Lifetime = config.Lifetime
+	pool.config.Quotas = config.Quotas
+	pool.config.Denylist = append([]common.Address(nil), config.Denylist...)
+	for addr := range pool.denylist {
+		delete(pool.denylist, addr)
+	}
+	for _, addr := range config.Denylist {
+		pool.denylist[addr] = struct{}{}
+	}
+	pool.mu.Unlock()
+
+	if priceChanged {
+		pool.SetGasPrice(new(big.Int).SetUint64(config.PriceLimit))
+	}
+	<-pool.requestPromoteExecutables(newAccountSet(pool.signer))
+}
+
+// Config returns the configuration of the pool in effect, including the limits
+// changed with SetLimits.
+func (pool *TxPool) Config() TxPoolConfig {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	config := pool.config
+	config.Locals = append([]common.Address(nil), config.Locals...)
+	config.Denylist = append([]common.Address(nil), config.Denylist...)
+	return config
+}
+
+// SetReplacementPolicy changes the rule for replacing pooled transactions of
+// the same sender and nonce, overriding the price bump of the configuration.
+// A nil policy reverts to the configured price bump.
+func (pool *TxPool) SetReplacementPolicy(policy ReplacementPolicy) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.customRBF = policy != nil
+	if policy == nil {
+		policy = pool.config.replacementPolicy()
+	}
+	pool.replacement = policy
+}
+
 // Nonce returns the next nonce of an account, with all transactions executable
 // by the pool already applied on top.
 func (pool *TxPool) Nonce(addr common.Address) uint64 {
@@ -529,6 +768,25 @@ func (pool *TxPool) ContentFrom(addr common.Address) (types.Transactions, types.
 	return pending, queued
 }
 
+// ContentRange iterates over the senders of the pending or queued transactions
+// in ascending address order, starting at the given address, and calls fn with
+// the transactions of each, sorted by nonce, until it returns false. Unlike
+// Content it doesn't copy the whole pool, so it is suitable for paging.
+func (pool *TxPool) ContentRange(queued bool, start common.Address, fn func(addr common.Address, txs types.Transactions) bool) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	lists, index := pool.pending, pool.senders[0]
+	if queued {
+		lists, index = pool.queue, pool.senders[1]
+	}
+	for _, addr := range index.from(start) {
+		if !fn(addr, lists[addr].Flatten()) {
+			return
+		}
+	}
+}
+
 // Pending retrieves all currently processable transactions, grouped by origin
 // account and sorted by nonce. The returned transaction set is a copy and can be
 // freely modified by calling code.
@@ -584,9 +842,38 @@ func (pool *TxPool) local() map[common.Address]types.Transactions {
 	return txs
 }
 
+// remotes retrieves the pending remote transactions to journal, grouped by
+// origin account and sorted by nonce. If there are more than the journal cap,
+// the best paying ones are kept.
+func (pool *TxPool) remotes() map[common.Address]types.Transactions {
+	var (
+		pending = make(map[common.Address]types.Transactions)
+		count   uint64
+	)
+	for addr, list := range pool.pending {
+		if !pool.locals.contains(addr) {
+			pending[addr] = list.Flatten()
+			count += uint64(len(pending[addr]))
+		}
+	}
+	limit := pool.config.RemoteJournalCap
+	if limit == 0 || count <= limit {
+		return pending
+	}
+	txs := make(map[common.Address]types.Transactions)
+	set := types.NewTransactionsByProfitAndNonce(pool.signer, pending, pool.priced.urgent.baseFee, pool.priced.urgent.l1Cost)
+	for tx := set.Peek(); tx != nil && limit > 0; tx = set.Peek() {
+		from, _ := types.Sender(pool.signer, tx)
+		txs[from] = append(txs[from], tx)
+		set.Shift()
+		limit--
+	}
+	return txs
+}
+
 // validateTx checks whether a transaction is valid according to the consensus
 // rules and adheres to some heuristic limits of the local node (price and size).
-func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
+func (pool *TxPool) validateTx(tx *types.Transaction, local bool, origin TxOrigin) error {
 	// No unauthenticated deposits allowed in the transaction pool.
 	// This is for spam protection, not consensus,
 	// as the external engine-API user authenticates deposits.
@@ -601,10 +888,40 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if !pool.eip1559 && tx.Type() == types.DynamicFeeTxType {
 		return ErrTxTypeNotSupported
 	}
+	// Reject blob transactions until EIP-4844 activates, and check their blobs
+	// and blob gas fee cap afterwards.
+	if tx.Type() == types.BlobTxType {
+		if !pool.eip4844 {
+			return ErrTxTypeNotSupported
+		}
+		if err := validateBlobHashes(pool.chainconfig, tx.BlobHashes()); err != nil {
+			return err
+		}
+		if tx.BlobGasFeeCap().BitLen() > 256 {
+			return ErrFeeCapVeryHigh
+		}
+		if tx.BlobGasFeeCap().Cmp(pool.blobFee) < 0 {
+			return ErrBlobFeeCapTooLow
+		}
+	}
+	// Reject set code transactions until EIP-7702 activates, and ones without
+	// authorizations afterwards.
+	if tx.Type() == types.SetCodeTxType {
+		if !pool.eip7702 {
+			return ErrTxTypeNotSupported
+		}
+		if len(tx.SetCodeAuthorizations()) == 0 {
+			return ErrEmptyAuthList
+		}
+	}
 	// Reject transactions over defined size to prevent DOS attacks
 	if uint64(tx.Size()) > txMaxSize {
 		return ErrOversizedData
 	}
+	// Reject transactions over the size limits of the network or the local node
+	if err := pool.txLimits.Check(tx); err != nil {
+		return err
+	}
 	// Transactions can't be negative. This may never happen using RLP decoded
 	// transactions but may occur if you create a transaction using the RPC.
 	if tx.Value().Sign() < 0 {
@@ -644,18 +961,27 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 	if l1Cost := pool.l1CostFn(tx); l1Cost != nil { // add rollup cost
 		cost = cost.Add(cost, l1Cost)
 	}
-	if pool.currentState.GetBalance(from).Cmp(cost) < 0 {
+	if pool.handlesFees(tx) {
+		// Fees paid in the fee currency only leave the value to the ether balance
+		if pool.currentState.GetBalance(from).Cmp(tx.Value()) < 0 {
+			return ErrInsufficientFunds
+		}
+		if pool.feeCurrency.Balance(pool.currentState, from).Cmp(cost.Sub(cost, tx.Value())) < 0 {
+			return ErrInsufficientFunds
+		}
+	} else if pool.currentState.GetBalance(from).Cmp(cost) < 0 {
 		return ErrInsufficientFunds
 	}
 	// Ensure the transaction has more gas than the basic tx fee.
-	intrGas, err := IntrinsicGas(tx.Data(), tx.AccessList(), tx.To() == nil, true, pool.istanbul)
+	intrGas, err := IntrinsicGas(tx.Data(), tx.AccessList(), tx.SetCodeAuthorizations(), tx.To() == nil, true, pool.istanbul)
 	if err != nil {
 		return err
 	}
 	if tx.Gas() < intrGas {
 		return ErrIntrinsicGas
 	}
-	return nil
+	// Apply the custom policies last, on otherwise valid transactions only
+	return pool.runValidationHooks(tx, from, local, origin)
 }
 
 // add validates a transaction and inserts it into the non-executable queue for later
@@ -665,7 +991,7 @@ func (pool *TxPool) validateTx(tx *types.Transaction, local bool) error {
 // If a newly added transaction is marked as local, its sending account will be
 // be added to the allowlist, preventing any associated transaction from being dropped
 // out of the pool due to pricing constraints.
-func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err error) {
+func (pool *TxPool) add(tx *types.Transaction, local bool, origin TxOrigin) (replaced bool, err error) {
 	// If the transaction is already known, discard it
 	hash := tx.Hash()
 	if pool.all.Get(hash) != nil {
@@ -678,13 +1004,25 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 	isLocal := local || pool.locals.containsTx(tx)
 
 	// If the transaction fails basic validation, discard it
-	if err := pool.validateTx(tx, isLocal); err != nil {
+	if err := pool.validateTx(tx, isLocal, origin); err != nil {
 		log.Trace("Discarding invalid transaction", "hash", hash, "err", err)
 		invalidTxMeter.Mark(1)
 		return false, err
 	}
+	// Reject the transaction if its origin used up its quota, unless replacing
+	if quota := pool.config.Quotas.quota(origin); quota > 0 && uint64(pool.all.OriginCount(origin)) >= quota && !pool.overlaps(tx) {
+		log.Trace("Discarding transaction over the origin quota", "hash", hash, "origin", origin)
+		overflowedTxMeter.Mark(1)
+		return false, ErrOriginQuota
+	}
+	// Blob transactions are limited separately, reject them if the limit is reached
+	if tx.Type() == types.BlobTxType && uint64(pool.all.Blobs()) >= pool.config.BlobSlots {
+		log.Trace("Discarding blob transaction over the limit", "hash", hash)
+		overflowedTxMeter.Mark(1)
+		return false, ErrBlobPoolOverflow
+	}
 	// If the transaction pool is full, discard underpriced transactions
-	if uint64(pool.all.Slots()+numSlots(tx)) > pool.config.GlobalSlots+pool.config.GlobalQueue {
+	if uint64(pool.all.Slots()+numSlots(tx)) > pool.config.GlobalSlots+pool.queueCapacity() {
 		// If the new transaction is underpriced, don't accept it
 		if !isLocal && pool.priced.Underpriced(tx) {
 			log.Trace("Discarding underpriced transaction", "hash", hash, "gasTipCap", tx.GasTipCap(), "gasFeeCap", tx.GasFeeCap())
@@ -703,7 +1041,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		// New transaction is better than our worse ones, make room for it.
 		// If it's a local transaction, forcibly discard all available transactions.
 		// Otherwise if we can't make enough room for new one, abort the operation.
-		drop, success := pool.priced.Discard(pool.all.Slots()-int(pool.config.GlobalSlots+pool.config.GlobalQueue)+numSlots(tx), isLocal)
+		drop, success := pool.priced.Discard(pool.all.Slots()-int(pool.config.GlobalSlots+pool.queueCapacity())+numSlots(tx), isLocal)
 
 		// Special case, we still can't make the room for the new remote one.
 		if !isLocal && !success {
@@ -717,6 +1055,7 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 		for _, tx := range drop {
 			log.Trace("Discarding freshly underpriced transaction", "hash", tx.Hash(), "gasTipCap", tx.GasTipCap(), "gasFeeCap", tx.GasFeeCap())
 			underpricedTxMeter.Mark(1)
+			pool.txDropped(tx, TxDropUnderpriced)
 			pool.removeTx(tx.Hash(), false)
 		}
 	}
@@ -724,13 +1063,14 @@ func (pool *TxPool) add(tx *types.Transaction, local bool) (replaced bool, err e
 	from, _ := types.Sender(pool.signer, tx) // already validated
 	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
 		// Nonce already pending, check if required price bump is met
-		inserted, old := list.Add(tx, pool.config.PriceBump)
+		inserted, old := list.Add(tx, pool.replacement)
 		if !inserted {
 			pendingDiscardMeter.Mark(1)
 			return false, ErrReplaceUnderpriced
 		}
 		// New transaction is better, replace old one
 		if old != nil {
+			pool.txDropped(old, TxDropReplaced)
 			pool.all.Remove(old.Hash())
 			pool.priced.Removed(1)
 			pendingReplaceMeter.Mark(1)
@@ -773,8 +1113,10 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 	from, _ := types.Sender(pool.signer, tx) // already validated
 	if pool.queue[from] == nil {
 		pool.queue[from] = newTxList(false)
+		pool.senders[1].add(from)
+		pool.queue[from].costFn = pool.etherCost
 	}
-	inserted, old := pool.queue[from].Add(tx, pool.config.PriceBump)
+	inserted, old := pool.queue[from].Add(tx, pool.replacement)
 	if !inserted {
 		// An older transaction was better, discard this
 		queuedDiscardMeter.Mark(1)
@@ -782,6 +1124,7 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 	}
 	// Discard any previous transaction and mark this
 	if old != nil {
+		pool.txDropped(old, TxDropReplaced)
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		queuedReplaceMeter.Mark(1)
@@ -805,6 +1148,69 @@ func (pool *TxPool) enqueueTx(hash common.Hash, tx *types.Transaction, local boo
 	return old != nil, nil
 }
 
+// overlaps reports whether a transaction has the nonce of a pooled one of the
+// same sender.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) overlaps(tx *types.Transaction) bool {
+	from, _ := types.Sender(pool.signer, tx) // already validated
+	if list := pool.pending[from]; list != nil && list.Overlaps(tx) {
+		return true
+	}
+	list := pool.queue[from]
+	return list != nil && list.Overlaps(tx)
+}
+
+// txDropped records the reason a transaction is removed from the pool and
+// queues the drop for notifying subscribers.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) txDropped(tx *types.Transaction, reason string) {
+	// Transactions whose nonce was used up on chain were included, barring
+	// the rare replacements by a transaction this pool never saw
+	if reason == TxDropNonceTooLow {
+		pool.latencies.inclusion.update(tx.Time())
+	} else {
+		pool.latencies.drop.update(tx.Time())
+	}
+	hash := tx.Hash()
+	pool.tags.drop(hash, reason)
+	pool.audit.record(&TxPoolAuditEntry{Kind: TxAuditDrop, Hash: hash, Reason: reason})
+
+	// Included transactions left the pool, but they weren't dropped
+	if _, ok := pool.mined[hash]; ok && reason == TxDropNonceTooLow {
+		return
+	}
+	pool.drops.add(hash, reason)
+	pool.dropped = append(pool.dropped, DroppedTx{Tx: tx, Reason: reason})
+}
+
+// takeDropped returns the drops queued since the last call.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) takeDropped() []DroppedTx {
+	dropped := pool.dropped
+	pool.dropped = nil
+	return dropped
+}
+
+// sendDropped notifies subscribers of dropped transactions. It must be called
+// without holding the pool lock.
+func (pool *TxPool) sendDropped(dropped []DroppedTx) {
+	if len(dropped) > 0 {
+		pool.dropFeed.Send(DropTxsEvent{dropped})
+	}
+}
+
+// unaffordableReason returns why a transaction the account can't pay for is
+// dropped, telling apart the ones only failing on the L1 data fee.
+func (pool *TxPool) unaffordableReason(addr common.Address, tx *types.Transaction) string {
+	if tx.Gas() <= pool.currentMaxGas && !pool.handlesFees(tx) && tx.Cost().Cmp(pool.currentState.GetBalance(addr)) <= 0 {
+		return TxDropL1FeeInsufficient
+	}
+	return TxDropUnaffordable
+}
+
 // journalTx adds the specified transaction to the local disk journal if it is
 // deemed to have been sent from a local account.
 func (pool *TxPool) journalTx(from common.Address, tx *types.Transaction) {
@@ -825,12 +1231,15 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	// Try to insert the transaction into the pending queue
 	if pool.pending[addr] == nil {
 		pool.pending[addr] = newTxList(true)
+		pool.senders[0].add(addr)
+		pool.pending[addr].costFn = pool.etherCost
 	}
 	list := pool.pending[addr]
 
-	inserted, old := list.Add(tx, pool.config.PriceBump)
+	inserted, old := list.Add(tx, pool.replacement)
 	if !inserted {
 		// An older transaction was better, discard this
+		pool.txDropped(tx, TxDropReplacedUnderpriced)
 		pool.all.Remove(hash)
 		pool.priced.Removed(1)
 		pendingDiscardMeter.Mark(1)
@@ -838,6 +1247,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	}
 	// Otherwise discard any previous transaction and mark this
 	if old != nil {
+		pool.txDropped(old, TxDropReplaced)
 		pool.all.Remove(old.Hash())
 		pool.priced.Removed(1)
 		pendingReplaceMeter.Mark(1)
@@ -847,6 +1257,8 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 	}
 	// Set the potentially new pending nonce and notify any subsystems of the new tx
 	pool.pendingNonces.set(addr, tx.Nonce()+1)
+	pool.audit.record(&TxPoolAuditEntry{Kind: TxAuditPromote, Hash: hash})
+	pool.latencies.promotion.update(tx.Time())
 
 	// Successful promotion, bump the heartbeat
 	pool.beats[addr] = time.Now()
@@ -859,7 +1271,7 @@ func (pool *TxPool) promoteTx(addr common.Address, hash common.Hash, tx *types.T
 // This method is used to add transactions from the RPC API and performs synchronous pool
 // reorganization and event propagation.
 func (pool *TxPool) AddLocals(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, !pool.config.NoLocals, true)
+	return pool.addTxs(txs, !pool.config.NoLocals, true, TxOriginLocal)
 }
 
 // AddLocal enqueues a single local transaction into the pool if it is valid. This is
@@ -869,18 +1281,26 @@ func (pool *TxPool) AddLocal(tx *types.Transaction) error {
 	return errs[0]
 }
 
+// AddRPC enqueues a single transaction submitted through the RPC API into the
+// pool if it is valid. It is treated as local like with AddLocal, but counts
+// towards the quota of RPC transactions.
+func (pool *TxPool) AddRPC(tx *types.Transaction) error {
+	errs := pool.addTxs([]*types.Transaction{tx}, !pool.config.NoLocals, true, TxOriginRPC)
+	return errs[0]
+}
+
 // AddRemotes enqueues a batch of transactions into the pool if they are valid. If the
 // senders are not among the locally tracked ones, full pricing constraints will apply.
 //
 // This method is used to add transactions from the p2p network and does not wait for pool
 // reorganization and internal event propagation.
 func (pool *TxPool) AddRemotes(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, false, false)
+	return pool.addTxs(txs, false, false, TxOriginP2P)
 }
 
 // This is like AddRemotes, but waits for pool reorganization. Tests use this method.
 func (pool *TxPool) AddRemotesSync(txs []*types.Transaction) []error {
-	return pool.addTxs(txs, false, true)
+	return pool.addTxs(txs, false, true, TxOriginP2P)
 }
 
 // This is like AddRemotes with a single transaction, but waits for pool reorganization. Tests use this method.
@@ -899,7 +1319,7 @@ func (pool *TxPool) AddRemote(tx *types.Transaction) error {
 }
 
 // addTxs attempts to queue a batch of transactions if they are valid.
-func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
+func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool, origin TxOrigin) []error {
 	// Filter out known ones without obtaining the pool lock or recovering signatures
 	var (
 		errs = make([]error, len(txs))
@@ -930,7 +1350,7 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 
 	// Process all the new transaction and merge any errors into the original slice
 	pool.mu.Lock()
-	newErrs, dirtyAddrs := pool.addTxsLocked(news, local)
+	newErrs, dirtyAddrs := pool.addTxsLocked(news, local, origin)
 	pool.mu.Unlock()
 
 	var nilSlot = 0
@@ -951,14 +1371,18 @@ func (pool *TxPool) addTxs(txs []*types.Transaction, local, sync bool) []error {
 
 // addTxsLocked attempts to queue a batch of transactions if they are valid.
 // The transaction pool lock must be held.
-func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool) ([]error, *accountSet) {
+func (pool *TxPool) addTxsLocked(txs []*types.Transaction, local bool, origin TxOrigin) ([]error, *accountSet) {
 	dirty := newAccountSet(pool.signer)
 	errs := make([]error, len(txs))
 	for i, tx := range txs {
-		replaced, err := pool.add(tx, local)
+		replaced, err := pool.add(tx, local, origin)
+		pool.auditAdd(tx, local, err)
 		errs[i] = err
-		if err == nil && !replaced {
-			dirty.addTx(tx)
+		if err == nil {
+			pool.all.SetOrigin(tx.Hash(), origin)
+			if !replaced {
+				dirty.addTx(tx)
+			}
 		}
 	}
 	validTxMeter.Mark(int64(len(dirty.accounts)))
@@ -1023,6 +1447,7 @@ func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
 			// If no more pending transactions are left, remove the list
 			if pending.Empty() {
 				delete(pool.pending, addr)
+				pool.senders[0].remove(addr)
 			}
 			// Postpone any invalidated transactions
 			for _, tx := range invalids {
@@ -1044,6 +1469,7 @@ func (pool *TxPool) removeTx(hash common.Hash, outofbound bool) {
 		}
 		if future.Empty() {
 			delete(pool.queue, addr)
+			pool.senders[1].remove(addr)
 			delete(pool.beats, addr)
 		}
 	}
@@ -1191,9 +1617,16 @@ func (pool *TxPool) runReorg(done chan struct{}, reset *txpoolResetRequest, dirt
 	// because of another transaction (e.g. higher gas price).
 	if reset != nil {
 		pool.demoteUnexecutables()
+		if pool.chainconfig.Optimism != nil {
+			// Rank remote transactions by their profit net of the L1 data cost
+			// at the new head's rollup fee parameters
+			pool.priced.SetL1Cost(pool.l1Cost)
+		}
 		if reset.newHead != nil && pool.chainconfig.IsLondon(new(big.Int).Add(reset.newHead.Number, big.NewInt(1))) {
 			pendingBaseFee := misc.CalcBaseFee(pool.chainconfig, reset.newHead)
 			pool.priced.SetBaseFee(pendingBaseFee)
+		} else if pool.chainconfig.Optimism != nil {
+			pool.priced.Reheap()
 		}
 		// Update all accounts to the latest known pending nonce
 		nonces := make(map[common.Address]uint64, len(pool.pending))
@@ -1209,8 +1642,12 @@ func (pool *TxPool) runReorg(done chan struct{}, reset *txpoolResetRequest, dirt
 
 	dropBetweenReorgHistogram.Update(int64(pool.changesSinceReorg))
 	pool.changesSinceReorg = 0 // Reset change counter
+	dropped := pool.takeDropped()
 	pool.mu.Unlock()
 
+	// Notify subsystems for dropped transactions
+	pool.sendDropped(dropped)
+
 	// Notify subsystems for newly added transactions
 	for _, tx := range promoted {
 		addr, _ := types.Sender(pool.signer, tx)
@@ -1232,18 +1669,23 @@ func (pool *TxPool) runReorg(done chan struct{}, reset *txpoolResetRequest, dirt
 // of the transaction pool is valid with regard to the chain state.
 func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	// If we're reorging an old state, reinject all dropped transactions
-	var reinject types.Transactions
+	var reinject, included types.Transactions
 
-	if oldHead != nil && oldHead.Hash() != newHead.ParentHash {
+	if oldHead != nil && oldHead.Hash() == newHead.ParentHash {
+		// Plain chain extension, only the transactions included are of interest
+		if block := pool.chain.GetBlock(newHead.Hash(), newHead.Number.Uint64()); block != nil {
+			included = block.Transactions()
+		}
+	} else if oldHead != nil {
 		// If the reorg is too deep, avoid doing it (will happen during fast sync)
 		oldNum := oldHead.Number.Uint64()
 		newNum := newHead.Number.Uint64()
 
-		if depth := uint64(math.Abs(float64(oldNum) - float64(newNum))); depth > 64 {
+		if depth := uint64(math.Abs(float64(oldNum) - float64(newNum))); depth > maxReinjectDepth {
 			log.Debug("Skipping deep transaction reorg", "depth", depth)
 		} else {
 			// Reorg seems shallow enough to pull in all transactions into memory
-			var discarded, included types.Transactions
+			var discarded types.Transactions
 			var (
 				rem = pool.chain.GetBlock(oldHead.Hash(), oldHead.Number.Uint64())
 				add = pool.chain.GetBlock(newHead.Hash(), newHead.Number.Uint64())
@@ -1308,30 +1750,107 @@ func (pool *TxPool) reset(oldHead, newHead *types.Header) {
 	if newHead == nil {
 		newHead = pool.chain.CurrentBlock().Header() // Special case during testing
 	}
+	pool.auditHead(newHead)
 	statedb, err := pool.chain.StateAt(newHead.Root)
 	if err != nil {
-		log.Error("Failed to reset txpool state", "err", err)
-		return
+		if pool.currentState != nil {
+			log.Error("Failed to reset txpool state", "err", err)
+			return
+		}
+		// The head state is missing on startup, e.g. a genesis committed without
+		// its state awaiting sync. Start out empty until the next head arrives.
+		log.Warn("Head state missing, starting txpool with empty state", "number", newHead.Number, "root", newHead.Root)
+		statedb, _ = state.New(types.EmptyRootHash, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
 	}
 	pool.currentState = statedb
 	pool.pendingNonces = newTxNoncer(statedb)
 	pool.currentMaxGas = newHead.GasLimit
 
+	pool.mined = make(map[common.Hash]struct{}, len(included))
+	for _, tx := range included {
+		pool.mined[tx.Hash()] = struct{}{}
+	}
+	// Remember the origins of the included transactions still pooled, so that
+	// the ones reorged out again are reinjected under their own origin
+	number := newHead.Number.Uint64()
+	for hash, mined := range pool.origins {
+		if mined.number+maxReinjectDepth < number {
+			delete(pool.origins, hash)
+		}
+	}
+	for _, tx := range included {
+		if origin, ok := pool.all.Origin(tx.Hash()); ok && origin != TxOriginP2P {
+			pool.origins[tx.Hash()] = minedOrigin{origin: origin, number: number}
+		}
+	}
+
 	costFn := NewL1CostFunc(pool.chainconfig, statedb)
 	pool.l1CostFn = func(message vm.RollupMessage) *big.Int {
 		return costFn(newHead.Number.Uint64(), message)
 	}
 
-	// Inject any transactions discarded due to reorgs
+	// Inject any transactions discarded due to reorgs, under the origin they
+	// originally entered the pool from
 	log.Debug("Reinjecting stale transactions", "count", len(reinject))
 	senderCacher.recover(pool.signer, reinject)
-	pool.addTxsLocked(reinject, false)
+
+	var byOrigin [txOriginCount]types.Transactions
+	for _, tx := range reinject {
+		origin := TxOriginP2P
+		if mined, ok := pool.origins[tx.Hash()]; ok {
+			origin = mined.origin
+			delete(pool.origins, tx.Hash())
+		}
+		byOrigin[origin] = append(byOrigin[origin], tx)
+	}
+	for origin, txs := range byOrigin {
+		if len(txs) > 0 {
+			pool.addTxsLocked(txs, false, TxOrigin(origin))
+		}
+	}
 
 	// Update all fork indicator by next pending block number.
 	next := new(big.Int).Add(newHead.Number, big.NewInt(1))
 	pool.istanbul = pool.chainconfig.IsIstanbul(next)
 	pool.eip2718 = pool.chainconfig.IsBerlin(next)
 	pool.eip1559 = pool.chainconfig.IsLondon(next)
+	pool.eip4844 = pool.chainconfig.IsBlobTx(next)
+	pool.blobFee = nil
+	if pool.eip4844 {
+		pool.blobFee = misc.CalcBlobFee(pool.chainconfig, misc.CalcExcessBlobGas(pool.chainconfig, newHead))
+	}
+	pool.eip7702 = pool.chainconfig.IsSetCodeTx(next)
+	pool.txLimits = ActiveTxLimits(pool.chainconfig, next, pool.config.Limits)
+
+	pool.dropUnmetConditionals(newHead)
+
+	pool.feeCurrency = nil
+	if pool.chainconfig.IsFeeCurrency(next) {
+		if pool.feeCurrency, err = NewFeeCurrency(pool.chainconfig); err != nil {
+			log.Error("Failed to set up fee currency", "err", err)
+		}
+	}
+}
+
+// handlesFees reports whether the fees of the transaction are paid in the fee
+// currency of the network instead of ether.
+func (pool *TxPool) handlesFees(tx *types.Transaction) bool {
+	return pool.feeCurrency != nil && pool.feeCurrency.Handles(tx)
+}
+
+// l1Cost returns the L1 data cost the sequencer pays to post the transaction at
+// the current head, nil if there is none.
+func (pool *TxPool) l1Cost(tx *types.Transaction) *big.Int {
+	return pool.l1CostFn(tx)
+}
+
+// etherCost returns the ether a transaction costs its sender, which is only the
+// transferred value if the fees are paid in the fee currency.
+func (pool *TxPool) etherCost(tx *types.Transaction) *big.Int {
+	if pool.handlesFees(tx) {
+		return new(big.Int).Set(tx.Value())
+	}
+	return tx.Cost()
 }
 
 // promoteExecutables moves transactions that have become processable from the
@@ -1351,20 +1870,24 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 		forwards := list.Forward(pool.currentState.GetNonce(addr))
 		for _, tx := range forwards {
 			hash := tx.Hash()
+			pool.txDropped(tx, TxDropNonceTooLow)
 			pool.all.Remove(hash)
 		}
 		log.Trace("Removed old queued transactions", "count", len(forwards))
 		balance := pool.currentState.GetBalance(addr)
 		if !list.Empty() {
 			// Reduce the cost-cap by L1 rollup cost of the first tx if necessary. Other txs will get filtered out afterwards.
-			if l1Cost := pool.l1CostFn(list.txs.FirstElement()); l1Cost != nil {
-				balance = balance.Sub(balance, l1Cost) // negative big int is fine
+			if first := list.txs.FirstElement(); pool.handlesFees(first) {
+				// Fees paid in the fee currency were checked at admission
+			} else if l1Cost := pool.l1CostFn(first); l1Cost != nil {
+				balance = new(big.Int).Sub(balance, l1Cost) // negative big int is fine
 			}
 		}
 		// Drop all transactions that are too costly (low balance or out of gas)
 		drops, _ := list.Filter(balance, pool.currentMaxGas)
 		for _, tx := range drops {
 			hash := tx.Hash()
+			pool.txDropped(tx, pool.unaffordableReason(addr, tx))
 			pool.all.Remove(hash)
 		}
 		log.Trace("Removed unpayable queued transactions", "count", len(drops))
@@ -1384,9 +1907,10 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 		// Drop all transactions over the allowed limit
 		var caps types.Transactions
 		if !pool.locals.contains(addr) {
-			caps = list.Cap(int(pool.config.AccountQueue))
+			caps = list.Cap(int(pool.accountQueue(addr)))
 			for _, tx := range caps {
 				hash := tx.Hash()
+				pool.txDropped(tx, TxDropAccountLimit)
 				pool.all.Remove(hash)
 				log.Trace("Removed cap-exceeding queued transaction", "hash", hash)
 			}
@@ -1401,6 +1925,7 @@ func (pool *TxPool) promoteExecutables(accounts []common.Address) []*types.Trans
 		// Delete the entire queue entry if it became empty.
 		if list.Empty() {
 			delete(pool.queue, addr)
+			pool.senders[1].remove(addr)
 			delete(pool.beats, addr)
 		}
 	}
@@ -1449,6 +1974,7 @@ func (pool *TxPool) truncatePending() {
 					for _, tx := range caps {
 						// Drop the transaction from the global pools too
 						hash := tx.Hash()
+						pool.txDropped(tx, TxDropPoolOverflow)
 						pool.all.Remove(hash)
 
 						// Update the account nonce to the dropped transaction
@@ -1476,6 +2002,7 @@ func (pool *TxPool) truncatePending() {
 				for _, tx := range caps {
 					// Drop the transaction from the global pools too
 					hash := tx.Hash()
+					pool.txDropped(tx, TxDropPoolOverflow)
 					pool.all.Remove(hash)
 
 					// Update the account nonce to the dropped transaction
@@ -1494,27 +2021,41 @@ func (pool *TxPool) truncatePending() {
 	pendingRateLimitMeter.Mark(int64(pendingBeforeCap - pending))
 }
 
-// truncateQueue drops the oldest transactions in the queue if the pool is above the global queue limit.
+// truncateQueue drops the oldest transactions in the queue if the pool is above the global queue limit,
+// or a queue class above its own limit.
 func (pool *TxPool) truncateQueue() {
-	queued := uint64(0)
-	for _, list := range pool.queue {
-		queued += uint64(list.Len())
+	// Group the queued accounts by queue class, the pool-wide queue being nil
+	var (
+		queued    = make(map[*TxQueueClass]uint64)
+		addresses = make(map[*TxQueueClass]addressesByHeartbeat)
+	)
+	for addr, list := range pool.queue {
+		class := pool.classOf[addr]
+		queued[class] += uint64(list.Len())
+		if !pool.locals.contains(addr) { // don't drop locals
+			addresses[class] = append(addresses[class], addressByHeartbeat{addr, pool.beats[addr]})
+		}
 	}
-	if queued <= pool.config.GlobalQueue {
-		return
+	for class, count := range queued {
+		limit := pool.config.GlobalQueue
+		if class != nil && class.GlobalQueue > 0 {
+			limit = class.GlobalQueue
+		}
+		if count > limit {
+			pool.truncateQueueOf(addresses[class], count-limit)
+		}
 	}
+}
 
+// truncateQueueOf drops queued transactions of the given accounts until the
+// overflow is dropped or only locals remain, starting with the accounts with
+// the oldest heartbeat.
+func (pool *TxPool) truncateQueueOf(addresses addressesByHeartbeat, drop uint64) {
 	// Sort all accounts with queued transactions by heartbeat
-	addresses := make(addressesByHeartbeat, 0, len(pool.queue))
-	for addr := range pool.queue {
-		if !pool.locals.contains(addr) { // don't drop locals
-			addresses = append(addresses, addressByHeartbeat{addr, pool.beats[addr]})
-		}
-	}
 	sort.Sort(sort.Reverse(addresses))
 
 	// Drop transactions until the total is below the limit or only locals remain
-	for drop := queued - pool.config.GlobalQueue; drop > 0 && len(addresses) > 0; {
+	for drop > 0 && len(addresses) > 0 {
 		addr := addresses[len(addresses)-1]
 		list := pool.queue[addr.address]
 
@@ -1523,6 +2064,7 @@ func (pool *TxPool) truncateQueue() {
 		// Drop all transactions if they are less than the overflow
 		if size := uint64(list.Len()); size <= drop {
 			for _, tx := range list.Flatten() {
+				pool.txDropped(tx, TxDropPoolOverflow)
 				pool.removeTx(tx.Hash(), true)
 			}
 			drop -= size
@@ -1532,6 +2074,7 @@ func (pool *TxPool) truncateQueue() {
 		// Otherwise drop only last few transactions
 		txs := list.Flatten()
 		for i := len(txs) - 1; i >= 0 && drop > 0; i-- {
+			pool.txDropped(txs[i], TxDropPoolOverflow)
 			pool.removeTx(txs[i].Hash(), true)
 			drop--
 			queuedRateLimitMeter.Mark(1)
@@ -1555,14 +2098,17 @@ func (pool *TxPool) demoteUnexecutables() {
 		olds := list.Forward(nonce)
 		for _, tx := range olds {
 			hash := tx.Hash()
+			pool.txDropped(tx, TxDropNonceTooLow)
 			pool.all.Remove(hash)
 			log.Trace("Removed old pending transaction", "hash", hash)
 		}
 		balance := pool.currentState.GetBalance(addr)
 		if !list.Empty() {
 			// Reduce the cost-cap by L1 rollup cost of the first tx if necessary. Other txs will get filtered out afterwards.
-			if l1Cost := pool.l1CostFn(list.txs.FirstElement()); l1Cost != nil {
-				balance = balance.Sub(balance, l1Cost) // negative big int is fine
+			if first := list.txs.FirstElement(); pool.handlesFees(first) {
+				// Fees paid in the fee currency were checked at admission
+			} else if l1Cost := pool.l1CostFn(first); l1Cost != nil {
+				balance = new(big.Int).Sub(balance, l1Cost) // negative big int is fine
 			}
 		}
 		// Drop all transactions that are too costly (low balance or out of gas), and queue any invalids back for later
@@ -1570,6 +2116,7 @@ func (pool *TxPool) demoteUnexecutables() {
 		for _, tx := range drops {
 			hash := tx.Hash()
 			log.Trace("Removed unpayable pending transaction", "hash", hash)
+			pool.txDropped(tx, pool.unaffordableReason(addr, tx))
 			pool.all.Remove(hash)
 		}
 		pendingNofundsMeter.Mark(int64(len(drops)))
@@ -1577,6 +2124,7 @@ func (pool *TxPool) demoteUnexecutables() {
 		for _, tx := range invalids {
 			hash := tx.Hash()
 			log.Trace("Demoting pending transaction", "hash", hash)
+			pool.audit.record(&TxPoolAuditEntry{Kind: TxAuditDemote, Hash: hash})
 
 			// Internal shuffle shouldn't touch the lookup set.
 			pool.enqueueTx(hash, tx, false, false)
@@ -1591,6 +2139,7 @@ func (pool *TxPool) demoteUnexecutables() {
 			for _, tx := range gapped {
 				hash := tx.Hash()
 				log.Error("Demoting invalidated transaction", "hash", hash)
+				pool.audit.record(&TxPoolAuditEntry{Kind: TxAuditDemote, Hash: hash})
 
 				// Internal shuffle shouldn't touch the lookup set.
 				pool.enqueueTx(hash, tx, false, false)
@@ -1602,6 +2151,7 @@ func (pool *TxPool) demoteUnexecutables() {
 		// Delete the entire pending entry if it became empty.
 		if list.Empty() {
 			delete(pool.pending, addr)
+			pool.senders[0].remove(addr)
 		}
 	}
 }
@@ -1702,9 +2252,13 @@ func (as *accountSet) merge(other *accountSet) {
 // to build upper-level structure.
 type txLookup struct {
 	slots   int
+	blobs   int
 	lock    sync.RWMutex
 	locals  map[common.Hash]*types.Transaction
 	remotes map[common.Hash]*types.Transaction
+
+	origins      map[common.Hash]TxOrigin // Origins of the transactions, if known
+	originCounts [txOriginCount]int       // Number of transactions per origin
 }
 
 // newTxLookup returns a new txLookup structure.
@@ -1712,6 +2266,7 @@ func newTxLookup() *txLookup {
 	return &txLookup{
 		locals:  make(map[common.Hash]*types.Transaction),
 		remotes: make(map[common.Hash]*types.Transaction),
+		origins: make(map[common.Hash]TxOrigin),
 	}
 }
 
@@ -1797,6 +2352,47 @@ func (t *txLookup) Slots() int {
 	return t.slots
 }
 
+// Blobs returns the current number of blob transactions in the lookup.
+func (t *txLookup) Blobs() int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.blobs
+}
+
+// OriginCount returns the current number of transactions from an origin in the
+// lookup.
+func (t *txLookup) OriginCount(origin TxOrigin) int {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.originCounts[origin]
+}
+
+// Origin returns the origin of a transaction in the lookup, if known.
+func (t *txLookup) Origin(hash common.Hash) (TxOrigin, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	origin, ok := t.origins[hash]
+	return origin, ok
+}
+
+// SetOrigin records the origin of a transaction in the lookup.
+func (t *txLookup) SetOrigin(hash common.Hash, origin TxOrigin) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.locals[hash] == nil && t.remotes[hash] == nil {
+		return
+	}
+	if old, ok := t.origins[hash]; ok {
+		t.originCounts[old]--
+	}
+	t.origins[hash] = origin
+	t.originCounts[origin]++
+}
+
 // Add adds a transaction to the lookup.
 func (t *txLookup) Add(tx *types.Transaction, local bool) {
 	t.lock.Lock()
@@ -1805,6 +2401,11 @@ func (t *txLookup) Add(tx *types.Transaction, local bool) {
 	t.slots += numSlots(tx)
 	slotsGauge.Update(int64(t.slots))
 
+	if tx.Type() == types.BlobTxType {
+		t.blobs++
+		blobsGauge.Update(int64(t.blobs))
+	}
+
 	if local {
 		t.locals[tx.Hash()] = tx
 	} else {
@@ -1828,6 +2429,15 @@ func (t *txLookup) Remove(hash common.Hash) {
 	t.slots -= numSlots(tx)
 	slotsGauge.Update(int64(t.slots))
 
+	if tx.Type() == types.BlobTxType {
+		t.blobs--
+		blobsGauge.Update(int64(t.blobs))
+	}
+	if origin, ok := t.origins[hash]; ok {
+		t.originCounts[origin]--
+		delete(t.origins, hash)
+	}
+
 	delete(t.locals, hash)
 	delete(t.remotes, hash)
 }
@@ -0,0 +1,270 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Kinds of entries in the transaction pool audit log.
+const (
+	TxAuditAdd     = "add"     // Transaction arrival, admitted or rejected
+	TxAuditDrop    = "drop"    // Transaction removed from the pool
+	TxAuditPromote = "promote" // Transaction moved from the queue to pending
+	TxAuditDemote  = "demote"  // Transaction moved from pending to the queue
+	TxAuditHead    = "head"    // Pool reset to a new chain head
+)
+
+// Pool sections a transaction may be admitted into.
+const (
+	txAuditPending = "pending"
+	txAuditQueued  = "queued"
+)
+
+// TxPoolAuditEntry is a single record of the transaction pool audit log.
+type TxPoolAuditEntry struct {
+	Time     time.Time       `json:"time"`
+	Kind     string          `json:"kind"`
+	Hash     common.Hash     `json:"hash"`               // Transaction hash, or block hash for heads
+	From     *common.Address `json:"from,omitempty"`     // Sender of added transactions
+	Tx       hexutil.Bytes   `json:"tx,omitempty"`       // Raw added transaction
+	Local    bool            `json:"local,omitempty"`    // Whether an added transaction is local
+	Status   string          `json:"status,omitempty"`   // Pool section an admitted transaction went into
+	Error    string          `json:"error,omitempty"`    // Reason an added transaction was rejected
+	Reason   string          `json:"reason,omitempty"`   // Reason a transaction was dropped
+	Number   uint64          `json:"number,omitempty"`   // Number of a new head
+	Included []common.Hash   `json:"included,omitempty"` // Transactions included in a new head
+}
+
+// txPoolAudit is an append-only log of the decisions taken by the pool, kept
+// to allow reconstructing the pool contents at any block after the fact.
+type txPoolAudit struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	failed bool // Whether a write failure was already reported
+}
+
+// newTxPoolAudit opens the audit log at the given path for appending.
+func newTxPoolAudit(path string) (*txPoolAudit, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &txPoolAudit{file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// record appends an entry to the log. Head entries flush the log to disk, so
+// that at most the decisions of a single block are lost on a crash.
+func (audit *txPoolAudit) record(entry *TxPoolAuditEntry) {
+	if audit == nil {
+		return
+	}
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	blob, err := json.Marshal(entry)
+	if err == nil {
+		blob = append(blob, '\n')
+		if _, err = audit.writer.Write(blob); err == nil && entry.Kind == TxAuditHead {
+			err = audit.writer.Flush()
+		}
+	}
+	if err != nil && !audit.failed {
+		log.Warn("Failed to write transaction pool audit log", "err", err)
+		audit.failed = true
+	}
+}
+
+// close flushes and closes the log.
+func (audit *txPoolAudit) close() error {
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+
+	if err := audit.writer.Flush(); err != nil {
+		audit.file.Close()
+		return err
+	}
+	return audit.file.Close()
+}
+
+// auditAdd records the arrival of a transaction and the decision taken on it.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) auditAdd(tx *types.Transaction, local bool, err error) {
+	if pool.audit == nil {
+		return
+	}
+	entry := &TxPoolAuditEntry{Kind: TxAuditAdd, Hash: tx.Hash(), Local: local}
+	entry.Tx, _ = tx.MarshalBinary()
+	if from, senderErr := types.Sender(pool.signer, tx); senderErr == nil {
+		entry.From = &from
+	}
+	switch {
+	case err != nil:
+		entry.Error = err.Error()
+	case entry.From != nil:
+		entry.Status = txAuditQueued
+		if list := pool.pending[*entry.From]; list != nil && list.txs.Get(tx.Nonce()) == tx {
+			entry.Status = txAuditPending
+		}
+	}
+	pool.audit.record(entry)
+}
+
+// auditHead records a reset of the pool to a new chain head.
+func (pool *TxPool) auditHead(head *types.Header) {
+	if pool.audit == nil {
+		return
+	}
+	entry := &TxPoolAuditEntry{Kind: TxAuditHead, Hash: head.Hash(), Number: head.Number.Uint64()}
+	if block := pool.chain.GetBlock(head.Hash(), head.Number.Uint64()); block != nil {
+		for _, tx := range block.Transactions() {
+			entry.Included = append(entry.Included, tx.Hash())
+		}
+	}
+	pool.audit.record(entry)
+}
+
+// TxPoolAuditTx is a transaction in a reconstructed pool.
+type TxPoolAuditTx struct {
+	Hash    common.Hash    `json:"hash"`
+	From    common.Address `json:"from"`
+	Tx      hexutil.Bytes  `json:"tx"`
+	Local   bool           `json:"local"`
+	Arrived time.Time      `json:"arrived"`
+}
+
+// TxPoolAuditSnapshot is the pool contents right before it was reset to a
+// given block, i.e. the transactions the block builder could choose from.
+type TxPoolAuditSnapshot struct {
+	Number   uint64           `json:"number"`
+	Hash     common.Hash      `json:"hash"`
+	Time     time.Time        `json:"time"` // Time the pool saw the block
+	Pending  []*TxPoolAuditTx `json:"pending"`
+	Queued   []*TxPoolAuditTx `json:"queued"`
+	Included []common.Hash    `json:"included"`
+	Skipped  []common.Hash    `json:"skipped"` // Pending but not included, by arrival
+}
+
+// ReplayTxPoolAudit replays an audit log up to the given block and returns the
+// pool contents the block was built from. If the log contains the block more
+// than once, e.g. due to restarts, the first occurrence is used.
+func ReplayTxPoolAudit(r io.Reader, number uint64) (*TxPoolAuditSnapshot, error) {
+	var (
+		dec     = json.NewDecoder(r)
+		txs     = make(map[common.Hash]*TxPoolAuditTx)
+		pending = make(map[common.Hash]bool)
+	)
+	for {
+		var entry TxPoolAuditEntry
+		if err := dec.Decode(&entry); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, fmt.Errorf("block %d not found in audit log", number)
+			}
+			return nil, err
+		}
+		switch entry.Kind {
+		case TxAuditAdd:
+			if entry.Error != "" || entry.From == nil {
+				continue
+			}
+			txs[entry.Hash] = &TxPoolAuditTx{
+				Hash:    entry.Hash,
+				From:    *entry.From,
+				Tx:      entry.Tx,
+				Local:   entry.Local,
+				Arrived: entry.Time,
+			}
+			pending[entry.Hash] = entry.Status == txAuditPending
+
+		case TxAuditDrop:
+			delete(txs, entry.Hash)
+			delete(pending, entry.Hash)
+
+		case TxAuditPromote, TxAuditDemote:
+			if _, ok := txs[entry.Hash]; ok {
+				pending[entry.Hash] = entry.Kind == TxAuditPromote
+			}
+
+		case TxAuditHead:
+			if entry.Number != number {
+				continue
+			}
+			snapshot := &TxPoolAuditSnapshot{
+				Number:   entry.Number,
+				Hash:     entry.Hash,
+				Time:     entry.Time,
+				Pending:  []*TxPoolAuditTx{},
+				Queued:   []*TxPoolAuditTx{},
+				Included: entry.Included,
+				Skipped:  []common.Hash{},
+			}
+			if snapshot.Included == nil {
+				snapshot.Included = []common.Hash{}
+			}
+			for hash, tx := range txs {
+				if pending[hash] {
+					snapshot.Pending = append(snapshot.Pending, tx)
+				} else {
+					snapshot.Queued = append(snapshot.Queued, tx)
+				}
+			}
+			sortByArrival(snapshot.Pending)
+			sortByArrival(snapshot.Queued)
+
+			included := make(map[common.Hash]bool, len(entry.Included))
+			for _, hash := range entry.Included {
+				included[hash] = true
+			}
+			for _, tx := range snapshot.Pending {
+				if !included[tx.Hash] {
+					snapshot.Skipped = append(snapshot.Skipped, tx.Hash)
+				}
+			}
+			return snapshot, nil
+		}
+	}
+}
+
+// sortByArrival sorts transactions by arrival time, breaking ties by hash to
+// keep the replay deterministic.
+func sortByArrival(txs []*TxPoolAuditTx) {
+	sort.Slice(txs, func(i, j int) bool {
+		if !txs[i].Arrived.Equal(txs[j].Arrived) {
+			return txs[i].Arrived.Before(txs[j].Arrived)
+		}
+		return bytes.Compare(txs[i].Hash[:], txs[j].Hash[:]) < 0
+	})
+}
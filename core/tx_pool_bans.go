@@ -0,0 +1,210 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// ErrSenderBanned is returned if the sender of a transaction is temporarily
+// banned from the pool.
+var ErrSenderBanned = errors.New("sender banned")
+
+// MaxBanDuration is the longest ban of a sender, longer bans are shortened to it.
+const MaxBanDuration = 100 * 365 * 24 * time.Hour
+
+// txBans is the set of senders temporarily banned from the pool. The bans are
+// saved to disk on every change to survive node restarts.
+type txBans struct {
+	mu    sync.Mutex
+	path  string // File the bans are saved to (empty = not persisted)
+	until map[common.Address]time.Time
+}
+
+// newTxBans creates the ban set, loading the bans saved at the given path.
+func newTxBans(path string) *txBans {
+	bans := &txBans{
+		path:  path,
+		until: make(map[common.Address]time.Time),
+	}
+	if path == "" {
+		return bans
+	}
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("Failed to load transaction pool bans", "err", err)
+		}
+		return bans
+	}
+	if err := json.Unmarshal(blob, &bans.until); err != nil {
+		log.Warn("Failed to decode transaction pool bans", "err", err)
+		bans.until = make(map[common.Address]time.Time)
+		return bans
+	}
+	bans.expire()
+	if len(bans.until) > 0 {
+		log.Info("Loaded transaction pool bans", "senders", len(bans.until))
+	}
+	return bans
+}
+
+// ban bans the sender until the given time, lifting its ban if the time is not
+// in the future, and saves the bans.
+func (b *txBans) ban(addr common.Address, until time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if until.After(time.Now()) {
+		b.until[addr] = until
+	} else {
+		delete(b.until, addr)
+	}
+	b.expire()
+	return b.save()
+}
+
+// banned reports whether the sender is currently banned.
+func (b *txBans) banned(addr common.Address) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	until, ok := b.until[addr]
+	return ok && time.Now().Before(until)
+}
+
+// list returns the banned senders and the end of their bans.
+func (b *txBans) list() map[common.Address]time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.expire()
+	bans := make(map[common.Address]time.Time, len(b.until))
+	for addr, until := range b.until {
+		bans[addr] = until
+	}
+	return bans
+}
+
+// expire forgets the bans that ended.
+//
+// Note, this method assumes the ban lock is held!
+func (b *txBans) expire() {
+	now := time.Now()
+	for addr, until := range b.until {
+		if !now.Before(until) {
+			delete(b.until, addr)
+		}
+	}
+}
+
+// save writes the bans to disk, replacing the previous ones atomically.
+//
+// Note, this method assumes the ban lock is held!
+func (b *txBans) save() error {
+	if b.path == "" {
+		return nil
+	}
+	blob, err := json.Marshal(b.until)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(b.path+".new", blob, 0644); err != nil {
+		return err
+	}
+	return os.Rename(b.path+".new", b.path)
+}
+
+// ValidateTx implements TxValidationHook, rejecting the transactions of banned
+// senders.
+func (b *txBans) ValidateTx(tx *types.Transaction, ctx *TxValidationContext) error {
+	if b.banned(ctx.From) {
+		return ErrSenderBanned
+	}
+	return nil
+}
+
+// Evict removes the given transactions from the pool, returning the hashes of
+// the ones that were pooled. Subsequent transactions of the same senders are
+// moved back to the queue.
+func (pool *TxPool) Evict(hashes []common.Hash) []common.Hash {
+	pool.mu.Lock()
+	var evicted []common.Hash
+	for _, hash := range hashes {
+		tx := pool.all.Get(hash)
+		if tx == nil {
+			continue
+		}
+		pool.txDropped(tx, TxDropEvicted)
+		pool.removeTx(hash, true)
+		evicted = append(evicted, hash)
+	}
+	dropped := pool.takeDropped()
+	pool.mu.Unlock()
+
+	pool.sendDropped(dropped)
+	return evicted
+}
+
+// BanSender rejects the transactions of the sender for the given duration, at
+// most MaxBanDuration, and removes its pooled ones, returning their hashes. A
+// duration that is not positive lifts the ban of the sender. The error reports
+// a failure to save the bans, the ban is in effect regardless.
+func (pool *TxPool) BanSender(addr common.Address, duration time.Duration) ([]common.Hash, error) {
+	if duration > MaxBanDuration {
+		duration = MaxBanDuration
+	}
+	err := pool.bans.ban(addr, time.Now().Add(duration))
+	if duration <= 0 {
+		log.Info("Lifted transaction pool ban", "sender", addr)
+		return nil, err
+	}
+	pool.mu.Lock()
+	var txs types.Transactions
+	if list := pool.pending[addr]; list != nil {
+		txs = append(txs, list.Flatten()...)
+	}
+	if list := pool.queue[addr]; list != nil {
+		txs = append(txs, list.Flatten()...)
+	}
+	evicted := make([]common.Hash, 0, len(txs))
+	for _, tx := range txs {
+		pool.txDropped(tx, TxDropBanned)
+		pool.removeTx(tx.Hash(), true)
+		evicted = append(evicted, tx.Hash())
+	}
+	dropped := pool.takeDropped()
+	pool.mu.Unlock()
+
+	pool.sendDropped(dropped)
+	log.Warn("Banned transaction pool sender", "sender", addr, "duration", duration, "evicted", len(evicted))
+	return evicted, err
+}
+
+// Bans returns the senders currently banned from the pool and the end of their
+// bans.
+func (pool *TxPool) Bans() map[common.Address]time.Time {
+	return pool.bans.list()
+}
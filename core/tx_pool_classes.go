@@ -0,0 +1,152 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// TxQueueClass is a class of senders, e.g. bridge relayers, whose non-executable
+// transactions are subject to limits of their own instead of the pool-wide ones.
+// The queued transactions of a class don't count towards the global queue, and
+// zero limits fall back to the pool-wide ones.
+type TxQueueClass struct {
+	Name         string
+	Senders      []common.Address
+	AccountQueue uint64        // Maximum number of queued transactions per account of the class
+	GlobalQueue  uint64        // Maximum number of queued transactions of all accounts of the class
+	Lifetime     time.Duration // Maximum amount of time transactions of the class are queued
+}
+
+// txQueueClassFile is the policy file format of a queue class.
+type txQueueClassFile struct {
+	Name         string           `json:"name"`
+	Senders      []common.Address `json:"senders"`
+	AccountQueue uint64           `json:"accountQueue"`
+	GlobalQueue  uint64           `json:"globalQueue"`
+	Lifetime     string           `json:"lifetime"` // e.g. "72h"
+}
+
+// LoadTxQueueClasses reads the queue classes of a policy file, a JSON list of
+// classes with the lifetimes given as duration strings.
+func LoadTxQueueClasses(path string) ([]TxQueueClass, error) {
+	blob, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file []txQueueClassFile
+	if err := json.Unmarshal(blob, &file); err != nil {
+		return nil, fmt.Errorf("invalid queue policy %s: %v", path, err)
+	}
+	classes := make([]TxQueueClass, len(file))
+	for i, class := range file {
+		classes[i] = TxQueueClass{
+			Name:         class.Name,
+			Senders:      class.Senders,
+			AccountQueue: class.AccountQueue,
+			GlobalQueue:  class.GlobalQueue,
+		}
+		if class.Lifetime != "" {
+			if classes[i].Lifetime, err = time.ParseDuration(class.Lifetime); err != nil {
+				return nil, fmt.Errorf("invalid lifetime of queue class %q: %v", class.Name, err)
+			}
+		}
+	}
+	return classes, nil
+}
+
+// SetQueueClasses replaces the queue classes of the pool. Transactions exceeding
+// the new global limits are dropped right away, the per account limits apply on
+// the next promotion of the account.
+func (pool *TxPool) SetQueueClasses(classes []TxQueueClass) error {
+	classOf := make(map[common.Address]*TxQueueClass)
+	sanitized := make([]TxQueueClass, len(classes))
+	for i, class := range classes {
+		if class.Name == "" {
+			return fmt.Errorf("queue class %d has no name", i)
+		}
+		class.Senders = append([]common.Address(nil), class.Senders...)
+		sanitized[i] = class
+		for _, addr := range class.Senders {
+			if other, ok := classOf[addr]; ok {
+				return fmt.Errorf("sender %x in both queue classes %q and %q", addr, other.Name, class.Name)
+			}
+			classOf[addr] = &sanitized[i]
+		}
+	}
+	pool.mu.Lock()
+	pool.queueClasses, pool.classOf = sanitized, classOf
+	pool.mu.Unlock()
+
+	<-pool.requestPromoteExecutables(newAccountSet(pool.signer))
+	return nil
+}
+
+// QueueClasses returns the queue classes of the pool.
+func (pool *TxPool) QueueClasses() []TxQueueClass {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	classes := make([]TxQueueClass, len(pool.queueClasses))
+	for i, class := range pool.queueClasses {
+		class.Senders = append([]common.Address(nil), class.Senders...)
+		classes[i] = class
+	}
+	return classes
+}
+
+// accountQueue returns the maximum number of queued transactions of an account.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) accountQueue(addr common.Address) uint64 {
+	if class := pool.classOf[addr]; class != nil && class.AccountQueue > 0 {
+		return class.AccountQueue
+	}
+	return pool.config.AccountQueue
+}
+
+// queueLifetime returns the maximum amount of time the transactions of an
+// account are queued.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) queueLifetime(addr common.Address) time.Duration {
+	if class := pool.classOf[addr]; class != nil && class.Lifetime > 0 {
+		return class.Lifetime
+	}
+	return pool.config.Lifetime
+}
+
+// queueCapacity returns the number of slots reserved for queued transactions,
+// the global queue and the queues of all classes.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) queueCapacity() uint64 {
+	capacity := pool.config.GlobalQueue
+	for _, class := range pool.queueClasses {
+		if class.GlobalQueue > 0 {
+			capacity += class.GlobalQueue
+		} else {
+			capacity += pool.config.GlobalQueue
+		}
+	}
+	return capacity
+}
@@ -0,0 +1,206 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// maxConditionalCost is the maximum number of accounts and storage slots the
+// conditions of a single transaction may reference.
+const maxConditionalCost = 1000
+
+// TxDropConditionNotMet is the reason for dropping a conditional transaction
+// whose conditions can no longer be met.
+const TxDropConditionNotMet = "conditions not met"
+
+var (
+	// ErrConditionNotMet is returned if the conditions of a transaction don't
+	// hold. The concrete error is a *ConditionError.
+	ErrConditionNotMet = errors.New("transaction conditions not met")
+
+	// ErrConditionTooCostly is returned if the conditions of a transaction
+	// reference too many accounts and storage slots.
+	ErrConditionTooCostly = errors.New("transaction conditions too costly")
+)
+
+// KnownAccount is the state an account must have for a conditional transaction
+// to be included. Unset fields are not checked.
+type KnownAccount struct {
+	Nonce   *uint64
+	Storage map[common.Hash]common.Hash
+}
+
+// TxConditional are the preconditions of a transaction. The transaction is only
+// included in blocks satisfying all of them, and dropped from the pool once they
+// can no longer be satisfied.
+type TxConditional struct {
+	BlockNumberMin *big.Int
+	BlockNumberMax *big.Int
+	TimestampMin   *uint64
+	TimestampMax   *uint64
+	KnownAccounts  map[common.Address]KnownAccount
+}
+
+// ConditionError describes the condition that failed.
+type ConditionError struct {
+	Condition string          `json:"condition"`         // Name of the failed condition
+	Address   *common.Address `json:"address,omitempty"` // Account of a failed account condition
+	Slot      *common.Hash    `json:"slot,omitempty"`    // Storage slot of a failed storage condition
+	Have      string          `json:"have"`              // Actual value
+	Want      string          `json:"want"`              // Required value
+}
+
+func (e *ConditionError) Error() string {
+	switch {
+	case e.Slot != nil:
+		return fmt.Sprintf("%v: %s of %x slot %x: have %s, want %s", ErrConditionNotMet, e.Condition, *e.Address, *e.Slot, e.Have, e.Want)
+	case e.Address != nil:
+		return fmt.Sprintf("%v: %s of %x: have %s, want %s", ErrConditionNotMet, e.Condition, *e.Address, e.Have, e.Want)
+	default:
+		return fmt.Sprintf("%v: %s: have %s, want %s", ErrConditionNotMet, e.Condition, e.Have, e.Want)
+	}
+}
+
+func (e *ConditionError) Unwrap() error { return ErrConditionNotMet }
+
+// Cost returns the number of accounts and storage slots the conditions reference.
+func (c *TxConditional) Cost() int {
+	cost := 0
+	for _, account := range c.KnownAccounts {
+		cost += 1 + len(account.Storage)
+	}
+	return cost
+}
+
+// Check verifies the conditions against a block with the given number and time,
+// executing on top of the given state.
+func (c *TxConditional) Check(number *big.Int, time uint64, statedb *state.StateDB) error {
+	if c.BlockNumberMin != nil && number.Cmp(c.BlockNumberMin) < 0 {
+		return &ConditionError{Condition: "blockNumberMin", Have: number.String(), Want: c.BlockNumberMin.String()}
+	}
+	if c.TimestampMin != nil && time < *c.TimestampMin {
+		return &ConditionError{Condition: "timestampMin", Have: fmt.Sprint(time), Want: fmt.Sprint(*c.TimestampMin)}
+	}
+	return c.checkLive(number, time, statedb)
+}
+
+// checkLive verifies the conditions a transaction must keep satisfying to stay
+// in the pool: the upper bounds and the state of the known accounts.
+func (c *TxConditional) checkLive(number *big.Int, time uint64, statedb *state.StateDB) error {
+	if c.BlockNumberMax != nil && number.Cmp(c.BlockNumberMax) > 0 {
+		return &ConditionError{Condition: "blockNumberMax", Have: number.String(), Want: c.BlockNumberMax.String()}
+	}
+	if c.TimestampMax != nil && time > *c.TimestampMax {
+		return &ConditionError{Condition: "timestampMax", Have: fmt.Sprint(time), Want: fmt.Sprint(*c.TimestampMax)}
+	}
+	for addr, account := range c.KnownAccounts {
+		addr := addr
+		if account.Nonce != nil {
+			if nonce := statedb.GetNonce(addr); nonce != *account.Nonce {
+				return &ConditionError{Condition: "nonce", Address: &addr, Have: fmt.Sprint(nonce), Want: fmt.Sprint(*account.Nonce)}
+			}
+		}
+		for slot, want := range account.Storage {
+			slot := slot
+			if have := statedb.GetState(addr, slot); have != want {
+				return &ConditionError{Condition: "storage", Address: &addr, Slot: &slot, Have: have.Hex(), Want: want.Hex()}
+			}
+		}
+	}
+	return nil
+}
+
+// AddRemoteConditional enqueues a remote transaction which may only be included
+// while its conditions hold. Conditions that can't be met anymore reject the
+// transaction right away, while lower bounds not reached yet keep it waiting in
+// the pool.
+func (pool *TxPool) AddRemoteConditional(tx *types.Transaction, cond *TxConditional) error {
+	if cost := cond.Cost(); cost > maxConditionalCost {
+		return fmt.Errorf("%w: %d > %d", ErrConditionTooCostly, cost, maxConditionalCost)
+	}
+	pool.mu.RLock()
+	head, statedb := pool.chain.CurrentBlock().Header(), pool.currentState
+	next := new(big.Int).Add(head.Number, common.Big1)
+	err := cond.checkLive(next, uint64(time.Now().Unix()), statedb)
+	pool.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return pool.addRemoteConditional(tx, cond)
+}
+
+// addRemoteConditional adds the transaction and records its conditions while
+// holding the pool lock, so the conditions are in place before the transaction
+// can be picked for a block.
+func (pool *TxPool) addRemoteConditional(tx *types.Transaction, cond *TxConditional) error {
+	if pool.all.Get(tx.Hash()) != nil {
+		knownTxMeter.Mark(1)
+		return ErrAlreadyKnown
+	}
+	if _, err := types.Sender(pool.signer, tx); err != nil {
+		invalidTxMeter.Mark(1)
+		return ErrInvalidSender
+	}
+	pool.mu.Lock()
+	errs, dirty := pool.addTxsLocked([]*types.Transaction{tx}, false, TxOriginRPC)
+	if errs[0] == nil {
+		pool.conditionals[tx.Hash()] = cond
+	}
+	pool.mu.Unlock()
+
+	pool.requestPromoteExecutables(dirty)
+	return errs[0]
+}
+
+// Conditional returns the conditions of a pooled transaction, or nil if it is
+// unconditional.
+func (pool *TxPool) Conditional(hash common.Hash) *TxConditional {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	return pool.conditionals[hash]
+}
+
+// dropUnmetConditionals forgets the conditions of transactions that left the
+// pool and drops the transactions whose conditions can't be met anymore after
+// the given head.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) dropUnmetConditionals(head *types.Header) {
+	next := new(big.Int).Add(head.Number, common.Big1)
+	for hash, cond := range pool.conditionals {
+		if pool.all.Get(hash) == nil {
+			delete(pool.conditionals, hash)
+			continue
+		}
+		if err := cond.checkLive(next, head.Time+1, pool.currentState); err != nil {
+			log.Debug("Dropping conditional transaction", "hash", hash, "err", err)
+			pool.txDropped(pool.all.Get(hash), TxDropConditionNotMet)
+			pool.removeTx(hash, true)
+			delete(pool.conditionals, hash)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ErrDenylisted is returned if the sender or recipient of a transaction is
+// denylisted by the pool configuration.
+var ErrDenylisted = errors.New("address denylisted")
+
+// TxValidationContext is the view of the pool a validation hook checks a
+// transaction against.
+type TxValidationContext struct {
+	From   common.Address
+	Local  bool
+	Origin TxOrigin
+	State  *state.StateDB // State the pool validates against, must not be modified
+	L1Cost *big.Int       // L1 data fee of the transaction, nil if not charged
+}
+
+// TxValidationHook is a custom policy applied to every transaction entering the
+// pool, after the transaction passed the built-in validation. Returning an error
+// rejects the transaction with that error.
+//
+// Hooks run with the pool lock held, so they must be quick and must not call
+// back into the pool.
+type TxValidationHook interface {
+	ValidateTx(tx *types.Transaction, ctx *TxValidationContext) error
+}
+
+// TxValidationHookFunc is an adapter to use an ordinary function as a hook.
+type TxValidationHookFunc func(tx *types.Transaction, ctx *TxValidationContext) error
+
+// ValidateTx implements TxValidationHook.
+func (f TxValidationHookFunc) ValidateTx(tx *types.Transaction, ctx *TxValidationContext) error {
+	return f(tx, ctx)
+}
+
+// AddressDenylist is a validation hook rejecting the transactions sent from or
+// to any of its addresses.
+type AddressDenylist map[common.Address]struct{}
+
+// NewAddressDenylist creates a denylist of the given addresses.
+func NewAddressDenylist(addrs []common.Address) AddressDenylist {
+	list := make(AddressDenylist, len(addrs))
+	for _, addr := range addrs {
+		list[addr] = struct{}{}
+	}
+	return list
+}
+
+// ValidateTx implements TxValidationHook.
+func (l AddressDenylist) ValidateTx(tx *types.Transaction, ctx *TxValidationContext) error {
+	if _, ok := l[ctx.From]; ok {
+		return ErrDenylisted
+	}
+	if to := tx.To(); to != nil {
+		if _, ok := l[*to]; ok {
+			return ErrDenylisted
+		}
+	}
+	return nil
+}
+
+// AddValidationHook registers a policy to check every incoming transaction
+// against. Transactions already in the pool are not checked.
+func (pool *TxPool) AddValidationHook(hook TxValidationHook) {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	pool.hooks = append(pool.hooks, hook)
+}
+
+// runValidationHooks checks a transaction against the registered hooks.
+//
+// Note, this method assumes the pool lock is held!
+func (pool *TxPool) runValidationHooks(tx *types.Transaction, from common.Address, local bool, origin TxOrigin) error {
+	if len(pool.hooks) == 0 {
+		return nil
+	}
+	ctx := &TxValidationContext{
+		From:   from,
+		Local:  local,
+		Origin: origin,
+		State:  pool.currentState,
+		L1Cost: pool.l1CostFn(tx),
+	}
+	for _, hook := range pool.hooks {
+		if err := hook.ValidateTx(tx, ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
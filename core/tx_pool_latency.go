@@ -0,0 +1,136 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// latencyWindow is the number of recent latencies the pool statistics are
+// computed over.
+const latencyWindow = 1024
+
+// TxLatencyStats summarizes the recent latencies of a pool event, measured from
+// the time a transaction was first seen.
+type TxLatencyStats struct {
+	Count   uint64        `json:"count"`   // Events since the pool started
+	Samples int           `json:"samples"` // Recent events the statistics are over
+	Mean    time.Duration `json:"mean"`    // in nanoseconds
+	P50     time.Duration `json:"p50"`
+	P90     time.Duration `json:"p90"`
+	P99     time.Duration `json:"p99"`
+	Max     time.Duration `json:"max"`
+}
+
+// TxPoolLatencies are the residency statistics of the transactions leaving the
+// pending set or the pool.
+type TxPoolLatencies struct {
+	Inclusion TxLatencyStats `json:"inclusion"` // Time to inclusion in a block
+	Drop      TxLatencyStats `json:"drop"`      // Time to removal without inclusion
+	Promotion TxLatencyStats `json:"promotion"` // Time to promotion from the queue to pending
+}
+
+// latencyTracker records the latencies of a pool event into a metrics timer and
+// keeps the recent ones for the statistics, which are available regardless of
+// whether metrics are enabled.
+type latencyTracker struct {
+	timer metrics.Timer
+
+	lock   sync.Mutex
+	count  uint64
+	recent []time.Duration // Ring buffer of the recent latencies
+	next   int             // Position of the next latency in the ring
+}
+
+func newLatencyTracker(name string) *latencyTracker {
+	return &latencyTracker{
+		timer:  metrics.GetOrRegisterTimer(name, nil),
+		recent: make([]time.Duration, 0, latencyWindow),
+	}
+}
+
+// update records the latency of an event for a transaction first seen at the
+// given time.
+func (t *latencyTracker) update(seen time.Time) {
+	latency := time.Since(seen)
+	t.timer.Update(latency)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.count++
+	if len(t.recent) < latencyWindow {
+		t.recent = append(t.recent, latency)
+	} else {
+		t.recent[t.next] = latency
+	}
+	t.next = (t.next + 1) % latencyWindow
+}
+
+// stats summarizes the recent latencies.
+func (t *latencyTracker) stats() TxLatencyStats {
+	t.lock.Lock()
+	sorted := append([]time.Duration(nil), t.recent...)
+	stats := TxLatencyStats{Count: t.count, Samples: len(sorted)}
+	t.lock.Unlock()
+
+	if len(sorted) == 0 {
+		return stats
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, latency := range sorted {
+		total += latency
+	}
+	percentile := func(p int) time.Duration {
+		return sorted[(len(sorted)-1)*p/100]
+	}
+	stats.Mean = total / time.Duration(len(sorted))
+	stats.P50, stats.P90, stats.P99 = percentile(50), percentile(90), percentile(99)
+	stats.Max = sorted[len(sorted)-1]
+	return stats
+}
+
+// txLatencies tracks how long transactions reside in the pool.
+type txLatencies struct {
+	inclusion *latencyTracker
+	drop      *latencyTracker
+	promotion *latencyTracker
+}
+
+func newTxLatencies() *txLatencies {
+	return &txLatencies{
+		inclusion: newLatencyTracker("txpool/latency/inclusion"),
+		drop:      newLatencyTracker("txpool/latency/drop"),
+		promotion: newLatencyTracker("txpool/latency/promotion"),
+	}
+}
+
+// Latencies returns the residency statistics of the recent transactions that
+// were included, dropped or promoted.
+func (pool *TxPool) Latencies() TxPoolLatencies {
+	return TxPoolLatencies{
+		Inclusion: pool.latencies.inclusion.stats(),
+		Drop:      pool.latencies.drop.stats(),
+		Promotion: pool.latencies.promotion.stats(),
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrOriginQuota is returned if the transactions pooled from the origin of a
+// transaction reached the quota of the origin.
+var ErrOriginQuota = errors.New("txpool origin quota exceeded")
+
+// TxOrigin is the source a transaction entered the pool from.
+type TxOrigin uint8
+
+const (
+	TxOriginP2P   TxOrigin = iota // Gossiped by peers
+	TxOriginRPC                   // Submitted through the RPC API
+	TxOriginLocal                 // Submitted locally, e.g. from the journal
+
+	txOriginCount
+)
+
+func (o TxOrigin) String() string {
+	switch o {
+	case TxOriginP2P:
+		return "p2p"
+	case TxOriginRPC:
+		return "rpc"
+	case TxOriginLocal:
+		return "local"
+	default:
+		return fmt.Sprintf("origin(%d)", o)
+	}
+}
+
+// TxOriginQuotas are the maximum numbers of pooled transactions per origin,
+// zero meaning unlimited. Quotas apply to new transactions, lowering one does
+// not evict pooled transactions.
+type TxOriginQuotas struct {
+	P2P   uint64 `toml:",omitempty"`
+	RPC   uint64 `toml:",omitempty"`
+	Local uint64 `toml:",omitempty"`
+}
+
+// quota returns the quota of an origin.
+func (q *TxOriginQuotas) quota(origin TxOrigin) uint64 {
+	switch origin {
+	case TxOriginP2P:
+		return q.P2P
+	case TxOriginRPC:
+		return q.RPC
+	case TxOriginLocal:
+		return q.Local
+	default:
+		return 0
+	}
+}
+
+// minedOrigin is the origin of a transaction included in a block, kept to
+// reinject the transaction under it if the block is reorged out.
+type minedOrigin struct {
+	origin TxOrigin
+	number uint64 // Number of the block including the transaction
+}
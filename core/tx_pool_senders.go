@@ -0,0 +1,63 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// senderIndex is the set of senders of a part of the pool, kept sorted by
+// address to page through the pool content without sorting it per request.
+type senderIndex struct {
+	addrs []common.Address
+}
+
+// search returns the position of the first sender not below the address.
+func (idx *senderIndex) search(addr common.Address) int {
+	return sort.Search(len(idx.addrs), func(i int) bool {
+		return bytes.Compare(idx.addrs[i][:], addr[:]) >= 0
+	})
+}
+
+// add inserts a sender into the index, if not yet present.
+func (idx *senderIndex) add(addr common.Address) {
+	i := idx.search(addr)
+	if i < len(idx.addrs) && idx.addrs[i] == addr {
+		return
+	}
+	idx.addrs = append(idx.addrs, common.Address{})
+	copy(idx.addrs[i+1:], idx.addrs[i:])
+	idx.addrs[i] = addr
+}
+
+// remove deletes a sender from the index, if present.
+func (idx *senderIndex) remove(addr common.Address) {
+	i := idx.search(addr)
+	if i == len(idx.addrs) || idx.addrs[i] != addr {
+		return
+	}
+	idx.addrs = append(idx.addrs[:i], idx.addrs[i+1:]...)
+}
+
+// from returns the senders starting at the given address. The returned slice
+// is shared with the index and only valid until it is next modified.
+func (idx *senderIndex) from(addr common.Address) []common.Address {
+	return idx.addrs[idx.search(addr):]
+}
@@ -0,0 +1,182 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// maxTaggedTxs is the number of tagged transactions remembered by the pool.
+// The oldest tags are forgotten first once the limit is reached.
+const maxTaggedTxs = 16384
+
+// Reasons for a transaction to be dropped from the pool.
+const (
+	TxDropExpired             = "expired"
+	TxDropUnderpriced         = "underpriced"
+	TxDropReplaced            = "replaced"
+	TxDropReplacedUnderpriced = "replacement underpriced"
+	TxDropNonceTooLow         = "nonce too low"
+	TxDropUnaffordable        = "insufficient funds or gas"
+	TxDropL1FeeInsufficient   = "insufficient funds for L1 fee"
+	TxDropAccountLimit        = "account limit exceeded"
+	TxDropPoolOverflow        = "pool overflow"
+	TxDropEvicted             = "evicted"
+	TxDropBanned              = "sender banned"
+)
+
+// maxDroppedTxs is the number of recent drops remembered by the pool.
+const maxDroppedTxs = 16384
+
+// TxDrop is the pool's record of a dropped transaction.
+type TxDrop struct {
+	Reason string
+	Time   time.Time
+}
+
+// txDrops remembers the reasons of recently dropped transactions.
+type txDrops struct {
+	cache *lru.Cache
+}
+
+func newTxDrops() *txDrops {
+	cache, _ := lru.New(maxDroppedTxs)
+	return &txDrops{cache: cache}
+}
+
+// add records the drop of a transaction.
+func (d *txDrops) add(hash common.Hash, reason string) {
+	d.cache.Add(hash, &TxDrop{Reason: reason, Time: time.Now()})
+}
+
+// Dropped returns why and when a transaction was dropped from the pool, or
+// nil if it is pooled or its drop is not remembered.
+func (pool *TxPool) Dropped(hash common.Hash) *TxDrop {
+	if pool.Get(hash) != nil {
+		return nil // Dropped but resubmitted since
+	}
+	if drop, ok := pool.drops.cache.Get(hash); ok {
+		cpy := *drop.(*TxDrop)
+		return &cpy
+	}
+	return nil
+}
+
+// TaggedTx is the pool's view of a transaction submitted with a tag.
+type TaggedTx struct {
+	Hash       common.Hash
+	Tag        string
+	Added      time.Time
+	Status     TxStatus  // Pool status, unknown once the transaction left the pool
+	DropReason string    // Set if the transaction was dropped
+	Dropped    time.Time // Set if the transaction was dropped
+}
+
+// txTags tracks tagged transactions and the reasons they left the pool.
+type txTags struct {
+	mu     sync.Mutex
+	byHash map[common.Hash]*TaggedTx
+	byTag  map[string][]*TaggedTx
+	order  []*TaggedTx // Insertion order, for evicting the oldest tags
+}
+
+func newTxTags() *txTags {
+	return &txTags{
+		byHash: make(map[common.Hash]*TaggedTx),
+		byTag:  make(map[string][]*TaggedTx),
+	}
+}
+
+// add tags a transaction, forgetting the oldest tag if over the limit.
+func (t *txTags) add(hash common.Hash, tag string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.byHash[hash]; ok {
+		return
+	}
+	if len(t.order) >= maxTaggedTxs {
+		t.forget(t.order[0])
+		t.order = t.order[1:]
+	}
+	entry := &TaggedTx{Hash: hash, Tag: tag, Added: time.Now()}
+	t.byHash[hash] = entry
+	t.byTag[tag] = append(t.byTag[tag], entry)
+	t.order = append(t.order, entry)
+}
+
+// forget removes an entry from the lookup maps.
+func (t *txTags) forget(entry *TaggedTx) {
+	delete(t.byHash, entry.Hash)
+	entries := t.byTag[entry.Tag]
+	for i, e := range entries {
+		if e == entry {
+			entries = append(entries[:i:i], entries[i+1:]...)
+			break
+		}
+	}
+	if len(entries) == 0 {
+		delete(t.byTag, entry.Tag)
+	} else {
+		t.byTag[entry.Tag] = entries
+	}
+}
+
+// drop records the reason a transaction left the pool, if it is tagged.
+func (t *txTags) drop(hash common.Hash, reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if entry, ok := t.byHash[hash]; ok && entry.DropReason == "" {
+		entry.DropReason, entry.Dropped = reason, time.Now()
+	}
+}
+
+// TagTx attaches an opaque tag to a pooled transaction, so that its lifecycle
+// can be queried later with TaggedTxs. Unknown transactions are ignored.
+func (pool *TxPool) TagTx(hash common.Hash, tag string) {
+	if pool.Get(hash) == nil {
+		return
+	}
+	pool.tags.add(hash, tag)
+}
+
+// TaggedTxs returns the transactions submitted with the given tag along with
+// their current status in the pool.
+func (pool *TxPool) TaggedTxs(tag string) []TaggedTx {
+	pool.tags.mu.Lock()
+	entries := pool.tags.byTag[tag]
+	txs := make([]TaggedTx, len(entries))
+	hashes := make([]common.Hash, len(entries))
+	for i, entry := range entries {
+		txs[i], hashes[i] = *entry, entry.Hash
+	}
+	pool.tags.mu.Unlock()
+
+	for i, status := range pool.Status(hashes) {
+		txs[i].Status = status
+		if status != TxStatusUnknown {
+			// Dropped but resubmitted since
+			txs[i].DropReason, txs[i].Dropped = "", time.Time{}
+		}
+	}
+	return txs
+}
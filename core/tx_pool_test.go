@@ -17,12 +17,17 @@
 package core
 
 import (
+	"bytes"
 	"crypto/ecdsa"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"reflect"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -49,6 +54,7 @@ var (
 func init() {
 	testTxPoolConfig = DefaultTxPoolConfig
 	testTxPoolConfig.Journal = ""
+	testTxPoolConfig.Bans = ""
 
 	cpy := *params.TestChainConfig
 	eip1559Config = &cpy
@@ -156,6 +162,21 @@ func validateTxPoolInternals(pool *TxPool) error {
 			return fmt.Errorf("pending nonce mismatch: have %v, want %v", nonce, last+1)
 		}
 	}
+	// Ensure the sender indexes are sorted and track exactly the pooled senders
+	for i, lists := range []map[common.Address]*txList{pool.pending, pool.queue} {
+		addrs := pool.senders[i].addrs
+		if len(addrs) != len(lists) {
+			return fmt.Errorf("sender index %d size mismatch: have %d, want %d", i, len(addrs), len(lists))
+		}
+		for j, addr := range addrs {
+			if lists[addr] == nil {
+				return fmt.Errorf("sender index %d tracks unknown sender %x", i, addr)
+			}
+			if j > 0 && bytes.Compare(addrs[j-1][:], addr[:]) >= 0 {
+				return fmt.Errorf("sender index %d unsorted at %d", i, j)
+			}
+		}
+	}
 	return nil
 }
 
@@ -430,14 +451,14 @@ func TestTransactionChainFork(t *testing.T) {
 	resetState()
 
 	tx := transaction(0, 100000, key)
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, false, TxOriginP2P); err != nil {
 		t.Error("didn't expect error", err)
 	}
 	pool.removeTx(tx.Hash(), true)
 
 	// reset the pool's internal state
 	resetState()
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, false, TxOriginP2P); err != nil {
 		t.Error("didn't expect error", err)
 	}
 }
@@ -464,10 +485,10 @@ func TestTransactionDoubleNonce(t *testing.T) {
 	tx3, _ := types.SignTx(types.NewTransaction(0, common.Address{}, big.NewInt(100), 1000000, big.NewInt(1), nil), signer, key)
 
 	// Add the first two transaction, ensure higher priced stays only
-	if replace, err := pool.add(tx1, false); err != nil || replace {
+	if replace, err := pool.add(tx1, false, TxOriginP2P); err != nil || replace {
 		t.Errorf("first transaction insert failed (%v) or reported replacement (%v)", err, replace)
 	}
-	if replace, err := pool.add(tx2, false); err != nil || !replace {
+	if replace, err := pool.add(tx2, false, TxOriginP2P); err != nil || !replace {
 		t.Errorf("second transaction insert failed (%v) or not reported replacement (%v)", err, replace)
 	}
 	<-pool.requestPromoteExecutables(newAccountSet(signer, addr))
@@ -479,7 +500,7 @@ func TestTransactionDoubleNonce(t *testing.T) {
 	}
 
 	// Add the third transaction and ensure it's not saved (smaller price)
-	pool.add(tx3, false)
+	pool.add(tx3, false, TxOriginP2P)
 	<-pool.requestPromoteExecutables(newAccountSet(signer, addr))
 	if pool.pending[addr].Len() != 1 {
 		t.Error("expected 1 pending transactions, got", pool.pending[addr].Len())
@@ -502,7 +523,7 @@ func TestTransactionMissingNonce(t *testing.T) {
 	addr := crypto.PubkeyToAddress(key.PublicKey)
 	testAddBalance(pool, addr, big.NewInt(100000000000000))
 	tx := transaction(1, 100000, key)
-	if _, err := pool.add(tx, false); err != nil {
+	if _, err := pool.add(tx, false, TxOriginP2P); err != nil {
 		t.Error("didn't expect error", err)
 	}
 	if len(pool.pending) != 0 {
@@ -1133,6 +1154,64 @@ func TestTransactionPendingLimiting(t *testing.T) {
 	}
 }
 
+// Tests that lowering the pool limits at runtime drops the excess transactions.
+func TestTransactionSetLimits(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	account := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, account, big.NewInt(1000000))
+
+	for i := uint64(0); i < 5; i++ {
+		if err := pool.addRemoteSync(transaction(i, 100000, key)); err != nil {
+			t.Fatalf("tx %d: failed to add transaction: %v", i, err)
+		}
+	}
+	config := testTxPoolConfig
+	config.AccountSlots, config.GlobalSlots = 1, 2
+	pool.SetLimits(config)
+
+	if pending, _ := pool.Stats(); pending != 2 {
+		t.Fatalf("pending transactions mismatch: have %d, want %d", pending, 2)
+	}
+	if pool.config.GlobalSlots != 2 {
+		t.Fatalf("global slots not updated: have %d, want %d", pool.config.GlobalSlots, 2)
+	}
+	if pool.gasPrice.Uint64() != testTxPoolConfig.PriceLimit {
+		t.Fatalf("gas price changed: have %v, want %d", pool.gasPrice, testTxPoolConfig.PriceLimit)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that the denylist can be replaced at runtime.
+func TestTransactionSetLimitsDenylist(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	account := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, account, big.NewInt(1000000))
+
+	config := testTxPoolConfig
+	config.Denylist = []common.Address{account}
+	pool.SetLimits(config)
+	if err := pool.addRemoteSync(transaction(0, 100000, key)); !errors.Is(err, ErrDenylisted) {
+		t.Fatalf("denylist error mismatch: have %v, want %v", err, ErrDenylisted)
+	}
+	if have := pool.Config().Denylist; len(have) != 1 || have[0] != account {
+		t.Fatalf("denylist not updated: have %v", have)
+	}
+	pool.SetLimits(testTxPoolConfig)
+	if err := pool.addRemoteSync(transaction(0, 100000, key)); err != nil {
+		t.Fatalf("failed to add transaction after lifting the denylist: %v", err)
+	}
+}
+
 // Tests that if the transaction count belonging to multiple accounts go above
 // some hard threshold, the higher transactions are dropped to prevent DOS
 // attacks.
@@ -1237,6 +1316,165 @@ func TestTransactionAllowedTxSize(t *testing.T) {
 	}
 }
 
+// Tests that transactions exceeding the size limits of the chain config are
+// rejected once the limits activate.
+func TestTransactionTxLimits(t *testing.T) {
+	t.Parallel()
+
+	config := *params.TestChainConfig
+	config.TxLimits = &params.TxLimitsConfig{MaxCalldata: 100, Block: common.Big0}
+
+	pool, key := setupTxPoolWithConfig(&config)
+	defer pool.Stop()
+
+	account := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, account, big.NewInt(1000000000))
+
+	if err := pool.addRemoteSync(pricedDataTransaction(0, 100000, big.NewInt(1), key, 100)); err != nil {
+		t.Fatalf("failed to add transaction within limits: %v", err)
+	}
+	if err := pool.addRemoteSync(pricedDataTransaction(1, 100000, big.NewInt(1), key, 101)); !errors.Is(err, ErrCalldataLimit) {
+		t.Fatalf("oversized calldata error mismatch: have %v, want %v", err, ErrCalldataLimit)
+	}
+}
+
+func blobTx(nonce uint64, blobFeeCap *big.Int, blobs int, key *ecdsa.PrivateKey) *types.Transaction {
+	hashes := make([]common.Hash, blobs)
+	for i := range hashes {
+		hashes[i][0] = params.BlobTxHashVersion
+	}
+	tx, _ := types.SignNewTx(key, types.NewCancunSigner(params.TestChainConfig.ChainID), &types.BlobTx{
+		ChainID:    params.TestChainConfig.ChainID,
+		Nonce:      nonce,
+		GasTipCap:  big.NewInt(1),
+		GasFeeCap:  big.NewInt(1),
+		Gas:        100000,
+		BlobFeeCap: blobFeeCap,
+		BlobHashes: hashes,
+	})
+	return tx
+}
+
+// Tests that blob transactions are only accepted once activated, are checked
+// against the blob fee and limits, and are limited separately in the pool.
+func TestTransactionBlobTx(t *testing.T) {
+	t.Parallel()
+
+	// Blob transactions are rejected before activation
+	pool, key := setupTxPoolWithConfig(eip1559Config)
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000000))
+	if err := pool.addRemoteSync(blobTx(0, big.NewInt(10), 1, key)); err == nil {
+		t.Fatal("blob tx accepted before activation")
+	}
+	pool.Stop()
+
+	config := *eip1559Config
+	config.BlobTx = &params.BlobTxConfig{MinBlobGasPrice: big.NewInt(10), Block: common.Big0}
+
+	pool, key = setupTxPoolWithConfig(&config)
+	defer pool.Stop()
+	pool.config.BlobSlots = 2
+
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000000))
+
+	if err := pool.addRemoteSync(blobTx(0, big.NewInt(9), 1, key)); !errors.Is(err, ErrBlobFeeCapTooLow) {
+		t.Fatalf("underpriced blob tx error mismatch: have %v, want %v", err, ErrBlobFeeCapTooLow)
+	}
+	if err := pool.addRemoteSync(blobTx(0, big.NewInt(10), 0, key)); !errors.Is(err, ErrMissingBlobHashes) {
+		t.Fatalf("blobless tx error mismatch: have %v, want %v", err, ErrMissingBlobHashes)
+	}
+	if err := pool.addRemoteSync(blobTx(0, big.NewInt(10), 7, key)); !errors.Is(err, ErrBlobGasLimit) {
+		t.Fatalf("blob limit error mismatch: have %v, want %v", err, ErrBlobGasLimit)
+	}
+	for i := uint64(0); i < 2; i++ {
+		if err := pool.addRemoteSync(blobTx(i, big.NewInt(10), 1, key)); err != nil {
+			t.Fatalf("failed to add blob tx %d: %v", i, err)
+		}
+	}
+	if err := pool.addRemoteSync(blobTx(2, big.NewInt(10), 1, key)); !errors.Is(err, ErrBlobPoolOverflow) {
+		t.Fatalf("blob pool overflow error mismatch: have %v, want %v", err, ErrBlobPoolOverflow)
+	}
+	// Regular transactions are not affected by the blob limit
+	if err := pool.addRemoteSync(dynamicFeeTx(2, 100000, big.NewInt(1), big.NewInt(1), key)); err != nil {
+		t.Fatalf("failed to add regular tx: %v", err)
+	}
+	if blobs := pool.all.Blobs(); blobs != 2 {
+		t.Fatalf("pooled blob tx count mismatch: have %d, want 2", blobs)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+	// The blob gas price follows the excess blob gas of the chain
+	excess, used := uint64(10*1024*1024), config.BlobTx.TargetBlobGas()
+	head := &types.Header{
+		Number:        big.NewInt(1),
+		GasLimit:      10000000,
+		BaseFee:       big.NewInt(params.InitialBaseFee),
+		ExcessBlobGas: &excess,
+		BlobGasUsed:   &used,
+	}
+	<-pool.requestReset(nil, head)
+
+	other, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(other.PublicKey), big.NewInt(1000000000000))
+	if err := pool.addRemoteSync(blobTx(0, big.NewInt(230), 1, other)); !errors.Is(err, ErrBlobFeeCapTooLow) {
+		t.Fatalf("blob tx below the blob fee market error mismatch: have %v, want %v", err, ErrBlobFeeCapTooLow)
+	}
+}
+
+func setCodeTx(nonce uint64, auths []types.SetCodeAuthorization, key *ecdsa.PrivateKey) *types.Transaction {
+	tx, _ := types.SignNewTx(key, types.NewPragueSigner(params.TestChainConfig.ChainID), &types.SetCodeTx{
+		ChainID:   params.TestChainConfig.ChainID,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(1),
+		Gas:       100000,
+		AuthList:  auths,
+	})
+	return tx
+}
+
+// Tests that set code transactions are only accepted once activated, must
+// carry authorizations, and pay for them in their intrinsic gas.
+func TestTransactionSetCodeTx(t *testing.T) {
+	t.Parallel()
+
+	authKey, _ := crypto.GenerateKey()
+	auth, _ := types.SignSetCode(authKey, types.SetCodeAuthorization{
+		ChainID: params.TestChainConfig.ChainID,
+		Address: common.Address{0xaa},
+	})
+	// Set code transactions are rejected before activation
+	pool, key := setupTxPoolWithConfig(eip1559Config)
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000000))
+	if err := pool.addRemoteSync(setCodeTx(0, []types.SetCodeAuthorization{auth}, key)); err == nil {
+		t.Fatal("set code tx accepted before activation")
+	}
+	pool.Stop()
+
+	config := *eip1559Config
+	config.SetCodeTx = &params.SetCodeTxConfig{Block: common.Big0}
+
+	pool, key = setupTxPoolWithConfig(&config)
+	defer pool.Stop()
+
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000000))
+
+	if err := pool.addRemoteSync(setCodeTx(0, []types.SetCodeAuthorization{}, key)); !errors.Is(err, ErrEmptyAuthList) {
+		t.Fatalf("empty auth list error mismatch: have %v, want %v", err, ErrEmptyAuthList)
+	}
+	auths := []types.SetCodeAuthorization{auth, auth, auth, auth}
+	if err := pool.addRemoteSync(setCodeTx(0, auths, key)); !errors.Is(err, ErrIntrinsicGas) {
+		t.Fatalf("intrinsic gas error mismatch: have %v, want %v", err, ErrIntrinsicGas)
+	}
+	if err := pool.addRemoteSync(setCodeTx(0, []types.SetCodeAuthorization{auth}, key)); err != nil {
+		t.Fatalf("failed to add set code tx: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that if transactions start being capped, transactions are also removed from 'all'
 func TestTransactionCapClearsFromAll(t *testing.T) {
 	t.Parallel()
@@ -2123,6 +2361,468 @@ func TestTransactionReplacement(t *testing.T) {
 	}
 }
 
+// Tests that tagged transactions report their pool status and the reason they
+// were dropped.
+func TestTransactionTags(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	pending := pricedTransaction(0, 100000, big.NewInt(1), key)
+	queued := pricedTransaction(2, 100000, big.NewInt(1), key)
+	if err := pool.addRemoteSync(pending); err != nil {
+		t.Fatalf("failed to add pending transaction: %v", err)
+	}
+	if err := pool.addRemoteSync(queued); err != nil {
+		t.Fatalf("failed to add queued transaction: %v", err)
+	}
+	pool.TagTx(pending.Hash(), "tag")
+	pool.TagTx(queued.Hash(), "tag")
+	pool.TagTx(common.Hash{1}, "tag") // unknown, ignored
+
+	txs := pool.TaggedTxs("tag")
+	if len(txs) != 2 {
+		t.Fatalf("tagged transaction count mismatch: have %d, want 2", len(txs))
+	}
+	if txs[0].Hash != pending.Hash() || txs[0].Status != TxStatusPending {
+		t.Errorf("pending transaction mismatch: have %x/%v", txs[0].Hash, txs[0].Status)
+	}
+	if txs[1].Hash != queued.Hash() || txs[1].Status != TxStatusQueued {
+		t.Errorf("queued transaction mismatch: have %x/%v", txs[1].Hash, txs[1].Status)
+	}
+	// Replace the pending transaction and ensure the reason is reported
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(2), key)); err != nil {
+		t.Fatalf("failed to replace pending transaction: %v", err)
+	}
+	txs = pool.TaggedTxs("tag")
+	if txs[0].Status != TxStatusUnknown || txs[0].DropReason != TxDropReplaced {
+		t.Errorf("replaced transaction mismatch: have %v/%q, want %v/%q", txs[0].Status, txs[0].DropReason, TxStatusUnknown, TxDropReplaced)
+	}
+	if txs[1].Status != TxStatusQueued || txs[1].DropReason != "" {
+		t.Errorf("queued transaction mismatch: have %v/%q", txs[1].Status, txs[1].DropReason)
+	}
+	if txs := pool.TaggedTxs("other"); len(txs) != 0 {
+		t.Errorf("unexpected transactions for unknown tag: %d", len(txs))
+	}
+}
+
+// Tests that the transactions of each origin are limited by the origin quotas,
+// which may be changed at runtime.
+func TestTransactionOriginQuotas(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	config := pool.Config()
+	config.Quotas.P2P = 2
+	pool.SetLimits(config)
+
+	for i := uint64(0); i < 2; i++ {
+		if err := pool.addRemoteSync(pricedTransaction(i, 100000, big.NewInt(1), key)); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", i, err)
+		}
+	}
+	if err := pool.addRemoteSync(pricedTransaction(2, 100000, big.NewInt(1), key)); !errors.Is(err, ErrOriginQuota) {
+		t.Fatalf("quota error mismatch: have %v, want %v", err, ErrOriginQuota)
+	}
+	// Replacements and other origins are not affected
+	if err := pool.addRemoteSync(pricedTransaction(1, 100000, big.NewInt(2), key)); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	if err := pool.AddRPC(pricedTransaction(2, 100000, big.NewInt(1), key)); err != nil {
+		t.Fatalf("failed to add RPC transaction: %v", err)
+	}
+	if have := pool.all.OriginCount(TxOriginP2P); have != 2 {
+		t.Fatalf("p2p transaction count mismatch: have %d, want 2", have)
+	}
+	if have := pool.all.OriginCount(TxOriginRPC); have != 1 {
+		t.Fatalf("rpc transaction count mismatch: have %d, want 1", have)
+	}
+	// Lifting the quota admits transactions again
+	config.Quotas.P2P = 0
+	pool.SetLimits(config)
+	if err := pool.addRemoteSync(pricedTransaction(3, 100000, big.NewInt(1), key)); err != nil {
+		t.Fatalf("failed to add transaction after lifting the quota: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
+// Tests that validation hooks and the denylist reject transactions entering the
+// pool, with access to the sender and the origin.
+func TestTransactionValidationHooks(t *testing.T) {
+	t.Parallel()
+
+	var (
+		key, _ = crypto.GenerateKey()
+		from   = crypto.PubkeyToAddress(key.PublicKey)
+		denied = common.Address{0xde}
+		config = testTxPoolConfig
+	)
+	config.Denylist = []common.Address{denied}
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{10000000, statedb, new(event.Feed)}
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+	<-pool.initDoneCh
+
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	errLarge := errors.New("calldata too large")
+	pool.AddValidationHook(TxValidationHookFunc(func(tx *types.Transaction, ctx *TxValidationContext) error {
+		if ctx.From != from || ctx.State == nil {
+			t.Errorf("hook context mismatch: from %x", ctx.From)
+		}
+		if ctx.Origin == TxOriginP2P && len(tx.Data()) > 8 {
+			return errLarge
+		}
+		return nil
+	}))
+	if err := pool.addRemoteSync(pricedDataTransaction(0, 100000, big.NewInt(1), key, 9)); !errors.Is(err, errLarge) {
+		t.Fatalf("hook error mismatch: have %v, want %v", err, errLarge)
+	}
+	if err := pool.AddRPC(pricedDataTransaction(0, 100000, big.NewInt(1), key, 9)); err != nil {
+		t.Fatalf("failed to add RPC transaction: %v", err)
+	}
+	tx, _ := types.SignTx(types.NewTransaction(1, denied, big.NewInt(1), 100000, big.NewInt(1), nil), types.HomesteadSigner{}, key)
+	if err := pool.addRemoteSync(tx); !errors.Is(err, ErrDenylisted) {
+		t.Fatalf("denylist error mismatch: have %v, want %v", err, ErrDenylisted)
+	}
+}
+
+// Tests that dropped transactions are announced with the reason of the drop and
+// remembered by the pool.
+func TestTransactionDropEvents(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	drops := make(chan DropTxsEvent, 8)
+	sub := pool.SubscribeDropTxsEvent(drops)
+	defer sub.Unsubscribe()
+
+	expect := func(tx *types.Transaction, reason string) {
+		t.Helper()
+		select {
+		case ev := <-drops:
+			if len(ev.Txs) != 1 || ev.Txs[0].Tx.Hash() != tx.Hash() || ev.Txs[0].Reason != reason {
+				t.Fatalf("drop event mismatch: have %v, want %x/%q", ev.Txs, tx.Hash(), reason)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no drop event for %x", tx.Hash())
+		}
+		if drop := pool.Dropped(tx.Hash()); drop == nil || drop.Reason != reason {
+			t.Fatalf("remembered drop mismatch: have %v, want %q", drop, reason)
+		}
+	}
+	// Replace a transaction and drop the replacement once its nonce is used up
+	tx0 := pricedTransaction(0, 100000, big.NewInt(1), key)
+	tx1 := pricedTransaction(0, 100000, big.NewInt(2), key)
+	if err := pool.addRemoteSync(tx0); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	if err := pool.addRemoteSync(tx1); err != nil {
+		t.Fatalf("failed to replace transaction: %v", err)
+	}
+	expect(tx0, TxDropReplaced)
+	if drop := pool.Dropped(tx1.Hash()); drop != nil {
+		t.Fatalf("pooled transaction reported dropped: %v", drop)
+	}
+	testSetNonce(pool, from, 1)
+	<-pool.requestReset(nil, nil)
+	expect(tx1, TxDropNonceTooLow)
+
+	// Transactions affordable but for the L1 fee are told apart
+	tx := pricedTransaction(1, 100000, big.NewInt(1), key)
+	if reason := pool.unaffordableReason(from, tx); reason != TxDropL1FeeInsufficient {
+		t.Errorf("L1 fee drop reason mismatch: have %q, want %q", reason, TxDropL1FeeInsufficient)
+	}
+	tx = pricedTransaction(1, 100000, big.NewInt(1000000), key)
+	if reason := pool.unaffordableReason(from, tx); reason != TxDropUnaffordable {
+		t.Errorf("unaffordable drop reason mismatch: have %q, want %q", reason, TxDropUnaffordable)
+	}
+}
+
+// minedTestChain is a test chain whose blocks all contain the same transactions.
+type minedTestChain struct {
+	*testBlockChain
+	block *types.Block
+}
+
+func (bc *minedTestChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return bc.block
+}
+
+// Tests that transactions leaving the pool because they were included are not
+// announced as dropped, unlike the ones whose nonce was used up by another.
+func TestTransactionMinedNotDropped(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	chain := &minedTestChain{testBlockChain: &testBlockChain{10000000, statedb, new(event.Feed)}}
+	pool := NewTxPool(testTxPoolConfig, params.TestChainConfig, chain)
+	defer pool.Stop()
+	<-pool.initDoneCh
+
+	mined, _ := crypto.GenerateKey()
+	replaced, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(mined.PublicKey), big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(replaced.PublicKey), big.NewInt(1000000000))
+
+	included := transaction(0, 100000, mined)
+	outdated := transaction(0, 100000, replaced)
+	if err := pool.AddRemotesSync([]*types.Transaction{included, outdated})[0]; err != nil {
+		t.Fatalf("failed to add transactions: %v", err)
+	}
+	drops := make(chan DropTxsEvent, 8)
+	sub := pool.SubscribeDropTxsEvent(drops)
+	defer sub.Unsubscribe()
+
+	parent := &types.Header{Number: big.NewInt(0), GasLimit: 10000000, BaseFee: big.NewInt(params.InitialBaseFee)}
+	head := &types.Header{Number: big.NewInt(1), ParentHash: parent.Hash(), GasLimit: 10000000, BaseFee: big.NewInt(params.InitialBaseFee)}
+	chain.block = types.NewBlock(head, []*types.Transaction{included}, nil, nil, trie.NewStackTrie(nil))
+
+	testSetNonce(pool, crypto.PubkeyToAddress(mined.PublicKey), 1)
+	testSetNonce(pool, crypto.PubkeyToAddress(replaced.PublicKey), 1)
+	<-pool.requestReset(parent, chain.block.Header())
+
+	select {
+	case ev := <-drops:
+		if len(ev.Txs) != 1 || ev.Txs[0].Tx.Hash() != outdated.Hash() || ev.Txs[0].Reason != TxDropNonceTooLow {
+			t.Fatalf("drop event mismatch: have %v, want %x/%q", ev.Txs, outdated.Hash(), TxDropNonceTooLow)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no drop event for the outdated transaction")
+	}
+	if pool.Get(included.Hash()) != nil {
+		t.Fatal("included transaction still pooled")
+	}
+	if drop := pool.Dropped(included.Hash()); drop != nil {
+		t.Fatalf("included transaction reported dropped: %v", drop)
+	}
+}
+
+// reorgTestChain is a test chain serving its blocks by hash.
+type reorgTestChain struct {
+	*testBlockChain
+	blocks map[common.Hash]*types.Block
+}
+
+func (bc *reorgTestChain) GetBlock(hash common.Hash, number uint64) *types.Block {
+	return bc.blocks[hash]
+}
+
+// Tests that transactions reorged out of the chain are reinjected under the
+// origin they originally entered the pool from, not counting towards the quota
+// of gossiped transactions.
+func TestTransactionReorgKeepsOrigin(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	chain := &reorgTestChain{testBlockChain: &testBlockChain{10000000, statedb, new(event.Feed)}, blocks: make(map[common.Hash]*types.Block)}
+	config := testTxPoolConfig
+	config.Quotas = TxOriginQuotas{P2P: 1}
+	pool := NewTxPool(config, params.TestChainConfig, chain)
+	defer pool.Stop()
+	<-pool.initDoneCh
+
+	submitter, _ := crypto.GenerateKey()
+	peer, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(submitter.PublicKey), big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(peer.PublicKey), big.NewInt(1000000000))
+
+	submitted := transaction(0, 100000, submitter)
+	if err := pool.AddRPC(submitted); err != nil {
+		t.Fatalf("failed to add submitted transaction: %v", err)
+	}
+	if err := pool.AddRemotesSync([]*types.Transaction{transaction(0, 100000, peer)})[0]; err != nil {
+		t.Fatalf("failed to add gossiped transaction: %v", err)
+	}
+	// Include the submitted transaction, then reorg it out again
+	newBlock := func(parent *types.Header, extra string, txs []*types.Transaction) *types.Block {
+		header := &types.Header{GasLimit: 10000000, BaseFee: big.NewInt(params.InitialBaseFee), Extra: []byte(extra)}
+		if parent != nil {
+			header.Number, header.ParentHash = new(big.Int).Add(parent.Number, common.Big1), parent.Hash()
+		} else {
+			header.Number = new(big.Int)
+		}
+		block := types.NewBlock(header, txs, nil, nil, trie.NewStackTrie(nil))
+		chain.blocks[block.Hash()] = block
+		return block
+	}
+	genesis := newBlock(nil, "", nil)
+	mined := newBlock(genesis.Header(), "mined", []*types.Transaction{submitted})
+	reorged := newBlock(genesis.Header(), "reorged", nil)
+
+	testSetNonce(pool, crypto.PubkeyToAddress(submitter.PublicKey), 1)
+	<-pool.requestReset(genesis.Header(), mined.Header())
+	if pool.Get(submitted.Hash()) != nil {
+		t.Fatal("included transaction still pooled")
+	}
+	testSetNonce(pool, crypto.PubkeyToAddress(submitter.PublicKey), 0)
+	<-pool.requestReset(mined.Header(), reorged.Header())
+
+	if pool.Get(submitted.Hash()) == nil {
+		t.Fatal("reorged transaction not reinjected")
+	}
+	if origin, ok := pool.all.Origin(submitted.Hash()); !ok || origin != TxOriginRPC {
+		t.Fatalf("reinjected origin mismatch: have %v, want %v", origin, TxOriginRPC)
+	}
+	if len(pool.origins) != 0 {
+		t.Fatalf("reinjected origins retained: %d", len(pool.origins))
+	}
+}
+
+// Tests that conditional transactions are only admitted while their conditions
+// hold and dropped once they fail.
+func TestTransactionConditional(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	var (
+		addr     = common.HexToAddress("0xaaaa")
+		slot     = common.Hash{1}
+		value    = common.Hash{2}
+		minBlock = big.NewInt(5)
+	)
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+	pool.mu.Lock()
+	pool.currentState.SetState(addr, slot, value)
+	pool.mu.Unlock()
+
+	// Conditions failing for the next block are rejected with the failed condition
+	var condErr *ConditionError
+	stale := &TxConditional{KnownAccounts: map[common.Address]KnownAccount{addr: {Storage: map[common.Hash]common.Hash{slot: {3}}}}}
+	err := pool.AddRemoteConditional(transaction(0, 100000, key), stale)
+	if !errors.Is(err, ErrConditionNotMet) || !errors.As(err, &condErr) || condErr.Condition != "storage" || *condErr.Slot != slot {
+		t.Fatalf("stale storage error mismatch: have %v", err)
+	}
+	expired := &TxConditional{BlockNumberMax: big.NewInt(0)}
+	if err := pool.AddRemoteConditional(transaction(0, 100000, key), expired); !errors.As(err, &condErr) || condErr.Condition != "blockNumberMax" {
+		t.Fatalf("expired block error mismatch: have %v", err)
+	}
+	costly := &TxConditional{KnownAccounts: make(map[common.Address]KnownAccount)}
+	for i := 0; i <= maxConditionalCost; i++ {
+		costly.KnownAccounts[common.BigToAddress(big.NewInt(int64(i)))] = KnownAccount{}
+	}
+	if err := pool.AddRemoteConditional(transaction(0, 100000, key), costly); !errors.Is(err, ErrConditionTooCostly) {
+		t.Fatalf("costly conditions error mismatch: have %v, want %v", err, ErrConditionTooCostly)
+	}
+	// Lower bounds not reached yet don't prevent admission
+	tx := transaction(0, 100000, key)
+	cond := &TxConditional{
+		BlockNumberMin: minBlock,
+		KnownAccounts:  map[common.Address]KnownAccount{addr: {Storage: map[common.Hash]common.Hash{slot: value}}},
+	}
+	if err := pool.AddRemoteConditional(tx, cond); err != nil {
+		t.Fatalf("failed to add conditional transaction: %v", err)
+	}
+	if pool.Conditional(tx.Hash()) != cond {
+		t.Fatalf("conditions of pooled transaction not tracked")
+	}
+	if err := cond.Check(big.NewInt(1), 0, pool.currentState); !errors.As(err, &condErr) || condErr.Condition != "blockNumberMin" {
+		t.Fatalf("early block error mismatch: have %v", err)
+	}
+	if err := cond.Check(minBlock, 0, pool.currentState); err != nil {
+		t.Fatalf("conditions not met at minimum block: %v", err)
+	}
+	// Changing the known state drops the transaction on the next reset
+	pool.mu.Lock()
+	pool.currentState.SetState(addr, slot, common.Hash{})
+	pool.mu.Unlock()
+	<-pool.requestReset(nil, nil)
+
+	if pool.Get(tx.Hash()) != nil || pool.Conditional(tx.Hash()) != nil {
+		t.Fatalf("transaction with unmet conditions not dropped")
+	}
+}
+
+// Tests that the audit log records the pool decisions and can be replayed into
+// the pool contents at a given block.
+func TestTransactionAuditReplay(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{1000000, statedb, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.AuditLog = filepath.Join(t.TempDir(), "audit.jsonl")
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	key, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	var (
+		original    = pricedTransaction(0, 100000, big.NewInt(1), key)
+		replacement = pricedTransaction(0, 100000, big.NewInt(2), key)
+		gapped      = pricedTransaction(2, 100000, big.NewInt(1), key)
+		filler      = pricedTransaction(1, 100000, big.NewInt(1), key)
+	)
+	for _, tx := range []*types.Transaction{original, replacement, gapped} {
+		if err := pool.addRemoteSync(tx); err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	if err := pool.addRemoteSync(pricedTransaction(0, 100001, big.NewInt(2), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("underpriced replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.addRemoteSync(filler); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	pool.Stop()
+
+	// Simulate the next block including only the first transaction
+	file, err := os.OpenFile(config.AuditLog, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	json.NewEncoder(file).Encode(&TxPoolAuditEntry{Time: time.Now(), Kind: TxAuditHead, Number: 1, Included: []common.Hash{replacement.Hash()}})
+	file.Close()
+
+	file, err = os.Open(config.AuditLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	snapshot, err := ReplayTxPoolAudit(file, 1)
+	if err != nil {
+		t.Fatalf("failed to replay audit log: %v", err)
+	}
+	var pending []common.Hash
+	for _, tx := range snapshot.Pending {
+		pending = append(pending, tx.Hash)
+	}
+	if want := []common.Hash{replacement.Hash(), gapped.Hash(), filler.Hash()}; !reflect.DeepEqual(pending, want) {
+		t.Errorf("pending transactions mismatch: have %x, want %x", pending, want)
+	}
+	if len(snapshot.Queued) != 0 {
+		t.Errorf("queued transaction count mismatch: have %d, want 0", len(snapshot.Queued))
+	}
+	if want := []common.Hash{gapped.Hash(), filler.Hash()}; !reflect.DeepEqual(snapshot.Skipped, want) {
+		t.Errorf("skipped transactions mismatch: have %x, want %x", snapshot.Skipped, want)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReplayTxPoolAudit(file, 2); err == nil {
+		t.Errorf("replay of unknown block succeeded")
+	}
+}
+
 // Tests that the pool rejects replacement dynamic fee transactions that don't
 // meet the minimum price bump required.
 func TestTransactionReplacementDynamicFee(t *testing.T) {
@@ -2233,6 +2933,58 @@ func TestTransactionReplacementDynamicFee(t *testing.T) {
 	}
 }
 
+// Tests that the replacement policy can require an absolute minimum bump, allow
+// replacements of identical fees and be overridden at runtime.
+func TestTransactionReplacementPolicy(t *testing.T) {
+	t.Parallel()
+
+	config := testTxPoolConfig
+	config.PriceBumpMin = 50
+	config.PriceBumpSameFee = true
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{1000000, statedb, new(event.Feed)}
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	key, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000000000))
+
+	// The absolute minimum exceeds the percentage bump for cheap transactions
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(100), key)); err != nil {
+		t.Fatalf("failed to add original transaction: %v", err)
+	}
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(149), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("replacement below minimum bump error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(150), key)); err != nil {
+		t.Fatalf("failed to replace with minimum bump: %v", err)
+	}
+	// A different transaction of identical fees replaces as well
+	if err := pool.addRemoteSync(pricedTransaction(0, 100001, big.NewInt(150), key)); err != nil {
+		t.Fatalf("failed to replace with identical fees: %v", err)
+	}
+	if pending, _ := pool.Stats(); pending != 1 {
+		t.Fatalf("pending transactions mismatched: have %d, want %d", pending, 1)
+	}
+	// Overriding the policy takes effect immediately, and reverts on nil
+	pool.SetReplacementPolicy(PriceBumpPolicy{Percent: 100})
+	if err := pool.addRemoteSync(pricedTransaction(0, 100002, big.NewInt(150), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("identical fee replacement error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(299), key)); err != ErrReplaceUnderpriced {
+		t.Fatalf("replacement below overridden bump error mismatch: have %v, want %v", err, ErrReplaceUnderpriced)
+	}
+	pool.SetReplacementPolicy(nil)
+	if err := pool.addRemoteSync(pricedTransaction(0, 100000, big.NewInt(200), key)); err != nil {
+		t.Fatalf("failed to replace after reverting policy: %v", err)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that local transactions are journaled to disk, but remote transactions
 // get discarded between restarts.
 func TestTransactionJournaling(t *testing.T)         { testTransactionJournaling(t, false) }
@@ -2346,6 +3098,56 @@ func testTransactionJournaling(t *testing.T, nolocals bool) {
 	pool.Stop()
 }
 
+// Tests that pending remote transactions are journaled on shutdown if enabled,
+// the best paying ones up to the cap, and revalidated when restored.
+func TestTransactionRemoteJournaling(t *testing.T) {
+	t.Parallel()
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{1000000, statedb, new(event.Feed)}
+
+	config := testTxPoolConfig
+	config.RemoteJournal = filepath.Join(t.TempDir(), "remotes.rlp")
+	config.RemoteJournalCap = 3
+
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+
+	cheap, _ := crypto.GenerateKey()
+	costly, _ := crypto.GenerateKey()
+	testAddBalance(pool, crypto.PubkeyToAddress(cheap.PublicKey), big.NewInt(1000000000))
+	testAddBalance(pool, crypto.PubkeyToAddress(costly.PublicKey), big.NewInt(1000000000))
+
+	txs := []*types.Transaction{
+		pricedTransaction(0, 100000, big.NewInt(1), cheap),
+		pricedTransaction(1, 100000, big.NewInt(1), cheap),
+		pricedTransaction(0, 100000, big.NewInt(2), costly),
+		pricedTransaction(1, 100000, big.NewInt(2), costly),
+	}
+	for _, err := range pool.AddRemotesSync(txs) {
+		if err != nil {
+			t.Fatalf("failed to add remote transaction: %v", err)
+		}
+	}
+	pool.Stop()
+
+	// Only the costly transactions and the first cheap one were journaled, mine
+	// the first costly one and restart
+	statedb.SetNonce(crypto.PubkeyToAddress(costly.PublicKey), 1)
+	blockchain = &testBlockChain{1000000, statedb, new(event.Feed)}
+
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	<-pool.requestPromoteExecutables(newAccountSet(pool.signer))
+	pending, queued := pool.Stats()
+	if pending != 2 || queued != 0 {
+		t.Fatalf("restored pool content mismatch: have %d/%d, want 2/0", pending, queued)
+	}
+	if pool.Get(txs[2].Hash()) != nil || pool.Get(txs[3].Hash()) == nil || pool.Get(txs[0].Hash()) == nil {
+		t.Fatalf("restored transactions mismatch")
+	}
+}
+
 // TestTransactionStatusCheck tests that the pool can correctly retrieve the
 // pending status of individual transactions.
 func TestTransactionStatusCheck(t *testing.T) {
@@ -2560,3 +3362,241 @@ func BenchmarkPoolMultiAccountBatchInsert(b *testing.B) {
 		pool.AddRemotesSync([]*types.Transaction{tx})
 	}
 }
+
+// Tests that the operator can evict transactions and temporarily ban senders,
+// and that the bans survive a restart of the pool.
+func TestTransactionEvictAndBan(t *testing.T) {
+	t.Parallel()
+
+	var (
+		key, _ = crypto.GenerateKey()
+		from   = crypto.PubkeyToAddress(key.PublicKey)
+		config = testTxPoolConfig
+	)
+	config.Bans = filepath.Join(t.TempDir(), "bans.json")
+
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{10000000, statedb, new(event.Feed)}
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	<-pool.initDoneCh
+
+	testAddBalance(pool, from, big.NewInt(1000000000))
+	txs := []*types.Transaction{transaction(0, 100000, key), transaction(1, 100000, key), transaction(3, 100000, key)}
+	for _, err := range pool.AddRemotesSync(txs) {
+		if err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	// Evict the first transaction, the following one is moved back to the queue
+	if evicted := pool.Evict([]common.Hash{txs[0].Hash(), {0x01}}); len(evicted) != 1 || evicted[0] != txs[0].Hash() {
+		t.Fatalf("evicted transactions mismatch: have %v, want [%x]", evicted, txs[0].Hash())
+	}
+	if pending, queued := pool.Stats(); pending != 0 || queued != 2 {
+		t.Fatalf("pool content mismatch after eviction: have %d/%d, want 0/2", pending, queued)
+	}
+	if drop := pool.Dropped(txs[0].Hash()); drop == nil || drop.Reason != TxDropEvicted {
+		t.Fatalf("eviction not remembered: %v", drop)
+	}
+	// Ban the sender, removing the rest of its transactions
+	evicted, err := pool.BanSender(from, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to ban sender: %v", err)
+	}
+	if len(evicted) != 2 || pool.all.Count() != 0 {
+		t.Fatalf("banned sender transactions not evicted: %d evicted, %d left", len(evicted), pool.all.Count())
+	}
+	if err := pool.addRemoteSync(txs[0]); !errors.Is(err, ErrSenderBanned) {
+		t.Fatalf("ban error mismatch: have %v, want %v", err, ErrSenderBanned)
+	}
+	pool.Stop()
+
+	// Restart the pool and check the ban is still in effect until lifted
+	pool = NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+	<-pool.initDoneCh
+
+	if _, ok := pool.Bans()[from]; !ok {
+		t.Fatalf("ban not restored")
+	}
+	if err := pool.addRemoteSync(txs[0]); !errors.Is(err, ErrSenderBanned) {
+		t.Fatalf("restored ban error mismatch: have %v, want %v", err, ErrSenderBanned)
+	}
+	if _, err := pool.BanSender(from, 0); err != nil {
+		t.Fatalf("failed to lift ban: %v", err)
+	}
+	if err := pool.addRemoteSync(txs[0]); err != nil {
+		t.Fatalf("failed to add transaction after lifting ban: %v", err)
+	}
+}
+
+// Tests that the time transactions spend in the pool is tracked for promotions,
+// inclusions and drops.
+func TestTransactionLatencies(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	from := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, from, big.NewInt(1000000000))
+
+	txs := []*types.Transaction{transaction(0, 100000, key), transaction(1, 100000, key), transaction(2, 100000, key)}
+	for _, err := range pool.AddRemotesSync(txs) {
+		if err != nil {
+			t.Fatalf("failed to add transaction: %v", err)
+		}
+	}
+	// Include the first transaction and evict the last one
+	pool.mu.Lock()
+	pool.currentState.SetNonce(from, 1)
+	pool.mu.Unlock()
+	<-pool.requestReset(nil, nil)
+	pool.Evict([]common.Hash{txs[2].Hash()})
+
+	latencies := pool.Latencies()
+	if have := latencies.Promotion.Count; have != 3 {
+		t.Errorf("promotion count mismatch: have %d, want 3", have)
+	}
+	if have := latencies.Inclusion.Count; have != 1 {
+		t.Errorf("inclusion count mismatch: have %d, want 1", have)
+	}
+	if have := latencies.Drop.Count; have != 1 {
+		t.Errorf("drop count mismatch: have %d, want 1", have)
+	}
+	if stats := latencies.Drop; stats.Samples != 1 || stats.Max <= 0 || stats.P50 != stats.Max {
+		t.Errorf("drop statistics mismatch: %+v", stats)
+	}
+}
+
+// Tests that the senders of a queue class are subject to the queue limits of
+// the class instead of the pool-wide ones.
+func TestTransactionQueueClasses(t *testing.T) {
+	t.Parallel()
+
+	var (
+		relayer, _ = crypto.GenerateKey()
+		anonymous  = make([]*ecdsa.PrivateKey, 3)
+		config     = testTxPoolConfig
+		policy     = filepath.Join(t.TempDir(), "policy.json")
+	)
+	config.AccountQueue = 2
+	config.GlobalQueue = 4
+	config.QueuePolicy = policy
+
+	blob := fmt.Sprintf(`[{"name": "relayers", "senders": ["%s"], "accountQueue": 8, "globalQueue": 8, "lifetime": "72h"}]`, crypto.PubkeyToAddress(relayer.PublicKey).Hex())
+	if err := os.WriteFile(policy, []byte(blob), 0644); err != nil {
+		t.Fatal(err)
+	}
+	statedb, _ := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	blockchain := &testBlockChain{1000000, statedb, new(event.Feed)}
+	pool := NewTxPool(config, params.TestChainConfig, blockchain)
+	defer pool.Stop()
+
+	classes := pool.QueueClasses()
+	if len(classes) != 1 || classes[0].Name != "relayers" || classes[0].Lifetime != 72*time.Hour {
+		t.Fatalf("queue classes mismatch: %+v", classes)
+	}
+	// Queue nonce gapped transactions of the relayer and the anonymous senders
+	testAddBalance(pool, crypto.PubkeyToAddress(relayer.PublicKey), big.NewInt(1000000000))
+	var txs []*types.Transaction
+	for i := uint64(1); i <= 6; i++ {
+		txs = append(txs, transaction(i, 100000, relayer))
+	}
+	for i := range anonymous {
+		anonymous[i], _ = crypto.GenerateKey()
+		testAddBalance(pool, crypto.PubkeyToAddress(anonymous[i].PublicKey), big.NewInt(1000000000))
+		for j := uint64(1); j <= 2; j++ {
+			txs = append(txs, transaction(j, 100000, anonymous[i]))
+		}
+	}
+	pool.AddRemotesSync(txs)
+
+	// The relayer exceeds the account and global queue limits on its own, while
+	// the anonymous senders are truncated to the global queue limit
+	if have := pool.queue[crypto.PubkeyToAddress(relayer.PublicKey)].Len(); have != 6 {
+		t.Fatalf("relayer queue mismatch: have %d, want 6", have)
+	}
+	if _, queued := pool.Stats(); queued != 6+int(config.GlobalQueue) {
+		t.Fatalf("queued transactions mismatch: have %d, want %d", queued, 6+config.GlobalQueue)
+	}
+	// Dropping the class subjects the relayer to the pool-wide limits again
+	if err := pool.SetQueueClasses(nil); err != nil {
+		t.Fatalf("failed to reset queue classes: %v", err)
+	}
+	if _, queued := pool.Stats(); queued != int(config.GlobalQueue) {
+		t.Fatalf("queued transactions mismatch after reset: have %d, want %d", queued, config.GlobalQueue)
+	}
+	// Senders may only be in a single class
+	addr := crypto.PubkeyToAddress(relayer.PublicKey)
+	if err := pool.SetQueueClasses([]TxQueueClass{{Name: "a", Senders: []common.Address{addr}}, {Name: "b", Senders: []common.Address{addr}}}); err == nil {
+		t.Fatalf("overlapping queue classes accepted")
+	}
+}
+
+// Tests that the pool content can be iterated by sender in address order from
+// any starting point, without losing track of the senders leaving the pool.
+func TestTransactionContentRange(t *testing.T) {
+	t.Parallel()
+
+	pool, _ := setupTxPool()
+	defer pool.Stop()
+
+	keys := make([]*ecdsa.PrivateKey, 5)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		testAddBalance(pool, crypto.PubkeyToAddress(keys[i].PublicKey), big.NewInt(1000000))
+	}
+	var txs types.Transactions
+	for _, key := range keys {
+		txs = append(txs, transaction(0, 100000, key), transaction(1, 100000, key), transaction(5, 100000, key))
+	}
+	pool.AddRemotesSync(txs)
+
+	collect := func(queued bool, start common.Address, limit int) []common.Address {
+		var addrs []common.Address
+		pool.ContentRange(queued, start, func(addr common.Address, txs types.Transactions) bool {
+			want := 2
+			if queued {
+				want = 1
+			}
+			if len(txs) != want {
+				t.Errorf("sender %x: transaction count mismatch: have %d, want %d", addr, len(txs), want)
+			}
+			addrs = append(addrs, addr)
+			return len(addrs) < limit
+		})
+		return addrs
+	}
+	all := collect(false, common.Address{}, len(keys)+1)
+	if len(all) != len(keys) {
+		t.Fatalf("pending sender count mismatch: have %d, want %d", len(all), len(keys))
+	}
+	for i := 1; i < len(all); i++ {
+		if bytes.Compare(all[i-1][:], all[i][:]) >= 0 {
+			t.Fatalf("senders not sorted: %x before %x", all[i-1], all[i])
+		}
+	}
+	if queued := collect(true, common.Address{}, len(keys)+1); !reflect.DeepEqual(queued, all) {
+		t.Errorf("queued senders mismatch: have %x, want %x", queued, all)
+	}
+	// Resume in the middle and stop early
+	if have := collect(false, all[2], 2); !reflect.DeepEqual(have, all[2:4]) {
+		t.Errorf("ranged senders mismatch: have %x, want %x", have, all[2:4])
+	}
+	// Drop a sender entirely and ensure it's no longer iterated
+	pool.mu.Lock()
+	for _, tx := range pool.all.remotes {
+		if from, _ := types.Sender(pool.signer, tx); from == all[1] {
+			pool.removeTx(tx.Hash(), true)
+		}
+	}
+	pool.mu.Unlock()
+
+	want := append([]common.Address{all[0]}, all[2:]...)
+	if have := collect(false, common.Address{}, len(keys)+1); !reflect.DeepEqual(have, want) {
+		t.Errorf("pending senders mismatch after removal: have %x, want %x", have, want)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
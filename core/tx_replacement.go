@@ -0,0 +1,65 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// ReplacementPolicy decides whether a transaction may replace a pooled one of
+// the same sender and nonce.
+type ReplacementPolicy interface {
+	Replaces(old, tx *types.Transaction) bool
+}
+
+// PriceBumpPolicy is the replacement policy of the pool configuration. The fee
+// cap and tip of a replacement must both exceed the old ones by a percentage,
+// and by an absolute minimum if set.
+type PriceBumpPolicy struct {
+	Percent uint64   // Minimum price bump percentage
+	Min     *big.Int // Minimum absolute price bump in wei (nil = no minimum)
+	SameFee bool     // Whether a different payload may replace at identical fees
+}
+
+// Replaces implements ReplacementPolicy.
+func (p PriceBumpPolicy) Replaces(old, tx *types.Transaction) bool {
+	if p.SameFee && old.GasFeeCapCmp(tx) == 0 && old.GasTipCapCmp(tx) == 0 {
+		return old.Hash() != tx.Hash()
+	}
+	if old.GasFeeCapCmp(tx) >= 0 || old.GasTipCapCmp(tx) >= 0 {
+		return false
+	}
+	// We have to ensure that both the new fee cap and tip are higher than the
+	// old ones as well as checking the percentage threshold to ensure that
+	// this is accurate for low (Wei-level) gas price replacements.
+	return tx.GasFeeCapIntCmp(p.threshold(old.GasFeeCap())) >= 0 && tx.GasTipCapIntCmp(p.threshold(old.GasTipCap())) >= 0
+}
+
+// threshold returns the minimum replacement price of an old one:
+// max(old * (100 + percent) / 100, old + min).
+func (p PriceBumpPolicy) threshold(old *big.Int) *big.Int {
+	threshold := new(big.Int).Mul(old, big.NewInt(100+int64(p.Percent)))
+	threshold.Div(threshold, big.NewInt(100))
+	if p.Min != nil {
+		if min := new(big.Int).Add(old, p.Min); min.Cmp(threshold) > 0 {
+			return min
+		}
+	}
+	return threshold
+}
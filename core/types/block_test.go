@@ -133,6 +133,38 @@ func TestEIP1559BlockEncoding(t *testing.T) {
 	}
 }
 
+// Tests that the withdrawals hash and blob gas fields of EIP-4844 headers survive
+// an encoding round trip, and that they are left out of legacy headers.
+func TestEIP4844HeaderEncoding(t *testing.T) {
+	used, excess := uint64(params.BlobTxBlobGasPerBlob), uint64(2*params.BlobTxBlobGasPerBlob)
+	tests := []*Header{
+		{Number: big.NewInt(1), Difficulty: big.NewInt(0), BaseFee: big.NewInt(params.InitialBaseFee)},
+		{Number: big.NewInt(1), Difficulty: big.NewInt(0), BaseFee: big.NewInt(params.InitialBaseFee), WithdrawalsHash: &EmptyRootHash, BlobGasUsed: &used, ExcessBlobGas: &excess},
+	}
+	for i, header := range tests {
+		enc, err := rlp.EncodeToBytes(header)
+		if err != nil {
+			t.Fatalf("test %d: failed to encode header: %v", i, err)
+		}
+		var dec Header
+		if err := rlp.DecodeBytes(enc, &dec); err != nil {
+			t.Fatalf("test %d: failed to decode header: %v", i, err)
+		}
+		if !reflect.DeepEqual(dec.WithdrawalsHash, header.WithdrawalsHash) {
+			t.Errorf("test %d: withdrawals hash mismatch: have %v, want %v", i, dec.WithdrawalsHash, header.WithdrawalsHash)
+		}
+		if !reflect.DeepEqual(dec.BlobGasUsed, header.BlobGasUsed) || !reflect.DeepEqual(dec.ExcessBlobGas, header.ExcessBlobGas) {
+			t.Errorf("test %d: blob gas mismatch: have %v/%v, want %v/%v", i, dec.BlobGasUsed, dec.ExcessBlobGas, header.BlobGasUsed, header.ExcessBlobGas)
+		}
+		if dec.Hash() != header.Hash() {
+			t.Errorf("test %d: hash mismatch: have %x, want %x", i, dec.Hash(), header.Hash())
+		}
+	}
+	if tests[0].Hash() == tests[1].Hash() {
+		t.Error("blob gas fields not covered by the header hash")
+	}
+}
+
 func TestEIP2718BlockEncoding(t *testing.T) {
 	blockEnc := common.FromHex("f90319f90211a00000000000000000000000000000000000000000000000000000000000000000a01dcc4de8dec75d7aab85b567b6ccd41ad312451b948a7413f0a142fd40d49347948888f1f195afa192cfee860698584c030f4c9db1a0ef1552a40b7165c3cd773806b9e0c165b75356e0314bf0706f279c729f51e017a0e6e49996c7ec59f7a23d22b83239a60151512c65613bf84a0d7da336399ebc4aa0cafe75574d59780665a97fbfd11365c7545aa8f1abf4e5e12e8243334ef7286bb901000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000083020000820200832fefd882a410845506eb0796636f6f6c65737420626c6f636b206f6e20636861696ea0bd4472abb6659ebe3ee06ee4d7b72a00a9f4d001caca51342001075469aff49888a13a5a8c8f2bb1c4f90101f85f800a82c35094095e7baea6a6c7c4c2dfeb977efac326af552d870a801ba09bea4c4daac7c7c52e093e6a4c35dbbcf8856f1af7b059ba20253e70848d094fa08a8fae537ce25ed8cb5af9adac3f141af69bd515bd2ba031522df09b97dd72b1b89e01f89b01800a8301e24194095e7baea6a6c7c4c2dfeb977efac326af552d878080f838f7940000000000000000000000000000000000000001e1a0000000000000000000000000000000000000000000000000000000000000000001a03dbacc8d0259f2508625e97fdfc57cd85fdd16e5821bc2c10bdd1a52649e8335a0476e10695b183a87b0aa292a7f4b78ef0c3fbe62aa2c42c84e1d9c3da159ef14c0")
 	var block Block
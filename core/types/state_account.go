@@ -32,3 +32,18 @@ type StateAccount struct {
 	Root     common.Hash // merkle root of the storage trie
 	CodeHash []byte
 }
+
+// AccountDiff is the change of an account by a block.
+type AccountDiff struct {
+	Address common.Address
+	Prev    *StateAccount `rlp:"nil"` // Nil if the account didn't exist before the block
+	Post    *StateAccount `rlp:"nil"` // Nil if the account was deleted by the block
+	Storage []SlotDiff
+}
+
+// SlotDiff is the change of a storage slot by a block.
+type SlotDiff struct {
+	Key  common.Hash
+	Prev common.Hash
+	Post common.Hash
+}
@@ -47,6 +47,8 @@ const (
 	LegacyTxType = iota
 	AccessListTxType
 	DynamicFeeTxType
+	BlobTxType
+	SetCodeTxType
 )
 
 // Transaction is an Ethereum transaction.
@@ -72,7 +74,8 @@ func NewTx(inner TxData) *Transaction {
 
 // TxData is the underlying data of a transaction.
 //
-// This is implemented by DynamicFeeTx, LegacyTx and AccessListTx.
+// This is implemented by DynamicFeeTx, LegacyTx, AccessListTx, BlobTx, SetCodeTx
+// and DepositTx.
 type TxData interface {
 	txType() byte // returns the type ID
 	copy() TxData // creates a deep copy and initializes all fields
@@ -189,6 +192,14 @@ func (tx *Transaction) decodeTyped(b []byte) (TxData, error) {
 		var inner DynamicFeeTx
 		err := rlp.DecodeBytes(b[1:], &inner)
 		return &inner, err
+	case BlobTxType:
+		var inner BlobTx
+		err := rlp.DecodeBytes(b[1:], &inner)
+		return &inner, err
+	case SetCodeTxType:
+		var inner SetCodeTx
+		err := rlp.DecodeBytes(b[1:], &inner)
+		return &inner, err
 	case DepositTxType:
 		var inner DepositTx
 		err := rlp.DecodeBytes(b[1:], &inner)
@@ -313,9 +324,48 @@ func (tx *Transaction) Mint() *big.Int {
 	return nil
 }
 
-// Cost returns gas * gasPrice + value.
+// BlobGas returns the blob gas limit of the transaction, zero if it isn't a
+// blob transaction.
+func (tx *Transaction) BlobGas() uint64 {
+	if blob, ok := tx.inner.(*BlobTx); ok {
+		return uint64(len(blob.BlobHashes)) * params.BlobTxBlobGasPerBlob
+	}
+	return 0
+}
+
+// BlobGasFeeCap returns the blob gas fee cap per blob gas of the transaction,
+// nil if it isn't a blob transaction.
+func (tx *Transaction) BlobGasFeeCap() *big.Int {
+	if blob, ok := tx.inner.(*BlobTx); ok {
+		return new(big.Int).Set(blob.BlobFeeCap)
+	}
+	return nil
+}
+
+// BlobHashes returns the versioned hashes of the blobs of the transaction, nil
+// if it isn't a blob transaction.
+func (tx *Transaction) BlobHashes() []common.Hash {
+	if blob, ok := tx.inner.(*BlobTx); ok {
+		return blob.BlobHashes
+	}
+	return nil
+}
+
+// SetCodeAuthorizations returns the authorizations of the transaction to set
+// the code of accounts, nil if it isn't a set code transaction.
+func (tx *Transaction) SetCodeAuthorizations() []SetCodeAuthorization {
+	if setcode, ok := tx.inner.(*SetCodeTx); ok {
+		return setcode.AuthList
+	}
+	return nil
+}
+
+// Cost returns gas * gasPrice + blobGas * blobGasFeeCap + value.
 func (tx *Transaction) Cost() *big.Int {
 	total := new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(tx.Gas()))
+	if blob, ok := tx.inner.(*BlobTx); ok {
+		total.Add(total, new(big.Int).Mul(blob.BlobFeeCap, new(big.Int).SetUint64(tx.BlobGas())))
+	}
 	total.Add(total, tx.Value())
 	return total
 }
@@ -428,6 +478,11 @@ func (tx *Transaction) Hash() common.Hash {
 	return h
 }
 
+// Time returns the time the transaction was first seen locally.
+func (tx *Transaction) Time() time.Time {
+	return tx.time
+}
+
 // Size returns the true RLP encoded storage size of the transaction, either by
 // encoding and returning it, or returning a previously cached value.
 func (tx *Transaction) Size() common.StorageSize {
@@ -525,10 +580,21 @@ type TxWithMinerFee struct {
 // miner gasTipCap if a base fee is provided.
 // Returns error in case of a negative effective miner gasTipCap.
 func NewTxWithMinerFee(tx *Transaction, baseFee *big.Int) (*TxWithMinerFee, error) {
+	return newTxWithMinerFee(tx, baseFee, nil)
+}
+
+// newTxWithMinerFee creates a wrapped transaction, deducting the L1 data cost
+// per unit of gas from the effective miner gasTipCap if a cost function is given.
+func newTxWithMinerFee(tx *Transaction, baseFee *big.Int, l1Cost func(*Transaction) *big.Int) (*TxWithMinerFee, error) {
 	minerFee, err := tx.EffectiveGasTip(baseFee)
 	if err != nil {
 		return nil, err
 	}
+	if l1Cost != nil && tx.Gas() > 0 {
+		if cost := l1Cost(tx); cost != nil {
+			minerFee.Sub(minerFee, new(big.Int).Div(cost, new(big.Int).SetUint64(tx.Gas())))
+		}
+	}
 	return &TxWithMinerFee{
 		tx:       tx,
 		minerFee: minerFee,
@@ -571,6 +637,7 @@ type TransactionsByPriceAndNonce struct {
 	heads   TxByPriceAndTime                // Next transaction for each unique account (price heap)
 	signer  Signer                          // Signer for the set of transactions
 	baseFee *big.Int                        // Current base fee
+	l1Cost  func(*Transaction) *big.Int     // L1 data cost of a transaction (nil = none)
 }
 
 // NewTransactionsByPriceAndNonce creates a transaction set that can retrieve
@@ -579,11 +646,23 @@ type TransactionsByPriceAndNonce struct {
 // Note, the input map is reowned so the caller should not interact any more with
 // if after providing it to the constructor.
 func NewTransactionsByPriceAndNonce(signer Signer, txs map[common.Address]Transactions, baseFee *big.Int) *TransactionsByPriceAndNonce {
+	return NewTransactionsByProfitAndNonce(signer, txs, baseFee, nil)
+}
+
+// NewTransactionsByProfitAndNonce creates a transaction set that can retrieve
+// transactions sorted by the profit they make the sequencer in a nonce-honouring
+// way: the effective tip minus the L1 data cost of the transaction per unit of
+// gas. Transactions with large calldata thus rank below ones paying the same tip
+// for less data.
+//
+// Note, the input map is reowned so the caller should not interact any more with
+// if after providing it to the constructor.
+func NewTransactionsByProfitAndNonce(signer Signer, txs map[common.Address]Transactions, baseFee *big.Int, l1Cost func(*Transaction) *big.Int) *TransactionsByPriceAndNonce {
 	// Initialize a price and received time based heap with the head transactions
 	heads := make(TxByPriceAndTime, 0, len(txs))
 	for from, accTxs := range txs {
 		acc, _ := Sender(signer, accTxs[0])
-		wrapped, err := NewTxWithMinerFee(accTxs[0], baseFee)
+		wrapped, err := newTxWithMinerFee(accTxs[0], baseFee, l1Cost)
 		// Remove transaction if sender doesn't match from, or if wrapping fails.
 		if acc != from || err != nil {
 			delete(txs, from)
@@ -600,6 +679,7 @@ func NewTransactionsByPriceAndNonce(signer Signer, txs map[common.Address]Transa
 		heads:   heads,
 		signer:  signer,
 		baseFee: baseFee,
+		l1Cost:  l1Cost,
 	}
 }
 
@@ -615,7 +695,7 @@ func (t *TransactionsByPriceAndNonce) Peek() *Transaction {
 func (t *TransactionsByPriceAndNonce) Shift() {
 	acc, _ := Sender(t.signer, t.heads[0].tx)
 	if txs, ok := t.txs[acc]; ok && len(txs) > 0 {
-		if wrapped, err := NewTxWithMinerFee(txs[0], t.baseFee); err == nil {
+		if wrapped, err := newTxWithMinerFee(txs[0], t.baseFee, t.l1Cost); err == nil {
 			t.heads[0], t.txs[acc] = wrapped, txs[1:]
 			heap.Fix(&t.heads, 0)
 			return
@@ -648,6 +728,11 @@ type Message struct {
 	isFake     bool
 	mint       *big.Int
 	l1CostGas  uint64
+
+	blobGasFeeCap *big.Int
+	blobHashes    []common.Hash
+
+	authList []SetCodeAuthorization
 }
 
 func NewMessage(from common.Address, to *common.Address, nonce uint64, amount *big.Int, gasLimit uint64, gasPrice, gasFeeCap, gasTipCap *big.Int, data []byte, accessList AccessList, isFake bool) Message {
@@ -682,6 +767,13 @@ func (tx *Transaction) AsMessage(s Signer, baseFee *big.Int) (Message, error) {
 		accessList: tx.AccessList(),
 		isFake:     false,
 	}
+	if blob, ok := tx.inner.(*BlobTx); ok {
+		msg.blobGasFeeCap = new(big.Int).Set(blob.BlobFeeCap)
+		msg.blobHashes = blob.BlobHashes
+	}
+	if setcode, ok := tx.inner.(*SetCodeTx); ok {
+		msg.authList = setcode.AuthList
+	}
 	if dep, ok := tx.inner.(*DepositTx); ok {
 		msg.mint = dep.Mint
 	} else {
@@ -696,19 +788,22 @@ func (tx *Transaction) AsMessage(s Signer, baseFee *big.Int) (Message, error) {
 	return msg, err
 }
 
-func (m Message) From() common.Address   { return m.from }
-func (m Message) To() *common.Address    { return m.to }
-func (m Message) GasPrice() *big.Int     { return m.gasPrice }
-func (m Message) GasFeeCap() *big.Int    { return m.gasFeeCap }
-func (m Message) GasTipCap() *big.Int    { return m.gasTipCap }
-func (m Message) Value() *big.Int        { return m.amount }
-func (m Message) Gas() uint64            { return m.gasLimit }
-func (m Message) Nonce() uint64          { return m.nonce }
-func (m Message) Data() []byte           { return m.data }
-func (m Message) AccessList() AccessList { return m.accessList }
-func (m Message) IsFake() bool           { return m.isFake }
-func (m Message) Mint() *big.Int         { return m.mint }
-func (m Message) RollupDataGas() uint64  { return m.l1CostGas }
+func (m Message) From() common.Address                          { return m.from }
+func (m Message) To() *common.Address                           { return m.to }
+func (m Message) GasPrice() *big.Int                            { return m.gasPrice }
+func (m Message) GasFeeCap() *big.Int                           { return m.gasFeeCap }
+func (m Message) GasTipCap() *big.Int                           { return m.gasTipCap }
+func (m Message) Value() *big.Int                               { return m.amount }
+func (m Message) Gas() uint64                                   { return m.gasLimit }
+func (m Message) Nonce() uint64                                 { return m.nonce }
+func (m Message) Data() []byte                                  { return m.data }
+func (m Message) AccessList() AccessList                        { return m.accessList }
+func (m Message) IsFake() bool                                  { return m.isFake }
+func (m Message) Mint() *big.Int                                { return m.mint }
+func (m Message) RollupDataGas() uint64                         { return m.l1CostGas }
+func (m Message) BlobGasFeeCap() *big.Int                       { return m.blobGasFeeCap }
+func (m Message) BlobHashes() []common.Hash                     { return m.blobHashes }
+func (m Message) SetCodeAuthorizations() []SetCodeAuthorization { return m.authList }
 
 // copyAddressPtr copies an address.
 func copyAddressPtr(a *common.Address) *common.Address {
@@ -29,6 +29,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -405,6 +406,42 @@ func TestTransactionTimeSort(t *testing.T) {
 	}
 }
 
+// Tests that the profit ordering ranks transactions by their tip minus their L1
+// data cost per unit of gas, instead of by the raw tip.
+func TestTransactionProfitSort(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 3)
+	for i := 0; i < len(keys); i++ {
+		keys[i], _ = crypto.GenerateKey()
+	}
+	signer := HomesteadSigner{}
+
+	// A high paying transaction with large calldata, and two lower paying ones
+	// without any
+	var (
+		groups = map[common.Address]Transactions{}
+		prices = []int64{10, 8, 6}
+		datas  = [][]byte{make([]byte, 1000), nil, nil}
+	)
+	for i, key := range keys {
+		tx, _ := SignTx(NewTransaction(0, common.Address{}, big.NewInt(100), 100000, big.NewInt(prices[i]), datas[i]), signer, key)
+		groups[crypto.PubkeyToAddress(key.PublicKey)] = Transactions{tx}
+	}
+	// Charge 500 wei per byte of calldata, 5 wei per unit of gas of the large one
+	l1Cost := func(tx *Transaction) *big.Int {
+		return big.NewInt(int64(len(tx.Data())) * 500)
+	}
+	txset := NewTransactionsByProfitAndNonce(signer, groups, nil, l1Cost)
+
+	var have []int64
+	for tx := txset.Peek(); tx != nil; tx = txset.Peek() {
+		have = append(have, tx.GasPrice().Int64())
+		txset.Shift()
+	}
+	if want := []int64{8, 6, 10}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("profit ordering mismatch: have %v, want %v", have, want)
+	}
+}
+
 // TestTransactionCoding tests serializing/de-serializing to/from rlp and JSON.
 func TestTransactionCoding(t *testing.T) {
 	key, err := crypto.GenerateKey()
@@ -492,6 +529,107 @@ func TestTransactionCoding(t *testing.T) {
 	}
 }
 
+func TestBlobTxCoding(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var (
+		signer = NewCancunSigner(common.Big1)
+		from   = crypto.PubkeyToAddress(key.PublicKey)
+		hashes = []common.Hash{{0x01, 0xaa}, {0x01, 0xbb}}
+	)
+	tx, err := SignNewTx(key, signer, &BlobTx{
+		ChainID:    big.NewInt(1),
+		Nonce:      1,
+		GasTipCap:  big.NewInt(1),
+		GasFeeCap:  big.NewInt(10),
+		Gas:        21000,
+		To:         common.HexToAddress("095e7baea6a6c7c4c2dfeb977efac326af552d87"),
+		BlobFeeCap: big.NewInt(3),
+		BlobHashes: hashes,
+	})
+	if err != nil {
+		t.Fatalf("could not sign transaction: %v", err)
+	}
+	if tx.BlobGas() != 2*params.BlobTxBlobGasPerBlob {
+		t.Fatalf("blob gas mismatch: have %d, want %d", tx.BlobGas(), 2*params.BlobTxBlobGasPerBlob)
+	}
+	for name, codec := range map[string]func(*Transaction) (*Transaction, error){"rlp": encodeDecodeBinary, "json": encodeDecodeJSON} {
+		parsed, err := codec(tx)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := assertEqual(parsed, tx); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if parsed.Type() != BlobTxType || parsed.BlobGasFeeCap().Cmp(tx.BlobGasFeeCap()) != 0 || !reflect.DeepEqual(parsed.BlobHashes(), hashes) {
+			t.Fatalf("%s: blob fields mismatch", name)
+		}
+		if sender, err := Sender(signer, parsed); err != nil || sender != from {
+			t.Fatalf("%s: sender mismatch: have %x (%v), want %x", name, sender, err, from)
+		}
+	}
+	// Signers predating blob transactions must reject them
+	if _, err := Sender(NewLondonSigner(common.Big1), tx); err != ErrTxTypeNotSupported {
+		t.Fatalf("london signer error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+}
+
+func TestSetCodeTxCoding(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	authKey, _ := crypto.GenerateKey()
+	var (
+		signer    = NewPragueSigner(common.Big1)
+		from      = crypto.PubkeyToAddress(key.PublicKey)
+		authority = crypto.PubkeyToAddress(authKey.PublicKey)
+		target    = common.HexToAddress("095e7baea6a6c7c4c2dfeb977efac326af552d87")
+	)
+	auth, err := SignSetCode(authKey, SetCodeAuthorization{
+		ChainID: big.NewInt(1),
+		Address: target,
+		Nonce:   5,
+	})
+	if err != nil {
+		t.Fatalf("could not sign authorization: %v", err)
+	}
+	if have, err := auth.Authority(); err != nil || have != authority {
+		t.Fatalf("authority mismatch: have %x (%v), want %x", have, err, authority)
+	}
+	tx, err := SignNewTx(key, signer, &SetCodeTx{
+		ChainID:   big.NewInt(1),
+		Nonce:     1,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(10),
+		Gas:       50000,
+		To:        authority,
+		AuthList:  []SetCodeAuthorization{auth},
+	})
+	if err != nil {
+		t.Fatalf("could not sign transaction: %v", err)
+	}
+	for name, codec := range map[string]func(*Transaction) (*Transaction, error){"rlp": encodeDecodeBinary, "json": encodeDecodeJSON} {
+		parsed, err := codec(tx)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := assertEqual(parsed, tx); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if parsed.Type() != SetCodeTxType || !reflect.DeepEqual(parsed.SetCodeAuthorizations(), tx.SetCodeAuthorizations()) {
+			t.Fatalf("%s: authorizations mismatch", name)
+		}
+		if sender, err := Sender(signer, parsed); err != nil || sender != from {
+			t.Fatalf("%s: sender mismatch: have %x (%v), want %x", name, sender, err, from)
+		}
+	}
+	// Signers predating set code transactions must reject them
+	if _, err := Sender(NewCancunSigner(common.Big1), tx); err != ErrTxTypeNotSupported {
+		t.Fatalf("cancun signer error mismatch: have %v, want %v", err, ErrTxTypeNotSupported)
+	}
+	// Delegations must round trip through their code representation
+	if addr, ok := ParseDelegation(AddressToDelegation(target)); !ok || addr != target {
+		t.Fatalf("delegation mismatch: have %x (%v), want %x", addr, ok, target)
+	}
+}
+
 func encodeDecodeJSON(tx *Transaction) (*Transaction, error) {
 	data, err := json.Marshal(tx)
 	if err != nil {
@@ -25,6 +25,7 @@ import (
 )
 
 var activators = map[int]func(*JumpTable){
+	7702: enable7702,
 	3855: enable3855,
 	3529: enable3529,
 	3198: enable3198,
@@ -192,3 +193,20 @@ func opPush0(pc *uint64, interpreter *EVMInterpreter, scope *ScopeContext) ([]by
 	scope.Stack.push(new(uint256.Int))
 	return nil, nil
 }
+
+// enable7702 applies EIP-7702 (set code transactions), charging calls for
+// accessing the delegation target of the called account.
+func enable7702(jt *JumpTable) {
+	// The operations are shared with the other jump tables, copy them before
+	// changing their gas functions
+	for op, gas := range map[OpCode]gasFunc{
+		CALL:         gasCallEIP7702,
+		CALLCODE:     gasCallCodeEIP7702,
+		STATICCALL:   gasStaticCallEIP7702,
+		DELEGATECALL: gasDelegateCallEIP7702,
+	} {
+		cpy := *jt[op]
+		cpy.dynamicGas = gas
+		jt[op] = &cpy
+	}
+}
@@ -90,6 +90,11 @@ func NewEVMInterpreter(evm *EVM, cfg Config) *EVMInterpreter {
 		default:
 			cfg.JumpTable = &frontierInstructionSet
 		}
+		if evm.chainRules.IsSetCodeTx {
+			copy := *cfg.JumpTable
+			enable7702(&copy)
+			cfg.JumpTable = &copy
+		}
 		for i, eip := range cfg.ExtraEips {
 			copy := *cfg.JumpTable
 			if err := EnableEIP(eip, &copy); err != nil {
@@ -21,6 +21,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -191,6 +192,48 @@ func makeCallVariantGasCallEIP2929(oldCalculator gasFunc) gasFunc {
 	}
 }
 
+// makeCallVariantGasCallEIP7702 charges for accessing the delegation target of
+// the called account on top of the EIP-2929 call gas.
+func makeCallVariantGasCallEIP7702(oldCalculator gasFunc) gasFunc {
+	return func(evm *EVM, contract *Contract, stack *Stack, mem *Memory, memorySize uint64) (uint64, error) {
+		addr := common.Address(stack.Back(1).Bytes20())
+
+		// Charge for the delegation target before calling the old calculator,
+		// so that it's accounted for in the available gas for the call
+		var delegationCost uint64
+		if target, ok := types.ParseDelegation(evm.StateDB.GetCode(addr)); ok {
+			if evm.StateDB.AddressInAccessList(target) {
+				delegationCost = params.WarmStorageReadCostEIP2929
+			} else {
+				evm.StateDB.AddAddressToAccessList(target)
+				delegationCost = params.ColdAccountAccessCostEIP2929
+			}
+			if !contract.UseGas(delegationCost) {
+				return 0, ErrOutOfGas
+			}
+		}
+		gas, err := oldCalculator(evm, contract, stack, mem, memorySize)
+		if delegationCost == 0 || err != nil {
+			return gas, err
+		}
+		// Add the charge back and return it as part of the dynamic gas, the same
+		// way as the cold access charge of EIP-2929
+		contract.Gas += delegationCost
+		var overflow bool
+		if gas, overflow = math.SafeAdd(gas, delegationCost); overflow {
+			return 0, ErrGasUintOverflow
+		}
+		return gas, nil
+	}
+}
+
+var (
+	gasCallEIP7702         = makeCallVariantGasCallEIP7702(gasCallEIP2929)
+	gasDelegateCallEIP7702 = makeCallVariantGasCallEIP7702(gasDelegateCallEIP2929)
+	gasStaticCallEIP7702   = makeCallVariantGasCallEIP7702(gasStaticCallEIP2929)
+	gasCallCodeEIP7702     = makeCallVariantGasCallEIP7702(gasCallCodeEIP2929)
+)
+
 var (
 	gasCallEIP2929         = makeCallVariantGasCallEIP2929(gasCall)
 	gasDelegateCallEIP2929 = makeCallVariantGasCallEIP2929(gasDelegateCall)
@@ -0,0 +1,164 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/time/rate"
+)
+
+var (
+	// errAccountRangeRate is returned if a client enumerated accounts faster
+	// than allowed.
+	errAccountRangeRate = errors.New("account range rate limit exceeded")
+
+	// errAccountRangeCursor is returned if a cursor isn't one handed out by
+	// debug_accountRange.
+	errAccountRangeCursor = errors.New("invalid account range cursor")
+
+	accountRangeServedMeter  = metrics.NewRegisteredMeter("eth/accountrange/served", nil)
+	accountRangeLimitedMeter = metrics.NewRegisteredMeter("eth/accountrange/limited", nil)
+)
+
+// AccountRangeConfig holds the optional settings of a debug_accountRange call.
+type AccountRangeConfig struct {
+	// Cursor resumes the enumeration where the previous page ended. The page is
+	// taken from the same state as the previous one, the block and the start
+	// key of the call are ignored.
+	Cursor hexutil.Bytes `json:"cursor"`
+
+	// NoRoot omits the storage roots of the accounts.
+	NoRoot bool `json:"noroot"`
+}
+
+// AccountRangeResult is the result of a debug_accountRange call.
+type AccountRangeResult struct {
+	state.IteratorDump
+
+	// Cursor retrieves the next page of the same state, it's empty after the
+	// last page and for the pending state, which isn't persisted.
+	Cursor hexutil.Bytes `json:"cursor,omitempty"`
+}
+
+// encodeAccountRangeCursor returns the cursor of the page of the given state
+// starting at the given account key.
+func encodeAccountRangeCursor(root common.Hash, next []byte) hexutil.Bytes {
+	cursor := make([]byte, 2*common.HashLength)
+	copy(cursor, root[:])
+	copy(cursor[common.HashLength:], next)
+	return cursor
+}
+
+// decodeAccountRangeCursor returns the state root and the account key a cursor
+// continues from.
+func decodeAccountRangeCursor(cursor []byte) (common.Hash, []byte, error) {
+	if len(cursor) != 2*common.HashLength {
+		return common.Hash{}, nil, errAccountRangeCursor
+	}
+	return common.BytesToHash(cursor[:common.HashLength]), cursor[common.HashLength:], nil
+}
+
+// accountRangeClient is the rate limiter of a single client.
+type accountRangeClient struct {
+	limiter *rate.Limiter
+	last    time.Time // Time of the last request
+}
+
+// accountRangeLimiter limits the accounts enumerated per second by each client,
+// told apart by their remote IP address. A client may enumerate a full page at
+// once, the allowance refilling at the configured rate.
+type accountRangeLimiter struct {
+	rate   rate.Limit
+	refill time.Duration // Time to refill the allowance of a client from empty
+
+	mu      sync.Mutex
+	pruned  time.Time
+	clients map[string]*accountRangeClient
+}
+
+// newAccountRangeLimiter creates a limiter allowing the given number of accounts
+// per second per client, or nil if the number is zero.
+func newAccountRangeLimiter(perSecond int) *accountRangeLimiter {
+	if perSecond <= 0 {
+		return nil
+	}
+	return &accountRangeLimiter{
+		rate:    rate.Limit(perSecond),
+		refill:  time.Duration(AccountRangeMaxResults) * time.Second / time.Duration(perSecond),
+		clients: make(map[string]*accountRangeClient),
+	}
+}
+
+// charge accounts the given number of accounts about to be enumerated for the
+// client, failing if it exceeds its allowance.
+func (l *accountRangeLimiter) charge(id string, accounts int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.pruned) >= l.refill {
+		// Forget the clients whose allowance refilled, they'd start anew anyway
+		for cid, c := range l.clients {
+			if now.Sub(c.last) >= l.refill {
+				delete(l.clients, cid)
+			}
+		}
+		l.pruned = now
+	}
+	c := l.clients[id]
+	if c == nil {
+		c = &accountRangeClient{limiter: rate.NewLimiter(l.rate, AccountRangeMaxResults)}
+		l.clients[id] = c
+	}
+	c.last = now
+
+	r := c.limiter.ReserveN(now, accounts)
+	if !r.OK() {
+		accountRangeLimitedMeter.Mark(1)
+		return fmt.Errorf("%w: page of %d accounts exceeds the allowance", errAccountRangeRate, accounts)
+	}
+	if delay := r.DelayFrom(now); delay > 0 {
+		r.CancelAt(now)
+		accountRangeLimitedMeter.Mark(1)
+		return fmt.Errorf("%w: %v accounts per second, retry in %v", errAccountRangeRate, l.rate, common.PrettyDuration(delay))
+	}
+	return nil
+}
+
+// accountRangeClientID identifies the RPC client of the request by its IP
+// address.
+func accountRangeClientID(ctx context.Context) string {
+	peer := rpc.PeerInfoFromContext(ctx)
+	if host, _, err := net.SplitHostPort(peer.RemoteAddr); err == nil {
+		return host
+	}
+	if peer.RemoteAddr != "" {
+		return peer.RemoteAddr
+	}
+	return peer.Transport
+}
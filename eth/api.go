@@ -25,6 +25,7 @@ import (
 	"math/big"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 	"time"
 
@@ -33,9 +34,13 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/stateless"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/ethereum/go-ethereum/trie"
@@ -71,6 +76,43 @@ func (api *EthereumAPI) Mining() bool {
 	return api.e.IsMining()
 }
 
+// ContractCreator is the transaction deploying a contract.
+type ContractCreator struct {
+	From             common.Address `json:"from"` // sender of the transaction, not necessarily the deploying contract
+	TransactionHash  common.Hash    `json:"transactionHash"`
+	TransactionIndex hexutil.Uint64 `json:"transactionIndex"`
+	BlockHash        common.Hash    `json:"blockHash"`
+	BlockNumber      hexutil.Uint64 `json:"blockNumber"`
+}
+
+// GetContractCreator returns the transaction which deployed the contract at the
+// given address, directly or through CREATE/CREATE2 of another contract. If the
+// contract was deployed repeatedly, the latest deployment is returned. Only
+// blocks executed by this node are indexed, it returns nil for contracts created
+// in blocks imported by snap sync.
+func (api *EthereumAPI) GetContractCreator(address common.Address) (*ContractCreator, error) {
+	creation := api.e.blockchain.GetContractCreation(address)
+	if creation == nil {
+		return nil, nil
+	}
+	block := api.e.blockchain.GetBlock(creation.BlockHash, creation.BlockNumber)
+	if block == nil || creation.TxIndex >= uint64(len(block.Transactions())) {
+		return nil, fmt.Errorf("block %#x of contract creation not found", creation.BlockHash)
+	}
+	tx := block.Transactions()[creation.TxIndex]
+	from, err := types.Sender(types.MakeSigner(api.e.blockchain.Config(), block.Number()), tx)
+	if err != nil {
+		return nil, err
+	}
+	return &ContractCreator{
+		From:             from,
+		TransactionHash:  creation.TxHash,
+		TransactionIndex: hexutil.Uint64(creation.TxIndex),
+		BlockHash:        creation.BlockHash,
+		BlockNumber:      hexutil.Uint64(creation.BlockNumber),
+	}, nil
+}
+
 // MinerAPI provides an API to control the miner.
 type MinerAPI struct {
 	e *Ethereum
@@ -134,6 +176,23 @@ func (api *MinerAPI) SetRecommitInterval(interval int) {
 	api.e.Miner().SetRecommitInterval(time.Duration(interval) * time.Millisecond)
 }
 
+// BuilderAPI exposes the decisions of the block builder for transparency
+// reports. It is only available on the authenticated endpoint.
+type BuilderAPI struct {
+	e *Ethereum
+}
+
+// NewBuilderAPI creates a new builder decision API.
+func NewBuilderAPI(e *Ethereum) *BuilderAPI {
+	return &BuilderAPI{e}
+}
+
+// GetBuilderDecisions returns why each candidate transaction of the payloads
+// built for the given block number was included, deferred or dropped.
+func (api *BuilderAPI) GetBuilderDecisions(number hexutil.Uint64) []*miner.PayloadDecisions {
+	return api.e.Miner().BuilderDecisions(uint64(number))
+}
+
 // AdminAPI is the collection of Ethereum full node related APIs for node
 // administration.
 type AdminAPI struct {
@@ -184,6 +243,172 @@ func (api *AdminAPI) ExportChain(file string, first *uint64, last *uint64) (bool
 	return true, nil
 }
 
+// AllowReorg permits the next forkchoice update to rewind up to the given number
+// of blocks, even if deeper than the configured maximum reorg depth.
+func (api *AdminAPI) AllowReorg(depth uint64) bool {
+	log.Warn("Deep reorg permitted by operator", "depth", depth)
+	api.eth.BlockChain().AllowReorg(depth)
+	return true
+}
+
+// SetTxPoolPriceBump changes the rule for replacing pooled transactions: the
+// fee cap and tip of a replacement must exceed the old ones by the percentage
+// and, if non-nil, by the absolute minimum in wei. With sameFee set, a different
+// transaction of identical fees may replace as well. The rule is kept across
+// configuration reloads.
+func (api *AdminAPI) SetTxPoolPriceBump(percent uint64, min *hexutil.Big, sameFee bool) bool {
+	policy := core.PriceBumpPolicy{Percent: percent, Min: (*big.Int)(min), SameFee: sameFee}
+	api.eth.TxPool().SetReplacementPolicy(policy)
+	log.Info("Transaction pool price bump updated", "percent", percent, "min", policy.Min, "sameFee", sameFee)
+	return true
+}
+
+// TxPoolLimits are the runtime adjustable limits of the transaction pool. Unset
+// fields keep their current value.
+type TxPoolLimits struct {
+	PriceLimit   *hexutil.Uint64 `json:"priceLimit,omitempty"`
+	AccountSlots *hexutil.Uint64 `json:"accountSlots,omitempty"`
+	GlobalSlots  *hexutil.Uint64 `json:"globalSlots,omitempty"`
+	AccountQueue *hexutil.Uint64 `json:"accountQueue,omitempty"`
+	GlobalQueue  *hexutil.Uint64 `json:"globalQueue,omitempty"`
+	Lifetime     *hexutil.Uint64 `json:"lifetime,omitempty"` // in seconds
+
+	// Maximum number of pooled transactions per origin, zero meaning unlimited
+	P2PQuota   *hexutil.Uint64 `json:"p2pQuota,omitempty"`
+	RPCQuota   *hexutil.Uint64 `json:"rpcQuota,omitempty"`
+	LocalQuota *hexutil.Uint64 `json:"localQuota,omitempty"`
+}
+
+// SetTxPoolLimits changes the given limits of the transaction pool and returns
+// all limits in effect afterwards. Transactions exceeding the new slot limits
+// are dropped right away, while lowered origin quotas only apply to incoming
+// transactions. The limits are reset by configuration reloads.
+func (api *AdminAPI) SetTxPoolLimits(limits TxPoolLimits) (*TxPoolLimits, error) {
+	if limits.Lifetime != nil && *limits.Lifetime == 0 {
+		return nil, errors.New("lifetime must be positive")
+	}
+	pool := api.eth.TxPool()
+	config := pool.Config()
+	set := func(field *uint64, value *hexutil.Uint64) {
+		if value != nil {
+			*field = uint64(*value)
+		}
+	}
+	set(&config.PriceLimit, limits.PriceLimit)
+	set(&config.AccountSlots, limits.AccountSlots)
+	set(&config.GlobalSlots, limits.GlobalSlots)
+	set(&config.AccountQueue, limits.AccountQueue)
+	set(&config.GlobalQueue, limits.GlobalQueue)
+	set(&config.Quotas.P2P, limits.P2PQuota)
+	set(&config.Quotas.RPC, limits.RPCQuota)
+	set(&config.Quotas.Local, limits.LocalQuota)
+	if limits.Lifetime != nil {
+		config.Lifetime = time.Duration(*limits.Lifetime) * time.Second
+	}
+	pool.SetLimits(config)
+
+	config = pool.Config()
+	log.Info("Transaction pool limits updated", "pricelimit", config.PriceLimit, "accountslots", config.AccountSlots,
+		"globalslots", config.GlobalSlots, "accountqueue", config.AccountQueue, "globalqueue", config.GlobalQueue,
+		"lifetime", config.Lifetime, "quotas", fmt.Sprintf("%+v", config.Quotas))
+
+	lifetime := uint64(config.Lifetime / time.Second)
+	return &TxPoolLimits{
+		PriceLimit:   (*hexutil.Uint64)(&config.PriceLimit),
+		AccountSlots: (*hexutil.Uint64)(&config.AccountSlots),
+		GlobalSlots:  (*hexutil.Uint64)(&config.GlobalSlots),
+		AccountQueue: (*hexutil.Uint64)(&config.AccountQueue),
+		GlobalQueue:  (*hexutil.Uint64)(&config.GlobalQueue),
+		Lifetime:     (*hexutil.Uint64)(&lifetime),
+		P2PQuota:     (*hexutil.Uint64)(&config.Quotas.P2P),
+		RPCQuota:     (*hexutil.Uint64)(&config.Quotas.RPC),
+		LocalQuota:   (*hexutil.Uint64)(&config.Quotas.Local),
+	}, nil
+}
+
+// TxQueueClass is a class of senders whose queued transactions are subject to
+// limits of their own. Zero limits fall back to the pool-wide ones.
+type TxQueueClass struct {
+	Name         string           `json:"name"`
+	Senders      []common.Address `json:"senders"`
+	AccountQueue hexutil.Uint64   `json:"accountQueue"`
+	GlobalQueue  hexutil.Uint64   `json:"globalQueue"`
+	Lifetime     hexutil.Uint64   `json:"lifetime"` // in seconds
+}
+
+// SetTxPoolQueueClasses replaces the sender classes with queue limits of their
+// own, e.g. to keep the transactions of relayers with large nonce gaps from
+// being evicted by the global queue limit. The classes are not saved to the
+// policy file.
+func (api *AdminAPI) SetTxPoolQueueClasses(classes []TxQueueClass) (bool, error) {
+	poolClasses := make([]core.TxQueueClass, len(classes))
+	for i, class := range classes {
+		poolClasses[i] = core.TxQueueClass{
+			Name:         class.Name,
+			Senders:      class.Senders,
+			AccountQueue: uint64(class.AccountQueue),
+			GlobalQueue:  uint64(class.GlobalQueue),
+			Lifetime:     time.Duration(class.Lifetime) * time.Second,
+		}
+	}
+	if err := api.eth.TxPool().SetQueueClasses(poolClasses); err != nil {
+		return false, err
+	}
+	log.Info("Transaction pool queue classes updated", "classes", len(classes))
+	return true, nil
+}
+
+// TxPoolQueueClasses returns the sender classes with queue limits of their own.
+func (api *AdminAPI) TxPoolQueueClasses() []TxQueueClass {
+	poolClasses := api.eth.TxPool().QueueClasses()
+	classes := make([]TxQueueClass, len(poolClasses))
+	for i, class := range poolClasses {
+		classes[i] = TxQueueClass{
+			Name:         class.Name,
+			Senders:      class.Senders,
+			AccountQueue: hexutil.Uint64(class.AccountQueue),
+			GlobalQueue:  hexutil.Uint64(class.GlobalQueue),
+			Lifetime:     hexutil.Uint64(class.Lifetime / time.Second),
+		}
+	}
+	return classes
+}
+
+// TxPoolAdminAPI is the collection of authenticated transaction pool APIs for
+// incident response.
+type TxPoolAdminAPI struct {
+	eth *Ethereum
+}
+
+// NewTxPoolAdminAPI creates a new transaction pool admin API.
+func NewTxPoolAdminAPI(eth *Ethereum) *TxPoolAdminAPI {
+	return &TxPoolAdminAPI{eth: eth}
+}
+
+// Evict removes the given transactions from the pool and returns the hashes of
+// the ones that were pooled.
+func (api *TxPoolAdminAPI) Evict(hashes []common.Hash) []common.Hash {
+	evicted := api.eth.TxPool().Evict(hashes)
+	log.Warn("Evicted transactions from the pool", "requested", len(hashes), "evicted", len(evicted))
+	return evicted
+}
+
+// BanSender rejects the transactions of the sender for the given duration in
+// seconds and removes its pooled ones, returning their hashes. The ban survives
+// node restarts, a zero duration lifts it. Durations beyond core.MaxBanDuration
+// are shortened to it.
+func (api *TxPoolAdminAPI) BanSender(addr common.Address, duration hexutil.Uint64) ([]common.Hash, error) {
+	if uint64(duration) > uint64(core.MaxBanDuration/time.Second) {
+		return api.eth.TxPool().BanSender(addr, core.MaxBanDuration)
+	}
+	return api.eth.TxPool().BanSender(addr, time.Duration(duration)*time.Second)
+}
+
+// Bans returns the senders banned from the pool and the end of their bans.
+func (api *TxPoolAdminAPI) Bans() map[common.Address]time.Time {
+	return api.eth.TxPool().Bans()
+}
+
 func hasAllBlocks(chain *core.BlockChain, bs []*types.Block) bool {
 	for _, b := range bs {
 		if !chain.HasBlock(b.Hash(), b.NumberU64()) {
@@ -246,12 +471,16 @@ func (api *AdminAPI) ImportChain(file string) (bool, error) {
 // DebugAPI is the collection of Ethereum full node APIs for debugging the
 // protocol.
 type DebugAPI struct {
-	eth *Ethereum
+	eth            *Ethereum
+	accountLimiter *accountRangeLimiter // Rate limit of debug_accountRange, nil if unlimited
 }
 
 // NewDebugAPI creates a new DebugAPI instance.
 func NewDebugAPI(eth *Ethereum) *DebugAPI {
-	return &DebugAPI{eth: eth}
+	return &DebugAPI{
+		eth:            eth,
+		accountLimiter: newAccountRangeLimiter(eth.config.RPCAccountRangeRate),
+	}
 }
 
 // DumpBlock retrieves the entire state of the database at a given block.
@@ -333,17 +562,30 @@ func (api *DebugAPI) GetBadBlocks(ctx context.Context) ([]*BadBlockArgs, error)
 // AccountRangeMaxResults is the maximum number of results to be returned per call
 const AccountRangeMaxResults = 256
 
-// AccountRange enumerates all accounts in the given block and start point in paging request
-func (api *DebugAPI) AccountRange(blockNrOrHash rpc.BlockNumberOrHash, start hexutil.Bytes, maxResults int, nocode, nostorage, incompletes bool) (state.IteratorDump, error) {
+// AccountRange enumerates all accounts in the given block and start point in paging request.
+// The returned cursor retrieves the next page from the same state, even if the
+// block was given by number or tag and the chain moved on meanwhile.
+func (api *DebugAPI) AccountRange(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, start hexutil.Bytes, maxResults int, nocode, nostorage, incompletes bool, config *AccountRangeConfig) (*AccountRangeResult, error) {
 	var stateDb *state.StateDB
 	var err error
+	var pending bool
 
-	if number, ok := blockNrOrHash.Number(); ok {
+	if config != nil && len(config.Cursor) > 0 {
+		var root common.Hash
+		if root, start, err = decodeAccountRangeCursor(config.Cursor); err != nil {
+			return nil, err
+		}
+		stateDb, err = api.eth.BlockChain().StateAt(root)
+		if err != nil {
+			return nil, fmt.Errorf("state %x of cursor unavailable: %v", root, err)
+		}
+	} else if number, ok := blockNrOrHash.Number(); ok {
 		if number == rpc.PendingBlockNumber {
 			// If we're dumping the pending state, we need to request
 			// both the pending block as well as the pending state from
 			// the miner and operate on those
 			_, stateDb = api.eth.miner.Pending()
+			pending = true
 		} else {
 			var block *types.Block
 			if number == rpc.LatestBlockNumber {
@@ -354,24 +596,24 @@ func (api *DebugAPI) AccountRange(blockNrOrHash rpc.BlockNumberOrHash, start hex
 				block = api.eth.blockchain.GetBlockByNumber(uint64(number))
 			}
 			if block == nil {
-				return state.IteratorDump{}, fmt.Errorf("block #%d not found", number)
+				return nil, fmt.Errorf("block #%d not found", number)
 			}
 			stateDb, err = api.eth.BlockChain().StateAt(block.Root())
 			if err != nil {
-				return state.IteratorDump{}, err
+				return nil, err
 			}
 		}
 	} else if hash, ok := blockNrOrHash.Hash(); ok {
 		block := api.eth.blockchain.GetBlockByHash(hash)
 		if block == nil {
-			return state.IteratorDump{}, fmt.Errorf("block %s not found", hash.Hex())
+			return nil, fmt.Errorf("block %s not found", hash.Hex())
 		}
 		stateDb, err = api.eth.BlockChain().StateAt(block.Root())
 		if err != nil {
-			return state.IteratorDump{}, err
+			return nil, err
 		}
 	} else {
-		return state.IteratorDump{}, errors.New("either block number or block hash must be specified")
+		return nil, errors.New("either block number or block hash must be specified")
 	}
 
 	opts := &state.DumpConfig{
@@ -384,7 +626,24 @@ func (api *DebugAPI) AccountRange(blockNrOrHash rpc.BlockNumberOrHash, start hex
 	if maxResults > AccountRangeMaxResults || maxResults <= 0 {
 		opts.Max = AccountRangeMaxResults
 	}
-	return stateDb.IteratorDump(opts), nil
+	if api.accountLimiter != nil {
+		if err := api.accountLimiter.charge(accountRangeClientID(ctx), int(opts.Max)); err != nil {
+			return nil, err
+		}
+	}
+	result := &AccountRangeResult{IteratorDump: stateDb.IteratorDump(opts)}
+	accountRangeServedMeter.Mark(int64(len(result.Accounts)))
+
+	if config != nil && config.NoRoot {
+		for addr, account := range result.Accounts {
+			account.Root = nil
+			result.Accounts[addr] = account
+		}
+	}
+	if result.Next != nil && !pending {
+		result.Cursor = encodeAccountRangeCursor(common.HexToHash(result.Root), result.Next)
+	}
+	return result, nil
 }
 
 // StorageRangeResult is the result of a debug_storageRangeAt API call.
@@ -419,28 +678,119 @@ func (api *DebugAPI) StorageRangeAt(blockHash common.Hash, txIndex int, contract
 }
 
 func storageRangeAt(st state.Trie, start []byte, maxResult int) (StorageRangeResult, error) {
-	it := trie.NewIterator(st.NodeIterator(start))
+	return newStorageRangeIterator(st, start).next(maxResult)
+}
+
+// storageRangeIterator pages through the slots of a storage trie.
+type storageRangeIterator struct {
+	st      state.Trie
+	it      *trie.Iterator
+	pending bool // Whether the iterator is positioned at a slot not returned yet
+}
+
+func newStorageRangeIterator(st state.Trie, start []byte) *storageRangeIterator {
+	return &storageRangeIterator{st: st, it: trie.NewIterator(st.NodeIterator(start))}
+}
+
+// next returns the following page of at most maxResult slots.
+func (r *storageRangeIterator) next(maxResult int) (StorageRangeResult, error) {
 	result := StorageRangeResult{Storage: storageMap{}}
-	for i := 0; i < maxResult && it.Next(); i++ {
-		_, content, _, err := rlp.Split(it.Value)
+	for len(result.Storage) < maxResult && (r.pending || r.it.Next()) {
+		r.pending = false
+
+		_, content, _, err := rlp.Split(r.it.Value)
 		if err != nil {
 			return StorageRangeResult{}, err
 		}
 		e := storageEntry{Value: common.BytesToHash(content)}
-		if preimage := st.GetKey(it.Key); preimage != nil {
+		if preimage := r.st.GetKey(r.it.Key); preimage != nil {
 			preimage := common.BytesToHash(preimage)
 			e.Key = &preimage
 		}
-		result.Storage[common.BytesToHash(it.Key)] = e
+		result.Storage[common.BytesToHash(r.it.Key)] = e
 	}
 	// Add the 'next key' so clients can continue downloading.
-	if it.Next() {
-		next := common.BytesToHash(it.Key)
+	if r.pending || r.it.Next() {
+		r.pending = true
+		next := common.BytesToHash(r.it.Key)
 		result.NextKey = &next
 	}
+	if r.it.Err != nil {
+		return StorageRangeResult{}, r.it.Err
+	}
 	return result, nil
 }
 
+// StorageRangePageMaxResults is the maximum number of slots per page streamed by
+// a streamStorageRangeAt subscription.
+const StorageRangePageMaxResults = 1024
+
+// StorageRangePage is a page of slots streamed by a streamStorageRangeAt
+// subscription.
+// If the iteration failed, the page carries the error and the key to resume from.
+type StorageRangePage struct {
+	StorageRangeResult
+	Error string `json:"error,omitempty"`
+}
+
+// StreamStorageRangeAt streams the storage at the given block height and
+// transaction index in pages of at most pageSize slots, starting at keyStart.
+// Every page holds the key to resume from in a new subscription, the last page
+// none. Pages are produced only as fast as the client reads them.
+func (api *DebugAPI) StreamStorageRangeAt(ctx context.Context, blockHash common.Hash, txIndex int, contractAddress common.Address, keyStart hexutil.Bytes, pageSize int) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	block := api.eth.blockchain.GetBlockByHash(blockHash)
+	if block == nil {
+		return &rpc.Subscription{}, fmt.Errorf("block %#x not found", blockHash)
+	}
+	_, _, statedb, err := api.eth.stateAtTransaction(block, txIndex, 0)
+	if err != nil {
+		return &rpc.Subscription{}, err
+	}
+	st := statedb.StorageTrie(contractAddress)
+	if st == nil {
+		return &rpc.Subscription{}, fmt.Errorf("account %x doesn't exist", contractAddress)
+	}
+	if pageSize > StorageRangePageMaxResults || pageSize <= 0 {
+		pageSize = StorageRangePageMaxResults
+	}
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		var (
+			ranges = newStorageRangeIterator(st, keyStart)
+			cursor = common.BytesToHash(keyStart)
+		)
+		for {
+			select {
+			case <-sub.Err():
+				return
+			default:
+			}
+			page, err := ranges.next(pageSize)
+			if err != nil {
+				log.Warn("Storage range streaming failed", "address", contractAddress, "cursor", cursor, "err", err)
+				notifier.NotifyWait(sub.ID, &StorageRangePage{
+					StorageRangeResult: StorageRangeResult{Storage: storageMap{}, NextKey: &cursor},
+					Error:              err.Error(),
+				})
+				return
+			}
+			if err := notifier.NotifyWait(sub.ID, &StorageRangePage{StorageRangeResult: page}); err != nil {
+				return
+			}
+			if page.NextKey == nil {
+				return
+			}
+			cursor = *page.NextKey
+		}
+	}()
+	return sub, nil
+}
+
 // GetModifiedAccountsByNumber returns all accounts that have changed between the
 // two blocks specified. A change is defined as a difference in nonce, balance,
 // code hash, or storage hash.
@@ -524,6 +874,104 @@ func (api *DebugAPI) getModifiedAccounts(startBlock, endBlock *types.Block) ([]c
 	return dirty, nil
 }
 
+// ProofRangeMaxResults is the maximum number of storage slots returned by a
+// debug_getProofRange call.
+const ProofRangeMaxResults = 1024
+
+// ProofRangeResult is the result of a debug_getProofRange API call.
+type ProofRangeResult struct {
+	StorageHash  common.Hash      `json:"storageHash"`
+	AccountProof []hexutil.Bytes  `json:"accountProof"`
+	Slots        []ProofRangeSlot `json:"slots"`
+	Proof        []hexutil.Bytes  `json:"proof"`   // Proof of the range boundaries, empty if the slots are the entire storage
+	NextKey      *common.Hash     `json:"nextKey"` // nil if Slots includes the last key in the trie
+}
+
+// ProofRangeSlot is a storage slot of a proven range.
+type ProofRangeSlot struct {
+	Hash  common.Hash  `json:"hash"`
+	Key   *common.Hash `json:"key"` // Preimage of the hash, nil if unknown
+	Value common.Hash  `json:"value"`
+}
+
+// GetProofRange returns a contiguous range of the storage slots of an account at
+// the given block, ordered by hash from the given start, along with the Merkle
+// proofs of the account and of the range boundaries. Like the snap protocol
+// storage ranges, the slots can be verified with trie.VerifyRangeProof.
+func (api *DebugAPI) GetProofRange(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash, start common.Hash, maxResults int) (*ProofRangeResult, error) {
+	statedb, _, err := api.eth.APIBackend.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if statedb == nil || err != nil {
+		return nil, err
+	}
+	st := statedb.StorageTrie(address)
+	if st == nil {
+		return nil, fmt.Errorf("account %x doesn't exist", address)
+	}
+	accountProof, err := statedb.GetProof(address)
+	if err != nil {
+		return nil, err
+	}
+	if maxResults > ProofRangeMaxResults || maxResults <= 0 {
+		maxResults = ProofRangeMaxResults
+	}
+	result, err := proveStorageRange(st, start, maxResults)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range accountProof {
+		result.AccountProof = append(result.AccountProof, node)
+	}
+	return result, nil
+}
+
+// proveStorageRange collects a range of storage slots along with the proofs of
+// its boundaries.
+func proveStorageRange(st state.Trie, start common.Hash, maxResults int) (*ProofRangeResult, error) {
+	result := &ProofRangeResult{
+		StorageHash:  st.Hash(),
+		AccountProof: []hexutil.Bytes{},
+		Slots:        []ProofRangeSlot{},
+		Proof:        []hexutil.Bytes{},
+	}
+	it := trie.NewIterator(st.NodeIterator(start[:]))
+	for len(result.Slots) < maxResults && it.Next() {
+		_, content, _, err := rlp.Split(it.Value)
+		if err != nil {
+			return nil, err
+		}
+		slot := ProofRangeSlot{Hash: common.BytesToHash(it.Key), Value: common.BytesToHash(content)}
+		if preimage := st.GetKey(it.Key); preimage != nil {
+			key := common.BytesToHash(preimage)
+			slot.Key = &key
+		}
+		result.Slots = append(result.Slots, slot)
+	}
+	if it.Next() {
+		next := common.BytesToHash(it.Key)
+		result.NextKey = &next
+	}
+	if it.Err != nil {
+		return nil, it.Err
+	}
+	// A range of the entire storage is verified by rebuilding the trie, without
+	// any proof. Otherwise prove the start and the last slot.
+	if start != (common.Hash{}) || result.NextKey != nil {
+		proof := light.NewNodeSet()
+		if err := st.Prove(start[:], 0, proof); err != nil {
+			return nil, err
+		}
+		if n := len(result.Slots); n > 0 {
+			if err := st.Prove(result.Slots[n-1].Hash[:], 0, proof); err != nil {
+				return nil, err
+			}
+		}
+		for _, node := range proof.NodeList() {
+			result.Proof = append(result.Proof, hexutil.Bytes(node))
+		}
+	}
+	return result, nil
+}
+
 // GetAccessibleState returns the first number where the node has accessible
 // state on disk. Note this being the post-state of that block and the pre-state
 // of the next block.
@@ -584,3 +1032,267 @@ func (api *DebugAPI) GetAccessibleState(from, to rpc.BlockNumber) (uint64, error
 	}
 	return 0, fmt.Errorf("No state found")
 }
+
+// CodeChange is a deployment or self-destruct of a contract.
+type CodeChange struct {
+	Type             string         `json:"type"` // "create" or "selfdestruct"
+	CodeHash         common.Hash    `json:"codeHash"`
+	TransactionHash  common.Hash    `json:"transactionHash"`
+	TransactionIndex hexutil.Uint64 `json:"transactionIndex"`
+	BlockHash        common.Hash    `json:"blockHash"`
+	BlockNumber      hexutil.Uint64 `json:"blockNumber"`
+}
+
+// GetCodeHistory returns the deployments and self-destructs of the contract at
+// the given address in chain order, revealing contracts re-created with different
+// code at the same address. Only blocks executed by this node are indexed.
+func (api *DebugAPI) GetCodeHistory(address common.Address) []CodeChange {
+	creations, destructions := api.eth.blockchain.GetContractHistory(address)
+
+	history := make([]CodeChange, 0, len(creations)+len(destructions))
+	for _, c := range creations {
+		history = append(history, CodeChange{
+			Type:             "create",
+			CodeHash:         c.CodeHash,
+			TransactionHash:  c.TxHash,
+			TransactionIndex: hexutil.Uint64(c.TxIndex),
+			BlockHash:        c.BlockHash,
+			BlockNumber:      hexutil.Uint64(c.BlockNumber),
+		})
+	}
+	for _, d := range destructions {
+		history = append(history, CodeChange{
+			Type:             "selfdestruct",
+			CodeHash:         d.CodeHash,
+			TransactionHash:  d.TxHash,
+			TransactionIndex: hexutil.Uint64(d.TxIndex),
+			BlockHash:        d.BlockHash,
+			BlockNumber:      hexutil.Uint64(d.BlockNumber),
+		})
+	}
+	// A contract self-destructing in its deploying transaction is created first.
+	sort.SliceStable(history, func(i, j int) bool {
+		if history[i].BlockNumber != history[j].BlockNumber {
+			return history[i].BlockNumber < history[j].BlockNumber
+		}
+		if history[i].TransactionIndex != history[j].TransactionIndex {
+			return history[i].TransactionIndex < history[j].TransactionIndex
+		}
+		return history[i].Type == "create" && history[j].Type != "create"
+	})
+	return history
+}
+
+// stateDiffReexec is the maximum number of blocks re-executed to regenerate the
+// parent state of a block whose state diff wasn't indexed.
+const stateDiffReexec = 128
+
+// AccountChange is the change of an account by a block.
+type AccountChange struct {
+	Address common.Address             `json:"address"`
+	Prev    *AccountState              `json:"prev"` // null if the account was created
+	Post    *AccountState              `json:"post"` // null if the account was deleted
+	Storage map[common.Hash]SlotChange `json:"storage,omitempty"`
+}
+
+// AccountState is the state of an account before or after a block, the code is
+// only included after the block if it was changed.
+type AccountState struct {
+	Balance  *hexutil.Big   `json:"balance"`
+	Nonce    hexutil.Uint64 `json:"nonce"`
+	CodeHash common.Hash    `json:"codeHash"`
+	Code     hexutil.Bytes  `json:"code,omitempty"`
+}
+
+// SlotChange is the change of a storage slot by a block.
+type SlotChange struct {
+	Prev common.Hash `json:"prev"`
+	Post common.Hash `json:"post"`
+}
+
+// GetStateDiff returns the accounts changed by the block with the given hash,
+// along with their modified storage slots, ordered by address. The changes are
+// read from the index if enabled, otherwise the block is re-executed.
+func (api *DebugAPI) GetStateDiff(hash common.Hash) ([]*AccountChange, error) {
+	block := api.eth.blockchain.GetBlockByHash(hash)
+	if block == nil {
+		return nil, fmt.Errorf("block %#x not found", hash)
+	}
+	diff := api.eth.blockchain.GetStateDiff(hash)
+	if diff == nil {
+		var err error
+		if diff, err = api.computeStateDiff(block); err != nil {
+			return nil, err
+		}
+	}
+	result := make([]*AccountChange, 0, len(diff))
+	for _, account := range diff {
+		entry := &AccountChange{Address: account.Address}
+		if account.Prev != nil {
+			entry.Prev = &AccountState{
+				Balance:  (*hexutil.Big)(account.Prev.Balance),
+				Nonce:    hexutil.Uint64(account.Prev.Nonce),
+				CodeHash: common.BytesToHash(account.Prev.CodeHash),
+			}
+		}
+		if account.Post != nil {
+			entry.Post = &AccountState{
+				Balance:  (*hexutil.Big)(account.Post.Balance),
+				Nonce:    hexutil.Uint64(account.Post.Nonce),
+				CodeHash: common.BytesToHash(account.Post.CodeHash),
+			}
+			if entry.Prev == nil || entry.Prev.CodeHash != entry.Post.CodeHash {
+				entry.Post.Code = rawdb.ReadCode(api.eth.ChainDb(), entry.Post.CodeHash)
+			}
+		}
+		if len(account.Storage) > 0 {
+			entry.Storage = make(map[common.Hash]SlotChange, len(account.Storage))
+			for _, slot := range account.Storage {
+				entry.Storage[slot.Key] = SlotChange{Prev: slot.Prev, Post: slot.Post}
+			}
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// computeStateDiff re-executes a block on top of its parent state to gather the
+// state changes.
+func (api *DebugAPI) computeStateDiff(block *types.Block) ([]*types.AccountDiff, error) {
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	parent := api.eth.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %#x not found", block.ParentHash())
+	}
+	statedb, err := api.eth.StateAtBlock(parent, stateDiffReexec, nil, true, false)
+	if err != nil {
+		return nil, err
+	}
+	statedb.TrackStateDiff()
+	if _, _, _, err := api.eth.blockchain.Processor().Process(block, statedb, vm.Config{}); err != nil {
+		return nil, fmt.Errorf("processing block %d failed: %v", block.NumberU64(), err)
+	}
+	statedb.IntermediateRoot(api.eth.blockchain.Config().IsEIP158(block.Number()))
+	return statedb.StateDiff(), nil
+}
+
+// ExecutionWitness returns the execution witness of a block: the trie nodes, the
+// contract codes and the ancestor headers needed to re-execute it without the
+// state database. Blocks whose witness wasn't recorded on import are re-executed
+// to generate it.
+func (api *DebugAPI) ExecutionWitness(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*stateless.Witness, error) {
+	block, err := api.eth.APIBackend.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, errors.New("block not found")
+	}
+	if witness := api.eth.blockchain.GetWitness(block.Hash()); witness != nil {
+		return witness, nil
+	}
+	return api.computeWitness(block)
+}
+
+// computeWitness re-executes a block on top of its parent state, recording the
+// state it accesses.
+func (api *DebugAPI) computeWitness(block *types.Block) (*stateless.Witness, error) {
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	parent := api.eth.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, fmt.Errorf("parent %#x not found", block.ParentHash())
+	}
+	statedb, err := api.eth.StateAtBlock(parent, stateDiffReexec, nil, true, false)
+	if err != nil {
+		return nil, err
+	}
+	witness, err := stateless.NewWitness(block.Header(), api.eth.blockchain)
+	if err != nil {
+		return nil, err
+	}
+	statedb.StartWitness(witness)
+	if _, _, _, err := api.eth.blockchain.Processor().Process(block, statedb, vm.Config{}); err != nil {
+		return nil, fmt.Errorf("processing block %d failed: %v", block.NumberU64(), err)
+	}
+	statedb.IntermediateRoot(api.eth.blockchain.Config().IsEIP158(block.Number()))
+	return witness, nil
+}
+
+// ExecuteStateless re-executes a block using only the state contained in the
+// given witness and checks the result against the block header, returning an
+// error if the witness is incomplete or the block is invalid.
+func (api *DebugAPI) ExecuteStateless(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, witness *stateless.Witness) error {
+	block, err := api.eth.APIBackend.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return err
+	}
+	if block == nil {
+		return errors.New("block not found")
+	}
+	if witness == nil || len(witness.Headers) == 0 {
+		return errors.New("missing witness")
+	}
+	return core.ExecuteStateless(api.eth.blockchain.Config(), api.eth.Engine(), vm.Config{}, block, witness)
+}
+
+// SetTrieFlushInterval configures after how much block processing time a whole
+// state trie is flushed to disk, e.g. "30m". A shorter interval shortens the
+// reprocessing after a crash at the cost of more disk writes.
+func (api *DebugAPI) SetTrieFlushInterval(interval string) error {
+	t, err := time.ParseDuration(interval)
+	if err != nil {
+		return err
+	}
+	if t < 0 {
+		return errors.New("negative trie flush interval")
+	}
+	api.eth.blockchain.SetTrieFlushInterval(t)
+	return nil
+}
+
+// SetTrieDirtyLimit configures the size of the dirty trie cache in megabytes at
+// which the oldest trie nodes are flushed to disk.
+func (api *DebugAPI) SetTrieDirtyLimit(limit int) error {
+	if limit < 0 {
+		return errors.New("negative trie dirty limit")
+	}
+	api.eth.blockchain.SetTrieDirtyLimit(limit)
+	return nil
+}
+
+// TrieFlushStatus is the usage of the dirty trie cache and the progress of its
+// flushing to disk, sizes being in bytes.
+type TrieFlushStatus struct {
+	Dirty          uint64         `json:"dirty"`
+	Preimages      uint64         `json:"preimages"`
+	DirtyLimit     uint64         `json:"dirtyLimit"`
+	FlushInterval  string         `json:"flushInterval"`
+	LastFlush      *time.Time     `json:"lastFlush,omitempty"`
+	LastFlushBlock hexutil.Uint64 `json:"lastFlushBlock"`
+	ReplayBlocks   hexutil.Uint64 `json:"replayBlocks"`
+	ReplayTime     string         `json:"replayTime"`
+}
+
+// TrieFlushStatus returns the usage of the dirty trie cache, the last flush of a
+// whole state trie and the blocks to reprocess, with their estimated processing
+// time, if the node crashed now.
+func (api *DebugAPI) TrieFlushStatus() *TrieFlushStatus {
+	status := api.eth.blockchain.TrieFlushStatus()
+	result := &TrieFlushStatus{
+		Dirty:          uint64(status.Dirty),
+		Preimages:      uint64(status.Preimages),
+		DirtyLimit:     uint64(status.DirtyLimit),
+		FlushInterval:  status.FlushInterval.String(),
+		LastFlushBlock: hexutil.Uint64(status.LastFlushBlock),
+		ReplayBlocks:   hexutil.Uint64(status.ReplayBlocks),
+		ReplayTime:     status.ReplayTime.String(),
+	}
+	if !status.LastFlush.IsZero() {
+		result.LastFlush = &status.LastFlush
+	}
+	return result
+}
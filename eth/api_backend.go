@@ -36,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -47,6 +48,7 @@ type EthAPIBackend struct {
 	allowUnprotectedTxs bool
 	eth                 *Ethereum
 	gpo                 *gasprice.Oracle
+	historical          *ethapi.HistoricalRPC
 }
 
 // ChainConfig returns the active chain configuration.
@@ -58,6 +60,14 @@ func (b *EthAPIBackend) CurrentBlock() *types.Block {
 	return b.eth.blockchain.CurrentBlock()
 }
 
+func (b *EthAPIBackend) CurrentSafeBlock() *types.Block {
+	return b.eth.blockchain.CurrentSafeBlock()
+}
+
+func (b *EthAPIBackend) CurrentFinalizedBlock() *types.Block {
+	return b.eth.blockchain.CurrentFinalizedBlock()
+}
+
 func (b *EthAPIBackend) SetHead(number uint64) {
 	b.eth.handler.downloader.Cancel()
 	b.eth.blockchain.SetHead(number)
@@ -231,6 +241,14 @@ func (b *EthAPIBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Sub
 	return b.eth.BlockChain().SubscribeChainEvent(ch)
 }
 
+func (b *EthAPIBackend) SubscribeChainSafeEvent(ch chan<- core.ChainSafeEvent) event.Subscription {
+	return b.eth.BlockChain().SubscribeChainSafeEvent(ch)
+}
+
+func (b *EthAPIBackend) SubscribeChainFinalizedEvent(ch chan<- core.ChainFinalizedEvent) event.Subscription {
+	return b.eth.BlockChain().SubscribeChainFinalizedEvent(ch)
+}
+
 func (b *EthAPIBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
 	return b.eth.BlockChain().SubscribeChainHeadEvent(ch)
 }
@@ -243,8 +261,28 @@ func (b *EthAPIBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscri
 	return b.eth.BlockChain().SubscribeLogsEvent(ch)
 }
 
+// errReadOnlyNode is returned when submitting transactions to a node sharing the
+// database of another one, which has no peers to forward them to.
+var errReadOnlyNode = errors.New("read-only node doesn't accept transactions")
+
 func (b *EthAPIBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
-	return b.eth.txPool.AddLocal(signedTx)
+	if b.eth.config.DatabaseReadOnly {
+		return errReadOnlyNode
+	}
+	if err := b.eth.txPool.AddRPC(signedTx); err != nil {
+		return err
+	}
+	if b.eth.txTracker != nil {
+		b.eth.txTracker.Track(signedTx)
+	}
+	return nil
+}
+
+func (b *EthAPIBackend) SendTxConditional(ctx context.Context, signedTx *types.Transaction, cond *core.TxConditional) error {
+	if b.eth.config.DatabaseReadOnly {
+		return errReadOnlyNode
+	}
+	return b.eth.txPool.AddRemoteConditional(signedTx, cond)
 }
 
 func (b *EthAPIBackend) GetPoolTransactions() (types.Transactions, error) {
@@ -281,6 +319,26 @@ func (b *EthAPIBackend) TxPoolContentFrom(addr common.Address) (types.Transactio
 	return b.eth.TxPool().ContentFrom(addr)
 }
 
+func (b *EthAPIBackend) TxPoolContentRange(queued bool, start common.Address, fn func(addr common.Address, txs types.Transactions) bool) {
+	b.eth.TxPool().ContentRange(queued, start, fn)
+}
+
+func (b *EthAPIBackend) TagTx(hash common.Hash, tag string) {
+	b.eth.TxPool().TagTx(hash, tag)
+}
+
+func (b *EthAPIBackend) TaggedTxs(tag string) []core.TaggedTx {
+	return b.eth.TxPool().TaggedTxs(tag)
+}
+
+func (b *EthAPIBackend) DroppedTx(hash common.Hash) *core.TxDrop {
+	return b.eth.TxPool().Dropped(hash)
+}
+
+func (b *EthAPIBackend) TxPoolLatencies() core.TxPoolLatencies {
+	return b.eth.TxPool().Latencies()
+}
+
 func (b *EthAPIBackend) TxPool() *core.TxPool {
 	return b.eth.TxPool()
 }
@@ -289,6 +347,10 @@ func (b *EthAPIBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.S
 	return b.eth.TxPool().SubscribeNewTxsEvent(ch)
 }
 
+func (b *EthAPIBackend) SubscribeDropTxsEvent(ch chan<- core.DropTxsEvent) event.Subscription {
+	return b.eth.TxPool().SubscribeDropTxsEvent(ch)
+}
+
 func (b *EthAPIBackend) SyncProgress() ethereum.SyncProgress {
 	return b.eth.Downloader().Progress()
 }
@@ -301,6 +363,15 @@ func (b *EthAPIBackend) FeeHistory(ctx context.Context, blockCount int, lastBloc
 	return b.gpo.FeeHistory(ctx, blockCount, lastBlock, rewardPercentiles)
 }
 
+func (b *EthAPIBackend) L1FeeHistory(ctx context.Context, oldest *big.Int, blocks int) (*gasprice.L1FeeHistory, error) {
+	return b.gpo.L1FeeHistory(ctx, oldest, blocks)
+}
+
+// SetGasPriceOracleConfig changes the sampling parameters of the gas price oracle.
+func (b *EthAPIBackend) SetGasPriceOracleConfig(config gasprice.Config) {
+	b.gpo.SetConfig(config)
+}
+
 func (b *EthAPIBackend) ChainDb() ethdb.Database {
 	return b.eth.ChainDb()
 }
@@ -321,6 +392,10 @@ func (b *EthAPIBackend) UnprotectedAllowed() bool {
 	return b.allowUnprotectedTxs
 }
 
+func (b *EthAPIBackend) HistoricalRPC() *ethapi.HistoricalRPC {
+	return b.historical
+}
+
 func (b *EthAPIBackend) RPCGasCap() uint64 {
 	return b.eth.config.RPCGasCap
 }
@@ -0,0 +1,228 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/leveldb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// errCompactionRunning is returned if a database compaction is requested while
+// another one is still running.
+var errCompactionRunning = errors.New("database compaction already running")
+
+// compactionWindow is a daily time window in UTC, in minutes since midnight. The
+// window wraps around midnight if it ends before it starts.
+type compactionWindow struct {
+	start, end int
+}
+
+// parseCompactionWindow parses a daily UTC time window in the "HH:MM-HH:MM"
+// format.
+func parseCompactionWindow(window string) (*compactionWindow, error) {
+	var sh, sm, eh, em int
+	if n, err := fmt.Sscanf(window, "%d:%d-%d:%d", &sh, &sm, &eh, &em); err != nil || n != 4 {
+		return nil, fmt.Errorf("invalid compaction window %q, want HH:MM-HH:MM", window)
+	}
+	for _, v := range [][2]int{{sh, sm}, {eh, em}} {
+		if v[0] < 0 || v[0] > 23 || v[1] < 0 || v[1] > 59 {
+			return nil, fmt.Errorf("invalid compaction window %q, want HH:MM-HH:MM", window)
+		}
+	}
+	w := &compactionWindow{start: sh*60 + sm, end: eh*60 + em}
+	if w.start == w.end {
+		return nil, fmt.Errorf("empty compaction window %q", window)
+	}
+	return w, nil
+}
+
+// contains returns whether the given time falls into the window.
+func (w *compactionWindow) contains(t time.Time) bool {
+	t = t.UTC()
+	minute := t.Hour()*60 + t.Minute()
+	if w.start < w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end
+}
+
+// dbCompactor schedules the heavy, full compactions of the chain database into
+// a daily time window and throttles the compactions running outside of it.
+// Full compactions, scheduled or requested by admin_compactNow, run at full
+// speed, one at a time.
+type dbCompactor struct {
+	db     ethdb.Database
+	rate   int               // Compaction rate limit outside the window in bytes/s (0 = unlimited)
+	window *compactionWindow // Daily window of the full compactions, nil if none
+
+	running bool // Whether a full compaction is running
+	lock    sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newDBCompactor creates a compactor of the given database, limiting the rate of
+// the compactions to rate MiB/s outside of the given daily window.
+func newDBCompactor(db ethdb.Database, rate int, window string) (*dbCompactor, error) {
+	c := &dbCompactor{
+		db:   db,
+		rate: rate * 1024 * 1024,
+		quit: make(chan struct{}),
+	}
+	if window != "" {
+		w, err := parseCompactionWindow(window)
+		if err != nil {
+			return nil, err
+		}
+		c.window = w
+	}
+	return c, nil
+}
+
+// start applies the compaction rate limit and, if a window is configured, starts
+// the scheduler of the full compactions.
+func (c *dbCompactor) start() {
+	c.lock.Lock()
+	c.throttle()
+	c.lock.Unlock()
+
+	if c.window == nil {
+		return
+	}
+	c.wg.Add(1)
+	go c.loop()
+}
+
+// loop triggers a full compaction whenever the window opens and lifts the rate
+// limit while it is open.
+func (c *dbCompactor) loop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	var open bool
+	for {
+		if now := c.window.contains(time.Now()); now != open {
+			open = now
+			if open {
+				log.Info("Database compaction window opened")
+				if err := c.compact(); err != nil {
+					log.Warn("Scheduled database compaction skipped", "err", err)
+				}
+			} else {
+				log.Info("Database compaction window closed")
+			}
+			c.lock.Lock()
+			c.throttle()
+			c.lock.Unlock()
+		}
+		select {
+		case <-ticker.C:
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// throttle applies the compaction rate limit, unless a full compaction is running
+// or the window is open. The caller must hold the lock.
+func (c *dbCompactor) throttle() {
+	if c.running || (c.window != nil && c.window.contains(time.Now())) {
+		leveldb.SetCompactionRate(0)
+		return
+	}
+	leveldb.SetCompactionRate(c.rate)
+}
+
+// compact starts a full compaction of the database in the background.
+func (c *dbCompactor) compact() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.running {
+		return errCompactionRunning
+	}
+	select {
+	case <-c.quit:
+		return errors.New("database closed")
+	default:
+	}
+	c.running = true
+	c.throttle()
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+
+		start := time.Now()
+		log.Info("Compacting chain database")
+
+		var err error
+		for b := 0; b < 256 && err == nil; b++ {
+			select {
+			case <-c.quit:
+				err = errors.New("database closed")
+				continue
+			default:
+			}
+			limit := []byte{byte(b + 1)}
+			if b == 255 {
+				limit = nil
+			}
+			err = c.db.Compact([]byte{byte(b)}, limit)
+		}
+		c.lock.Lock()
+		c.running = false
+		c.throttle()
+		c.lock.Unlock()
+
+		if err != nil {
+			log.Error("Database compaction failed", "elapsed", time.Since(start), "err", err)
+			return
+		}
+		log.Info("Compacted chain database", "elapsed", time.Since(start))
+	}()
+	return nil
+}
+
+// close aborts any running compaction between ranges and waits for it to
+// terminate.
+func (c *dbCompactor) close() {
+	c.lock.Lock()
+	select {
+	case <-c.quit:
+	default:
+		close(c.quit)
+	}
+	c.lock.Unlock()
+
+	c.wg.Wait()
+}
+
+// CompactNow starts a full compaction of the chain database in the background,
+// running at full speed regardless of the compaction rate limit.
+func (api *AdminAPI) CompactNow() error {
+	return api.eth.dbCompactor.compact()
+}
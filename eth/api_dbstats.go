@@ -0,0 +1,183 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// dbCacheMetrics are the metrics prefixes of the database caches whose hit
+// rates are reported, each having a hit and a miss meter.
+var dbCacheMetrics = []string{
+	"trie/memcache/clean",
+	"trie/memcache/dirty",
+	"state/snapshot/clean/account",
+	"state/snapshot/clean/storage",
+	"state/snapshot/dirty/account",
+	"state/snapshot/dirty/storage",
+}
+
+// errInspectionRunning is returned if a database inspection is requested while
+// another one is still running.
+var errInspectionRunning = errors.New("database inspection already running")
+
+// DBStats is the state of the chain database.
+type DBStats struct {
+	Ancients   []rawdb.AncientStat     `json:"ancients"`
+	Compaction string                  `json:"compaction"`
+	Caches     map[string]DBCacheStats `json:"caches"`
+	Inspection *DBInspection           `json:"inspection"`
+}
+
+// DBCacheStats are the hits and misses of a database cache since startup.
+type DBCacheStats struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hitRate"`
+}
+
+// DBInspection is the progress and the result of a full traversal of the chain
+// database.
+type DBInspection struct {
+	Running  bool                 `json:"running"`
+	Started  time.Time            `json:"started"`
+	Finished *time.Time           `json:"finished,omitempty"`
+	Stats    *rawdb.DatabaseStats `json:"stats,omitempty"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// dbInspector runs full traversals of the chain database in the background, one
+// at a time, retaining the result of the last one.
+type dbInspector struct {
+	db ethdb.Database
+
+	last *DBInspection // Last inspection started, nil if none
+	lock sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newDBInspector creates an idle inspector of the given database.
+func newDBInspector(db ethdb.Database) *dbInspector {
+	return &dbInspector{
+		db:   db,
+		quit: make(chan struct{}),
+	}
+}
+
+// inspect starts a full traversal of the database in the background.
+func (i *dbInspector) inspect() error {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.last != nil && i.last.Running {
+		return errInspectionRunning
+	}
+	select {
+	case <-i.quit:
+		return errors.New("database closed")
+	default:
+	}
+	i.last = &DBInspection{Running: true, Started: time.Now()}
+
+	i.wg.Add(1)
+	go func() {
+		defer i.wg.Done()
+
+		log.Info("Inspecting chain database")
+		stats, err := rawdb.CollectDatabaseStats(i.db, nil, nil, i.quit)
+
+		i.lock.Lock()
+		defer i.lock.Unlock()
+
+		finished := time.Now()
+		i.last = &DBInspection{Started: i.last.Started, Finished: &finished, Stats: stats}
+		if err != nil {
+			i.last.Error = err.Error()
+		}
+		log.Info("Inspected chain database", "elapsed", time.Since(i.last.Started), "err", err)
+	}()
+	return nil
+}
+
+// status returns a copy of the last inspection, nil if none was started.
+func (i *dbInspector) status() *DBInspection {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.last == nil {
+		return nil
+	}
+	status := *i.last
+	return &status
+}
+
+// close aborts any running inspection and waits for it to terminate.
+func (i *dbInspector) close() {
+	i.lock.Lock()
+	select {
+	case <-i.quit:
+	default:
+		close(i.quit)
+	}
+	i.lock.Unlock()
+
+	i.wg.Wait()
+}
+
+// DbStats returns the state of the chain database: the ancient tables, the
+// compaction statistics, the hit rates of the caches (with metrics enabled) and
+// the last full inspection started by debug_dbInspect.
+func (api *DebugAPI) DbStats() *DBStats {
+	db := api.eth.ChainDb()
+	stats := &DBStats{
+		Ancients:   rawdb.ReadAncientStats(db),
+		Caches:     make(map[string]DBCacheStats),
+		Inspection: api.eth.dbInspector.status(),
+	}
+	if compaction, err := db.Stat("leveldb.stats"); err == nil {
+		stats.Compaction = compaction
+	}
+	for _, name := range dbCacheMetrics {
+		hits, ok1 := metrics.Get(name + "/hit").(metrics.Meter)
+		misses, ok2 := metrics.Get(name + "/miss").(metrics.Meter)
+		if !ok1 || !ok2 {
+			continue
+		}
+		cache := DBCacheStats{Hits: hits.Count(), Misses: misses.Count()}
+		if total := cache.Hits + cache.Misses; total > 0 {
+			cache.HitRate = float64(cache.Hits) / float64(total)
+		}
+		stats.Caches[name] = cache
+	}
+	return stats
+}
+
+// DbInspect starts a full traversal of the chain database in the background,
+// gathering the sizes reported by `geth db inspect`. The progress and the result
+// are reported by debug_dbStats.
+func (api *DebugAPI) DbInspect() error {
+	return api.eth.dbInspector.inspect()
+}
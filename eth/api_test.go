@@ -18,17 +18,32 @@ package eth
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"math/big"
 	"reflect"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 var dumper = spew.ConfigState{Indent: "    "}
@@ -218,3 +233,401 @@ func TestStorageRangeAt(t *testing.T) {
 		}
 	}
 }
+
+func TestStorageRangeIterator(t *testing.T) {
+	t.Parallel()
+
+	var (
+		state, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		addr     = common.Address{0x01}
+	)
+	for i := byte(1); i <= 10; i++ {
+		state.SetState(addr, common.Hash{i}, common.Hash{i})
+	}
+	st := state.StorageTrie(addr)
+	want, err := storageRangeAt(st, nil, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Page through the storage and ensure the pages are contiguous
+	var (
+		ranges = newStorageRangeIterator(st, nil)
+		have   = storageMap{}
+	)
+	for pages := 1; ; pages++ {
+		page, err := ranges.next(3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for hash, entry := range page.Storage {
+			have[hash] = entry
+		}
+		if page.NextKey == nil {
+			if pages != 4 {
+				t.Fatalf("page count mismatch: have %d, want 4", pages)
+			}
+			break
+		}
+		if _, ok := want.Storage[*page.NextKey]; !ok || len(page.Storage) != 3 {
+			t.Fatalf("page %d: invalid continuation %x after %d slots", pages, *page.NextKey, len(page.Storage))
+		}
+		if _, ok := have[*page.NextKey]; ok {
+			t.Fatalf("page %d: continuation %x already returned", pages, *page.NextKey)
+		}
+	}
+	if !reflect.DeepEqual(have, want.Storage) {
+		t.Fatalf("paged storage mismatch:\nhave %s\nwant %s", dumper.Sdump(have), dumper.Sdump(want.Storage))
+	}
+}
+
+func TestProveStorageRange(t *testing.T) {
+	t.Parallel()
+
+	var (
+		state, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+		addr     = common.Address{0x01}
+	)
+	for i := byte(1); i <= 10; i++ {
+		state.SetState(addr, common.Hash{i}, common.Hash{0xff, i})
+	}
+	st := state.StorageTrie(addr)
+
+	// Retrieve the storage in pages, verifying every one against the root
+	var (
+		start common.Hash
+		slots int
+	)
+	for {
+		result, err := proveStorageRange(st, start, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.StorageHash != st.Hash() {
+			t.Fatalf("storage hash mismatch: have %x, want %x", result.StorageHash, st.Hash())
+		}
+		var (
+			keys   [][]byte
+			values [][]byte
+			proof  ethdb.KeyValueStore
+		)
+		for _, slot := range result.Slots {
+			if slot.Key == nil || state.GetState(addr, *slot.Key) != slot.Value {
+				t.Fatalf("wrong slot %x: %+v", slot.Hash, slot)
+			}
+			value, _ := rlp.EncodeToBytes(common.TrimLeftZeroes(slot.Value[:]))
+			keys, values = append(keys, common.CopyBytes(slot.Hash[:])), append(values, value)
+		}
+		if len(result.Proof) > 0 {
+			proof = memorydb.New()
+			for _, node := range result.Proof {
+				proof.Put(crypto.Keccak256(node), node)
+			}
+		} else if start != (common.Hash{}) || result.NextKey != nil {
+			t.Fatalf("missing boundary proof for range %x", start)
+		}
+		last := keys[len(keys)-1]
+		more, err := trie.VerifyRangeProof(result.StorageHash, start[:], last, keys, values, proof)
+		if err != nil {
+			t.Fatalf("range %x proof verification failed: %v", start, err)
+		}
+		if more != (result.NextKey != nil) {
+			t.Fatalf("range %x continuation mismatch: proof %v, next key %v", start, more, result.NextKey)
+		}
+		slots += len(result.Slots)
+		if result.NextKey == nil {
+			break
+		}
+		start = *result.NextKey
+	}
+	if slots != 10 {
+		t.Fatalf("slot count mismatch: have %d, want 10", slots)
+	}
+}
+
+// remoteStateService serves the methods used by debug_compareState from a state.
+type remoteStateService struct{ state *state.StateDB }
+
+func (s remoteStateService) AccountRange(blockNrOrHash rpc.BlockNumberOrHash, start hexutil.Bytes, maxResults int, nocode, nostorage, incompletes bool) (state.IteratorDump, error) {
+	return s.state.IteratorDump(&state.DumpConfig{
+		SkipCode:          nocode,
+		SkipStorage:       nostorage,
+		OnlyWithAddresses: !incompletes,
+		Start:             start,
+		Max:               uint64(maxResults),
+	}), nil
+}
+
+func (s remoteStateService) GetStorageAt(address common.Address, key common.Hash, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	return s.state.GetState(address, key).Bytes(), nil
+}
+
+func TestCompareState(t *testing.T) {
+	t.Parallel()
+
+	var (
+		same     = common.Address{0x01}
+		balance  = common.Address{0x02}
+		storage  = common.Address{0x03}
+		onlyHere = common.Address{0x04}
+		onlyThem = common.Address{0x05}
+	)
+	newState := func(remote bool) *state.StateDB {
+		db := state.NewDatabase(rawdb.NewMemoryDatabase())
+		statedb, _ := state.New(common.Hash{}, db, nil)
+		statedb.SetBalance(same, big.NewInt(1))
+		statedb.SetNonce(storage, 1)
+		statedb.SetState(storage, common.Hash{0x01}, common.Hash{0x01})
+		if remote {
+			statedb.SetBalance(balance, big.NewInt(3))
+			statedb.SetState(storage, common.Hash{0x02}, common.Hash{0x03})
+			statedb.SetNonce(onlyThem, 1)
+		} else {
+			statedb.SetBalance(balance, big.NewInt(2))
+			statedb.SetState(storage, common.Hash{0x02}, common.Hash{0x02})
+			statedb.SetNonce(onlyHere, 1)
+		}
+		root, _ := statedb.Commit(true)
+		statedb, _ = state.New(root, db, nil)
+		return statedb
+	}
+	local, remote := newState(false), newState(true)
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	server.RegisterName("debug", remoteStateService{remote})
+	server.RegisterName("eth", remoteStateService{remote})
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	result, err := compareState(context.Background(), client, local, common.Hash{}, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Compared != 5 || result.Next != nil {
+		t.Fatalf("wrong range: compared %d accounts, next %v", result.Compared, []byte(result.Next))
+	}
+	have := make(map[common.Address]AccountDiff)
+	for _, diff := range result.Accounts {
+		have[diff.Address] = diff
+	}
+	if len(have) != 4 {
+		t.Fatalf("have %d differing accounts, want 4", len(have))
+	}
+	if diff := have[balance]; !reflect.DeepEqual(diff.Fields, []string{"balance"}) {
+		t.Errorf("wrong balance diff: %v", diff.Fields)
+	}
+	if diff := have[onlyHere]; diff.Missing != "remote" {
+		t.Errorf("wrong diff of local-only account: %+v", diff)
+	}
+	if diff := have[onlyThem]; diff.Missing != "local" {
+		t.Errorf("wrong diff of remote-only account: %+v", diff)
+	}
+	want := []SlotDiff{{Key: common.Hash{0x02}, Local: common.Hash{0x02}, Remote: common.Hash{0x03}}}
+	if diff := have[storage]; !reflect.DeepEqual(diff.Slots, want) {
+		t.Errorf("wrong storage diff: %+v", diff.Slots)
+	}
+}
+
+func TestCheckComparePeer(t *testing.T) {
+	t.Parallel()
+
+	for _, endpoint := range []string{"http://localhost:8545", "https://node.example.com", "ws://127.0.0.1:8546", "wss://node.example.com/ws"} {
+		if err := CheckComparePeer(endpoint); err != nil {
+			t.Errorf("endpoint %q rejected: %v", endpoint, err)
+		}
+	}
+	for _, endpoint := range []string{"/tmp/geth.ipc", "file:///tmp/geth.ipc", "stdio", "http://", "ftp://node.example.com"} {
+		if err := CheckComparePeer(endpoint); err == nil {
+			t.Errorf("endpoint %q accepted", endpoint)
+		}
+	}
+	api := NewDebugAPI(&Ethereum{config: &ethconfig.Config{RPCComparePeers: []string{"http://localhost:8545", "/tmp/geth.ipc"}}})
+	if err := api.checkComparePeer("http://localhost:8545"); err != nil {
+		t.Errorf("configured peer rejected: %v", err)
+	}
+	if err := api.checkComparePeer("http://localhost:8546"); err == nil {
+		t.Error("unconfigured peer accepted")
+	}
+	if err := api.checkComparePeer("/tmp/geth.ipc"); err == nil {
+		t.Error("configured IPC peer accepted")
+	}
+}
+
+func TestCompactionWindow(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2022, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		window string
+		inside []time.Time
+		out    []time.Time
+	}{
+		{"02:00-05:30", []time.Time{at(2, 0), at(4, 59), at(5, 29)}, []time.Time{at(1, 59), at(5, 30), at(23, 0)}},
+		{"22:00-03:00", []time.Time{at(22, 0), at(23, 59), at(0, 0), at(2, 59)}, []time.Time{at(3, 0), at(12, 0), at(21, 59)}},
+	}
+	for _, tt := range tests {
+		w, err := parseCompactionWindow(tt.window)
+		if err != nil {
+			t.Fatalf("window %q: %v", tt.window, err)
+		}
+		for _, now := range tt.inside {
+			if !w.contains(now) {
+				t.Errorf("window %q: %v should be inside", tt.window, now)
+			}
+		}
+		for _, now := range tt.out {
+			if w.contains(now) {
+				t.Errorf("window %q: %v should be outside", tt.window, now)
+			}
+		}
+	}
+	for _, window := range []string{"", "2-5", "02:00-24:00", "02:60-03:00", "04:00-04:00"} {
+		if _, err := parseCompactionWindow(window); err == nil {
+			t.Errorf("window %q: expected error", window)
+		}
+	}
+}
+
+func TestAccountRangeCursor(t *testing.T) {
+	t.Parallel()
+
+	var (
+		key, _  = crypto.GenerateKey()
+		sender  = crypto.PubkeyToAddress(key.PublicKey)
+		funds   = big.NewInt(1000000000000000)
+		alloc   = core.GenesisAlloc{sender: {Balance: funds}}
+		db      = rawdb.NewMemoryDatabase()
+		gendb   = rawdb.NewMemoryDatabase()
+		signer  = types.LatestSigner(params.TestChainConfig)
+		genspec = &core.Genesis{Config: params.TestChainConfig, Alloc: alloc}
+	)
+	for i := 0; i < 9; i++ {
+		alloc[common.BigToAddress(big.NewInt(int64(i+1)))] = core.GenesisAccount{Balance: big.NewInt(1)}
+	}
+	genesis := genspec.MustCommit(db)
+	genspec.MustCommit(gendb)
+
+	preimages := make(map[common.Hash][]byte)
+	for addr := range alloc {
+		preimages[crypto.Keccak256Hash(addr[:])] = common.CopyBytes(addr[:])
+	}
+	rawdb.WritePreimages(db, preimages)
+
+	cacheConfig := &core.CacheConfig{TrieCleanLimit: 256, TrieDirtyLimit: 256, TrieTimeLimit: 5 * time.Minute, Preimages: true}
+	chain, err := core.NewBlockChain(db, cacheConfig, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	// Fund a new account in the next block, after the first page was retrieved
+	blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), gendb, 1, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(b.TxNonce(sender), common.Address{0xff}, big.NewInt(1), params.TxGas, b.BaseFee(), nil), signer, key)
+		b.AddTx(tx)
+	})
+	api := NewDebugAPI(&Ethereum{blockchain: chain, config: &ethconfig.Config{}})
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+
+	page, err := api.AccountRange(context.Background(), latest, nil, 3, true, true, false, &AccountRangeConfig{NoRoot: true})
+	if err != nil {
+		t.Fatalf("failed to retrieve first page: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to insert block: %v", err)
+	}
+	seen := make(map[common.Address]struct{})
+	for pages := 1; ; pages++ {
+		if page.Root != fmt.Sprintf("%x", genesis.Root()) {
+			t.Fatalf("page %d: root mismatch: have %s, want %x", pages, page.Root, genesis.Root())
+		}
+		if len(page.Accounts) > 3 {
+			t.Fatalf("page %d: too many accounts: %d", pages, len(page.Accounts))
+		}
+		for addr, account := range page.Accounts {
+			if _, ok := seen[addr]; ok {
+				t.Fatalf("page %d: account %x repeated", pages, addr)
+			}
+			if account.Root != nil {
+				t.Fatalf("page %d: account %x has storage root", pages, addr)
+			}
+			seen[addr] = struct{}{}
+		}
+		if page.Cursor == nil {
+			if page.Next != nil {
+				t.Fatalf("page %d: next key without cursor", pages)
+			}
+			break
+		}
+		if page, err = api.AccountRange(context.Background(), latest, nil, 3, true, true, false, &AccountRangeConfig{Cursor: page.Cursor, NoRoot: true}); err != nil {
+			t.Fatalf("failed to retrieve page %d: %v", pages+1, err)
+		}
+	}
+	if len(seen) != len(alloc) {
+		t.Fatalf("account count mismatch: have %d, want %d", len(seen), len(alloc))
+	}
+	if _, err := api.AccountRange(context.Background(), latest, nil, 3, true, true, false, &AccountRangeConfig{Cursor: hexutil.Bytes{1, 2, 3}}); err != errAccountRangeCursor {
+		t.Fatalf("invalid cursor error mismatch: have %v, want %v", err, errAccountRangeCursor)
+	}
+}
+
+func TestAccountRangeLimiter(t *testing.T) {
+	limiter := newAccountRangeLimiter(1)
+	if err := limiter.charge("a", AccountRangeMaxResults); err != nil {
+		t.Fatalf("full page refused: %v", err)
+	}
+	if err := limiter.charge("a", 1); !errors.Is(err, errAccountRangeRate) {
+		t.Fatalf("exhausted allowance error mismatch: have %v, want %v", err, errAccountRangeRate)
+	}
+	if err := limiter.charge("b", AccountRangeMaxResults); err != nil {
+		t.Fatalf("other client refused: %v", err)
+	}
+	if newAccountRangeLimiter(0) != nil {
+		t.Fatalf("limiter created without rate")
+	}
+}
+
+// Tests that sender bans beyond the maximum duration are shortened to it rather
+// than overflowing into a lifted ban.
+func TestBanSenderClamp(t *testing.T) {
+	t.Parallel()
+
+	db := rawdb.NewMemoryDatabase()
+	(&core.Genesis{Config: params.TestChainConfig}).MustCommit(db)
+	chain, _ := core.NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+
+	config := core.DefaultTxPoolConfig
+	config.Journal, config.Bans = "", ""
+	pool := core.NewTxPool(config, params.TestChainConfig, chain)
+	defer pool.Stop()
+
+	api := NewTxPoolAdminAPI(&Ethereum{txPool: pool})
+	sender := common.Address{0x01}
+	for _, duration := range []uint64{1 << 62, ^uint64(0)} {
+		if _, err := api.BanSender(sender, hexutil.Uint64(duration)); err != nil {
+			t.Fatalf("failed to ban sender: %v", err)
+		}
+		until, ok := api.Bans()[sender]
+		if !ok {
+			t.Fatalf("ban of %d seconds not in effect", duration)
+		}
+		if max := time.Now().Add(core.MaxBanDuration); until.After(max) {
+			t.Fatalf("ban of %d seconds not clamped: until %v", duration, until)
+		}
+	}
+}
+
+// Tests that nodes sharing the database of another one reject transactions,
+// having no peers to forward them to.
+func TestReadOnlySendTx(t *testing.T) {
+	eth := &Ethereum{config: &ethconfig.Config{DatabaseReadOnly: true}}
+	backend := &EthAPIBackend{eth: eth}
+
+	tx := types.NewTransaction(0, common.Address{}, big.NewInt(1), params.TxGas, big.NewInt(params.InitialBaseFee), nil)
+	if err := backend.SendTx(context.Background(), tx); !errors.Is(err, errReadOnlyNode) {
+		t.Errorf("send error mismatch: have %v, want %v", err, errReadOnlyNode)
+	}
+	if err := backend.SendTxConditional(context.Background(), tx, nil); !errors.Is(err, errReadOnlyNode) {
+		t.Errorf("conditional send error mismatch: have %v, want %v", err, errReadOnlyNode)
+	}
+}
@@ -36,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
 	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/state/pruner"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -45,7 +46,9 @@ import (
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
+	"github.com/ethereum/go-ethereum/eth/txtracker"
 	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/objectstore"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/internal/shutdowncheck"
@@ -66,10 +69,13 @@ type Config = ethconfig.Config
 
 // Ethereum implements the Ethereum full node service.
 type Ethereum struct {
+	engineHeartbeat int64 // Time of the last engine API call in unix nanoseconds, accessed atomically
+
 	config *ethconfig.Config
 
 	// Handlers
 	txPool             *core.TxPool
+	txTracker          *txtracker.TxTracker // Tracker of the local transactions, nil if disabled
 	blockchain         *core.BlockChain
 	handler            *handler
 	ethDialCandidates  enode.Iterator
@@ -101,6 +107,11 @@ type Ethereum struct {
 	lock sync.RWMutex // Protects the variadic fields (e.g. gas price and etherbase)
 
 	shutdownTracker *shutdowncheck.ShutdownTracker // Tracks if and when the node has shutdown ungracefully
+	dbInspector     *dbInspector                   // Background traversals of the chain database
+	dbCompactor     *dbCompactor                   // Scheduler and throttle of the database compactions
+	dbRefresher     *dbRefresher                   // Follower of the owner of a read-only chain database, nil if owned
+
+	events *Events // Event bus for programs embedding the node
 }
 
 // New creates a new Ethereum object (including the
@@ -133,10 +144,64 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	ethashConfig.NotifyFull = config.Miner.NotifyFull
 
 	// Assemble the Ethereum object
-	chainDb, err := stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", false)
+	var (
+		chainDb  ethdb.Database
+		sharedDb *rawdb.SharedDatabase
+		err      error
+	)
+	switch {
+	case config.DatabaseReadOnly && (config.DatabaseCold != "" || config.DatabaseFreezerRemote != ""):
+		return nil, errors.New("read-only database is not supported with tiered state or remote ancients")
+	case config.DatabaseReadOnly:
+		if sharedDb, err = stack.OpenSharedDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/"); err == nil {
+			chainDb = sharedDb
+		}
+	case config.DatabaseCold != "" && config.DatabaseFreezerRemote != "":
+		return nil, errors.New("tiered state is not supported with remote ancients")
+	case config.DatabaseCold != "":
+		chainDb, err = stack.OpenTieredDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, config.DatabaseCold, config.DatabaseColdAge, "eth/db/chaindata/", false)
+	case config.DatabaseFreezerRemote != "":
+		store, serr := objectstore.NewS3(config.DatabaseFreezerRemote, config.DatabaseFreezerRemoteRegion, config.DatabaseFreezerRemoteAnon)
+		if serr != nil {
+			return nil, serr
+		}
+		remote := rawdb.RemoteFreezerConfig{
+			Store:     store,
+			CacheSize: uint64(config.DatabaseFreezerRemoteCache) * 1024 * 1024,
+			Upload:    config.DatabaseFreezerRemoteUpload,
+		}
+		chainDb, err = stack.OpenDatabaseWithRemoteFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, remote, "eth/db/chaindata/", false)
+	default:
+		chainDb, err = stack.OpenDatabaseWithFreezer("chaindata", config.DatabaseCache, config.DatabaseHandles, config.DatabaseFreezer, "eth/db/chaindata/", false)
+	}
 	if err != nil {
 		return nil, err
 	}
+	if config.DatabaseReadOnly && rawdb.ReadHeadBlockHash(chainDb) == (common.Hash{}) {
+		chainDb.Close()
+		return nil, errors.New("read-only database not initialized by its owner")
+	}
+	scheme, err := rawdb.ParseStateScheme(config.StateScheme, chainDb)
+	if err != nil {
+		chainDb.Close()
+		return nil, err
+	}
+	if scheme == rawdb.PathScheme {
+		if config.NoPruning {
+			chainDb.Close()
+			return nil, errors.New("archive mode is not supported by the path state scheme")
+		}
+		if config.SyncMode == downloader.SnapSync {
+			log.Warn("Snap sync is not supported by the path state scheme, switching to full sync")
+			config.SyncMode = downloader.FullSync
+		}
+	}
+	// Mark new databases with their scheme before any state is written, existing
+	// ones are recognized by their layout
+	if !config.DatabaseReadOnly && rawdb.ReadStateScheme(chainDb) == "" {
+		rawdb.WriteStateScheme(chainDb, scheme)
+	}
+	log.Info("Using state scheme", "scheme", scheme)
 	chainConfig, genesisHash, genesisErr := core.SetupGenesisBlockWithOverride(chainDb, config.Genesis, config.OverrideGrayGlacier, config.OverrideTerminalTotalDifficulty)
 	if _, ok := genesisErr.(*params.ConfigCompatError); genesisErr != nil && !ok {
 		return nil, genesisErr
@@ -149,8 +214,10 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	log.Info(strings.Repeat("-", 153))
 	log.Info("")
 
-	if err := pruner.RecoverPruning(stack.ResolvePath(""), chainDb, stack.ResolvePath(config.TrieCleanCacheJournal)); err != nil {
-		log.Error("Failed to recover state", "error", err)
+	if !config.DatabaseReadOnly {
+		if err := pruner.RecoverPruning(stack.ResolvePath(""), chainDb, stack.ResolvePath(config.TrieCleanCacheJournal)); err != nil {
+			log.Error("Failed to recover state", "error", err)
+		}
 	}
 	merger := consensus.NewMerger(chainDb)
 	eth := &Ethereum{
@@ -168,6 +235,10 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		bloomIndexer:      core.NewBloomIndexer(chainDb, params.BloomBitsBlocks, params.BloomConfirms),
 		p2pServer:         stack.Server(),
 		shutdownTracker:   shutdowncheck.NewShutdownTracker(chainDb),
+		dbInspector:       newDBInspector(chainDb),
+	}
+	if eth.dbCompactor, err = newDBCompactor(chainDb, config.DatabaseCompactionRate, config.DatabaseCompactionWindow); err != nil {
+		return nil, err
 	}
 
 	bcVersion := rawdb.ReadDatabaseVersion(chainDb)
@@ -201,23 +272,69 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 			TrieTimeLimit:       config.TrieTimeout,
 			SnapshotLimit:       config.SnapshotCache,
 			Preimages:           config.Preimages,
+			HeatMapJournal:      stack.ResolvePath("heatmap.json"),
+			HeatMapContracts:    config.CacheWarmupContracts,
+			StateDiffs:          config.StateDiffIndex,
+			Witnesses:           config.RecordWitnesses,
+			LogLookupLimit:      config.LogLookupLimit,
+			StateScheme:         scheme,
+			StateHistory:        config.StateHistory,
+			TriePrefetch: state.PrefetcherConfig{
+				Workers:     config.TriePrefetchWorkers,
+				Concurrency: config.TriePrefetchConcurrency,
+			},
+			TriePrefetchOff: config.NoImportTriePrefetch,
 		}
+		txLookupLimit = &config.TxLookupLimit
 	)
-	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, vmConfig, eth.shouldPreserve, &config.TxLookupLimit)
+	if config.DatabaseReadOnly {
+		// The owner of a read-only database maintains its indexes and journals,
+		// which would clash with the ones of the other nodes sharing it
+		cacheConfig.TrieCleanJournal = ""
+		cacheConfig.HeatMapJournal = ""
+		cacheConfig.SnapshotLimit = 0
+		txLookupLimit = nil
+
+		config.TxPool.Journal = ""
+		config.TxPool.RemoteJournal = ""
+		config.TxPool.Bans = ""
+	}
+	eth.blockchain, err = core.NewBlockChain(chainDb, cacheConfig, chainConfig, eth.engine, vmConfig, eth.shouldPreserve, txLookupLimit)
 	if err != nil {
 		return nil, err
 	}
-	// Rewind the chain in case of an incompatible config upgrade.
-	if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
-		log.Warn("Rewinding chain to upgrade configuration", "err", compat)
-		eth.blockchain.SetHead(compat.RewindTo)
-		rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
+	eth.blockchain.SetMaxReorgDepth(config.MaxReorgDepth)
+
+	if config.DatabaseReadOnly {
+		eth.dbRefresher = newDBRefresher(sharedDb, eth.blockchain, config.DatabaseRefresh)
+	} else {
+		// Rewind the chain in case of an incompatible config upgrade.
+		if compat, ok := genesisErr.(*params.ConfigCompatError); ok {
+			log.Warn("Rewinding chain to upgrade configuration", "err", compat)
+			eth.blockchain.SetHead(compat.RewindTo)
+			rawdb.WriteChainConfig(chainDb, genesisHash, chainConfig)
+		}
+		eth.bloomIndexer.Start(eth.blockchain)
 	}
-	eth.bloomIndexer.Start(eth.blockchain)
 
 	if config.TxPool.Journal != "" {
 		config.TxPool.Journal = stack.ResolvePath(config.TxPool.Journal)
 	}
+	if config.TxPool.RemoteJournal != "" {
+		config.TxPool.RemoteJournal = stack.ResolvePath(config.TxPool.RemoteJournal)
+	}
+	if config.TxPool.QueuePolicy != "" {
+		config.TxPool.QueuePolicy = stack.ResolvePath(config.TxPool.QueuePolicy)
+	}
+	if config.TxPool.AuditLog != "" {
+		config.TxPool.AuditLog = stack.ResolvePath(config.TxPool.AuditLog)
+	}
+	if config.TxPool.Bans != "" {
+		config.TxPool.Bans = stack.ResolvePath(config.TxPool.Bans)
+	}
+	if config.EngineRecord != "" {
+		config.EngineRecord = stack.ResolvePath(config.EngineRecord)
+	}
 	eth.txPool = core.NewTxPool(config.TxPool, chainConfig, eth.blockchain)
 
 	// Permit the downloader to use the trie cache allowance during fast sync
@@ -237,14 +354,16 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 		EventMux:       eth.eventMux,
 		Checkpoint:     checkpoint,
 		RequiredBlocks: config.RequiredBlocks,
+		TxBudget:       config.TxBroadcastBudget,
 	}); err != nil {
 		return nil, err
 	}
 
+	config.Miner.TriePrefetch = cacheConfig.TriePrefetch
 	eth.miner = miner.New(eth, &config.Miner, chainConfig, eth.EventMux(), eth.engine, eth.isLocalBlock)
 	eth.miner.SetExtra(makeExtraData(config.Miner.ExtraData))
 
-	eth.APIBackend = &EthAPIBackend{stack.Config().ExtRPCEnabled(), stack.Config().AllowUnprotectedTxs, eth, nil}
+	eth.APIBackend = &EthAPIBackend{stack.Config().ExtRPCEnabled(), stack.Config().AllowUnprotectedTxs, eth, nil, nil}
 	if eth.APIBackend.allowUnprotectedTxs {
 		log.Info("Unprotected transactions allowed")
 	}
@@ -254,6 +373,15 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	}
 	eth.APIBackend.gpo = gasprice.NewOracle(eth.APIBackend, gpoParams)
 
+	if config.HistoricalRPC != "" {
+		client, err := rpc.Dial(config.HistoricalRPC)
+		if err != nil {
+			return nil, fmt.Errorf("failed to dial historical RPC endpoint: %w", err)
+		}
+		eth.APIBackend.historical = ethapi.NewHistoricalRPC(client, config.HistoricalBlock, config.HistoricalRPCTimeout)
+		log.Info("Proxying historical queries", "endpoint", config.HistoricalRPC, "before", config.HistoricalBlock)
+	}
+
 	// Setup DNS discovery iterators.
 	dnsclient := dnsdisc.NewClient(dnsdisc.Config{})
 	eth.ethDialCandidates, err = dnsclient.NewIterator(eth.config.EthDiscoveryURLs...)
@@ -271,10 +399,17 @@ func New(stack *node.Node, config *ethconfig.Config) (*Ethereum, error) {
 	// Register the backend on the node
 	stack.RegisterAPIs(eth.APIs())
 	stack.RegisterProtocols(eth.Protocols())
+	eth.events = newEvents(eth)
 	stack.RegisterLifecycle(eth)
+	stack.RegisterLifecycle(eth.events)
+	stack.RegisterLifecycle(newNodeEventReporter(stack, eth))
+	stack.RegisterDrainHook(eth.txPool.FlushJournal)
 
 	// Successful startup; push a marker and check previous unclean shutdowns.
-	eth.shutdownTracker.MarkStartup()
+	// The markers of a read-only database are the business of its owner.
+	if !config.DatabaseReadOnly {
+		eth.shutdownTracker.MarkStartup()
+	}
 
 	return eth, nil
 }
@@ -304,6 +439,55 @@ func (s *Ethereum) APIs() []rpc.API {
 	// Append any APIs exposed explicitly by the consensus engine
 	apis = append(apis, s.engine.APIs(s.BlockChain())...)
 
+	// Expose signed inclusion promises if a sequencer key is configured
+	if s.config.InclusionPromiseKey != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "eth",
+			Service:   NewInclusionPromiseAPI(s, s.config.InclusionPromiseKey, s.config.InclusionPromiseWindow),
+		})
+	}
+
+	// Expose the builder decisions to the sequencer operator if logged
+	if s.config.Miner.DecisionLogBlocks > 0 {
+		apis = append(apis, rpc.API{
+			Namespace:     "miner",
+			Service:       NewBuilderAPI(s),
+			Authenticated: true,
+		})
+	}
+	// Expose the pool administration next to the engine API only, so that nodes
+	// not driven by a consensus client don't open the auth listener
+	if s.blockchain.Config().TerminalTotalDifficulty != nil {
+		apis = append(apis, rpc.API{
+			Namespace:     "txpool",
+			Service:       NewTxPoolAdminAPI(s),
+			Authenticated: true,
+		})
+	}
+	// Expose the rollup configuration on rollup chains
+	if s.blockchain.Config().Optimism != nil {
+		apis = append(apis, rpc.API{
+			Namespace: "rollup",
+			Service:   NewRollupAPI(s),
+		})
+	}
+
+	filterTimeout := s.config.RPCFilterTimeout
+	if filterTimeout <= 0 {
+		filterTimeout = ethconfig.Defaults.RPCFilterTimeout
+	}
+	filterAPI := filters.NewFilterAPI(s.APIBackend, false, filterTimeout)
+	filterAPI.SetLimits(filters.FilterLimits{
+		ClientFilters: s.config.RPCFilterClientLimit,
+		Backlog:       s.config.RPCFilterBacklog,
+	})
+	if s.config.RPCSubscriptionFanout {
+		filterAPI.EnableFanout()
+	}
+	if s.config.RPCFilterCheckpoints {
+		filterAPI.EnableCheckpoints()
+	}
+
 	// Append all the local APIs and return
 	return append(apis, []rpc.API{
 		{
@@ -317,10 +501,13 @@ func (s *Ethereum) APIs() []rpc.API {
 			Service:   downloader.NewDownloaderAPI(s.handler.downloader, s.eventMux),
 		}, {
 			Namespace: "eth",
-			Service:   filters.NewFilterAPI(s.APIBackend, false, 5*time.Minute),
+			Service:   filterAPI,
 		}, {
 			Namespace: "admin",
 			Service:   NewAdminAPI(s),
+		}, {
+			Namespace: "admin",
+			Service:   filters.NewFilterAdminAPI(filterAPI),
 		}, {
 			Namespace: "debug",
 			Service:   NewDebugAPI(s),
@@ -496,6 +683,7 @@ func (s *Ethereum) AccountManager() *accounts.Manager  { return s.accountManager
 func (s *Ethereum) BlockChain() *core.BlockChain       { return s.blockchain }
 func (s *Ethereum) TxPool() *core.TxPool               { return s.txPool }
 func (s *Ethereum) EventMux() *event.TypeMux           { return s.eventMux }
+func (s *Ethereum) Events() *Events                    { return s.events }
 func (s *Ethereum) Engine() consensus.Engine           { return s.engine }
 func (s *Ethereum) ChainDb() ethdb.Database            { return s.chainDb }
 func (s *Ethereum) IsListening() bool                  { return true } // Always listening
@@ -503,6 +691,9 @@ func (s *Ethereum) Downloader() *downloader.Downloader { return s.handler.downlo
 func (s *Ethereum) Synced() bool                       { return atomic.LoadUint32(&s.handler.acceptTxs) == 1 }
 func (s *Ethereum) SetSynced()                         { atomic.StoreUint32(&s.handler.acceptTxs, 1) }
 func (s *Ethereum) ArchiveMode() bool                  { return s.config.NoPruning }
+func (s *Ethereum) VerifyDeposits() bool               { return s.config.VerifyDeposits }
+func (s *Ethereum) EngineRecord() string               { return s.config.EngineRecord }
+func (s *Ethereum) EngineSlowCall() time.Duration      { return s.config.EngineSlowCall }
 func (s *Ethereum) BloomIndexer() *core.ChainIndexer   { return s.bloomIndexer }
 func (s *Ethereum) Merger() *consensus.Merger          { return s.merger }
 func (s *Ethereum) SyncMode() downloader.SyncMode {
@@ -531,6 +722,14 @@ func (s *Ethereum) Start() error {
 	// Regularly update shutdown marker
 	s.shutdownTracker.Start()
 
+	// Throttle and schedule the database compactions
+	s.dbCompactor.start()
+
+	// Follow the owner of a read-only database
+	if s.dbRefresher != nil {
+		s.dbRefresher.start()
+	}
+
 	// Figure out a max peers count based on the server limits
 	maxPeers := s.p2pServer.MaxPeers
 	if s.config.LightServ > 0 {
@@ -553,18 +752,59 @@ func (s *Ethereum) Stop() error {
 	s.handler.Stop()
 
 	// Then stop everything else.
+	if s.dbRefresher != nil {
+		s.dbRefresher.close()
+	}
 	s.bloomIndexer.Close()
 	close(s.closeBloomHandler)
 	s.txPool.Stop()
 	s.miner.Close()
 	s.blockchain.Stop()
 	s.engine.Close()
+	s.APIBackend.historical.Close()
 
 	// Clean shutdown marker as the last thing before closing db
 	s.shutdownTracker.Stop()
+	s.dbInspector.close()
+	s.dbCompactor.close()
 
 	s.chainDb.Close()
 	s.eventMux.Stop()
 
 	return nil
 }
+
+// RegisterTxTracker adds the tracker of the local transactions submitted through
+// the RPC API to the stack. The fees of transactions are only bumped if their
+// sender is an unlocked account of the node.
+func RegisterTxTracker(stack *node.Node, backend *Ethereum, config txtracker.Config) {
+	sign := func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		account := accounts.Account{Address: from}
+		wallet, err := backend.accountManager.Find(account)
+		if err != nil {
+			return nil, err
+		}
+		return wallet.SignTx(account, tx, backend.blockchain.Config().ChainID)
+	}
+	backend.txTracker = txtracker.New(config, backend.blockchain, backend.txPool, backend.handler.BroadcastTransactions, sign)
+	stack.RegisterLifecycle(backend.txTracker)
+	stack.RegisterAPIs(backend.txTracker.APIs())
+}
+
+// RegisterStatePruner adds the online pruning of the stale state to the stack,
+// and exposes its progress as debug_pruneStatus.
+func RegisterStatePruner(stack *node.Node, backend *Ethereum, config pruner.OnlineConfig) error {
+	if backend.config.NoPruning && !config.Retention() {
+		return errors.New("online pruning of archive nodes requires a state retention window")
+	}
+	if config.Retention() && !backend.config.NoPruning {
+		return errors.New("state retention requires an archive node")
+	}
+	if backend.blockchain.StateCache().TrieDB().Scheme() == rawdb.PathScheme {
+		return errors.New("online pruning is not needed by the path state scheme, which keeps a single state")
+	}
+	p := pruner.NewOnlinePruner(config, backend.chainDb, backend.blockchain.StateCache().TrieDB(), backend.blockchain)
+	stack.RegisterLifecycle(p)
+	stack.RegisterAPIs(p.APIs())
+	return nil
+}
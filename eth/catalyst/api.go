@@ -27,12 +27,14 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/beacon"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/rpc"
 )
@@ -40,12 +42,27 @@ import (
 // Register adds catalyst APIs to the full node.
 func Register(stack *node.Node, backend *eth.Ethereum) error {
 	log.Warn("Catalyst mode enabled", "protocol", "eth")
+	api := NewConsensusAPI(backend)
+	var service interface{} = api
+	if path := backend.EngineRecord(); path != "" {
+		recorder, err := newEngineRecorder(path)
+		if err != nil {
+			return err
+		}
+		stack.RegisterLifecycle(recorder)
+		service = &recordingAPI{ConsensusAPI: api, recorder: recorder}
+		log.Info("Recording engine API calls", "path", path)
+	}
 	stack.RegisterAPIs([]rpc.API{
 		{
 			Namespace:     "engine",
-			Service:       NewConsensusAPI(backend),
+			Service:       service,
 			Authenticated: true,
 		},
+		{
+			Namespace: "admin",
+			Service:   NewSequencerAPI(api),
+		},
 	})
 	return nil
 }
@@ -54,6 +71,9 @@ type ConsensusAPI struct {
 	eth          *eth.Ethereum
 	remoteBlocks *headerQueue  // Cache of remote payloads received
 	localBlocks  *payloadQueue // Cache of local payloads generated
+	deposits     *depositQueue // Cache of deposits derived by the rollup node, nil if not verified
+	slowCall     time.Duration // Serving time above which block import and building calls are logged
+	sequencer    *sequencerState
 	// Lock for the forkChoiceUpdated method
 	forkChoiceLock sync.Mutex
 }
@@ -64,11 +84,17 @@ func NewConsensusAPI(eth *eth.Ethereum) *ConsensusAPI {
 	if eth.BlockChain().Config().TerminalTotalDifficulty == nil {
 		panic("Catalyst started without valid total difficulty")
 	}
-	return &ConsensusAPI{
+	api := &ConsensusAPI{
 		eth:          eth,
 		remoteBlocks: newHeaderQueue(),
 		localBlocks:  newPayloadQueue(),
+		slowCall:     eth.EngineSlowCall(),
+		sequencer:    newSequencerState(),
 	}
+	if eth.VerifyDeposits() && eth.BlockChain().Config().Optimism != nil {
+		api.deposits = newDepositQueue()
+	}
+	return api
 }
 
 // ForkchoiceUpdatedV1 has several responsibilities:
@@ -82,9 +108,12 @@ func NewConsensusAPI(eth *eth.Ethereum) *ConsensusAPI {
 // If there are payloadAttributes:
 // 		we try to assemble a block with the payloadAttributes and return its payloadID
 func (api *ConsensusAPI) ForkchoiceUpdatedV1(update beacon.ForkchoiceStateV1, payloadAttributes *beacon.PayloadAttributesV1) (beacon.ForkChoiceResponse, error) {
+	defer forkchoiceUpdatedMetrics.track(api.slowCall, time.Now(), "head", update.HeadBlockHash, "attributes", payloadAttributes != nil)
+
 	api.forkChoiceLock.Lock()
 	defer api.forkChoiceLock.Unlock()
 
+	api.eth.EngineHeartbeat()
 	log.Trace("Engine API request received", "method", "ForkchoiceUpdated", "head", update.HeadBlockHash, "finalized", update.FinalizedBlockHash, "safe", update.SafeBlockHash)
 	if update.HeadBlockHash == (common.Hash{}) {
 		log.Warn("Forkchoice requested update to zero hash")
@@ -148,6 +177,12 @@ func (api *ConsensusAPI) ForkchoiceUpdatedV1(update beacon.ForkchoiceStateV1, pa
 	if rawdb.ReadCanonicalHash(api.eth.ChainDb(), block.NumberU64()) != update.HeadBlockHash {
 		// Block is not canonical, set head.
 		if latestValid, err := api.eth.BlockChain().SetCanonical(block); err != nil {
+			if errors.Is(err, core.ErrReorgTooDeep) {
+				// The head is fine, don't follow it until permitted by the operator
+				// but keep the consensus client from deeming it invalid
+				log.Warn("Deferring forkchoice update beyond maximum reorg depth", "number", block.NumberU64(), "hash", update.HeadBlockHash, "err", err)
+				return beacon.STATUS_SYNCING, nil
+			}
 			return beacon.ForkChoiceResponse{PayloadStatus: beacon.PayloadStatusV1{Status: beacon.INVALID, LatestValidHash: &latestValid}}, err
 		}
 	} else if api.eth.BlockChain().CurrentBlock().Hash() == update.HeadBlockHash {
@@ -191,11 +226,18 @@ func (api *ConsensusAPI) ForkchoiceUpdatedV1(update beacon.ForkchoiceStateV1, pa
 			log.Warn("Safe block not in canonical chain")
 			return beacon.STATUS_INVALID, beacon.InvalidForkChoiceState.With(errors.New("safe block not in canonical chain"))
 		}
+		api.eth.BlockChain().SetSafe(safeBlock)
 	}
 	// If payload generation was requested, create a new block to be potentially
 	// sealed by the beacon client. The payload will be requested later, and we
 	// might replace it arbitrarily many times in between.
 	if payloadAttributes != nil {
+		// Blocks derived from L1 without the pool are still built for following
+		// the chain, only sequencing new blocks is handed over.
+		if !payloadAttributes.NoTxPool && api.sequencer.refuse() {
+			log.Warn("Refusing payload building request of stopped sequencer", "parent", update.HeadBlockHash)
+			return valid(nil), beacon.GenericServerError.With(errSequencerStopped)
+		}
 		// Decode forceTxs. TODO: How to handle tx validity.
 		forceTxs := make(types.Transactions, 0, len(payloadAttributes.Transactions))
 		for i, otx := range payloadAttributes.Transactions {
@@ -205,34 +247,67 @@ func (api *ConsensusAPI) ForkchoiceUpdatedV1(update beacon.ForkchoiceStateV1, pa
 			}
 			forceTxs = append(forceTxs, &tx)
 		}
-		// Create an empty block first which can be used as a fallback
-		empty, err := api.eth.Miner().GetSealingBlockSync(update.HeadBlockHash, payloadAttributes.Timestamp, payloadAttributes.SuggestedFeeRecipient, payloadAttributes.Random, true, forceTxs)
-		if err != nil {
-			log.Error("Failed to create empty sealing payload", "err", err)
-			return valid(nil), beacon.InvalidPayloadAttributes.With(err)
-		}
-		if payloadAttributes.NoTxPool {
-			id := computePayloadId(update.HeadBlockHash, payloadAttributes)
-			api.localBlocks.put(id, &payload{block: empty, result: nil, done: true}) // we don't let it default to "empty", we just mark the thing as done.
-			return valid(&id), nil
+		if api.deposits != nil {
+			api.deposits.put(update.HeadBlockHash, payloadAttributes.Timestamp, depositSourceHashes(forceTxs))
 		}
-		// Send a request to generate a full block in the background.
-		// The result can be obtained via the returned channel.
-		resCh, err := api.eth.Miner().GetSealingBlockAsync(update.HeadBlockHash, payloadAttributes.Timestamp, payloadAttributes.SuggestedFeeRecipient, payloadAttributes.Random, false, forceTxs)
+		// Build an empty block first which can be used as a fallback, then keep
+		// improving the full block in the background until it is requested.
+		payload, err := api.eth.Miner().BuildPayload(&miner.BuildPayloadArgs{
+			Parent:       update.HeadBlockHash,
+			Timestamp:    payloadAttributes.Timestamp,
+			FeeRecipient: payloadAttributes.SuggestedFeeRecipient,
+			Random:       payloadAttributes.Random,
+			Transactions: forceTxs,
+			NoTxPool:     payloadAttributes.NoTxPool,
+			GasLimit:     payloadAttributes.GasLimit,
+			ExtraData:    payloadAttributes.ExtraData,
+		})
 		if err != nil {
-			log.Error("Failed to create async sealing payload", "err", err)
+			log.Error("Failed to build payload", "err", err)
 			return valid(nil), beacon.InvalidPayloadAttributes.With(err)
 		}
 		id := computePayloadId(update.HeadBlockHash, payloadAttributes)
-		api.localBlocks.put(id, &payload{empty: empty, result: resCh})
+		api.localBlocks.put(id, payload)
+		api.sequencer.requestedPayload(id)
 		return valid(&id), nil
 	}
 	return valid(nil), nil
 }
 
+// engineCapabilities are the engine API methods supported by this node, as
+// advertised by ExchangeCapabilities.
+var engineCapabilities = []string{
+	"engine_forkchoiceUpdatedV1",
+	"engine_exchangeTransitionConfigurationV1",
+	"engine_getPayloadV1",
+	"engine_newPayloadV1",
+	"engine_getPayloadBodiesByHashV1",
+	"engine_getPayloadBodiesByRangeV1",
+}
+
+// rollupCapabilities are the rollup extensions of the engine API supported by
+// this node. Rather than methods, they name the extended fields, so the rollup
+// node can rely on them instead of probing with failing calls.
+var rollupCapabilities = []string{
+	"rollup_payloadAttributesTransactionsV1", // PayloadAttributesV1.Transactions
+	"rollup_payloadAttributesNoTxPoolV1",     // PayloadAttributesV1.NoTxPool
+	"rollup_payloadAttributesGasLimitV1",     // PayloadAttributesV1.GasLimit
+	"rollup_payloadAttributesExtraDataV1",    // PayloadAttributesV1.ExtraData
+}
+
+// ExchangeCapabilities returns the engine API methods and rollup extensions
+// supported by this node. The capabilities of the consensus client are logged.
+func (api *ConsensusAPI) ExchangeCapabilities(capabilities []string) []string {
+	log.Debug("Engine API capabilities exchanged", "consensus", capabilities)
+	caps := make([]string, 0, len(engineCapabilities)+len(rollupCapabilities))
+	caps = append(caps, engineCapabilities...)
+	return append(caps, rollupCapabilities...)
+}
+
 // ExchangeTransitionConfigurationV1 checks the given configuration against
 // the configuration of the node.
 func (api *ConsensusAPI) ExchangeTransitionConfigurationV1(config beacon.TransitionConfigurationV1) (*beacon.TransitionConfigurationV1, error) {
+	api.eth.EngineHeartbeat()
 	if config.TerminalTotalDifficulty == nil {
 		return nil, errors.New("invalid terminal total difficulty")
 	}
@@ -257,16 +332,78 @@ func (api *ConsensusAPI) ExchangeTransitionConfigurationV1(config beacon.Transit
 
 // GetPayloadV1 returns a cached payload by id.
 func (api *ConsensusAPI) GetPayloadV1(payloadID beacon.PayloadID) (*beacon.ExecutableDataV1, error) {
+	defer getPayloadMetrics.track(api.slowCall, time.Now(), "id", payloadID)
+
+	api.eth.EngineHeartbeat()
 	log.Trace("Engine API request received", "method", "GetPayload", "id", payloadID)
 	data := api.localBlocks.get(payloadID)
 	if data == nil {
 		return nil, beacon.UnknownPayload
 	}
+	getPayloadMetrics.payload(data)
+	api.sequencer.deliveredPayload(payloadID, data)
 	return data, nil
 }
 
+// maxPayloadBodies is the maximum number of payload bodies returned by
+// GetPayloadBodiesByRangeV1.
+const maxPayloadBodies = 1024
+
+// GetPayloadBodiesByHashV1 returns the bodies of the blocks with the given hashes,
+// nil for unknown blocks.
+func (api *ConsensusAPI) GetPayloadBodiesByHashV1(hashes []common.Hash) ([]*beacon.ExecutionPayloadBodyV1, error) {
+	if len(hashes) > maxPayloadBodies {
+		return nil, beacon.TooLargeRequest.With(fmt.Errorf("requested count too large: %d", len(hashes)))
+	}
+	bodies := make([]*beacon.ExecutionPayloadBodyV1, len(hashes))
+	for i, hash := range hashes {
+		bodies[i] = payloadBody(api.eth.BlockChain().GetBlockByHash(hash))
+	}
+	return bodies, nil
+}
+
+// GetPayloadBodiesByRangeV1 returns the bodies of count canonical blocks starting
+// at start. The result is truncated at the head of the chain, missing blocks
+// within the range are nil.
+func (api *ConsensusAPI) GetPayloadBodiesByRangeV1(start, count hexutil.Uint64) ([]*beacon.ExecutionPayloadBodyV1, error) {
+	if start == 0 || count == 0 {
+		return nil, beacon.InvalidParams.With(fmt.Errorf("invalid start or count, start: %d count: %d", start, count))
+	}
+	if count > maxPayloadBodies {
+		return nil, beacon.TooLargeRequest.With(fmt.Errorf("requested count too large: %d", count))
+	}
+	last := uint64(start) + uint64(count) - 1
+	if current := api.eth.BlockChain().CurrentBlock().NumberU64(); last > current {
+		last = current
+	}
+	var bodies []*beacon.ExecutionPayloadBodyV1
+	for number := uint64(start); number <= last; number++ {
+		bodies = append(bodies, payloadBody(api.eth.BlockChain().GetBlockByNumber(number)))
+	}
+	if bodies == nil {
+		bodies = []*beacon.ExecutionPayloadBodyV1{}
+	}
+	return bodies, nil
+}
+
+// payloadBody converts the body of a block into a payload body.
+func payloadBody(block *types.Block) *beacon.ExecutionPayloadBodyV1 {
+	if block == nil {
+		return nil
+	}
+	txs := make([]hexutil.Bytes, len(block.Transactions()))
+	for i, tx := range block.Transactions() {
+		txs[i], _ = tx.MarshalBinary()
+	}
+	return &beacon.ExecutionPayloadBodyV1{TransactionData: txs}
+}
+
 // NewPayloadV1 creates an Eth1 block, inserts it in the chain, and returns the status of the chain.
 func (api *ConsensusAPI) NewPayloadV1(params beacon.ExecutableDataV1) (beacon.PayloadStatusV1, error) {
+	defer newPayloadMetrics.track(api.slowCall, time.Now(), "number", params.Number, "hash", params.BlockHash, "txs", len(params.Transactions), "gas", params.GasUsed)
+	newPayloadMetrics.payload(&params)
+
+	api.eth.EngineHeartbeat()
 	log.Trace("Engine API request received", "method", "ExecutePayload", "number", params.Number, "hash", params.BlockHash)
 	block, err := beacon.ExecutableDataToBlock(params)
 	if err != nil {
@@ -280,6 +417,15 @@ func (api *ConsensusAPI) NewPayloadV1(params beacon.ExecutableDataV1) (beacon.Pa
 		hash := block.Hash()
 		return beacon.PayloadStatusV1{Status: beacon.VALID, LatestValidHash: &hash}, nil
 	}
+	// Verifiers reject payloads not carrying exactly the deposits derived by the
+	// rollup node, before spending any effort on them.
+	if api.deposits != nil {
+		expected, known := api.deposits.get(block.ParentHash(), block.Time())
+		if err := verifyDeposits(block.Transactions(), expected, known); err != nil {
+			log.Warn("Rejecting payload with invalid deposits", "number", params.Number, "hash", params.BlockHash, "err", err)
+			return beacon.PayloadStatusV1{Status: beacon.INVALID}, beacon.InvalidDeposits.With(err)
+		}
+	}
 	// If the parent is missing, we - in theory - could trigger a sync, but that
 	// would also entail a reorg. That is problematic if multiple sibling blocks
 	// are being fed to us, and even more so, if some semi-distant uncle shortens
@@ -345,6 +491,12 @@ func computePayloadId(headBlockHash common.Hash, params *beacon.PayloadAttribute
 	binary.Write(hasher, binary.BigEndian, params.Timestamp)
 	hasher.Write(params.Random[:])
 	hasher.Write(params.SuggestedFeeRecipient[:])
+	if params.GasLimit != nil {
+		binary.Write(hasher, binary.BigEndian, *params.GasLimit)
+	}
+	// Length prefix the extra-data, so that it can't be confused with the gas limit
+	binary.Write(hasher, binary.BigEndian, uint64(len(params.ExtraData)))
+	hasher.Write(params.ExtraData)
 	var out beacon.PayloadID
 	copy(out[:], hasher.Sum(nil)[:8])
 	return out
@@ -20,6 +20,8 @@ import (
 	"bytes"
 	"fmt"
 	"math/big"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -184,6 +186,57 @@ func TestEth2PrepareAndGetPayload(t *testing.T) {
 	}
 }
 
+func TestPayloadAttributesOverrides(t *testing.T) {
+	genesis, blocks := generatePreMergeChain(10)
+	n, ethservice := startEthService(t, genesis, blocks)
+	defer n.Close()
+
+	var (
+		api    = NewConsensusAPI(ethservice)
+		parent = ethservice.BlockChain().CurrentBlock()
+		within = parent.GasLimit() + parent.GasLimit()/params.GasLimitBoundDivisor - 1
+		beyond = parent.GasLimit() * 2
+	)
+	tests := []struct {
+		gasLimit  *uint64
+		extra     []byte
+		shouldErr bool
+	}{
+		{&within, []byte("rollup"), false},
+		{nil, make([]byte, params.MaximumExtraDataSize), false},
+		{&beyond, nil, true},
+		{nil, make([]byte, params.MaximumExtraDataSize+1), true},
+	}
+	for i, test := range tests {
+		attrs := beacon.PayloadAttributesV1{
+			Timestamp: parent.Time() + 5,
+			GasLimit:  test.gasLimit,
+			ExtraData: test.extra,
+		}
+		fcState := beacon.ForkchoiceStateV1{HeadBlockHash: parent.Hash()}
+		resp, err := api.ForkchoiceUpdatedV1(fcState, &attrs)
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("test %d: expected error preparing payload", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("test %d: error preparing payload: %v", i, err)
+		}
+		execData, err := api.GetPayloadV1(*resp.PayloadID)
+		if err != nil {
+			t.Fatalf("test %d: error getting payload: %v", i, err)
+		}
+		if test.gasLimit != nil && execData.GasLimit != *test.gasLimit {
+			t.Errorf("test %d: gas limit mismatch: have %d, want %d", i, execData.GasLimit, *test.gasLimit)
+		}
+		if !bytes.Equal(execData.ExtraData, test.extra) {
+			t.Errorf("test %d: extra-data mismatch: have %x, want %x", i, execData.ExtraData, test.extra)
+		}
+	}
+}
+
 func checkLogEvents(t *testing.T, logsCh <-chan []*types.Log, rmLogsCh <-chan core.RemovedLogsEvent, wantNew, wantRemoved int) {
 	t.Helper()
 
@@ -389,6 +442,52 @@ func TestEth2DeepReorg(t *testing.T) {
 	*/
 }
 
+// Tests that forkchoice updates rewinding more than the maximum reorg depth are
+// deferred without deeming the head invalid, until permitted by the operator.
+func TestForkchoiceReorgTooDeep(t *testing.T) {
+	genesis, preMergeBlocks := generatePreMergeChain(10)
+	n, ethservice := startEthService(t, genesis, preMergeBlocks)
+	defer n.Close()
+
+	var (
+		api    = NewConsensusAPI(ethservice)
+		parent = preMergeBlocks[len(preMergeBlocks)-1]
+	)
+	setupBlocks(t, ethservice, 4, parent, func(parent *types.Block) {})
+	head := ethservice.BlockChain().CurrentBlock()
+
+	// Fork off the last pre-merge block, rewinding 4 blocks
+	for i := 0; i < 2; i++ {
+		execData, err := assembleBlock(api, parent.Hash(), &beacon.PayloadAttributesV1{
+			Timestamp: parent.Time() + 6,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create the executable data %v", err)
+		}
+		if resp, err := api.NewPayloadV1(*execData); err != nil || resp.Status != beacon.VALID {
+			t.Fatalf("Failed to insert block: %v", err)
+		}
+		parent, _ = beacon.ExecutableDataToBlock(*execData)
+	}
+	ethservice.BlockChain().SetMaxReorgDepth(2)
+
+	fcState := beacon.ForkchoiceStateV1{HeadBlockHash: parent.Hash()}
+	resp, err := api.ForkchoiceUpdatedV1(fcState, nil)
+	if err != nil || resp.PayloadStatus.Status != beacon.SYNCING {
+		t.Fatalf("deep reorg status mismatch: have %v, err %v, want %v", resp.PayloadStatus.Status, err, beacon.SYNCING)
+	}
+	if current := ethservice.BlockChain().CurrentBlock(); current.Hash() != head.Hash() {
+		t.Fatalf("head changed by deferred reorg: have %d [%x]", current.NumberU64(), current.Hash())
+	}
+	ethservice.BlockChain().AllowReorg(4)
+	if resp, err := api.ForkchoiceUpdatedV1(fcState, nil); err != nil || resp.PayloadStatus.Status != beacon.VALID {
+		t.Fatalf("permitted reorg status mismatch: have %v, err %v, want %v", resp.PayloadStatus.Status, err, beacon.VALID)
+	}
+	if current := ethservice.BlockChain().CurrentBlock(); current.Hash() != parent.Hash() {
+		t.Fatalf("head mismatch after permitted reorg: have %d [%x]", current.NumberU64(), current.Hash())
+	}
+}
+
 // startEthService creates a full node instance for testing.
 func startEthService(t *testing.T, genesis *core.Genesis, blocks []*types.Block) (*node.Node, *eth.Ethereum) {
 	t.Helper()
@@ -481,6 +580,9 @@ func TestExchangeTransitionConfig(t *testing.T) {
 	var (
 		api = NewConsensusAPI(ethservice)
 	)
+	if !ethservice.LastEngineHeartbeat().IsZero() {
+		t.Fatal("engine heartbeat recorded before any engine API call")
+	}
 	// invalid ttd
 	config := beacon.TransitionConfigurationV1{
 		TerminalTotalDifficulty: (*hexutil.Big)(big.NewInt(0)),
@@ -490,6 +592,9 @@ func TestExchangeTransitionConfig(t *testing.T) {
 	if _, err := api.ExchangeTransitionConfigurationV1(config); err == nil {
 		t.Fatal("expected error on invalid config, invalid ttd")
 	}
+	if ethservice.LastEngineHeartbeat().IsZero() {
+		t.Fatal("engine heartbeat not recorded")
+	}
 	// invalid terminal block hash
 	config = beacon.TransitionConfigurationV1{
 		TerminalTotalDifficulty: (*hexutil.Big)(genesis.Config.TerminalTotalDifficulty),
@@ -859,3 +964,107 @@ func TestNewPayloadOnInvalidTerminalBlock(t *testing.T) {
 		t.Fatalf("error sending invalid forkchoice, invalid status: %v", resp.PayloadStatus.Status)
 	}
 }
+
+func TestGetPayloadBodies(t *testing.T) {
+	genesis, preMergeBlocks := generatePreMergeChain(10)
+	n, ethservice := startEthService(t, genesis, preMergeBlocks)
+	defer n.Close()
+
+	api := NewConsensusAPI(ethservice)
+	chain := ethservice.BlockChain()
+
+	// Bodies by hash, unknown blocks are nil.
+	hashes := []common.Hash{preMergeBlocks[2].Hash(), {0x01}, preMergeBlocks[5].Hash()}
+	bodies, err := api.GetPayloadBodiesByHashV1(hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 3 || bodies[1] != nil {
+		t.Fatalf("wrong bodies by hash: %v", bodies)
+	}
+	for _, i := range []int{0, 2} {
+		checkPayloadBody(t, bodies[i], chain.GetBlockByHash(hashes[i]))
+	}
+
+	// Bodies by range, truncated at the head.
+	bodies, err = api.GetPayloadBodiesByRangeV1(8, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 3 {
+		t.Fatalf("wrong number of bodies by range: have %d, want 3", len(bodies))
+	}
+	for i, body := range bodies {
+		checkPayloadBody(t, body, chain.GetBlockByNumber(uint64(8+i)))
+	}
+	if bodies, err := api.GetPayloadBodiesByRangeV1(20, 5); err != nil || len(bodies) != 0 {
+		t.Fatalf("wrong result beyond the head: %v, %v", bodies, err)
+	}
+	if _, err := api.GetPayloadBodiesByRangeV1(0, 1); err == nil {
+		t.Fatal("no error for zero start")
+	}
+	if _, err := api.GetPayloadBodiesByRangeV1(1, maxPayloadBodies+1); err == nil {
+		t.Fatal("no error for too large count")
+	}
+}
+
+func checkPayloadBody(t *testing.T, body *beacon.ExecutionPayloadBodyV1, block *types.Block) {
+	t.Helper()
+	if body == nil {
+		t.Fatalf("missing body of block %d", block.NumberU64())
+	}
+	if len(body.TransactionData) != len(block.Transactions()) {
+		t.Fatalf("wrong number of transactions in body of block %d", block.NumberU64())
+	}
+	for i, tx := range block.Transactions() {
+		enc, _ := tx.MarshalBinary()
+		if !bytes.Equal(body.TransactionData[i], enc) {
+			t.Errorf("wrong transaction %d in body of block %d", i, block.NumberU64())
+		}
+	}
+}
+
+func TestExchangeCapabilities(t *testing.T) {
+	// Every engine method except ExchangeCapabilities itself is advertised.
+	var api *ConsensusAPI
+	caps := api.ExchangeCapabilities([]string{"engine_newPayloadV1"})
+	advertised := make(map[string]bool)
+	for _, c := range caps {
+		advertised[c] = true
+	}
+	typ := reflect.TypeOf(api)
+	for i := 0; i < typ.NumMethod(); i++ {
+		name := typ.Method(i).Name
+		if name == "ExchangeCapabilities" {
+			continue
+		}
+		method := "engine_" + strings.ToLower(name[:1]) + name[1:]
+		if !advertised[method] {
+			t.Errorf("method %s not advertised", method)
+		}
+		delete(advertised, method)
+	}
+	// The remaining capabilities are rollup extensions.
+	for c := range advertised {
+		if !strings.HasPrefix(c, "rollup_") {
+			t.Errorf("unknown capability %s", c)
+		}
+	}
+	if len(advertised) != len(rollupCapabilities) {
+		t.Errorf("wrong number of rollup capabilities: have %d, want %d", len(advertised), len(rollupCapabilities))
+	}
+}
+
+// Tests that the payload id tells apart the attributes whose gas limit and
+// extra-data would otherwise hash the same.
+func TestPayloadIdExtraData(t *testing.T) {
+	var (
+		head     = common.Hash{0x01}
+		gasLimit = uint64(0x0102030405060708)
+	)
+	withGasLimit := &beacon.PayloadAttributesV1{Timestamp: 1, GasLimit: &gasLimit}
+	withExtra := &beacon.PayloadAttributesV1{Timestamp: 1, ExtraData: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}}
+	if computePayloadId(head, withGasLimit) == computePayloadId(head, withExtra) {
+		t.Fatalf("gas limit and extra-data attributes share a payload id")
+	}
+}
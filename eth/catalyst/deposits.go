@@ -0,0 +1,85 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// DepositError reports a payload whose deposits differ from the ones derived
+// by the rollup node.
+type DepositError struct {
+	Index  int    // Position of the offending transaction in the payload
+	Reason string // What is wrong with the transaction
+}
+
+func (e *DepositError) Error() string {
+	return fmt.Sprintf("invalid deposit at index %d: %s", e.Index, e.Reason)
+}
+
+// depositSourceHashes returns the source hashes of the deposit transactions.
+func depositSourceHashes(txs types.Transactions) []common.Hash {
+	var hashes []common.Hash
+	for _, tx := range txs {
+		if tx.Type() == types.DepositTxType {
+			hashes = append(hashes, tx.SourceHash())
+		}
+	}
+	return hashes
+}
+
+// verifyDeposits checks that the deposits of a payload lead its transactions,
+// starting with the L1 attributes deposit, and are unique. If the deposits
+// derived by the rollup node are known, they must match exactly.
+func verifyDeposits(txs types.Transactions, expected []common.Hash, known bool) error {
+	if len(txs) == 0 || txs[0].Type() != types.DepositTxType {
+		return &DepositError{Index: 0, Reason: "missing L1 attributes deposit"}
+	}
+	var (
+		count int
+		seen  = make(map[common.Hash]bool)
+	)
+	for i, tx := range txs {
+		if tx.Type() != types.DepositTxType {
+			continue
+		}
+		if i != count {
+			return &DepositError{Index: i, Reason: "deposit after regular transactions"}
+		}
+		hash := tx.SourceHash()
+		if seen[hash] {
+			return &DepositError{Index: i, Reason: fmt.Sprintf("duplicate source hash %x", hash)}
+		}
+		seen[hash] = true
+		if known {
+			if i >= len(expected) {
+				return &DepositError{Index: i, Reason: fmt.Sprintf("unexpected deposit %x, want %d deposits", hash, len(expected))}
+			}
+			if hash != expected[i] {
+				return &DepositError{Index: i, Reason: fmt.Sprintf("source hash mismatch: have %x, want %x", hash, expected[i])}
+			}
+		}
+		count++
+	}
+	if known && count < len(expected) {
+		return &DepositError{Index: count, Reason: fmt.Sprintf("missing deposit %x, have %d deposits", expected[count], count)}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func TestVerifyDeposits(t *testing.T) {
+	deposit := func(source byte) *types.Transaction {
+		return types.NewTx(&types.DepositTx{SourceHash: common.Hash{source}, Value: common.Big0})
+	}
+	regular := types.NewTransaction(0, common.Address{}, common.Big0, 0, common.Big0, nil)
+
+	tests := []struct {
+		txs      types.Transactions
+		expected []common.Hash
+		known    bool
+		index    int // Index of the offending transaction, -1 if valid
+	}{
+		{types.Transactions{deposit(1), deposit(2), regular}, nil, false, -1},
+		{types.Transactions{deposit(1), deposit(2), regular}, []common.Hash{{1}, {2}}, true, -1},
+		{types.Transactions{regular, deposit(1)}, nil, false, 0},
+		{types.Transactions{deposit(1), regular, deposit(2)}, nil, false, 2},
+		{types.Transactions{deposit(1), deposit(1)}, nil, false, 1},
+		{types.Transactions{deposit(1), deposit(3)}, []common.Hash{{1}, {2}}, true, 1},
+		{types.Transactions{deposit(1)}, []common.Hash{{1}, {2}}, true, 1},
+		{types.Transactions{deposit(1), deposit(2)}, []common.Hash{{1}}, true, 1},
+	}
+	for i, test := range tests {
+		err := verifyDeposits(test.txs, test.expected, test.known)
+		if test.index < 0 {
+			if err != nil {
+				t.Errorf("test %d: unexpected error: %v", i, err)
+			}
+			continue
+		}
+		var depErr *DepositError
+		if !errors.As(err, &depErr) {
+			t.Errorf("test %d: error mismatch: have %v, want deposit error", i, err)
+		} else if depErr.Index != test.index {
+			t.Errorf("test %d: index mismatch: have %d, want %d", i, depErr.Index, test.index)
+		}
+	}
+}
+
+func TestDepositQueue(t *testing.T) {
+	q := newDepositQueue()
+	for i := 0; i <= maxTrackedDeposits; i++ {
+		q.put(common.Hash{byte(i)}, uint64(i), []common.Hash{{byte(i)}})
+	}
+	if _, ok := q.get(common.Hash{0}, 0); ok {
+		t.Error("evicted deposits retrieved")
+	}
+	if deposits, ok := q.get(common.Hash{1}, 1); !ok || len(deposits) != 1 || deposits[0] != (common.Hash{1}) {
+		t.Errorf("deposits mismatch: have %v, %v", deposits, ok)
+	}
+	if _, ok := q.get(common.Hash{1}, 2); ok {
+		t.Error("deposits retrieved for other timestamp")
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// engineCallMetrics are the metrics of an engine API method importing or
+// building blocks. Serving times are in microseconds.
+type engineCallMetrics struct {
+	method   string
+	duration metrics.Histogram
+	slow     metrics.Meter
+
+	size metrics.Gauge // Encoded transactions of the last payload, in bytes
+	txs  metrics.Gauge // Transactions of the last payload
+	gas  metrics.Gauge // Gas used by the last payload
+}
+
+func newEngineCallMetrics(method, name string, payload bool) *engineCallMetrics {
+	m := &engineCallMetrics{
+		method:   method,
+		duration: metrics.NewRegisteredHistogram("engine/"+name+"/duration", nil, metrics.NewExpDecaySample(1028, 0.015)),
+		slow:     metrics.NewRegisteredMeter("engine/"+name+"/slow", nil),
+	}
+	if payload {
+		m.size = metrics.NewRegisteredGauge("engine/"+name+"/size", nil)
+		m.txs = metrics.NewRegisteredGauge("engine/"+name+"/txs", nil)
+		m.gas = metrics.NewRegisteredGauge("engine/"+name+"/gas", nil)
+	}
+	return m
+}
+
+var (
+	newPayloadMetrics        = newEngineCallMetrics("engine_newPayloadV1", "newpayload", true)
+	forkchoiceUpdatedMetrics = newEngineCallMetrics("engine_forkchoiceUpdatedV1", "forkchoiceupdated", false)
+	getPayloadMetrics        = newEngineCallMetrics("engine_getPayloadV1", "getpayload", true)
+)
+
+// track records the serving time of a call started at the given time, and logs
+// the call if it took longer than the threshold (0 = never). It is meant to be
+// deferred at the start of the call.
+func (m *engineCallMetrics) track(threshold time.Duration, start time.Time, ctx ...interface{}) {
+	elapsed := time.Since(start)
+	m.duration.Update(elapsed.Microseconds())
+	if threshold > 0 && elapsed > threshold {
+		m.slow.Mark(1)
+		log.Warn("Slow engine API call", append([]interface{}{"method", m.method, "elapsed", common.PrettyDuration(elapsed)}, ctx...)...)
+	}
+}
+
+// payload records the size of a payload imported or built.
+func (m *engineCallMetrics) payload(data *beacon.ExecutableDataV1) {
+	var size int
+	for _, tx := range data.Transactions {
+		size += len(tx)
+	}
+	m.size.Update(int64(size))
+	m.txs.Update(int64(len(data.Transactions)))
+	m.gas.Update(int64(data.GasUsed))
+}
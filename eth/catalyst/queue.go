@@ -23,6 +23,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/beacon"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/miner"
 )
 
 // maxTrackedPayloads is the maximum number of prepared payloads the execution
@@ -35,52 +36,19 @@ const maxTrackedPayloads = 10
 // latest one; but have a slight wiggle room for non-ideal conditions.
 const maxTrackedHeaders = 10
 
-// payload wraps the miner's block production channel, allowing the mined block
-// to be retrieved later upon the GetPayload engine API call.
-type payload struct {
-	lock   sync.Mutex
-	done   bool
-	empty  *types.Block
-	block  *types.Block
-	result chan *types.Block
-}
-
-// resolve extracts the generated full block from the given channel if possible
-// or fallback to empty block as an alternative.
-func (req *payload) resolve() *beacon.ExecutableDataV1 {
-	// this function can be called concurrently, prevent any
-	// concurrency issue in the first place.
-	req.lock.Lock()
-	defer req.lock.Unlock()
-
-	// Try to resolve the full block first if it's not obtained
-	// yet. The returned block can be nil if the generation fails.
-
-	if !req.done {
-		timeout := time.NewTimer(500 * time.Millisecond)
-		defer timeout.Stop()
-
-		select {
-		case req.block = <-req.result:
-			req.done = true
-		case <-timeout.C:
-			// TODO(rjl49345642, Marius), should we keep this
-			// 100ms timeout allowance? Why not just use the
-			// default and then fallback to empty directly?
-		}
-	}
+// maxTrackedDeposits is the maximum number of payload attributes the execution
+// engine tracks the deposits of, to verify the payloads derived from them.
+const maxTrackedDeposits = 10
 
-	if req.block != nil {
-		return beacon.BlockToExecutableData(req.block)
-	}
-	return beacon.BlockToExecutableData(req.empty)
-}
+// payloadResolveTimeout is the maximum time GetPayload waits for the first full
+// payload to be built before falling back to the empty one.
+const payloadResolveTimeout = 500 * time.Millisecond
 
 // payloadQueueItem represents an id->payload tuple to store until it's retrieved
 // or evicted.
 type payloadQueueItem struct {
-	id   beacon.PayloadID
-	data *payload
+	id      beacon.PayloadID
+	payload *miner.Payload
 }
 
 // payloadQueue tracks the latest handful of constructed payloads to be retrieved
@@ -99,14 +67,14 @@ func newPayloadQueue() *payloadQueue {
 }
 
 // put inserts a new payload into the queue at the given id.
-func (q *payloadQueue) put(id beacon.PayloadID, data *payload) {
+func (q *payloadQueue) put(id beacon.PayloadID, payload *miner.Payload) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 
 	copy(q.payloads[1:], q.payloads)
 	q.payloads[0] = &payloadQueueItem{
-		id:   id,
-		data: data,
+		id:      id,
+		payload: payload,
 	}
 }
 
@@ -120,7 +88,7 @@ func (q *payloadQueue) get(id beacon.PayloadID) *beacon.ExecutableDataV1 {
 			return nil // no more items
 		}
 		if item.id == id {
-			return item.data.resolve()
+			return beacon.BlockToExecutableData(item.payload.Resolve(payloadResolveTimeout))
 		}
 	}
 	return nil
@@ -175,3 +143,55 @@ func (q *headerQueue) get(hash common.Hash) *types.Header {
 	}
 	return nil
 }
+
+// depositQueueItem represents the deposits of the payload attributes for a block
+// on top of the given parent at the given time.
+type depositQueueItem struct {
+	parent    common.Hash
+	timestamp uint64
+	deposits  []common.Hash // Source hashes of the deposits, in order
+}
+
+// depositQueue tracks the deposits of the latest handful of payload attributes
+// supplied by the rollup node.
+type depositQueue struct {
+	items []*depositQueueItem
+	lock  sync.RWMutex
+}
+
+// newDepositQueue creates a pre-initialized queue with a fixed number of slots
+// all containing empty items.
+func newDepositQueue() *depositQueue {
+	return &depositQueue{
+		items: make([]*depositQueueItem, maxTrackedDeposits),
+	}
+}
+
+// put inserts the deposits of a block into the queue.
+func (q *depositQueue) put(parent common.Hash, timestamp uint64, deposits []common.Hash) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	copy(q.items[1:], q.items)
+	q.items[0] = &depositQueueItem{
+		parent:    parent,
+		timestamp: timestamp,
+		deposits:  deposits,
+	}
+}
+
+// get retrieves the deposits of a block, or false if they are not known.
+func (q *depositQueue) get(parent common.Hash, timestamp uint64) ([]common.Hash, bool) {
+	q.lock.RLock()
+	defer q.lock.RUnlock()
+
+	for _, item := range q.items {
+		if item == nil {
+			return nil, false // no more items
+		}
+		if item.parent == parent && item.timestamp == timestamp {
+			return item.deposits, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,204 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// EngineRecord is a single engine API call of a recording.
+type EngineRecord struct {
+	Time     time.Time         `json:"time"`
+	Method   string            `json:"method"`
+	Params   []json.RawMessage `json:"params"`
+	Result   json.RawMessage   `json:"result,omitempty"`
+	Error    string            `json:"error,omitempty"`
+	Duration time.Duration     `json:"duration"` // Time taken to serve the call, in nanoseconds
+}
+
+// engineRecorder appends the engine API calls to a file, one JSON record per
+// line, to allow replaying them into a fresh node.
+type engineRecorder struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	failed bool // Whether a write failure was already reported
+}
+
+// newEngineRecorder opens the recording at the given path for appending.
+func newEngineRecorder(path string) (*engineRecorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &engineRecorder{file: file, writer: bufio.NewWriter(file)}, nil
+}
+
+// record appends a call to the recording and flushes it, so that the calls
+// leading to a crash are all on disk.
+func (r *engineRecorder) record(method string, start time.Time, result interface{}, err error, params ...interface{}) {
+	entry := &EngineRecord{Time: start, Method: method, Duration: time.Since(start)}
+	for _, param := range params {
+		blob, _ := json.Marshal(param)
+		entry.Params = append(entry.Params, blob)
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		entry.Result, _ = json.Marshal(result)
+	}
+	blob, merr := json.Marshal(entry)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if merr == nil {
+		blob = append(blob, '\n')
+		if _, merr = r.writer.Write(blob); merr == nil {
+			merr = r.writer.Flush()
+		}
+	}
+	if merr != nil && !r.failed {
+		log.Warn("Failed to write engine API recording", "err", merr)
+		r.failed = true
+	}
+}
+
+// Start implements node.Lifecycle, the recorder is ready once opened.
+func (r *engineRecorder) Start() error { return nil }
+
+// Stop implements node.Lifecycle, flushing and closing the recording.
+func (r *engineRecorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.writer.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// recordingAPI is the engine API recording the calls that drive the chain and
+// block production. The remaining methods are served unrecorded.
+type recordingAPI struct {
+	*ConsensusAPI
+	recorder *engineRecorder
+}
+
+func (api *recordingAPI) ForkchoiceUpdatedV1(update beacon.ForkchoiceStateV1, payloadAttributes *beacon.PayloadAttributesV1) (beacon.ForkChoiceResponse, error) {
+	start := time.Now()
+	resp, err := api.ConsensusAPI.ForkchoiceUpdatedV1(update, payloadAttributes)
+	api.recorder.record("engine_forkchoiceUpdatedV1", start, resp, err, update, payloadAttributes)
+	return resp, err
+}
+
+func (api *recordingAPI) ExchangeTransitionConfigurationV1(config beacon.TransitionConfigurationV1) (*beacon.TransitionConfigurationV1, error) {
+	start := time.Now()
+	resp, err := api.ConsensusAPI.ExchangeTransitionConfigurationV1(config)
+	api.recorder.record("engine_exchangeTransitionConfigurationV1", start, resp, err, config)
+	return resp, err
+}
+
+func (api *recordingAPI) GetPayloadV1(payloadID beacon.PayloadID) (*beacon.ExecutableDataV1, error) {
+	start := time.Now()
+	data, err := api.ConsensusAPI.GetPayloadV1(payloadID)
+	api.recorder.record("engine_getPayloadV1", start, data, err, payloadID)
+	return data, err
+}
+
+func (api *recordingAPI) NewPayloadV1(params beacon.ExecutableDataV1) (beacon.PayloadStatusV1, error) {
+	start := time.Now()
+	status, err := api.ConsensusAPI.NewPayloadV1(params)
+	api.recorder.record("engine_newPayloadV1", start, status, err, params)
+	return status, err
+}
+
+// ReplayEngineCall feeds a recorded call into the given API and returns its
+// result.
+func ReplayEngineCall(api *ConsensusAPI, record *EngineRecord) (interface{}, error) {
+	switch record.Method {
+	case "engine_forkchoiceUpdatedV1":
+		var (
+			update     beacon.ForkchoiceStateV1
+			attributes *beacon.PayloadAttributesV1
+		)
+		if err := decodeEngineParams(record, &update, &attributes); err != nil {
+			return nil, err
+		}
+		return api.ForkchoiceUpdatedV1(update, attributes)
+
+	case "engine_exchangeTransitionConfigurationV1":
+		var config beacon.TransitionConfigurationV1
+		if err := decodeEngineParams(record, &config); err != nil {
+			return nil, err
+		}
+		return api.ExchangeTransitionConfigurationV1(config)
+
+	case "engine_getPayloadV1":
+		var id beacon.PayloadID
+		if err := decodeEngineParams(record, &id); err != nil {
+			return nil, err
+		}
+		return api.GetPayloadV1(id)
+
+	case "engine_newPayloadV1":
+		var params beacon.ExecutableDataV1
+		if err := decodeEngineParams(record, &params); err != nil {
+			return nil, err
+		}
+		return api.NewPayloadV1(params)
+
+	case "engine_getPayloadBodiesByHashV1":
+		var hashes []common.Hash
+		if err := decodeEngineParams(record, &hashes); err != nil {
+			return nil, err
+		}
+		return api.GetPayloadBodiesByHashV1(hashes)
+
+	case "engine_getPayloadBodiesByRangeV1":
+		var start, count hexutil.Uint64
+		if err := decodeEngineParams(record, &start, &count); err != nil {
+			return nil, err
+		}
+		return api.GetPayloadBodiesByRangeV1(start, count)
+	}
+	return nil, fmt.Errorf("unsupported engine method %q", record.Method)
+}
+
+// decodeEngineParams decodes the parameters of a recorded call.
+func decodeEngineParams(record *EngineRecord, params ...interface{}) error {
+	if len(record.Params) > len(params) {
+		return fmt.Errorf("too many parameters for %s: have %d, want at most %d", record.Method, len(record.Params), len(params))
+	}
+	for i, blob := range record.Params {
+		if err := json.Unmarshal(blob, params[i]); err != nil {
+			return fmt.Errorf("invalid parameter %d of %s: %v", i, record.Method, err)
+		}
+	}
+	return nil
+}
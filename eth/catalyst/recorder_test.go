@@ -0,0 +1,100 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/beacon"
+)
+
+func TestEngineRecordReplay(t *testing.T) {
+	genesis, preMergeBlocks := generatePreMergeChain(10)
+	n, ethservice := startEthService(t, genesis, preMergeBlocks)
+	defer n.Close()
+
+	path := filepath.Join(t.TempDir(), "engine.jsonl")
+	recorder, err := newEngineRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := &recordingAPI{ConsensusAPI: NewConsensusAPI(ethservice), recorder: recorder}
+
+	// Drive the chain through the recording API, including a failing call
+	if _, err := api.GetPayloadV1(beacon.PayloadID{0x01}); err == nil {
+		t.Fatal("unknown payload retrieved")
+	}
+	parent := ethservice.BlockChain().CurrentBlock()
+	for i := 0; i < 3; i++ {
+		payload := getNewPayload(t, api.ConsensusAPI, parent)
+		if status, err := api.NewPayloadV1(*payload); err != nil || status.Status != beacon.VALID {
+			t.Fatalf("payload %d not accepted: %v %v", i, status.Status, err)
+		}
+		update := beacon.ForkchoiceStateV1{HeadBlockHash: payload.BlockHash, SafeBlockHash: payload.ParentHash, FinalizedBlockHash: payload.ParentHash}
+		if _, err := api.ForkchoiceUpdatedV1(update, nil); err != nil {
+			t.Fatal(err)
+		}
+		parent = ethservice.BlockChain().CurrentBlock()
+	}
+	if err := recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	// Replay the recording into a fresh node and check it ends up on the same head
+	n2, ethservice2 := startEthService(t, genesis, preMergeBlocks)
+	defer n2.Close()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var (
+		replayer = NewConsensusAPI(ethservice2)
+		dec      = json.NewDecoder(file)
+		calls    int
+	)
+	for dec.More() {
+		var record EngineRecord
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		result, err := ReplayEngineCall(replayer, &record)
+		if record.Error != "" {
+			if err == nil || err.Error() != record.Error {
+				t.Fatalf("call %d (%s) error mismatch: have %v, want %s", calls, record.Method, err, record.Error)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("call %d (%s) failed: %v", calls, record.Method, err)
+			}
+			if blob, _ := json.Marshal(result); string(blob) != string(record.Result) {
+				t.Fatalf("call %d (%s) result mismatch: have %s, want %s", calls, record.Method, blob, record.Result)
+			}
+		}
+		calls++
+	}
+	if calls != 7 {
+		t.Fatalf("recorded calls mismatch: have %d, want 7", calls)
+	}
+	if head := ethservice2.BlockChain().CurrentBlock(); head.Hash() != parent.Hash() {
+		t.Fatalf("replayed head mismatch: have %d (%x), want %d (%x)", head.NumberU64(), head.Hash(), parent.NumberU64(), parent.Hash())
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/beacon"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// maxTrackedDeliveries is the number of recent payload deliveries the delivery
+// times are reported over.
+const maxTrackedDeliveries = 64
+
+// errSequencerStopped is returned for payload building requests while the
+// sequencer is stopped.
+var errSequencerStopped = errors.New("sequencer stopped")
+
+// SequencerPayload describes the last payload delivered to the rollup node.
+type SequencerPayload struct {
+	Number uint64        `json:"number"`
+	Hash   common.Hash   `json:"hash"`
+	Txs    int           `json:"txs"`
+	Gas    uint64        `json:"gas"`
+	Time   time.Time     `json:"time"` // Time the payload was delivered
+	Age    time.Duration `json:"age"`  // in nanoseconds
+}
+
+// SequencerDeliveryTimes are the times between requesting payloads and having
+// them delivered, over the recent deliveries.
+type SequencerDeliveryTimes struct {
+	Last    time.Duration `json:"last"` // in nanoseconds
+	Average time.Duration `json:"average"`
+	Max     time.Duration `json:"max"`
+}
+
+// SequencerStatus reports the block production of the node.
+type SequencerStatus struct {
+	Stopped bool        `json:"stopped"` // Whether payload building requests are refused
+	Syncing bool        `json:"syncing"`
+	Head    common.Hash `json:"head"`
+
+	Requested uint64 `json:"requested"` // Payload building requests served
+	Delivered uint64 `json:"delivered"` // Payloads retrieved
+	Refused   uint64 `json:"refused"`   // Payload building requests refused while stopped
+
+	LastPayload   *SequencerPayload       `json:"lastPayload,omitempty"` // Nil until a payload was delivered
+	PendingTxs    int                     `json:"pendingTxs"`
+	QueuedTxs     int                     `json:"queuedTxs"`
+	BuildTime     time.Duration           `json:"buildTime"` // Last full payload build, in nanoseconds
+	DeliveryTimes *SequencerDeliveryTimes `json:"deliveryTimes,omitempty"`
+}
+
+// sequencerState tracks the payloads built through the engine API and whether
+// building them is permitted.
+type sequencerState struct {
+	lock sync.Mutex
+
+	stopped    bool
+	requests   map[beacon.PayloadID]time.Time // Build requests not yet delivered
+	deliveries []time.Duration                // Recent delivery times, oldest first
+	last       *SequencerPayload
+
+	requested, delivered, refused uint64
+}
+
+func newSequencerState() *sequencerState {
+	return &sequencerState{requests: make(map[beacon.PayloadID]time.Time)}
+}
+
+// refuse reports whether payload building requests are refused, counting the
+// refusal if so.
+func (s *sequencerState) refuse() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopped {
+		s.refused++
+	}
+	return s.stopped
+}
+
+// requestedPayload records a payload building request.
+func (s *sequencerState) requestedPayload(id beacon.PayloadID) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requested++
+	if _, ok := s.requests[id]; ok {
+		return // Repeated request, keep timing from the first
+	}
+	// Forget the oldest request if the payload is no longer tracked anyway
+	if len(s.requests) >= maxTrackedPayloads {
+		var (
+			oldest   beacon.PayloadID
+			earliest time.Time
+		)
+		for id, t := range s.requests {
+			if earliest.IsZero() || t.Before(earliest) {
+				oldest, earliest = id, t
+			}
+		}
+		delete(s.requests, oldest)
+	}
+	s.requests[id] = time.Now()
+}
+
+// deliveredPayload records the retrieval of a payload.
+func (s *sequencerState) deliveredPayload(id beacon.PayloadID, data *beacon.ExecutableDataV1) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now()
+	s.delivered++
+	s.last = &SequencerPayload{
+		Number: data.Number,
+		Hash:   data.BlockHash,
+		Txs:    len(data.Transactions),
+		Gas:    data.GasUsed,
+		Time:   now,
+	}
+	if requested, ok := s.requests[id]; ok {
+		delete(s.requests, id)
+		if len(s.deliveries) >= maxTrackedDeliveries {
+			s.deliveries = append(s.deliveries[:0], s.deliveries[1:]...)
+		}
+		s.deliveries = append(s.deliveries, now.Sub(requested))
+	}
+}
+
+// SequencerAPI offers the block production diagnostics of the node and allows
+// stopping it for handing sequencing over to another node.
+type SequencerAPI struct {
+	api *ConsensusAPI
+}
+
+// NewSequencerAPI creates the sequencer API of the given engine API.
+func NewSequencerAPI(api *ConsensusAPI) *SequencerAPI {
+	return &SequencerAPI{api: api}
+}
+
+// SequencerStatus reports the payloads built by the node, the transactions
+// waiting in the pool and whether the node is syncing.
+func (s *SequencerAPI) SequencerStatus() *SequencerStatus {
+	var (
+		eth    = s.api.eth
+		state  = s.api.sequencer
+		status = &SequencerStatus{
+			Syncing:   eth.Downloader().Synchronising(),
+			Head:      eth.BlockChain().CurrentBlock().Hash(),
+			BuildTime: eth.Miner().PayloadBuildTime(),
+		}
+	)
+	status.PendingTxs, status.QueuedTxs = eth.TxPool().Stats()
+
+	state.lock.Lock()
+	defer state.lock.Unlock()
+
+	status.Stopped = state.stopped
+	status.Requested, status.Delivered, status.Refused = state.requested, state.delivered, state.refused
+	if state.last != nil {
+		last := *state.last
+		last.Age = time.Since(last.Time)
+		status.LastPayload = &last
+	}
+	if len(state.deliveries) > 0 {
+		times := &SequencerDeliveryTimes{Last: state.deliveries[len(state.deliveries)-1]}
+		var total time.Duration
+		for _, t := range state.deliveries {
+			total += t
+			if t > times.Max {
+				times.Max = t
+			}
+		}
+		times.Average = total / time.Duration(len(state.deliveries))
+		status.DeliveryTimes = times
+	}
+	return status
+}
+
+// StopSequencer makes the node refuse payload building requests, while still
+// following the chain, and returns the hash of the current head for the next
+// sequencer to build on.
+func (s *SequencerAPI) StopSequencer() common.Hash {
+	state := s.api.sequencer
+	state.lock.Lock()
+	state.stopped = true
+	state.lock.Unlock()
+
+	head := s.api.eth.BlockChain().CurrentBlock()
+	log.Warn("Sequencer stopped, refusing payload building requests", "number", head.NumberU64(), "hash", head.Hash())
+	return head.Hash()
+}
+
+// StartSequencer makes the node accept payload building requests again.
+func (s *SequencerAPI) StartSequencer() {
+	state := s.api.sequencer
+	state.lock.Lock()
+	state.stopped = false
+	state.lock.Unlock()
+
+	log.Info("Sequencer started, accepting payload building requests")
+}
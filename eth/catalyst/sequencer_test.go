@@ -0,0 +1,84 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package catalyst
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/beacon"
+)
+
+func TestSequencerStatus(t *testing.T) {
+	genesis, blocks := generatePreMergeChain(10)
+	genesis.Config.TerminalTotalDifficulty.Sub(genesis.Config.TerminalTotalDifficulty, blocks[9].Difficulty())
+	n, ethservice := startEthService(t, genesis, blocks[:9])
+	defer n.Close()
+
+	var (
+		api       = NewConsensusAPI(ethservice)
+		sequencer = NewSequencerAPI(api)
+		update    = beacon.ForkchoiceStateV1{HeadBlockHash: blocks[8].Hash()}
+		attrs     = &beacon.PayloadAttributesV1{Timestamp: blocks[8].Time() + 5}
+	)
+	ethservice.TxPool().AddLocals(blocks[9].Transactions())
+
+	if status := sequencer.SequencerStatus(); status.Stopped || status.LastPayload != nil || status.PendingTxs != len(blocks[9].Transactions()) {
+		t.Fatalf("initial status mismatch: have %+v", status)
+	}
+	resp, err := api.ForkchoiceUpdatedV1(update, attrs)
+	if err != nil || resp.PayloadID == nil {
+		t.Fatalf("payload not built: %v", err)
+	}
+	data, err := api.GetPayloadV1(*resp.PayloadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := sequencer.SequencerStatus()
+	if status.Requested != 1 || status.Delivered != 1 || status.DeliveryTimes == nil {
+		t.Fatalf("payload counters mismatch: have %+v", status)
+	}
+	if last := status.LastPayload; last == nil || last.Hash != data.BlockHash || last.Txs != len(data.Transactions) {
+		t.Fatalf("last payload mismatch: have %+v", last)
+	}
+	// Hand sequencing over, the head still follows but payloads are refused
+	if head := sequencer.StopSequencer(); head != blocks[8].Hash() {
+		t.Fatalf("handover head mismatch: have %x, want %x", head, blocks[8].Hash())
+	}
+	attrs.Timestamp++
+	if _, err := api.ForkchoiceUpdatedV1(update, attrs); err == nil {
+		t.Fatal("stopped sequencer built payload")
+	} else if data := err.(*beacon.EngineAPIError).ErrorData(); !reflect.DeepEqual(data, struct {
+		Error string `json:"err"`
+	}{errSequencerStopped.Error()}) {
+		t.Fatalf("stopped sequencer error mismatch: have %v, want %v", data, errSequencerStopped)
+	}
+	if _, err := api.ForkchoiceUpdatedV1(update, nil); err != nil {
+		t.Fatalf("stopped sequencer refused forkchoice update: %v", err)
+	}
+	derived := &beacon.PayloadAttributesV1{Timestamp: attrs.Timestamp, NoTxPool: true}
+	if resp, err := api.ForkchoiceUpdatedV1(update, derived); err != nil || resp.PayloadID == nil {
+		t.Fatalf("stopped sequencer refused derived payload: %v", err)
+	}
+	if status := sequencer.SequencerStatus(); !status.Stopped || status.Refused != 1 {
+		t.Fatalf("stopped status mismatch: have %+v", status)
+	}
+	sequencer.StartSequencer()
+	if resp, err := api.ForkchoiceUpdatedV1(update, attrs); err != nil || resp.PayloadID == nil {
+		t.Fatalf("restarted sequencer refused payload: %v", err)
+	}
+}
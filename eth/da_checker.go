@@ -0,0 +1,509 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// daPollInterval is the interval at which L1 is polled for new batches.
+	daPollInterval = 12 * time.Second
+
+	// daMaxBlocksPerPoll is the maximum number of L1 blocks scanned per poll,
+	// to bound the time spent catching up after a restart.
+	daMaxBlocksPerPoll = 256
+
+	// daChannelTimeout is the number of L1 blocks after which incomplete
+	// channels are dropped, matching the rollup's channel timeout.
+	daChannelTimeout = 300
+
+	// daMaxMismatches is the number of mismatching blocks remembered.
+	daMaxMismatches = 64
+
+	// daMaxRLPBytesPerChannel is the maximum decompressed size of a channel,
+	// matching the limit of the rollup's derivation.
+	daMaxRLPBytesPerChannel = 10_000_000
+
+	// daMaxFramesPerChannel is the maximum number of frames of a channel.
+	daMaxFramesPerChannel = 1024
+
+	// Sizes of the fixed fields of a batch frame.
+	daChannelIDLength = 16
+	daFrameOverhead   = daChannelIDLength + 2 + 4 + 1
+)
+
+var (
+	daLagGauge       = metrics.NewRegisteredGauge("rollup/da/lag", nil)     // Local blocks not yet posted to L1
+	daLagTimeGauge   = metrics.NewRegisteredGauge("rollup/da/lagtime", nil) // Seconds between the head and the last posted block
+	daL1BlockGauge   = metrics.NewRegisteredGauge("rollup/da/l1block", nil)
+	daMismatchMeter  = metrics.NewRegisteredMeter("rollup/da/mismatch", nil)
+	daBadFramesMeter = metrics.NewRegisteredMeter("rollup/da/badframes", nil)
+)
+
+// DACheckConfig contains the settings of the data availability checker.
+type DACheckConfig struct {
+	L1RPC      string         // Endpoint of the L1 node
+	BatchInbox common.Address // L1 address batches are posted to
+	Batcher    common.Address // Sender of batch transactions, any if zero
+	L1Start    uint64         // L1 block to start scanning from, 0 to start a channel timeout behind the head
+}
+
+// DAMismatch is a local block which differs from its posted batch.
+type DAMismatch struct {
+	Number  hexutil.Uint64 `json:"number"`
+	Hash    common.Hash    `json:"hash"`
+	L1Block hexutil.Uint64 `json:"l1Block"` // L1 block completing the channel of the batch
+	Reason  string         `json:"reason"`
+}
+
+// DAStatus describes how far local blocks are represented in batches posted
+// to L1.
+type DAStatus struct {
+	L1Block     hexutil.Uint64 `json:"l1Block"`     // Last scanned L1 block
+	PostedBlock hexutil.Uint64 `json:"postedBlock"` // Highest local block found in a posted batch
+	PostedHash  common.Hash    `json:"postedHash"`
+	HeadBlock   hexutil.Uint64 `json:"headBlock"`
+	Lag         hexutil.Uint64 `json:"lag"`     // Number of local blocks not yet posted
+	LagTime     hexutil.Uint64 `json:"lagTime"` // Seconds between the head and the last posted block
+	Mismatches  []DAMismatch   `json:"mismatches"`
+	Error       string         `json:"error,omitempty"` // Last error talking to L1
+}
+
+// daBatch is a singular batch as posted to L1.
+type daBatch struct {
+	ParentHash   common.Hash
+	EpochNum     uint64
+	EpochHash    common.Hash
+	Timestamp    uint64
+	Transactions [][]byte
+}
+
+// daChannel collects the frames of a channel until it is complete.
+type daChannel struct {
+	frames map[uint16][]byte
+	last   int    // number of the closing frame, -1 until seen
+	opened uint64 // L1 block of the first frame
+}
+
+// daChannelBank reassembles channels from the frames of batch transactions.
+type daChannelBank struct {
+	channels map[[daChannelIDLength]byte]*daChannel
+}
+
+func newDAChannelBank() *daChannelBank {
+	return &daChannelBank{channels: make(map[[daChannelIDLength]byte]*daChannel)}
+}
+
+// addData parses the frames in the data of a batch transaction included in the
+// given L1 block and returns the batches of all channels completed by them.
+func (b *daChannelBank) addData(data []byte, l1Block uint64) ([]*daBatch, error) {
+	if len(data) == 0 || data[0] != 0 {
+		return nil, errors.New("unknown derivation version")
+	}
+	data = data[1:]
+
+	var batches []*daBatch
+	for len(data) > 0 {
+		if len(data) < daFrameOverhead {
+			return batches, errors.New("truncated frame header")
+		}
+		var id [daChannelIDLength]byte
+		copy(id[:], data)
+		number := binary.BigEndian.Uint16(data[daChannelIDLength:])
+		size := binary.BigEndian.Uint32(data[daChannelIDLength+2:])
+		data = data[daChannelIDLength+6:]
+		if uint64(len(data)) < uint64(size)+1 {
+			return batches, errors.New("truncated frame data")
+		}
+		frame, isLast := data[:size], data[size]
+		if isLast > 1 {
+			return batches, errors.New("invalid frame closing flag")
+		}
+		data = data[size+1:]
+
+		if number >= daMaxFramesPerChannel {
+			return batches, fmt.Errorf("channel %x: frame %d exceeds limit of %d frames", id, number, daMaxFramesPerChannel)
+		}
+		ch := b.channels[id]
+		if ch == nil {
+			ch = &daChannel{frames: make(map[uint16][]byte), last: -1, opened: l1Block}
+			b.channels[id] = ch
+		}
+		if _, ok := ch.frames[number]; ok {
+			continue // duplicate frames are ignored
+		}
+		ch.frames[number] = frame
+		if isLast == 1 {
+			ch.last = int(number)
+		}
+		if ch.last < 0 || len(ch.frames) != ch.last+1 {
+			continue
+		}
+		delete(b.channels, id)
+		decoded, err := ch.decode()
+		if err != nil {
+			return batches, fmt.Errorf("channel %x: %v", id, err)
+		}
+		batches = append(batches, decoded...)
+	}
+	return batches, nil
+}
+
+// prune drops channels which timed out before being completed.
+func (b *daChannelBank) prune(l1Block uint64) {
+	for id, ch := range b.channels {
+		if ch.opened+daChannelTimeout < l1Block {
+			delete(b.channels, id)
+		}
+	}
+}
+
+// decode decompresses a complete channel and decodes its batches.
+func (ch *daChannel) decode() ([]*daBatch, error) {
+	var data []byte
+	for i := 0; i <= ch.last; i++ {
+		frame, ok := ch.frames[uint16(i)]
+		if !ok {
+			return nil, fmt.Errorf("missing frame %d", i)
+		}
+		data = append(data, frame...)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	// The decompressed channel is bounded, as a small channel may inflate to
+	// an arbitrary size.
+	var (
+		batches []*daBatch
+		stream  = rlp.NewStream(io.LimitReader(zr, daMaxRLPBytesPerChannel), daMaxRLPBytesPerChannel)
+	)
+	for {
+		blob, err := stream.Bytes()
+		if err == io.EOF {
+			return batches, nil
+		}
+		if err != nil {
+			return batches, err
+		}
+		if len(blob) == 0 || blob[0] != 0 {
+			return batches, errors.New("unknown batch version")
+		}
+		batch := new(daBatch)
+		if err := rlp.DecodeBytes(blob[1:], batch); err != nil {
+			return batches, err
+		}
+		batches = append(batches, batch)
+	}
+}
+
+// daL1Block is the part of an L1 block relevant to the checker. Blocks are
+// decoded from their JSON representation to remain independent of the L1
+// transaction types known to this node.
+type daL1Block struct {
+	Number       hexutil.Uint64 `json:"number"`
+	Transactions []struct {
+		From  common.Address  `json:"from"`
+		To    *common.Address `json:"to"`
+		Input hexutil.Bytes   `json:"input"`
+	} `json:"transactions"`
+}
+
+// daChecker cross-checks local blocks against the batches posted to L1.
+type daChecker struct {
+	config DACheckConfig
+	chain  *core.BlockChain
+	l1     *rpc.Client
+	bank   *daChannelBank
+
+	mu         sync.Mutex
+	next       uint64        // next L1 block to scan
+	posted     *types.Header // highest local block found in a posted batch
+	mismatches []DAMismatch  // most recent mismatches, oldest first
+	err        error         // last error talking to L1
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newDAChecker(config DACheckConfig, chain *core.BlockChain) (*daChecker, error) {
+	l1, err := rpc.Dial(config.L1RPC)
+	if err != nil {
+		return nil, err
+	}
+	return &daChecker{
+		config: config,
+		chain:  chain,
+		l1:     l1,
+		bank:   newDAChannelBank(),
+		next:   config.L1Start,
+		quit:   make(chan struct{}),
+	}, nil
+}
+
+// Start implements node.Lifecycle, launching the background scanning loop.
+func (c *daChecker) Start() error {
+	c.wg.Add(1)
+	go c.loop()
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating the scanning loop.
+func (c *daChecker) Stop() error {
+	close(c.quit)
+	c.wg.Wait()
+	c.l1.Close()
+	return nil
+}
+
+func (c *daChecker) loop() {
+	defer c.wg.Done()
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			ctx, cancel := context.WithTimeout(context.Background(), daPollInterval)
+			err := c.poll(ctx)
+			cancel()
+
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+			if err != nil {
+				log.Warn("Failed to check posted batches", "err", err)
+			}
+			c.updateMetrics()
+			timer.Reset(daPollInterval)
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// poll scans the L1 blocks up to the head for batches.
+func (c *daChecker) poll(ctx context.Context) error {
+	var head hexutil.Uint64
+	if err := c.l1.CallContext(ctx, &head, "eth_blockNumber"); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if c.next == 0 && uint64(head) > daChannelTimeout {
+		c.next = uint64(head) - daChannelTimeout
+	}
+	next := c.next
+	c.mu.Unlock()
+
+	for n := next; n <= uint64(head) && n < next+daMaxBlocksPerPoll; n++ {
+		var block *daL1Block
+		if err := c.l1.CallContext(ctx, &block, "eth_getBlockByNumber", hexutil.Uint64(n), true); err != nil {
+			return err
+		}
+		if block == nil {
+			return fmt.Errorf("L1 block %d not found", n)
+		}
+		for _, tx := range block.Transactions {
+			if tx.To == nil || *tx.To != c.config.BatchInbox {
+				continue
+			}
+			if c.config.Batcher != (common.Address{}) && tx.From != c.config.Batcher {
+				continue
+			}
+			batches, err := c.bank.addData(tx.Input, n)
+			if err != nil {
+				daBadFramesMeter.Mark(1)
+				log.Debug("Invalid batch transaction data", "l1", n, "err", err)
+			}
+			for _, batch := range batches {
+				c.checkBatch(batch, n)
+			}
+		}
+		c.bank.prune(n)
+
+		c.mu.Lock()
+		c.next = n + 1
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// checkBatch compares a posted batch with the local block of the same timestamp.
+func (c *daChecker) checkBatch(batch *daBatch, l1Block uint64) {
+	header := c.headerByTime(batch.Timestamp)
+	if header == nil {
+		return // batch is ahead of the local chain
+	}
+	if reason := c.compare(header, batch); reason != "" {
+		log.Error("Local block differs from posted batch", "number", header.Number, "hash", header.Hash(), "l1", l1Block, "reason", reason)
+		daMismatchMeter.Mark(1)
+
+		c.mu.Lock()
+		c.mismatches = append(c.mismatches, DAMismatch{
+			Number:  hexutil.Uint64(header.Number.Uint64()),
+			Hash:    header.Hash(),
+			L1Block: hexutil.Uint64(l1Block),
+			Reason:  reason,
+		})
+		if len(c.mismatches) > daMaxMismatches {
+			c.mismatches = c.mismatches[len(c.mismatches)-daMaxMismatches:]
+		}
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Lock()
+	if c.posted == nil || header.Number.Cmp(c.posted.Number) > 0 {
+		c.posted = header
+	}
+	c.mu.Unlock()
+}
+
+// compare returns why a local block doesn't match a batch, or an empty string
+// if it does. Deposits are not part of batches and are skipped.
+func (c *daChecker) compare(header *types.Header, batch *daBatch) string {
+	if header.ParentHash != batch.ParentHash {
+		return fmt.Sprintf("parent hash %x, batch has %x", header.ParentHash, batch.ParentHash)
+	}
+	block := c.chain.GetBlock(header.Hash(), header.Number.Uint64())
+	if block == nil {
+		return "block body missing"
+	}
+	var local []common.Hash
+	for _, tx := range block.Transactions() {
+		if tx.Type() != types.DepositTxType {
+			local = append(local, tx.Hash())
+		}
+	}
+	if len(local) != len(batch.Transactions) {
+		return fmt.Sprintf("%d transactions, batch has %d", len(local), len(batch.Transactions))
+	}
+	for i, blob := range batch.Transactions {
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(blob); err != nil {
+			return fmt.Sprintf("invalid batch transaction %d: %v", i, err)
+		}
+		if tx.Hash() != local[i] {
+			return fmt.Sprintf("transaction %d is %x, batch has %x", i, local[i], tx.Hash())
+		}
+	}
+	return ""
+}
+
+// headerByTime returns the canonical header with the given timestamp, or nil if
+// there is none.
+func (c *daChecker) headerByTime(time uint64) *types.Header {
+	head := c.chain.CurrentHeader().Number.Uint64()
+	n := sort.Search(int(head)+1, func(i int) bool {
+		header := c.chain.GetHeaderByNumber(uint64(i))
+		return header == nil || header.Time >= time
+	})
+	if n > int(head) {
+		return nil
+	}
+	if header := c.chain.GetHeaderByNumber(uint64(n)); header != nil && header.Time == time {
+		return header
+	}
+	return nil
+}
+
+// status reports the current data availability lag.
+func (c *daChecker) status() *DAStatus {
+	head := c.chain.CurrentHeader()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := &DAStatus{
+		HeadBlock:  hexutil.Uint64(head.Number.Uint64()),
+		Lag:        hexutil.Uint64(head.Number.Uint64()),
+		Mismatches: append([]DAMismatch{}, c.mismatches...),
+	}
+	if c.next > 0 {
+		status.L1Block = hexutil.Uint64(c.next - 1)
+	}
+	if c.posted != nil {
+		status.PostedBlock = hexutil.Uint64(c.posted.Number.Uint64())
+		status.PostedHash = c.posted.Hash()
+		status.Lag = 0
+		if head.Number.Cmp(c.posted.Number) > 0 {
+			status.Lag = hexutil.Uint64(head.Number.Uint64() - c.posted.Number.Uint64())
+		}
+		if head.Time > c.posted.Time {
+			status.LagTime = hexutil.Uint64(head.Time - c.posted.Time)
+		}
+	}
+	if c.err != nil {
+		status.Error = c.err.Error()
+	}
+	return status
+}
+
+func (c *daChecker) updateMetrics() {
+	status := c.status()
+	daLagGauge.Update(int64(status.Lag))
+	daLagTimeGauge.Update(int64(status.LagTime))
+	daL1BlockGauge.Update(int64(status.L1Block))
+}
+
+// RegisterDAChecker adds a service to the stack checking that the blocks of the
+// backend are represented in batches posted to L1, and exposes its status as
+// rollup_daStatus.
+func RegisterDAChecker(stack *node.Node, backend *Ethereum, config DACheckConfig) error {
+	c, err := newDAChecker(config, backend.BlockChain())
+	if err != nil {
+		return err
+	}
+	stack.RegisterLifecycle(c)
+	stack.RegisterAPIs([]rpc.API{{
+		Namespace: "rollup",
+		Service:   &DACheckAPI{c},
+	}})
+	return nil
+}
+
+// DACheckAPI exposes the state of the data availability checker.
+type DACheckAPI struct {
+	c *daChecker
+}
+
+// DAStatus returns how far local blocks are represented in batches posted to L1.
+func (api *DACheckAPI) DAStatus() *DAStatus {
+	return api.c.status()
+}
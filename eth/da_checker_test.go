@@ -0,0 +1,115 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// encodeDAFrame encodes a single batch frame.
+func encodeDAFrame(id [daChannelIDLength]byte, number uint16, data []byte, last bool) []byte {
+	var header [6]byte
+	binary.BigEndian.PutUint16(header[:2], number)
+	binary.BigEndian.PutUint32(header[2:], uint32(len(data)))
+
+	frame := append(append([]byte{}, id[:]...), header[:]...)
+	frame = append(frame, data...)
+	if last {
+		return append(frame, 1)
+	}
+	return append(frame, 0)
+}
+
+func TestDAChannelBank(t *testing.T) {
+	batches := []*daBatch{
+		{ParentHash: common.Hash{1}, EpochNum: 10, Timestamp: 100, Transactions: [][]byte{{0xaa}}},
+		{ParentHash: common.Hash{2}, EpochNum: 10, Timestamp: 102, Transactions: [][]byte{}},
+	}
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	for _, batch := range batches {
+		blob, _ := rlp.EncodeToBytes(batch)
+		rlp.Encode(zw, append([]byte{0}, blob...))
+	}
+	zw.Close()
+	channel := buf.Bytes()
+
+	// Split the channel over two transactions, delivering the closing frame first.
+	var (
+		id   = [daChannelIDLength]byte{0xc0}
+		half = len(channel) / 2
+		bank = newDAChannelBank()
+	)
+	tx1 := append([]byte{0}, encodeDAFrame(id, 1, channel[half:], true)...)
+	tx2 := append([]byte{0}, encodeDAFrame(id, 0, channel[:half], false)...)
+
+	have, err := bank.addData(tx1, 1)
+	if err != nil || len(have) != 0 {
+		t.Fatalf("incomplete channel: have %d batches, err %v", len(have), err)
+	}
+	if have, err = bank.addData(tx2, 2); err != nil {
+		t.Fatalf("failed to decode channel: %v", err)
+	}
+	if len(have) != len(batches) {
+		t.Fatalf("have %d batches, want %d", len(have), len(batches))
+	}
+	for i, batch := range have {
+		if batch.ParentHash != batches[i].ParentHash || batch.Timestamp != batches[i].Timestamp || len(batch.Transactions) != len(batches[i].Transactions) {
+			t.Errorf("batch %d mismatch: have %+v, want %+v", i, batch, batches[i])
+		}
+	}
+	if len(bank.channels) != 0 {
+		t.Fatalf("completed channel not removed")
+	}
+
+	// Incomplete channels time out.
+	bank.addData(tx1, 10)
+	bank.prune(10 + daChannelTimeout)
+	if len(bank.channels) != 1 {
+		t.Fatalf("channel pruned before timeout")
+	}
+	bank.prune(11 + daChannelTimeout)
+	if len(bank.channels) != 0 {
+		t.Fatalf("channel not pruned after timeout")
+	}
+
+	// Malformed data is rejected.
+	if _, err := bank.addData([]byte{1}, 20); err == nil {
+		t.Fatal("unknown derivation version accepted")
+	}
+	if _, err := bank.addData(tx1[:len(tx1)-2], 20); err == nil {
+		t.Fatal("truncated frame accepted")
+	}
+	if _, err := bank.addData(append([]byte{0}, encodeDAFrame(id, daMaxFramesPerChannel, channel, false)...), 20); err == nil {
+		t.Fatal("frame beyond the channel limit accepted")
+	}
+
+	// Channels inflating beyond the limit are rejected.
+	buf.Reset()
+	zw = zlib.NewWriter(&buf)
+	rlp.Encode(zw, make([]byte, daMaxRLPBytesPerChannel+1))
+	zw.Close()
+	if _, err := bank.addData(append([]byte{0}, encodeDAFrame([daChannelIDLength]byte{0xc1}, 0, buf.Bytes(), true)...), 20); err == nil {
+		t.Fatal("oversized channel accepted")
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// dbRefresher periodically refreshes the snapshot of a database shared with the
+// node owning it and moves the chain to the head of the owner.
+type dbRefresher struct {
+	db       *rawdb.SharedDatabase
+	chain    *core.BlockChain
+	interval time.Duration
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newDBRefresher creates an idle refresher of the given shared database.
+func newDBRefresher(db *rawdb.SharedDatabase, chain *core.BlockChain, interval time.Duration) *dbRefresher {
+	return &dbRefresher{
+		db:       db,
+		chain:    chain,
+		interval: interval,
+		quit:     make(chan struct{}),
+	}
+}
+
+// start starts refreshing the database in the background.
+func (r *dbRefresher) start() {
+	r.wg.Add(1)
+	go r.loop()
+}
+
+// loop refreshes the database every interval until closed.
+func (r *dbRefresher) loop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.refresh()
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+// refresh reopens the snapshot of the database and reloads the chain head from
+// it. Failures are transient, the owner may be in the middle of a compaction.
+func (r *dbRefresher) refresh() {
+	if err := r.db.Refresh(); err != nil {
+		log.Warn("Failed to refresh shared database", "err", err)
+		return
+	}
+	if err := r.chain.ReloadHead(); err != nil {
+		log.Warn("Failed to reload shared chain head", "err", err)
+	}
+}
+
+// close stops refreshing the database.
+func (r *dbRefresher) close() {
+	close(r.quit)
+	r.wg.Wait()
+}
@@ -79,6 +79,7 @@ var (
 	errTooOld                  = errors.New("peer's protocol version too old")
 	errNoAncestorFound         = errors.New("no common ancestor found")
 	errNoPivotHeader           = errors.New("pivot header is not found")
+	errNoPivotState            = errors.New("chain too short to sync the missing genesis state")
 	ErrMergeTransition         = errors.New("legacy sync reached the merge")
 )
 
@@ -181,6 +182,9 @@ type BlockChain interface {
 	// HasFastBlock verifies a snap block's presence in the local chain.
 	HasFastBlock(common.Hash, uint64) bool
 
+	// HasState checks if the state trie with the given root is present locally.
+	HasState(common.Hash) bool
+
 	// GetBlockByHash retrieves a block from the local chain.
 	GetBlockByHash(common.Hash) *types.Block
 
@@ -507,6 +511,12 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td, ttd *
 	// nil panics on access.
 	if mode == SnapSync && pivot == nil {
 		pivot = d.blockchain.CurrentBlock().Header()
+
+		// A chain started from a genesis committed without its state can't be
+		// imported block by block, wait for the chain to reach a state pivot.
+		if pivot.Number.Uint64() == 0 && !d.blockchain.HasState(pivot.Root) {
+			return errNoPivotState
+		}
 	}
 	height := latest.Number.Uint64()
 
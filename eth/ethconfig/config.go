@@ -18,6 +18,7 @@
 package ethconfig
 
 import (
+	"crypto/ecdsa"
 	"math/big"
 	"os"
 	"os/user"
@@ -83,16 +84,31 @@ var Defaults = Config{
 	TrieDirtyCache:          256,
 	TrieTimeout:             60 * time.Minute,
 	SnapshotCache:           102,
+	StateHistory:            params.FullImmutabilityThreshold,
+	DatabaseColdAge:         30 * 24 * time.Hour,
+
+	DatabaseFreezerRemoteRegion: "us-east-1",
+	DatabaseFreezerRemoteCache:  4096,
+	DatabaseRefresh:             5 * time.Second,
 	Miner: miner.Config{
 		GasCeil:  30000000,
 		GasPrice: big.NewInt(params.GWei),
 		Recommit: 3 * time.Second,
+
+		NewPayloadRecommit: 500 * time.Millisecond,
+		NewPayloadTimeout:  2 * time.Second,
 	},
 	TxPool:        core.DefaultTxPoolConfig,
 	RPCGasCap:     50000000,
 	RPCEVMTimeout: 5 * time.Second,
 	GPO:           FullNodeGPO,
 	RPCTxFeeCap:   1, // 1 ether
+
+	RPCFilterTimeout: 5 * time.Minute,
+
+	InclusionPromiseWindow: 10,
+	HistoricalRPCTimeout:   5 * time.Second,
+	EngineSlowCall:         time.Second,
 }
 
 func init() {
@@ -116,6 +132,15 @@ func init() {
 	}
 }
 
+// TraceJob is a background trace job scheduled from the configuration.
+type TraceJob struct {
+	From     uint64 // First block to trace
+	To       uint64 // Last block to trace
+	Output   string // Output file, relative paths are resolved in the job directory
+	Tracer   string `toml:",omitempty"` // Tracer to use, the struct logger if empty
+	Throttle string `toml:",omitempty"` // Pause between blocks, e.g. "100ms"
+}
+
 //go:generate go run github.com/fjl/gencodec -type Config -formats toml -out gen_config.go
 
 // Config contains configuration options for of the ETH and LES protocols.
@@ -136,7 +161,15 @@ type Config struct {
 	NoPruning  bool // Whether to disable pruning and flush everything to disk
 	NoPrefetch bool // Whether to disable prefetching and only load state on demand
 
-	TxLookupLimit uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
+	StateScheme  string `toml:",omitempty"` // Scheme used to store the state, detected from the database if empty
+	StateHistory uint64 `toml:",omitempty"` // Number of recent states the path scheme can roll back to
+
+	TriePrefetchWorkers     int  `toml:",omitempty"` // Maximum number of tries prefetched in parallel (0 = unlimited)
+	TriePrefetchConcurrency int  `toml:",omitempty"` // Number of parallel loaders of a single prefetched trie
+	NoImportTriePrefetch    bool `toml:",omitempty"` // Whether to disable the trie prefetcher during block import
+
+	TxLookupLimit  uint64 `toml:",omitempty"` // The maximum number of blocks from head whose tx indices are reserved.
+	LogLookupLimit uint64 `toml:",omitempty"` // The maximum number of blocks from head whose log indices are reserved.
 
 	// RequiredBlocks is a set of block number -> hash mappings which must be in the
 	// canonical chain of all remote peers. Setting the option makes geth verify the
@@ -163,6 +196,31 @@ type Config struct {
 	DatabaseCache      int
 	DatabaseFreezer    string
 
+	// DatabaseCold is the directory of the cold database receiving the state
+	// untouched for DatabaseColdAge, empty if state is not tiered.
+	DatabaseCold    string        `toml:",omitempty"`
+	DatabaseColdAge time.Duration `toml:",omitempty"`
+
+	// DatabaseFreezerRemote is the location of the remote ancient store shared
+	// with other nodes, empty if all ancients are kept locally.
+	DatabaseFreezerRemote       string `toml:",omitempty"`
+	DatabaseFreezerRemoteRegion string `toml:",omitempty"`
+	DatabaseFreezerRemoteCache  int    `toml:",omitempty"` // Segment cache size in MB
+	DatabaseFreezerRemoteUpload bool   `toml:",omitempty"`
+	DatabaseFreezerRemoteAnon   bool   `toml:",omitempty"` // Access a public bucket without credentials
+
+	// DatabaseCompactionRate limits the write rate of the database compactions
+	// in MiB/s (0 = unlimited), except for the full compactions, which run in
+	// the daily UTC DatabaseCompactionWindow ("HH:MM-HH:MM") if set.
+	DatabaseCompactionRate   int    `toml:",omitempty"`
+	DatabaseCompactionWindow string `toml:",omitempty"`
+
+	// DatabaseReadOnly shares the database of the node owning the data directory
+	// read-only, serving its chain as of the last state it flushed and following
+	// it every DatabaseRefresh.
+	DatabaseReadOnly bool          `toml:",omitempty"`
+	DatabaseRefresh  time.Duration `toml:",omitempty"`
+
 	TrieCleanCache          int
 	TrieCleanCacheJournal   string        `toml:",omitempty"` // Disk journal directory for trie cache to survive node restarts
 	TrieCleanCacheRejournal time.Duration `toml:",omitempty"` // Time interval to regenerate the journal for clean cache
@@ -170,6 +228,15 @@ type Config struct {
 	TrieTimeout             time.Duration
 	SnapshotCache           int
 	Preimages               bool
+	CacheWarmupContracts    int `toml:",omitempty"` // Number of hottest contracts pre-loaded into the caches on startup (0 = disabled)
+
+	// Whether to store the state changes of every imported block, served by
+	// debug_getStateDiff without re-executing the block
+	StateDiffIndex bool `toml:",omitempty"`
+
+	// Whether to record the execution witness of every imported block, served
+	// by debug_executionWitness without re-executing the block
+	RecordWitnesses bool `toml:",omitempty"`
 
 	// Mining options
 	Miner miner.Config
@@ -180,6 +247,10 @@ type Config struct {
 	// Transaction pool options
 	TxPool core.TxPoolConfig
 
+	// Per-peer bytes per second of transactions broadcast directly instead of
+	// announced (0 = unlimited)
+	TxBroadcastBudget uint64 `toml:",omitempty"`
+
 	// Gas Price Oracle options
 	GPO gasprice.Config
 
@@ -199,6 +270,83 @@ type Config struct {
 	// send-transction variants. The unit is ether.
 	RPCTxFeeCap float64
 
+	// RPCSubscriptionFanout makes subscriptions to new heads and logs with the
+	// same criteria share one internal feed and notification encoding.
+	RPCSubscriptionFanout bool `toml:",omitempty"`
+
+	// RPCFilterCheckpoints persists the log filters installed via eth_newFilter
+	// and their delivery position, serving them without gaps across restarts.
+	RPCFilterCheckpoints bool `toml:",omitempty"`
+
+	// RPCFilterTimeout uninstalls the filters not polled for the given time.
+	// RPCFilterClientLimit and RPCFilterBacklog bound the filters installed per
+	// client and the results queued per filter, zero values disable them.
+	RPCFilterTimeout     time.Duration `toml:",omitempty"`
+	RPCFilterClientLimit int           `toml:",omitempty"`
+	RPCFilterBacklog     int           `toml:",omitempty"`
+
+	// RPCTrace* limit the tracing work done for RPC clients, in total and per
+	// client: the traces running at once, the gas traced per minute, the time a
+	// trace may wait for a free slot and the transactions of a block traced at
+	// once. Zero values disable the respective limit.
+	RPCTraceConcurrency        int           `toml:",omitempty"`
+	RPCTraceClientConcurrency  int           `toml:",omitempty"`
+	RPCTraceGasPerMinute       uint64        `toml:",omitempty"`
+	RPCTraceClientGasPerMinute uint64        `toml:",omitempty"`
+	RPCTraceQueueTimeout       time.Duration `toml:",omitempty"`
+	RPCTraceBlockConcurrency   int           `toml:",omitempty"`
+
+	// RPCTraceIndex indexes the addresses in the call traces of the chain,
+	// speeding up their lookup by trace_filter.
+	RPCTraceIndex bool `toml:",omitempty"`
+
+	// RPCTraceJobs enables the background trace jobs, managed via the admin
+	// namespace. TraceJobs are scheduled on startup, unless a job with the same
+	// output file is already known from a previous run.
+	RPCTraceJobs bool       `toml:",omitempty"`
+	TraceJobs    []TraceJob `toml:",omitempty"`
+
+	// RPCAccountRangeRate is the number of accounts a client may enumerate per
+	// second via debug_accountRange. Zero disables the limit.
+	RPCAccountRangeRate int `toml:",omitempty"`
+
+	// RPCComparePeers are the http(s) and ws(s) endpoints of the nodes whose
+	// state debug_compareState may be compared with.
+	RPCComparePeers []string `toml:",omitempty"`
+
+	// InclusionPromiseKey is the sequencer key used to sign transaction inclusion
+	// promises. No promises are issued if it is nil.
+	InclusionPromiseKey *ecdsa.PrivateKey `toml:"-"`
+
+	// InclusionPromiseWindow is the number of blocks following the current head
+	// within which a promised transaction is committed to be included.
+	InclusionPromiseWindow uint64 `toml:",omitempty"`
+
+	// MaxReorgDepth is the maximum number of canonical blocks a forkchoice update
+	// may rewind. Deeper rewinds are refused unless permitted by the operator via
+	// admin_allowReorg. Zero disables the limit.
+	MaxReorgDepth uint64 `toml:",omitempty"`
+
+	// HistoricalRPC is the endpoint of a legacy node serving the blocks preceding
+	// HistoricalBlock, e.g. from before a regenesis. Queries for those blocks,
+	// their transactions and their state are proxied to it.
+	HistoricalRPC        string        `toml:",omitempty"`
+	HistoricalRPCTimeout time.Duration `toml:",omitempty"`
+	HistoricalBlock      uint64        `toml:",omitempty"`
+
+	// VerifyDeposits makes the engine API reject payloads whose deposits don't
+	// lead their transactions or differ from the ones of the payload attributes
+	// last supplied by the rollup node for the same parent and timestamp.
+	VerifyDeposits bool `toml:",omitempty"`
+
+	// EngineRecord is the file every engine API call driving the chain is
+	// appended to, for replaying them into a fresh node (empty = disabled).
+	EngineRecord string `toml:",omitempty"`
+
+	// EngineSlowCall is the serving time above which the engine API calls that
+	// import and build blocks are logged as slow (0 = never).
+	EngineSlowCall time.Duration `toml:",omitempty"`
+
 	// Checkpoint is a hardcoded checkpoint which can be nil.
 	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"`
 
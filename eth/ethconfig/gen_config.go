@@ -3,6 +3,7 @@
 package ethconfig
 
 import (
+	"crypto/ecdsa"
 	"math/big"
 	"time"
 
@@ -25,7 +26,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		SnapDiscoveryURLs               []string
 		NoPruning                       bool
 		NoPrefetch                      bool
+		StateScheme                     string                 `toml:",omitempty"`
+		StateHistory                    uint64                 `toml:",omitempty"`
+		TriePrefetchWorkers             int                    `toml:",omitempty"`
+		TriePrefetchConcurrency         int                    `toml:",omitempty"`
+		NoImportTriePrefetch            bool                   `toml:",omitempty"`
 		TxLookupLimit                   uint64                 `toml:",omitempty"`
+		LogLookupLimit                  uint64                 `toml:",omitempty"`
 		RequiredBlocks                  map[uint64]common.Hash `toml:"-"`
 		LightServ                       int                    `toml:",omitempty"`
 		LightIngress                    int                    `toml:",omitempty"`
@@ -41,22 +48,62 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		DatabaseHandles                 int                    `toml:"-"`
 		DatabaseCache                   int
 		DatabaseFreezer                 string
+		DatabaseCold                    string        `toml:",omitempty"`
+		DatabaseColdAge                 time.Duration `toml:",omitempty"`
+		DatabaseFreezerRemote           string        `toml:",omitempty"`
+		DatabaseFreezerRemoteRegion     string        `toml:",omitempty"`
+		DatabaseFreezerRemoteCache      int           `toml:",omitempty"`
+		DatabaseFreezerRemoteUpload     bool          `toml:",omitempty"`
+		DatabaseFreezerRemoteAnon       bool          `toml:",omitempty"`
+		DatabaseCompactionRate          int           `toml:",omitempty"`
+		DatabaseCompactionWindow        string        `toml:",omitempty"`
+		DatabaseReadOnly                bool          `toml:",omitempty"`
+		DatabaseRefresh                 time.Duration `toml:",omitempty"`
 		TrieCleanCache                  int
 		TrieCleanCacheJournal           string        `toml:",omitempty"`
 		TrieCleanCacheRejournal         time.Duration `toml:",omitempty"`
 		TrieDirtyCache                  int
 		TrieTimeout                     time.Duration
 		SnapshotCache                   int
+		CacheWarmupContracts            int  `toml:",omitempty"`
+		StateDiffIndex                  bool `toml:",omitempty"`
+		RecordWitnesses                 bool `toml:",omitempty"`
 		Preimages                       bool
 		Miner                           miner.Config
 		Ethash                          ethash.Config
 		TxPool                          core.TxPoolConfig
+		TxBroadcastBudget               uint64 `toml:",omitempty"`
 		GPO                             gasprice.Config
 		EnablePreimageRecording         bool
 		DocRoot                         string `toml:"-"`
 		RPCGasCap                       uint64
 		RPCEVMTimeout                   time.Duration
 		RPCTxFeeCap                     float64
+		RPCSubscriptionFanout           bool                           `toml:",omitempty"`
+		RPCFilterCheckpoints            bool                           `toml:",omitempty"`
+		RPCFilterTimeout                time.Duration                  `toml:",omitempty"`
+		RPCFilterClientLimit            int                            `toml:",omitempty"`
+		RPCFilterBacklog                int                            `toml:",omitempty"`
+		RPCTraceConcurrency             int                            `toml:",omitempty"`
+		RPCTraceClientConcurrency       int                            `toml:",omitempty"`
+		RPCTraceGasPerMinute            uint64                         `toml:",omitempty"`
+		RPCTraceClientGasPerMinute      uint64                         `toml:",omitempty"`
+		RPCTraceQueueTimeout            time.Duration                  `toml:",omitempty"`
+		RPCTraceBlockConcurrency        int                            `toml:",omitempty"`
+		RPCTraceIndex                   bool                           `toml:",omitempty"`
+		RPCTraceJobs                    bool                           `toml:",omitempty"`
+		TraceJobs                       []TraceJob                     `toml:",omitempty"`
+		RPCAccountRangeRate             int                            `toml:",omitempty"`
+		RPCComparePeers                 []string                       `toml:",omitempty"`
+		InclusionPromiseKey             *ecdsa.PrivateKey              `toml:"-"`
+		InclusionPromiseWindow          uint64                         `toml:",omitempty"`
+		MaxReorgDepth                   uint64                         `toml:",omitempty"`
+		HistoricalRPC                   string                         `toml:",omitempty"`
+		HistoricalRPCTimeout            time.Duration                  `toml:",omitempty"`
+		HistoricalBlock                 uint64                         `toml:",omitempty"`
+		VerifyDeposits                  bool                           `toml:",omitempty"`
+		EngineRecord                    string                         `toml:",omitempty"`
+		EngineSlowCall                  time.Duration                  `toml:",omitempty"`
 		Checkpoint                      *params.TrustedCheckpoint      `toml:",omitempty"`
 		CheckpointOracle                *params.CheckpointOracleConfig `toml:",omitempty"`
 		OverrideGrayGlacier             *big.Int                       `toml:",omitempty"`
@@ -70,7 +117,13 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.SnapDiscoveryURLs = c.SnapDiscoveryURLs
 	enc.NoPruning = c.NoPruning
 	enc.NoPrefetch = c.NoPrefetch
+	enc.StateScheme = c.StateScheme
+	enc.StateHistory = c.StateHistory
+	enc.TriePrefetchWorkers = c.TriePrefetchWorkers
+	enc.TriePrefetchConcurrency = c.TriePrefetchConcurrency
+	enc.NoImportTriePrefetch = c.NoImportTriePrefetch
 	enc.TxLookupLimit = c.TxLookupLimit
+	enc.LogLookupLimit = c.LogLookupLimit
 	enc.RequiredBlocks = c.RequiredBlocks
 	enc.LightServ = c.LightServ
 	enc.LightIngress = c.LightIngress
@@ -86,22 +139,62 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
 	enc.DatabaseFreezer = c.DatabaseFreezer
+	enc.DatabaseCold = c.DatabaseCold
+	enc.DatabaseColdAge = c.DatabaseColdAge
+	enc.DatabaseFreezerRemote = c.DatabaseFreezerRemote
+	enc.DatabaseFreezerRemoteRegion = c.DatabaseFreezerRemoteRegion
+	enc.DatabaseFreezerRemoteCache = c.DatabaseFreezerRemoteCache
+	enc.DatabaseFreezerRemoteUpload = c.DatabaseFreezerRemoteUpload
+	enc.DatabaseFreezerRemoteAnon = c.DatabaseFreezerRemoteAnon
+	enc.DatabaseCompactionRate = c.DatabaseCompactionRate
+	enc.DatabaseCompactionWindow = c.DatabaseCompactionWindow
+	enc.DatabaseReadOnly = c.DatabaseReadOnly
+	enc.DatabaseRefresh = c.DatabaseRefresh
 	enc.TrieCleanCache = c.TrieCleanCache
 	enc.TrieCleanCacheJournal = c.TrieCleanCacheJournal
 	enc.TrieCleanCacheRejournal = c.TrieCleanCacheRejournal
 	enc.TrieDirtyCache = c.TrieDirtyCache
 	enc.TrieTimeout = c.TrieTimeout
 	enc.SnapshotCache = c.SnapshotCache
+	enc.CacheWarmupContracts = c.CacheWarmupContracts
+	enc.StateDiffIndex = c.StateDiffIndex
+	enc.RecordWitnesses = c.RecordWitnesses
 	enc.Preimages = c.Preimages
 	enc.Miner = c.Miner
 	enc.Ethash = c.Ethash
 	enc.TxPool = c.TxPool
+	enc.TxBroadcastBudget = c.TxBroadcastBudget
 	enc.GPO = c.GPO
 	enc.EnablePreimageRecording = c.EnablePreimageRecording
 	enc.DocRoot = c.DocRoot
 	enc.RPCGasCap = c.RPCGasCap
 	enc.RPCEVMTimeout = c.RPCEVMTimeout
 	enc.RPCTxFeeCap = c.RPCTxFeeCap
+	enc.RPCSubscriptionFanout = c.RPCSubscriptionFanout
+	enc.RPCFilterCheckpoints = c.RPCFilterCheckpoints
+	enc.RPCFilterTimeout = c.RPCFilterTimeout
+	enc.RPCFilterClientLimit = c.RPCFilterClientLimit
+	enc.RPCFilterBacklog = c.RPCFilterBacklog
+	enc.RPCTraceConcurrency = c.RPCTraceConcurrency
+	enc.RPCTraceClientConcurrency = c.RPCTraceClientConcurrency
+	enc.RPCTraceGasPerMinute = c.RPCTraceGasPerMinute
+	enc.RPCTraceClientGasPerMinute = c.RPCTraceClientGasPerMinute
+	enc.RPCTraceQueueTimeout = c.RPCTraceQueueTimeout
+	enc.RPCTraceBlockConcurrency = c.RPCTraceBlockConcurrency
+	enc.RPCTraceIndex = c.RPCTraceIndex
+	enc.RPCTraceJobs = c.RPCTraceJobs
+	enc.TraceJobs = c.TraceJobs
+	enc.RPCAccountRangeRate = c.RPCAccountRangeRate
+	enc.RPCComparePeers = c.RPCComparePeers
+	enc.InclusionPromiseKey = c.InclusionPromiseKey
+	enc.InclusionPromiseWindow = c.InclusionPromiseWindow
+	enc.MaxReorgDepth = c.MaxReorgDepth
+	enc.HistoricalRPC = c.HistoricalRPC
+	enc.HistoricalRPCTimeout = c.HistoricalRPCTimeout
+	enc.HistoricalBlock = c.HistoricalBlock
+	enc.VerifyDeposits = c.VerifyDeposits
+	enc.EngineRecord = c.EngineRecord
+	enc.EngineSlowCall = c.EngineSlowCall
 	enc.Checkpoint = c.Checkpoint
 	enc.CheckpointOracle = c.CheckpointOracle
 	enc.OverrideGrayGlacier = c.OverrideGrayGlacier
@@ -119,7 +212,13 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		SnapDiscoveryURLs               []string
 		NoPruning                       *bool
 		NoPrefetch                      *bool
+		StateScheme                     *string                `toml:",omitempty"`
+		StateHistory                    *uint64                `toml:",omitempty"`
+		TriePrefetchWorkers             *int                   `toml:",omitempty"`
+		TriePrefetchConcurrency         *int                   `toml:",omitempty"`
+		NoImportTriePrefetch            *bool                  `toml:",omitempty"`
 		TxLookupLimit                   *uint64                `toml:",omitempty"`
+		LogLookupLimit                  *uint64                `toml:",omitempty"`
 		RequiredBlocks                  map[uint64]common.Hash `toml:"-"`
 		LightServ                       *int                   `toml:",omitempty"`
 		LightIngress                    *int                   `toml:",omitempty"`
@@ -135,22 +234,62 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		DatabaseHandles                 *int                   `toml:"-"`
 		DatabaseCache                   *int
 		DatabaseFreezer                 *string
+		DatabaseCold                    *string        `toml:",omitempty"`
+		DatabaseColdAge                 *time.Duration `toml:",omitempty"`
+		DatabaseFreezerRemote           *string        `toml:",omitempty"`
+		DatabaseFreezerRemoteRegion     *string        `toml:",omitempty"`
+		DatabaseFreezerRemoteCache      *int           `toml:",omitempty"`
+		DatabaseFreezerRemoteUpload     *bool          `toml:",omitempty"`
+		DatabaseFreezerRemoteAnon       *bool          `toml:",omitempty"`
+		DatabaseCompactionRate          *int           `toml:",omitempty"`
+		DatabaseCompactionWindow        *string        `toml:",omitempty"`
+		DatabaseReadOnly                *bool          `toml:",omitempty"`
+		DatabaseRefresh                 *time.Duration `toml:",omitempty"`
 		TrieCleanCache                  *int
 		TrieCleanCacheJournal           *string        `toml:",omitempty"`
 		TrieCleanCacheRejournal         *time.Duration `toml:",omitempty"`
 		TrieDirtyCache                  *int
 		TrieTimeout                     *time.Duration
 		SnapshotCache                   *int
+		CacheWarmupContracts            *int  `toml:",omitempty"`
+		StateDiffIndex                  *bool `toml:",omitempty"`
+		RecordWitnesses                 *bool `toml:",omitempty"`
 		Preimages                       *bool
 		Miner                           *miner.Config
 		Ethash                          *ethash.Config
 		TxPool                          *core.TxPoolConfig
+		TxBroadcastBudget               *uint64 `toml:",omitempty"`
 		GPO                             *gasprice.Config
 		EnablePreimageRecording         *bool
 		DocRoot                         *string `toml:"-"`
 		RPCGasCap                       *uint64
 		RPCEVMTimeout                   *time.Duration
 		RPCTxFeeCap                     *float64
+		RPCSubscriptionFanout           *bool                          `toml:",omitempty"`
+		RPCFilterCheckpoints            *bool                          `toml:",omitempty"`
+		RPCFilterTimeout                *time.Duration                 `toml:",omitempty"`
+		RPCFilterClientLimit            *int                           `toml:",omitempty"`
+		RPCFilterBacklog                *int                           `toml:",omitempty"`
+		RPCTraceConcurrency             *int                           `toml:",omitempty"`
+		RPCTraceClientConcurrency       *int                           `toml:",omitempty"`
+		RPCTraceGasPerMinute            *uint64                        `toml:",omitempty"`
+		RPCTraceClientGasPerMinute      *uint64                        `toml:",omitempty"`
+		RPCTraceQueueTimeout            *time.Duration                 `toml:",omitempty"`
+		RPCTraceBlockConcurrency        *int                           `toml:",omitempty"`
+		RPCTraceIndex                   *bool                          `toml:",omitempty"`
+		RPCTraceJobs                    *bool                          `toml:",omitempty"`
+		TraceJobs                       []TraceJob                     `toml:",omitempty"`
+		RPCAccountRangeRate             *int                           `toml:",omitempty"`
+		RPCComparePeers                 []string                       `toml:",omitempty"`
+		InclusionPromiseKey             *ecdsa.PrivateKey              `toml:"-"`
+		InclusionPromiseWindow          *uint64                        `toml:",omitempty"`
+		MaxReorgDepth                   *uint64                        `toml:",omitempty"`
+		HistoricalRPC                   *string                        `toml:",omitempty"`
+		HistoricalRPCTimeout            *time.Duration                 `toml:",omitempty"`
+		HistoricalBlock                 *uint64                        `toml:",omitempty"`
+		VerifyDeposits                  *bool                          `toml:",omitempty"`
+		EngineRecord                    *string                        `toml:",omitempty"`
+		EngineSlowCall                  *time.Duration                 `toml:",omitempty"`
 		Checkpoint                      *params.TrustedCheckpoint      `toml:",omitempty"`
 		CheckpointOracle                *params.CheckpointOracleConfig `toml:",omitempty"`
 		OverrideGrayGlacier             *big.Int                       `toml:",omitempty"`
@@ -181,9 +320,27 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.NoPrefetch != nil {
 		c.NoPrefetch = *dec.NoPrefetch
 	}
+	if dec.StateScheme != nil {
+		c.StateScheme = *dec.StateScheme
+	}
+	if dec.StateHistory != nil {
+		c.StateHistory = *dec.StateHistory
+	}
+	if dec.TriePrefetchWorkers != nil {
+		c.TriePrefetchWorkers = *dec.TriePrefetchWorkers
+	}
+	if dec.TriePrefetchConcurrency != nil {
+		c.TriePrefetchConcurrency = *dec.TriePrefetchConcurrency
+	}
+	if dec.NoImportTriePrefetch != nil {
+		c.NoImportTriePrefetch = *dec.NoImportTriePrefetch
+	}
 	if dec.TxLookupLimit != nil {
 		c.TxLookupLimit = *dec.TxLookupLimit
 	}
+	if dec.LogLookupLimit != nil {
+		c.LogLookupLimit = *dec.LogLookupLimit
+	}
 	if dec.RequiredBlocks != nil {
 		c.RequiredBlocks = dec.RequiredBlocks
 	}
@@ -229,6 +386,39 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.DatabaseFreezer != nil {
 		c.DatabaseFreezer = *dec.DatabaseFreezer
 	}
+	if dec.DatabaseCold != nil {
+		c.DatabaseCold = *dec.DatabaseCold
+	}
+	if dec.DatabaseColdAge != nil {
+		c.DatabaseColdAge = *dec.DatabaseColdAge
+	}
+	if dec.DatabaseFreezerRemote != nil {
+		c.DatabaseFreezerRemote = *dec.DatabaseFreezerRemote
+	}
+	if dec.DatabaseFreezerRemoteRegion != nil {
+		c.DatabaseFreezerRemoteRegion = *dec.DatabaseFreezerRemoteRegion
+	}
+	if dec.DatabaseFreezerRemoteCache != nil {
+		c.DatabaseFreezerRemoteCache = *dec.DatabaseFreezerRemoteCache
+	}
+	if dec.DatabaseFreezerRemoteUpload != nil {
+		c.DatabaseFreezerRemoteUpload = *dec.DatabaseFreezerRemoteUpload
+	}
+	if dec.DatabaseFreezerRemoteAnon != nil {
+		c.DatabaseFreezerRemoteAnon = *dec.DatabaseFreezerRemoteAnon
+	}
+	if dec.DatabaseCompactionRate != nil {
+		c.DatabaseCompactionRate = *dec.DatabaseCompactionRate
+	}
+	if dec.DatabaseCompactionWindow != nil {
+		c.DatabaseCompactionWindow = *dec.DatabaseCompactionWindow
+	}
+	if dec.DatabaseReadOnly != nil {
+		c.DatabaseReadOnly = *dec.DatabaseReadOnly
+	}
+	if dec.DatabaseRefresh != nil {
+		c.DatabaseRefresh = *dec.DatabaseRefresh
+	}
 	if dec.TrieCleanCache != nil {
 		c.TrieCleanCache = *dec.TrieCleanCache
 	}
@@ -247,6 +437,15 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.SnapshotCache != nil {
 		c.SnapshotCache = *dec.SnapshotCache
 	}
+	if dec.CacheWarmupContracts != nil {
+		c.CacheWarmupContracts = *dec.CacheWarmupContracts
+	}
+	if dec.StateDiffIndex != nil {
+		c.StateDiffIndex = *dec.StateDiffIndex
+	}
+	if dec.RecordWitnesses != nil {
+		c.RecordWitnesses = *dec.RecordWitnesses
+	}
 	if dec.Preimages != nil {
 		c.Preimages = *dec.Preimages
 	}
@@ -259,6 +458,9 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.TxPool != nil {
 		c.TxPool = *dec.TxPool
 	}
+	if dec.TxBroadcastBudget != nil {
+		c.TxBroadcastBudget = *dec.TxBroadcastBudget
+	}
 	if dec.GPO != nil {
 		c.GPO = *dec.GPO
 	}
@@ -277,6 +479,81 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.RPCTxFeeCap != nil {
 		c.RPCTxFeeCap = *dec.RPCTxFeeCap
 	}
+	if dec.RPCSubscriptionFanout != nil {
+		c.RPCSubscriptionFanout = *dec.RPCSubscriptionFanout
+	}
+	if dec.RPCFilterCheckpoints != nil {
+		c.RPCFilterCheckpoints = *dec.RPCFilterCheckpoints
+	}
+	if dec.RPCFilterTimeout != nil {
+		c.RPCFilterTimeout = *dec.RPCFilterTimeout
+	}
+	if dec.RPCFilterClientLimit != nil {
+		c.RPCFilterClientLimit = *dec.RPCFilterClientLimit
+	}
+	if dec.RPCFilterBacklog != nil {
+		c.RPCFilterBacklog = *dec.RPCFilterBacklog
+	}
+	if dec.RPCTraceConcurrency != nil {
+		c.RPCTraceConcurrency = *dec.RPCTraceConcurrency
+	}
+	if dec.RPCTraceClientConcurrency != nil {
+		c.RPCTraceClientConcurrency = *dec.RPCTraceClientConcurrency
+	}
+	if dec.RPCTraceGasPerMinute != nil {
+		c.RPCTraceGasPerMinute = *dec.RPCTraceGasPerMinute
+	}
+	if dec.RPCTraceClientGasPerMinute != nil {
+		c.RPCTraceClientGasPerMinute = *dec.RPCTraceClientGasPerMinute
+	}
+	if dec.RPCTraceQueueTimeout != nil {
+		c.RPCTraceQueueTimeout = *dec.RPCTraceQueueTimeout
+	}
+	if dec.RPCTraceBlockConcurrency != nil {
+		c.RPCTraceBlockConcurrency = *dec.RPCTraceBlockConcurrency
+	}
+	if dec.RPCTraceIndex != nil {
+		c.RPCTraceIndex = *dec.RPCTraceIndex
+	}
+	if dec.RPCTraceJobs != nil {
+		c.RPCTraceJobs = *dec.RPCTraceJobs
+	}
+	if dec.TraceJobs != nil {
+		c.TraceJobs = dec.TraceJobs
+	}
+	if dec.RPCAccountRangeRate != nil {
+		c.RPCAccountRangeRate = *dec.RPCAccountRangeRate
+	}
+	if dec.RPCComparePeers != nil {
+		c.RPCComparePeers = dec.RPCComparePeers
+	}
+	if dec.InclusionPromiseKey != nil {
+		c.InclusionPromiseKey = dec.InclusionPromiseKey
+	}
+	if dec.InclusionPromiseWindow != nil {
+		c.InclusionPromiseWindow = *dec.InclusionPromiseWindow
+	}
+	if dec.MaxReorgDepth != nil {
+		c.MaxReorgDepth = *dec.MaxReorgDepth
+	}
+	if dec.HistoricalRPC != nil {
+		c.HistoricalRPC = *dec.HistoricalRPC
+	}
+	if dec.HistoricalRPCTimeout != nil {
+		c.HistoricalRPCTimeout = *dec.HistoricalRPCTimeout
+	}
+	if dec.HistoricalBlock != nil {
+		c.HistoricalBlock = *dec.HistoricalBlock
+	}
+	if dec.VerifyDeposits != nil {
+		c.VerifyDeposits = *dec.VerifyDeposits
+	}
+	if dec.EngineRecord != nil {
+		c.EngineRecord = *dec.EngineRecord
+	}
+	if dec.EngineSlowCall != nil {
+		c.EngineSlowCall = *dec.EngineSlowCall
+	}
 	if dec.Checkpoint != nil {
 		c.Checkpoint = dec.Checkpoint
 	}
@@ -0,0 +1,279 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+var eventsDroppedMeter = metrics.NewRegisteredMeter("eth/events/dropped", nil)
+
+// Backpressure determines how an event subscription deals with a consumer that
+// doesn't keep up with the events.
+type Backpressure int
+
+const (
+	// Block delivers every event, stalling the producer until the consumer
+	// received it. A slow consumer thus slows down the node itself, e.g. block
+	// import for head events, so it should only be used by consumers that must
+	// not miss events and drain their channel promptly.
+	Block Backpressure = iota
+
+	// Drop discards the events that don't fit into the buffer of the consumer
+	// channel, never stalling the node. Discarded events are counted by the
+	// subscription.
+	Drop
+)
+
+// HeadEvent is posted when the head of the canonical chain changes.
+type HeadEvent struct {
+	Header *types.Header
+}
+
+// ReorgEvent is posted when the canonical chain reorganises, before the
+// HeadEvent of the new head.
+type ReorgEvent struct {
+	OldHead *types.Header
+	NewHead *types.Header
+	Depth   uint64 // Number of dropped canonical blocks
+}
+
+// TxsEvent is posted when transactions enter the transaction pool.
+type TxsEvent struct {
+	Txs []*types.Transaction
+}
+
+// FinalizedEvent is posted when the finalized block changes.
+type FinalizedEvent struct {
+	Header *types.Header
+}
+
+// SyncPhase is a state of the chain synchronisation.
+type SyncPhase string
+
+const (
+	SyncStarted SyncPhase = "started" // The node started syncing with a peer
+	SyncDone    SyncPhase = "done"    // The node finished syncing
+	SyncFailed  SyncPhase = "failed"  // The sync failed, see SyncEvent.Err
+)
+
+// SyncEvent is posted when the sync phase changes.
+type SyncEvent struct {
+	Phase SyncPhase
+	Err   error // Reason of a failed sync
+}
+
+// Subscription is a subscription to the event bus.
+type Subscription struct {
+	event.Subscription
+	dropped uint64 // Events discarded by the Drop policy, accessed atomically
+}
+
+// Dropped returns the number of events discarded because the consumer didn't
+// keep up. It is always zero for subscriptions with the Block policy.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Events is the event bus of the eth service for programs embedding the node.
+// It offers typed subscriptions to chain, transaction pool and sync events with
+// a choice of backpressure, so that embedders need not combine the internal
+// feeds of the various components.
+//
+// Events are delivered in the order they occurred. Subscriptions end when the
+// node stops.
+type Events struct {
+	chain *core.BlockChain
+
+	headCh   chan core.ChainHeadEvent
+	headSub  event.Subscription
+	finalCh  chan core.ChainFinalizedEvent
+	finalSub event.Subscription
+	txsCh    chan core.NewTxsEvent
+	txsSub   event.Subscription
+	syncSub  *event.TypeMuxSubscription
+
+	headFeed      event.Feed
+	reorgFeed     event.Feed
+	txsFeed       event.Feed
+	finalizedFeed event.Feed
+	syncFeed      event.Feed
+	scope         event.SubscriptionScope
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newEvents(eth *Ethereum) *Events {
+	e := &Events{
+		chain:   eth.blockchain,
+		headCh:  make(chan core.ChainHeadEvent, 16),
+		finalCh: make(chan core.ChainFinalizedEvent, 16),
+		txsCh:   make(chan core.NewTxsEvent, 16),
+		quit:    make(chan struct{}),
+	}
+	e.headSub = eth.blockchain.SubscribeChainHeadEvent(e.headCh)
+	e.finalSub = eth.blockchain.SubscribeChainFinalizedEvent(e.finalCh)
+	e.txsSub = eth.txPool.SubscribeNewTxsEvent(e.txsCh)
+	e.syncSub = eth.eventMux.Subscribe(downloader.StartEvent{}, downloader.DoneEvent{}, downloader.FailedEvent{})
+	return e
+}
+
+// SubscribeHeads subscribes the channel to changes of the chain head.
+func (e *Events) SubscribeHeads(ch chan<- HeadEvent, bp Backpressure) *Subscription {
+	return e.subscribe(&e.headFeed, ch, bp)
+}
+
+// SubscribeReorgs subscribes the channel to reorganisations of the chain.
+func (e *Events) SubscribeReorgs(ch chan<- ReorgEvent, bp Backpressure) *Subscription {
+	return e.subscribe(&e.reorgFeed, ch, bp)
+}
+
+// SubscribeTxs subscribes the channel to transactions entering the pool.
+func (e *Events) SubscribeTxs(ch chan<- TxsEvent, bp Backpressure) *Subscription {
+	return e.subscribe(&e.txsFeed, ch, bp)
+}
+
+// SubscribeFinalized subscribes the channel to changes of the finalized block.
+func (e *Events) SubscribeFinalized(ch chan<- FinalizedEvent, bp Backpressure) *Subscription {
+	return e.subscribe(&e.finalizedFeed, ch, bp)
+}
+
+// SubscribeSync subscribes the channel to changes of the sync phase.
+func (e *Events) SubscribeSync(ch chan<- SyncEvent, bp Backpressure) *Subscription {
+	return e.subscribe(&e.syncFeed, ch, bp)
+}
+
+// subscribe subscribes the channel to the feed, applying the backpressure
+// policy. With Drop, events are forwarded to the channel by a goroutine which
+// discards the ones the channel can't take.
+func (e *Events) subscribe(feed *event.Feed, ch interface{}, bp Backpressure) *Subscription {
+	if bp == Block {
+		return &Subscription{Subscription: e.scope.Track(feed.Subscribe(ch))}
+	}
+	out := reflect.ValueOf(ch)
+	in := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, out.Type().Elem()), 0)
+	sub := feed.Subscribe(in.Interface())
+
+	s := new(Subscription)
+	s.Subscription = e.scope.Track(event.NewSubscription(func(quit <-chan struct{}) error {
+		defer sub.Unsubscribe()
+
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: in},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(quit)},
+		}
+		for {
+			if chosen, ev, _ := reflect.Select(cases); chosen == 1 {
+				return nil
+			} else if !out.TrySend(ev) {
+				atomic.AddUint64(&s.dropped, 1)
+				eventsDroppedMeter.Mark(1)
+			}
+		}
+	}))
+	return s
+}
+
+// Start implements node.Lifecycle.
+func (e *Events) Start() error {
+	e.wg.Add(1)
+	go e.loop()
+	return nil
+}
+
+// Stop implements node.Lifecycle, ending all subscriptions.
+func (e *Events) Stop() error {
+	close(e.quit)
+	e.scope.Close() // Unblocks a loop stalled by a consumer
+	e.wg.Wait()
+	return nil
+}
+
+// loop translates the events of the node components into bus events.
+func (e *Events) loop() {
+	defer e.wg.Done()
+	defer e.headSub.Unsubscribe()
+	defer e.finalSub.Unsubscribe()
+	defer e.txsSub.Unsubscribe()
+	defer e.syncSub.Unsubscribe()
+
+	prev := e.chain.CurrentHeader()
+	for {
+		select {
+		case ev := <-e.headCh:
+			head := ev.Block.Header()
+			if prev != nil && head.ParentHash != prev.Hash() {
+				if depth := e.reorgDepth(prev); depth > 0 {
+					e.reorgFeed.Send(ReorgEvent{OldHead: prev, NewHead: head, Depth: depth})
+				}
+			}
+			e.headFeed.Send(HeadEvent{Header: head})
+			prev = head
+
+		case ev := <-e.finalCh:
+			e.finalizedFeed.Send(FinalizedEvent{Header: ev.Block.Header()})
+
+		case ev := <-e.txsCh:
+			e.txsFeed.Send(TxsEvent{Txs: ev.Txs})
+
+		case ev, ok := <-e.syncSub.Chan():
+			if !ok {
+				return
+			}
+			switch data := ev.Data.(type) {
+			case downloader.StartEvent:
+				e.syncFeed.Send(SyncEvent{Phase: SyncStarted})
+			case downloader.DoneEvent:
+				e.syncFeed.Send(SyncEvent{Phase: SyncDone})
+			case downloader.FailedEvent:
+				e.syncFeed.Send(SyncEvent{Phase: SyncFailed, Err: data.Err})
+			}
+
+		case <-e.headSub.Err():
+			return
+		case <-e.finalSub.Err():
+			return
+		case <-e.txsSub.Err():
+			return
+		case <-e.quit:
+			return
+		}
+	}
+}
+
+// reorgDepth counts the blocks of the chain ending in old that are no longer
+// canonical.
+func (e *Events) reorgDepth(old *types.Header) uint64 {
+	var depth uint64
+	for old != nil && e.chain.GetCanonicalHash(old.Number.Uint64()) != old.Hash() {
+		depth++
+		if old.Number.Sign() == 0 {
+			break
+		}
+		old = e.chain.GetHeader(old.ParentHash, old.Number.Uint64()-1)
+	}
+	return depth
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestEvents(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	genesis := (&core.Genesis{Config: params.TestChainConfig}).MustCommit(db)
+	chain, _ := core.NewBlockChain(db, nil, params.TestChainConfig, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+	config := core.DefaultTxPoolConfig
+	config.Journal = ""
+	pool := core.NewTxPool(config, params.TestChainConfig, chain)
+	defer pool.Stop()
+
+	mux := new(event.TypeMux)
+	events := newEvents(&Ethereum{blockchain: chain, txPool: pool, eventMux: mux})
+	events.Start()
+	defer events.Stop()
+
+	heads := make(chan HeadEvent, 10)
+	headSub := events.SubscribeHeads(heads, Block)
+	defer headSub.Unsubscribe()
+	reorgs := make(chan ReorgEvent, 10)
+	reorgSub := events.SubscribeReorgs(reorgs, Block)
+	defer reorgSub.Unsubscribe()
+	finals := make(chan FinalizedEvent, 10)
+	finalSub := events.SubscribeFinalized(finals, Block)
+	defer finalSub.Unsubscribe()
+	syncs := make(chan SyncEvent, 10)
+	syncSub := events.SubscribeSync(syncs, Block)
+	defer syncSub.Unsubscribe()
+
+	// A consumer which never reads must neither stall the others nor the node
+	stalled := make(chan HeadEvent)
+	stalledSub := events.SubscribeHeads(stalled, Drop)
+	defer stalledSub.Unsubscribe()
+
+	recv := func(ch interface{}) interface{} {
+		t.Helper()
+		switch ch := ch.(type) {
+		case chan HeadEvent:
+			select {
+			case ev := <-ch:
+				return ev
+			case <-time.After(time.Second):
+			}
+		case chan ReorgEvent:
+			select {
+			case ev := <-ch:
+				return ev
+			case <-time.After(time.Second):
+			}
+		case chan FinalizedEvent:
+			select {
+			case ev := <-ch:
+				return ev
+			case <-time.After(time.Second):
+			}
+		case chan SyncEvent:
+			select {
+			case ev := <-ch:
+				return ev
+			case <-time.After(time.Second):
+			}
+		}
+		t.Fatalf("no event on %T", ch)
+		return nil
+	}
+	// Import a chain, then reorg it to a longer one forking off the genesis
+	canon, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 3, nil)
+	fork, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 4, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(common.Address{0x01})
+	})
+	if _, err := chain.InsertChain(canon); err != nil {
+		t.Fatal(err)
+	}
+	if ev := recv(heads).(HeadEvent); ev.Header.Hash() != canon[2].Hash() {
+		t.Fatalf("head mismatch: have %d, want %d", ev.Header.Number, canon[2].Number())
+	}
+	if _, err := chain.InsertChain(fork); err != nil {
+		t.Fatal(err)
+	}
+	ev := recv(reorgs).(ReorgEvent)
+	if ev.OldHead.Hash() != canon[2].Hash() || ev.NewHead.Hash() != fork[3].Hash() || ev.Depth != 3 {
+		t.Fatalf("reorg mismatch: have %d -> %d depth %d", ev.OldHead.Number, ev.NewHead.Number, ev.Depth)
+	}
+	if ev := recv(heads).(HeadEvent); ev.Header.Hash() != fork[3].Hash() {
+		t.Fatalf("head mismatch: have %d, want %d", ev.Header.Number, fork[3].Number())
+	}
+	// Finalizing the same block twice is reported once
+	chain.SetFinalized(fork[1])
+	chain.SetFinalized(fork[1])
+	chain.SetFinalized(fork[2])
+	if ev := recv(finals).(FinalizedEvent); ev.Header.Hash() != fork[1].Hash() {
+		t.Fatalf("finalized mismatch: have %d, want %d", ev.Header.Number, fork[1].Number())
+	}
+	if ev := recv(finals).(FinalizedEvent); ev.Header.Hash() != fork[2].Hash() {
+		t.Fatalf("finalized mismatch: have %d, want %d", ev.Header.Number, fork[2].Number())
+	}
+	// Sync phases are translated from the downloader events
+	failure := errors.New("peer dropped")
+	mux.Post(downloader.StartEvent{})
+	mux.Post(downloader.FailedEvent{Err: failure})
+	if ev := recv(syncs).(SyncEvent); ev.Phase != SyncStarted {
+		t.Fatalf("sync phase mismatch: have %s, want %s", ev.Phase, SyncStarted)
+	}
+	if ev := recv(syncs).(SyncEvent); ev.Phase != SyncFailed || ev.Err != failure {
+		t.Fatalf("sync failure mismatch: have %s %v, want %s %v", ev.Phase, ev.Err, SyncFailed, failure)
+	}
+	if dropped := stalledSub.Dropped(); dropped != 2 {
+		t.Fatalf("dropped events mismatch: have %d, want 2", dropped)
+	}
+	if dropped := headSub.Dropped(); dropped != 0 {
+		t.Fatalf("blocking subscription dropped %d events", dropped)
+	}
+}
@@ -53,6 +53,11 @@ const (
 	// re-request them.
 	maxTxUnderpricedSetSize = 32768
 
+	// maxTxStaleSetSize is the size of the stale transaction set that is used to
+	// track recent transactions rejected for their nonce being used up, mostly
+	// included ones still announced by slower peers, so we don't re-request them.
+	maxTxStaleSetSize = 32768
+
 	// txArriveTimeout is the time allowance before an announced transaction is
 	// explicitly requested.
 	txArriveTimeout = 500 * time.Millisecond
@@ -72,10 +77,12 @@ var (
 	txAnnounceInMeter          = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/in", nil)
 	txAnnounceKnownMeter       = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/known", nil)
 	txAnnounceUnderpricedMeter = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/underpriced", nil)
+	txAnnounceStaleMeter       = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/stale", nil)
 	txAnnounceDOSMeter         = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/dos", nil)
 
 	txBroadcastInMeter          = metrics.NewRegisteredMeter("eth/fetcher/transaction/broadcasts/in", nil)
 	txBroadcastKnownMeter       = metrics.NewRegisteredMeter("eth/fetcher/transaction/broadcasts/known", nil)
+	txBroadcastKnownBytesMeter  = metrics.NewRegisteredMeter("eth/fetcher/transaction/broadcasts/knownbytes", nil)
 	txBroadcastUnderpricedMeter = metrics.NewRegisteredMeter("eth/fetcher/transaction/broadcasts/underpriced", nil)
 	txBroadcastOtherRejectMeter = metrics.NewRegisteredMeter("eth/fetcher/transaction/broadcasts/otherreject", nil)
 
@@ -86,6 +93,7 @@ var (
 
 	txReplyInMeter          = metrics.NewRegisteredMeter("eth/fetcher/transaction/replies/in", nil)
 	txReplyKnownMeter       = metrics.NewRegisteredMeter("eth/fetcher/transaction/replies/known", nil)
+	txReplyKnownBytesMeter  = metrics.NewRegisteredMeter("eth/fetcher/transaction/replies/knownbytes", nil)
 	txReplyUnderpricedMeter = metrics.NewRegisteredMeter("eth/fetcher/transaction/replies/underpriced", nil)
 	txReplyOtherRejectMeter = metrics.NewRegisteredMeter("eth/fetcher/transaction/replies/otherreject", nil)
 
@@ -149,6 +157,7 @@ type TxFetcher struct {
 	quit    chan struct{}
 
 	underpriced mapset.Set // Transactions discarded as too cheap (don't re-fetch)
+	stale       mapset.Set // Transactions discarded for their used up nonce (don't re-fetch)
 
 	// Stage 1: Waiting lists for newly discovered transactions that might be
 	// broadcast without needing explicit request/reply round trips.
@@ -203,6 +212,7 @@ func NewTxFetcherForTests(
 		requests:    make(map[string]*txRequest),
 		alternates:  make(map[common.Hash]map[string]struct{}),
 		underpriced: mapset.NewSet(),
+		stale:       mapset.NewSet(),
 		hasTx:       hasTx,
 		addTxs:      addTxs,
 		fetchTxs:    fetchTxs,
@@ -223,8 +233,8 @@ func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
 	// still valuable to check here because it runs concurrent  to the internal
 	// loop, so anything caught here is time saved internally.
 	var (
-		unknowns               = make([]common.Hash, 0, len(hashes))
-		duplicate, underpriced int64
+		unknowns                      = make([]common.Hash, 0, len(hashes))
+		duplicate, underpriced, stale int64
 	)
 	for _, hash := range hashes {
 		switch {
@@ -234,12 +244,16 @@ func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
 		case f.underpriced.Contains(hash):
 			underpriced++
 
+		case f.stale.Contains(hash):
+			stale++
+
 		default:
 			unknowns = append(unknowns, hash)
 		}
 	}
 	txAnnounceKnownMeter.Mark(duplicate)
 	txAnnounceUnderpricedMeter.Mark(underpriced)
+	txAnnounceStaleMeter.Mark(stale)
 
 	// If anything's left to announce, push it into the internal loop
 	if len(unknowns) == 0 {
@@ -271,10 +285,11 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 	// Push all the transactions into the pool, tracking underpriced ones to avoid
 	// re-requesting them and dropping the peer in case of malicious transfers.
 	var (
-		added       = make([]common.Hash, 0, len(txs))
-		duplicate   int64
-		underpriced int64
-		otherreject int64
+		added          = make([]common.Hash, 0, len(txs))
+		duplicate      int64
+		duplicateBytes int64
+		underpriced    int64
+		otherreject    int64
 	)
 	errs := f.addTxs(txs)
 	for i, err := range errs {
@@ -287,12 +302,21 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 			}
 			f.underpriced.Add(txs[i].Hash())
 		}
+		// Track the transaction hash if its nonce is used up, the transaction was
+		// most probably included already and is only announced by slow peers.
+		if errors.Is(err, core.ErrNonceTooLow) {
+			for f.stale.Cardinality() >= maxTxStaleSetSize {
+				f.stale.Pop()
+			}
+			f.stale.Add(txs[i].Hash())
+		}
 		// Track a few interesting failure types
 		switch {
 		case err == nil: // Noop, but need to handle to not count these
 
 		case errors.Is(err, core.ErrAlreadyKnown):
 			duplicate++
+			duplicateBytes += int64(txs[i].Size())
 
 		case errors.Is(err, core.ErrUnderpriced) || errors.Is(err, core.ErrReplaceUnderpriced):
 			underpriced++
@@ -304,10 +328,12 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 	}
 	if direct {
 		txReplyKnownMeter.Mark(duplicate)
+		txReplyKnownBytesMeter.Mark(duplicateBytes)
 		txReplyUnderpricedMeter.Mark(underpriced)
 		txReplyOtherRejectMeter.Mark(otherreject)
 	} else {
 		txBroadcastKnownMeter.Mark(duplicate)
+		txBroadcastKnownBytesMeter.Mark(duplicateBytes)
 		txBroadcastUnderpricedMeter.Mark(underpriced)
 		txBroadcastOtherRejectMeter.Mark(otherreject)
 	}
@@ -896,6 +896,40 @@ func TestTransactionFetcherUnderpricedDedup(t *testing.T) {
 	})
 }
 
+// Tests that transactions rejected for their used up nonce, e.g. included ones
+// announced by slow peers, don't get rescheduled by any of the peers.
+func TestTransactionFetcherStaleDedup(t *testing.T) {
+	testTransactionFetcherParallel(t, txFetcherTest{
+		init: func() *TxFetcher {
+			return NewTxFetcher(
+				func(common.Hash) bool { return false },
+				func(txs []*types.Transaction) []error {
+					errs := make([]error, len(txs))
+					for i := 0; i < len(errs); i++ {
+						errs[i] = core.ErrNonceTooLow
+					}
+					return errs
+				},
+				func(string, []common.Hash) error { return nil },
+			)
+		},
+		steps: []interface{}{
+			// Deliver a transaction through the fetcher, but reject as stale
+			doTxNotify{peer: "A", hashes: []common.Hash{testTxsHashes[0]}},
+			doWait{time: txArriveTimeout, step: true},
+			doTxEnqueue{peer: "A", txs: []*types.Transaction{testTxs[0]}, direct: true},
+			isScheduled{nil, nil, nil},
+
+			// Announce the transaction from another peer, ensure it's not scheduled back
+			doTxNotify{peer: "B", hashes: []common.Hash{testTxsHashes[0], testTxsHashes[1]}}, // [1] is needed to force a step in the fetcher
+			isWaiting(map[string][]common.Hash{
+				"B": {testTxsHashes[1]},
+			}),
+			isScheduled{nil, nil, nil},
+		},
+	})
+}
+
 // Tests that underpriced transactions don't get rescheduled after being rejected,
 // but at the same time there's a hard cap on the number of transactions that are
 // tracked.
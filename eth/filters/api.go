@@ -23,11 +23,13 @@ import (
 	"fmt"
 	"math/big"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rpc"
 )
@@ -41,6 +43,27 @@ type filter struct {
 	crit     FilterCriteria
 	logs     []*types.Log
 	s        *Subscription // associated subscription in event system
+
+	cp          *filterCheckpoint // persisted delivery position, nil if not persisted
+	backfilling bool              // logs since the checkpoint are still being retrieved
+
+	client   string    // client that installed the filter, empty if restored
+	created  time.Time // time the filter was installed
+	lastPoll time.Time // time the filter was last polled
+	polls    uint64    // number of times the filter was polled
+}
+
+// newInstalledFilter creates a filter installed by the client of the request.
+func newInstalledFilter(ctx context.Context, typ Type, s *Subscription, timeout time.Duration) *filter {
+	now := time.Now()
+	return &filter{
+		typ:      typ,
+		deadline: time.NewTimer(timeout),
+		s:        s,
+		client:   filterClient(ctx),
+		created:  now,
+		lastPoll: now,
+	}
 }
 
 // FilterAPI offers support to create and manage filters. This will allow external clients to retrieve various
@@ -51,21 +74,40 @@ type FilterAPI struct {
 	filtersMu sync.Mutex
 	filters   map[rpc.ID]*filter
 	timeout   time.Duration
+	fanout    *fanout // shared subscription feeds, nil if disabled
+
+	checkpoints bool // whether log filters are persisted across restarts
+
+	limits        FilterLimits                  // cleanup policies for abandoned filters
+	usageMu       sync.Mutex                    // Protects the subscriptions
+	subscriptions map[rpc.ID]*subscriptionUsage // activity of the RPC subscriptions
+	expired       uint64                        // filters not polled within the timeout, accessed atomically
+	evicted       uint64                        // filters beyond the client limit, accessed atomically
+	overflows     uint64                        // filters beyond the backlog limit, accessed atomically
 }
 
 // NewFilterAPI returns a new FilterAPI instance.
 func NewFilterAPI(backend Backend, lightMode bool, timeout time.Duration) *FilterAPI {
 	api := &FilterAPI{
-		backend: backend,
-		events:  NewEventSystem(backend, lightMode),
-		filters: make(map[rpc.ID]*filter),
-		timeout: timeout,
+		backend:       backend,
+		events:        NewEventSystem(backend, lightMode),
+		filters:       make(map[rpc.ID]*filter),
+		timeout:       timeout,
+		subscriptions: make(map[rpc.ID]*subscriptionUsage),
 	}
 	go api.timeoutLoop(timeout)
 
 	return api
 }
 
+// EnableFanout makes subscriptions to new heads and logs with identical criteria
+// share a single internal feed, encoding every notification only once. It is
+// meant for nodes serving many clients and must be called before the API is
+// registered.
+func (api *FilterAPI) EnableFanout() {
+	api.fanout = newFanout()
+}
+
 // timeoutLoop runs at the interval set by 'timeout' and deletes filters
 // that have not been recently used. It is started when the API is created.
 func (api *FilterAPI) timeoutLoop(timeout time.Duration) {
@@ -80,6 +122,10 @@ func (api *FilterAPI) timeoutLoop(timeout time.Duration) {
 			case <-f.deadline.C:
 				toUninstall = append(toUninstall, f.s)
 				delete(api.filters, id)
+				api.deleteCheckpoint(id, f)
+
+				atomic.AddUint64(&api.expired, 1)
+				filterExpiredMeter.Mark(1)
 			default:
 				continue
 			}
@@ -101,25 +147,31 @@ func (api *FilterAPI) timeoutLoop(timeout time.Duration) {
 //
 // It is part of the filter package because this filter can be used through the
 // `eth_getFilterChanges` polling method that is also used for log filters.
-func (api *FilterAPI) NewPendingTransactionFilter() rpc.ID {
+func (api *FilterAPI) NewPendingTransactionFilter(ctx context.Context) rpc.ID {
 	var (
 		pendingTxs   = make(chan []common.Hash)
 		pendingTxSub = api.events.SubscribePendingTxs(pendingTxs)
 	)
 
-	api.filtersMu.Lock()
-	api.filters[pendingTxSub.ID] = &filter{typ: PendingTransactionsSubscription, deadline: time.NewTimer(api.timeout), hashes: make([]common.Hash, 0), s: pendingTxSub}
-	api.filtersMu.Unlock()
+	f := newInstalledFilter(ctx, PendingTransactionsSubscription, pendingTxSub, api.timeout)
+	f.hashes = make([]common.Hash, 0)
+	api.installFilter(pendingTxSub.ID, f)
 
 	go func() {
 		for {
 			select {
 			case ph := <-pendingTxs:
 				api.filtersMu.Lock()
-				if f, found := api.filters[pendingTxSub.ID]; found {
+				f, found := api.filters[pendingTxSub.ID]
+				if found {
 					f.hashes = append(f.hashes, ph...)
 				}
+				overflowed := found && api.overflowed(pendingTxSub.ID, f)
 				api.filtersMu.Unlock()
+
+				if overflowed {
+					pendingTxSub.Unsubscribe()
+				}
 			case <-pendingTxSub.Err():
 				api.filtersMu.Lock()
 				delete(api.filters, pendingTxSub.ID)
@@ -141,8 +193,11 @@ func (api *FilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Subscrip
 	}
 
 	rpcSub := notifier.CreateSubscription()
+	usage := api.trackSubscription(ctx, rpcSub.ID, "newPendingTransactions")
 
 	go func() {
+		defer api.untrackSubscription(rpcSub.ID)
+
 		txHashes := make(chan []common.Hash, 128)
 		pendingTxSub := api.events.SubscribePendingTxs(txHashes)
 
@@ -154,6 +209,7 @@ func (api *FilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Subscrip
 				for _, h := range hashes {
 					notifier.Notify(rpcSub.ID, h)
 				}
+				usage.notify(len(hashes))
 			case <-rpcSub.Err():
 				pendingTxSub.Unsubscribe()
 				return
@@ -167,27 +223,84 @@ func (api *FilterAPI) NewPendingTransactions(ctx context.Context) (*rpc.Subscrip
 	return rpcSub, nil
 }
 
+// droppedTransaction is delivered when a transaction is dropped from the
+// transaction pool.
+type droppedTransaction struct {
+	Hash   common.Hash    `json:"hash"`
+	From   common.Address `json:"from"`
+	Nonce  hexutil.Uint64 `json:"nonce"`
+	Reason string         `json:"reason"`
+}
+
+// DroppedPendingTransactions creates a subscription that is triggered each time
+// a transaction is dropped from the transaction pool without being included,
+// carrying the reason it was dropped.
+func (api *FilterAPI) DroppedPendingTransactions(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+	usage := api.trackSubscription(ctx, rpcSub.ID, "droppedPendingTransactions")
+
+	go func() {
+		defer api.untrackSubscription(rpcSub.ID)
+
+		drops := make(chan []core.DroppedTx, 128)
+		dropsSub := api.events.SubscribeDroppedTxs(drops)
+		defer dropsSub.Unsubscribe()
+
+		for {
+			select {
+			case txs := <-drops:
+				for _, drop := range txs {
+					from, _ := types.Sender(types.LatestSignerForChainID(drop.Tx.ChainId()), drop.Tx)
+					notifier.Notify(rpcSub.ID, &droppedTransaction{
+						Hash:   drop.Tx.Hash(),
+						From:   from,
+						Nonce:  hexutil.Uint64(drop.Tx.Nonce()),
+						Reason: drop.Reason,
+					})
+				}
+				usage.notify(len(txs))
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
 // It is part of the filter package since polling goes with eth_getFilterChanges.
-func (api *FilterAPI) NewBlockFilter() rpc.ID {
+func (api *FilterAPI) NewBlockFilter(ctx context.Context) rpc.ID {
 	var (
 		headers   = make(chan *types.Header)
 		headerSub = api.events.SubscribeNewHeads(headers)
 	)
 
-	api.filtersMu.Lock()
-	api.filters[headerSub.ID] = &filter{typ: BlocksSubscription, deadline: time.NewTimer(api.timeout), hashes: make([]common.Hash, 0), s: headerSub}
-	api.filtersMu.Unlock()
+	f := newInstalledFilter(ctx, BlocksSubscription, headerSub, api.timeout)
+	f.hashes = make([]common.Hash, 0)
+	api.installFilter(headerSub.ID, f)
 
 	go func() {
 		for {
 			select {
 			case h := <-headers:
 				api.filtersMu.Lock()
-				if f, found := api.filters[headerSub.ID]; found {
+				f, found := api.filters[headerSub.ID]
+				if found {
 					f.hashes = append(f.hashes, h.Hash())
 				}
+				overflowed := found && api.overflowed(headerSub.ID, f)
 				api.filtersMu.Unlock()
+
+				if overflowed {
+					headerSub.Unsubscribe()
+				}
 			case <-headerSub.Err():
 				api.filtersMu.Lock()
 				delete(api.filters, headerSub.ID)
@@ -201,22 +314,48 @@ func (api *FilterAPI) NewBlockFilter() rpc.ID {
 }
 
 // NewHeads send a notification each time a new (header) block is appended to the chain.
-func (api *FilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+// The optional criteria restrict the notifications to blocks matching them and
+// reduce the delivered header to the selected fields.
+func (api *FilterAPI) NewHeads(ctx context.Context, crit *HeadFilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
+	if crit == nil {
+		crit = new(HeadFilterCriteria)
+	}
+	if err := crit.validate(); err != nil {
+		return nil, err
+	}
 
 	rpcSub := notifier.CreateSubscription()
+	usage := api.trackSubscription(ctx, rpcSub.ID, "newHeads")
+	if api.fanout != nil {
+		if err := api.fanoutNewHeads(notifier, rpcSub, crit, usage); err != nil {
+			api.untrackSubscription(rpcSub.ID)
+			return nil, err
+		}
+		return rpcSub, nil
+	}
 
 	go func() {
+		defer api.untrackSubscription(rpcSub.ID)
+
 		headers := make(chan *types.Header)
 		headersSub := api.events.SubscribeNewHeads(headers)
 
 		for {
 			select {
 			case h := <-headers:
-				notifier.Notify(rpcSub.ID, h)
+				if !crit.matches(api.backend.ChainDb(), h) {
+					continue
+				}
+				payload, err := crit.project(h)
+				if err != nil {
+					continue
+				}
+				notifier.Notify(rpcSub.ID, payload)
+				usage.notify(1)
 			case <-rpcSub.Err():
 				headersSub.Unsubscribe()
 				return
@@ -230,6 +369,74 @@ func (api *FilterAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
 	return rpcSub, nil
 }
 
+// headLabelRef identifies a block carrying the safe or finalized label.
+type headLabelRef struct {
+	Number    hexutil.Uint64 `json:"number"`
+	Hash      common.Hash    `json:"hash"`
+	Timestamp hexutil.Uint64 `json:"timestamp"`
+}
+
+// headLabelNotification is delivered when the safe or finalized label moves.
+type headLabelNotification struct {
+	Old *headLabelRef `json:"old"` // Previously labelled block, null if none
+	New *headLabelRef `json:"new"`
+}
+
+func newHeadLabelRef(header *types.Header) *headLabelRef {
+	if header == nil {
+		return nil
+	}
+	return &headLabelRef{
+		Number:    hexutil.Uint64(header.Number.Uint64()),
+		Hash:      header.Hash(),
+		Timestamp: hexutil.Uint64(header.Time),
+	}
+}
+
+// SafeHeads sends a notification each time the consensus client moves the safe
+// block, carrying the previous and the new safe block.
+func (api *FilterAPI) SafeHeads(ctx context.Context) (*rpc.Subscription, error) {
+	return api.headLabels(ctx, "safeHeads", api.events.SubscribeSafeHeads)
+}
+
+// FinalizedHeads sends a notification each time the consensus client moves the
+// finalized block, carrying the previous and the new finalized block.
+func (api *FilterAPI) FinalizedHeads(ctx context.Context) (*rpc.Subscription, error) {
+	return api.headLabels(ctx, "finalizedHeads", api.events.SubscribeFinalizedHeads)
+}
+
+// headLabels forwards the moves of a block label to a new RPC subscription.
+func (api *FilterAPI) headLabels(ctx context.Context, typ string, subscribe func(chan HeadLabelChange) *Subscription) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+	usage := api.trackSubscription(ctx, rpcSub.ID, typ)
+
+	go func() {
+		defer api.untrackSubscription(rpcSub.ID)
+
+		changes := make(chan HeadLabelChange)
+		changesSub := subscribe(changes)
+		defer changesSub.Unsubscribe()
+
+		for {
+			select {
+			case c := <-changes:
+				notifier.Notify(rpcSub.ID, &headLabelNotification{Old: newHeadLabelRef(c.Old), New: newHeadLabelRef(c.New)})
+				usage.notify(1)
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // Logs creates a subscription that fires for all new log that match the given filter criteria.
 func (api *FilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
 	notifier, supported := rpc.NotifierFromContext(ctx)
@@ -237,17 +444,25 @@ func (api *FilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subsc
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
 
-	var (
-		rpcSub      = notifier.CreateSubscription()
-		matchedLogs = make(chan []*types.Log)
-	)
+	rpcSub := notifier.CreateSubscription()
+	usage := api.trackSubscription(ctx, rpcSub.ID, "logs")
+	if api.fanout != nil {
+		if err := api.fanoutLogs(notifier, rpcSub, crit, usage); err != nil {
+			api.untrackSubscription(rpcSub.ID)
+			return nil, err
+		}
+		return rpcSub, nil
+	}
+	matchedLogs := make(chan []*types.Log)
 
 	logsSub, err := api.events.SubscribeLogs(ethereum.FilterQuery(crit), matchedLogs)
 	if err != nil {
+		api.untrackSubscription(rpcSub.ID)
 		return nil, err
 	}
 
 	go func() {
+		defer api.untrackSubscription(rpcSub.ID)
 
 		for {
 			select {
@@ -256,6 +471,7 @@ func (api *FilterAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subsc
 					log := log
 					notifier.Notify(rpcSub.ID, &log)
 				}
+				usage.notify(len(logs))
 			case <-rpcSub.Err(): // client send an unsubscribe request
 				logsSub.Unsubscribe()
 				return
@@ -284,38 +500,52 @@ type FilterCriteria ethereum.FilterQuery
 // again but with the removed property set to true.
 //
 // In case "fromBlock" > "toBlock" an error is returned.
-func (api *FilterAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
+func (api *FilterAPI) NewFilter(ctx context.Context, crit FilterCriteria) (rpc.ID, error) {
 	logs := make(chan []*types.Log)
 	logsSub, err := api.events.SubscribeLogs(ethereum.FilterQuery(crit), logs)
 	if err != nil {
 		return "", err
 	}
 
-	api.filtersMu.Lock()
-	api.filters[logsSub.ID] = &filter{typ: LogsSubscription, crit: crit, deadline: time.NewTimer(api.timeout), logs: make([]*types.Log, 0), s: logsSub}
-	api.filtersMu.Unlock()
+	f := newInstalledFilter(ctx, LogsSubscription, logsSub, api.timeout)
+	f.crit, f.logs = crit, make([]*types.Log, 0)
+	if api.checkpoints && checkpointable(crit) {
+		f.cp = api.newCheckpoint(crit)
+		api.writeCheckpoint(logsSub.ID, f.cp)
+	}
+	api.installFilter(logsSub.ID, f)
 
-	go func() {
-		for {
-			select {
-			case l := <-logs:
-				api.filtersMu.Lock()
-				if f, found := api.filters[logsSub.ID]; found {
-					f.logs = append(f.logs, l...)
-				}
-				api.filtersMu.Unlock()
-			case <-logsSub.Err():
-				api.filtersMu.Lock()
-				delete(api.filters, logsSub.ID)
-				api.filtersMu.Unlock()
-				return
-			}
-		}
-	}()
+	go api.collectLogs(logsSub.ID, logsSub, logs)
 
 	return logsSub.ID, nil
 }
 
+// collectLogs queues the logs matched by the subscription of a log filter until
+// they are retrieved by eth_getFilterChanges.
+func (api *FilterAPI) collectLogs(id rpc.ID, logsSub *Subscription, logs chan []*types.Log) {
+	for {
+		select {
+		case l := <-logs:
+			api.filtersMu.Lock()
+			f, found := api.filters[id]
+			if found {
+				f.logs = append(f.logs, l...)
+			}
+			overflowed := found && api.overflowed(id, f)
+			api.filtersMu.Unlock()
+
+			if overflowed {
+				logsSub.Unsubscribe()
+			}
+		case <-logsSub.Err():
+			api.filtersMu.Lock()
+			delete(api.filters, id)
+			api.filtersMu.Unlock()
+			return
+		}
+	}
+}
+
 // GetLogs returns logs matching the given argument that are stored within the state.
 func (api *FilterAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
 	var filter *Filter
@@ -349,6 +579,7 @@ func (api *FilterAPI) UninstallFilter(id rpc.ID) bool {
 	f, found := api.filters[id]
 	if found {
 		delete(api.filters, id)
+		api.deleteCheckpoint(id, f)
 	}
 	api.filtersMu.Unlock()
 	if found {
@@ -410,6 +641,8 @@ func (api *FilterAPI) GetFilterChanges(id rpc.ID) (interface{}, error) {
 			<-f.deadline.C
 		}
 		f.deadline.Reset(api.timeout)
+		f.lastPoll = time.Now()
+		f.polls++
 
 		switch f.typ {
 		case PendingTransactionsSubscription, BlocksSubscription:
@@ -417,8 +650,15 @@ func (api *FilterAPI) GetFilterChanges(id rpc.ID) (interface{}, error) {
 			f.hashes = nil
 			return returnHashes(hashes), nil
 		case LogsSubscription, MinedAndPendingLogsSubscription:
+			if f.backfilling {
+				// Restored filter, deliver nothing until the logs missed are in
+				return returnLogs(nil), nil
+			}
 			logs := f.logs
 			f.logs = nil
+			if f.cp != nil {
+				api.advanceCheckpoint(id, f.cp, logs)
+			}
 			return returnLogs(logs), nil
 		}
 	}
@@ -0,0 +1,267 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// filterCheckpoint is the persisted state of an installed log filter, allowing
+// it to keep serving eth_getFilterChanges across restarts.
+//
+// The checkpoint tracks the last block whose logs were delivered to the client.
+// If that block was reorged out while the node was down, delivery resumes after
+// the last block known to be final instead, delivering some logs twice rather
+// than missing any.
+type filterCheckpoint struct {
+	FromBlock *big.Int         `json:"fromBlock,omitempty"`
+	ToBlock   *big.Int         `json:"toBlock,omitempty"`
+	Addresses []common.Address `json:"addresses,omitempty"`
+	Topics    [][]common.Hash  `json:"topics,omitempty"`
+
+	Number uint64      `json:"number"` // Last block whose logs were delivered
+	Hash   common.Hash `json:"hash"`   // Hash of the last delivered block
+	Safe   uint64      `json:"safe"`   // Last delivered block known to be final
+}
+
+// EnableCheckpoints persists the log filters installed through eth_newFilter
+// along with their delivery position, and restores the ones persisted by a
+// previous run. It must be called before the API is registered.
+func (api *FilterAPI) EnableCheckpoints() {
+	api.checkpoints = true
+	api.restoreFilters()
+}
+
+// checkpointable returns whether filters with the given criteria can be
+// persisted. Filters for a single block or for pending logs can't.
+func checkpointable(crit FilterCriteria) bool {
+	if crit.BlockHash != nil {
+		return false
+	}
+	pending := big.NewInt(rpc.PendingBlockNumber.Int64())
+	return (crit.FromBlock == nil || crit.FromBlock.Cmp(pending) != 0) && (crit.ToBlock == nil || crit.ToBlock.Cmp(pending) != 0)
+}
+
+// newCheckpoint creates the checkpoint of a log filter installed at the current
+// head of the chain.
+func (api *FilterAPI) newCheckpoint(crit FilterCriteria) *filterCheckpoint {
+	cp := &filterCheckpoint{
+		FromBlock: crit.FromBlock,
+		ToBlock:   crit.ToBlock,
+		Addresses: crit.Addresses,
+		Topics:    crit.Topics,
+	}
+	if head, _ := api.backend.HeaderByNumber(context.Background(), rpc.LatestBlockNumber); head != nil {
+		cp.Number, cp.Hash = head.Number.Uint64(), head.Hash()
+	}
+	cp.Safe = api.safeNumber(cp.Number)
+	return cp
+}
+
+// safeNumber returns the last block up to the given one that is final. If the
+// chain doesn't track finality, the given block is assumed to be final.
+func (api *FilterAPI) safeNumber(number uint64) uint64 {
+	final, _ := api.backend.HeaderByNumber(context.Background(), rpc.FinalizedBlockNumber)
+	if final != nil && final.Number.Uint64() < number {
+		return final.Number.Uint64()
+	}
+	return number
+}
+
+// advanceCheckpoint moves the checkpoint of a filter past the delivered logs
+// and persists it.
+func (api *FilterAPI) advanceCheckpoint(id rpc.ID, cp *filterCheckpoint, delivered []*types.Log) {
+	var last *types.Log
+	for _, l := range delivered {
+		if !l.Removed {
+			last = l
+		}
+	}
+	if last == nil || (last.BlockNumber == cp.Number && last.BlockHash == cp.Hash) {
+		return
+	}
+	cp.Number, cp.Hash = last.BlockNumber, last.BlockHash
+	if safe := api.safeNumber(cp.Number); safe > cp.Safe {
+		cp.Safe = safe
+	}
+	api.writeCheckpoint(id, cp)
+}
+
+// writeCheckpoint persists the checkpoint of a filter.
+func (api *FilterAPI) writeCheckpoint(id rpc.ID, cp *filterCheckpoint) {
+	blob, err := json.Marshal(cp)
+	if err != nil {
+		log.Warn("Failed to encode log filter checkpoint", "id", id, "err", err)
+		return
+	}
+	rawdb.WriteFilterCheckpoint(api.backend.ChainDb(), string(id), blob)
+}
+
+// deleteCheckpoint removes the checkpoint of an uninstalled filter.
+func (api *FilterAPI) deleteCheckpoint(id rpc.ID, f *filter) {
+	if f.cp != nil {
+		rawdb.DeleteFilterCheckpoint(api.backend.ChainDb(), string(id))
+	}
+}
+
+// restoreFilters reinstalls the log filters persisted by a previous run under
+// their original ids. Their logs since the checkpoint are retrieved in the
+// background and delivered ahead of the new ones.
+func (api *FilterAPI) restoreFilters() {
+	db := api.backend.ChainDb()
+	for id, blob := range rawdb.ReadFilterCheckpoints(db) {
+		cp := new(filterCheckpoint)
+		if err := json.Unmarshal(blob, cp); err != nil {
+			log.Warn("Dropping corrupt log filter checkpoint", "id", id, "err", err)
+			rawdb.DeleteFilterCheckpoint(db, id)
+			continue
+		}
+		crit := FilterCriteria{
+			FromBlock: cp.FromBlock,
+			ToBlock:   cp.ToBlock,
+			Addresses: cp.Addresses,
+			Topics:    cp.Topics,
+		}
+		from := cp.Number + 1
+		if rawdb.ReadCanonicalHash(db, cp.Number) != cp.Hash {
+			log.Warn("Last delivered block of log filter reorged, resuming after final block", "id", id, "number", cp.Number, "hash", cp.Hash, "final", cp.Safe)
+			from = cp.Safe + 1
+		}
+		logs := make(chan []*types.Log)
+		logsSub, err := api.events.SubscribeLogs(ethereum.FilterQuery(crit), logs)
+		if err != nil {
+			log.Warn("Failed to restore log filter", "id", id, "err", err)
+			rawdb.DeleteFilterCheckpoint(db, id)
+			continue
+		}
+		api.filtersMu.Lock()
+		f := newInstalledFilter(context.Background(), LogsSubscription, logsSub, api.timeout)
+		f.crit, f.logs, f.cp, f.backfilling = crit, make([]*types.Log, 0), cp, true
+		api.filters[rpc.ID(id)] = f
+		api.filtersMu.Unlock()
+
+		go api.collectLogs(rpc.ID(id), logsSub, logs)
+		go api.backfill(rpc.ID(id), crit, from)
+		log.Debug("Restored log filter", "id", id, "from", from)
+	}
+}
+
+const (
+	// maxBackfillBlocks is the maximum number of blocks whose logs a restored
+	// filter retrieves. Filters left behind further are dropped instead.
+	maxBackfillBlocks = 10000
+
+	// backfillRetries is the number of times retrieving the logs of a restored
+	// filter is retried before the filter is dropped.
+	backfillRetries = 3
+)
+
+// backfillRetryDelay is the time to wait before retrying to retrieve the logs
+// of a restored filter.
+var backfillRetryDelay = 5 * time.Second
+
+// errBackfillTooLong is returned if a restored filter missed the logs of more
+// blocks than it's allowed to retrieve.
+var errBackfillTooLong = errors.New("too many blocks to backfill")
+
+// backfill retrieves the logs of a restored filter from the given block up to
+// the current head and queues them ahead of the logs that arrived meanwhile.
+//
+// If the logs can't be retrieved, the filter is dropped along with its checkpoint
+// rather than silently skipping them, so the client notices and reinstalls it.
+func (api *FilterAPI) backfill(id rpc.ID, crit FilterCriteria, from uint64) {
+	for attempt := 0; ; attempt++ {
+		logs, head, err := api.backfillLogs(crit, from)
+		if err == nil {
+			api.finishBackfill(id, logs, head)
+			return
+		}
+		if errors.Is(err, errBackfillTooLong) || attempt == backfillRetries {
+			log.Warn("Dropping restored log filter, missed logs unavailable", "id", id, "from", from, "err", err)
+			api.UninstallFilter(id)
+			return
+		}
+		log.Debug("Failed to retrieve logs of restored filter", "id", id, "from", from, "err", err)
+		time.Sleep(backfillRetryDelay)
+
+		api.filtersMu.Lock()
+		_, found := api.filters[id]
+		api.filtersMu.Unlock()
+		if !found {
+			return
+		}
+	}
+}
+
+// backfillLogs retrieves the logs matching the criteria from the given block up
+// to the current head, returning them along with the head.
+func (api *FilterAPI) backfillLogs(crit FilterCriteria, from uint64) ([]*types.Log, uint64, error) {
+	head, _ := api.backend.HeaderByNumber(context.Background(), rpc.LatestBlockNumber)
+	if head == nil {
+		return nil, 0, errors.New("head block unavailable")
+	}
+	begin, end := int64(from), head.Number.Int64()
+	if crit.FromBlock != nil && crit.FromBlock.Sign() >= 0 && crit.FromBlock.Int64() > begin {
+		begin = crit.FromBlock.Int64()
+	}
+	if crit.ToBlock != nil && crit.ToBlock.Sign() >= 0 && crit.ToBlock.Int64() < end {
+		end = crit.ToBlock.Int64()
+	}
+	if begin > end {
+		return nil, head.Number.Uint64(), nil
+	}
+	if end-begin >= maxBackfillBlocks {
+		return nil, 0, fmt.Errorf("%w: %d, limit %d", errBackfillTooLong, end-begin+1, maxBackfillBlocks)
+	}
+	logs, err := NewRangeFilter(api.backend, begin, end, crit.Addresses, crit.Topics).Logs(context.Background())
+	if err != nil {
+		return nil, 0, err
+	}
+	return logs, head.Number.Uint64(), nil
+}
+
+// finishBackfill queues the retrieved logs of a restored filter, dropping the
+// ones that arrived meanwhile for blocks already covered, and makes the filter
+// deliver logs again.
+func (api *FilterAPI) finishBackfill(id rpc.ID, logs []*types.Log, head uint64) {
+	api.filtersMu.Lock()
+	defer api.filtersMu.Unlock()
+
+	f, found := api.filters[id]
+	if !found {
+		return
+	}
+	for _, l := range f.logs {
+		if l.BlockNumber > head {
+			logs = append(logs, l)
+		}
+	}
+	f.logs = logs
+	f.backfilling = false
+}
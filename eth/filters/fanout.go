@@ -0,0 +1,223 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// fanoutBufferSize is the number of encoded notifications kept per shared feed.
+// Subscribers falling further behind skip the overwritten notifications.
+const fanoutBufferSize = 256
+
+var (
+	fanoutFeedsGauge  = metrics.NewRegisteredGauge("eth/filters/fanout/feeds", nil)
+	fanoutLaggedMeter = metrics.NewRegisteredMeter("eth/filters/fanout/lagged", nil)
+)
+
+// fanout shares a single internal subscription among all subscribers with the
+// same filter criteria. Every notification is encoded once into the ring buffer
+// of the feed, which each subscriber consumes at its own cursor, so slow clients
+// neither delay the others nor cost additional serialization.
+type fanout struct {
+	mu    sync.Mutex
+	feeds map[string]*fanoutFeed // feeds by filter shape
+}
+
+// fanoutFeed is the shared feed of one filter shape.
+type fanoutFeed struct {
+	mu   sync.Mutex
+	ring [fanoutBufferSize]json.RawMessage
+	next uint64        // sequence number of the next notification
+	wake chan struct{} // closed and replaced when a notification is published
+
+	subs        int    // number of subscribers, protected by the fanout lock
+	unsubscribe func() // stops the internal subscription
+}
+
+func newFanout() *fanout {
+	return &fanout{feeds: make(map[string]*fanoutFeed)}
+}
+
+// publish encodes the notification and wakes up all subscribers.
+func (f *fanoutFeed) publish(data interface{}) {
+	enc, err := json.Marshal(data)
+	if err != nil {
+		log.Warn("Failed to encode shared notification", "err", err)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.ring[f.next%fanoutBufferSize] = enc
+	f.next++
+	close(f.wake)
+	f.wake = make(chan struct{})
+}
+
+// subscribe joins the feed of the given shape, starting it with the given
+// function if it does not exist yet. It returns the feed and the cursor of the
+// new subscriber.
+func (fo *fanout) subscribe(shape string, start func(*fanoutFeed) (func(), error)) (*fanoutFeed, uint64, error) {
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+
+	f := fo.feeds[shape]
+	if f == nil {
+		f = &fanoutFeed{wake: make(chan struct{})}
+		unsubscribe, err := start(f)
+		if err != nil {
+			return nil, 0, err
+		}
+		f.unsubscribe = unsubscribe
+		fo.feeds[shape] = f
+		fanoutFeedsGauge.Update(int64(len(fo.feeds)))
+	}
+	f.subs++
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f, f.next, nil
+}
+
+// release leaves the feed of the given shape, stopping it after the last
+// subscriber is gone.
+func (fo *fanout) release(shape string, f *fanoutFeed) {
+	fo.mu.Lock()
+	defer fo.mu.Unlock()
+
+	if f.subs--; f.subs == 0 {
+		f.unsubscribe()
+		delete(fo.feeds, shape)
+		fanoutFeedsGauge.Update(int64(len(fo.feeds)))
+	}
+}
+
+// serve delivers the notifications of the feed from the given cursor on until
+// the subscription ends.
+func (fo *fanout) serve(shape string, f *fanoutFeed, cursor uint64, notifier *rpc.Notifier, sub *rpc.Subscription, usage *subscriptionUsage, done func()) {
+	defer done()
+	defer fo.release(shape, f)
+
+	var pending []json.RawMessage
+	for {
+		f.mu.Lock()
+		next, wake := f.next, f.wake
+		if next-cursor > fanoutBufferSize {
+			fanoutLaggedMeter.Mark(int64(next - cursor - fanoutBufferSize))
+			cursor = next - fanoutBufferSize
+		}
+		pending = pending[:0]
+		for ; cursor < next; cursor++ {
+			pending = append(pending, f.ring[cursor%fanoutBufferSize])
+		}
+		f.mu.Unlock()
+
+		for _, enc := range pending {
+			notifier.Notify(sub.ID, enc)
+		}
+		if len(pending) > 0 {
+			usage.notify(len(pending))
+		}
+		select {
+		case <-wake:
+		case <-sub.Err():
+			return
+		case <-notifier.Closed():
+			return
+		}
+	}
+}
+
+// fanoutNewHeads subscribes to the shared feed of new headers matching crit.
+func (api *FilterAPI) fanoutNewHeads(notifier *rpc.Notifier, sub *rpc.Subscription, crit *HeadFilterCriteria, usage *subscriptionUsage) error {
+	shape, err := json.Marshal(crit)
+	if err != nil {
+		return err
+	}
+	start := func(f *fanoutFeed) (func(), error) {
+		headers := make(chan *types.Header)
+		headersSub := api.events.SubscribeNewHeads(headers)
+		go func() {
+			for {
+				select {
+				case h := <-headers:
+					if !crit.matches(api.backend.ChainDb(), h) {
+						continue
+					}
+					payload, err := crit.project(h)
+					if err != nil {
+						continue
+					}
+					f.publish(payload)
+				case <-headersSub.Err():
+					return
+				}
+			}
+		}()
+		return headersSub.Unsubscribe, nil
+	}
+	key := "newHeads" + string(shape)
+	f, cursor, err := api.fanout.subscribe(key, start)
+	if err != nil {
+		return err
+	}
+	go api.fanout.serve(key, f, cursor, notifier, sub, usage, func() { api.untrackSubscription(sub.ID) })
+	return nil
+}
+
+// fanoutLogs subscribes to the shared feed of logs matching crit.
+func (api *FilterAPI) fanoutLogs(notifier *rpc.Notifier, sub *rpc.Subscription, crit FilterCriteria, usage *subscriptionUsage) error {
+	shape, err := json.Marshal(crit)
+	if err != nil {
+		return err
+	}
+	start := func(f *fanoutFeed) (func(), error) {
+		matchedLogs := make(chan []*types.Log)
+		logsSub, err := api.events.SubscribeLogs(ethereum.FilterQuery(crit), matchedLogs)
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			for {
+				select {
+				case logs := <-matchedLogs:
+					for _, log := range logs {
+						f.publish(log)
+					}
+				case <-logsSub.Err():
+					return
+				}
+			}
+		}()
+		return logsSub.Unsubscribe, nil
+	}
+	key := "logs" + string(shape)
+	f, cursor, err := api.fanout.subscribe(key, start)
+	if err != nil {
+		return err
+	}
+	go api.fanout.serve(key, f, cursor, notifier, sub, usage, func() { api.untrackSubscription(sub.ID) })
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestFanoutNewHeads(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db        = rawdb.NewMemoryDatabase()
+		backend   = &testBackend{db: db}
+		api       = NewFilterAPI(backend, false, deadline)
+		genesis   = (&core.Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}).MustCommit(db)
+		chain, _  = core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 5, func(i int, gen *core.BlockGen) {})
+		server    = rpc.NewServer()
+		feedCount = func() int {
+			api.fanout.mu.Lock()
+			defer api.fanout.mu.Unlock()
+			return len(api.fanout.feeds)
+		}
+	)
+	api.EnableFanout()
+	if err := server.RegisterName("eth", api); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Stop()
+
+	// Identical subscriptions share one feed, different criteria get their own.
+	var (
+		subs  []*rpc.ClientSubscription
+		chans []chan map[string]interface{}
+	)
+	for i := 0; i < 4; i++ {
+		client := rpc.DialInProc(server)
+		defer client.Close()
+
+		ch := make(chan map[string]interface{}, len(chain))
+		args := []interface{}{"newHeads"}
+		if i == 3 {
+			args = append(args, map[string]interface{}{"fields": []string{"hash"}})
+		}
+		sub, err := client.EthSubscribe(context.Background(), ch, args...)
+		if err != nil {
+			t.Fatalf("subscription %d failed: %v", i, err)
+		}
+		subs, chans = append(subs, sub), append(chans, ch)
+	}
+	if n := feedCount(); n != 2 {
+		t.Fatalf("wrong number of shared feeds: have %d, want 2", n)
+	}
+	for _, block := range chain {
+		backend.chainFeed.Send(core.ChainEvent{Hash: block.Hash(), Block: block})
+	}
+	for i, ch := range chans {
+		for _, block := range chain {
+			select {
+			case head := <-ch:
+				if head["hash"] != block.Hash().Hex() {
+					t.Fatalf("subscription %d: wrong head: have %v, want %x", i, head["hash"], block.Hash())
+				}
+				if _, ok := head["number"]; ok == (i == 3) {
+					t.Fatalf("subscription %d: wrong fields: %v", i, head)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("subscription %d: head #%d not delivered", i, block.NumberU64())
+			}
+		}
+	}
+	// The feeds stop after their last subscriber is gone.
+	for _, sub := range subs {
+		sub.Unsubscribe()
+	}
+	for start := time.Now(); feedCount() != 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("shared feeds not stopped: %d left", feedCount())
+		}
+	}
+}
@@ -52,6 +52,13 @@ const (
 	PendingTransactionsSubscription
 	// BlocksSubscription queries hashes for blocks that are imported
 	BlocksSubscription
+	// SafeHeadsSubscription queries moves of the safe block
+	SafeHeadsSubscription
+	// FinalizedHeadsSubscription queries moves of the finalized block
+	FinalizedHeadsSubscription
+	// DroppedTransactionsSubscription queries transactions dropped from the
+	// transaction pool along with the reason
+	DroppedTransactionsSubscription
 	// LastSubscription keeps track of the last index
 	LastIndexSubscription
 )
@@ -66,8 +73,17 @@ const (
 	logsChanSize = 10
 	// chainEvChanSize is the size of channel listening to ChainEvent.
 	chainEvChanSize = 10
+	// labelEvChanSize is the size of channel listening to ChainSafeEvent and
+	// ChainFinalizedEvent.
+	labelEvChanSize = 10
 )
 
+// HeadLabelChange is a move of the safe or finalized label of the chain.
+type HeadLabelChange struct {
+	Old *types.Header // Previously labelled block, nil if none
+	New *types.Header
+}
+
 type subscription struct {
 	id        rpc.ID
 	typ       Type
@@ -76,6 +92,8 @@ type subscription struct {
 	logs      chan []*types.Log
 	hashes    chan []common.Hash
 	headers   chan *types.Header
+	labels    chan HeadLabelChange
+	drops     chan []core.DroppedTx
 	installed chan struct{} // closed when the filter is installed
 	err       chan error    // closed when the filter is uninstalled
 }
@@ -93,15 +111,21 @@ type EventSystem struct {
 	rmLogsSub      event.Subscription // Subscription for removed log event
 	pendingLogsSub event.Subscription // Subscription for pending log event
 	chainSub       event.Subscription // Subscription for new chain event
+	safeSub        event.Subscription // Subscription for safe block event
+	finalizedSub   event.Subscription // Subscription for finalized block event
+	dropTxsSub     event.Subscription // Subscription for dropped transaction event
 
 	// Channels
-	install       chan *subscription         // install filter for event notification
-	uninstall     chan *subscription         // remove filter for event notification
-	txsCh         chan core.NewTxsEvent      // Channel to receive new transactions event
-	logsCh        chan []*types.Log          // Channel to receive new log event
-	pendingLogsCh chan []*types.Log          // Channel to receive new log event
-	rmLogsCh      chan core.RemovedLogsEvent // Channel to receive removed log event
-	chainCh       chan core.ChainEvent       // Channel to receive new chain event
+	install       chan *subscription            // install filter for event notification
+	uninstall     chan *subscription            // remove filter for event notification
+	txsCh         chan core.NewTxsEvent         // Channel to receive new transactions event
+	logsCh        chan []*types.Log             // Channel to receive new log event
+	pendingLogsCh chan []*types.Log             // Channel to receive new log event
+	rmLogsCh      chan core.RemovedLogsEvent    // Channel to receive removed log event
+	chainCh       chan core.ChainEvent          // Channel to receive new chain event
+	safeCh        chan core.ChainSafeEvent      // Channel to receive safe block event
+	finalizedCh   chan core.ChainFinalizedEvent // Channel to receive finalized block event
+	dropTxsCh     chan core.DropTxsEvent        // Channel to receive dropped transaction event
 }
 
 // NewEventSystem creates a new manager that listens for event on the given mux,
@@ -121,6 +145,9 @@ func NewEventSystem(backend Backend, lightMode bool) *EventSystem {
 		rmLogsCh:      make(chan core.RemovedLogsEvent, rmLogsChanSize),
 		pendingLogsCh: make(chan []*types.Log, logsChanSize),
 		chainCh:       make(chan core.ChainEvent, chainEvChanSize),
+		safeCh:        make(chan core.ChainSafeEvent, labelEvChanSize),
+		finalizedCh:   make(chan core.ChainFinalizedEvent, labelEvChanSize),
+		dropTxsCh:     make(chan core.DropTxsEvent, txChanSize),
 	}
 
 	// Subscribe events
@@ -129,9 +156,12 @@ func NewEventSystem(backend Backend, lightMode bool) *EventSystem {
 	m.rmLogsSub = m.backend.SubscribeRemovedLogsEvent(m.rmLogsCh)
 	m.chainSub = m.backend.SubscribeChainEvent(m.chainCh)
 	m.pendingLogsSub = m.backend.SubscribePendingLogsEvent(m.pendingLogsCh)
+	m.safeSub = m.backend.SubscribeChainSafeEvent(m.safeCh)
+	m.finalizedSub = m.backend.SubscribeChainFinalizedEvent(m.finalizedCh)
+	m.dropTxsSub = m.backend.SubscribeDropTxsEvent(m.dropTxsCh)
 
 	// Make sure none of the subscriptions are empty
-	if m.txsSub == nil || m.logsSub == nil || m.rmLogsSub == nil || m.chainSub == nil || m.pendingLogsSub == nil {
+	if m.txsSub == nil || m.logsSub == nil || m.rmLogsSub == nil || m.chainSub == nil || m.pendingLogsSub == nil || m.safeSub == nil || m.finalizedSub == nil || m.dropTxsSub == nil {
 		log.Crit("Subscribe for event system failed")
 	}
 
@@ -167,6 +197,8 @@ func (sub *Subscription) Unsubscribe() {
 			case <-sub.f.logs:
 			case <-sub.f.hashes:
 			case <-sub.f.headers:
+			case <-sub.f.labels:
+			case <-sub.f.drops:
 			}
 		}
 
@@ -306,6 +338,50 @@ func (es *EventSystem) SubscribePendingTxs(hashes chan []common.Hash) *Subscript
 	return es.subscribe(sub)
 }
 
+// SubscribeSafeHeads creates a subscription that writes the moves of the safe
+// block, as reported by the consensus client.
+func (es *EventSystem) SubscribeSafeHeads(labels chan HeadLabelChange) *Subscription {
+	return es.subscribeLabels(SafeHeadsSubscription, labels)
+}
+
+// SubscribeFinalizedHeads creates a subscription that writes the moves of the
+// finalized block, as reported by the consensus client.
+func (es *EventSystem) SubscribeFinalizedHeads(labels chan HeadLabelChange) *Subscription {
+	return es.subscribeLabels(FinalizedHeadsSubscription, labels)
+}
+
+// SubscribeDroppedTxs creates a subscription that writes the transactions
+// dropped from the transaction pool, along with the reason.
+func (es *EventSystem) SubscribeDroppedTxs(drops chan []core.DroppedTx) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       DroppedTransactionsSubscription,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan []common.Hash),
+		headers:   make(chan *types.Header),
+		drops:     drops,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
+func (es *EventSystem) subscribeLabels(typ Type, labels chan HeadLabelChange) *Subscription {
+	sub := &subscription{
+		id:        rpc.NewID(),
+		typ:       typ,
+		created:   time.Now(),
+		logs:      make(chan []*types.Log),
+		hashes:    make(chan []common.Hash),
+		headers:   make(chan *types.Header),
+		labels:    labels,
+		installed: make(chan struct{}),
+		err:       make(chan error),
+	}
+	return es.subscribe(sub)
+}
+
 type filterIndex map[Type]map[rpc.ID]*subscription
 
 func (es *EventSystem) handleLogs(filters filterIndex, ev []*types.Log) {
@@ -351,6 +427,12 @@ func (es *EventSystem) handleTxsEvent(filters filterIndex, ev core.NewTxsEvent)
 	}
 }
 
+func (es *EventSystem) handleDropTxsEvent(filters filterIndex, ev core.DropTxsEvent) {
+	for _, f := range filters[DroppedTransactionsSubscription] {
+		f.drops <- ev.Txs
+	}
+}
+
 func (es *EventSystem) handleChainEvent(filters filterIndex, ev core.ChainEvent) {
 	for _, f := range filters[BlocksSubscription] {
 		f.headers <- ev.Block.Header()
@@ -366,6 +448,16 @@ func (es *EventSystem) handleChainEvent(filters filterIndex, ev core.ChainEvent)
 	}
 }
 
+func (es *EventSystem) handleLabelEvent(filters filterIndex, typ Type, block, old *types.Block) {
+	change := HeadLabelChange{New: block.Header()}
+	if old != nil {
+		change.Old = old.Header()
+	}
+	for _, f := range filters[typ] {
+		f.labels <- change
+	}
+}
+
 func (es *EventSystem) lightFilterNewHead(newHeader *types.Header, callBack func(*types.Header, bool)) {
 	oldh := es.lastHead
 	es.lastHead = newHeader
@@ -448,6 +540,9 @@ func (es *EventSystem) eventLoop() {
 		es.rmLogsSub.Unsubscribe()
 		es.pendingLogsSub.Unsubscribe()
 		es.chainSub.Unsubscribe()
+		es.safeSub.Unsubscribe()
+		es.finalizedSub.Unsubscribe()
+		es.dropTxsSub.Unsubscribe()
 	}()
 
 	index := make(filterIndex)
@@ -467,6 +562,12 @@ func (es *EventSystem) eventLoop() {
 			es.handlePendingLogs(index, ev)
 		case ev := <-es.chainCh:
 			es.handleChainEvent(index, ev)
+		case ev := <-es.safeCh:
+			es.handleLabelEvent(index, SafeHeadsSubscription, ev.Block, ev.Old)
+		case ev := <-es.finalizedCh:
+			es.handleLabelEvent(index, FinalizedHeadsSubscription, ev.Block, ev.Old)
+		case ev := <-es.dropTxsCh:
+			es.handleDropTxsEvent(index, ev)
 
 		case f := <-es.install:
 			if f.typ == MinedAndPendingLogsSubscription {
@@ -18,6 +18,7 @@ package filters
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"math/rand"
@@ -47,10 +48,13 @@ type testBackend struct {
 	db              ethdb.Database
 	sections        uint64
 	txFeed          event.Feed
+	dropTxsFeed     event.Feed
 	logsFeed        event.Feed
 	rmLogsFeed      event.Feed
 	pendingLogsFeed event.Feed
 	chainFeed       event.Feed
+	safeFeed        event.Feed
+	finalizedFeed   event.Feed
 }
 
 func (b *testBackend) ChainDb() ethdb.Database {
@@ -62,8 +66,11 @@ func (b *testBackend) HeaderByNumber(ctx context.Context, blockNr rpc.BlockNumbe
 		hash common.Hash
 		num  uint64
 	)
-	if blockNr == rpc.LatestBlockNumber {
+	if blockNr == rpc.LatestBlockNumber || blockNr == rpc.FinalizedBlockNumber {
 		hash = rawdb.ReadHeadBlockHash(b.db)
+		if blockNr == rpc.FinalizedBlockNumber {
+			hash = rawdb.ReadFinalizedBlockHash(b.db)
+		}
 		number := rawdb.ReadHeaderNumber(b.db, hash)
 		if number == nil {
 			return nil, nil
@@ -113,6 +120,10 @@ func (b *testBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Sub
 	return b.txFeed.Subscribe(ch)
 }
 
+func (b *testBackend) SubscribeDropTxsEvent(ch chan<- core.DropTxsEvent) event.Subscription {
+	return b.dropTxsFeed.Subscribe(ch)
+}
+
 func (b *testBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return b.rmLogsFeed.Subscribe(ch)
 }
@@ -129,6 +140,14 @@ func (b *testBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subsc
 	return b.chainFeed.Subscribe(ch)
 }
 
+func (b *testBackend) SubscribeChainSafeEvent(ch chan<- core.ChainSafeEvent) event.Subscription {
+	return b.safeFeed.Subscribe(ch)
+}
+
+func (b *testBackend) SubscribeChainFinalizedEvent(ch chan<- core.ChainFinalizedEvent) event.Subscription {
+	return b.finalizedFeed.Subscribe(ch)
+}
+
 func (b *testBackend) BloomStatus() (uint64, uint64) {
 	return params.BloomBitsBlocks, b.sections
 }
@@ -216,6 +235,82 @@ func TestBlockSubscription(t *testing.T) {
 	<-sub1.Err()
 }
 
+// TestHeadLabelSubscriptions tests that moves of the safe and finalized labels
+// are delivered to their respective subscriptions only.
+func TestHeadLabelSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db       = rawdb.NewMemoryDatabase()
+		backend  = &testBackend{db: db}
+		api      = NewFilterAPI(backend, false, deadline)
+		genesis  = (&core.Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}).MustCommit(db)
+		chain, _ = core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 3, func(i int, gen *core.BlockGen) {})
+	)
+	safe := make(chan HeadLabelChange, 2)
+	safeSub := api.events.SubscribeSafeHeads(safe)
+	defer safeSub.Unsubscribe()
+	final := make(chan HeadLabelChange, 2)
+	finalSub := api.events.SubscribeFinalizedHeads(final)
+	defer finalSub.Unsubscribe()
+
+	go func() {
+		backend.safeFeed.Send(core.ChainSafeEvent{Block: chain[0]})
+		backend.finalizedFeed.Send(core.ChainFinalizedEvent{Block: chain[0]})
+		backend.safeFeed.Send(core.ChainSafeEvent{Block: chain[2], Old: chain[0]})
+	}()
+	expect := func(ch chan HeadLabelChange, old, new *types.Block) {
+		t.Helper()
+		select {
+		case c := <-ch:
+			if (old == nil) != (c.Old == nil) || (old != nil && c.Old.Hash() != old.Hash()) {
+				t.Errorf("old block mismatch: have %v, want %v", c.Old, old)
+			}
+			if c.New.Hash() != new.Hash() {
+				t.Errorf("new block mismatch: have %d, want %d", c.New.Number, new.Number())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("label change not delivered")
+		}
+	}
+	expect(safe, nil, chain[0])
+	expect(final, nil, chain[0])
+	expect(safe, chain[0], chain[2])
+
+	select {
+	case c := <-final:
+		t.Fatalf("safe label change delivered as finalized: %d", c.New.Number)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestDroppedTxSubscription tests whether dropped transactions are delivered
+// along with the reason of the drop.
+func TestDroppedTxSubscription(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewFilterAPI(backend, false, deadline)
+		tx      = types.NewTransaction(0, common.HexToAddress("0xb794f5ea0ba39494ce83a213fffba74279579268"), new(big.Int), 0, new(big.Int), nil)
+	)
+	drops := make(chan []core.DroppedTx, 1)
+	sub := api.events.SubscribeDroppedTxs(drops)
+	defer sub.Unsubscribe()
+
+	go backend.dropTxsFeed.Send(core.DropTxsEvent{Txs: []core.DroppedTx{{Tx: tx, Reason: core.TxDropUnderpriced}}})
+
+	select {
+	case txs := <-drops:
+		if len(txs) != 1 || txs[0].Tx.Hash() != tx.Hash() || txs[0].Reason != core.TxDropUnderpriced {
+			t.Fatalf("dropped transactions mismatch: have %v", txs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("dropped transaction not delivered")
+	}
+}
+
 // TestPendingTxFilter tests whether pending tx filters retrieve all pending transactions that are posted to the event mux.
 func TestPendingTxFilter(t *testing.T) {
 	t.Parallel()
@@ -236,7 +331,7 @@ func TestPendingTxFilter(t *testing.T) {
 		hashes []common.Hash
 	)
 
-	fid0 := api.NewPendingTransactionFilter()
+	fid0 := api.NewPendingTransactionFilter(context.Background())
 
 	time.Sleep(1 * time.Second)
 	backend.txFeed.Send(core.NewTxsEvent{Txs: transactions})
@@ -304,7 +399,7 @@ func TestLogFilterCreation(t *testing.T) {
 	)
 
 	for i, test := range testCases {
-		id, err := api.NewFilter(test.crit)
+		id, err := api.NewFilter(context.Background(), test.crit)
 		if err != nil && test.success {
 			t.Errorf("expected filter creation for case %d to success, got %v", i, err)
 		}
@@ -337,7 +432,7 @@ func TestInvalidLogFilterCreation(t *testing.T) {
 	}
 
 	for i, test := range testCases {
-		if _, err := api.NewFilter(test); err == nil {
+		if _, err := api.NewFilter(context.Background(), test); err == nil {
 			t.Errorf("Expected NewFilter for case #%d to fail", i)
 		}
 	}
@@ -430,7 +525,7 @@ func TestLogFilter(t *testing.T) {
 
 	// create all filters
 	for i := range testCases {
-		testCases[i].id, _ = api.NewFilter(testCases[i].crit)
+		testCases[i].id, _ = api.NewFilter(context.Background(), testCases[i].crit)
 	}
 
 	// raise events
@@ -479,6 +574,141 @@ func TestLogFilter(t *testing.T) {
 	}
 }
 
+// TestLogFilterCheckpoint tests that persisted log filters are restored and
+// deliver the logs of the blocks imported while the node was down, resuming
+// after the last final block if the last delivered one was reorged out.
+func TestLogFilterCheckpoint(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		addr    = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		genesis = core.GenesisBlockForTesting(db, addr, big.NewInt(1000000))
+	)
+	chain, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 6, func(i int, gen *core.BlockGen) {
+		if i == 1 || i == 4 {
+			receipt := types.NewReceipt(nil, false, 0)
+			receipt.Logs = []*types.Log{{Address: addr}}
+			gen.AddUncheckedReceipt(receipt)
+			gen.AddUncheckedTx(types.NewTransaction(uint64(i), common.Address{}, big.NewInt(1), 1, gen.BaseFee(), nil))
+		}
+	})
+	importBlocks := func(blocks []*types.Block, receipts []types.Receipts) {
+		for i, block := range blocks {
+			rawdb.WriteBlock(db, block)
+			rawdb.WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+			rawdb.WriteHeadBlockHash(db, block.Hash())
+			rawdb.WriteReceipts(db, block.Hash(), block.NumberU64(), receipts[i])
+		}
+	}
+	fetchLogs := func(api *FilterAPI, id rpc.ID, want int) []*types.Log {
+		var fetched []*types.Log
+		for timeout := time.Now().Add(time.Second); len(fetched) < want && time.Now().Before(timeout); time.Sleep(10 * time.Millisecond) {
+			results, err := api.GetFilterChanges(id)
+			if err != nil {
+				t.Fatalf("failed to fetch logs: %v", err)
+			}
+			fetched = append(fetched, results.([]*types.Log)...)
+		}
+		return fetched
+	}
+	importBlocks(chain[:1], receipts[:1])
+
+	api := NewFilterAPI(backend, false, deadline)
+	api.EnableCheckpoints()
+	id, err := api.NewFilter(context.Background(), FilterCriteria{Addresses: []common.Address{addr}})
+	if err != nil {
+		t.Fatalf("failed to install filter: %v", err)
+	}
+	// Deliver the logs of block 2, checkpointing the filter there
+	importBlocks(chain[1:3], receipts[1:3])
+	rawdb.WriteFinalizedBlockHash(db, chain[0].Hash())
+
+	time.Sleep(100 * time.Millisecond)
+	backend.logsFeed.Send(rawdb.ReadReceipts(db, chain[1].Hash(), 2, params.TestChainConfig)[0].Logs)
+	if logs := fetchLogs(api, id, 1); len(logs) != 1 || logs[0].BlockNumber != 2 {
+		t.Fatalf("live logs mismatch: have %v, want 1 log of block 2", logs)
+	}
+	// Restart with blocks imported while down, the logs of block 5 are delivered
+	importBlocks(chain[3:], receipts[3:])
+
+	restarted := NewFilterAPI(backend, false, deadline)
+	restarted.EnableCheckpoints()
+	if logs := fetchLogs(restarted, id, 1); len(logs) != 1 || logs[0].BlockNumber != 5 {
+		t.Fatalf("restored logs mismatch: have %v, want 1 log of block 5", logs)
+	}
+	// Pretend block 5 was reorged out, delivery resumes after the final block 1
+	blob := rawdb.ReadFilterCheckpoints(db)[string(id)]
+	cp := new(filterCheckpoint)
+	if err := json.Unmarshal(blob, cp); err != nil {
+		t.Fatalf("failed to decode checkpoint: %v", err)
+	}
+	if cp.Number != 5 || cp.Safe != 1 {
+		t.Fatalf("checkpoint mismatch: have number %d safe %d, want number 5 safe 1", cp.Number, cp.Safe)
+	}
+	cp.Hash = common.Hash{0x01}
+	blob, _ = json.Marshal(cp)
+	rawdb.WriteFilterCheckpoint(db, string(id), blob)
+
+	reorged := NewFilterAPI(backend, false, deadline)
+	reorged.EnableCheckpoints()
+	if logs := fetchLogs(reorged, id, 2); len(logs) != 2 || logs[0].BlockNumber != 2 || logs[1].BlockNumber != 5 {
+		t.Fatalf("redelivered logs mismatch: have %v, want logs of blocks 2 and 5", logs)
+	}
+	// Uninstalling the filter forgets it for good
+	if !reorged.UninstallFilter(id) {
+		t.Fatal("failed to uninstall restored filter")
+	}
+	if _, ok := rawdb.ReadFilterCheckpoints(db)[string(id)]; ok {
+		t.Fatal("checkpoint of uninstalled filter retained")
+	}
+}
+
+// TestLogFilterCheckpointBackfillFailure tests that restored filters whose
+// missed logs can't be retrieved are dropped along with their checkpoint,
+// instead of skipping the logs.
+func TestLogFilterCheckpointBackfillFailure(t *testing.T) {
+	defer func(delay time.Duration) { backfillRetryDelay = delay }(backfillRetryDelay)
+	backfillRetryDelay = 10 * time.Millisecond
+
+	addr := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	dropped := func(db ethdb.Database) bool {
+		blob, _ := json.Marshal(&filterCheckpoint{Addresses: []common.Address{addr}})
+		rawdb.WriteFilterCheckpoint(db, "0x1", blob)
+
+		api := NewFilterAPI(&testBackend{db: db}, false, deadline)
+		api.EnableCheckpoints()
+		for timeout := time.Now().Add(time.Second); time.Now().Before(timeout); time.Sleep(10 * time.Millisecond) {
+			if _, err := api.GetFilterChanges("0x1"); err != nil {
+				return true
+			}
+		}
+		return false
+	}
+	// The head is unavailable, retrieving the logs fails until retries run out
+	db := rawdb.NewMemoryDatabase()
+	if !dropped(db) {
+		t.Fatal("filter without retrievable logs retained")
+	}
+	if _, ok := rawdb.ReadFilterCheckpoints(db)["0x1"]; ok {
+		t.Fatal("checkpoint of dropped filter retained")
+	}
+	// The head is too far ahead of the checkpoint to retrieve the missed logs
+	db = rawdb.NewMemoryDatabase()
+	head := &types.Header{Number: big.NewInt(2 * maxBackfillBlocks), Difficulty: common.Big1}
+	rawdb.WriteHeader(db, head)
+	rawdb.WriteCanonicalHash(db, head.Hash(), head.Number.Uint64())
+	rawdb.WriteHeadBlockHash(db, head.Hash())
+
+	if !dropped(db) {
+		t.Fatal("filter beyond the backfill limit retained")
+	}
+	if _, ok := rawdb.ReadFilterCheckpoints(db)["0x1"]; ok {
+		t.Fatal("checkpoint of dropped filter retained")
+	}
+}
+
 // TestPendingLogsSubscription tests if a subscription receives the correct pending logs that are posted to the event feed.
 func TestPendingLogsSubscription(t *testing.T) {
 	t.Parallel()
@@ -694,7 +924,7 @@ func TestPendingTxFilterDeadlock(t *testing.T) {
 	// timeout either in 100ms or 200ms
 	fids := make([]rpc.ID, 20)
 	for i := 0; i < len(fids); i++ {
-		fid := api.NewPendingTransactionFilter()
+		fid := api.NewPendingTransactionFilter(context.Background())
 		fids[i] = fid
 		// Wait for at least one tx to arrive in filter
 		for {
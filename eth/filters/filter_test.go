@@ -216,4 +216,20 @@ func TestFilters(t *testing.T) {
 	if len(logs) != 0 {
 		t.Error("expected 0 log, got", len(logs))
 	}
+
+	// Ranges reaching below the pruned log index must be refused
+	rawdb.WriteLogIndexTail(db, 900)
+
+	filter = NewRangeFilter(backend, 1, 10, nil, [][]common.Hash{{hash1, hash2}})
+	if _, err := filter.Logs(context.Background()); err == nil || err.Error() != "indexed history starts at block 900" {
+		t.Errorf("expected pruned history error, got %v", err)
+	}
+	filter = NewRangeFilter(backend, 900, 999, []common.Address{addr}, [][]common.Hash{{hash3}})
+	logs, err := filter.Logs(context.Background())
+	if err != nil {
+		t.Fatalf("failed to filter retained logs: %v", err)
+	}
+	if len(logs) != 1 {
+		t.Error("expected 1 log, got", len(logs))
+	}
 }
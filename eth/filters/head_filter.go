@@ -0,0 +1,113 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// headerFields is the set of field names a newHeads subscriber may select,
+// derived from the JSON encoding of types.Header.
+var headerFields = func() map[string]struct{} {
+	enc, err := json.Marshal(&types.Header{})
+	if err != nil {
+		panic(err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(enc, &fields); err != nil {
+		panic(err)
+	}
+	set := make(map[string]struct{}, len(fields))
+	for name := range fields {
+		set[name] = struct{}{}
+	}
+	return set
+}()
+
+// HeadFilterCriteria narrows down the notifications delivered to a single
+// newHeads subscriber. All conditions must hold for a header to be delivered.
+type HeadFilterCriteria struct {
+	Fields     []string         `json:"fields"`     // Header fields to include, all of them if empty
+	MinGasUsed *hexutil.Uint64  `json:"minGasUsed"` // Only blocks that used more gas than this
+	To         []common.Address `json:"to"`         // Only blocks containing a transaction to one of these addresses
+}
+
+// validate checks that all requested header fields are known.
+func (crit *HeadFilterCriteria) validate() error {
+	for _, field := range crit.Fields {
+		if _, ok := headerFields[field]; !ok {
+			return fmt.Errorf("unknown header field %q", field)
+		}
+	}
+	return nil
+}
+
+// matches reports whether the header satisfies the criteria. The block body is
+// only loaded from the database when filtering on transaction recipients.
+func (crit *HeadFilterCriteria) matches(db ethdb.Reader, header *types.Header) bool {
+	if crit.MinGasUsed != nil && header.GasUsed <= uint64(*crit.MinGasUsed) {
+		return false
+	}
+	if len(crit.To) == 0 {
+		return true
+	}
+	body := rawdb.ReadBody(db, header.Hash(), header.Number.Uint64())
+	if body == nil {
+		return false
+	}
+	for _, tx := range body.Transactions {
+		if tx.To() == nil {
+			continue
+		}
+		for _, addr := range crit.To {
+			if *tx.To() == addr {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// project returns the notification payload for the header, reduced to the
+// requested field set.
+func (crit *HeadFilterCriteria) project(header *types.Header) (interface{}, error) {
+	if len(crit.Fields) == 0 {
+		return header, nil
+	}
+	enc, err := json.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(enc, &fields); err != nil {
+		return nil, err
+	}
+	result := make(map[string]json.RawMessage, len(crit.Fields))
+	for _, field := range crit.Fields {
+		if value, ok := fields[field]; ok {
+			result[field] = value
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+func TestHeadFilterCriteria(t *testing.T) {
+	var (
+		db    = rawdb.NewMemoryDatabase()
+		addr1 = common.HexToAddress("0x1111111111111111111111111111111111111111")
+		addr2 = common.HexToAddress("0x2222222222222222222222222222222222222222")
+		tx    = types.NewTransaction(0, addr1, big.NewInt(0), 21000, big.NewInt(1), nil)
+		block = types.NewBlock(&types.Header{Number: big.NewInt(1), GasUsed: 21000}, []*types.Transaction{tx}, nil, nil, trie.NewStackTrie(nil))
+	)
+	rawdb.WriteBlock(db, block)
+
+	minGas := func(n uint64) *hexutil.Uint64 { v := hexutil.Uint64(n); return &v }
+	tests := []struct {
+		crit HeadFilterCriteria
+		want bool
+	}{
+		{HeadFilterCriteria{}, true},
+		{HeadFilterCriteria{MinGasUsed: minGas(20000)}, true},
+		{HeadFilterCriteria{MinGasUsed: minGas(21000)}, false},
+		{HeadFilterCriteria{To: []common.Address{addr1}}, true},
+		{HeadFilterCriteria{To: []common.Address{addr2}}, false},
+		{HeadFilterCriteria{To: []common.Address{addr2, addr1}, MinGasUsed: minGas(0)}, true},
+	}
+	for i, test := range tests {
+		if have := test.crit.matches(db, block.Header()); have != test.want {
+			t.Errorf("test %d: match mismatch: have %v, want %v", i, have, test.want)
+		}
+	}
+}
+
+func TestHeadFilterProjection(t *testing.T) {
+	crit := HeadFilterCriteria{Fields: []string{"number", "gasUsed", "hash"}}
+	if err := crit.validate(); err != nil {
+		t.Fatalf("valid criteria rejected: %v", err)
+	}
+	header := &types.Header{Number: big.NewInt(10), GasUsed: 0x5208}
+	payload, err := crit.project(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, _ := json.Marshal(payload)
+	var fields map[string]string
+	if err := json.Unmarshal(enc, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 3 {
+		t.Fatalf("wrong number of fields: have %d, want 3", len(fields))
+	}
+	if fields["number"] != "0xa" || fields["gasUsed"] != "0x5208" || fields["hash"] != header.Hash().Hex() {
+		t.Fatalf("wrong projected fields: %v", fields)
+	}
+
+	bad := HeadFilterCriteria{Fields: []string{"gasUsed", "notAField"}}
+	if err := bad.validate(); err == nil {
+		t.Fatal("expected error for unknown field")
+	}
+}
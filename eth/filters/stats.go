@@ -0,0 +1,295 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"net"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	filterExpiredMeter    = metrics.NewRegisteredMeter("eth/filters/expired", nil)
+	filterEvictedMeter    = metrics.NewRegisteredMeter("eth/filters/evicted", nil)
+	filterOverflowedMeter = metrics.NewRegisteredMeter("eth/filters/overflowed", nil)
+)
+
+// FilterLimits are the cleanup policies for filters abandoned by their clients,
+// on top of uninstalling the ones not polled within the filter timeout.
+type FilterLimits struct {
+	ClientFilters int // Filters per client, the least recently polled are uninstalled beyond (0 = unlimited)
+	Backlog       int // Undelivered hashes or logs per filter, the filter is uninstalled beyond (0 = unlimited)
+}
+
+// SetLimits sets the cleanup policies for abandoned filters. It must be called
+// before the API is registered.
+func (api *FilterAPI) SetLimits(limits FilterLimits) {
+	api.limits = limits
+}
+
+// FilterUsage describes an installed filter or subscription and the activity
+// of its client.
+type FilterUsage struct {
+	ID        rpc.ID         `json:"id"`
+	Kind      string         `json:"kind"` // "filter" or "subscription"
+	Type      string         `json:"type"`
+	Client    string         `json:"client"`
+	Created   time.Time      `json:"created"`
+	Age       hexutil.Uint64 `json:"age"`       // in seconds
+	Idle      hexutil.Uint64 `json:"idle"`      // seconds since last polled or notified
+	Delivered hexutil.Uint64 `json:"delivered"` // polls of a filter, notifications of a subscription
+	Backlog   int            `json:"backlog"`   // undelivered hashes or logs of a filter
+}
+
+// ClientFilterUsage sums up the filters and subscriptions of a client.
+type ClientFilterUsage struct {
+	Filters       int `json:"filters"`
+	Subscriptions int `json:"subscriptions"`
+	Backlog       int `json:"backlog"`
+}
+
+// FilterStats is the usage of filters and subscriptions along with the number
+// of filters uninstalled by the cleanup policies.
+type FilterStats struct {
+	Filters    []*FilterUsage                `json:"filters"`
+	Clients    map[string]*ClientFilterUsage `json:"clients"`
+	Expired    hexutil.Uint64                `json:"expired"`    // not polled within the timeout
+	Evicted    hexutil.Uint64                `json:"evicted"`    // beyond the limit of their client
+	Overflowed hexutil.Uint64                `json:"overflowed"` // beyond the backlog limit
+}
+
+// subscriptionUsage tracks the activity of an RPC subscription.
+type subscriptionUsage struct {
+	typ      string
+	client   string
+	created  time.Time
+	notified uint64 // Number of notifications sent, accessed atomically
+	last     int64  // Unix nanoseconds of the last notification, accessed atomically
+}
+
+// notify records that notifications were sent for the subscription.
+func (u *subscriptionUsage) notify(n int) {
+	atomic.AddUint64(&u.notified, uint64(n))
+	atomic.StoreInt64(&u.last, time.Now().UnixNano())
+}
+
+// filterClient identifies the RPC client of the request by its IP address.
+func filterClient(ctx context.Context) string {
+	peer := rpc.PeerInfoFromContext(ctx)
+	if host, _, err := net.SplitHostPort(peer.RemoteAddr); err == nil {
+		return host
+	}
+	if peer.RemoteAddr != "" {
+		return peer.RemoteAddr
+	}
+	return peer.Transport
+}
+
+// filterTypeName returns the name of a filter type in the filter statistics.
+func filterTypeName(typ Type) string {
+	switch typ {
+	case LogsSubscription, MinedAndPendingLogsSubscription, PendingLogsSubscription:
+		return "logs"
+	case BlocksSubscription:
+		return "blocks"
+	case PendingTransactionsSubscription:
+		return "pendingTransactions"
+	case DroppedTransactionsSubscription:
+		return "droppedPendingTransactions"
+	default:
+		return "unknown"
+	}
+}
+
+// trackSubscription starts tracking the activity of a new subscription. It must
+// be stopped with untrackSubscription once the subscription ends.
+func (api *FilterAPI) trackSubscription(ctx context.Context, id rpc.ID, typ string) *subscriptionUsage {
+	now := time.Now()
+	usage := &subscriptionUsage{typ: typ, client: filterClient(ctx), created: now, last: now.UnixNano()}
+
+	api.usageMu.Lock()
+	api.subscriptions[id] = usage
+	api.usageMu.Unlock()
+
+	return usage
+}
+
+// untrackSubscription stops tracking an ended subscription.
+func (api *FilterAPI) untrackSubscription(id rpc.ID) {
+	api.usageMu.Lock()
+	delete(api.subscriptions, id)
+	api.usageMu.Unlock()
+}
+
+// installFilter registers a new filter, uninstalling the least recently polled
+// filters of its client beyond the client limit.
+func (api *FilterAPI) installFilter(id rpc.ID, f *filter) {
+	api.filtersMu.Lock()
+	api.filters[id] = f
+	evicted := api.evictFilters(f.client)
+	api.filtersMu.Unlock()
+
+	for _, s := range evicted {
+		s.Unsubscribe()
+	}
+}
+
+// evictFilters removes the least recently polled filters of the given client
+// beyond the client limit and returns their subscriptions to be unsubscribed
+// outside the lock. Filters of unknown clients are never evicted.
+func (api *FilterAPI) evictFilters(client string) []*Subscription {
+	if api.limits.ClientFilters <= 0 || client == "" {
+		return nil
+	}
+	var ids []rpc.ID
+	for id, f := range api.filters {
+		if f.client == client {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) <= api.limits.ClientFilters {
+		return nil
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return api.filters[ids[i]].lastPoll.Before(api.filters[ids[j]].lastPoll)
+	})
+	var evicted []*Subscription
+	for _, id := range ids[:len(ids)-api.limits.ClientFilters] {
+		f := api.filters[id]
+		delete(api.filters, id)
+		api.deleteCheckpoint(id, f)
+		evicted = append(evicted, f.s)
+
+		atomic.AddUint64(&api.evicted, 1)
+		filterEvictedMeter.Mark(1)
+	}
+	return evicted
+}
+
+// overflowed removes the filter if its undelivered hashes or logs exceed the
+// backlog limit, reporting whether the subscription of the filter needs to be
+// unsubscribed outside the lock.
+func (api *FilterAPI) overflowed(id rpc.ID, f *filter) bool {
+	if api.limits.Backlog <= 0 || len(f.hashes)+len(f.logs) <= api.limits.Backlog {
+		return false
+	}
+	delete(api.filters, id)
+	api.deleteCheckpoint(id, f)
+
+	atomic.AddUint64(&api.overflows, 1)
+	filterOverflowedMeter.Mark(1)
+	return true
+}
+
+// FilterAdminAPI exposes the usage of filters and subscriptions to operators.
+type FilterAdminAPI struct {
+	api *FilterAPI
+}
+
+// NewFilterAdminAPI creates the admin API of the given filter API.
+func NewFilterAdminAPI(api *FilterAPI) *FilterAdminAPI {
+	return &FilterAdminAPI{api: api}
+}
+
+// FilterStats returns the installed filters and active subscriptions with their
+// age and activity, summed up per client, oldest first.
+func (admin *FilterAdminAPI) FilterStats() *FilterStats {
+	var (
+		api   = admin.api
+		now   = time.Now()
+		stats = &FilterStats{
+			Clients:    make(map[string]*ClientFilterUsage),
+			Expired:    hexutil.Uint64(atomic.LoadUint64(&api.expired)),
+			Evicted:    hexutil.Uint64(atomic.LoadUint64(&api.evicted)),
+			Overflowed: hexutil.Uint64(atomic.LoadUint64(&api.overflows)),
+		}
+	)
+	client := func(name string) *ClientFilterUsage {
+		if stats.Clients[name] == nil {
+			stats.Clients[name] = new(ClientFilterUsage)
+		}
+		return stats.Clients[name]
+	}
+	api.filtersMu.Lock()
+	for id, f := range api.filters {
+		backlog := len(f.hashes) + len(f.logs)
+		stats.Filters = append(stats.Filters, &FilterUsage{
+			ID:        id,
+			Kind:      "filter",
+			Type:      filterTypeName(f.typ),
+			Client:    f.client,
+			Created:   f.created,
+			Age:       hexutil.Uint64(now.Sub(f.created) / time.Second),
+			Idle:      hexutil.Uint64(now.Sub(f.lastPoll) / time.Second),
+			Delivered: hexutil.Uint64(f.polls),
+			Backlog:   backlog,
+		})
+		c := client(f.client)
+		c.Filters++
+		c.Backlog += backlog
+	}
+	api.filtersMu.Unlock()
+
+	api.usageMu.Lock()
+	for id, u := range api.subscriptions {
+		stats.Filters = append(stats.Filters, &FilterUsage{
+			ID:        id,
+			Kind:      "subscription",
+			Type:      u.typ,
+			Client:    u.client,
+			Created:   u.created,
+			Age:       hexutil.Uint64(now.Sub(u.created) / time.Second),
+			Idle:      hexutil.Uint64(now.Sub(time.Unix(0, atomic.LoadInt64(&u.last))) / time.Second),
+			Delivered: hexutil.Uint64(atomic.LoadUint64(&u.notified)),
+		})
+		client(u.client).Subscriptions++
+	}
+	api.usageMu.Unlock()
+
+	sort.Slice(stats.Filters, func(i, j int) bool {
+		return stats.Filters[i].Created.Before(stats.Filters[j].Created)
+	})
+	return stats
+}
+
+// DropFilters uninstalls all filters of the given client, returning how many
+// were uninstalled.
+func (admin *FilterAdminAPI) DropFilters(client string) int {
+	api := admin.api
+
+	var dropped []*Subscription
+	api.filtersMu.Lock()
+	for id, f := range api.filters {
+		if f.client == client {
+			delete(api.filters, id)
+			api.deleteCheckpoint(id, f)
+			dropped = append(dropped, f.s)
+		}
+	}
+	api.filtersMu.Unlock()
+
+	for _, s := range dropped {
+		s.Unsubscribe()
+	}
+	return len(dropped)
+}
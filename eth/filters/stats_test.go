@@ -0,0 +1,117 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package filters
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestFilterClientLimit(t *testing.T) {
+	t.Parallel()
+
+	var (
+		backend = &testBackend{db: rawdb.NewMemoryDatabase()}
+		api     = NewFilterAPI(backend, false, deadline)
+		admin   = NewFilterAdminAPI(api)
+		now     = time.Now()
+	)
+	api.SetLimits(FilterLimits{ClientFilters: 2})
+
+	// Install three filters of a client, polled in reverse order of creation
+	var ids []rpc.ID
+	for i := 0; i < 3; i++ {
+		sub := api.events.SubscribeNewHeads(make(chan *types.Header))
+		f := newInstalledFilter(context.Background(), BlocksSubscription, sub, deadline)
+		f.client, f.lastPoll = "10.0.0.1", now.Add(-time.Duration(i)*time.Second)
+		api.installFilter(sub.ID, f)
+		ids = append(ids, sub.ID)
+	}
+	// Filters of unknown clients are not subject to the limit
+	api.NewBlockFilter(context.Background())
+
+	if _, err := api.GetFilterChanges(ids[2]); err == nil {
+		t.Fatal("least recently polled filter not evicted")
+	}
+	for _, id := range ids[:2] {
+		if _, err := api.GetFilterChanges(id); err != nil {
+			t.Fatalf("filter %s evicted: %v", id, err)
+		}
+	}
+	stats := admin.FilterStats()
+	if len(stats.Filters) != 3 || stats.Evicted != 1 {
+		t.Fatalf("stats mismatch: have %d filters, %d evicted, want 3 filters, 1 evicted", len(stats.Filters), stats.Evicted)
+	}
+	if c := stats.Clients["10.0.0.1"]; c == nil || c.Filters != 2 {
+		t.Fatalf("client stats mismatch: have %+v", c)
+	}
+	for _, f := range stats.Filters {
+		if f.Kind != "filter" || f.Type != "blocks" {
+			t.Errorf("filter %s mismatch: have kind %s type %s", f.ID, f.Kind, f.Type)
+		}
+		if f.Client == "10.0.0.1" && f.Delivered != 1 {
+			t.Errorf("filter %s polls mismatch: have %d, want 1", f.ID, f.Delivered)
+		}
+	}
+	if dropped := admin.DropFilters("10.0.0.1"); dropped != 2 {
+		t.Fatalf("dropped filters mismatch: have %d, want 2", dropped)
+	}
+	if stats = admin.FilterStats(); len(stats.Filters) != 1 {
+		t.Fatalf("filters left mismatch: have %d, want 1", len(stats.Filters))
+	}
+}
+
+func TestFilterBacklogLimit(t *testing.T) {
+	t.Parallel()
+
+	var (
+		db      = rawdb.NewMemoryDatabase()
+		backend = &testBackend{db: db}
+		api     = NewFilterAPI(backend, false, deadline)
+		genesis = (&core.Genesis{BaseFee: big.NewInt(params.InitialBaseFee)}).MustCommit(db)
+		chain   = func() []*types.Block {
+			blocks, _ := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, 4, func(i int, gen *core.BlockGen) {})
+			return blocks
+		}()
+	)
+	api.SetLimits(FilterLimits{Backlog: 3})
+
+	id := api.NewBlockFilter(context.Background())
+	time.Sleep(100 * time.Millisecond)
+	for _, block := range chain {
+		backend.chainFeed.Send(core.ChainEvent{Hash: block.Hash(), Block: block})
+	}
+	// The filter is never polled and is dropped once its backlog overflows
+	for timeout := time.Now().Add(time.Second); time.Now().Before(timeout); time.Sleep(10 * time.Millisecond) {
+		if stats := NewFilterAdminAPI(api).FilterStats(); stats.Overflowed == 1 && len(stats.Filters) == 0 {
+			if _, err := api.GetFilterChanges(id); err == nil {
+				t.Fatal("overflowed filter still installed")
+			}
+			return
+		}
+	}
+	t.Fatal("filter backlog not limited")
+}
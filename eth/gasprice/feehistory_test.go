@@ -22,6 +22,10 @@ import (
 	"math/big"
 	"testing"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
@@ -56,7 +60,7 @@ func TestFeeHistory(t *testing.T) {
 			MaxHeaderHistory: c.maxHeader,
 			MaxBlockHistory:  c.maxBlock,
 		}
-		backend := newTestBackend(t, big.NewInt(16), c.pending)
+		backend := newTestBackend(t, big.NewInt(16), c.pending, false)
 		oracle := NewOracle(backend, config)
 
 		first, reward, baseFee, ratio, err := oracle.FeeHistory(context.Background(), c.count, c.last, c.percent)
@@ -87,3 +91,61 @@ func TestFeeHistory(t *testing.T) {
 		}
 	}
 }
+
+func TestL1FeeHistory(t *testing.T) {
+	oracle := NewOracle(newTestBackend(t, big.NewInt(0), true, true), Config{MaxHeaderHistory: 1000, MaxBlockHistory: 1000})
+
+	first, _, _, gasUsed, err := oracle.FeeHistory(context.Background(), 4, rpc.PendingBlockNumber, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := oracle.L1FeeHistory(context.Background(), first, len(gasUsed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if history == nil {
+		t.Fatal("missing L1 fee history")
+	}
+	if len(history.L1BaseFee) != 4 {
+		t.Fatalf("L1 base fee count mismatch: have %d, want 4", len(history.L1BaseFee))
+	}
+	for i, fee := range history.L1BaseFee {
+		if fee == nil || fee.Cmp(big.NewInt(params.GWei)) != 0 {
+			t.Errorf("L1 base fee %d mismatch: have %v, want %d", i, fee, int64(params.GWei))
+		}
+	}
+	if history.Overhead.Int64() != 2100 || history.Scalar.Int64() != 1000000 || history.Decimals != 6 {
+		t.Errorf("fee params mismatch: have %v %v %d", history.Overhead, history.Scalar, history.Decimals)
+	}
+	// The state of older blocks may be missing, but not the one of the newest
+	backend := &stateMissingBackend{newTestBackend(t, big.NewInt(0), true, true), first.Uint64()}
+	oracle = NewOracle(backend, Config{MaxHeaderHistory: 1000, MaxBlockHistory: 1000})
+	if history, err = oracle.L1FeeHistory(context.Background(), first, 2); err != nil {
+		t.Fatalf("failed to retrieve L1 fee history with pruned state: %v", err)
+	}
+	if history.L1BaseFee[0] != nil || history.L1BaseFee[1] == nil {
+		t.Errorf("L1 base fees mismatch with pruned state: %v", history.L1BaseFee)
+	}
+	if history, err = oracle.L1FeeHistory(context.Background(), new(big.Int).Sub(first, common.Big1), 2); err == nil {
+		t.Errorf("L1 fee history returned without the newest state: %v", history)
+	}
+	// Fee history of non-rollup chains has no L1 data fee
+	oracle = NewOracle(newTestBackend(t, big.NewInt(0), false, false), Config{MaxHeaderHistory: 1000})
+	if history, err = oracle.L1FeeHistory(context.Background(), big.NewInt(30), 2); history != nil || err != nil {
+		t.Errorf("unexpected L1 fee history: %v, %v", history, err)
+	}
+}
+
+// stateMissingBackend is a test backend whose state is not available up to the
+// given block.
+type stateMissingBackend struct {
+	*testBackend
+	missing uint64
+}
+
+func (b *stateMissingBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	if number >= 0 && uint64(number) <= b.missing {
+		return nil, nil, errors.New("missing trie node")
+	}
+	return b.testBackend.StateAndHeaderByNumber(ctx, number)
+}
@@ -67,8 +67,8 @@ type Oracle struct {
 	lastPrice   *big.Int
 	maxPrice    *big.Int
 	ignorePrice *big.Int
-	cacheLock   sync.RWMutex
-	fetchLock   sync.Mutex
+	cacheLock   sync.RWMutex // Protects lastHead, lastPrice and the history limits
+	fetchLock   sync.Mutex   // Protects the sampling parameters
 
 	checkBlocks, percentile           int
 	maxHeaderHistory, maxBlockHistory int
@@ -78,6 +78,42 @@ type Oracle struct {
 // NewOracle returns a new gasprice oracle which can recommend suitable
 // gasprice for newly created transaction.
 func NewOracle(backend OracleBackend, params Config) *Oracle {
+	cache, _ := lru.New(2048)
+	headEvent := make(chan core.ChainHeadEvent, 1)
+	backend.SubscribeChainHeadEvent(headEvent)
+	go func() {
+		var lastHead common.Hash
+		for ev := range headEvent {
+			if ev.Block.ParentHash() != lastHead {
+				cache.Purge()
+			}
+			lastHead = ev.Block.Hash()
+		}
+	}()
+
+	oracle := &Oracle{
+		backend:      backend,
+		lastPrice:    params.Default,
+		historyCache: cache,
+	}
+	oracle.setParams(params)
+	return oracle
+}
+
+// SetConfig replaces the sampling parameters of the oracle. The default price
+// is only used on creation and is ignored.
+func (oracle *Oracle) SetConfig(params Config) {
+	oracle.fetchLock.Lock()
+	defer oracle.fetchLock.Unlock()
+	oracle.cacheLock.Lock()
+	defer oracle.cacheLock.Unlock()
+
+	oracle.setParams(params)
+	oracle.lastHead = common.Hash{} // recalculate with the new parameters
+}
+
+// setParams sanitizes and applies the sampling parameters.
+func (oracle *Oracle) setParams(params Config) {
 	blocks := params.Blocks
 	if blocks < 1 {
 		blocks = 1
@@ -113,31 +149,9 @@ func NewOracle(backend OracleBackend, params Config) *Oracle {
 		maxBlockHistory = 1
 		log.Warn("Sanitizing invalid gasprice oracle max block history", "provided", params.MaxBlockHistory, "updated", maxBlockHistory)
 	}
-
-	cache, _ := lru.New(2048)
-	headEvent := make(chan core.ChainHeadEvent, 1)
-	backend.SubscribeChainHeadEvent(headEvent)
-	go func() {
-		var lastHead common.Hash
-		for ev := range headEvent {
-			if ev.Block.ParentHash() != lastHead {
-				cache.Purge()
-			}
-			lastHead = ev.Block.Hash()
-		}
-	}()
-
-	return &Oracle{
-		backend:          backend,
-		lastPrice:        params.Default,
-		maxPrice:         maxPrice,
-		ignorePrice:      ignorePrice,
-		checkBlocks:      blocks,
-		percentile:       percent,
-		maxHeaderHistory: maxHeaderHistory,
-		maxBlockHistory:  maxBlockHistory,
-		historyCache:     cache,
-	}
+	oracle.maxPrice, oracle.ignorePrice = maxPrice, ignorePrice
+	oracle.checkBlocks, oracle.percentile = blocks, percent
+	oracle.maxHeaderHistory, oracle.maxBlockHistory = maxHeaderHistory, maxBlockHistory
 }
 
 // SuggestTipCap returns a tip cap so that newly created transaction can have a
@@ -146,6 +160,9 @@ func NewOracle(backend OracleBackend, params Config) *Oracle {
 // Note, for legacy transactions and the legacy eth_gasPrice RPC call, it will be
 // necessary to add the basefee to the returned number to fall back to the legacy
 // behavior.
+//
+// On rollup chains, the lowest recently included tip is suggested unless the
+// sampled blocks were above their gas target.
 func (oracle *Oracle) SuggestTipCap(ctx context.Context) (*big.Int, error) {
 	head, _ := oracle.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
 	headHash := head.Hash()
@@ -180,6 +197,7 @@ func (oracle *Oracle) SuggestTipCap(ctx context.Context) (*big.Int, error) {
 		exp++
 		number--
 	}
+	var congested bool
 	for exp > 0 {
 		res := <-result
 		if res.err != nil {
@@ -187,6 +205,7 @@ func (oracle *Oracle) SuggestTipCap(ctx context.Context) (*big.Int, error) {
 			return new(big.Int).Set(lastPrice), res.err
 		}
 		exp--
+		congested = congested || res.congested
 		// Nothing returned. There are two special cases here:
 		// - The block is empty
 		// - All the transactions included are sent by the miner itself.
@@ -209,6 +228,13 @@ func (oracle *Oracle) SuggestTipCap(ctx context.Context) (*big.Int, error) {
 	if len(results) > 0 {
 		sort.Sort(bigIntArray(results))
 		price = results[(len(results)-1)*oracle.percentile/100]
+
+		// The sequencer includes every transaction paying the base fee as long
+		// as blocks stay below the gas target, so the lowest tip included is
+		// all that is needed. Tips only compete once blocks fill up.
+		if oracle.backend.ChainConfig().Optimism != nil && !congested {
+			price = results[0]
+		}
 	}
 	if price.Cmp(oracle.maxPrice) > 0 {
 		price = new(big.Int).Set(oracle.maxPrice)
@@ -222,8 +248,9 @@ func (oracle *Oracle) SuggestTipCap(ctx context.Context) (*big.Int, error) {
 }
 
 type results struct {
-	values []*big.Int
-	err    error
+	values    []*big.Int
+	congested bool // whether the block used more than its gas target
+	err       error
 }
 
 type txSorter struct {
@@ -258,7 +285,7 @@ func (oracle *Oracle) getBlockValues(ctx context.Context, signer types.Signer, b
 	block, err := oracle.backend.BlockByNumber(ctx, rpc.BlockNumber(blockNum))
 	if block == nil {
 		select {
-		case result <- results{nil, err}:
+		case result <- results{nil, false, err}:
 		case <-quit:
 		}
 		return
@@ -271,6 +298,10 @@ func (oracle *Oracle) getBlockValues(ctx context.Context, signer types.Signer, b
 
 	var prices []*big.Int
 	for _, tx := range sorter.txs {
+		// Deposits are forced in by the rollup node and pay no tip
+		if tx.Type() == types.DepositTxType {
+			continue
+		}
 		tip, _ := tx.EffectiveGasTip(block.BaseFee())
 		if ignoreUnder != nil && tip.Cmp(ignoreUnder) == -1 {
 			continue
@@ -283,8 +314,9 @@ func (oracle *Oracle) getBlockValues(ctx context.Context, signer types.Signer, b
 			}
 		}
 	}
+	congested := block.GasUsed() > block.GasLimit()/params.ElasticityMultiplier
 	select {
-	case result <- results{prices, nil}:
+	case result <- results{prices, congested, nil}:
 	case <-quit:
 	}
 }
@@ -0,0 +1,116 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package gasprice
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// stateBackend is implemented by oracle backends able to serve the state of
+// recent blocks, which holds the parameters of the rollup L1 data fee.
+type stateBackend interface {
+	StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error)
+}
+
+// L1FeeHistory holds the parameters of the rollup L1 data fee over a range of
+// blocks. The fee of a transaction is
+//
+//	(rollupDataGas + overhead) * l1BaseFee * scalar / 10**decimals
+type L1FeeHistory struct {
+	L1BaseFee []*big.Int // L1 base fee at each block, nil where the state is unavailable
+	Overhead  *big.Int   // Overhead at the newest block
+	Scalar    *big.Int   // Scalar at the newest block
+	Decimals  uint64     // Decimals of the scalar at the newest block
+}
+
+// l1FeeParams are the L1 data fee parameters set in a single block.
+type l1FeeParams struct {
+	l1BaseFee, overhead, scalar *big.Int
+	decimals                    uint64
+}
+
+// L1FeeHistory returns the L1 data fee parameters of the given range of blocks,
+// as fee history is returned for it. Nil is returned if the chain is not a
+// rollup, and an error if the state of the newest block is not available.
+func (oracle *Oracle) L1FeeHistory(ctx context.Context, oldest *big.Int, blocks int) (*L1FeeHistory, error) {
+	if oracle.backend.ChainConfig().Optimism == nil || oldest == nil || blocks < 1 {
+		return nil, nil
+	}
+	backend, ok := oracle.backend.(stateBackend)
+	if !ok {
+		return nil, nil
+	}
+	head, err := oracle.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil || head == nil {
+		return nil, err
+	}
+	var (
+		history = &L1FeeHistory{L1BaseFee: make([]*big.Int, blocks)}
+		newest  *l1FeeParams
+	)
+	for i := 0; i < blocks; i++ {
+		number := oldest.Uint64() + uint64(i)
+		if newest, err = oracle.l1FeeParams(ctx, backend, number, number > head.Number.Uint64()); err != nil {
+			// The state of older blocks may be pruned, only the newest one is required
+			if i == blocks-1 {
+				return nil, err
+			}
+			continue
+		}
+		history.L1BaseFee[i] = newest.l1BaseFee
+	}
+	history.Overhead, history.Scalar, history.Decimals = newest.overhead, newest.scalar, newest.decimals
+	return history, nil
+}
+
+// l1FeeParams reads the L1 data fee parameters from the state of a block.
+func (oracle *Oracle) l1FeeParams(ctx context.Context, backend stateBackend, number uint64, pending bool) (*l1FeeParams, error) {
+	cacheKey := struct{ l1Fee uint64 }{number}
+	if !pending {
+		if p, ok := oracle.historyCache.Get(cacheKey); ok {
+			return p.(*l1FeeParams), nil
+		}
+	}
+	blockNumber := rpc.BlockNumber(number)
+	if pending {
+		blockNumber = rpc.PendingBlockNumber
+	}
+	statedb, _, err := backend.StateAndHeaderByNumber(ctx, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if statedb == nil {
+		return nil, fmt.Errorf("state of block %d not available", number)
+	}
+	params := &l1FeeParams{
+		l1BaseFee: statedb.GetState(core.L1BlockAddr, core.L1BaseFeeSlot).Big(),
+		overhead:  statedb.GetState(core.OVM_GasPriceOracleAddr, core.OverheadSlot).Big(),
+		scalar:    statedb.GetState(core.OVM_GasPriceOracleAddr, core.ScalarSlot).Big(),
+		decimals:  statedb.GetState(core.OVM_GasPriceOracleAddr, core.DecimalsSlot).Big().Uint64(),
+	}
+	if !pending {
+		oracle.historyCache.Add(cacheKey, params)
+	}
+	return params, nil
+}
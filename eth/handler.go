@@ -29,6 +29,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/beacon"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/fetcher"
@@ -37,6 +38,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params"
 )
@@ -49,6 +51,8 @@ const (
 
 var (
 	syncChallengeTimeout = 15 * time.Second // Time allowance for a node to reply to the sync progress challenge
+
+	txBroadcastThrottledMeter = metrics.NewRegisteredMeter("eth/txs/broadcast/throttled", nil)
 )
 
 // txPool defines the methods needed from a transaction pool implementation to
@@ -87,6 +91,7 @@ type handlerConfig struct {
 	EventMux       *event.TypeMux            // Legacy event mux, deprecate for `feed`
 	Checkpoint     *params.TrustedCheckpoint // Hard coded checkpoint for sync challenges
 	RequiredBlocks map[uint64]common.Hash    // Hard coded map of required block hashes for sync challenges
+	TxBudget       uint64                    // Per-peer bytes per second of direct transaction broadcasts (0 = unlimited)
 }
 
 type handler struct {
@@ -103,6 +108,7 @@ type handler struct {
 	txpool   txPool
 	chain    *core.BlockChain
 	maxPeers int
+	txBudget uint64 // Per-peer bytes per second of direct transaction broadcasts (0 = unlimited)
 
 	downloader   *downloader.Downloader
 	blockFetcher *fetcher.BlockFetcher
@@ -141,6 +147,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		peers:          newPeerSet(),
 		merger:         config.Merger,
 		requiredBlocks: config.RequiredBlocks,
+		txBudget:       config.TxBudget,
 		quitSync:       make(chan struct{}),
 	}
 	if config.Sync == downloader.FullSync {
@@ -151,11 +158,18 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		//   below the sync point.
 		// * the last snap sync is not finished while user specifies a full sync this
 		//   time. But we don't have any recent state for full sync.
-		// In these cases however it's safe to reenable snap sync.
+		// In these cases however it's safe to reenable snap sync, unless the state
+		// is stored with the path scheme, which snap sync doesn't support.
 		fullBlock, fastBlock := h.chain.CurrentBlock(), h.chain.CurrentFastBlock()
-		if fullBlock.NumberU64() == 0 && fastBlock.NumberU64() > 0 {
+		snappable := h.chain.StateCache().TrieDB().Scheme() != rawdb.PathScheme
+		if snappable && fullBlock.NumberU64() == 0 && fastBlock.NumberU64() > 0 {
 			h.snapSync = uint32(1)
 			log.Warn("Switch sync mode from full sync to snap sync")
+		} else if snappable && fullBlock.NumberU64() == 0 && !h.chain.HasState(fullBlock.Root()) {
+			// The genesis was committed without its state (e.g. the migrated
+			// state of a rollup chain), so it can't be full synced from.
+			h.snapSync = uint32(1)
+			log.Warn("Switch sync mode from full sync to snap sync", "reason", "missing genesis state")
 		}
 	} else {
 		if h.chain.CurrentBlock().NumberU64() > 0 {
@@ -345,7 +359,7 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 	peer.Log().Debug("Ethereum peer connected", "name", peer.Name())
 
 	// Register the peer locally
-	if err := h.peers.registerPeer(peer, snap); err != nil {
+	if err := h.peers.registerPeer(peer, snap, h.txBudget); err != nil {
 		peer.Log().Error("Ethereum peer registration failed", "err", err)
 		return err
 	}
@@ -606,10 +620,13 @@ func (h *handler) BroadcastBlock(block *types.Block, propagate bool) {
 // already have the given transaction.
 func (h *handler) BroadcastTransactions(txs types.Transactions) {
 	var (
-		annoCount   int // Count of announcements made
-		annoPeers   int
-		directCount int // Count of the txs sent directly to peers
-		directPeers int // Count of the peers that were sent transactions directly
+		annoCount     int // Count of announcements made
+		annoPeers     int
+		directCount   int // Count of the txs sent directly to peers
+		directPeers   int // Count of the peers that were sent transactions directly
+		throttleCount int // Count of the txs announced instead for exceeding the peer budget
+
+		now = time.Now()
 
 		txset = make(map[*ethPeer][]common.Hash) // Set peer->hash to transfer directly
 		annos = make(map[*ethPeer][]common.Hash) // Set peer->hash to announce
@@ -621,6 +638,12 @@ func (h *handler) BroadcastTransactions(txs types.Transactions) {
 		// Send the tx unconditionally to a subset of our peers
 		numDirect := int(math.Sqrt(float64(len(peers))))
 		for _, peer := range peers[:numDirect] {
+			// Announce the tx instead if the peer used up its bandwidth budget
+			if peer.txBudget != nil && !peer.txBudget.AllowN(now, int(tx.Size())) {
+				annos[peer] = append(annos[peer], tx.Hash())
+				throttleCount++
+				continue
+			}
 			txset[peer] = append(txset[peer], tx.Hash())
 		}
 		// For the remaining peers, send announcement only
@@ -638,9 +661,11 @@ func (h *handler) BroadcastTransactions(txs types.Transactions) {
 		annoCount += len(hashes)
 		peer.AsyncSendPooledTransactionHashes(hashes)
 	}
+	txBroadcastThrottledMeter.Mark(int64(throttleCount))
+
 	log.Debug("Transaction broadcast", "txs", len(txs),
 		"announce packs", annoPeers, "announced hashes", annoCount,
-		"tx packs", directPeers, "broadcast txs", directCount)
+		"tx packs", directPeers, "broadcast txs", directCount, "throttled txs", throttleCount)
 }
 
 // minedBroadcastLoop sends mined blocks to connected peers.
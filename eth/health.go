@@ -0,0 +1,141 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// HealthConfig contains the thresholds of the readiness endpoint. Zero values
+// disable the respective check.
+type HealthConfig struct {
+	MaxBlockAge      time.Duration // Maximum age of the head block
+	MinPeers         int           // Minimum number of connected peers
+	MaxEngineSilence time.Duration // Maximum time since the last engine API call
+}
+
+// HealthReport is the body served by the health endpoints.
+type HealthReport struct {
+	Healthy   bool        `json:"healthy"`
+	Syncing   bool        `json:"syncing"`
+	Peers     int         `json:"peers"`
+	Head      HealthHead  `json:"head"`
+	Engine    *HealthTime `json:"engine,omitempty"` // Last engine API call, nil if none
+	Failures  []string    `json:"failures,omitempty"`
+	CheckedAt time.Time   `json:"checkedAt"`
+}
+
+// HealthHead describes the head block in a health report.
+type HealthHead struct {
+	Number uint64        `json:"number"`
+	Hash   common.Hash   `json:"hash"`
+	Age    time.Duration `json:"age"` // in nanoseconds
+}
+
+// HealthTime is a point in time with its age.
+type HealthTime struct {
+	Time time.Time     `json:"time"`
+	Age  time.Duration `json:"age"` // in nanoseconds
+}
+
+// EngineHeartbeat records that the consensus client just called the engine API.
+func (s *Ethereum) EngineHeartbeat() {
+	atomic.StoreInt64(&s.engineHeartbeat, time.Now().UnixNano())
+}
+
+// LastEngineHeartbeat returns the time of the last engine API call, or the zero
+// time if there was none.
+func (s *Ethereum) LastEngineHeartbeat() time.Time {
+	if t := atomic.LoadInt64(&s.engineHeartbeat); t != 0 {
+		return time.Unix(0, t)
+	}
+	return time.Time{}
+}
+
+// healthReport checks the state of the node. Liveness only depends on the
+// node responding, readiness also requires all configured thresholds to hold.
+func (s *Ethereum) healthReport(config HealthConfig, readiness bool) *HealthReport {
+	now := time.Now()
+	head := s.blockchain.CurrentBlock()
+	report := &HealthReport{
+		Syncing: s.handler.downloader.Synchronising(),
+		Head: HealthHead{
+			Number: head.NumberU64(),
+			Hash:   head.Hash(),
+			Age:    now.Sub(time.Unix(int64(head.Time()), 0)),
+		},
+		CheckedAt: now,
+	}
+	if s.p2pServer != nil {
+		report.Peers = s.p2pServer.PeerCount()
+	}
+	if last := s.LastEngineHeartbeat(); !last.IsZero() {
+		report.Engine = &HealthTime{Time: last, Age: now.Sub(last)}
+	}
+	if readiness {
+		report.checkReadiness(config)
+	}
+	report.Healthy = len(report.Failures) == 0
+	return report
+}
+
+// checkReadiness records the failures of the report against the thresholds
+// of the readiness endpoint.
+func (r *HealthReport) checkReadiness(config HealthConfig) {
+	if r.Syncing {
+		r.Failures = append(r.Failures, "node is syncing")
+	}
+	if r.Peers < config.MinPeers {
+		r.Failures = append(r.Failures, fmt.Sprintf("%d peers, want at least %d", r.Peers, config.MinPeers))
+	}
+	if config.MaxBlockAge > 0 && r.Head.Age > config.MaxBlockAge {
+		r.Failures = append(r.Failures, fmt.Sprintf("head block is %v old", r.Head.Age.Round(time.Second)))
+	}
+	if config.MaxEngineSilence > 0 {
+		if r.Engine == nil {
+			r.Failures = append(r.Failures, "no engine API calls received")
+		} else if r.Engine.Age > config.MaxEngineSilence {
+			r.Failures = append(r.Failures, fmt.Sprintf("no engine API calls for %v", r.Engine.Age.Round(time.Second)))
+		}
+	}
+}
+
+// healthHandler serves health reports, answering with 503 if unhealthy.
+func (s *Ethereum) healthHandler(config HealthConfig, readiness bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := s.healthReport(config, readiness)
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// RegisterHealthHandlers adds the /healthz liveness and /readyz readiness
+// endpoints to the HTTP server of the stack.
+func RegisterHealthHandlers(stack *node.Node, backend *Ethereum, config HealthConfig) {
+	stack.RegisterHandler("Health", "/healthz", backend.healthHandler(config, false))
+	stack.RegisterHandler("Readiness", "/readyz", backend.healthHandler(config, true))
+}
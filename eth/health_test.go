@@ -0,0 +1,146 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Tests that the readiness thresholds are checked independently, each failing
+// the report with its own reason.
+func TestHealthReadiness(t *testing.T) {
+	recent := &HealthTime{Age: time.Second}
+	silent := &HealthTime{Age: time.Hour}
+
+	tests := []struct {
+		name     string
+		config   HealthConfig
+		report   HealthReport
+		failures []string
+	}{
+		{
+			name:   "no thresholds",
+			report: HealthReport{Head: HealthHead{Age: time.Hour}},
+		},
+		{
+			name:     "syncing",
+			report:   HealthReport{Syncing: true},
+			failures: []string{"node is syncing"},
+		},
+		{
+			name:   "enough peers",
+			config: HealthConfig{MinPeers: 2},
+			report: HealthReport{Peers: 2},
+		},
+		{
+			name:     "too few peers",
+			config:   HealthConfig{MinPeers: 2},
+			report:   HealthReport{Peers: 1},
+			failures: []string{"1 peers, want at least 2"},
+		},
+		{
+			name:   "recent head",
+			config: HealthConfig{MaxBlockAge: time.Minute},
+			report: HealthReport{Head: HealthHead{Age: time.Minute}},
+		},
+		{
+			name:     "stale head",
+			config:   HealthConfig{MaxBlockAge: time.Minute},
+			report:   HealthReport{Head: HealthHead{Age: 90 * time.Second}},
+			failures: []string{"head block is 1m30s old"},
+		},
+		{
+			name:   "recent engine call",
+			config: HealthConfig{MaxEngineSilence: time.Minute},
+			report: HealthReport{Engine: recent},
+		},
+		{
+			name:     "no engine calls",
+			config:   HealthConfig{MaxEngineSilence: time.Minute},
+			failures: []string{"no engine API calls received"},
+		},
+		{
+			name:     "silent engine",
+			config:   HealthConfig{MaxEngineSilence: time.Minute},
+			report:   HealthReport{Engine: silent},
+			failures: []string{"no engine API calls for 1h0m0s"},
+		},
+		{
+			name:     "all failing",
+			config:   HealthConfig{MinPeers: 1, MaxBlockAge: time.Minute, MaxEngineSilence: time.Minute},
+			report:   HealthReport{Syncing: true, Head: HealthHead{Age: time.Hour}, Engine: silent},
+			failures: []string{"node is syncing", "0 peers, want at least 1", "head block is 1h0m0s old", "no engine API calls for 1h0m0s"},
+		},
+	}
+	for _, tt := range tests {
+		report := tt.report
+		report.checkReadiness(tt.config)
+		if !reflect.DeepEqual(report.Failures, tt.failures) {
+			t.Errorf("%s: failures mismatch: have %q, want %q", tt.name, report.Failures, tt.failures)
+		}
+	}
+}
+
+// Tests that the health endpoints answer with the report of the node, failing
+// the readiness but not the liveness of a node with a stale head.
+func TestHealthHandlers(t *testing.T) {
+	h := newTestHandler()
+	defer h.close()
+	backend := &Ethereum{blockchain: h.chain, handler: h.handler}
+
+	tests := []struct {
+		name      string
+		config    HealthConfig
+		readiness bool
+		heartbeat bool
+		status    int
+	}{
+		{"liveness", HealthConfig{MaxBlockAge: time.Minute, MaxEngineSilence: time.Minute}, false, false, http.StatusOK},
+		{"ready", HealthConfig{}, true, false, http.StatusOK},
+		{"stale head", HealthConfig{MaxBlockAge: time.Minute}, true, false, http.StatusServiceUnavailable},
+		{"no peers", HealthConfig{MinPeers: 1}, true, false, http.StatusServiceUnavailable},
+		{"no engine calls", HealthConfig{MaxEngineSilence: time.Minute}, true, false, http.StatusServiceUnavailable},
+		{"engine calls", HealthConfig{MaxEngineSilence: time.Minute}, true, true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		if tt.heartbeat {
+			backend.EngineHeartbeat()
+		}
+		rec := httptest.NewRecorder()
+		backend.healthHandler(tt.config, tt.readiness).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+		if rec.Code != tt.status {
+			t.Errorf("%s: status mismatch: have %d, want %d", tt.name, rec.Code, tt.status)
+		}
+		var report HealthReport
+		if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+			t.Errorf("%s: failed to decode report: %v", tt.name, err)
+			continue
+		}
+		if report.Healthy != (tt.status == http.StatusOK) {
+			t.Errorf("%s: health mismatch: have %v, status %d", tt.name, report.Healthy, rec.Code)
+		}
+		if report.Head.Hash != h.chain.CurrentBlock().Hash() {
+			t.Errorf("%s: head mismatch: have %x, want %x", tt.name, report.Head.Hash, h.chain.CurrentBlock().Hash())
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+)
+
+// errPromiseNotPending is returned if a submitted transaction is not executable,
+// e.g. queued behind a nonce gap, and can't be promised for inclusion.
+var errPromiseNotPending = errors.New("transaction not pending, no inclusion promise issued")
+
+// InclusionPromise is a soft-confirmation issued by the sequencer when accepting
+// a transaction: a signed commitment to include the transaction within a block
+// in the range [FromBlock, ToBlock].
+type InclusionPromise struct {
+	TxHash    common.Hash    `json:"txHash"`
+	FromBlock hexutil.Uint64 `json:"fromBlock"`
+	ToBlock   hexutil.Uint64 `json:"toBlock"`
+	Sequencer common.Address `json:"sequencer"`
+	Signature hexutil.Bytes  `json:"signature"`
+}
+
+// SigHash returns the digest signed by the sequencer. It commits to the chain
+// id so promises cannot be replayed across networks.
+func (p *InclusionPromise) SigHash(chainID *big.Int) common.Hash {
+	var window [16]byte
+	binary.BigEndian.PutUint64(window[:8], uint64(p.FromBlock))
+	binary.BigEndian.PutUint64(window[8:], uint64(p.ToBlock))
+	return crypto.Keccak256Hash(
+		common.BigToHash(chainID).Bytes(),
+		p.TxHash.Bytes(),
+		window[:],
+	)
+}
+
+// Verify checks that the promise was signed by its sequencer.
+func (p *InclusionPromise) Verify(chainID *big.Int) error {
+	if len(p.Signature) != crypto.SignatureLength {
+		return errors.New("invalid promise signature length")
+	}
+	pubkey, err := crypto.SigToPub(p.SigHash(chainID).Bytes(), p.Signature)
+	if err != nil {
+		return err
+	}
+	if crypto.PubkeyToAddress(*pubkey) != p.Sequencer {
+		return errors.New("promise not signed by sequencer")
+	}
+	return nil
+}
+
+// InclusionPromiseAPI accepts transactions and returns signed inclusion promises
+// for them.
+type InclusionPromiseAPI struct {
+	e      *Ethereum
+	key    *ecdsa.PrivateKey
+	window uint64
+}
+
+// NewInclusionPromiseAPI creates a new API issuing promises signed by key for
+// inclusion within window blocks.
+func NewInclusionPromiseAPI(e *Ethereum, key *ecdsa.PrivateKey, window uint64) *InclusionPromiseAPI {
+	if window == 0 {
+		window = 1
+	}
+	return &InclusionPromiseAPI{e: e, key: key, window: window}
+}
+
+// SendRawTransactionWithPromise submits a signed transaction to the pool and
+// returns the sequencer's promise to include it within the configured block
+// window. Only transactions the pool deems executable are promised, others are
+// kept in the pool but answered with an error.
+func (api *InclusionPromiseAPI) SendRawTransactionWithPromise(ctx context.Context, input hexutil.Bytes) (*InclusionPromise, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return nil, err
+	}
+	hash, err := ethapi.SubmitTransaction(ctx, api.e.APIBackend, tx)
+	if err != nil {
+		return nil, err
+	}
+	if api.e.TxPool().Status([]common.Hash{hash})[0] != core.TxStatusPending {
+		return nil, errPromiseNotPending
+	}
+	head := api.e.BlockChain().CurrentHeader().Number.Uint64()
+	return api.sign(hash, head+1, head+api.window)
+}
+
+// sign creates the promise for the given transaction and block window.
+func (api *InclusionPromiseAPI) sign(hash common.Hash, from, to uint64) (*InclusionPromise, error) {
+	promise := &InclusionPromise{
+		TxHash:    hash,
+		FromBlock: hexutil.Uint64(from),
+		ToBlock:   hexutil.Uint64(to),
+		Sequencer: crypto.PubkeyToAddress(api.key.PublicKey),
+	}
+	sig, err := crypto.Sign(promise.SigHash(api.e.BlockChain().Config().ChainID).Bytes(), api.key)
+	if err != nil {
+		return nil, err
+	}
+	promise.Signature = sig
+	return promise, nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/ethconfig"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestInclusionPromiseVerify(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	chainID := big.NewInt(288)
+
+	promise := &InclusionPromise{
+		TxHash:    common.HexToHash("0x01"),
+		FromBlock: 11,
+		ToBlock:   20,
+		Sequencer: crypto.PubkeyToAddress(key.PublicKey),
+	}
+	sig, err := crypto.Sign(promise.SigHash(chainID).Bytes(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	promise.Signature = sig
+	if err := promise.Verify(chainID); err != nil {
+		t.Fatalf("valid promise rejected: %v", err)
+	}
+	if err := promise.Verify(big.NewInt(1)); err == nil {
+		t.Fatal("promise accepted on different chain")
+	}
+	promise.ToBlock++
+	if err := promise.Verify(chainID); err == nil {
+		t.Fatal("tampered promise accepted")
+	}
+}
+
+// Tests that promises are only issued for transactions the pool can execute,
+// and that queued ones are kept in the pool without a promise.
+func TestInclusionPromiseQueued(t *testing.T) {
+	var (
+		key, _ = crypto.GenerateKey()
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		db     = rawdb.NewMemoryDatabase()
+		gspec  = &core.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  core.GenesisAlloc{addr: {Balance: big.NewInt(params.Ether)}},
+		}
+	)
+	gspec.MustCommit(db)
+	chain, _ := core.NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+	config := core.DefaultTxPoolConfig
+	config.Journal = ""
+	pool := core.NewTxPool(config, gspec.Config, chain)
+	defer pool.Stop()
+
+	e := &Ethereum{blockchain: chain, txPool: pool, config: &ethconfig.Config{RPCTxFeeCap: 1}}
+	e.APIBackend = &EthAPIBackend{eth: e}
+	seq, _ := crypto.GenerateKey()
+	api := NewInclusionPromiseAPI(e, seq, 10)
+
+	send := func(nonce uint64) (*types.Transaction, *InclusionPromise, error) {
+		tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{0xaa}, big.NewInt(1), params.TxGas, big.NewInt(params.InitialBaseFee), nil), types.LatestSigner(gspec.Config), key)
+		input, _ := tx.MarshalBinary()
+		promise, err := api.SendRawTransactionWithPromise(context.Background(), input)
+		return tx, promise, err
+	}
+	// An executable transaction is promised for the next blocks
+	tx, promise, err := send(0)
+	if err != nil {
+		t.Fatalf("executable transaction rejected: %v", err)
+	}
+	if promise.TxHash != tx.Hash() || promise.FromBlock != 1 || promise.ToBlock != 10 {
+		t.Fatalf("promise mismatch: have %x [%d, %d], want %x [1, 10]", promise.TxHash, promise.FromBlock, promise.ToBlock, tx.Hash())
+	}
+	if err := promise.Verify(gspec.Config.ChainID); err != nil {
+		t.Fatalf("promise not verifiable: %v", err)
+	}
+	// A transaction behind a nonce gap is queued, but not promised
+	tx, promise, err = send(2)
+	if err != errPromiseNotPending {
+		t.Fatalf("queued transaction error mismatch: have %v, want %v", err, errPromiseNotPending)
+	}
+	if promise != nil {
+		t.Fatal("queued transaction promised")
+	}
+	if status := pool.Status([]common.Hash{tx.Hash()})[0]; status != core.TxStatusQueued {
+		t.Fatalf("queued transaction status mismatch: have %v, want %v", status, core.TxStatusQueued)
+	}
+}
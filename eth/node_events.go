@@ -0,0 +1,101 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/node"
+)
+
+// nodeEventReporter posts sync phase changes, head updates and reorgs of the
+// chain to the node event feed.
+type nodeEventReporter struct {
+	stack  *node.Node
+	events *Events
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newNodeEventReporter(stack *node.Node, eth *Ethereum) *nodeEventReporter {
+	return &nodeEventReporter{
+		stack:  stack,
+		events: eth.events,
+		quit:   make(chan struct{}),
+	}
+}
+
+// Start implements node.Lifecycle.
+func (r *nodeEventReporter) Start() error {
+	r.wg.Add(1)
+	go r.loop()
+	return nil
+}
+
+// Stop implements node.Lifecycle.
+func (r *nodeEventReporter) Stop() error {
+	close(r.quit)
+	r.wg.Wait()
+	return nil
+}
+
+func (r *nodeEventReporter) loop() {
+	defer r.wg.Done()
+
+	// Unbuffered channels retain the order of reorgs and the heads following them
+	heads := make(chan HeadEvent)
+	headSub := r.events.SubscribeHeads(heads, Block)
+	defer headSub.Unsubscribe()
+	reorgs := make(chan ReorgEvent)
+	reorgSub := r.events.SubscribeReorgs(reorgs, Block)
+	defer reorgSub.Unsubscribe()
+	syncs := make(chan SyncEvent)
+	syncSub := r.events.SubscribeSync(syncs, Block)
+	defer syncSub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-reorgs:
+			r.stack.PostEvent(node.ChainReorg, node.ChainReorgInfo{
+				OldHead: headInfo(ev.OldHead),
+				NewHead: headInfo(ev.NewHead),
+				Depth:   ev.Depth,
+			})
+
+		case ev := <-heads:
+			r.stack.PostEvent(node.ChainHead, headInfo(ev.Header))
+
+		case ev := <-syncs:
+			info := node.SyncPhaseInfo{Phase: string(ev.Phase)}
+			if ev.Err != nil {
+				info.Error = ev.Err.Error()
+			}
+			r.stack.PostEvent(node.SyncPhase, info)
+
+		case <-headSub.Err():
+			return
+		case <-r.quit:
+			return
+		}
+	}
+}
+
+func headInfo(header *types.Header) node.ChainHeadInfo {
+	return node.ChainHeadInfo{Number: header.Number.Uint64(), Hash: header.Hash()}
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
+	"golang.org/x/time/rate"
 )
 
 // ethPeerInfo represents a short summary of the `eth` sub-protocol metadata known
@@ -34,7 +35,8 @@ type ethPeerInfo struct {
 // ethPeer is a wrapper around eth.Peer to maintain a few extra metadata.
 type ethPeer struct {
 	*eth.Peer
-	snapExt *snapPeer // Satellite `snap` connection
+	snapExt  *snapPeer     // Satellite `snap` connection
+	txBudget *rate.Limiter // Bandwidth budget of direct transaction broadcasts (nil = unlimited)
 }
 
 // info gathers and returns some `eth` protocol metadata known about a peer.
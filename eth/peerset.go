@@ -25,6 +25,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/eth/protocols/snap"
 	"github.com/ethereum/go-ethereum/p2p"
+	"golang.org/x/time/rate"
 )
 
 var (
@@ -132,8 +133,9 @@ func (ps *peerSet) waitSnapExtension(peer *eth.Peer) (*snap.Peer, error) {
 }
 
 // registerPeer injects a new `eth` peer into the working set, or returns an error
-// if the peer is already known.
-func (ps *peerSet) registerPeer(peer *eth.Peer, ext *snap.Peer) error {
+// if the peer is already known. A non-zero budget limits the bytes per second of
+// transactions broadcast directly to the peer.
+func (ps *peerSet) registerPeer(peer *eth.Peer, ext *snap.Peer, budget uint64) error {
 	// Start tracking the new peer
 	ps.lock.Lock()
 	defer ps.lock.Unlock()
@@ -152,6 +154,9 @@ func (ps *peerSet) registerPeer(peer *eth.Peer, ext *snap.Peer) error {
 		eth.snapExt = &snapPeer{ext}
 		ps.snapPeers++
 	}
+	if budget > 0 {
+		eth.txBudget = rate.NewLimiter(rate.Limit(budget), int(budget))
+	}
 	ps.peers[id] = eth
 	return nil
 }
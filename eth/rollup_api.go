@@ -0,0 +1,211 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// RollupBlockRef identifies a block of the rollup chain.
+type RollupBlockRef struct {
+	Number    hexutil.Uint64 `json:"number"`
+	Hash      common.Hash    `json:"hash"`
+	Timestamp hexutil.Uint64 `json:"timestamp"`
+}
+
+// RollupL1Origin is the L1 block the head of the rollup chain derives from, as
+// recorded in the L1Block predeploy.
+type RollupL1Origin struct {
+	Number         hexutil.Uint64 `json:"number"`
+	Hash           common.Hash    `json:"hash"`
+	Timestamp      hexutil.Uint64 `json:"timestamp"`
+	BaseFee        *hexutil.Big   `json:"baseFee"`
+	SequenceNumber hexutil.Uint64 `json:"sequenceNumber"` // Position of the head within the L1 epoch
+}
+
+// RollupFeeParams are the parameters of the L1 data fee, as set in the gas
+// price oracle by the system config.
+type RollupFeeParams struct {
+	Overhead *hexutil.Big   `json:"overhead"`
+	Scalar   *hexutil.Big   `json:"scalar"`
+	Decimals hexutil.Uint64 `json:"decimals"`
+}
+
+// RollupInfo describes the rollup configuration of the chain and its progress
+// as driven by the rollup node through the engine API.
+type RollupInfo struct {
+	ChainID          *hexutil.Big   `json:"chainId"`
+	BaseFeeRecipient common.Address `json:"baseFeeRecipient"`
+	L1FeeRecipient   common.Address `json:"l1FeeRecipient"`
+	GasLimit         hexutil.Uint64 `json:"gasLimit"`
+
+	L1Origin  *RollupL1Origin  `json:"l1Origin"`
+	FeeParams *RollupFeeParams `json:"feeParams"`
+
+	Unsafe    *RollupBlockRef `json:"unsafe"`
+	Safe      *RollupBlockRef `json:"safe"`      // Nil until reported by the rollup node
+	Finalized *RollupBlockRef `json:"finalized"` // Nil until reported by the rollup node
+}
+
+// RollupGasPrices are the prices paid by a transaction included in a block. Its
+// total fee is
+//
+//	gasUsed * l2GasPrice + (rollupDataGas + overhead) * l1BaseFee * scalar / 10**decimals
+type RollupGasPrices struct {
+	L2GasPrice *hexutil.Big `json:"l2GasPrice"` // Suggested tip plus the base fee
+	L2BaseFee  *hexutil.Big `json:"l2BaseFee,omitempty"`
+	L2Tip      *hexutil.Big `json:"l2Tip"`
+
+	L1BaseFee  *hexutil.Big   `json:"l1BaseFee"`
+	L1GasPrice *hexutil.Big   `json:"l1GasPrice"` // Scaled price of a unit of rollup data gas, rounded down
+	Overhead   *hexutil.Big   `json:"overhead"`
+	Scalar     *hexutil.Big   `json:"scalar"`
+	Decimals   hexutil.Uint64 `json:"decimals"`
+}
+
+// RollupAPI exposes the rollup configuration of the chain, so that monitoring
+// and explorers need not derive it from the system contracts.
+type RollupAPI struct {
+	e *Ethereum
+}
+
+// NewRollupAPI creates a new rollup configuration API.
+func NewRollupAPI(e *Ethereum) *RollupAPI {
+	return &RollupAPI{e: e}
+}
+
+// GetInfo returns the rollup configuration at the current head along with the
+// unsafe, safe and finalized heads.
+func (api *RollupAPI) GetInfo() (*RollupInfo, error) {
+	chain := api.e.BlockChain()
+	config := chain.Config()
+	head := chain.CurrentBlock()
+	statedb, err := chain.StateAt(head.Root())
+	if err != nil {
+		return nil, err
+	}
+	info := &RollupInfo{
+		ChainID:   (*hexutil.Big)(config.ChainID),
+		GasLimit:  hexutil.Uint64(head.GasLimit()),
+		Unsafe:    rollupBlockRef(head),
+		Safe:      rollupBlockRef(chain.CurrentSafeBlock()),
+		Finalized: rollupBlockRef(chain.CurrentFinalizedBlock()),
+	}
+	if config.Optimism != nil {
+		info.BaseFeeRecipient = config.Optimism.BaseFeeRecipient
+		info.L1FeeRecipient = config.Optimism.L1FeeRecipient
+	}
+	// The number and timestamp are packed into the low-order bytes of the slot
+	packed := statedb.GetState(core.L1BlockAddr, core.L1NumberTimestampSlot)
+	info.L1Origin = &RollupL1Origin{
+		Number:         hexutil.Uint64(binary.BigEndian.Uint64(packed[24:])),
+		Timestamp:      hexutil.Uint64(binary.BigEndian.Uint64(packed[16:24])),
+		Hash:           statedb.GetState(core.L1BlockAddr, core.L1BlockHashSlot),
+		BaseFee:        (*hexutil.Big)(statedb.GetState(core.L1BlockAddr, core.L1BaseFeeSlot).Big()),
+		SequenceNumber: hexutil.Uint64(statedb.GetState(core.L1BlockAddr, core.L1SequenceNumberSlot).Big().Uint64()),
+	}
+	info.FeeParams = &RollupFeeParams{
+		Overhead: (*hexutil.Big)(statedb.GetState(core.OVM_GasPriceOracleAddr, core.OverheadSlot).Big()),
+		Scalar:   (*hexutil.Big)(statedb.GetState(core.OVM_GasPriceOracleAddr, core.ScalarSlot).Big()),
+		Decimals: hexutil.Uint64(statedb.GetState(core.OVM_GasPriceOracleAddr, core.DecimalsSlot).Big().Uint64()),
+	}
+	return info, nil
+}
+
+// GasPrices returns the L2 execution gas price together with the L1 data fee
+// parameters of the given block, so that the total cost of a transaction can be
+// estimated in one call. The block defaults to the pending one, whose base fee
+// follows from the head and whose L1 fee parameters are those of the head.
+func (api *RollupAPI) GasPrices(ctx context.Context, blockNrOrHash *rpc.BlockNumberOrHash) (*RollupGasPrices, error) {
+	tip, err := api.e.APIBackend.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		header  *types.Header
+		baseFee *big.Int
+	)
+	if blockNrOrHash == nil || isPending(*blockNrOrHash) {
+		header = api.e.BlockChain().CurrentBlock().Header()
+		config := api.e.BlockChain().Config()
+		if config.IsLondon(new(big.Int).Add(header.Number, common.Big1)) {
+			baseFee = misc.CalcBaseFee(config, header)
+		}
+	} else {
+		header, err = api.e.APIBackend.HeaderByNumberOrHash(ctx, *blockNrOrHash)
+		if err != nil {
+			return nil, err
+		}
+		if header == nil {
+			return nil, fmt.Errorf("block %v not found", blockNrOrHash)
+		}
+		baseFee = header.BaseFee
+	}
+	statedb, err := api.e.BlockChain().StateAt(header.Root)
+	if err != nil {
+		return nil, err
+	}
+	prices := &RollupGasPrices{
+		L2GasPrice: (*hexutil.Big)(new(big.Int).Set(tip)),
+		L2Tip:      (*hexutil.Big)(tip),
+	}
+	if baseFee != nil {
+		prices.L2BaseFee = (*hexutil.Big)(baseFee)
+		prices.L2GasPrice.ToInt().Add(prices.L2GasPrice.ToInt(), baseFee)
+	}
+	var (
+		l1BaseFee = statedb.GetState(core.L1BlockAddr, core.L1BaseFeeSlot).Big()
+		scalar    = statedb.GetState(core.OVM_GasPriceOracleAddr, core.ScalarSlot).Big()
+		decimals  = statedb.GetState(core.OVM_GasPriceOracleAddr, core.DecimalsSlot).Big()
+	)
+	l1GasPrice := new(big.Int).Mul(l1BaseFee, scalar)
+	l1GasPrice.Div(l1GasPrice, new(big.Int).Exp(big.NewInt(10), decimals, nil))
+
+	prices.L1BaseFee = (*hexutil.Big)(l1BaseFee)
+	prices.L1GasPrice = (*hexutil.Big)(l1GasPrice)
+	prices.Overhead = (*hexutil.Big)(statedb.GetState(core.OVM_GasPriceOracleAddr, core.OverheadSlot).Big())
+	prices.Scalar = (*hexutil.Big)(scalar)
+	prices.Decimals = hexutil.Uint64(decimals.Uint64())
+	return prices, nil
+}
+
+// isPending reports whether blockNrOrHash refers to the pending block.
+func isPending(blockNrOrHash rpc.BlockNumberOrHash) bool {
+	number, ok := blockNrOrHash.Number()
+	return ok && number == rpc.PendingBlockNumber
+}
+
+func rollupBlockRef(block *types.Block) *RollupBlockRef {
+	if block == nil {
+		return nil
+	}
+	return &RollupBlockRef{
+		Number:    hexutil.Uint64(block.NumberU64()),
+		Hash:      block.Hash(),
+		Timestamp: hexutil.Uint64(block.Time()),
+	}
+}
@@ -0,0 +1,171 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/misc"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+func TestRollupGetInfo(t *testing.T) {
+	config := *params.TestChainConfig
+	config.Optimism = &params.OptimismConfig{
+		BaseFeeRecipient: common.Address{0x01},
+		L1FeeRecipient:   common.Address{0x02},
+	}
+	l1Hash := common.HexToHash("0xabcd")
+	db := rawdb.NewMemoryDatabase()
+	genesis := (&core.Genesis{
+		Config: &config,
+		Alloc: core.GenesisAlloc{
+			core.L1BlockAddr: {
+				Balance: common.Big0,
+				Storage: map[common.Hash]common.Hash{
+					// timestamp 0x6300 | number 0x100
+					core.L1NumberTimestampSlot: common.HexToHash("0x00000000000063000000000000000100"),
+					core.L1BaseFeeSlot:         common.BigToHash(common.Big32),
+					core.L1BlockHashSlot:       l1Hash,
+					core.L1SequenceNumberSlot:  common.BigToHash(common.Big3),
+				},
+			},
+			core.OVM_GasPriceOracleAddr: {
+				Balance: common.Big0,
+				Storage: map[common.Hash]common.Hash{
+					core.OverheadSlot: common.BigToHash(common.Big257),
+					core.ScalarSlot:   common.BigToHash(common.Big2),
+					core.DecimalsSlot: common.BigToHash(common.Big1),
+				},
+			},
+		},
+	}).MustCommit(db)
+	chain, _ := core.NewBlockChain(db, nil, &config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+
+	blocks, _ := core.GenerateChain(&config, genesis, ethash.NewFaker(), db, 3, nil)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	api := NewRollupAPI(&Ethereum{blockchain: chain})
+
+	info, err := api.GetInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.BaseFeeRecipient != config.Optimism.BaseFeeRecipient || info.L1FeeRecipient != config.Optimism.L1FeeRecipient {
+		t.Errorf("fee recipients mismatch: have %x %x", info.BaseFeeRecipient, info.L1FeeRecipient)
+	}
+	if origin := info.L1Origin; origin.Number != 0x100 || origin.Timestamp != 0x6300 || origin.Hash != l1Hash || origin.BaseFee.ToInt().Int64() != 32 || origin.SequenceNumber != 3 {
+		t.Errorf("L1 origin mismatch: have %+v", origin)
+	}
+	if fees := info.FeeParams; fees.Overhead.ToInt().Int64() != 257 || fees.Scalar.ToInt().Int64() != 2 || fees.Decimals != 1 {
+		t.Errorf("fee params mismatch: have %+v", fees)
+	}
+	if info.Unsafe == nil || info.Unsafe.Hash != blocks[2].Hash() {
+		t.Errorf("unsafe head mismatch: have %+v", info.Unsafe)
+	}
+	if info.Safe != nil || info.Finalized != nil {
+		t.Errorf("unreported heads present: safe %+v, finalized %+v", info.Safe, info.Finalized)
+	}
+	chain.SetSafe(blocks[1])
+	chain.SetFinalized(blocks[0])
+
+	if info, err = api.GetInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if info.Safe == nil || info.Safe.Hash != blocks[1].Hash() {
+		t.Errorf("safe head mismatch: have %+v", info.Safe)
+	}
+	if info.Finalized == nil || info.Finalized.Hash != blocks[0].Hash() {
+		t.Errorf("finalized head mismatch: have %+v", info.Finalized)
+	}
+}
+
+func TestRollupGasPrices(t *testing.T) {
+	config := *params.TestChainConfig
+	config.Optimism = &params.OptimismConfig{}
+	db := rawdb.NewMemoryDatabase()
+	genesis := (&core.Genesis{
+		Config:  &config,
+		BaseFee: big.NewInt(params.InitialBaseFee),
+		Alloc: core.GenesisAlloc{
+			core.L1BlockAddr: {
+				Balance: common.Big0,
+				Storage: map[common.Hash]common.Hash{
+					core.L1BaseFeeSlot: common.BigToHash(big.NewInt(30)),
+				},
+			},
+			core.OVM_GasPriceOracleAddr: {
+				Balance: common.Big0,
+				Storage: map[common.Hash]common.Hash{
+					core.OverheadSlot: common.BigToHash(big.NewInt(2100)),
+					core.ScalarSlot:   common.BigToHash(big.NewInt(1500)),
+					core.DecimalsSlot: common.BigToHash(big.NewInt(3)),
+				},
+			},
+		},
+	}).MustCommit(db)
+	chain, _ := core.NewBlockChain(db, nil, &config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	defer chain.Stop()
+
+	blocks, _ := core.GenerateChain(&config, genesis, ethash.NewFaker(), db, 2, nil)
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatal(err)
+	}
+	eth := &Ethereum{blockchain: chain}
+	eth.APIBackend = &EthAPIBackend{eth: eth}
+	eth.APIBackend.gpo = gasprice.NewOracle(eth.APIBackend, gasprice.Config{Blocks: 2, Percentile: 60, Default: big.NewInt(params.GWei)})
+
+	// The pending block is priced at the base fee following the head.
+	api := NewRollupAPI(eth)
+	prices, err := api.GasPrices(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseFee := misc.CalcBaseFee(&config, blocks[1].Header())
+	if prices.L2BaseFee.ToInt().Cmp(baseFee) != 0 {
+		t.Errorf("L2 base fee mismatch: have %v, want %v", prices.L2BaseFee, baseFee)
+	}
+	if want := new(big.Int).Add(baseFee, prices.L2Tip.ToInt()); prices.L2GasPrice.ToInt().Cmp(want) != 0 {
+		t.Errorf("L2 gas price mismatch: have %v, want %v", prices.L2GasPrice, want)
+	}
+	// Other blocks are priced at their own base fee.
+	first := rpc.BlockNumberOrHashWithNumber(1)
+	if prices, err = api.GasPrices(context.Background(), &first); err != nil {
+		t.Fatal(err)
+	}
+	if baseFee := blocks[0].BaseFee(); prices.L2BaseFee.ToInt().Cmp(baseFee) != 0 {
+		t.Errorf("block 1 L2 base fee mismatch: have %v, want %v", prices.L2BaseFee, baseFee)
+	}
+	// 30 * 1500 / 10**3
+	if prices.L1BaseFee.ToInt().Int64() != 30 || prices.L1GasPrice.ToInt().Int64() != 45 {
+		t.Errorf("L1 prices mismatch: have base fee %v, gas price %v", prices.L1BaseFee, prices.L1GasPrice)
+	}
+	if prices.Overhead.ToInt().Int64() != 2100 || prices.Scalar.ToInt().Int64() != 1500 || prices.Decimals != 3 {
+		t.Errorf("L1 fee params mismatch: have %+v", prices)
+	}
+}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -52,6 +53,14 @@ func (eth *Ethereum) StateAtBlock(block *types.Block, reexec uint64, base *state
 		report   = true
 		origin   = block.NumberU64()
 	)
+	// The path scheme only holds the recent states, which the live database serves.
+	// Regenerating older ones would need a separate database committing on disk.
+	if eth.blockchain.StateCache().TrieDB().Scheme() == rawdb.PathScheme {
+		if statedb, err = eth.blockchain.StateAt(block.Root()); err != nil {
+			return nil, fmt.Errorf("historical state unavailable with the path scheme: %v", err)
+		}
+		return statedb, nil
+	}
 	// Check the live database first if we have the state fully available, use that.
 	if checkLive {
 		statedb, err = eth.blockchain.StateAt(block.Root())
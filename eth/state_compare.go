@@ -0,0 +1,272 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// compareStorageMaxSlots is the maximum number of storage slots compared per
+// account whose storage root differs.
+const compareStorageMaxSlots = 256
+
+// StateComparison is the result of a debug_compareState API call.
+type StateComparison struct {
+	Block      common.Hash   `json:"block"`
+	Root       common.Hash   `json:"root"`
+	RemoteRoot common.Hash   `json:"remoteRoot"`
+	Compared   int           `json:"compared"` // Number of accounts compared
+	Accounts   []AccountDiff `json:"accounts"`
+	Next       hexutil.Bytes `json:"next,omitempty"` // Start of the next range, nil if the state was compared to its end
+}
+
+// AccountDiff describes how an account differs between the local and the
+// remote state.
+type AccountDiff struct {
+	Address        common.Address     `json:"address"`
+	Missing        string             `json:"missing,omitempty"` // "local" or "remote" if the account only exists on one side
+	Fields         []string           `json:"fields,omitempty"`  // Differing account fields
+	Local          *state.DumpAccount `json:"local,omitempty"`
+	Remote         *state.DumpAccount `json:"remote,omitempty"`
+	Slots          []SlotDiff         `json:"slots,omitempty"`
+	SlotsTruncated bool               `json:"slotsTruncated,omitempty"` // Whether not all local slots were compared
+}
+
+// SlotDiff is a storage slot with differing values.
+type SlotDiff struct {
+	Key    common.Hash `json:"key"`
+	Local  common.Hash `json:"local"`
+	Remote common.Hash `json:"remote"`
+}
+
+// CheckComparePeer checks that the endpoint of a node to compare the state with
+// is an http(s) or ws(s) URL. Other endpoints, like IPC paths, are rejected.
+func CheckComparePeer(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid compare peer %q: %v", endpoint, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "ws", "wss":
+	default:
+		return fmt.Errorf("invalid compare peer %q: unsupported scheme %q", endpoint, u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid compare peer %q: missing host", endpoint)
+	}
+	return nil
+}
+
+// CompareState compares a range of accounts of the state at the given block with
+// the same state on another node, which must serve the debug and eth namespaces
+// at otherNodeURL. Only the endpoints configured by the operator can be compared
+// with. Like AccountRange, the range starts at the given account hash
+// and comprises at most maxResults accounts. For accounts whose storage differs,
+// the local storage slots are compared with the remote ones; slots only present
+// on the remote node are not detected. Both nodes need preimages of the compared
+// accounts and slots.
+func (api *DebugAPI) CompareState(ctx context.Context, otherNodeURL string, blockNrOrHash rpc.BlockNumberOrHash, start hexutil.Bytes, maxResults int) (*StateComparison, error) {
+	header, err := api.eth.APIBackend.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("block %v not found", blockNrOrHash)
+	}
+	statedb, err := api.eth.BlockChain().StateAt(header.Root)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.checkComparePeer(otherNodeURL); err != nil {
+		return nil, err
+	}
+	client, err := rpc.DialContext(ctx, otherNodeURL)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	return compareState(ctx, client, statedb, header.Hash(), start, maxResults)
+}
+
+// checkComparePeer checks that the given endpoint is one of the configured peers
+// to compare the state with.
+func (api *DebugAPI) checkComparePeer(endpoint string) error {
+	for _, peer := range api.eth.config.RPCComparePeers {
+		if peer == endpoint {
+			return CheckComparePeer(endpoint)
+		}
+	}
+	return errors.New("endpoint is not a configured compare peer")
+}
+
+func compareState(ctx context.Context, client *rpc.Client, statedb *state.StateDB, block common.Hash, start []byte, maxResults int) (*StateComparison, error) {
+	if maxResults > AccountRangeMaxResults || maxResults <= 0 {
+		maxResults = AccountRangeMaxResults
+	}
+	local := statedb.IteratorDump(&state.DumpConfig{
+		SkipCode:          true,
+		SkipStorage:       true,
+		OnlyWithAddresses: true,
+		Start:             start,
+		Max:               uint64(maxResults),
+	})
+	var remote state.IteratorDump
+	err := client.CallContext(ctx, &remote, "debug_accountRange", rpc.BlockNumberOrHashWithHash(block, true), hexutil.Bytes(start), maxResults, true, true, false)
+	if err != nil {
+		return nil, fmt.Errorf("remote account range failed: %v", err)
+	}
+	result := &StateComparison{
+		Block:      block,
+		Root:       common.HexToHash(local.Root),
+		RemoteRoot: common.HexToHash(remote.Root),
+		Accounts:   []AccountDiff{},
+	}
+	// Only compare accounts up to the end of the shorter range, accounts beyond
+	// are part of the next range.
+	switch {
+	case local.Next == nil:
+		result.Next = remote.Next
+	case remote.Next == nil || bytes.Compare(local.Next, remote.Next) < 0:
+		result.Next = local.Next
+	default:
+		result.Next = remote.Next
+	}
+	inRange := func(addr common.Address) bool {
+		return result.Next == nil || bytes.Compare(crypto.Keccak256(addr[:]), result.Next) < 0
+	}
+	addrs := make(map[common.Address]struct{})
+	for addr := range local.Accounts {
+		addrs[addr] = struct{}{}
+	}
+	for addr := range remote.Accounts {
+		addrs[addr] = struct{}{}
+	}
+	for addr := range addrs {
+		if !inRange(addr) {
+			continue
+		}
+		result.Compared++
+		diff, err := compareAccount(ctx, client, statedb, block, addr, local.Accounts, remote.Accounts)
+		if err != nil {
+			return nil, err
+		}
+		if diff != nil {
+			result.Accounts = append(result.Accounts, *diff)
+		}
+	}
+	sort.Slice(result.Accounts, func(i, j int) bool {
+		return bytes.Compare(crypto.Keccak256(result.Accounts[i].Address[:]), crypto.Keccak256(result.Accounts[j].Address[:])) < 0
+	})
+	return result, nil
+}
+
+// compareAccount compares an account present in at least one of the dumps,
+// returning nil if it is the same on both sides.
+func compareAccount(ctx context.Context, client *rpc.Client, statedb *state.StateDB, block common.Hash, addr common.Address, locals, remotes map[common.Address]state.DumpAccount) (*AccountDiff, error) {
+	local, haveLocal := locals[addr]
+	remote, haveRemote := remotes[addr]
+	diff := &AccountDiff{Address: addr}
+	switch {
+	case !haveLocal:
+		diff.Missing, diff.Remote = "local", &remote
+		return diff, nil
+	case !haveRemote:
+		diff.Missing, diff.Local = "remote", &local
+		return diff, nil
+	}
+	if local.Balance != remote.Balance {
+		diff.Fields = append(diff.Fields, "balance")
+	}
+	if local.Nonce != remote.Nonce {
+		diff.Fields = append(diff.Fields, "nonce")
+	}
+	if !bytes.Equal(local.CodeHash, remote.CodeHash) {
+		diff.Fields = append(diff.Fields, "codeHash")
+	}
+	if !bytes.Equal(local.Root, remote.Root) {
+		diff.Fields = append(diff.Fields, "storageRoot")
+		if err := compareStorage(ctx, client, statedb, block, diff); err != nil {
+			return nil, err
+		}
+	}
+	if len(diff.Fields) == 0 {
+		return nil, nil
+	}
+	diff.Local, diff.Remote = &local, &remote
+	return diff, nil
+}
+
+// compareStorage compares the local storage slots of an account with the
+// remote ones.
+func compareStorage(ctx context.Context, client *rpc.Client, statedb *state.StateDB, block common.Hash, diff *AccountDiff) error {
+	st := statedb.StorageTrie(diff.Address)
+	if st == nil {
+		return nil
+	}
+	storage, err := storageRangeAt(st, nil, compareStorageMaxSlots)
+	if err != nil {
+		return err
+	}
+	diff.SlotsTruncated = storage.NextKey != nil
+
+	var (
+		slots   []SlotDiff
+		results []hexutil.Bytes
+		batch   []rpc.BatchElem
+	)
+	for _, entry := range storage.Storage {
+		if entry.Key == nil {
+			diff.SlotsTruncated = true // no preimage, can't query the remote slot
+			continue
+		}
+		slots = append(slots, SlotDiff{Key: *entry.Key, Local: entry.Value})
+	}
+	sort.Slice(slots, func(i, j int) bool { return bytes.Compare(slots[i].Key[:], slots[j].Key[:]) < 0 })
+
+	results = make([]hexutil.Bytes, len(slots))
+	for i, slot := range slots {
+		batch = append(batch, rpc.BatchElem{
+			Method: "eth_getStorageAt",
+			Args:   []interface{}{diff.Address, slot.Key, rpc.BlockNumberOrHashWithHash(block, true)},
+			Result: &results[i],
+		})
+	}
+	if err := client.BatchCallContext(ctx, batch); err != nil {
+		return fmt.Errorf("remote storage query failed: %v", err)
+	}
+	for i, slot := range slots {
+		if batch[i].Error != nil {
+			return fmt.Errorf("remote storage query failed: %v", batch[i].Error)
+		}
+		if slot.Remote = common.BytesToHash(results[i]); slot.Remote != slot.Local {
+			diff.Slots = append(diff.Slots, slot)
+		}
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
@@ -79,11 +80,15 @@ type Backend interface {
 	// so this method should be called with the parent.
 	StateAtBlock(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB, checkLive, preferDisk bool) (*state.StateDB, error)
 	StateAtTransaction(ctx context.Context, block *types.Block, txIndex int, reexec uint64) (core.Message, vm.BlockContext, *state.StateDB, error)
+	// HistoricalRPC returns the proxy to the legacy node serving the blocks
+	// preceding the local chain, nil if there is none.
+	HistoricalRPC() *ethapi.HistoricalRPC
 }
 
 // API is the collection of tracing APIs exposed over the private debugging endpoint.
 type API struct {
 	backend Backend
+	quota   *traceQuota // Limits on the tracing work of RPC clients, nil if unlimited
 }
 
 // NewAPI creates a new API definition for the tracing methods of the Ethereum service.
@@ -245,6 +250,12 @@ func (api *API) traceChain(ctx context.Context, start, end *types.Block, config
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
+	// Hold a tracing slot for the whole segment, the gas is accounted per block
+	client := traceClient(ctx)
+	release, err := api.startTrace(ctx, 0)
+	if err != nil {
+		return &rpc.Subscription{}, err
+	}
 	sub := notifier.CreateSubscription()
 
 	// Prepare all the states for tracing. Note this procedure can take very
@@ -254,7 +265,7 @@ func (api *API) traceChain(ctx context.Context, start, end *types.Block, config
 		reexec = *config.Reexec
 	}
 	blocks := int(end.NumberU64() - start.NumberU64())
-	threads := runtime.NumCPU()
+	threads := api.blockThreads()
 	if threads > blocks {
 		threads = blocks
 	}
@@ -278,9 +289,10 @@ func (api *API) traceChain(ctx context.Context, start, end *types.Block, config
 				for i, tx := range task.block.Transactions() {
 					msg, _ := tx.AsMessage(signer, task.block.BaseFee())
 					txctx := &Context{
-						BlockHash: task.block.Hash(),
-						TxIndex:   i,
-						TxHash:    tx.Hash(),
+						BlockHash:   task.block.Hash(),
+						BlockNumber: task.block.Number(),
+						TxIndex:     i,
+						TxHash:      tx.Hash(),
 					}
 					res, err := api.traceTx(localctx, msg, txctx, blockCtx, task.statedb, config)
 					if err != nil {
@@ -321,6 +333,7 @@ func (api *API) traceChain(ctx context.Context, start, end *types.Block, config
 		defer func() {
 			close(tasks)
 			pend.Wait()
+			release()
 
 			switch {
 			case failed != nil:
@@ -389,6 +402,12 @@ func (api *API) traceChain(ctx context.Context, start, end *types.Block, config
 				failed = err
 				break
 			}
+			if api.quota != nil {
+				if err := api.quota.charge(client, next.GasUsed()); err != nil {
+					failed = err
+					break
+				}
+			}
 			// Send the block over to the concurrent tracers (if not in the fast-forward phase)
 			txs := next.Transactions()
 			select {
@@ -431,9 +450,22 @@ func (api *API) traceChain(ctx context.Context, start, end *types.Block, config
 	return sub, nil
 }
 
+// blockThreads returns the number of transactions of a block traced at once.
+func (api *API) blockThreads() int {
+	if api.quota != nil && api.quota.config.BlockConcurrency > 0 {
+		return api.quota.config.BlockConcurrency
+	}
+	return runtime.NumCPU()
+}
+
 // TraceBlockByNumber returns the structured logs created during the execution of
 // EVM and returns them as a JSON object.
 func (api *API) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber, config *TraceConfig) ([]*txTraceResult, error) {
+	if h := api.backend.HistoricalRPC(); h.Covers(number) {
+		var res []*txTraceResult
+		err := h.Call(ctx, &res, "debug_traceBlockByNumber", number, config)
+		return res, err
+	}
 	block, err := api.blockByNumber(ctx, number)
 	if err != nil {
 		return nil, err
@@ -444,6 +476,13 @@ func (api *API) TraceBlockByNumber(ctx context.Context, number rpc.BlockNumber,
 // TraceBlockByHash returns the structured logs created during the execution of
 // EVM and returns them as a JSON object.
 func (api *API) TraceBlockByHash(ctx context.Context, hash common.Hash, config *TraceConfig) ([]*txTraceResult, error) {
+	if h := api.backend.HistoricalRPC(); h != nil {
+		if block, _ := api.backend.BlockByHash(ctx, hash); block == nil {
+			var res []*txTraceResult
+			err := h.Call(ctx, &res, "debug_traceBlockByHash", hash, config)
+			return res, err
+		}
+	}
 	block, err := api.blockByHash(ctx, hash)
 	if err != nil {
 		return nil, err
@@ -507,6 +546,12 @@ func (api *API) IntermediateRoots(ctx context.Context, hash common.Hash, config
 	if block.NumberU64() == 0 {
 		return nil, errors.New("genesis is not traceable")
 	}
+	release, err := api.startTrace(ctx, block.GasUsed())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	parent, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(block.NumberU64()-1), block.ParentHash())
 	if err != nil {
 		return nil, err
@@ -566,6 +611,18 @@ func (api *API) StandardTraceBadBlockToFile(ctx context.Context, hash common.Has
 // executes all the transactions contained within. The return value will be one item
 // per transaction, dependent on the requestd tracer.
 func (api *API) traceBlock(ctx context.Context, block *types.Block, config *TraceConfig) ([]*txTraceResult, error) {
+	release, err := api.startTrace(ctx, block.GasUsed())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return api.traceBlockThreads(ctx, block, config, api.blockThreads())
+}
+
+// traceBlockThreads is like traceBlock, but limits the number of transactions
+// traced concurrently.
+func (api *API) traceBlockThreads(ctx context.Context, block *types.Block, config *TraceConfig, threads int) ([]*txTraceResult, error) {
 	if block.NumberU64() == 0 {
 		return nil, errors.New("genesis is not traceable")
 	}
@@ -590,7 +647,6 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 		pend = new(sync.WaitGroup)
 		jobs = make(chan *txTraceTask, len(txs))
 	)
-	threads := runtime.NumCPU()
 	if threads > len(txs) {
 		threads = len(txs)
 	}
@@ -605,9 +661,10 @@ func (api *API) traceBlock(ctx context.Context, block *types.Block, config *Trac
 			for task := range jobs {
 				msg, _ := txs[task.index].AsMessage(signer, block.BaseFee())
 				txctx := &Context{
-					BlockHash: blockHash,
-					TxIndex:   task.index,
-					TxHash:    txs[task.index].Hash(),
+					BlockHash:   blockHash,
+					BlockNumber: block.Number(),
+					TxIndex:     task.index,
+					TxHash:      txs[task.index].Hash(),
 				}
 				res, err := api.traceTx(ctx, msg, txctx, blockCtx, task.statedb, config)
 				if err != nil {
@@ -661,6 +718,12 @@ func (api *API) standardTraceBlockToFile(ctx context.Context, block *types.Block
 	if block.NumberU64() == 0 {
 		return nil, errors.New("genesis is not traceable")
 	}
+	release, err := api.startTrace(ctx, block.GasUsed())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	parent, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(block.NumberU64()-1), block.ParentHash())
 	if err != nil {
 		return nil, err
@@ -781,10 +844,16 @@ func containsTx(block *types.Block, hash common.Hash) bool {
 // TraceTransaction returns the structured logs created during the execution of EVM
 // and returns them as a JSON object.
 func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *TraceConfig) (interface{}, error) {
-	_, blockHash, blockNumber, index, err := api.backend.GetTransaction(ctx, hash)
+	tx, blockHash, blockNumber, index, err := api.backend.GetTransaction(ctx, hash)
 	if err != nil {
 		return nil, err
 	}
+	// Transactions preceding the local chain are traced by the legacy node
+	if h := api.backend.HistoricalRPC(); h != nil && tx == nil {
+		var res json.RawMessage
+		err := h.Call(ctx, &res, "debug_traceTransaction", hash, config)
+		return res, err
+	}
 	// It shouldn't happen in practice.
 	if blockNumber == 0 {
 		return nil, errors.New("genesis is not traceable")
@@ -797,14 +866,21 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 	if err != nil {
 		return nil, err
 	}
+	release, err := api.startTrace(ctx, tx.Gas())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	msg, vmctx, statedb, err := api.backend.StateAtTransaction(ctx, block, int(index), reexec)
 	if err != nil {
 		return nil, err
 	}
 	txctx := &Context{
-		BlockHash: blockHash,
-		TxIndex:   int(index),
-		TxHash:    hash,
+		BlockHash:   blockHash,
+		BlockNumber: block.Number(),
+		TxIndex:     int(index),
+		TxHash:      hash,
 	}
 	return api.traceTx(ctx, msg, txctx, vmctx, statedb, config)
 }
@@ -813,6 +889,21 @@ func (api *API) TraceTransaction(ctx context.Context, hash common.Hash, config *
 // created during the execution of EVM if the given transaction was added on
 // top of the provided block and returns them as a JSON object.
 func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, config *TraceCallConfig) (interface{}, error) {
+	// Calls on blocks preceding the local chain are traced by the legacy node
+	if h := api.backend.HistoricalRPC(); h != nil {
+		historical := false
+		if number, ok := blockNrOrHash.Number(); ok {
+			historical = h.Covers(number)
+		} else if hash, ok := blockNrOrHash.Hash(); ok {
+			header, _ := api.backend.HeaderByHash(ctx, hash)
+			historical = header == nil
+		}
+		if historical {
+			var res json.RawMessage
+			err := h.Call(ctx, &res, "debug_traceCall", args, ethapi.BlockArg(blockNrOrHash), config)
+			return res, err
+		}
+	}
 	// Try to retrieve the specified block
 	var (
 		err   error
@@ -836,6 +927,17 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 	if err != nil {
 		return nil, err
 	}
+	// Reserve the tracing quota for the gas the call may use
+	msg, err := args.ToMessage(api.backend.RPCGasCap(), block.BaseFee())
+	if err != nil {
+		return nil, err
+	}
+	release, err := api.startTrace(ctx, msg.Gas())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	// try to recompute the state
 	reexec := defaultTraceReexec
 	if config != nil && config.Reexec != nil {
@@ -854,11 +956,6 @@ func (api *API) TraceCall(ctx context.Context, args ethapi.TransactionArgs, bloc
 		config.BlockOverrides.Apply(&vmctx)
 	}
 	vmctx.L1CostFunc = core.NewL1CostFunc(api.backend.ChainConfig(), statedb)
-	// Execute the trace
-	msg, err := args.ToMessage(api.backend.RPCGasCap(), block.BaseFee())
-	if err != nil {
-		return nil, err
-	}
 
 	var traceConfig *TraceConfig
 	if config != nil {
@@ -918,13 +1015,21 @@ func (api *API) traceTx(ctx context.Context, message core.Message, txctx *Contex
 	return tracer.GetResult()
 }
 
-// APIs return the collection of RPC services the tracer package offers.
-func APIs(backend Backend) []rpc.API {
+// APIs return the collection of RPC services the tracer package offers, with the
+// tracing work limited by the given quotas. The trace namespace looks up the
+// filtered addresses in the given index if not nil.
+func APIs(backend Backend, quota QuotaConfig, index *TraceIndex) []rpc.API {
+	api := NewAPI(backend)
+	api.quota = newTraceQuota(quota)
+
 	// Append all the local APIs and return
 	return []rpc.API{
 		{
 			Namespace: "debug",
-			Service:   NewAPI(backend),
+			Service:   api,
+		}, {
+			Namespace: "trace",
+			Service:   NewTraceAPI(api, index),
 		},
 	}
 }
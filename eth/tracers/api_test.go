@@ -26,6 +26,7 @@ import (
 	"math/big"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -127,6 +128,10 @@ func (b *testBackend) RPCGasCap() uint64 {
 	return 25000000
 }
 
+func (b *testBackend) HistoricalRPC() *ethapi.HistoricalRPC {
+	return nil
+}
+
 func (b *testBackend) ChainConfig() *params.ChainConfig {
 	return b.chainConfig
 }
@@ -421,6 +426,196 @@ func TestTraceBlock(t *testing.T) {
 	}
 }
 
+func TestTraceQuota(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+	}}
+	api := NewAPI(newTestBackend(t, 2, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), types.HomesteadSigner{}, accounts[0].key)
+		b.AddTx(tx)
+	}))
+	api.quota = newTraceQuota(QuotaConfig{
+		Concurrency:        1,
+		ClientGasPerMinute: params.TxGas + params.TxGas/2,
+		QueueTimeout:       10 * time.Millisecond,
+	})
+	// Traces wait for a free slot only up to the queue timeout
+	release, err := api.quota.acquire(context.Background(), "other")
+	if err != nil {
+		t.Fatalf("failed to acquire trace slot: %v", err)
+	}
+	if _, err := api.TraceBlockByNumber(context.Background(), 1, nil); !errors.Is(err, errTraceBusy) {
+		t.Fatalf("busy error mismatch: have %v, want %v", err, errTraceBusy)
+	}
+	release()
+
+	// The first trace of the window may exceed the remaining gas quota, later ones fail
+	if _, err := api.TraceBlockByNumber(context.Background(), 1, nil); err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if _, err := api.TraceBlockByNumber(context.Background(), 2, nil); !errors.Is(err, errTraceGasQuota) {
+		t.Fatalf("gas quota error mismatch: have %v, want %v", err, errTraceGasQuota)
+	}
+	// Other clients have their own gas quota
+	if err := api.quota.charge("other", params.TxGas); err != nil {
+		t.Fatalf("failed to charge other client: %v", err)
+	}
+}
+
+func TestSubscribeTraceBlock(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+	}}
+	txs := 5
+	api := NewAPI(newTestBackend(t, 1, genesis, func(i int, b *core.BlockGen) {
+		for j := 0; j < txs; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(uint64(j), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), types.HomesteadSigner{}, accounts[0].key)
+			b.AddTx(tx)
+		}
+	}))
+	api.quota = newTraceQuota(QuotaConfig{BlockConcurrency: 2})
+
+	block, _ := api.backend.BlockByNumber(context.Background(), 1)
+	want, err := api.TraceBlockByHash(context.Background(), block.Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("debug", api); err != nil {
+		t.Fatalf("failed to register API: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	results := make(chan *txTraceStreamResult)
+	sub, err := client.Subscribe(context.Background(), "debug", results, "subscribeTraceBlock", block.Hash(), nil)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	for i := 0; i < txs; i++ {
+		select {
+		case res := <-results:
+			if res.TxIndex != i || res.TxHash != block.Transactions()[i].Hash() {
+				t.Fatalf("trace %d: transaction mismatch: have #%d %x", i, res.TxIndex, res.TxHash)
+			}
+			var wantRes interface{}
+			blob, _ := json.Marshal(want[i].Result)
+			json.Unmarshal(blob, &wantRes)
+			if !reflect.DeepEqual(res.Result, wantRes) {
+				t.Fatalf("trace %d: result mismatch: have %v, want %v", i, res.Result, wantRes)
+			}
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("trace %d: timeout", i)
+		}
+	}
+}
+
+// staleStateBackend serves the state of the chain head as the state of every
+// block, making the replay of earlier blocks fail.
+type staleStateBackend struct {
+	*testBackend
+}
+
+func (b *staleStateBackend) StateAtBlock(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB, checkLive bool, preferDisk bool) (*state.StateDB, error) {
+	return b.chain.StateAt(b.chain.CurrentBlock().Root())
+}
+
+// streamBlockTrace subscribes to the trace stream of the given block and
+// collects the transaction traces and the final notification.
+func streamBlockTrace(t *testing.T, backend Backend, hash common.Hash) ([]txTraceStreamResult, blockTraceStreamEnd) {
+	t.Helper()
+
+	server := rpc.NewServer()
+	defer server.Stop()
+	if err := server.RegisterName("debug", NewAPI(backend)); err != nil {
+		t.Fatalf("failed to register tracing api: %v", err)
+	}
+	client := rpc.DialInProc(server)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	ch := make(chan json.RawMessage)
+	sub, err := client.Subscribe(ctx, "debug", ch, "subscribeTraceBlock", hash)
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer sub.Unsubscribe()
+
+	var results []txTraceStreamResult
+	for {
+		select {
+		case msg := <-ch:
+			var end blockTraceStreamEnd
+			if strings.Contains(string(msg), `"traced"`) {
+				if err := json.Unmarshal(msg, &end); err != nil {
+					t.Fatalf("failed to decode stream end: %v", err)
+				}
+				return results, end
+			}
+			var result txTraceStreamResult
+			if err := json.Unmarshal(msg, &result); err != nil {
+				t.Fatalf("failed to decode trace: %v", err)
+			}
+			results = append(results, result)
+		case err := <-sub.Err():
+			t.Fatalf("subscription failed: %v", err)
+		case <-ctx.Done():
+			t.Fatalf("stream not ended, %d traces received", len(results))
+		}
+	}
+}
+
+// Tests that the trace stream of a block ends with a notification telling
+// whether the block was traced completely.
+func TestSubscribeTraceBlockEnd(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+	}}
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 2, genesis, func(i int, b *core.BlockGen) {
+		for j := 0; j < 3; j++ {
+			tx, _ := types.SignTx(types.NewTransaction(uint64(3*i+j), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+			b.AddTx(tx)
+		}
+	})
+	block := backend.chain.GetBlockByNumber(1)
+
+	// A complete stream delivers every trace in order
+	results, end := streamBlockTrace(t, backend, block.Hash())
+	if len(results) != 3 {
+		t.Fatalf("trace count mismatch: have %d, want %d", len(results), 3)
+	}
+	for i, result := range results {
+		if result.TxIndex != i || result.TxHash != block.Transactions()[i].Hash() || result.Error != "" {
+			t.Errorf("trace %d mismatch: index %d, hash %x, error %q", i, result.TxIndex, result.TxHash, result.Error)
+		}
+	}
+	if !end.Complete || end.Traced != 3 || end.Error != "" {
+		t.Errorf("stream end mismatch: have %+v", end)
+	}
+	// A stream cut short by a failing transaction reports the failure
+	results, end = streamBlockTrace(t, &staleStateBackend{backend}, block.Hash())
+	if end.Complete || end.Traced != len(results) || !strings.Contains(end.Error, "transaction 0 failed") {
+		t.Errorf("failed stream end mismatch: have %+v, %d traces", end, len(results))
+	}
+}
+
 func TestTracingWithOverrides(t *testing.T) {
 	t.Parallel()
 	// Initialize test accounts
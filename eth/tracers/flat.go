@@ -0,0 +1,272 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/vm"
+)
+
+// flatCallTracerName is the name of the tracer producing the flat call traces
+// of the trace namespace, also available to the debug namespace.
+const flatCallTracerName = "flatCallTracer"
+
+func init() {
+	RegisterNative(flatCallTracerName, newFlatCallTracer)
+}
+
+// parityErrors maps the EVM errors to the messages of OpenEthereum.
+var parityErrors = map[error]string{
+	vm.ErrExecutionReverted:        "Reverted",
+	vm.ErrOutOfGas:                 "Out of gas",
+	vm.ErrCodeStoreOutOfGas:        "Out of gas",
+	vm.ErrGasUintOverflow:          "Out of gas",
+	vm.ErrInvalidJump:              "Bad jump destination",
+	vm.ErrDepth:                    "Out of stack",
+	vm.ErrInsufficientBalance:      "Insufficient balance",
+	vm.ErrWriteProtection:          "Mutable Call In Static Context",
+	vm.ErrContractAddressCollision: "Contract address collision",
+	vm.ErrReturnDataOutOfBounds:    "Out of bounds",
+}
+
+// FlatCallAction is the action of a flat call trace. Calls fill in the call
+// type, sender, receiver, gas, input and value; creations the sender, gas, init
+// code and value; self-destructs the address, refund address and balance.
+type FlatCallAction struct {
+	Address       *common.Address `json:"address,omitempty"`
+	Balance       *hexutil.Big    `json:"balance,omitempty"`
+	CallType      string          `json:"callType,omitempty"`
+	From          *common.Address `json:"from,omitempty"`
+	Gas           *hexutil.Uint64 `json:"gas,omitempty"`
+	Init          *hexutil.Bytes  `json:"init,omitempty"`
+	Input         *hexutil.Bytes  `json:"input,omitempty"`
+	RefundAddress *common.Address `json:"refundAddress,omitempty"`
+	To            *common.Address `json:"to,omitempty"`
+	Value         *hexutil.Big    `json:"value,omitempty"`
+}
+
+// FlatCallResult is the outcome of a successful call or creation.
+type FlatCallResult struct {
+	Address *common.Address `json:"address,omitempty"`
+	Code    *hexutil.Bytes  `json:"code,omitempty"`
+	GasUsed *hexutil.Uint64 `json:"gasUsed,omitempty"`
+	Output  *hexutil.Bytes  `json:"output,omitempty"`
+}
+
+// FlatCallTrace is a single call, creation or self-destruct of a transaction in
+// the trace format of OpenEthereum.
+type FlatCallTrace struct {
+	Action              FlatCallAction  `json:"action"`
+	BlockHash           *common.Hash    `json:"blockHash"`
+	BlockNumber         *uint64         `json:"blockNumber"`
+	Error               string          `json:"error,omitempty"`
+	Result              *FlatCallResult `json:"result"`
+	Subtraces           int             `json:"subtraces"`
+	TraceAddress        []int           `json:"traceAddress"`
+	TransactionHash     *common.Hash    `json:"transactionHash"`
+	TransactionPosition *uint64         `json:"transactionPosition"`
+	Type                string          `json:"type"`
+}
+
+// flatCallFrame is a call frame of the transaction being traced.
+type flatCallFrame struct {
+	op      vm.OpCode
+	from    common.Address
+	to      common.Address
+	input   []byte
+	gas     uint64
+	gasUsed uint64
+	value   *big.Int
+	output  []byte
+	err     error
+	calls   []*flatCallFrame
+}
+
+// flatCallTracer collects the call frames of a transaction and flattens them
+// into the traces of OpenEthereum.
+type flatCallTracer struct {
+	ctx       *Context
+	env       *vm.EVM
+	callstack []*flatCallFrame
+	interrupt uint32 // Atomic flag to signal execution interruption
+	reason    error  // Textual reason for the interruption
+}
+
+// newFlatCallTracer creates a flat call tracer for the transaction of the
+// given context.
+func newFlatCallTracer(ctx *Context) (Tracer, error) {
+	return &flatCallTracer{ctx: ctx}, nil
+}
+
+// CaptureStart implements the EVMLogger interface to initialize the tracing operation.
+func (t *flatCallTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, create bool, input []byte, gas uint64, value *big.Int) {
+	t.env = env
+	op := vm.CALL
+	if create {
+		op = vm.CREATE
+	}
+	t.callstack = []*flatCallFrame{{op: op, from: from, to: to, input: common.CopyBytes(input), gas: gas, value: value}}
+}
+
+// CaptureEnd is called after the call finishes to finalize the tracing.
+func (t *flatCallTracer) CaptureEnd(output []byte, gasUsed uint64, _ time.Duration, err error) {
+	t.callstack[0].gasUsed, t.callstack[0].output, t.callstack[0].err = gasUsed, common.CopyBytes(output), err
+}
+
+// CaptureState implements the EVMLogger interface to trace a single step of VM execution.
+func (t *flatCallTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+}
+
+// CaptureFault implements the EVMLogger interface to trace an execution fault.
+func (t *flatCallTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, _ *vm.ScopeContext, depth int, err error) {
+}
+
+// CaptureEnter is called when EVM enters a new scope (via call, create or selfdestruct).
+func (t *flatCallTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, input []byte, gas uint64, value *big.Int) {
+	// Skip if tracing was interrupted
+	if atomic.LoadUint32(&t.interrupt) > 0 {
+		t.env.Cancel()
+		return
+	}
+	t.callstack = append(t.callstack, &flatCallFrame{op: typ, from: from, to: to, input: common.CopyBytes(input), gas: gas, value: value})
+}
+
+// CaptureExit is called when EVM exits a scope, even if the scope didn't
+// execute any code.
+func (t *flatCallTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
+	size := len(t.callstack)
+	if size <= 1 {
+		return
+	}
+	call := t.callstack[size-1]
+	t.callstack = t.callstack[:size-1]
+
+	call.gasUsed, call.output, call.err = gasUsed, common.CopyBytes(output), err
+	t.callstack[size-2].calls = append(t.callstack[size-2].calls, call)
+}
+
+func (t *flatCallTracer) CaptureTxStart(gasLimit uint64) {}
+
+func (t *flatCallTracer) CaptureTxEnd(restGas uint64) {}
+
+// GetResult returns the json-encoded list of flat call traces, and any error
+// arising from the encoding or forceful termination (via `Stop`).
+func (t *flatCallTracer) GetResult() (json.RawMessage, error) {
+	if len(t.callstack) != 1 {
+		return nil, errors.New("incorrect number of top-level calls")
+	}
+	traces := t.flatten(t.callstack[0], []int{}, nil)
+	res, err := json.Marshal(traces)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(res), t.reason
+}
+
+// Stop terminates execution of the tracer at the first opportune moment.
+func (t *flatCallTracer) Stop(err error) {
+	t.reason = err
+	atomic.StoreUint32(&t.interrupt, 1)
+}
+
+// flatten appends the traces of the frame and its subcalls, depth first.
+func (t *flatCallTracer) flatten(frame *flatCallFrame, address []int, traces []*FlatCallTrace) []*FlatCallTrace {
+	trace := &FlatCallTrace{
+		Subtraces:    len(frame.calls),
+		TraceAddress: address,
+	}
+	if t.ctx != nil && t.ctx.BlockHash != (common.Hash{}) {
+		blockHash, txHash, txIndex := t.ctx.BlockHash, t.ctx.TxHash, uint64(t.ctx.TxIndex)
+		trace.BlockHash, trace.TransactionHash, trace.TransactionPosition = &blockHash, &txHash, &txIndex
+		if t.ctx.BlockNumber != nil {
+			number := t.ctx.BlockNumber.Uint64()
+			trace.BlockNumber = &number
+		}
+	}
+	value := frame.value
+	if value == nil {
+		value = new(big.Int)
+	}
+	from, to := frame.from, frame.to
+	switch frame.op {
+	case vm.CREATE, vm.CREATE2:
+		gas, init := hexutil.Uint64(frame.gas), hexutil.Bytes(frame.input)
+		trace.Type = "create"
+		trace.Action = FlatCallAction{From: &from, Gas: &gas, Init: &init, Value: (*hexutil.Big)(value)}
+		if frame.err == nil {
+			gasUsed, code := hexutil.Uint64(frame.gasUsed), hexutil.Bytes(frame.output)
+			trace.Result = &FlatCallResult{Address: &to, Code: &code, GasUsed: &gasUsed}
+		}
+	case vm.SELFDESTRUCT:
+		trace.Type = "suicide"
+		trace.Action = FlatCallAction{Address: &from, Balance: (*hexutil.Big)(value), RefundAddress: &to}
+	default:
+		gas, input := hexutil.Uint64(frame.gas), hexutil.Bytes(frame.input)
+		trace.Type = "call"
+		trace.Action = FlatCallAction{CallType: parityCallType(frame.op), From: &from, Gas: &gas, Input: &input, To: &to, Value: (*hexutil.Big)(value)}
+		if frame.err == nil {
+			gasUsed, output := hexutil.Uint64(frame.gasUsed), hexutil.Bytes(frame.output)
+			trace.Result = &FlatCallResult{GasUsed: &gasUsed, Output: &output}
+		}
+	}
+	if frame.err != nil {
+		trace.Error = parityError(frame.err)
+	}
+	traces = append(traces, trace)
+	for i, call := range frame.calls {
+		sub := make([]int, len(address)+1)
+		copy(sub, address)
+		sub[len(address)] = i
+		traces = t.flatten(call, sub, traces)
+	}
+	return traces
+}
+
+// parityCallType returns the OpenEthereum name of a call opcode.
+func parityCallType(op vm.OpCode) string {
+	switch op {
+	case vm.STATICCALL:
+		return "staticcall"
+	case vm.DELEGATECALL:
+		return "delegatecall"
+	case vm.CALLCODE:
+		return "callcode"
+	default:
+		return "call"
+	}
+}
+
+// parityError returns the OpenEthereum message of an EVM error.
+func parityError(err error) string {
+	if msg, ok := parityErrors[err]; ok {
+		return msg
+	}
+	switch err.(type) {
+	case *vm.ErrStackUnderflow, *vm.ErrStackOverflow:
+		return "Out of stack"
+	case *vm.ErrInvalidOpCode:
+		return "Bad instruction"
+	}
+	return err.Error()
+}
@@ -35,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/eth/tracers/logger"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/tests"
@@ -388,3 +389,100 @@ func TestZeroValueToNotExitCall(t *testing.T) {
 		t.Error("have != want")
 	}
 }
+
+// TestDepositTracers tests that the tracers handle rollup deposit transactions,
+// which mint ether to the sender, pay no gas price and are charged their full
+// gas limit, even if they fail.
+func TestDepositTracers(t *testing.T) {
+	var (
+		from   = common.HexToAddress("0x00000000000000000000000000000000000d0001")
+		to     = common.HexToAddress("0x00000000000000000000000000000000deadbeef")
+		mint   = big.NewInt(1000)
+		signer = types.NewLondonSigner(big.NewInt(1))
+		code   = []byte{byte(vm.PUSH1), 0x1, byte(vm.PUSH1), 0x0, byte(vm.SSTORE)} // store 1 in slot 0
+	)
+	context := vm.BlockContext{
+		CanTransfer: core.CanTransfer,
+		Transfer:    core.Transfer,
+		Coinbase:    common.Address{},
+		BlockNumber: new(big.Int).SetUint64(8000000),
+		Time:        new(big.Int).SetUint64(5),
+		Difficulty:  big.NewInt(0x30000),
+		GasLimit:    uint64(6000000),
+	}
+	alloc := core.GenesisAlloc{
+		to:   core.GenesisAccount{Nonce: 1, Code: code, Balance: big.NewInt(0)},
+		from: core.GenesisAccount{Nonce: 5, Balance: big.NewInt(10)},
+	}
+	trace := func(tracerName string, value *big.Int) map[string]interface{} {
+		tx := types.NewTx(&types.DepositTx{
+			From:  from,
+			To:    &to,
+			Mint:  mint,
+			Value: value,
+			Gas:   100000,
+		})
+		msg, err := tx.AsMessage(signer, nil)
+		if err != nil {
+			t.Fatalf("failed to prepare deposit for tracing: %v", err)
+		}
+		var tracer vm.EVMLogger
+		var result func() (json.RawMessage, error)
+		if tracerName == "" {
+			structLogger := logger.NewStructLogger(nil)
+			tracer, result = structLogger, structLogger.GetResult
+		} else {
+			native, err := tracers.New(tracerName, nil)
+			if err != nil {
+				t.Fatalf("failed to create %s: %v", tracerName, err)
+			}
+			tracer, result = native, native.GetResult
+		}
+		_, statedb := tests.MakePreState(rawdb.NewMemoryDatabase(), alloc, false)
+		evm := vm.NewEVM(context, core.NewEVMTxContext(msg), statedb, params.MainnetChainConfig, vm.Config{Debug: true, Tracer: tracer})
+		res, err := core.NewStateTransition(evm, msg, new(core.GasPool).AddGas(tx.Gas())).TransitionDb()
+		if err != nil {
+			t.Fatalf("failed to execute deposit: %v", err)
+		}
+		if res.UsedGas != tx.Gas() {
+			t.Fatalf("deposit gas mismatch: have %d, want %d", res.UsedGas, tx.Gas())
+		}
+		raw, err := result()
+		if err != nil {
+			t.Fatalf("failed to retrieve %s result: %v", tracerName, err)
+		}
+		var have map[string]interface{}
+		if err := json.Unmarshal(raw, &have); err != nil {
+			t.Fatalf("failed to unmarshal %s result: %v", tracerName, err)
+		}
+		return have
+	}
+	for _, tt := range []struct {
+		value  *big.Int
+		failed bool
+	}{
+		{value: big.NewInt(100)},                // funded by the mint
+		{value: big.NewInt(2000), failed: true}, // exceeds balance and mint
+	} {
+		call := trace("callTracer", tt.value)
+		if call["mint"] != "0x3e8" || call["isSystemTx"] != false {
+			t.Errorf("value %v: deposit fields mismatch: have mint %v, isSystemTx %v", tt.value, call["mint"], call["isSystemTx"])
+		}
+		if call["gasUsed"] != "0x186a0" {
+			t.Errorf("value %v: call gas used mismatch: have %v, want 0x186a0", tt.value, call["gasUsed"])
+		}
+		if errStr, _ := call["error"].(string); tt.failed != strings.HasPrefix(errStr, "failed deposit") {
+			t.Errorf("value %v: call error mismatch: have %q, failed %v", tt.value, errStr, tt.failed)
+		}
+		// The prestate excludes the minted ether and the nonce increment
+		prestate := trace("prestateTracer", tt.value)
+		sender, _ := prestate[strings.ToLower(from.Hex())].(map[string]interface{})
+		if sender["balance"] != "0xa" || sender["nonce"] != float64(5) {
+			t.Errorf("value %v: sender prestate mismatch: have %v", tt.value, sender)
+		}
+		logs := trace("", tt.value)
+		if logs["gas"] != float64(100000) || logs["failed"] != tt.failed {
+			t.Errorf("value %v: struct log result mismatch: gas %v, failed %v", tt.value, logs["gas"], logs["failed"])
+		}
+	}
+}
@@ -0,0 +1,468 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// JobStatus is the state of a trace job.
+type JobStatus string
+
+const (
+	JobQueued   JobStatus = "queued"
+	JobRunning  JobStatus = "running"
+	JobDone     JobStatus = "done"
+	JobFailed   JobStatus = "failed"
+	JobCanceled JobStatus = "canceled"
+)
+
+// JobSpec defines a trace job: the block range to trace, the tracer to use and
+// the file the results are written to.
+type JobSpec struct {
+	From     hexutil.Uint64 `json:"from"`               // First block to trace
+	To       hexutil.Uint64 `json:"to"`                 // Last block to trace
+	Output   string         `json:"output"`             // Output file, relative paths are resolved in the job directory
+	Config   *TraceConfig   `json:"config,omitempty"`   // Tracer configuration
+	Throttle string         `json:"throttle,omitempty"` // Pause between blocks, e.g. "100ms"
+}
+
+// Job is a trace job and its progress. Results are written to the output file
+// as one JSON object per line and block.
+type Job struct {
+	ID      uint64         `json:"id"`
+	Spec    JobSpec        `json:"spec"`
+	Status  JobStatus      `json:"status"`
+	Next    hexutil.Uint64 `json:"next"`   // Next block to trace
+	Offset  int64          `json:"offset"` // Output file size after the last traced block
+	Error   string         `json:"error,omitempty"`
+	Created time.Time      `json:"created"`
+	Updated time.Time      `json:"updated"`
+}
+
+// JobManager runs trace jobs one at a time in the background. Jobs and their
+// progress are persisted after every block, so they resume where they left
+// off when the node restarts.
+type JobManager struct {
+	api  *API
+	dir  string // directory of the job file and relative outputs, empty for in-memory
+	file string
+
+	mu      sync.Mutex
+	jobs    []*Job
+	nextID  uint64
+	running uint64             // id of the running job, zero if none
+	cancel  context.CancelFunc // cancels the running job
+
+	wake chan struct{}
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewJobManager creates a trace job manager keeping its state in dir. An
+// empty dir keeps jobs in memory only.
+func NewJobManager(backend Backend, dir string) (*JobManager, error) {
+	m := &JobManager{
+		api:    NewAPI(backend),
+		dir:    dir,
+		nextID: 1,
+		wake:   make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+	}
+	if dir == "" {
+		return m, nil
+	}
+	m.file = filepath.Join(dir, "jobs.json")
+	blob, err := os.ReadFile(m.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(blob, &m.jobs); err != nil {
+		return nil, fmt.Errorf("invalid trace job file %s: %v", m.file, err)
+	}
+	for _, job := range m.jobs {
+		if job.ID >= m.nextID {
+			m.nextID = job.ID + 1
+		}
+		// Interrupted jobs are picked up again from their last checkpoint.
+		if job.Status == JobRunning {
+			job.Status = JobQueued
+		}
+	}
+	return m, nil
+}
+
+// Start implements node.Lifecycle, starting the job runner.
+func (m *JobManager) Start() error {
+	m.wg.Add(1)
+	go m.loop()
+	m.notify()
+	return nil
+}
+
+// Stop implements node.Lifecycle, interrupting the running job.
+func (m *JobManager) Stop() error {
+	close(m.quit)
+	m.mu.Lock()
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+	return nil
+}
+
+// Schedule enqueues the configured jobs which are not known yet, identified by
+// their output file, so that each of them runs once across restarts.
+func (m *JobManager) Schedule(specs []JobSpec) error {
+	for _, spec := range specs {
+		output, err := m.resolveOutput(spec.Output)
+		if err != nil {
+			return err
+		}
+		m.mu.Lock()
+		known := false
+		for _, job := range m.jobs {
+			if job.Spec.Output == output {
+				known = true
+				break
+			}
+		}
+		m.mu.Unlock()
+		if known {
+			continue
+		}
+		job, err := m.Enqueue(spec)
+		if err != nil {
+			return fmt.Errorf("invalid trace job %s: %v", spec.Output, err)
+		}
+		log.Info("Scheduled configured trace job", "id", job.ID, "from", uint64(spec.From), "to", uint64(spec.To), "output", job.Spec.Output)
+	}
+	return nil
+}
+
+// resolveOutput resolves the output file of a job in the job directory.
+func (m *JobManager) resolveOutput(output string) (string, error) {
+	if output == "" {
+		return "", errors.New("missing output file")
+	}
+	if filepath.IsAbs(output) {
+		return output, nil
+	}
+	if m.dir == "" {
+		return "", errors.New("output file must be absolute without a data directory")
+	}
+	return filepath.Join(m.dir, output), nil
+}
+
+// Enqueue adds a new job and returns it.
+func (m *JobManager) Enqueue(spec JobSpec) (*Job, error) {
+	if spec.To < spec.From {
+		return nil, fmt.Errorf("invalid block range %d-%d", spec.From, spec.To)
+	}
+	if spec.From == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	if spec.Throttle != "" {
+		if _, err := time.ParseDuration(spec.Throttle); err != nil {
+			return nil, fmt.Errorf("invalid throttle: %v", err)
+		}
+	}
+	output, err := m.resolveOutput(spec.Output)
+	if err != nil {
+		return nil, err
+	}
+	spec.Output = output
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	job := &Job{
+		ID:      m.nextID,
+		Spec:    spec,
+		Status:  JobQueued,
+		Next:    spec.From,
+		Created: now,
+		Updated: now,
+	}
+	m.nextID++
+	m.jobs = append(m.jobs, job)
+	if err := m.persistLocked(); err != nil {
+		m.jobs = m.jobs[:len(m.jobs)-1]
+		return nil, err
+	}
+	m.notify()
+	cpy := *job
+	return &cpy, nil
+}
+
+// Cancel stops a queued or running job.
+func (m *JobManager) Cancel(id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job := m.jobLocked(id)
+	if job == nil {
+		return fmt.Errorf("trace job %d not found", id)
+	}
+	if job.Status != JobQueued && job.Status != JobRunning {
+		return fmt.Errorf("trace job %d is %s", id, job.Status)
+	}
+	if m.running == id {
+		m.cancel()
+	}
+	job.Status, job.Updated = JobCanceled, time.Now()
+	return m.persistLocked()
+}
+
+// Remove deletes a finished job from the job list. The output file is kept.
+func (m *JobManager) Remove(id uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, job := range m.jobs {
+		if job.ID != id {
+			continue
+		}
+		if job.Status == JobQueued || job.Status == JobRunning {
+			return fmt.Errorf("trace job %d is %s", id, job.Status)
+		}
+		m.jobs = append(m.jobs[:i], m.jobs[i+1:]...)
+		return m.persistLocked()
+	}
+	return fmt.Errorf("trace job %d not found", id)
+}
+
+// Jobs returns a copy of all jobs.
+func (m *JobManager) Jobs() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	jobs := make([]*Job, len(m.jobs))
+	for i, job := range m.jobs {
+		cpy := *job
+		jobs[i] = &cpy
+	}
+	return jobs
+}
+
+func (m *JobManager) jobLocked(id uint64) *Job {
+	for _, job := range m.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+// persistLocked writes the job list to disk, replacing the previous file
+// atomically.
+func (m *JobManager) persistLocked() error {
+	if m.file == "" {
+		return nil
+	}
+	blob, err := json.MarshalIndent(m.jobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return err
+	}
+	tmp := m.file + ".tmp"
+	if err := os.WriteFile(tmp, blob, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, m.file)
+}
+
+func (m *JobManager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// loop runs queued jobs in order of creation.
+func (m *JobManager) loop() {
+	defer m.wg.Done()
+
+	for {
+		m.mu.Lock()
+		var job *Job
+		for _, j := range m.jobs {
+			if j.Status == JobQueued {
+				job = j
+				break
+			}
+		}
+		if job == nil {
+			m.mu.Unlock()
+			select {
+			case <-m.wake:
+				continue
+			case <-m.quit:
+				return
+			}
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		m.running, m.cancel = job.ID, cancel
+		job.Status, job.Updated = JobRunning, time.Now()
+		if err := m.persistLocked(); err != nil {
+			log.Warn("Failed to persist trace jobs", "err", err)
+		}
+		m.mu.Unlock()
+
+		log.Info("Starting trace job", "id", job.ID, "from", uint64(job.Next), "to", uint64(job.Spec.To))
+		err := m.run(ctx, job)
+		cancel()
+
+		m.mu.Lock()
+		m.running, m.cancel = 0, nil
+		switch {
+		case job.Status == JobCanceled:
+			log.Info("Trace job canceled", "id", job.ID)
+		case err == nil:
+			job.Status = JobDone
+			log.Info("Trace job finished", "id", job.ID, "output", job.Spec.Output)
+		case ctx.Err() != nil:
+			// Interrupted by shutdown, resume on the next start.
+			job.Status = JobQueued
+		default:
+			job.Status, job.Error = JobFailed, err.Error()
+			log.Warn("Trace job failed", "id", job.ID, "err", err)
+		}
+		job.Updated = time.Now()
+		if err := m.persistLocked(); err != nil {
+			log.Warn("Failed to persist trace jobs", "err", err)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// run traces the remaining blocks of a job, checkpointing after every block.
+func (m *JobManager) run(ctx context.Context, job *Job) error {
+	out, err := os.OpenFile(job.Spec.Output, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	// Drop any output written after the last checkpoint.
+	if err := out.Truncate(job.Offset); err != nil {
+		return err
+	}
+	if _, err := out.Seek(job.Offset, 0); err != nil {
+		return err
+	}
+	var throttle time.Duration
+	if job.Spec.Throttle != "" {
+		throttle, _ = time.ParseDuration(job.Spec.Throttle)
+	}
+	for number := uint64(job.Next); number <= uint64(job.Spec.To); number++ {
+		block, err := m.api.blockByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return err
+		}
+		// Trace with a single thread to leave resources to the node.
+		traces, err := m.api.traceBlockThreads(ctx, block, job.Spec.Config, 1)
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		blob, err := json.Marshal(&blockTraceResult{
+			Block:  hexutil.Uint64(number),
+			Hash:   block.Hash(),
+			Traces: traces,
+		})
+		if err != nil {
+			return err
+		}
+		n, err := out.Write(append(blob, '\n'))
+		if err != nil {
+			return err
+		}
+		if err := out.Sync(); err != nil {
+			return err
+		}
+		m.mu.Lock()
+		job.Next, job.Offset, job.Updated = hexutil.Uint64(number+1), job.Offset+int64(n), time.Now()
+		err = m.persistLocked()
+		m.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if throttle > 0 {
+			select {
+			case <-time.After(throttle):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+}
+
+// APIs returns the RPC services managing trace jobs.
+func (m *JobManager) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace: "admin",
+			Service:   &JobAPI{m},
+		},
+	}
+}
+
+// JobAPI exposes trace jobs over RPC.
+type JobAPI struct {
+	m *JobManager
+}
+
+// EnqueueTraceJob schedules a new trace job and returns it.
+func (api *JobAPI) EnqueueTraceJob(spec JobSpec) (*Job, error) {
+	return api.m.Enqueue(spec)
+}
+
+// TraceJobs returns all trace jobs with their progress.
+func (api *JobAPI) TraceJobs() []*Job {
+	return api.m.Jobs()
+}
+
+// CancelTraceJob stops a queued or running trace job.
+func (api *JobAPI) CancelTraceJob(id uint64) error {
+	return api.m.Cancel(id)
+}
+
+// RemoveTraceJob deletes a finished trace job.
+func (api *JobAPI) RemoveTraceJob(id uint64) error {
+	return api.m.Remove(id)
+}
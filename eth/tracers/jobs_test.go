@@ -0,0 +1,150 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// waitJob blocks until the job with the given id reaches a final state.
+func waitJob(t *testing.T, m *JobManager, id uint64) *Job {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		for _, job := range m.Jobs() {
+			if job.ID == id && job.Status != JobQueued && job.Status != JobRunning {
+				return job
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("trace job %d did not finish", id)
+	return nil
+}
+
+func TestTraceJobResume(t *testing.T) {
+	t.Parallel()
+
+	accounts := newAccounts(2)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+	}}
+	signer := types.HomesteadSigner{}
+	backend := newTestBackend(t, 5, genesis, func(i int, b *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), accounts[1].addr, big.NewInt(1000), params.TxGas, b.BaseFee(), nil), signer, accounts[0].key)
+		b.AddTx(tx)
+	})
+	dir := t.TempDir()
+
+	// Run a job to completion.
+	m, err := NewJobManager(backend, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+	job, err := m.Enqueue(JobSpec{From: 1, To: 5, Output: "out.jsonl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job = waitJob(t, m, job.ID); job.Status != JobDone {
+		t.Fatalf("job status %s, error %q", job.Status, job.Error)
+	}
+	m.Stop()
+
+	want, err := os.ReadFile(filepath.Join(dir, "out.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(want, []byte("\n")); lines != 5 {
+		t.Fatalf("have %d result lines, want 5", lines)
+	}
+
+	// Simulate a crash after block 2 with partial output of block 3.
+	offset := bytes.Index(want, []byte(`{"block":"0x3"`))
+	os.WriteFile(filepath.Join(dir, "out.jsonl"), append(want[:offset:offset], `{"block":"0x3","ha`...), 0644)
+	job.Status, job.Next, job.Offset = JobRunning, 3, int64(offset)
+	blob, _ := json.Marshal([]*Job{job})
+	os.WriteFile(filepath.Join(dir, "jobs.json"), blob, 0600)
+
+	m, err = NewJobManager(backend, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Start()
+	defer m.Stop()
+	if job = waitJob(t, m, job.ID); job.Status != JobDone {
+		t.Fatalf("resumed job status %s, error %q", job.Status, job.Error)
+	}
+	have, err := os.ReadFile(filepath.Join(dir, "out.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(have, want) {
+		t.Fatalf("resumed output mismatch:\nhave %s\nwant %s", have, want)
+	}
+}
+
+// Tests that configured jobs are scheduled once, and not again after a restart.
+func TestTraceJobSchedule(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	m, err := NewJobManager(nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	specs := []JobSpec{
+		{From: 1, To: 5, Output: "a.jsonl"},
+		{From: 2, To: 3, Output: filepath.Join(dir, "b.jsonl")},
+	}
+	if err := m.Schedule(specs); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Schedule(specs); err != nil {
+		t.Fatal(err)
+	}
+	if jobs := m.Jobs(); len(jobs) != 2 {
+		t.Fatalf("have %d jobs, want 2", len(jobs))
+	}
+	// Jobs known from the previous run are not scheduled again
+	m, err = NewJobManager(nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Schedule(append(specs, JobSpec{From: 1, To: 1, Output: "c.jsonl"})); err != nil {
+		t.Fatal(err)
+	}
+	jobs := m.Jobs()
+	if len(jobs) != 3 {
+		t.Fatalf("have %d jobs after restart, want 3", len(jobs))
+	}
+	if want := filepath.Join(dir, "c.jsonl"); jobs[2].Spec.Output != want || jobs[2].ID != 3 {
+		t.Fatalf("wrong scheduled job: id %d, output %s", jobs[2].ID, jobs[2].Spec.Output)
+	}
+	if err := m.Schedule([]JobSpec{{From: 5, To: 1, Output: "d.jsonl"}}); err == nil {
+		t.Fatal("invalid job scheduled")
+	}
+}
@@ -225,6 +225,19 @@ func (t *jsTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Addr
 	}
 	t.ctx["value"] = valueBig
 	t.ctx["block"] = t.vm.ToValue(env.Context.BlockNumber.Uint64())
+	if env.TxContext.IsDeposit {
+		mint := env.TxContext.Mint
+		if mint == nil {
+			mint = new(big.Int)
+		}
+		mintBig, err := t.toBig(t.vm, mint.String())
+		if err != nil {
+			t.err = err
+			return
+		}
+		t.ctx["mint"] = mintBig
+		t.ctx["isSystemTx"] = t.vm.ToValue(env.TxContext.IsSystemTx)
+	}
 	// Update list of precompiles based on current block
 	rules := env.ChainConfig().Rules(env.Context.BlockNumber, env.Context.Random != nil)
 	t.activePrecompiles = vm.ActivePrecompiles(rules)
@@ -61,6 +61,11 @@
 		var fromBal = bigInt(this.prestate[toHex(ctx.from)].balance.slice(2), 16);
 		var toBal   = bigInt(this.prestate[toHex(ctx.to)].balance.slice(2), 16);
 
+		// Deposits mint to the sender before execution
+		if (ctx.mint !== undefined) {
+			fromBal = fromBal.subtract(ctx.mint);
+		}
+
 		this.prestate[toHex(ctx.to)].balance   = '0x'+toBal.subtract(ctx.value).toString(16);
 		this.prestate[toHex(ctx.from)].balance = '0x'+fromBal.add(ctx.value).add((ctx.gasUsed + ctx.intrinsicGas) * ctx.gasPrice).toString(16);
 
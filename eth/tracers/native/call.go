@@ -45,11 +45,16 @@ type callFrame struct {
 	Output  string      `json:"output,omitempty"`
 	Error   string      `json:"error,omitempty"`
 	Calls   []callFrame `json:"calls,omitempty"`
+
+	// Rollup deposit fields, only set on the top frame of deposit transactions
+	Mint       string `json:"mint,omitempty"`
+	IsSystemTx *bool  `json:"isSystemTx,omitempty"`
 }
 
 type callTracer struct {
 	env       *vm.EVM
 	callstack []callFrame
+	gasLimit  uint64 // Amount of gas bought for the whole tx
 	interrupt uint32 // Atomic flag to signal execution interruption
 	reason    error  // Textual reason for the interruption
 }
@@ -76,6 +81,15 @@ func (t *callTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Ad
 	if create {
 		t.callstack[0].Type = "CREATE"
 	}
+	if env.TxContext.IsDeposit {
+		mint := env.TxContext.Mint
+		if mint == nil {
+			mint = new(big.Int)
+		}
+		isSystemTx := env.TxContext.IsSystemTx
+		t.callstack[0].Mint = bigToHex(mint)
+		t.callstack[0].IsSystemTx = &isSystemTx
+	}
 }
 
 // CaptureEnd is called after the call finishes to finalize the tracing.
@@ -142,9 +156,16 @@ func (t *callTracer) CaptureExit(output []byte, gasUsed uint64, err error) {
 	t.callstack[size-1].Calls = append(t.callstack[size-1].Calls, call)
 }
 
-func (*callTracer) CaptureTxStart(gasLimit uint64) {}
+func (t *callTracer) CaptureTxStart(gasLimit uint64) {
+	t.gasLimit = gasLimit
+}
 
-func (*callTracer) CaptureTxEnd(restGas uint64) {}
+func (t *callTracer) CaptureTxEnd(restGas uint64) {
+	// Deposits are charged their full gas limit, report the same as the receipt
+	if t.env != nil && t.env.TxContext.IsDeposit {
+		t.callstack[0].GasUsed = uintToHex(t.gasLimit - restGas)
+	}
+}
 
 // GetResult returns the json-encoded nested list of call traces, and any
 // error arising from the encoding or forceful termination (via `Stop`).
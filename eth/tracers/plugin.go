@@ -0,0 +1,83 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"fmt"
+	"plugin"
+	"sort"
+)
+
+// PluginSymbol is the name of the variable through which a Go plugin exports
+// its native tracers, of type map[string]tracers.NativeConstructor:
+//
+//	package main
+//
+//	var Tracers = map[string]tracers.NativeConstructor{
+//		"bridgeEventTracer": newBridgeEventTracer,
+//	}
+//
+// The plugin needs to be built with `go build -buildmode=plugin` against the
+// same go-ethereum sources, Go version and build tags as the node loading it.
+// Some dependencies only support dynamic linking in pure Go, so both the node
+// and the plugin need the purego build tag.
+const PluginSymbol = "Tracers"
+
+// LoadPlugin opens the Go plugin at the given path and registers the native
+// tracers it exports, returning their names. No tracer is registered if any of
+// the names is already taken.
+func LoadPlugin(path string) ([]string, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup(PluginSymbol)
+	if err != nil {
+		return nil, err
+	}
+	exported, ok := sym.(*map[string]NativeConstructor)
+	if !ok {
+		return nil, fmt.Errorf("plugin symbol %s has type %T, want *map[string]tracers.NativeConstructor", PluginSymbol, sym)
+	}
+	return registerNatives(*exported)
+}
+
+// registerNatives registers the given native tracers at once, failing without
+// registering any of them if a name is taken.
+func registerNatives(ctors map[string]NativeConstructor) ([]string, error) {
+	names := make([]string, 0, len(ctors))
+	for name, ctor := range ctors {
+		if name == "" || ctor == nil {
+			return nil, fmt.Errorf("native tracer %q without name or constructor", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	nativesLock.Lock()
+	defer nativesLock.Unlock()
+
+	for _, name := range names {
+		if _, ok := natives[name]; ok {
+			return nil, fmt.Errorf("native tracer %q already registered", name)
+		}
+	}
+	for name, ctor := range ctors {
+		natives[name] = ctor
+	}
+	return names, nil
+}
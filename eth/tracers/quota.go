@@ -0,0 +1,241 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// quotaWindow is the period over which traced gas is accounted.
+const quotaWindow = time.Minute
+
+var (
+	// errTraceBusy is returned if a trace couldn't start within the queue timeout.
+	errTraceBusy = errors.New("too many concurrent traces")
+
+	// errTraceGasQuota is returned if the gas traced in the current window
+	// exhausted the quota.
+	errTraceGasQuota = errors.New("trace gas quota exceeded")
+
+	traceQueuedGauge    = metrics.NewRegisteredGauge("eth/tracers/quota/queued", nil)
+	traceBusyMeter      = metrics.NewRegisteredMeter("eth/tracers/quota/busy", nil)
+	traceGasQuotaMeter  = metrics.NewRegisteredMeter("eth/tracers/quota/gas", nil)
+	traceGasTracedMeter = metrics.NewRegisteredMeter("eth/tracers/quota/traced", nil)
+)
+
+// QuotaConfig limits the tracing work done on behalf of RPC clients, both in
+// total and per client. Clients are told apart by their remote IP address.
+// Zero values disable the respective limit.
+type QuotaConfig struct {
+	Concurrency        int           // Traces running at once
+	ClientConcurrency  int           // Traces running at once per client
+	GasPerMinute       uint64        // Gas traced per minute
+	ClientGasPerMinute uint64        // Gas traced per minute per client
+	QueueTimeout       time.Duration // Maximum time a trace waits for a free slot (0 = until cancelled)
+	BlockConcurrency   int           // Transactions of a block traced at once (0 = number of CPUs)
+}
+
+// gasWindow accounts the gas traced in the current quota window.
+type gasWindow struct {
+	start time.Time
+	gas   uint64
+}
+
+// charge adds the gas to the window if within the limit, returning the time
+// until the window resets otherwise. An empty window accepts any amount, so
+// traces larger than the limit are still possible.
+func (w *gasWindow) charge(gas, limit uint64, now time.Time) (bool, time.Duration) {
+	if now.Sub(w.start) >= quotaWindow {
+		w.start, w.gas = now, 0
+	}
+	if limit > 0 && w.gas > 0 && w.gas+gas > limit {
+		return false, w.start.Add(quotaWindow).Sub(now)
+	}
+	w.gas += gas
+	return true, 0
+}
+
+// clientQuota is the tracing usage of a single client.
+type clientQuota struct {
+	slots  chan struct{} // running traces, nil if unlimited
+	gas    gasWindow
+	active int // running and queued traces, protected by the quota lock
+}
+
+// traceQuota enforces a QuotaConfig.
+type traceQuota struct {
+	config QuotaConfig
+	slots  chan struct{} // running traces, nil if unlimited
+
+	mu      sync.Mutex
+	gas     gasWindow
+	clients map[string]*clientQuota
+}
+
+func newTraceQuota(config QuotaConfig) *traceQuota {
+	q := &traceQuota{
+		config:  config,
+		clients: make(map[string]*clientQuota),
+	}
+	if config.Concurrency > 0 {
+		q.slots = make(chan struct{}, config.Concurrency)
+	}
+	return q
+}
+
+// client returns the usage of the given client, creating it if needed.
+//
+// Note, this method assumes the quota lock is held!
+func (q *traceQuota) client(id string) *clientQuota {
+	c := q.clients[id]
+	if c == nil {
+		c = new(clientQuota)
+		if q.config.ClientConcurrency > 0 {
+			c.slots = make(chan struct{}, q.config.ClientConcurrency)
+		}
+		q.clients[id] = c
+	}
+	return c
+}
+
+// acquire waits for a free slot for the client, both within its own limit and
+// the global one, and returns the function releasing it.
+func (q *traceQuota) acquire(ctx context.Context, id string) (func(), error) {
+	q.mu.Lock()
+	c := q.client(id)
+	c.active++
+	q.mu.Unlock()
+
+	done := func() {
+		q.mu.Lock()
+		c.active--
+		q.mu.Unlock()
+	}
+	var timeout <-chan time.Time
+	if q.config.QueueTimeout > 0 {
+		timer := time.NewTimer(q.config.QueueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	traceQueuedGauge.Inc(1)
+	defer traceQueuedGauge.Dec(1)
+
+	wait := func(slots chan struct{}, limit int, scope string) error {
+		if slots == nil {
+			return nil
+		}
+		select {
+		case slots <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout:
+			traceBusyMeter.Mark(1)
+			return fmt.Errorf("%w: %d %s traces running, waited %v", errTraceBusy, limit, scope, q.config.QueueTimeout)
+		}
+	}
+	if err := wait(c.slots, q.config.ClientConcurrency, "client"); err != nil {
+		done()
+		return nil, err
+	}
+	if err := wait(q.slots, q.config.Concurrency, "total"); err != nil {
+		if c.slots != nil {
+			<-c.slots
+		}
+		done()
+		return nil, err
+	}
+	return func() {
+		if q.slots != nil {
+			<-q.slots
+		}
+		if c.slots != nil {
+			<-c.slots
+		}
+		done()
+	}, nil
+}
+
+// charge accounts the gas about to be traced for the client, failing if the
+// client or the node exhausted its gas quota of the current window.
+func (q *traceQuota) charge(id string, gas uint64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(q.gas.start) >= quotaWindow {
+		// Forget the clients that went idle along with the previous window
+		for cid, c := range q.clients {
+			if c.active == 0 && now.Sub(c.gas.start) >= quotaWindow {
+				delete(q.clients, cid)
+			}
+		}
+	}
+	c := q.client(id)
+	client, global := c.gas, q.gas
+	if ok, retry := client.charge(gas, q.config.ClientGasPerMinute, now); !ok {
+		traceGasQuotaMeter.Mark(1)
+		return fmt.Errorf("%w: client traced %d of %d gas per minute, retry in %v", errTraceGasQuota, c.gas.gas, q.config.ClientGasPerMinute, common.PrettyDuration(retry))
+	}
+	if ok, retry := global.charge(gas, q.config.GasPerMinute, now); !ok {
+		traceGasQuotaMeter.Mark(1)
+		return fmt.Errorf("%w: node traced %d of %d gas per minute, retry in %v", errTraceGasQuota, q.gas.gas, q.config.GasPerMinute, common.PrettyDuration(retry))
+	}
+	c.gas, q.gas = client, global
+	traceGasTracedMeter.Mark(int64(gas))
+	return nil
+}
+
+// traceClient identifies the RPC client of the request by its IP address.
+func traceClient(ctx context.Context) string {
+	peer := rpc.PeerInfoFromContext(ctx)
+	if host, _, err := net.SplitHostPort(peer.RemoteAddr); err == nil {
+		return host
+	}
+	if peer.RemoteAddr != "" {
+		return peer.RemoteAddr
+	}
+	return peer.Transport
+}
+
+// startTrace reserves the quota for tracing the given amount of gas on behalf
+// of the client of the request. The returned function must be called once the
+// trace is done.
+func (api *API) startTrace(ctx context.Context, gas uint64) (func(), error) {
+	if api.quota == nil {
+		return func() {}, nil
+	}
+	id := traceClient(ctx)
+	release, err := api.quota.acquire(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := api.quota.charge(id, gas); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
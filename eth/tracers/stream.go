@@ -0,0 +1,212 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// txTraceStreamResult is the trace of a single transaction streamed by
+// debug_subscribeTraceBlock.
+type txTraceStreamResult struct {
+	TxIndex int         `json:"txIndex"`          // Index of the transaction within the block
+	TxHash  common.Hash `json:"txHash"`           // Hash of the transaction
+	Result  interface{} `json:"result,omitempty"` // Trace results produced by the tracer
+	Error   string      `json:"error,omitempty"`  // Trace failure produced by the tracer
+}
+
+// blockTraceStreamEnd is the last notification of debug_subscribeTraceBlock,
+// telling a complete stream apart from one cut short.
+type blockTraceStreamEnd struct {
+	Complete bool   `json:"complete"`        // Whether every transaction of the block was traced
+	Traced   int    `json:"traced"`          // Number of transaction traces streamed
+	Error    string `json:"error,omitempty"` // Failure that ended the stream early
+}
+
+// txTraceStreamTask is a transaction queued for tracing, whose result is
+// delivered on done.
+type txTraceStreamTask struct {
+	statedb *state.StateDB // Intermediate state prepped for tracing
+	index   int            // Transaction offset in the block
+	done    chan *txTraceStreamResult
+}
+
+// SubscribeTraceBlock traces the transactions of the block with the given hash
+// and streams their traces as they are produced, one notification per
+// transaction in the order of the block, followed by a final notification
+// reporting whether the block was traced completely. Unlike
+// debug_traceBlockByHash, the traces of the whole block are never held at once,
+// making it usable on large blocks with the struct logger. It's invoked via debug_subscribe, with
+// "subscribeTraceBlock" as first parameter.
+func (api *API) SubscribeTraceBlock(ctx context.Context, hash common.Hash, config *TraceConfig) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	block, err := api.blockByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if block.NumberU64() == 0 {
+		return nil, errors.New("genesis is not traceable")
+	}
+	release, err := api.startTrace(ctx, block.GasUsed())
+	if err != nil {
+		return nil, err
+	}
+	parent, err := api.blockByNumberAndHash(ctx, rpc.BlockNumber(block.NumberU64()-1), block.ParentHash())
+	if err != nil {
+		release()
+		return nil, err
+	}
+	reexec := defaultTraceReexec
+	if config != nil && config.Reexec != nil {
+		reexec = *config.Reexec
+	}
+	statedb, err := api.backend.StateAtBlock(ctx, parent, reexec, nil, true, false)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	sub := notifier.CreateSubscription()
+
+	go func() {
+		defer release()
+		api.streamBlockTrace(notifier, sub, block, statedb, config)
+	}()
+	return sub, nil
+}
+
+// streamBlockTrace traces the transactions of the block on top of the given
+// parent state, notifying the subscriber of each trace in the order of the
+// block. At most as many transactions as traced concurrently are buffered,
+// the tracing being held back by the delivery of the notifications.
+func (api *API) streamBlockTrace(notifier *rpc.Notifier, sub *rpc.Subscription, block *types.Block, statedb *state.StateDB, config *TraceConfig) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		signer    = types.MakeSigner(api.backend.ChainConfig(), block.Number())
+		txs       = block.Transactions()
+		blockHash = block.Hash()
+		threads   = api.blockThreads()
+		begin     = time.Now()
+	)
+	if threads > len(txs) {
+		threads = len(txs)
+	}
+	var (
+		jobs    = make(chan *txTraceStreamTask, threads)
+		ordered = make(chan *txTraceStreamTask, threads)
+	)
+	for th := 0; th < threads; th++ {
+		go func() {
+			blockCtx := core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
+			blockCtx.L1CostFunc = core.NewL1CostFunc(api.backend.ChainConfig(), statedb)
+			for task := range jobs {
+				msg, _ := txs[task.index].AsMessage(signer, block.BaseFee())
+				txctx := &Context{
+					BlockHash:   blockHash,
+					BlockNumber: block.Number(),
+					TxIndex:     task.index,
+					TxHash:      txs[task.index].Hash(),
+				}
+				result := &txTraceStreamResult{TxIndex: task.index, TxHash: txctx.TxHash}
+				if res, err := api.traceTx(ctx, msg, txctx, blockCtx, task.statedb, config); err != nil {
+					result.Error = err.Error()
+				} else {
+					result.Result = res
+				}
+				task.done <- result
+			}
+		}()
+	}
+	// Deliver the traces in order as they complete, dropping them once the
+	// subscriber is gone
+	delivered := make(chan int)
+	go func() {
+		var count int
+		for task := range ordered {
+			result := <-task.done
+			select {
+			case <-sub.Err():
+			case <-notifier.Closed():
+			default:
+				notifier.Notify(sub.ID, result)
+				count++
+			}
+		}
+		delivered <- count
+	}()
+	// Feed the transactions into the tracers, advancing the state without tracing
+	var (
+		failed   error
+		failedAt int
+	)
+	blockCtx := core.NewEVMBlockContext(block.Header(), api.chainContext(ctx), nil)
+	blockCtx.L1CostFunc = core.NewL1CostFunc(api.backend.ChainConfig(), statedb)
+feed:
+	for i, tx := range txs {
+		task := &txTraceStreamTask{statedb: statedb.Copy(), index: i, done: make(chan *txTraceStreamResult, 1)}
+		select {
+		case ordered <- task:
+		case <-sub.Err():
+			break feed
+		case <-notifier.Closed():
+			break feed
+		}
+		jobs <- task
+
+		msg, _ := tx.AsMessage(signer, block.BaseFee())
+		statedb.Prepare(tx.Hash(), i)
+		vmenv := vm.NewEVM(blockCtx, core.NewEVMTxContext(msg), statedb, api.backend.ChainConfig(), vm.Config{})
+		if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(msg.Gas())); err != nil {
+			failed, failedAt = err, i
+			break
+		}
+		// Only delete empty objects if EIP158/161 (a.k.a Spurious Dragon) is in effect
+		statedb.Finalise(vmenv.ChainConfig().IsEIP158(block.Number()))
+	}
+	close(jobs)
+	close(ordered)
+	count := <-delivered
+
+	end := &blockTraceStreamEnd{Traced: count}
+	switch {
+	case failed != nil:
+		log.Warn("Block trace streaming failed", "number", block.NumberU64(), "hash", blockHash, "transactions", count, "elapsed", time.Since(begin), "err", failed)
+		end.Error = fmt.Sprintf("transaction %d failed: %v", failedAt, failed)
+	case count < len(txs):
+		log.Debug("Block trace streaming aborted", "number", block.NumberU64(), "hash", blockHash, "transactions", count, "elapsed", time.Since(begin))
+		return // Subscriber gone, nobody to notify
+	default:
+		log.Debug("Block trace streaming finished", "number", block.NumberU64(), "hash", blockHash, "transactions", count, "elapsed", time.Since(begin))
+		end.Complete = true
+	}
+	notifier.Notify(sub.ID, end)
+}
@@ -0,0 +1,252 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// traceFilterMaxBlocks is the maximum number of blocks traced by a single
+// trace_filter query.
+const traceFilterMaxBlocks = 10000
+
+// TraceAPI is the OpenEthereum compatible trace namespace, serving the flat call
+// traces of transactions. Block rewards aren't traced, there being none.
+type TraceAPI struct {
+	api   *API
+	index *TraceIndex // Index of the addresses in the traces, nil if not indexed
+}
+
+// NewTraceAPI creates the trace namespace on top of the tracing API, looking up
+// the filtered addresses in the given index if not nil.
+func NewTraceAPI(api *API, index *TraceIndex) *TraceAPI {
+	return &TraceAPI{api: api, index: index}
+}
+
+// TraceFilterArgs are the criteria of the traces returned by trace_filter. A
+// trace matches if its sender is one of FromAddress and its receiver is one of
+// ToAddress, empty lists matching any address. The matching traces are paged
+// through by skipping After of them and returning Count at most.
+type TraceFilterArgs struct {
+	FromBlock   *rpc.BlockNumber `json:"fromBlock"`
+	ToBlock     *rpc.BlockNumber `json:"toBlock"`
+	FromAddress []common.Address `json:"fromAddress"`
+	ToAddress   []common.Address `json:"toAddress"`
+	After       *uint64          `json:"after"`
+	Count       *uint64          `json:"count"`
+}
+
+// Block returns the flat call traces of all the transactions in the block.
+func (api *TraceAPI) Block(ctx context.Context, number rpc.BlockNumber) ([]*FlatCallTrace, error) {
+	if h := api.api.backend.HistoricalRPC(); h.Covers(number) {
+		var res []*FlatCallTrace
+		err := h.Call(ctx, &res, "trace_block", number)
+		return res, err
+	}
+	block, err := api.api.blockByNumber(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+	return api.blockTraces(ctx, block)
+}
+
+// Transaction returns the flat call traces of the transaction, or nil if the
+// transaction is unknown.
+func (api *TraceAPI) Transaction(ctx context.Context, hash common.Hash) ([]*FlatCallTrace, error) {
+	tx, _, _, _, err := api.api.backend.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		// Transactions preceding the local chain are traced by the legacy node
+		if h := api.api.backend.HistoricalRPC(); h != nil {
+			var res []*FlatCallTrace
+			err := h.Call(ctx, &res, "trace_transaction", hash)
+			return res, err
+		}
+		return nil, nil
+	}
+	tracer := flatCallTracerName
+	res, err := api.api.TraceTransaction(ctx, hash, &TraceConfig{Tracer: &tracer})
+	if err != nil {
+		return nil, err
+	}
+	var traces []*FlatCallTrace
+	if err := json.Unmarshal(res.(json.RawMessage), &traces); err != nil {
+		return nil, err
+	}
+	return traces, nil
+}
+
+// Filter returns the flat call traces in the block range matching the criteria.
+// The addresses are looked up in the trace index if it covers the range,
+// otherwise every block of the range is traced.
+func (api *TraceAPI) Filter(ctx context.Context, args TraceFilterArgs) ([]*FlatCallTrace, error) {
+	from, err := api.resolveNumber(ctx, args.FromBlock)
+	if err != nil {
+		return nil, err
+	}
+	to, err := api.resolveNumber(ctx, args.ToBlock)
+	if err != nil {
+		return nil, err
+	}
+	if from > to {
+		return nil, fmt.Errorf("invalid block range %d-%d", from, to)
+	}
+	var numbers []uint64
+	if api.index != nil && len(args.FromAddress)+len(args.ToAddress) > 0 && api.index.covers(from, to) {
+		if numbers, err = api.index.blocks(args.FromAddress, args.ToAddress, from, to); err != nil {
+			return nil, err
+		}
+	} else {
+		if to-from >= traceFilterMaxBlocks {
+			return nil, fmt.Errorf("block range %d-%d exceeds %d blocks", from, to, traceFilterMaxBlocks)
+		}
+		for n := from; n <= to; n++ {
+			numbers = append(numbers, n)
+		}
+	}
+	var (
+		results = []*FlatCallTrace{}
+		skipped uint64
+	)
+	for i, number := range numbers {
+		if i == traceFilterMaxBlocks {
+			return nil, fmt.Errorf("filter matches more than %d blocks, narrow the range or page with count", traceFilterMaxBlocks)
+		}
+		if number == 0 {
+			continue // Genesis isn't traceable, nor contains any transactions
+		}
+		block, err := api.api.blockByNumber(ctx, rpc.BlockNumber(number))
+		if err != nil {
+			return nil, err
+		}
+		traces, err := api.blockTraces(ctx, block)
+		if err != nil {
+			return nil, err
+		}
+		for _, trace := range traces {
+			if !args.matches(trace) {
+				continue
+			}
+			if args.After != nil && skipped < *args.After {
+				skipped++
+				continue
+			}
+			results = append(results, trace)
+			if args.Count != nil && uint64(len(results)) >= *args.Count {
+				return results, nil
+			}
+		}
+	}
+	return results, nil
+}
+
+// resolveNumber returns the number of the block, the latest one if not given.
+func (api *TraceAPI) resolveNumber(ctx context.Context, number *rpc.BlockNumber) (uint64, error) {
+	if number != nil && *number >= 0 {
+		return uint64(*number), nil
+	}
+	tag := rpc.LatestBlockNumber
+	if number != nil {
+		tag = *number
+	}
+	header, err := api.api.backend.HeaderByNumber(ctx, tag)
+	if err != nil {
+		return 0, err
+	}
+	if header == nil {
+		return 0, fmt.Errorf("block %v not found", tag)
+	}
+	return header.Number.Uint64(), nil
+}
+
+// blockTraces traces the transactions of the block on behalf of an RPC client.
+func (api *TraceAPI) blockTraces(ctx context.Context, block *types.Block) ([]*FlatCallTrace, error) {
+	if block.NumberU64() == 0 {
+		return []*FlatCallTrace{}, nil
+	}
+	tracer := flatCallTracerName
+	results, err := api.api.traceBlock(ctx, block, &TraceConfig{Tracer: &tracer})
+	if err != nil {
+		return nil, err
+	}
+	return decodeFlatTraces(block, results)
+}
+
+// decodeFlatTraces concatenates the flat call traces of the transactions of a
+// block.
+func decodeFlatTraces(block *types.Block, results []*txTraceResult) ([]*FlatCallTrace, error) {
+	traces := []*FlatCallTrace{}
+	for i, result := range results {
+		if result.Error != "" {
+			return nil, fmt.Errorf("failed to trace transaction %#x: %s", block.Transactions()[i].Hash(), result.Error)
+		}
+		var txTraces []*FlatCallTrace
+		if err := json.Unmarshal(result.Result.(json.RawMessage), &txTraces); err != nil {
+			return nil, err
+		}
+		traces = append(traces, txTraces...)
+	}
+	return traces, nil
+}
+
+// matches returns whether the trace meets the address criteria.
+func (args *TraceFilterArgs) matches(trace *FlatCallTrace) bool {
+	from, to := traceParties(trace)
+	return containsAddress(args.FromAddress, from) && containsAddress(args.ToAddress, to)
+}
+
+// traceParties returns the sender and receiver of a trace: the caller and the
+// callee of calls, the creator and the created contract of creations, and the
+// destructed contract and the beneficiary of self-destructs.
+func traceParties(trace *FlatCallTrace) (from, to *common.Address) {
+	switch trace.Type {
+	case "create":
+		if trace.Result != nil {
+			to = trace.Result.Address
+		}
+		return trace.Action.From, to
+	case "suicide":
+		return trace.Action.Address, trace.Action.RefundAddress
+	default:
+		return trace.Action.From, trace.Action.To
+	}
+}
+
+// containsAddress returns whether the address is in the list, an empty list
+// containing any address.
+func containsAddress(addrs []common.Address, addr *common.Address) bool {
+	if len(addrs) == 0 {
+		return true
+	}
+	if addr == nil {
+		return false
+	}
+	for _, a := range addrs {
+		if a == *addr {
+			return true
+		}
+	}
+	return false
+}
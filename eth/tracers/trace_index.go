@@ -0,0 +1,413 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"encoding/binary"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// traceIndexBackfillBatch is the number of old blocks indexed between checks
+// for new chain heads.
+const traceIndexBackfillBatch = 16
+
+var (
+	traceIndexHeadKey     = []byte("Head") // Number of the highest indexed block
+	traceIndexTailKey     = []byte("Tail") // Number of the lowest indexed block
+	traceIndexHashPrefix  = []byte("h")    // traceIndexHashPrefix + number (uint64 big endian) -> hash of the indexed block
+	traceIndexEntryPrefix = []byte("a")    // traceIndexEntryPrefix + address + number (uint64 big endian) -> nil
+)
+
+// TraceIndex is an on-disk index of the blocks whose traces have an address as
+// sender or receiver, covering a contiguous range of blocks. Blocks reorged out
+// of the chain leave stale entries, so the indexed blocks are candidates to be
+// traced rather than definitive matches.
+type TraceIndex struct {
+	db ethdb.KeyValueStore
+
+	lock    sync.RWMutex
+	indexed bool   // Whether any block was indexed
+	head    uint64 // Number of the highest indexed block
+	tail    uint64 // Number of the lowest indexed block
+}
+
+// NewTraceIndex opens the trace index stored in the given database.
+func NewTraceIndex(db ethdb.KeyValueStore) (*TraceIndex, error) {
+	index := &TraceIndex{db: db}
+	if ok, err := db.Has(traceIndexHeadKey); err != nil || !ok {
+		return index, err // Nothing indexed yet, unless failed
+	}
+	head, err := db.Get(traceIndexHeadKey)
+	if err != nil {
+		return nil, err
+	}
+	tail, err := db.Get(traceIndexTailKey)
+	if err != nil {
+		return nil, err
+	}
+	index.indexed = true
+	index.head = binary.BigEndian.Uint64(head)
+	index.tail = binary.BigEndian.Uint64(tail)
+	return index, nil
+}
+
+// covers returns whether all the blocks of the range are indexed.
+func (index *TraceIndex) covers(from, to uint64) bool {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+
+	return index.indexed && index.tail <= from && to <= index.head
+}
+
+// span returns the range of indexed blocks.
+func (index *TraceIndex) span() (bool, uint64, uint64) {
+	index.lock.RLock()
+	defer index.lock.RUnlock()
+
+	return index.indexed, index.tail, index.head
+}
+
+// hash returns the hash of the indexed block with the given number.
+func (index *TraceIndex) hash(number uint64) common.Hash {
+	blob, _ := index.db.Get(append(common.CopyBytes(traceIndexHashPrefix), encodeTraceIndexNumber(number)...))
+	return common.BytesToHash(blob)
+}
+
+// blocks returns the ascending numbers of the blocks in the range whose traces
+// may match the addresses: blocks with one of the senders if any are given,
+// and with one of the receivers if any are given.
+func (index *TraceIndex) blocks(senders, receivers []common.Address, from, to uint64) ([]uint64, error) {
+	var (
+		numbers []uint64
+		err     error
+	)
+	if len(senders) > 0 {
+		if numbers, err = index.lookup(senders, from, to); err != nil {
+			return nil, err
+		}
+	}
+	if len(receivers) > 0 {
+		matches, err := index.lookup(receivers, from, to)
+		if err != nil {
+			return nil, err
+		}
+		if len(senders) == 0 {
+			return matches, nil
+		}
+		numbers = intersectNumbers(numbers, matches)
+	}
+	return numbers, nil
+}
+
+// lookup returns the ascending numbers of the blocks in the range whose traces
+// contain any of the addresses.
+func (index *TraceIndex) lookup(addrs []common.Address, from, to uint64) ([]uint64, error) {
+	set := make(map[uint64]struct{})
+	for _, addr := range addrs {
+		prefix := append(common.CopyBytes(traceIndexEntryPrefix), addr.Bytes()...)
+		it := index.db.NewIterator(prefix, encodeTraceIndexNumber(from))
+		for it.Next() {
+			number := binary.BigEndian.Uint64(it.Key()[len(prefix):])
+			if number > to {
+				break
+			}
+			set[number] = struct{}{}
+		}
+		it.Release()
+		if err := it.Error(); err != nil {
+			return nil, err
+		}
+	}
+	numbers := make([]uint64, 0, len(set))
+	for number := range set {
+		numbers = append(numbers, number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	return numbers, nil
+}
+
+// write indexes the traces of the block with the given number and hash, and
+// extends the indexed range to it. A head block is the new highest indexed
+// block, even if lower than the previous one after a reorg; other blocks are
+// the new lowest indexed block.
+func (index *TraceIndex) write(number uint64, hash common.Hash, traces []*FlatCallTrace, head bool) error {
+	enc := encodeTraceIndexNumber(number)
+	batch := index.db.NewBatch()
+	for _, trace := range traces {
+		from, to := traceParties(trace)
+		for _, addr := range []*common.Address{from, to} {
+			if addr != nil {
+				key := append(append(common.CopyBytes(traceIndexEntryPrefix), addr.Bytes()...), enc...)
+				if err := batch.Put(key, nil); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := batch.Put(append(common.CopyBytes(traceIndexHashPrefix), enc...), hash.Bytes()); err != nil {
+		return err
+	}
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	indexed, tail, top := true, index.tail, index.head
+	switch {
+	case !index.indexed:
+		tail, top = number, number
+	case head:
+		top = number
+	default:
+		tail = number
+	}
+	if err := batch.Put(traceIndexHeadKey, encodeTraceIndexNumber(top)); err != nil {
+		return err
+	}
+	if err := batch.Put(traceIndexTailKey, encodeTraceIndexNumber(tail)); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	index.indexed, index.tail, index.head = indexed, tail, top
+	return nil
+}
+
+// encodeTraceIndexNumber encodes a block number as big endian uint64.
+func encodeTraceIndexNumber(number uint64) []byte {
+	enc := make([]byte, 8)
+	binary.BigEndian.PutUint64(enc, number)
+	return enc
+}
+
+// intersectNumbers returns the numbers present in both ascending lists.
+func intersectNumbers(a, b []uint64) []uint64 {
+	var res []uint64
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			res = append(res, a[i])
+			i, j = i+1, j+1
+		}
+	}
+	return res
+}
+
+// TraceIndexBackend is the backend of the trace indexer, which follows the head
+// of the chain.
+type TraceIndexBackend interface {
+	Backend
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// TraceIndexer maintains a trace index, indexing the new blocks of the chain as
+// they arrive and the older ones in the background, down to the genesis or to
+// the first block whose state is unavailable.
+type TraceIndexer struct {
+	backend TraceIndexBackend
+	api     *API
+	index   *TraceIndex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewTraceIndexer creates an indexer of the traces of the chain, storing the
+// index in the given database.
+func NewTraceIndexer(backend TraceIndexBackend, db ethdb.KeyValueStore) (*TraceIndexer, error) {
+	index, err := NewTraceIndex(db)
+	if err != nil {
+		return nil, err
+	}
+	return &TraceIndexer{
+		backend: backend,
+		api:     NewAPI(backend),
+		index:   index,
+		quit:    make(chan struct{}),
+	}, nil
+}
+
+// Index returns the trace index maintained by the indexer.
+func (indexer *TraceIndexer) Index() *TraceIndex {
+	return indexer.index
+}
+
+// Start implements node.Lifecycle, starting the indexing.
+func (indexer *TraceIndexer) Start() error {
+	indexer.wg.Add(1)
+	go indexer.loop()
+	return nil
+}
+
+// Stop implements node.Lifecycle, stopping the indexing.
+func (indexer *TraceIndexer) Stop() error {
+	close(indexer.quit)
+	indexer.wg.Wait()
+	return nil
+}
+
+// loop indexes the new heads of the chain, and backfills the older blocks while
+// there are no new heads.
+func (indexer *TraceIndexer) loop() {
+	defer indexer.wg.Done()
+
+	heads := make(chan core.ChainHeadEvent, 16)
+	sub := indexer.backend.SubscribeChainHeadEvent(heads)
+	defer sub.Unsubscribe()
+
+	backfill := true
+	for {
+		if err := indexer.indexHead(); err != nil {
+			log.Warn("Failed to index chain head traces", "err", err)
+		}
+		if backfill {
+			done, err := indexer.backfill(traceIndexBackfillBatch)
+			if err != nil {
+				_, tail, _ := indexer.index.span()
+				log.Warn("Stopped backfilling trace index", "tail", tail, "err", err)
+			}
+			backfill = !done && err == nil
+		}
+		if backfill {
+			select {
+			case <-heads:
+			case <-indexer.quit:
+				return
+			default:
+			}
+			continue
+		}
+		select {
+		case <-heads:
+		case <-sub.Err():
+			return
+		case <-indexer.quit:
+			return
+		}
+	}
+}
+
+// indexHead indexes the blocks up to the head of the chain, reindexing those
+// reorged since they were indexed.
+func (indexer *TraceIndexer) indexHead() error {
+	ctx := context.Background()
+	header, err := indexer.backend.HeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if err != nil {
+		return err
+	}
+	head := header.Number.Uint64()
+
+	indexed, tail, number := indexer.index.span()
+	if !indexed {
+		return indexer.indexBlock(head, true)
+	}
+	// Find the highest indexed block still in the chain
+	for number >= tail {
+		if number <= head {
+			canonical, err := indexer.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+			if err != nil {
+				return err
+			}
+			if canonical != nil && canonical.Hash() == indexer.index.hash(number) {
+				break
+			}
+		}
+		if number == 0 {
+			break
+		}
+		number--
+	}
+	if number < tail {
+		number = tail - 1 // Reorged beyond the indexed range, reindex it all
+	}
+	var (
+		start  = time.Now()
+		logged = time.Now()
+	)
+	for next := number + 1; next <= head; next++ {
+		select {
+		case <-indexer.quit:
+			return nil
+		default:
+		}
+		if err := indexer.indexBlock(next, true); err != nil {
+			return err
+		}
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Indexing chain traces", "number", next, "head", head, "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+	}
+	return nil
+}
+
+// backfill indexes up to the given number of blocks below the indexed range,
+// returning whether the genesis was reached.
+func (indexer *TraceIndexer) backfill(blocks int) (bool, error) {
+	for i := 0; i < blocks; i++ {
+		indexed, tail, _ := indexer.index.span()
+		if !indexed {
+			return false, nil
+		}
+		if tail == 0 {
+			return true, nil
+		}
+		select {
+		case <-indexer.quit:
+			return false, nil
+		default:
+		}
+		if err := indexer.indexBlock(tail-1, false); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// indexBlock traces the block with the given number and indexes its traces.
+func (indexer *TraceIndexer) indexBlock(number uint64, head bool) error {
+	ctx := context.Background()
+	block, err := indexer.api.blockByNumber(ctx, rpc.BlockNumber(number))
+	if err != nil {
+		return err
+	}
+	var traces []*FlatCallTrace
+	if number > 0 {
+		tracer := flatCallTracerName
+		results, err := indexer.api.traceBlockThreads(ctx, block, &TraceConfig{Tracer: &tracer}, indexer.api.blockThreads())
+		if err != nil {
+			return err
+		}
+		if traces, err = decodeFlatTraces(block, results); err != nil {
+			return err
+		}
+	}
+	return indexer.index.write(number, block.Hash(), traces, head)
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracers
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testIndexBackend is a test backend following the chain head.
+type testIndexBackend struct {
+	*testBackend
+}
+
+func (b testIndexBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return b.chain.SubscribeChainHeadEvent(ch)
+}
+
+// newTraceTestBackend creates a chain of three blocks, the first and the last
+// calling a contract which calls another address, the middle one a transfer.
+func newTraceTestBackend(t *testing.T) (testIndexBackend, common.Address, common.Address, common.Address) {
+	var (
+		accounts = newAccounts(2)
+		caller   = common.HexToAddress("0xca11")
+		callee   = common.HexToAddress("0xca11ee")
+	)
+	// PUSH1 0 x5 (return and argument areas, value), PUSH20 callee, GAS, CALL, STOP
+	code := append(common.FromHex("60006000600060006000"), 0x73)
+	code = append(append(code, callee.Bytes()...), 0x5a, 0xf1, 0x00)
+
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		accounts[0].addr: {Balance: big.NewInt(params.Ether)},
+		caller:           {Balance: big.NewInt(0), Code: code},
+	}}
+	backend := newTestBackend(t, 3, genesis, func(i int, b *core.BlockGen) {
+		to := caller
+		if i == 1 {
+			to = accounts[1].addr
+		}
+		tx, _ := types.SignTx(types.NewTransaction(uint64(i), to, big.NewInt(1000), 100000, b.BaseFee(), nil), types.HomesteadSigner{}, accounts[0].key)
+		b.AddTx(tx)
+	})
+	return testIndexBackend{backend}, accounts[0].addr, caller, callee
+}
+
+func TestTraceBlockFlat(t *testing.T) {
+	t.Parallel()
+
+	backend, sender, caller, callee := newTraceTestBackend(t)
+	api := NewTraceAPI(NewAPI(backend), nil)
+
+	traces, err := api.Block(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("failed to trace block: %v", err)
+	}
+	if len(traces) != 2 {
+		t.Fatalf("trace count mismatch: have %d, want 2", len(traces))
+	}
+	block := backend.chain.GetBlockByNumber(1)
+	outer, inner := traces[0], traces[1]
+	if outer.Type != "call" || outer.Action.CallType != "call" || *outer.Action.From != sender || *outer.Action.To != caller {
+		t.Errorf("outer call mismatch: %+v", outer.Action)
+	}
+	if outer.Subtraces != 1 || len(outer.TraceAddress) != 0 || outer.Result == nil {
+		t.Errorf("outer call shape mismatch: subtraces %d, address %v, result %v", outer.Subtraces, outer.TraceAddress, outer.Result)
+	}
+	if *outer.BlockHash != block.Hash() || *outer.BlockNumber != 1 || *outer.TransactionHash != block.Transactions()[0].Hash() || *outer.TransactionPosition != 0 {
+		t.Errorf("outer call location mismatch: %+v", outer)
+	}
+	if *inner.Action.From != caller || *inner.Action.To != callee || inner.Subtraces != 0 || !reflect.DeepEqual(inner.TraceAddress, []int{0}) {
+		t.Errorf("inner call mismatch: %+v", inner)
+	}
+	txTraces, err := api.Transaction(context.Background(), block.Transactions()[0].Hash())
+	if err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	if !reflect.DeepEqual(txTraces, traces) {
+		t.Errorf("transaction traces mismatch: have %+v, want %+v", txTraces, traces)
+	}
+}
+
+func TestTraceFilter(t *testing.T) {
+	t.Parallel()
+
+	backend, sender, caller, callee := newTraceTestBackend(t)
+
+	indexer, err := NewTraceIndexer(backend, rawdb.NewMemoryDatabase())
+	if err != nil {
+		t.Fatalf("failed to create indexer: %v", err)
+	}
+	if err := indexer.indexHead(); err != nil {
+		t.Fatalf("failed to index head: %v", err)
+	}
+	if done, err := indexer.backfill(10); err != nil || !done {
+		t.Fatalf("failed to backfill index: done %v, err %v", done, err)
+	}
+	if !indexer.Index().covers(0, 3) {
+		t.Fatalf("index doesn't cover the chain")
+	}
+	if numbers, _ := indexer.Index().blocks([]common.Address{caller}, []common.Address{callee}, 0, 3); !reflect.DeepEqual(numbers, []uint64{1, 3}) {
+		t.Fatalf("indexed blocks mismatch: have %v, want [1 3]", numbers)
+	}
+	var (
+		from, to = rpc.BlockNumber(1), rpc.BlockNumber(3)
+		one      = uint64(1)
+	)
+	tests := []struct {
+		args  TraceFilterArgs
+		count int
+		block uint64
+	}{
+		{TraceFilterArgs{FromBlock: &from, ToBlock: &to}, 5, 1},
+		{TraceFilterArgs{FromBlock: &from, ToBlock: &to, FromAddress: []common.Address{sender}}, 3, 1},
+		{TraceFilterArgs{FromBlock: &from, ToBlock: &to, ToAddress: []common.Address{callee}}, 2, 1},
+		{TraceFilterArgs{FromBlock: &from, ToBlock: &to, FromAddress: []common.Address{caller}, ToAddress: []common.Address{callee}, After: &one}, 1, 3},
+		{TraceFilterArgs{FromBlock: &from, ToBlock: &to, ToAddress: []common.Address{callee}, Count: &one}, 1, 1},
+		{TraceFilterArgs{FromBlock: &from, ToBlock: &to, ToAddress: []common.Address{sender}}, 0, 0},
+	}
+	for _, index := range []*TraceIndex{nil, indexer.Index()} {
+		api := NewTraceAPI(NewAPI(backend), index)
+		for i, tt := range tests {
+			traces, err := api.Filter(context.Background(), tt.args)
+			if err != nil {
+				t.Fatalf("test %d, indexed %v: failed to filter: %v", i, index != nil, err)
+			}
+			if len(traces) != tt.count {
+				t.Fatalf("test %d, indexed %v: trace count mismatch: have %d, want %d", i, index != nil, len(traces), tt.count)
+			}
+			if len(traces) > 0 && *traces[0].BlockNumber != tt.block {
+				t.Fatalf("test %d, indexed %v: first block mismatch: have %d, want %d", i, index != nil, *traces[0].BlockNumber, tt.block)
+			}
+		}
+	}
+}
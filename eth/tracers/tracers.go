@@ -20,6 +20,10 @@ package tracers
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -28,9 +32,10 @@ import (
 // Context contains some contextual infos for a transaction execution that is not
 // available from within the EVM object.
 type Context struct {
-	BlockHash common.Hash // Hash of the block the tx is contained within (zero if dangling tx or call)
-	TxIndex   int         // Index of the transaction within a block (zero if dangling tx or call)
-	TxHash    common.Hash // Hash of the transaction being traced (zero if dangling call)
+	BlockHash   common.Hash // Hash of the block the tx is contained within (zero if dangling tx or call)
+	BlockNumber *big.Int    // Number of the block the tx is contained within (nil if dangling tx or call)
+	TxIndex     int         // Index of the transaction within a block (zero if dangling tx or call)
+	TxHash      common.Hash // Hash of the transaction being traced (zero if dangling call)
 }
 
 // Tracer interface extends vm.EVMLogger and additionally
@@ -44,10 +49,56 @@ type Tracer interface {
 
 type lookupFunc func(string, *Context) (Tracer, error)
 
+// NativeConstructor creates an instance of a native tracer for tracing the
+// transaction of the given context.
+type NativeConstructor func(ctx *Context) (Tracer, error)
+
 var (
 	lookups []lookupFunc
+
+	natives     = make(map[string]NativeConstructor)
+	nativesLock sync.RWMutex
 )
 
+// RegisterNative registers a tracer written in Go under the given name, making
+// it available to all tracing endpoints. Native tracers take precedence over
+// the tracers of the registered lookups. It panics if the name is already
+// taken, and is meant to be called from the init function of the package
+// implementing the tracer.
+func RegisterNative(name string, ctor NativeConstructor) {
+	if err := registerNative(name, ctor); err != nil {
+		panic(err)
+	}
+}
+
+// registerNative registers a native tracer, failing if the name is taken.
+func registerNative(name string, ctor NativeConstructor) error {
+	if name == "" || ctor == nil {
+		return errors.New("native tracer without name or constructor")
+	}
+	nativesLock.Lock()
+	defer nativesLock.Unlock()
+
+	if _, ok := natives[name]; ok {
+		return fmt.Errorf("native tracer %q already registered", name)
+	}
+	natives[name] = ctor
+	return nil
+}
+
+// Natives returns the sorted names of the registered native tracers.
+func Natives() []string {
+	nativesLock.RLock()
+	defer nativesLock.RUnlock()
+
+	names := make([]string, 0, len(natives))
+	for name := range natives {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // RegisterLookup registers a method as a lookup for tracers, meaning that
 // users can invoke a named tracer through that lookup. If 'wildcard' is true,
 // then the lookup will be placed last. This is typically meant for interpreted
@@ -60,9 +111,16 @@ func RegisterLookup(wildcard bool, lookup lookupFunc) {
 	}
 }
 
-// New returns a new instance of a tracer, by iterating through the
-// registered lookups.
+// New returns a new instance of a tracer, either a native one registered under
+// the given name, or one found by iterating through the registered lookups.
 func New(code string, ctx *Context) (Tracer, error) {
+	nativesLock.RLock()
+	ctor := natives[code]
+	nativesLock.RUnlock()
+
+	if ctor != nil {
+		return ctor(ctx)
+	}
 	for _, lookup := range lookups {
 		if tracer, err := lookup(code, ctx); err == nil {
 			return tracer, nil
@@ -109,3 +109,40 @@ func BenchmarkTransactionTrace(b *testing.B) {
 		tracer.Reset()
 	}
 }
+
+func TestRegisterNative(t *testing.T) {
+	newTracer := func(ctx *Context) (Tracer, error) {
+		return logger.NewStructLogger(nil), nil
+	}
+	RegisterNative("testNativeTracer", newTracer)
+
+	if _, err := New("testNativeTracer", new(Context)); err != nil {
+		t.Fatalf("failed to create registered tracer: %v", err)
+	}
+	if err := registerNative("testNativeTracer", newTracer); err == nil {
+		t.Fatalf("duplicate tracer registered")
+	}
+	// A plugin clashing with a registered tracer must register none of its own
+	if _, err := registerNatives(map[string]NativeConstructor{"testPluginTracer": newTracer, "testNativeTracer": newTracer}); err == nil {
+		t.Fatalf("clashing plugin tracers registered")
+	}
+	if _, err := New("testPluginTracer", new(Context)); err == nil {
+		t.Fatalf("tracer of clashing plugin registered")
+	}
+	names, err := registerNatives(map[string]NativeConstructor{"testPluginTracer": newTracer})
+	if err != nil {
+		t.Fatalf("failed to register plugin tracers: %v", err)
+	}
+	if len(names) != 1 || names[0] != "testPluginTracer" {
+		t.Fatalf("registered names mismatch: have %v", names)
+	}
+	var found int
+	for _, name := range Natives() {
+		if name == "testNativeTracer" || name == "testPluginTracer" {
+			found++
+		}
+	}
+	if found != 2 {
+		t.Fatalf("registered tracers missing from %v", Natives())
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package txtracker
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// APIs returns the RPC services of the tracker. They expose every transaction
+// submitted through the RPC API, so they are only served to authenticated
+// callers.
+func (t *TxTracker) APIs() []rpc.API {
+	return []rpc.API{
+		{
+			Namespace:     "txpool",
+			Service:       &TxPoolAPI{t},
+			Authenticated: true,
+		}, {
+			Namespace:     "eth",
+			Service:       &EthAPI{t},
+			Authenticated: true,
+		},
+	}
+}
+
+// TxPoolAPI offers the statuses of the tracked local transactions.
+type TxPoolAPI struct {
+	t *TxTracker
+}
+
+// LocalStatus returns the statuses of the tracked local transactions.
+func (api *TxPoolAPI) LocalStatus() []*TxStatus {
+	return api.t.Statuses()
+}
+
+// EthAPI offers the subscription to the status changes of the tracked local
+// transactions.
+type EthAPI struct {
+	t *TxTracker
+}
+
+// LocalTxStatus creates a subscription that is triggered each time the status
+// of a tracked local transaction changes.
+func (api *EthAPI) LocalTxStatus(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		statuses := make(chan *TxStatus, 128)
+		statusSub := api.t.SubscribeStatus(statuses)
+		defer statusSub.Unsubscribe()
+
+		for {
+			select {
+			case status := <-statuses:
+				notifier.Notify(rpcSub.ID, status)
+			case <-statusSub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
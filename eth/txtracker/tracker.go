@@ -0,0 +1,532 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package txtracker tracks locally submitted transactions until they are
+// included, resubmitting them if they get lost from the pool.
+package txtracker
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	// chainHeadChanSize is the size of channel listening to ChainHeadEvent.
+	chainHeadChanSize = 10
+
+	// dropChanSize is the size of channel listening to DropTxsEvent.
+	dropChanSize = 128
+
+	// recheckInterval is the interval at which the tracked transactions are
+	// checked in the absence of new blocks.
+	recheckInterval = 10 * time.Second
+
+	// maxTracked is the maximum number of transactions tracked at a time.
+	maxTracked = 4096
+)
+
+// Statuses of tracked transactions.
+const (
+	StatusPending   = "pending"   // In the pool
+	StatusDropped   = "dropped"   // Removed from the pool without being included
+	StatusIncluded  = "included"  // Included in a canonical block
+	StatusConfirmed = "confirmed" // Included deep enough to stop tracking
+	StatusReplaced  = "replaced"  // Nonce used by another transaction, tracking stopped
+	StatusBumped    = "bumped"    // Superseded by a resubmission with bumped fees
+)
+
+var (
+	trackedGauge  = metrics.NewRegisteredGauge("txtracker/tracked", nil)
+	resubmitMeter = metrics.NewRegisteredMeter("txtracker/resubmits", nil)
+	bumpMeter     = metrics.NewRegisteredMeter("txtracker/bumps", nil)
+)
+
+// Config contains the settings of the local transaction tracker.
+type Config struct {
+	Resubmit      time.Duration // Delay after which transactions not included are resubmitted (0 = never)
+	FeeBump       uint64        // Percentage the fees are bumped by on resubmission (0 = resubmit as is)
+	MaxFeeBumps   uint64        // Maximum number of fee bumps of a transaction
+	Confirmations uint64        // Number of blocks on top of the inclusion after which tracking stops
+}
+
+// DefaultConfig contains the default settings of the local transaction tracker.
+var DefaultConfig = Config{
+	MaxFeeBumps:   3,
+	Confirmations: 64,
+}
+
+// Chain is the chain the inclusions of the tracked transactions are looked up in.
+type Chain interface {
+	Config() *params.ChainConfig
+	CurrentBlock() *types.Block
+	StateAt(root common.Hash) (*state.StateDB, error)
+	GetTransactionLookup(hash common.Hash) *rawdb.LegacyTxLookupEntry
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// TxPool is the pool the tracked transactions are resubmitted to.
+type TxPool interface {
+	Has(hash common.Hash) bool
+	AddLocal(tx *types.Transaction) error
+	SubscribeDropTxsEvent(ch chan<- core.DropTxsEvent) event.Subscription
+}
+
+// SignFn signs a transaction with the fees bumped on behalf of its sender.
+type SignFn func(from common.Address, tx *types.Transaction) (*types.Transaction, error)
+
+// TxStatus is the status of a tracked transaction.
+type TxStatus struct {
+	Hash        common.Hash     `json:"hash"`
+	From        common.Address  `json:"from"`
+	Nonce       hexutil.Uint64  `json:"nonce"`
+	Status      string          `json:"status"`
+	Reason      string          `json:"reason,omitempty"` // Reason of the drop from the pool
+	BlockHash   *common.Hash    `json:"blockHash,omitempty"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"`
+	Replacement *common.Hash    `json:"replacement,omitempty"` // Transaction with the bumped fees
+	Resubmits   hexutil.Uint64  `json:"resubmits"`
+	FeeBumps    hexutil.Uint64  `json:"feeBumps"`
+}
+
+// trackedTx is a local transaction tracked until its inclusion.
+type trackedTx struct {
+	tx        *types.Transaction
+	from      common.Address
+	status    string
+	reason    string
+	block     *rawdb.LegacyTxLookupEntry // Inclusion of the transaction, if included
+	submitted time.Time                  // Time of the last (re)submission
+	resubmits uint64
+	bumps     uint64
+}
+
+func (t *trackedTx) toStatus() *TxStatus {
+	status := &TxStatus{
+		Hash:      t.tx.Hash(),
+		From:      t.from,
+		Nonce:     hexutil.Uint64(t.tx.Nonce()),
+		Status:    t.status,
+		Reason:    t.reason,
+		Resubmits: hexutil.Uint64(t.resubmits),
+		FeeBumps:  hexutil.Uint64(t.bumps),
+	}
+	if t.block != nil {
+		hash, number := t.block.BlockHash, hexutil.Uint64(t.block.BlockIndex)
+		status.BlockHash, status.BlockNumber = &hash, &number
+	}
+	return status
+}
+
+// TxTracker remembers locally submitted transactions and monitors their
+// inclusion, resubmitting the ones that left the pool without being included
+// and optionally bumping the fees of the ones that got stuck.
+type TxTracker struct {
+	config    Config
+	chain     Chain
+	pool      TxPool
+	broadcast func(types.Transactions) // Rebroadcasts pooled transactions to peers
+	sign      SignFn                   // Signs fee bumps, nil if disabled
+	signer    types.Signer
+
+	mu      sync.Mutex
+	tracked map[common.Hash]*trackedTx
+	queued  []*TxStatus // Status changes sent once the lock is released
+
+	feed  event.Feed
+	scope event.SubscriptionScope
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a tracker of the local transactions added to the pool. Fees are
+// only bumped if a sign function is given.
+func New(config Config, chain Chain, pool TxPool, broadcast func(types.Transactions), sign SignFn) *TxTracker {
+	return &TxTracker{
+		config:    config,
+		chain:     chain,
+		pool:      pool,
+		broadcast: broadcast,
+		sign:      sign,
+		signer:    types.LatestSigner(chain.Config()),
+		tracked:   make(map[common.Hash]*trackedTx),
+		quit:      make(chan struct{}),
+	}
+}
+
+// Start implements node.Lifecycle, starting the monitoring of the tracked
+// transactions.
+func (t *TxTracker) Start() error {
+	// Subscribe before returning, so that no event sent after Start is missed
+	heads := make(chan core.ChainHeadEvent, chainHeadChanSize)
+	headSub := t.chain.SubscribeChainHeadEvent(heads)
+	drops := make(chan core.DropTxsEvent, dropChanSize)
+	dropSub := t.pool.SubscribeDropTxsEvent(drops)
+
+	t.wg.Add(2)
+	go t.loop(heads, headSub)
+	go t.dropLoop(drops, dropSub)
+	log.Info("Started local transaction tracker", "resubmit", t.config.Resubmit, "feebump", t.config.FeeBump)
+	return nil
+}
+
+// Stop implements node.Lifecycle, terminating the monitoring.
+func (t *TxTracker) Stop() error {
+	close(t.quit)
+	t.wg.Wait()
+	t.scope.Close()
+	return nil
+}
+
+// Track starts tracking a local transaction that was added to the pool.
+func (t *TxTracker) Track(tx *types.Transaction) {
+	from, err := types.Sender(t.signer, tx)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.unlock()
+
+	if _, ok := t.tracked[tx.Hash()]; ok {
+		return
+	}
+	if len(t.tracked) >= maxTracked {
+		log.Warn("Too many tracked local transactions", "hash", tx.Hash(), "limit", maxTracked)
+		return
+	}
+	t.add(&trackedTx{tx: tx, from: from, status: StatusPending, submitted: time.Now()})
+}
+
+// Statuses returns the statuses of the tracked transactions.
+func (t *TxTracker) Statuses() []*TxStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	statuses := make([]*TxStatus, 0, len(t.tracked))
+	for _, tracked := range t.tracked {
+		statuses = append(statuses, tracked.toStatus())
+	}
+	return statuses
+}
+
+// SubscribeStatus subscribes to the status changes of the tracked transactions.
+func (t *TxTracker) SubscribeStatus(ch chan<- *TxStatus) event.Subscription {
+	return t.scope.Track(t.feed.Subscribe(ch))
+}
+
+// add starts tracking a transaction.
+//
+// Note, this method assumes the tracker lock is held!
+func (t *TxTracker) add(tracked *trackedTx) {
+	t.tracked[tracked.tx.Hash()] = tracked
+	trackedGauge.Update(int64(len(t.tracked)))
+	t.queued = append(t.queued, tracked.toStatus())
+}
+
+// remove stops tracking a transaction, announcing its final status.
+//
+// Note, this method assumes the tracker lock is held!
+func (t *TxTracker) remove(tracked *trackedTx, status *TxStatus) {
+	delete(t.tracked, tracked.tx.Hash())
+	trackedGauge.Update(int64(len(t.tracked)))
+	t.queued = append(t.queued, status)
+}
+
+// update sets the status of a tracked transaction, announcing it if changed.
+//
+// Note, this method assumes the tracker lock is held!
+func (t *TxTracker) update(tracked *trackedTx, status, reason string) {
+	if tracked.status == status && tracked.reason == reason {
+		return
+	}
+	tracked.status, tracked.reason = status, reason
+	t.queued = append(t.queued, tracked.toStatus())
+}
+
+// unlock releases the tracker lock and announces the status changes made while
+// holding it, so that slow subscribers don't hold up the tracker.
+func (t *TxTracker) unlock() {
+	queued := t.queued
+	t.queued = nil
+	t.mu.Unlock()
+
+	for _, status := range queued {
+		t.feed.Send(status)
+	}
+}
+
+// active reports whether the transaction is still tracked.
+func (t *TxTracker) active(tracked *trackedTx) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.tracked[tracked.tx.Hash()] == tracked
+}
+
+// loop checks the tracked transactions on every new block and periodically.
+func (t *TxTracker) loop(heads <-chan core.ChainHeadEvent, sub event.Subscription) {
+	defer t.wg.Done()
+	defer sub.Unsubscribe()
+
+	recheck := time.NewTicker(recheckInterval)
+	defer recheck.Stop()
+
+	for {
+		select {
+		case <-heads:
+			t.check()
+		case <-recheck.C:
+			t.check()
+		case <-sub.Err():
+			return
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// dropLoop records the drops of tracked transactions from the pool. It runs
+// apart from the checks as the pool announces the drops caused by the fee bumps
+// of the checks synchronously.
+func (t *TxTracker) dropLoop(drops <-chan core.DropTxsEvent, sub event.Subscription) {
+	defer t.wg.Done()
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-drops:
+			t.mu.Lock()
+			for _, drop := range ev.Txs {
+				tracked := t.tracked[drop.Tx.Hash()]
+				if tracked == nil {
+					continue
+				}
+				switch {
+				case drop.Reason == core.TxDropEvicted || drop.Reason == core.TxDropBanned:
+					// Removed by the operator, don't bring it back
+					tracked.status, tracked.reason = StatusDropped, drop.Reason
+					t.remove(tracked, tracked.toStatus())
+				case tracked.status == StatusPending:
+					t.update(tracked, StatusDropped, drop.Reason)
+				}
+			}
+			t.unlock()
+		case <-sub.Err():
+			return
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// check updates the statuses of the tracked transactions against the current
+// head and resubmits the ones that are due.
+func (t *TxTracker) check() {
+	head := t.chain.CurrentBlock()
+	statedb, err := t.chain.StateAt(head.Root())
+	if err != nil {
+		log.Warn("Failed to check local transactions", "err", err)
+		return
+	}
+	var due []*trackedTx
+
+	t.mu.Lock()
+	for hash, tracked := range t.tracked {
+		// Track included transactions until they are deep enough to not be
+		// reorged out any more
+		if lookup := t.chain.GetTransactionLookup(hash); lookup != nil {
+			if tracked.block == nil || tracked.block.BlockHash != lookup.BlockHash {
+				tracked.block, tracked.status, tracked.reason = lookup, StatusIncluded, ""
+				t.queued = append(t.queued, tracked.toStatus())
+			}
+			if head.NumberU64() >= lookup.BlockIndex+t.config.Confirmations {
+				tracked.status = StatusConfirmed
+				t.remove(tracked, tracked.toStatus())
+			}
+			continue
+		}
+		tracked.block = nil
+
+		// Stop tracking if the nonce was used by another transaction
+		if statedb.GetNonce(tracked.from) > tracked.tx.Nonce() {
+			tracked.status, tracked.reason = StatusReplaced, ""
+			t.remove(tracked, tracked.toStatus())
+			continue
+		}
+		if t.pool.Has(hash) {
+			t.update(tracked, StatusPending, "")
+		} else if tracked.status != StatusDropped {
+			t.update(tracked, StatusDropped, "")
+		}
+		if t.config.Resubmit > 0 && time.Since(tracked.submitted) >= t.config.Resubmit {
+			due = append(due, tracked)
+		}
+	}
+	t.unlock()
+
+	// Resubmit outside of the lock, the pool announces drops synchronously
+	for _, tracked := range due {
+		t.resubmit(tracked)
+	}
+}
+
+// resubmit bumps the fees of a transaction that is due if enabled, otherwise it
+// adds the transaction back to the pool or rebroadcasts it if still pooled.
+func (t *TxTracker) resubmit(tracked *trackedTx) {
+	if !t.active(tracked) {
+		return // stopped tracking meanwhile
+	}
+	if t.sign != nil && t.config.FeeBump > 0 && tracked.bumps < t.config.MaxFeeBumps {
+		if t.bump(tracked) != nil {
+			return
+		}
+	}
+	var (
+		pooled = t.pool.Has(tracked.tx.Hash())
+		err    error
+	)
+	if pooled {
+		t.broadcast(types.Transactions{tracked.tx})
+	} else {
+		err = t.pool.AddLocal(tracked.tx)
+	}
+	t.mu.Lock()
+	defer t.unlock()
+
+	if t.tracked[tracked.tx.Hash()] != tracked {
+		return // stopped tracking meanwhile
+	}
+	tracked.submitted = time.Now()
+	switch {
+	case err == nil:
+		tracked.resubmits++
+		resubmitMeter.Mark(1)
+		tracked.status, tracked.reason = StatusPending, ""
+		t.queued = append(t.queued, tracked.toStatus())
+
+	case errors.Is(err, core.ErrNonceTooLow):
+		tracked.status, tracked.reason = StatusReplaced, ""
+		t.remove(tracked, tracked.toStatus())
+
+	default:
+		log.Debug("Failed to resubmit local transaction", "hash", tracked.tx.Hash(), "err", err)
+	}
+}
+
+// bump resubmits a transaction with its fees bumped, replacing the tracked one
+// by the bumped one. Nil is returned if the fees could not be bumped. Nothing
+// is resubmitted if the transaction stopped being tracked.
+func (t *TxTracker) bump(tracked *trackedTx) *types.Transaction {
+	unsigned := bumpFees(tracked.tx, t.config.FeeBump)
+	if unsigned == nil {
+		return nil
+	}
+	bumped, err := t.sign(tracked.from, unsigned)
+	if err != nil {
+		log.Debug("Failed to sign fee bump", "hash", tracked.tx.Hash(), "err", err)
+		return nil
+	}
+	if !t.active(tracked) {
+		return bumped // stopped tracking meanwhile, e.g. evicted
+	}
+	if err := t.pool.AddLocal(bumped); err != nil {
+		log.Debug("Failed to add fee bump", "hash", tracked.tx.Hash(), "err", err)
+		return nil
+	}
+	bumpMeter.Mark(1)
+
+	t.mu.Lock()
+	defer t.unlock()
+
+	if t.tracked[tracked.tx.Hash()] != tracked {
+		return bumped // stopped tracking while adding the bump
+	}
+	status := tracked.toStatus()
+	status.Status, status.Reason = StatusBumped, ""
+	replacement := bumped.Hash()
+	status.Replacement = &replacement
+	t.remove(tracked, status)
+
+	t.add(&trackedTx{
+		tx:        bumped,
+		from:      tracked.from,
+		status:    StatusPending,
+		submitted: time.Now(),
+		resubmits: tracked.resubmits + 1,
+		bumps:     tracked.bumps + 1,
+	})
+	return bumped
+}
+
+// bumpFees returns an unsigned copy of the transaction with its fees raised by
+// the given percentage, rounded up. Nil is returned for transaction types that
+// can't be bumped.
+func bumpFees(tx *types.Transaction, percent uint64) *types.Transaction {
+	bump := func(fee *big.Int) *big.Int {
+		bumped := new(big.Int).Mul(fee, new(big.Int).SetUint64(100+percent))
+		bumped.Add(bumped, big.NewInt(99))
+		return bumped.Div(bumped, big.NewInt(100))
+	}
+	switch tx.Type() {
+	case types.LegacyTxType:
+		return types.NewTx(&types.LegacyTx{
+			Nonce:    tx.Nonce(),
+			GasPrice: bump(tx.GasPrice()),
+			Gas:      tx.Gas(),
+			To:       tx.To(),
+			Value:    tx.Value(),
+			Data:     tx.Data(),
+		})
+	case types.AccessListTxType:
+		return types.NewTx(&types.AccessListTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasPrice:   bump(tx.GasPrice()),
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+		})
+	case types.DynamicFeeTxType:
+		return types.NewTx(&types.DynamicFeeTx{
+			ChainID:    tx.ChainId(),
+			Nonce:      tx.Nonce(),
+			GasTipCap:  bump(tx.GasTipCap()),
+			GasFeeCap:  bump(tx.GasFeeCap()),
+			Gas:        tx.Gas(),
+			To:         tx.To(),
+			Value:      tx.Value(),
+			Data:       tx.Data(),
+			AccessList: tx.AccessList(),
+		})
+	default:
+		return nil
+	}
+}
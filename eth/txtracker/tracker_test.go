@@ -0,0 +1,261 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package txtracker
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var testKey, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+
+type testChain struct {
+	head    *types.Block
+	state   *state.StateDB
+	lookups map[common.Hash]*rawdb.LegacyTxLookupEntry
+	feed    event.Feed
+}
+
+func (c *testChain) Config() *params.ChainConfig { return params.TestChainConfig }
+func (c *testChain) CurrentBlock() *types.Block  { return c.head }
+
+func (c *testChain) StateAt(common.Hash) (*state.StateDB, error) {
+	return c.state, nil
+}
+
+func (c *testChain) GetTransactionLookup(hash common.Hash) *rawdb.LegacyTxLookupEntry {
+	return c.lookups[hash]
+}
+
+func (c *testChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return c.feed.Subscribe(ch)
+}
+
+type testPool struct {
+	txs  map[common.Hash]*types.Transaction
+	feed event.Feed
+}
+
+func (p *testPool) Has(hash common.Hash) bool { return p.txs[hash] != nil }
+
+func (p *testPool) AddLocal(tx *types.Transaction) error {
+	p.txs[tx.Hash()] = tx
+	return nil
+}
+
+func (p *testPool) SubscribeDropTxsEvent(ch chan<- core.DropTxsEvent) event.Subscription {
+	return p.feed.Subscribe(ch)
+}
+
+func newTestTracker(t *testing.T, config Config, sign SignFn) (*TxTracker, *testChain, *testPool) {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	chain := &testChain{
+		head:    types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)}),
+		state:   statedb,
+		lookups: make(map[common.Hash]*rawdb.LegacyTxLookupEntry),
+	}
+	pool := &testPool{txs: make(map[common.Hash]*types.Transaction)}
+	return New(config, chain, pool, func(types.Transactions) {}, sign), chain, pool
+}
+
+func newTestTx(t *testing.T, nonce uint64) *types.Transaction {
+	tx, err := types.SignNewTx(testKey, types.LatestSigner(params.TestChainConfig), &types.DynamicFeeTx{
+		ChainID:   params.TestChainConfig.ChainID,
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(10),
+		Gas:       21000,
+		To:        &common.Address{},
+	})
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return tx
+}
+
+func checkStatus(t *testing.T, tracker *TxTracker, hash common.Hash, want string) {
+	t.Helper()
+	for _, status := range tracker.Statuses() {
+		if status.Hash == hash {
+			if status.Status != want {
+				t.Fatalf("status mismatch: have %s, want %s", status.Status, want)
+			}
+			return
+		}
+	}
+	if want != "" {
+		t.Fatalf("transaction %x not tracked, want %s", hash, want)
+	}
+}
+
+// Tests that tracked transactions are resubmitted after leaving the pool, and
+// tracked until enough blocks are built on top of their inclusion.
+func TestTrackerResubmitAndConfirm(t *testing.T) {
+	tracker, chain, pool := newTestTracker(t, Config{Resubmit: time.Nanosecond, Confirmations: 2}, nil)
+
+	tx := newTestTx(t, 0)
+	pool.AddLocal(tx)
+	tracker.Track(tx)
+	checkStatus(t, tracker, tx.Hash(), StatusPending)
+
+	// Drop the transaction from the pool and ensure it's added back
+	delete(pool.txs, tx.Hash())
+	tracker.check()
+	if !pool.Has(tx.Hash()) {
+		t.Fatalf("dropped transaction not resubmitted")
+	}
+	checkStatus(t, tracker, tx.Hash(), StatusPending)
+
+	// Include the transaction and ensure it's tracked until confirmed
+	chain.lookups[tx.Hash()] = &rawdb.LegacyTxLookupEntry{BlockHash: common.Hash{0x01}, BlockIndex: 1}
+	tracker.check()
+	checkStatus(t, tracker, tx.Hash(), StatusIncluded)
+
+	chain.head = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(3)})
+	tracker.check()
+	checkStatus(t, tracker, tx.Hash(), "")
+
+	// Ensure transactions with their nonce used up stop being tracked
+	stale := newTestTx(t, 1)
+	tracker.Track(stale)
+	chain.state.SetNonce(crypto.PubkeyToAddress(testKey.PublicKey), 2)
+	tracker.check()
+	checkStatus(t, tracker, stale.Hash(), "")
+}
+
+// Tests that stuck transactions get their fees bumped and are replaced in the
+// tracker by the bumped ones, up to the maximum number of bumps.
+func TestTrackerFeeBump(t *testing.T) {
+	sign := func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		return types.SignTx(tx, types.LatestSigner(params.TestChainConfig), testKey)
+	}
+	tracker, _, pool := newTestTracker(t, Config{Resubmit: time.Nanosecond, FeeBump: 10, MaxFeeBumps: 1}, sign)
+
+	tx := newTestTx(t, 0)
+	pool.AddLocal(tx)
+	tracker.Track(tx)
+
+	statuses := make(chan *TxStatus, 16)
+	sub := tracker.SubscribeStatus(statuses)
+	defer sub.Unsubscribe()
+
+	tracker.check()
+	checkStatus(t, tracker, tx.Hash(), "")
+
+	status := <-statuses
+	if status.Status != StatusBumped || status.Replacement == nil {
+		t.Fatalf("bump not announced: %+v", status)
+	}
+	bumped := pool.txs[*status.Replacement]
+	if bumped == nil {
+		t.Fatalf("bumped transaction not pooled")
+	}
+	if bumped.GasTipCap().Uint64() != 2 || bumped.GasFeeCap().Uint64() != 11 {
+		t.Fatalf("bumped fees mismatch: have tip %v cap %v, want tip 2 cap 11", bumped.GasTipCap(), bumped.GasFeeCap())
+	}
+	checkStatus(t, tracker, bumped.Hash(), StatusPending)
+
+	// Ensure the maximum number of bumps is respected
+	tracker.check()
+	checkStatus(t, tracker, bumped.Hash(), StatusPending)
+	if len(pool.txs) != 2 {
+		t.Fatalf("pooled transaction count mismatch: have %d, want 2", len(pool.txs))
+	}
+}
+
+// Tests that transactions evicted or banned by the operator stop being tracked
+// and are neither resubmitted nor bumped.
+func TestTrackerEvicted(t *testing.T) {
+	sign := func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		return types.SignTx(tx, types.LatestSigner(params.TestChainConfig), testKey)
+	}
+	tracker, _, pool := newTestTracker(t, Config{Resubmit: time.Nanosecond, FeeBump: 10, MaxFeeBumps: 1}, sign)
+	tracker.Start()
+	defer tracker.Stop()
+
+	tx := newTestTx(t, 0)
+	pool.AddLocal(tx)
+	tracker.Track(tx)
+	tracked := tracker.tracked[tx.Hash()]
+
+	statuses := make(chan *TxStatus, 16)
+	sub := tracker.SubscribeStatus(statuses)
+	defer sub.Unsubscribe()
+
+	delete(pool.txs, tx.Hash())
+	pool.feed.Send(core.DropTxsEvent{Txs: []core.DroppedTx{{Tx: tx, Reason: core.TxDropEvicted}}})
+	select {
+	case status := <-statuses:
+		if status.Status != StatusDropped || status.Reason != core.TxDropEvicted {
+			t.Fatalf("eviction status mismatch: %+v", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("eviction not announced")
+	}
+	checkStatus(t, tracker, tx.Hash(), "")
+
+	// Neither a pending check nor a late bump may bring it back
+	tracker.check()
+	tracker.bump(tracked)
+	if len(pool.txs) != 0 {
+		t.Fatalf("evicted transaction resubmitted: %d pooled", len(pool.txs))
+	}
+}
+
+// Tests that status changes are announced without holding the tracker lock, so
+// that a slow subscriber doesn't block the tracker.
+func TestTrackerSlowSubscriber(t *testing.T) {
+	tracker, _, pool := newTestTracker(t, DefaultConfig, nil)
+
+	statuses := make(chan *TxStatus)
+	sub := tracker.SubscribeStatus(statuses)
+	defer sub.Unsubscribe()
+
+	tx := newTestTx(t, 0)
+	pool.AddLocal(tx)
+	go tracker.Track(tx)
+
+	// The announcement is blocked, the tracker must remain usable meanwhile
+	done := make(chan struct{})
+	go func() {
+		for len(tracker.Statuses()) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("tracker blocked by subscriber")
+	}
+	if status := <-statuses; status.Hash != tx.Hash() || status.Status != StatusPending {
+		t.Fatalf("status mismatch: %+v", status)
+	}
+}
@@ -35,6 +35,7 @@ import (
 	"github.com/syndtr/goleveldb/leveldb/errors"
 	"github.com/syndtr/goleveldb/leveldb/filter"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/storage"
 	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
@@ -60,8 +61,9 @@ const (
 // functionality it also supports batch writes and iterating over the keyspace in
 // binary-alphabetical order.
 type Database struct {
-	fn string      // filename for reporting
-	db *leveldb.DB // LevelDB instance
+	fn   string          // filename for reporting
+	db   *leveldb.DB     // LevelDB instance
+	stor storage.Storage // Storage of the instance, throttling the compactions
 
 	compTimeMeter      metrics.Meter // Meter for measuring the total time spent in database compaction
 	compReadMeter      metrics.Meter // Meter for measuring the data read during compaction
@@ -85,7 +87,30 @@ type Database struct {
 // New returns a wrapped LevelDB object. The namespace is the prefix that the
 // metrics reporting should use for surfacing internal stats.
 func New(file string, cache int, handles int, namespace string, readonly bool) (*Database, error) {
-	return NewCustom(file, namespace, func(options *opt.Options) {
+	return NewCustom(file, namespace, sizeOptions(cache, handles, readonly))
+}
+
+// NewShared returns a wrapped LevelDB object opened read-only without locking the
+// database, which another process may be writing to. The database is a snapshot
+// of the last state flushed by the writer and needs to be reopened periodically
+// to follow it, as the compactions of the writer delete the tables it reads.
+func NewShared(file string, cache int, handles int, namespace string) (*Database, error) {
+	options := configureOptions(sizeOptions(cache, handles, true))
+	logger := log.New("database", file)
+	logger.Debug("Opening shared database", "cache", common.StorageSize(options.GetBlockCacheCapacity()), "handles", options.GetOpenFilesCacheCapacity())
+
+	options.ErrorIfMissing = true
+	db, err := leveldb.Open(sharedStorage{path: file}, options)
+	if err != nil {
+		return nil, err
+	}
+	return newDatabase(file, namespace, db, nil, logger), nil
+}
+
+// sizeOptions returns the customization of the leveldb options applying the given
+// cache and file handle allowances.
+func sizeOptions(cache int, handles int, readonly bool) func(options *opt.Options) {
+	return func(options *opt.Options) {
 		// Ensure we have some minimal caching and file guarantees
 		if cache < minCache {
 			cache = minCache
@@ -100,7 +125,7 @@ func New(file string, cache int, handles int, namespace string, readonly bool) (
 		if readonly {
 			options.ReadOnly = true
 		}
-	})
+	}
 }
 
 // NewCustom returns a wrapped LevelDB object. The namespace is the prefix that the
@@ -117,36 +142,48 @@ func NewCustom(file string, namespace string, customize func(options *opt.Option
 	logger.Info("Allocated cache and file handles", logCtx...)
 
 	// Open the db and recover any potential corruptions
-	db, err := leveldb.OpenFile(file, options)
+	stor, err := storage.OpenFile(file, options.GetReadOnly())
+	if err != nil {
+		return nil, err
+	}
+	db, err := leveldb.Open(throttledStorage{stor}, options)
 	if _, corrupted := err.(*errors.ErrCorrupted); corrupted {
-		db, err = leveldb.RecoverFile(file, nil)
+		db, err = leveldb.Recover(throttledStorage{stor}, nil)
 	}
 	if err != nil {
+		stor.Close()
 		return nil, err
 	}
+	return newDatabase(file, namespace, db, stor, logger), nil
+}
+
+// newDatabase wraps an opened leveldb instance, registering its metrics and
+// starting their collection.
+func newDatabase(file string, namespace string, db *leveldb.DB, stor storage.Storage, logger log.Logger) *Database {
 	// Assemble the wrapper with all the registered metrics
 	ldb := &Database{
 		fn:       file,
 		db:       db,
+		stor:     stor,
 		log:      logger,
 		quitChan: make(chan chan error),
 	}
-	ldb.compTimeMeter = metrics.NewRegisteredMeter(namespace+"compact/time", nil)
-	ldb.compReadMeter = metrics.NewRegisteredMeter(namespace+"compact/input", nil)
-	ldb.compWriteMeter = metrics.NewRegisteredMeter(namespace+"compact/output", nil)
-	ldb.diskSizeGauge = metrics.NewRegisteredGauge(namespace+"disk/size", nil)
-	ldb.diskReadMeter = metrics.NewRegisteredMeter(namespace+"disk/read", nil)
-	ldb.diskWriteMeter = metrics.NewRegisteredMeter(namespace+"disk/write", nil)
-	ldb.writeDelayMeter = metrics.NewRegisteredMeter(namespace+"compact/writedelay/duration", nil)
-	ldb.writeDelayNMeter = metrics.NewRegisteredMeter(namespace+"compact/writedelay/counter", nil)
-	ldb.memCompGauge = metrics.NewRegisteredGauge(namespace+"compact/memory", nil)
-	ldb.level0CompGauge = metrics.NewRegisteredGauge(namespace+"compact/level0", nil)
-	ldb.nonlevel0CompGauge = metrics.NewRegisteredGauge(namespace+"compact/nonlevel0", nil)
-	ldb.seekCompGauge = metrics.NewRegisteredGauge(namespace+"compact/seek", nil)
+	ldb.compTimeMeter = metrics.GetOrRegisterMeter(namespace+"compact/time", nil)
+	ldb.compReadMeter = metrics.GetOrRegisterMeter(namespace+"compact/input", nil)
+	ldb.compWriteMeter = metrics.GetOrRegisterMeter(namespace+"compact/output", nil)
+	ldb.diskSizeGauge = metrics.GetOrRegisterGauge(namespace+"disk/size", nil)
+	ldb.diskReadMeter = metrics.GetOrRegisterMeter(namespace+"disk/read", nil)
+	ldb.diskWriteMeter = metrics.GetOrRegisterMeter(namespace+"disk/write", nil)
+	ldb.writeDelayMeter = metrics.GetOrRegisterMeter(namespace+"compact/writedelay/duration", nil)
+	ldb.writeDelayNMeter = metrics.GetOrRegisterMeter(namespace+"compact/writedelay/counter", nil)
+	ldb.memCompGauge = metrics.GetOrRegisterGauge(namespace+"compact/memory", nil)
+	ldb.level0CompGauge = metrics.GetOrRegisterGauge(namespace+"compact/level0", nil)
+	ldb.nonlevel0CompGauge = metrics.GetOrRegisterGauge(namespace+"compact/nonlevel0", nil)
+	ldb.seekCompGauge = metrics.GetOrRegisterGauge(namespace+"compact/seek", nil)
 
 	// Start up the metrics gathering and return
 	go ldb.meter(metricsGatheringInterval)
-	return ldb, nil
+	return ldb
 }
 
 // configureOptions sets some default options, then runs the provided setter.
@@ -177,7 +214,14 @@ func (db *Database) Close() error {
 		}
 		db.quitChan = nil
 	}
-	return db.db.Close()
+	// The storage is not owned by the db instance, close it separately
+	err := db.db.Close()
+	if db.stor != nil {
+		if serr := db.stor.Close(); err == nil {
+			err = serr
+		}
+	}
+	return err
 }
 
 // Has retrieves if a key is present in the key-value store.
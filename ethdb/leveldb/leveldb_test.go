@@ -17,12 +17,17 @@
 package leveldb
 
 import (
+	"bytes"
+	"crypto/rand"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/ethdb/dbtest"
 	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
 	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/syndtr/goleveldb/leveldb/util"
 )
 
 func TestLevelDB(t *testing.T) {
@@ -38,3 +43,121 @@ func TestLevelDB(t *testing.T) {
 		})
 	})
 }
+
+// Tests that compactions keep working through the throttled storage, taking
+// about as long as the rate limit mandates.
+func TestCompactionThrottling(t *testing.T) {
+	db, err := leveldb.Open(throttledStorage{storage.NewMemStorage()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	values := make([][]byte, 128)
+	for i := range values {
+		values[i] = make([]byte, 1024)
+		rand.Read(values[i])
+		if err := db.Put([]byte{byte(i)}, values[i], nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	SetCompactionRate(64 * 1024)
+	defer SetCompactionRate(0)
+
+	if rate := CompactionRate(); rate != 64*1024 {
+		t.Fatalf("compaction rate mismatch: have %d, want %d", rate, 64*1024)
+	}
+	start := time.Now()
+	if err := db.CompactRange(util.Range{}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("compaction not throttled: took %v for 128KB at 64KB/s", elapsed)
+	}
+	for i := 0; i < 128; i++ {
+		if have, err := db.Get([]byte{byte(i)}, nil); err != nil || !bytes.Equal(have, values[i]) {
+			t.Fatalf("item %d mismatch after compaction: %v", i, err)
+		}
+	}
+}
+
+// Tests that flushing the memory tables into level 0 isn't throttled, so that
+// a low compaction rate doesn't stall the writes.
+func TestCompactionThrottlingSkipsFlush(t *testing.T) {
+	db, err := leveldb.Open(throttledStorage{storage.NewMemStorage()}, &opt.Options{WriteBuffer: 32 * 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	SetCompactionRate(1024)
+	defer SetCompactionRate(0)
+
+	// Write enough to flush a few memory tables, which would take minutes if
+	// throttled
+	value := make([]byte, 1024)
+	rand.Read(value)
+
+	start := time.Now()
+	for i := 0; i < 128; i++ {
+		if err := db.Put([]byte{byte(i)}, value, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Fatalf("memory table flushes throttled: took %v for 128KB", elapsed)
+	}
+	var stats leveldb.DBStats
+	if err := db.Stats(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.LevelTablesCounts) == 0 || stats.LevelTablesCounts[0] == 0 {
+		t.Fatalf("no memory table flushed: %v", stats.LevelTablesCounts)
+	}
+}
+
+// Tests that a database can be opened in shared mode while another instance is
+// writing to it, seeing the writes flushed before it was opened.
+func TestSharedDatabase(t *testing.T) {
+	dir := t.TempDir()
+
+	writer, err := New(dir, 0, 0, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	if err := writer.Put([]byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := NewShared(dir, 0, 0, "")
+	if err != nil {
+		t.Fatalf("failed to open shared database: %v", err)
+	}
+	if have, err := reader.Get([]byte("a")); err != nil || string(have) != "1" {
+		t.Fatalf("flushed item mismatch: have %q, %v", have, err)
+	}
+	if err := reader.Put([]byte("b"), []byte("2")); err == nil {
+		t.Fatal("shared database accepted a write")
+	}
+	// Write some more, the open instance is a snapshot but a reopened one sees it
+	if err := writer.Put([]byte("b"), []byte("2")); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := reader.Has([]byte("b")); ok {
+		t.Fatal("shared database snapshot changed")
+	}
+	reader.Close()
+
+	if reader, err = NewShared(dir, 0, 0, ""); err != nil {
+		t.Fatalf("failed to reopen shared database: %v", err)
+	}
+	defer reader.Close()
+
+	if have, err := reader.Get([]byte("b")); err != nil || string(have) != "2" {
+		t.Fatalf("item written since mismatch: have %q, %v", have, err)
+	}
+	if _, err := NewShared(t.TempDir(), 0, 0, ""); err == nil {
+		t.Fatal("opened missing shared database")
+	}
+}
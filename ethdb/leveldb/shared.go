@@ -0,0 +1,172 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !js
+// +build !js
+
+package leveldb
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+// errSharedStorage is returned when attempting to modify a database opened in
+// shared mode.
+var errSharedStorage = errors.New("leveldb: shared storage is read-only")
+
+// sharedStorage is a read-only leveldb storage that doesn't lock the database
+// directory, allowing it to be opened while another process is writing to it.
+// The opened database is a snapshot of the last state flushed by the writer.
+// The writer's compactions delete the tables it's built on, so the snapshot
+// needs to be reopened periodically.
+type sharedStorage struct {
+	path string
+}
+
+// Lock implements storage.Storage, not locking anything.
+func (s sharedStorage) Lock() (storage.Locker, error) {
+	return sharedLocker{}, nil
+}
+
+// Log implements storage.Storage, discarding the log message.
+func (s sharedStorage) Log(str string) {}
+
+// SetMeta implements storage.Storage, refusing to modify the database.
+func (s sharedStorage) SetMeta(fd storage.FileDesc) error {
+	return errSharedStorage
+}
+
+// GetMeta implements storage.Storage, returning the manifest file the CURRENT
+// file points to.
+func (s sharedStorage) GetMeta() (storage.FileDesc, error) {
+	blob, err := ioutil.ReadFile(filepath.Join(s.path, "CURRENT"))
+	if err != nil {
+		return storage.FileDesc{}, err
+	}
+	name := strings.TrimSuffix(string(blob), "\n")
+	fd, ok := parseStorageName(name)
+	if !ok || fd.Type != storage.TypeManifest || len(name) == len(blob) {
+		return storage.FileDesc{}, &storage.ErrCorrupted{Fd: fd, Err: fmt.Errorf("corrupted CURRENT file: %q", blob)}
+	}
+	if _, err := os.Stat(filepath.Join(s.path, name)); err != nil {
+		return storage.FileDesc{}, err
+	}
+	return fd, nil
+}
+
+// List implements storage.Storage, returning the files of the given types.
+func (s sharedStorage) List(ft storage.FileType) ([]storage.FileDesc, error) {
+	names, err := ioutil.ReadDir(s.path)
+	if err != nil {
+		return nil, err
+	}
+	var fds []storage.FileDesc
+	for _, info := range names {
+		if fd, ok := parseStorageName(info.Name()); ok && fd.Type&ft != 0 {
+			fds = append(fds, fd)
+		}
+	}
+	return fds, nil
+}
+
+// Open implements storage.Storage, opening the file of the given descriptor.
+func (s sharedStorage) Open(fd storage.FileDesc) (storage.Reader, error) {
+	f, err := os.Open(filepath.Join(s.path, storageName(fd)))
+	if err != nil && fd.Type == storage.TypeTable && os.IsNotExist(err) {
+		// Tables of old databases may still have the legacy extension
+		if f, err := os.Open(filepath.Join(s.path, fmt.Sprintf("%06d.sst", fd.Num))); err == nil {
+			return f, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Create implements storage.Storage, refusing to modify the database.
+func (s sharedStorage) Create(fd storage.FileDesc) (storage.Writer, error) {
+	return nil, errSharedStorage
+}
+
+// Remove implements storage.Storage, refusing to modify the database.
+func (s sharedStorage) Remove(fd storage.FileDesc) error {
+	return errSharedStorage
+}
+
+// Rename implements storage.Storage, refusing to modify the database.
+func (s sharedStorage) Rename(oldfd, newfd storage.FileDesc) error {
+	return errSharedStorage
+}
+
+// Close implements storage.Storage, the files being closed by the database.
+func (s sharedStorage) Close() error {
+	return nil
+}
+
+// sharedLocker is the no-op lock of a shared storage.
+type sharedLocker struct{}
+
+// Unlock implements storage.Locker.
+func (sharedLocker) Unlock() {}
+
+// storageName returns the name of the file of the given descriptor, following
+// the naming of the leveldb file storage.
+func storageName(fd storage.FileDesc) string {
+	switch fd.Type {
+	case storage.TypeManifest:
+		return fmt.Sprintf("MANIFEST-%06d", fd.Num)
+	case storage.TypeJournal:
+		return fmt.Sprintf("%06d.log", fd.Num)
+	case storage.TypeTable:
+		return fmt.Sprintf("%06d.ldb", fd.Num)
+	default:
+		return fmt.Sprintf("%06d.tmp", fd.Num)
+	}
+}
+
+// parseStorageName parses the name of a file of the leveldb file storage.
+func parseStorageName(name string) (storage.FileDesc, bool) {
+	var (
+		fd   storage.FileDesc
+		tail string
+	)
+	if _, err := fmt.Sscanf(name, "%d.%s", &fd.Num, &tail); err == nil {
+		switch tail {
+		case "log":
+			fd.Type = storage.TypeJournal
+		case "ldb", "sst":
+			fd.Type = storage.TypeTable
+		case "tmp":
+			fd.Type = storage.TypeTemp
+		default:
+			return fd, false
+		}
+		return fd, true
+	}
+	if n, _ := fmt.Sscanf(name, "MANIFEST-%d%s", &fd.Num, &tail); n == 1 {
+		fd.Type = storage.TypeManifest
+		return fd, true
+	}
+	return fd, false
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+//go:build !js
+// +build !js
+
+package leveldb
+
+import (
+	"context"
+	"runtime"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"golang.org/x/time/rate"
+)
+
+// compactionLimiter limits the rate at which the compactions of all the opened
+// databases write tables to disk. It is unlimited by default.
+var compactionLimiter = rate.NewLimiter(rate.Inf, 0)
+
+// SetCompactionRate limits the rate at which the compactions of all the opened
+// databases write tables to disk, in bytes per second (0 = unlimited). Only the
+// compactions into level 1 and above are throttled, the memory tables are
+// flushed into level 0 at full speed not to stall the writes.
+func SetCompactionRate(bytes int) {
+	if bytes <= 0 {
+		compactionLimiter.SetLimit(rate.Inf)
+		return
+	}
+	compactionLimiter.SetBurst(bytes)
+	compactionLimiter.SetLimit(rate.Limit(bytes))
+}
+
+// CompactionRate returns the current compaction rate limit in bytes per second,
+// 0 if unlimited.
+func CompactionRate() int {
+	if compactionLimiter.Limit() == rate.Inf {
+		return 0
+	}
+	return int(compactionLimiter.Limit())
+}
+
+// throttledStorage is a leveldb storage throttling the writes of the tables
+// created by the compactions into level 1 and above.
+type throttledStorage struct {
+	storage.Storage
+}
+
+// Create implements storage.Storage, throttling the writers of the tables not
+// flushed from a memory table.
+func (s throttledStorage) Create(fd storage.FileDesc) (storage.Writer, error) {
+	w, err := s.Storage.Create(fd)
+	if err != nil || fd.Type != storage.TypeTable || flushingMemdb() {
+		return w, err
+	}
+	return throttledWriter{Writer: w}, nil
+}
+
+// flushingMemdb reports whether the calling goroutine is flushing a memory table
+// into level 0. The storage isn't told what a table is created for, so the
+// flush is recognized by leveldb's function on the stack.
+func flushingMemdb() bool {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if strings.HasSuffix(frame.Function, ".(*session).flushMemdb") {
+			return true
+		}
+		if !more {
+			return false
+		}
+	}
+}
+
+// throttledWriter is a table writer waiting for the compaction rate limit.
+type throttledWriter struct {
+	storage.Writer
+}
+
+// Write implements io.Writer, writing the data in chunks fitting in the burst
+// allowance of the compaction rate limit.
+func (w throttledWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := len(p)
+		if compactionLimiter.Limit() != rate.Inf {
+			if burst := compactionLimiter.Burst(); burst > 0 && chunk > burst {
+				chunk = burst
+			}
+			// WaitN only fails if the limit was changed meanwhile, write anyway
+			compactionLimiter.WaitN(context.Background(), chunk)
+		}
+		n, err := w.Writer.Write(p[:chunk])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[chunk:]
+	}
+	return written, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package objectstore implements access to flat object storage, such as the
+// S3-compatible services, holding immutable blobs under string keys.
+package objectstore
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNotFound is returned if the requested object is not in the store.
+var ErrNotFound = errors.New("object not found")
+
+// Store is a flat object storage.
+type Store interface {
+	// Get retrieves the object stored under the given key.
+	Get(key string) ([]byte, error)
+
+	// Put stores the object under the given key, replacing any previous one.
+	Put(key string, data []byte) error
+}
+
+// MemoryStore is an ephemeral object store kept in memory.
+type MemoryStore struct {
+	objects map[string][]byte
+	lock    sync.RWMutex
+}
+
+// NewMemory creates an empty ephemeral object store.
+func NewMemory() *MemoryStore {
+	return &MemoryStore{objects: make(map[string][]byte)}
+}
+
+// Get retrieves the object stored under the given key.
+func (s *MemoryStore) Get(key string) ([]byte, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, data...), nil
+}
+
+// Put stores the object under the given key, replacing any previous one.
+func (s *MemoryStore) Put(key string, data []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.objects[key] = append([]byte{}, data...)
+	return nil
+}
+
+// Len returns the number of objects in the store.
+func (s *MemoryStore) Len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return len(s.objects)
+}
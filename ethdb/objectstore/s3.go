@@ -0,0 +1,138 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// s3RequestTimeout is the maximum time allowed for a single object transfer.
+const s3RequestTimeout = 5 * time.Minute
+
+// S3Store is an object store backed by an S3-compatible service, addressing the
+// objects path-style under a bucket and an optional key prefix.
+type S3Store struct {
+	base   *url.URL // Endpoint, bucket and key prefix of the objects
+	region string
+	creds  aws.CredentialsProvider // Nil if the requests are sent unsigned
+	signer *v4.Signer
+	client *http.Client
+}
+
+// NewS3 creates an object store from a path-style location of the form
+// https://endpoint/bucket[/prefix]. The credentials are resolved from the
+// standard AWS sources (environment, shared config files, instance roles),
+// unless anonymous access to a public bucket is requested.
+func NewS3(location string, region string, anonymous bool) (*S3Store, error) {
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported object store scheme %q", base.Scheme)
+	}
+	if strings.Trim(base.Path, "/") == "" {
+		return nil, fmt.Errorf("missing bucket in object store location %q", location)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+
+	store := &S3Store{
+		base:   base,
+		region: region,
+		signer: v4.NewSigner(),
+		client: &http.Client{Timeout: s3RequestTimeout},
+	}
+	if !anonymous {
+		cfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(region))
+		if err != nil {
+			return nil, err
+		}
+		store.creds = cfg.Credentials
+	}
+	return store, nil
+}
+
+// Get retrieves the object stored under the given key.
+func (s *S3Store) Get(key string) ([]byte, error) {
+	res, err := s.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(res.Body)
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf("object %s retrieval failed: %s", key, res.Status)
+	}
+}
+
+// Put stores the object under the given key, replacing any previous one.
+func (s *S3Store) Put(key string, data []byte) error {
+	res, err := s.do(http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("object %s upload failed: %s", key, res.Status)
+	}
+	return nil
+}
+
+// do sends a request for the object with the given key, signed unless the
+// store is accessed anonymously.
+func (s *S3Store) do(method string, key string, body []byte) (*http.Response, error) {
+	target := *s.base
+	target.Path += "/" + key
+
+	req, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(body)
+	payload := hex.EncodeToString(hash[:])
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+
+	if s.creds != nil {
+		creds, err := s.creds.Retrieve(req.Context())
+		if err != nil {
+			return nil, err
+		}
+		if err := s.signer.SignHTTP(req.Context(), creds, req, payload, "s3", s.region, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return s.client.Do(req)
+}
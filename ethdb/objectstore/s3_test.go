@@ -0,0 +1,54 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package objectstore
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Tests that an anonymous store retrieves public objects with unsigned requests.
+func TestS3Anonymous(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("anonymous request signed: %s", auth)
+		}
+		if r.URL.Path != "/bucket/prefix/key" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("value"))
+	}))
+	defer srv.Close()
+
+	store, err := NewS3(srv.URL+"/bucket/prefix/", "us-east-1", true)
+	if err != nil {
+		t.Fatal("can't create store", err)
+	}
+	blob, err := store.Get("key")
+	if err != nil {
+		t.Fatal("retrieval failed:", err)
+	}
+	if !bytes.Equal(blob, []byte("value")) {
+		t.Fatalf("object mismatch: have %q, want %q", blob, "value")
+	}
+	if _, err := store.Get("missing"); err != ErrNotFound {
+		t.Fatalf("missing object error mismatch: have %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tiered
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// defaultFilterSize is the size of the access filter of a generation in
+	// bytes. Accesses beyond its capacity raise the false positive rate, keeping
+	// more untouched entries in the hot store.
+	defaultFilterSize = 32 * 1024 * 1024
+
+	// filterHashes is the number of bits set per access.
+	filterHashes = 3
+)
+
+// generation is a bloom filter of the tiered entries accessed since the start
+// of a generation. Accesses are recorded with atomic operations, so that reads
+// don't contend on a lock.
+type generation struct {
+	start time.Time
+	bits  []uint64
+}
+
+// newGeneration creates an empty generation of the given filter size in bytes,
+// the default if zero.
+func newGeneration(start time.Time, size int) *generation {
+	if size <= 0 {
+		size = defaultFilterSize
+	}
+	return &generation{
+		start: time.Unix(start.Unix(), 0),
+		bits:  make([]uint64, (size+7)/8),
+	}
+}
+
+// decodeGeneration parses a generation persisted by encode, which must have the
+// given filter size in bytes, the default if zero.
+func decodeGeneration(blob []byte, size int) (*generation, error) {
+	if size <= 0 {
+		size = defaultFilterSize
+	}
+	words := (size + 7) / 8
+	if len(blob) != 8+8*words {
+		return nil, fmt.Errorf("filter size mismatch: have %d, want %d", len(blob)-8, 8*words)
+	}
+	gen := &generation{
+		start: time.Unix(int64(binary.BigEndian.Uint64(blob)), 0),
+		bits:  make([]uint64, words),
+	}
+	for i := range gen.bits {
+		gen.bits[i] = binary.BigEndian.Uint64(blob[8+8*i:])
+	}
+	return gen, nil
+}
+
+// encode serializes the generation as its start in unix seconds followed by its
+// filter.
+func (g *generation) encode() []byte {
+	blob := make([]byte, 8+8*len(g.bits))
+	binary.BigEndian.PutUint64(blob, uint64(g.start.Unix()))
+	for i := range g.bits {
+		binary.BigEndian.PutUint64(blob[8+8*i:], atomic.LoadUint64(&g.bits[i]))
+	}
+	return blob
+}
+
+// positions returns the filter bits of the given key, derived from its 64-bit
+// FNV-1a hash by double hashing.
+func (g *generation) positions(key []byte) [filterHashes]uint64 {
+	h := uint64(14695981039346656037)
+	for _, b := range key {
+		h ^= uint64(b)
+		h *= 1099511628211
+	}
+	var (
+		size = uint64(len(g.bits)) * 64
+		step = (h>>32 | h<<32) | 1
+		pos  [filterHashes]uint64
+	)
+	for i := range pos {
+		pos[i] = (h + uint64(i)*step) % size
+	}
+	return pos
+}
+
+// add records the access of the given key.
+func (g *generation) add(key []byte) {
+	for _, pos := range g.positions(key) {
+		word, mask := &g.bits[pos/64], uint64(1)<<(pos%64)
+		for {
+			old := atomic.LoadUint64(word)
+			if old&mask != 0 || atomic.CompareAndSwapUint64(word, old, old|mask) {
+				break
+			}
+		}
+	}
+}
+
+// contains reports whether the given key was possibly accessed.
+func (g *generation) contains(key []byte) bool {
+	for _, pos := range g.positions(key) {
+		if atomic.LoadUint64(&g.bits[pos/64])&(uint64(1)<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,118 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tiered
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// mergedIterator iterates over the hot and cold stores in key order. Entries
+// present in both are returned once, with the value of the hot store, and the
+// internal tracking records are skipped.
+type mergedIterator struct {
+	hot, cold     ethdb.Iterator
+	hotOk, coldOk bool
+
+	started           bool
+	fromHot, fromCold bool // stores the current entry was taken from
+}
+
+func newMergedIterator(hot, cold ethdb.Iterator) *mergedIterator {
+	return &mergedIterator{hot: hot, cold: cold}
+}
+
+// nextHot advances the hot iterator past the tracking records.
+func (it *mergedIterator) nextHot() bool {
+	for it.hot.Next() {
+		if !isMetaKey(it.hot.Key()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next moves the iterator to the next key/value pair.
+func (it *mergedIterator) Next() bool {
+	if !it.started {
+		it.started = true
+		it.hotOk, it.coldOk = it.nextHot(), it.cold.Next()
+	} else {
+		if it.fromHot {
+			it.hotOk = it.nextHot()
+		}
+		if it.fromCold {
+			it.coldOk = it.cold.Next()
+		}
+	}
+	switch {
+	case !it.hotOk && !it.coldOk:
+		it.fromHot, it.fromCold = false, false
+		return false
+	case !it.coldOk:
+		it.fromHot, it.fromCold = true, false
+	case !it.hotOk:
+		it.fromHot, it.fromCold = false, true
+	default:
+		switch cmp := bytes.Compare(it.hot.Key(), it.cold.Key()); {
+		case cmp < 0:
+			it.fromHot, it.fromCold = true, false
+		case cmp > 0:
+			it.fromHot, it.fromCold = false, true
+		default:
+			it.fromHot, it.fromCold = true, true
+		}
+	}
+	return true
+}
+
+// Error returns any accumulated error of either store.
+func (it *mergedIterator) Error() error {
+	if err := it.hot.Error(); err != nil {
+		return err
+	}
+	return it.cold.Error()
+}
+
+// Key returns the key of the current key/value pair, or nil if done.
+func (it *mergedIterator) Key() []byte {
+	switch {
+	case it.fromHot:
+		return it.hot.Key()
+	case it.fromCold:
+		return it.cold.Key()
+	}
+	return nil
+}
+
+// Value returns the value of the current key/value pair, or nil if done.
+func (it *mergedIterator) Value() []byte {
+	switch {
+	case it.fromHot:
+		return it.hot.Value()
+	case it.fromCold:
+		return it.cold.Value()
+	}
+	return nil
+}
+
+// Release releases the iterators of both stores.
+func (it *mergedIterator) Release() {
+	it.hot.Release()
+	it.cold.Release()
+}
@@ -0,0 +1,290 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tiered
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// generations is the number of generations the migration age is split into.
+	// Accesses are tracked per generation, an entry is untouched for the age if
+	// none of the generations spanning it saw an access.
+	generations = 4
+
+	// migrateBatchSize is the number of entries migrated at once while holding
+	// off writes.
+	migrateBatchSize = 1024
+)
+
+var (
+	// generationPrefix + start (uint64 big endian unix seconds) -> access filter
+	// of the generation started at that time.
+	generationPrefix = []byte("tiered-generation-")
+
+	// cursorKey tracks the hot store key the next sweep resumes at.
+	cursorKey = []byte("tiered-cursor")
+)
+
+var errClosed = errors.New("tiered database closed")
+
+func generationKey(start time.Time) []byte {
+	key := make([]byte, len(generationPrefix)+8)
+	copy(key, generationPrefix)
+	binary.BigEndian.PutUint64(key[len(generationPrefix):], uint64(start.Unix()))
+	return key
+}
+
+// isMetaKey reports whether the key is one of the internal tracking records.
+func isMetaKey(key []byte) bool {
+	return bytes.HasPrefix(key, generationPrefix) || bytes.Equal(key, cursorKey)
+}
+
+// touch records the access of a tiered entry in the current generation. It is
+// lock free, so it doesn't slow down reads.
+func (db *Database) touch(key []byte) {
+	if db.config.ReadOnly {
+		return
+	}
+	db.current.Load().(*generation).add(key)
+}
+
+// touched reports whether the tiered entry was accessed in any of the tracked
+// generations. The generation lock must be held.
+func (db *Database) touched(key []byte) bool {
+	if db.current.Load().(*generation).contains(key) {
+		return true
+	}
+	for _, gen := range db.past {
+		if gen.contains(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadGenerations restores the generations persisted in the hot store, resuming
+// the newest one if it is still current. Generations tracked with a different
+// filter size are discarded.
+func (db *Database) loadGenerations() {
+	var (
+		now   = db.now()
+		stale [][]byte
+	)
+	it := db.hot.NewIterator(generationPrefix, nil)
+	for it.Next() {
+		gen, err := decodeGeneration(it.Value(), db.config.FilterSize)
+		if err != nil {
+			log.Warn("Discarding tiered access filter", "err", err)
+			stale = append(stale, common.CopyBytes(it.Key()))
+			continue
+		}
+		db.past = append(db.past, gen)
+	}
+	if err := it.Error(); err != nil {
+		log.Warn("Failed to load tiered access filters", "err", err)
+	}
+	it.Release()
+
+	for _, key := range stale {
+		db.hot.Delete(key)
+	}
+	if n := len(db.past); n > 0 && now.Sub(db.past[n-1].start) < db.span() {
+		db.current.Store(db.past[n-1])
+		db.past = db.past[:n-1]
+	} else {
+		db.current.Store(newGeneration(now, db.config.FilterSize))
+	}
+	if cursor, err := db.hot.Get(cursorKey); err == nil {
+		db.cursor = cursor
+	}
+}
+
+// span returns the time covered by a generation.
+func (db *Database) span() time.Duration {
+	return db.config.Age / generations
+}
+
+// rotate starts a new generation once the current one covered its span, and
+// drops the past generations no longer needed to cover the migration age. The
+// generation lock must be held.
+func (db *Database) rotate(now time.Time) error {
+	current := db.current.Load().(*generation)
+	if now.Sub(current.start) < db.span() {
+		return nil
+	}
+	if err := db.hot.Put(generationKey(current.start), current.encode()); err != nil {
+		return err
+	}
+	db.past = append(db.past, current)
+	db.current.Store(newGeneration(now, db.config.FilterSize))
+
+	// A generation ends when the next one starts, drop it if that is older
+	// than the age
+	cutoff := now.Add(-db.config.Age)
+	for len(db.past) > 1 && !db.past[1].start.After(cutoff) {
+		if err := db.hot.Delete(generationKey(db.past[0].start)); err != nil {
+			return err
+		}
+		db.past = db.past[1:]
+	}
+	return nil
+}
+
+// covered reports whether the tracked generations reach back the whole age, so
+// that entries untouched in all of them are old enough to migrate. The
+// generation lock must be held.
+func (db *Database) covered(now time.Time) bool {
+	oldest := db.current.Load().(*generation)
+	if len(db.past) > 0 {
+		oldest = db.past[0]
+	}
+	return !oldest.start.After(now.Add(-db.config.Age))
+}
+
+// loop sweeps the hot store for entries to migrate at the configured interval.
+func (db *Database) loop() {
+	defer db.wg.Done()
+
+	ticker := time.NewTicker(db.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			start := time.Now()
+			migrated, err := db.Migrate()
+			switch {
+			case errors.Is(err, errClosed):
+				return
+			case err != nil:
+				log.Warn("Failed to migrate tiered entries", "migrated", migrated, "err", err)
+			case migrated > 0:
+				log.Info("Migrated untouched state to cold storage", "entries", migrated, "elapsed", common.PrettyDuration(time.Since(start)))
+			}
+		case <-db.quit:
+			return
+		}
+	}
+}
+
+// Migrate sweeps the next range of the hot store, moving the tiered entries
+// untouched for the configured age to the cold store, and returns the number
+// of entries moved. At most SweepLimit entries are visited per call, the sweeps
+// resuming where the previous one stopped and wrapping around at the end of
+// the store. Nothing is migrated until the accesses are tracked for the age.
+func (db *Database) Migrate() (int, error) {
+	if db.config.ReadOnly {
+		return 0, nil
+	}
+	db.genLock.Lock()
+	defer db.genLock.Unlock()
+
+	now := db.now()
+	if err := db.rotate(now); err != nil {
+		return 0, err
+	}
+	if !db.covered(now) {
+		return 0, nil
+	}
+	var (
+		migrated   int
+		visited    int
+		keys, vals [][]byte
+		next       []byte
+	)
+	it := db.hot.NewIterator(nil, db.cursor)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if db.config.SweepLimit > 0 && visited >= db.config.SweepLimit {
+			next = common.CopyBytes(key)
+			break
+		}
+		visited++
+		if isMetaKey(key) || !db.config.Tiered(key) || db.touched(key) {
+			continue
+		}
+		keys = append(keys, common.CopyBytes(key))
+		vals = append(vals, common.CopyBytes(it.Value()))
+		if len(keys) >= migrateBatchSize {
+			n, err := db.move(keys, vals)
+			if migrated += n; err != nil {
+				return migrated, err
+			}
+			keys, vals = keys[:0], vals[:0]
+		}
+	}
+	if err := it.Error(); err != nil {
+		return migrated, err
+	}
+	n, err := db.move(keys, vals)
+	if migrated += n; err != nil {
+		return migrated, err
+	}
+	db.cursor = next
+	if next == nil {
+		return migrated, db.hot.Delete(cursorKey)
+	}
+	return migrated, db.hot.Put(cursorKey, next)
+}
+
+// move migrates the given entries to the cold store, skipping the ones that
+// changed or were accessed since they were selected. The generation lock must
+// be held.
+func (db *Database) move(keys, vals [][]byte) (int, error) {
+	select {
+	case <-db.quit:
+		return 0, errClosed
+	default:
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	var (
+		hot   = db.hot.NewBatch()
+		cold  = db.cold.NewBatch()
+		moved int
+	)
+	for i, key := range keys {
+		if db.current.Load().(*generation).contains(key) {
+			continue
+		}
+		if current, err := db.hot.Get(key); err != nil || !bytes.Equal(current, vals[i]) {
+			continue
+		}
+		cold.Put(key, vals[i])
+		hot.Delete(key)
+		moved++
+	}
+	// Write the cold store first, so the entries are never missing from both
+	if err := cold.Write(); err != nil {
+		return 0, err
+	}
+	if err := hot.Write(); err != nil {
+		return 0, err
+	}
+	db.migrateMeter.Mark(int64(moved))
+	return moved, nil
+}
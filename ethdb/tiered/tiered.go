@@ -0,0 +1,315 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tiered implements a key-value store spread over a hot store on fast
+// storage and a cold store on a slower volume. Selected entries untouched for a
+// configured age are migrated to the cold store, reads fall through to it
+// transparently and bring the entries read back into the hot store.
+package tiered
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// Config are the tiering parameters.
+type Config struct {
+	Age        time.Duration         // Entries untouched for this long are migrated to the cold store
+	Interval   time.Duration         // Interval between migration sweeps (0 = no background sweeps)
+	SweepLimit int                   // Maximum number of hot entries visited per sweep (0 = unlimited)
+	FilterSize int                   // Size of the access filter of each generation in bytes (0 = default)
+	Tiered     func(key []byte) bool // Selects the entries subject to tiering
+	ReadOnly   bool                  // Neither track accesses nor migrate entries
+}
+
+// Database is a key-value store serving the tiered entries from a hot and a
+// cold store, and all other entries from the hot store.
+type Database struct {
+	hot    ethdb.KeyValueStore
+	cold   ethdb.KeyValueStore
+	config Config
+
+	lock sync.RWMutex // Excludes migrations and promotions from concurrent writes
+
+	current atomic.Value     // Generation tracking the current accesses (*generation)
+	genLock sync.Mutex       // Serializes sweeps, protecting the fields below
+	past    []*generation    // Past generations still needed to cover the age, oldest first
+	cursor  []byte           // Hot store key the next sweep resumes at
+	now     func() time.Time // Clock of the generations, replaceable in tests
+
+	hotHitMeter   metrics.Meter
+	coldHitMeter  metrics.Meter
+	missMeter     metrics.Meter
+	migrateMeter  metrics.Meter
+	promoteMeter  metrics.Meter
+	coldReadTimer metrics.Timer
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a tiered store over the given hot and cold stores, reporting the
+// tier hit rates under the given metrics namespace. The tiered store owns both
+// stores and closes them on Close.
+func New(hot, cold ethdb.KeyValueStore, config Config, namespace string) *Database {
+	db := &Database{
+		hot:           hot,
+		cold:          cold,
+		config:        config,
+		now:           time.Now,
+		hotHitMeter:   metrics.NewRegisteredMeter(namespace+"tiered/hot", nil),
+		coldHitMeter:  metrics.NewRegisteredMeter(namespace+"tiered/cold", nil),
+		missMeter:     metrics.NewRegisteredMeter(namespace+"tiered/miss", nil),
+		migrateMeter:  metrics.NewRegisteredMeter(namespace+"tiered/migrated", nil),
+		promoteMeter:  metrics.NewRegisteredMeter(namespace+"tiered/promoted", nil),
+		coldReadTimer: metrics.NewRegisteredTimer(namespace+"tiered/cold/read", nil),
+		quit:          make(chan struct{}),
+	}
+	if !config.ReadOnly {
+		db.loadGenerations()
+	}
+	if !config.ReadOnly && config.Interval > 0 {
+		db.wg.Add(1)
+		go db.loop()
+	}
+	return db
+}
+
+// Has retrieves if a key is present in either store.
+func (db *Database) Has(key []byte) (bool, error) {
+	if ok, err := db.hot.Has(key); ok || err != nil || !db.config.Tiered(key) {
+		return ok, err
+	}
+	return db.cold.Has(key)
+}
+
+// Get retrieves the given key from the hot store, falling through to the cold
+// store for tiered entries. Entries read from the cold store are brought back
+// into the hot store.
+func (db *Database) Get(key []byte) ([]byte, error) {
+	value, err := db.hot.Get(key)
+	if !db.config.Tiered(key) {
+		return value, err
+	}
+	if err == nil {
+		db.hotHitMeter.Mark(1)
+		db.touch(key)
+		return value, nil
+	}
+	start := time.Now()
+	value, err = db.cold.Get(key)
+	db.coldReadTimer.UpdateSince(start)
+	if err != nil {
+		db.missMeter.Mark(1)
+		return nil, err
+	}
+	db.coldHitMeter.Mark(1)
+	db.promote(key, value)
+	return value, nil
+}
+
+// Put inserts the given value into the hot store.
+func (db *Database) Put(key []byte, value []byte) error {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.config.Tiered(key) {
+		db.touch(key)
+	}
+	return db.hot.Put(key, value)
+}
+
+// Delete removes the key from both stores.
+func (db *Database) Delete(key []byte) error {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	if db.config.Tiered(key) {
+		if err := db.cold.Delete(key); err != nil {
+			return err
+		}
+	}
+	return db.hot.Delete(key)
+}
+
+// NewBatch creates a write-only batch over both stores.
+func (db *Database) NewBatch() ethdb.Batch {
+	return &batch{db: db, hot: db.hot.NewBatch(), cold: db.cold.NewBatch()}
+}
+
+// NewBatchWithSize creates a write-only batch with pre-allocated buffer.
+func (db *Database) NewBatchWithSize(size int) ethdb.Batch {
+	return &batch{db: db, hot: db.hot.NewBatchWithSize(size), cold: db.cold.NewBatch()}
+}
+
+// NewIterator creates an iterator over the merged content of both stores, the
+// hot entries shadowing stale cold ones.
+func (db *Database) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	return newMergedIterator(db.hot.NewIterator(prefix, start), db.cold.NewIterator(prefix, start))
+}
+
+// NewSnapshot creates a snapshot over both stores.
+func (db *Database) NewSnapshot() (ethdb.Snapshot, error) {
+	hot, err := db.hot.NewSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	cold, err := db.cold.NewSnapshot()
+	if err != nil {
+		hot.Release()
+		return nil, err
+	}
+	return &snapshot{hot: hot, cold: cold, tiered: db.config.Tiered}, nil
+}
+
+// Stat returns a particular internal stat of the hot store.
+func (db *Database) Stat(property string) (string, error) {
+	return db.hot.Stat(property)
+}
+
+// Compact flattens the given key range of both stores.
+func (db *Database) Compact(start []byte, limit []byte) error {
+	if err := db.hot.Compact(start, limit); err != nil {
+		return err
+	}
+	return db.cold.Compact(start, limit)
+}
+
+// Close stops migrating entries and closes both stores.
+func (db *Database) Close() error {
+	close(db.quit)
+	db.wg.Wait()
+
+	if !db.config.ReadOnly {
+		db.genLock.Lock()
+		current := db.current.Load().(*generation)
+		if err := db.hot.Put(generationKey(current.start), current.encode()); err != nil {
+			log.Warn("Failed to write tiered access filter", "err", err)
+		}
+		db.genLock.Unlock()
+	}
+	err := db.hot.Close()
+	if cerr := db.cold.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// promote brings an entry read from the cold store back into the hot store,
+// unless it was deleted or rewritten meanwhile.
+func (db *Database) promote(key, value []byte) {
+	if db.config.ReadOnly {
+		return
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if ok, _ := db.hot.Has(key); ok {
+		return
+	}
+	if current, err := db.cold.Get(key); err != nil || !bytes.Equal(current, value) {
+		return
+	}
+	if err := db.hot.Put(key, value); err != nil {
+		log.Warn("Failed to promote tiered entry", "err", err)
+		return
+	}
+	db.promoteMeter.Mark(1)
+	db.touch(key)
+}
+
+// batch is a write-only batch over both stores. Values are only written to the
+// hot store, deletions apply to both.
+type batch struct {
+	db   *Database
+	hot  ethdb.Batch
+	cold ethdb.Batch
+}
+
+func (b *batch) Put(key, value []byte) error {
+	if b.db.config.Tiered(key) {
+		b.db.touch(key)
+	}
+	return b.hot.Put(key, value)
+}
+
+func (b *batch) Delete(key []byte) error {
+	if b.db.config.Tiered(key) {
+		if err := b.cold.Delete(key); err != nil {
+			return err
+		}
+	}
+	return b.hot.Delete(key)
+}
+
+func (b *batch) ValueSize() int {
+	return b.hot.ValueSize() + b.cold.ValueSize()
+}
+
+// Write deletes from the cold store first, so that readers never find stale
+// cold entries of deleted hot ones.
+func (b *batch) Write() error {
+	b.db.lock.RLock()
+	defer b.db.lock.RUnlock()
+
+	if err := b.cold.Write(); err != nil {
+		return err
+	}
+	return b.hot.Write()
+}
+
+func (b *batch) Reset() {
+	b.hot.Reset()
+	b.cold.Reset()
+}
+
+// Replay replays the batch contents.
+func (b *batch) Replay(w ethdb.KeyValueWriter) error {
+	return b.hot.Replay(w)
+}
+
+// snapshot is a snapshot over both stores.
+type snapshot struct {
+	hot    ethdb.Snapshot
+	cold   ethdb.Snapshot
+	tiered func(key []byte) bool
+}
+
+func (s *snapshot) Has(key []byte) (bool, error) {
+	if ok, err := s.hot.Has(key); ok || err != nil || !s.tiered(key) {
+		return ok, err
+	}
+	return s.cold.Has(key)
+}
+
+func (s *snapshot) Get(key []byte) ([]byte, error) {
+	value, err := s.hot.Get(key)
+	if err == nil || !s.tiered(key) {
+		return value, err
+	}
+	return s.cold.Get(key)
+}
+
+func (s *snapshot) Release() {
+	s.hot.Release()
+	s.cold.Release()
+}
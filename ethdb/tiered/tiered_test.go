@@ -0,0 +1,188 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tiered
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/dbtest"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+)
+
+// tieredKey selects keys starting with "s" as tiered in the tests.
+func tieredKey(key []byte) bool {
+	return len(key) > 0 && key[0] == 's'
+}
+
+// testFilterSize keeps the access filters of the test databases small.
+const testFilterSize = 1024
+
+func TestTieredDB(t *testing.T) {
+	t.Run("DatabaseSuite", func(t *testing.T) {
+		dbtest.TestDatabaseSuite(t, func() ethdb.KeyValueStore {
+			return New(memorydb.New(), memorydb.New(), Config{FilterSize: testFilterSize, Tiered: tieredKey}, "")
+		})
+	})
+}
+
+// newTestDatabase creates a tiered database over memory stores, with a clock
+// advanced by the returned function.
+func newTestDatabase(config Config) (*Database, ethdb.KeyValueStore, ethdb.KeyValueStore, func(time.Duration)) {
+	hot, cold := memorydb.New(), memorydb.New()
+	config.FilterSize, config.Tiered = testFilterSize, tieredKey
+	db := New(hot, cold, config, "")
+
+	now := time.Now()
+	db.now = func() time.Time { return now }
+	return db, hot, cold, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestMigrate(t *testing.T) {
+	db, hot, cold, advance := newTestDatabase(Config{Age: 4 * time.Hour})
+	defer db.Close()
+
+	for _, key := range []string{"s1", "s2", "s3", "x1"} {
+		db.Put([]byte(key), []byte("v"+key))
+	}
+	// Nothing is migrated until the accesses are tracked for the whole age
+	if n, err := db.Migrate(); n != 0 || err != nil {
+		t.Fatalf("first sweep: migrated %d, err %v", n, err)
+	}
+	// The entries were written in a generation ending now, they are not old
+	// enough yet. Keep s2 in use in the next generation.
+	advance(5 * time.Hour)
+	if n, err := db.Migrate(); n != 0 || err != nil {
+		t.Fatalf("second sweep: migrated %d, err %v", n, err)
+	}
+	db.Get([]byte("s2"))
+
+	advance(4*time.Hour + time.Second)
+	if n, err := db.Migrate(); n != 2 || err != nil {
+		t.Fatalf("third sweep: migrated %d, err %v", n, err)
+	}
+	for _, key := range []string{"s1", "s3"} {
+		if ok, _ := hot.Has([]byte(key)); ok {
+			t.Fatalf("migrated entry %s left in hot store", key)
+		}
+		if ok, _ := cold.Has([]byte(key)); !ok {
+			t.Fatalf("migrated entry %s missing from cold store", key)
+		}
+	}
+	for _, key := range []string{"s2", "x1"} {
+		if ok, _ := cold.Has([]byte(key)); ok {
+			t.Fatalf("entry %s migrated", key)
+		}
+	}
+	// Iteration covers both stores and skips the tracking records
+	it := db.NewIterator(nil, nil)
+	var keys []string
+	for it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	it.Release()
+	if want := []string{"s1", "s2", "s3", "x1"}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("iterated keys mismatch: have %v, want %v", keys, want)
+	}
+	// Reads fall through to the cold store and bring the entry back
+	if value, err := db.Get([]byte("s1")); err != nil || !bytes.Equal(value, []byte("vs1")) {
+		t.Fatalf("cold read mismatch: have %q, %v", value, err)
+	}
+	if ok, _ := hot.Has([]byte("s1")); !ok {
+		t.Fatal("cold entry not promoted")
+	}
+	// Deletions apply to both stores
+	if err := db.Delete([]byte("s3")); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := db.Has([]byte("s3")); ok {
+		t.Fatal("deleted entry still present")
+	}
+	if ok, _ := cold.Has([]byte("s3")); ok {
+		t.Fatal("deleted entry left in cold store")
+	}
+}
+
+// Tests that sweeps visit a bounded number of entries, resuming where the last
+// one stopped.
+func TestMigrateSweepLimit(t *testing.T) {
+	db, hot, cold, advance := newTestDatabase(Config{Age: 4 * time.Hour, SweepLimit: 2})
+	defer db.Close()
+
+	for _, key := range []string{"s1", "s2", "s3", "s4", "s5"} {
+		hot.Put([]byte(key), []byte("v"+key))
+	}
+	advance(4 * time.Hour)
+	for i, want := range []int{2, 2, 1, 0} {
+		if n, err := db.Migrate(); n != want || err != nil {
+			t.Fatalf("sweep %d: migrated %d, want %d, err %v", i, n, want, err)
+		}
+	}
+	for _, key := range []string{"s1", "s2", "s3", "s4", "s5"} {
+		if ok, _ := cold.Has([]byte(key)); !ok {
+			t.Fatalf("entry %s not migrated", key)
+		}
+	}
+}
+
+// Tests that the generations are persisted and restored, keeping the accesses
+// tracked before a restart.
+func TestGenerationPersistence(t *testing.T) {
+	gen := newGeneration(time.Unix(1000, 0), testFilterSize)
+	gen.add([]byte("s1"))
+
+	restored, err := decodeGeneration(gen.encode(), testFilterSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restored.start.Equal(gen.start) {
+		t.Fatalf("start mismatch: have %v, want %v", restored.start, gen.start)
+	}
+	if !restored.contains([]byte("s1")) {
+		t.Fatal("access lost")
+	}
+	if restored.contains([]byte("s2")) {
+		t.Fatal("unexpected access")
+	}
+	if _, err := decodeGeneration(gen.encode(), 2*testFilterSize); err == nil {
+		t.Fatal("filter of different size accepted")
+	}
+	// Generations persisted in the hot store are resumed
+	hot := memorydb.New()
+	hot.Put(generationKey(time.Now()), newGeneration(time.Now(), testFilterSize).encode())
+	db := New(hot, memorydb.New(), Config{Age: 4 * time.Hour, FilterSize: testFilterSize, Tiered: tieredKey}, "")
+	defer db.Close()
+	if len(db.past) != 0 {
+		t.Fatalf("current generation not resumed: %d past generations", len(db.past))
+	}
+}
+
+func TestMigrateSkipsRewritten(t *testing.T) {
+	db, _, cold, _ := newTestDatabase(Config{Age: time.Hour})
+	defer db.Close()
+
+	db.hot.Put([]byte("s1"), []byte("old"))
+	if n, err := db.move([][]byte{[]byte("s1")}, [][]byte{[]byte("stale")}); n != 0 || err != nil {
+		t.Fatalf("rewritten entry migrated: %d, %v", n, err)
+	}
+	if ok, _ := cold.Has([]byte("s1")); ok {
+		t.Fatal("rewritten entry written to cold store")
+	}
+}
@@ -43,6 +43,7 @@ import (
 	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/gorilla/websocket"
 )
@@ -80,6 +81,15 @@ type fullNodeBackend interface {
 	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
 }
 
+// rollupBackend encompasses the functionality necessary for a rollup node
+// reporting to ethstats
+type rollupBackend interface {
+	fullNodeBackend
+	ChainConfig() *params.ChainConfig
+	CurrentSafeBlock() *types.Block
+	CurrentFinalizedBlock() *types.Block
+}
+
 // Service implements an Ethereum netstats reporting daemon that pushes local
 // chain statistics up to a monitoring server.
 type Service struct {
@@ -87,9 +97,10 @@ type Service struct {
 	backend backend
 	engine  consensus.Engine // Consensus engine to retrieve variadic block fields
 
-	node string // Name of the node to display on the monitoring page
-	pass string // Password to authorize access to the monitoring page
-	host string // Remote address of the monitoring service
+	node  string // Name of the node to display on the monitoring page
+	pass  string // Password to authorize access to the monitoring page
+	host  string // Remote address of the monitoring service
+	token string // Bearer token to authorize the connection with, only sent over TLS
 
 	pongCh chan struct{} // Pong notifications are fed into this channel
 	histCh chan []uint64 // History request block numbers are fed into this channel
@@ -167,12 +178,16 @@ func parseEthstatsURL(url string) (parts []string, err error) {
 	return []string{nodename, pass, host}, nil
 }
 
-// New returns a monitoring service ready for stats reporting.
-func New(node *node.Node, backend backend, engine consensus.Engine, url string) error {
+// New returns a monitoring service ready for stats reporting. If a token is
+// given, it is sent as a bearer token when connecting, which requires TLS.
+func New(node *node.Node, backend backend, engine consensus.Engine, url string, token string) error {
 	parts, err := parseEthstatsURL(url)
 	if err != nil {
 		return err
 	}
+	if token != "" && strings.Contains(parts[2], "://") && !strings.HasPrefix(parts[2], "wss://") {
+		return fmt.Errorf("ethstats token requires a TLS connection: %s", parts[2])
+	}
 	ethstats := &Service{
 		backend: backend,
 		engine:  engine,
@@ -180,6 +195,7 @@ func New(node *node.Node, backend backend, engine consensus.Engine, url string)
 		node:    parts[0],
 		pass:    parts[1],
 		host:    parts[2],
+		token:   token,
 		pongCh:  make(chan struct{}),
 		histCh:  make(chan []uint64, 1),
 	}
@@ -260,6 +276,9 @@ func (s *Service) loop(chainHeadCh chan core.ChainHeadEvent, txEventCh chan core
 	// url.Parse and url.IsAbs is unsuitable (https://github.com/golang/go/issues/19779)
 	if !strings.Contains(path, "://") {
 		urls = []string{"wss://" + path, "ws://" + path}
+		if s.token != "" {
+			urls = urls[:1] // Never leak the token over a plain connection
+		}
 	}
 
 	errTimer := time.NewTimer(0)
@@ -278,6 +297,9 @@ func (s *Service) loop(chainHeadCh chan core.ChainHeadEvent, txEventCh chan core
 			dialer := websocket.Dialer{HandshakeTimeout: 5 * time.Second}
 			header := make(http.Header)
 			header.Set("origin", "http://localhost")
+			if s.token != "" {
+				header.Set("Authorization", "Bearer "+s.token)
+			}
 			for _, url := range urls {
 				c, _, e := dialer.Dial(url, header)
 				err = e
@@ -333,6 +355,9 @@ func (s *Service) loop(chainHeadCh chan core.ChainHeadEvent, txEventCh chan core
 					if err = s.reportPending(conn); err != nil {
 						log.Warn("Post-block transaction stats report failed", "err", err)
 					}
+					if err = s.reportRollup(conn); err != nil {
+						log.Warn("Post-block rollup stats report failed", "err", err)
+					}
 				case <-txCh:
 					if err = s.reportPending(conn); err != nil {
 						log.Warn("Transaction stats report failed", "err", err)
@@ -486,7 +511,7 @@ func (s *Service) login(conn *connWrapper) error {
 			API:      "No",
 			Os:       runtime.GOOS,
 			OsVer:    runtime.GOARCH,
-			Client:   "0.1.1",
+			Client:   "0.2.0",
 			History:  true,
 		},
 		Secret: s.pass,
@@ -521,6 +546,9 @@ func (s *Service) report(conn *connWrapper) error {
 	if err := s.reportStats(conn); err != nil {
 		return err
 	}
+	if err := s.reportRollup(conn); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -809,3 +837,72 @@ func (s *Service) reportStats(conn *connWrapper) error {
 	}
 	return conn.WriteJSON(report)
 }
+
+// headStats is the information to report about a labeled head of the chain.
+type headStats struct {
+	Number    uint64      `json:"number"`
+	Hash      common.Hash `json:"hash"`
+	Timestamp uint64      `json:"timestamp"`
+}
+
+// rollupStats is the information to report about the progress of a rollup.
+type rollupStats struct {
+	Unsafe           *headStats `json:"unsafe"`
+	Safe             *headStats `json:"safe"`             // Nil until reported by the rollup node
+	Finalized        *headStats `json:"finalized"`        // Nil until reported by the rollup node
+	Deposits         int        `json:"deposits"`         // Deposit transactions in the unsafe head
+	PayloadBuildTime int64      `json:"payloadBuildTime"` // Milliseconds to build the last payload
+}
+
+// reportRollup retrieves the unsafe, safe and finalized heads of a rollup along
+// with its block building stats and reports them to the stats server. Nodes of
+// other chains report nothing.
+func (s *Service) reportRollup(conn *connWrapper) error {
+	stats := s.assembleRollupStats()
+	if stats == nil {
+		return nil
+	}
+	log.Trace("Sending rollup details to ethstats", "unsafe", stats.Unsafe.Number)
+
+	report := map[string][]interface{}{
+		"emit": {"rollup", map[string]interface{}{
+			"id":     s.node,
+			"rollup": stats,
+		}},
+	}
+	return conn.WriteJSON(report)
+}
+
+// assembleRollupStats assembles the rollup stats, or returns nil if the node is
+// not a full node of a rollup.
+func (s *Service) assembleRollupStats() *rollupStats {
+	rollupBackend, ok := s.backend.(rollupBackend)
+	if !ok || rollupBackend.ChainConfig().Optimism == nil {
+		return nil
+	}
+	head := rollupBackend.CurrentBlock()
+
+	stats := &rollupStats{
+		Unsafe:           newHeadStats(head),
+		Safe:             newHeadStats(rollupBackend.CurrentSafeBlock()),
+		Finalized:        newHeadStats(rollupBackend.CurrentFinalizedBlock()),
+		PayloadBuildTime: rollupBackend.Miner().PayloadBuildTime().Milliseconds(),
+	}
+	for _, tx := range head.Transactions() {
+		if tx.Type() == types.DepositTxType {
+			stats.Deposits++
+		}
+	}
+	return stats
+}
+
+func newHeadStats(block *types.Block) *headStats {
+	if block == nil {
+		return nil
+	}
+	return &headStats{
+		Number:    block.NumberU64(),
+		Hash:      block.Hash(),
+		Timestamp: block.Time(),
+	}
+}
@@ -17,8 +17,15 @@
 package ethstats
 
 import (
+	"math/big"
 	"strconv"
 	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/miner"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 func TestParseEthstatsURL(t *testing.T) {
@@ -81,3 +88,43 @@ func TestParseEthstatsURL(t *testing.T) {
 	}
 
 }
+
+type testRollupBackend struct {
+	fullNodeBackend
+	config          *params.ChainConfig
+	head, safe, fin *types.Block
+}
+
+func (b *testRollupBackend) ChainConfig() *params.ChainConfig    { return b.config }
+func (b *testRollupBackend) CurrentBlock() *types.Block          { return b.head }
+func (b *testRollupBackend) CurrentSafeBlock() *types.Block      { return b.safe }
+func (b *testRollupBackend) CurrentFinalizedBlock() *types.Block { return b.fin }
+func (b *testRollupBackend) Miner() *miner.Miner                 { return new(miner.Miner) }
+
+func TestAssembleRollupStats(t *testing.T) {
+	var (
+		safe    = types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})
+		deposit = types.NewTx(&types.DepositTx{Value: common.Big0})
+		legacy  = types.NewTransaction(0, common.Address{}, common.Big0, 0, common.Big0, nil)
+		head    = types.NewBlock(&types.Header{Number: big.NewInt(2)}, []*types.Transaction{deposit, legacy}, nil, nil, trie.NewStackTrie(nil))
+	)
+	backend := &testRollupBackend{config: params.TestChainConfig, head: head, safe: safe}
+	s := &Service{backend: backend}
+	if stats := s.assembleRollupStats(); stats != nil {
+		t.Fatalf("rollup stats reported for non-rollup chain: %+v", stats)
+	}
+	config := *params.TestChainConfig
+	config.Optimism = &params.OptimismConfig{}
+	backend.config = &config
+
+	stats := s.assembleRollupStats()
+	if stats == nil {
+		t.Fatal("no rollup stats reported")
+	}
+	if stats.Unsafe.Hash != head.Hash() || stats.Safe.Hash != safe.Hash() || stats.Finalized != nil {
+		t.Errorf("heads mismatch: unsafe %+v, safe %+v, finalized %+v", stats.Unsafe, stats.Safe, stats.Finalized)
+	}
+	if stats.Deposits != 1 {
+		t.Errorf("deposit count mismatch: have %d, want 1", stats.Deposits)
+	}
+}
@@ -129,6 +129,18 @@ func init() {
 	log.Root().SetHandler(glogger)
 }
 
+// SetLogLevels applies the log verbosity, if not nil, and vmodule pattern, if
+// not empty, unless they were given on the command line, which takes precedence.
+func SetLogLevels(ctx *cli.Context, verbosity *int, vmodule string) error {
+	if verbosity != nil && !ctx.IsSet(verbosityFlag.Name) {
+		glogger.Verbosity(log.Lvl(*verbosity))
+	}
+	if vmodule != "" && !ctx.IsSet(vmoduleFlag.Name) {
+		return glogger.Vmodule(vmodule)
+	}
+	return nil
+}
+
 // Setup initializes profiling and logging based on the CLI flags.
 // It should be called as early as possible in the program.
 func Setup(ctx *cli.Context) error {
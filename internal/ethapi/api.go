@@ -17,6 +17,7 @@
 package ethapi
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -26,7 +27,6 @@ import (
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/ethereum/go-ethereum/accounts"
-	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/accounts/scwallet"
 	"github.com/ethereum/go-ethereum/common"
@@ -35,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus/ethash"
 	"github.com/ethereum/go-ethereum/consensus/misc"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
@@ -84,6 +85,16 @@ type feeHistoryResult struct {
 	Reward       [][]*hexutil.Big `json:"reward,omitempty"`
 	BaseFee      []*hexutil.Big   `json:"baseFeePerGas,omitempty"`
 	GasUsedRatio []float64        `json:"gasUsedRatio"`
+	L1Fee        *feeHistoryL1Fee `json:"l1Fee,omitempty"` // Rollup chains only
+}
+
+// feeHistoryL1Fee holds the parameters of the rollup L1 data fee over the
+// range of blocks of a fee history.
+type feeHistoryL1Fee struct {
+	L1BaseFee []*hexutil.Big `json:"l1BaseFee"`
+	Overhead  *hexutil.Big   `json:"overhead"`
+	Scalar    *hexutil.Big   `json:"scalar"`
+	Decimals  hexutil.Uint64 `json:"decimals"`
 }
 
 func (s *EthereumAPI) FeeHistory(ctx context.Context, blockCount rpc.DecimalOrHex, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*feeHistoryResult, error) {
@@ -110,6 +121,21 @@ func (s *EthereumAPI) FeeHistory(ctx context.Context, blockCount rpc.DecimalOrHe
 			results.BaseFee[i] = (*hexutil.Big)(v)
 		}
 	}
+	l1Fee, err := s.b.L1FeeHistory(ctx, oldest, len(gasUsed))
+	if err != nil {
+		return nil, err
+	}
+	if l1Fee != nil {
+		results.L1Fee = &feeHistoryL1Fee{
+			L1BaseFee: make([]*hexutil.Big, len(l1Fee.L1BaseFee)),
+			Overhead:  (*hexutil.Big)(l1Fee.Overhead),
+			Scalar:    (*hexutil.Big)(l1Fee.Scalar),
+			Decimals:  hexutil.Uint64(l1Fee.Decimals),
+		}
+		for i, v := range l1Fee.L1BaseFee {
+			results.L1Fee.L1BaseFee[i] = (*hexutil.Big)(v)
+		}
+	}
 	return results, nil
 }
 
@@ -207,6 +233,152 @@ func (s *TxPoolAPI) ContentFrom(addr common.Address) map[string]map[string]*RPCT
 	return content
 }
 
+const (
+	// defaultTxPoolPageSize is the number of transactions in a page of the pool
+	// content if no limit is requested.
+	defaultTxPoolPageSize = 1000
+
+	// maxTxPoolPageSize is the maximum number of transactions in a page of the
+	// pool content.
+	maxTxPoolPageSize = 10000
+)
+
+// TxPoolCursor is the position in the pool content a page starts after. The
+// content is ordered pending before queued, then by sender and nonce.
+type TxPoolCursor struct {
+	Queued bool           `json:"queued"`
+	From   common.Address `json:"from"`
+	Nonce  hexutil.Uint64 `json:"nonce"`
+}
+
+// TxPoolFilter selects the transactions of a page of the pool content, unset
+// fields match all transactions.
+type TxPoolFilter struct {
+	From   *common.Address `json:"from"`
+	To     *common.Address `json:"to"`
+	MinTip *hexutil.Big    `json:"minTip"` // Minimum effective tip at the current base fee
+}
+
+// matches reports whether the transaction of the given sender passes the filter.
+func (f *TxPoolFilter) matches(from common.Address, tx *types.Transaction, baseFee *big.Int) bool {
+	if f == nil {
+		return true
+	}
+	if f.From != nil && *f.From != from {
+		return false
+	}
+	if f.To != nil && (tx.To() == nil || *tx.To() != *f.To) {
+		return false
+	}
+	if f.MinTip != nil && tx.EffectiveGasTipValue(baseFee).Cmp(f.MinTip.ToInt()) < 0 {
+		return false
+	}
+	return true
+}
+
+// pooledTx is a transaction of a page of the pool content.
+type pooledTx struct {
+	queued bool
+	from   common.Address
+	tx     *types.Transaction
+}
+
+// page returns the transactions of the pool content following the cursor that
+// pass the filter, up to the limit, and the cursor of the next page if there
+// are more. The pool is walked in sender order from the cursor on, so a page
+// only visits the senders it returns.
+func (s *TxPoolAPI) page(cursor *TxPoolCursor, limit rpc.DecimalOrHex, filter *TxPoolFilter) ([]pooledTx, *TxPoolCursor) {
+	size := int(limit)
+	if size == 0 {
+		size = defaultTxPoolPageSize
+	}
+	if size > maxTxPoolPageSize {
+		size = maxTxPoolPageSize
+	}
+	var (
+		baseFee = s.b.CurrentHeader().BaseFee
+		txs     []pooledTx
+		next    *TxPoolCursor
+	)
+	for _, queued := range []bool{false, true} {
+		if cursor != nil && cursor.Queued && !queued {
+			continue
+		}
+		// Resume the section at the cursor, or jump to the filtered sender
+		var (
+			after = cursor != nil && cursor.Queued == queued
+			start common.Address
+		)
+		if after {
+			start = cursor.From
+		}
+		if filter != nil && filter.From != nil {
+			if bytes.Compare(filter.From[:], start[:]) < 0 {
+				continue
+			}
+			start = *filter.From
+		}
+		s.b.TxPoolContentRange(queued, start, func(from common.Address, list types.Transactions) bool {
+			if filter != nil && filter.From != nil && from != *filter.From {
+				return false
+			}
+			for _, tx := range list {
+				if after && from == cursor.From && tx.Nonce() <= uint64(cursor.Nonce) {
+					continue
+				}
+				if !filter.matches(from, tx, baseFee) {
+					continue
+				}
+				if len(txs) == size {
+					last := txs[len(txs)-1]
+					next = &TxPoolCursor{Queued: last.queued, From: last.from, Nonce: hexutil.Uint64(last.tx.Nonce())}
+					return false
+				}
+				txs = append(txs, pooledTx{queued: queued, from: from, tx: tx})
+			}
+			return true
+		})
+		if next != nil {
+			return txs, next
+		}
+	}
+	return txs, nil
+}
+
+// TxPoolContentPage is a page of the transactions contained within the
+// transaction pool.
+type TxPoolContentPage struct {
+	Pending map[string]map[string]*RPCTransaction `json:"pending"`
+	Queued  map[string]map[string]*RPCTransaction `json:"queued"`
+	Next    *TxPoolCursor                         `json:"next"` // Nil on the last page
+}
+
+// ContentPaged returns a page of the transactions contained within the
+// transaction pool, starting after the cursor and limited to the ones passing
+// the filter. A nil cursor starts at the beginning.
+func (s *TxPoolAPI) ContentPaged(cursor *TxPoolCursor, limit rpc.DecimalOrHex, filter *TxPoolFilter) *TxPoolContentPage {
+	txs, next := s.page(cursor, limit, filter)
+	page := &TxPoolContentPage{
+		Pending: make(map[string]map[string]*RPCTransaction),
+		Queued:  make(map[string]map[string]*RPCTransaction),
+		Next:    next,
+	}
+	curHeader := s.b.CurrentHeader()
+	for _, ptx := range txs {
+		content := page.Pending
+		if ptx.queued {
+			content = page.Queued
+		}
+		dump := content[ptx.from.Hex()]
+		if dump == nil {
+			dump = make(map[string]*RPCTransaction)
+			content[ptx.from.Hex()] = dump
+		}
+		dump[fmt.Sprintf("%d", ptx.tx.Nonce())] = newRPCPendingTransaction(ptx.tx, curHeader, s.b.ChainConfig())
+	}
+	return page
+}
+
 // Status returns the number of pending and queued transaction in the pool.
 func (s *TxPoolAPI) Status() map[string]hexutil.Uint {
 	pending, queue := s.b.Stats()
@@ -216,6 +388,95 @@ func (s *TxPoolAPI) Status() map[string]hexutil.Uint {
 	}
 }
 
+// TxPoolStats are the size of the transaction pool and the time transactions
+// spend in it.
+type TxPoolStats struct {
+	Pending   hexutil.Uint         `json:"pending"`
+	Queued    hexutil.Uint         `json:"queued"`
+	Latencies core.TxPoolLatencies `json:"latencies"`
+}
+
+// Stats returns the number of pending and queued transactions in the pool and
+// the time the recent transactions took to be included, dropped or promoted.
+func (s *TxPoolAPI) Stats() *TxPoolStats {
+	pending, queue := s.b.Stats()
+	return &TxPoolStats{
+		Pending:   hexutil.Uint(pending),
+		Queued:    hexutil.Uint(queue),
+		Latencies: s.b.TxPoolLatencies(),
+	}
+}
+
+const (
+	// txTagHeader is the HTTP header which may carry a transaction tag.
+	txTagHeader = "X-Tx-Tag"
+
+	// maxTxTagLength is the maximum length of a transaction tag.
+	maxTxTagLength = 256
+)
+
+// TaggedTransaction is the lifecycle status of a tagged transaction.
+type TaggedTransaction struct {
+	Hash        common.Hash     `json:"hash"`
+	Status      string          `json:"status"` // pending, queued, mined, dropped or unknown
+	DropReason  string          `json:"dropReason,omitempty"`
+	Added       time.Time       `json:"added"`
+	Dropped     *time.Time      `json:"dropped,omitempty"`
+	BlockHash   *common.Hash    `json:"blockHash,omitempty"`
+	BlockNumber *hexutil.Uint64 `json:"blockNumber,omitempty"`
+}
+
+// StatusByTag returns the lifecycle status of all transactions submitted with
+// the given tag.
+func (s *TxPoolAPI) StatusByTag(ctx context.Context, tag string) ([]*TaggedTransaction, error) {
+	tagged := s.b.TaggedTxs(tag)
+	results := make([]*TaggedTransaction, 0, len(tagged))
+	for _, tx := range tagged {
+		result := &TaggedTransaction{Hash: tx.Hash, Added: tx.Added}
+		switch tx.Status {
+		case core.TxStatusPending:
+			result.Status = "pending"
+		case core.TxStatusQueued:
+			result.Status = "queued"
+		default:
+			// Out of the pool, either mined or dropped
+			_, blockHash, blockNumber, _, err := s.b.GetTransaction(ctx, tx.Hash)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case blockHash != (common.Hash{}):
+				result.Status = "mined"
+				result.BlockHash, result.BlockNumber = &blockHash, (*hexutil.Uint64)(&blockNumber)
+			case tx.DropReason != "":
+				result.Status = "dropped"
+				result.DropReason, result.Dropped = tx.DropReason, &tx.Dropped
+			default:
+				result.Status = "unknown"
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// DroppedTransaction is the reason a transaction was dropped from the pool.
+type DroppedTransaction struct {
+	Hash    common.Hash `json:"hash"`
+	Reason  string      `json:"reason"`
+	Dropped time.Time   `json:"dropped"`
+}
+
+// Dropped returns why and when a recently dropped transaction left the pool
+// without being included, or null if the pool doesn't remember its drop.
+func (s *TxPoolAPI) Dropped(hash common.Hash) *DroppedTransaction {
+	drop := s.b.DroppedTx(hash)
+	if drop == nil {
+		return nil
+	}
+	return &DroppedTransaction{Hash: hash, Reason: drop.Reason, Dropped: drop.Time}
+}
+
 // Inspect retrieves the content of the transaction pool and flattens it into an
 // easily inspectable list.
 func (s *TxPoolAPI) Inspect() map[string]map[string]map[string]string {
@@ -225,18 +486,11 @@ func (s *TxPoolAPI) Inspect() map[string]map[string]map[string]string {
 	}
 	pending, queue := s.b.TxPoolContent()
 
-	// Define a formatter to flatten a transaction into a string
-	var format = func(tx *types.Transaction) string {
-		if to := tx.To(); to != nil {
-			return fmt.Sprintf("%s: %v wei + %v gas × %v wei", tx.To().Hex(), tx.Value(), tx.Gas(), tx.GasPrice())
-		}
-		return fmt.Sprintf("contract creation: %v wei + %v gas × %v wei", tx.Value(), tx.Gas(), tx.GasPrice())
-	}
 	// Flatten the pending transactions
 	for account, txs := range pending {
 		dump := make(map[string]string)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = format(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = inspectTx(tx)
 		}
 		content["pending"][account.Hex()] = dump
 	}
@@ -244,13 +498,52 @@ func (s *TxPoolAPI) Inspect() map[string]map[string]map[string]string {
 	for account, txs := range queue {
 		dump := make(map[string]string)
 		for _, tx := range txs {
-			dump[fmt.Sprintf("%d", tx.Nonce())] = format(tx)
+			dump[fmt.Sprintf("%d", tx.Nonce())] = inspectTx(tx)
 		}
 		content["queued"][account.Hex()] = dump
 	}
 	return content
 }
 
+// TxPoolInspectPage is a page of the flattened transactions contained within
+// the transaction pool.
+type TxPoolInspectPage struct {
+	Pending map[string]map[string]string `json:"pending"`
+	Queued  map[string]map[string]string `json:"queued"`
+	Next    *TxPoolCursor                `json:"next"` // Nil on the last page
+}
+
+// InspectPaged is the flattened variant of ContentPaged.
+func (s *TxPoolAPI) InspectPaged(cursor *TxPoolCursor, limit rpc.DecimalOrHex, filter *TxPoolFilter) *TxPoolInspectPage {
+	txs, next := s.page(cursor, limit, filter)
+	page := &TxPoolInspectPage{
+		Pending: make(map[string]map[string]string),
+		Queued:  make(map[string]map[string]string),
+		Next:    next,
+	}
+	for _, ptx := range txs {
+		content := page.Pending
+		if ptx.queued {
+			content = page.Queued
+		}
+		dump := content[ptx.from.Hex()]
+		if dump == nil {
+			dump = make(map[string]string)
+			content[ptx.from.Hex()] = dump
+		}
+		dump[fmt.Sprintf("%d", ptx.tx.Nonce())] = inspectTx(ptx.tx)
+	}
+	return page
+}
+
+// inspectTx flattens a transaction into a string.
+func inspectTx(tx *types.Transaction) string {
+	if to := tx.To(); to != nil {
+		return fmt.Sprintf("%s: %v wei + %v gas × %v wei", tx.To().Hex(), tx.Value(), tx.Gas(), tx.GasPrice())
+	}
+	return fmt.Sprintf("contract creation: %v wei + %v gas × %v wei", tx.Value(), tx.Gas(), tx.GasPrice())
+}
+
 // EthereumAccountAPI provides an API to access accounts managed by this node.
 // It offers only methods that can retrieve accounts.
 type EthereumAccountAPI struct {
@@ -600,12 +893,13 @@ func (s *PersonalAccountAPI) Unpair(ctx context.Context, url string, pin string)
 
 // BlockChainAPI provides an API to access Ethereum blockchain data.
 type BlockChainAPI struct {
-	b Backend
+	b            Backend
+	customErrors *ErrorRegistry
 }
 
 // NewBlockChainAPI creates a new Ethereum blockchain API.
-func NewBlockChainAPI(b Backend) *BlockChainAPI {
-	return &BlockChainAPI{b}
+func NewBlockChainAPI(b Backend, customErrors *ErrorRegistry) *BlockChainAPI {
+	return &BlockChainAPI{b, customErrors}
 }
 
 // ChainId is the EIP-155 replay-protection chain id for the current Ethereum chain config.
@@ -628,6 +922,13 @@ func (s *BlockChainAPI) BlockNumber() hexutil.Uint64 {
 // given block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta
 // block numbers are also allowed.
 func (s *BlockChainAPI) GetBalance(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (*hexutil.Big, error) {
+	if h := historicalRPC(ctx, s.b, blockNrOrHash); h != nil {
+		var res hexutil.Big
+		if err := h.Call(ctx, &res, "eth_getBalance", address, BlockArg(blockNrOrHash)); err != nil {
+			return nil, err
+		}
+		return &res, nil
+	}
 	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
@@ -652,13 +953,72 @@ type StorageResult struct {
 	Proof []string     `json:"proof"`
 }
 
+const (
+	// maxProofRequests is the maximum number of accounts proven by a single
+	// eth_getProofs call.
+	maxProofRequests = 1024
+
+	// maxProofStorageKeys is the maximum number of storage keys proven by a
+	// single eth_getProofs call, across all the accounts.
+	maxProofStorageKeys = 8192
+)
+
 // GetProof returns the Merkle-proof for a given account and optionally some storage keys.
 func (s *BlockChainAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*AccountResult, error) {
+	if h := historicalRPC(ctx, s.b, blockNrOrHash); h != nil {
+		var res *AccountResult
+		err := h.Call(ctx, &res, "eth_getProof", address, storageKeys, BlockArg(blockNrOrHash))
+		return res, err
+	}
 	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
 	}
+	return accountProof(state, address, storageKeys)
+}
+
+// ProofRequest is an account to prove along with some of its storage keys.
+type ProofRequest struct {
+	Address     common.Address `json:"address"`
+	StorageKeys []string       `json:"storageKeys"`
+}
 
+// GetProofs returns the Merkle-proofs for many accounts and optionally some of
+// their storage keys, all against the state of the same block.
+func (s *BlockChainAPI) GetProofs(ctx context.Context, requests []ProofRequest, blockNrOrHash rpc.BlockNumberOrHash) ([]*AccountResult, error) {
+	if len(requests) > maxProofRequests {
+		return nil, fmt.Errorf("too many accounts requested: %d > %d", len(requests), maxProofRequests)
+	}
+	var keys int
+	for _, req := range requests {
+		keys += len(req.StorageKeys)
+	}
+	if keys > maxProofStorageKeys {
+		return nil, fmt.Errorf("too many storage keys requested: %d > %d", keys, maxProofStorageKeys)
+	}
+	results := make([]*AccountResult, len(requests))
+	if h := historicalRPC(ctx, s.b, blockNrOrHash); h != nil {
+		for i, req := range requests {
+			if err := h.Call(ctx, &results[i], "eth_getProof", req.Address, req.StorageKeys, BlockArg(blockNrOrHash)); err != nil {
+				return nil, err
+			}
+		}
+		return results, nil
+	}
+	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	for i, req := range requests {
+		if results[i], err = accountProof(state, req.Address, req.StorageKeys); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// accountProof creates the Merkle-proof for an account and some storage keys.
+func accountProof(state *state.StateDB, address common.Address, storageKeys []string) (*AccountResult, error) {
 	storageTrie := state.StorageTrie(address)
 	storageHash := types.EmptyRootHash
 	codeHash := state.GetCodeHash(address)
@@ -706,6 +1066,11 @@ func (s *BlockChainAPI) GetProof(ctx context.Context, address common.Address, st
 // * When blockNr is -1 the chain head is returned.
 // * When blockNr is -2 the pending chain head is returned.
 func (s *BlockChainAPI) GetHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (map[string]interface{}, error) {
+	if h := s.b.HistoricalRPC(); h.Covers(number) {
+		var res map[string]interface{}
+		err := h.Call(ctx, &res, "eth_getHeaderByNumber", number)
+		return res, err
+	}
 	header, err := s.b.HeaderByNumber(ctx, number)
 	if header != nil && err == nil {
 		response := s.rpcMarshalHeader(ctx, header)
@@ -726,6 +1091,11 @@ func (s *BlockChainAPI) GetHeaderByHash(ctx context.Context, hash common.Hash) m
 	if header != nil {
 		return s.rpcMarshalHeader(ctx, header)
 	}
+	if h := s.b.HistoricalRPC(); h != nil {
+		var res map[string]interface{}
+		h.Call(ctx, &res, "eth_getHeaderByHash", hash)
+		return res
+	}
 	return nil
 }
 
@@ -735,6 +1105,11 @@ func (s *BlockChainAPI) GetHeaderByHash(ctx context.Context, hash common.Hash) m
 // * When fullTx is true all transactions in the block are returned, otherwise
 //   only the transaction hash is returned.
 func (s *BlockChainAPI) GetBlockByNumber(ctx context.Context, number rpc.BlockNumber, fullTx bool) (map[string]interface{}, error) {
+	if h := s.b.HistoricalRPC(); h.Covers(number) {
+		var res map[string]interface{}
+		err := h.Call(ctx, &res, "eth_getBlockByNumber", number, fullTx)
+		return res, err
+	}
 	block, err := s.b.BlockByNumber(ctx, number)
 	if block != nil && err == nil {
 		response, err := s.rpcMarshalBlock(ctx, block, true, fullTx)
@@ -756,6 +1131,11 @@ func (s *BlockChainAPI) GetBlockByHash(ctx context.Context, hash common.Hash, fu
 	if block != nil {
 		return s.rpcMarshalBlock(ctx, block, true, fullTx)
 	}
+	if h := s.b.HistoricalRPC(); h != nil && err == nil {
+		var res map[string]interface{}
+		err := h.Call(ctx, &res, "eth_getBlockByHash", hash, fullTx)
+		return res, err
+	}
 	return nil, err
 }
 
@@ -809,6 +1189,11 @@ func (s *BlockChainAPI) GetUncleCountByBlockHash(ctx context.Context, blockHash
 
 // GetCode returns the code stored at the given address in the state for the given block number.
 func (s *BlockChainAPI) GetCode(ctx context.Context, address common.Address, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	if h := historicalRPC(ctx, s.b, blockNrOrHash); h != nil {
+		var res hexutil.Bytes
+		err := h.Call(ctx, &res, "eth_getCode", address, BlockArg(blockNrOrHash))
+		return res, err
+	}
 	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
@@ -821,6 +1206,11 @@ func (s *BlockChainAPI) GetCode(ctx context.Context, address common.Address, blo
 // block number. The rpc.LatestBlockNumber and rpc.PendingBlockNumber meta block
 // numbers are also allowed.
 func (s *BlockChainAPI) GetStorageAt(ctx context.Context, address common.Address, key string, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	if h := historicalRPC(ctx, s.b, blockNrOrHash); h != nil {
+		var res hexutil.Bytes
+		err := h.Call(ctx, &res, "eth_getStorageAt", address, key, BlockArg(blockNrOrHash))
+		return res, err
+	}
 	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
 		return nil, err
@@ -975,36 +1365,6 @@ func DoCall(ctx context.Context, b Backend, args TransactionArgs, blockNrOrHash
 	return result, nil
 }
 
-func newRevertError(result *core.ExecutionResult) *revertError {
-	reason, errUnpack := abi.UnpackRevert(result.Revert())
-	err := errors.New("execution reverted")
-	if errUnpack == nil {
-		err = fmt.Errorf("execution reverted: %v", reason)
-	}
-	return &revertError{
-		error:  err,
-		reason: hexutil.Encode(result.Revert()),
-	}
-}
-
-// revertError is an API error that encompassas an EVM revertal with JSON error
-// code and a binary data blob.
-type revertError struct {
-	error
-	reason string // revert reason hex encoded
-}
-
-// ErrorCode returns the JSON error code for a revertal.
-// See: https://github.com/ethereum/wiki/wiki/JSON-RPC-Error-Codes-Improvement-Proposal
-func (e *revertError) ErrorCode() int {
-	return 3
-}
-
-// ErrorData returns the hex encoded revert reason.
-func (e *revertError) ErrorData() interface{} {
-	return e.reason
-}
-
 // Call executes the given transaction on the state for the given block number.
 //
 // Additionally, the caller can specify a batch of contract for fields overriding.
@@ -1012,13 +1372,22 @@ func (e *revertError) ErrorData() interface{} {
 // Note, this function doesn't make and changes in the state/blockchain and is
 // useful to execute and retrieve values.
 func (s *BlockChainAPI) Call(ctx context.Context, args TransactionArgs, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride) (hexutil.Bytes, error) {
+	if h := historicalRPC(ctx, s.b, blockNrOrHash); h != nil {
+		var res hexutil.Bytes
+		callArgs := []interface{}{args, BlockArg(blockNrOrHash)}
+		if overrides != nil {
+			callArgs = append(callArgs, overrides)
+		}
+		err := h.Call(ctx, &res, "eth_call", callArgs...)
+		return res, err
+	}
 	result, err := DoCall(ctx, s.b, args, blockNrOrHash, overrides, s.b.RPCEVMTimeout(), s.b.RPCGasCap())
 	if err != nil {
 		return nil, err
 	}
 	// If the result contains a revert reason, try to unpack and return it.
 	if len(result.Revert()) > 0 {
-		return nil, newRevertError(result)
+		return nil, newRevertError(s.customErrors, result.Revert())
 	}
 	return result.Return(), result.Err
 }
@@ -1132,7 +1501,7 @@ func DoEstimateGas(ctx context.Context, b Backend, args TransactionArgs, blockNr
 		if failed {
 			if result != nil && result.Err != vm.ErrOutOfGas {
 				if len(result.Revert()) > 0 {
-					return 0, newRevertError(result)
+					return 0, newRevertError(nil, result.Revert())
 				}
 				return 0, result.Err
 			}
@@ -1150,7 +1519,17 @@ func (s *BlockChainAPI) EstimateGas(ctx context.Context, args TransactionArgs, b
 	if blockNrOrHash != nil {
 		bNrOrHash = *blockNrOrHash
 	}
-	return DoEstimateGas(ctx, s.b, args, bNrOrHash, s.b.RPCGasCap())
+	if h := historicalRPC(ctx, s.b, bNrOrHash); h != nil {
+		var res hexutil.Uint64
+		err := h.Call(ctx, &res, "eth_estimateGas", args, BlockArg(bNrOrHash))
+		return res, err
+	}
+	gas, err := DoEstimateGas(ctx, s.b, args, bNrOrHash, s.b.RPCGasCap())
+	if revertErr, ok := err.(*revertError); ok {
+		// Only the builtin errors are known to the estimator, decode the custom ones
+		err = newRevertError(s.customErrors, revertErr.revert)
+	}
+	return gas, err
 }
 
 // RPCMarshalHeader converts the given header to the RPC output .
@@ -1178,6 +1557,15 @@ func RPCMarshalHeader(head *types.Header) map[string]interface{} {
 	if head.BaseFee != nil {
 		result["baseFeePerGas"] = (*hexutil.Big)(head.BaseFee)
 	}
+	if head.WithdrawalsHash != nil {
+		result["withdrawalsRoot"] = head.WithdrawalsHash
+	}
+	if head.BlobGasUsed != nil {
+		result["blobGasUsed"] = hexutil.Uint64(*head.BlobGasUsed)
+	}
+	if head.ExcessBlobGas != nil {
+		result["excessBlobGas"] = hexutil.Uint64(*head.ExcessBlobGas)
+	}
 
 	return result
 }
@@ -1261,6 +1649,13 @@ type RPCTransaction struct {
 	R                *hexutil.Big      `json:"r"`
 	S                *hexutil.Big      `json:"s"`
 
+	// blob-tx only
+	BlobGasFeeCap *hexutil.Big  `json:"maxFeePerBlobGas,omitempty"`
+	BlobHashes    []common.Hash `json:"blobVersionedHashes,omitempty"`
+
+	// setcode-tx only
+	AuthorizationList []types.SetCodeAuthorization `json:"authorizationList,omitempty"`
+
 	// deposit-tx only
 	SourceHash *common.Hash `json:"sourceHash,omitempty"`
 	Mint       *hexutil.Big `json:"mint,omitempty"`
@@ -1321,12 +1716,19 @@ func newRPCTransaction(tx *types.Transaction, blockHash common.Hash, blockNumber
 		al := tx.AccessList()
 		result.Accesses = &al
 		result.ChainID = (*hexutil.Big)(tx.ChainId())
-	case types.DynamicFeeTxType:
+	case types.DynamicFeeTxType, types.BlobTxType, types.SetCodeTxType:
 		al := tx.AccessList()
 		result.Accesses = &al
 		result.ChainID = (*hexutil.Big)(tx.ChainId())
 		result.GasFeeCap = (*hexutil.Big)(tx.GasFeeCap())
 		result.GasTipCap = (*hexutil.Big)(tx.GasTipCap())
+		if tx.Type() == types.BlobTxType {
+			result.BlobGasFeeCap = (*hexutil.Big)(tx.BlobGasFeeCap())
+			result.BlobHashes = tx.BlobHashes()
+		}
+		if tx.Type() == types.SetCodeTxType {
+			result.AuthorizationList = tx.SetCodeAuthorizations()
+		}
 		// if the transaction has been mined, compute the effective gas price
 		if baseFee != nil && blockHash != (common.Hash{}) {
 			// price = min(tip, gasFeeCap - baseFee) + baseFee
@@ -1554,6 +1956,13 @@ func (s *TransactionAPI) GetTransactionCount(ctx context.Context, address common
 		}
 		return (*hexutil.Uint64)(&nonce), nil
 	}
+	if h := historicalRPC(ctx, s.b, blockNrOrHash); h != nil {
+		var res hexutil.Uint64
+		if err := h.Call(ctx, &res, "eth_getTransactionCount", address, BlockArg(blockNrOrHash)); err != nil {
+			return nil, err
+		}
+		return &res, nil
+	}
 	// Resolve block number and use its state to ask for the nonce
 	state, _, err := s.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
 	if state == nil || err != nil {
@@ -1581,9 +1990,23 @@ func (s *TransactionAPI) GetTransactionByHash(ctx context.Context, hash common.H
 	if tx := s.b.GetPoolTransaction(hash); tx != nil {
 		return newRPCPendingTransaction(tx, s.b.CurrentHeader(), s.b.ChainConfig()), nil
 	}
+	// Try the transactions preceding the local chain
+	if h := s.b.HistoricalRPC(); h != nil {
+		var res *RPCTransaction
+		err := h.Call(ctx, &res, "eth_getTransactionByHash", hash)
+		return res, err
+	}
+	// Transaction unknown, return as such unless it may predate the index
+	return nil, s.unindexedErr()
+}
 
-	// Transaction unknown, return as such
-	return nil, nil
+// unindexedErr returns the error reporting a transaction unknown to the node as
+// possibly older than the transactions indexed, nil if the whole chain is.
+func (s *TransactionAPI) unindexedErr() error {
+	if tail := rawdb.ReadTxIndexTail(s.b.ChainDb()); tail != nil && *tail > 0 {
+		return fmt.Errorf("indexed history starts at block %d", *tail)
+	}
+	return nil
 }
 
 // GetRawTransactionByHash returns the bytes of the transaction for the given hash.
@@ -1596,7 +2019,7 @@ func (s *TransactionAPI) GetRawTransactionByHash(ctx context.Context, hash commo
 	if tx == nil {
 		if tx = s.b.GetPoolTransaction(hash); tx == nil {
 			// Transaction not found anywhere, abort
-			return nil, nil
+			return nil, s.unindexedErr()
 		}
 	}
 	// Serialize to RLP and return
@@ -1606,9 +2029,19 @@ func (s *TransactionAPI) GetRawTransactionByHash(ctx context.Context, hash commo
 // GetTransactionReceipt returns the transaction receipt for the given transaction hash.
 func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error) {
 	tx, blockHash, blockNumber, index, err := s.b.GetTransaction(ctx, hash)
-	if err != nil {
+	if err != nil || tx == nil {
+		// Try the transactions preceding the local chain
+		if h := s.b.HistoricalRPC(); h != nil {
+			var res map[string]interface{}
+			err := h.Call(ctx, &res, "eth_getTransactionReceipt", hash)
+			return res, err
+		}
 		// When the transaction doesn't exist, the RPC method should return JSON null
-		// as per specification.
+		// as per specification. Pending ones are polled for, only report the index
+		// window for the transactions unknown to the pool too.
+		if err == nil && s.b.GetPoolTransaction(hash) == nil {
+			return nil, s.unindexedErr()
+		}
 		return nil, nil
 	}
 	receipts, err := s.b.GetReceipts(ctx, blockHash)
@@ -1650,6 +2083,18 @@ func (s *TransactionAPI) GetTransactionReceipt(ctx context.Context, hash common.
 		gasPrice := new(big.Int).Add(header.BaseFee, tx.EffectiveGasTipValue(header.BaseFee))
 		fields["effectiveGasPrice"] = hexutil.Uint64(gasPrice.Uint64())
 	}
+	if tx.Type() == types.BlobTxType {
+		header, err := s.b.HeaderByHash(ctx, blockHash)
+		if err != nil {
+			return nil, err
+		}
+		var excessBlobGas uint64
+		if header.ExcessBlobGas != nil {
+			excessBlobGas = *header.ExcessBlobGas
+		}
+		fields["blobGasUsed"] = hexutil.Uint64(tx.BlobGas())
+		fields["blobGasPrice"] = (*hexutil.Big)(misc.CalcBlobFee(s.b.ChainConfig(), excessBlobGas))
+	}
 	// Assign receipt status or post state.
 	if len(receipt.PostState) > 0 {
 		fields["root"] = hexutil.Bytes(receipt.PostState)
@@ -1681,17 +2126,11 @@ func (s *TransactionAPI) sign(addr common.Address, tx *types.Transaction) (*type
 
 // SubmitTransaction is a helper function that submits tx to txPool and logs a message.
 func SubmitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (common.Hash, error) {
-	// If the transaction fee cap is already specified, ensure the
-	// fee of the given transaction is _reasonable_.
-	if err := checkTxFee(tx.GasPrice(), tx.Gas(), b.RPCTxFeeCap()); err != nil {
+	if err := checkSubmission(b, tx); err != nil {
 		return common.Hash{}, err
 	}
-	if !b.UnprotectedAllowed() && !tx.Protected() {
-		// Ensure only eip155 signed transactions are submitted if EIP155Required is set.
-		return common.Hash{}, errors.New("only replay-protected (EIP-155) transactions allowed over RPC")
-	}
 	if err := b.SendTx(ctx, tx); err != nil {
-		return common.Hash{}, err
+		return common.Hash{}, newPrecheckError(ctx, b, tx, err)
 	}
 	// Print a log with full tx details for manual investigations and interventions
 	signer := types.MakeSigner(b.ChainConfig(), b.CurrentBlock().Number())
@@ -1709,6 +2148,20 @@ func SubmitTransaction(ctx context.Context, b Backend, tx *types.Transaction) (c
 	return tx.Hash(), nil
 }
 
+// checkSubmission verifies the node policies for transactions submitted over RPC.
+func checkSubmission(b Backend, tx *types.Transaction) error {
+	// If the transaction fee cap is already specified, ensure the
+	// fee of the given transaction is _reasonable_.
+	if err := checkTxFee(tx.GasPrice(), tx.Gas(), b.RPCTxFeeCap()); err != nil {
+		return err
+	}
+	if !b.UnprotectedAllowed() && !tx.Protected() {
+		// Ensure only eip155 signed transactions are submitted if EIP155Required is set.
+		return errors.New("only replay-protected (EIP-155) transactions allowed over RPC")
+	}
+	return nil
+}
+
 // SendTransaction creates a transaction for the given argument, sign it and submit it to the
 // transaction pool.
 func (s *TransactionAPI) SendTransaction(ctx context.Context, args TransactionArgs) (common.Hash, error) {
@@ -1760,12 +2213,30 @@ func (s *TransactionAPI) FillTransaction(ctx context.Context, args TransactionAr
 
 // SendRawTransaction will add the signed transaction to the transaction pool.
 // The sender is responsible for signing the transaction and using the correct nonce.
-func (s *TransactionAPI) SendRawTransaction(ctx context.Context, input hexutil.Bytes) (common.Hash, error) {
+//
+// An optional tag, given as parameter or in the X-Tx-Tag header, is attached to
+// the pooled transaction and can be used to query its lifecycle later on.
+func (s *TransactionAPI) SendRawTransaction(ctx context.Context, input hexutil.Bytes, tag *string) (common.Hash, error) {
 	tx := new(types.Transaction)
 	if err := tx.UnmarshalBinary(input); err != nil {
 		return common.Hash{}, err
 	}
-	return SubmitTransaction(ctx, s.b, tx)
+	if tag == nil {
+		if headerTag := rpc.PeerInfoFromContext(ctx).HTTP.Header.Get(txTagHeader); headerTag != "" {
+			tag = &headerTag
+		}
+	}
+	if tag != nil && len(*tag) > maxTxTagLength {
+		return common.Hash{}, fmt.Errorf("transaction tag too long: %d > %d", len(*tag), maxTxTagLength)
+	}
+	hash, err := SubmitTransaction(ctx, s.b, tx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if tag != nil && *tag != "" {
+		s.b.TagTx(hash, *tag)
+	}
+	return hash, nil
 }
 
 // Sign calculates an ECDSA signature for:
@@ -1936,6 +2407,45 @@ func (api *DebugAPI) GetBlockRlp(ctx context.Context, number uint64) (hexutil.By
 	return rlp.EncodeToBytes(block)
 }
 
+// GetRawHeader retrieves the RLP encoding of a single header.
+func (api *DebugAPI) GetRawHeader(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	header, err := api.b.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("header %s not found", blockNrOrHash.String())
+	}
+	return rlp.EncodeToBytes(header)
+}
+
+// GetRawBlock retrieves the RLP encoding of a single block.
+func (api *DebugAPI) GetRawBlock(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (hexutil.Bytes, error) {
+	block, err := api.b.BlockByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, fmt.Errorf("block %s not found", blockNrOrHash.String())
+	}
+	return rlp.EncodeToBytes(block)
+}
+
+// GetRawTransaction returns the binary encoding of the transaction for the given
+// hash, either finalized or pooled.
+func (api *DebugAPI) GetRawTransaction(ctx context.Context, hash common.Hash) (hexutil.Bytes, error) {
+	tx, _, _, _, err := api.b.GetTransaction(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		if tx = api.b.GetPoolTransaction(hash); tx == nil {
+			return nil, nil
+		}
+	}
+	return tx.MarshalBinary()
+}
+
 // GetRawReceipts retrieves the binary-encoded raw receipts of a single block.
 func (api *DebugAPI) GetRawReceipts(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) ([]hexutil.Bytes, error) {
 	var hash common.Hash
@@ -1946,6 +2456,9 @@ func (api *DebugAPI) GetRawReceipts(ctx context.Context, blockNrOrHash rpc.Block
 		if err != nil {
 			return nil, err
 		}
+		if block == nil {
+			return nil, fmt.Errorf("block %s not found", blockNrOrHash.String())
+		}
 		hash = block.Hash()
 	}
 	receipts, err := api.b.GetReceipts(ctx, hash)
@@ -0,0 +1,469 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// txPoolBackend is a backend with a fixed pool content, only serving the calls
+// needed by the paged pool content. It records the senders visited.
+type txPoolBackend struct {
+	Backend
+	head    *types.Header
+	content [2]map[common.Address]types.Transactions
+	visited []common.Address
+}
+
+func (b *txPoolBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+func (b *txPoolBackend) CurrentHeader() *types.Header     { return b.head }
+func (b *txPoolBackend) TxPoolContentRange(queued bool, start common.Address, fn func(addr common.Address, txs types.Transactions) bool) {
+	content := b.content[0]
+	if queued {
+		content = b.content[1]
+	}
+	var senders []common.Address
+	for addr := range content {
+		if bytes.Compare(addr[:], start[:]) >= 0 {
+			senders = append(senders, addr)
+		}
+	}
+	sort.Slice(senders, func(i, j int) bool { return bytes.Compare(senders[i][:], senders[j][:]) < 0 })
+	for _, addr := range senders {
+		b.visited = append(b.visited, addr)
+		if !fn(addr, content[addr]) {
+			return
+		}
+	}
+}
+
+// newTxPoolBackend creates a pool of three senders with two pending and one
+// queued transaction each, tipping 1, 2 and 3 wei by sender.
+func newTxPoolBackend(t *testing.T) (*txPoolBackend, []common.Address) {
+	b := &txPoolBackend{
+		head:    &types.Header{Number: big.NewInt(1), BaseFee: big.NewInt(params.InitialBaseFee)},
+		content: [2]map[common.Address]types.Transactions{{}, {}},
+	}
+	signer := types.LatestSigner(params.TestChainConfig)
+	var senders []common.Address
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		for _, nonce := range []uint64{0, 1, 5} {
+			tx := types.MustSignNewTx(key, signer, &types.DynamicFeeTx{
+				ChainID:   params.TestChainConfig.ChainID,
+				Nonce:     nonce,
+				GasTipCap: big.NewInt(int64(i + 1)),
+				GasFeeCap: big.NewInt(params.InitialBaseFee + 10),
+				Gas:       21000,
+				To:        &common.Address{byte(nonce)},
+			})
+			if nonce == 5 {
+				b.content[1][addr] = append(b.content[1][addr], tx)
+			} else {
+				b.content[0][addr] = append(b.content[0][addr], tx)
+			}
+		}
+		senders = append(senders, addr)
+	}
+	sort.Slice(senders, func(i, j int) bool { return bytes.Compare(senders[i][:], senders[j][:]) < 0 })
+	return b, senders
+}
+
+// Tests that paging through the pool content returns every transaction once,
+// pending before queued and in sender and nonce order.
+func TestTxPoolPaging(t *testing.T) {
+	b, senders := newTxPoolBackend(t)
+	api := NewTxPoolAPI(b)
+
+	type entry struct {
+		queued bool
+		from   common.Address
+		nonce  uint64
+	}
+	var want []entry
+	for i, content := range b.content {
+		for _, from := range senders {
+			for _, tx := range content[from] {
+				want = append(want, entry{i == 1, from, tx.Nonce()})
+			}
+		}
+	}
+	var (
+		have   []entry
+		cursor *TxPoolCursor
+		pages  int
+	)
+	for {
+		txs, next := api.page(cursor, 2, nil)
+		if len(txs) > 2 {
+			t.Fatalf("page %d: too many transactions: have %d, want at most 2", pages, len(txs))
+		}
+		for _, ptx := range txs {
+			have = append(have, entry{ptx.queued, ptx.from, ptx.tx.Nonce()})
+		}
+		pages++
+		if next == nil {
+			break
+		}
+		cursor = next
+	}
+	if len(have) != len(want) {
+		t.Fatalf("transaction count mismatch: have %d, want %d", len(have), len(want))
+	}
+	for i := range want {
+		if have[i] != want[i] {
+			t.Errorf("transaction %d mismatch: have %+v, want %+v", i, have[i], want[i])
+		}
+	}
+	if pages != 5 {
+		t.Errorf("page count mismatch: have %d, want %d", pages, 5)
+	}
+}
+
+// Tests that the pool content pages are filtered, and that filtering by sender
+// doesn't walk the whole pool.
+func TestTxPoolPagingFilter(t *testing.T) {
+	b, senders := newTxPoolBackend(t)
+	api := NewTxPoolAPI(b)
+
+	// Filter by sender, which must not walk the other senders
+	txs, next := api.page(nil, 0, &TxPoolFilter{From: &senders[1]})
+	if next != nil || len(txs) != 3 {
+		t.Fatalf("sender filter mismatch: have %d transactions, next %v, want 3 and none", len(txs), next)
+	}
+	for _, ptx := range txs {
+		if ptx.from != senders[1] {
+			t.Errorf("transaction of unfiltered sender %x", ptx.from)
+		}
+	}
+	// Each section jumps to the sender and stops at the next one
+	if want := []common.Address{senders[1], senders[2], senders[1], senders[2]}; !reflect.DeepEqual(b.visited, want) {
+		t.Errorf("visited senders mismatch: have %x, want %x", b.visited, want)
+	}
+	// Filter by recipient, which matches one pending transaction per sender
+	to := common.Address{1}
+	if txs, _ := api.page(nil, 0, &TxPoolFilter{To: &to}); len(txs) != len(senders) {
+		t.Errorf("recipient filter mismatch: have %d transactions, want %d", len(txs), len(senders))
+	}
+	// Filter by tip, which leaves the transactions of the top two tippers
+	if txs, _ := api.page(nil, 0, &TxPoolFilter{MinTip: (*hexutil.Big)(big.NewInt(2))}); len(txs) != 6 {
+		t.Errorf("tip filter mismatch: have %d transactions, want %d", len(txs), 6)
+	}
+	// Resuming past the filtered sender yields nothing
+	cursor := &TxPoolCursor{Queued: true, From: senders[2]}
+	if txs, _ := api.page(cursor, 0, &TxPoolFilter{From: &senders[1]}); len(txs) != 0 {
+		t.Errorf("resumed sender filter mismatch: have %d transactions, want 0", len(txs))
+	}
+}
+
+// txLookupBackend is a backend with an empty chain and pool, only serving the
+// calls needed by the transaction lookups.
+type txLookupBackend struct {
+	Backend
+	db   ethdb.Database
+	pool map[common.Hash]*types.Transaction
+}
+
+func (b *txLookupBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+func (b *txLookupBackend) ChainDb() ethdb.Database          { return b.db }
+func (b *txLookupBackend) HistoricalRPC() *HistoricalRPC    { return nil }
+func (b *txLookupBackend) GetPoolTransaction(hash common.Hash) *types.Transaction {
+	return b.pool[hash]
+}
+func (b *txLookupBackend) GetTransaction(ctx context.Context, hash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	return nil, common.Hash{}, 0, 0, nil
+}
+
+// Tests that lookups of unknown transactions report the start of the indexed
+// history once the transaction index is pruned, but not for pooled ones.
+func TestTransactionLookupIndexTail(t *testing.T) {
+	b := &txLookupBackend{db: rawdb.NewMemoryDatabase(), pool: make(map[common.Hash]*types.Transaction)}
+	api := NewTransactionAPI(b, nil)
+
+	pooled := types.NewTransaction(0, common.Address{}, big.NewInt(1), params.TxGas, big.NewInt(1), nil)
+	b.pool[pooled.Hash()] = pooled
+
+	// Unknown transactions are null while the whole chain is indexed
+	if tx, err := api.GetTransactionByHash(context.Background(), common.Hash{1}); tx != nil || err != nil {
+		t.Fatalf("unknown transaction mismatch on full index: %v, %v", tx, err)
+	}
+	rawdb.WriteTxIndexTail(b.db, 100)
+
+	want := "indexed history starts at block 100"
+	if _, err := api.GetTransactionByHash(context.Background(), common.Hash{1}); err == nil || err.Error() != want {
+		t.Errorf("transaction error mismatch: have %v, want %q", err, want)
+	}
+	if _, err := api.GetRawTransactionByHash(context.Background(), common.Hash{1}); err == nil || err.Error() != want {
+		t.Errorf("raw transaction error mismatch: have %v, want %q", err, want)
+	}
+	if _, err := api.GetTransactionReceipt(context.Background(), common.Hash{1}); err == nil || err.Error() != want {
+		t.Errorf("receipt error mismatch: have %v, want %q", err, want)
+	}
+	// Pending transactions have no receipt yet, without being out of the window
+	if receipt, err := api.GetTransactionReceipt(context.Background(), pooled.Hash()); receipt != nil || err != nil {
+		t.Errorf("pending receipt mismatch: %v, %v", receipt, err)
+	}
+}
+
+// Tests that eth_getProofs refuses requests exceeding the number of accounts or
+// the total number of storage keys proven in a single call.
+func TestGetProofsLimits(t *testing.T) {
+	api := NewBlockChainAPI(nil, nil)
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+
+	if _, err := api.GetProofs(context.Background(), make([]ProofRequest, maxProofRequests+1), latest); err == nil {
+		t.Errorf("too many accounts accepted")
+	}
+	requests := make([]ProofRequest, 2)
+	requests[0].StorageKeys = make([]string, maxProofStorageKeys/2)
+	requests[1].StorageKeys = make([]string, maxProofStorageKeys/2+1)
+	if _, err := api.GetProofs(context.Background(), requests, latest); err == nil {
+		t.Errorf("too many storage keys accepted")
+	}
+}
+
+// evmBackend is a backend with a fixed head and state, only serving the calls
+// needed to execute messages on top of them. Every state request is answered
+// with a fresh copy of the state.
+type evmBackend struct {
+	Backend
+	config *params.ChainConfig
+	head   *types.Header
+	state  *state.StateDB
+}
+
+func (b *evmBackend) ChainConfig() *params.ChainConfig { return b.config }
+func (b *evmBackend) CurrentHeader() *types.Header     { return b.head }
+func (b *evmBackend) RPCGasCap() uint64                { return 50_000_000 }
+func (b *evmBackend) RPCEVMTimeout() time.Duration     { return 0 }
+func (b *evmBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return big.NewInt(params.GWei), nil
+}
+func (b *evmBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	return b.state.GetNonce(addr), nil
+}
+func (b *evmBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	return types.NewBlockWithHeader(b.head), nil
+}
+func (b *evmBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	return b.state.Copy(), b.head, nil
+}
+func (b *evmBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config) (*vm.EVM, func() error, error) {
+	context := core.NewEVMBlockContext(header, nil, &header.Coinbase)
+	context.L1CostFunc = core.NewL1CostFunc(b.config, state)
+	return vm.NewEVM(context, core.NewEVMTxContext(msg), state, b.config, *vmConfig), func() error { return nil }, nil
+}
+
+func newEVMBackend(t *testing.T, config *params.ChainConfig) *evmBackend {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	return &evmBackend{
+		config: config,
+		head: &types.Header{
+			Number:     big.NewInt(1),
+			Difficulty: big.NewInt(0),
+			GasLimit:   30_000_000,
+			BaseFee:    big.NewInt(params.InitialBaseFee),
+		},
+		state: statedb,
+	}
+}
+
+// counterCode increments the first storage slot of the contract on every call.
+var counterCode = hexutil.Bytes{
+	byte(vm.PUSH1), 0, byte(vm.SLOAD), byte(vm.PUSH1), 1, byte(vm.ADD),
+	byte(vm.PUSH1), 0, byte(vm.SSTORE), byte(vm.STOP),
+}
+
+// Tests that sandbox transactions persist their state changes for the later
+// calls of the sandbox, while sandbox calls and other sandboxes don't see them.
+func TestSandboxPersistence(t *testing.T) {
+	var (
+		b       = newEVMBackend(t, params.TestChainConfig)
+		api     = NewSandboxAPI(b, nil)
+		ctx     = context.Background()
+		latest  = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		from    = common.Address{0xaa}
+		counter = common.Address{0xcc}
+	)
+	b.state.SetBalance(from, big.NewInt(params.Ether))
+	b.state.SetCode(counter, counterCode)
+
+	id, err := api.SandboxOpen(ctx, latest, nil)
+	if err != nil {
+		t.Fatalf("failed to open sandbox: %v", err)
+	}
+	count := func(id rpc.ID) uint64 {
+		t.Helper()
+		account, err := api.SandboxAccount(id, counter, []common.Hash{{}})
+		if err != nil {
+			t.Fatalf("failed to read counter: %v", err)
+		}
+		return account.Storage[common.Hash{}].Big().Uint64()
+	}
+	value := (*hexutil.Big)(big.NewInt(1000))
+	args := TransactionArgs{From: &from, To: &counter, Value: value}
+
+	// Calls execute, but leave the sandbox as it was
+	for i := 0; i < 2; i++ {
+		res, err := api.SandboxCall(ctx, id, args)
+		if err != nil || res.Failed {
+			t.Fatalf("call %d failed: %v, %+v", i, err, res)
+		}
+	}
+	if have := count(id); have != 0 {
+		t.Errorf("counter changed by calls: have %d, want 0", have)
+	}
+	// Transactions are observed by the later calls
+	for i := 0; i < 2; i++ {
+		res, err := api.SandboxTransact(ctx, id, args)
+		if err != nil || res.Failed {
+			t.Fatalf("transaction %d failed: %v, %+v", i, err, res)
+		}
+	}
+	if have := count(id); have != 2 {
+		t.Errorf("counter mismatch after transactions: have %d, want 2", have)
+	}
+	account, _ := api.SandboxAccount(id, counter, nil)
+	if account.Balance.ToInt().Cmp(big.NewInt(2000)) != 0 {
+		t.Errorf("balance mismatch after transactions: have %v, want 2000", account.Balance)
+	}
+	// Other sandboxes and the chain state are left untouched
+	other, err := api.SandboxOpen(ctx, latest, nil)
+	if err != nil {
+		t.Fatalf("failed to open sandbox: %v", err)
+	}
+	if have := count(other); have != 0 {
+		t.Errorf("counter leaked into other sandbox: have %d, want 0", have)
+	}
+	if have := b.state.GetState(counter, common.Hash{}); have != (common.Hash{}) {
+		t.Errorf("counter leaked into chain state: have %x", have)
+	}
+	// Closed sandboxes are gone
+	if err := api.SandboxClose(id); err != nil {
+		t.Fatalf("failed to close sandbox: %v", err)
+	}
+	if _, err := api.SandboxCall(ctx, id, args); err != errSandboxNotFound {
+		t.Errorf("closed sandbox error mismatch: have %v, want %v", err, errSandboxNotFound)
+	}
+}
+
+// Tests that the gas of a sandbox is capped by its remaining allowance, and
+// that exhausted sandboxes refuse further calls.
+func TestSandboxGasLimit(t *testing.T) {
+	var (
+		b       = newEVMBackend(t, params.TestChainConfig)
+		api     = NewSandboxAPI(b, nil)
+		ctx     = context.Background()
+		from    = common.Address{0xaa}
+		counter = common.Address{0xcc}
+	)
+	b.state.SetCode(counter, counterCode)
+
+	id, err := api.SandboxOpen(ctx, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), nil)
+	if err != nil {
+		t.Fatalf("failed to open sandbox: %v", err)
+	}
+	sb, _ := api.sandbox(id)
+
+	// Calls are capped to the remaining allowance of the sandbox, the counter
+	// update running out of gas instead of using the requested gas
+	remaining := params.TxGas + params.SstoreSetGasEIP2200/2
+	sb.gasUsed = sandboxGasLimit - remaining
+	gas := hexutil.Uint64(10 * params.TxGas)
+
+	res, err := api.SandboxTransact(ctx, id, TransactionArgs{From: &from, To: &counter, Gas: &gas})
+	if err != nil {
+		t.Fatalf("transaction failed: %v", err)
+	}
+	if !res.Failed || uint64(res.GasUsed) != remaining {
+		t.Fatalf("capped transaction mismatch: failed %v, gas used %d, want failure using %d", res.Failed, res.GasUsed, remaining)
+	}
+	if sb.gasUsed != sandboxGasLimit {
+		t.Fatalf("gas used mismatch: have %d, want %d", sb.gasUsed, uint64(sandboxGasLimit))
+	}
+	// Exhausted sandboxes refuse calls and transactions alike
+	if _, err := api.SandboxCall(ctx, id, TransactionArgs{From: &from, To: &counter}); err != errSandboxGasLimit {
+		t.Errorf("call error mismatch: have %v, want %v", err, errSandboxGasLimit)
+	}
+	if _, err := api.SandboxTransact(ctx, id, TransactionArgs{From: &from, To: &counter}); err != errSandboxGasLimit {
+		t.Errorf("transaction error mismatch: have %v, want %v", err, errSandboxGasLimit)
+	}
+}
+
+// Tests that the number of open sandboxes is limited, and that closed or idle
+// ones make room for new sandboxes.
+func TestSandboxSessionLimit(t *testing.T) {
+	var (
+		api    = NewSandboxAPI(newEVMBackend(t, params.TestChainConfig), nil)
+		ctx    = context.Background()
+		latest = rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		ids    []rpc.ID
+	)
+	for i := 0; i < maxSandboxSessions; i++ {
+		id, err := api.SandboxOpen(ctx, latest, nil)
+		if err != nil {
+			t.Fatalf("failed to open sandbox %d: %v", i, err)
+		}
+		ids = append(ids, id)
+	}
+	if _, err := api.SandboxOpen(ctx, latest, nil); err != errTooManySandboxes {
+		t.Fatalf("sandbox beyond limit error mismatch: have %v, want %v", err, errTooManySandboxes)
+	}
+	// Closing a sandbox makes room for another one
+	if err := api.SandboxClose(ids[0]); err != nil {
+		t.Fatalf("failed to close sandbox: %v", err)
+	}
+	if _, err := api.SandboxOpen(ctx, latest, nil); err != nil {
+		t.Fatalf("failed to open sandbox after close: %v", err)
+	}
+	if _, err := api.SandboxOpen(ctx, latest, nil); err != errTooManySandboxes {
+		t.Fatalf("sandbox beyond limit error mismatch: have %v, want %v", err, errTooManySandboxes)
+	}
+	// Idle sandboxes expire and make room too
+	api.mu.Lock()
+	api.sandboxes[ids[1]].used = time.Now().Add(-sandboxIdleTimeout - time.Second)
+	api.mu.Unlock()
+
+	if _, err := api.SandboxOpen(ctx, latest, nil); err != nil {
+		t.Fatalf("failed to open sandbox after expiry: %v", err)
+	}
+	if _, err := api.sandbox(ids[1]); err != errSandboxNotFound {
+		t.Errorf("expired sandbox error mismatch: have %v, want %v", err, errSandboxNotFound)
+	}
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/params"
@@ -45,13 +46,15 @@ type Backend interface {
 
 	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
 	FeeHistory(ctx context.Context, blockCount int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error)
+	L1FeeHistory(ctx context.Context, oldest *big.Int, blocks int) (*gasprice.L1FeeHistory, error)
 	ChainDb() ethdb.Database
 	AccountManager() *accounts.Manager
 	ExtRPCEnabled() bool
-	RPCGasCap() uint64            // global gas cap for eth_call over rpc: DoS protection
-	RPCEVMTimeout() time.Duration // global timeout for eth_call over rpc: DoS protection
-	RPCTxFeeCap() float64         // global tx fee cap for all transaction related APIs
-	UnprotectedAllowed() bool     // allows only for EIP155 transactions.
+	RPCGasCap() uint64             // global gas cap for eth_call over rpc: DoS protection
+	RPCEVMTimeout() time.Duration  // global timeout for eth_call over rpc: DoS protection
+	RPCTxFeeCap() float64          // global tx fee cap for all transaction related APIs
+	UnprotectedAllowed() bool      // allows only for EIP155 transactions.
+	HistoricalRPC() *HistoricalRPC // proxy for blocks preceding the local chain, nil if none
 
 	// Blockchain API
 	SetHead(number uint64)
@@ -72,9 +75,12 @@ type Backend interface {
 	SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription
 	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
 	SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) event.Subscription
+	SubscribeChainSafeEvent(ch chan<- core.ChainSafeEvent) event.Subscription
+	SubscribeChainFinalizedEvent(ch chan<- core.ChainFinalizedEvent) event.Subscription
 
 	// Transaction pool API
 	SendTx(ctx context.Context, signedTx *types.Transaction) error
+	SendTxConditional(ctx context.Context, signedTx *types.Transaction, cond *core.TxConditional) error
 	GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error)
 	GetPoolTransactions() (types.Transactions, error)
 	GetPoolTransaction(txHash common.Hash) *types.Transaction
@@ -82,7 +88,13 @@ type Backend interface {
 	Stats() (pending int, queued int)
 	TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions)
 	TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions)
+	TxPoolContentRange(queued bool, start common.Address, fn func(addr common.Address, txs types.Transactions) bool)
+	TagTx(hash common.Hash, tag string)
+	TaggedTxs(tag string) []core.TaggedTx
+	DroppedTx(hash common.Hash) *core.TxDrop
+	TxPoolLatencies() core.TxPoolLatencies
 	SubscribeNewTxsEvent(chan<- core.NewTxsEvent) event.Subscription
+	SubscribeDropTxsEvent(chan<- core.DropTxsEvent) event.Subscription
 
 	// Filter API
 	BloomStatus() (uint64, uint64)
@@ -98,13 +110,14 @@ type Backend interface {
 
 func GetAPIs(apiBackend Backend) []rpc.API {
 	nonceLock := new(AddrLocker)
-	return []rpc.API{
+	customErrors := NewErrorRegistry()
+	apis := []rpc.API{
 		{
 			Namespace: "eth",
 			Service:   NewEthereumAPI(apiBackend),
 		}, {
 			Namespace: "eth",
-			Service:   NewBlockChainAPI(apiBackend),
+			Service:   NewBlockChainAPI(apiBackend, customErrors),
 		}, {
 			Namespace: "eth",
 			Service:   NewTransactionAPI(apiBackend, nonceLock),
@@ -120,6 +133,25 @@ func GetAPIs(apiBackend Backend) []rpc.API {
 		}, {
 			Namespace: "personal",
 			Service:   NewPersonalAccountAPI(apiBackend, nonceLock),
+		}, {
+			Namespace: "admin",
+			Service:   NewErrorABIAPI(customErrors),
 		},
 	}
+	// The authenticated APIs are only served next to the engine API, so that
+	// nodes not driven by a consensus client don't open the auth listener.
+	if apiBackend.ChainConfig().TerminalTotalDifficulty != nil {
+		apis = append(apis, []rpc.API{
+			{
+				Namespace:     "debug",
+				Service:       NewSandboxAPI(apiBackend, customErrors),
+				Authenticated: true,
+			}, {
+				Namespace:     "eth",
+				Service:       NewConditionalTxAPI(apiBackend),
+				Authenticated: true,
+			},
+		}...)
+	}
+	return apis
 }
@@ -0,0 +1,102 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// ForkStatus is the activation status of a fork at a given block.
+type ForkStatus struct {
+	Name   string       `json:"name"`
+	Block  *hexutil.Big `json:"block"` // nil if not scheduled
+	Active bool         `json:"active"`
+}
+
+// RollupConfig contains the rollup parameters in effect.
+type RollupConfig struct {
+	BaseFeeRecipient      common.Address `json:"baseFeeRecipient"`
+	L1FeeRecipient        common.Address `json:"l1FeeRecipient"`
+	L1BlockAddress        common.Address `json:"l1BlockAddress"`        // Predeploy holding the L1 fee parameters
+	GasPriceOracleAddress common.Address `json:"gasPriceOracleAddress"` // Predeploy exposing the L1 fee calculation
+	DepositTxType         hexutil.Uint64 `json:"depositTxType"`
+}
+
+// ChainConfigResult is the resolved chain configuration at a given block.
+type ChainConfigResult struct {
+	Config      *params.ChainConfig `json:"config"`
+	BlockNumber hexutil.Uint64      `json:"blockNumber"`
+	BlockHash   common.Hash         `json:"blockHash"`
+	Timestamp   hexutil.Uint64      `json:"timestamp"`
+	Forks       []ForkStatus        `json:"forks"`
+	Merged      bool                `json:"merged"`           // Whether the block is past the merge
+	Rollup      *RollupConfig       `json:"rollup,omitempty"` // Rollup parameters, nil on L1 chains
+}
+
+// ChainConfig returns the chain configuration along with the activation status
+// of every fork at the given block, defaulting to the latest block.
+func (api *DebugAPI) ChainConfig(ctx context.Context, blockNrOrHash *rpc.BlockNumberOrHash) (*ChainConfigResult, error) {
+	if blockNrOrHash == nil {
+		latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+		blockNrOrHash = &latest
+	}
+	header, err := api.b.HeaderByNumberOrHash(ctx, *blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("block %v not found", blockNrOrHash)
+	}
+	config := api.b.ChainConfig()
+	result := &ChainConfigResult{
+		Config:      config,
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		BlockHash:   header.Hash(),
+		Timestamp:   hexutil.Uint64(header.Time),
+		Merged:      config.TerminalTotalDifficulty != nil && header.Difficulty.Sign() == 0,
+	}
+	for _, fork := range config.Forks() {
+		block := fork.Block
+		if fork.Name == "petersburg" && block == nil {
+			// Petersburg defaults to the Constantinople block.
+			block = config.ConstantinopleBlock
+		}
+		result.Forks = append(result.Forks, ForkStatus{
+			Name:   fork.Name,
+			Block:  (*hexutil.Big)(block),
+			Active: block != nil && block.Cmp(header.Number) <= 0,
+		})
+	}
+	if config.Optimism != nil {
+		result.Rollup = &RollupConfig{
+			BaseFeeRecipient:      config.Optimism.BaseFeeRecipient,
+			L1FeeRecipient:        config.Optimism.L1FeeRecipient,
+			L1BlockAddress:        core.L1BlockAddr,
+			GasPriceOracleAddress: core.OVM_GasPriceOracleAddr,
+			DepositTxType:         hexutil.Uint64(types.DepositTxType),
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// chainConfigBackend is a backend with a fixed head, only serving the calls
+// needed by the chain config resolution.
+type chainConfigBackend struct {
+	Backend
+	config *params.ChainConfig
+	head   *types.Header
+}
+
+func (b *chainConfigBackend) ChainConfig() *params.ChainConfig { return b.config }
+func (b *chainConfigBackend) HeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Header, error) {
+	return b.head, nil
+}
+
+// Tests that the resolved chain config reports the activation of the rollup
+// forks and parameters next to the mainline ones.
+func TestDebugChainConfig(t *testing.T) {
+	config := *params.TestChainConfig
+	config.Optimism = &params.OptimismConfig{BaseFeeRecipient: common.Address{1}, L1FeeRecipient: common.Address{2}}
+	config.TxLimits = &params.TxLimitsConfig{MaxCalldata: 100, Block: big.NewInt(5)}
+	config.BlobTx = &params.BlobTxConfig{Block: big.NewInt(20)}
+	config.SetCodeTx = &params.SetCodeTxConfig{Block: big.NewInt(10)}
+
+	b := &chainConfigBackend{
+		config: &config,
+		head:   &types.Header{Number: big.NewInt(10), Difficulty: big.NewInt(1), Time: 100},
+	}
+	result, err := NewDebugAPI(b).ChainConfig(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("failed to resolve chain config: %v", err)
+	}
+	if result.BlockNumber != 10 || result.BlockHash != b.head.Hash() || result.Timestamp != 100 || result.Merged {
+		t.Errorf("block mismatch: have #%d %x at %d (merged %v)", result.BlockNumber, result.BlockHash, result.Timestamp, result.Merged)
+	}
+	forks := make(map[string]ForkStatus)
+	for _, fork := range result.Forks {
+		forks[fork.Name] = fork
+	}
+	tests := []struct {
+		name   string
+		block  *big.Int
+		active bool
+	}{
+		{"petersburg", big.NewInt(0), true},
+		{"london", big.NewInt(0), true},
+		{"mergeNetsplit", nil, false},
+		{"txLimits", big.NewInt(5), true},
+		{"setCodeTx", big.NewInt(10), true},
+		{"blobTx", big.NewInt(20), false},
+		{"feeCurrency", nil, false},
+		{"verkle", nil, false},
+	}
+	for _, tt := range tests {
+		fork, ok := forks[tt.name]
+		if !ok {
+			t.Errorf("fork %s missing", tt.name)
+			continue
+		}
+		if (tt.block == nil) != (fork.Block == nil) || (tt.block != nil && fork.Block.ToInt().Cmp(tt.block) != 0) {
+			t.Errorf("fork %s block mismatch: have %v, want %v", tt.name, fork.Block, tt.block)
+		}
+		if fork.Active != tt.active {
+			t.Errorf("fork %s activation mismatch: have %v, want %v", tt.name, fork.Active, tt.active)
+		}
+	}
+	if result.Rollup == nil {
+		t.Fatal("rollup parameters missing")
+	}
+	if result.Rollup.BaseFeeRecipient != config.Optimism.BaseFeeRecipient || result.Rollup.L1BlockAddress != core.L1BlockAddr {
+		t.Errorf("rollup parameters mismatch: %+v", result.Rollup)
+	}
+	// Chains without the rollup config have no rollup parameters
+	b.config = params.TestChainConfig
+	if result, err = NewDebugAPI(b).ChainConfig(context.Background(), nil); err != nil {
+		t.Fatalf("failed to resolve chain config: %v", err)
+	}
+	if result.Rollup != nil {
+		t.Errorf("rollup parameters on non-rollup chain: %+v", result.Rollup)
+	}
+}
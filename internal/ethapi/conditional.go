@@ -0,0 +1,117 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// KnownAccountArgs is the expected state of an account a conditional
+// transaction depends on.
+type KnownAccountArgs struct {
+	Nonce   *hexutil.Uint64             `json:"nonce,omitempty"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// TransactionConditional are the preconditions for including a transaction.
+type TransactionConditional struct {
+	BlockNumberMin *hexutil.Big                        `json:"blockNumberMin,omitempty"`
+	BlockNumberMax *hexutil.Big                        `json:"blockNumberMax,omitempty"`
+	TimestampMin   *hexutil.Uint64                     `json:"timestampMin,omitempty"`
+	TimestampMax   *hexutil.Uint64                     `json:"timestampMax,omitempty"`
+	KnownAccounts  map[common.Address]KnownAccountArgs `json:"knownAccounts,omitempty"`
+}
+
+// toTxConditional converts the arguments into the conditions of the pool.
+func (args *TransactionConditional) toTxConditional() *core.TxConditional {
+	cond := &core.TxConditional{
+		BlockNumberMin: (*big.Int)(args.BlockNumberMin),
+		BlockNumberMax: (*big.Int)(args.BlockNumberMax),
+		TimestampMin:   (*uint64)(args.TimestampMin),
+		TimestampMax:   (*uint64)(args.TimestampMax),
+	}
+	if len(args.KnownAccounts) > 0 {
+		cond.KnownAccounts = make(map[common.Address]core.KnownAccount, len(args.KnownAccounts))
+		for addr, account := range args.KnownAccounts {
+			cond.KnownAccounts[addr] = core.KnownAccount{
+				Nonce:   (*uint64)(account.Nonce),
+				Storage: account.Storage,
+			}
+		}
+	}
+	return cond
+}
+
+// conditionError is an API error for a transaction rejected because of its
+// conditions, carrying the failed condition as structured data.
+type conditionError struct {
+	error
+	data *core.ConditionError
+}
+
+// ErrorCode returns the JSON error code for rejected conditional transactions.
+func (e *conditionError) ErrorCode() int {
+	return -32003
+}
+
+// ErrorData returns the failed condition.
+func (e *conditionError) ErrorData() interface{} {
+	return e.data
+}
+
+// ConditionalTxAPI offers submission of transactions with inclusion conditions,
+// e.g. for account abstraction bundlers. It is only available on the
+// authenticated endpoint.
+type ConditionalTxAPI struct {
+	b Backend
+}
+
+// NewConditionalTxAPI creates a new conditional transaction API.
+func NewConditionalTxAPI(b Backend) *ConditionalTxAPI {
+	return &ConditionalTxAPI{b: b}
+}
+
+// SendRawTransactionConditional adds the signed transaction to the pool, to be
+// included only in blocks satisfying the given conditions. Transactions whose
+// conditions fail are rejected with the failed condition as error data, or
+// dropped from the pool if they fail later on.
+func (api *ConditionalTxAPI) SendRawTransactionConditional(ctx context.Context, input hexutil.Bytes, args TransactionConditional) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	if err := checkSubmission(api.b, tx); err != nil {
+		return common.Hash{}, err
+	}
+	if err := api.b.SendTxConditional(ctx, tx, args.toTxConditional()); err != nil {
+		var condErr *core.ConditionError
+		if errors.As(err, &condErr) {
+			return common.Hash{}, &conditionError{error: err, data: condErr}
+		}
+		return common.Hash{}, newPrecheckError(ctx, api.b, tx, err)
+	}
+	log.Info("Submitted conditional transaction", "hash", tx.Hash().Hex(), "nonce", tx.Nonce(), "recipient", tx.To(), "value", tx.Value())
+	return tx.Hash(), nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// estimateGasDetailedResult is the gas estimate of a transaction along with
+// the L1 data fee it is charged on rollup chains.
+type estimateGasDetailedResult struct {
+	Gas       hexutil.Uint64 `json:"gas"`
+	L1Fee     *hexutil.Big   `json:"l1Fee"`
+	L1GasUsed hexutil.Uint64 `json:"l1GasUsed"` // Rollup data gas plus the fee overhead
+	TotalFee  *hexutil.Big   `json:"totalFeeWei"`
+}
+
+// EstimateGasDetailed estimates the gas of the given transaction like
+// EstimateGas, and returns the L1 data fee of the transaction at the current L1
+// pricing along with the total fee. The fee fields left unspecified are filled
+// in like for sending the transaction, and the signature is accounted for as
+// non-zero bytes.
+func (s *BlockChainAPI) EstimateGasDetailed(ctx context.Context, args TransactionArgs, blockNrOrHash *rpc.BlockNumberOrHash) (*estimateGasDetailedResult, error) {
+	bNrOrHash := rpc.BlockNumberOrHashWithNumber(rpc.PendingBlockNumber)
+	if blockNrOrHash != nil {
+		bNrOrHash = *blockNrOrHash
+	}
+	if args.From == nil {
+		args.From = new(common.Address)
+	}
+	gas, err := DoEstimateGas(ctx, s.b, args, bNrOrHash, s.b.RPCGasCap())
+	if err != nil {
+		return nil, err
+	}
+	args.Gas = &gas
+	if err := args.setDefaults(ctx, s.b); err != nil {
+		return nil, err
+	}
+	state, header, err := s.b.StateAndHeaderByNumberOrHash(ctx, bNrOrHash)
+	if state == nil || err != nil {
+		return nil, err
+	}
+	tx := args.toTransaction()
+
+	result := &estimateGasDetailedResult{Gas: gas, L1Fee: new(hexutil.Big)}
+	if s.b.ChainConfig().Optimism != nil {
+		overhead := state.GetState(core.OVM_GasPriceOracleAddr, core.OverheadSlot).Big()
+		result.L1GasUsed = hexutil.Uint64(tx.RollupDataGas() + overhead.Uint64())
+		if fee := core.NewL1CostFunc(s.b.ChainConfig(), state)(header.Number.Uint64(), tx); fee != nil {
+			result.L1Fee = (*hexutil.Big)(fee)
+		}
+	}
+	price := tx.GasPrice()
+	if header.BaseFee != nil {
+		price = new(big.Int).Add(tx.EffectiveGasTipValue(header.BaseFee), header.BaseFee)
+	}
+	total := new(big.Int).Mul(price, new(big.Int).SetUint64(uint64(gas)))
+	result.TotalFee = (*hexutil.Big)(total.Add(total, result.L1Fee.ToInt()))
+	return result, nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// estimateArgs returns a fully specified transfer with calldata, leaving only
+// the gas to be estimated.
+func estimateArgs(from common.Address) TransactionArgs {
+	var (
+		to    = common.Address{0xbb}
+		nonce = hexutil.Uint64(0)
+		data  = hexutil.Bytes{0x00, 0x00, 0x01, 0x02, 0x03, 0x00, 0xff}
+	)
+	return TransactionArgs{
+		From:                 &from,
+		To:                   &to,
+		Value:                (*hexutil.Big)(big.NewInt(1)),
+		Nonce:                &nonce,
+		MaxFeePerGas:         (*hexutil.Big)(big.NewInt(3 * params.GWei)),
+		MaxPriorityFeePerGas: (*hexutil.Big)(big.NewInt(params.GWei)),
+		Data:                 &data,
+	}
+}
+
+// estimatedTx returns the transaction the estimate of args is priced for.
+func estimatedTx(config *params.ChainConfig, args TransactionArgs, gas hexutil.Uint64) *types.Transaction {
+	return types.NewTx(&types.DynamicFeeTx{
+		ChainID:   config.ChainID,
+		Nonce:     uint64(*args.Nonce),
+		GasTipCap: args.MaxPriorityFeePerGas.ToInt(),
+		GasFeeCap: args.MaxFeePerGas.ToInt(),
+		Gas:       uint64(gas),
+		To:        args.To,
+		Value:     args.Value.ToInt(),
+		Data:      *args.Data,
+	})
+}
+
+// Tests that the detailed estimate of a rollup transaction charges the L1 data
+// fee of the oracle parameters on top of the execution fee.
+func TestEstimateGasDetailedRollup(t *testing.T) {
+	config := *params.TestChainConfig
+	config.Optimism = &params.OptimismConfig{}
+
+	b := newEVMBackend(t, &config)
+	from := common.Address{0xaa}
+	b.state.SetBalance(from, big.NewInt(params.Ether))
+	b.state.SetState(core.L1BlockAddr, core.L1BaseFeeSlot, common.BigToHash(big.NewInt(30*params.GWei)))
+	b.state.SetState(core.OVM_GasPriceOracleAddr, core.OverheadSlot, common.BigToHash(big.NewInt(2100)))
+	b.state.SetState(core.OVM_GasPriceOracleAddr, core.ScalarSlot, common.BigToHash(big.NewInt(1_500_000)))
+	b.state.SetState(core.OVM_GasPriceOracleAddr, core.DecimalsSlot, common.BigToHash(big.NewInt(6)))
+
+	args := estimateArgs(from)
+	result, err := NewBlockChainAPI(b, nil).EstimateGasDetailed(context.Background(), args, nil)
+	if err != nil {
+		t.Fatalf("failed to estimate: %v", err)
+	}
+	want := params.TxGas + 3*params.TxDataZeroGas + 4*params.TxDataNonZeroGasEIP2028
+	if uint64(result.Gas) != want {
+		t.Errorf("gas mismatch: have %d, want %d", result.Gas, want)
+	}
+	tx := estimatedTx(&config, args, result.Gas)
+
+	l1Fee := core.NewL1CostFunc(&config, b.state)(b.head.Number.Uint64(), tx)
+	if l1Fee == nil || l1Fee.Sign() == 0 {
+		t.Fatalf("reference L1 fee missing: %v", l1Fee)
+	}
+	if result.L1Fee.ToInt().Cmp(l1Fee) != 0 {
+		t.Errorf("L1 fee mismatch: have %v, want %v", result.L1Fee, l1Fee)
+	}
+	if have, want := uint64(result.L1GasUsed), tx.RollupDataGas()+2100; have != want {
+		t.Errorf("L1 gas mismatch: have %d, want %d", have, want)
+	}
+	// The execution is priced at the base fee plus the tip, within the fee cap
+	total := new(big.Int).Mul(big.NewInt(params.InitialBaseFee+params.GWei), new(big.Int).SetUint64(uint64(result.Gas)))
+	total.Add(total, l1Fee)
+	if result.TotalFee.ToInt().Cmp(total) != 0 {
+		t.Errorf("total fee mismatch: have %v, want %v", result.TotalFee, total)
+	}
+}
+
+// Tests that the detailed estimate of a non-rollup transaction has no L1 data
+// fee, the total fee being the execution fee only.
+func TestEstimateGasDetailedL1(t *testing.T) {
+	b := newEVMBackend(t, params.TestChainConfig)
+	from := common.Address{0xaa}
+	b.state.SetBalance(from, big.NewInt(params.Ether))
+
+	// Oracle parameters must not be charged on chains without the rollup config
+	b.state.SetState(core.L1BlockAddr, core.L1BaseFeeSlot, common.BigToHash(big.NewInt(30*params.GWei)))
+	b.state.SetState(core.OVM_GasPriceOracleAddr, core.ScalarSlot, common.BigToHash(big.NewInt(1)))
+
+	result, err := NewBlockChainAPI(b, nil).EstimateGasDetailed(context.Background(), estimateArgs(from), nil)
+	if err != nil {
+		t.Fatalf("failed to estimate: %v", err)
+	}
+	if result.L1Fee.ToInt().Sign() != 0 || result.L1GasUsed != 0 {
+		t.Errorf("L1 fee charged: %v, %d gas", result.L1Fee, result.L1GasUsed)
+	}
+	total := new(big.Int).Mul(big.NewInt(params.InitialBaseFee+params.GWei), new(big.Int).SetUint64(uint64(result.Gas)))
+	if result.TotalFee.ToInt().Cmp(total) != 0 {
+		t.Errorf("total fee mismatch: have %v, want %v", result.TotalFee, total)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// gasPriceTierBlocks is the number of recent blocks sampled for the tiers.
+	gasPriceTierBlocks = 20
+
+	// Fullness thresholds of the sampled blocks. Below the EIP-1559 target all
+	// transactions paying the base fee fit into the next block; above the busy
+	// threshold, blocks are effectively full and low tips wait for a lull.
+	gasPriceTierTarget = 0.5
+	gasPriceTierBusy   = 0.9
+)
+
+// gasPriceTierPercentiles are the gas-weighted tip percentiles of the sampled
+// blocks used for the slow, standard and fast tiers.
+var gasPriceTierPercentiles = []float64{25, 60, 90}
+
+// gasPriceTier is a fee suggestion with the expected inclusion delay.
+type gasPriceTier struct {
+	MaxPriorityFeePerGas *hexutil.Big   `json:"maxPriorityFeePerGas"`
+	GasPrice             *hexutil.Big   `json:"gasPrice"`      // tip plus the base fee of the next block
+	ExpectedDelay        hexutil.Uint64 `json:"expectedDelay"` // in blocks
+}
+
+type gasPriceTiersResult struct {
+	BaseFee  *hexutil.Big  `json:"baseFeePerGas,omitempty"` // of the next block
+	Fullness float64       `json:"fullness"`                // average gas used ratio of the sampled blocks
+	Slow     *gasPriceTier `json:"slow"`
+	Standard *gasPriceTier `json:"standard"`
+	Fast     *gasPriceTier `json:"fast"`
+}
+
+// GasPriceTiers returns slow, standard and fast fee suggestions, computed from
+// the tips paid in recent blocks and how full these blocks were.
+func (s *EthereumAPI) GasPriceTiers(ctx context.Context) (*gasPriceTiersResult, error) {
+	_, reward, baseFee, gasUsed, err := s.b.FeeHistory(ctx, gasPriceTierBlocks, rpc.LatestBlockNumber, gasPriceTierPercentiles)
+	if err != nil {
+		return nil, err
+	}
+	// Take the median tip of each tier over the non-empty blocks, falling back
+	// to the regular suggestion if there are none.
+	var (
+		fullness float64
+		tips     = make([][]*big.Int, len(gasPriceTierPercentiles))
+	)
+	for i, ratio := range gasUsed {
+		fullness += ratio
+		if ratio == 0 || i >= len(reward) {
+			continue
+		}
+		for j := range tips {
+			tips[j] = append(tips[j], reward[i][j])
+		}
+	}
+	if len(gasUsed) > 0 {
+		fullness /= float64(len(gasUsed))
+	}
+	var fallback *big.Int
+	tierTips := make([]*big.Int, len(tips))
+	for i, samples := range tips {
+		if len(samples) == 0 {
+			if fallback == nil {
+				if fallback, err = s.b.SuggestGasTipCap(ctx); err != nil {
+					return nil, err
+				}
+			}
+			tierTips[i] = fallback
+			continue
+		}
+		sort.Slice(samples, func(a, b int) bool { return samples[a].Cmp(samples[b]) < 0 })
+		tierTips[i] = samples[len(samples)/2]
+		// Faster tiers never suggest lower tips than slower ones.
+		if i > 0 && tierTips[i].Cmp(tierTips[i-1]) < 0 {
+			tierTips[i] = tierTips[i-1]
+		}
+	}
+	result := &gasPriceTiersResult{Fullness: fullness}
+	var nextBaseFee *big.Int
+	if len(baseFee) > 0 && baseFee[len(baseFee)-1] != nil {
+		nextBaseFee = baseFee[len(baseFee)-1]
+		result.BaseFee = (*hexutil.Big)(nextBaseFee)
+	}
+	tier := func(tip *big.Int, delay uint64) *gasPriceTier {
+		price := new(big.Int).Set(tip)
+		if nextBaseFee != nil {
+			price.Add(price, nextBaseFee)
+		}
+		return &gasPriceTier{
+			MaxPriorityFeePerGas: (*hexutil.Big)(tip),
+			GasPrice:             (*hexutil.Big)(price),
+			ExpectedDelay:        hexutil.Uint64(delay),
+		}
+	}
+	switch {
+	case fullness < gasPriceTierTarget:
+		result.Slow, result.Standard = tier(tierTips[0], 1), tier(tierTips[1], 1)
+	case fullness < gasPriceTierBusy:
+		result.Slow, result.Standard = tier(tierTips[0], 2), tier(tierTips[1], 1)
+	default:
+		result.Slow, result.Standard = tier(tierTips[0], 4), tier(tierTips[1], 2)
+	}
+	result.Fast = tier(tierTips[2], 1)
+	return result, nil
+}
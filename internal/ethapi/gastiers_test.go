@@ -0,0 +1,177 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// feeHistoryBackend is a backend with a fixed fee history, only serving the
+// calls needed by the gas price tiers.
+type feeHistoryBackend struct {
+	Backend
+	reward  [][]*big.Int
+	baseFee []*big.Int
+	gasUsed []float64
+
+	tip       *big.Int
+	suggested int // number of tip suggestions requested
+}
+
+func (b *feeHistoryBackend) FeeHistory(ctx context.Context, blockCount int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error) {
+	return big.NewInt(1), b.reward, b.baseFee, b.gasUsed, nil
+}
+func (b *feeHistoryBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	b.suggested++
+	return b.tip, nil
+}
+
+// rewards returns the slow, standard and fast percentile tips of a block.
+func rewards(slow, standard, fast int64) []*big.Int {
+	return []*big.Int{big.NewInt(slow), big.NewInt(standard), big.NewInt(fast)}
+}
+
+// Tests that the tiers take the median tip of their percentile over the non-empty
+// blocks, never cheaper for faster tiers, and delay the slower tiers by the
+// fullness of the sampled blocks.
+func TestGasPriceTiers(t *testing.T) {
+	type tier struct{ tip, delay uint64 }
+	tests := []struct {
+		name    string
+		reward  [][]*big.Int
+		baseFee []*big.Int
+		gasUsed []float64
+
+		fullness              float64
+		slow, standard, fast  tier
+		price                 uint64 // gas price of the standard tier
+		withBaseFee, fallback bool
+	}{
+		{
+			name:        "median tips of idle blocks",
+			reward:      [][]*big.Int{rewards(1, 2, 3), rewards(5, 6, 7), rewards(3, 4, 5)},
+			baseFee:     []*big.Int{big.NewInt(100), big.NewInt(100), big.NewInt(100), big.NewInt(90)},
+			gasUsed:     []float64{0.2, 0.3, 0.1},
+			fullness:    0.2,
+			slow:        tier{3, 1},
+			standard:    tier{4, 1},
+			fast:        tier{5, 1},
+			price:       94,
+			withBaseFee: true,
+		},
+		{
+			name:        "empty blocks are not sampled",
+			reward:      [][]*big.Int{rewards(0, 0, 0), rewards(5, 6, 7), rewards(0, 0, 0)},
+			baseFee:     []*big.Int{big.NewInt(100), big.NewInt(100), big.NewInt(100), big.NewInt(90)},
+			gasUsed:     []float64{0, 0.6, 0},
+			fullness:    0.2,
+			slow:        tier{5, 1},
+			standard:    tier{6, 1},
+			fast:        tier{7, 1},
+			price:       96,
+			withBaseFee: true,
+		},
+		{
+			name:        "busy blocks delay the slow tier",
+			reward:      [][]*big.Int{rewards(1, 2, 3), rewards(1, 2, 3)},
+			baseFee:     []*big.Int{big.NewInt(100), big.NewInt(110), big.NewInt(120)},
+			gasUsed:     []float64{0.6, 0.8},
+			fullness:    0.7,
+			slow:        tier{1, 2},
+			standard:    tier{2, 1},
+			fast:        tier{3, 1},
+			price:       122,
+			withBaseFee: true,
+		},
+		{
+			name:        "full blocks delay the slow and standard tiers",
+			reward:      [][]*big.Int{rewards(1, 2, 3), rewards(1, 2, 3)},
+			baseFee:     []*big.Int{big.NewInt(100), big.NewInt(112), big.NewInt(126)},
+			gasUsed:     []float64{0.95, 1},
+			fullness:    0.975,
+			slow:        tier{1, 4},
+			standard:    tier{2, 2},
+			fast:        tier{3, 1},
+			price:       128,
+			withBaseFee: true,
+		},
+		{
+			name:     "faster tiers are never cheaper",
+			reward:   [][]*big.Int{rewards(9, 2, 1), rewards(8, 3, 2), rewards(7, 4, 3)},
+			gasUsed:  []float64{0.1, 0.1, 0.1},
+			fullness: 0.1,
+			slow:     tier{8, 1},
+			standard: tier{8, 1},
+			fast:     tier{8, 1},
+			price:    8,
+		},
+		{
+			name:        "no samples fall back to the tip suggestion",
+			reward:      [][]*big.Int{rewards(0, 0, 0)},
+			baseFee:     []*big.Int{big.NewInt(100), big.NewInt(90)},
+			gasUsed:     []float64{0},
+			fullness:    0,
+			slow:        tier{42, 1},
+			standard:    tier{42, 1},
+			fast:        tier{42, 1},
+			price:       132,
+			withBaseFee: true,
+			fallback:    true,
+		},
+	}
+	for _, tt := range tests {
+		b := &feeHistoryBackend{reward: tt.reward, baseFee: tt.baseFee, gasUsed: tt.gasUsed, tip: big.NewInt(42)}
+		result, err := NewEthereumAPI(b).GasPriceTiers(context.Background())
+		if err != nil {
+			t.Errorf("%s: failed to compute tiers: %v", tt.name, err)
+			continue
+		}
+		if math.Abs(result.Fullness-tt.fullness) > 1e-9 {
+			t.Errorf("%s: fullness mismatch: have %v, want %v", tt.name, result.Fullness, tt.fullness)
+		}
+		if (b.suggested == 1) != tt.fallback || b.suggested > 1 {
+			t.Errorf("%s: tip suggestions mismatch: have %d, want fallback %v", tt.name, b.suggested, tt.fallback)
+		}
+		if (result.BaseFee != nil) != tt.withBaseFee {
+			t.Errorf("%s: base fee mismatch: have %v, want present %v", tt.name, result.BaseFee, tt.withBaseFee)
+		}
+		for _, c := range []struct {
+			name string
+			have *gasPriceTier
+			want tier
+		}{{"slow", result.Slow, tt.slow}, {"standard", result.Standard, tt.standard}, {"fast", result.Fast, tt.fast}} {
+			if tip := c.have.MaxPriorityFeePerGas.ToInt().Uint64(); tip != c.want.tip {
+				t.Errorf("%s: %s tip mismatch: have %d, want %d", tt.name, c.name, tip, c.want.tip)
+			}
+			if uint64(c.have.ExpectedDelay) != c.want.delay {
+				t.Errorf("%s: %s delay mismatch: have %d, want %d", tt.name, c.name, c.have.ExpectedDelay, c.want.delay)
+			}
+		}
+		if price := result.Standard.GasPrice.ToInt().Uint64(); price != tt.price {
+			t.Errorf("%s: standard price mismatch: have %d, want %d", tt.name, price, tt.price)
+		}
+		// Faster tiers never cost less than slower ones
+		if result.Standard.GasPrice.ToInt().Cmp(result.Slow.GasPrice.ToInt()) < 0 || result.Fast.GasPrice.ToInt().Cmp(result.Standard.GasPrice.ToInt()) < 0 {
+			t.Errorf("%s: tier prices out of order: %v, %v, %v", tt.name, result.Slow.GasPrice, result.Standard.GasPrice, result.Fast.GasPrice)
+		}
+	}
+}
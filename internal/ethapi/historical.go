@@ -0,0 +1,105 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+var (
+	historicalRequestMeter = metrics.NewRegisteredMeter("rpc/historical/requests", nil)
+	historicalFailureMeter = metrics.NewRegisteredMeter("rpc/historical/failures", nil)
+)
+
+// HistoricalRPC serves requests concerning blocks preceding the local chain,
+// e.g. from before a regenesis, by proxying them to a legacy node retaining
+// them. A nil HistoricalRPC serves nothing.
+type HistoricalRPC struct {
+	client  *rpc.Client
+	block   uint64        // First block of the local chain
+	timeout time.Duration // Timeout of proxied requests, 0 if unlimited
+}
+
+// NewHistoricalRPC creates a proxy to the legacy node behind the client for the
+// blocks preceding the given one.
+func NewHistoricalRPC(client *rpc.Client, block uint64, timeout time.Duration) *HistoricalRPC {
+	return &HistoricalRPC{client: client, block: block, timeout: timeout}
+}
+
+// Close terminates the connection to the legacy node.
+func (h *HistoricalRPC) Close() {
+	if h != nil {
+		h.client.Close()
+	}
+}
+
+// Covers returns whether the block with the given number is served by the
+// legacy node.
+func (h *HistoricalRPC) Covers(number rpc.BlockNumber) bool {
+	return h != nil && number >= 0 && uint64(number) < h.block
+}
+
+// Call proxies the request to the legacy node, decoding its result into the
+// given value. Errors of the legacy node are passed on as they are.
+func (h *HistoricalRPC) Call(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	if h.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.timeout)
+		defer cancel()
+	}
+	historicalRequestMeter.Mark(1)
+	if err := h.client.CallContext(ctx, result, method, args...); err != nil {
+		historicalFailureMeter.Mark(1)
+		return err
+	}
+	return nil
+}
+
+// BlockArg encodes a block reference for the legacy node, which may not support
+// the object notation of EIP-1898 for block numbers.
+func BlockArg(blockNrOrHash rpc.BlockNumberOrHash) interface{} {
+	if number, ok := blockNrOrHash.Number(); ok {
+		return number
+	}
+	hash, _ := blockNrOrHash.Hash()
+	return hash
+}
+
+// historicalRPC returns the proxy to the legacy node if it serves the given
+// block, nil otherwise. Blocks referenced by hash are served by the legacy node
+// if they are unknown locally.
+func historicalRPC(ctx context.Context, b Backend, blockNrOrHash rpc.BlockNumberOrHash) *HistoricalRPC {
+	h := b.HistoricalRPC()
+	if h == nil {
+		return nil
+	}
+	if number, ok := blockNrOrHash.Number(); ok {
+		if h.Covers(number) {
+			return h
+		}
+		return nil
+	}
+	hash, _ := blockNrOrHash.Hash()
+	if header, _ := b.HeaderByHash(ctx, hash); header != nil {
+		return nil
+	}
+	return h
+}
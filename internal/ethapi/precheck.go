@@ -0,0 +1,129 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// JSON error codes of transactions rejected by the pool. Rejections not listed
+// here keep the generic -32000 code.
+const (
+	errCodeNonceGap          = -32011
+	errCodeIntrinsicGas      = -32012
+	errCodeFeeCapTooLow      = -32013 // Fee cap below the base fee
+	errCodeInsufficientFunds = -32015
+	errCodeOversized         = -32016
+)
+
+// PrecheckErrorData are the offending values of a transaction rejected by the
+// pre-checks of the pool, as far as they could be determined.
+type PrecheckErrorData struct {
+	Have  *hexutil.Big `json:"have,omitempty"`  // Value of the transaction
+	Want  *hexutil.Big `json:"want,omitempty"`  // Value the pool requires at the current head
+	L1Fee *hexutil.Big `json:"l1Fee,omitempty"` // L1 data fee part of the required funds
+}
+
+// precheckError is an API error for a transaction rejected by the pool, with a
+// distinct JSON error code per reason and the offending values as data.
+type precheckError struct {
+	error
+	code int
+	data *PrecheckErrorData
+}
+
+// ErrorCode returns the JSON error code of the rejection reason.
+func (e *precheckError) ErrorCode() int {
+	return e.code
+}
+
+// ErrorData returns the offending values of the transaction.
+func (e *precheckError) ErrorData() interface{} {
+	if e.data == nil {
+		return nil
+	}
+	return e.data
+}
+
+// newPrecheckError converts a pool rejection of the transaction into a structured
+// API error, reading the required values from the latest state. Rejections for
+// other reasons are returned as they are.
+func newPrecheckError(ctx context.Context, b Backend, tx *types.Transaction, err error) error {
+	var code int
+	switch {
+	case errors.Is(err, core.ErrNonceTooHigh):
+		code = errCodeNonceGap
+	case errors.Is(err, core.ErrFeeCapTooLow):
+		code = errCodeFeeCapTooLow
+	case errors.Is(err, core.ErrIntrinsicGas):
+		code = errCodeIntrinsicGas
+	case errors.Is(err, core.ErrInsufficientFunds):
+		code = errCodeInsufficientFunds
+	case errors.Is(err, core.ErrOversizedData):
+		code = errCodeOversized
+	default:
+		return err
+	}
+	precheckErr := &precheckError{error: err, code: code}
+
+	state, header, stateErr := b.StateAndHeaderByNumber(ctx, rpc.LatestBlockNumber)
+	if state == nil || stateErr != nil {
+		return precheckErr
+	}
+	from, senderErr := types.Sender(types.LatestSigner(b.ChainConfig()), tx)
+	if senderErr != nil {
+		return precheckErr
+	}
+	data := new(PrecheckErrorData)
+	switch code {
+	case errCodeNonceGap:
+		data.Have = (*hexutil.Big)(new(big.Int).SetUint64(tx.Nonce()))
+		if nonce, err := b.GetPoolNonce(ctx, from); err == nil {
+			data.Want = (*hexutil.Big)(new(big.Int).SetUint64(nonce))
+		}
+	case errCodeFeeCapTooLow:
+		data.Have = (*hexutil.Big)(tx.GasFeeCap())
+		if header.BaseFee != nil {
+			data.Want = (*hexutil.Big)(new(big.Int).Set(header.BaseFee))
+		}
+	case errCodeIntrinsicGas:
+		data.Have = (*hexutil.Big)(new(big.Int).SetUint64(tx.Gas()))
+		istanbul := b.ChainConfig().IsIstanbul(header.Number)
+		if gas, err := core.IntrinsicGas(tx.Data(), tx.AccessList(), tx.SetCodeAuthorizations(), tx.To() == nil, true, istanbul); err == nil {
+			data.Want = (*hexutil.Big)(new(big.Int).SetUint64(gas))
+		}
+	case errCodeInsufficientFunds:
+		cost := tx.Cost()
+		if l1Cost := core.NewL1CostFunc(b.ChainConfig(), state)(header.Number.Uint64(), tx); l1Cost != nil {
+			cost.Add(cost, l1Cost)
+			data.L1Fee = (*hexutil.Big)(l1Cost)
+		}
+		data.Have = (*hexutil.Big)(state.GetBalance(from))
+		data.Want = (*hexutil.Big)(cost)
+	case errCodeOversized:
+		data.Have = (*hexutil.Big)(new(big.Int).SetUint64(uint64(tx.Size())))
+	}
+	precheckErr.data = data
+	return precheckErr
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// precheckBackend is a backend with a fixed head and pool nonce, only serving
+// the calls needed by the pre-checks.
+type precheckBackend struct {
+	Backend
+	head   *types.Header
+	state  *state.StateDB
+	nonces map[common.Address]uint64
+}
+
+func (b *precheckBackend) ChainConfig() *params.ChainConfig { return params.TestChainConfig }
+func (b *precheckBackend) CurrentHeader() *types.Header     { return b.head }
+func (b *precheckBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	return b.nonces[addr], nil
+}
+func (b *precheckBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	return b.state, b.head, nil
+}
+
+func newPrecheckBackend(t *testing.T) *precheckBackend {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	if err != nil {
+		t.Fatalf("failed to create state: %v", err)
+	}
+	return &precheckBackend{
+		head:   &types.Header{Number: big.NewInt(1), BaseFee: big.NewInt(params.InitialBaseFee)},
+		state:  statedb,
+		nonces: make(map[common.Address]uint64),
+	}
+}
+
+// Tests that pool rejections are converted into structured errors, keeping the
+// generic ones as they are.
+func TestNewPrecheckError(t *testing.T) {
+	b := newPrecheckBackend(t)
+	key, _ := crypto.GenerateKey()
+	b.state.SetBalance(crypto.PubkeyToAddress(key.PublicKey), big.NewInt(1000))
+	b.nonces[crypto.PubkeyToAddress(key.PublicKey)] = 2
+
+	tx := types.MustSignNewTx(key, types.LatestSigner(params.TestChainConfig), &types.DynamicFeeTx{
+		ChainID:   params.TestChainConfig.ChainID,
+		Nonce:     3,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(params.InitialBaseFee - 1),
+		Gas:       20000,
+		To:        &common.Address{},
+		Value:     big.NewInt(1),
+	})
+	tests := []struct {
+		err  error
+		code int
+		have *big.Int
+		want *big.Int
+	}{
+		{core.ErrNonceTooLow, 0, nil, nil},
+		{core.ErrUnderpriced, 0, nil, nil},
+		{core.ErrNonceTooHigh, errCodeNonceGap, big.NewInt(3), big.NewInt(2)},
+		{core.ErrFeeCapTooLow, errCodeFeeCapTooLow, big.NewInt(params.InitialBaseFee - 1), big.NewInt(params.InitialBaseFee)},
+		{core.ErrIntrinsicGas, errCodeIntrinsicGas, big.NewInt(20000), big.NewInt(int64(params.TxGas))},
+		{core.ErrInsufficientFunds, errCodeInsufficientFunds, big.NewInt(1000), tx.Cost()},
+		{core.ErrOversizedData, errCodeOversized, new(big.Int).SetUint64(uint64(tx.Size())), nil},
+	}
+	for i, tt := range tests {
+		err := newPrecheckError(context.Background(), b, tx, fmt.Errorf("%w: test", tt.err))
+		if tt.code == 0 {
+			if _, ok := err.(*precheckError); ok {
+				t.Errorf("test %d: generic rejection converted: %v", i, err)
+			}
+			continue
+		}
+		perr, ok := err.(*precheckError)
+		if !ok {
+			t.Errorf("test %d: error mismatch: have %v, want code %d", i, err, tt.code)
+			continue
+		}
+		if perr.ErrorCode() != tt.code {
+			t.Errorf("test %d: error code mismatch: have %d, want %d", i, perr.ErrorCode(), tt.code)
+		}
+		if perr.data.Have.ToInt().Cmp(tt.have) != 0 {
+			t.Errorf("test %d: offending value mismatch: have %v, want %v", i, perr.data.Have, tt.have)
+		}
+		if (tt.want == nil) != (perr.data.Want == nil) || (tt.want != nil && perr.data.Want.ToInt().Cmp(tt.want) != 0) {
+			t.Errorf("test %d: required value mismatch: have %v, want %v", i, perr.data.Want, tt.want)
+		}
+	}
+}
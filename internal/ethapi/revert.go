@@ -0,0 +1,255 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// builtinErrors are the revert encodings emitted by the Solidity compiler itself.
+var builtinErrors = func() []abi.Error {
+	str, _ := abi.NewType("string", "", nil)
+	u256, _ := abi.NewType("uint256", "", nil)
+	return []abi.Error{
+		abi.NewError("Error", abi.Arguments{{Name: "reason", Type: str}}),
+		abi.NewError("Panic", abi.Arguments{{Name: "code", Type: u256}}),
+	}
+}()
+
+// ErrorRegistry maps 4-byte error selectors to the ABI definitions of the custom
+// errors registered through the admin namespace.
+type ErrorRegistry struct {
+	mu     sync.RWMutex
+	errors map[[4]byte]abi.Error
+}
+
+// NewErrorRegistry creates an empty custom error registry.
+func NewErrorRegistry() *ErrorRegistry {
+	return &ErrorRegistry{errors: make(map[[4]byte]abi.Error)}
+}
+
+// register adds all error definitions of the given ABI to the registry and
+// returns their number.
+func (r *ErrorRegistry) register(definition abi.ABI) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range definition.Errors {
+		var selector [4]byte
+		copy(selector[:], e.ID[:4])
+		r.errors[selector] = e
+	}
+	return len(definition.Errors)
+}
+
+// lookup returns the error definition for the given selector, preferring the
+// compiler builtins over registered definitions. A nil registry only resolves
+// the builtins.
+func (r *ErrorRegistry) lookup(selector []byte) (abi.Error, bool) {
+	for _, e := range builtinErrors {
+		if string(e.ID[:4]) == string(selector) {
+			return e, true
+		}
+	}
+	if r == nil {
+		return abi.Error{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var key [4]byte
+	copy(key[:], selector)
+	e, ok := r.errors[key]
+	return e, ok
+}
+
+// signatures returns the sorted signatures of all registered errors.
+func (r *ErrorRegistry) signatures() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sigs := make([]string, 0, len(r.errors))
+	for _, e := range r.errors {
+		sigs = append(sigs, e.Sig)
+	}
+	sort.Strings(sigs)
+	return sigs
+}
+
+// reset drops all registered errors.
+func (r *ErrorRegistry) reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.errors = make(map[[4]byte]abi.Error)
+}
+
+// RevertData is the structured data attached to the error of a reverted call.
+type RevertData struct {
+	Raw       hexutil.Bytes `json:"raw"`                 // Revert data as returned by the EVM
+	Selector  hexutil.Bytes `json:"selector,omitempty"`  // Leading 4 bytes of the revert data
+	Name      string        `json:"name,omitempty"`      // Name of the decoded error
+	Signature string        `json:"signature,omitempty"` // Canonical signature of the decoded error
+	Args      []RevertArg   `json:"args,omitempty"`      // Decoded error arguments
+}
+
+// RevertArg is a single decoded argument of a revert error.
+type RevertArg struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// decodeRevert decodes revert data into its structured form, using the given
+// registry to resolve custom errors.
+func decodeRevert(registry *ErrorRegistry, data []byte) *RevertData {
+	result := &RevertData{Raw: data}
+	if len(data) < 4 {
+		return result
+	}
+	result.Selector = data[:4]
+
+	definition, ok := registry.lookup(data[:4])
+	if !ok {
+		return result
+	}
+	values, err := definition.Unpack(data)
+	if err != nil {
+		return result
+	}
+	unpacked := values.([]interface{})
+	result.Name, result.Signature = definition.Name, definition.Sig
+	result.Args = make([]RevertArg, len(unpacked))
+	for i, value := range unpacked {
+		result.Args[i] = RevertArg{
+			Name:  definition.Inputs[i].Name,
+			Type:  definition.Inputs[i].Type.String(),
+			Value: formatRevertValue(value),
+		}
+	}
+	return result
+}
+
+// formatRevertValue converts decoded ABI values into their JSON-RPC encoding.
+func formatRevertValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case *big.Int:
+		return (*hexutil.Big)(v)
+	case []byte:
+		return hexutil.Bytes(v)
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Array:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, rv.Len())
+			reflect.Copy(reflect.ValueOf(b), rv)
+			return hexutil.Bytes(b)
+		}
+		fallthrough
+	case reflect.Slice:
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			items[i] = formatRevertValue(rv.Index(i).Interface())
+		}
+		return items
+	}
+	return value
+}
+
+// newRevertError creates a revert error for the given revert data, decoding it
+// against the builtin and the registered errors.
+func newRevertError(registry *ErrorRegistry, revert []byte) *revertError {
+	decoded := decodeRevert(registry, revert)
+	err := errors.New("execution reverted")
+	switch decoded.Name {
+	case "":
+	case "Error":
+		err = fmt.Errorf("execution reverted: %v", decoded.Args[0].Value)
+	default:
+		err = fmt.Errorf("execution reverted: %v", decoded.Signature)
+	}
+	return &revertError{
+		error:   err,
+		revert:  revert,
+		decoded: decoded,
+	}
+}
+
+// revertError is an API error that encompassas an EVM revertal with JSON error
+// code and a binary data blob.
+type revertError struct {
+	error
+	revert  []byte      // raw revert data
+	decoded *RevertData // decoded revert reason
+}
+
+// ErrorCode returns the JSON error code for a revertal.
+// See: https://github.com/ethereum/wiki/wiki/JSON-RPC-Error-Codes-Improvement-Proposal
+func (e *revertError) ErrorCode() int {
+	return 3
+}
+
+// ErrorData returns the hex encoded revert reason.
+func (e *revertError) ErrorData() interface{} {
+	return hexutil.Encode(e.revert)
+}
+
+// ErrorDecoded returns the revert reason decoded against the known errors.
+func (e *revertError) ErrorDecoded() interface{} {
+	return e.decoded
+}
+
+// ErrorABIAPI manages the custom error definitions used to decode the revert
+// data of failing calls.
+type ErrorABIAPI struct {
+	errors *ErrorRegistry
+}
+
+// NewErrorABIAPI creates a new custom error management API.
+func NewErrorABIAPI(errors *ErrorRegistry) *ErrorABIAPI {
+	return &ErrorABIAPI{errors}
+}
+
+// RegisterErrorABI registers the custom errors of the given JSON contract ABI
+// and returns the number of errors found in it.
+func (api *ErrorABIAPI) RegisterErrorABI(definition string) (int, error) {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		return 0, fmt.Errorf("invalid ABI: %v", err)
+	}
+	return api.errors.register(parsed), nil
+}
+
+// ErrorSignatures returns the signatures of all registered custom errors.
+func (api *ErrorABIAPI) ErrorSignatures() []string {
+	return api.errors.signatures()
+}
+
+// ClearErrorABIs removes all registered custom errors.
+func (api *ErrorABIAPI) ClearErrorABIs() {
+	api.errors.reset()
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const revertTestABI = `[{"type":"error","name":"Unauthorized","inputs":[{"name":"caller","type":"address"},{"name":"amount","type":"uint256"}]}]`
+
+// revertTestData concatenates the selector of the given signature with the
+// given 32-byte words.
+func revertTestData(sig string, words ...[]byte) []byte {
+	data := crypto.Keccak256([]byte(sig))[:4]
+	for _, word := range words {
+		data = append(data, common.LeftPadBytes(word, 32)...)
+	}
+	return data
+}
+
+// Tests that revert data is decoded against the builtin errors and the ones
+// registered with the API, while the error data stays the hex encoded revert.
+func TestRevertError(t *testing.T) {
+	registry := NewErrorRegistry()
+	if n, err := NewErrorABIAPI(registry).RegisterErrorABI(revertTestABI); err != nil || n != 1 {
+		t.Fatalf("failed to register errors: %d, %v", n, err)
+	}
+	reason := append(revertTestData("Error(string)", big.NewInt(32).Bytes(), big.NewInt(4).Bytes()), common.RightPadBytes([]byte("oops"), 32)...)
+	caller := common.HexToAddress("0x1234")
+	custom := revertTestData("Unauthorized(address,uint256)", caller.Bytes(), big.NewInt(7).Bytes())
+
+	tests := []struct {
+		registry *ErrorRegistry
+		revert   []byte
+		message  string
+		name     string
+		args     []RevertArg
+	}{
+		{registry, []byte{0x1, 0x2}, "execution reverted", "", nil},
+		{registry, reason, "execution reverted: oops", "Error", []RevertArg{{"reason", "string", "oops"}}},
+		{registry, revertTestData("Panic(uint256)", big.NewInt(0x11).Bytes()), "execution reverted: Panic(uint256)", "Panic", []RevertArg{{"code", "uint256", (*hexutil.Big)(big.NewInt(0x11))}}},
+		{registry, custom, "execution reverted: Unauthorized(address,uint256)", "Unauthorized", []RevertArg{{"caller", "address", hexutil.Bytes(caller.Bytes())}, {"amount", "uint256", (*hexutil.Big)(big.NewInt(7))}}},
+		{nil, custom, "execution reverted", "", nil},
+		{NewErrorRegistry(), custom, "execution reverted", "", nil},
+	}
+	for i, tt := range tests {
+		err := newRevertError(tt.registry, tt.revert)
+		if err.Error() != tt.message {
+			t.Errorf("test %d: message mismatch: have %q, want %q", i, err.Error(), tt.message)
+		}
+		if have, want := err.ErrorData(), hexutil.Encode(tt.revert); have != want {
+			t.Errorf("test %d: error data mismatch: have %v, want %v", i, have, want)
+		}
+		decoded := err.ErrorDecoded().(*RevertData)
+		if decoded.Name != tt.name {
+			t.Errorf("test %d: decoded name mismatch: have %q, want %q", i, decoded.Name, tt.name)
+		}
+		if !reflect.DeepEqual(decoded.Args, tt.args) {
+			t.Errorf("test %d: decoded arguments mismatch: have %v, want %v", i, decoded.Args, tt.args)
+		}
+		if len(tt.revert) >= 4 && !reflect.DeepEqual([]byte(decoded.Selector), tt.revert[:4]) {
+			t.Errorf("test %d: selector mismatch: have %x, want %x", i, decoded.Selector, tt.revert[:4])
+		}
+	}
+}
+
+// Tests that the custom errors are scoped to the registry of the API they were
+// registered with.
+func TestErrorABIAPI(t *testing.T) {
+	var (
+		first  = NewErrorABIAPI(NewErrorRegistry())
+		second = NewErrorABIAPI(NewErrorRegistry())
+	)
+	if _, err := first.RegisterErrorABI("not an abi"); err == nil {
+		t.Fatal("invalid ABI accepted")
+	}
+	if _, err := first.RegisterErrorABI(revertTestABI); err != nil {
+		t.Fatalf("failed to register errors: %v", err)
+	}
+	if have, want := first.ErrorSignatures(), []string{"Unauthorized(address,uint256)"}; !reflect.DeepEqual(have, want) {
+		t.Fatalf("signatures mismatch: have %v, want %v", have, want)
+	}
+	if have := second.ErrorSignatures(); len(have) != 0 {
+		t.Fatalf("errors leaked into another registry: %v", have)
+	}
+	first.ClearErrorABIs()
+	if have := first.ErrorSignatures(); len(have) != 0 {
+		t.Fatalf("errors retained after clearing: %v", have)
+	}
+}
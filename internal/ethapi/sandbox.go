@@ -0,0 +1,285 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethapi
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+const (
+	// maxSandboxSessions is the maximum number of concurrently open sandboxes.
+	maxSandboxSessions = 16
+
+	// sandboxIdleTimeout is the time after which unused sandboxes are dropped.
+	sandboxIdleTimeout = 10 * time.Minute
+
+	// sandboxGasLimit is the total amount of gas a sandbox may consume.
+	sandboxGasLimit = 1_000_000_000
+)
+
+var (
+	errSandboxNotFound  = errors.New("sandbox not found")
+	errTooManySandboxes = errors.New("too many open sandboxes")
+	errSandboxGasLimit  = errors.New("sandbox gas limit exhausted")
+)
+
+// sandbox is an ephemeral copy of the state at some block, on top of which
+// calls are executed and optionally persisted.
+type sandbox struct {
+	mu      sync.Mutex
+	id      rpc.ID
+	header  *types.Header
+	state   *state.StateDB
+	gasUsed uint64
+	calls   int
+	used    time.Time // last use, guarded by the SandboxAPI lock
+}
+
+// SandboxResult is the outcome of a call executed in a sandbox.
+type SandboxResult struct {
+	ReturnData hexutil.Bytes  `json:"returnData"`
+	GasUsed    hexutil.Uint64 `json:"gasUsed"`
+	Failed     bool           `json:"failed"`
+	Error      string         `json:"error,omitempty"`
+	Revert     *RevertData    `json:"revert,omitempty"`
+	Logs       []*types.Log   `json:"logs"`
+}
+
+// SandboxAccount is the state of an account in a sandbox.
+type SandboxAccount struct {
+	Balance *hexutil.Big                `json:"balance"`
+	Nonce   hexutil.Uint64              `json:"nonce"`
+	Code    hexutil.Bytes               `json:"code"`
+	Storage map[common.Hash]common.Hash `json:"storage,omitempty"`
+}
+
+// SandboxAPI offers interactive EVM execution against ephemeral state
+// sandboxes. Sandboxes are limited in number, total gas and lifetime.
+type SandboxAPI struct {
+	b            Backend
+	customErrors *ErrorRegistry
+
+	mu        sync.Mutex
+	sandboxes map[rpc.ID]*sandbox
+}
+
+// NewSandboxAPI creates a new sandbox API.
+func NewSandboxAPI(b Backend, customErrors *ErrorRegistry) *SandboxAPI {
+	return &SandboxAPI{b: b, customErrors: customErrors, sandboxes: make(map[rpc.ID]*sandbox)}
+}
+
+// SandboxOpen creates a sandbox on top of the state of the given block with
+// optional state overrides and returns its id.
+func (api *SandboxAPI) SandboxOpen(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash, overrides *StateOverride) (rpc.ID, error) {
+	statedb, header, err := api.b.StateAndHeaderByNumberOrHash(ctx, blockNrOrHash)
+	if statedb == nil || err != nil {
+		return "", err
+	}
+	if err := overrides.Apply(statedb); err != nil {
+		return "", err
+	}
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	api.expireLocked()
+	if len(api.sandboxes) >= maxSandboxSessions {
+		return "", errTooManySandboxes
+	}
+	sb := &sandbox{id: rpc.NewID(), header: header, state: statedb, used: time.Now()}
+	api.sandboxes[sb.id] = sb
+	return sb.id, nil
+}
+
+// SandboxClose drops a sandbox.
+func (api *SandboxAPI) SandboxClose(id rpc.ID) error {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	if _, ok := api.sandboxes[id]; !ok {
+		return errSandboxNotFound
+	}
+	delete(api.sandboxes, id)
+	return nil
+}
+
+// SandboxOverride applies state overrides to a sandbox.
+func (api *SandboxAPI) SandboxOverride(id rpc.ID, overrides StateOverride) error {
+	sb, err := api.sandbox(id)
+	if err != nil {
+		return err
+	}
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	return overrides.Apply(sb.state)
+}
+
+// SandboxCall executes a call in a sandbox without keeping its state changes.
+func (api *SandboxAPI) SandboxCall(ctx context.Context, id rpc.ID, args TransactionArgs) (*SandboxResult, error) {
+	return api.execute(ctx, id, args, false)
+}
+
+// SandboxTransact executes a call in a sandbox and keeps its state changes,
+// so that subsequent calls observe them.
+func (api *SandboxAPI) SandboxTransact(ctx context.Context, id rpc.ID, args TransactionArgs) (*SandboxResult, error) {
+	return api.execute(ctx, id, args, true)
+}
+
+// SandboxAccount returns the state of an account in a sandbox, including the
+// requested storage slots.
+func (api *SandboxAPI) SandboxAccount(id rpc.ID, address common.Address, slots []common.Hash) (*SandboxAccount, error) {
+	sb, err := api.sandbox(id)
+	if err != nil {
+		return nil, err
+	}
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	account := &SandboxAccount{
+		Balance: (*hexutil.Big)(sb.state.GetBalance(address)),
+		Nonce:   hexutil.Uint64(sb.state.GetNonce(address)),
+		Code:    sb.state.GetCode(address),
+	}
+	if len(slots) > 0 {
+		account.Storage = make(map[common.Hash]common.Hash, len(slots))
+		for _, slot := range slots {
+			account.Storage[slot] = sb.state.GetState(address, slot)
+		}
+	}
+	return account, sb.state.Error()
+}
+
+// sandbox returns the sandbox with the given id and marks it used.
+func (api *SandboxAPI) sandbox(id rpc.ID) (*sandbox, error) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	api.expireLocked()
+	sb, ok := api.sandboxes[id]
+	if !ok {
+		return nil, errSandboxNotFound
+	}
+	sb.used = time.Now()
+	return sb, nil
+}
+
+// expireLocked drops idle sandboxes.
+func (api *SandboxAPI) expireLocked() {
+	for id, sb := range api.sandboxes {
+		if time.Since(sb.used) > sandboxIdleTimeout {
+			delete(api.sandboxes, id)
+		}
+	}
+}
+
+// execute runs a call in a sandbox, persisting its state changes if requested.
+func (api *SandboxAPI) execute(ctx context.Context, id rpc.ID, args TransactionArgs, persist bool) (*SandboxResult, error) {
+	sb, err := api.sandbox(id)
+	if err != nil {
+		return nil, err
+	}
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+
+	if sb.gasUsed >= sandboxGasLimit {
+		return nil, errSandboxGasLimit
+	}
+	gasCap := api.b.RPCGasCap()
+	if remaining := sandboxGasLimit - sb.gasUsed; gasCap == 0 || gasCap > remaining {
+		gasCap = remaining
+	}
+	msg, err := args.ToMessage(gasCap, sb.header.BaseFee)
+	if err != nil {
+		return nil, err
+	}
+	timeout := api.b.RPCEVMTimeout()
+	var cancel context.CancelFunc
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	// Each call gets a unique pseudo transaction hash to collect its logs.
+	var seq [8]byte
+	binary.BigEndian.PutUint64(seq[:], uint64(sb.calls))
+	txHash := crypto.Keccak256Hash([]byte(sb.id), seq[:])
+	sb.calls++
+
+	snapshot := sb.state.Snapshot()
+	sb.state.Prepare(txHash, sb.calls-1)
+	evm, vmError, err := api.b.GetEVM(ctx, msg, sb.state, sb.header, &vm.Config{NoBaseFee: true})
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		<-ctx.Done()
+		evm.Cancel()
+	}()
+	result, err := core.ApplyMessage(evm, msg, new(core.GasPool).AddGas(math.MaxUint64))
+	if vmErr := vmError(); vmErr != nil {
+		sb.state.RevertToSnapshot(snapshot)
+		return nil, vmErr
+	}
+	if evm.Cancelled() {
+		sb.state.RevertToSnapshot(snapshot)
+		return nil, fmt.Errorf("execution aborted (timeout = %v)", timeout)
+	}
+	if err != nil {
+		sb.state.RevertToSnapshot(snapshot)
+		return nil, fmt.Errorf("err: %w (supplied gas %d)", err, msg.Gas())
+	}
+	sb.gasUsed += result.UsedGas
+
+	res := &SandboxResult{
+		ReturnData: result.Return(),
+		GasUsed:    hexutil.Uint64(result.UsedGas),
+		Failed:     result.Failed(),
+		Logs:       sb.state.GetLogs(txHash, common.Hash{}),
+	}
+	if res.Logs == nil {
+		res.Logs = []*types.Log{}
+	}
+	if result.Failed() {
+		res.Error = result.Err.Error()
+		if len(result.Revert()) > 0 {
+			res.Revert = decodeRevert(api.customErrors, result.Revert())
+		}
+	}
+	if persist {
+		sb.state.Finalise(api.b.ChainConfig().IsEIP158(sb.header.Number))
+	} else {
+		sb.state.RevertToSnapshot(snapshot)
+	}
+	return res, nil
+}
@@ -30,6 +30,7 @@ var Modules = map[string]string{
 	"txpool":   TxpoolJs,
 	"les":      LESJs,
 	"vflux":    VfluxJs,
+	"trace":    TraceJs,
 }
 
 const CliqueJs = `
@@ -152,11 +153,61 @@ web3._extend({
 			call: 'admin_importChain',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'compactNow',
+			call: 'admin_compactNow',
+		}),
+		new web3._extend.Method({
+			name: 'allowReorg',
+			call: 'admin_allowReorg',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setTxPoolPriceBump',
+			call: 'admin_setTxPoolPriceBump',
+			params: 3,
+			inputFormatter: [null, web3._extend.utils.fromDecimal, null]
+		}),
+		new web3._extend.Method({
+			name: 'setTxPoolQueueClasses',
+			call: 'admin_setTxPoolQueueClasses',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'txPoolQueueClasses',
+			call: 'admin_txPoolQueueClasses',
+		}),
+		new web3._extend.Method({
+			name: 'setTxPoolLimits',
+			call: 'admin_setTxPoolLimits',
+			params: 1,
+		}),
 		new web3._extend.Method({
 			name: 'sleepBlocks',
 			call: 'admin_sleepBlocks',
 			params: 2
 		}),
+		new web3._extend.Method({
+			name: 'filterStats',
+			call: 'admin_filterStats'
+		}),
+		new web3._extend.Method({
+			name: 'dropFilters',
+			call: 'admin_dropFilters',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'sequencerStatus',
+			call: 'admin_sequencerStatus'
+		}),
+		new web3._extend.Method({
+			name: 'stopSequencer',
+			call: 'admin_stopSequencer'
+		}),
+		new web3._extend.Method({
+			name: 'startSequencer',
+			call: 'admin_startSequencer'
+		}),
 		new web3._extend.Method({
 			name: 'startHTTP',
 			call: 'admin_startHTTP',
@@ -189,6 +240,44 @@ web3._extend({
 			name: 'stopWS',
 			call: 'admin_stopWS'
 		}),
+		new web3._extend.Method({
+			name: 'reloadConfig',
+			call: 'admin_reloadConfig'
+		}),
+		new web3._extend.Method({
+			name: 'startDrain',
+			call: 'admin_startDrain',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'drainStatus',
+			call: 'admin_drainStatus'
+		}),
+		new web3._extend.Method({
+			name: 'registerErrorABI',
+			call: 'admin_registerErrorABI',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'clearErrorABIs',
+			call: 'admin_clearErrorABIs'
+		}),
+		new web3._extend.Method({
+			name: 'enqueueTraceJob',
+			call: 'admin_enqueueTraceJob',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'cancelTraceJob',
+			call: 'admin_cancelTraceJob',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'removeTraceJob',
+			call: 'admin_removeTraceJob',
+			params: 1
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -199,10 +288,22 @@ web3._extend({
 			name: 'peers',
 			getter: 'admin_peers'
 		}),
+		new web3._extend.Property({
+			name: 'dialCandidates',
+			getter: 'admin_dialCandidates'
+		}),
 		new web3._extend.Property({
 			name: 'datadir',
 			getter: 'admin_datadir'
 		}),
+		new web3._extend.Property({
+			name: 'errorSignatures',
+			getter: 'admin_errorSignatures'
+		}),
+		new web3._extend.Property({
+			name: 'traceJobs',
+			getter: 'admin_traceJobs'
+		}),
 	]
 });
 `
@@ -214,8 +315,51 @@ web3._extend({
 		new web3._extend.Method({
 			name: 'accountRange',
 			call: 'debug_accountRange',
-			params: 6,
-			inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter, null, null, null, null, null],
+			params: 7,
+			inputFormatter: [web3._extend.formatters.inputDefaultBlockNumberFormatter, null, null, null, null, null, null],
+		}),
+		new web3._extend.Method({
+			name: 'compareState',
+			call: 'debug_compareState',
+			params: 4,
+			inputFormatter: [null, web3._extend.formatters.inputDefaultBlockNumberFormatter, null, null],
+		}),
+		new web3._extend.Method({
+			name: 'chainConfig',
+			call: 'debug_chainConfig',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'sandboxOpen',
+			call: 'debug_sandboxOpen',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
+		new web3._extend.Method({
+			name: 'sandboxClose',
+			call: 'debug_sandboxClose',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'sandboxOverride',
+			call: 'debug_sandboxOverride',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'sandboxCall',
+			call: 'debug_sandboxCall',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'sandboxTransact',
+			call: 'debug_sandboxTransact',
+			params: 2
+		}),
+		new web3._extend.Method({
+			name: 'sandboxAccount',
+			call: 'debug_sandboxAccount',
+			params: 3
 		}),
 		new web3._extend.Method({
 			name: 'printBlock',
@@ -233,11 +377,26 @@ web3._extend({
 			call: 'debug_getBlockRlp',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'getRawHeader',
+			call: 'debug_getRawHeader',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getRawBlock',
+			call: 'debug_getRawBlock',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'getRawReceipts',
 			call: 'debug_getRawReceipts',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'getRawTransaction',
+			call: 'debug_getRawTransaction',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'setHead',
 			call: 'debug_setHead',
@@ -264,6 +423,20 @@ web3._extend({
 			name: 'chaindbCompact',
 			call: 'debug_chaindbCompact',
 		}),
+		new web3._extend.Method({
+			name: 'dbInspect',
+			call: 'debug_dbInspect',
+		}),
+		new web3._extend.Method({
+			name: 'setTrieFlushInterval',
+			call: 'debug_setTrieFlushInterval',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'setTrieDirtyLimit',
+			call: 'debug_setTrieDirtyLimit',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'verbosity',
 			call: 'debug_verbosity',
@@ -447,6 +620,12 @@ web3._extend({
 			call: 'debug_storageRangeAt',
 			params: 5,
 		}),
+		new web3._extend.Method({
+			name: 'getProofRange',
+			call: 'debug_getProofRange',
+			params: 4,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter, web3._extend.formatters.inputBlockNumberFormatter, null, null]
+		}),
 		new web3._extend.Method({
 			name: 'getModifiedAccountsByNumber',
 			call: 'debug_getModifiedAccountsByNumber',
@@ -470,6 +649,28 @@ web3._extend({
 			params: 2,
 			inputFormatter:[web3._extend.formatters.inputBlockNumberFormatter, web3._extend.formatters.inputBlockNumberFormatter],
 		}),
+		new web3._extend.Method({
+			name: 'getCodeHistory',
+			call: 'debug_getCodeHistory',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'getStateDiff',
+			call: 'debug_getStateDiff',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'executionWitness',
+			call: 'debug_executionWitness',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'executeStateless',
+			call: 'debug_executeStateless',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter, null]
+		}),
 		new web3._extend.Method({
 			name: 'dbGet',
 			call: 'debug_dbGet',
@@ -486,7 +687,20 @@ web3._extend({
 			params: 0
 		}),
 	],
-	properties: []
+	properties: [
+		new web3._extend.Property({
+			name: 'pruneStatus',
+			getter: 'debug_pruneStatus'
+		}),
+		new web3._extend.Property({
+			name: 'dbStats',
+			getter: 'debug_dbStats'
+		}),
+		new web3._extend.Property({
+			name: 'trieFlushStatus',
+			getter: 'debug_trieFlushStatus'
+		}),
+	]
 });
 `
 
@@ -499,6 +713,12 @@ web3._extend({
 			call: 'eth_chainId',
 			params: 0
 		}),
+		new web3._extend.Method({
+			name: 'getContractCreator',
+			call: 'eth_getContractCreator',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'sign',
 			call: 'eth_sign',
@@ -524,6 +744,12 @@ web3._extend({
 			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputBlockNumberFormatter],
 			outputFormatter: web3._extend.utils.toDecimal
 		}),
+		new web3._extend.Method({
+			name: 'estimateGasDetailed',
+			call: 'eth_estimateGasDetailed',
+			params: 2,
+			inputFormatter: [web3._extend.formatters.inputCallFormatter, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'submitTransaction',
 			call: 'eth_submitTransaction',
@@ -578,6 +804,12 @@ web3._extend({
 			params: 3,
 			inputFormatter: [web3._extend.formatters.inputAddressFormatter, null, web3._extend.formatters.inputBlockNumberFormatter]
 		}),
+		new web3._extend.Method({
+			name: 'getProofs',
+			call: 'eth_getProofs',
+			params: 2,
+			inputFormatter: [null, web3._extend.formatters.inputBlockNumberFormatter]
+		}),
 		new web3._extend.Method({
 			name: 'createAccessList',
 			call: 'eth_createAccessList',
@@ -614,6 +846,10 @@ web3._extend({
 			getter: 'eth_maxPriorityFeePerGas',
 			outputFormatter: web3._extend.utils.toBigNumber
 		}),
+		new web3._extend.Property({
+			name: 'gasPriceTiers',
+			getter: 'eth_gasPriceTiers'
+		}),
 	]
 });
 `
@@ -774,11 +1010,55 @@ web3._extend({
 				return status;
 			}
 		}),
+		new web3._extend.Property({
+			name: 'stats',
+			getter: 'txpool_stats'
+		}),
+		new web3._extend.Property({
+			name: 'localStatus',
+			getter: 'txpool_localStatus'
+		}),
 		new web3._extend.Method({
 			name: 'contentFrom',
 			call: 'txpool_contentFrom',
 			params: 1,
 		}),
+		new web3._extend.Method({
+			name: 'contentPaged',
+			call: 'txpool_contentPaged',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'inspectPaged',
+			call: 'txpool_inspectPaged',
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'statusByTag',
+			call: 'txpool_statusByTag',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'dropped',
+			call: 'txpool_dropped',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'evict',
+			call: 'txpool_evict',
+			params: 1,
+		}),
+		new web3._extend.Method({
+			name: 'banSender',
+			call: 'txpool_banSender',
+			params: 2,
+		}),
+		new web3._extend.Method({
+			name: 'bans',
+			call: 'txpool_bans',
+		}),
 	]
 });
 `
@@ -867,3 +1147,28 @@ web3._extend({
 	]
 });
 `
+
+const TraceJs = `
+web3._extend({
+	property: 'trace',
+	methods:
+	[
+		new web3._extend.Method({
+			name: 'block',
+			call: 'trace_block',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputBlockNumberFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'transaction',
+			call: 'trace_transaction',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'filter',
+			call: 'trace_filter',
+			params: 1
+		}),
+	]
+});
+`
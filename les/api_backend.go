@@ -17,9 +17,11 @@
 package les
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"math/big"
+	"sort"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
@@ -35,6 +37,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/gasprice"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -196,6 +199,10 @@ func (b *LesApiBackend) SendTx(ctx context.Context, signedTx *types.Transaction)
 	return b.eth.txPool.Add(ctx, signedTx)
 }
 
+func (b *LesApiBackend) SendTxConditional(ctx context.Context, signedTx *types.Transaction, cond *core.TxConditional) error {
+	return errors.New("conditional transactions not supported by light client")
+}
+
 func (b *LesApiBackend) RemoveTx(txHash common.Hash) {
 	b.eth.txPool.RemoveTx(txHash)
 }
@@ -228,10 +235,57 @@ func (b *LesApiBackend) TxPoolContentFrom(addr common.Address) (types.Transactio
 	return b.eth.txPool.ContentFrom(addr)
 }
 
+// TxPoolContentRange walks the light pool by sender. The light pool only holds
+// the node's own transactions, so it is simply sorted per call.
+func (b *LesApiBackend) TxPoolContentRange(queued bool, start common.Address, fn func(addr common.Address, txs types.Transactions) bool) {
+	pending, queue := b.eth.txPool.Content()
+	content := pending
+	if queued {
+		content = queue
+	}
+	senders := make([]common.Address, 0, len(content))
+	for addr := range content {
+		if bytes.Compare(addr[:], start[:]) >= 0 {
+			senders = append(senders, addr)
+		}
+	}
+	sort.Slice(senders, func(i, j int) bool {
+		return bytes.Compare(senders[i][:], senders[j][:]) < 0
+	})
+	for _, addr := range senders {
+		txs := content[addr]
+		sort.Sort(types.TxByNonce(txs))
+		if !fn(addr, txs) {
+			return
+		}
+	}
+}
+
+func (b *LesApiBackend) TagTx(hash common.Hash, tag string) {}
+
+func (b *LesApiBackend) TaggedTxs(tag string) []core.TaggedTx {
+	return nil
+}
+
+func (b *LesApiBackend) DroppedTx(hash common.Hash) *core.TxDrop {
+	return nil
+}
+
+func (b *LesApiBackend) TxPoolLatencies() core.TxPoolLatencies {
+	return core.TxPoolLatencies{}
+}
+
 func (b *LesApiBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
 	return b.eth.txPool.SubscribeNewTxsEvent(ch)
 }
 
+func (b *LesApiBackend) SubscribeDropTxsEvent(ch chan<- core.DropTxsEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
 func (b *LesApiBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
 	return b.eth.blockchain.SubscribeChainEvent(ch)
 }
@@ -255,6 +309,20 @@ func (b *LesApiBackend) SubscribePendingLogsEvent(ch chan<- []*types.Log) event.
 	})
 }
 
+func (b *LesApiBackend) SubscribeChainSafeEvent(ch chan<- core.ChainSafeEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+func (b *LesApiBackend) SubscribeChainFinalizedEvent(ch chan<- core.ChainFinalizedEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
 func (b *LesApiBackend) SubscribeRemovedLogsEvent(ch chan<- core.RemovedLogsEvent) event.Subscription {
 	return b.eth.blockchain.SubscribeRemovedLogsEvent(ch)
 }
@@ -275,6 +343,10 @@ func (b *LesApiBackend) FeeHistory(ctx context.Context, blockCount int, lastBloc
 	return b.gpo.FeeHistory(ctx, blockCount, lastBlock, rewardPercentiles)
 }
 
+func (b *LesApiBackend) L1FeeHistory(ctx context.Context, oldest *big.Int, blocks int) (*gasprice.L1FeeHistory, error) {
+	return b.gpo.L1FeeHistory(ctx, oldest, blocks)
+}
+
 func (b *LesApiBackend) ChainDb() ethdb.Database {
 	return b.eth.chainDb
 }
@@ -291,6 +363,10 @@ func (b *LesApiBackend) UnprotectedAllowed() bool {
 	return b.allowUnprotectedTxs
 }
 
+func (b *LesApiBackend) HistoricalRPC() *ethapi.HistoricalRPC {
+	return nil
+}
+
 func (b *LesApiBackend) RPCGasCap() uint64 {
 	return b.eth.config.RPCGasCap
 }
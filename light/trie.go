@@ -144,6 +144,12 @@ func (t *odrTrie) Commit(onleaf trie.LeafCallback) (common.Hash, int, error) {
 	return t.trie.Commit(onleaf)
 }
 
+// CommitState commits the trie like Commit, the light client stores no state
+// by path.
+func (t *odrTrie) CommitState(state common.Hash, onleaf trie.LeafCallback) (common.Hash, int, error) {
+	return t.Commit(onleaf)
+}
+
 func (t *odrTrie) Hash() common.Hash {
 	if t.trie == nil {
 		return t.id.Root
@@ -0,0 +1,122 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Outcomes of a candidate transaction of a payload.
+const (
+	TxIncluded = "included" // The transaction is part of the payload
+	TxDeferred = "deferred" // The transaction stays pooled for a later payload
+	TxDropped  = "dropped"  // The transaction failed and is not going to be retried
+)
+
+// Reasons for the outcome of a candidate transaction.
+const (
+	reasonTip          = "insufficient tip"
+	reasonGasLimit     = "gas limit"
+	reasonBlobGasLimit = "blob gas limit"
+	reasonNonceLow     = "nonce too low"
+	reasonNonceHigh    = "nonce too high"
+	reasonTxType       = "unsupported tx type"
+	reasonProtected    = "policy: replay protected before EIP-155"
+	reasonCondition    = "policy: condition failed: "
+	reasonTxLimits     = "policy: size limit: "
+	reasonForced       = "forced"
+)
+
+// TxDecision records what the builder did with a candidate transaction.
+type TxDecision struct {
+	Hash    common.Hash    `json:"hash"`
+	From    common.Address `json:"from"`
+	Outcome string         `json:"outcome"`
+	Reason  string         `json:"reason,omitempty"`
+}
+
+// PayloadDecisions are the decisions taken while building a payload.
+type PayloadDecisions struct {
+	Number     hexutil.Uint64 `json:"number"`
+	Hash       common.Hash    `json:"hash"`
+	ParentHash common.Hash    `json:"parentHash"`
+	Timestamp  hexutil.Uint64 `json:"timestamp"`
+	Txs        []TxDecision   `json:"txs"`
+}
+
+// decisionLog retains the decisions of the payloads built for the most recent
+// block numbers. A payload may be rebuilt several times, so there may be more
+// than one per number.
+type decisionLog struct {
+	blocks uint64 // Number of recent block numbers to retain
+
+	mu       sync.RWMutex
+	payloads map[uint64][]*PayloadDecisions
+	latest   uint64
+}
+
+func newDecisionLog(blocks uint64) *decisionLog {
+	return &decisionLog{
+		blocks:   blocks,
+		payloads: make(map[uint64][]*PayloadDecisions),
+	}
+}
+
+// add records the decisions of a built payload, forgetting the ones of payloads
+// that fell out of the retention window.
+func (l *decisionLog) add(block *types.Block, txs []TxDecision) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	number := block.NumberU64()
+	l.payloads[number] = append(l.payloads[number], &PayloadDecisions{
+		Number:     hexutil.Uint64(number),
+		Hash:       block.Hash(),
+		ParentHash: block.ParentHash(),
+		Timestamp:  hexutil.Uint64(block.Time()),
+		Txs:        txs,
+	})
+	if number > l.latest {
+		l.latest = number
+	}
+	for n := range l.payloads {
+		if n+l.blocks <= l.latest {
+			delete(l.payloads, n)
+		}
+	}
+}
+
+// get returns the decisions of the payloads built for the given block number.
+func (l *decisionLog) get(number uint64) []*PayloadDecisions {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return append([]*PayloadDecisions{}, l.payloads[number]...)
+}
+
+// decide records the decision on a candidate transaction of the payload being
+// built, if decisions are logged.
+func (w *worker) decide(env *environment, tx *types.Transaction, from common.Address, outcome, reason string) {
+	if w.decisions == nil {
+		return
+	}
+	env.decisions = append(env.decisions, TxDecision{Hash: tx.Hash(), From: from, Outcome: outcome, Reason: reason})
+}
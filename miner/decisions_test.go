@@ -0,0 +1,84 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+func TestBuilderDecisions(t *testing.T) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	config := *testConfig
+	config.DecisionLogBlocks = 2
+	b := newTestWorkerBackend(t, ethashChainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	b.txPool.AddLocals(pendingTxs)
+	w := newWorker(&config, ethashChainConfig, engine, b, new(event.TypeMux), nil, false)
+	defer w.close()
+
+	// Force the pooled transaction, making its pooled copy stale
+	forced := pendingTxs[0]
+	block, _, err := w.getSealingBlock(b.chain.Genesis().Hash(), uint64(time.Now().Unix()), common.Address{}, common.Hash{}, false, types.Transactions{forced}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloads := (&Miner{worker: w}).BuilderDecisions(1)
+	if len(payloads) != 1 {
+		t.Fatalf("payload count mismatch: have %d, want 1", len(payloads))
+	}
+	if payloads[0].Hash != block.Hash() {
+		t.Errorf("payload hash mismatch: have %x, want %x", payloads[0].Hash, block.Hash())
+	}
+	want := []TxDecision{
+		{Hash: forced.Hash(), From: testBankAddress, Outcome: TxIncluded, Reason: reasonForced},
+		{Hash: forced.Hash(), From: testBankAddress, Outcome: TxDropped, Reason: reasonNonceLow},
+	}
+	if have := payloads[0].Txs; len(have) != len(want) {
+		t.Fatalf("decision count mismatch: have %v, want %v", have, want)
+	}
+	for i, have := range payloads[0].Txs {
+		if have != want[i] {
+			t.Errorf("decision %d mismatch: have %+v, want %+v", i, have, want[i])
+		}
+	}
+}
+
+func TestDecisionLogRetention(t *testing.T) {
+	log := newDecisionLog(2)
+	for _, number := range []int64{1, 1, 2, 3} {
+		log.add(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(number)}), nil)
+	}
+	if n := len(log.get(1)); n != 0 {
+		t.Errorf("expired payloads retained: %d", n)
+	}
+	if n := len(log.get(2)); n != 1 {
+		t.Errorf("payload count mismatch: have %d, want 1", n)
+	}
+	log.add(types.NewBlockWithHeader(&types.Header{Number: big.NewInt(3)}), nil)
+	if n := len(log.get(3)); n != 2 {
+		t.Errorf("rebuilt payload count mismatch: have %d, want 2", n)
+	}
+}
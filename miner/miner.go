@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"math/big"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -54,10 +55,23 @@ type Config struct {
 	GasPrice   *big.Int       // Minimum gas price for mining a transaction
 	Recommit   time.Duration  // The time interval for miner to re-create mining work.
 	Noverify   bool           // Disable remote mining solution verification(only useful in ethash).
+
+	NewPayloadRecommit time.Duration // The time interval to rebuild payloads requested by the engine API (0 = build once)
+	NewPayloadTimeout  time.Duration // The maximum time to keep improving a payload requested by the engine API
+
+	DecisionLogBlocks uint64 `toml:",omitempty"` // Number of recent block numbers to log the builder decisions of (0 = disabled)
+
+	TxLimits   core.TxLimits `toml:",omitempty"` // Local size limits of transactions, tightening the ones of the chain config
+	TxOrdering string        `toml:",omitempty"` // Name of the transaction ordering policy (empty = price)
+
+	NoTriePrefetch bool                   `toml:",omitempty"` // Whether to disable the trie prefetcher during block building
+	TriePrefetch   state.PrefetcherConfig `toml:"-"`          // Tuning of the trie prefetcher, shared with block import
 }
 
 // Miner creates blocks and searches for proof-of-work values.
 type Miner struct {
+	payloadBuildTime int64 // Duration of the most recent full payload build (atomic access)
+
 	mux      *event.TypeMux
 	worker   *worker
 	coinbase common.Address
@@ -175,6 +189,15 @@ func (miner *Miner) Hashrate() uint64 {
 	return 0
 }
 
+// BuilderDecisions returns the decisions taken on the candidate transactions of
+// the payloads built for the given block number, or nil if they aren't logged.
+func (miner *Miner) BuilderDecisions(number uint64) []*PayloadDecisions {
+	if miner.worker.decisions == nil {
+		return nil
+	}
+	return miner.worker.decisions.get(number)
+}
+
 func (miner *Miner) SetExtra(extra []byte) error {
 	if uint64(len(extra)) > params.MaximumExtraDataSize {
 		return fmt.Errorf("extra exceeds max length. %d > %v", len(extra), params.MaximumExtraDataSize)
@@ -235,32 +258,22 @@ func (miner *Miner) DisablePreseal() {
 	miner.worker.disablePreseal()
 }
 
+// PayloadBuildTime returns how long the most recent full payload requested by the
+// engine API took to build, or zero if none was built yet.
+func (miner *Miner) PayloadBuildTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&miner.payloadBuildTime))
+}
+
 // SubscribePendingLogs starts delivering logs from pending transactions
 // to the given channel.
 func (miner *Miner) SubscribePendingLogs(ch chan<- []*types.Log) event.Subscription {
 	return miner.worker.pendingLogsFeed.Subscribe(ch)
 }
 
-// GetSealingBlockAsync requests to generate a sealing block according to the
-// given parameters. Regardless of whether the generation is successful or not,
-// there is always a result that will be returned through the result channel.
-// The difference is that if the execution fails, the returned result is nil
-// and the concrete error is dropped silently.
-func (miner *Miner) GetSealingBlockAsync(parent common.Hash, timestamp uint64, coinbase common.Address, random common.Hash, noTxs bool, forceTxs types.Transactions) (chan *types.Block, error) {
-	resCh, _, err := miner.worker.getSealingBlock(parent, timestamp, coinbase, random, noTxs, forceTxs)
-	if err != nil {
-		return nil, err
-	}
-	return resCh, nil
-}
-
 // GetSealingBlockSync creates a sealing block according to the given parameters.
 // If the generation is failed or the underlying work is already closed, an error
 // will be returned.
 func (miner *Miner) GetSealingBlockSync(parent common.Hash, timestamp uint64, coinbase common.Address, random common.Hash, noTxs bool, forceTxs types.Transactions) (*types.Block, error) {
-	resCh, errCh, err := miner.worker.getSealingBlock(parent, timestamp, coinbase, random, noTxs, forceTxs)
-	if err != nil {
-		return nil, err
-	}
-	return <-resCh, <-errCh
+	block, _, err := miner.worker.getSealingBlock(parent, timestamp, coinbase, random, noTxs, forceTxs, nil, nil)
+	return block, err
 }
@@ -0,0 +1,220 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// Names of the built-in transaction ordering policies.
+const (
+	PriceOrdering = "price" // Most paying transaction first, the default
+	FIFOOrdering  = "fifo"  // First seen transaction first
+)
+
+// TxOrderingPolicy decides the order the pending transactions of the pool are
+// included in a block in. Local and remote transactions are ordered separately,
+// the local ones being included first.
+type TxOrderingPolicy interface {
+	// Order returns the pending transactions, keyed by sender and sorted by
+	// nonce, in the order they should be included in. The transactions of an
+	// account must keep their nonce order, any of them may be left out. The
+	// pending map may be modified.
+	Order(signer types.Signer, pending map[common.Address]types.Transactions, baseFee *big.Int) types.Transactions
+}
+
+var (
+	txOrderingLock     sync.RWMutex
+	txOrderingPolicies = map[string]TxOrderingPolicy{
+		PriceOrdering: priceOrdering{},
+		FIFOOrdering:  fifoOrdering{},
+	}
+)
+
+// RegisterTxOrderingPolicy makes a transaction ordering policy selectable by the
+// given name in the miner config. It is meant to be called from init functions
+// of network specific packages and panics if the name is already taken.
+func RegisterTxOrderingPolicy(name string, policy TxOrderingPolicy) {
+	txOrderingLock.Lock()
+	defer txOrderingLock.Unlock()
+
+	if _, ok := txOrderingPolicies[name]; ok {
+		panic(fmt.Sprintf("transaction ordering policy %q registered twice", name))
+	}
+	txOrderingPolicies[name] = policy
+}
+
+// LookupTxOrderingPolicy returns the transaction ordering policy registered with
+// the given name. The empty name selects the price ordering.
+func LookupTxOrderingPolicy(name string) (TxOrderingPolicy, error) {
+	if name == "" {
+		name = PriceOrdering
+	}
+	txOrderingLock.RLock()
+	defer txOrderingLock.RUnlock()
+
+	policy, ok := txOrderingPolicies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown transaction ordering policy %q", name)
+	}
+	return policy, nil
+}
+
+// priceOrdering orders transactions by the fees they pay the miner, the ones
+// seen first coming first among the equally paying ones.
+type priceOrdering struct{}
+
+func (priceOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions, baseFee *big.Int) types.Transactions {
+	var (
+		ordered types.Transactions
+		txs     = types.NewTransactionsByPriceAndNonce(signer, pending, baseFee)
+	)
+	for tx := txs.Peek(); tx != nil; tx = txs.Peek() {
+		ordered = append(ordered, tx)
+		txs.Shift()
+	}
+	return ordered
+}
+
+// fifoOrdering orders transactions by the time they were first seen, regardless
+// of the fees they pay. Transactions not paying the base fee are left out.
+type fifoOrdering struct{}
+
+func (fifoOrdering) Order(signer types.Signer, pending map[common.Address]types.Transactions, baseFee *big.Int) types.Transactions {
+	var ordered types.Transactions
+	for _, txs := range pending {
+		for _, tx := range txs {
+			if baseFee != nil && tx.GasFeeCapIntCmp(baseFee) < 0 {
+				break // Subsequent transactions of the account can't be included either
+			}
+			ordered = append(ordered, tx)
+		}
+	}
+	// A stable sort keeps the nonce order of the transactions of an account,
+	// unless a later nonce was seen first
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Time().Before(ordered[j].Time())
+	})
+	return fixNonceOrder(signer, ordered)
+}
+
+// fixNonceOrder reorders the transactions of each account into nonce order,
+// keeping the positions the account occupies in the list.
+func fixNonceOrder(signer types.Signer, txs types.Transactions) types.Transactions {
+	accounts := make(map[common.Address]types.Transactions)
+	senders := make([]common.Address, len(txs))
+	for i, tx := range txs {
+		senders[i], _ = types.Sender(signer, tx)
+		accounts[senders[i]] = append(accounts[senders[i]], tx)
+	}
+	for _, accTxs := range accounts {
+		sort.Sort(types.TxByNonce(accTxs))
+	}
+	for i, sender := range senders {
+		txs[i] = accounts[sender][0]
+		accounts[sender] = accounts[sender][1:]
+	}
+	return txs
+}
+
+// orderedTransactions iterates over the transactions in the order chosen by a
+// policy, skipping the remaining ones of the accounts that were popped.
+type orderedTransactions struct {
+	txs     types.Transactions
+	signer  types.Signer
+	skipped map[common.Address]bool
+}
+
+func newOrderedTransactions(signer types.Signer, txs types.Transactions) *orderedTransactions {
+	t := &orderedTransactions{
+		txs:     txs,
+		signer:  signer,
+		skipped: make(map[common.Address]bool),
+	}
+	t.skip()
+	return t
+}
+
+// Peek returns the next transaction in order.
+func (t *orderedTransactions) Peek() *types.Transaction {
+	if len(t.txs) == 0 {
+		return nil
+	}
+	return t.txs[0]
+}
+
+// Shift moves on to the next transaction in order.
+func (t *orderedTransactions) Shift() {
+	t.txs = t.txs[1:]
+	t.skip()
+}
+
+// Pop moves on to the next transaction in order, skipping all the remaining ones
+// of the same account.
+func (t *orderedTransactions) Pop() {
+	from, _ := types.Sender(t.signer, t.txs[0])
+	t.skipped[from] = true
+	t.Shift()
+}
+
+// skip drops the leading transactions of popped accounts.
+func (t *orderedTransactions) skip() {
+	for len(t.txs) > 0 {
+		if from, _ := types.Sender(t.signer, t.txs[0]); !t.skipped[from] {
+			return
+		}
+		t.txs = t.txs[1:]
+	}
+}
+
+// txIterator is the set of candidate transactions a block is filled with.
+type txIterator interface {
+	Peek() *types.Transaction
+	Shift()
+	Pop()
+}
+
+// orderTransactions returns the pending transactions in the order of the policy
+// of the worker.
+//
+// On rollups the price ordering ranks transactions by the profit they make the
+// sequencer, their tip minus the L1 data cost of their calldata per unit of gas,
+// with the L1 pricing read from the rollup fee state of the block.
+func (w *worker) orderTransactions(env *environment, pending map[common.Address]types.Transactions) txIterator {
+	if _, ok := w.ordering.(priceOrdering); ok {
+		// Order lazily, blocks usually fill up long before the pool is exhausted
+		if w.chainConfig.Optimism == nil {
+			return types.NewTransactionsByPriceAndNonce(env.signer, pending, env.header.BaseFee)
+		}
+		var (
+			number = env.header.Number.Uint64()
+			costFn = core.NewL1CostFunc(w.chainConfig, env.state)
+		)
+		l1Cost := func(tx *types.Transaction) *big.Int {
+			return costFn(number, tx)
+		}
+		return types.NewTransactionsByProfitAndNonce(env.signer, pending, env.header.BaseFee, l1Cost)
+	}
+	return newOrderedTransactions(env.signer, w.ordering.Order(env.signer, pending, env.header.BaseFee))
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestTxOrderingPolicies(t *testing.T) {
+	var (
+		signer    = types.HomesteadSigner{}
+		cheap, _  = crypto.GenerateKey()
+		pricey, _ = crypto.GenerateKey()
+	)
+	newTx := func(nonce uint64, price int64, key *ecdsa.PrivateKey) *types.Transaction {
+		time.Sleep(time.Millisecond) // Ensure distinct first seen times
+		tx, _ := types.SignTx(types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 21000, big.NewInt(price), nil), signer, key)
+		return tx
+	}
+	// The second transaction of the cheap account is seen before its first one
+	var (
+		cheap1  = newTx(1, 1, cheap)
+		cheap0  = newTx(0, 1, cheap)
+		pricey0 = newTx(0, 10, pricey)
+		pricey1 = newTx(1, 10, pricey)
+	)
+	pending := func() map[common.Address]types.Transactions {
+		return map[common.Address]types.Transactions{
+			crypto.PubkeyToAddress(cheap.PublicKey):  {cheap0, cheap1},
+			crypto.PubkeyToAddress(pricey.PublicKey): {pricey0, pricey1},
+		}
+	}
+	tests := []struct {
+		name string
+		want types.Transactions
+	}{
+		{PriceOrdering, types.Transactions{pricey0, pricey1, cheap0, cheap1}},
+		{FIFOOrdering, types.Transactions{cheap0, cheap1, pricey0, pricey1}},
+	}
+	for _, test := range tests {
+		policy, err := LookupTxOrderingPolicy(test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		have := policy.Order(signer, pending(), nil)
+		if len(have) != len(test.want) {
+			t.Fatalf("%s: transaction count mismatch: have %d, want %d", test.name, len(have), len(test.want))
+		}
+		for i := range have {
+			if have[i] != test.want[i] {
+				t.Errorf("%s: transaction %d mismatch: have %x, want %x", test.name, i, have[i].Hash(), test.want[i].Hash())
+			}
+		}
+	}
+	if _, err := LookupTxOrderingPolicy("unknown"); err == nil {
+		t.Error("unknown policy found")
+	}
+	// Popping a transaction skips the rest of its account
+	txs := newOrderedTransactions(signer, types.Transactions{cheap0, pricey0, cheap1, pricey1})
+	txs.Pop()
+	for _, want := range []*types.Transaction{pricey0, pricey1} {
+		if have := txs.Peek(); have != want {
+			t.Fatalf("iterated transaction mismatch: have %x, want %x", have.Hash(), want.Hash())
+		}
+		txs.Shift()
+	}
+	if tx := txs.Peek(); tx != nil {
+		t.Fatalf("iteration not exhausted: %x", tx.Hash())
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// BuildPayloadArgs contains the provided parameters for building a payload.
+type BuildPayloadArgs struct {
+	Parent       common.Hash        // The parent block to build the payload on top of
+	Timestamp    uint64             // The timestamp of the payload
+	FeeRecipient common.Address     // The fee recipient of the payload
+	Random       common.Hash        // The provided randomness value
+	Transactions types.Transactions // Transactions forced into the payload, before any pool transactions
+	NoTxPool     bool               // Whether to build the payload without pool transactions
+	GasLimit     *uint64            // Gas limit of the payload, nil to derive it from the configured one
+	ExtraData    []byte             // Extra-data of the payload, nil to leave it empty
+}
+
+// Payload wraps a payload requested by the engine API. The empty payload, only
+// containing the forced transactions, is built first and always available. The
+// full payload is rebuilt in the background with the transactions of the pool,
+// keeping the candidate paying the most fees, until it is resolved or the build
+// deadline is reached.
+type Payload struct {
+	empty    *types.Block
+	full     *types.Block
+	fullFees *big.Int
+
+	stop     chan struct{} // closed when the payload is resolved
+	stopOnce sync.Once
+	built    chan struct{} // closed after the first full build attempt
+	resolved bool          // set once the payload was returned, freezing it
+	lock     sync.Mutex
+}
+
+// newPayload creates a payload with the given empty block.
+func newPayload(empty *types.Block) *Payload {
+	return &Payload{
+		empty: empty,
+		stop:  make(chan struct{}),
+		built: make(chan struct{}),
+	}
+}
+
+// update replaces the full payload if the given block pays more fees. Blocks
+// built after the payload was resolved are dropped, so that it never changes
+// once returned.
+func (payload *Payload) update(block *types.Block, fees *big.Int, elapsed time.Duration) {
+	payload.lock.Lock()
+	defer payload.lock.Unlock()
+
+	if payload.resolved {
+		log.Debug("Dropped late payload", "number", block.NumberU64(), "hash", block.Hash(), "elapsed", common.PrettyDuration(elapsed))
+		return
+	}
+	if payload.fullFees == nil || fees.Cmp(payload.fullFees) > 0 {
+		payload.full, payload.fullFees = block, fees
+		log.Debug("Updated payload", "number", block.NumberU64(), "hash", block.Hash(), "txs", len(block.Transactions()),
+			"gas", block.GasUsed(), "fees", fees, "elapsed", common.PrettyDuration(elapsed))
+	}
+}
+
+// Resolve stops improving the payload and returns the full payload with the most
+// fees, or the empty payload if there is none. If the first full build attempt
+// is still in progress, it is awaited for at most the given timeout. It is safe
+// to be called multiple times.
+func (payload *Payload) Resolve(timeout time.Duration) *types.Block {
+	payload.stopOnce.Do(func() { close(payload.stop) })
+
+	wait := time.NewTimer(timeout)
+	defer wait.Stop()
+	select {
+	case <-payload.built:
+	case <-wait.C:
+	}
+	payload.lock.Lock()
+	defer payload.lock.Unlock()
+
+	payload.resolved = true
+	if payload.full != nil {
+		return payload.full
+	}
+	return payload.empty
+}
+
+// BuildPayload builds the empty payload synchronously and, unless the pool is
+// excluded, keeps rebuilding the full payload in the background every
+// NewPayloadRecommit until the payload is resolved or NewPayloadTimeout elapses.
+func (miner *Miner) BuildPayload(args *BuildPayloadArgs) (*Payload, error) {
+	empty, _, err := miner.worker.getSealingBlock(args.Parent, args.Timestamp, args.FeeRecipient, args.Random, true, args.Transactions, args.GasLimit, args.ExtraData)
+	if err != nil {
+		return nil, err
+	}
+	payload := newPayload(empty)
+	if args.NoTxPool {
+		close(payload.built)
+		return payload, nil
+	}
+	var (
+		recommit = miner.worker.config.NewPayloadRecommit
+		timeout  = miner.worker.config.NewPayloadTimeout
+	)
+	go func() {
+		deadline := time.NewTimer(timeout)
+		defer deadline.Stop()
+
+		var builtOnce sync.Once
+		for {
+			start := time.Now()
+			block, fees, err := miner.worker.getSealingBlock(args.Parent, args.Timestamp, args.FeeRecipient, args.Random, false, args.Transactions, args.GasLimit, args.ExtraData)
+			if err == nil {
+				elapsed := time.Since(start)
+				atomic.StoreInt64(&miner.payloadBuildTime, int64(elapsed))
+				payload.update(block, fees, elapsed)
+			} else {
+				log.Warn("Failed to build payload", "parent", args.Parent, "err", err)
+			}
+			builtOnce.Do(func() { close(payload.built) })
+
+			if recommit <= 0 {
+				return
+			}
+			rebuild := time.NewTimer(recommit)
+			select {
+			case <-rebuild.C:
+			case <-payload.stop:
+				rebuild.Stop()
+				return
+			case <-deadline.C:
+				rebuild.Stop()
+				log.Debug("Stopped improving payload", "parent", args.Parent, "elapsed", common.PrettyDuration(timeout))
+				return
+			}
+		}
+	}()
+	return payload, nil
+}
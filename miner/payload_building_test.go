@@ -0,0 +1,95 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package miner
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestBuildPayload(t *testing.T) {
+	var (
+		db        = rawdb.NewMemoryDatabase()
+		engine    = ethash.NewFaker()
+		recipient = common.HexToAddress("0xdeadbeef")
+		config    = *testConfig
+	)
+	defer engine.Close()
+
+	config.NewPayloadRecommit = 50 * time.Millisecond
+	config.NewPayloadTimeout = 5 * time.Second
+	b := newTestWorkerBackend(t, params.TestChainConfig, engine, db, 0)
+	b.txPool.AddLocals(pendingTxs)
+	w := newWorker(&config, params.TestChainConfig, engine, b, new(event.TypeMux), nil, false)
+	defer w.close()
+	miner := &Miner{worker: w}
+
+	args := &BuildPayloadArgs{
+		Parent:       b.chain.CurrentBlock().Hash(),
+		Timestamp:    uint64(time.Now().Unix()),
+		FeeRecipient: recipient,
+	}
+	payload, err := miner.BuildPayload(args)
+	if err != nil {
+		t.Fatalf("failed to build payload: %v", err)
+	}
+	if txs := len(payload.empty.Transactions()); txs != 0 {
+		t.Fatalf("empty payload has %d transactions", txs)
+	}
+	// Transactions arriving after the first build are picked up by a rebuild.
+	<-payload.built
+	b.txPool.AddLocal(b.newRandomTx(false))
+	time.Sleep(500 * time.Millisecond)
+
+	block := payload.Resolve(time.Second)
+	if txs := len(block.Transactions()); txs != 2 {
+		t.Fatalf("wrong number of payload transactions: have %d, want 2", txs)
+	}
+	if block.Coinbase() != recipient || block.Time() != args.Timestamp {
+		t.Fatalf("wrong payload header: coinbase %x, time %d", block.Coinbase(), block.Time())
+	}
+	// Resolving stops further rebuilds.
+	b.txPool.AddLocal(b.newRandomTx(false))
+	time.Sleep(200 * time.Millisecond)
+	if again := payload.Resolve(time.Second); again.Hash() != block.Hash() {
+		t.Fatal("payload changed after being resolved")
+	}
+	// Builds finishing after the payload was resolved are dropped.
+	late := types.NewBlockWithHeader(&types.Header{Number: block.Number(), Extra: []byte("late")})
+	payload.update(late, new(big.Int).Lsh(common.Big1, 128), 0)
+	if again := payload.Resolve(time.Second); again.Hash() != block.Hash() {
+		t.Fatal("late build replaced resolved payload")
+	}
+
+	// Payloads without pool transactions only contain the forced ones.
+	args.NoTxPool = true
+	args.Transactions = types.Transactions{pendingTxs[0]}
+	if payload, err = miner.BuildPayload(args); err != nil {
+		t.Fatalf("failed to build payload: %v", err)
+	}
+	if txs := len(payload.Resolve(0).Transactions()); txs != 1 {
+		t.Fatalf("wrong number of payload transactions: have %d, want 1", txs)
+	}
+}
@@ -92,12 +92,15 @@ type environment struct {
 	family    mapset.Set     // family set (used for checking uncle invalidity)
 	tcount    int            // tx count in cycle
 	gasPool   *core.GasPool  // available gas used to pack transactions
+	blobGas   uint64         // blob gas used by the packed transactions
 	coinbase  common.Address
 
 	header   *types.Header
 	txs      []*types.Transaction
 	receipts []*types.Receipt
 	uncles   map[common.Hash]*types.Header
+
+	decisions []TxDecision // Decisions on the candidate transactions, if logged
 }
 
 // copy creates a deep copy of environment.
@@ -108,6 +111,7 @@ func (env *environment) copy() *environment {
 		ancestors: env.ancestors.Clone(),
 		family:    env.family.Clone(),
 		tcount:    env.tcount,
+		blobGas:   env.blobGas,
 		coinbase:  env.coinbase,
 		header:    types.CopyHeader(env.header),
 		receipts:  copyReceipts(env.receipts),
@@ -167,11 +171,17 @@ type newWorkReq struct {
 	timestamp int64
 }
 
+// newPayloadResult represents a result struct corresponds to payload generation.
+type newPayloadResult struct {
+	err   error
+	block *types.Block
+	fees  *big.Int // total block fees in wei
+}
+
 // getWorkReq represents a request for getting a new sealing work with provided parameters.
 type getWorkReq struct {
 	params *generateParams
-	result chan *types.Block // non-blocking channel
-	err    chan error
+	result chan *newPayloadResult // non-blocking channel
 }
 
 // intervalAdjust represents a resubmitting interval adjustment.
@@ -188,6 +198,8 @@ type worker struct {
 	engine      consensus.Engine
 	eth         Backend
 	chain       *core.BlockChain
+	decisions   *decisionLog     // Builder decisions of recent payloads, nil if not logged
+	ordering    TxOrderingPolicy // Order the pending transactions are included in
 
 	// Feeds
 	pendingLogsFeed event.Feed
@@ -282,6 +294,15 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 	worker.chainHeadSub = eth.BlockChain().SubscribeChainHeadEvent(worker.chainHeadCh)
 	worker.chainSideSub = eth.BlockChain().SubscribeChainSideEvent(worker.chainSideCh)
 
+	if config.DecisionLogBlocks > 0 {
+		worker.decisions = newDecisionLog(config.DecisionLogBlocks)
+	}
+	ordering, err := LookupTxOrderingPolicy(config.TxOrdering)
+	if err != nil {
+		log.Warn("Falling back to price ordering of transactions", "err", err)
+		ordering = priceOrdering{}
+	}
+	worker.ordering = ordering
 	// Sanitize recommit interval if the user-specified one is too short.
 	recommit := worker.config.Recommit
 	if recommit < minRecommitInterval {
@@ -534,14 +555,8 @@ func (w *worker) mainLoop() {
 			w.commitWork(req.interrupt, req.noempty, req.timestamp)
 
 		case req := <-w.getWorkCh:
-			block, err := w.generateWork(req.params)
-			if err != nil {
-				req.err <- err
-				req.result <- nil
-			} else {
-				req.err <- nil
-				req.result <- block
-			}
+			block, fees, err := w.generateWork(req.params)
+			req.result <- &newPayloadResult{err: err, block: block, fees: fees}
 		case ev := <-w.chainSideCh:
 			// Short circuit for duplicate side blocks
 			if _, exist := w.localUncles[ev.Block.Hash()]; exist {
@@ -595,7 +610,7 @@ func (w *worker) mainLoop() {
 					acc, _ := types.Sender(w.current.signer, tx)
 					txs[acc] = append(txs[acc], tx)
 				}
-				txset := types.NewTransactionsByPriceAndNonce(w.current.signer, txs, w.current.header.BaseFee)
+				txset := w.orderTransactions(w.current, txs)
 				tcount := w.current.tcount
 				w.commitTransactions(w.current, txset, nil)
 
@@ -762,7 +777,14 @@ func (w *worker) makeEnv(parent *types.Block, header *types.Header, coinbase com
 	if err != nil {
 		return nil, err
 	}
-	state.StartPrefetcher("miner")
+	if config := w.chainConfig.Verkle; config != nil && config.Block != nil && config.Block.Cmp(header.Number) == 0 {
+		if err := state.ConvertToVerkle(); err != nil {
+			return nil, err
+		}
+	}
+	if !w.config.NoTriePrefetch {
+		state.StartPrefetcher("miner", &w.config.TriePrefetch)
+	}
 
 	// Note the passed coinbase may be different with header.Coinbase.
 	env := &environment{
@@ -835,17 +857,23 @@ func (w *worker) commitTransaction(env *environment, tx *types.Transaction) ([]*
 	}
 	env.txs = append(env.txs, tx)
 	env.receipts = append(env.receipts, receipt)
+	env.blobGas += tx.BlobGas()
+	if env.header.BlobGasUsed != nil {
+		*env.header.BlobGasUsed = env.blobGas
+	}
 
 	return receipt.Logs, nil
 }
 
-func (w *worker) commitTransactions(env *environment, txs *types.TransactionsByPriceAndNonce, interrupt *int32) error {
+func (w *worker) commitTransactions(env *environment, txs txIterator, interrupt *int32) error {
 	gasLimit := env.header.GasLimit
 	if env.gasPool == nil {
 		env.gasPool = new(core.GasPool).AddGas(gasLimit)
 	}
-	var coalescedLogs []*types.Log
-
+	var (
+		coalescedLogs []*types.Log
+		limits        = core.ActiveTxLimits(w.chainConfig, env.header.Number, w.config.TxLimits)
+	)
 	for {
 		// In the following three cases, we will interrupt the execution of the transaction.
 		// (1) new head block event arrival, the interrupt signal is 1
@@ -887,10 +915,36 @@ func (w *worker) commitTransactions(env *environment, txs *types.TransactionsByP
 		// phase, start ignoring the sender until we do.
 		if tx.Protected() && !w.chainConfig.IsEIP155(env.header.Number) {
 			log.Trace("Ignoring reply protected transaction", "hash", tx.Hash(), "eip155", w.chainConfig.EIP155Block)
+			w.decide(env, tx, from, TxDeferred, reasonProtected)
 
 			txs.Pop()
 			continue
 		}
+		// Skip transactions exceeding the size limits, along with the rest of the account
+		if err := limits.Check(tx); err != nil {
+			log.Trace("Skipping oversized transaction", "hash", tx.Hash(), "err", err)
+			w.decide(env, tx, from, TxDropped, reasonTxLimits+err.Error())
+			txs.Pop()
+			continue
+		}
+		// Skip blob transactions exceeding the blob gas left in the block
+		if blobGas := tx.BlobGas(); blobGas > 0 && w.chainConfig.IsBlobTx(env.header.Number) {
+			if env.blobGas+blobGas > w.chainConfig.BlobTx.MaxBlobGas() {
+				log.Trace("Blob gas limit exceeded for current block", "sender", from)
+				w.decide(env, tx, from, TxDeferred, reasonBlobGasLimit)
+				txs.Pop()
+				continue
+			}
+		}
+		// Skip conditional transactions whose conditions don't hold for this block
+		if cond := w.eth.TxPool().Conditional(tx.Hash()); cond != nil {
+			if err := cond.Check(env.header.Number, env.header.Time, env.state); err != nil {
+				log.Trace("Skipping conditional transaction", "hash", tx.Hash(), "err", err)
+				w.decide(env, tx, from, TxDeferred, reasonCondition+err.Error())
+				txs.Pop()
+				continue
+			}
+		}
 		// Start executing the transaction
 		env.state.Prepare(tx.Hash(), env.tcount)
 
@@ -899,33 +953,39 @@ func (w *worker) commitTransactions(env *environment, txs *types.TransactionsByP
 		case errors.Is(err, core.ErrGasLimitReached):
 			// Pop the current out-of-gas transaction without shifting in the next from the account
 			log.Trace("Gas limit exceeded for current block", "sender", from)
+			w.decide(env, tx, from, TxDeferred, reasonGasLimit)
 			txs.Pop()
 
 		case errors.Is(err, core.ErrNonceTooLow):
 			// New head notification data race between the transaction pool and miner, shift
 			log.Trace("Skipping transaction with low nonce", "sender", from, "nonce", tx.Nonce())
+			w.decide(env, tx, from, TxDropped, reasonNonceLow)
 			txs.Shift()
 
 		case errors.Is(err, core.ErrNonceTooHigh):
 			// Reorg notification data race between the transaction pool and miner, skip account =
 			log.Trace("Skipping account with hight nonce", "sender", from, "nonce", tx.Nonce())
+			w.decide(env, tx, from, TxDeferred, reasonNonceHigh)
 			txs.Pop()
 
 		case errors.Is(err, nil):
 			// Everything ok, collect the logs and shift in the next transaction from the same account
 			coalescedLogs = append(coalescedLogs, logs...)
 			env.tcount++
+			w.decide(env, tx, from, TxIncluded, "")
 			txs.Shift()
 
 		case errors.Is(err, core.ErrTxTypeNotSupported):
 			// Pop the unsupported transaction without shifting in the next from the account
 			log.Trace("Skipping unsupported transaction type", "sender", from, "type", tx.Type())
+			w.decide(env, tx, from, TxDropped, reasonTxType)
 			txs.Pop()
 
 		default:
 			// Strange error, discard the transaction and get the next in line (note, the
 			// nonce-too-high clause will prevent us from executing in vain).
 			log.Debug("Transaction failed, account skipped", "hash", tx.Hash(), "err", err)
+			w.decide(env, tx, from, TxDropped, err.Error())
 			txs.Shift()
 		}
 	}
@@ -965,6 +1025,25 @@ type generateParams struct {
 	noTxs      bool           // Flag whether an empty block without any transaction is expected
 
 	forceTxs types.Transactions // Transactions to force-include at the start of the block
+	gasLimit *uint64            // Gas limit overriding the configured one, nil if not overridden
+	extra    []byte             // Extra-data overriding the configured one, nil if not overridden
+}
+
+// verifyGasLimitOverride checks whether a block on top of the given parent may
+// have the requested gas limit.
+func verifyGasLimitOverride(config *params.ChainConfig, parent *types.Header, gasLimit uint64) error {
+	if gasLimit > params.MaxGasLimit {
+		return fmt.Errorf("invalid gas limit: have %d, max %d", gasLimit, params.MaxGasLimit)
+	}
+	number := new(big.Int).Add(parent.Number, common.Big1)
+	if !config.IsLondon(number) {
+		return misc.VerifyGaslimit(parent.GasLimit, gasLimit)
+	}
+	return misc.VerifyEip1559Header(config, parent, &types.Header{
+		Number:   number,
+		GasLimit: gasLimit,
+		BaseFee:  misc.CalcBaseFee(config, parent),
+	})
 }
 
 // prepareWork constructs the sealing task according to the given parameters,
@@ -1015,6 +1094,27 @@ func (w *worker) prepareWork(genParams *generateParams) (*environment, error) {
 			header.GasLimit = core.CalcGasLimit(parentGasLimit, w.config.GasCeil)
 		}
 	}
+	// Track the blob gas if we are on an EIP-4844 chain
+	if w.chainConfig.IsBlobTx(header.Number) {
+		excessBlobGas := misc.CalcExcessBlobGas(w.chainConfig, parent.Header())
+		header.ExcessBlobGas = &excessBlobGas
+		header.BlobGasUsed = new(uint64)
+		header.WithdrawalsHash = &types.EmptyRootHash
+	}
+	// Apply the overrides requested by the rollup node, rejecting the ones the
+	// resulting block would be invalid with.
+	if genParams.gasLimit != nil {
+		if err := verifyGasLimitOverride(w.chainConfig, parent.Header(), *genParams.gasLimit); err != nil {
+			return nil, err
+		}
+		header.GasLimit = *genParams.gasLimit
+	}
+	if genParams.extra != nil {
+		if len(genParams.extra) > int(params.MaximumExtraDataSize) {
+			return nil, fmt.Errorf("invalid extra-data: length %d exceeds %d", len(genParams.extra), params.MaximumExtraDataSize)
+		}
+		header.Extra = genParams.extra
+	}
 	// Run the consensus preparation with the default or customized consensus engine.
 	if err := w.engine.Prepare(w.chain, header); err != nil {
 		log.Error("Failed to prepare header for sealing", "err", err)
@@ -1056,6 +1156,17 @@ func (w *worker) fillTransactions(interrupt *int32, env *environment) error {
 	// Split the pending transactions into locals and remotes
 	// Fill the block with all available pending transactions.
 	pending := w.eth.TxPool().Pending(true)
+	if w.decisions != nil {
+		// Record the transactions held back for their tip, which the pool caps
+		// the pending lists at
+		for addr, txs := range w.eth.TxPool().Pending(false) {
+			if have := len(pending[addr]); have < len(txs) {
+				for _, tx := range txs[have:] {
+					w.decide(env, tx, addr, TxDeferred, reasonTip)
+				}
+			}
+		}
+	}
 	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
 	for _, account := range w.eth.TxPool().Locals() {
 		if txs := remoteTxs[account]; len(txs) > 0 {
@@ -1064,13 +1175,13 @@ func (w *worker) fillTransactions(interrupt *int32, env *environment) error {
 		}
 	}
 	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(env.signer, localTxs, env.header.BaseFee)
+		txs := w.orderTransactions(env, localTxs)
 		if err := w.commitTransactions(env, txs, interrupt); err != nil {
 			return err
 		}
 	}
 	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(env.signer, remoteTxs, env.header.BaseFee)
+		txs := w.orderTransactions(env, remoteTxs)
 		if err := w.commitTransactions(env, txs, interrupt); err != nil {
 			return err
 		}
@@ -1079,10 +1190,10 @@ func (w *worker) fillTransactions(interrupt *int32, env *environment) error {
 }
 
 // generateWork generates a sealing block based on the given parameters.
-func (w *worker) generateWork(genParams *generateParams) (*types.Block, error) {
+func (w *worker) generateWork(genParams *generateParams) (*types.Block, *big.Int, error) {
 	work, err := w.prepareWork(genParams)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer work.discard()
 
@@ -1107,12 +1218,18 @@ func (w *worker) generateWork(genParams *generateParams) (*types.Block, error) {
 		// phase, start ignoring the sender until we do.
 		if tx.Protected() && !w.chainConfig.IsEIP155(work.header.Number) {
 			log.Trace("Ignoring reply protected transaction", "hash", tx.Hash(), "eip155", w.chainConfig.EIP155Block)
+			w.decide(work, tx, from, TxDropped, reasonProtected)
 			continue
 		}
 		// Start executing the transaction
 		work.state.Prepare(tx.Hash(), work.tcount)
 
 		_, err := w.commitTransaction(work, tx)
+		if err == nil {
+			w.decide(work, tx, from, TxIncluded, reasonForced)
+		} else {
+			w.decide(work, tx, from, TxDropped, err.Error())
+		}
 		switch {
 		case errors.Is(err, core.ErrGasLimitReached):
 			// Pop the current out-of-gas transaction without shifting in the next from the account
@@ -1146,7 +1263,14 @@ func (w *worker) generateWork(genParams *generateParams) (*types.Block, error) {
 	if !genParams.noTxs {
 		w.fillTransactions(nil, work)
 	}
-	return w.engine.FinalizeAndAssemble(w.chain, work.header, work.state, work.txs, work.unclelist(), work.receipts)
+	block, err := w.engine.FinalizeAndAssemble(w.chain, work.header, work.state, work.txs, work.unclelist(), work.receipts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if w.decisions != nil {
+		w.decisions.add(block, work.decisions)
+	}
+	return block, totalFeesWei(block, work.receipts), nil
 }
 
 // commitWork generates several new sealing tasks based on the parent block
@@ -1229,14 +1353,9 @@ func (w *worker) commit(env *environment, interval func(), update bool, start ti
 	return nil
 }
 
-// getSealingBlock generates the sealing block based on the given parameters.
-// The generation result will be passed back via the given channel no matter
-// the generation itself succeeds or not.
-func (w *worker) getSealingBlock(parent common.Hash, timestamp uint64, coinbase common.Address, random common.Hash, noTxs bool, forceTxs types.Transactions) (chan *types.Block, chan error, error) {
-	var (
-		resCh = make(chan *types.Block, 1)
-		errCh = make(chan error, 1)
-	)
+// getSealingBlock generates the sealing block based on the given parameters,
+// returning it with its total fees in wei.
+func (w *worker) getSealingBlock(parent common.Hash, timestamp uint64, coinbase common.Address, random common.Hash, noTxs bool, forceTxs types.Transactions, gasLimit *uint64, extra []byte) (*types.Block, *big.Int, error) {
 	req := &getWorkReq{
 		params: &generateParams{
 			timestamp:  timestamp,
@@ -1248,13 +1367,15 @@ func (w *worker) getSealingBlock(parent common.Hash, timestamp uint64, coinbase
 			noExtra:    true,
 			noTxs:      noTxs,
 			forceTxs:   forceTxs,
+			gasLimit:   gasLimit,
+			extra:      extra,
 		},
-		result: resCh,
-		err:    errCh,
+		result: make(chan *newPayloadResult, 1),
 	}
 	select {
 	case w.getWorkCh <- req:
-		return resCh, errCh, nil
+		result := <-req.result
+		return result.block, result.fees, result.err
 	case <-w.exitCh:
 		return nil, nil, errors.New("miner closed")
 	}
@@ -1287,10 +1408,16 @@ func (w *worker) postSideBlock(event core.ChainSideEvent) {
 
 // totalFees computes total consumed miner fees in ETH. Block transactions and receipts have to have the same order.
 func totalFees(block *types.Block, receipts []*types.Receipt) *big.Float {
+	feesWei := totalFeesWei(block, receipts)
+	return new(big.Float).Quo(new(big.Float).SetInt(feesWei), new(big.Float).SetInt(big.NewInt(params.Ether)))
+}
+
+// totalFeesWei computes total consumed miner fees in wei.
+func totalFeesWei(block *types.Block, receipts []*types.Receipt) *big.Int {
 	feesWei := new(big.Int)
 	for i, tx := range block.Transactions() {
 		minerFee, _ := tx.EffectiveGasTip(block.BaseFee())
 		feesWei.Add(feesWei, new(big.Int).Mul(new(big.Int).SetUint64(receipts[i].GasUsed), minerFee))
 	}
-	return new(big.Float).Quo(new(big.Float).SetInt(feesWei), new(big.Float).SetInt(big.NewInt(params.Ether)))
+	return feesWei
 }
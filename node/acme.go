@@ -0,0 +1,48 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"crypto/tls"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// datadirACMECache is the default directory of ACME certificates and keys.
+const datadirACMECache = "acme"
+
+// acmeTLSConfig creates the TLS configuration of the public HTTP and WebSocket
+// servers, which obtains certificates for the configured domains from an ACME
+// certificate authority (Let's Encrypt). The domain ownership is verified with
+// the TLS-ALPN-01 challenge, so the servers must be reachable on port 443.
+func acmeTLSConfig(conf *Config) *tls.Config {
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		HostPolicy: autocert.HostWhitelist(conf.ACMEDomains...),
+		Email:      conf.ACMEEmail,
+	}
+	cacheDir := conf.ACMECacheDir
+	if cacheDir == "" {
+		cacheDir = datadirACMECache
+	}
+	if dir := conf.ResolvePath(cacheDir); dir != "" {
+		manager.Cache = autocert.DirCache(dir)
+	} else {
+		conf.Logger.Warn("No ACME certificate cache, certificates are requested on every start")
+	}
+	return manager.TLSConfig()
+}
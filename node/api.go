@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -154,6 +155,37 @@ func (api *adminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error)
 	return rpcSub, nil
 }
 
+// NodeEvents creates an RPC subscription which receives node events, such as
+// RPC listeners opening, sync phase changes and chain reorganisations.
+func (api *adminAPI) NodeEvents(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		events := make(chan NodeEvent, 16)
+		sub := api.node.SubscribeEvents(events)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case event := <-events:
+				notifier.Notify(rpcSub.ID, event)
+			case <-sub.Err():
+				return
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+
+	return rpcSub, nil
+}
+
 // StartHTTP starts the HTTP RPC API server.
 func (api *adminAPI) StartHTTP(host *string, port *int, cors *string, apis *string, vhosts *string) (bool, error) {
 	api.node.lock.Lock()
@@ -175,7 +207,9 @@ func (api *adminAPI) StartHTTP(host *string, port *int, cors *string, apis *stri
 	config := httpConfig{
 		CorsAllowedOrigins: api.node.config.HTTPCors,
 		Vhosts:             api.node.config.HTTPVirtualHosts,
+		NamespaceRules:     api.node.config.HTTPNamespaceRules,
 		Modules:            api.node.config.HTTPModules,
+		compression:        api.node.config.RPCCompressionThreshold,
 	}
 	if cors != nil {
 		config.CorsAllowedOrigins = nil
@@ -247,8 +281,13 @@ func (api *adminAPI) StartWS(host *string, port *int, allowedOrigins *string, ap
 
 	// Determine config.
 	config := wsConfig{
-		Modules: api.node.config.WSModules,
-		Origins: api.node.config.WSOrigins,
+		Modules:       api.node.config.WSModules,
+		Origins:       api.node.config.WSOrigins,
+		subBuffer:     api.node.config.wsSubscriptionBuffer(),
+		compression:   api.node.config.RPCCompressionThreshold,
+		maxConns:      api.node.config.WSMaxConnections,
+		maxConnsPerIP: api.node.config.WSMaxConnectionsPerIP,
+		idleTimeout:   api.node.config.WSIdleTimeout,
 		// ExposeAll: api.node.config.WSExposeAll,
 	}
 	if apis != nil {
@@ -265,7 +304,7 @@ func (api *adminAPI) StartWS(host *string, port *int, allowedOrigins *string, ap
 	}
 
 	// Enable WebSocket on the server.
-	server := api.node.wsServerForPort(*port, false)
+	server := api.node.wsServerFor(*host, *port, false)
 	if err := server.setListenAddr(*host, *port); err != nil {
 		return false, err
 	}
@@ -287,6 +326,35 @@ func (api *adminAPI) StopWS() (bool, error) {
 	return true, nil
 }
 
+// ReloadConfig re-applies the configuration settings which can be changed
+// without restarting the node.
+func (api *adminAPI) ReloadConfig() (bool, error) {
+	if err := api.node.ReloadConfig(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// StartDrain prepares the node for shutdown: the public RPC servers refuse new
+// requests, and services persist their state once in-flight requests finished
+// or the timeout (in seconds, default 30) expired. Progress is reported by
+// DrainStatus and the "drained" node event.
+func (api *adminAPI) StartDrain(timeout *int) (bool, error) {
+	seconds := 30
+	if timeout != nil {
+		seconds = *timeout
+	}
+	if err := api.node.StartDrain(time.Duration(seconds) * time.Second); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DrainStatus reports the progress of draining the node.
+func (api *adminAPI) DrainStatus() DrainStatus {
+	return api.node.DrainStatus()
+}
+
 // Peers retrieves all the information we know about each individual peer at the
 // protocol granularity.
 func (api *adminAPI) Peers() ([]*p2p.PeerInfo, error) {
@@ -297,6 +365,21 @@ func (api *adminAPI) Peers() ([]*p2p.PeerInfo, error) {
 	return server.PeersInfo(), nil
 }
 
+// DialCandidates retrieves the state of the peer dialer: the dials in progress,
+// the disconnected static nodes and the most recent dial decisions together
+// with the reasons for skipping candidates.
+func (api *adminAPI) DialCandidates() (*p2p.DialInfo, error) {
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	info := server.DialInfo()
+	if info == nil {
+		return nil, ErrNodeStopped
+	}
+	return info, nil
+}
+
 // NodeInfo retrieves all the information we know about the host node at the
 // protocol granularity.
 func (api *adminAPI) NodeInfo() (*p2p.NodeInfo, error) {
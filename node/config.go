@@ -24,6 +24,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -65,6 +66,11 @@ type Config struct {
 	// in memory.
 	DataDir string
 
+	// ReadOnlyDataDir shares the data directory read-only with the node owning it,
+	// opening it without locking it. The node database of the peer-to-peer server
+	// is kept in memory.
+	ReadOnlyDataDir bool `toml:",omitempty"`
+
 	// Configuration of peer-to-peer networking.
 	P2P p2p.Config
 
@@ -77,6 +83,11 @@ type Config struct {
 	// is created by New and destroyed when the node is stopped.
 	KeyStoreDir string `toml:",omitempty"`
 
+	// KeyStoreMounts are additional directories holding externally provisioned key
+	// files, such as secret mounts. Like KeyStoreDir they are watched for added and
+	// removed keys while the node is running, but new keys are never written there.
+	KeyStoreMounts []string `toml:",omitempty"`
+
 	// ExternalSigner specifies an external URI for a clef-type signer
 	ExternalSigner string `toml:",omitempty"`
 
@@ -104,7 +115,9 @@ type Config struct {
 	IPCPath string
 
 	// HTTPHost is the host interface on which to start the HTTP RPC server. If this
-	// field is empty, no HTTP API endpoint will be started.
+	// field is empty, no HTTP API endpoint will be started. A value of the form
+	// unix:///path/to/socket serves HTTP on a Unix domain socket instead, in which
+	// case HTTPPort is ignored.
 	HTTPHost string
 
 	// HTTPPort is the TCP port number on which to start the HTTP RPC server. The
@@ -126,6 +139,12 @@ type Config struct {
 	// Requests using ip address directly are not affected
 	HTTPVirtualHosts []string `toml:",omitempty"`
 
+	// HTTPNamespaceRules overrides HTTPCors and HTTPVirtualHosts for individual
+	// API namespaces, e.g. to open "eth" to browsers while restricting "admin"
+	// and "debug" to internal origins. Requests calling several namespaces must
+	// satisfy the rules of all of them.
+	HTTPNamespaceRules map[string]NamespaceRule `toml:",omitempty"`
+
 	// HTTPModules is a list of API modules to expose via the HTTP RPC interface.
 	// If the module list is empty, all RPC API endpoints designated public will be
 	// exposed.
@@ -138,7 +157,22 @@ type Config struct {
 	// HTTPPathPrefix specifies a path prefix on which http-rpc is to be served.
 	HTTPPathPrefix string `toml:",omitempty"`
 
+	// ACMEDomains enables TLS on the HTTP and WebSocket RPC servers with
+	// certificates for these domains, obtained automatically from an ACME
+	// certificate authority (Let's Encrypt). The servers must be reachable on
+	// port 443 of the domains for the certificates to be issued.
+	ACMEDomains []string `toml:",omitempty"`
+
+	// ACMECacheDir is the directory in which ACME certificates and account keys
+	// are stored. It defaults to the "acme" subdirectory of the instance directory.
+	ACMECacheDir string `toml:",omitempty"`
+
+	// ACMEEmail is the optional contact address of the ACME account, used by the
+	// certificate authority to notify about problems with certificates.
+	ACMEEmail string `toml:",omitempty"`
+
 	// AuthAddr is the listening address on which authenticated APIs are provided.
+	// Like HTTPHost, it may be a unix:// socket path.
 	AuthAddr string `toml:",omitempty"`
 
 	// AuthPort is the port number on which authenticated APIs are provided.
@@ -148,8 +182,21 @@ type Config struct {
 	// for the authenticated api. This is by default {'localhost'}.
 	AuthVirtualHosts []string `toml:",omitempty"`
 
+	// AdminHost is the host interface on which to start the admin HTTP RPC
+	// server, which only serves the admin, debug and txpool namespaces. If this
+	// field is empty, no admin RPC interface will be started.
+	AdminHost string `toml:",omitempty"`
+
+	// AdminPort is the TCP port number on which to start the admin HTTP RPC server.
+	AdminPort int `toml:",omitempty"`
+
+	// AdminVirtualHosts is the list of virtual hostnames which are allowed on
+	// incoming requests for the admin RPC interface. This is by default {'localhost'}.
+	AdminVirtualHosts []string `toml:",omitempty"`
+
 	// WSHost is the host interface on which to start the websocket RPC server. If
-	// this field is empty, no websocket API endpoint will be started.
+	// this field is empty, no websocket API endpoint will be started. Like HTTPHost,
+	// it may be a unix:// socket path.
 	WSHost string
 
 	// WSPort is the TCP port number on which to start the websocket RPC server. The
@@ -170,6 +217,27 @@ type Config struct {
 	// exposed.
 	WSModules []string
 
+	// WSSubscriptionBuffer is the number of notifications queued per subscription
+	// on WebSocket connections. Zero writes notifications to the connection
+	// directly, blocking the event producer while the client is slow to read.
+	WSSubscriptionBuffer int `toml:",omitempty"`
+
+	// WSSubscriptionOverflow is the policy applied when a subscription queue is
+	// full: "drop-oldest" (default), "drop-newest" or "disconnect".
+	WSSubscriptionOverflow string `toml:",omitempty"`
+
+	// WSMaxConnections is the maximum number of concurrent WebSocket connections,
+	// zero means unlimited.
+	WSMaxConnections int `toml:",omitempty"`
+
+	// WSMaxConnectionsPerIP is the maximum number of concurrent WebSocket
+	// connections from a single remote IP address, zero means unlimited.
+	WSMaxConnectionsPerIP int `toml:",omitempty"`
+
+	// WSIdleTimeout is the duration after which WebSocket connections without any
+	// request or notification are closed, zero disables the timeout.
+	WSIdleTimeout time.Duration `toml:",omitempty"`
+
 	// WSExposeAll exposes all API modules via the WebSocket RPC interface rather
 	// than just the public ones.
 	//
@@ -201,8 +269,37 @@ type Config struct {
 	// AllowUnprotectedTxs allows non EIP-155 protected transactions to be send over RPC.
 	AllowUnprotectedTxs bool `toml:",omitempty"`
 
-	// JWTSecret is the path to the hex-encoded jwt secret.
+	// JWTSecret is the path to the hex-encoded jwt secret, or a reference to
+	// it in a secret store (see LoadSecret).
 	JWTSecret string `toml:",omitempty"`
+
+	// RPCConcurrencyLimit is the maximum number of RPC calls executed concurrently
+	// across the HTTP and WebSocket endpoints. Calls beyond the limit are queued,
+	// with calls on the authenticated endpoints dispatched ahead of public ones.
+	// Zero disables the limit.
+	RPCConcurrencyLimit int `toml:",omitempty"`
+
+	// RPCDeniedMethods lists the methods rejected by the public HTTP and WebSocket
+	// endpoints, e.g. "debug_traceTransaction".
+	RPCDeniedMethods []string `toml:",omitempty"`
+
+	// RPCEnginePriorityWeight is the number of queued authenticated calls that are
+	// dispatched for every queued public call while the concurrency limit is hit.
+	RPCEnginePriorityWeight int `toml:",omitempty"`
+
+	// RPCCompressionThreshold is the minimum size in bytes of HTTP and WebSocket
+	// RPC responses compressed for clients accepting it. Smaller responses are
+	// sent as is. A negative value disables response compression.
+	RPCCompressionThreshold int `toml:",omitempty"`
+}
+
+// wsSubscriptionBuffer returns the subscription queueing settings of WebSocket
+// connections.
+func (c *Config) wsSubscriptionBuffer() rpc.SubscriptionBufferConfig {
+	return rpc.SubscriptionBufferConfig{
+		Size:     c.WSSubscriptionBuffer,
+		Overflow: rpc.OverflowPolicy(c.WSSubscriptionOverflow),
+	}
 }
 
 // IPCEndpoint resolves an IPC endpoint based on a configured value, taking into
@@ -232,7 +329,7 @@ func (c *Config) IPCEndpoint() string {
 
 // NodeDB returns the path to the discovery node database.
 func (c *Config) NodeDB() string {
-	if c.DataDir == "" {
+	if c.DataDir == "" || c.ReadOnlyDataDir {
 		return "" // ephemeral
 	}
 	return c.ResolvePath(datadirNodeDatabase)
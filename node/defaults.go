@@ -36,6 +36,8 @@ const (
 	DefaultGraphQLPort = 8547        // Default TCP port for the GraphQL server
 	DefaultAuthHost    = "localhost" // Default host interface for the authenticated apis
 	DefaultAuthPort    = 8551        // Default port for the authenticated apis
+	DefaultAdminHost   = "localhost" // Default host interface for the admin apis
+	DefaultAdminPort   = 8552        // Default port for the admin apis
 )
 
 var (
@@ -44,21 +46,31 @@ var (
 	DefaultAuthOrigins = []string{"localhost"} // Default origins for the authenticated apis
 	DefaultAuthPrefix  = ""                    // Default prefix for the authenticated apis
 	DefaultAuthModules = []string{"eth", "engine"}
+
+	DefaultAdminVhosts  = []string{"localhost"} // Default virtual hosts for the admin apis
+	DefaultAdminModules = []string{"admin", "debug", "txpool"}
 )
 
+// DefaultCompressionThreshold is the default minimum size of compressed RPC
+// responses. Compressing smaller responses costs more CPU than it saves bytes.
+const DefaultCompressionThreshold = 1024
+
 // DefaultConfig contains reasonable default settings.
 var DefaultConfig = Config{
-	DataDir:             DefaultDataDir(),
-	HTTPPort:            DefaultHTTPPort,
-	AuthAddr:            DefaultAuthHost,
-	AuthPort:            DefaultAuthPort,
-	AuthVirtualHosts:    DefaultAuthVhosts,
-	HTTPModules:         []string{"net", "web3"},
-	HTTPVirtualHosts:    []string{"localhost"},
-	HTTPTimeouts:        rpc.DefaultHTTPTimeouts,
-	WSPort:              DefaultWSPort,
-	WSModules:           []string{"net", "web3"},
-	GraphQLVirtualHosts: []string{"localhost"},
+	DataDir:                 DefaultDataDir(),
+	HTTPPort:                DefaultHTTPPort,
+	AuthAddr:                DefaultAuthHost,
+	AuthPort:                DefaultAuthPort,
+	AuthVirtualHosts:        DefaultAuthVhosts,
+	AdminPort:               DefaultAdminPort,
+	AdminVirtualHosts:       DefaultAdminVhosts,
+	HTTPModules:             []string{"net", "web3"},
+	HTTPVirtualHosts:        []string{"localhost"},
+	HTTPTimeouts:            rpc.DefaultHTTPTimeouts,
+	RPCCompressionThreshold: DefaultCompressionThreshold,
+	WSPort:                  DefaultWSPort,
+	WSModules:               []string{"net", "web3"},
+	GraphQLVirtualHosts:     []string{"localhost"},
 	P2P: p2p.Config{
 		ListenAddr: ":30303",
 		MaxPeers:   50,
@@ -0,0 +1,131 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// drainPollInterval is how often in-flight requests are checked while draining.
+const drainPollInterval = 50 * time.Millisecond
+
+// DrainStatus reports the progress of draining the public RPC servers.
+type DrainStatus struct {
+	Draining bool  `json:"draining"` // Whether new requests are refused
+	Drained  bool  `json:"drained"`  // Whether the node is ready for shutdown
+	InFlight int64 `json:"inFlight"` // Number of HTTP requests being served
+}
+
+// drainer tracks in-flight requests of the public RPC servers and refuses new
+// ones once draining started.
+type drainer struct {
+	draining int32 // atomic, non-zero once draining started
+	drained  int32 // atomic, non-zero once the node is ready for shutdown
+	inflight int64 // atomic
+
+	mu         sync.Mutex
+	retryAfter string         // Retry-After header of refused requests
+	hooks      []func() error // run once in-flight requests finished
+}
+
+// enter admits a request, returning false if the node is draining. Admitted
+// requests must call leave when done.
+func (d *drainer) enter() bool {
+	atomic.AddInt64(&d.inflight, 1)
+	if atomic.LoadInt32(&d.draining) != 0 {
+		d.leave()
+		return false
+	}
+	return true
+}
+
+func (d *drainer) leave() {
+	atomic.AddInt64(&d.inflight, -1)
+}
+
+// refuse answers a request turned away while draining.
+func (d *drainer) refuse(w http.ResponseWriter) {
+	d.mu.Lock()
+	retryAfter := d.retryAfter
+	d.mu.Unlock()
+
+	w.Header().Set("Retry-After", retryAfter)
+	http.Error(w, "node is shutting down", http.StatusServiceUnavailable)
+}
+
+func (d *drainer) status() DrainStatus {
+	return DrainStatus{
+		Draining: atomic.LoadInt32(&d.draining) != 0,
+		Drained:  atomic.LoadInt32(&d.drained) != 0,
+		InFlight: atomic.LoadInt64(&d.inflight),
+	}
+}
+
+// RegisterDrainHook adds a function which is run when draining the node, after
+// in-flight requests finished. Services use it to persist state ahead of the
+// shutdown.
+func (n *Node) RegisterDrainHook(hook func() error) {
+	n.drain.mu.Lock()
+	defer n.drain.mu.Unlock()
+
+	n.drain.hooks = append(n.drain.hooks, hook)
+}
+
+// StartDrain prepares the node for shutdown. The public HTTP and WebSocket
+// servers refuse new requests and connections with 503, and once in-flight
+// requests finished or the timeout expired the drain hooks are run. A NodeDrained
+// event is posted when the node is ready for shutdown.
+func (n *Node) StartDrain(timeout time.Duration) error {
+	n.drain.mu.Lock()
+	if atomic.LoadInt32(&n.drain.draining) != 0 {
+		n.drain.mu.Unlock()
+		return ErrDraining
+	}
+	n.drain.retryAfter = strconv.Itoa(int((timeout + time.Second - 1) / time.Second))
+	hooks := append([]func() error{}, n.drain.hooks...)
+	atomic.StoreInt32(&n.drain.draining, 1)
+	n.drain.mu.Unlock()
+
+	n.log.Info("Draining RPC servers", "timeout", timeout)
+	go func() {
+		deadline := time.Now().Add(timeout)
+		for atomic.LoadInt64(&n.drain.inflight) > 0 && time.Now().Before(deadline) {
+			time.Sleep(drainPollInterval)
+		}
+		if inflight := atomic.LoadInt64(&n.drain.inflight); inflight > 0 {
+			n.log.Warn("Drain timed out with requests in flight", "requests", inflight)
+		}
+		for _, hook := range hooks {
+			if err := hook(); err != nil {
+				n.log.Warn("Drain hook failed", "err", err)
+			}
+		}
+		atomic.StoreInt32(&n.drain.drained, 1)
+		n.log.Info("Node drained, ready for shutdown")
+		n.PostEvent(NodeDrained, nil)
+	}()
+	return nil
+}
+
+// DrainStatus reports the progress of draining the node.
+func (n *Node) DrainStatus() DrainStatus {
+	return n.drain.status()
+}
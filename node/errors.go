@@ -28,6 +28,10 @@ var (
 	ErrNodeStopped    = errors.New("node not started")
 	ErrNodeRunning    = errors.New("node already running")
 	ErrServiceUnknown = errors.New("unknown service")
+	ErrLifecycleCycle = errors.New("lifecycle dependency cycle")
+
+	ErrReloadUnsupported = errors.New("configuration reload not supported")
+	ErrDraining          = errors.New("node already draining")
 
 	datadirInUseErrnos = map[uint]bool{11: true, 32: true, 35: true}
 )
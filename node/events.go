@@ -0,0 +1,85 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/event"
+)
+
+// NodeEventType identifies the kind of a node event.
+type NodeEventType string
+
+const (
+	NodeStarted     NodeEventType = "started"         // All lifecycles are running
+	NodeStopping    NodeEventType = "stopping"        // The node is shutting down
+	NodeDrained     NodeEventType = "drained"         // The node finished draining, see StartDrain
+	RPCListenerUp   NodeEventType = "rpcListenerUp"   // An HTTP or WebSocket listener opened, data is RPCListenerInfo
+	RPCListenerDown NodeEventType = "rpcListenerDown" // An HTTP or WebSocket listener closed, data is RPCListenerInfo
+	SyncPhase       NodeEventType = "syncPhase"       // The sync phase changed, data is SyncPhaseInfo
+	ChainHead       NodeEventType = "chainHead"       // The chain head changed, data is ChainHeadInfo
+	ChainReorg      NodeEventType = "chainReorg"      // The chain reorganised, data is ChainReorgInfo
+)
+
+// NodeEvent is a state transition of the node or one of its services.
+type NodeEvent struct {
+	Type NodeEventType `json:"type"`
+	Time time.Time     `json:"time"`
+	Data interface{}   `json:"data,omitempty"`
+}
+
+// RPCListenerInfo describes an RPC listener which opened or closed.
+type RPCListenerInfo struct {
+	Endpoint string `json:"endpoint"`
+	HTTP     bool   `json:"http"` // Whether the listener serves HTTP-RPC
+	WS       bool   `json:"ws"`   // Whether the listener serves WebSocket-RPC
+	Auth     bool   `json:"auth"` // Whether the listener requires JWT authentication
+}
+
+// SyncPhaseInfo describes a sync phase change.
+type SyncPhaseInfo struct {
+	Phase string `json:"phase"`           // "started", "done" or "failed"
+	Error string `json:"error,omitempty"` // Reason of a failed sync
+}
+
+// ChainHeadInfo describes a new chain head.
+type ChainHeadInfo struct {
+	Number uint64      `json:"number"`
+	Hash   common.Hash `json:"hash"`
+}
+
+// ChainReorgInfo describes a reorganisation of the canonical chain.
+type ChainReorgInfo struct {
+	OldHead ChainHeadInfo `json:"oldHead"`
+	NewHead ChainHeadInfo `json:"newHead"`
+	Depth   uint64        `json:"depth"` // Number of dropped canonical blocks
+}
+
+// SubscribeEvents subscribes the given channel to node events. Sends on the
+// feed block until all subscribers received the event, so subscribers should
+// drain their channel promptly.
+func (n *Node) SubscribeEvents(ch chan<- NodeEvent) event.Subscription {
+	return n.events.Subscribe(ch)
+}
+
+// PostEvent delivers an event to all subscribers. Services use it to report
+// their own state transitions.
+func (n *Node) PostEvent(typ NodeEventType, data interface{}) {
+	n.events.Send(NodeEvent{Type: typ, Time: time.Now(), Data: data})
+}
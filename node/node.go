@@ -27,6 +27,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
@@ -43,6 +44,7 @@ import (
 // Node is a container on which services can be registered.
 type Node struct {
 	eventmux      *event.TypeMux
+	events        event.Feed // Feed of NodeEvents, see SubscribeEvents
 	config        *Config
 	accman        *accounts.Manager
 	log           log.Logger
@@ -55,14 +57,20 @@ type Node struct {
 	state         int               // Tracks state of node lifecycle
 
 	lock          sync.Mutex
-	lifecycles    []Lifecycle // All registered backends, services, and auxiliary services that have a lifecycle
-	rpcAPIs       []rpc.API   // List of APIs currently provided by the node
-	http          *httpServer //
-	ws            *httpServer //
-	httpAuth      *httpServer //
-	wsAuth        *httpServer //
-	ipc           *ipcServer  // Stores information about the ipc http server
-	inprocHandler *rpc.Server // In-process RPC request handler to process the API requests
+	lifecycles    []Lifecycle         // All registered backends, services, and auxiliary services that have a lifecycle
+	lifecycleDeps map[int][]Lifecycle // Lifecycles needed running by each lifecycle index, see RegisterLifecycleWithDeps
+	rpcAPIs       []rpc.API           // List of APIs currently provided by the node
+	http          *httpServer         //
+	ws            *httpServer         //
+	httpAuth      *httpServer         //
+	wsAuth        *httpServer         //
+	admin         *httpServer         // Serves the admin namespaces only
+	ipc           *ipcServer          // Stores information about the ipc http server
+	inprocHandler *rpc.Server         // In-process RPC request handler to process the API requests
+	rpcSched      *rpc.Scheduler      // Shared RPC call scheduler of the HTTP and WebSocket servers
+	rpcDeny       *rpc.MethodDenylist // Methods rejected by the public HTTP and WebSocket servers
+	reloader      func() error        // Reloads the configuration, see SetConfigReloader
+	drain         drainer             // Tracks requests of the public servers, see StartDrain
 
 	databases map[*closeTrackingDB]struct{} // All open databases
 }
@@ -150,14 +158,41 @@ func New(conf *Config) (*Node, error) {
 	if err := validatePrefix("WebSocket", conf.WSPathPrefix); err != nil {
 		return nil, err
 	}
+	if err := conf.wsSubscriptionBuffer().Validate(); err != nil {
+		return nil, err
+	}
 
 	// Configure RPC servers.
 	node.http = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.httpAuth = newHTTPServer(node.log, conf.HTTPTimeouts)
 	node.ws = newHTTPServer(node.log, rpc.DefaultHTTPTimeouts)
 	node.wsAuth = newHTTPServer(node.log, rpc.DefaultHTTPTimeouts)
+	node.admin = newHTTPServer(node.log, conf.HTTPTimeouts)
+	for _, server := range []*httpServer{node.http, node.httpAuth, node.ws, node.wsAuth, node.admin} {
+		server.notify = node.PostEvent
+	}
+	node.http.drain, node.ws.drain = &node.drain, &node.drain
+	if len(conf.ACMEDomains) > 0 {
+		tlsConfig := acmeTLSConfig(conf)
+		node.http.setTLS(tlsConfig)
+		node.ws.setTLS(tlsConfig)
+	}
 	node.ipc = newIPCServer(node.log, conf.IPCEndpoint())
 
+	// Share a priority scheduler between the public and authenticated servers.
+	// It is created even if calls are not limited, so a limit can be set later.
+	node.rpcSched = rpc.NewScheduler(conf.RPCConcurrencyLimit, map[rpc.PriorityClass]int{
+		rpc.PriorityEngine: conf.RPCEnginePriorityWeight,
+	})
+	node.http.setScheduler(node.rpcSched, rpc.PriorityPublic)
+	node.ws.setScheduler(node.rpcSched, rpc.PriorityPublic)
+	node.httpAuth.setScheduler(node.rpcSched, rpc.PriorityEngine)
+	node.wsAuth.setScheduler(node.rpcSched, rpc.PriorityEngine)
+
+	node.rpcDeny = rpc.NewMethodDenylist(conf.RPCDeniedMethods)
+	node.http.setDenylist(node.rpcDeny)
+	node.ws.setDenylist(node.rpcDeny)
+
 	return node, nil
 }
 
@@ -176,9 +211,16 @@ func (n *Node) Start() error {
 		n.lock.Unlock()
 		return ErrNodeStopped
 	}
+	// Order lifecycles so that dependencies start first and stop last.
+	sorted, err := sortLifecycles(n.lifecycles, n.lifecycleDeps)
+	if err != nil {
+		n.lock.Unlock()
+		return err
+	}
+	n.lifecycles = sorted
 	n.state = runningState
 	// open networking and RPC endpoints
-	err := n.openEndpoints()
+	err = n.openEndpoints()
 	lifecycles := make([]Lifecycle, len(n.lifecycles))
 	copy(lifecycles, n.lifecycles)
 	n.lock.Unlock()
@@ -200,8 +242,10 @@ func (n *Node) Start() error {
 	if err != nil {
 		n.stopServices(started)
 		n.doClose(nil)
+		return err
 	}
-	return err
+	n.PostEvent(NodeStarted, nil)
+	return nil
 }
 
 // Close stops the Node and releases resources acquired in
@@ -219,6 +263,7 @@ func (n *Node) Close() error {
 		return n.doClose(nil)
 	case runningState:
 		// The node was started, release resources acquired by Start().
+		n.PostEvent(NodeStopping, nil)
 		var errs []error
 		if err := n.stopServices(n.lifecycles); err != nil {
 			errs = append(errs, err)
@@ -292,6 +337,71 @@ func containsLifecycle(lfs []Lifecycle, l Lifecycle) bool {
 	return false
 }
 
+// sortLifecycles orders lifecycles such that each one comes after all of its
+// dependencies, keeping the registration order otherwise. The dependencies are
+// keyed by the index of the lifecycle needing them, as lifecycles need not be
+// usable as map keys.
+func sortLifecycles(lfs []Lifecycle, deps map[int][]Lifecycle) ([]Lifecycle, error) {
+	const (
+		visiting = iota + 1
+		visited
+	)
+	var (
+		sorted = make([]Lifecycle, 0, len(lfs))
+		state  = make([]int, len(lfs))
+		path   []int
+		visit  func(i int) error
+	)
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			cycle := make([]string, 0, len(path)+1)
+			for j := len(path) - 1; j >= 0; j-- {
+				cycle = append([]string{fmt.Sprintf("%T", lfs[path[j]])}, cycle...)
+				if path[j] == i {
+					break
+				}
+			}
+			cycle = append(cycle, fmt.Sprintf("%T", lfs[i]))
+			return fmt.Errorf("%w: %s", ErrLifecycleCycle, strings.Join(cycle, " -> "))
+		}
+		state[i] = visiting
+		path = append(path, i)
+		for _, dep := range deps[i] {
+			j := indexLifecycle(lfs, dep)
+			if j < 0 {
+				return fmt.Errorf("%w: %T depends on unregistered %T", ErrServiceUnknown, lfs[i], dep)
+			}
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		sorted = append(sorted, lfs[i])
+		return nil
+	}
+	for i := range lfs {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// indexLifecycle returns the index of a lifecycle in the list, or -1 if it is
+// not in it.
+func indexLifecycle(lfs []Lifecycle, l Lifecycle) int {
+	for i, obj := range lfs {
+		if obj == l {
+			return i
+		}
+	}
+	return -1
+}
+
 // stopServices terminates running services, RPC and p2p networking.
 // It is the inverse of Start.
 func (n *Node) stopServices(running []Lifecycle) error {
@@ -323,6 +433,10 @@ func (n *Node) openDataDir() error {
 	if err := os.MkdirAll(instdir, 0700); err != nil {
 		return err
 	}
+	// A read-only instance shares the directory with the instance owning it
+	if n.config.ReadOnlyDataDir {
+		return nil
+	}
 	// Lock the instance directory to prevent concurrent use by another instance as well as
 	// accidental use of the instance directory as a database.
 	release, _, err := fileutil.Flock(filepath.Join(instdir, "LOCK"))
@@ -347,6 +461,20 @@ func (n *Node) closeDataDir() {
 // or from the default location. If neither of those are present, it generates
 // a new secret and stores to the default location.
 func (n *Node) obtainJWTSecret(cliParam string) ([]byte, error) {
+	// Secrets held by a secret store are never generated.
+	if IsSecretRef(cliParam) {
+		data, err := LoadSecret(cliParam)
+		if err != nil {
+			return nil, err
+		}
+		jwtSecret := common.FromHex(strings.TrimSpace(string(data)))
+		if len(jwtSecret) != 32 {
+			log.Error("Invalid JWT secret", "source", cliParam, "length", len(jwtSecret))
+			return nil, errors.New("invalid JWT secret")
+		}
+		log.Info("Loaded JWT secret", "source", cliParam, "crc32", fmt.Sprintf("%#x", crc32.ChecksumIEEE(jwtSecret)))
+		return jwtSecret, nil
+	}
 	fileName := cliParam
 	if len(fileName) == 0 {
 		// no path provided, use default
@@ -403,8 +531,10 @@ func (n *Node) startRPC() error {
 		if err := server.enableRPC(apis, httpConfig{
 			CorsAllowedOrigins: n.config.HTTPCors,
 			Vhosts:             n.config.HTTPVirtualHosts,
+			NamespaceRules:     n.config.HTTPNamespaceRules,
 			Modules:            n.config.HTTPModules,
 			prefix:             n.config.HTTPPathPrefix,
+			compression:        n.config.RPCCompressionThreshold,
 		}); err != nil {
 			return err
 		}
@@ -413,14 +543,19 @@ func (n *Node) startRPC() error {
 	}
 
 	initWS := func(apis []rpc.API, port int) error {
-		server := n.wsServerForPort(port, false)
+		server := n.wsServerFor(n.config.WSHost, port, false)
 		if err := server.setListenAddr(n.config.WSHost, port); err != nil {
 			return err
 		}
 		if err := server.enableWS(n.rpcAPIs, wsConfig{
-			Modules: n.config.WSModules,
-			Origins: n.config.WSOrigins,
-			prefix:  n.config.WSPathPrefix,
+			Modules:       n.config.WSModules,
+			Origins:       n.config.WSOrigins,
+			prefix:        n.config.WSPathPrefix,
+			subBuffer:     n.config.wsSubscriptionBuffer(),
+			compression:   n.config.RPCCompressionThreshold,
+			maxConns:      n.config.WSMaxConnections,
+			maxConnsPerIP: n.config.WSMaxConnectionsPerIP,
+			idleTimeout:   n.config.WSIdleTimeout,
 		}); err != nil {
 			return err
 		}
@@ -440,20 +575,39 @@ func (n *Node) startRPC() error {
 			Modules:            DefaultAuthModules,
 			prefix:             DefaultAuthPrefix,
 			jwtSecret:          secret,
+			compression:        n.config.RPCCompressionThreshold,
 		}); err != nil {
 			return err
 		}
 		servers = append(servers, server)
 		// Enable auth via WS
-		server = n.wsServerForPort(port, true)
+		server = n.wsServerFor(n.config.AuthAddr, port, true)
 		if err := server.setListenAddr(n.config.AuthAddr, port); err != nil {
 			return err
 		}
 		if err := server.enableWS(apis, wsConfig{
-			Modules:   DefaultAuthModules,
-			Origins:   DefaultAuthOrigins,
-			prefix:    DefaultAuthPrefix,
-			jwtSecret: secret,
+			Modules:     DefaultAuthModules,
+			Origins:     DefaultAuthOrigins,
+			prefix:      DefaultAuthPrefix,
+			jwtSecret:   secret,
+			subBuffer:   n.config.wsSubscriptionBuffer(),
+			compression: n.config.RPCCompressionThreshold,
+		}); err != nil {
+			return err
+		}
+		servers = append(servers, server)
+		return nil
+	}
+
+	initAdmin := func(apis []rpc.API) error {
+		server := n.admin
+		if err := server.setListenAddr(n.config.AdminHost, n.config.AdminPort); err != nil {
+			return err
+		}
+		if err := server.enableRPC(apis, httpConfig{
+			Vhosts:      n.config.AdminVirtualHosts,
+			Modules:     DefaultAdminModules,
+			compression: n.config.RPCCompressionThreshold,
 		}); err != nil {
 			return err
 		}
@@ -485,6 +639,12 @@ func (n *Node) startRPC() error {
 			return err
 		}
 	}
+	// Configure the admin API.
+	if n.config.AdminHost != "" {
+		if err := initAdmin(open); err != nil {
+			return err
+		}
+	}
 	// Start the servers
 	for _, server := range servers {
 		if err := server.start(); err != nil {
@@ -494,12 +654,25 @@ func (n *Node) startRPC() error {
 	return nil
 }
 
-func (n *Node) wsServerForPort(port int, authenticated bool) *httpServer {
+// wsServerFor returns the server which should handle WebSocket connections on
+// the given listen address: the HTTP server if it listens there too, otherwise
+// the dedicated WebSocket server.
+func (n *Node) wsServerFor(host string, port int, authenticated bool) *httpServer {
 	httpServer, wsServer := n.http, n.ws
 	if authenticated {
 		httpServer, wsServer = n.httpAuth, n.wsAuth
 	}
-	if n.config.HTTPHost == "" || httpServer.port == port {
+	if n.config.HTTPHost == "" {
+		return httpServer
+	}
+	// Unix sockets are identified by their path alone.
+	if isUnixEndpoint(host) || isUnixEndpoint(httpServer.host) {
+		if host == httpServer.host {
+			return httpServer
+		}
+		return wsServer
+	}
+	if httpServer.port == port {
 		return httpServer
 	}
 	return wsServer
@@ -510,6 +683,7 @@ func (n *Node) stopRPC() {
 	n.ws.stop()
 	n.httpAuth.stop()
 	n.wsAuth.stop()
+	n.admin.stop()
 	n.ipc.stop()
 	n.stopInProc()
 }
@@ -539,6 +713,11 @@ func (n *Node) RegisterLifecycle(lifecycle Lifecycle) {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 
+	n.registerLifecycle(lifecycle)
+}
+
+// registerLifecycle adds a lifecycle to the node. The lock must be held.
+func (n *Node) registerLifecycle(lifecycle Lifecycle) {
 	if n.state != initializingState {
 		panic("can't register lifecycle on running/stopped node")
 	}
@@ -548,6 +727,22 @@ func (n *Node) RegisterLifecycle(lifecycle Lifecycle) {
 	n.lifecycles = append(n.lifecycles, lifecycle)
 }
 
+// RegisterLifecycleWithDeps registers the given Lifecycle on the node, declaring
+// that it requires the given dependencies to be running. Dependencies are started
+// before and stopped after the lifecycle, regardless of registration order. They
+// must be registered by the time the node is started, and cycles are reported
+// as an error by Start.
+func (n *Node) RegisterLifecycleWithDeps(lifecycle Lifecycle, deps ...Lifecycle) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.registerLifecycle(lifecycle)
+	if n.lifecycleDeps == nil {
+		n.lifecycleDeps = make(map[int][]Lifecycle)
+	}
+	n.lifecycleDeps[len(n.lifecycles)-1] = deps
+}
+
 // RegisterProtocols adds backend's protocols to the node's p2p server.
 func (n *Node) RegisterProtocols(protocols []p2p.Protocol) {
 	n.lock.Lock()
@@ -570,6 +765,100 @@ func (n *Node) RegisterAPIs(apis []rpc.API) {
 	n.rpcAPIs = append(n.rpcAPIs, apis...)
 }
 
+// RegisterAPIsLive registers APIs on a running node. Unlike RegisterAPIs, they
+// are immediately served by all open RPC endpoints: the in-process handler and
+// IPC serve all APIs, HTTP and WebSocket the ones admitted by their module
+// configuration. Authenticated APIs are only added to the authenticated
+// endpoints. Before the node is started, it behaves like RegisterAPIs.
+func (n *Node) RegisterAPIsLive(apis []rpc.API) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	switch n.state {
+	case initializingState:
+		n.rpcAPIs = append(n.rpcAPIs, apis...)
+		return nil
+	case closedState:
+		return ErrNodeStopped
+	}
+	// Check the services up front, so registration doesn't fail half way.
+	var (
+		open  []rpc.API
+		check = rpc.NewServer()
+	)
+	defer check.Stop()
+	for _, api := range apis {
+		if api.Namespace == rpc.MetadataApi {
+			return fmt.Errorf("namespace %q is reserved", api.Namespace)
+		}
+		if err := check.RegisterName(api.Namespace, api.Service); err != nil {
+			return err
+		}
+		if !api.Authenticated {
+			open = append(open, api)
+		}
+	}
+	// Register with the in-process and IPC handlers first, they serve all
+	// APIs unconditionally.
+	servers := []*rpc.Server{n.inprocHandler}
+	if srv := n.ipc.server(); srv != nil {
+		servers = append(servers, srv)
+	}
+	for _, srv := range servers {
+		if err := registerAllowedApis(apis, nil, srv); err != nil {
+			return err
+		}
+	}
+	for _, server := range []*httpServer{n.http, n.ws, n.admin} {
+		if err := server.registerAPIs(open); err != nil {
+			return err
+		}
+	}
+	for _, server := range []*httpServer{n.httpAuth, n.wsAuth} {
+		if err := server.registerAPIs(apis); err != nil {
+			return err
+		}
+	}
+	n.rpcAPIs = append(n.rpcAPIs, apis...)
+	return nil
+}
+
+// UnregisterNamespace removes all APIs of the given namespace from the node. On
+// a running node, the namespace is removed from all open RPC endpoints, calls
+// already in progress are not interrupted.
+func (n *Node) UnregisterNamespace(namespace string) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.state == closedState {
+		return ErrNodeStopped
+	}
+	if namespace == rpc.MetadataApi {
+		return fmt.Errorf("namespace %q is reserved", namespace)
+	}
+	var apis []rpc.API
+	for _, api := range n.rpcAPIs {
+		if api.Namespace != namespace {
+			apis = append(apis, api)
+		}
+	}
+	if len(apis) == len(n.rpcAPIs) {
+		return fmt.Errorf("namespace %q not registered", namespace)
+	}
+	n.rpcAPIs = apis
+	if n.state != runningState {
+		return nil
+	}
+	n.inprocHandler.UnregisterName(namespace)
+	if srv := n.ipc.server(); srv != nil {
+		srv.UnregisterName(namespace)
+	}
+	for _, server := range []*httpServer{n.http, n.ws, n.httpAuth, n.wsAuth, n.admin} {
+		server.unregisterNamespace(namespace)
+	}
+	return nil
+}
+
 // GetAPIs return two sets of APIs, both the ones that do not require
 // authentication, and the complete set
 func (n *Node) GetAPIs() (unauthenticated, all []rpc.API) {
@@ -657,28 +946,67 @@ func (n *Node) IPCEndpoint() string {
 // HTTPEndpoint returns the URL of the HTTP server. Note that this URL does not
 // contain the JSON-RPC path prefix set by HTTPPathPrefix.
 func (n *Node) HTTPEndpoint() string {
-	return "http://" + n.http.listenAddr()
+	return endpointURL(n.http.scheme("http"), n.http.listenAddr())
 }
 
 // WSEndpoint returns the current JSON-RPC over WebSocket endpoint.
 func (n *Node) WSEndpoint() string {
 	if n.http.wsAllowed() {
-		return "ws://" + n.http.listenAddr() + n.http.wsConfig.prefix
+		return endpointURL(n.http.scheme("ws"), n.http.listenAddr()) + n.http.wsConfig.prefix
 	}
-	return "ws://" + n.ws.listenAddr() + n.ws.wsConfig.prefix
+	return endpointURL(n.ws.scheme("ws"), n.ws.listenAddr()) + n.ws.wsConfig.prefix
 }
 
 // HTTPAuthEndpoint returns the URL of the authenticated HTTP server.
 func (n *Node) HTTPAuthEndpoint() string {
-	return "http://" + n.httpAuth.listenAddr()
+	return endpointURL("http", n.httpAuth.listenAddr())
 }
 
 // WSAuthEndpoint returns the current authenticated JSON-RPC over WebSocket endpoint.
 func (n *Node) WSAuthEndpoint() string {
 	if n.httpAuth.wsAllowed() {
-		return "ws://" + n.httpAuth.listenAddr() + n.httpAuth.wsConfig.prefix
+		return endpointURL("ws", n.httpAuth.listenAddr()) + n.httpAuth.wsConfig.prefix
 	}
-	return "ws://" + n.wsAuth.listenAddr() + n.wsAuth.wsConfig.prefix
+	return endpointURL("ws", n.wsAuth.listenAddr()) + n.wsAuth.wsConfig.prefix
+}
+
+// SetRPCConcurrencyLimit changes the maximum number of concurrently executed RPC
+// calls and the weight of authenticated calls. A limit below one disables it.
+func (n *Node) SetRPCConcurrencyLimit(limit, engineWeight int) {
+	n.rpcSched.SetLimit(limit, map[rpc.PriorityClass]int{rpc.PriorityEngine: engineWeight})
+}
+
+// SetRPCDeniedMethods replaces the methods rejected by the public HTTP and
+// WebSocket endpoints. It also applies to connections which are already open.
+func (n *Node) SetRPCDeniedMethods(methods []string) {
+	n.rpcDeny.Set(methods)
+}
+
+// SetConfigReloader sets the function invoked by ReloadConfig, which applies the
+// settings that can be changed without restarting the node.
+func (n *Node) SetConfigReloader(reload func() error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	n.reloader = reload
+}
+
+// ReloadConfig reloads the node configuration with the function set by
+// SetConfigReloader.
+func (n *Node) ReloadConfig() error {
+	n.lock.Lock()
+	reload := n.reloader
+	n.lock.Unlock()
+
+	if reload == nil {
+		return ErrReloadUnsupported
+	}
+	return reload()
+}
+
+// AdminEndpoint returns the URL of the admin HTTP server.
+func (n *Node) AdminEndpoint() string {
+	return endpointURL("http", n.admin.listenAddr())
 }
 
 // EventMux retrieves the event multiplexer used by all the network services in
@@ -744,6 +1072,104 @@ func (n *Node) OpenDatabaseWithFreezer(name string, cache, handles int, freezer,
 	return db, err
 }
 
+// OpenTieredDatabaseWithFreezer opens a database with a chain freezer like
+// OpenDatabaseWithFreezer, additionally migrating the state untouched for the
+// given age to a cold database in the given directory, e.g. on a slower volume.
+// If the node is an ephemeral one, a memory database is returned.
+func (n *Node) OpenTieredDatabaseWithFreezer(name string, cache, handles int, freezer, cold string, coldAge time.Duration, namespace string, readonly bool) (ethdb.Database, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.state == closedState {
+		return nil, ErrNodeStopped
+	}
+
+	var db ethdb.Database
+	var err error
+	if n.config.DataDir == "" {
+		db = rawdb.NewMemoryDatabase()
+	} else {
+		root := n.ResolvePath(name)
+		switch {
+		case freezer == "":
+			freezer = filepath.Join(root, "ancient")
+		case !filepath.IsAbs(freezer):
+			freezer = n.ResolvePath(freezer)
+		}
+		if !filepath.IsAbs(cold) {
+			cold = n.ResolvePath(cold)
+		}
+		db, err = rawdb.NewTieredLevelDBDatabaseWithFreezer(root, cold, coldAge, cache, handles, freezer, namespace, readonly)
+	}
+
+	if err == nil {
+		db = n.wrapDatabase(db)
+	}
+	return db, err
+}
+
+// OpenDatabaseWithRemoteFreezer opens a database with a chain freezer like
+// OpenDatabaseWithFreezer, complemented by the ancient chain segments in a remote
+// store. The local ancients available remotely are discarded, the downloaded
+// segments are cached in the ancient directory unless configured otherwise. If
+// the node is an ephemeral one, a memory database is returned.
+func (n *Node) OpenDatabaseWithRemoteFreezer(name string, cache, handles int, freezer string, remote rawdb.RemoteFreezerConfig, namespace string, readonly bool) (ethdb.Database, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.state == closedState {
+		return nil, ErrNodeStopped
+	}
+
+	var db ethdb.Database
+	var err error
+	if n.config.DataDir == "" {
+		db = rawdb.NewMemoryDatabase()
+	} else {
+		root := n.ResolvePath(name)
+		switch {
+		case freezer == "":
+			freezer = filepath.Join(root, "ancient")
+		case !filepath.IsAbs(freezer):
+			freezer = n.ResolvePath(freezer)
+		}
+		switch {
+		case remote.CacheDir == "":
+			remote.CacheDir = filepath.Join(freezer, "remote")
+		case !filepath.IsAbs(remote.CacheDir):
+			remote.CacheDir = n.ResolvePath(remote.CacheDir)
+		}
+		db, err = rawdb.NewLevelDBDatabaseWithRemoteFreezer(root, cache, handles, freezer, remote, namespace, readonly)
+	}
+
+	if err == nil {
+		db = n.wrapDatabase(db)
+	}
+	return db, err
+}
+
+// OpenSharedDatabaseWithFreezer opens a database with a chain freezer like
+// OpenDatabaseWithFreezer, read-only and without locking it, while the node
+// owning the data directory may be writing to it. The database is a snapshot of
+// the last state flushed by the writer, which has to be refreshed to follow it.
+// Unlike the other databases, it is not closed by the node.
+func (n *Node) OpenSharedDatabaseWithFreezer(name string, cache, handles int, freezer, namespace string) (*rawdb.SharedDatabase, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if n.state == closedState {
+		return nil, ErrNodeStopped
+	}
+	if n.config.DataDir == "" {
+		return nil, errors.New("shared database requires a data directory")
+	}
+	root := n.ResolvePath(name)
+	switch {
+	case freezer == "":
+		freezer = filepath.Join(root, "ancient")
+	case !filepath.IsAbs(freezer):
+		freezer = n.ResolvePath(freezer)
+	}
+	return rawdb.NewSharedLevelDBDatabaseWithFreezer(root, cache, handles, freezer, namespace)
+}
+
 // ResolvePath returns the absolute path of a resource in the instance directory.
 func (n *Node) ResolvePath(x string) string {
 	return n.config.ResolvePath(x)
@@ -25,6 +25,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
@@ -258,6 +259,77 @@ func TestLifecycleLifeCycle(t *testing.T) {
 	}
 }
 
+// Tests that lifecycles with declared dependencies are started after and stopped
+// before their dependencies, and that cycles are rejected.
+func TestLifecycleDependencies(t *testing.T) {
+	stack, _ := New(testNodeConfig())
+	defer stack.Close()
+
+	var events []string
+	newService := func(id string) *InstrumentedService {
+		return &InstrumentedService{
+			startHook: func() { events = append(events, "start "+id) },
+			stopHook:  func() { events = append(events, "stop "+id) },
+		}
+	}
+	a, b, c := newService("A"), newService("B"), newService("C")
+
+	// B needs A and C, but is registered first, and C needs A.
+	stack.RegisterLifecycleWithDeps(b, a, c)
+	stack.RegisterLifecycleWithDeps(c, a)
+	stack.RegisterLifecycle(a)
+
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if err := stack.Close(); err != nil {
+		t.Fatalf("failed to stop protocol stack: %v", err)
+	}
+	want := []string{"start A", "start C", "start B", "stop B", "stop C", "stop A"}
+	if !reflect.DeepEqual(events, want) {
+		t.Fatalf("lifecycle order mismatch: have %v, want %v", events, want)
+	}
+
+	// Cycles and unregistered dependencies fail to start.
+	stack, _ = New(testNodeConfig())
+	defer stack.Close()
+	a, b = newService("A"), newService("B")
+	stack.RegisterLifecycleWithDeps(a, b)
+	stack.RegisterLifecycleWithDeps(b, a)
+	if err := stack.Start(); !errors.Is(err, ErrLifecycleCycle) {
+		t.Fatalf("cyclic start error mismatch: have %v, want %v", err, ErrLifecycleCycle)
+	}
+
+	stack, _ = New(testNodeConfig())
+	defer stack.Close()
+	stack.RegisterLifecycleWithDeps(newService("A"), newService("B"))
+	if err := stack.Start(); !errors.Is(err, ErrServiceUnknown) {
+		t.Fatalf("unregistered dependency error mismatch: have %v, want %v", err, ErrServiceUnknown)
+	}
+
+	// Lifecycles which can't be map keys may declare dependencies too.
+	stack, _ = New(testNodeConfig())
+	defer stack.Close()
+	events = nil
+	a = newService("A")
+	stack.RegisterLifecycleWithDeps(uncomparableLifecycle{func() { events = append(events, "start B") }}, a)
+	stack.RegisterLifecycle(a)
+	if err := stack.Start(); err != nil {
+		t.Fatalf("failed to start protocol stack: %v", err)
+	}
+	if want := []string{"start A", "start B"}; !reflect.DeepEqual(events, want) {
+		t.Fatalf("lifecycle order mismatch: have %v, want %v", events, want)
+	}
+}
+
+// uncomparableLifecycle is a lifecycle whose values can't be compared.
+type uncomparableLifecycle struct {
+	start func()
+}
+
+func (l uncomparableLifecycle) Start() error { l.start(); return nil }
+func (l uncomparableLifecycle) Stop() error  { return nil }
+
 // Tests that if a Lifecycle fails to start, all others started before it will be
 // shut down.
 func TestLifecycleStartupError(t *testing.T) {
@@ -430,6 +502,210 @@ func TestRegisterHandler_Unsuccessful(t *testing.T) {
 	node.RegisterHandler("test", "/test", handler)
 }
 
+type liveService struct{}
+
+func (liveService) Ping() string { return "pong" }
+
+// Tests that the deny list of a running node applies to the public endpoints only.
+func TestSetRPCDeniedMethods(t *testing.T) {
+	node := startHTTP(t, 0, 0)
+	defer node.Close()
+
+	client, err := rpc.DialHTTP(node.HTTPEndpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	inproc, _ := node.Attach()
+	defer inproc.Close()
+
+	if err := node.RegisterAPIsLive([]rpc.API{{Namespace: "live", Service: liveService{}}}); err != nil {
+		t.Fatalf("could not register APIs: %v", err)
+	}
+	var result string
+	if err := client.Call(&result, "live_ping"); err != nil {
+		t.Fatalf("call failed before denying: %v", err)
+	}
+	node.SetRPCDeniedMethods([]string{"live_ping"})
+	if err := client.Call(&result, "live_ping"); err == nil {
+		t.Fatal("denied call succeeded")
+	}
+	if err := inproc.Call(&result, "live_ping"); err != nil {
+		t.Fatalf("in-process call failed: %v", err)
+	}
+	node.SetRPCDeniedMethods(nil)
+	if err := client.Call(&result, "live_ping"); err != nil {
+		t.Fatalf("call failed after lifting the deny list: %v", err)
+	}
+}
+
+// Tests that APIs can be added to and removed from a running node.
+func TestRegisterAPIsLive(t *testing.T) {
+	node := startHTTP(t, 0, 0)
+	defer node.Close()
+
+	client, err := rpc.DialHTTP(node.HTTPEndpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	inproc, _ := node.Attach()
+	defer inproc.Close()
+
+	var result string
+	if err := client.Call(&result, "live_ping"); err == nil {
+		t.Fatal("call succeeded before registration")
+	}
+	if err := node.RegisterAPIsLive([]rpc.API{{Namespace: "live", Service: liveService{}}}); err != nil {
+		t.Fatalf("could not register APIs: %v", err)
+	}
+	for _, c := range []*rpc.Client{client, inproc} {
+		if err := c.Call(&result, "live_ping"); err != nil || result != "pong" {
+			t.Fatalf("call failed after registration: %q %v", result, err)
+		}
+	}
+	if err := node.UnregisterNamespace("live"); err != nil {
+		t.Fatalf("could not unregister namespace: %v", err)
+	}
+	for _, c := range []*rpc.Client{client, inproc} {
+		if err := c.Call(&result, "live_ping"); err == nil {
+			t.Fatal("call succeeded after unregistration")
+		}
+	}
+	if err := node.UnregisterNamespace("live"); err == nil {
+		t.Fatal("unregistered namespace twice")
+	}
+	if err := node.RegisterAPIsLive([]rpc.API{{Namespace: "rpc", Service: liveService{}}}); err == nil {
+		t.Fatal("registered reserved namespace")
+	}
+}
+
+// Tests that the admin endpoint only serves the admin namespaces.
+func TestAdminEndpoint(t *testing.T) {
+	conf := &Config{AdminHost: "127.0.0.1", AdminVirtualHosts: []string{"*"}}
+	node, err := New(conf)
+	if err != nil {
+		t.Fatalf("could not create node: %v", err)
+	}
+	defer node.Close()
+	node.RegisterAPIs([]rpc.API{
+		{Namespace: "admin", Service: liveService{}},
+		{Namespace: "debug", Service: liveService{}},
+		{Namespace: "live", Service: liveService{}},
+	})
+	if err := node.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	client, err := rpc.DialHTTP(node.AdminEndpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var result string
+	for _, method := range []string{"admin_ping", "debug_ping"} {
+		if err := client.Call(&result, method); err != nil || result != "pong" {
+			t.Fatalf("%s failed: %q %v", method, result, err)
+		}
+	}
+	if err := client.Call(&result, "live_ping"); err == nil {
+		t.Fatal("non-admin namespace served on the admin endpoint")
+	}
+}
+
+// Tests that node lifecycle and RPC listener changes are posted as node events.
+func TestNodeEvents(t *testing.T) {
+	node, err := New(&Config{HTTPHost: "127.0.0.1"})
+	if err != nil {
+		t.Fatalf("could not create node: %v", err)
+	}
+	events := make(chan NodeEvent, 10)
+	sub := node.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	if err := node.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	node.Close()
+
+	var have []NodeEventType
+	for len(events) > 0 {
+		ev := <-events
+		have = append(have, ev.Type)
+		if info, ok := ev.Data.(RPCListenerInfo); ok && (!info.HTTP || info.Endpoint == "") {
+			t.Errorf("wrong listener info in %s event: %+v", ev.Type, info)
+		}
+	}
+	want := []NodeEventType{RPCListenerUp, NodeStarted, NodeStopping, RPCListenerDown}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("wrong events: have %v, want %v", have, want)
+	}
+}
+
+type blockingService struct{ entered, release chan struct{} }
+
+func (s blockingService) Block() {
+	s.entered <- struct{}{}
+	<-s.release
+}
+
+// Tests that draining refuses new requests, waits for in-flight ones and runs
+// the drain hooks.
+func TestNodeDrain(t *testing.T) {
+	node := createNode(t, 0, 0)
+	defer node.Close()
+	service := blockingService{make(chan struct{}), make(chan struct{})}
+	node.RegisterAPIs([]rpc.API{{Namespace: "block", Service: service}})
+	hooked := make(chan struct{})
+	node.RegisterDrainHook(func() error {
+		close(hooked)
+		return nil
+	})
+	if err := node.Start(); err != nil {
+		t.Fatalf("could not start node: %v", err)
+	}
+	client, err := rpc.DialHTTP(node.HTTPEndpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	done := make(chan error)
+	go func() { done <- client.Call(nil, "block_block") }()
+	<-service.entered
+
+	if err := node.StartDrain(time.Minute); err != nil {
+		t.Fatalf("could not start drain: %v", err)
+	}
+	if err := node.StartDrain(time.Minute); err != ErrDraining {
+		t.Fatalf("wrong error for repeated drain: %v", err)
+	}
+	req, _ := http.NewRequest(http.MethodPost, node.HTTPEndpoint(), strings.NewReader("{}"))
+	resp := doHTTPRequest(t, req)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || resp.Header.Get("Retry-After") != "60" {
+		t.Fatalf("new request not refused: %d, Retry-After %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if status := node.DrainStatus(); !status.Draining || status.Drained || status.InFlight != 1 {
+		t.Fatalf("wrong status with request in flight: %+v", status)
+	}
+	close(service.release)
+	if err := <-done; err != nil {
+		t.Fatalf("in-flight request failed: %v", err)
+	}
+	select {
+	case <-hooked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("drain hook not run")
+	}
+	for i := 0; !node.DrainStatus().Drained; i++ {
+		if i == 100 {
+			t.Fatal("node not drained")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 // Tests whether websocket requests can be handled on the same port as a regular http server.
 func TestWebsocketHTTPOnSamePort_WebsocketRequest(t *testing.T) {
 	node := startHTTP(t, 0, 0)
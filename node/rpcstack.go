@@ -17,19 +17,27 @@
 package node
 
 import (
+	"bytes"
+	"compress/flate"
 	"compress/gzip"
 	"context"
+	"crypto/tls"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/rs/cors"
 )
@@ -39,16 +47,24 @@ type httpConfig struct {
 	Modules            []string
 	CorsAllowedOrigins []string
 	Vhosts             []string
-	prefix             string // path prefix on which to mount http handler
-	jwtSecret          []byte // optional JWT secret
+	NamespaceRules     map[string]NamespaceRule // per-namespace CORS and vhost overrides
+	prefix             string                   // path prefix on which to mount http handler
+	jwtSecret          []byte                   // optional JWT secret
+	compression        int                      // minimum size of compressed responses, negative if disabled
 }
 
 // wsConfig is the JSON-RPC/Websocket configuration
 type wsConfig struct {
-	Origins   []string
-	Modules   []string
-	prefix    string // path prefix on which to mount ws handler
-	jwtSecret []byte // optional JWT secret
+	Origins     []string
+	Modules     []string
+	prefix      string                       // path prefix on which to mount ws handler
+	jwtSecret   []byte                       // optional JWT secret
+	subBuffer   rpc.SubscriptionBufferConfig // queueing of subscription notifications
+	compression int                          // minimum size of compressed messages, negative if disabled
+
+	maxConns      int           // maximum number of concurrent connections, zero if unlimited
+	maxConnsPerIP int           // maximum number of concurrent connections per remote IP, zero if unlimited
+	idleTimeout   time.Duration // inactivity after which connections are closed, zero if disabled
 }
 
 type rpcHandler struct {
@@ -80,6 +96,16 @@ type httpServer struct {
 	port     int
 
 	handlerNames map[string]string
+
+	// Admission control of the RPC servers, set by setScheduler and setDenylist.
+	sched      *rpc.Scheduler
+	schedClass rpc.PriorityClass
+	deny       *rpc.MethodDenylist
+
+	tlsConfig *tls.Config // Serves TLS on TCP listeners if set, see setTLS
+
+	notify func(NodeEventType, interface{}) // Reports listener changes, may be nil
+	drain  *drainer                         // Admission of requests while draining, may be nil
 }
 
 const (
@@ -94,8 +120,26 @@ func newHTTPServer(log log.Logger, timeouts rpc.HTTPTimeouts) *httpServer {
 	return h
 }
 
-// setListenAddr configures the listening address of the server.
-// The address can only be set while the server isn't running.
+// unixEndpointPrefix marks listen hosts which are Unix domain socket paths.
+const unixEndpointPrefix = "unix://"
+
+// isUnixEndpoint reports whether the given listen host is a Unix socket.
+func isUnixEndpoint(host string) bool {
+	return strings.HasPrefix(host, unixEndpointPrefix)
+}
+
+// endpointURL returns the URL of a listen address for the given scheme. Unix
+// socket endpoints are returned as is.
+func endpointURL(scheme, addr string) string {
+	if isUnixEndpoint(addr) {
+		return addr
+	}
+	return scheme + "://" + addr
+}
+
+// setListenAddr configures the listening address of the server. A host of the
+// form unix:///path/to/socket makes the server listen on a Unix domain socket,
+// ignoring the port. The address can only be set while the server isn't running.
 func (h *httpServer) setListenAddr(host string, port int) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -103,18 +147,95 @@ func (h *httpServer) setListenAddr(host string, port int) error {
 	if h.listener != nil && (host != h.host || port != h.port) {
 		return fmt.Errorf("HTTP server already running on %s", h.endpoint)
 	}
-
+	if isUnixEndpoint(host) && host == unixEndpointPrefix {
+		return fmt.Errorf("missing Unix socket path in %q", host)
+	}
 	h.host, h.port = host, port
-	h.endpoint = fmt.Sprintf("%s:%d", host, port)
+	if isUnixEndpoint(host) {
+		h.endpoint = host
+	} else {
+		h.endpoint = fmt.Sprintf("%s:%d", host, port)
+	}
 	return nil
 }
 
+// listen opens the listener of the configured endpoint. Leftover Unix sockets
+// of previous runs are replaced, the socket file is removed again when the
+// listener is closed.
+func (h *httpServer) listen() (net.Listener, error) {
+	if !isUnixEndpoint(h.endpoint) {
+		listener, err := net.Listen("tcp", h.endpoint)
+		if err != nil || h.tlsConfig == nil {
+			return listener, err
+		}
+		return tls.NewListener(listener, h.tlsConfig), nil
+	}
+	path := strings.TrimPrefix(h.endpoint, unixEndpointPrefix)
+	if err := os.MkdirAll(filepath.Dir(path), 0751); err != nil {
+		return nil, err
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("refusing to replace non-socket file %s", path)
+		}
+		os.Remove(path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// setTLS makes the server serve TLS with the given configuration. Unix socket
+// endpoints remain unencrypted. TLS can only be set while the server isn't running.
+func (h *httpServer) setTLS(config *tls.Config) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.tlsConfig = config
+}
+
+// scheme returns the URL scheme of the server for the given unencrypted one.
+func (h *httpServer) scheme(plain string) string {
+	if h.tlsConfig != nil {
+		return plain + "s"
+	}
+	return plain
+}
+
+// setScheduler makes the RPC servers created by this server subject to admission
+// by the given scheduler under the given priority class.
+func (h *httpServer) setScheduler(sched *rpc.Scheduler, class rpc.PriorityClass) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.sched, h.schedClass = sched, class
+}
+
+// setDenylist makes the RPC servers created by this server reject the methods in
+// the given deny list.
+func (h *httpServer) setDenylist(deny *rpc.MethodDenylist) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.deny = deny
+}
+
 // listenAddr returns the listening address of the server.
 func (h *httpServer) listenAddr() string {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if h.listener != nil {
+	return h.listenAddrLocked()
+}
+
+func (h *httpServer) listenAddrLocked() string {
+	if h.listener != nil && !isUnixEndpoint(h.endpoint) {
 		return h.listener.Addr().String()
 	}
 	return h.endpoint
@@ -139,7 +260,7 @@ func (h *httpServer) start() error {
 	}
 
 	// Start the server.
-	listener, err := net.Listen("tcp", h.endpoint)
+	listener, err := h.listen()
 	if err != nil {
 		// If the server fails to start, we need to clear out the RPC and WS
 		// configuration so they can be configured another time.
@@ -149,9 +270,12 @@ func (h *httpServer) start() error {
 	}
 	h.listener = listener
 	go h.server.Serve(listener)
+	if h.notify != nil {
+		h.notify(RPCListenerUp, h.listenerInfo())
+	}
 
 	if h.wsAllowed() {
-		url := fmt.Sprintf("ws://%v", listener.Addr())
+		url := endpointURL(h.scheme("ws"), h.listenAddrLocked())
 		if h.wsConfig.prefix != "" {
 			url += h.wsConfig.prefix
 		}
@@ -163,7 +287,7 @@ func (h *httpServer) start() error {
 	}
 	// Log http endpoint.
 	h.log.Info("HTTP server started",
-		"endpoint", listener.Addr(), "auth", (h.httpConfig.jwtSecret != nil),
+		"endpoint", h.listenAddrLocked(), "auth", (h.httpConfig.jwtSecret != nil),
 		"prefix", h.httpConfig.prefix,
 		"cors", strings.Join(h.httpConfig.CorsAllowedOrigins, ","),
 		"vhosts", strings.Join(h.httpConfig.Vhosts, ","),
@@ -179,7 +303,7 @@ func (h *httpServer) start() error {
 	for _, path := range paths {
 		name := h.handlerNames[path]
 		if !logged[name] {
-			log.Info(name+" enabled", "url", "http://"+listener.Addr().String()+path)
+			log.Info(name+" enabled", "url", endpointURL(h.scheme("http"), h.listenAddrLocked())+path)
 			logged[name] = true
 		}
 	}
@@ -187,6 +311,19 @@ func (h *httpServer) start() error {
 }
 
 func (h *httpServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// refuse new requests and connections when draining
+	if h.drain != nil {
+		if !h.drain.enter() {
+			h.drain.refuse(w)
+			return
+		}
+		// Established WebSocket connections are not waited for.
+		if isWebsocket(r) {
+			h.drain.leave()
+		} else {
+			defer h.drain.leave()
+		}
+	}
 	// check if ws request and serve if ws enabled
 	ws := h.wsHandler.Load().(*rpcHandler)
 	if ws != nil && isWebsocket(r) {
@@ -243,6 +380,16 @@ func validatePrefix(what, path string) error {
 	return nil
 }
 
+// listenerInfo describes the running listener for node events.
+func (h *httpServer) listenerInfo() RPCListenerInfo {
+	return RPCListenerInfo{
+		Endpoint: h.listenAddrLocked(),
+		HTTP:     h.rpcAllowed(),
+		WS:       h.wsAllowed(),
+		Auth:     h.httpConfig.jwtSecret != nil || h.wsConfig.jwtSecret != nil,
+	}
+}
+
 // stop shuts down the HTTP server.
 func (h *httpServer) stop() {
 	h.mu.Lock()
@@ -256,6 +403,7 @@ func (h *httpServer) doStop() {
 	}
 
 	// Shut down the server.
+	info := h.listenerInfo()
 	httpHandler := h.httpHandler.Load().(*rpcHandler)
 	wsHandler := h.wsHandler.Load().(*rpcHandler)
 	if httpHandler != nil {
@@ -274,7 +422,10 @@ func (h *httpServer) doStop() {
 		h.server.Close()
 	}
 	h.listener.Close()
-	h.log.Info("HTTP server stopped", "endpoint", h.listener.Addr())
+	h.log.Info("HTTP server stopped", "endpoint", h.listenAddrLocked())
+	if h.notify != nil {
+		h.notify(RPCListenerDown, info)
+	}
 
 	// Clear out everything to allow re-configuring it later.
 	h.host, h.port, h.endpoint = "", 0, ""
@@ -292,12 +443,29 @@ func (h *httpServer) enableRPC(apis []rpc.API, config httpConfig) error {
 
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	if h.sched != nil {
+		srv.SetScheduler(h.sched, h.schedClass)
+	}
+	srv.SetMethodDenylist(h.deny)
 	if err := RegisterApis(apis, config.Modules, srv); err != nil {
 		return err
 	}
 	h.httpConfig = config
+
+	// With namespace rules in place, the outer CORS and vhost handlers admit
+	// the union of all configured values and the namespace handler narrows
+	// them down per request.
+	var (
+		handler = http.Handler(srv)
+		cors    = config.CorsAllowedOrigins
+		vhosts  = config.Vhosts
+	)
+	if len(config.NamespaceRules) > 0 {
+		nh := newNamespaceHandler(config, srv)
+		handler, cors, vhosts = nh, nh.cors, nh.vhosts
+	}
 	h.httpHandler.Store(&rpcHandler{
-		Handler: NewHTTPHandlerStack(srv, config.CorsAllowedOrigins, config.Vhosts, config.jwtSecret),
+		Handler: newHTTPHandlerStack(handler, cors, vhosts, config.jwtSecret, config.compression),
 		server:  srv,
 	})
 	return nil
@@ -323,12 +491,23 @@ func (h *httpServer) enableWS(apis []rpc.API, config wsConfig) error {
 	}
 	// Create RPC server and handler.
 	srv := rpc.NewServer()
+	if h.sched != nil {
+		srv.SetScheduler(h.sched, h.schedClass)
+	}
+	srv.SetMethodDenylist(h.deny)
+	srv.SetSubscriptionBuffer(config.subBuffer)
+	srv.SetWebsocketCompression(config.compression)
+	srv.SetWebsocketIdleTimeout(config.idleTimeout)
 	if err := RegisterApis(apis, config.Modules, srv); err != nil {
 		return err
 	}
 	h.wsConfig = config
+	handler := srv.WebsocketHandler(config.Origins)
+	if config.maxConns > 0 || config.maxConnsPerIP > 0 {
+		handler = newWSConnLimiter(handler, config.maxConns, config.maxConnsPerIP)
+	}
 	h.wsHandler.Store(&rpcHandler{
-		Handler: NewWSHandlerStack(srv.WebsocketHandler(config.Origins), config.jwtSecret),
+		Handler: NewWSHandlerStack(handler, config.jwtSecret),
 		server:  srv,
 	})
 	return nil
@@ -356,6 +535,38 @@ func (h *httpServer) disableWS() bool {
 	return ws != nil
 }
 
+// registerAPIs adds APIs to the running HTTP and WebSocket RPC servers, subject
+// to their module configuration.
+func (h *httpServer) registerAPIs(apis []rpc.API) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if handler := h.httpHandler.Load().(*rpcHandler); handler != nil {
+		if err := registerAllowedApis(apis, h.httpConfig.Modules, handler.server); err != nil {
+			return err
+		}
+	}
+	if handler := h.wsHandler.Load().(*rpcHandler); handler != nil {
+		if err := registerAllowedApis(apis, h.wsConfig.Modules, handler.server); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unregisterNamespace removes a namespace from the running RPC servers.
+func (h *httpServer) unregisterNamespace(namespace string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if handler := h.httpHandler.Load().(*rpcHandler); handler != nil {
+		handler.server.UnregisterName(namespace)
+	}
+	if handler := h.wsHandler.Load().(*rpcHandler); handler != nil {
+		handler.server.UnregisterName(namespace)
+	}
+}
+
 // rpcAllowed returns true when JSON-RPC over HTTP is enabled.
 func (h *httpServer) rpcAllowed() bool {
 	return h.httpHandler.Load().(*rpcHandler) != nil
@@ -374,13 +585,22 @@ func isWebsocket(r *http.Request) bool {
 
 // NewHTTPHandlerStack returns wrapped http-related handlers
 func NewHTTPHandlerStack(srv http.Handler, cors []string, vhosts []string, jwtSecret []byte) http.Handler {
+	return newHTTPHandlerStack(srv, cors, vhosts, jwtSecret, 0)
+}
+
+// newHTTPHandlerStack is NewHTTPHandlerStack with a configurable minimum size of
+// compressed responses.
+func newHTTPHandlerStack(srv http.Handler, cors []string, vhosts []string, jwtSecret []byte, compression int) http.Handler {
 	// Wrap the CORS-handler within a host-handler
 	handler := newCorsHandler(srv, cors)
 	handler = newVHostHandler(vhosts, handler)
 	if len(jwtSecret) != 0 {
 		handler = newJWTHandler(jwtSecret, handler)
 	}
-	return newGzipHandler(handler)
+	if compression < 0 {
+		return handler
+	}
+	return newCompressionHandler(handler, compression)
 }
 
 // NewWSHandlerStack returns a wrapped ws-related handler.
@@ -391,6 +611,71 @@ func NewWSHandlerStack(srv http.Handler, jwtSecret []byte) http.Handler {
 	return srv
 }
 
+// wsRejectedMeter counts WebSocket upgrades rejected by connection limits.
+var wsRejectedMeter = metrics.NewRegisteredMeter("rpc/ws/rejected", nil)
+
+// wsConnLimiter rejects WebSocket upgrades exceeding the configured number of
+// concurrent connections, globally or from a single remote IP. It relies on the
+// wrapped handler serving the connection until it is closed.
+type wsConnLimiter struct {
+	next          http.Handler
+	maxConns      int
+	maxConnsPerIP int
+
+	mu    sync.Mutex
+	conns int
+	perIP map[string]int
+}
+
+func newWSConnLimiter(next http.Handler, maxConns, maxConnsPerIP int) http.Handler {
+	return &wsConnLimiter{
+		next:          next,
+		maxConns:      maxConns,
+		maxConnsPerIP: maxConnsPerIP,
+		perIP:         make(map[string]int),
+	}
+}
+
+// ServeHTTP implements http.Handler.
+func (l *wsConnLimiter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !l.acquire(ip) {
+		wsRejectedMeter.Mark(1)
+		http.Error(w, "too many websocket connections", http.StatusServiceUnavailable)
+		return
+	}
+	defer l.release(ip)
+	l.next.ServeHTTP(w, r)
+}
+
+func (l *wsConnLimiter) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.maxConns > 0 && l.conns >= l.maxConns {
+		return false
+	}
+	if l.maxConnsPerIP > 0 && l.perIP[ip] >= l.maxConnsPerIP {
+		return false
+	}
+	l.conns++
+	l.perIP[ip]++
+	return true
+}
+
+func (l *wsConnLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.conns--
+	if l.perIP[ip]--; l.perIP[ip] == 0 {
+		delete(l.perIP, ip)
+	}
+}
+
 func newCorsHandler(srv http.Handler, allowedOrigins []string) http.Handler {
 	// disable CORS support if user has not specified a custom CORS configuration
 	if len(allowedOrigins) == 0 {
@@ -424,10 +709,18 @@ func newVHostHandler(vhosts []string, next http.Handler) http.Handler {
 
 // ServeHTTP serves JSON-RPC requests over HTTP, implements http.Handler
 func (h *virtualHostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.allowed(r) {
+		http.Error(w, "invalid host specified", http.StatusForbidden)
+		return
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// allowed reports whether the Host-header of the request is acceptable.
+func (h *virtualHostHandler) allowed(r *http.Request) bool {
 	// if r.Host is not set, we can continue serving since a browser would set the Host header
 	if r.Host == "" {
-		h.next.ServeHTTP(w, r)
-		return
+		return true
 	}
 	host, _, err := net.SplitHostPort(r.Host)
 	if err != nil {
@@ -436,59 +729,350 @@ func (h *virtualHostHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	if ipAddr := net.ParseIP(host); ipAddr != nil {
 		// It's an IP address, we can serve that
-		h.next.ServeHTTP(w, r)
-		return
-
+		return true
 	}
 	// Not an IP address, but a hostname. Need to validate
 	if _, exist := h.vhosts["*"]; exist {
+		return true
+	}
+	_, exist := h.vhosts[host]
+	return exist
+}
+
+// NamespaceRule overrides the CORS origins and virtual hosts accepted by the
+// HTTP endpoint for a single API namespace. Nil fields inherit the endpoint
+// wide setting.
+type NamespaceRule struct {
+	CorsAllowedOrigins []string `toml:",omitempty"`
+	VirtualHosts       []string `toml:",omitempty"`
+}
+
+// maxNamespaceCheckBody is the largest request body inspected for namespace
+// rules. It matches the request size limit of the RPC server.
+const maxNamespaceCheckBody = 5 * 1024 * 1024
+
+// namespacePolicy is the resolved access policy of a namespace.
+type namespacePolicy struct {
+	origins []string
+	vhosts  *virtualHostHandler
+}
+
+// namespaceHandler enforces per-namespace CORS origins and virtual hosts by
+// inspecting the methods of a JSON-RPC request before it is dispatched.
+type namespaceHandler struct {
+	defaults namespacePolicy
+	rules    map[string]namespacePolicy
+	cors     []string // union of all allowed origins
+	vhosts   []string // union of all allowed virtual hosts
+	next     http.Handler
+}
+
+func newNamespaceHandler(config httpConfig, next http.Handler) *namespaceHandler {
+	h := &namespaceHandler{
+		defaults: namespacePolicy{config.CorsAllowedOrigins, newVHostHandler(config.Vhosts, nil).(*virtualHostHandler)},
+		rules:    make(map[string]namespacePolicy, len(config.NamespaceRules)),
+		next:     next,
+	}
+	var (
+		cors   = make(map[string]struct{})
+		vhosts = make(map[string]struct{})
+	)
+	add := func(set map[string]struct{}, list *[]string, values []string) {
+		for _, v := range values {
+			if _, ok := set[v]; !ok {
+				set[v] = struct{}{}
+				*list = append(*list, v)
+			}
+		}
+	}
+	add(cors, &h.cors, config.CorsAllowedOrigins)
+	add(vhosts, &h.vhosts, config.Vhosts)
+	for namespace, rule := range config.NamespaceRules {
+		policy := h.defaults
+		if rule.CorsAllowedOrigins != nil {
+			policy.origins = rule.CorsAllowedOrigins
+			add(cors, &h.cors, rule.CorsAllowedOrigins)
+		}
+		if rule.VirtualHosts != nil {
+			policy.vhosts = newVHostHandler(rule.VirtualHosts, nil).(*virtualHostHandler)
+			add(vhosts, &h.vhosts, rule.VirtualHosts)
+		}
+		h.rules[namespace] = policy
+	}
+	sort.Strings(h.cors)
+	sort.Strings(h.vhosts)
+	return h
+}
+
+// ServeHTTP serves JSON-RPC requests over HTTP, implements http.Handler
+func (h *namespaceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.Body == nil {
 		h.next.ServeHTTP(w, r)
 		return
 	}
-	if _, exist := h.vhosts[host]; exist {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxNamespaceCheckBody+1))
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) > maxNamespaceCheckBody {
+		// Too large to be served anyway, leave it to the RPC server to reject.
 		h.next.ServeHTTP(w, r)
 		return
 	}
-	http.Error(w, "invalid host specified", http.StatusForbidden)
+	// The RPC server decodes requests leniently, so bodies not parsed here may
+	// still be executed and are refused rather than let through unchecked.
+	namespaces, ok := requestNamespaces(body)
+	if !ok {
+		http.Error(w, "invalid JSON-RPC request", http.StatusBadRequest)
+		return
+	}
+	origin := r.Header.Get("Origin")
+	for _, namespace := range namespaces {
+		policy, ok := h.rules[namespace]
+		if !ok {
+			policy = h.defaults
+		}
+		if !policy.vhosts.allowed(r) {
+			http.Error(w, fmt.Sprintf("invalid host specified for namespace %q", namespace), http.StatusForbidden)
+			return
+		}
+		// Without allowed origins CORS is off for the namespace, so only the
+		// origins the CORS handler would admit for other namespaces need to be
+		// turned away.
+		if origin != "" && !originAllowed(policy.origins, origin) && (len(policy.origins) > 0 || originAllowed(h.cors, origin)) {
+			http.Error(w, fmt.Sprintf("origin not allowed for namespace %q", namespace), http.StatusForbidden)
+			return
+		}
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// requestNamespaces returns the distinct API namespaces called by a JSON-RPC
+// request or batch, and false if the request can't be parsed.
+func requestNamespaces(body []byte) ([]string, bool) {
+	type call struct {
+		Method string `json:"method"`
+	}
+	var calls []call
+	body = bytes.TrimLeft(body, " \t\r\n")
+	if len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &calls); err != nil {
+			return nil, false
+		}
+	} else {
+		var c call
+		if err := json.Unmarshal(body, &c); err != nil {
+			return nil, false
+		}
+		calls = append(calls, c)
+	}
+	var (
+		seen       = make(map[string]struct{})
+		namespaces []string
+	)
+	for _, c := range calls {
+		namespace := c.Method
+		if i := strings.Index(namespace, "_"); i >= 0 {
+			namespace = namespace[:i]
+		}
+		if _, ok := seen[namespace]; !ok {
+			seen[namespace] = struct{}{}
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	return namespaces, true
 }
 
-var gzPool = sync.Pool{
-	New: func() interface{} {
-		w := gzip.NewWriter(io.Discard)
-		return w
-	},
+// originAllowed reports whether origin matches one of the allowed origins.
+// Like the CORS handler, it accepts "*" and patterns containing one wildcard.
+func originAllowed(allowed []string, origin string) bool {
+	origin = strings.ToLower(origin)
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(pattern)
+		if pattern == "*" || pattern == origin {
+			return true
+		}
+		if i := strings.IndexByte(pattern, '*'); i >= 0 {
+			prefix, suffix := pattern[:i], pattern[i+1:]
+			if len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return false
 }
 
-type gzipResponseWriter struct {
-	io.Writer
+// compressWriter is a pooled response compressor.
+type compressWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+}
+
+var (
+	gzPool = sync.Pool{
+		New: func() interface{} {
+			return gzip.NewWriter(io.Discard)
+		},
+	}
+	flatePool = sync.Pool{
+		New: func() interface{} {
+			w, _ := flate.NewWriter(io.Discard, flate.DefaultCompression)
+			return w
+		},
+	}
+)
+
+// responseEncodings are the supported content codings in order of preference.
+var responseEncodings = []struct {
+	name string
+	pool *sync.Pool
+}{
+	{"gzip", &gzPool},
+	{"deflate", &flatePool},
+}
+
+// negotiateEncoding returns the index of the supported content coding the client
+// prefers according to its Accept-Encoding header, or -1 if it accepts none.
+// Codings of equal quality are ranked by responseEncodings order.
+func negotiateEncoding(header string) int {
+	var (
+		best     = -1
+		bestQ    float64
+		wildcard = -1.0
+		quality  = make([]float64, len(responseEncodings))
+	)
+	for i := range quality {
+		quality[i] = -1
+	}
+	for _, part := range strings.Split(header, ",") {
+		name, q := part, 1.0
+		if i := strings.IndexByte(part, ';'); i >= 0 {
+			name = part[:i]
+			for _, param := range strings.Split(part[i+1:], ";") {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					var err error
+					if q, err = strconv.ParseFloat(param[2:], 64); err != nil {
+						q = 0
+					}
+				}
+			}
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+		for i, enc := range responseEncodings {
+			if enc.name == name {
+				quality[i] = q
+			}
+		}
+	}
+	for i, q := range quality {
+		if q < 0 {
+			q = wildcard // not listed, covered by the wildcard if present
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	return best
+}
+
+// compressionResponseWriter buffers the response until it reaches the size
+// threshold, then either streams it through the compressor or, if the response
+// ends below the threshold, writes it out uncompressed.
+type compressionResponseWriter struct {
 	http.ResponseWriter
+	encoding  int
+	threshold int
+
+	status  int
+	buf     []byte
+	decided bool
+	cw      compressWriter // nil if the response is sent uncompressed
 }
 
-func (w *gzipResponseWriter) WriteHeader(status int) {
-	w.Header().Del("Content-Length")
-	w.ResponseWriter.WriteHeader(status)
+func (w *compressionResponseWriter) WriteHeader(status int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+	w.status = status
 }
 
-func (w *gzipResponseWriter) Write(b []byte) (int, error) {
-	return w.Writer.Write(b)
+func (w *compressionResponseWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.threshold || len(w.buf) == 0 {
+			return len(b), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.cw != nil {
+		return w.cw.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
 }
 
-func newGzipHandler(next http.Handler) http.Handler {
+// start sends the header and the buffered part of the response.
+func (w *compressionResponseWriter) start(compress bool) error {
+	w.decided = true
+	if compress {
+		enc := responseEncodings[w.encoding]
+		w.Header().Set("Content-Encoding", enc.name)
+		w.Header().Del("Content-Length")
+		w.cw = enc.pool.Get().(compressWriter)
+		w.cw.Reset(w.ResponseWriter)
+	}
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.cw != nil {
+		_, err := w.cw.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// close flushes the remainder of the response.
+func (w *compressionResponseWriter) close() {
+	if !w.decided {
+		w.start(false)
+	}
+	if w.cw != nil {
+		w.cw.Close()
+		responseEncodings[w.encoding].pool.Put(w.cw)
+	}
+}
+
+// newCompressionHandler compresses responses of at least threshold bytes with
+// the content coding negotiated via Accept-Encoding.
+func newCompressionHandler(next http.Handler, threshold int) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding < 0 {
 			next.ServeHTTP(w, r)
 			return
 		}
+		cw := &compressionResponseWriter{ResponseWriter: w, encoding: encoding, threshold: threshold}
+		defer cw.close()
 
-		w.Header().Set("Content-Encoding", "gzip")
-
-		gz := gzPool.Get().(*gzip.Writer)
-		defer gzPool.Put(gz)
-
-		gz.Reset(w)
-		defer gz.Close()
-
-		next.ServeHTTP(&gzipResponseWriter{ResponseWriter: w, Writer: gz}, r)
+		next.ServeHTTP(cw, r)
 	})
 }
 
@@ -505,6 +1089,14 @@ func newIPCServer(log log.Logger, endpoint string) *ipcServer {
 	return &ipcServer{log: log, endpoint: endpoint}
 }
 
+// server returns the RPC server of the endpoint, or nil if it isn't running.
+func (is *ipcServer) server() *rpc.Server {
+	is.mu.Lock()
+	defer is.mu.Unlock()
+
+	return is.srv
+}
+
 // Start starts the httpServer's http.Server
 func (is *ipcServer) start(apis []rpc.API) error {
 	is.mu.Lock()
@@ -543,6 +1135,12 @@ func RegisterApis(apis []rpc.API, modules []string, srv *rpc.Server) error {
 	if bad, available := checkModuleAvailability(modules, apis); len(bad) > 0 {
 		log.Error("Unavailable modules in HTTP API list", "unavailable", bad, "available", available)
 	}
+	return registerAllowedApis(apis, modules, srv)
+}
+
+// registerAllowedApis registers the APIs whose namespace is in the given module
+// list, or all of them if the list is empty.
+func registerAllowedApis(apis []rpc.API, modules []string, srv *rpc.Server) error {
 	// Generate the allow list based on the allowed modules
 	allowList := make(map[string]bool)
 	for _, module := range modules {
@@ -17,10 +17,17 @@
 package node
 
 import (
-	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -60,6 +67,125 @@ func TestVhosts(t *testing.T) {
 	assert.Equal(t, resp2.StatusCode, http.StatusForbidden)
 }
 
+// TestNamespaceRules makes sure per-namespace CORS and vhost rules are enforced.
+func TestNamespaceRules(t *testing.T) {
+	srv := createAndStartServer(t, &httpConfig{
+		CorsAllowedOrigins: []string{"public.com"},
+		Vhosts:             []string{"*"},
+		NamespaceRules: map[string]NamespaceRule{
+			"rpc":   {CorsAllowedOrigins: []string{"internal.com"}},
+			"debug": {VirtualHosts: []string{"internal"}},
+		},
+	}, false, &wsConfig{})
+	defer srv.stop()
+	url := "http://" + srv.listenAddr()
+
+	// Restricted origin for the "rpc" namespace.
+	resp := rpcMethodRequest(t, url, "rpc_modules", "origin", "internal.com")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "internal.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	resp = rpcMethodRequest(t, url, "rpc_modules", "origin", "public.com")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Namespaces without rules use the endpoint settings.
+	resp = rpcMethodRequest(t, url, "eth_chainId", "origin", "public.com")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "public.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	resp = rpcMethodRequest(t, url, "eth_chainId", "origin", "internal.com")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Restricted virtual hosts for the "debug" namespace.
+	resp = rpcMethodRequest(t, url, "debug_foo", "host", "internal")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = rpcMethodRequest(t, url, "debug_foo", "host", "external")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	resp = rpcMethodRequest(t, url, "eth_chainId", "host", "external")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Requests which can't be checked must be refused.
+	for _, body := range []string{
+		`[{"jsonrpc":"2.0","id":1,"method":"debug_foo"},1]`,
+		`{"jsonrpc":"2.0","id":1,"method":"debug_foo"} junk`,
+		`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}{"jsonrpc":"2.0","id":2,"method":"debug_foo"}`,
+	} {
+		req, _ := http.NewRequest("POST", url, strings.NewReader(body))
+		req.Header.Set("content-type", "application/json")
+		req.Host = "external"
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request failed: %v", err)
+		}
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, body)
+	}
+}
+
+// TestUnixSocketListener makes sure the http server can listen on a Unix socket
+// and removes it when stopped.
+func TestUnixSocketListener(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "http.sock")
+	srv := newHTTPServer(testlog.Logger(t, log.LvlDebug), rpc.DefaultHTTPTimeouts)
+	assert.NoError(t, srv.enableRPC(nil, httpConfig{Vhosts: []string{"localhost"}}))
+	assert.NoError(t, srv.setListenAddr("unix://"+path, 0))
+	assert.NoError(t, srv.start())
+	assert.Equal(t, "unix://"+path, srv.listenAddr())
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return new(net.Dialer).DialContext(ctx, "unix", path)
+		},
+	}}
+	resp, err := client.Post("http://localhost/", "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"rpc_modules","params":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	srv.stop()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("socket file not removed: %v", err)
+	}
+}
+
+// TestTLSListener checks that the server serves TLS if configured.
+func TestTLSListener(t *testing.T) {
+	// Borrow the test certificate of httptest, which is valid for 127.0.0.1.
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	certs, client := ts.TLS.Certificates, ts.Client()
+	ts.Close()
+
+	srv := newHTTPServer(testlog.Logger(t, log.LvlDebug), rpc.DefaultHTTPTimeouts)
+	srv.setTLS(&tls.Config{Certificates: certs})
+	assert.NoError(t, srv.enableRPC(nil, httpConfig{Vhosts: []string{"*"}}))
+	assert.NoError(t, srv.setListenAddr("127.0.0.1", 0))
+	assert.NoError(t, srv.start())
+	defer srv.stop()
+
+	url := endpointURL(srv.scheme("http"), srv.listenAddr())
+	if !strings.HasPrefix(url, "https://") {
+		t.Fatalf("wrong endpoint URL %s", url)
+	}
+	resp, err := client.Post(url, "application/json", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"rpc_modules","params":[]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Unencrypted requests fail.
+	if resp, err := http.Post("http://"+srv.listenAddr(), "application/json", strings.NewReader("{}")); err == nil {
+		resp.Body.Close()
+		assert.NotEqual(t, http.StatusOK, resp.StatusCode)
+	}
+}
+
 type originTest struct {
 	spec    string
 	expOk   []string
@@ -176,6 +302,98 @@ func TestIsWebsocket(t *testing.T) {
 	assert.True(t, isWebsocket(r))
 }
 
+// TestNegotiateEncoding checks content coding selection from Accept-Encoding.
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"br, deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"GZIP ; q=0.8", "gzip"},
+		{"gzip;q=0", ""},
+		{"*", "gzip"},
+		{"*, gzip;q=0", "deflate"},
+	}
+	for _, tt := range tests {
+		have := ""
+		if i := negotiateEncoding(tt.header); i >= 0 {
+			have = responseEncodings[i].name
+		}
+		if have != tt.want {
+			t.Errorf("Accept-Encoding %q: have %q, want %q", tt.header, have, tt.want)
+		}
+	}
+}
+
+// TestCompressionThreshold makes sure only responses above the threshold are compressed.
+func TestCompressionThreshold(t *testing.T) {
+	for _, tt := range []struct {
+		threshold int
+		want      string
+	}{
+		{0, "gzip"},
+		{1024, ""},
+	} {
+		srv := createAndStartServer(t, &httpConfig{compression: tt.threshold}, false, &wsConfig{})
+		url := "http://" + srv.listenAddr()
+
+		resp := rpcRequest(t, url, "accept-encoding", "gzip")
+		body := resp.Body
+		if resp.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body = gz
+		}
+		blob, err := io.ReadAll(body)
+		resp.Body.Close()
+		srv.stop()
+
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, resp.Header.Get("Content-Encoding"), "threshold %d", tt.threshold)
+		assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+		assert.Contains(t, string(blob), `"result"`)
+	}
+}
+
+// TestWebsocketConnectionLimits checks that WebSocket upgrades beyond the
+// configured connection limits are rejected.
+func TestWebsocketConnectionLimits(t *testing.T) {
+	srv := createAndStartServer(t, &httpConfig{}, true, &wsConfig{maxConnsPerIP: 1})
+	defer srv.stop()
+	url := "ws://" + srv.listenAddr()
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("first connection failed: %v", err)
+	}
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatal("second connection from the same IP accepted")
+	}
+	if resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("wrong response for rejected connection: %v", resp)
+	}
+	// Closing the first connection frees the slot.
+	conn.Close()
+	for i := 0; ; i++ {
+		err := wsRequest(t, url)
+		if err == nil {
+			break
+		}
+		if i == 50 {
+			t.Fatalf("connection still rejected after the first was closed: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
 func Test_checkPath(t *testing.T) {
 	tests := []struct {
 		req      *http.Request
@@ -268,9 +486,15 @@ func wsRequest(t *testing.T, url string, extraHeaders ...string) error {
 // rpcRequest performs a JSON-RPC request to the given URL.
 func rpcRequest(t *testing.T, url string, extraHeaders ...string) *http.Response {
 	t.Helper()
+	return rpcMethodRequest(t, url, "rpc_modules", extraHeaders...)
+}
+
+// rpcMethodRequest performs a JSON-RPC request calling the given method.
+func rpcMethodRequest(t *testing.T, url string, method string, extraHeaders ...string) *http.Response {
+	t.Helper()
 
 	// Create the request.
-	body := bytes.NewReader([]byte(`{"jsonrpc":"2.0","id":1,"method":"rpc_modules","params":[]}`))
+	body := strings.NewReader(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":%q,"params":[]}`, method))
 	req, err := http.NewRequest("POST", url, body)
 	if err != nil {
 		t.Fatal("could not create http request:", err)
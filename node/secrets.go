@@ -0,0 +1,291 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// secretLoadTimeout is the maximum time spent loading a secret.
+const secretLoadTimeout = 30 * time.Second
+
+// SecretProvider loads secrets, such as the engine API JWT secret or the p2p
+// node key, from a backing store.
+type SecretProvider interface {
+	// Secret returns the secret referenced by the given URL.
+	Secret(ctx context.Context, ref *url.URL) ([]byte, error)
+}
+
+var (
+	secretProvidersLock sync.RWMutex
+	secretProviders     = map[string]SecretProvider{
+		"file":   fileSecrets{},
+		"vault":  new(VaultSecrets),
+		"awskms": new(AWSKMSSecrets),
+		"gcpkms": new(GCPKMSSecrets),
+	}
+)
+
+// RegisterSecretProvider registers a provider for secret references with the
+// given URL scheme, replacing any previously registered one.
+func RegisterSecretProvider(scheme string, provider SecretProvider) {
+	secretProvidersLock.Lock()
+	defer secretProvidersLock.Unlock()
+
+	secretProviders[scheme] = provider
+}
+
+// secretProvider returns the provider for a secret reference, or nil if the
+// reference is a plain file path.
+func secretProvider(ref string) (SecretProvider, *url.URL) {
+	if !strings.Contains(ref, "://") {
+		return nil, nil
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, nil
+	}
+	secretProvidersLock.RLock()
+	defer secretProvidersLock.RUnlock()
+
+	if provider, ok := secretProviders[u.Scheme]; ok {
+		return provider, u
+	}
+	return nil, nil
+}
+
+// IsSecretRef reports whether the given string references a secret of one of
+// the registered providers, as opposed to being a plain file path.
+func IsSecretRef(ref string) bool {
+	provider, _ := secretProvider(ref)
+	return provider != nil
+}
+
+// LoadSecret loads the secret with the given reference. References are URLs
+// whose scheme selects the provider, plain paths are read from disk:
+//
+//	/path/to/secret, file:///path/to/secret
+//	vault://<mount>/<path>[#field]
+//	awskms:///path/to/ciphertext[?region=<region>]
+//	gcpkms://projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>?ciphertext=<path>
+func LoadSecret(ref string) ([]byte, error) {
+	provider, u := secretProvider(ref)
+	if provider == nil {
+		return os.ReadFile(ref)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), secretLoadTimeout)
+	defer cancel()
+
+	secret, err := provider.Secret(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s secret: %w", u.Scheme, err)
+	}
+	return secret, nil
+}
+
+// LoadNodeKey loads a hex-encoded p2p node key with the given reference.
+func LoadNodeKey(ref string) (*ecdsa.PrivateKey, error) {
+	if !IsSecretRef(ref) {
+		return crypto.LoadECDSA(ref)
+	}
+	secret, err := LoadSecret(ref)
+	if err != nil {
+		return nil, err
+	}
+	return crypto.HexToECDSA(strings.TrimSpace(string(secret)))
+}
+
+// fileSecrets reads secrets from file:// URLs.
+type fileSecrets struct{}
+
+func (fileSecrets) Secret(ctx context.Context, ref *url.URL) ([]byte, error) {
+	return os.ReadFile(ref.Path)
+}
+
+// VaultSecrets reads secrets from the KV version 2 secrets engine of HashiCorp
+// Vault. References are of the form vault://<mount>/<path>#<field>, the field
+// defaulting to "value". The server address and token are taken from the
+// VAULT_ADDR and VAULT_TOKEN environment variables, unless set explicitly.
+type VaultSecrets struct {
+	Address   string
+	Token     string
+	Namespace string
+	Client    *http.Client
+}
+
+func (v *VaultSecrets) Secret(ctx context.Context, ref *url.URL) ([]byte, error) {
+	var (
+		address   = firstNonEmpty(v.Address, os.Getenv("VAULT_ADDR"), "http://127.0.0.1:8200")
+		token     = firstNonEmpty(v.Token, os.Getenv("VAULT_TOKEN"))
+		namespace = firstNonEmpty(v.Namespace, os.Getenv("VAULT_NAMESPACE"))
+		field     = firstNonEmpty(ref.Fragment, "value")
+	)
+	if token == "" {
+		return nil, errors.New("no vault token configured")
+	}
+	path := strings.Trim(ref.Path, "/")
+	if ref.Host == "" || path == "" {
+		return nil, fmt.Errorf("invalid vault secret reference %q", ref)
+	}
+	endpoint := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimRight(address, "/"), ref.Host, path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if namespace != "" {
+		req.Header.Set("X-Vault-Namespace", namespace)
+	}
+	var result struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := doSecretRequest(v.Client, req, &result); err != nil {
+		return nil, err
+	}
+	value, ok := result.Data.Data[field].(string)
+	if !ok {
+		return nil, fmt.Errorf("field %q not found in vault secret", field)
+	}
+	return []byte(value), nil
+}
+
+// GCPKMSSecrets decrypts secrets with Google Cloud KMS. References name the
+// crypto key and the file holding the ciphertext, which may be base64 encoded:
+// gcpkms://projects/<p>/locations/<l>/keyRings/<r>/cryptoKeys/<k>?ciphertext=<path>.
+// The access token is taken from the GOOGLE_OAUTH_ACCESS_TOKEN environment
+// variable if set, otherwise from the compute metadata server.
+type GCPKMSSecrets struct {
+	Endpoint string // Defaults to https://cloudkms.googleapis.com
+	Client   *http.Client
+}
+
+const gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+func (g *GCPKMSSecrets) Secret(ctx context.Context, ref *url.URL) ([]byte, error) {
+	name := ref.Host + ref.Path
+	if !strings.HasPrefix(name, "projects/") || !strings.Contains(name, "/cryptoKeys/") {
+		return nil, fmt.Errorf("invalid gcpkms key name %q", name)
+	}
+	ciphertext, err := readCiphertext(ref.Query().Get("ciphertext"))
+	if err != nil {
+		return nil, err
+	}
+	token, err := g.token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := json.Marshal(map[string]string{"ciphertext": base64.StdEncoding.EncodeToString(ciphertext)})
+	endpoint := fmt.Sprintf("%s/v1/%s:decrypt", strings.TrimRight(firstNonEmpty(g.Endpoint, "https://cloudkms.googleapis.com"), "/"), name)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		Plaintext []byte `json:"plaintext"` // base64 in JSON
+	}
+	if err := doSecretRequest(g.Client, req, &result); err != nil {
+		return nil, err
+	}
+	return result.Plaintext, nil
+}
+
+// token returns an OAuth access token for Cloud KMS.
+func (g *GCPKMSSecrets) token(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpMetadataTokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := doSecretRequest(g.Client, req, &result); err != nil {
+		return "", fmt.Errorf("failed to obtain access token: %w", err)
+	}
+	return result.AccessToken, nil
+}
+
+// doSecretRequest executes a request against a secret store and decodes the
+// JSON response into result.
+func doSecretRequest(client *http.Client, req *http.Request, result interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, result)
+}
+
+// readCiphertext reads a ciphertext file, decoding it if base64 encoded.
+func readCiphertext(path string) ([]byte, error) {
+	if path == "" {
+		return nil, errors.New("no ciphertext file given")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data))); err == nil {
+		return decoded, nil
+	}
+	return data, nil
+}
+
+// firstNonEmpty returns the first non-empty string.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// AWSKMSSecrets decrypts secrets with AWS KMS. References name the file holding
+// the ciphertext, which may be base64 encoded as output by the AWS CLI:
+// awskms:///path/to/ciphertext?region=<region>. Credentials and the default
+// region are taken from the standard AWS configuration sources.
+type AWSKMSSecrets struct {
+	Endpoint string // Defaults to https://kms.<region>.amazonaws.com
+	Client   *http.Client
+}
+
+func (a *AWSKMSSecrets) Secret(ctx context.Context, ref *url.URL) ([]byte, error) {
+	ciphertext, err := readCiphertext(ref.Path)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	region := firstNonEmpty(ref.Query().Get("region"), cfg.Region)
+	if region == "" {
+		return nil, errors.New("no AWS region configured")
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := json.Marshal(map[string][]byte{"CiphertextBlob": ciphertext})
+	endpoint := firstNonEmpty(a.Endpoint, fmt.Sprintf("https://kms.%s.amazonaws.com/", region))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Decrypt")
+
+	hash := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), "kms", region, time.Now()); err != nil {
+		return nil, err
+	}
+	var result struct {
+		Plaintext []byte `json:"Plaintext"` // base64 in JSON
+	}
+	if err := doSecretRequest(a.Client, req, &result); err != nil {
+		return nil, err
+	}
+	return result.Plaintext, nil
+}
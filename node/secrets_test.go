@@ -0,0 +1,122 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package node
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestVaultSecrets(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/geth/jwt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"value":"0xabcd","other":"0x1234"}}}`))
+	}))
+	defer srv.Close()
+
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "token")
+
+	tests := []struct {
+		ref, want string
+		fail      bool
+	}{
+		{ref: "vault://secret/geth/jwt", want: "0xabcd"},
+		{ref: "vault://secret/geth/jwt#other", want: "0x1234"},
+		{ref: "vault://secret/geth/jwt#missing", fail: true},
+		{ref: "vault://secret/geth/unknown", fail: true},
+	}
+	for _, test := range tests {
+		secret, err := LoadSecret(test.ref)
+		if test.fail {
+			if err == nil {
+				t.Errorf("%s: expected error", test.ref)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.ref, err)
+		} else if string(secret) != test.want {
+			t.Errorf("%s: secret mismatch: have %q, want %q", test.ref, secret, test.want)
+		}
+	}
+}
+
+func TestGCPKMSSecrets(t *testing.T) {
+	const key = "projects/p/locations/global/keyRings/r/cryptoKeys/k"
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Ciphertext []byte `json:"ciphertext"`
+		}
+		if r.Header.Get("Authorization") != "Bearer token" || r.URL.Path != "/v1/"+key+":decrypt" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(map[string][]byte{"plaintext": append([]byte("plain-"), req.Ciphertext...)})
+	}))
+	defer srv.Close()
+
+	RegisterSecretProvider("testkms", &GCPKMSSecrets{Endpoint: srv.URL})
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "token")
+
+	path := filepath.Join(t.TempDir(), "ciphertext")
+	os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString([]byte("secret"))+"\n"), 0600)
+
+	secret, err := LoadSecret("testkms://" + key + "?ciphertext=" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(secret) != "plain-secret" {
+		t.Fatalf("secret mismatch: have %q, want %q", secret, "plain-secret")
+	}
+}
+
+func TestLoadNodeKey(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	path := filepath.Join(t.TempDir(), "nodekey")
+	if err := crypto.SaveECDSA(path, key); err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []string{path, "file://" + path} {
+		if IsSecretRef(ref) == (ref == path) {
+			t.Errorf("%s: wrong secret reference detection", ref)
+		}
+		loaded, err := LoadNodeKey(ref)
+		if err != nil {
+			t.Fatalf("%s: %v", ref, err)
+		}
+		if !loaded.Equal(key) {
+			t.Errorf("%s: loaded key mismatch", ref)
+		}
+	}
+}
@@ -79,6 +79,7 @@ var (
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNetRestrict      = errors.New("not contained in netrestrict list")
 	errNoPort           = errors.New("node does not provide TCP port")
+	errUnresolved       = errors.New("node endpoint not resolved")
 )
 
 // dialer creates outbound connections and submits them into Server.
@@ -103,6 +104,7 @@ type dialScheduler struct {
 	remStaticCh chan *enode.Node
 	addPeerCh   chan *conn
 	remPeerCh   chan *conn
+	infoCh      chan chan *DialInfo
 
 	// Everything below here belongs to loop and
 	// should only be accessed by code on the loop goroutine.
@@ -122,6 +124,14 @@ type dialScheduler struct {
 	historyTimer     mclock.Timer
 	historyTimerTime mclock.AbsTime
 
+	// Consecutive dial failures per node, used for the redial backoff, and the
+	// addresses of dynamically dialed peers, used for subnet diversity.
+	failures   map[enode.ID]*dialFailures
+	dynPeerIPs map[enode.ID]net.IP
+
+	// Recent dial decisions for introspection.
+	decisions []*DialCandidate
+
 	// for logStats
 	lastStatsLog     mclock.AbsTime
 	doneSinceLastLog int
@@ -139,6 +149,7 @@ type dialConfig struct {
 	log            log.Logger
 	clock          mclock.Clock
 	rand           *mrand.Rand
+	policy         DialPolicy
 }
 
 func (cfg dialConfig) withDefaults() dialConfig {
@@ -157,6 +168,7 @@ func (cfg dialConfig) withDefaults() dialConfig {
 		seed := int64(binary.BigEndian.Uint64(seedb))
 		cfg.rand = mrand.New(mrand.NewSource(seed))
 	}
+	cfg.policy = cfg.policy.withDefaults()
 	return cfg
 }
 
@@ -173,6 +185,9 @@ func newDialScheduler(config dialConfig, it enode.Iterator, setupFunc dialSetupF
 		remStaticCh: make(chan *enode.Node),
 		addPeerCh:   make(chan *conn),
 		remPeerCh:   make(chan *conn),
+		infoCh:      make(chan chan *DialInfo),
+		failures:    make(map[enode.ID]*dialFailures),
+		dynPeerIPs:  make(map[enode.ID]net.IP),
 	}
 	d.lastStatsLog = d.clock.Now()
 	d.ctx, d.cancel = context.WithCancel(context.Background())
@@ -220,6 +235,17 @@ func (d *dialScheduler) peerRemoved(c *conn) {
 	}
 }
 
+// dialInfo returns a snapshot of the dialer state.
+func (d *dialScheduler) dialInfo() *DialInfo {
+	ch := make(chan *DialInfo, 1)
+	select {
+	case d.infoCh <- ch:
+		return <-ch
+	case <-d.ctx.Done():
+		return nil
+	}
+}
+
 // loop is the main loop of the dialer.
 func (d *dialScheduler) loop(it enode.Iterator) {
 	var (
@@ -242,7 +268,12 @@ loop:
 
 		select {
 		case node := <-nodesCh:
-			if err := d.checkDial(node); err != nil {
+			err := d.checkDial(node)
+			if err == nil {
+				err = d.checkSubnet(node)
+			}
+			d.recordDecision(node, false, err)
+			if err != nil {
 				d.log.Trace("Discarding dial candidate", "id", node.ID(), "ip", node.IP(), "reason", err)
 			} else {
 				d.startDial(newDialTask(node, dynDialedConn))
@@ -251,6 +282,7 @@ loop:
 		case task := <-d.doneCh:
 			id := task.dest.ID()
 			delete(d.dialing, id)
+			d.dialFinished(task)
 			d.updateStaticPool(id)
 			d.doneSinceLastLog++
 
@@ -260,6 +292,9 @@ loop:
 			}
 			id := c.node.ID()
 			d.peers[id] = struct{}{}
+			if c.is(dynDialedConn) {
+				d.dynPeerIPs[id] = c.node.IP()
+			}
 			// Remove from static pool because the node is now connected.
 			task := d.static[id]
 			if task != nil && task.staticPoolIndex >= 0 {
@@ -272,6 +307,7 @@ loop:
 				d.dialPeers--
 			}
 			delete(d.peers, c.node.ID())
+			delete(d.dynPeerIPs, c.node.ID())
 			d.updateStaticPool(c.node.ID())
 
 		case node := <-d.addStaticCh:
@@ -301,6 +337,9 @@ loop:
 		case <-historyExp:
 			d.expireHistory()
 
+		case ch := <-d.infoCh:
+			ch <- d.info()
+
 		case <-d.ctx.Done():
 			it.Close()
 			break loop
@@ -371,6 +410,7 @@ func (d *dialScheduler) expireHistory() {
 		copy(id[:], hkey)
 		d.updateStaticPool(id)
 	})
+	d.expireFailures()
 }
 
 // freeDialSlots returns the number of free dial slots. The result can be negative
@@ -415,6 +455,7 @@ func (d *dialScheduler) startStaticDials(n int) (started int) {
 	for started = 0; started < n && len(d.staticPool) > 0; started++ {
 		idx := d.rand.Intn(len(d.staticPool))
 		task := d.staticPool[idx]
+		d.recordDecision(task.dest, true, nil)
 		d.startDial(task)
 		d.removeFromStaticPool(idx)
 	}
@@ -452,8 +493,12 @@ func (d *dialScheduler) removeFromStaticPool(idx int) {
 // startDial runs the given dial task in a separate goroutine.
 func (d *dialScheduler) startDial(task *dialTask) {
 	d.log.Trace("Starting p2p dial", "id", task.dest.ID(), "ip", task.dest.IP(), "flag", task.flags)
+	var failures int
+	if f := d.failures[task.dest.ID()]; f != nil {
+		failures = f.count
+	}
 	hkey := string(task.dest.ID().Bytes())
-	d.history.add(hkey, d.clock.Now().Add(dialHistoryExpiration))
+	d.history.add(hkey, d.clock.Now().Add(d.policy.redialDelay(failures)))
 	d.dialing[task.dest.ID()] = task
 	go func() {
 		task.run(d)
@@ -470,6 +515,7 @@ type dialTask struct {
 	dest         *enode.Node
 	lastResolved mclock.AbsTime
 	resolveDelay time.Duration
+	err          error // connection error of the last dial, if any
 }
 
 func newDialTask(dest *enode.Node, flags connFlag) *dialTask {
@@ -481,7 +527,9 @@ type dialError struct {
 }
 
 func (t *dialTask) run(d *dialScheduler) {
+	t.err = nil
 	if t.needResolve() && !t.resolve(d) {
+		t.err = errUnresolved
 		return
 	}
 
@@ -490,10 +538,13 @@ func (t *dialTask) run(d *dialScheduler) {
 		// For static nodes, resolve one more time if dialing fails.
 		if _, ok := err.(*dialError); ok && t.flags&staticDialedConn != 0 {
 			if t.resolve(d) {
-				t.dial(d, t.dest)
+				err = t.dial(d, t.dest)
 			}
 		}
 	}
+	if _, ok := err.(*dialError); ok {
+		t.err = err
+	}
 }
 
 func (t *dialTask) needResolve() bool {
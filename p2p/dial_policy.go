@@ -0,0 +1,217 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"errors"
+	"math"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+)
+
+const (
+	// dialDecisionLimit is the number of recent dial decisions kept for
+	// introspection.
+	dialDecisionLimit = 256
+
+	// defaultDialSubnetPrefix is the IPv4 prefix length used for subnet
+	// diversity if none is configured.
+	defaultDialSubnetPrefix = 24
+)
+
+var errSubnetLimit = errors.New("too many dialed peers in subnet")
+
+// DialPolicy configures how the dial scheduler picks and retries outbound
+// connections. The zero value keeps the default behavior: a fixed redial delay
+// and no subnet diversity requirement.
+type DialPolicy struct {
+	// RedialDelay is the minimum time between two dials of the same node.
+	// Zero defaults to a value slightly above the inbound throttle time.
+	RedialDelay time.Duration `toml:",omitempty"`
+
+	// RedialBackoff multiplies the redial delay for each consecutive failed
+	// dial of a node. Values below one disable the backoff.
+	RedialBackoff float64 `toml:",omitempty"`
+
+	// MaxRedialDelay caps the redial delay reached through backoff.
+	MaxRedialDelay time.Duration `toml:",omitempty"`
+
+	// SubnetLimit is the maximum number of dynamically dialed peers (connected
+	// or being dialed) sharing a subnet. Zero disables the limit. Static nodes
+	// are exempt.
+	SubnetLimit uint `toml:",omitempty"`
+
+	// SubnetPrefix is the number of leading IP address bits defining a subnet
+	// for SubnetLimit. Zero defaults to 24.
+	SubnetPrefix uint `toml:",omitempty"`
+}
+
+func (p DialPolicy) withDefaults() DialPolicy {
+	if p.RedialDelay == 0 {
+		p.RedialDelay = dialHistoryExpiration
+	}
+	if p.RedialBackoff < 1 {
+		p.RedialBackoff = 1
+	}
+	if p.MaxRedialDelay < p.RedialDelay {
+		p.MaxRedialDelay = p.RedialDelay
+	}
+	if p.SubnetPrefix == 0 {
+		p.SubnetPrefix = defaultDialSubnetPrefix
+	}
+	return p
+}
+
+// redialDelay returns the time to wait before dialing a node again after the
+// given number of consecutive failures.
+func (p DialPolicy) redialDelay(failures int) time.Duration {
+	delay := float64(p.RedialDelay) * math.Pow(p.RedialBackoff, float64(failures))
+	if delay > float64(p.MaxRedialDelay) {
+		return p.MaxRedialDelay
+	}
+	return time.Duration(delay)
+}
+
+// dialFailures tracks consecutive failed dials of a node.
+type dialFailures struct {
+	count int
+	last  mclock.AbsTime
+}
+
+// DialCandidate describes a node considered by the dial scheduler.
+type DialCandidate struct {
+	ID       enode.ID       `json:"id"`
+	IP       net.IP         `json:"ip,omitempty"`
+	Static   bool           `json:"static"`
+	Dialed   bool           `json:"dialed"`           // whether a dial was started
+	Reason   string         `json:"reason,omitempty"` // why the node was skipped
+	Failures int            `json:"failures"`         // consecutive failed dials
+	Time     mclock.AbsTime `json:"time,omitempty"`   // time of the decision on the dialer clock
+}
+
+// DialInfo is a snapshot of the dial scheduler state.
+type DialInfo struct {
+	MaxDialPeers int              `json:"maxDialPeers"`
+	DialPeers    int              `json:"dialPeers"`
+	Policy       DialPolicy       `json:"policy"`
+	Dialing      []*DialCandidate `json:"dialing"` // Dials in progress
+	Static       []*DialCandidate `json:"static"`  // Static nodes not connected
+	Recent       []*DialCandidate `json:"recent"`  // Recent decisions, oldest first
+}
+
+// recordDecision adds a dial decision to the introspection log.
+func (d *dialScheduler) recordDecision(n *enode.Node, static bool, err error) {
+	c := d.candidate(n, static, err)
+	c.Time = d.clock.Now()
+	if len(d.decisions) >= dialDecisionLimit {
+		copy(d.decisions, d.decisions[1:])
+		d.decisions = d.decisions[:len(d.decisions)-1]
+	}
+	d.decisions = append(d.decisions, c)
+}
+
+// candidate creates the introspection entry of a node.
+func (d *dialScheduler) candidate(n *enode.Node, static bool, err error) *DialCandidate {
+	c := &DialCandidate{ID: n.ID(), IP: n.IP(), Static: static, Dialed: err == nil}
+	if err != nil {
+		c.Reason = err.Error()
+	}
+	if f := d.failures[n.ID()]; f != nil {
+		c.Failures = f.count
+	}
+	return c
+}
+
+// info creates a snapshot of the scheduler state. It must be called on the
+// loop goroutine.
+func (d *dialScheduler) info() *DialInfo {
+	info := &DialInfo{
+		MaxDialPeers: d.maxDialPeers,
+		DialPeers:    d.dialPeers,
+		Policy:       d.policy,
+		Dialing:      make([]*DialCandidate, 0, len(d.dialing)),
+		Recent:       make([]*DialCandidate, len(d.decisions)),
+	}
+	for _, task := range d.dialing {
+		info.Dialing = append(info.Dialing, d.candidate(task.dest, task.flags&staticDialedConn != 0, nil))
+	}
+	for id, task := range d.static {
+		if _, ok := d.peers[id]; ok {
+			continue
+		}
+		err := d.checkDial(task.dest)
+		if err == errAlreadyDialing {
+			continue
+		}
+		info.Static = append(info.Static, d.candidate(task.dest, true, err))
+	}
+	for i, c := range d.decisions {
+		cpy := *c
+		info.Recent[i] = &cpy
+	}
+	return info
+}
+
+// checkSubnet returns an error if dialing n would exceed the subnet limit.
+func (d *dialScheduler) checkSubnet(n *enode.Node) error {
+	if d.policy.SubnetLimit == 0 || n.IP() == nil {
+		return nil
+	}
+	set := netutil.DistinctNetSet{Subnet: d.policy.SubnetPrefix, Limit: d.policy.SubnetLimit}
+	for _, task := range d.dialing {
+		if task.flags&dynDialedConn != 0 && task.dest.IP() != nil {
+			set.Add(task.dest.IP())
+		}
+	}
+	for _, ip := range d.dynPeerIPs {
+		set.Add(ip)
+	}
+	if !set.Add(n.IP()) {
+		return errSubnetLimit
+	}
+	return nil
+}
+
+// dialFinished updates the failure record of a node after a dial attempt.
+func (d *dialScheduler) dialFinished(task *dialTask) {
+	id := task.dest.ID()
+	if task.err == nil {
+		delete(d.failures, id)
+		return
+	}
+	f := d.failures[id]
+	if f == nil {
+		f = new(dialFailures)
+		d.failures[id] = f
+	}
+	f.count++
+	f.last = d.clock.Now()
+}
+
+// expireFailures drops failure records which no longer affect the redial delay.
+func (d *dialScheduler) expireFailures() {
+	now := d.clock.Now()
+	for id, f := range d.failures {
+		if time.Duration(now-f.last) > 2*d.policy.MaxRedialDelay {
+			delete(d.failures, id)
+		}
+	}
+}
@@ -394,6 +394,102 @@ func TestDialSchedResolve(t *testing.T) {
 	})
 }
 
+// This test checks that a static node whose endpoint can't be resolved counts
+// as a failed dial.
+func TestDialSchedResolveFailure(t *testing.T) {
+	t.Parallel()
+
+	config := dialConfig{
+		maxActiveDials: 1,
+		maxDialPeers:   1,
+	}
+	node := newNode(uintID(0x01), "")
+	runDialTest(t, config, []dialTestRound{
+		{
+			update: func(d *dialScheduler) {
+				d.addStatic(node)
+			},
+			wantResolves: map[enode.ID]*enode.Node{
+				uintID(0x01): nil,
+			},
+		},
+		{
+			update: func(d *dialScheduler) {
+				// The dial task finishes asynchronously, wait for it
+				for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+					if info := d.dialInfo(); len(info.Static) == 1 && info.Static[0].Failures == 1 {
+						return
+					}
+				}
+				t.Errorf("failed resolve not recorded: %+v", d.dialInfo().Static)
+			},
+		},
+	})
+}
+
+// This test checks that the subnet limit of the dial policy restricts dynamic dials.
+func TestDialSchedSubnetLimit(t *testing.T) {
+	t.Parallel()
+
+	config := dialConfig{
+		maxActiveDials: 10,
+		maxDialPeers:   10,
+		policy:         DialPolicy{SubnetLimit: 2},
+	}
+	runDialTest(t, config, []dialTestRound{
+		{
+			peersAdded: []*conn{
+				{flags: dynDialedConn, node: newNode(uintID(0x01), "127.0.1.1:30303")},
+			},
+			discovered: []*enode.Node{
+				newNode(uintID(0x02), "127.0.1.2:30303"),
+				newNode(uintID(0x03), "127.0.1.3:30303"), // skipped, subnet full
+				newNode(uintID(0x04), "127.0.2.4:30303"),
+			},
+			wantNewDials: []*enode.Node{
+				newNode(uintID(0x02), "127.0.1.2:30303"),
+				newNode(uintID(0x04), "127.0.2.4:30303"),
+			},
+		},
+		{
+			update: func(d *dialScheduler) {
+				info := d.dialInfo()
+				if len(info.Dialing) != 2 {
+					t.Errorf("wrong number of dials in progress: %d", len(info.Dialing))
+				}
+				for _, c := range info.Recent {
+					if c.ID == uintID(0x03) && c.Reason != errSubnetLimit.Error() {
+						t.Errorf("wrong skip reason for 0x03: %q", c.Reason)
+					}
+					if c.Time > d.clock.Now() {
+						t.Errorf("decision time %v ahead of the dialer clock %v", c.Time, d.clock.Now())
+					}
+				}
+			},
+			succeeded: []enode.ID{
+				uintID(0x02),
+			},
+		},
+	})
+}
+
+func TestDialPolicyRedialDelay(t *testing.T) {
+	policy := DialPolicy{
+		RedialDelay:    time.Second,
+		RedialBackoff:  2,
+		MaxRedialDelay: 10 * time.Second,
+	}.withDefaults()
+
+	for failures, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second} {
+		if have := policy.redialDelay(failures); have != want {
+			t.Errorf("failures %d: delay mismatch: have %v, want %v", failures, have, want)
+		}
+	}
+	if have := (DialPolicy{}).withDefaults().redialDelay(5); have != dialHistoryExpiration {
+		t.Errorf("default policy delay mismatch: have %v, want %v", have, dialHistoryExpiration)
+	}
+}
+
 // -------
 // Code below here is the framework for the tests above.
 
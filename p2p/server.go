@@ -152,6 +152,10 @@ type Config struct {
 	// If NoDial is true, the server will not dial any peers.
 	NoDial bool `toml:",omitempty"`
 
+	// DialPolicy configures the redial backoff and subnet diversity of
+	// outbound connections.
+	DialPolicy DialPolicy `toml:",omitempty"`
+
 	// If EnableMsgEvents is set then the server will emit PeerEvents
 	// whenever a message is sent to or received from a peer
 	EnableMsgEvents bool
@@ -635,6 +639,7 @@ func (srv *Server) setupDialScheduler() {
 		netRestrict:    srv.NetRestrict,
 		dialer:         srv.Dialer,
 		clock:          srv.clock,
+		policy:         srv.DialPolicy,
 	}
 	if srv.ntab != nil {
 		config.resolver = srv.ntab
@@ -1111,6 +1116,23 @@ func (srv *Server) NodeInfo() *NodeInfo {
 	return info
 }
 
+// DialInfo returns the state of the dial scheduler, including the current dial
+// candidates and why they were dialed or skipped. It returns nil if the server
+// is not running.
+func (srv *Server) DialInfo() *DialInfo {
+	srv.lock.Lock()
+	dialsched := srv.dialsched
+	if !srv.running {
+		dialsched = nil
+	}
+	srv.lock.Unlock()
+
+	if dialsched == nil {
+		return nil
+	}
+	return dialsched.dialInfo()
+}
+
 // PeersInfo returns an array of metadata objects describing connected peers.
 func (srv *Server) PeersInfo() []*PeerInfo {
 	// Gather all the generic and sub-protocol specific infos
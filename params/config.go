@@ -261,16 +261,16 @@ var (
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil, nil}
+	AllEthashProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil, nil, nil, nil, nil, nil, nil}
 
 	// AllCliqueProtocolChanges contains every protocol change (EIPs) introduced
 	// and accepted by the Ethereum core developers into the Clique consensus.
 	//
 	// This configuration is intentionally not using keyed fields to force anyone
 	// adding flags to the config to also have to set these fields.
-	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil}
+	AllCliqueProtocolChanges = &ChainConfig{big.NewInt(1337), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, nil, nil, nil, &CliqueConfig{Period: 0, Epoch: 30000}, nil, nil, nil, nil, nil, nil}
 
-	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil, nil}
+	TestChainConfig = &ChainConfig{big.NewInt(1), big.NewInt(0), nil, false, big.NewInt(0), common.Hash{}, big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), big.NewInt(0), nil, nil, new(EthashConfig), nil, nil, nil, nil, nil, nil, nil}
 	TestRules       = TestChainConfig.Rules(new(big.Int), false)
 )
 
@@ -372,6 +372,21 @@ type ChainConfig struct {
 
 	// Optimism config, nil if not active
 	Optimism *OptimismConfig `json:"optimism,omitempty"`
+
+	// FeeCurrency config, nil if fees can only be paid in ether
+	FeeCurrency *FeeCurrencyConfig `json:"feeCurrency,omitempty"`
+
+	// TxLimits config, nil if transactions are only bounded by the local limits
+	TxLimits *TxLimitsConfig `json:"txLimits,omitempty"`
+
+	// BlobTx config, nil if blob transactions are not accepted
+	BlobTx *BlobTxConfig `json:"blobTx,omitempty"`
+
+	// SetCodeTx config, nil if set code transactions are not accepted
+	SetCodeTx *SetCodeTxConfig `json:"setCodeTx,omitempty"`
+
+	// Verkle config, nil if the state is kept in the merkle patricia tries
+	Verkle *VerkleConfig `json:"verkle,omitempty"`
 }
 
 // EthashConfig is the consensus engine configs for proof-of-work based sealing.
@@ -404,6 +419,60 @@ func (o *OptimismConfig) String() string {
 	return "optimism"
 }
 
+// FeeCurrencyConfig selects an alternative fee payment of the network, e.g. fees
+// paid in an ERC-20 token through a designated contract. The named hook must be
+// registered with the core package by the client of the network.
+type FeeCurrencyConfig struct {
+	Name     string         `json:"name"`               // Name of the registered fee currency hook
+	Contract common.Address `json:"contract,omitempty"` // Designated contract of the hook, if any
+	Block    *big.Int       `json:"block,omitempty"`    // Activation block (nil = never)
+}
+
+// TxLimitsConfig bounds the size of transactions, e.g. to cap the worst-case data
+// availability cost of the batches of a rollup. The limits are enforced at pool
+// admission, block building and block import. Zero limits are not enforced.
+type TxLimitsConfig struct {
+	MaxTxSize   uint64   `json:"maxTxSize,omitempty"`   // Maximum encoded size of a transaction
+	MaxCalldata uint64   `json:"maxCalldata,omitempty"` // Maximum input data of a transaction
+	MaxInitCode uint64   `json:"maxInitCode,omitempty"` // Maximum input data of a contract creation
+	Block       *big.Int `json:"block,omitempty"`       // Activation block (nil = never)
+}
+
+// BlobTxConfig enables blob transactions (EIP-4844). The chain doesn't carry the
+// blobs, but prices blob gas by the excess blob gas tracked in the headers.
+type BlobTxConfig struct {
+	MaxBlobGasPerBlock uint64   `json:"maxBlobGasPerBlock,omitempty"` // Blob gas limit of a block (0 = BlobTxMaxBlobGasPerBlock)
+	MinBlobGasPrice    *big.Int `json:"minBlobGasPrice,omitempty"`    // Minimum price of blob gas, if above BlobTxMinBlobGasprice
+	Block              *big.Int `json:"block,omitempty"`              // Activation block (nil = never)
+}
+
+// MaxBlobGas returns the blob gas limit of a block.
+func (c *BlobTxConfig) MaxBlobGas() uint64 {
+	if c.MaxBlobGasPerBlock == 0 {
+		return BlobTxMaxBlobGasPerBlock
+	}
+	return c.MaxBlobGasPerBlock
+}
+
+// TargetBlobGas returns the blob gas a block targets, half of its limit.
+func (c *BlobTxConfig) TargetBlobGas() uint64 {
+	return c.MaxBlobGas() / 2
+}
+
+// SetCodeTxConfig enables set code transactions (EIP-7702), which let accounts
+// delegate the execution of calls to them to the code of a contract.
+type SetCodeTxConfig struct {
+	Block *big.Int `json:"block,omitempty"` // Activation block (nil = never)
+}
+
+// VerkleConfig enables the experimental verkle tree state (EIP-6800). The state
+// is converted into a verkle tree once, at the start of the activation block,
+// which can't be the genesis. The conversion needs the preimages of the trie
+// keys, and the verkle state is neither snapshotted nor stored by path.
+type VerkleConfig struct {
+	Block *big.Int `json:"block,omitempty"` // Conversion block (nil = never)
+}
+
 // String implements the fmt.Stringer interface.
 func (c *ChainConfig) String() string {
 	var banner string
@@ -547,6 +616,36 @@ func (c *ChainConfig) IsGrayGlacier(num *big.Int) bool {
 	return isForked(c.GrayGlacierBlock, num)
 }
 
+// IsFeeCurrency returns whether num is either equal to the fee currency activation
+// block or greater.
+func (c *ChainConfig) IsFeeCurrency(num *big.Int) bool {
+	return c.FeeCurrency != nil && isForked(c.FeeCurrency.Block, num)
+}
+
+// IsTxLimits returns whether num is either equal to the transaction limits
+// activation block or greater.
+func (c *ChainConfig) IsTxLimits(num *big.Int) bool {
+	return c.TxLimits != nil && isForked(c.TxLimits.Block, num)
+}
+
+// IsBlobTx returns whether num is either equal to the blob transaction activation
+// block or greater.
+func (c *ChainConfig) IsBlobTx(num *big.Int) bool {
+	return c.BlobTx != nil && isForked(c.BlobTx.Block, num)
+}
+
+// IsSetCodeTx returns whether num is either equal to the set code transaction
+// activation block or greater.
+func (c *ChainConfig) IsSetCodeTx(num *big.Int) bool {
+	return c.SetCodeTx != nil && isForked(c.SetCodeTx.Block, num)
+}
+
+// IsVerkle returns whether num is either equal to the verkle conversion block
+// or greater.
+func (c *ChainConfig) IsVerkle(num *big.Int) bool {
+	return c.Verkle != nil && isForked(c.Verkle.Block, num)
+}
+
 // IsTerminalPoWBlock returns whether the given block is the last block of PoW stage.
 func (c *ChainConfig) IsTerminalPoWBlock(parentTotalDiff *big.Int, totalDiff *big.Int) bool {
 	if c.TerminalTotalDifficulty == nil {
@@ -576,50 +675,64 @@ func (c *ChainConfig) CheckCompatible(newcfg *ChainConfig, height uint64) *Confi
 // CheckConfigForkOrder checks that we don't "skip" any forks, geth isn't pluggable enough
 // to guarantee that forks can be implemented in a different order than on official networks
 func (c *ChainConfig) CheckConfigForkOrder() error {
-	type fork struct {
-		name     string
-		block    *big.Int
-		optional bool // if true, the fork may be nil and next fork is still allowed
-	}
-	var lastFork fork
-	for _, cur := range []fork{
-		{name: "homesteadBlock", block: c.HomesteadBlock},
-		{name: "daoForkBlock", block: c.DAOForkBlock, optional: true},
-		{name: "eip150Block", block: c.EIP150Block},
-		{name: "eip155Block", block: c.EIP155Block},
-		{name: "eip158Block", block: c.EIP158Block},
-		{name: "byzantiumBlock", block: c.ByzantiumBlock},
-		{name: "constantinopleBlock", block: c.ConstantinopleBlock},
-		{name: "petersburgBlock", block: c.PetersburgBlock},
-		{name: "istanbulBlock", block: c.IstanbulBlock},
-		{name: "muirGlacierBlock", block: c.MuirGlacierBlock, optional: true},
-		{name: "berlinBlock", block: c.BerlinBlock},
-		{name: "londonBlock", block: c.LondonBlock},
-		{name: "arrowGlacierBlock", block: c.ArrowGlacierBlock, optional: true},
-		{name: "grayGlacierBlock", block: c.GrayGlacierBlock, optional: true},
-		{name: "mergeNetsplitBlock", block: c.MergeNetsplitBlock, optional: true},
-	} {
-		if lastFork.name != "" {
+	var lastFork Fork
+	for _, cur := range c.Forks() {
+		if lastFork.Name != "" {
 			// Next one must be higher number
-			if lastFork.block == nil && cur.block != nil {
-				return fmt.Errorf("unsupported fork ordering: %v not enabled, but %v enabled at %v",
-					lastFork.name, cur.name, cur.block)
+			if lastFork.Block == nil && cur.Block != nil {
+				return fmt.Errorf("unsupported fork ordering: %vBlock not enabled, but %vBlock enabled at %v",
+					lastFork.Name, cur.Name, cur.Block)
 			}
-			if lastFork.block != nil && cur.block != nil {
-				if lastFork.block.Cmp(cur.block) > 0 {
-					return fmt.Errorf("unsupported fork ordering: %v enabled at %v, but %v enabled at %v",
-						lastFork.name, lastFork.block, cur.name, cur.block)
+			if lastFork.Block != nil && cur.Block != nil {
+				if lastFork.Block.Cmp(cur.Block) > 0 {
+					return fmt.Errorf("unsupported fork ordering: %vBlock enabled at %v, but %vBlock enabled at %v",
+						lastFork.Name, lastFork.Block, cur.Name, cur.Block)
 				}
 			}
 		}
-		// If it was optional and not set, then ignore it
-		if !cur.optional || cur.block != nil {
+		// If it was optional and not set, or the next forks don't depend on it,
+		// then ignore it
+		if (!cur.Optional || cur.Block != nil) && !cur.Independent {
 			lastFork = cur
 		}
 	}
 	return nil
 }
 
+// Fork is a block number activated fork of the chain configuration.
+type Fork struct {
+	Name        string
+	Block       *big.Int // nil if the fork is not scheduled
+	Optional    bool     // if true, the fork may be nil and next fork is still allowed
+	Independent bool     // if true, the fork is not ordered against the next forks
+}
+
+// Forks returns the block number activated forks in activation order.
+func (c *ChainConfig) Forks() []Fork {
+	return []Fork{
+		{Name: "homestead", Block: c.HomesteadBlock},
+		{Name: "daoFork", Block: c.DAOForkBlock, Optional: true},
+		{Name: "eip150", Block: c.EIP150Block},
+		{Name: "eip155", Block: c.EIP155Block},
+		{Name: "eip158", Block: c.EIP158Block},
+		{Name: "byzantium", Block: c.ByzantiumBlock},
+		{Name: "constantinople", Block: c.ConstantinopleBlock},
+		{Name: "petersburg", Block: c.PetersburgBlock},
+		{Name: "istanbul", Block: c.IstanbulBlock},
+		{Name: "muirGlacier", Block: c.MuirGlacierBlock, Optional: true},
+		{Name: "berlin", Block: c.BerlinBlock},
+		{Name: "london", Block: c.LondonBlock},
+		{Name: "arrowGlacier", Block: c.ArrowGlacierBlock, Optional: true},
+		{Name: "grayGlacier", Block: c.GrayGlacierBlock, Optional: true},
+		{Name: "mergeNetsplit", Block: c.MergeNetsplitBlock, Optional: true},
+		{Name: "txLimits", Block: c.txLimitsBlock(), Optional: true, Independent: true},
+		{Name: "blobTx", Block: c.blobTxBlock(), Optional: true, Independent: true},
+		{Name: "setCodeTx", Block: c.setCodeTxBlock(), Optional: true, Independent: true},
+		{Name: "feeCurrency", Block: c.feeCurrencyBlock(), Optional: true, Independent: true},
+		{Name: "verkle", Block: c.verkleBlock(), Optional: true, Independent: true},
+	}
+}
+
 func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *ConfigCompatError {
 	if isForkIncompatible(c.HomesteadBlock, newcfg.HomesteadBlock, head) {
 		return newCompatError("Homestead fork block", c.HomesteadBlock, newcfg.HomesteadBlock)
@@ -676,9 +789,69 @@ func (c *ChainConfig) checkCompatible(newcfg *ChainConfig, head *big.Int) *Confi
 	if isForkIncompatible(c.MergeNetsplitBlock, newcfg.MergeNetsplitBlock, head) {
 		return newCompatError("Merge netsplit fork block", c.MergeNetsplitBlock, newcfg.MergeNetsplitBlock)
 	}
+	if isForkIncompatible(c.txLimitsBlock(), newcfg.txLimitsBlock(), head) {
+		return newCompatError("Transaction limits fork block", c.txLimitsBlock(), newcfg.txLimitsBlock())
+	}
+	if isForkIncompatible(c.blobTxBlock(), newcfg.blobTxBlock(), head) {
+		return newCompatError("Blob transaction fork block", c.blobTxBlock(), newcfg.blobTxBlock())
+	}
+	if isForkIncompatible(c.setCodeTxBlock(), newcfg.setCodeTxBlock(), head) {
+		return newCompatError("Set code transaction fork block", c.setCodeTxBlock(), newcfg.setCodeTxBlock())
+	}
+	if isForkIncompatible(c.feeCurrencyBlock(), newcfg.feeCurrencyBlock(), head) {
+		return newCompatError("Fee currency fork block", c.feeCurrencyBlock(), newcfg.feeCurrencyBlock())
+	}
+	if isForkIncompatible(c.verkleBlock(), newcfg.verkleBlock(), head) {
+		return newCompatError("Verkle fork block", c.verkleBlock(), newcfg.verkleBlock())
+	}
 	return nil
 }
 
+// txLimitsBlock returns the transaction limits activation block, nil if the
+// limits are not configured.
+func (c *ChainConfig) txLimitsBlock() *big.Int {
+	if c.TxLimits == nil {
+		return nil
+	}
+	return c.TxLimits.Block
+}
+
+// feeCurrencyBlock returns the fee currency activation block, nil if fees can
+// only be paid in ether.
+func (c *ChainConfig) feeCurrencyBlock() *big.Int {
+	if c.FeeCurrency == nil {
+		return nil
+	}
+	return c.FeeCurrency.Block
+}
+
+// setCodeTxBlock returns the set code transaction activation block, nil if the
+// transactions are not accepted.
+func (c *ChainConfig) setCodeTxBlock() *big.Int {
+	if c.SetCodeTx == nil {
+		return nil
+	}
+	return c.SetCodeTx.Block
+}
+
+// blobTxBlock returns the blob transaction activation block, nil if the
+// transactions are not accepted.
+func (c *ChainConfig) blobTxBlock() *big.Int {
+	if c.BlobTx == nil {
+		return nil
+	}
+	return c.BlobTx.Block
+}
+
+// verkleBlock returns the verkle conversion block, nil if the state is kept in
+// the merkle patricia tries.
+func (c *ChainConfig) verkleBlock() *big.Int {
+	if c.Verkle == nil {
+		return nil
+	}
+	return c.Verkle.Block
+}
+
 // isForkIncompatible returns true if a fork scheduled at s1 cannot be rescheduled to
 // block s2 because head is already past the fork.
 func isForkIncompatible(s1, s2, head *big.Int) bool {
@@ -745,6 +918,7 @@ type Rules struct {
 	IsByzantium, IsConstantinople, IsPetersburg, IsIstanbul bool
 	IsBerlin, IsLondon                                      bool
 	IsMerge                                                 bool
+	IsSetCodeTx                                             bool
 }
 
 // Rules ensures c's ChainID is not nil.
@@ -766,5 +940,6 @@ func (c *ChainConfig) Rules(num *big.Int, isMerge bool) Rules {
 		IsBerlin:         c.IsBerlin(num),
 		IsLondon:         c.IsLondon(num),
 		IsMerge:          isMerge,
+		IsSetCodeTx:      c.IsSetCodeTx(num),
 	}
 }
@@ -87,6 +87,67 @@ func TestCheckCompatible(t *testing.T) {
 				RewindTo:     30,
 			},
 		},
+		{
+			stored:  &ChainConfig{TxLimits: &TxLimitsConfig{Block: big.NewInt(10)}},
+			new:     &ChainConfig{TxLimits: &TxLimitsConfig{Block: big.NewInt(20)}},
+			head:    9,
+			wantErr: nil,
+		},
+		{
+			stored: &ChainConfig{TxLimits: &TxLimitsConfig{Block: big.NewInt(10)}},
+			new:    &ChainConfig{},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "Transaction limits fork block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    nil,
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{BlobTx: &BlobTxConfig{Block: big.NewInt(10)}},
+			new:    &ChainConfig{BlobTx: &BlobTxConfig{Block: big.NewInt(20)}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "Blob transaction fork block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(20),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{},
+			new:    &ChainConfig{SetCodeTx: &SetCodeTxConfig{Block: big.NewInt(10)}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "Set code transaction fork block",
+				StoredConfig: nil,
+				NewConfig:    big.NewInt(10),
+				RewindTo:     9,
+			},
+		},
+		{
+			stored: &ChainConfig{FeeCurrency: &FeeCurrencyConfig{Name: "token", Block: big.NewInt(10)}},
+			new:    &ChainConfig{FeeCurrency: &FeeCurrencyConfig{Name: "token", Block: big.NewInt(5)}},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "Fee currency fork block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    big.NewInt(5),
+				RewindTo:     4,
+			},
+		},
+		{
+			stored: &ChainConfig{Verkle: &VerkleConfig{Block: big.NewInt(10)}},
+			new:    &ChainConfig{},
+			head:   15,
+			wantErr: &ConfigCompatError{
+				What:         "Verkle fork block",
+				StoredConfig: big.NewInt(10),
+				NewConfig:    nil,
+				RewindTo:     9,
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -96,3 +157,41 @@ func TestCheckCompatible(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckConfigForkOrder(t *testing.T) {
+	// The rollup forks follow the mainline forks, but not each other
+	rollup := *AllEthashProtocolChanges
+	rollup.TxLimits = &TxLimitsConfig{Block: big.NewInt(20)}
+	rollup.BlobTx = &BlobTxConfig{Block: big.NewInt(10)}
+	rollup.SetCodeTx = &SetCodeTxConfig{Block: big.NewInt(0)}
+	rollup.FeeCurrency = &FeeCurrencyConfig{Block: big.NewInt(5)}
+
+	early := rollup
+	early.LondonBlock = big.NewInt(8)
+	early.ArrowGlacierBlock, early.GrayGlacierBlock, early.MergeNetsplitBlock = nil, nil, nil
+
+	tests := []struct {
+		config *ChainConfig
+		ok     bool
+	}{
+		{AllEthashProtocolChanges, true},
+		// Mainline forks can't be skipped or reordered
+		{&ChainConfig{HomesteadBlock: big.NewInt(0), EIP150Block: nil, EIP155Block: big.NewInt(0)}, false},
+		{&ChainConfig{HomesteadBlock: big.NewInt(10), EIP150Block: big.NewInt(5)}, false},
+		// Optional forks may be left out or scheduled between their neighbours
+		{&ChainConfig{HomesteadBlock: big.NewInt(0), DAOForkBlock: nil, EIP150Block: big.NewInt(0)}, true},
+		{&ChainConfig{HomesteadBlock: big.NewInt(0), DAOForkBlock: big.NewInt(5), EIP150Block: big.NewInt(10)}, true},
+		{&ChainConfig{HomesteadBlock: big.NewInt(0), DAOForkBlock: big.NewInt(10), EIP150Block: big.NewInt(5)}, false},
+		{&rollup, true},
+		{&early, false},
+	}
+	for i, tt := range tests {
+		err := tt.config.CheckConfigForkOrder()
+		if tt.ok && err != nil {
+			t.Errorf("test %d: valid fork order rejected: %v", i, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("test %d: invalid fork order accepted", i)
+		}
+	}
+}
@@ -89,6 +89,8 @@ const (
 	TxAccessListAddressGas    uint64 = 2400 // Per address specified in EIP 2930 access list
 	TxAccessListStorageKeyGas uint64 = 1900 // Per storage key specified in EIP 2930 access list
 
+	TxAuthTupleGas uint64 = 12500 // Per EIP 7702 authorization of an existing account, CallNewAccountGas otherwise
+
 	// These have been changed during the course of the chain
 	CallGasFrontier              uint64 = 40  // Once per CALL operation & message call transaction.
 	CallGasEIP150                uint64 = 700 // Static portion of gas for CALL-derivates after EIP 150 (Tangerine)
@@ -125,6 +127,12 @@ const (
 
 	MaxCodeSize = 24576 // Maximum bytecode to permit for a contract
 
+	BlobTxBlobGasPerBlob             = 1 << 17                  // Gas consumption of a single data blob (== blob byte size)
+	BlobTxMinBlobGasprice            = 1                        // Minimum gas price for data blobs
+	BlobTxBlobGaspriceUpdateFraction = 3338477                  // Controls the maximum rate of change for blob gas price
+	BlobTxHashVersion                = 0x01                     // Version byte of the commitment hash
+	BlobTxMaxBlobGasPerBlock         = 6 * BlobTxBlobGasPerBlob // Maximum consumable blob gas for data blobs per block
+
 	// Precompiled contract gas prices
 
 	EcrecoverGas        uint64 = 3000 // Elliptic curve sender recovery gas price
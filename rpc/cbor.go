@@ -0,0 +1,180 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// cborContentType is the media type HTTP clients accept to receive CBOR
+	// encoded responses.
+	cborContentType = "application/cbor"
+
+	// cborSubprotocol is the WebSocket subprotocol clients request to receive
+	// CBOR encoded messages.
+	cborSubprotocol = "jsonrpc-cbor"
+)
+
+// CBOR major types.
+const (
+	cborUint   = 0
+	cborNegInt = 1
+	cborBytes  = 2
+	cborText   = 3
+	cborTag    = 6
+)
+
+// cborBase16Tag marks byte strings which are expected to be converted to hex
+// when translated back to JSON (RFC 8949, section 3.4.5.2).
+const cborBase16Tag = 23
+
+// acceptsCBOR reports whether an HTTP Accept header lists the CBOR media type.
+func acceptsCBOR(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		if mt, _, err := mime.ParseMediaType(part); err == nil && mt == cborContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonToCBOR transcodes JSON-RPC messages to CBOR. Arrays and objects become
+// indefinite-length arrays and maps. String values holding lower-case hex with
+// an even number of digits and a "0x" prefix are encoded as byte strings tagged
+// for base16 conversion, which halves the size of hashes, addresses and data.
+func jsonToCBOR(data []byte) ([]byte, error) {
+	type container struct {
+		object bool
+		items  int // number of keys and values read so far
+	}
+	var (
+		dec   = json.NewDecoder(bytes.NewReader(data))
+		out   = make([]byte, 0, len(data)/2)
+		stack []container
+	)
+	dec.UseNumber()
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF && len(stack) == 0 {
+			return out, nil
+		}
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		var isKey bool
+		if delim, ok := tok.(json.Delim); !ok || delim == '[' || delim == '{' {
+			if len(stack) > 0 {
+				top := &stack[len(stack)-1]
+				isKey = top.object && top.items%2 == 0
+				top.items++
+			}
+		}
+		switch v := tok.(type) {
+		case json.Delim:
+			switch v {
+			case '[':
+				out = append(out, 0x9f)
+				stack = append(stack, container{})
+			case '{':
+				out = append(out, 0xbf)
+				stack = append(stack, container{object: true})
+			default:
+				out = append(out, 0xff)
+				stack = stack[:len(stack)-1]
+			}
+		case bool:
+			if v {
+				out = append(out, 0xf5)
+			} else {
+				out = append(out, 0xf4)
+			}
+		case nil:
+			out = append(out, 0xf6)
+		case json.Number:
+			out = appendCBORNumber(out, v)
+		case string:
+			if raw, ok := hexBytes(v); ok && !isKey {
+				out = appendCBORHead(out, cborTag, cborBase16Tag)
+				out = appendCBORHead(out, cborBytes, uint64(len(raw)))
+				out = append(out, raw...)
+			} else {
+				out = appendCBORHead(out, cborText, uint64(len(v)))
+				out = append(out, v...)
+			}
+		}
+	}
+}
+
+// hexBytes decodes s if it is "0x" prefixed lower-case hex of even length.
+func hexBytes(s string) ([]byte, bool) {
+	if len(s) < 2 || s[:2] != "0x" || len(s)%2 != 0 {
+		return nil, false
+	}
+	for _, c := range s[2:] {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return nil, false
+		}
+	}
+	raw, err := hex.DecodeString(s[2:])
+	return raw, err == nil
+}
+
+// appendCBORNumber encodes integers as such and all other numbers as doubles.
+func appendCBORNumber(out []byte, n json.Number) []byte {
+	if i, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return appendCBORHead(out, cborUint, i)
+	}
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return appendCBORHead(out, cborNegInt, uint64(-(i + 1)))
+	}
+	f, _ := n.Float64()
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], math.Float64bits(f))
+	return append(append(out, 0xfb), buf[:]...)
+}
+
+// appendCBORHead encodes the initial bytes of a data item.
+func appendCBORHead(out []byte, major byte, n uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], n)
+
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(out, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(out, major|24, byte(n))
+	case n <= math.MaxUint16:
+		return append(append(out, major|25), buf[6:]...)
+	case n <= math.MaxUint32:
+		return append(append(out, major|26), buf[4:]...)
+	default:
+		return append(append(out, major|27), buf[:]...)
+	}
+}
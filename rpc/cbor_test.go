@@ -0,0 +1,161 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestJSONToCBOR(t *testing.T) {
+	tests := []struct {
+		json string
+		cbor string
+	}{
+		{`0`, "00"},
+		{`23`, "17"},
+		{`24`, "1818"},
+		{`1000`, "1903e8"},
+		{`1000000`, "1a000f4240"},
+		{`18446744073709551615`, "1bffffffffffffffff"},
+		{`-1`, "20"},
+		{`-1000`, "3903e7"},
+		{`1.5`, "fb3ff8000000000000"},
+		{`true`, "f5"},
+		{`false`, "f4"},
+		{`null`, "f6"},
+		{`"a"`, "6161"},
+		{`"0x"`, "d740"},
+		{`"0x01ff"`, "d74201ff"},
+		{`"0x1"`, "63307831"},
+		{`"0xAB"`, "6430784142"},
+		{`[]`, "9fff"},
+		{`[1,[2]]`, "9f019f02ffff"},
+		{`{"0x01":"0x01"}`, "bf6430783031d74101ff"},
+		{`{"a":{"b":null},"c":[true]}`, "bf6161bf6162f6ff61639ff5ffff"},
+	}
+	for _, test := range tests {
+		have, err := jsonToCBOR([]byte(test.json))
+		if err != nil {
+			t.Errorf("%s: error %v", test.json, err)
+			continue
+		}
+		if hex.EncodeToString(have) != test.cbor {
+			t.Errorf("%s: have %x, want %s", test.json, have, test.cbor)
+		}
+	}
+	if _, err := jsonToCBOR([]byte(`{"a":`)); err == nil {
+		t.Error("truncated input accepted")
+	}
+}
+
+func TestAcceptsCBOR(t *testing.T) {
+	tests := map[string]bool{
+		"":                                   false,
+		"application/json":                   false,
+		"application/cbor":                   true,
+		"application/json, application/cbor": true,
+		"application/cbor;q=0.9":             true,
+		"application/cborx":                  false,
+	}
+	for header, want := range tests {
+		if have := acceptsCBOR(header); have != want {
+			t.Errorf("%q: have %v, want %v", header, have, want)
+		}
+	}
+}
+
+// This checks that HTTP clients receive CBOR responses if they accept them.
+func TestHTTPCBORResponse(t *testing.T) {
+	s := newTestServer()
+	defer s.Stop()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	request := func(accept string) (string, []byte) {
+		body := `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]}`
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(body))
+		req.Header.Set("content-type", contentType)
+		req.Header.Set("accept", accept)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.Header.Get("content-type"), data
+	}
+	ctype, data := request(contentType)
+	if ctype != contentType {
+		t.Fatalf("wrong content type %q", ctype)
+	}
+	want, err := jsonToCBOR(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctype, data = request(cborContentType)
+	if ctype != cborContentType {
+		t.Fatalf("wrong content type %q", ctype)
+	}
+	if !bytes.Equal(data, want) {
+		t.Fatalf("wrong response %x, want %x", data, want)
+	}
+}
+
+// This checks that WebSocket clients receive binary CBOR messages if they
+// negotiate the subprotocol.
+func TestWebsocketCBORSubprotocol(t *testing.T) {
+	var (
+		srv     = newTestServer()
+		httpsrv = httptest.NewServer(srv.WebsocketHandler([]string{"*"}))
+		wsURL   = "ws:" + strings.TrimPrefix(httpsrv.URL, "http:")
+	)
+	defer srv.Stop()
+	defer httpsrv.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{cborSubprotocol}}
+	conn, _, err := dialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("can't dial: %v", err)
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != cborSubprotocol {
+		t.Fatalf("subprotocol not negotiated: %q", conn.Subprotocol())
+	}
+	req := `{"jsonrpc":"2.0","id":1,"method":"test_echo","params":["x",1]}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(req)); err != nil {
+		t.Fatal(err)
+	}
+	kind, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kind != websocket.BinaryMessage {
+		t.Fatalf("wrong message type %d", kind)
+	}
+	want, _ := jsonToCBOR([]byte(`{"jsonrpc":"2.0","id":1,"result":{"String":"x","Int":1,"Args":null}}`))
+	if !bytes.Equal(data, want) {
+		t.Fatalf("wrong response %x, want %x", data, want)
+	}
+}
@@ -76,6 +76,7 @@ type Client struct {
 	idgen    func() ID // for subscriptions
 	isHTTP   bool      // connection type: http, ws or ipc
 	services *serviceRegistry
+	cfg      handlerConfig // settings for serving calls of the remote end
 
 	idCounter uint32
 
@@ -112,7 +113,11 @@ func (c *Client) newClientConn(conn ServerCodec) *clientConn {
 	ctx := context.Background()
 	ctx = context.WithValue(ctx, clientContextKey{}, c)
 	ctx = context.WithValue(ctx, peerInfoContextKey{}, conn.peerInfo())
+	if c.cfg.requestIDs {
+		ctx = withRequestID(ctx, conn.peerInfo().HTTP.Header)
+	}
 	handler := newHandler(ctx, conn, c.idgen, c.services)
+	handler.cfg = c.cfg
 	return &clientConn{conn, handler}
 }
 
@@ -217,17 +222,18 @@ func newClient(initctx context.Context, connect reconnectFunc) (*Client, error)
 	if err != nil {
 		return nil, err
 	}
-	c := initClient(conn, randomIDGenerator(), new(serviceRegistry))
+	c := initClient(conn, randomIDGenerator(), new(serviceRegistry), handlerConfig{})
 	c.reconnectFunc = connect
 	return c, nil
 }
 
-func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry) *Client {
+func initClient(conn ServerCodec, idgen func() ID, services *serviceRegistry, cfg handlerConfig) *Client {
 	_, isHTTP := conn.(*httpConn)
 	c := &Client{
 		isHTTP:      isHTTP,
 		idgen:       idgen,
 		services:    services,
+		cfg:         cfg,
 		writeConn:   conn,
 		close:       make(chan struct{}),
 		closing:     make(chan struct{}),
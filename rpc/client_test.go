@@ -93,6 +93,12 @@ func TestClientErrorData(t *testing.T) {
 	} else if e.ErrorData() != (testError{}.ErrorData()) {
 		t.Fatalf("wrong error data %#v, want %#v", e.ErrorData(), testError{}.ErrorData())
 	}
+	// Check decoded data.
+	if e, ok := err.(DecodedError); !ok {
+		t.Fatalf("client did not return rpc.DecodedError, got %#v", e)
+	} else if e.ErrorDecoded() != (testError{}.ErrorDecoded()) {
+		t.Fatalf("wrong decoded error data %#v, want %#v", e.ErrorDecoded(), testError{}.ErrorDecoded())
+	}
 }
 
 func TestClientBatchRequest(t *testing.T) {
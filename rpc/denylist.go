@@ -0,0 +1,56 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import "sync"
+
+// MethodDenylist is a set of method names which are rejected by the servers it
+// is assigned to. It can be replaced while the servers are running.
+type MethodDenylist struct {
+	mu      sync.RWMutex
+	methods map[string]struct{}
+}
+
+// NewMethodDenylist creates a deny list of the given methods.
+func NewMethodDenylist(methods []string) *MethodDenylist {
+	d := new(MethodDenylist)
+	d.Set(methods)
+	return d
+}
+
+// Set replaces the denied methods.
+func (d *MethodDenylist) Set(methods []string) {
+	set := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		set[method] = struct{}{}
+	}
+	d.mu.Lock()
+	d.methods = set
+	d.mu.Unlock()
+}
+
+// contains reports whether the method is denied. A nil list denies nothing.
+func (d *MethodDenylist) contains(method string) bool {
+	if d == nil {
+		return false
+	}
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	_, ok := d.methods[method]
+	return ok
+}
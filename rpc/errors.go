@@ -45,6 +45,13 @@ type DataError interface {
 	ErrorData() interface{} // returns the error data
 }
 
+// A DecodedError contains a decoded form of its error data, sent along with the
+// raw data.
+type DecodedError interface {
+	Error() string             // returns the message
+	ErrorDecoded() interface{} // returns the decoded error data
+}
+
 // Error types defined below are the built-in JSON-RPC errors.
 
 var (
@@ -61,11 +61,21 @@ type handler struct {
 	conn           jsonWriter                     // where responses will be sent
 	log            log.Logger
 	allowSubscribe bool
+	cfg            handlerConfig
 
 	subLock    sync.Mutex
 	serverSubs map[ID]*Subscription
 }
 
+// handlerConfig holds the settings a Server applies to the handlers of its
+// connections. The zero value is used for client connections.
+type handlerConfig struct {
+	sched      schedPolicy              // admission of incoming calls
+	subBuffer  SubscriptionBufferConfig // delivery of subscription notifications
+	requestIDs bool                     // whether connections are assigned request IDs
+	deny       *MethodDenylist          // methods answered as not found
+}
+
 type callProc struct {
 	ctx       context.Context
 	notifiers []*Notifier
@@ -88,6 +98,9 @@ func newHandler(connCtx context.Context, conn jsonWriter, idgen func() ID, reg *
 	if conn.remoteAddr() != "" {
 		h.log = h.log.New("conn", conn.remoteAddr())
 	}
+	if id := RequestIDFromContext(connCtx); id != "" {
+		h.log = h.log.New("requestid", id)
+	}
 	h.unsubscribeCb = newCallback(reflect.Value{}, reflect.ValueOf(h.unsubscribe))
 	return h
 }
@@ -114,12 +127,16 @@ func (h *handler) handleBatch(msgs []*jsonrpcMessage) {
 	}
 	// Process calls on a goroutine because they may block indefinitely:
 	h.startCallProc(func(cp *callProc) {
+		if err := h.cfg.sched.acquire(cp.ctx); err != nil {
+			return
+		}
 		answers := make([]*jsonrpcMessage, 0, len(msgs))
 		for _, msg := range calls {
 			if answer := h.handleCallMsg(cp, msg); answer != nil {
 				answers = append(answers, answer)
 			}
 		}
+		h.cfg.sched.release()
 		h.addSubscriptions(cp.notifiers)
 		if len(answers) > 0 {
 			h.conn.writeJSON(cp.ctx, answers)
@@ -136,7 +153,11 @@ func (h *handler) handleMsg(msg *jsonrpcMessage) {
 		return
 	}
 	h.startCallProc(func(cp *callProc) {
+		if err := h.cfg.sched.acquire(cp.ctx); err != nil {
+			return
+		}
 		answer := h.handleCallMsg(cp, msg)
+		h.cfg.sched.release()
 		h.addSubscriptions(cp.notifiers)
 		if answer != nil {
 			h.conn.writeJSON(cp.ctx, answer)
@@ -212,6 +233,7 @@ func (h *handler) cancelServerSubscriptions(err error) {
 	for id, s := range h.serverSubs {
 		s.err <- err
 		close(s.err)
+		close(s.done)
 		delete(h.serverSubs, id)
 	}
 }
@@ -299,6 +321,9 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 		var ctx []interface{}
 		ctx = append(ctx, "reqid", idForLog{msg.ID}, "duration", time.Since(start))
 		if resp.Error != nil {
+			if resp.Error.Data == nil && requestIDSupplied(h.rootCtx) {
+				resp.Error.Data = map[string]string{"requestId": RequestIDFromContext(h.rootCtx)}
+			}
 			ctx = append(ctx, "err", resp.Error.Message)
 			if resp.Error.Data != nil {
 				ctx = append(ctx, "errdata", resp.Error.Data)
@@ -317,6 +342,9 @@ func (h *handler) handleCallMsg(ctx *callProc, msg *jsonrpcMessage) *jsonrpcMess
 
 // handleCall processes method calls.
 func (h *handler) handleCall(cp *callProc, msg *jsonrpcMessage) *jsonrpcMessage {
+	if h.cfg.deny.contains(msg.Method) {
+		return msg.errorResponse(&methodNotFoundError{method: msg.Method})
+	}
 	if msg.isSubscribe() {
 		return h.handleSubscribe(cp, msg)
 	}
@@ -377,7 +405,7 @@ func (h *handler) handleSubscribe(cp *callProc, msg *jsonrpcMessage) *jsonrpcMes
 	args = args[1:]
 
 	// Install notifier in context so the subscription handler can find it.
-	n := &Notifier{h: h, namespace: namespace}
+	n := &Notifier{h: h, namespace: namespace, bufcfg: h.cfg.subBuffer}
 	cp.notifiers = append(cp.notifiers, n)
 	ctx := context.WithValue(cp.ctx, notifierKey{}, n)
 
@@ -403,6 +431,7 @@ func (h *handler) unsubscribe(ctx context.Context, id ID) (bool, error) {
 		return false, ErrSubscriptionNotFound
 	}
 	close(s.err)
+	close(s.done)
 	delete(h.serverSubs, id)
 	return true, nil
 }
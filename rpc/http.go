@@ -219,6 +219,9 @@ func (hc *httpConn) doRequest(ctx context.Context, msg interface{}) (io.ReadClos
 			return nil, err
 		}
 	}
+	if id := RequestIDFromContext(ctx); id != "" && req.Header.Get(RequestIDHeader) == "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
 
 	// do request
 	resp, err := hc.client.Do(req)
@@ -251,7 +254,24 @@ type httpServerConn struct {
 func newHTTPServerConn(r *http.Request, w http.ResponseWriter) ServerCodec {
 	body := io.LimitReader(r.Body, maxRequestContentLength)
 	conn := &httpServerConn{Reader: body, Writer: w, r: r}
-	return NewCodec(conn)
+	if !acceptsCBOR(r.Header.Get("accept")) {
+		return NewCodec(conn)
+	}
+	// The client asked for CBOR responses, requests are still JSON.
+	dec := json.NewDecoder(conn)
+	dec.UseNumber()
+	encode := func(v interface{}) error {
+		msg, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if msg, err = jsonToCBOR(msg); err != nil {
+			return err
+		}
+		_, err = conn.Write(msg)
+		return err
+	}
+	return NewFuncCodec(conn, encode, dec.Decode)
 }
 
 // Close does nothing and always returns nil.
@@ -283,13 +303,20 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	connInfo.HTTP.Host = r.Host
 	connInfo.HTTP.Origin = r.Header.Get("Origin")
 	connInfo.HTTP.UserAgent = r.Header.Get("User-Agent")
+	connInfo.HTTP.Header = r.Header
 	ctx := r.Context()
 	ctx = context.WithValue(ctx, peerInfoContextKey{}, connInfo)
+	ctx = withRequestID(ctx, r.Header)
+	w.Header().Set(RequestIDHeader, RequestIDFromContext(ctx))
 
 	// All checks passed, create a codec that reads directly from the request body
 	// until EOF, writes the response to w, and orders the server to process a
 	// single request.
-	w.Header().Set("content-type", contentType)
+	if acceptsCBOR(r.Header.Get("accept")) {
+		w.Header().Set("content-type", cborContentType)
+	} else {
+		w.Header().Set("content-type", contentType)
+	}
 	codec := newHTTPServerConn(r, w)
 	defer codec.close()
 	s.serveSingleRequest(ctx, codec)
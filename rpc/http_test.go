@@ -17,6 +17,8 @@
 package rpc
 
 import (
+	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -198,3 +200,67 @@ func TestHTTPPeerInfo(t *testing.T) {
 		t.Errorf("wrong HTTP.Origin %q", info.HTTP.UserAgent)
 	}
 }
+
+func TestHTTPRequestID(t *testing.T) {
+	s := newTestServer()
+	defer s.Stop()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	post := func(body string, header http.Header) (*http.Response, string) {
+		req, _ := http.NewRequest(http.MethodPost, ts.URL, strings.NewReader(body))
+		req.Header = header
+		req.Header.Set("content-type", contentType)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		blob, _ := io.ReadAll(resp.Body)
+		return resp, string(blob)
+	}
+	// A supplied ID is echoed, passed to the call and included in error data.
+	header := http.Header{RequestIDHeader: {"req-1"}}
+	resp, body := post(`{"jsonrpc":"2.0","id":1,"method":"test_requestID"}`, header)
+	if id := resp.Header.Get(RequestIDHeader); id != "req-1" {
+		t.Errorf("wrong echoed request ID %q", id)
+	}
+	if want := `{"jsonrpc":"2.0","id":1,"result":"req-1"}`; strings.TrimSpace(body) != want {
+		t.Errorf("wrong response %s, want %s", body, want)
+	}
+	_, body = post(`{"jsonrpc":"2.0","id":1,"method":"test_unknown"}`, header)
+	if !strings.Contains(body, `"data":{"requestId":"req-1"}`) {
+		t.Errorf("request ID missing in error response %s", body)
+	}
+	// Without a valid ID, one is generated but not added to errors.
+	resp, body = post(`{"jsonrpc":"2.0","id":1,"method":"test_unknown"}`, http.Header{RequestIDHeader: {"bad id"}})
+	if id := resp.Header.Get(RequestIDHeader); len(id) != 16 {
+		t.Errorf("wrong generated request ID %q", id)
+	}
+	if strings.Contains(body, "requestId") {
+		t.Errorf("generated request ID in error response %s", body)
+	}
+}
+
+func TestHTTPClientRequestID(t *testing.T) {
+	s := newTestServer()
+	defer s.Stop()
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+
+	c, err := DialHTTP(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The client forwards the request ID of the call context.
+	ctx := withRequestID(context.Background(), http.Header{RequestIDHeader: {"req-2"}})
+	var id string
+	if err := c.CallContext(ctx, &id, "test_requestID"); err != nil {
+		t.Fatal(err)
+	}
+	if id != "req-2" {
+		t.Errorf("wrong forwarded request ID %q", id)
+	}
+}
@@ -119,6 +119,10 @@ func errorMessage(err error) *jsonrpcMessage {
 	if ok {
 		msg.Error.Data = de.ErrorData()
 	}
+	dec, ok := err.(DecodedError)
+	if ok {
+		msg.Error.Decoded = dec.ErrorDecoded()
+	}
 	return msg
 }
 
@@ -126,6 +130,7 @@ type jsonError struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
+	Decoded interface{} `json:"decoded,omitempty"`
 }
 
 func (err *jsonError) Error() string {
@@ -143,6 +148,10 @@ func (err *jsonError) ErrorData() interface{} {
 	return err.Data
 }
 
+func (err *jsonError) ErrorDecoded() interface{} {
+	return err.Decoded
+}
+
 // Conn is a subset of the methods of net.Conn which are sufficient for ServerCodec.
 type Conn interface {
 	io.ReadWriteCloser
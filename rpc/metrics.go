@@ -32,6 +32,10 @@ var (
 	serveTimeHistName = "rpc/duration"
 
 	rpcServingTimer = metrics.NewRegisteredTimer("rpc/duration/all", nil)
+
+	subscriptionDroppedMeter = metrics.NewRegisteredMeter("rpc/subscriptions/dropped", nil)
+
+	wsIdleEvictedMeter = metrics.NewRegisteredMeter("rpc/ws/evicted", nil)
 )
 
 // updateServeTimeHistogram tracks the serving time of a remote RPC call.
@@ -0,0 +1,83 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// RequestIDHeader is the HTTP header carrying the ID used to correlate a request
+// across load balancers and nodes.
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLength is the maximum length of request IDs accepted from clients.
+const maxRequestIDLength = 128
+
+type requestIDContextKey struct{}
+
+type requestID struct {
+	id       string
+	supplied bool // whether the ID was sent by the client
+}
+
+// RequestIDFromContext returns the ID of the request being served, or an empty
+// string if there is none. For HTTP, it is the X-Request-Id header of the request
+// or a generated ID. Calls on WebSocket connections share the ID of the upgrade
+// request. Outgoing HTTP requests of a Client send the ID of their context.
+func RequestIDFromContext(ctx context.Context) string {
+	rid, _ := ctx.Value(requestIDContextKey{}).(requestID)
+	return rid.id
+}
+
+// requestIDSupplied reports whether the request ID of the context was sent by the
+// client, rather than generated by the server.
+func requestIDSupplied(ctx context.Context) bool {
+	rid, _ := ctx.Value(requestIDContextKey{}).(requestID)
+	return rid.supplied
+}
+
+// withRequestID returns a context carrying the request ID of the given headers,
+// generating one if there is no valid ID.
+func withRequestID(ctx context.Context, header http.Header) context.Context {
+	if id := header.Get(RequestIDHeader); validRequestID(id) {
+		return context.WithValue(ctx, requestIDContextKey{}, requestID{id: id, supplied: true})
+	}
+	return context.WithValue(ctx, requestIDContextKey{}, requestID{id: newRequestID()})
+}
+
+// validRequestID reports whether id is non-empty, not too long and consists of
+// printable ASCII characters only, so it is safe to log and echo.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	var buf [8]byte
+	rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
@@ -0,0 +1,226 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+// PriorityClass is the dispatch class of the calls served by a Server.
+type PriorityClass int
+
+const (
+	PriorityPublic PriorityClass = iota // Calls arriving on the public endpoints
+	PriorityEngine                      // Calls arriving on the authenticated (engine API) endpoints
+
+	numPriorityClasses
+)
+
+// String implements fmt.Stringer.
+func (c PriorityClass) String() string {
+	switch c {
+	case PriorityPublic:
+		return "public"
+	case PriorityEngine:
+		return "engine"
+	default:
+		return fmt.Sprintf("class(%d)", int(c))
+	}
+}
+
+// DefaultPriorityWeights are the scheduling weights used for classes that are
+// not configured explicitly: while both classes have queued calls, eight engine
+// calls are dispatched for every public one.
+var DefaultPriorityWeights = map[PriorityClass]int{
+	PriorityPublic: 1,
+	PriorityEngine: 8,
+}
+
+// Scheduler bounds the number of concurrently executing calls across any number
+// of servers. Calls exceeding the limit are queued per priority class and are
+// dispatched using smooth weighted round-robin between the classes, so higher
+// priority traffic is served first without fully starving the lower classes.
+type Scheduler struct {
+	mu      sync.Mutex
+	limit   int
+	weights [numPriorityClasses]int
+	running int
+	queues  [numPriorityClasses][]chan struct{}
+	current [numPriorityClasses]int // running weights of the round-robin
+
+	queuedGauges [numPriorityClasses]metrics.Gauge
+	waitTimers   [numPriorityClasses]metrics.Timer
+}
+
+// NewScheduler creates a scheduler admitting at most limit concurrent calls, or
+// all calls if the limit is below one. Classes missing from weights fall back to
+// DefaultPriorityWeights.
+func NewScheduler(limit int, weights map[PriorityClass]int) *Scheduler {
+	s := new(Scheduler)
+	for class := PriorityClass(0); class < numPriorityClasses; class++ {
+		s.queuedGauges[class] = metrics.GetOrRegisterGauge(fmt.Sprintf("rpc/sched/%s/queued", class), nil)
+		s.waitTimers[class] = metrics.GetOrRegisterTimer(fmt.Sprintf("rpc/sched/%s/wait", class), nil)
+	}
+	s.setLimitLocked(limit, weights)
+	return s
+}
+
+// SetLimit changes the concurrency limit and class weights. Calls already
+// running are not affected, queued calls are admitted if the limit was raised.
+func (s *Scheduler) SetLimit(limit int, weights map[PriorityClass]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setLimitLocked(limit, weights)
+	s.dispatchLocked()
+}
+
+func (s *Scheduler) setLimitLocked(limit int, weights map[PriorityClass]int) {
+	s.limit = limit
+	for class := PriorityClass(0); class < numPriorityClasses; class++ {
+		w, ok := weights[class]
+		if !ok || w < 1 {
+			w = DefaultPriorityWeights[class]
+		}
+		s.weights[class] = w
+	}
+}
+
+// acquire blocks until a call of the given class may run or ctx is canceled.
+func (s *Scheduler) acquire(ctx context.Context, class PriorityClass) error {
+	s.mu.Lock()
+	if s.freeLocked() && s.queuedLocked() == 0 {
+		s.running++
+		s.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	s.queues[class] = append(s.queues[class], ready)
+	s.queuedGauges[class].Inc(1)
+	s.mu.Unlock()
+
+	start := time.Now()
+	select {
+	case <-ready:
+		s.waitTimers[class].UpdateSince(start)
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		for i, ch := range s.queues[class] {
+			if ch == ready {
+				s.queues[class] = append(s.queues[class][:i], s.queues[class][i+1:]...)
+				s.queuedGauges[class].Dec(1)
+				return ctx.Err()
+			}
+		}
+		// The call was admitted concurrently with the cancellation, hand
+		// the slot over to the next waiter.
+		s.running--
+		s.dispatchLocked()
+		return ctx.Err()
+	}
+}
+
+// release returns a slot obtained by acquire.
+func (s *Scheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.running--
+	s.dispatchLocked()
+}
+
+// dispatchLocked admits queued calls while there are free slots.
+func (s *Scheduler) dispatchLocked() {
+	for s.freeLocked() {
+		class, ok := s.nextLocked()
+		if !ok {
+			return
+		}
+		ready := s.queues[class][0]
+		s.queues[class] = s.queues[class][1:]
+		s.queuedGauges[class].Dec(1)
+		s.running++
+		close(ready)
+	}
+}
+
+// nextLocked picks the class to dispatch from using smooth weighted round-robin
+// over the classes that have queued calls.
+func (s *Scheduler) nextLocked() (PriorityClass, bool) {
+	var (
+		best  = PriorityClass(-1)
+		total int
+	)
+	for class := PriorityClass(0); class < numPriorityClasses; class++ {
+		if len(s.queues[class]) == 0 {
+			s.current[class] = 0
+			continue
+		}
+		s.current[class] += s.weights[class]
+		total += s.weights[class]
+		if best < 0 || s.current[class] > s.current[best] {
+			best = class
+		}
+	}
+	if best < 0 {
+		return 0, false
+	}
+	s.current[best] -= total
+	return best, true
+}
+
+// freeLocked reports whether another call may run.
+func (s *Scheduler) freeLocked() bool {
+	return s.limit < 1 || s.running < s.limit
+}
+
+// queuedLocked returns the total number of queued calls.
+func (s *Scheduler) queuedLocked() int {
+	var n int
+	for _, q := range s.queues {
+		n += len(q)
+	}
+	return n
+}
+
+// schedPolicy binds a handler to a scheduler and the class its calls run under.
+// The zero value runs all calls unscheduled.
+type schedPolicy struct {
+	sched *Scheduler
+	class PriorityClass
+}
+
+func (p schedPolicy) acquire(ctx context.Context) error {
+	if p.sched == nil {
+		return nil
+	}
+	return p.sched.acquire(ctx, p.class)
+}
+
+func (p schedPolicy) release() {
+	if p.sched != nil {
+		p.sched.release()
+	}
+}
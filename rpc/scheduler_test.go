@@ -0,0 +1,151 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// waitQueued blocks until the scheduler has n queued calls.
+func waitQueued(t *testing.T, s *Scheduler, n int) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		queued := s.queuedLocked()
+		s.mu.Unlock()
+		if queued == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("scheduler did not reach %d queued calls", n)
+}
+
+func TestSchedulerPriority(t *testing.T) {
+	s := NewScheduler(1, map[PriorityClass]int{PriorityPublic: 1, PriorityEngine: 2})
+
+	// Occupy the only slot, then queue up calls of both classes.
+	if err := s.acquire(context.Background(), PriorityPublic); err != nil {
+		t.Fatal(err)
+	}
+	order := make(chan PriorityClass, 6)
+	enqueue := func(class PriorityClass, queued int) {
+		go func() {
+			if err := s.acquire(context.Background(), class); err != nil {
+				t.Error(err)
+				return
+			}
+			order <- class
+		}()
+		waitQueued(t, s, queued)
+	}
+	for i := 0; i < 3; i++ {
+		enqueue(PriorityPublic, i+1)
+	}
+	for i := 0; i < 3; i++ {
+		enqueue(PriorityEngine, i+4)
+	}
+	// Release slots one at a time and check the dispatch order.
+	want := []PriorityClass{PriorityEngine, PriorityPublic, PriorityEngine, PriorityEngine, PriorityPublic, PriorityPublic}
+	for i, class := range want {
+		s.release()
+		if have := <-order; have != class {
+			t.Fatalf("dispatch %d: have class %v, want %v", i, have, class)
+		}
+	}
+	s.release()
+}
+
+func TestSchedulerCancel(t *testing.T) {
+	s := NewScheduler(1, nil)
+	if err := s.acquire(context.Background(), PriorityEngine); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := s.acquire(ctx, PriorityPublic); err != context.DeadlineExceeded {
+		t.Fatalf("wrong error: %v", err)
+	}
+	s.mu.Lock()
+	queued := s.queuedLocked()
+	s.mu.Unlock()
+	if queued != 0 {
+		t.Fatalf("canceled call still queued")
+	}
+	s.release()
+	if err := s.acquire(context.Background(), PriorityPublic); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSchedulerSetLimit(t *testing.T) {
+	s := NewScheduler(1, nil)
+	if err := s.acquire(context.Background(), PriorityPublic); err != nil {
+		t.Fatal(err)
+	}
+	admitted := make(chan struct{})
+	go func() {
+		if err := s.acquire(context.Background(), PriorityPublic); err != nil {
+			t.Error(err)
+		}
+		close(admitted)
+	}()
+	waitQueued(t, s, 1)
+
+	// Raising the limit admits the queued call.
+	s.SetLimit(2, nil)
+	select {
+	case <-admitted:
+	case <-time.After(time.Second):
+		t.Fatal("queued call not admitted after raising the limit")
+	}
+	s.release()
+	s.release()
+}
+
+func TestSchedulerUnlimited(t *testing.T) {
+	s := NewScheduler(1, nil)
+	if err := s.acquire(context.Background(), PriorityPublic); err != nil {
+		t.Fatal(err)
+	}
+	admitted := make(chan struct{})
+	go func() {
+		if err := s.acquire(context.Background(), PriorityPublic); err != nil {
+			t.Error(err)
+		}
+		close(admitted)
+	}()
+	waitQueued(t, s, 1)
+
+	// Disabling the limit admits the queued call and all later ones.
+	s.SetLimit(0, nil)
+	select {
+	case <-admitted:
+	case <-time.After(time.Second):
+		t.Fatal("queued call not admitted after disabling the limit")
+	}
+	for i := 0; i < 10; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := s.acquire(ctx, PriorityPublic)
+		cancel()
+		if err != nil {
+			t.Fatalf("call %d not admitted: %v", i, err)
+		}
+	}
+}
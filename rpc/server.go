@@ -19,7 +19,9 @@ package rpc
 import (
 	"context"
 	"io"
+	"net/http"
 	"sync/atomic"
+	"time"
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/ethereum/go-ethereum/log"
@@ -47,11 +49,16 @@ type Server struct {
 	idgen    func() ID
 	run      int32
 	codecs   mapset.Set
+	cfg      handlerConfig
+
+	wsCompression int           // minimum size of compressed WebSocket messages, negative if disabled
+	wsIdleTimeout time.Duration // inactivity after which WebSocket connections are closed, zero if disabled
 }
 
 // NewServer creates a new server instance with no registered handlers.
 func NewServer() *Server {
-	server := &Server{idgen: randomIDGenerator(), codecs: mapset.NewSet(), run: 1}
+	server := &Server{idgen: randomIDGenerator(), codecs: mapset.NewSet(), run: 1, wsCompression: -1}
+	server.cfg.requestIDs = true
 	// Register the default service providing meta information about the RPC service such
 	// as the services and methods it offers.
 	rpcService := &RPCService{server}
@@ -67,6 +74,49 @@ func (s *Server) RegisterName(name string, receiver interface{}) error {
 	return s.services.registerName(name, receiver)
 }
 
+// UnregisterName removes all methods and subscriptions of the service with the
+// given name. Calls and subscriptions which are already running are not
+// affected. It reports whether the service existed.
+func (s *Server) UnregisterName(name string) bool {
+	return s.services.unregisterName(name)
+}
+
+// SetScheduler makes all calls served by the server subject to admission by
+// the given scheduler, queued under the given priority class. It must be called
+// before the server starts serving requests.
+func (s *Server) SetScheduler(sched *Scheduler, class PriorityClass) {
+	s.cfg.sched = schedPolicy{sched: sched, class: class}
+}
+
+// SetMethodDenylist makes the server reject calls of the methods in the given
+// deny list as if they did not exist. It must be called before the server starts
+// serving requests, the list itself can be changed at any time.
+func (s *Server) SetMethodDenylist(deny *MethodDenylist) {
+	s.cfg.deny = deny
+}
+
+// SetSubscriptionBuffer configures the queueing of subscription notifications
+// for all connections served after the call.
+func (s *Server) SetSubscriptionBuffer(cfg SubscriptionBufferConfig) {
+	s.cfg.subBuffer = cfg
+}
+
+// SetWebsocketCompression enables permessage-deflate compression of WebSocket
+// messages of at least threshold bytes, for clients negotiating it. A negative
+// threshold disables compression. It must be called before the server starts
+// serving WebSocket connections.
+func (s *Server) SetWebsocketCompression(threshold int) {
+	s.wsCompression = threshold
+}
+
+// SetWebsocketIdleTimeout makes the server close WebSocket connections on which
+// no message was received or sent for the given duration. Zero disables the
+// timeout. It must be called before the server starts serving WebSocket
+// connections.
+func (s *Server) SetWebsocketIdleTimeout(timeout time.Duration) {
+	s.wsIdleTimeout = timeout
+}
+
 // ServeCodec reads incoming requests from codec, calls the appropriate callback and writes
 // the response back using the given codec. It will block until the codec is closed or the
 // server is stopped. In either case the codec is closed.
@@ -84,7 +134,7 @@ func (s *Server) ServeCodec(codec ServerCodec, options CodecOption) {
 	s.codecs.Add(codec)
 	defer s.codecs.Remove(codec)
 
-	c := initClient(codec, s.idgen, &s.services)
+	c := initClient(codec, s.idgen, &s.services, s.cfg)
 	<-codec.closed()
 	c.Close()
 }
@@ -100,6 +150,7 @@ func (s *Server) serveSingleRequest(ctx context.Context, codec ServerCodec) {
 
 	h := newHandler(ctx, codec, s.idgen, &s.services)
 	h.allowSubscribe = false
+	h.cfg = s.cfg
 	defer h.close(io.EOF, nil)
 
 	reqs, batch, err := codec.readBatch()
@@ -168,6 +219,8 @@ type PeerInfo struct {
 		UserAgent string
 		Origin    string
 		Host      string
+		// All header values sent by the client.
+		Header http.Header
 	}
 }
 
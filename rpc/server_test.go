@@ -45,7 +45,7 @@ func TestServerRegisterName(t *testing.T) {
 		t.Fatalf("Expected service calc to be registered")
 	}
 
-	wantCallbacks := 10
+	wantCallbacks := 11
 	if len(svc.callbacks) != wantCallbacks {
 		t.Errorf("Expected %d callbacks for service 'service', got %d", wantCallbacks, len(svc.callbacks))
 	}
@@ -152,3 +152,30 @@ func TestServerShortLivedConn(t *testing.T) {
 		}
 	}
 }
+
+func TestServerMethodDenylist(t *testing.T) {
+	server := newTestServer()
+	defer server.Stop()
+	deny := NewMethodDenylist([]string{"test_noArgsRets"})
+	server.SetMethodDenylist(deny)
+
+	client := DialInProc(server)
+	defer client.Close()
+
+	err := client.Call(nil, "test_noArgsRets")
+	if rpcErr, ok := err.(Error); !ok || rpcErr.ErrorCode() != (&methodNotFoundError{}).ErrorCode() {
+		t.Fatalf("denied method: wrong error %v", err)
+	}
+	if err := client.Call(nil, "test_sleep", time.Duration(0)); err != nil {
+		t.Fatalf("allowed method: %v", err)
+	}
+
+	// Replacing the list takes effect on the open connection.
+	deny.Set([]string{"test_sleep"})
+	if err := client.Call(nil, "test_noArgsRets"); err != nil {
+		t.Fatalf("no longer denied method: %v", err)
+	}
+	if err := client.Call(nil, "test_sleep", time.Duration(0)); err == nil {
+		t.Fatal("newly denied method succeeded")
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"math/rand"
 	"reflect"
 	"strings"
@@ -36,8 +37,46 @@ var (
 	ErrNotificationsUnsupported = errors.New("notifications not supported")
 	// ErrSubscriptionNotFound is returned when the notification for the given id is not found
 	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrSubscriptionOverflow is returned when a notification cannot be queued and
+	// the overflow policy of the subscription is to disconnect the client
+	ErrSubscriptionOverflow = errors.New("subscription buffer overflow")
+	// ErrSubscriptionClosed is returned when waiting to deliver a notification of
+	// a subscription which was unsubscribed or whose connection was closed
+	ErrSubscriptionClosed = errors.New("subscription closed")
 )
 
+// OverflowPolicy selects how a subscription handles notifications arriving while
+// its buffer is full.
+type OverflowPolicy string
+
+const (
+	OverflowDropOldest OverflowPolicy = "drop-oldest" // Discard the oldest queued notification
+	OverflowDropNewest OverflowPolicy = "drop-newest" // Discard the incoming notification
+	OverflowDisconnect OverflowPolicy = "disconnect"  // Close the client connection
+)
+
+// SubscriptionBufferConfig configures the delivery of subscription notifications.
+type SubscriptionBufferConfig struct {
+	// Size is the number of notifications queued per subscription. Zero disables
+	// the queue: notifications are then written to the connection directly,
+	// blocking the producer while the client is slow to read.
+	Size int
+
+	// Overflow is the policy applied when the queue is full. It defaults to
+	// OverflowDropOldest.
+	Overflow OverflowPolicy
+}
+
+// Validate checks the overflow policy.
+func (cfg SubscriptionBufferConfig) Validate() error {
+	switch cfg.Overflow {
+	case "", OverflowDropOldest, OverflowDropNewest, OverflowDisconnect:
+		return nil
+	default:
+		return fmt.Errorf("invalid subscription overflow policy %q", cfg.Overflow)
+	}
+}
+
 var globalGen = randomIDGenerator()
 
 // ID defines a pseudo random number that is used to identify RPC subscriptions.
@@ -99,6 +138,12 @@ type Notifier struct {
 	buffer       []json.RawMessage
 	callReturned bool
 	activated    bool
+
+	// Asynchronous delivery through a bounded queue, if enabled by bufcfg.
+	bufcfg SubscriptionBufferConfig
+	queue  []json.RawMessage
+	wakeup chan struct{}
+	space  chan struct{} // Signalled on activation and whenever the queue shrinks
 }
 
 // CreateSubscription returns a new subscription that is coupled to the
@@ -114,16 +159,24 @@ func (n *Notifier) CreateSubscription() *Subscription {
 	} else if n.callReturned {
 		panic("can't create subscription after subscribe call has returned")
 	}
-	n.sub = &Subscription{ID: n.h.idgen(), namespace: n.namespace, err: make(chan error, 1)}
+	n.sub = &Subscription{ID: n.h.idgen(), namespace: n.namespace, err: make(chan error, 1), done: make(chan struct{})}
+	n.space = make(chan struct{}, 1)
 	return n.sub
 }
 
 // Notify sends a notification to the client with the given data as payload.
 // If an error occurs the RPC connection is closed and the error is returned.
+// Payloads given as json.RawMessage are sent as is, allowing notifications
+// shared by many subscribers to be encoded only once.
 func (n *Notifier) Notify(id ID, data interface{}) error {
-	enc, err := json.Marshal(data)
-	if err != nil {
-		return err
+	var enc json.RawMessage
+	if raw, ok := data.(json.RawMessage); ok {
+		enc = raw
+	} else {
+		var err error
+		if enc, err = json.Marshal(data); err != nil {
+			return err
+		}
 	}
 
 	n.mu.Lock()
@@ -135,12 +188,66 @@ func (n *Notifier) Notify(id ID, data interface{}) error {
 		panic("Notify with wrong ID")
 	}
 	if n.activated {
+		if n.bufcfg.Size > 0 {
+			return n.enqueue(enc)
+		}
 		return n.send(n.sub, enc)
 	}
 	n.buffer = append(n.buffer, enc)
 	return nil
 }
 
+// NotifyWait sends a notification like Notify, but waits for room in the delivery
+// queue instead of applying the overflow policy, so that producers of lossless
+// streams follow the pace of the client. Before the subscription is activated
+// at most one notification is held back.
+func (n *Notifier) NotifyWait(id ID, data interface{}) error {
+	enc, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	for {
+		n.mu.Lock()
+		if n.sub == nil {
+			panic("can't Notify before subscription is created")
+		} else if n.sub.ID != id {
+			panic("Notify with wrong ID")
+		}
+		switch {
+		case !n.activated && len(n.buffer) == 0:
+			n.buffer = append(n.buffer, enc)
+			n.mu.Unlock()
+			return nil
+		case n.activated && n.bufcfg.Size == 0:
+			err := n.send(n.sub, enc)
+			n.mu.Unlock()
+			return err
+		case n.activated && len(n.queue) < n.bufcfg.Size:
+			err := n.enqueue(enc)
+			n.mu.Unlock()
+			return err
+		}
+		sub, space := n.sub, n.space
+		n.mu.Unlock()
+
+		select {
+		case <-space:
+		case <-sub.done:
+			return ErrSubscriptionClosed
+		case <-n.h.conn.closed():
+			return ErrSubscriptionClosed
+		}
+	}
+}
+
+// signalSpace wakes up a producer waiting in NotifyWait.
+func (n *Notifier) signalSpace() {
+	select {
+	case n.space <- struct{}{}:
+	default:
+	}
+}
+
 // Closed returns a channel that is closed when the RPC connection is closed.
 // Deprecated: use subscription error channel
 func (n *Notifier) Closed() <-chan interface{} {
@@ -163,15 +270,83 @@ func (n *Notifier) activate() error {
 	n.mu.Lock()
 	defer n.mu.Unlock()
 
-	for _, data := range n.buffer {
-		if err := n.send(n.sub, data); err != nil {
-			return err
+	if n.bufcfg.Size > 0 {
+		n.wakeup = make(chan struct{}, 1)
+		go n.sendLoop(n.sub)
+		for _, data := range n.buffer {
+			if err := n.enqueue(data); err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, data := range n.buffer {
+			if err := n.send(n.sub, data); err != nil {
+				return err
+			}
 		}
 	}
+	n.buffer = nil
 	n.activated = true
+	n.signalSpace()
 	return nil
 }
 
+// enqueue adds a notification to the delivery queue, applying the overflow
+// policy if the queue is full. The caller must hold n.mu.
+func (n *Notifier) enqueue(data json.RawMessage) error {
+	if len(n.queue) >= n.bufcfg.Size {
+		subscriptionDroppedMeter.Mark(1)
+		switch n.bufcfg.Overflow {
+		case OverflowDropNewest:
+			return nil
+		case OverflowDisconnect:
+			if c, ok := n.h.conn.(interface{ close() }); ok {
+				c.close()
+			}
+			return ErrSubscriptionOverflow
+		default:
+			n.queue[0] = nil
+			n.queue = n.queue[1:]
+		}
+	}
+	n.queue = append(n.queue, data)
+	select {
+	case n.wakeup <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// sendLoop delivers queued notifications until the subscription ends or the
+// connection is closed.
+func (n *Notifier) sendLoop(sub *Subscription) {
+	for {
+		select {
+		case <-n.wakeup:
+		case <-sub.done:
+			return
+		case <-n.h.conn.closed():
+			return
+		}
+		for {
+			n.mu.Lock()
+			if len(n.queue) == 0 {
+				n.mu.Unlock()
+				break
+			}
+			data := n.queue[0]
+			n.queue[0] = nil
+			n.queue = n.queue[1:]
+			n.signalSpace()
+			n.mu.Unlock()
+
+			if err := n.send(sub, data); err != nil {
+				return
+			}
+		}
+	}
+}
+
 func (n *Notifier) send(sub *Subscription, data json.RawMessage) error {
 	params, _ := json.Marshal(&subscriptionResult{ID: string(sub.ID), Result: data})
 	ctx := context.Background()
@@ -187,7 +362,8 @@ func (n *Notifier) send(sub *Subscription, data json.RawMessage) error {
 type Subscription struct {
 	ID        ID
 	namespace string
-	err       chan error // closed on unsubscribe
+	err       chan error    // closed on unsubscribe
+	done      chan struct{} // closed on unsubscribe, internal to the notifier
 }
 
 // Err returns a channel that is closed when the client send an unsubscribe request.
@@ -17,10 +17,13 @@
 package rpc
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -178,6 +181,128 @@ type subConfirmation struct {
 
 // waitForMessages reads RPC messages from 'in' and dispatches them into the given channels.
 // It stops if there is an error.
+// overflowTestConn is a jsonWriter which records whether it was closed.
+type overflowTestConn struct {
+	closeCh chan interface{}
+}
+
+func (c *overflowTestConn) writeJSON(context.Context, interface{}) error { return nil }
+func (c *overflowTestConn) closed() <-chan interface{}                   { return c.closeCh }
+func (c *overflowTestConn) remoteAddr() string                           { return "" }
+func (c *overflowTestConn) close()                                       { close(c.closeCh) }
+
+func TestNotifierOverflow(t *testing.T) {
+	tests := []struct {
+		policy  OverflowPolicy
+		want    []string
+		wantErr error
+	}{
+		{OverflowDropOldest, []string{"2", "3"}, nil},
+		{"", []string{"2", "3"}, nil},
+		{OverflowDropNewest, []string{"1", "2"}, nil},
+		{OverflowDisconnect, []string{"1", "2"}, ErrSubscriptionOverflow},
+	}
+	for _, test := range tests {
+		conn := &overflowTestConn{closeCh: make(chan interface{})}
+		n := &Notifier{
+			h:      &handler{conn: conn},
+			bufcfg: SubscriptionBufferConfig{Size: 2, Overflow: test.policy},
+			wakeup: make(chan struct{}, 1),
+		}
+		var err error
+		for _, data := range []string{"1", "2", "3"} {
+			if err = n.enqueue(json.RawMessage(data)); err != nil {
+				break
+			}
+		}
+		if err != test.wantErr {
+			t.Errorf("policy %q: wrong error: have %v, want %v", test.policy, err, test.wantErr)
+		}
+		var have []string
+		for _, data := range n.queue {
+			have = append(have, string(data))
+		}
+		if !reflect.DeepEqual(have, test.want) {
+			t.Errorf("policy %q: wrong queue: have %v, want %v", test.policy, have, test.want)
+		}
+		select {
+		case <-conn.closeCh:
+			if test.policy != OverflowDisconnect {
+				t.Errorf("policy %q: connection closed", test.policy)
+			}
+		default:
+			if test.policy == OverflowDisconnect {
+				t.Errorf("policy %q: connection not closed", test.policy)
+			}
+		}
+	}
+}
+
+// blockingTestConn is a connection whose writes block until released.
+type blockingTestConn struct {
+	overflowTestConn
+	release chan struct{}
+	written chan string
+}
+
+func (c *blockingTestConn) writeJSON(ctx context.Context, v interface{}) error {
+	<-c.release
+	var res subscriptionResult
+	json.Unmarshal(v.(*jsonrpcMessage).Params, &res)
+	c.written <- string(res.Result)
+	return nil
+}
+
+// Tests that NotifyWait holds the producer back while the delivery queue is full
+// rather than dropping notifications.
+func TestNotifierWait(t *testing.T) {
+	conn := &blockingTestConn{
+		overflowTestConn: overflowTestConn{closeCh: make(chan interface{})},
+		release:          make(chan struct{}),
+		written:          make(chan string, 5),
+	}
+	n := &Notifier{
+		h:      &handler{conn: conn, idgen: randomIDGenerator()},
+		bufcfg: SubscriptionBufferConfig{Size: 1},
+	}
+	sub := n.CreateSubscription()
+
+	var sent int32
+	go func() {
+		for i := 1; i <= 5; i++ {
+			if err := n.NotifyWait(sub.ID, i); err != nil {
+				t.Errorf("notification %d failed: %v", i, err)
+				return
+			}
+			atomic.AddInt32(&sent, 1)
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if have := atomic.LoadInt32(&sent); have != 1 {
+		t.Fatalf("notifications held before activation: have %d, want 1", have)
+	}
+	if err := n.activate(); err != nil {
+		t.Fatal(err)
+	}
+	// One notification is being written, one is queued, the producer waits
+	time.Sleep(50 * time.Millisecond)
+	if have := atomic.LoadInt32(&sent); have != 2 {
+		t.Fatalf("notifications accepted while blocked: have %d, want 2", have)
+	}
+	close(conn.release)
+	for i := 1; i <= 5; i++ {
+		select {
+		case data := <-conn.written:
+			if data != fmt.Sprint(i) {
+				t.Fatalf("notification %d mismatch: have %s", i, data)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("notification %d not delivered", i)
+		}
+	}
+	conn.close()
+}
+
 func waitForMessages(in *json.Decoder, successes chan subConfirmation, notifications chan subscriptionResult, errors chan error) {
 	for {
 		resp, notification, err := readAndValidateMessage(in)
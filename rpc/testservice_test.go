@@ -66,9 +66,10 @@ type echoResult struct {
 
 type testError struct{}
 
-func (testError) Error() string          { return "testError" }
-func (testError) ErrorCode() int         { return 444 }
-func (testError) ErrorData() interface{} { return "testError data" }
+func (testError) Error() string             { return "testError" }
+func (testError) ErrorCode() int            { return 444 }
+func (testError) ErrorData() interface{}    { return "testError data" }
+func (testError) ErrorDecoded() interface{} { return "testError decoded" }
 
 func (s *testService) NoArgsRets() {}
 
@@ -84,6 +85,10 @@ func (s *testService) PeerInfo(ctx context.Context) PeerInfo {
 	return PeerInfoFromContext(ctx)
 }
 
+func (s *testService) RequestID(ctx context.Context) string {
+	return RequestIDFromContext(ctx)
+}
+
 func (s *testService) Sleep(ctx context.Context, duration time.Duration) {
 	time.Sleep(duration)
 }
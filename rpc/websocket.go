@@ -19,6 +19,7 @@ package rpc
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -26,6 +27,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	mapset "github.com/deckarep/golang-set"
@@ -50,18 +52,24 @@ var wsBufferPool = new(sync.Pool)
 // To allow connections with any origin, pass "*".
 func (s *Server) WebsocketHandler(allowedOrigins []string) http.Handler {
 	var upgrader = websocket.Upgrader{
-		ReadBufferSize:  wsReadBuffer,
-		WriteBufferSize: wsWriteBuffer,
-		WriteBufferPool: wsBufferPool,
-		CheckOrigin:     wsHandshakeValidator(allowedOrigins),
+		ReadBufferSize:    wsReadBuffer,
+		WriteBufferSize:   wsWriteBuffer,
+		WriteBufferPool:   wsBufferPool,
+		CheckOrigin:       wsHandshakeValidator(allowedOrigins),
+		EnableCompression: s.wsCompression >= 0,
+		Subprotocols:      []string{cborSubprotocol},
 	}
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		conn, err := upgrader.Upgrade(w, r, nil)
+		var header http.Header
+		if id := r.Header.Get(RequestIDHeader); validRequestID(id) {
+			header = http.Header{RequestIDHeader: {id}}
+		}
+		conn, err := upgrader.Upgrade(w, r, header)
 		if err != nil {
 			log.Debug("WebSocket upgrade failed", "err", err)
 			return
 		}
-		codec := newWebsocketCodec(conn, r.Host, r.Header)
+		codec := newWebsocketCodec(conn, r.Host, r.Header, s.wsCompression, s.wsIdleTimeout)
 		s.ServeCodec(codec, 0)
 	})
 }
@@ -198,7 +206,7 @@ func DialWebsocketWithDialer(ctx context.Context, endpoint, origin string, diale
 			}
 			return nil, hErr
 		}
-		return newWebsocketCodec(conn, endpoint, header), nil
+		return newWebsocketCodec(conn, endpoint, header, -1, 0), nil
 	})
 }
 
@@ -249,7 +257,7 @@ func DialWebsocketWithAuth(ctx context.Context, endpoint, origin string, auth He
 			}
 			return nil, hErr
 		}
-		return newWebsocketCodec(conn, endpoint, dialHeader), nil
+		return newWebsocketCodec(conn, endpoint, dialHeader, -1, 0), nil
 	})
 }
 
@@ -277,18 +285,48 @@ type websocketCodec struct {
 
 	wg        sync.WaitGroup
 	pingReset chan struct{}
+
+	idleTimeout time.Duration
+	lastActive  int64 // unix nanoseconds of the last message read or written, atomic
 }
 
-func newWebsocketCodec(conn *websocket.Conn, host string, req http.Header) ServerCodec {
+// newWebsocketCodec creates a codec on an established connection. Messages of at
+// least compressThreshold bytes are compressed if the connection negotiated it,
+// a negative threshold disables compression. Outgoing messages are sent as binary
+// CBOR if the connection negotiated the CBOR subprotocol. The connection is closed
+// when no message was read or written within idleTimeout, unless it is zero.
+func newWebsocketCodec(conn *websocket.Conn, host string, req http.Header, compressThreshold int, idleTimeout time.Duration) ServerCodec {
 	conn.SetReadLimit(wsMessageSizeLimit)
 	conn.SetPongHandler(func(appData string) error {
 		conn.SetReadDeadline(time.Time{})
 		return nil
 	})
+	encode := conn.WriteJSON
+	if cbor := conn.Subprotocol() == cborSubprotocol; cbor || compressThreshold >= 0 {
+		encode = func(v interface{}) error {
+			msg, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			kind := websocket.TextMessage
+			if cbor {
+				if msg, err = jsonToCBOR(msg); err != nil {
+					return err
+				}
+				kind = websocket.BinaryMessage
+			}
+			if compressThreshold >= 0 {
+				conn.EnableWriteCompression(len(msg) >= compressThreshold)
+			}
+			return conn.WriteMessage(kind, msg)
+		}
+	}
 	wc := &websocketCodec{
-		jsonCodec: NewFuncCodec(conn, conn.WriteJSON, conn.ReadJSON).(*jsonCodec),
-		conn:      conn,
-		pingReset: make(chan struct{}, 1),
+		jsonCodec:   NewFuncCodec(conn, encode, conn.ReadJSON).(*jsonCodec),
+		conn:        conn,
+		pingReset:   make(chan struct{}, 1),
+		idleTimeout: idleTimeout,
+		lastActive:  time.Now().UnixNano(),
 		info: PeerInfo{
 			Transport:  "ws",
 			RemoteAddr: conn.RemoteAddr().String(),
@@ -298,9 +336,14 @@ func newWebsocketCodec(conn *websocket.Conn, host string, req http.Header) Serve
 	wc.info.HTTP.Host = host
 	wc.info.HTTP.Origin = req.Get("Origin")
 	wc.info.HTTP.UserAgent = req.Get("User-Agent")
+	wc.info.HTTP.Header = req
 	// Start pinger.
 	wc.wg.Add(1)
 	go wc.pingLoop()
+	if idleTimeout > 0 {
+		wc.wg.Add(1)
+		go wc.idleLoop()
+	}
 	return wc
 }
 
@@ -313,9 +356,18 @@ func (wc *websocketCodec) peerInfo() PeerInfo {
 	return wc.info
 }
 
+func (wc *websocketCodec) readBatch() ([]*jsonrpcMessage, bool, error) {
+	msgs, batch, err := wc.jsonCodec.readBatch()
+	if err == nil {
+		atomic.StoreInt64(&wc.lastActive, time.Now().UnixNano())
+	}
+	return msgs, batch, err
+}
+
 func (wc *websocketCodec) writeJSON(ctx context.Context, v interface{}) error {
 	err := wc.jsonCodec.writeJSON(ctx, v)
 	if err == nil {
+		atomic.StoreInt64(&wc.lastActive, time.Now().UnixNano())
 		// Notify pingLoop to delay the next idle ping.
 		select {
 		case wc.pingReset <- struct{}{}:
@@ -350,3 +402,27 @@ func (wc *websocketCodec) pingLoop() {
 		}
 	}
 }
+
+// idleLoop closes the connection when no message was read or written within
+// the idle timeout. Ping and pong frames don't count as activity.
+func (wc *websocketCodec) idleLoop() {
+	var timer = time.NewTimer(wc.idleTimeout)
+	defer wc.wg.Done()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-wc.closed():
+			return
+		case <-timer.C:
+			idle := time.Since(time.Unix(0, atomic.LoadInt64(&wc.lastActive)))
+			if idle >= wc.idleTimeout {
+				log.Debug("Closing idle WebSocket connection", "remote", wc.info.RemoteAddr, "idle", idle)
+				wsIdleEvictedMeter.Mark(1)
+				wc.jsonCodec.close()
+				return
+			}
+			timer.Reset(wc.idleTimeout - idle)
+		}
+	}
+}
@@ -117,6 +117,52 @@ func TestWebsocketLargeCall(t *testing.T) {
 	}
 }
 
+// This test checks that the server closes connections without any messages
+// within the idle timeout.
+func TestWebsocketIdleTimeout(t *testing.T) {
+	t.Parallel()
+
+	var (
+		srv     = newTestServer()
+		httpsrv = httptest.NewServer(srv.WebsocketHandler([]string{"*"}))
+		wsURL   = "ws:" + strings.TrimPrefix(httpsrv.URL, "http:")
+	)
+	srv.SetWebsocketIdleTimeout(300 * time.Millisecond)
+	defer srv.Stop()
+	defer httpsrv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("can't dial: %v", err)
+	}
+	defer conn.Close()
+
+	// Requests keep the connection alive.
+	for i := 0; i < 3; i++ {
+		if err := conn.WriteJSON(map[string]interface{}{"jsonrpc": "2.0", "id": i, "method": "test_echo", "params": []interface{}{"x", 1}}); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		var resp jsonrpcMessage
+		if err := conn.ReadJSON(&resp); err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	// Without requests, the server closes the connection.
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	start := time.Now()
+	_, _, err = conn.ReadMessage()
+	if err == nil {
+		t.Fatal("idle connection not closed")
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("idle connection not closed within the timeout")
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("connection closed too early (after %v)", elapsed)
+	}
+}
+
 func TestWebsocketPeerInfo(t *testing.T) {
 	var (
 		s     = newTestServer()
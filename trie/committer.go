@@ -45,6 +45,10 @@ type leaf struct {
 type committer struct {
 	onleaf LeafCallback
 	leafCh chan *leaf
+
+	// nodes, if set, collects the committed nodes by path for the path scheme,
+	// instead of inserting them into the database by hash.
+	nodes map[string]*pathNode
 }
 
 // committers live in a global sync.Pool
@@ -62,6 +66,7 @@ func newCommitter() *committer {
 func returnCommitterToPool(h *committer) {
 	h.onleaf = nil
 	h.leafCh = nil
+	h.nodes = nil
 	committerPool.Put(h)
 }
 
@@ -183,6 +188,11 @@ func (c *committer) store(path []byte, n node, db *Database) node {
 		// The size is used for mem tracking, does not need to be exact
 		size = estimateSize(n)
 	}
+	// Collect the node by path for the path scheme, the leaf callback is still
+	// needed for tracking the storage tries
+	if c.nodes != nil {
+		c.nodes[string(path)] = &pathNode{hash: common.BytesToHash(hash), blob: nodeToBytes(n)}
+	}
 	// If we're using channel-based leaf-reporting, send to channel.
 	// The leaf channel will be active only when there an active leaf-callback
 	if c.leafCh != nil {
@@ -192,7 +202,7 @@ func (c *committer) store(path []byte, n node, db *Database) node {
 			node: n,
 			path: path,
 		}
-	} else if db != nil {
+	} else if db != nil && c.nodes == nil {
 		// No leaf-callback used, but there's still a database. Do serial
 		// insertion
 		db.insert(common.BytesToHash(hash), size, n)
@@ -209,7 +219,9 @@ func (c *committer) commitLoop(db *Database) {
 			n    = item.node
 		)
 		// We are pooling the trie nodes into an intermediate memory cache
-		db.insert(hash, size, n)
+		if c.nodes == nil {
+			db.insert(hash, size, n)
+		}
 
 		if c.onleaf != nil {
 			switch n := n.(type) {
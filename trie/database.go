@@ -88,6 +88,10 @@ type Database struct {
 	childrenSize  common.StorageSize // Storage size of the external children tracking
 	preimagesSize common.StorageSize // Storage size of the preimages cache
 
+	flushHook func(common.Hash) // Callback invoked before a node is written to disk
+
+	path *pathDatabase // Layered trie nodes of the path scheme, nil for the hash scheme
+
 	lock sync.RWMutex
 }
 
@@ -266,11 +270,14 @@ type Config struct {
 	Cache     int    // Memory allowance (MB) to use for caching trie nodes in memory
 	Journal   string // Journal of clean cache to survive node restarts
 	Preimages bool   // Flag whether the preimage of trie key is recorded
+	Scheme    string // Scheme of the persistent state, detected from the database if empty
+	History   uint64 // Number of state transitions the path scheme can revert, zero to keep none
 }
 
 // NewDatabase creates a new trie database to store ephemeral trie content before
 // its written out to disk or garbage collected. No read cache is created, so all
-// data retrievals will hit the underlying disk database.
+// data retrievals will hit the underlying disk database. The hash scheme is used
+// without inspecting the database.
 func NewDatabase(diskdb ethdb.KeyValueStore) *Database {
 	return NewDatabaseWithConfig(diskdb, nil)
 }
@@ -297,9 +304,37 @@ func NewDatabaseWithConfig(diskdb ethdb.KeyValueStore, config *Config) *Database
 	if config == nil || config.Preimages { // TODO(karalabe): Flip to default off in the future
 		db.preimages = make(map[common.Hash][]byte)
 	}
+	if config != nil {
+		scheme := config.Scheme
+		if scheme == "" {
+			scheme = rawdb.ReadStateScheme(diskdb)
+		}
+		if scheme == rawdb.PathScheme {
+			db.path = newPathDatabase(db, config.History)
+		}
+	}
 	return db
 }
 
+// Scheme returns the scheme the state is stored with.
+func (db *Database) Scheme() string {
+	if db.path != nil {
+		return rawdb.PathScheme
+	}
+	return rawdb.HashScheme
+}
+
+// SetFlushHook sets the callback invoked with the hash of every node before it's
+// written out to disk, either by Cap or Commit. A nil hook removes it.
+//
+// The path scheme doesn't write nodes by hash, the hook is never invoked.
+func (db *Database) SetFlushHook(hook func(hash common.Hash)) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.flushHook = hook
+}
+
 // DiskDB retrieves the persistent storage backing the trie database.
 func (db *Database) DiskDB() ethdb.KeyValueStore {
 	return db.diskdb
@@ -370,6 +405,10 @@ func (db *Database) node(hash common.Hash) node {
 			return mustDecodeNode(hash[:], enc)
 		}
 	}
+	// Nodes of the path scheme can't be looked up by hash alone
+	if db.path != nil {
+		return nil
+	}
 	// Retrieve the node from the dirty cache if available
 	db.lock.RLock()
 	dirty := db.dirties[hash]
@@ -397,6 +436,9 @@ func (db *Database) node(hash common.Hash) node {
 
 // Node retrieves an encoded cached trie node from memory. If it cannot be found
 // cached, the method queries the persistent database for the content.
+//
+// The path scheme can only serve the nodes found in the clean cache by hash, use
+// NodeBlob to retrieve the others.
 func (db *Database) Node(hash common.Hash) ([]byte, error) {
 	// It doesn't make sense to retrieve the metaroot
 	if hash == (common.Hash{}) {
@@ -410,6 +452,9 @@ func (db *Database) Node(hash common.Hash) ([]byte, error) {
 			return enc, nil
 		}
 	}
+	if db.path != nil {
+		return nil, errors.New("not found")
+	}
 	// Retrieve the node from the dirty cache if available
 	db.lock.RLock()
 	dirty := db.dirties[hash]
@@ -435,6 +480,41 @@ func (db *Database) Node(hash common.Hash) ([]byte, error) {
 	return nil, errors.New("not found")
 }
 
+// NodeBlob retrieves the encoded trie node with the given hash, stored at the
+// given path of the trie of the given owner. The path is only used by the path
+// scheme, the hash scheme retrieves the node by hash.
+func (db *Database) NodeBlob(owner common.Hash, path []byte, hash common.Hash) ([]byte, error) {
+	if db.path == nil {
+		return db.Node(hash)
+	}
+	if hash == (common.Hash{}) {
+		return nil, errors.New("not found")
+	}
+	if blob := db.pathBlob(owner, path, hash); len(blob) != 0 {
+		return blob, nil
+	}
+	return nil, errors.New("not found")
+}
+
+// pathBlob retrieves the encoded trie node of the path scheme with the given
+// hash and path, from the clean cache, the diff layers or disk.
+func (db *Database) pathBlob(owner common.Hash, path []byte, hash common.Hash) []byte {
+	if db.cleans != nil {
+		if enc := db.cleans.Get(nil, hash[:]); enc != nil {
+			memcacheCleanHitMeter.Mark(1)
+			memcacheCleanReadMeter.Mark(int64(len(enc)))
+			return enc
+		}
+	}
+	enc, disk := db.path.node(owner, path, hash)
+	if disk && db.cleans != nil {
+		db.cleans.Set(hash[:], enc)
+		memcacheCleanMissMeter.Mark(1)
+		memcacheCleanWriteMeter.Mark(int64(len(enc)))
+	}
+	return enc
+}
+
 // preimage retrieves a cached trie node pre-image from memory. If it cannot be
 // found cached, the method queries the persistent database for the content.
 func (db *Database) preimage(hash common.Hash) []byte {
@@ -469,6 +549,22 @@ func (db *Database) Nodes() []common.Hash {
 	return hashes
 }
 
+// Roots retrieves the hashes of the tries referenced by the metaroot, i.e. the
+// state roots kept alive in memory by the user of the database.
+func (db *Database) Roots() []common.Hash {
+	if db.path != nil {
+		return db.path.roots()
+	}
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	var roots []common.Hash
+	for root := range db.dirties[common.Hash{}].children {
+		roots = append(roots, root)
+	}
+	return roots
+}
+
 // Reference adds a new reference from a parent node to a child node.
 // This function is used to add reference between internal trie node
 // and external node(e.g. storage trie root), all internal trie nodes
@@ -502,7 +598,12 @@ func (db *Database) reference(child common.Hash, parent common.Hash) {
 }
 
 // Dereference removes an existing reference from a root node.
+//
+// The path scheme doesn't reference count the nodes, the call is a noop.
 func (db *Database) Dereference(root common.Hash) {
+	if db.path != nil {
+		return
+	}
 	// Sanity check to ensure that the meta-root is not removed
 	if root == (common.Hash{}) {
 		log.Error("Attempted to dereference the trie cache meta root")
@@ -581,7 +682,13 @@ func (db *Database) dereference(child common.Hash, parent common.Hash) {
 //
 // Note, this method is a non-synchronized mutator. It is unsafe to call this
 // concurrently with other mutators.
+//
+// The path scheme bounds its memory usage by the number of diff layers instead,
+// the call is a noop.
 func (db *Database) Cap(limit common.StorageSize) error {
+	if db.path != nil {
+		return nil
+	}
 	// Create a database batch to flush persistent data out. It is important that
 	// outside code doesn't see an inconsistent state (referenced data removed from
 	// memory cache during commit but not yet in persistent storage). This is ensured
@@ -589,6 +696,10 @@ func (db *Database) Cap(limit common.StorageSize) error {
 	nodes, storage, start := len(db.dirties), db.dirtiesSize, time.Now()
 	batch := db.diskdb.NewBatch()
 
+	db.lock.RLock()
+	hook := db.flushHook
+	db.lock.RUnlock()
+
 	// db.dirtiesSize only contains the useful data in the cache, but when reporting
 	// the total memory consumption, the maintenance metadata is also needed to be
 	// counted.
@@ -616,6 +727,9 @@ func (db *Database) Cap(limit common.StorageSize) error {
 	for size > limit && oldest != (common.Hash{}) {
 		// Fetch the oldest referenced node and push into the batch
 		node := db.dirties[oldest]
+		if hook != nil {
+			hook(oldest)
+		}
 		rawdb.WriteTrieNode(batch, oldest, node.rlp())
 
 		// If we exceeded the ideal batch size, commit and reset
@@ -684,7 +798,13 @@ func (db *Database) Cap(limit common.StorageSize) error {
 //
 // Note, this method is a non-synchronized mutator. It is unsafe to call this
 // concurrently with other mutators.
+//
+// With the path scheme, all the diff layers of the state are merged into the
+// persistent state, and the callback is never invoked.
 func (db *Database) Commit(node common.Hash, report bool, callback func(common.Hash)) error {
+	if db.path != nil {
+		return db.path.commit(node, report)
+	}
 	// Create a database batch to flush persistent data out. It is important that
 	// outside code doesn't see an inconsistent state (referenced data removed from
 	// memory cache during commit but not yet in persistent storage). This is ensured
@@ -702,6 +822,22 @@ func (db *Database) Commit(node common.Hash, report bool, callback func(common.H
 		}
 		batch.Reset()
 	}
+	// Report the written nodes to the flush hook too, if one is set
+	db.lock.RLock()
+	hook := db.flushHook
+	db.lock.RUnlock()
+
+	if hook != nil {
+		if callback == nil {
+			callback = hook
+		} else {
+			committed := callback
+			callback = func(hash common.Hash) {
+				hook(hash)
+				committed(hash)
+			}
+		}
+	}
 	// Move the trie itself into the batch, flushing if enough data is accumulated
 	nodes, storage := len(db.dirties), db.dirtiesSize
 
@@ -829,6 +965,13 @@ func (c *cleaner) Delete(key []byte) error {
 // Size returns the current storage size of the memory cache in front of the
 // persistent database layer.
 func (db *Database) Size() (common.StorageSize, common.StorageSize) {
+	if db.path != nil {
+		size := db.path.memory()
+
+		db.lock.RLock()
+		defer db.lock.RUnlock()
+		return size, db.preimagesSize
+	}
 	db.lock.RLock()
 	defer db.lock.RUnlock()
 
@@ -840,6 +983,67 @@ func (db *Database) Size() (common.StorageSize, common.StorageSize) {
 	return db.dirtiesSize + db.childrenSize + metadataSize - metarootRefs, db.preimagesSize
 }
 
+// Update seals the trie nodes committed for the state with the given root into
+// its transition from parent. The nodes are tracked by state, so the commits of
+// different states may be interleaved.
+//
+// Only the path scheme tracks the state transitions, the call is a noop with
+// the hash scheme.
+func (db *Database) Update(root common.Hash, parent common.Hash) error {
+	if db.path == nil {
+		return nil
+	}
+	return db.path.update(root, parent)
+}
+
+// Journal persists the diff layers of the given state, to be restored when the
+// database is reopened. It's a noop with the hash scheme.
+func (db *Database) Journal(root common.Hash) error {
+	if db.path == nil {
+		return nil
+	}
+	return db.path.journal(root)
+}
+
+// Recoverable reports whether the persistent state can be reverted to the state
+// with the given root. The hash scheme can't revert states.
+func (db *Database) Recoverable(root common.Hash) bool {
+	if db.path == nil {
+		return false
+	}
+	return db.path.recoverable(root)
+}
+
+// Recover reverts the persistent state to the state with the given root, which
+// must be Recoverable. All the states held in memory are dropped.
+func (db *Database) Recover(root common.Hash) error {
+	if db.path == nil {
+		return errUnsupportedScheme
+	}
+	return db.path.recover(root)
+}
+
+// Reset wipes the whole state of the path scheme, before committing a new one
+// from scratch, such as the genesis state.
+func (db *Database) Reset() error {
+	if db.path == nil {
+		return errUnsupportedScheme
+	}
+	return db.path.reset()
+}
+
+// flushPreimages moves the accumulated preimages into the batch.
+func (db *Database) flushPreimages(batch ethdb.KeyValueWriter) {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	if len(db.preimages) == 0 {
+		return
+	}
+	rawdb.WritePreimages(batch, db.preimages)
+	db.preimages, db.preimagesSize = make(map[common.Hash][]byte), 0
+}
+
 // saveCache saves clean state cache to given directory path
 // using specified CPU cores.
 func (db *Database) saveCache(dir string, threads int) error {
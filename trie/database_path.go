@@ -0,0 +1,724 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// maxDiffLayers is the number of state transitions kept in memory on top of the
+// persistent state by the path scheme. Older transitions are merged into the
+// persistent state.
+const maxDiffLayers = 128
+
+// pathJournalVersion is the version of the serialized trie node layers, bumped
+// whenever their format changes.
+const pathJournalVersion uint64 = 0
+
+var (
+	pathLayerHitMeter  = metrics.NewRegisteredMeter("trie/pathdb/layer/hit", nil)
+	pathDiskHitMeter   = metrics.NewRegisteredMeter("trie/pathdb/disk/hit", nil)
+	pathDiskMissMeter  = metrics.NewRegisteredMeter("trie/pathdb/disk/miss", nil)
+	pathFlushTimeTimer = metrics.NewRegisteredResettingTimer("trie/pathdb/flush/time", nil)
+	pathFlushSizeMeter = metrics.NewRegisteredMeter("trie/pathdb/flush/size", nil)
+)
+
+var (
+	// errStateUnavailable is returned when building on, committing or reverting
+	// to a state that is neither held in memory nor on disk.
+	errStateUnavailable = errors.New("state not available")
+
+	// errUnsupportedScheme is returned when an operation is not supported by
+	// the scheme of the trie database.
+	errUnsupportedScheme = errors.New("operation not supported by the state scheme")
+)
+
+// pathNode is a trie node held in memory by the path scheme. A nil blob marks a
+// node deleted from the trie.
+type pathNode struct {
+	hash common.Hash
+	blob []byte
+}
+
+// pathNodeSet is a set of trie nodes, keyed by the owner of their trie and by
+// their path in it.
+type pathNodeSet map[common.Hash]map[string]*pathNode
+
+// size returns the memory used by the nodes of the set.
+func (set pathNodeSet) size() common.StorageSize {
+	var size common.StorageSize
+	for owner, nodes := range set {
+		for path, n := range nodes {
+			size += common.StorageSize(len(owner) + len(path) + len(n.hash) + len(n.blob))
+		}
+	}
+	return size
+}
+
+// diffLayer is the set of trie nodes changed by a state transition, stacked on
+// top of the state it was applied to.
+type diffLayer struct {
+	root   common.Hash // Root of the state after the transition
+	parent common.Hash // Root of the state the transition was applied to
+	nodes  pathNodeSet // Trie nodes changed by the transition
+	size   common.StorageSize
+}
+
+// pathIndexEntry is a trie node held by one or more diff layers.
+type pathIndexEntry struct {
+	blob []byte
+	refs int
+}
+
+// pathDatabase maintains the state of the path scheme: a single persistent
+// version of the tries stored by node path, and a tree of in-memory diff layers
+// holding the recent state transitions on top of it.
+//
+// Trie nodes are always requested along with their hash, so any copy of a node
+// found at the requested path, in any layer, is the correct one. This allows
+// serving the nodes from an index over all the layers instead of walking them
+// from a particular state root. Nodes of storage tries left behind by destructed
+// accounts are not wiped for the same reason: they are never requested again.
+//
+// Every transition merged into the persistent state is recorded in a reverse
+// diff, the trie history, allowing the persistent state to be rolled back to
+// one of the recent states.
+type pathDatabase struct {
+	db      *Database // Parent database, for the clean cache and the preimages
+	diskdb  ethdb.KeyValueStore
+	history uint64 // Number of trie histories to keep, zero to keep none
+
+	lock        sync.RWMutex
+	diskRoot    common.Hash                                // Root of the persistent state
+	historyHead uint64                                     // Id of the latest trie history
+	layers      map[common.Hash]*diffLayer                 // Diff layers by state root
+	children    map[common.Hash][]common.Hash              // Roots of the layers built on each state
+	index       map[string]map[common.Hash]*pathIndexEntry // Nodes of all diff layers by owner and path
+	size        common.StorageSize                         // Memory used by the diff layers
+
+	pending     map[common.Hash]pathNodeSet // Nodes committed by the tries, not yet sealed into a layer, by state root
+	pendingLock sync.Mutex
+}
+
+// newPathDatabase creates the path scheme database on top of the persistent
+// state, restoring the diff layers journaled at the last shutdown.
+func newPathDatabase(db *Database, history uint64) *pathDatabase {
+	p := &pathDatabase{
+		db:          db,
+		diskdb:      db.diskdb,
+		history:     history,
+		diskRoot:    emptyRoot,
+		historyHead: rawdb.ReadTrieHistoryHead(db.diskdb),
+		pending:     make(map[common.Hash]pathNodeSet),
+	}
+	if blob := rawdb.ReadAccountTrieNode(db.diskdb, nil); len(blob) > 0 {
+		p.diskRoot = crypto.Keccak256Hash(blob)
+	}
+	p.resetLayers()
+	if err := p.loadJournal(); err != nil {
+		log.Warn("Failed to load trie journal", "err", err)
+	}
+	return p
+}
+
+// resetLayers drops all the diff layers.
+func (p *pathDatabase) resetLayers() {
+	p.layers = make(map[common.Hash]*diffLayer)
+	p.children = make(map[common.Hash][]common.Hash)
+	p.index = make(map[string]map[common.Hash]*pathIndexEntry)
+	p.size = 0
+}
+
+// pathIndexKey returns the key of the given trie node in the layer index.
+func pathIndexKey(owner common.Hash, path string) string {
+	return string(owner[:]) + path
+}
+
+// readPathNode reads the trie node stored at the given path on disk.
+func readPathNode(db ethdb.KeyValueReader, owner common.Hash, path []byte) []byte {
+	if owner == (common.Hash{}) {
+		return rawdb.ReadAccountTrieNode(db, path)
+	}
+	return rawdb.ReadStorageTrieNode(db, owner, path)
+}
+
+// writePathNode stores the trie node at the given path on disk, deleting it if
+// the blob is empty.
+func writePathNode(db ethdb.KeyValueWriter, owner common.Hash, path []byte, blob []byte) {
+	switch {
+	case owner == (common.Hash{}) && len(blob) == 0:
+		rawdb.DeleteAccountTrieNode(db, path)
+	case owner == (common.Hash{}):
+		rawdb.WriteAccountTrieNode(db, path, blob)
+	case len(blob) == 0:
+		rawdb.DeleteStorageTrieNode(db, owner, path)
+	default:
+		rawdb.WriteStorageTrieNode(db, owner, path, blob)
+	}
+}
+
+// node retrieves the trie node with the given hash at the given path, either
+// from the diff layers or from disk. It returns nil if the node is unavailable,
+// along with whether it was loaded from disk.
+func (p *pathDatabase) node(owner common.Hash, path []byte, hash common.Hash) ([]byte, bool) {
+	p.lock.RLock()
+	if entry := p.index[pathIndexKey(owner, string(path))][hash]; entry != nil {
+		p.lock.RUnlock()
+		pathLayerHitMeter.Mark(1)
+		return entry.blob, false
+	}
+	p.lock.RUnlock()
+
+	// The disk holds a single version of each node, make sure it's the requested one
+	blob := readPathNode(p.diskdb, owner, path)
+	if len(blob) == 0 || crypto.Keccak256Hash(blob) != hash {
+		pathDiskMissMeter.Mark(1)
+		return nil, false
+	}
+	pathDiskHitMeter.Mark(1)
+	return blob, true
+}
+
+// stage tracks the nodes committed by a trie, to be sealed into the diff layer
+// of the state with the given root by update. Keeping the nodes apart by state
+// allows the commits of different states to be interleaved.
+func (p *pathDatabase) stage(root common.Hash, owner common.Hash, nodes map[string]*pathNode) {
+	p.pendingLock.Lock()
+	defer p.pendingLock.Unlock()
+
+	set := p.pending[root]
+	if set == nil {
+		set = make(pathNodeSet)
+		p.pending[root] = set
+	}
+	if set[owner] == nil {
+		set[owner] = nodes
+		return
+	}
+	for path, n := range nodes {
+		set[owner][path] = n
+	}
+}
+
+// available reports whether the state with the given root is held by a diff
+// layer or on disk.
+//
+// Note, this method assumes that the lock is held!
+func (p *pathDatabase) available(root common.Hash) bool {
+	return root == p.diskRoot || p.layers[root] != nil
+}
+
+// update seals the trie nodes committed for the given state into a new diff
+// layer, transitioning the state from parent to root. The oldest layers of the
+// state are merged into the persistent state if more than maxDiffLayers exist.
+func (p *pathDatabase) update(root common.Hash, parent common.Hash) error {
+	p.pendingLock.Lock()
+	nodes := p.pending[root]
+	delete(p.pending, root)
+	p.pendingLock.Unlock()
+
+	if nodes == nil {
+		nodes = make(pathNodeSet)
+	}
+	if parent == (common.Hash{}) {
+		parent = emptyRoot
+	}
+	if root == parent {
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// Building a state already known is a noop, e.g. a block imported again
+	if p.available(root) {
+		return nil
+	}
+	if !p.available(parent) {
+		return fmt.Errorf("%w: parent %x", errStateUnavailable, parent)
+	}
+	p.addLayer(&diffLayer{root: root, parent: parent, nodes: nodes, size: nodes.size()})
+	return p.capLayers(root, maxDiffLayers)
+}
+
+// addLayer links a new diff layer into the tree and the node index.
+//
+// Note, this method assumes that the lock is held!
+func (p *pathDatabase) addLayer(layer *diffLayer) {
+	p.layers[layer.root] = layer
+	p.children[layer.parent] = append(p.children[layer.parent], layer.root)
+
+	for owner, nodes := range layer.nodes {
+		for path, n := range nodes {
+			if n.blob == nil {
+				continue
+			}
+			key := pathIndexKey(owner, path)
+			if p.index[key] == nil {
+				p.index[key] = make(map[common.Hash]*pathIndexEntry)
+			}
+			if entry := p.index[key][n.hash]; entry != nil {
+				entry.refs++
+			} else {
+				p.index[key][n.hash] = &pathIndexEntry{blob: n.blob, refs: 1}
+			}
+		}
+	}
+	p.size += layer.size
+}
+
+// removeLayer unlinks a diff layer from the tree and the node index.
+//
+// Note, this method assumes that the lock is held!
+func (p *pathDatabase) removeLayer(layer *diffLayer) {
+	delete(p.layers, layer.root)
+
+	for owner, nodes := range layer.nodes {
+		for path, n := range nodes {
+			if n.blob == nil {
+				continue
+			}
+			key := pathIndexKey(owner, path)
+			if entry := p.index[key][n.hash]; entry != nil {
+				if entry.refs--; entry.refs == 0 {
+					delete(p.index[key], n.hash)
+				}
+				if len(p.index[key]) == 0 {
+					delete(p.index, key)
+				}
+			}
+		}
+	}
+	p.size -= layer.size
+}
+
+// dropLayer removes a diff layer along with all the layers built on top of it.
+//
+// Note, this method assumes that the lock is held!
+func (p *pathDatabase) dropLayer(root common.Hash) {
+	for _, child := range p.children[root] {
+		p.dropLayer(child)
+	}
+	delete(p.children, root)
+
+	if layer := p.layers[root]; layer != nil {
+		p.removeLayer(layer)
+	}
+}
+
+// chain returns the diff layers from the given state down to the persistent one.
+//
+// Note, this method assumes that the lock is held!
+func (p *pathDatabase) chain(root common.Hash) ([]*diffLayer, error) {
+	var layers []*diffLayer
+	for root != p.diskRoot {
+		layer := p.layers[root]
+		if layer == nil {
+			return nil, fmt.Errorf("%w: %x", errStateUnavailable, root)
+		}
+		layers = append(layers, layer)
+		root = layer.parent
+	}
+	return layers, nil
+}
+
+// capLayers merges the oldest diff layers of the given state into the persistent
+// state, until at most the given number of layers remain.
+//
+// Note, this method assumes that the lock is held!
+func (p *pathDatabase) capLayers(root common.Hash, layers int) error {
+	chain, err := p.chain(root)
+	if err != nil {
+		return err
+	}
+	for len(chain) > layers {
+		if err := p.flatten(chain[len(chain)-1]); err != nil {
+			return err
+		}
+		chain = chain[:len(chain)-1]
+	}
+	return nil
+}
+
+// flatten merges the bottom diff layer into the persistent state, recording the
+// nodes it overwrites into a trie history. The other layers built on the old
+// persistent state can't be reached anymore and are dropped.
+//
+// Note, this method assumes that the lock is held!
+func (p *pathDatabase) flatten(layer *diffLayer) error {
+	if layer.parent != p.diskRoot {
+		return fmt.Errorf("layer %x is not on top of the persistent state %x", layer.root, p.diskRoot)
+	}
+	var (
+		start   = time.Now()
+		batch   = p.diskdb.NewBatch()
+		history = &trieHistory{Parent: layer.parent, Root: layer.root}
+	)
+	for owner, nodes := range layer.nodes {
+		for path, n := range nodes {
+			prev := readPathNode(p.diskdb, owner, []byte(path))
+			if len(prev) == 0 && n.blob == nil {
+				continue
+			}
+			history.Nodes = append(history.Nodes, trieHistoryNode{Owner: owner, Path: []byte(path), Blob: prev})
+			writePathNode(batch, owner, []byte(path), n.blob)
+		}
+	}
+	if p.history > 0 {
+		if err := p.writeHistory(batch, p.historyHead+1, history); err != nil {
+			return err
+		}
+	}
+	p.db.flushPreimages(batch)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if p.history > 0 {
+		p.historyHead++
+	}
+	pathFlushTimeTimer.Update(time.Since(start))
+	pathFlushSizeMeter.Mark(int64(batch.ValueSize()))
+
+	// Move the persistent state up, dropping the layers left on the old one
+	for _, child := range p.children[layer.parent] {
+		if child != layer.root {
+			p.dropLayer(child)
+		}
+	}
+	delete(p.children, layer.parent)
+	p.removeLayer(layer)
+	p.diskRoot = layer.root
+
+	log.Debug("Persisted trie layer", "root", layer.root, "nodes", len(history.Nodes), "size", layer.size, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// commit merges all the diff layers of the given state into the persistent one.
+func (p *pathDatabase) commit(root common.Hash, report bool) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	start := time.Now()
+	chain, err := p.chain(root)
+	if err != nil {
+		return err
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if err := p.flatten(chain[i]); err != nil {
+			return err
+		}
+	}
+	// Flush the preimages even if the state was already persisted
+	batch := p.diskdb.NewBatch()
+	p.db.flushPreimages(batch)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	logger := log.Info
+	if !report {
+		logger = log.Debug
+	}
+	logger("Persisted trie layers", "root", root, "layers", len(chain), "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// roots returns the state roots held by the diff layers.
+func (p *pathDatabase) roots() []common.Hash {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	roots := make([]common.Hash, 0, len(p.layers))
+	for root := range p.layers {
+		roots = append(roots, root)
+	}
+	return roots
+}
+
+// memory returns the memory used by the diff layers.
+func (p *pathDatabase) memory() common.StorageSize {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.size
+}
+
+// journalNode is a trie node of a journaled diff layer, with an empty blob for
+// a deleted node.
+type journalNode struct {
+	Owner common.Hash
+	Path  []byte
+	Hash  common.Hash
+	Blob  []byte
+}
+
+// journalLayer is a journaled diff layer.
+type journalLayer struct {
+	Root   common.Hash
+	Parent common.Hash
+	Nodes  []journalNode
+}
+
+// pathJournal is the serialized set of diff layers of a state, from the bottom
+// one up, along with the persistent state they were built on.
+type pathJournal struct {
+	Version  uint64
+	DiskRoot common.Hash
+	Layers   []journalLayer
+}
+
+// journal persists the diff layers of the given state, so they can be restored
+// after a restart.
+func (p *pathDatabase) journal(root common.Hash) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	chain, err := p.chain(root)
+	if err != nil {
+		return err
+	}
+	journal := &pathJournal{Version: pathJournalVersion, DiskRoot: p.diskRoot}
+	for i := len(chain) - 1; i >= 0; i-- {
+		layer := journalLayer{Root: chain[i].root, Parent: chain[i].parent}
+		for owner, nodes := range chain[i].nodes {
+			for path, n := range nodes {
+				layer.Nodes = append(layer.Nodes, journalNode{Owner: owner, Path: []byte(path), Hash: n.hash, Blob: n.blob})
+			}
+		}
+		journal.Layers = append(journal.Layers, layer)
+	}
+	blob, err := rlp.EncodeToBytes(journal)
+	if err != nil {
+		return err
+	}
+	batch := p.diskdb.NewBatch()
+	rawdb.WriteTrieJournal(batch, blob)
+	p.db.flushPreimages(batch)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	log.Info("Persisted trie journal", "root", root, "layers", len(chain), "size", common.StorageSize(len(blob)))
+	return nil
+}
+
+// loadJournal restores the diff layers journaled at the last shutdown, if they
+// were built on the current persistent state.
+func (p *pathDatabase) loadJournal() error {
+	blob := rawdb.ReadTrieJournal(p.diskdb)
+	if len(blob) == 0 {
+		return nil
+	}
+	var journal pathJournal
+	if err := rlp.DecodeBytes(blob, &journal); err != nil {
+		return err
+	}
+	if journal.Version != pathJournalVersion {
+		return fmt.Errorf("unsupported journal version %d", journal.Version)
+	}
+	if journal.DiskRoot != p.diskRoot {
+		log.Info("Discarding stale trie journal", "root", journal.DiskRoot, "persistent", p.diskRoot)
+		return nil
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, entry := range journal.Layers {
+		if !p.available(entry.Parent) {
+			return fmt.Errorf("%w: parent %x", errStateUnavailable, entry.Parent)
+		}
+		nodes := make(pathNodeSet)
+		for _, n := range entry.Nodes {
+			if nodes[n.Owner] == nil {
+				nodes[n.Owner] = make(map[string]*pathNode)
+			}
+			node := &pathNode{hash: n.Hash}
+			if len(n.Blob) > 0 {
+				node.blob = n.Blob
+			}
+			nodes[n.Owner][string(n.Path)] = node
+		}
+		p.addLayer(&diffLayer{root: entry.Root, parent: entry.Parent, nodes: nodes, size: nodes.size()})
+	}
+	log.Info("Loaded trie journal", "layers", len(journal.Layers), "size", p.size)
+	return nil
+}
+
+// trieHistoryNode is a trie node overwritten by a state transition, with an
+// empty blob if the node didn't exist before.
+type trieHistoryNode struct {
+	Owner common.Hash
+	Path  []byte
+	Blob  []byte
+}
+
+// trieHistory is the reverse diff of a state transition merged into the
+// persistent state.
+type trieHistory struct {
+	Parent common.Hash // Root of the state the history reverts to
+	Root   common.Hash // Root of the state the history applies to
+	Nodes  []trieHistoryNode
+}
+
+// readHistoryParent decodes the parent root of a trie history, skipping its
+// nodes.
+func readHistoryParent(blob []byte) (common.Hash, error) {
+	var parent common.Hash
+
+	stream := rlp.NewStream(bytes.NewReader(blob), uint64(len(blob)))
+	if _, err := stream.List(); err != nil {
+		return parent, err
+	}
+	err := stream.Decode(&parent)
+	return parent, err
+}
+
+// writeHistory adds the trie history with the given id into the batch, pruning
+// the histories falling out of the retained range.
+//
+// Note, this method assumes that the lock is held!
+func (p *pathDatabase) writeHistory(batch ethdb.Batch, id uint64, history *trieHistory) error {
+	blob, err := rlp.EncodeToBytes(history)
+	if err != nil {
+		return err
+	}
+	rawdb.WriteTrieHistory(batch, id, blob)
+	rawdb.WriteTrieHistoryID(batch, history.Parent, id)
+	rawdb.WriteTrieHistoryHead(batch, id)
+
+	// Prune the histories out of the retained range, more than one if it shrunk
+	for old := int64(id) - int64(p.history); old > 0; old-- {
+		blob := rawdb.ReadTrieHistory(p.diskdb, uint64(old))
+		if len(blob) == 0 {
+			break
+		}
+		rawdb.DeleteTrieHistory(batch, uint64(old))
+		if parent, err := readHistoryParent(blob); err == nil {
+			if indexed, ok := rawdb.ReadTrieHistoryID(p.diskdb, parent); ok && indexed == uint64(old) {
+				rawdb.DeleteTrieHistoryID(batch, parent)
+			}
+		}
+	}
+	return nil
+}
+
+// recoverable reports whether the persistent state can be reverted to the state
+// with the given root, using the trie histories.
+func (p *pathDatabase) recoverable(root common.Hash) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if root == p.diskRoot {
+		return false
+	}
+	id, ok := rawdb.ReadTrieHistoryID(p.diskdb, root)
+	if !ok || id > p.historyHead {
+		return false
+	}
+	// Histories are pruned from the oldest, the newer ones are all available
+	return len(rawdb.ReadTrieHistory(p.diskdb, id)) > 0
+}
+
+// recover reverts the persistent state to the one with the given root, applying
+// the trie histories recorded since. All the diff layers are dropped.
+func (p *pathDatabase) recover(root common.Hash) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if root == p.diskRoot {
+		return nil
+	}
+	id, ok := rawdb.ReadTrieHistoryID(p.diskdb, root)
+	if !ok || id > p.historyHead {
+		return fmt.Errorf("%w: %x not recoverable", errStateUnavailable, root)
+	}
+	p.resetLayers()
+
+	start := time.Now()
+	for p.diskRoot != root {
+		blob := rawdb.ReadTrieHistory(p.diskdb, p.historyHead)
+		if len(blob) == 0 {
+			return fmt.Errorf("trie history %d missing", p.historyHead)
+		}
+		var history trieHistory
+		if err := rlp.DecodeBytes(blob, &history); err != nil {
+			return err
+		}
+		if history.Root != p.diskRoot {
+			return fmt.Errorf("trie history %d of state %x, persistent state %x", p.historyHead, history.Root, p.diskRoot)
+		}
+		batch := p.diskdb.NewBatch()
+		for _, n := range history.Nodes {
+			writePathNode(batch, n.Owner, n.Path, n.Blob)
+		}
+		rawdb.DeleteTrieHistory(batch, p.historyHead)
+		if indexed, ok := rawdb.ReadTrieHistoryID(p.diskdb, history.Parent); ok && indexed == p.historyHead {
+			rawdb.DeleteTrieHistoryID(batch, history.Parent)
+		}
+		rawdb.WriteTrieHistoryHead(batch, p.historyHead-1)
+		if err := batch.Write(); err != nil {
+			return err
+		}
+		p.historyHead--
+		p.diskRoot = history.Parent
+	}
+	log.Info("Reverted persistent state", "root", root, "elapsed", common.PrettyDuration(time.Since(start)))
+	return nil
+}
+
+// reset wipes the state entirely, along with its histories and journal.
+func (p *pathDatabase) reset() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.pendingLock.Lock()
+	p.pending = make(map[common.Hash]pathNodeSet)
+	p.pendingLock.Unlock()
+
+	p.resetLayers()
+
+	batch := p.diskdb.NewBatch()
+	for id := p.historyHead; id > 0; id-- {
+		blob := rawdb.ReadTrieHistory(p.diskdb, id)
+		if len(blob) == 0 {
+			break
+		}
+		rawdb.DeleteTrieHistory(batch, id)
+		if parent, err := readHistoryParent(blob); err == nil {
+			rawdb.DeleteTrieHistoryID(batch, parent)
+		}
+	}
+	rawdb.WriteTrieHistoryHead(batch, 0)
+	rawdb.DeleteTrieJournal(batch)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if err := rawdb.DeletePathTrieNodes(p.diskdb); err != nil {
+		return err
+	}
+	p.diskRoot, p.historyHead = emptyRoot, 0
+	return nil
+}
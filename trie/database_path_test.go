@@ -0,0 +1,369 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package trie
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+)
+
+func newPathTestDatabase(diskdb ethdb.KeyValueStore, history uint64) *Database {
+	return NewDatabaseWithConfig(diskdb, &Config{Scheme: rawdb.PathScheme, History: history})
+}
+
+// pathTestState is the content of a trie, an empty value marks a deleted key.
+type pathTestState map[string]string
+
+// commitPathState applies the changes on top of the parent state and seals the
+// transition into a diff layer.
+func commitPathState(t *testing.T, db *Database, parent common.Hash, changes pathTestState) common.Hash {
+	t.Helper()
+
+	tr, err := New(common.Hash{}, parent, db)
+	if err != nil {
+		t.Fatalf("failed to open trie %x: %v", parent, err)
+	}
+	for k, v := range changes {
+		if v == "" {
+			if err := tr.TryDelete([]byte(k)); err != nil {
+				t.Fatalf("failed to delete %q: %v", k, err)
+			}
+		} else {
+			if err := tr.TryUpdate([]byte(k), []byte(v)); err != nil {
+				t.Fatalf("failed to update %q: %v", k, err)
+			}
+		}
+	}
+	root, _, err := tr.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	if err := db.Update(root, parent); err != nil {
+		t.Fatalf("failed to update state %x: %v", root, err)
+	}
+	return root
+}
+
+// checkPathState checks that the state of the given root has the expected content.
+func checkPathState(t *testing.T, db *Database, root common.Hash, want pathTestState) {
+	t.Helper()
+
+	tr, err := New(common.Hash{}, root, db)
+	if err != nil {
+		t.Fatalf("failed to open trie %x: %v", root, err)
+	}
+	for k, v := range want {
+		have, err := tr.TryGet([]byte(k))
+		if err != nil {
+			t.Fatalf("state %x: failed to read %q: %v", root, k, err)
+		}
+		if string(have) != v {
+			t.Fatalf("state %x: value mismatch for %q: have %q, want %q", root, k, have, v)
+		}
+	}
+}
+
+// makePathStates builds a chain of states on top of the empty one, returning
+// their roots along with their full content.
+func makePathStates(t *testing.T, db *Database, n int) ([]common.Hash, []pathTestState) {
+	var (
+		roots  []common.Hash
+		states []pathTestState
+		parent = emptyRoot
+		state  = make(pathTestState)
+	)
+	for i := 0; i < n; i++ {
+		changes := pathTestState{
+			fmt.Sprintf("key-%d", i%16): fmt.Sprintf("value-%d", i),
+		}
+		if i > 4 {
+			changes[fmt.Sprintf("key-%d", (i-4)%16)] = ""
+		}
+		parent = commitPathState(t, db, parent, changes)
+
+		next := make(pathTestState)
+		for k, v := range state {
+			next[k] = v
+		}
+		for k, v := range changes {
+			next[k] = v
+		}
+		roots, states, state = append(roots, parent), append(states, next), next
+	}
+	return roots, states
+}
+
+// Tests that all the states held in the diff layers are readable, including the
+// ones of forks built on an older state.
+func TestPathDatabaseLayers(t *testing.T) {
+	db := newPathTestDatabase(memorydb.New(), 0)
+	roots, states := makePathStates(t, db, 32)
+
+	fork := commitPathState(t, db, roots[10], pathTestState{"key-3": "forked"})
+	for i, root := range roots {
+		checkPathState(t, db, root, states[i])
+	}
+	want := make(pathTestState)
+	for k, v := range states[10] {
+		want[k] = v
+	}
+	want["key-3"] = "forked"
+	checkPathState(t, db, fork, want)
+
+	if err := db.Update(common.Hash{0x1}, common.Hash{0x2}); err == nil {
+		t.Fatal("state built on an unknown parent accepted")
+	}
+}
+
+// Tests that the oldest diff layers are merged into the persistent state once
+// too many are accumulated, dropping the forks left behind.
+func TestPathDatabaseCap(t *testing.T) {
+	diskdb := memorydb.New()
+	db := newPathTestDatabase(diskdb, 0)
+	roots, states := makePathStates(t, db, maxDiffLayers+10)
+
+	// The fork is built before the layers it's based on get flattened
+	fork := commitPathState(t, db, roots[20], pathTestState{"key-3": "forked"})
+	roots = append(roots, commitPathState(t, db, roots[len(roots)-1], pathTestState{"key-1": "last"}))
+	for i := 0; i < 20; i++ {
+		roots = append(roots, commitPathState(t, db, roots[len(roots)-1], pathTestState{"key-2": fmt.Sprintf("last-%d", i)}))
+	}
+	if have := len(db.Roots()); have != maxDiffLayers {
+		t.Fatalf("diff layer count mismatch: have %d, want %d", have, maxDiffLayers)
+	}
+	for _, root := range db.Roots() {
+		if root == fork {
+			t.Fatal("fork of a flattened state retained")
+		}
+	}
+	disk := len(roots) - 1 - maxDiffLayers
+	if db.path.diskRoot != roots[disk] {
+		t.Fatalf("persistent state mismatch: have %x, want %x", db.path.diskRoot, roots[disk])
+	}
+	for i := disk; i < len(states); i++ {
+		checkPathState(t, db, roots[i], states[i])
+	}
+	// The persistent state must be readable without the diff layers
+	checkPathState(t, newPathTestDatabase(diskdb, 0), roots[disk], states[disk])
+}
+
+// Tests that the diff layers survive a restart through the journal.
+func TestPathDatabaseJournal(t *testing.T) {
+	diskdb := memorydb.New()
+	db := newPathTestDatabase(diskdb, 0)
+	roots, states := makePathStates(t, db, 16)
+
+	if err := db.Commit(roots[4], false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := db.Journal(roots[len(roots)-1]); err != nil {
+		t.Fatalf("failed to journal state: %v", err)
+	}
+	db = newPathTestDatabase(diskdb, 0)
+	if have, want := len(db.Roots()), len(roots)-5; have != want {
+		t.Fatalf("diff layer count mismatch: have %d, want %d", have, want)
+	}
+	for i := 4; i < len(roots); i++ {
+		checkPathState(t, db, roots[i], states[i])
+	}
+	// The journal is stale once the persistent state moved on, it must be
+	// discarded rather than stacked on the wrong state
+	if err := db.Commit(roots[len(roots)-1], false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	db = newPathTestDatabase(diskdb, 0)
+	if have := len(db.Roots()); have != 0 {
+		t.Fatalf("stale journal loaded: %d diff layers", have)
+	}
+	checkPathState(t, db, roots[len(roots)-1], states[len(roots)-1])
+}
+
+// Tests that states can be built on a reopened database, and survive being
+// reopened again.
+func TestPathDatabaseReopen(t *testing.T) {
+	diskdb := memorydb.New()
+	db := newPathTestDatabase(diskdb, 0)
+	roots, states := makePathStates(t, db, 16)
+
+	if err := db.Journal(roots[len(roots)-1]); err != nil {
+		t.Fatalf("failed to journal state: %v", err)
+	}
+	db = newPathTestDatabase(diskdb, 0)
+	root := commitPathState(t, db, roots[len(roots)-1], pathTestState{"key-1": "reopened"})
+
+	want := make(pathTestState)
+	for k, v := range states[len(states)-1] {
+		want[k] = v
+	}
+	want["key-1"] = "reopened"
+	checkPathState(t, db, root, want)
+
+	// Journal the new state, then persist it, reopening in between
+	if err := db.Journal(root); err != nil {
+		t.Fatalf("failed to journal state: %v", err)
+	}
+	db = newPathTestDatabase(diskdb, 0)
+	if have, want := len(db.Roots()), len(roots)+1; have != want {
+		t.Fatalf("diff layer count mismatch: have %d, want %d", have, want)
+	}
+	if err := db.Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	checkPathState(t, newPathTestDatabase(diskdb, 0), root, want)
+}
+
+// Tests that the commits of different states can be interleaved, each state
+// only receiving the nodes committed for it, including those of the storage
+// tries committed before the account trie.
+func TestPathDatabaseInterleaved(t *testing.T) {
+	var (
+		diskdb = memorydb.New()
+		db     = newPathTestDatabase(diskdb, 0)
+		parent = commitPathState(t, db, emptyRoot, pathTestState{"key": "parent"})
+		owner  = common.Hash{0x1}
+	)
+	open := func(owner, root common.Hash, key, value string) *Trie {
+		tr, err := New(owner, root, db)
+		if err != nil {
+			t.Fatalf("failed to open trie %x: %v", root, err)
+		}
+		if err := tr.TryUpdate([]byte(key), []byte(value)); err != nil {
+			t.Fatalf("failed to update %q: %v", key, err)
+		}
+		return tr
+	}
+	var (
+		accountsA, accountsB = open(common.Hash{}, parent, "key", "a"), open(common.Hash{}, parent, "key", "b")
+		storageA, storageB   = open(owner, emptyRoot, "slot", "a"), open(owner, emptyRoot, "slot", "b")
+		rootA, rootB         = accountsA.Hash(), accountsB.Hash()
+	)
+	// Commit the tries of both states before sealing either of them
+	slotsA, _, err := storageA.CommitState(rootA, nil)
+	if err != nil {
+		t.Fatalf("failed to commit storage: %v", err)
+	}
+	slotsB, _, err := storageB.CommitState(rootB, nil)
+	if err != nil {
+		t.Fatalf("failed to commit storage: %v", err)
+	}
+	for _, tr := range []*Trie{accountsA, accountsB} {
+		if _, _, err := tr.Commit(nil); err != nil {
+			t.Fatalf("failed to commit trie: %v", err)
+		}
+	}
+	if err := db.Update(rootB, parent); err != nil {
+		t.Fatalf("failed to update state: %v", err)
+	}
+	if err := db.Update(rootA, parent); err != nil {
+		t.Fatalf("failed to update state: %v", err)
+	}
+	checkPathState(t, db, rootB, pathTestState{"key": "b"})
+
+	// Persist one of the states, dropping the other, all its nodes must be there
+	if err := db.Commit(rootA, false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	db = newPathTestDatabase(diskdb, 0)
+	checkPathState(t, db, rootA, pathTestState{"key": "a"})
+
+	storage, err := New(owner, slotsA, db)
+	if err != nil {
+		t.Fatalf("failed to open storage %x: %v", slotsA, err)
+	}
+	if have, err := storage.TryGet([]byte("slot")); err != nil || string(have) != "a" {
+		t.Fatalf("storage slot mismatch: have %q, %v, want %q", have, err, "a")
+	}
+	if _, err := New(owner, slotsB, db); err == nil {
+		t.Fatal("storage of the dropped state persisted")
+	}
+}
+
+// Tests that the persistent state can be reverted to an older one through the
+// trie histories, as long as they are retained.
+func TestPathDatabaseRecover(t *testing.T) {
+	diskdb := memorydb.New()
+	db := newPathTestDatabase(diskdb, 8)
+	roots, states := makePathStates(t, db, 16)
+
+	if err := db.Commit(roots[len(roots)-1], false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if db.Recoverable(roots[len(roots)-1]) {
+		t.Fatal("persistent state reported recoverable")
+	}
+	if db.Recoverable(roots[4]) {
+		t.Fatal("state with pruned history reported recoverable")
+	}
+	for _, i := range []int{12, 7} {
+		if !db.Recoverable(roots[i]) {
+			t.Fatalf("state %d not recoverable", i)
+		}
+		if err := db.Recover(roots[i]); err != nil {
+			t.Fatalf("failed to recover state %d: %v", i, err)
+		}
+		checkPathState(t, newPathTestDatabase(diskdb, 8), roots[i], states[i])
+	}
+	// New states must be buildable on top of the recovered one
+	root := commitPathState(t, db, roots[7], pathTestState{"key-1": "recovered"})
+	if err := db.Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	want := make(pathTestState)
+	for k, v := range states[7] {
+		want[k] = v
+	}
+	want["key-1"] = "recovered"
+	checkPathState(t, newPathTestDatabase(diskdb, 8), root, want)
+}
+
+// Tests that resetting wipes the whole state, so a new one can be committed
+// from scratch.
+func TestPathDatabaseReset(t *testing.T) {
+	diskdb := memorydb.New()
+	db := newPathTestDatabase(diskdb, 8)
+	roots, _ := makePathStates(t, db, 16)
+
+	if err := db.Commit(roots[8], false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := db.Reset(); err != nil {
+		t.Fatalf("failed to reset state: %v", err)
+	}
+	if have := len(db.Roots()); have != 0 {
+		t.Fatalf("diff layers retained: %d", have)
+	}
+	if db.Recoverable(roots[4]) {
+		t.Fatal("state recoverable after reset")
+	}
+	it := diskdb.NewIterator(nil, nil)
+	defer it.Release()
+	for it.Next() {
+		if rawdb.IsTrieNodePathKey(it.Key()) {
+			t.Fatalf("trie node %x retained", it.Key())
+		}
+	}
+	root := commitPathState(t, db, emptyRoot, pathTestState{"key": "value"})
+	if err := db.Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	checkPathState(t, newPathTestDatabase(diskdb, 8), root, pathTestState{"key": "value"})
+}
@@ -31,3 +31,58 @@ func TestDatabaseMetarootFetch(t *testing.T) {
 		t.Fatalf("metaroot retrieval succeeded")
 	}
 }
+
+// Tests that the flush hook is invoked for every trie node persisted by the
+// trie database.
+func TestDatabaseFlushHook(t *testing.T) {
+	db := NewDatabase(memorydb.New())
+	trie := NewEmpty(db)
+	for i := byte(0); i < 32; i++ {
+		trie.Update([]byte{i, i}, common.CopyBytes(make([]byte, 32)))
+	}
+	root, nodes, err := trie.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit trie: %v", err)
+	}
+	flushed := make(map[common.Hash]struct{})
+	db.SetFlushHook(func(hash common.Hash) { flushed[hash] = struct{}{} })
+	if err := db.Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to flush trie: %v", err)
+	}
+	if len(flushed) != nodes {
+		t.Fatalf("flushed node count mismatch: have %d, want %d", len(flushed), nodes)
+	}
+	if _, ok := flushed[root]; !ok {
+		t.Fatalf("root not reported to the flush hook")
+	}
+}
+
+// Tests that the roots referenced by the metaroot are reported, until they are
+// dereferenced.
+func TestDatabaseRoots(t *testing.T) {
+	db := NewDatabase(memorydb.New())
+	var roots []common.Hash
+	for i := byte(0); i < 3; i++ {
+		trie := NewEmpty(db)
+		trie.Update([]byte{i}, []byte{i})
+		root, _, err := trie.Commit(nil)
+		if err != nil {
+			t.Fatalf("failed to commit trie: %v", err)
+		}
+		db.Reference(root, common.Hash{})
+		roots = append(roots, root)
+	}
+	if have := db.Roots(); len(have) != 3 {
+		t.Fatalf("root count mismatch: have %d, want 3", len(have))
+	}
+	db.Dereference(roots[0])
+	have := db.Roots()
+	if len(have) != 2 {
+		t.Fatalf("root count mismatch after dereference: have %d, want 2", len(have))
+	}
+	for _, root := range have {
+		if root == roots[0] {
+			t.Fatalf("dereferenced root reported")
+		}
+	}
+}
@@ -162,6 +162,12 @@ func (t *SecureTrie) GetKey(shaKey []byte) []byte {
 // Committing flushes nodes from memory. Subsequent Get calls will load nodes
 // from the database.
 func (t *SecureTrie) Commit(onleaf LeafCallback) (common.Hash, int, error) {
+	return t.CommitState(common.Hash{}, onleaf)
+}
+
+// CommitState is like Commit, but stages the nodes of the path scheme into the
+// transition to the given state root.
+func (t *SecureTrie) CommitState(state common.Hash, onleaf LeafCallback) (common.Hash, int, error) {
 	// Write all the pre-images to the actual disk database
 	if len(t.getSecKeyCache()) > 0 {
 		if t.trie.db.preimages != nil { // Ugly direct check but avoids the below write lock
@@ -174,7 +180,7 @@ func (t *SecureTrie) Commit(onleaf LeafCallback) (common.Hash, int, error) {
 		t.secKeyCache = make(map[string][]byte)
 	}
 	// Commit the trie to its intermediate node database
-	return t.trie.Commit(onleaf)
+	return t.trie.CommitState(state, onleaf)
 }
 
 // Hash returns the root hash of SecureTrie. It does not write to the
@@ -191,6 +197,12 @@ func (t *SecureTrie) Copy() *SecureTrie {
 	}
 }
 
+// SetRecorder installs a callback invoked with every trie node subsequently
+// loaded from the database.
+func (t *SecureTrie) SetRecorder(recorder func(hash common.Hash, blob []byte)) {
+	t.trie.SetRecorder(recorder)
+}
+
 // NodeIterator returns an iterator that returns nodes of the underlying trie. Iteration
 // starts at the key after the given start key.
 func (t *SecureTrie) NodeIterator(start []byte) NodeIterator {
@@ -73,6 +73,10 @@ type Trie struct {
 	// tracer is the state diff tracer can be used to track newly added/deleted
 	// trie node. It will be reset after each commit operation.
 	tracer *tracer
+
+	// recorder, if set, is invoked with every node loaded from the database,
+	// allowing the accessed part of the trie to be reproduced without it.
+	recorder func(hash common.Hash, blob []byte)
 }
 
 // newFlag returns the cache flag value for a newly created node.
@@ -88,9 +92,17 @@ func (t *Trie) Copy() *Trie {
 		owner:    t.owner,
 		unhashed: t.unhashed,
 		tracer:   t.tracer.copy(),
+		recorder: t.recorder,
 	}
 }
 
+// SetRecorder installs a callback invoked with the hash and the encoding of
+// every trie node subsequently loaded from the database. Nodes resolved before
+// the call, such as the root, are not reported.
+func (t *Trie) SetRecorder(recorder func(hash common.Hash, blob []byte)) {
+	t.recorder = recorder
+}
+
 // New creates a trie with an existing root node from db and an assigned
 // owner for storage proximity.
 //
@@ -128,6 +140,10 @@ func newTrie(owner common.Hash, root common.Hash, db *Database) (*Trie, error) {
 		owner: owner,
 		//tracer: newTracer(),
 	}
+	// The path scheme needs the deleted nodes, to remove them from their paths
+	if db.path != nil {
+		trie.tracer = newTracer()
+	}
 	if root != (common.Hash{}) && root != emptyRoot {
 		rootnode, err := trie.resolveHash(root[:], nil)
 		if err != nil {
@@ -236,7 +252,7 @@ func (t *Trie) tryGetNode(origNode node, path []byte, pos int) (item []byte, new
 		if hash == nil {
 			return nil, origNode, 0, errors.New("non-consensus node")
 		}
-		blob, err := t.db.Node(common.BytesToHash(hash))
+		blob, err := t.db.NodeBlob(t.owner, path[:pos], common.BytesToHash(hash))
 		return blob, origNode, 1, err
 	}
 	// Path still needs to be traversed, descend into children
@@ -512,7 +528,7 @@ func (t *Trie) delete(n node, prefix, key []byte) (bool, node, error) {
 				// shortNode{..., shortNode{...}}.  Since the entry
 				// might not be loaded yet, resolve it just for this
 				// check.
-				cnode, err := t.resolve(n.Children[pos], prefix)
+				cnode, err := t.resolve(n.Children[pos], append(prefix, byte(pos)))
 				if err != nil {
 					return false, nil, err
 				}
@@ -574,7 +590,21 @@ func (t *Trie) resolve(n node, prefix []byte) (node, error) {
 
 func (t *Trie) resolveHash(n hashNode, prefix []byte) (node, error) {
 	hash := common.BytesToHash(n)
+	if t.db.path != nil {
+		if blob := t.db.pathBlob(t.owner, prefix, hash); len(blob) != 0 {
+			if t.recorder != nil {
+				t.recorder(hash, blob)
+			}
+			return mustDecodeNode(hash[:], blob), nil
+		}
+		return nil, &MissingNodeError{Owner: t.owner, NodeHash: hash, Path: prefix}
+	}
 	if node := t.db.node(hash); node != nil {
+		if t.recorder != nil {
+			if blob, _ := t.db.Node(hash); len(blob) != 0 {
+				t.recorder(hash, blob)
+			}
+		}
 		return node, nil
 	}
 	return nil, &MissingNodeError{Owner: t.owner, NodeHash: hash, Path: prefix}
@@ -582,8 +612,11 @@ func (t *Trie) resolveHash(n hashNode, prefix []byte) (node, error) {
 
 func (t *Trie) resolveBlob(n hashNode, prefix []byte) ([]byte, error) {
 	hash := common.BytesToHash(n)
-	blob, _ := t.db.Node(hash)
+	blob, _ := t.db.NodeBlob(t.owner, prefix, hash)
 	if len(blob) != 0 {
+		if t.recorder != nil {
+			t.recorder(hash, blob)
+		}
 		return blob, nil
 	}
 	return nil, &MissingNodeError{Owner: t.owner, NodeHash: hash, Path: prefix}
@@ -598,14 +631,28 @@ func (t *Trie) Hash() common.Hash {
 }
 
 // Commit writes all nodes to the trie's memory database, tracking the internal
-// and external (for account tries) references.
+// and external (for account tries) references. With the path scheme, the nodes
+// are staged into the transition to the state with the root of the trie.
 func (t *Trie) Commit(onleaf LeafCallback) (common.Hash, int, error) {
+	return t.CommitState(common.Hash{}, onleaf)
+}
+
+// CommitState is like Commit, but stages the nodes of the path scheme into the
+// transition to the given state root, e.g. for the storage tries of a state. A
+// zero root stands for the root of the trie itself.
+func (t *Trie) CommitState(state common.Hash, onleaf LeafCallback) (common.Hash, int, error) {
 	if t.db == nil {
 		panic("commit called on trie with nil database")
 	}
 	defer t.tracer.reset()
 
 	if t.root == nil {
+		if t.db.path != nil {
+			if state == (common.Hash{}) {
+				state = emptyRoot
+			}
+			t.stage(state, make(map[string]*pathNode))
+		}
 		return emptyRoot, 0, nil
 	}
 	// Derive the hash for all dirty nodes first. We hold the assumption
@@ -623,6 +670,11 @@ func (t *Trie) Commit(onleaf LeafCallback) (common.Hash, int, error) {
 		t.root = hashedNode
 		return rootHash, 0, nil
 	}
+	var nodes map[string]*pathNode
+	if t.db.path != nil {
+		nodes = make(map[string]*pathNode)
+		h.nodes = nodes
+	}
 	var wg sync.WaitGroup
 	if onleaf != nil {
 		h.onleaf = onleaf
@@ -645,10 +697,30 @@ func (t *Trie) Commit(onleaf LeafCallback) (common.Hash, int, error) {
 	if err != nil {
 		return common.Hash{}, 0, err
 	}
+	if nodes != nil {
+		if state == (common.Hash{}) {
+			state = rootHash
+		}
+		t.stage(state, nodes)
+	}
 	t.root = newRoot
 	return rootHash, committed, nil
 }
 
+// stage hands the nodes committed by path over to the database, along with the
+// ones deleted from the trie since the last commit, to be sealed into the
+// transition to the given state.
+func (t *Trie) stage(state common.Hash, nodes map[string]*pathNode) {
+	for _, path := range t.tracer.deleteList() {
+		if _, ok := nodes[string(path)]; !ok {
+			nodes[string(path)] = &pathNode{}
+		}
+	}
+	if len(nodes) > 0 {
+		t.db.path.stage(state, t.owner, nodes)
+	}
+}
+
 // hashRoot calculates the root hash of the given trie
 func (t *Trie) hashRoot() (node, node, error) {
 	if t.root == nil {
@@ -0,0 +1,315 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package verkle
+
+import (
+	"errors"
+	"math/big"
+	"math/bits"
+)
+
+// fp is an element of the base field of the bandersnatch curve, which is the
+// scalar field of BLS12-381, held in montgomery form as little endian limbs.
+type fp [4]uint64
+
+var (
+	// fpModulus is the modulus of the base field.
+	fpModulus = fp{0xffffffff00000001, 0x53bda402fffe5bfe, 0x3339d80809a1d805, 0x73eda753299d7d48}
+
+	// fpInv is -fpModulus^-1 mod 2^64, used by the montgomery reduction.
+	fpInv uint64 = 0xfffffffeffffffff
+
+	fpModulusBig = limbsToBig(fpModulus)
+	fpHalf       = new(big.Int).Rsh(fpModulusBig, 1) // (p-1)/2, the largest "small" element
+
+	fpR2  fp // 2^512 mod p, converting into montgomery form
+	fpOne fp // 2^256 mod p, the montgomery form of 1
+
+	// The exponents of the inversion, the legendre symbol and the square root
+	fpInvExp      = new(big.Int).Sub(fpModulusBig, big.NewInt(2))
+	fpLegendreExp = new(big.Int).Rsh(fpModulusBig, 1)
+	fpSqrtS       uint // 2-adicity of p-1
+	fpSqrtQ       *big.Int
+	fpSqrtQExp    *big.Int // (q+1)/2
+	fpSqrtZ       fp       // non-residue raised to q, generating the 2^s roots of unity
+
+	errFieldOverflow = errors.New("field element not canonical")
+)
+
+func init() {
+	r := new(big.Int).Lsh(big.NewInt(1), 256)
+	fpOne = bigToLimbs(new(big.Int).Mod(r, fpModulusBig))
+	fpR2 = bigToLimbs(new(big.Int).Mod(new(big.Int).Mul(r, r), fpModulusBig))
+
+	fpSqrtQ = new(big.Int).Sub(fpModulusBig, big.NewInt(1))
+	for fpSqrtQ.Bit(0) == 0 {
+		fpSqrtQ.Rsh(fpSqrtQ, 1)
+		fpSqrtS++
+	}
+	fpSqrtQExp = new(big.Int).Add(fpSqrtQ, big.NewInt(1))
+	fpSqrtQExp.Rsh(fpSqrtQExp, 1)
+
+	for i := int64(2); ; i++ {
+		var z fp
+		z.setBig(big.NewInt(i))
+		if z.legendre() == -1 {
+			fpSqrtZ.exp(&z, fpSqrtQ)
+			break
+		}
+	}
+}
+
+// limbsToBig converts little endian limbs into an integer.
+func limbsToBig(l [4]uint64) *big.Int {
+	var buf [32]byte
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			buf[31-8*i-j] = byte(l[i] >> (8 * j))
+		}
+	}
+	return new(big.Int).SetBytes(buf[:])
+}
+
+// bigToLimbs converts an integer below 2^256 into little endian limbs.
+func bigToLimbs(x *big.Int) (l [4]uint64) {
+	var buf [32]byte
+	x.FillBytes(buf[:])
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 8; j++ {
+			l[i] |= uint64(buf[31-8*i-j]) << (8 * j)
+		}
+	}
+	return l
+}
+
+// setBig sets z to x mod p.
+func (z *fp) setBig(x *big.Int) *fp {
+	v := x
+	if x.Sign() < 0 || x.Cmp(fpModulusBig) >= 0 {
+		v = new(big.Int).Mod(x, fpModulusBig)
+	}
+	*z = bigToLimbs(v)
+	return z.mul(z, &fpR2)
+}
+
+// big returns the canonical value of z.
+func (z *fp) big() *big.Int {
+	var v fp
+	v.mul(z, &fp{1})
+	return limbsToBig(v)
+}
+
+// setBytes sets z to the big endian value of buf, which must be canonical.
+func (z *fp) setBytes(buf []byte) error {
+	v := new(big.Int).SetBytes(buf)
+	if v.Cmp(fpModulusBig) >= 0 {
+		return errFieldOverflow
+	}
+	z.setBig(v)
+	return nil
+}
+
+// bytes returns the big endian canonical value of z.
+func (z *fp) bytes() (buf [32]byte) {
+	z.big().FillBytes(buf[:])
+	return buf
+}
+
+// isZero reports whether z is zero.
+func (z *fp) isZero() bool {
+	return z[0]|z[1]|z[2]|z[3] == 0
+}
+
+// equal reports whether z and x are the same element.
+func (z *fp) equal(x *fp) bool {
+	return *z == *x
+}
+
+// lexicographicallyLargest reports whether z is above (p-1)/2.
+func (z *fp) lexicographicallyLargest() bool {
+	return z.big().Cmp(fpHalf) > 0
+}
+
+// reduce subtracts the modulus from z if z is not below it. The carry of the
+// operation producing z signals a value above 2^256.
+func (z *fp) reduce(carry uint64) {
+	var (
+		t      fp
+		borrow uint64
+	)
+	t[0], borrow = bits.Sub64(z[0], fpModulus[0], 0)
+	t[1], borrow = bits.Sub64(z[1], fpModulus[1], borrow)
+	t[2], borrow = bits.Sub64(z[2], fpModulus[2], borrow)
+	t[3], borrow = bits.Sub64(z[3], fpModulus[3], borrow)
+	if carry != 0 || borrow == 0 {
+		*z = t
+	}
+}
+
+// add sets z to x+y.
+func (z *fp) add(x, y *fp) *fp {
+	var carry uint64
+	z[0], carry = bits.Add64(x[0], y[0], 0)
+	z[1], carry = bits.Add64(x[1], y[1], carry)
+	z[2], carry = bits.Add64(x[2], y[2], carry)
+	z[3], carry = bits.Add64(x[3], y[3], carry)
+	z.reduce(carry)
+	return z
+}
+
+// sub sets z to x-y.
+func (z *fp) sub(x, y *fp) *fp {
+	var borrow uint64
+	z[0], borrow = bits.Sub64(x[0], y[0], 0)
+	z[1], borrow = bits.Sub64(x[1], y[1], borrow)
+	z[2], borrow = bits.Sub64(x[2], y[2], borrow)
+	z[3], borrow = bits.Sub64(x[3], y[3], borrow)
+	if borrow != 0 {
+		var carry uint64
+		z[0], carry = bits.Add64(z[0], fpModulus[0], 0)
+		z[1], carry = bits.Add64(z[1], fpModulus[1], carry)
+		z[2], carry = bits.Add64(z[2], fpModulus[2], carry)
+		z[3], _ = bits.Add64(z[3], fpModulus[3], carry)
+	}
+	return z
+}
+
+// neg sets z to -x.
+func (z *fp) neg(x *fp) *fp {
+	return z.sub(&fp{}, x)
+}
+
+// mul sets z to x*y, using the coarsely integrated operand scanning montgomery
+// multiplication.
+func (z *fp) mul(x, y *fp) *fp {
+	var t [6]uint64
+	for i := 0; i < 4; i++ {
+		var c uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := bits.Mul64(x[j], y[i])
+			var cc uint64
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j], c = lo, hi
+		}
+		var cc uint64
+		t[4], cc = bits.Add64(t[4], c, 0)
+		t[5] = cc
+
+		m := t[0] * fpInv
+		hi, lo := bits.Mul64(m, fpModulus[0])
+		_, cc = bits.Add64(lo, t[0], 0)
+		c = hi + cc
+		for j := 1; j < 4; j++ {
+			hi, lo = bits.Mul64(m, fpModulus[j])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j-1], c = lo, hi
+		}
+		t[3], cc = bits.Add64(t[4], c, 0)
+		t[4] = t[5] + cc
+	}
+	*z = fp{t[0], t[1], t[2], t[3]}
+	z.reduce(t[4])
+	return z
+}
+
+// square sets z to x*x.
+func (z *fp) square(x *fp) *fp {
+	return z.mul(x, x)
+}
+
+// exp sets z to x^e.
+func (z *fp) exp(x *fp, e *big.Int) *fp {
+	var (
+		base = *x
+		res  = fpOne
+	)
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		res.square(&res)
+		if e.Bit(i) == 1 {
+			res.mul(&res, &base)
+		}
+	}
+	*z = res
+	return z
+}
+
+// inverse sets z to 1/x, or zero if x is zero.
+func (z *fp) inverse(x *fp) *fp {
+	return z.exp(x, fpInvExp)
+}
+
+// legendre returns 1 if z is a non-zero square, -1 if it isn't a square and 0
+// if it is zero.
+func (z *fp) legendre() int {
+	var l fp
+	l.exp(z, fpLegendreExp)
+	switch {
+	case l.isZero():
+		return 0
+	case l.equal(&fpOne):
+		return 1
+	default:
+		return -1
+	}
+}
+
+// sqrt sets z to a square root of x with the Tonelli-Shanks algorithm,
+// returning false if x is not a square.
+func (z *fp) sqrt(x *fp) bool {
+	switch x.legendre() {
+	case 0:
+		*z = fp{}
+		return true
+	case -1:
+		return false
+	}
+	var (
+		m = fpSqrtS
+		c = fpSqrtZ
+		t fp
+		r fp
+	)
+	t.exp(x, fpSqrtQ)
+	r.exp(x, fpSqrtQExp)
+	for !t.equal(&fpOne) {
+		// Find the least i such that t^(2^i) = 1
+		var (
+			i  uint
+			tt = t
+		)
+		for !tt.equal(&fpOne) {
+			tt.square(&tt)
+			i++
+		}
+		b := c
+		for j := uint(0); j < m-i-1; j++ {
+			b.square(&b)
+		}
+		m = i
+		c.square(&b)
+		t.mul(&t, &c)
+		r.mul(&r, &b)
+	}
+	*z = r
+	return true
+}
@@ -0,0 +1,90 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package verkle
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// randomBig returns a random integer below the field modulus, with a bias
+// towards the edges of the field.
+func randomBig(rng *rand.Rand) *big.Int {
+	switch rng.Intn(4) {
+	case 0:
+		return big.NewInt(rng.Int63n(4))
+	case 1:
+		return new(big.Int).Sub(fpModulusBig, big.NewInt(1+rng.Int63n(4)))
+	default:
+		return new(big.Int).Rand(rng, fpModulusBig)
+	}
+}
+
+// Tests the field operations against the arbitrary precision ones.
+func TestFieldArithmetic(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	mod := func(x *big.Int) *big.Int { return x.Mod(x, fpModulusBig) }
+
+	for i := 0; i < 1000; i++ {
+		a, b := randomBig(rng), randomBig(rng)
+
+		var x, y, z fp
+		x.setBig(a)
+		y.setBig(b)
+		if x.big().Cmp(a) != 0 {
+			t.Fatalf("conversion mismatch: have %v, want %v", x.big(), a)
+		}
+		if have, want := z.add(&x, &y).big(), mod(new(big.Int).Add(a, b)); have.Cmp(want) != 0 {
+			t.Fatalf("%v + %v mismatch: have %v, want %v", a, b, have, want)
+		}
+		if have, want := z.sub(&x, &y).big(), mod(new(big.Int).Sub(a, b)); have.Cmp(want) != 0 {
+			t.Fatalf("%v - %v mismatch: have %v, want %v", a, b, have, want)
+		}
+		if have, want := z.mul(&x, &y).big(), mod(new(big.Int).Mul(a, b)); have.Cmp(want) != 0 {
+			t.Fatalf("%v * %v mismatch: have %v, want %v", a, b, have, want)
+		}
+		if have, want := z.neg(&x).big(), mod(new(big.Int).Neg(a)); have.Cmp(want) != 0 {
+			t.Fatalf("-%v mismatch: have %v, want %v", a, have, want)
+		}
+		if a.Sign() != 0 {
+			if have, want := z.inverse(&x).big(), new(big.Int).ModInverse(a, fpModulusBig); have.Cmp(want) != 0 {
+				t.Fatalf("1/%v mismatch: have %v, want %v", a, have, want)
+			}
+		}
+		if have, want := x.legendre(), big.Jacobi(a, fpModulusBig); have != want {
+			t.Fatalf("legendre symbol of %v mismatch: have %d, want %d", a, have, want)
+		}
+		var r fp
+		if ok := r.sqrt(&x); ok != (x.legendre() >= 0) {
+			t.Fatalf("square root of %v existence mismatch: have %v", a, ok)
+		} else if ok {
+			if z.square(&r); !z.equal(&x) {
+				t.Fatalf("square root of %v mismatch: %v squared is %v", a, r.big(), z.big())
+			}
+		}
+		buf := x.bytes()
+		if err := z.setBytes(buf[:]); err != nil || !z.equal(&x) {
+			t.Fatalf("bytes of %v mismatch: have %v, err %v", a, z.big(), err)
+		}
+	}
+	// Non-canonical encodings are rejected
+	var x fp
+	if err := x.setBytes(fpModulusBig.Bytes()); err != errFieldOverflow {
+		t.Errorf("modulus accepted: %v", err)
+	}
+}
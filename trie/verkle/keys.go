@@ -0,0 +1,148 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package verkle
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// The leaves of the account header, in the stem of the tree index zero of the
+// account, as laid out by EIP-6800.
+const (
+	VersionLeafKey  = 0
+	BalanceLeafKey  = 1
+	NonceLeafKey    = 2
+	CodeHashLeafKey = 3
+	CodeSizeLeafKey = 4
+
+	headerStorageOffset = 64  // first leaf of the storage slots kept in the header stem
+	codeOffset          = 128 // first leaf of the code chunks
+
+	// The push opcodes, the EVM package depending on the state
+	push1  = 0x60
+	push32 = 0x7f
+)
+
+var (
+	// mainStorageOffset is the position of the storage slots not kept in the
+	// header stem, 256^31.
+	mainStorageOffset = new(big.Int).Lsh(big.NewInt(1), 248)
+
+	headerStorageCap = big.NewInt(codeOffset - headerStorageOffset)
+	nodeWidth        = big.NewInt(NodeWidth)
+)
+
+// pedersenHash hashes the padded address and the tree index into the stem of
+// the key, by committing to the 16 byte little endian chunks of the input
+// prefixed by its length.
+func pedersenHash(addr common.Address, treeIndex *big.Int) []byte {
+	var input [64]byte
+	copy(input[12:32], addr[:])
+	index := treeIndex.Bytes()
+	for i, b := range index {
+		input[32+len(index)-1-i] = b
+	}
+	scalars := map[int]*big.Int{0: big.NewInt(2 + 256*int64(len(input)))}
+	for i := 0; i < len(input)/16; i++ {
+		var chunk [16]byte
+		for j := 0; j < 16; j++ {
+			chunk[j] = input[16*i+15-j]
+		}
+		scalars[i+1] = new(big.Int).SetBytes(chunk[:])
+	}
+	return scalarBytes(commit(scalars).mapToScalar())
+}
+
+// scalarBytes returns the 32 byte little endian encoding of a scalar.
+func scalarBytes(s *big.Int) []byte {
+	var buf [32]byte
+	s.FillBytes(buf[:])
+	for i := 0; i < 16; i++ {
+		buf[i], buf[31-i] = buf[31-i], buf[i]
+	}
+	return buf[:]
+}
+
+// treeKey returns the key of the leaf at the given position of the account.
+func treeKey(addr common.Address, treeIndex *big.Int, subIndex byte) []byte {
+	key := pedersenHash(addr, treeIndex)
+	key[31] = subIndex
+	return key
+}
+
+// HeaderKey returns the key of a leaf of the account header.
+func HeaderKey(addr common.Address, leaf byte) []byte {
+	return treeKey(addr, new(big.Int), leaf)
+}
+
+// positionKey returns the key of the leaf at the given position of the account,
+// splitting it into the tree index and the leaf of the stem.
+func positionKey(addr common.Address, pos *big.Int) []byte {
+	index, sub := new(big.Int).DivMod(pos, nodeWidth, new(big.Int))
+	return treeKey(addr, index, byte(sub.Uint64()))
+}
+
+// StorageKey returns the key of a storage slot of the account. The first slots
+// share the stem of the header, the others are spread over the whole tree.
+func StorageKey(addr common.Address, slot common.Hash) []byte {
+	pos := new(big.Int).SetBytes(slot[:])
+	if pos.Cmp(headerStorageCap) < 0 {
+		pos.Add(pos, big.NewInt(headerStorageOffset))
+	} else {
+		pos.Add(pos, mainStorageOffset)
+	}
+	return positionKey(addr, pos)
+}
+
+// CodeChunkKey returns the key of a code chunk of the account.
+func CodeChunkKey(addr common.Address, chunk uint64) []byte {
+	pos := new(big.Int).SetUint64(chunk)
+	return positionKey(addr, pos.Add(pos, big.NewInt(codeOffset)))
+}
+
+// ChunkifyCode splits the code into 31 byte chunks, each prefixed by the number
+// of its leading bytes which are push data, so that the jump destinations can
+// be checked from a single chunk.
+func ChunkifyCode(code []byte) [][]byte {
+	chunks := make([][]byte, (len(code)+30)/31)
+	for i := range chunks {
+		chunks[i] = make([]byte, 32)
+		copy(chunks[i][1:], code[31*i:])
+	}
+	// Mark the push data leading each chunk
+	for pc := 0; pc < len(code); {
+		op := code[pc]
+		pc++
+		if op < push1 || op > push32 {
+			continue
+		}
+		size := int(op-push1) + 1
+		for i := pc; i < pc+size && i < len(code); i++ {
+			if i%31 == 0 {
+				left := pc + size - i
+				if left > 31 {
+					left = 31
+				}
+				chunks[i/31][0] = byte(left)
+			}
+		}
+		pc += size
+	}
+	return chunks
+}
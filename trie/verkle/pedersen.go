@@ -0,0 +1,82 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package verkle
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+	"sync"
+)
+
+// NodeWidth is the number of children of an internal node and of values of a
+// leaf node.
+const NodeWidth = 256
+
+// generatorSeed is the seed the commitment generators are derived from.
+const generatorSeed = "eth_verkle_oct_2021"
+
+var (
+	generators     [NodeWidth]point
+	generatorsOnce sync.Once
+)
+
+// generator returns the i-th generator of the pedersen commitments. The points
+// are derived by hashing the seed with an increasing counter until the hash is
+// the x coordinate of a group element, so that nobody knows their discrete
+// logarithms relative to each other.
+func generator(i int) *point {
+	generatorsOnce.Do(func() {
+		var counter uint64
+		for n := 0; n < NodeWidth; counter++ {
+			var buf [8]byte
+			binary.BigEndian.PutUint64(buf[:], counter)
+			hash := sha256.Sum256(append([]byte(generatorSeed), buf[:]...))
+
+			var x fp
+			x.setBig(new(big.Int).SetBytes(hash[:]))
+			if err := generators[n].setX(&x); err != nil {
+				continue
+			}
+			n++
+		}
+	})
+	return &generators[i]
+}
+
+// commit returns the pedersen commitment to a sparse vector, the sum of the
+// scalars times the generator of their index.
+func commit(scalars map[int]*big.Int) *point {
+	res := identity()
+	for i, s := range scalars {
+		if s.Sign() == 0 {
+			continue
+		}
+		res.add(res, new(point).scalarMul(generator(i), s))
+	}
+	return res
+}
+
+// commitDelta returns the commitment c updated by changing the scalar of the
+// index i from prev to next.
+func commitDelta(c *point, i int, prev, next *big.Int) *point {
+	delta := new(big.Int).Sub(next, prev)
+	if delta.Sign() == 0 {
+		return c
+	}
+	return new(point).add(c, new(point).scalarMul(generator(i), delta))
+}
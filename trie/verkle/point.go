@@ -0,0 +1,226 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package verkle
+
+import (
+	"errors"
+	"math/big"
+)
+
+var (
+	// curveA and curveD are the parameters of the bandersnatch twisted edwards
+	// curve a*x^2 + y^2 = 1 + d*x^2*y^2.
+	curveA fp
+	curveD fp
+
+	// groupOrder is the order of the prime subgroup of bandersnatch, which is
+	// the order of the banderwagon group.
+	groupOrder, _ = new(big.Int).SetString("1cfb69d4ca675f520cce760202687600ff8f87007419047174fd06b52876e7e1", 16)
+
+	errNotOnCurve    = errors.New("point not on curve")
+	errNotInSubgroup = errors.New("point not in subgroup")
+)
+
+func init() {
+	curveA.setBig(big.NewInt(-5))
+	d, _ := new(big.Int).SetString("6389c12633c267cbc66e3bf86be3b6d8cb66677177e54f92b369f2f5188d58e7", 16)
+	curveD.setBig(d)
+}
+
+// point is an element of the banderwagon group, the prime order subgroup of the
+// bandersnatch curve with the points P and P+(0,-1) identified, in extended
+// twisted edwards coordinates x=X/Z, y=Y/Z, x*y=T/Z.
+type point struct {
+	x, y, t, z fp
+}
+
+// identity returns the neutral element of the group.
+func identity() *point {
+	return &point{y: fpOne, z: fpOne}
+}
+
+// equal reports whether p and q are the same group element, which is the case
+// if x/y matches, as P and P+(0,-1) are identified.
+func (p *point) equal(q *point) bool {
+	var l, r fp
+	l.mul(&p.x, &q.y)
+	r.mul(&q.x, &p.y)
+	return l.equal(&r)
+}
+
+// add sets p to q+r, with the unified formula which is complete over the group.
+func (p *point) add(q, r *point) *point {
+	var a, b, c, d, e, f, g, h, t fp
+	a.mul(&q.x, &r.x)
+	b.mul(&q.y, &r.y)
+	c.mul(&q.t, &r.t)
+	c.mul(&c, &curveD)
+	d.mul(&q.z, &r.z)
+
+	e.add(&q.x, &q.y)
+	t.add(&r.x, &r.y)
+	e.mul(&e, &t)
+	e.sub(&e, &a)
+	e.sub(&e, &b)
+
+	f.sub(&d, &c)
+	g.add(&d, &c)
+	t.mul(&a, &curveA)
+	h.sub(&b, &t)
+
+	p.x.mul(&e, &f)
+	p.y.mul(&g, &h)
+	p.t.mul(&e, &h)
+	p.z.mul(&f, &g)
+	return p
+}
+
+// double sets p to q+q.
+func (p *point) double(q *point) *point {
+	var a, b, c, d, e, f, g, h fp
+	a.square(&q.x)
+	b.square(&q.y)
+	c.square(&q.z)
+	c.add(&c, &c)
+	d.mul(&a, &curveA)
+
+	e.add(&q.x, &q.y)
+	e.square(&e)
+	e.sub(&e, &a)
+	e.sub(&e, &b)
+
+	g.add(&d, &b)
+	f.sub(&g, &c)
+	h.sub(&d, &b)
+
+	p.x.mul(&e, &f)
+	p.y.mul(&g, &h)
+	p.t.mul(&e, &h)
+	p.z.mul(&f, &g)
+	return p
+}
+
+// neg sets p to -q.
+func (p *point) neg(q *point) *point {
+	p.x.neg(&q.x)
+	p.y = q.y
+	p.t.neg(&q.t)
+	p.z = q.z
+	return p
+}
+
+// scalarMul sets p to k*q, with a fixed window of four bits.
+func (p *point) scalarMul(q *point, k *big.Int) *point {
+	if k.Sign() < 0 || k.Cmp(groupOrder) >= 0 {
+		k = new(big.Int).Mod(k, groupOrder)
+	}
+	var table [16]point
+	table[0] = *identity()
+	table[1] = *q
+	for i := 2; i < 16; i++ {
+		table[i].add(&table[i-1], q)
+	}
+	res := identity()
+	for i := (k.BitLen() + 3) / 4; i > 0; i-- {
+		res.double(res)
+		res.double(res)
+		res.double(res)
+		res.double(res)
+
+		var w uint
+		for j := 0; j < 4; j++ {
+			w |= k.Bit(4*(i-1)+j) << j
+		}
+		if w != 0 {
+			res.add(res, &table[w])
+		}
+	}
+	*p = *res
+	return p
+}
+
+// affine returns the affine coordinates of p.
+func (p *point) affine() (x, y fp) {
+	var inv fp
+	inv.inverse(&p.z)
+	x.mul(&p.x, &inv)
+	y.mul(&p.y, &inv)
+	return x, y
+}
+
+// bytes serializes p as the big endian x coordinate of the representative
+// with the lexicographically largest y.
+func (p *point) bytes() [32]byte {
+	x, y := p.affine()
+	if !y.lexicographicallyLargest() {
+		x.neg(&x)
+	}
+	return x.bytes()
+}
+
+// setBytes deserializes p, checking the subgroup membership.
+func (p *point) setBytes(buf []byte) error {
+	var x fp
+	if err := x.setBytes(buf); err != nil {
+		return err
+	}
+	return p.setX(&x)
+}
+
+// setX sets p to the group element with the given x coordinate and the
+// lexicographically largest y coordinate.
+func (p *point) setX(x *fp) error {
+	// The points of the group are the ones where 1-a*x^2 is a square
+	var x2, num, den fp
+	x2.square(x)
+	num.mul(&x2, &curveA)
+	num.sub(&fpOne, &num)
+	if num.legendre() != 1 {
+		return errNotInSubgroup
+	}
+	// y^2 = (1-a*x^2) / (1-d*x^2)
+	den.mul(&x2, &curveD)
+	den.sub(&fpOne, &den)
+	den.inverse(&den)
+	num.mul(&num, &den)
+
+	var y fp
+	if !y.sqrt(&num) {
+		return errNotOnCurve
+	}
+	if !y.lexicographicallyLargest() {
+		y.neg(&y)
+	}
+	p.x, p.y, p.z = *x, y, fpOne
+	p.t.mul(x, &y)
+	return nil
+}
+
+// mapToField maps p to the base field as x/y, which is the same for both the
+// representatives of the element.
+func (p *point) mapToField() fp {
+	var inv, res fp
+	inv.inverse(&p.y)
+	res.mul(&p.x, &inv)
+	return res
+}
+
+// mapToScalar maps p to the scalar field, reducing its base field image.
+func (p *point) mapToScalar() *big.Int {
+	v := p.mapToField()
+	return new(big.Int).Mod(v.big(), groupOrder)
+}
@@ -0,0 +1,167 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package verkle
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// onCurve reports whether p satisfies the curve equation and the extended
+// coordinate invariant.
+func onCurve(p *point) bool {
+	x, y := p.affine()
+
+	var x2, y2, l, r, t fp
+	x2.square(&x)
+	y2.square(&y)
+	l.mul(&x2, &curveA)
+	l.add(&l, &y2)
+	r.mul(&x2, &y2)
+	r.mul(&r, &curveD)
+	r.add(&r, &fpOne)
+
+	t.mul(&p.x, &p.y)
+	var tz fp
+	tz.mul(&p.t, &p.z)
+	return l.equal(&r) && t.equal(&tz)
+}
+
+// Tests that the generators are distinct group elements of the prime order.
+func TestGenerators(t *testing.T) {
+	seen := make(map[[32]byte]bool)
+	for i := 0; i < NodeWidth; i++ {
+		g := generator(i)
+		if !onCurve(g) {
+			t.Fatalf("generator %d not on curve", i)
+		}
+		if !new(point).scalarMul(g, new(big.Int).Sub(groupOrder, big.NewInt(1))).equal(new(point).neg(g)) {
+			t.Fatalf("generator %d not of the group order", i)
+		}
+		enc := g.bytes()
+		if seen[enc] {
+			t.Fatalf("generator %d duplicated", i)
+		}
+		seen[enc] = true
+	}
+	// The generators match the ones of the reference implementation
+	for i, want := range map[int]string{
+		0:   "01587ad1336675eb912550ec2a28eb8923b824b490dd2ba82e48f14590a298a0",
+		255: "3de2be346b539395b0c0de56a5ccca54a317f1b5c80107b0802af9a62276a4d8",
+	} {
+		if have := generator(i).bytes(); common.Bytes2Hex(have[:]) != want {
+			t.Errorf("generator %d mismatch: have %x, want %s", i, have, want)
+		}
+	}
+}
+
+// Tests the group laws and the consistency of the scalar multiplication with
+// the additions.
+func TestGroupLaws(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() *point {
+		return new(point).scalarMul(generator(0), new(big.Int).Rand(rng, groupOrder))
+	}
+	for i := 0; i < 20; i++ {
+		p, q, r := random(), random(), random()
+
+		// Commutativity and associativity
+		if !new(point).add(p, q).equal(new(point).add(q, p)) {
+			t.Fatal("addition not commutative")
+		}
+		pq := new(point).add(p, q)
+		qr := new(point).add(q, r)
+		if !new(point).add(pq, r).equal(new(point).add(p, qr)) {
+			t.Fatal("addition not associative")
+		}
+		// Identity, inverse and doubling
+		if !new(point).add(p, identity()).equal(p) {
+			t.Fatal("identity not neutral")
+		}
+		if !new(point).add(p, new(point).neg(p)).equal(identity()) {
+			t.Fatal("negation not inverse")
+		}
+		if !new(point).double(p).equal(new(point).add(p, p)) {
+			t.Fatal("doubling mismatch")
+		}
+		if !onCurve(pq) || !onCurve(new(point).double(p)) {
+			t.Fatal("result not on curve")
+		}
+		// Scalar multiplication distributes over the scalars
+		a, b := new(big.Int).Rand(rng, groupOrder), new(big.Int).Rand(rng, groupOrder)
+		sum := new(point).add(new(point).scalarMul(p, a), new(point).scalarMul(p, b))
+		if !new(point).scalarMul(p, new(big.Int).Add(a, b)).equal(sum) {
+			t.Fatal("scalar multiplication not distributive")
+		}
+		small := identity()
+		for k := 0; k < 20; k++ {
+			if !new(point).scalarMul(p, big.NewInt(int64(k))).equal(small) {
+				t.Fatalf("scalar multiplication by %d mismatch", k)
+			}
+			small.add(small, p)
+		}
+	}
+}
+
+// Tests that the serialization of the elements round trips, doesn't depend on
+// the representative and rejects points outside the group.
+func TestPointEncoding(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		p := new(point).scalarMul(generator(1), new(big.Int).Rand(rng, groupOrder))
+		enc := p.bytes()
+
+		var q point
+		if err := q.setBytes(enc[:]); err != nil {
+			t.Fatalf("failed to decode %x: %v", enc, err)
+		}
+		if !q.equal(p) || q.bytes() != enc {
+			t.Fatalf("decoded point mismatch: %x", enc)
+		}
+		// The other representative, P+(0,-1), has the same encoding and image
+		var other point
+		other.x.neg(&p.x)
+		other.y.neg(&p.y)
+		other.t = p.t
+		other.z = p.z
+		if other.bytes() != enc || other.mapToScalar().Cmp(p.mapToScalar()) != 0 {
+			t.Fatalf("representatives of %x mismatch", enc)
+		}
+	}
+	if enc := identity().bytes(); enc != [32]byte{} {
+		t.Errorf("identity encoding mismatch: %x", enc)
+	}
+	// Find an x coordinate of a point outside the group
+	var x fp
+	for i := int64(1); ; i++ {
+		x.setBig(big.NewInt(i))
+		var x2, num fp
+		x2.square(&x)
+		num.mul(&x2, &curveA)
+		num.sub(&fpOne, &num)
+		if num.legendre() == -1 {
+			break
+		}
+	}
+	var p point
+	if err := p.setX(&x); err != errNotInSubgroup {
+		t.Errorf("point outside the group accepted: %v", err)
+	}
+}
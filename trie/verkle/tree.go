@@ -0,0 +1,721 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package verkle implements the experimental verkle tree state of EIP-6800, in
+// which a single tree of pedersen vector commitments over the banderwagon group
+// holds the accounts, their code and storage.
+//
+// The package only provides what's needed to execute blocks over the tree: the
+// nodes are persisted keyed by their commitment and never pruned, and there are
+// no proofs nor iteration.
+package verkle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+const (
+	// StemLength is the length of the key prefix shared by the values of a leaf.
+	StemLength = 31
+
+	// KeyLength and ValueLength are the lengths of the keys and values.
+	KeyLength   = 32
+	ValueLength = 32
+)
+
+// The types of the serialized nodes.
+const (
+	internalNodeType = 1
+	leafNodeType     = 2
+)
+
+var (
+	errInvalidNode   = errors.New("invalid verkle node")
+	errInvalidKey    = errors.New("invalid verkle key")
+	errInvalidValue  = errors.New("invalid verkle value")
+	errNotInternal   = errors.New("verkle root is not an internal node")
+	leafMarker       = new(big.Int).Lsh(big.NewInt(1), 128)
+	extensionMarker  = big.NewInt(1)
+	bitmapLength     = NodeWidth / 8
+	leafHeaderLength = 1 + StemLength + bitmapLength + 2*32
+)
+
+// commitment is the commitment of a node, decoded on demand from its serialized
+// form when the node is loaded from the database.
+type commitment struct {
+	hash  common.Hash
+	point *point
+}
+
+// get returns the commitment as a group element.
+func (c *commitment) get() (*point, error) {
+	if c.point == nil {
+		p := new(point)
+		if err := p.setBytes(c.hash[:]); err != nil {
+			return nil, err
+		}
+		c.point = p
+	}
+	return c.point, nil
+}
+
+// set updates the commitment to the given element.
+func (c *commitment) set(p *point) {
+	c.point, c.hash = p, p.bytes()
+}
+
+// scalar returns the commitment mapped to the scalar field, as it's committed
+// to by the parent node.
+func (c *commitment) scalar() (*big.Int, error) {
+	p, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+	return p.mapToScalar(), nil
+}
+
+// hashedNode is a node not loaded from the database yet, its commitment being
+// known from its parent.
+type hashedNode common.Hash
+
+// internalNode is a node committing to the 256 subtrees of the keys sharing
+// its path.
+type internalNode struct {
+	children [NodeWidth]node
+
+	commitment commitment
+	hashed     bool              // whether the commitment is computed, possibly before the changes
+	changed    map[byte]*big.Int // scalars of the changed children at the time of the commitment
+	dirty      bool              // whether the node needs to be written to the database
+}
+
+// leafNode is a node holding the 256 values of the keys sharing a stem. The
+// values are committed to in two halves, the node committing to the stem and
+// the commitments of the halves.
+type leafNode struct {
+	stem   []byte
+	values [NodeWidth][]byte
+
+	commitment commitment
+	c1, c2     commitment
+	hashed     bool            // whether the commitments are computed, possibly before the changes
+	changed    map[byte][]byte // values of the changed leaves at the time of the commitments
+	dirty      bool            // whether the node needs to be written to the database
+}
+
+// node is one of *internalNode, *leafNode or hashedNode.
+type node interface{}
+
+// Tree is a verkle tree backed by a database, loading the nodes on demand and
+// writing the changed ones on commit. It is safe for concurrent use.
+type Tree struct {
+	db   ethdb.KeyValueReader
+	root *internalNode
+	lock sync.Mutex
+}
+
+// NewTree opens the tree with the given root commitment, the zero hash being
+// the one of the empty tree.
+func NewTree(root common.Hash, db ethdb.KeyValueReader) (*Tree, error) {
+	t := &Tree{db: db}
+	if root == (common.Hash{}) {
+		t.root = &internalNode{hashed: true}
+		t.root.commitment.set(identity())
+		return t, nil
+	}
+	n, err := t.load(root)
+	if err != nil {
+		return nil, err
+	}
+	in, ok := n.(*internalNode)
+	if !ok {
+		return nil, errNotInternal
+	}
+	t.root = in
+	return t, nil
+}
+
+// load retrieves the node with the given commitment from the database.
+func (t *Tree) load(hash common.Hash) (node, error) {
+	blob := rawdb.ReadVerkleNode(t.db, hash)
+	if len(blob) == 0 {
+		return nil, &trie.MissingNodeError{NodeHash: hash}
+	}
+	n, err := decodeNode(blob)
+	if err != nil {
+		return nil, fmt.Errorf("verkle node %x: %w", hash, err)
+	}
+	switch n := n.(type) {
+	case *internalNode:
+		n.commitment.hash = hash
+	case *leafNode:
+		n.commitment.hash = hash
+	}
+	return n, nil
+}
+
+// resolve returns the child of an internal node, loading it if needed.
+func (t *Tree) resolve(n *internalNode, idx byte) (node, error) {
+	if hash, ok := n.children[idx].(hashedNode); ok {
+		child, err := t.load(common.Hash(hash))
+		if err != nil {
+			return nil, err
+		}
+		n.children[idx] = child
+	}
+	return n.children[idx], nil
+}
+
+// scalarOf returns the scalar a child is committed to with by its parent, the
+// child being unchanged since the commitment of the parent.
+func scalarOf(n node) (*big.Int, error) {
+	switch n := n.(type) {
+	case nil:
+		return new(big.Int), nil
+	case hashedNode:
+		c := commitment{hash: common.Hash(n)}
+		return c.scalar()
+	case *internalNode:
+		return n.commitment.scalar()
+	case *leafNode:
+		return n.commitment.scalar()
+	}
+	panic(fmt.Sprintf("unknown node type %T", n))
+}
+
+// touch marks the child of an internal node as about to change, tracking the
+// scalar it's committed to with.
+func (n *internalNode) touch(idx byte) error {
+	n.dirty = true
+	if !n.hashed {
+		return nil
+	}
+	if _, ok := n.changed[idx]; ok {
+		return nil
+	}
+	s, err := scalarOf(n.children[idx])
+	if err != nil {
+		return err
+	}
+	if n.changed == nil {
+		n.changed = make(map[byte]*big.Int)
+	}
+	n.changed[idx] = s
+	return nil
+}
+
+// set changes a value of a leaf, tracking its previous value.
+func (l *leafNode) set(idx byte, value []byte) {
+	l.dirty = true
+	if l.hashed {
+		if _, ok := l.changed[idx]; !ok {
+			if l.changed == nil {
+				l.changed = make(map[byte][]byte)
+			}
+			l.changed[idx] = l.values[idx]
+		}
+	}
+	l.values[idx] = value
+}
+
+// empty reports whether the leaf has no values left.
+func (l *leafNode) empty() bool {
+	for _, v := range l.values {
+		if v != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Get returns the value of the key, nil if it's not in the tree.
+func (t *Tree) Get(key []byte) ([]byte, error) {
+	if len(key) != KeyLength {
+		return nil, errInvalidKey
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.get(key)
+}
+
+func (t *Tree) get(key []byte) ([]byte, error) {
+	n := t.root
+	for depth := 0; depth < StemLength; depth++ {
+		child, err := t.resolve(n, key[depth])
+		if err != nil {
+			return nil, err
+		}
+		switch child := child.(type) {
+		case nil:
+			return nil, nil
+		case *leafNode:
+			if !bytes.Equal(child.stem, key[:StemLength]) {
+				return nil, nil
+			}
+			return child.values[key[StemLength]], nil
+		case *internalNode:
+			n = child
+		}
+	}
+	return nil, errInvalidNode
+}
+
+// Insert sets the value of the key.
+func (t *Tree) Insert(key, value []byte) error {
+	if len(key) != KeyLength {
+		return errInvalidKey
+	}
+	if len(value) != ValueLength {
+		return errInvalidValue
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if prev, err := t.get(key); err != nil || bytes.Equal(prev, value) {
+		return err
+	}
+	return t.insert(t.root, 0, key, common.CopyBytes(value))
+}
+
+func (t *Tree) insert(n *internalNode, depth int, key, value []byte) error {
+	idx := key[depth]
+	child, err := t.resolve(n, idx)
+	if err != nil {
+		return err
+	}
+	if err := n.touch(idx); err != nil {
+		return err
+	}
+	switch child := child.(type) {
+	case nil:
+		leaf := &leafNode{stem: common.CopyBytes(key[:StemLength]), dirty: true}
+		leaf.values[key[StemLength]] = value
+		n.children[idx] = leaf
+		return nil
+
+	case *leafNode:
+		if bytes.Equal(child.stem, key[:StemLength]) {
+			child.set(key[StemLength], value)
+			return nil
+		}
+		// The stems diverge deeper, push the leaf into a new internal node
+		in := &internalNode{dirty: true}
+		in.children[child.stem[depth+1]] = child
+		n.children[idx] = in
+		return t.insert(in, depth+1, key, value)
+
+	case *internalNode:
+		return t.insert(child, depth+1, key, value)
+	}
+	return errInvalidNode
+}
+
+// Delete removes the key from the tree.
+func (t *Tree) Delete(key []byte) error {
+	if len(key) != KeyLength {
+		return errInvalidKey
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if prev, err := t.get(key); err != nil || prev == nil {
+		return err
+	}
+	return t.delete(t.root, 0, key)
+}
+
+func (t *Tree) delete(n *internalNode, depth int, key []byte) error {
+	idx := key[depth]
+	child, err := t.resolve(n, idx)
+	if err != nil {
+		return err
+	}
+	if err := n.touch(idx); err != nil {
+		return err
+	}
+	switch child := child.(type) {
+	case *leafNode:
+		if child.set(key[StemLength], nil); child.empty() {
+			n.children[idx] = nil
+		}
+		return nil
+
+	case *internalNode:
+		if err := t.delete(child, depth+1, key); err != nil {
+			return err
+		}
+		// Keep the tree canonical, a subtree left with a single leaf is replaced
+		// by the leaf itself
+		var (
+			count int
+			last  byte
+		)
+		for i, c := range child.children {
+			if c != nil {
+				count, last = count+1, byte(i)
+			}
+		}
+		switch count {
+		case 0:
+			n.children[idx] = nil
+		case 1:
+			only, err := t.resolve(child, last)
+			if err != nil {
+				return err
+			}
+			if leaf, ok := only.(*leafNode); ok {
+				n.children[idx] = leaf
+			}
+		}
+		return nil
+	}
+	return errInvalidNode
+}
+
+// valueScalars returns the scalars of the lower and upper halves of a value
+// as committed to by a leaf, an absent value committing to zeros while the
+// present ones are marked.
+func valueScalars(value []byte) (lo, hi *big.Int) {
+	if value == nil {
+		return new(big.Int), new(big.Int)
+	}
+	lo = new(big.Int).SetBytes(reverse(value[:16]))
+	hi = new(big.Int).SetBytes(reverse(value[16:]))
+	return lo.Add(lo, leafMarker), hi
+}
+
+// reverse returns the bytes in the reverse order, converting between little
+// and big endian.
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
+
+// hashNode computes the commitment of a node and of its changed subtrees.
+func hashNode(n node) error {
+	switch n := n.(type) {
+	case *internalNode:
+		return n.hash()
+	case *leafNode:
+		return n.hash()
+	}
+	return nil
+}
+
+// hash computes the commitment of the internal node, only updating it with the
+// changed children if it was computed before.
+func (n *internalNode) hash() error {
+	if n.hashed && len(n.changed) == 0 {
+		return nil
+	}
+	var c *point
+	if !n.hashed {
+		scalars := make(map[int]*big.Int)
+		for i, child := range n.children {
+			if child == nil {
+				continue
+			}
+			if err := hashNode(child); err != nil {
+				return err
+			}
+			s, err := scalarOf(child)
+			if err != nil {
+				return err
+			}
+			scalars[i] = s
+		}
+		c = commit(scalars)
+	} else {
+		var err error
+		if c, err = n.commitment.get(); err != nil {
+			return err
+		}
+		for i, prev := range n.changed {
+			child := n.children[i]
+			if err := hashNode(child); err != nil {
+				return err
+			}
+			s, err := scalarOf(child)
+			if err != nil {
+				return err
+			}
+			c = commitDelta(c, int(i), prev, s)
+		}
+	}
+	n.commitment.set(c)
+	n.hashed, n.changed = true, nil
+	return nil
+}
+
+// hash computes the commitments of the leaf, only updating them with the
+// changed values if they were computed before.
+func (l *leafNode) hash() error {
+	if l.hashed && len(l.changed) == 0 {
+		return nil
+	}
+	if !l.hashed {
+		s1, s2 := make(map[int]*big.Int), make(map[int]*big.Int)
+		for i, v := range l.values {
+			if v == nil {
+				continue
+			}
+			scalars, j := s1, i
+			if i >= NodeWidth/2 {
+				scalars, j = s2, i-NodeWidth/2
+			}
+			scalars[2*j], scalars[2*j+1] = valueScalars(v)
+		}
+		c1, c2 := commit(s1), commit(s2)
+		l.c1.set(c1)
+		l.c2.set(c2)
+		l.commitment.set(commit(map[int]*big.Int{
+			0: extensionMarker,
+			1: new(big.Int).SetBytes(reverse(l.stem)),
+			2: c1.mapToScalar(),
+			3: c2.mapToScalar(),
+		}))
+		l.hashed, l.changed = true, nil
+		return nil
+	}
+	c, err := l.commitment.get()
+	if err != nil {
+		return err
+	}
+	halves := [2]*commitment{&l.c1, &l.c2}
+	var (
+		points [2]*point
+		prev   [2]*big.Int
+	)
+	for i, old := range l.changed {
+		half, j := 0, int(i)
+		if j >= NodeWidth/2 {
+			half, j = 1, j-NodeWidth/2
+		}
+		if points[half] == nil {
+			if points[half], err = halves[half].get(); err != nil {
+				return err
+			}
+			prev[half] = points[half].mapToScalar()
+		}
+		oldLo, oldHi := valueScalars(old)
+		newLo, newHi := valueScalars(l.values[i])
+		points[half] = commitDelta(points[half], 2*j, oldLo, newLo)
+		points[half] = commitDelta(points[half], 2*j+1, oldHi, newHi)
+	}
+	for half := range halves {
+		if points[half] != nil {
+			halves[half].set(points[half])
+			c = commitDelta(c, 2+half, prev[half], points[half].mapToScalar())
+		}
+	}
+	l.commitment.set(c)
+	l.changed = nil
+	return nil
+}
+
+// Hash returns the root commitment of the tree.
+func (t *Tree) Hash() (common.Hash, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if err := t.root.hash(); err != nil {
+		return common.Hash{}, err
+	}
+	return t.root.commitment.hash, nil
+}
+
+// Commit writes the changed nodes into the database, returning the root
+// commitment and the number of nodes written. The nodes of the previous
+// versions of the tree are kept.
+func (t *Tree) Commit(db ethdb.KeyValueWriter) (common.Hash, int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if err := t.root.hash(); err != nil {
+		return common.Hash{}, 0, err
+	}
+	root := t.root.commitment.hash
+	if root == (common.Hash{}) {
+		// The empty tree has nothing to store, and its root is ambiguous with
+		// the one of the empty merkle patricia trie
+		t.root.dirty = false
+		return root, 0, nil
+	}
+	return root, store(t.root, db), nil
+}
+
+// store writes the changed nodes of a subtree into the database.
+func store(n node, db ethdb.KeyValueWriter) int {
+	switch n := n.(type) {
+	case *internalNode:
+		if !n.dirty {
+			return 0
+		}
+		var count int
+		for _, child := range n.children {
+			count += store(child, db)
+		}
+		rawdb.WriteVerkleNode(db, n.commitment.hash, n.encode())
+		n.dirty = false
+		return count + 1
+
+	case *leafNode:
+		if !n.dirty {
+			return 0
+		}
+		rawdb.WriteVerkleNode(db, n.commitment.hash, n.encode())
+		n.dirty = false
+		return 1
+	}
+	return 0
+}
+
+// Copy returns an independent copy of the tree.
+func (t *Tree) Copy() *Tree {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return &Tree{db: t.db, root: t.root.copy()}
+}
+
+func (n *internalNode) copy() *internalNode {
+	cpy := *n
+	for i, child := range n.children {
+		switch child := child.(type) {
+		case *internalNode:
+			cpy.children[i] = child.copy()
+		case *leafNode:
+			cpy.children[i] = child.copy()
+		}
+	}
+	if n.changed != nil {
+		cpy.changed = make(map[byte]*big.Int, len(n.changed))
+		for i, s := range n.changed {
+			cpy.changed[i] = s
+		}
+	}
+	return &cpy
+}
+
+func (l *leafNode) copy() *leafNode {
+	cpy := *l
+	if l.changed != nil {
+		cpy.changed = make(map[byte][]byte, len(l.changed))
+		for i, v := range l.changed {
+			cpy.changed[i] = v
+		}
+	}
+	return &cpy
+}
+
+// encode serializes an internal node as its type, the bitmap of its children
+// and their commitments.
+func (n *internalNode) encode() []byte {
+	blob := make([]byte, 1+bitmapLength, 1+bitmapLength+NodeWidth*32)
+	blob[0] = internalNodeType
+	for i, child := range n.children {
+		var hash common.Hash
+		switch child := child.(type) {
+		case nil:
+			continue
+		case hashedNode:
+			hash = common.Hash(child)
+		case *internalNode:
+			hash = child.commitment.hash
+		case *leafNode:
+			hash = child.commitment.hash
+		}
+		blob[1+i/8] |= 1 << (i % 8)
+		blob = append(blob, hash[:]...)
+	}
+	return blob
+}
+
+// encode serializes a leaf node as its type, its stem, the bitmap of its values,
+// the commitments of the halves and the values.
+func (l *leafNode) encode() []byte {
+	blob := make([]byte, leafHeaderLength, leafHeaderLength+NodeWidth*ValueLength)
+	blob[0] = leafNodeType
+	copy(blob[1:], l.stem)
+	copy(blob[1+StemLength+bitmapLength:], l.c1.hash[:])
+	copy(blob[1+StemLength+bitmapLength+32:], l.c2.hash[:])
+	for i, v := range l.values {
+		if v != nil {
+			blob[1+StemLength+i/8] |= 1 << (i % 8)
+			blob = append(blob, v...)
+		}
+	}
+	return blob
+}
+
+// decodeNode parses a serialized node, its children left unresolved.
+func decodeNode(blob []byte) (node, error) {
+	switch {
+	case len(blob) >= 1+bitmapLength && blob[0] == internalNodeType:
+		n := &internalNode{hashed: true}
+		bitmap, rest := blob[1:1+bitmapLength], blob[1+bitmapLength:]
+		for i := 0; i < NodeWidth; i++ {
+			if bitmap[i/8]&(1<<(i%8)) == 0 {
+				continue
+			}
+			if len(rest) < 32 {
+				return nil, errInvalidNode
+			}
+			n.children[i] = hashedNode(common.BytesToHash(rest[:32]))
+			rest = rest[32:]
+		}
+		if len(rest) != 0 {
+			return nil, errInvalidNode
+		}
+		return n, nil
+
+	case len(blob) >= leafHeaderLength && blob[0] == leafNodeType:
+		l := &leafNode{stem: common.CopyBytes(blob[1 : 1+StemLength]), hashed: true}
+		bitmap := blob[1+StemLength : 1+StemLength+bitmapLength]
+		copy(l.c1.hash[:], blob[1+StemLength+bitmapLength:])
+		copy(l.c2.hash[:], blob[1+StemLength+bitmapLength+32:])
+
+		rest := blob[leafHeaderLength:]
+		for i := 0; i < NodeWidth; i++ {
+			if bitmap[i/8]&(1<<(i%8)) == 0 {
+				continue
+			}
+			if len(rest) < ValueLength {
+				return nil, errInvalidNode
+			}
+			l.values[i] = common.CopyBytes(rest[:ValueLength])
+			rest = rest[ValueLength:]
+		}
+		if len(rest) != 0 {
+			return nil, errInvalidNode
+		}
+		return l, nil
+	}
+	return nil, errInvalidNode
+}
@@ -0,0 +1,245 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package verkle
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+)
+
+// randomEntries returns keys with random values, sharing stems and prefixes of
+// stems so that the leaves are split and merged.
+func randomEntries(rng *rand.Rand, n int) map[string][]byte {
+	entries := make(map[string][]byte)
+	var last []byte
+	for len(entries) < n {
+		key := make([]byte, KeyLength)
+		rng.Read(key)
+		if last != nil {
+			switch rng.Intn(3) {
+			case 0: // same stem
+				copy(key, last[:StemLength])
+			case 1: // shared prefix of the stem
+				copy(key, last[:1+rng.Intn(StemLength-1)])
+			}
+		}
+		value := make([]byte, ValueLength)
+		rng.Read(value)
+		entries[string(key)] = value
+		last = key
+	}
+	return entries
+}
+
+// buildTree inserts the entries into a fresh tree in a random order.
+func buildTree(t *testing.T, rng *rand.Rand, entries map[string][]byte) *Tree {
+	tree, _ := NewTree(common.Hash{}, rawdb.NewMemoryDatabase())
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	rng.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+	for _, key := range keys {
+		if err := tree.Insert([]byte(key), entries[key]); err != nil {
+			t.Fatalf("failed to insert %x: %v", key, err)
+		}
+	}
+	return tree
+}
+
+func mustHash(t *testing.T, tree *Tree) common.Hash {
+	root, err := tree.Hash()
+	if err != nil {
+		t.Fatalf("failed to hash tree: %v", err)
+	}
+	return root
+}
+
+// Tests that the inserted values are retrieved and the absent ones aren't.
+func TestTreeGet(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	entries := randomEntries(rng, 200)
+	tree := buildTree(t, rng, entries)
+
+	for key, want := range entries {
+		if have, err := tree.Get([]byte(key)); err != nil || !bytes.Equal(have, want) {
+			t.Fatalf("value of %x mismatch: have %x, want %x, err %v", key, have, want, err)
+		}
+		// The neighbour key in the same leaf
+		other := []byte(key)
+		other[StemLength]++
+		if _, ok := entries[string(other)]; !ok {
+			if have, err := tree.Get(other); err != nil || have != nil {
+				t.Fatalf("absent key %x found: %x, err %v", other, have, err)
+			}
+		}
+	}
+	if err := tree.Insert(make([]byte, 31), make([]byte, ValueLength)); err != errInvalidKey {
+		t.Errorf("short key accepted: %v", err)
+	}
+	if err := tree.Insert(make([]byte, KeyLength), make([]byte, 31)); err != errInvalidValue {
+		t.Errorf("short value accepted: %v", err)
+	}
+}
+
+// Tests that the root only depends on the content of the tree, not on the order
+// of the updates, the intermediate commitments and the deleted keys, and that
+// the incrementally updated commitments match the ones computed from scratch.
+func TestTreeCanonical(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	entries := randomEntries(rng, 200)
+	want := mustHash(t, buildTree(t, rng, entries))
+
+	if have := mustHash(t, buildTree(t, rng, entries)); have != want {
+		t.Fatalf("root depends on the insertion order: have %x, want %x", have, want)
+	}
+	// Start from other entries, overwrite the wanted ones with other values and
+	// then the right ones, deleting the others, hashing in between
+	extra := randomEntries(rng, 100)
+	tree := buildTree(t, rng, extra)
+	mustHash(t, tree)
+	for key := range entries {
+		value := make([]byte, ValueLength)
+		rng.Read(value)
+		tree.Insert([]byte(key), value)
+	}
+	mustHash(t, tree)
+	for key, value := range entries {
+		tree.Insert([]byte(key), value)
+	}
+	for key := range extra {
+		if _, ok := entries[key]; !ok {
+			tree.Delete([]byte(key))
+		}
+	}
+	if have := mustHash(t, tree); have != want {
+		t.Fatalf("root depends on the update history: have %x, want %x", have, want)
+	}
+}
+
+// Tests that deleting every key returns to the empty root, and that deleting
+// keys gives the root of the tree built without them.
+func TestTreeDelete(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	entries := randomEntries(rng, 200)
+	tree := buildTree(t, rng, entries)
+	mustHash(t, tree)
+
+	kept := make(map[string][]byte)
+	for key, value := range entries {
+		if rng.Intn(2) == 0 {
+			kept[key] = value
+			continue
+		}
+		if err := tree.Delete([]byte(key)); err != nil {
+			t.Fatalf("failed to delete %x: %v", key, err)
+		}
+		if rng.Intn(10) == 0 {
+			mustHash(t, tree)
+		}
+	}
+	if have, want := mustHash(t, tree), mustHash(t, buildTree(t, rng, kept)); have != want {
+		t.Fatalf("root after deletions mismatch: have %x, want %x", have, want)
+	}
+	for key := range kept {
+		tree.Delete([]byte(key))
+	}
+	if root := mustHash(t, tree); root != (common.Hash{}) {
+		t.Fatalf("root of the emptied tree: %x", root)
+	}
+	// Deleting absent keys is a noop
+	if err := tree.Delete(make([]byte, KeyLength)); err != nil {
+		t.Fatalf("failed to delete absent key: %v", err)
+	}
+}
+
+// Tests that a committed tree is reopened from the database with its content,
+// further updates of the lazily loaded nodes yielding the same root as the
+// updates in memory.
+func TestTreeCommit(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	entries := randomEntries(rng, 200)
+	db := rawdb.NewMemoryDatabase()
+	tree := buildTree(t, rng, entries)
+
+	root, committed, err := tree.Commit(db)
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if committed == 0 || !rawdb.HasVerkleNode(db, root) {
+		t.Fatalf("root not stored, %d nodes committed", committed)
+	}
+	reopened, err := NewTree(root, db)
+	if err != nil {
+		t.Fatalf("failed to reopen tree: %v", err)
+	}
+	for key, want := range entries {
+		if have, err := reopened.Get([]byte(key)); err != nil || !bytes.Equal(have, want) {
+			t.Fatalf("value of %x mismatch: have %x, want %x, err %v", key, have, want, err)
+		}
+	}
+	// Apply the same updates to both trees
+	reopened, _ = NewTree(root, db)
+	for key := range entries {
+		switch rng.Intn(3) {
+		case 0:
+			tree.Delete([]byte(key))
+			reopened.Delete([]byte(key))
+		case 1:
+			tree.Insert([]byte(key), common.Hash{1}.Bytes())
+			reopened.Insert([]byte(key), common.Hash{1}.Bytes())
+		}
+	}
+	if have, want := mustHash(t, reopened), mustHash(t, tree); have != want {
+		t.Fatalf("root of the reopened tree mismatch: have %x, want %x", have, want)
+	}
+	// Commit the updated tree on top of the previous one
+	updated, _, err := reopened.Commit(db)
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if _, err := NewTree(updated, db); err != nil {
+		t.Fatalf("failed to reopen updated tree: %v", err)
+	}
+	if _, err := NewTree(common.Hash{0xff}, db); err == nil {
+		t.Fatal("missing root opened")
+	}
+}
+
+// Tests that a copy of a tree is independent of the original.
+func TestTreeCopy(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	entries := randomEntries(rng, 50)
+	tree := buildTree(t, rng, entries)
+	root := mustHash(t, tree)
+
+	cpy := tree.Copy()
+	for key := range entries {
+		cpy.Insert([]byte(key), common.Hash{1}.Bytes())
+		break
+	}
+	if mustHash(t, cpy) == root {
+		t.Fatal("copy not updated")
+	}
+	if have := mustHash(t, tree); have != root {
+		t.Fatalf("original changed by the copy: have %x, want %x", have, root)
+	}
+}
@@ -0,0 +1,362 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package verkle
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	errUnsupported = errors.New("not supported by the verkle tree")
+
+	emptyCodeHash = crypto.Keccak256Hash(nil)
+)
+
+// Trie is the account trie of a verkle state. The accounts, their code and
+// storage are kept in a single tree, the storage tries being views into it.
+type Trie struct {
+	tree *Tree
+	db   ethdb.KeyValueStore
+
+	stems map[common.Address][]byte // stems of the account headers, expensive to derive
+	lock  sync.Mutex
+}
+
+// New opens the verkle state with the given root.
+func New(root common.Hash, db ethdb.KeyValueStore) (*Trie, error) {
+	tree, err := NewTree(root, db)
+	if err != nil {
+		return nil, err
+	}
+	return &Trie{tree: tree, db: db, stems: make(map[common.Address][]byte)}, nil
+}
+
+// IsRoot reports whether the root is the one of a verkle state stored in the
+// database.
+func IsRoot(db ethdb.KeyValueReader, root common.Hash) bool {
+	return root != (common.Hash{}) && rawdb.HasVerkleNode(db, root)
+}
+
+// headerKey returns the key of a leaf of the header stem of the account.
+func (t *Trie) headerKey(addr common.Address, leaf byte) []byte {
+	t.lock.Lock()
+	stem, ok := t.stems[addr]
+	t.lock.Unlock()
+
+	if !ok {
+		stem = HeaderKey(addr, 0)[:StemLength]
+
+		t.lock.Lock()
+		t.stems[addr] = stem
+		t.lock.Unlock()
+	}
+	return append(common.CopyBytes(stem), leaf)
+}
+
+// storageKey returns the key of a storage slot of the account, reusing the
+// header stem for the slots kept in it.
+func (t *Trie) storageKey(addr common.Address, slot common.Hash) []byte {
+	if pos := new(big.Int).SetBytes(slot[:]); pos.Cmp(headerStorageCap) < 0 {
+		return t.headerKey(addr, byte(headerStorageOffset+pos.Uint64()))
+	}
+	return StorageKey(addr, slot)
+}
+
+// leValue returns the 32 byte little endian encoding of an integer.
+func leValue(v *big.Int) []byte {
+	return scalarBytes(v)
+}
+
+// uintValue returns the 32 byte little endian encoding of an integer.
+func uintValue(v uint64) []byte {
+	buf := make([]byte, ValueLength)
+	binary.LittleEndian.PutUint64(buf, v)
+	return buf
+}
+
+// GetKey returns nil, the keys of the verkle tree have no preimages.
+func (t *Trie) GetKey([]byte) []byte {
+	return nil
+}
+
+// TryGet returns the RLP encoded account with the given address, assembled from
+// the leaves of its header.
+func (t *Trie) TryGet(key []byte) ([]byte, error) {
+	acc, err := t.account(common.BytesToAddress(key))
+	if acc == nil || err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(acc)
+}
+
+// account retrieves the account with the given address, nil if it doesn't
+// exist.
+func (t *Trie) account(addr common.Address) (*types.StateAccount, error) {
+	var values [CodeHashLeafKey + 1][]byte
+	for _, leaf := range []byte{BalanceLeafKey, NonceLeafKey, CodeHashLeafKey} {
+		v, err := t.tree.Get(t.headerKey(addr, leaf))
+		if err != nil {
+			return nil, err
+		}
+		values[leaf] = v
+	}
+	if values[BalanceLeafKey] == nil && values[NonceLeafKey] == nil && values[CodeHashLeafKey] == nil {
+		return nil, nil
+	}
+	acc := &types.StateAccount{
+		Balance:  new(big.Int).SetBytes(reverse(values[BalanceLeafKey])),
+		Root:     types.EmptyRootHash,
+		CodeHash: emptyCodeHash.Bytes(),
+	}
+	if nonce := values[NonceLeafKey]; nonce != nil {
+		acc.Nonce = binary.LittleEndian.Uint64(nonce)
+	}
+	if hash := values[CodeHashLeafKey]; hash != nil {
+		acc.CodeHash = common.CopyBytes(hash)
+	}
+	return acc, nil
+}
+
+// TryUpdateAccount writes the header of the account with the given address. The
+// code size is written with the code, accounts without code having it zero.
+func (t *Trie) TryUpdateAccount(key []byte, acc *types.StateAccount) error {
+	addr := common.BytesToAddress(key)
+	values := map[byte][]byte{
+		VersionLeafKey:  make([]byte, ValueLength),
+		BalanceLeafKey:  leValue(acc.Balance),
+		NonceLeafKey:    uintValue(acc.Nonce),
+		CodeHashLeafKey: common.BytesToHash(acc.CodeHash).Bytes(),
+	}
+	if common.BytesToHash(acc.CodeHash) == emptyCodeHash {
+		values[CodeSizeLeafKey] = uintValue(0)
+	}
+	for leaf, v := range values {
+		if err := t.tree.Insert(t.headerKey(addr, leaf), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UpdateCode writes the code of the account with the given address in chunks,
+// dropping the chunks of a previous longer code.
+func (t *Trie) UpdateCode(addr common.Address, code []byte) error {
+	prev, err := t.codeChunks(addr)
+	if err != nil {
+		return err
+	}
+	chunks := ChunkifyCode(code)
+	for i, chunk := range chunks {
+		if err := t.tree.Insert(CodeChunkKey(addr, uint64(i)), chunk); err != nil {
+			return err
+		}
+	}
+	for i := uint64(len(chunks)); i < prev; i++ {
+		if err := t.tree.Delete(CodeChunkKey(addr, i)); err != nil {
+			return err
+		}
+	}
+	return t.tree.Insert(t.headerKey(addr, CodeSizeLeafKey), uintValue(uint64(len(code))))
+}
+
+// codeChunks returns the number of code chunks stored for the account.
+func (t *Trie) codeChunks(addr common.Address) (uint64, error) {
+	size, err := t.tree.Get(t.headerKey(addr, CodeSizeLeafKey))
+	if size == nil || err != nil {
+		return 0, err
+	}
+	return (binary.LittleEndian.Uint64(size) + 30) / 31, nil
+}
+
+// TryUpdate is not supported, the accounts are written by TryUpdateAccount.
+func (t *Trie) TryUpdate(key, value []byte) error {
+	return errUnsupported
+}
+
+// TryDelete removes the header and the code of the account with the given
+// address. Its storage is left in the tree, as the slots of an account can't
+// be enumerated.
+func (t *Trie) TryDelete(key []byte) error {
+	addr := common.BytesToAddress(key)
+	chunks, err := t.codeChunks(addr)
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < chunks; i++ {
+		if err := t.tree.Delete(CodeChunkKey(addr, i)); err != nil {
+			return err
+		}
+	}
+	for leaf := byte(VersionLeafKey); leaf <= CodeSizeLeafKey; leaf++ {
+		if err := t.tree.Delete(t.headerKey(addr, leaf)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Hash returns the root commitment of the tree.
+func (t *Trie) Hash() common.Hash {
+	root, err := t.tree.Hash()
+	if err != nil {
+		log.Error("Failed to hash verkle tree", "err", err)
+	}
+	return root
+}
+
+// Commit writes the changed nodes of the tree into the database.
+func (t *Trie) Commit(onleaf trie.LeafCallback) (common.Hash, int, error) {
+	batch := t.db.NewBatch()
+	root, committed, err := t.tree.Commit(batch)
+	if err != nil {
+		return common.Hash{}, 0, err
+	}
+	if err := batch.Write(); err != nil {
+		return common.Hash{}, 0, err
+	}
+	return root, committed, nil
+}
+
+// CommitState is like Commit, the verkle state being kept by commitment.
+func (t *Trie) CommitState(state common.Hash, onleaf trie.LeafCallback) (common.Hash, int, error) {
+	return t.Commit(onleaf)
+}
+
+// NodeIterator returns an iterator failing right away, the tree can't be
+// iterated.
+func (t *Trie) NodeIterator(startKey []byte) trie.NodeIterator {
+	return nodeIterator{}
+}
+
+// Prove is not supported.
+func (t *Trie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error {
+	return errUnsupported
+}
+
+// Copy returns an independent copy of the trie.
+func (t *Trie) Copy() *Trie {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	stems := make(map[common.Address][]byte, len(t.stems))
+	for addr, stem := range t.stems {
+		stems[addr] = stem
+	}
+	return &Trie{tree: t.tree.Copy(), db: t.db, stems: stems}
+}
+
+// StorageTrie returns the view of the storage of the account with the given
+// address.
+func (t *Trie) StorageTrie(addr common.Address) *StorageTrie {
+	return &StorageTrie{trie: t, addr: addr}
+}
+
+// StorageTrie is the storage trie of an account of a verkle state, a view into
+// the tree of the state. The storage has no root of its own, it's committed
+// with the state.
+type StorageTrie struct {
+	trie *Trie
+	addr common.Address
+}
+
+// GetKey returns nil, the keys of the verkle tree have no preimages.
+func (t *StorageTrie) GetKey([]byte) []byte {
+	return nil
+}
+
+// TryGet returns the RLP encoded value of the given slot.
+func (t *StorageTrie) TryGet(key []byte) ([]byte, error) {
+	v, err := t.trie.tree.Get(t.trie.storageKey(t.addr, common.BytesToHash(key)))
+	if v == nil || err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(common.TrimLeftZeroes(v))
+}
+
+// TryUpdateAccount is not supported by storage tries.
+func (t *StorageTrie) TryUpdateAccount(key []byte, acc *types.StateAccount) error {
+	return errUnsupported
+}
+
+// TryUpdate sets the given slot to the RLP encoded value.
+func (t *StorageTrie) TryUpdate(key, value []byte) error {
+	_, content, _, err := rlp.Split(value)
+	if err != nil {
+		return err
+	}
+	return t.trie.tree.Insert(t.trie.storageKey(t.addr, common.BytesToHash(key)), common.LeftPadBytes(content, ValueLength))
+}
+
+// TryDelete clears the given slot.
+func (t *StorageTrie) TryDelete(key []byte) error {
+	return t.trie.tree.Delete(t.trie.storageKey(t.addr, common.BytesToHash(key)))
+}
+
+// Hash returns the root of the empty trie, the storage being committed to by
+// the state.
+func (t *StorageTrie) Hash() common.Hash {
+	return types.EmptyRootHash
+}
+
+// Commit does nothing, the storage is committed with the state.
+func (t *StorageTrie) Commit(onleaf trie.LeafCallback) (common.Hash, int, error) {
+	return types.EmptyRootHash, 0, nil
+}
+
+// CommitState does nothing, the storage is committed with the state.
+func (t *StorageTrie) CommitState(state common.Hash, onleaf trie.LeafCallback) (common.Hash, int, error) {
+	return types.EmptyRootHash, 0, nil
+}
+
+// NodeIterator returns an iterator failing right away, the tree can't be
+// iterated.
+func (t *StorageTrie) NodeIterator(startKey []byte) trie.NodeIterator {
+	return nodeIterator{}
+}
+
+// Prove is not supported.
+func (t *StorageTrie) Prove(key []byte, fromLevel uint, proofDb ethdb.KeyValueWriter) error {
+	return errUnsupported
+}
+
+// nodeIterator is the iterator of the verkle tries, which ends right away with
+// an error.
+type nodeIterator struct{}
+
+func (nodeIterator) Next(bool) bool                   { return false }
+func (nodeIterator) Error() error                     { return errUnsupported }
+func (nodeIterator) Hash() common.Hash                { return common.Hash{} }
+func (nodeIterator) Parent() common.Hash              { return common.Hash{} }
+func (nodeIterator) Path() []byte                     { return nil }
+func (nodeIterator) NodeBlob() []byte                 { return nil }
+func (nodeIterator) Leaf() bool                       { return false }
+func (nodeIterator) LeafKey() []byte                  { return nil }
+func (nodeIterator) LeafBlob() []byte                 { return nil }
+func (nodeIterator) LeafProof() [][]byte              { return nil }
+func (nodeIterator) AddResolver(ethdb.KeyValueReader) {}
@@ -0,0 +1,189 @@
+// Copyright 2022 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package verkle
+
+import (
+	"bytes"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Tests the layout of the keys of an account.
+func TestKeys(t *testing.T) {
+	addr := common.HexToAddress("0x71562b71999873db5b286df957af199ec94617f7")
+	header := HeaderKey(addr, VersionLeafKey)
+
+	for _, tt := range []struct {
+		key  []byte
+		stem bool // whether the key is in the header stem
+		leaf byte
+	}{
+		{HeaderKey(addr, CodeSizeLeafKey), true, CodeSizeLeafKey},
+		{StorageKey(addr, common.Hash{}), true, 64},
+		{StorageKey(addr, common.BigToHash(big.NewInt(63))), true, 127},
+		{StorageKey(addr, common.BigToHash(big.NewInt(64))), false, 64},
+		{CodeChunkKey(addr, 0), true, 128},
+		{CodeChunkKey(addr, 127), true, 255},
+		{CodeChunkKey(addr, 128), false, 0},
+	} {
+		if have := bytes.Equal(tt.key[:StemLength], header[:StemLength]); have != tt.stem {
+			t.Errorf("key %x in header stem: have %v, want %v", tt.key, have, tt.stem)
+		}
+		if tt.key[StemLength] != tt.leaf {
+			t.Errorf("key %x leaf mismatch: have %d, want %d", tt.key, tt.key[StemLength], tt.leaf)
+		}
+	}
+	if bytes.Equal(header[:StemLength], HeaderKey(common.Address{1}, VersionLeafKey)[:StemLength]) {
+		t.Error("accounts share their header stem")
+	}
+}
+
+// Tests that the code is chunked with the count of the leading push data.
+func TestChunkifyCode(t *testing.T) {
+	// 30 jumpdests, then a push32 crossing the two next chunks
+	code := bytes.Repeat([]byte{0x5b}, 30)
+	code = append(code, push32)
+	code = append(code, bytes.Repeat([]byte{push1}, 32)...)
+	code = append(code, push1+1)
+
+	chunks := ChunkifyCode(code)
+	if len(chunks) != 3 {
+		t.Fatalf("chunk count mismatch: have %d, want 3", len(chunks))
+	}
+	for i, want := range []byte{0, 31, 1} {
+		if chunks[i][0] != want {
+			t.Errorf("chunk %d push data mismatch: have %d, want %d", i, chunks[i][0], want)
+		}
+		content := make([]byte, 31)
+		copy(content, code[31*i:])
+		if !bytes.Equal(chunks[i][1:], content) {
+			t.Errorf("chunk %d content mismatch: %x", i, chunks[i][1:])
+		}
+	}
+	if len(ChunkifyCode(nil)) != 0 {
+		t.Error("chunks of empty code")
+	}
+}
+
+// Tests that the accounts, their code and storage are written in the tree and
+// read back after a commit.
+func TestTrieAccounts(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	tr, _ := New(common.Hash{}, db)
+
+	var (
+		addr = common.Address{1}
+		code = bytes.Repeat([]byte{push1, 0x01}, 100)
+		acc  = &types.StateAccount{
+			Nonce:    3,
+			Balance:  big.NewInt(1000000),
+			Root:     types.EmptyRootHash,
+			CodeHash: crypto.Keccak256(code),
+		}
+		slots = []common.Hash{{}, common.BigToHash(big.NewInt(100)), {0xff}}
+	)
+	if err := tr.TryUpdateAccount(addr[:], acc); err != nil {
+		t.Fatalf("failed to write account: %v", err)
+	}
+	if err := tr.UpdateCode(addr, code); err != nil {
+		t.Fatalf("failed to write code: %v", err)
+	}
+	storage := tr.StorageTrie(addr)
+	for i, slot := range slots {
+		value, _ := rlp.EncodeToBytes(common.TrimLeftZeroes(big.NewInt(int64(i + 1)).Bytes()))
+		if err := storage.TryUpdate(slot[:], value); err != nil {
+			t.Fatalf("failed to write slot %x: %v", slot, err)
+		}
+	}
+	root, _, err := tr.Commit(nil)
+	if err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if !IsRoot(db, root) || IsRoot(db, types.EmptyRootHash) {
+		t.Fatalf("root %x not recognized", root)
+	}
+	// Read everything back from the database
+	tr, err = New(root, db)
+	if err != nil {
+		t.Fatalf("failed to open trie: %v", err)
+	}
+	blob, err := tr.TryGet(addr[:])
+	if err != nil {
+		t.Fatalf("failed to read account: %v", err)
+	}
+	have := new(types.StateAccount)
+	if err := rlp.DecodeBytes(blob, have); err != nil {
+		t.Fatalf("failed to decode account: %v", err)
+	}
+	if !reflect.DeepEqual(have, acc) {
+		t.Fatalf("account mismatch: have %+v, want %+v", have, acc)
+	}
+	if chunks, _ := tr.codeChunks(addr); chunks != 7 {
+		t.Fatalf("code chunk count mismatch: have %d, want 7", chunks)
+	}
+	storage = tr.StorageTrie(addr)
+	for i, slot := range slots {
+		blob, err := storage.TryGet(slot[:])
+		if err != nil {
+			t.Fatalf("failed to read slot %x: %v", slot, err)
+		}
+		var value []byte
+		rlp.DecodeBytes(blob, &value)
+		if new(big.Int).SetBytes(value).Int64() != int64(i+1) {
+			t.Fatalf("slot %x mismatch: have %x", slot, value)
+		}
+	}
+	if blob, _ := storage.TryGet(common.Hash{1}.Bytes()); blob != nil {
+		t.Fatalf("absent slot found: %x", blob)
+	}
+	// Shrink the code, then delete the account, leaving the storage only
+	copied := tr.Copy()
+	if err := tr.UpdateCode(addr, code[:40]); err != nil {
+		t.Fatalf("failed to shrink code: %v", err)
+	}
+	if v, _ := tr.tree.Get(CodeChunkKey(addr, 2)); v != nil {
+		t.Fatal("stale code chunk left")
+	}
+	if err := tr.TryDelete(addr[:]); err != nil {
+		t.Fatalf("failed to delete account: %v", err)
+	}
+	if blob, _ := tr.TryGet(addr[:]); blob != nil {
+		t.Fatalf("deleted account found: %x", blob)
+	}
+	if v, _ := tr.tree.Get(CodeChunkKey(addr, 0)); v != nil {
+		t.Fatal("code of the deleted account left")
+	}
+	// Only the storage remains, which the copy isn't affected by
+	only, _ := New(common.Hash{}, rawdb.NewMemoryDatabase())
+	for i, slot := range slots {
+		value, _ := rlp.EncodeToBytes(big.NewInt(int64(i + 1)).Bytes())
+		only.StorageTrie(addr).TryUpdate(slot[:], value)
+	}
+	if have, want := tr.Hash(), only.Hash(); have != want {
+		t.Fatalf("root after deletion mismatch: have %x, want %x", have, want)
+	}
+	if copied.Hash() != root {
+		t.Fatal("copy changed by the original")
+	}
+}